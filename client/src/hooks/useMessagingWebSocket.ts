@@ -318,7 +318,8 @@ export function useMessagingWebSocket({
 
     setStatus('connecting');
 
-    const ws = new WebSocket(wsUrl);
+    // Browsers can't set headers on the upgrade, so the token rides in the subprotocol
+    const ws = new WebSocket(wsUrl, ['bearer', token]);
     wsRef.current = ws;
 
     ws.onopen = () => {
@@ -7,7 +7,7 @@
 
 import { useEffect, useRef, useCallback, useState } from 'react';
 import { useQueryClient } from '@tanstack/react-query';
-import { queryKeys } from '@/lib/api';
+import { queryKeys, tokenStorage } from '@/lib/api';
 import type { WSEventType, WSMessage, WSSubscribeMessage } from '@/types/showroom';
 
 interface UseShowroomWebSocketOptions {
@@ -118,8 +118,16 @@ export function useShowroomWebSocket({
       wsRef.current.close();
     }
 
+    // Get auth token
+    const token = tokenStorage.getToken();
+    if (!token) {
+      setState((prev) => ({ ...prev, error: 'Not authenticated' }));
+      return;
+    }
+
     try {
-      const ws = new WebSocket(WS_URL);
+      // Browsers can't set headers on the upgrade, so the token rides in the subprotocol
+      const ws = new WebSocket(WS_URL, ['bearer', token]);
       wsRef.current = ws;
 
       ws.onopen = () => {
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"autolytiq/shared/logging"
+
+	"github.com/gorilla/mux"
+)
+
+// Access log field names
+const (
+	AccessLogFieldTimestamp    = "timestamp"
+	AccessLogFieldMethod       = "method"
+	AccessLogFieldRoute        = "route"
+	AccessLogFieldStatus       = "status"
+	AccessLogFieldBytes        = "bytes"
+	AccessLogFieldLatencyMs    = "latency_ms"
+	AccessLogFieldUserID       = "user_id"
+	AccessLogFieldDealershipID = "dealership_id"
+	AccessLogFieldClientIP     = "client_ip"
+	AccessLogFieldRequestID    = "request_id"
+	AccessLogFieldTraceID      = "trace_id"
+	AccessLogFieldUserAgent    = "user_agent"
+)
+
+// DefaultAccessLogFields are the fields written when none are configured
+var DefaultAccessLogFields = []string{
+	AccessLogFieldTimestamp,
+	AccessLogFieldMethod,
+	AccessLogFieldRoute,
+	AccessLogFieldStatus,
+	AccessLogFieldBytes,
+	AccessLogFieldLatencyMs,
+	AccessLogFieldUserID,
+	AccessLogFieldDealershipID,
+	AccessLogFieldClientIP,
+	AccessLogFieldRequestID,
+	AccessLogFieldTraceID,
+}
+
+// accessLogFields is the set of fields that may be configured. Raw paths,
+// query strings, headers and bodies are deliberately absent since they may
+// carry tokens or PII; the route is logged as its path template instead.
+var accessLogFields = map[string]bool{
+	AccessLogFieldTimestamp:    true,
+	AccessLogFieldMethod:       true,
+	AccessLogFieldRoute:        true,
+	AccessLogFieldStatus:       true,
+	AccessLogFieldBytes:        true,
+	AccessLogFieldLatencyMs:    true,
+	AccessLogFieldUserID:       true,
+	AccessLogFieldDealershipID: true,
+	AccessLogFieldClientIP:     true,
+	AccessLogFieldRequestID:    true,
+	AccessLogFieldTraceID:      true,
+	AccessLogFieldUserAgent:    true,
+}
+
+// AccessLogConfig holds gateway access log configuration
+type AccessLogConfig struct {
+	// Enable/disable the access log
+	Enabled bool
+
+	// Destination is "stdout" or a file path
+	Destination string
+
+	// Fields lists the fields to write, in output order
+	Fields []string
+}
+
+// DefaultAccessLogConfig returns the default access log configuration
+func DefaultAccessLogConfig() *AccessLogConfig {
+	return &AccessLogConfig{
+		Enabled:     false,
+		Destination: "stdout",
+		Fields:      DefaultAccessLogFields,
+	}
+}
+
+// ParseAccessLogFields parses a comma-separated field list, returning an
+// error for fields that are not allowed
+func ParseAccessLogFields(value string) ([]string, error) {
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if field == "" {
+			continue
+		}
+		if !accessLogFields[field] {
+			return nil, fmt.Errorf("unknown access log field: %s", field)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// AccessLogRecord is a single access log entry
+type AccessLogRecord struct {
+	Timestamp    time.Time
+	Method       string
+	Route        string
+	Status       int
+	Bytes        int64
+	Latency      time.Duration
+	UserID       string
+	DealershipID string
+	ClientIP     string
+	RequestID    string
+	TraceID      string
+	UserAgent    string
+}
+
+// value returns the record's value for a field
+func (r *AccessLogRecord) value(field string) interface{} {
+	switch field {
+	case AccessLogFieldTimestamp:
+		return r.Timestamp.UTC().Format(time.RFC3339Nano)
+	case AccessLogFieldMethod:
+		return r.Method
+	case AccessLogFieldRoute:
+		return r.Route
+	case AccessLogFieldStatus:
+		return r.Status
+	case AccessLogFieldBytes:
+		return r.Bytes
+	case AccessLogFieldLatencyMs:
+		return float64(r.Latency.Microseconds()) / 1000
+	case AccessLogFieldUserID:
+		return r.UserID
+	case AccessLogFieldDealershipID:
+		return r.DealershipID
+	case AccessLogFieldClientIP:
+		return r.ClientIP
+	case AccessLogFieldRequestID:
+		return r.RequestID
+	case AccessLogFieldTraceID:
+		return r.TraceID
+	case AccessLogFieldUserAgent:
+		return r.UserAgent
+	}
+	return nil
+}
+
+// AccessLogWriter writes access log records as JSON lines
+type AccessLogWriter struct {
+	mu     sync.Mutex
+	out    io.Writer
+	closer io.Closer
+	fields []string
+}
+
+// NewAccessLogWriter creates an access log writer for the configured destination
+func NewAccessLogWriter(config *AccessLogConfig) (*AccessLogWriter, error) {
+	fields := config.Fields
+	if len(fields) == 0 {
+		fields = DefaultAccessLogFields
+	}
+
+	if config.Destination == "" || config.Destination == "stdout" {
+		return &AccessLogWriter{out: os.Stdout, fields: fields}, nil
+	}
+
+	f, err := os.OpenFile(config.Destination, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open access log: %w", err)
+	}
+	return &AccessLogWriter{out: f, closer: f, fields: fields}, nil
+}
+
+// newAccessLogWriterTo creates an access log writer for an arbitrary writer
+func newAccessLogWriterTo(out io.Writer, fields []string) *AccessLogWriter {
+	if len(fields) == 0 {
+		fields = DefaultAccessLogFields
+	}
+	return &AccessLogWriter{out: out, fields: fields}
+}
+
+// Write writes a record as one JSON line
+func (w *AccessLogWriter) Write(record *AccessLogRecord) error {
+	var buf strings.Builder
+	buf.WriteByte('{')
+	for i, field := range w.fields {
+		value, err := json.Marshal(record.value(field))
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(&buf, "%q:%s", field, value)
+	}
+	buf.WriteString("}\n")
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := io.WriteString(w.out, buf.String())
+	return err
+}
+
+// Close closes the destination file, if any
+func (w *AccessLogWriter) Close() error {
+	if w.closer != nil {
+		return w.closer.Close()
+	}
+	return nil
+}
+
+type accessLogIdentityKey struct{}
+
+// accessLogIdentity carries the authenticated caller back out to the access
+// log middleware, which runs before JWTMiddleware has populated the context
+type accessLogIdentity struct {
+	userID       string
+	dealershipID string
+}
+
+// setAccessLogIdentity records the authenticated caller for the access log
+func setAccessLogIdentity(ctx context.Context, userID, dealershipID string) {
+	if identity, ok := ctx.Value(accessLogIdentityKey{}).(*accessLogIdentity); ok {
+		identity.userID = userID
+		identity.dealershipID = dealershipID
+	}
+}
+
+// accessLogResponseWriter captures status and response size
+type accessLogResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	bytes      int64
+}
+
+func (w *accessLogResponseWriter) WriteHeader(code int) {
+	w.statusCode = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *accessLogResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush supports streaming responses through the wrapper
+func (w *accessLogResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack supports WebSocket upgrades through the wrapper
+func (w *accessLogResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
+
+// traceIDFromHeader extracts the trace ID from a W3C traceparent header
+func traceIDFromHeader(r *http.Request) string {
+	parts := strings.Split(r.Header.Get("traceparent"), "-")
+	if len(parts) == 4 && len(parts[1]) == 32 {
+		return parts[1]
+	}
+	return ""
+}
+
+// AccessLogMiddleware writes an access log record for every request. It is
+// separate from RequestLoggingMiddleware, which writes to the application log.
+// It must run after RequestIDMiddleware so the request ID is available.
+func AccessLogMiddleware(writer *AccessLogWriter, logger *logging.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if writer == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			identity := &accessLogIdentity{}
+			ctx := context.WithValue(r.Context(), accessLogIdentityKey{}, identity)
+			wrapper := &accessLogResponseWriter{
+				ResponseWriter: w,
+				statusCode:     http.StatusOK,
+			}
+
+			next.ServeHTTP(wrapper, r.WithContext(ctx))
+
+			route := ""
+			if current := mux.CurrentRoute(r); current != nil {
+				route, _ = current.GetPathTemplate()
+			}
+
+			record := &AccessLogRecord{
+				Timestamp:    start,
+				Method:       r.Method,
+				Route:        route,
+				Status:       wrapper.statusCode,
+				Bytes:        wrapper.bytes,
+				Latency:      time.Since(start),
+				UserID:       identity.userID,
+				DealershipID: identity.dealershipID,
+				ClientIP:     getClientIP(r),
+				RequestID:    logging.GetTraceID(ctx),
+				TraceID:      traceIDFromHeader(r),
+				UserAgent:    r.UserAgent(),
+			}
+
+			if err := writer.Write(record); err != nil {
+				logger.WithContext(ctx).WithError(err).Error("Failed to write access log")
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"autolytiq/shared/logging"
+
+	"github.com/gorilla/mux"
+)
+
+var accessLogJWTConfig = &JWTConfig{SecretKey: "test-secret", Issuer: "autolytiq"}
+
+func setupAccessLogRouter(writer *AccessLogWriter) *mux.Router {
+	router := mux.NewRouter()
+	router.Use(logging.RequestIDMiddleware)
+	router.Use(AccessLogMiddleware(writer, proxyTestLogger()))
+
+	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}).Methods("GET")
+
+	api := router.PathPrefix("/api/v1").Subrouter()
+	api.Use(JWTMiddleware(accessLogJWTConfig))
+	api.HandleFunc("/customers/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"cust-1"}`))
+	}).Methods("GET")
+
+	return router
+}
+
+// parseAccessLogLines decodes each JSON line, failing on anything else
+func parseAccessLogLines(t *testing.T, out string) []map[string]interface{} {
+	t.Helper()
+	if !strings.HasSuffix(out, "\n") {
+		t.Fatalf("Expected access log to end with a newline, got %q", out)
+	}
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Access log line is not valid JSON: %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestAccessLogMiddleware_WritesJSONLine(t *testing.T) {
+	var buf bytes.Buffer
+	router := setupAccessLogRouter(newAccessLogWriterTo(&buf, nil))
+
+	token, err := GenerateToken(accessLogJWTConfig, "user-123", "dealer-456", "admin@example.com", "ADMIN")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/customers/cust-1?api_key=secret-value", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-Request-ID", "req-abc")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.RemoteAddr = "203.0.113.7:5000"
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	records := parseAccessLogLines(t, buf.String())
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 access log record, got %d", len(records))
+	}
+	record := records[0]
+
+	var keys []string
+	for key := range record {
+		keys = append(keys, key)
+	}
+	if len(keys) != len(DefaultAccessLogFields) {
+		t.Errorf("Expected fields %v, got %v", DefaultAccessLogFields, keys)
+	}
+
+	expected := map[string]interface{}{
+		"method":        "GET",
+		"route":         "/api/v1/customers/{id}",
+		"status":        float64(http.StatusCreated),
+		"bytes":         float64(len(`{"id":"cust-1"}`)),
+		"user_id":       "user-123",
+		"dealership_id": "dealer-456",
+		"client_ip":     "203.0.113.7",
+		"request_id":    "req-abc",
+		"trace_id":      "4bf92f3577b34da6a3ce929d0e0e4736",
+	}
+	for key, want := range expected {
+		if record[key] != want {
+			t.Errorf("Expected %s = %v, got %v", key, want, record[key])
+		}
+	}
+	if _, ok := record["latency_ms"].(float64); !ok {
+		t.Errorf("Expected numeric latency_ms, got %v", record["latency_ms"])
+	}
+	if _, ok := record["timestamp"].(string); !ok {
+		t.Errorf("Expected timestamp string, got %v", record["timestamp"])
+	}
+
+	if strings.Contains(buf.String(), "secret-value") || strings.Contains(buf.String(), token) {
+		t.Errorf("Access log leaked request secrets: %s", buf.String())
+	}
+}
+
+func TestAccessLogMiddleware_ConfiguredFields(t *testing.T) {
+	var buf bytes.Buffer
+	fields, err := ParseAccessLogFields("status, method ,route")
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := setupAccessLogRouter(newAccessLogWriterTo(&buf, fields))
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+
+	want := `{"status":200,"method":"GET","route":"/health"}` + "\n"
+	if buf.String() != want {
+		t.Errorf("Access log = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestAccessLogMiddleware_Unauthenticated(t *testing.T) {
+	var buf bytes.Buffer
+	router := setupAccessLogRouter(newAccessLogWriterTo(&buf, nil))
+
+	// Identity headers from the client must not be trusted
+	req := httptest.NewRequest("GET", "/api/v1/customers/cust-1", nil)
+	req.Header.Set("X-User-ID", "spoofed-user")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	records := parseAccessLogLines(t, buf.String())
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 access log record, got %d", len(records))
+	}
+	if records[0]["status"] != float64(http.StatusUnauthorized) {
+		t.Errorf("Expected status 401, got %v", records[0]["status"])
+	}
+	if records[0]["user_id"] != "" {
+		t.Errorf("Expected empty user_id, got %v", records[0]["user_id"])
+	}
+}
+
+func TestParseAccessLogFields_RejectsUnknown(t *testing.T) {
+	for _, value := range []string{"path", "status,authorization", "query"} {
+		if _, err := ParseAccessLogFields(value); err == nil {
+			t.Errorf("Expected error for fields %q", value)
+		}
+	}
+
+	fields, err := ParseAccessLogFields("user_agent,,bytes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fields, []string{"user_agent", "bytes"}) {
+		t.Errorf("ParseAccessLogFields() = %v", fields)
+	}
+}
+
+func TestNewAccessLogWriter_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	writer, err := NewAccessLogWriter(&AccessLogConfig{Enabled: true, Destination: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := setupAccessLogRouter(writer)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records := parseAccessLogLines(t, string(data)); len(records) != 2 {
+		t.Errorf("Expected 2 access log records, got %d", len(records))
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"autolytiq/shared/logging"
+
+	"github.com/gorilla/mux"
+)
+
+// AuditRule identifies a sensitive route by method and mux path template
+type AuditRule struct {
+	// Method is the HTTP method to match, or "*" for any method
+	Method string
+
+	// Route is the path template as registered, e.g. "/api/v1/gdpr/delete/{customer_id}"
+	Route string
+}
+
+// AuditConfig holds gateway audit configuration
+type AuditConfig struct {
+	// Rules lists the sensitive routes to audit
+	Rules []AuditRule
+
+	// Enable/disable gateway auditing
+	Enabled bool
+}
+
+// DefaultAuditConfig returns the default set of audited sensitive routes
+func DefaultAuditConfig() *AuditConfig {
+	return &AuditConfig{
+		Rules: []AuditRule{
+			{Method: "POST", Route: "/api/v1/gdpr/delete/{customer_id}"},
+			{Method: "POST", Route: "/api/v1/gdpr/anonymize/{customer_id}"},
+			{Method: "POST", Route: "/api/v1/gdpr/export/{customer_id}"},
+			{Method: "PUT", Route: "/api/v1/gdpr/requests/{id}/status"},
+			{Method: "POST", Route: "/api/v1/retention/policies"},
+			{Method: "PUT", Route: "/api/v1/retention/policies/{id}"},
+			{Method: "DELETE", Route: "/api/v1/retention/policies/{id}"},
+			{Method: "PUT", Route: "/api/v1/users/{id}/role"},
+			{Method: "POST", Route: "/api/v1/users/{id}/password"},
+			{Method: "DELETE", Route: "/api/v1/users/{id}"},
+			{Method: "*", Route: "/api/v1/auth/impersonate"},
+			{Method: "POST", Route: "/api/v1/inventory/vehicles/{id}/transfer"},
+		},
+		Enabled: true,
+	}
+}
+
+// ParseAuditRules parses a comma-separated list of "METHOD /route" entries.
+// Entries without a method match any method.
+func ParseAuditRules(value string) []AuditRule {
+	var rules []AuditRule
+	for _, entry := range strings.Split(value, ",") {
+		fields := strings.Fields(entry)
+		switch len(fields) {
+		case 1:
+			rules = append(rules, AuditRule{Method: "*", Route: fields[0]})
+		case 2:
+			rules = append(rules, AuditRule{Method: strings.ToUpper(fields[0]), Route: fields[1]})
+		}
+	}
+	return rules
+}
+
+// matches reports whether the rule applies to the request
+func (c *AuditConfig) matches(method, route string) bool {
+	for _, rule := range c.Rules {
+		if rule.Route == route && (rule.Method == "*" || rule.Method == method) {
+			return true
+		}
+	}
+	return false
+}
+
+// AuditEntry is a gateway record of a sensitive operation. Request bodies and
+// query strings are never captured since they may carry secrets.
+type AuditEntry struct {
+	Timestamp    time.Time `json:"timestamp"`
+	RequestID    string    `json:"request_id,omitempty"`
+	UserID       string    `json:"user_id,omitempty"`
+	DealershipID string    `json:"dealership_id,omitempty"`
+	Role         string    `json:"role,omitempty"`
+	Method       string    `json:"method"`
+	Route        string    `json:"route"`
+	Path         string    `json:"path"`
+	Status       int       `json:"status"`
+	ClientIP     string    `json:"client_ip,omitempty"`
+	DurationMs   int64     `json:"duration_ms"`
+}
+
+// AuditSink receives gateway audit entries
+type AuditSink interface {
+	Record(ctx context.Context, entry AuditEntry) error
+}
+
+// LoggerAuditSink writes audit entries to the structured log
+type LoggerAuditSink struct {
+	logger *logging.Logger
+}
+
+// NewLoggerAuditSink creates an audit sink backed by the gateway logger
+func NewLoggerAuditSink(logger *logging.Logger) *LoggerAuditSink {
+	return &LoggerAuditSink{logger: logger}
+}
+
+// Record logs the audit entry. Audit entries are always written at warn level
+// so they survive production log level filtering.
+func (s *LoggerAuditSink) Record(ctx context.Context, entry AuditEntry) error {
+	s.logger.WithFields(map[string]interface{}{
+		"audit":         true,
+		"request_id":    entry.RequestID,
+		"user_id":       entry.UserID,
+		"dealership_id": entry.DealershipID,
+		"role":          entry.Role,
+		"method":        entry.Method,
+		"route":         entry.Route,
+		"path":          entry.Path,
+		"status":        entry.Status,
+		"client_ip":     entry.ClientIP,
+		"duration_ms":   entry.DurationMs,
+		"audit_time":    entry.Timestamp.Format(time.RFC3339Nano),
+	}).Warn("Gateway audit")
+	return nil
+}
+
+// AuditMiddleware records an audit entry after the response for requests
+// matching a configured sensitive route. It must run after JWTMiddleware so
+// the caller's identity is available.
+func AuditMiddleware(config *AuditConfig, sink AuditSink, logger *logging.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if config == nil || !config.Enabled || sink == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			route := ""
+			if current := mux.CurrentRoute(r); current != nil {
+				route, _ = current.GetPathTemplate()
+			}
+			if !config.matches(r.Method, route) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			wrapper := &responseWriterWrapper{
+				ResponseWriter: w,
+				statusCode:     http.StatusOK,
+			}
+
+			next.ServeHTTP(wrapper, r)
+
+			ctx := r.Context()
+			entry := AuditEntry{
+				Timestamp:    start.UTC(),
+				RequestID:    logging.GetTraceID(ctx),
+				UserID:       GetUserIDFromContext(ctx),
+				DealershipID: GetDealershipIDFromContext(ctx),
+				Role:         GetRoleFromContext(ctx),
+				Method:       r.Method,
+				Route:        route,
+				Path:         r.URL.Path,
+				Status:       wrapper.statusCode,
+				ClientIP:     getClientIP(r),
+				DurationMs:   time.Since(start).Milliseconds(),
+			}
+
+			if err := sink.Record(ctx, entry); err != nil {
+				logger.WithContext(ctx).WithError(err).Error("Failed to record gateway audit entry")
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// recordingAuditSink captures audit entries for assertions
+type recordingAuditSink struct {
+	entries []AuditEntry
+}
+
+func (s *recordingAuditSink) Record(ctx context.Context, entry AuditEntry) error {
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+var auditJWTConfig = &JWTConfig{SecretKey: "test-secret", Issuer: "autolytiq"}
+
+func setupAuditRouter(config *AuditConfig, sink AuditSink, status int) *mux.Router {
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api/v1").Subrouter()
+	api.Use(JWTMiddleware(auditJWTConfig))
+	api.Use(AuditMiddleware(config, sink, proxyTestLogger()))
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}
+	api.HandleFunc("/gdpr/delete/{customer_id}", handler).Methods("POST")
+	api.HandleFunc("/users/{id}/role", handler).Methods("PUT")
+	api.HandleFunc("/users/{id}", handler).Methods("GET", "DELETE")
+
+	return router
+}
+
+func auditRequest(t *testing.T, router *mux.Router, method, path string, body []byte) {
+	t.Helper()
+
+	token, err := GenerateToken(auditJWTConfig, "user-123", "dealer-456", "admin@example.com", "ADMIN")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+}
+
+func TestAuditMiddleware_SensitiveRouteAudited(t *testing.T) {
+	sink := &recordingAuditSink{}
+	router := setupAuditRouter(DefaultAuditConfig(), sink, http.StatusAccepted)
+
+	auditRequest(t, router, "POST", "/api/v1/gdpr/delete/cust-789?token=secret", []byte(`{"password":"hunter2"}`))
+
+	if len(sink.entries) != 1 {
+		t.Fatalf("Expected 1 audit entry, got %d", len(sink.entries))
+	}
+
+	entry := sink.entries[0]
+	if entry.UserID != "user-123" || entry.DealershipID != "dealer-456" || entry.Role != "ADMIN" {
+		t.Errorf("Unexpected identity in audit entry: %+v", entry)
+	}
+	if entry.Method != "POST" || entry.Route != "/api/v1/gdpr/delete/{customer_id}" {
+		t.Errorf("Unexpected route in audit entry: %s %s", entry.Method, entry.Route)
+	}
+	if entry.Path != "/api/v1/gdpr/delete/cust-789" {
+		t.Errorf("Expected path without query string, got %s", entry.Path)
+	}
+	if entry.Status != http.StatusAccepted {
+		t.Errorf("Expected status %d, got %d", http.StatusAccepted, entry.Status)
+	}
+	if entry.Timestamp.IsZero() {
+		t.Error("Expected timestamp to be set")
+	}
+}
+
+func TestAuditMiddleware_NonSensitiveRouteSkipped(t *testing.T) {
+	sink := &recordingAuditSink{}
+	router := setupAuditRouter(DefaultAuditConfig(), sink, http.StatusOK)
+
+	// Same route template, but only DELETE is sensitive
+	auditRequest(t, router, "GET", "/api/v1/users/user-1", nil)
+
+	if len(sink.entries) != 0 {
+		t.Errorf("Expected no audit entries, got %+v", sink.entries)
+	}
+}
+
+func TestAuditMiddleware_RecordsFailedAttempts(t *testing.T) {
+	sink := &recordingAuditSink{}
+	router := setupAuditRouter(DefaultAuditConfig(), sink, http.StatusForbidden)
+
+	auditRequest(t, router, "PUT", "/api/v1/users/user-1/role", nil)
+
+	if len(sink.entries) != 1 || sink.entries[0].Status != http.StatusForbidden {
+		t.Errorf("Expected a 403 audit entry, got %+v", sink.entries)
+	}
+}
+
+func TestAuditMiddleware_Disabled(t *testing.T) {
+	sink := &recordingAuditSink{}
+	config := DefaultAuditConfig()
+	config.Enabled = false
+	router := setupAuditRouter(config, sink, http.StatusOK)
+
+	auditRequest(t, router, "POST", "/api/v1/gdpr/delete/cust-789", nil)
+
+	if len(sink.entries) != 0 {
+		t.Errorf("Expected no audit entries when disabled, got %d", len(sink.entries))
+	}
+}
+
+func TestParseAuditRules(t *testing.T) {
+	rules := ParseAuditRules("post /api/v1/gdpr/delete/{customer_id}, /api/v1/auth/impersonate,")
+
+	expected := []AuditRule{
+		{Method: "POST", Route: "/api/v1/gdpr/delete/{customer_id}"},
+		{Method: "*", Route: "/api/v1/auth/impersonate"},
+	}
+	if !reflect.DeepEqual(rules, expected) {
+		t.Errorf("ParseAuditRules() = %+v, want %+v", rules, expected)
+	}
+}
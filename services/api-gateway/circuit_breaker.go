@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CircuitBreakerConfig controls when the gateway stops calling an upstream
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens an
+	// upstream's breaker
+	FailureThreshold int
+
+	// OpenDuration is how long an open breaker rejects requests before a
+	// trial request is let through
+	OpenDuration time.Duration
+}
+
+// DefaultCircuitBreakerConfig returns the default breaker configuration
+func DefaultCircuitBreakerConfig() *CircuitBreakerConfig {
+	return &CircuitBreakerConfig{
+		FailureThreshold: 5,
+		OpenDuration:     30 * time.Second,
+	}
+}
+
+// CircuitBreaker tracks consecutive failures of one upstream. Once open, it
+// rejects requests until OpenDuration has passed; the next request is then
+// let through, and closes the breaker if it succeeds or reopens it if not.
+type CircuitBreaker struct {
+	config *CircuitBreakerConfig
+	now    func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// NewCircuitBreaker creates a closed circuit breaker
+func NewCircuitBreaker(config *CircuitBreakerConfig) *CircuitBreaker {
+	return &CircuitBreaker{config: config, now: time.Now}
+}
+
+// Allow reports whether a request may be sent to the upstream
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.now().Before(b.openUntil)
+}
+
+// Success records a request the upstream handled, closing the breaker
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.openUntil = time.Time{}
+}
+
+// Failure records a request the upstream failed, opening the breaker once
+// the threshold is reached
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.config.FailureThreshold > 0 && b.failures >= b.config.FailureThreshold {
+		b.openUntil = b.now().Add(b.config.OpenDuration)
+	}
+}
+
+// isUpstreamFailure reports whether a response status means the upstream
+// is unavailable rather than rejecting the request
+func isUpstreamFailure(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+// breakerFor returns the circuit breaker for an upstream, creating it on
+// first use
+func (s *Server) breakerFor(targetServiceURL string) *CircuitBreaker {
+	s.breakersMu.Lock()
+	defer s.breakersMu.Unlock()
+
+	breaker, ok := s.breakers[targetServiceURL]
+	if !ok {
+		breaker = NewCircuitBreaker(s.config.CircuitBreakerConfig)
+		s.breakers[targetServiceURL] = breaker
+	}
+	return breaker
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/logging"
+	"autolytiq/shared/tracing"
+
+	"github.com/gorilla/mux"
+)
+
+// FeatureGateRule gates a route behind a config-service feature flag
+type FeatureGateRule struct {
+	// Method is the HTTP method to match, or "*" for any method
+	Method string
+
+	// Route is the path template as registered, e.g. "/api/v1/deals/export"
+	Route string
+
+	// Flag is the feature flag key evaluated for the caller's dealership
+	Flag string
+
+	// FailOpen proxies the request when the flag cannot be evaluated
+	FailOpen bool
+}
+
+// FeatureGateConfig holds gateway feature gating configuration
+type FeatureGateConfig struct {
+	// Rules lists the gated routes
+	Rules []FeatureGateRule
+
+	// DisabledStatus is returned when a route's flag is off: 404 hides the
+	// route entirely, 403 reports it as unavailable
+	DisabledStatus int
+
+	// CacheTTL is how long a flag evaluation is reused per dealership
+	CacheTTL time.Duration
+}
+
+// DefaultFeatureGateConfig returns feature gating with no gated routes
+func DefaultFeatureGateConfig() *FeatureGateConfig {
+	return &FeatureGateConfig{
+		DisabledStatus: http.StatusNotFound,
+		CacheTTL:       time.Minute,
+	}
+}
+
+// ParseFeatureGateRules parses a comma-separated list of
+// "METHOD /route=flag[:open|:closed]" entries. Entries without a method match
+// any method, and entries without a failure mode use failOpen.
+func ParseFeatureGateRules(value string, failOpen bool) ([]FeatureGateRule, error) {
+	var rules []FeatureGateRule
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		eq := strings.LastIndex(entry, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("feature gate %q has no flag", entry)
+		}
+		rule := FeatureGateRule{Method: "*", Flag: strings.TrimSpace(entry[eq+1:]), FailOpen: failOpen}
+
+		if i := strings.LastIndex(rule.Flag, ":"); i >= 0 {
+			switch mode := rule.Flag[i+1:]; mode {
+			case "open":
+				rule.FailOpen = true
+			case "closed":
+				rule.FailOpen = false
+			default:
+				return nil, fmt.Errorf("feature gate %q has unknown failure mode %q", entry, mode)
+			}
+			rule.Flag = rule.Flag[:i]
+		}
+		if rule.Flag == "" {
+			return nil, fmt.Errorf("feature gate %q has no flag", entry)
+		}
+
+		fields := strings.Fields(entry[:eq])
+		switch len(fields) {
+		case 1:
+			rule.Route = fields[0]
+		case 2:
+			rule.Method = strings.ToUpper(fields[0])
+			rule.Route = fields[1]
+		default:
+			return nil, fmt.Errorf("feature gate %q must be \"METHOD /route=flag\"", entry)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// match returns the rule gating the request, if any
+func (c *FeatureGateConfig) match(method, route string) (FeatureGateRule, bool) {
+	for _, rule := range c.Rules {
+		if rule.Route == route && (rule.Method == "*" || rule.Method == method) {
+			return rule, true
+		}
+	}
+	return FeatureGateRule{}, false
+}
+
+// FlagEvaluator reports whether a feature flag is enabled for a dealership
+type FlagEvaluator interface {
+	Evaluate(ctx context.Context, flag, dealershipID string) (bool, error)
+}
+
+// ConfigFlagEvaluator evaluates flags with config-service, caching results
+type ConfigFlagEvaluator struct {
+	baseURL    string
+	httpClient *http.Client
+	ttl        time.Duration
+
+	mu    sync.Mutex
+	cache map[string]flagEvaluation
+}
+
+// flagEvaluation is a cached flag result
+type flagEvaluation struct {
+	enabled   bool
+	expiresAt time.Time
+}
+
+// NewConfigFlagEvaluator creates an evaluator for config-service at baseURL
+func NewConfigFlagEvaluator(baseURL string, ttl time.Duration) *ConfigFlagEvaluator {
+	return &ConfigFlagEvaluator{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Transport: tracing.Transport(nil), Timeout: 2 * time.Second},
+		ttl:        ttl,
+		cache:      make(map[string]flagEvaluation),
+	}
+}
+
+// Evaluate returns the flag's value for the dealership. Failed evaluations
+// are not cached, so config-service is retried on the next request.
+func (e *ConfigFlagEvaluator) Evaluate(ctx context.Context, flag, dealershipID string) (bool, error) {
+	cacheKey := flag + "|" + dealershipID
+	now := time.Now()
+
+	e.mu.Lock()
+	cached, ok := e.cache[cacheKey]
+	e.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.enabled, nil
+	}
+
+	body, _ := json.Marshal(map[string]string{"dealership_id": dealershipID})
+	req, err := http.NewRequestWithContext(ctx, "POST", e.baseURL+"/config/features/"+url.PathEscape(flag)+"/evaluate", bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if traceID := logging.GetTraceID(ctx); traceID != "" {
+		req.Header.Set(logging.RequestIDHeader, traceID)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate feature flag: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("config-service returned status %d evaluating feature flag %s", resp.StatusCode, flag)
+	}
+
+	var result struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("failed to decode feature flag evaluation: %w", err)
+	}
+
+	e.mu.Lock()
+	e.cache[cacheKey] = flagEvaluation{enabled: result.Enabled, expiresAt: now.Add(e.ttl)}
+	e.mu.Unlock()
+
+	return result.Enabled, nil
+}
+
+// FeatureGateMiddleware proxies requests to a gated route only when its flag
+// is enabled for the caller's dealership. It must run after JWTMiddleware so
+// the dealership is known.
+func FeatureGateMiddleware(config *FeatureGateConfig, evaluator FlagEvaluator, logger *logging.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if config == nil || len(config.Rules) == 0 || evaluator == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			route := ""
+			if current := mux.CurrentRoute(r); current != nil {
+				route, _ = current.GetPathTemplate()
+			}
+			rule, gated := config.match(r.Method, route)
+			if !gated {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+			dealershipID := GetDealershipIDFromContext(ctx)
+			enabled, err := evaluator.Evaluate(ctx, rule.Flag, dealershipID)
+			if err != nil {
+				logger.WithContext(ctx).WithError(err).WithFields(map[string]interface{}{
+					"flag":          rule.Flag,
+					"dealership_id": dealershipID,
+					"fail_open":     rule.FailOpen,
+				}).Warn("Feature gate evaluation failed")
+				enabled = rule.FailOpen
+			}
+
+			if !enabled {
+				writeFeatureDisabled(w, config.DisabledStatus)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeFeatureDisabled responds to a request for a route whose flag is off
+func writeFeatureDisabled(w http.ResponseWriter, status int) {
+	if status == http.StatusForbidden {
+		apierror.WriteError(w, status, "FEATURE_DISABLED", "This feature is not enabled for your dealership")
+		return
+	}
+	apierror.WriteError(w, http.StatusNotFound, "NOT_FOUND", "Not found")
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// stubFlagEvaluator enables flags for the listed dealerships
+type stubFlagEvaluator struct {
+	enabled map[string]bool
+	err     error
+}
+
+func (e *stubFlagEvaluator) Evaluate(ctx context.Context, flag, dealershipID string) (bool, error) {
+	if e.err != nil {
+		return false, e.err
+	}
+	return e.enabled[flag+"|"+dealershipID], nil
+}
+
+func setupFeatureGateRouter(config *FeatureGateConfig, evaluator FlagEvaluator) *mux.Router {
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api/v1").Subrouter()
+	api.Use(JWTMiddleware(auditJWTConfig))
+	api.Use(FeatureGateMiddleware(config, evaluator, proxyTestLogger()))
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	api.HandleFunc("/deals/export", handler).Methods("GET")
+	api.HandleFunc("/deals/{id}", handler).Methods("GET", "DELETE")
+
+	return router
+}
+
+func featureGateRequest(t *testing.T, router *mux.Router, method, path, dealershipID string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	token, err := GenerateToken(auditJWTConfig, "user-123", dealershipID, "user@example.com", "MANAGER")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestFeatureGate_BlocksDealershipWithFlagOff(t *testing.T) {
+	config := DefaultFeatureGateConfig()
+	config.Rules = []FeatureGateRule{{Method: "GET", Route: "/api/v1/deals/export", Flag: "deal_export"}}
+	evaluator := &stubFlagEvaluator{enabled: map[string]bool{"deal_export|dealer-on": true}}
+	router := setupFeatureGateRouter(config, evaluator)
+
+	if rr := featureGateRequest(t, router, "GET", "/api/v1/deals/export", "dealer-on"); rr.Code != http.StatusOK {
+		t.Errorf("Expected the flagged dealership to be proxied, got %d", rr.Code)
+	}
+
+	rr := featureGateRequest(t, router, "GET", "/api/v1/deals/export", "dealer-off")
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a dealership with the flag off, got %d", rr.Code)
+	}
+	var body errorResponse
+	json.NewDecoder(rr.Body).Decode(&body)
+	if body.Error.Code != "NOT_FOUND" || body.Error.Message == "" {
+		t.Errorf("Expected an error body, got %+v", body)
+	}
+
+	// Ungated routes and methods are unaffected
+	if rr := featureGateRequest(t, router, "GET", "/api/v1/deals/deal-1", "dealer-off"); rr.Code != http.StatusOK {
+		t.Errorf("Expected an ungated route to be proxied, got %d", rr.Code)
+	}
+
+	config.DisabledStatus = http.StatusForbidden
+	if rr := featureGateRequest(t, router, "GET", "/api/v1/deals/export", "dealer-off"); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected the configured 403, got %d", rr.Code)
+	}
+}
+
+func TestFeatureGate_FailureMode(t *testing.T) {
+	config := DefaultFeatureGateConfig()
+	config.Rules = []FeatureGateRule{
+		{Method: "GET", Route: "/api/v1/deals/export", Flag: "deal_export", FailOpen: true},
+		{Method: "DELETE", Route: "/api/v1/deals/{id}", Flag: "deal_delete"},
+	}
+	router := setupFeatureGateRouter(config, &stubFlagEvaluator{err: errors.New("config-service unavailable")})
+
+	if rr := featureGateRequest(t, router, "GET", "/api/v1/deals/export", "dealer-1"); rr.Code != http.StatusOK {
+		t.Errorf("Expected a fail-open route to be proxied, got %d", rr.Code)
+	}
+	if rr := featureGateRequest(t, router, "DELETE", "/api/v1/deals/deal-1", "dealer-1"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected a fail-closed route to be blocked, got %d", rr.Code)
+	}
+}
+
+func TestParseFeatureGateRules(t *testing.T) {
+	rules, err := ParseFeatureGateRules("GET /api/v1/deals/export=deal_export, /api/v1/quotes=quotes:open,POST /api/v1/x=x_flag:closed", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FeatureGateRule{
+		{Method: "GET", Route: "/api/v1/deals/export", Flag: "deal_export", FailOpen: true},
+		{Method: "*", Route: "/api/v1/quotes", Flag: "quotes", FailOpen: true},
+		{Method: "POST", Route: "/api/v1/x", Flag: "x_flag", FailOpen: false},
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("Expected %d rules, got %+v", len(want), rules)
+	}
+	for i := range want {
+		if rules[i] != want[i] {
+			t.Errorf("Rule %d: expected %+v, got %+v", i, want[i], rules[i])
+		}
+	}
+
+	for _, invalid := range []string{"GET /api/v1/deals", "GET /api/v1/deals=", "GET /api/v1/deals=flag:sometimes", "GET PUT /x=flag"} {
+		if _, err := ParseFeatureGateRules(invalid, false); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestConfigFlagEvaluator_CachesPerDealership(t *testing.T) {
+	var calls int32
+	configService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.Method != "POST" || r.URL.Path != "/config/features/deal_export/evaluate" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(map[string]bool{"enabled": req["dealership_id"] == "dealer-on"})
+	}))
+	defer configService.Close()
+
+	evaluator := NewConfigFlagEvaluator(configService.URL, time.Minute)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if enabled, err := evaluator.Evaluate(ctx, "deal_export", "dealer-on"); err != nil || !enabled {
+			t.Fatalf("Expected the flag on for dealer-on, got %v, %v", enabled, err)
+		}
+	}
+	if enabled, _ := evaluator.Evaluate(ctx, "deal_export", "dealer-off"); enabled {
+		t.Error("Expected the flag off for dealer-off")
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("Expected one config-service call per dealership, got %d", got)
+	}
+}
@@ -3,7 +3,11 @@ module autolytiq/api-gateway
 go 1.18
 
 require (
+	autolytiq/shared/apierror v0.0.0
+	autolytiq/shared/configcheck v0.0.0
+	autolytiq/shared/graceful v0.0.0
 	autolytiq/shared/logging v0.0.0
+	autolytiq/shared/tracing v0.0.0
 	github.com/golang-jwt/jwt/v4 v4.5.0
 	github.com/gorilla/mux v1.8.1
 	github.com/gorilla/websocket v1.5.0
@@ -27,4 +31,12 @@ require (
 	google.golang.org/protobuf v1.31.0 // indirect
 )
 
+replace autolytiq/shared/configcheck => ../shared/configcheck
+
 replace autolytiq/shared/logging => ../shared/logging
+
+replace autolytiq/shared/graceful => ../shared/graceful
+
+replace autolytiq/shared/apierror => ../shared/apierror
+
+replace autolytiq/shared/tracing => ../shared/tracing
@@ -4,18 +4,24 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/logging"
+
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/websocket"
 )
 
 // Claims represents JWT claims
 type Claims struct {
-	UserID       string `json:"user_id"`
-	DealershipID string `json:"dealership_id"`
-	Email        string `json:"email"`
-	Role         string `json:"role"`
+	UserID       string   `json:"user_id"`
+	DealershipID string   `json:"dealership_id"`
+	Email        string   `json:"email"`
+	Role         string   `json:"role"`
+	Scopes       []string `json:"scopes,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -23,6 +29,38 @@ type Claims struct {
 type JWTConfig struct {
 	SecretKey string
 	Issuer    string
+
+	// RefreshThreshold is the remaining token lifetime under which responses
+	// suggest a refresh; disabled when zero
+	RefreshThreshold time.Duration
+}
+
+// Token lifetime headers set on authenticated responses so clients can
+// refresh before the token expires
+const (
+	HeaderTokenExpiresIn        = "X-Token-Expires-In"
+	HeaderTokenRefreshSuggested = "X-Token-Refresh-Suggested"
+)
+
+// DefaultTokenRefreshThreshold is the default JWTConfig.RefreshThreshold
+const DefaultTokenRefreshThreshold = 5 * time.Minute
+
+// setTokenLifetimeHeaders reports the seconds until the token expires, and
+// whether the client should refresh now. Tokens without an expiry get no
+// headers.
+func setTokenLifetimeHeaders(w http.ResponseWriter, config *JWTConfig, claims *Claims, now time.Time) {
+	if claims.ExpiresAt == nil {
+		return
+	}
+
+	remaining := claims.ExpiresAt.Time.Sub(now)
+	if remaining < 0 {
+		remaining = 0
+	}
+	w.Header().Set(HeaderTokenExpiresIn, strconv.FormatInt(int64(remaining/time.Second), 10))
+	if config.RefreshThreshold > 0 && remaining < config.RefreshThreshold {
+		w.Header().Set(HeaderTokenRefreshSuggested, "true")
+	}
 }
 
 // contextKey is a custom type for context keys to avoid collisions
@@ -37,6 +75,8 @@ const (
 	ContextKeyEmail contextKey = "email"
 	// ContextKeyRole is the context key for role
 	ContextKeyRole contextKey = "role"
+	// ContextKeyScopes is the context key for scopes
+	ContextKeyScopes contextKey = "scopes"
 )
 
 // JWTMiddleware creates middleware that validates JWT tokens
@@ -46,14 +86,14 @@ func JWTMiddleware(config *JWTConfig) func(http.Handler) http.Handler {
 			// Extract token from Authorization header
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
-				http.Error(w, `{"error":"Missing authorization header"}`, http.StatusUnauthorized)
+				apierror.WriteError(w, http.StatusUnauthorized, "MISSING_AUTHORIZATION", "Missing authorization header")
 				return
 			}
 
 			// Check for Bearer token format
 			parts := strings.Split(authHeader, " ")
 			if len(parts) != 2 || parts[0] != "Bearer" {
-				http.Error(w, `{"error":"Invalid authorization format. Expected: Bearer <token>"}`, http.StatusUnauthorized)
+				apierror.WriteError(w, http.StatusUnauthorized, "INVALID_AUTHORIZATION_FORMAT", "Invalid authorization format. Expected: Bearer <token>")
 				return
 			}
 
@@ -69,7 +109,7 @@ func JWTMiddleware(config *JWTConfig) func(http.Handler) http.Handler {
 			})
 
 			if err != nil {
-				http.Error(w, fmt.Sprintf(`{"error":"Invalid token: %v"}`, err), http.StatusUnauthorized)
+				apierror.WriteError(w, http.StatusUnauthorized, "INVALID_TOKEN", fmt.Sprintf("Invalid token: %v", err))
 				return
 			}
 
@@ -77,13 +117,13 @@ func JWTMiddleware(config *JWTConfig) func(http.Handler) http.Handler {
 			if claims, ok := token.Claims.(*Claims); ok && token.Valid {
 				// Verify issuer
 				if claims.Issuer != config.Issuer {
-					http.Error(w, `{"error":"Invalid token issuer"}`, http.StatusUnauthorized)
+					apierror.WriteError(w, http.StatusUnauthorized, "INVALID_TOKEN_ISSUER", "Invalid token issuer")
 					return
 				}
 
 				// Check expiration
 				if claims.ExpiresAt != nil && claims.ExpiresAt.Time.Before(time.Now()) {
-					http.Error(w, `{"error":"Token expired"}`, http.StatusUnauthorized)
+					apierror.WriteError(w, http.StatusUnauthorized, "TOKEN_EXPIRED", "Token expired")
 					return
 				}
 
@@ -92,17 +132,72 @@ func JWTMiddleware(config *JWTConfig) func(http.Handler) http.Handler {
 				ctx = context.WithValue(ctx, ContextKeyDealershipID, claims.DealershipID)
 				ctx = context.WithValue(ctx, ContextKeyEmail, claims.Email)
 				ctx = context.WithValue(ctx, ContextKeyRole, claims.Role)
+				ctx = context.WithValue(ctx, ContextKeyScopes, claims.Scopes)
+				ctx = logging.SetIdentity(ctx, claims.DealershipID, claims.UserID)
+				setAccessLogIdentity(ctx, claims.UserID, claims.DealershipID)
+				setTokenLifetimeHeaders(w, config, claims, time.Now())
 
 				// Continue with modified request
 				next.ServeHTTP(w, r.WithContext(ctx))
 			} else {
-				http.Error(w, `{"error":"Invalid token claims"}`, http.StatusUnauthorized)
+				apierror.WriteError(w, http.StatusUnauthorized, "INVALID_TOKEN_CLAIMS", "Invalid token claims")
 				return
 			}
 		})
 	}
 }
 
+// WebSocketTokenParam is the query parameter WebSocket clients pass their
+// token in, since browsers cannot set headers on the upgrade request
+const WebSocketTokenParam = "access_token"
+
+// WebSocketBearerProtocol is the subprotocol that, followed by the token,
+// lets browsers pass their token in Sec-WebSocket-Protocol instead, keeping
+// it out of URLs and access logs: new WebSocket(url, ["bearer", token])
+const WebSocketBearerProtocol = "bearer"
+
+// WebSocketJWTMiddleware validates the JWT on a WebSocket upgrade. The token
+// is read from the Authorization header, the bearer subprotocol or the
+// access_token query parameter, and removed from the latter two before the
+// request goes any further.
+func WebSocketJWTMiddleware(config *JWTConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		validate := JWTMiddleware(config)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token := takeSubprotocolToken(r); token != "" && r.Header.Get("Authorization") == "" {
+				r.Header.Set("Authorization", "Bearer "+token)
+			}
+
+			query := r.URL.Query()
+			if token := query.Get(WebSocketTokenParam); token != "" {
+				if r.Header.Get("Authorization") == "" {
+					r.Header.Set("Authorization", "Bearer "+token)
+				}
+				query.Del(WebSocketTokenParam)
+				r.URL.RawQuery = query.Encode()
+			}
+			validate.ServeHTTP(w, r)
+		})
+	}
+}
+
+// takeSubprotocolToken returns the token following the bearer subprotocol
+// and removes it from the request's subprotocols, leaving bearer for the
+// upgrade to accept
+func takeSubprotocolToken(r *http.Request) string {
+	protocols := websocket.Subprotocols(r)
+	for i := 0; i+1 < len(protocols); i++ {
+		if protocols[i] != WebSocketBearerProtocol {
+			continue
+		}
+		token := protocols[i+1]
+		remaining := append(protocols[:i+1:i+1], protocols[i+2:]...)
+		r.Header.Set("Sec-WebSocket-Protocol", strings.Join(remaining, ", "))
+		return token
+	}
+	return ""
+}
+
 // GenerateToken generates a JWT token for testing/development
 func GenerateToken(config *JWTConfig, userID, dealershipID, email, role string) (string, error) {
 	claims := Claims{
@@ -152,3 +247,11 @@ func GetRoleFromContext(ctx context.Context) string {
 	}
 	return ""
 }
+
+// GetScopesFromContext extracts scopes from context
+func GetScopesFromContext(ctx context.Context) []string {
+	if scopes, ok := ctx.Value(ContextKeyScopes).([]string); ok {
+		return scopes
+	}
+	return nil
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
+	"autolytiq/shared/logging"
+
 	"github.com/golang-jwt/jwt/v4"
 )
 
@@ -69,9 +73,10 @@ func TestJWTMiddleware_MissingAuthHeader(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	handler := JWTMiddleware(config)(testHandler)
+	handler := logging.RequestIDMiddleware(JWTMiddleware(config)(testHandler))
 
 	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("X-Request-ID", "req-42")
 	// No Authorization header
 
 	rr := httptest.NewRecorder()
@@ -81,6 +86,14 @@ func TestJWTMiddleware_MissingAuthHeader(t *testing.T) {
 		t.Errorf("handler returned wrong status code: got %v want %v",
 			status, http.StatusUnauthorized)
 	}
+
+	var resp errorResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Error.Code != "MISSING_AUTHORIZATION" || resp.Error.RequestID != "req-42" {
+		t.Errorf("Expected a structured error with the request ID, got %+v", resp.Error)
+	}
 }
 
 func TestJWTMiddleware_InvalidAuthFormat(t *testing.T) {
@@ -259,6 +272,150 @@ func TestJWTMiddleware_ExpiredToken(t *testing.T) {
 	}
 }
 
+func TestJWTMiddleware_TokenLifetimeHeaders(t *testing.T) {
+	config := &JWTConfig{
+		SecretKey:        "test-secret",
+		Issuer:           "test-issuer",
+		RefreshThreshold: 5 * time.Minute,
+	}
+
+	tests := []struct {
+		name             string
+		expiresIn        time.Duration
+		refreshSuggested bool
+	}{
+		{"fresh token", time.Hour, false},
+		{"nearly expired token", 2 * time.Minute, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := Claims{
+				UserID: "user123",
+				RegisteredClaims: jwt.RegisteredClaims{
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(tt.expiresIn)),
+					Issuer:    config.Issuer,
+				},
+			}
+			tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.SecretKey))
+			if err != nil {
+				t.Fatalf("Failed to generate token: %v", err)
+			}
+
+			handler := JWTMiddleware(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Bearer "+tokenString)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			expiresIn, err := strconv.Atoi(rr.Header().Get(HeaderTokenExpiresIn))
+			if err != nil {
+				t.Fatalf("Expected a numeric %s header, got %q", HeaderTokenExpiresIn, rr.Header().Get(HeaderTokenExpiresIn))
+			}
+			// Allow for the token's whole-second expiry and test runtime
+			want := int(tt.expiresIn / time.Second)
+			if expiresIn > want || expiresIn < want-2 {
+				t.Errorf("Expected about %d seconds remaining, got %d", want, expiresIn)
+			}
+
+			suggested := rr.Header().Get(HeaderTokenRefreshSuggested) == "true"
+			if suggested != tt.refreshSuggested {
+				t.Errorf("Expected refresh suggested %v, got %v", tt.refreshSuggested, suggested)
+			}
+		})
+	}
+}
+
+func TestJWTMiddleware_NoLifetimeHeadersOnFailure(t *testing.T) {
+	config := &JWTConfig{
+		SecretKey: "test-secret",
+		Issuer:    "test-issuer",
+	}
+
+	handler := JWTMiddleware(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Authorization", "Bearer invalid.token.here")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Header().Get(HeaderTokenExpiresIn) != "" {
+		t.Error("Expected no lifetime header on an unauthenticated response")
+	}
+}
+
+func TestWebSocketJWTMiddleware(t *testing.T) {
+	config := &JWTConfig{
+		SecretKey: "test-secret",
+		Issuer:    "test-issuer",
+	}
+	token, err := GenerateToken(config, "user123", "dealer456", "test@example.com", "sales")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	var gotDealership, gotQuery string
+	handler := WebSocketJWTMiddleware(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotDealership = GetDealershipIDFromContext(r.Context())
+		gotQuery = r.URL.RawQuery
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Unauthenticated upgrades are rejected
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/ws/inventory", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a token, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/ws/inventory?access_token=not-a-token", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 with an invalid token, got %d", rr.Code)
+	}
+
+	// The token may be passed in the query, and is not forwarded
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/ws/inventory?access_token="+token+"&v=1", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if gotDealership != "dealer456" || gotQuery != "v=1" {
+		t.Errorf("Expected the token's dealership and the token stripped, got %q and %q", gotDealership, gotQuery)
+	}
+
+	// Or after the bearer subprotocol, which is kept for the upgrade to accept
+	var gotProtocols string
+	protocolHandler := WebSocketJWTMiddleware(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotDealership = GetDealershipIDFromContext(r.Context())
+		gotProtocols = r.Header.Get("Sec-WebSocket-Protocol")
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest("GET", "/ws/messaging", nil)
+	req.Header.Set("Sec-WebSocket-Protocol", "bearer, "+token)
+	rr = httptest.NewRecorder()
+	protocolHandler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if gotDealership != "dealer456" || gotProtocols != "bearer" {
+		t.Errorf("Expected the token's dealership and the token stripped, got %q and %q", gotDealership, gotProtocols)
+	}
+
+	req = httptest.NewRequest("GET", "/ws/messaging", nil)
+	req.Header.Set("Sec-WebSocket-Protocol", "bearer, not-a-token")
+	rr = httptest.NewRecorder()
+	protocolHandler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 with an invalid subprotocol token, got %d", rr.Code)
+	}
+}
+
 func TestGenerateToken(t *testing.T) {
 	config := &JWTConfig{
 		SecretKey: "test-secret",
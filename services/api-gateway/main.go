@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"autolytiq/shared/configcheck"
+	"autolytiq/shared/graceful"
 	"autolytiq/shared/logging"
+	"autolytiq/shared/tracing"
 
 	"github.com/gorilla/mux"
 )
@@ -31,8 +36,33 @@ type Config struct {
 	JWTSecret               string
 	JWTIssuer               string
 
+	// JWTRefreshThreshold is the remaining token lifetime under which
+	// responses carry X-Token-Refresh-Suggested
+	JWTRefreshThreshold time.Duration
+
 	// Rate limiting configuration
 	RateLimitConfig *RateLimitConfig
+
+	// Audit configuration for sensitive routes
+	AuditConfig *AuditConfig
+
+	// Access log configuration
+	AccessLogConfig *AccessLogConfig
+
+	// Server-Sent Events stream timeouts
+	SSEConfig *SSEConfig
+
+	// Feature flag gating of routes
+	FeatureGateConfig *FeatureGateConfig
+
+	// Upstream circuit breaking
+	CircuitBreakerConfig *CircuitBreakerConfig
+
+	// Serving cached responses while an upstream is unavailable
+	StaleCacheConfig *StaleCacheConfig
+
+	// Proxied request timeouts and retries
+	ProxyConfig *ProxyConfig
 }
 
 // Server represents the API Gateway server
@@ -42,7 +72,21 @@ type Server struct {
 	jwtConfig   *JWTConfig
 	rateLimiter *RateLimiter
 	metrics     *RateLimitMetrics
+	auditSink   AuditSink
+	accessLog   *AccessLogWriter
+	sseClient   *http.Client
+	proxyClient *http.Client
 	logger      *logging.Logger
+	tracer      *tracing.Tracer
+
+	flagEvaluator FlagEvaluator
+
+	// Subrouters that require a JWT, for the OpenAPI spec
+	protectedRouters []*mux.Router
+
+	breakersMu sync.Mutex
+	breakers   map[string]*CircuitBreaker
+	staleCache *StaleCache
 }
 
 // NewServer creates a new API Gateway server
@@ -58,12 +102,51 @@ func NewServer(config *Config, logger *logging.Logger) *Server {
 		router: mux.NewRouter(),
 		config: config,
 		jwtConfig: &JWTConfig{
-			SecretKey: config.JWTSecret,
-			Issuer:    config.JWTIssuer,
+			SecretKey:        config.JWTSecret,
+			Issuer:           config.JWTIssuer,
+			RefreshThreshold: config.JWTRefreshThreshold,
 		},
 		rateLimiter: rateLimiter,
 		metrics:     metrics,
+		auditSink:   NewLoggerAuditSink(logger),
 		logger:      logger,
+		breakers:    make(map[string]*CircuitBreaker),
+		tracer: tracing.New(tracing.Config{
+			Service: "api-gateway",
+			OnError: func(err error) { logger.WithError(err).Warn("Failed to export trace spans") },
+		}),
+	}
+	if s.config.AuditConfig == nil {
+		s.config.AuditConfig = DefaultAuditConfig()
+	}
+	if s.config.SSEConfig == nil {
+		s.config.SSEConfig = DefaultSSEConfig()
+	}
+	s.sseClient = newSSEClient(s.config.SSEConfig)
+	if s.config.FeatureGateConfig == nil {
+		s.config.FeatureGateConfig = DefaultFeatureGateConfig()
+	}
+	if len(s.config.FeatureGateConfig.Rules) > 0 {
+		s.flagEvaluator = NewConfigFlagEvaluator(s.config.ConfigServiceURL, s.config.FeatureGateConfig.CacheTTL)
+	}
+	if s.config.CircuitBreakerConfig == nil {
+		s.config.CircuitBreakerConfig = DefaultCircuitBreakerConfig()
+	}
+	if s.config.StaleCacheConfig == nil {
+		s.config.StaleCacheConfig = DefaultStaleCacheConfig()
+	}
+	s.staleCache = NewStaleCache(s.config.StaleCacheConfig.MaxEntries)
+	if s.config.ProxyConfig == nil {
+		s.config.ProxyConfig = DefaultProxyConfig()
+	}
+	s.proxyClient = newProxyClient(s.config.ProxyConfig)
+	if s.config.AccessLogConfig != nil && s.config.AccessLogConfig.Enabled {
+		accessLog, err := NewAccessLogWriter(s.config.AccessLogConfig)
+		if err != nil {
+			logger.Warnf("Access log disabled: %v", err)
+		} else {
+			s.accessLog = accessLog
+		}
 	}
 
 	s.setupRoutes()
@@ -74,8 +157,10 @@ func NewServer(config *Config, logger *logging.Logger) *Server {
 
 // setupMiddleware configures middleware for the server
 func (s *Server) setupMiddleware() {
-	// Order matters: request ID first, then logging, then CORS, then validation, then metrics
-	s.router.Use(logging.RequestIDMiddleware)
+	// Order matters: tracing and request ID first, then access log and logging, then CORS, then validation, then metrics
+	s.router.Use(s.tracer.Middleware)
+	s.router.Use(logging.EdgeRequestIDMiddleware)
+	s.router.Use(AccessLogMiddleware(s.accessLog, s.logger))
 	s.router.Use(logging.RequestLoggingMiddleware(s.logger))
 	s.router.Use(corsMiddleware(s.config.AllowedOrigins))
 	s.router.Use(GatewayValidationMiddleware) // Validate all incoming requests
@@ -96,6 +181,7 @@ func (s *Server) setupRoutes() {
 	s.router.HandleFunc("/ready", s.readinessCheck).Methods("GET")
 	s.router.HandleFunc("/live", s.livenessCheck).Methods("GET")
 	s.router.HandleFunc("/api/v1/version", s.version).Methods("GET")
+	s.router.HandleFunc("/api/v1/openapi.json", s.openAPISpec).Methods("GET")
 
 	// Auth routes (public - no JWT required, rate limited by IP)
 	authPublic := s.router.PathPrefix("/api/v1/auth").Subrouter()
@@ -110,28 +196,108 @@ func (s *Server) setupRoutes() {
 	// Auth routes (protected - requires JWT)
 	authProtected := s.router.PathPrefix("/api/v1/auth").Subrouter()
 	authProtected.Use(JWTMiddleware(s.jwtConfig))
+	s.protectedRouters = append(s.protectedRouters, authProtected)
+	authProtected.Use(AuditMiddleware(s.config.AuditConfig, s.auditSink, s.logger))
 	authProtected.Use(RateLimitMiddleware(s.rateLimiter, s.logger))
 	authProtected.HandleFunc("/logout", s.proxyToAuthService).Methods("POST")
+	authProtected.HandleFunc("/logout-all", s.proxyToAuthService).Methods("POST")
 	authProtected.HandleFunc("/me", s.proxyToAuthService).Methods("GET")
 	authProtected.HandleFunc("/change-password", s.proxyToAuthService).Methods("POST")
+	authProtected.HandleFunc("/invites", s.proxyToAuthService).Methods("POST")
+	authProtected.HandleFunc("/users", s.proxyToAuthService).Methods("POST")
+
+	// Deal document downloads (public - authorized by the signed URL, rate limited by IP)
+	documentsPublic := s.router.PathPrefix("/api/v1/deals").Subrouter()
+	documentsPublic.Use(RateLimitMiddleware(s.rateLimiter, s.logger))
+	documentsPublic.HandleFunc("/{id}/documents/{document_id}/content", s.proxyToDealServicePublic).Methods("GET")
+
+	// E-signature provider callbacks (public - authenticated by the provider's signature, rate limited by IP)
+	documentsPublic.HandleFunc("/signature/webhook", s.proxyToDealServicePublic).Methods("POST")
+
+	// Price alert unsubscribe links (public - authorized by the token in the link, rate limited by IP)
+	inventoryPublic := s.router.PathPrefix("/api/v1/inventory").Subrouter()
+	inventoryPublic.Use(RateLimitMiddleware(s.rateLimiter, s.logger))
+	inventoryPublic.HandleFunc("/vehicles/watches/unsubscribe", s.proxyToInventoryServicePublic).Methods("GET", "POST")
+
+	// Marketing email unsubscribe links (public - authorized by the signed token, rate limited by IP)
+	emailPublic := s.router.PathPrefix("/api/v1/email").Subrouter()
+	emailPublic.Use(RateLimitMiddleware(s.rateLimiter, s.logger))
+	emailPublic.HandleFunc("/unsubscribe/{token}", s.proxyToEmailServicePublic).Methods("GET", "POST")
+
+	// Customer self-service GDPR requests (public - verified by emailed link, rate limited by IP)
+	gdprPublic := s.router.PathPrefix("/api/v1/gdpr/public").Subrouter()
+	gdprPublic.Use(RateLimitMiddleware(s.rateLimiter, s.logger))
+	gdprPublic.HandleFunc("/request", s.proxyToDataRetentionServicePublic).Methods("POST")
+	gdprPublic.HandleFunc("/verify/{token}", s.proxyToDataRetentionServicePublic).Methods("GET", "POST")
 
 	// Protected routes (authentication required)
 	api := s.router.PathPrefix("/api/v1").Subrouter()
 	api.Use(JWTMiddleware(s.jwtConfig))
+	s.protectedRouters = append(s.protectedRouters, api)
+	api.Use(AuditMiddleware(s.config.AuditConfig, s.auditSink, s.logger))
 	api.Use(RateLimitMiddleware(s.rateLimiter, s.logger))
+	api.Use(FeatureGateMiddleware(s.config.FeatureGateConfig, s.flagEvaluator, s.logger))
 
 	// Deal Service routes
 	api.HandleFunc("/deals", s.proxyToDealService).Methods("GET", "POST")
+	api.HandleFunc("/deals/export", s.proxyToDealService).Methods("GET")
+	api.HandleFunc("/deals/quote", s.proxyToDealService).Methods("POST")
+	api.HandleFunc("/deals/quotes/{quote_id}/document", s.proxyToDealService).Methods("GET")
+	api.HandleFunc("/deals/from-quote/{quote_id}", s.proxyToDealService).Methods("POST")
 	api.HandleFunc("/deals/{id}", s.proxyToDealService).Methods("GET", "PUT", "DELETE")
+	api.HandleFunc("/deals/{id}/history", s.proxyToDealService).Methods("GET")
+	api.HandleFunc("/deals/{id}/allowed-transitions", s.proxyToDealService).Methods("GET")
+	api.HandleFunc("/deals/{id}/notes", s.proxyToDealService).Methods("POST")
+	api.HandleFunc("/deals/{id}/activity", s.proxyToDealService).Methods("GET")
+	api.HandleFunc("/deals/{id}/versions", s.proxyToDealService).Methods("GET")
+	api.HandleFunc("/deals/{id}/revert/{version}", s.proxyToDealService).Methods("POST")
+	api.HandleFunc("/deals/{id}/approve", s.proxyToDealService).Methods("POST")
+	api.HandleFunc("/deals/{id}/profitability", s.proxyToDealService).Methods("GET")
+	api.HandleFunc("/deals/{id}/financing", s.proxyToDealService).Methods("GET")
+	api.HandleFunc("/deals/{id}/payment-schedule", s.proxyToDealService).Methods("POST")
+	api.HandleFunc("/deals/{id}/buyers-order", s.proxyToDealService).Methods("GET")
+	api.HandleFunc("/deals/{id}/credit-application", s.proxyToDealService).Methods("POST")
+	api.HandleFunc("/deals/{id}/credit-applications", s.proxyToDealService).Methods("GET")
+	api.HandleFunc("/deals/{id}/documents", s.proxyToDealService).Methods("GET", "POST")
+	api.HandleFunc("/deals/{id}/documents/{document_id}", s.proxyToDealService).Methods("DELETE")
+	api.HandleFunc("/deals/{id}/signature-request", s.proxyToDealService).Methods("POST")
+	api.HandleFunc("/deals/{id}/signature-requests", s.proxyToDealService).Methods("GET")
 
 	// Customer Service routes
 	api.HandleFunc("/customers", s.proxyToCustomerService).Methods("GET", "POST")
+	api.HandleFunc("/customers/segments", s.proxyToCustomerService).Methods("GET", "POST")
+	api.HandleFunc("/customers/segments/{id}", s.proxyToCustomerService).Methods("GET")
+	api.HandleFunc("/customers/segments/{id}/members", s.proxyToCustomerService).Methods("GET")
+	api.HandleFunc("/customers/tags", s.proxyToCustomerService).Methods("GET")
+	api.HandleFunc("/customers/bulk-tags", s.proxyToCustomerService).Methods("POST")
+	api.HandleFunc("/customers/upcoming-milestones", s.proxyToCustomerService).Methods("GET")
+	api.HandleFunc("/customers/admin/reencrypt", s.proxyToCustomerService).Methods("POST")
 	api.HandleFunc("/customers/{id}", s.proxyToCustomerService).Methods("GET", "PUT", "DELETE")
+	api.HandleFunc("/customers/{id}/tags", s.proxyToCustomerService).Methods("POST", "DELETE")
+	api.HandleFunc("/customers/{id}/merge", s.proxyToCustomerService).Methods("POST")
+	api.HandleFunc("/customers/{id}/pii-health", s.proxyToCustomerService).Methods("GET")
 
 	// Inventory Service routes
 	api.HandleFunc("/inventory/vehicles", s.proxyToInventoryService).Methods("GET", "POST")
-	api.HandleFunc("/inventory/vehicles/{id}", s.proxyToInventoryService).Methods("GET", "PUT", "DELETE")
+	api.HandleFunc("/inventory/vehicles/bulk", s.proxyToInventoryService).Methods("POST")
+	api.HandleFunc("/inventory/vehicles/makes", s.proxyToInventoryService).Methods("GET")
+	api.HandleFunc("/inventory/vehicles/models", s.proxyToInventoryService).Methods("GET")
+	api.HandleFunc("/inventory/vehicles/synonyms", s.proxyToInventoryService).Methods("GET", "PUT")
+	api.HandleFunc("/inventory/vehicles/synonyms/{id}", s.proxyToInventoryService).Methods("DELETE")
+	api.HandleFunc("/inventory/vehicles/search-insights", s.proxyToInventoryService).Methods("GET")
+	api.HandleFunc("/inventory/vehicles/compare", s.proxyToInventoryService).Methods("POST")
+	api.HandleFunc("/inventory/vehicles/aging", s.proxyToInventoryService).Methods("GET")
+	api.HandleFunc("/inventory/vehicles/{id}", s.proxyToInventoryService).Methods("GET", "PUT", "PATCH", "DELETE")
+	api.HandleFunc("/inventory/vehicles/{id}/restore", s.proxyToInventoryService).Methods("POST")
+	api.HandleFunc("/inventory/vehicles/{id}/price-history", s.proxyToInventoryService).Methods("GET")
+	api.HandleFunc("/inventory/vehicles/{id}/watch", s.proxyToInventoryService).Methods("POST")
+	api.HandleFunc("/inventory/vehicles/{id}/watchers", s.proxyToInventoryService).Methods("GET")
+	api.HandleFunc("/inventory/vehicles/{id}/transfer", s.proxyToInventoryService).Methods("POST")
+	api.HandleFunc("/inventory/vehicles/{id}/transfers", s.proxyToInventoryService).Methods("GET")
+	api.HandleFunc("/inventory/vehicles/{id}/photos", s.proxyToInventoryService).Methods("GET")
+	api.HandleFunc("/inventory/vehicles/photos/bulk", s.proxyToInventoryService).Methods("POST")
 	api.HandleFunc("/inventory/vehicles/validate-vin", s.proxyToInventoryService).Methods("POST")
+	api.HandleFunc("/inventory/vehicles/stats/trend", s.proxyToInventoryService).Methods("GET")
 	api.HandleFunc("/inventory/vehicles/decode-vin", s.proxyToInventoryService).Methods("POST")
 	api.HandleFunc("/inventory/stats", s.proxyToInventoryService).Methods("GET")
 
@@ -139,9 +305,15 @@ func (s *Server) setupRoutes() {
 	api.HandleFunc("/email/send", s.proxyToEmailService).Methods("POST")
 	api.HandleFunc("/email/send-template", s.proxyToEmailService).Methods("POST")
 	api.HandleFunc("/email/templates", s.proxyToEmailService).Methods("GET", "POST")
+	api.HandleFunc("/email/templates/leaderboard", s.proxyToEmailService).Methods("GET")
 	api.HandleFunc("/email/templates/{id}", s.proxyToEmailService).Methods("GET", "PUT", "DELETE")
+	api.HandleFunc("/email/templates/{id}/ab-results", s.proxyToEmailService).Methods("GET")
+	api.HandleFunc("/email/templates/{id}/stats", s.proxyToEmailService).Methods("GET")
 	api.HandleFunc("/email/logs", s.proxyToEmailService).Methods("GET")
 	api.HandleFunc("/email/logs/{id}", s.proxyToEmailService).Methods("GET")
+	api.HandleFunc("/email/logs/{id}/events", s.proxyToEmailService).Methods("POST")
+	api.HandleFunc("/email/suppressions", s.proxyToEmailService).Methods("GET", "POST")
+	api.HandleFunc("/email/suppressions/{email}", s.proxyToEmailService).Methods("DELETE")
 
 	// User Service routes
 	api.HandleFunc("/users", s.proxyToUserService).Methods("GET", "POST")
@@ -156,6 +328,8 @@ func (s *Server) setupRoutes() {
 	api.HandleFunc("/config/settings", s.proxyToConfigService).Methods("GET")
 	api.HandleFunc("/config/settings/{key}", s.proxyToConfigService).Methods("GET", "PUT", "DELETE")
 	api.HandleFunc("/config/categories/{category}", s.proxyToConfigService).Methods("GET")
+	api.HandleFunc("/config/version", s.proxyToConfigService).Methods("GET")
+	api.HandleFunc("/config/validate", s.proxyToConfigService).Methods("POST")
 	api.HandleFunc("/config/features", s.proxyToConfigService).Methods("GET", "POST")
 	api.HandleFunc("/config/features/{key}", s.proxyToConfigService).Methods("GET", "PUT", "DELETE")
 	api.HandleFunc("/config/features/{key}/evaluate", s.proxyToConfigService).Methods("POST")
@@ -173,13 +347,18 @@ func (s *Server) setupRoutes() {
 	api.HandleFunc("/showroom/visits/{id}/notes", s.proxyToShowroomService).Methods("GET", "POST")
 	api.HandleFunc("/showroom/visits/{id}/notes/{note_id}", s.proxyToShowroomService).Methods("PATCH", "DELETE")
 	api.HandleFunc("/showroom/visits/{id}/events", s.proxyToShowroomService).Methods("GET")
+	api.HandleFunc("/showroom/mentions", s.proxyToShowroomService).Methods("GET")
+	api.HandleFunc("/showroom/source-report", s.proxyToShowroomService).Methods("GET")
+	api.HandleFunc("/showroom/analytics/timers", s.proxyToShowroomService).Methods("GET")
+	api.HandleFunc("/showroom/reports/lost-reasons", s.proxyToShowroomService).Methods("GET")
 	api.HandleFunc("/showroom/workflow-config", s.proxyToShowroomService).Methods("GET", "PUT")
 
-	// WebSocket endpoint for showroom (upgrade handled separately)
-	s.router.HandleFunc("/ws/showroom", s.proxyWebSocketToShowroom)
+	// WebSocket endpoint for showroom (authenticated, scoped to the token's dealership)
+	s.router.Handle("/ws/showroom", WebSocketJWTMiddleware(s.jwtConfig)(http.HandlerFunc(s.proxyWebSocketToShowroom)))
 
 	// Messaging Service routes
 	api.HandleFunc("/messaging/conversations", s.proxyToMessagingService).Methods("GET", "POST")
+	api.HandleFunc("/messaging/conversations/bulk-tags", s.proxyToMessagingService).Methods("POST")
 	api.HandleFunc("/messaging/conversations/{id}", s.proxyToMessagingService).Methods("GET", "PATCH")
 	api.HandleFunc("/messaging/conversations/{conversationId}/messages", s.proxyToMessagingService).Methods("GET", "POST")
 	api.HandleFunc("/messaging/conversations/{conversationId}/messages/{messageId}", s.proxyToMessagingService).Methods("GET", "PATCH", "DELETE")
@@ -189,9 +368,20 @@ func (s *Server) setupRoutes() {
 	api.HandleFunc("/messaging/conversations/{conversationId}/typing", s.proxyToMessagingService).Methods("POST")
 	api.HandleFunc("/messaging/conversations/{conversationId}/participants", s.proxyToMessagingService).Methods("GET", "POST")
 	api.HandleFunc("/messaging/conversations/{conversationId}/participants/{userId}", s.proxyToMessagingService).Methods("DELETE")
+	api.HandleFunc("/messaging/conversations/{conversationId}/tags", s.proxyToMessagingService).Methods("POST")
+	api.HandleFunc("/messaging/conversations/{conversationId}/tags/{tag}", s.proxyToMessagingService).Methods("DELETE")
+	api.HandleFunc("/messaging/tags", s.proxyToMessagingService).Methods("GET")
+	api.HandleFunc("/messaging/scheduled", s.proxyToMessagingService).Methods("GET")
+	api.HandleFunc("/messaging/scheduled/{messageId}", s.proxyToMessagingService).Methods("DELETE")
+	api.HandleFunc("/messaging/preferences", s.proxyToMessagingService).Methods("GET", "PUT")
+	api.HandleFunc("/messaging/announcements", s.proxyToMessagingService).Methods("GET", "POST")
+	api.HandleFunc("/messaging/announcements/{announcementId}/read", s.proxyToMessagingService).Methods("POST")
 
-	// WebSocket endpoint for messaging
-	s.router.HandleFunc("/ws/messaging", s.proxyWebSocketToMessaging)
+	// WebSocket endpoint for messaging (authenticated, scoped to the token's user and dealership)
+	s.router.Handle("/ws/messaging", WebSocketJWTMiddleware(s.jwtConfig)(http.HandlerFunc(s.proxyWebSocketToMessaging)))
+
+	// WebSocket endpoint for live inventory updates (authenticated, scoped to the token's dealership)
+	s.router.Handle("/ws/inventory", WebSocketJWTMiddleware(s.jwtConfig)(http.HandlerFunc(s.proxyWebSocketToInventory)))
 
 	// Settings Service routes
 	api.HandleFunc("/settings/user", s.proxyToSettingsService).Methods("GET", "POST", "PUT", "DELETE")
@@ -207,8 +397,12 @@ func (s *Server) setupRoutes() {
 	api.HandleFunc("/gdpr/requests", s.proxyToDataRetentionService).Methods("GET")
 	api.HandleFunc("/gdpr/requests/{id}", s.proxyToDataRetentionService).Methods("GET")
 	api.HandleFunc("/gdpr/requests/{id}/status", s.proxyToDataRetentionService).Methods("PUT")
+	api.HandleFunc("/gdpr/requests/{id}/approve", s.proxyToDataRetentionService).Methods("POST")
 
 	// Consent Management routes
+	api.HandleFunc("/consent/versions", s.proxyToDataRetentionService).Methods("GET", "POST")
+	api.HandleFunc("/consent/outdated", s.proxyToDataRetentionService).Methods("GET")
+	api.HandleFunc("/consent/reconsent-requests", s.proxyToDataRetentionService).Methods("POST")
 	api.HandleFunc("/consent/{customer_id}", s.proxyToDataRetentionService).Methods("GET", "PUT")
 	api.HandleFunc("/consent/{customer_id}/history", s.proxyToDataRetentionService).Methods("GET")
 	api.HandleFunc("/consent/marketing/opt-out", s.proxyToDataRetentionService).Methods("POST")
@@ -281,6 +475,11 @@ func (s *Server) proxyToDealService(w http.ResponseWriter, r *http.Request) {
 	s.proxyRequest(w, r, s.config.DealServiceURL, "/deals")
 }
 
+// proxyToDealServicePublic proxies requests to deal-service (public routes, no JWT required)
+func (s *Server) proxyToDealServicePublic(w http.ResponseWriter, r *http.Request) {
+	s.proxyRequestPublic(w, r, s.config.DealServiceURL, "/deals")
+}
+
 // proxyToCustomerService proxies requests to customer-service
 func (s *Server) proxyToCustomerService(w http.ResponseWriter, r *http.Request) {
 	s.proxyRequest(w, r, s.config.CustomerServiceURL, "/customers")
@@ -291,11 +490,27 @@ func (s *Server) proxyToInventoryService(w http.ResponseWriter, r *http.Request)
 	s.proxyRequest(w, r, s.config.InventoryServiceURL, "/inventory")
 }
 
+// proxyToInventoryServicePublic proxies requests to inventory-service (public routes, no JWT required)
+func (s *Server) proxyToInventoryServicePublic(w http.ResponseWriter, r *http.Request) {
+	s.proxyRequestPublic(w, r, s.config.InventoryServiceURL, "/inventory")
+}
+
 // proxyToEmailService proxies requests to email-service
 func (s *Server) proxyToEmailService(w http.ResponseWriter, r *http.Request) {
 	s.proxyRequest(w, r, s.config.EmailServiceURL, "/email")
 }
 
+// proxyToEmailServicePublic proxies unauthenticated requests to email-service
+func (s *Server) proxyToEmailServicePublic(w http.ResponseWriter, r *http.Request) {
+	s.proxyRequestPublic(w, r, s.config.EmailServiceURL, "/email")
+}
+
+// proxyToDataRetentionServicePublic proxies unauthenticated requests to
+// data-retention-service
+func (s *Server) proxyToDataRetentionServicePublic(w http.ResponseWriter, r *http.Request) {
+	s.proxyRequestPublic(w, r, s.config.DataRetentionServiceURL, "/gdpr")
+}
+
 // proxyToUserService proxies requests to user-service
 func (s *Server) proxyToUserService(w http.ResponseWriter, r *http.Request) {
 	s.proxyRequest(w, r, s.config.UserServiceURL, "/users")
@@ -331,6 +546,11 @@ func (s *Server) proxyToMessagingService(w http.ResponseWriter, r *http.Request)
 	s.proxyRequest(w, r, s.config.MessagingServiceURL, "/messaging")
 }
 
+// proxyWebSocketToInventory proxies WebSocket connections to inventory-service
+func (s *Server) proxyWebSocketToInventory(w http.ResponseWriter, r *http.Request) {
+	s.proxyWebSocket(w, r, s.config.InventoryServiceURL)
+}
+
 // proxyWebSocketToMessaging proxies WebSocket connections to messaging-service
 func (s *Server) proxyWebSocketToMessaging(w http.ResponseWriter, r *http.Request) {
 	s.proxyWebSocket(w, r, s.config.MessagingServiceURL)
@@ -343,22 +563,24 @@ func (s *Server) proxyToSettingsService(w http.ResponseWriter, r *http.Request)
 
 // proxyToDataRetentionService proxies requests to data-retention-service
 func (s *Server) proxyToDataRetentionService(w http.ResponseWriter, r *http.Request) {
-	// Determine the correct path prefix based on the URL path
-	path := r.URL.Path
-	var prefix string
-	switch {
-	case len(path) >= 12 && path[8:12] == "gdpr":
-		prefix = "/gdpr"
-	case len(path) >= 15 && path[8:15] == "consent":
-		prefix = "/consent"
-	case len(path) >= 17 && path[8:17] == "retention":
-		prefix = "/retention"
-	case len(path) >= 13 && path[8:13] == "audit":
-		prefix = "/audit"
-	default:
-		prefix = ""
-	}
-	s.proxyRequest(w, r, s.config.DataRetentionServiceURL, prefix)
+	s.proxyRequest(w, r, s.config.DataRetentionServiceURL, dataRetentionPrefix(r.URL.Path))
+}
+
+// dataRetentionPrefixes are the top-level paths data-retention-service serves
+var dataRetentionPrefixes = []string{"/gdpr", "/consent", "/retention", "/audit"}
+
+// dataRetentionPrefix returns the data-retention-service prefix a gateway
+// path is under, or "" if none. The first whole segment that names one is
+// used, so the gateway's base path and API version don't matter.
+func dataRetentionPrefix(path string) string {
+	for _, segment := range strings.Split(path, "/") {
+		for _, prefix := range dataRetentionPrefixes {
+			if segment == prefix[1:] {
+				return prefix
+			}
+		}
+	}
+	return ""
 }
 
 // corsMiddleware adds CORS headers
@@ -367,8 +589,8 @@ func corsMiddleware(allowedOrigins string) func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", allowedOrigins)
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, PATCH, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, X-Request-ID")
-			w.Header().Set("Access-Control-Expose-Headers", "X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, Retry-After, X-Request-ID")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, X-Request-ID, If-Match")
+			w.Header().Set("Access-Control-Expose-Headers", "X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, Retry-After, X-Request-ID, X-Token-Expires-In, X-Token-Refresh-Suggested, ETag")
 
 			if r.Method == "OPTIONS" {
 				w.WriteHeader(http.StatusOK)
@@ -380,14 +602,24 @@ func corsMiddleware(allowedOrigins string) func(http.Handler) http.Handler {
 	}
 }
 
-// Start starts the API Gateway server
-func (s *Server) Start() error {
+// Start serves the API Gateway until ctx is cancelled, then waits for
+// in-flight requests to finish
+func (s *Server) Start(ctx context.Context) error {
 	s.logger.Infof("Starting API Gateway on port %s", s.config.Port)
-	return http.ListenAndServe(":"+s.config.Port, s.router)
+	srv := &http.Server{Addr: ":" + s.config.Port, Handler: s.router}
+	return graceful.ListenAndServe(ctx, srv, graceful.DefaultTimeout)
 }
 
 // Close closes server resources
 func (s *Server) Close() error {
+	if err := s.tracer.Shutdown(context.Background()); err != nil {
+		s.logger.WithError(err).Warn("Failed to flush trace spans")
+	}
+	if s.accessLog != nil {
+		if err := s.accessLog.Close(); err != nil {
+			s.logger.WithError(err).Warn("Failed to close access log")
+		}
+	}
 	if s.rateLimiter != nil {
 		return s.rateLimiter.Close()
 	}
@@ -401,14 +633,26 @@ func loadConfig(logger *logging.Logger) *Config {
 		jwtSecret = "development-secret-change-in-production"
 	}
 
-	// Validate JWT secret strength
-	if len(jwtSecret) < 32 {
-		logger.Fatalf("JWT_SECRET must be at least 32 characters (got %d). Use a strong random key for security.", len(jwtSecret))
-	}
-
 	// Load rate limiting configuration
 	rateLimitConfig := loadRateLimitConfig()
 
+	// Load audit configuration
+	auditConfig := loadAuditConfig()
+
+	// Load access log configuration
+	accessLogConfig := loadAccessLogConfig(logger)
+
+	// Load event stream configuration
+	sseConfig := loadSSEConfig()
+
+	// Load feature gating configuration
+	featureGateConfig := loadFeatureGateConfig(logger)
+
+	// Load upstream failure handling configuration
+	circuitBreakerConfig := loadCircuitBreakerConfig()
+	staleCacheConfig := loadStaleCacheConfig(logger)
+	proxyConfig := loadProxyConfig()
+
 	return &Config{
 		Port:                    getEnv("PORT", "8080"),
 		AuthServiceURL:          getEnv("AUTH_SERVICE_URL", "http://localhost:8087"),
@@ -425,8 +669,191 @@ func loadConfig(logger *logging.Logger) *Config {
 		AllowedOrigins:          getEnv("ALLOWED_ORIGINS", "http://localhost:5173"),
 		JWTSecret:               jwtSecret,
 		JWTIssuer:               getEnv("JWT_ISSUER", "autolytiq"),
+		JWTRefreshThreshold:     time.Duration(getEnvInt("JWT_REFRESH_THRESHOLD_SECONDS", int(DefaultTokenRefreshThreshold/time.Second))) * time.Second,
 		RateLimitConfig:         rateLimitConfig,
+		AuditConfig:             auditConfig,
+		AccessLogConfig:         accessLogConfig,
+		SSEConfig:               sseConfig,
+		FeatureGateConfig:       featureGateConfig,
+		CircuitBreakerConfig:    circuitBreakerConfig,
+		StaleCacheConfig:        staleCacheConfig,
+		ProxyConfig:             proxyConfig,
+	}
+}
+
+// minJWTSecretLength is the shortest JWT signing secret accepted at startup
+const minJWTSecretLength = 32
+
+// minInternalTokenLength is the shortest internal service token accepted at
+// startup
+const minInternalTokenLength = 32
+
+// validateConfig checks the loaded configuration, plus the raw values of
+// settings that silently fall back to a default when they don't parse, and
+// reports every problem at once
+func validateConfig(config *Config) error {
+	c := configcheck.New()
+
+	c.Port("PORT", config.Port)
+	c.Secret("JWT_SECRET", config.JWTSecret, minJWTSecretLength)
+	c.Secret("INTERNAL_SERVICE_TOKEN", os.Getenv("INTERNAL_SERVICE_TOKEN"), minInternalTokenLength)
+	if _, err := ParseCIDRs(strings.Split(os.Getenv("INTERNAL_NETWORKS"), ",")); err != nil {
+		c.Addf("INTERNAL_NETWORKS", "%v", err)
+	}
+
+	serviceURLs := []struct{ key, value string }{
+		{"AUTH_SERVICE_URL", config.AuthServiceURL},
+		{"DEAL_SERVICE_URL", config.DealServiceURL},
+		{"CUSTOMER_SERVICE_URL", config.CustomerServiceURL},
+		{"INVENTORY_SERVICE_URL", config.InventoryServiceURL},
+		{"EMAIL_SERVICE_URL", config.EmailServiceURL},
+		{"USER_SERVICE_URL", config.UserServiceURL},
+		{"CONFIG_SERVICE_URL", config.ConfigServiceURL},
+		{"SHOWROOM_SERVICE_URL", config.ShowroomServiceURL},
+		{"MESSAGING_SERVICE_URL", config.MessagingServiceURL},
+		{"SETTINGS_SERVICE_URL", config.SettingsServiceURL},
+		{"DATA_RETENTION_SERVICE_URL", config.DataRetentionServiceURL},
+	}
+	for _, u := range serviceURLs {
+		c.Required(u.key, u.value)
+		c.URL(u.key, u.value)
+	}
+
+	if config.RateLimitConfig != nil {
+		c.URL("REDIS_URL", config.RateLimitConfig.RedisURL)
+	}
+	c.Int("REDIS_DB", os.Getenv("REDIS_DB"), 0)
+	c.Int("JWT_REFRESH_THRESHOLD_SECONDS", os.Getenv("JWT_REFRESH_THRESHOLD_SECONDS"), 0)
+	c.Int("PROXY_MAX_RETRIES", os.Getenv("PROXY_MAX_RETRIES"), 0)
+	c.Duration("PROXY_TIMEOUT", os.Getenv("PROXY_TIMEOUT"))
+	c.Duration("PROXY_RETRY_BACKOFF", os.Getenv("PROXY_RETRY_BACKOFF"))
+	for _, key := range []string{"RATE_LIMIT_IP", "RATE_LIMIT_USER", "RATE_LIMIT_DEALERSHIP", "RATE_LIMIT_WINDOW_SECONDS",
+		"SSE_CONNECT_TIMEOUT_SECONDS", "SSE_HEARTBEAT_SECONDS", "SSE_MAX_DURATION_SECONDS", "FEATURE_GATE_CACHE_SECONDS",
+		"CIRCUIT_BREAKER_FAILURES", "CIRCUIT_BREAKER_OPEN_SECONDS", "STALE_CACHE_MAX_AGE_SECONDS", "STALE_CACHE_MAX_ENTRIES"} {
+		c.Int(key, os.Getenv(key), 1)
+	}
+	for _, key := range []string{"RATE_LIMIT_ENABLED", "RATE_LIMIT_LOCAL_FALLBACK", "AUDIT_ENABLED", "ACCESS_LOG_ENABLED", "FEATURE_GATE_FAIL_OPEN"} {
+		c.Bool(key, os.Getenv(key))
+	}
+	if mode := os.Getenv("RATE_LIMIT_FAIL_MODE"); mode != "" && mode != RateLimitFailOpen && mode != RateLimitFailClosed {
+		c.Addf("RATE_LIMIT_FAIL_MODE", "must be open or closed, got %q", mode)
 	}
+	if _, err := ParseFeatureGateRules(os.Getenv("FEATURE_GATED_ROUTES"), false); err != nil {
+		c.Addf("FEATURE_GATED_ROUTES", "%v", err)
+	}
+	if _, err := ParseRouteOverrides(os.Environ()); err != nil {
+		c.Addf("RATE_LIMIT_ROUTE", "%v", err)
+	}
+	if _, err := ParseStaleRoutes(os.Getenv("STALE_CACHE_ROUTES")); err != nil {
+		c.Addf("STALE_CACHE_ROUTES", "%v", err)
+	}
+	if status := os.Getenv("FEATURE_GATE_DISABLED_STATUS"); status != "" && status != "403" && status != "404" {
+		c.Addf("FEATURE_GATE_DISABLED_STATUS", "must be 403 or 404, got %q", status)
+	}
+
+	return c.Err()
+}
+
+// loadAuditConfig loads gateway audit configuration from environment
+func loadAuditConfig() *AuditConfig {
+	config := DefaultAuditConfig()
+
+	config.Enabled = getEnvBool("AUDIT_ENABLED", true)
+
+	// Replace the default sensitive routes (comma-separated "METHOD /route")
+	if routes := getEnv("AUDIT_SENSITIVE_ROUTES", ""); routes != "" {
+		config.Rules = ParseAuditRules(routes)
+	}
+
+	return config
+}
+
+// loadFeatureGateConfig loads feature flag gating from environment. Invalid
+// gated routes are rejected by validateConfig before this is used.
+func loadFeatureGateConfig(logger *logging.Logger) *FeatureGateConfig {
+	config := DefaultFeatureGateConfig()
+
+	config.DisabledStatus = getEnvInt("FEATURE_GATE_DISABLED_STATUS", http.StatusNotFound)
+	config.CacheTTL = time.Duration(getEnvInt("FEATURE_GATE_CACHE_SECONDS", 60)) * time.Second
+
+	// Comma-separated "METHOD /route=flag[:open|:closed]"
+	rules, err := ParseFeatureGateRules(getEnv("FEATURE_GATED_ROUTES", ""), getEnvBool("FEATURE_GATE_FAIL_OPEN", false))
+	if err != nil {
+		logger.Warnf("Invalid FEATURE_GATED_ROUTES, no routes gated: %v", err)
+	}
+	config.Rules = rules
+
+	return config
+}
+
+// loadCircuitBreakerConfig loads upstream circuit breaking from environment
+func loadCircuitBreakerConfig() *CircuitBreakerConfig {
+	config := DefaultCircuitBreakerConfig()
+
+	config.FailureThreshold = getEnvInt("CIRCUIT_BREAKER_FAILURES", 5)
+	config.OpenDuration = time.Duration(getEnvInt("CIRCUIT_BREAKER_OPEN_SECONDS", 30)) * time.Second
+
+	return config
+}
+
+// loadProxyConfig loads proxied request timeouts and retries from environment
+func loadProxyConfig() *ProxyConfig {
+	config := DefaultProxyConfig()
+
+	config.Timeout = getEnvDuration("PROXY_TIMEOUT", config.Timeout)
+	config.MaxRetries = getEnvInt("PROXY_MAX_RETRIES", config.MaxRetries)
+	config.RetryBackoff = getEnvDuration("PROXY_RETRY_BACKOFF", config.RetryBackoff)
+
+	return config
+}
+
+// loadStaleCacheConfig loads stale serving from environment. Invalid routes
+// are rejected by validateConfig before this is used.
+func loadStaleCacheConfig(logger *logging.Logger) *StaleCacheConfig {
+	config := DefaultStaleCacheConfig()
+
+	config.MaxAge = time.Duration(getEnvInt("STALE_CACHE_MAX_AGE_SECONDS", 300)) * time.Second
+	config.MaxEntries = getEnvInt("STALE_CACHE_MAX_ENTRIES", 10000)
+
+	// Comma-separated "/route[=max age seconds]"
+	routes, err := ParseStaleRoutes(getEnv("STALE_CACHE_ROUTES", ""))
+	if err != nil {
+		logger.Warnf("Invalid STALE_CACHE_ROUTES, no routes served stale: %v", err)
+	}
+	config.Routes = routes
+
+	return config
+}
+
+// loadAccessLogConfig loads access log configuration from environment
+func loadAccessLogConfig(logger *logging.Logger) *AccessLogConfig {
+	config := DefaultAccessLogConfig()
+
+	config.Enabled = getEnvBool("ACCESS_LOG_ENABLED", false)
+	config.Destination = getEnv("ACCESS_LOG_DESTINATION", "stdout")
+
+	// Comma-separated subset of access log fields, in output order
+	if value := getEnv("ACCESS_LOG_FIELDS", ""); value != "" {
+		fields, err := ParseAccessLogFields(value)
+		if err != nil {
+			logger.Warnf("Invalid ACCESS_LOG_FIELDS, using defaults: %v", err)
+		} else {
+			config.Fields = fields
+		}
+	}
+
+	return config
+}
+
+// loadSSEConfig loads event stream timeouts from environment
+func loadSSEConfig() *SSEConfig {
+	config := DefaultSSEConfig()
+
+	config.ConnectTimeout = time.Duration(getEnvInt("SSE_CONNECT_TIMEOUT_SECONDS", 10)) * time.Second
+	config.HeartbeatInterval = time.Duration(getEnvInt("SSE_HEARTBEAT_SECONDS", 15)) * time.Second
+	config.MaxDuration = time.Duration(getEnvInt("SSE_MAX_DURATION_SECONDS", 3600)) * time.Second
+
+	return config
 }
 
 // loadRateLimitConfig loads rate limiting configuration from environment
@@ -450,6 +877,24 @@ func loadRateLimitConfig() *RateLimitConfig {
 	// Enabled flag
 	config.Enabled = getEnvBool("RATE_LIMIT_ENABLED", true)
 
+	// Behavior while Redis is unavailable
+	config.FailMode = getEnv("RATE_LIMIT_FAIL_MODE", RateLimitFailOpen)
+	config.LocalFallback = getEnvBool("RATE_LIMIT_LOCAL_FALLBACK", true)
+
+	// Service-to-service calls from these networks that send the internal
+	// token skip rate limiting. Invalid ranges are reported by validateConfig.
+	config.InternalToken = os.Getenv("INTERNAL_SERVICE_TOKEN")
+	if networks := os.Getenv("INTERNAL_NETWORKS"); networks != "" {
+		if parsed, err := ParseCIDRs(strings.Split(networks, ",")); err == nil {
+			config.InternalNetworks = parsed
+		}
+	}
+
+	// Per-route limits; invalid entries are reported by validateConfig
+	if overrides, err := ParseRouteOverrides(os.Environ()); err == nil {
+		config.RouteOverrides = overrides
+	}
+
 	// Bypass paths (comma-separated)
 	bypassPaths := getEnv("RATE_LIMIT_BYPASS_PATHS", "")
 	if bypassPaths != "" {
@@ -475,6 +920,15 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := configcheck.ParseDuration(value); err == nil && duration > 0 {
+			return duration
+		}
+	}
+	return defaultValue
+}
+
 func getEnvBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if boolValue, err := strconv.ParseBool(value); err == nil {
@@ -491,6 +945,13 @@ func main() {
 	})
 
 	config := loadConfig(logger)
+	if err := validateConfig(config); err != nil {
+		logger.Fatal(err.Error())
+	}
+	// Stop serving on SIGINT or SIGTERM, once in-flight requests finish
+	ctx, stop := graceful.SignalContext(context.Background())
+	defer stop()
+
 	server := NewServer(config, logger)
 
 	defer func() {
@@ -499,7 +960,7 @@ func main() {
 		}
 	}()
 
-	if err := server.Start(); err != nil {
+	if err := server.Start(ctx); err != nil {
 		logger.Fatal(err.Error())
 	}
 }
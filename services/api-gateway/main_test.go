@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"autolytiq/shared/configcheck"
+)
+
+func TestValidateConfig_Defaults(t *testing.T) {
+	config := loadConfig(testLogger())
+	if err := validateConfig(config); err != nil {
+		t.Errorf("Expected the default configuration to be valid, got %v", err)
+	}
+}
+
+func TestValidateConfig_ReportsAllProblems(t *testing.T) {
+	t.Setenv("JWT_SECRET", "too-short")
+	t.Setenv("PORT", "http")
+	t.Setenv("DEAL_SERVICE_URL", "deal-service:8081")
+	t.Setenv("RATE_LIMIT_IP", "lots")
+	t.Setenv("RATE_LIMIT_ENABLED", "sometimes")
+
+	err := validateConfig(loadConfig(testLogger()))
+	var configErr *configcheck.Error
+	if !errors.As(err, &configErr) {
+		t.Fatalf("Expected a configuration error, got %v", err)
+	}
+	if len(configErr.Problems) != 5 {
+		t.Errorf("Expected 5 problems in one error, got %d: %v", len(configErr.Problems), err)
+	}
+	for _, key := range []string{"JWT_SECRET", "PORT", "DEAL_SERVICE_URL", "RATE_LIMIT_IP", "RATE_LIMIT_ENABLED"} {
+		if !strings.Contains(err.Error(), key+":") {
+			t.Errorf("Expected the error to name %s, got %q", key, err.Error())
+		}
+	}
+}
+
+func TestValidateConfig_InternalToken(t *testing.T) {
+	t.Setenv("INTERNAL_SERVICE_TOKEN", "short")
+	t.Setenv("INTERNAL_NETWORKS", "10.0.0.0/8,10.1.0.0")
+
+	err := validateConfig(loadConfig(testLogger()))
+	for _, key := range []string{"INTERNAL_SERVICE_TOKEN", "INTERNAL_NETWORKS"} {
+		if err == nil || !strings.Contains(err.Error(), key+":") {
+			t.Errorf("Expected the error to name %s, got %v", key, err)
+		}
+	}
+	if err != nil && strings.Contains(err.Error(), "short") {
+		t.Error("Expected the token itself not to be in the error")
+	}
+}
+
+func TestDataRetentionPrefix(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/api/v1/gdpr/export/customer-1", "/gdpr"},
+		{"/api/v1/gdpr/requests", "/gdpr"},
+		{"/api/v1/consent/versions", "/consent"},
+		{"/api/v1/consent/customer-1/history", "/consent"},
+		{"/api/v1/retention/policies", "/retention"},
+		{"/api/v1/audit/logs/log-1", "/audit"},
+		{"/api/v1/consent/audit", "/consent"},
+
+		// Another version or base path
+		{"/api/v2/gdpr/requests", "/gdpr"},
+		{"/gateway/api/v1/audit/logs", "/audit"},
+		{"/retention/policies", "/retention"},
+
+		// Byte offsets used to match these on a leading substring
+		{"/api/v1/gdprx/requests", ""},
+		{"/api/v1/retention-status", ""},
+		{"/api/v1/auditing/logs", ""},
+		{"/api/v1/consents", ""},
+		{"/api/v1/deals", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := dataRetentionPrefix(tt.path); got != tt.want {
+			t.Errorf("dataRetentionPrefix(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"bufio"
+	"fmt"
+	"net"
 	"net/http"
 	"sync"
 
@@ -12,6 +15,7 @@ import (
 type RateLimitMetrics struct {
 	hitsTotal     *prometheus.CounterVec
 	exceededTotal *prometheus.CounterVec
+	degradedTotal *prometheus.CounterVec
 
 	// HTTP metrics (standard autolytiq namespace for consistency across services)
 	httpRequestsTotal    *prometheus.CounterVec
@@ -56,6 +60,15 @@ func NewRateLimitMetrics() *RateLimitMetrics {
 			},
 			[]string{"limit_type"},
 		),
+		degradedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   "autolytiq",
+				Name:        "rate_limit_degraded_total",
+				Help:        "Total number of rate limit checks made while Redis was unavailable",
+				ConstLabels: prometheus.Labels{"service": serviceName},
+			},
+			[]string{"mode"},
+		),
 		// Standard HTTP metrics (consistent with other services)
 		httpRequestsTotal: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
@@ -127,6 +140,7 @@ func NewRateLimitMetrics() *RateLimitMetrics {
 	m.registry.MustRegister(
 		m.hitsTotal,
 		m.exceededTotal,
+		m.degradedTotal,
 		m.httpRequestsTotal,
 		m.httpRequestDuration,
 		m.httpRequestsInFlight,
@@ -151,6 +165,12 @@ func (m *RateLimitMetrics) RecordExceeded(limitType string) {
 	m.exceededTotal.WithLabelValues(limitType).Inc()
 }
 
+// RecordDegraded increments the counter of checks made while Redis was
+// unavailable, by how they were decided
+func (m *RateLimitMetrics) RecordDegraded(mode string) {
+	m.degradedTotal.WithLabelValues(mode).Inc()
+}
+
 // RecordRequest records an HTTP request
 func (m *RateLimitMetrics) RecordRequest(method, path, status string) {
 	m.requestsTotal.WithLabelValues(method, path, status).Inc()
@@ -239,6 +259,22 @@ func (w *responseWriterWrapper) WriteHeader(code int) {
 	w.ResponseWriter.WriteHeader(code)
 }
 
+// Flush supports streaming responses through the wrapper
+func (w *responseWriterWrapper) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack supports WebSocket upgrades through the wrapper
+func (w *responseWriterWrapper) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
+
 // normalizePath normalizes URL paths to prevent high cardinality metrics
 // It replaces IDs and UUIDs with placeholders
 func normalizePath(path string) string {
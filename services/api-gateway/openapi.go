@@ -0,0 +1,364 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// openAPIVersion is the API version reported in the spec, matching /api/v1/version
+const openAPIVersion = "1.0.0"
+
+// pathVariableRegex matches a mux path variable and its optional pattern,
+// e.g. {id} or {id:[0-9]+}
+var pathVariableRegex = regexp.MustCompile(`\{([^}:]+)(?::[^}]*)?\}`)
+
+// openAPIOperation describes the known request and response schema of a
+// route. The names refer to openAPISchemas.
+type openAPIOperation struct {
+	Summary  string
+	Request  string
+	Response string
+}
+
+// openAPIOperations documents the request and response bodies of routes the
+// gateway knows the shape of, keyed by method and path. Routes that aren't
+// listed are still in the spec, with an untyped JSON body.
+var openAPIOperations = map[string]openAPIOperation{
+	"GET /health":                       {Summary: "Gateway health", Response: "Status"},
+	"GET /ready":                        {Summary: "Readiness probe", Response: "Status"},
+	"GET /live":                         {Summary: "Liveness probe", Response: "Status"},
+	"GET /api/v1/version":               {Summary: "Gateway version", Response: "Version"},
+	"GET /api/v1/openapi.json":          {Summary: "This OpenAPI document"},
+	"POST /api/v1/auth/register":        {Summary: "Register a user", Request: "RegisterRequest", Response: "AuthResponse"},
+	"POST /api/v1/auth/login":           {Summary: "Log in", Request: "LoginRequest", Response: "AuthResponse"},
+	"POST /api/v1/auth/refresh":         {Summary: "Refresh an access token", Request: "RefreshRequest", Response: "AuthResponse"},
+	"POST /api/v1/auth/forgot-password": {Summary: "Request a password reset email", Request: "ForgotPasswordRequest"},
+	"POST /api/v1/auth/reset-password":  {Summary: "Reset a password with a reset token", Request: "ResetPasswordRequest"},
+	"GET /api/v1/auth/me":               {Summary: "The authenticated user", Response: "User"},
+	"POST /api/v1/auth/change-password": {Summary: "Change the authenticated user's password", Request: "ChangePasswordRequest"},
+	"POST /api/v1/auth/invites":         {Summary: "Invite a user to the dealership", Request: "CreateInviteRequest", Response: "InviteResponse"},
+	"POST /api/v1/auth/users":           {Summary: "Create a user in the dealership", Request: "CreateUserRequest", Response: "User"},
+}
+
+// openAPISchemas are the component schemas referred to by openAPIOperations
+var openAPISchemas = map[string]interface{}{
+	"Error": objectSchema(map[string]interface{}{
+		"error": objectSchema(map[string]interface{}{
+			"code":       stringSchema(),
+			"message":    stringSchema(),
+			"request_id": stringSchema(),
+		}, "code", "message"),
+	}, "error"),
+	"Status": objectSchema(map[string]interface{}{
+		"status":    stringSchema(),
+		"service":   stringSchema(),
+		"timestamp": map[string]interface{}{"type": "string", "format": "date-time"},
+	}, "status"),
+	"Version": objectSchema(map[string]interface{}{
+		"version": stringSchema(),
+		"service": stringSchema(),
+	}, "version"),
+	"RegisterRequest": objectSchema(map[string]interface{}{
+		"email":         map[string]interface{}{"type": "string", "format": "email"},
+		"password":      stringSchema(),
+		"first_name":    stringSchema(),
+		"last_name":     stringSchema(),
+		"dealership_id": map[string]interface{}{"type": "string", "format": "uuid"},
+		"invite_token":  stringSchema(),
+	}, "email", "password"),
+	"LoginRequest": objectSchema(map[string]interface{}{
+		"email":    map[string]interface{}{"type": "string", "format": "email"},
+		"password": stringSchema(),
+	}, "email", "password"),
+	"RefreshRequest": objectSchema(map[string]interface{}{
+		"refresh_token": stringSchema(),
+	}, "refresh_token"),
+	"ForgotPasswordRequest": objectSchema(map[string]interface{}{
+		"email": map[string]interface{}{"type": "string", "format": "email"},
+	}, "email"),
+	"ResetPasswordRequest": objectSchema(map[string]interface{}{
+		"token":        stringSchema(),
+		"new_password": stringSchema(),
+	}, "token", "new_password"),
+	"ChangePasswordRequest": objectSchema(map[string]interface{}{
+		"current_password": stringSchema(),
+		"new_password":     stringSchema(),
+	}, "current_password", "new_password"),
+	"CreateInviteRequest": objectSchema(map[string]interface{}{
+		"email": map[string]interface{}{"type": "string", "format": "email"},
+		"role":  stringSchema(),
+	}, "email"),
+	"InviteResponse": objectSchema(map[string]interface{}{
+		"invite_token":  stringSchema(),
+		"email":         stringSchema(),
+		"role":          stringSchema(),
+		"dealership_id": stringSchema(),
+		"expires_at":    map[string]interface{}{"type": "string", "format": "date-time"},
+	}),
+	"CreateUserRequest": objectSchema(map[string]interface{}{
+		"email":      map[string]interface{}{"type": "string", "format": "email"},
+		"password":   stringSchema(),
+		"first_name": stringSchema(),
+		"last_name":  stringSchema(),
+		"role":       stringSchema(),
+	}, "email", "password"),
+	"User": objectSchema(map[string]interface{}{
+		"id":            stringSchema(),
+		"email":         stringSchema(),
+		"first_name":    stringSchema(),
+		"last_name":     stringSchema(),
+		"role":          stringSchema(),
+		"dealership_id": stringSchema(),
+	}),
+	"AuthResponse": objectSchema(map[string]interface{}{
+		"access_token":  stringSchema(),
+		"refresh_token": stringSchema(),
+		"expires_in":    map[string]interface{}{"type": "integer"},
+		"token_type":    stringSchema(),
+		"user":          schemaRef("User"),
+	}),
+}
+
+func stringSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "string"}
+}
+
+func objectSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// jsonContent is a JSON body with the named schema, or any JSON when the
+// schema isn't known
+func jsonContent(schema string) map[string]interface{} {
+	s := map[string]interface{}{}
+	if schema != "" {
+		s = schemaRef(schema)
+	}
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": s}}
+}
+
+// headerRef refers to a header in the spec's components
+func headerRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/headers/" + name}
+}
+
+// openAPIRoute is a registered route as documented in the spec
+type openAPIRoute struct {
+	Path          string
+	Methods       []string
+	Authenticated bool
+	RateLimited   bool
+}
+
+// openAPIRoutes derives the documented routes from the router. Routes on the
+// JWT protected subrouters require a bearer token, and routes on any
+// subrouter are rate limited. Routes without methods, the WebSocket
+// endpoints and the static frontend, aren't part of the REST API and are
+// left out.
+func (s *Server) openAPIRoutes() []openAPIRoute {
+	protected := make(map[*mux.Router]bool)
+	for _, r := range s.protectedRouters {
+		protected[r] = true
+	}
+
+	var routes []openAPIRoute
+	s.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if route.GetHandler() == nil {
+			return nil
+		}
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil || len(methods) == 0 {
+			return nil
+		}
+		routes = append(routes, openAPIRoute{
+			Path:          path,
+			Methods:       methods,
+			Authenticated: protected[router],
+			RateLimited:   router != s.router,
+		})
+		return nil
+	})
+	return routes
+}
+
+// buildOpenAPISpec assembles the OpenAPI document for the registered routes
+func (s *Server) buildOpenAPISpec() map[string]interface{} {
+	paths := make(map[string]map[string]interface{})
+	for _, route := range s.openAPIRoutes() {
+		path := pathVariableRegex.ReplaceAllString(route.Path, "{$1}")
+		item, ok := paths[path]
+		if !ok {
+			item = make(map[string]interface{})
+			paths[path] = item
+			var parameters []interface{}
+			for _, m := range pathVariableRegex.FindAllStringSubmatch(route.Path, -1) {
+				parameters = append(parameters, map[string]interface{}{
+					"name":     m[1],
+					"in":       "path",
+					"required": true,
+					"schema":   stringSchema(),
+				})
+			}
+			if len(parameters) > 0 {
+				item["parameters"] = parameters
+			}
+		}
+		for _, method := range route.Methods {
+			item[strings.ToLower(method)] = openAPIOperationFor(method, path, route)
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Autolytiq API",
+			"version":     openAPIVersion,
+			"description": "Routes served by the Autolytiq API gateway. Protected routes require a bearer access token; rate limited routes report the caller's quota in the X-RateLimit headers and answer 429 with Retry-After when it is exhausted.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": openAPISchemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":         "http",
+					"scheme":       "bearer",
+					"bearerFormat": "JWT",
+				},
+			},
+			"headers": map[string]interface{}{
+				"X-RateLimit-Limit": map[string]interface{}{
+					"description": "Requests allowed in the current window",
+					"schema":      map[string]interface{}{"type": "integer"},
+				},
+				"X-RateLimit-Remaining": map[string]interface{}{
+					"description": "Requests left in the current window",
+					"schema":      map[string]interface{}{"type": "integer"},
+				},
+				"X-RateLimit-Reset": map[string]interface{}{
+					"description": "Unix time the current window resets",
+					"schema":      map[string]interface{}{"type": "integer"},
+				},
+				"Retry-After": map[string]interface{}{
+					"description": "Seconds to wait before retrying",
+					"schema":      map[string]interface{}{"type": "integer"},
+				},
+				HeaderTokenExpiresIn: map[string]interface{}{
+					"description": "Seconds until the access token expires",
+					"schema":      map[string]interface{}{"type": "integer"},
+				},
+				HeaderTokenRefreshSuggested: map[string]interface{}{
+					"description": "Set to true when the access token should be refreshed",
+					"schema":      stringSchema(),
+				},
+			},
+		},
+	}
+}
+
+// openAPIOperationFor documents one method of a route
+func openAPIOperationFor(method, path string, route openAPIRoute) map[string]interface{} {
+	known := openAPIOperations[method+" "+path]
+
+	headers := map[string]interface{}{}
+	if route.RateLimited {
+		headers["X-RateLimit-Limit"] = headerRef("X-RateLimit-Limit")
+		headers["X-RateLimit-Remaining"] = headerRef("X-RateLimit-Remaining")
+		headers["X-RateLimit-Reset"] = headerRef("X-RateLimit-Reset")
+	}
+	if route.Authenticated {
+		headers[HeaderTokenExpiresIn] = headerRef(HeaderTokenExpiresIn)
+		headers[HeaderTokenRefreshSuggested] = headerRef(HeaderTokenRefreshSuggested)
+	}
+
+	success := map[string]interface{}{
+		"description": "Success",
+		"content":     jsonContent(known.Response),
+	}
+	if len(headers) > 0 {
+		success["headers"] = headers
+	}
+	responses := map[string]interface{}{
+		"default": map[string]interface{}{
+			"description": "Error",
+			"content":     jsonContent("Error"),
+		},
+	}
+	responses["200"] = success
+	if route.RateLimited {
+		responses["429"] = map[string]interface{}{
+			"description": "Rate limit exceeded",
+			"headers":     map[string]interface{}{"Retry-After": headerRef("Retry-After")},
+			"content":     jsonContent("Error"),
+		}
+	}
+
+	operation := map[string]interface{}{
+		"operationId": operationID(method, path),
+		"tags":        []string{openAPITag(path)},
+		"responses":   responses,
+	}
+	if known.Summary != "" {
+		operation["summary"] = known.Summary
+	}
+	if route.Authenticated {
+		operation["security"] = []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+		responses["401"] = map[string]interface{}{
+			"description": "Missing, invalid or expired access token",
+			"content":     jsonContent("Error"),
+		}
+	}
+	if method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch {
+		operation["requestBody"] = map[string]interface{}{
+			"required": known.Request != "",
+			"content":  jsonContent(known.Request),
+		}
+	}
+	return operation
+}
+
+// operationID derives a stable operation ID from the method and path, e.g.
+// GET /api/v1/deals/{id} is get_deals_id
+func operationID(method, path string) string {
+	path = strings.TrimPrefix(path, "/api/v1")
+	var parts []string
+	for _, part := range strings.Split(path, "/") {
+		part = strings.Trim(part, "{}")
+		part = strings.NewReplacer("-", "_", ".", "_").Replace(part)
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.ToLower(method) + "_" + strings.Join(parts, "_")
+}
+
+// openAPITag groups operations by the first path segment after /api/v1.
+// Routes outside /api/v1 are the gateway's own.
+func openAPITag(path string) string {
+	if !strings.HasPrefix(path, "/api/v1/") {
+		return "gateway"
+	}
+	return strings.SplitN(strings.TrimPrefix(path, "/api/v1/"), "/", 2)[0]
+}
+
+// openAPISpec handles GET /api/v1/openapi.json
+func (s *Server) openAPISpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s.buildOpenAPISpec())
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestOpenAPISpecListsRegisteredRoutes(t *testing.T) {
+	config := &Config{
+		Port:           "8080",
+		AllowedOrigins: "*",
+		JWTSecret:      "development-secret-change-in-production-testing",
+		JWTIssuer:      "test-issuer",
+	}
+	server := NewServer(config, proxyTestLogger())
+
+	req := httptest.NewRequest("GET", "/api/v1/openapi.json", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 without a token, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("Failed to decode spec: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("Expected an OpenAPI 3 document, got %q", spec.OpenAPI)
+	}
+
+	// Every route with methods is documented with exactly those methods
+	documented := 0
+	server.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil || route.GetHandler() == nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		item, ok := spec.Paths[path]
+		if !ok {
+			t.Errorf("Expected %s in the spec", path)
+			return nil
+		}
+		for _, method := range methods {
+			if _, ok := item[strings.ToLower(method)]; !ok {
+				t.Errorf("Expected %s %s in the spec", method, path)
+			}
+			documented++
+		}
+		return nil
+	})
+	operations := 0
+	for path, item := range spec.Paths {
+		for method := range item {
+			if method != "parameters" {
+				operations++
+			}
+		}
+		if strings.HasPrefix(path, "/ws/") {
+			t.Errorf("Expected the WebSocket endpoint %s to be left out", path)
+		}
+	}
+	if operations != documented {
+		t.Errorf("Expected %d operations, got %d", documented, operations)
+	}
+
+	var operation struct {
+		Security  []interface{} `json:"security"`
+		Responses map[string]struct {
+			Headers map[string]interface{} `json:"headers"`
+		} `json:"responses"`
+	}
+	deal := spec.Paths["/api/v1/deals/{id}"]
+	if _, ok := deal["delete"]; !ok {
+		t.Fatalf("Expected DELETE /api/v1/deals/{id}, got %v", deal)
+	}
+	if _, ok := deal["patch"]; ok {
+		t.Error("Expected no PATCH for /api/v1/deals/{id}")
+	}
+	json.Unmarshal(deal["get"], &operation)
+	if len(operation.Security) == 0 {
+		t.Error("Expected protected routes to require a bearer token")
+	}
+	headers := operation.Responses["200"].Headers
+	for _, header := range []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", HeaderTokenExpiresIn} {
+		if _, ok := headers[header]; !ok {
+			t.Errorf("Expected the %s header to be documented, got %v", header, headers)
+		}
+	}
+
+	operation.Security = nil
+	operation.Responses = nil
+	json.Unmarshal(spec.Paths["/api/v1/auth/login"]["post"], &operation)
+	if len(operation.Security) != 0 {
+		t.Error("Expected login not to require a token")
+	}
+	if _, ok := operation.Responses["429"]; !ok {
+		t.Error("Expected login to document the rate limit response")
+	}
+	if !strings.Contains(rr.Body.String(), `"$ref":"#/components/schemas/LoginRequest"`) {
+		t.Error("Expected the login request schema to be referenced")
+	}
+}
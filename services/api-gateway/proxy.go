@@ -7,23 +7,13 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
-	"time"
 
+	"autolytiq/shared/apierror"
 	"autolytiq/shared/logging"
 
 	"github.com/gorilla/websocket"
 )
 
-// httpClient is a shared HTTP client with reasonable timeouts
-var httpClient = &http.Client{
-	Timeout: 30 * time.Second,
-	Transport: &http.Transport{
-		MaxIdleConns:        100,
-		MaxIdleConnsPerHost: 10,
-		IdleConnTimeout:     90 * time.Second,
-	},
-}
-
 // proxyRequestPublic forwards an HTTP request to a target service without requiring JWT context
 // Used for public endpoints like login, register, etc.
 func (s *Server) proxyRequestPublic(w http.ResponseWriter, r *http.Request, targetServiceURL, basePath string) {
@@ -45,7 +35,7 @@ func (s *Server) doProxyRequest(w http.ResponseWriter, r *http.Request, targetSe
 	dealershipID := GetDealershipIDFromContext(r.Context())
 	if requireAuth && dealershipID == "" {
 		ctxLogger.Warn("No dealership_id found in JWT context")
-		http.Error(w, `{"error":"Missing dealership context"}`, http.StatusBadRequest)
+		apierror.WriteError(w, http.StatusBadRequest, "MISSING_DEALERSHIP_CONTEXT", "Missing dealership context")
 		return
 	}
 
@@ -66,7 +56,7 @@ func (s *Server) doProxyRequest(w http.ResponseWriter, r *http.Request, targetSe
 		bodyBytes, err = io.ReadAll(r.Body)
 		if err != nil {
 			ctxLogger.WithError(err).Error("Failed to read request body")
-			http.Error(w, `{"error":"Failed to read request body"}`, http.StatusInternalServerError)
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read request body")
 			return
 		}
 		defer r.Body.Close()
@@ -76,7 +66,7 @@ func (s *Server) doProxyRequest(w http.ResponseWriter, r *http.Request, targetSe
 	proxyReq, err := http.NewRequestWithContext(r.Context(), r.Method, targetURL, bytes.NewReader(bodyBytes))
 	if err != nil {
 		ctxLogger.WithError(err).Error("Failed to create proxy request")
-		http.Error(w, `{"error":"Failed to create proxy request"}`, http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to create proxy request")
 		return
 	}
 
@@ -90,7 +80,8 @@ func (s *Server) doProxyRequest(w http.ResponseWriter, r *http.Request, targetSe
 		}
 	}
 
-	// Propagate logging headers
+	// Propagate logging headers, so the service logs the gateway's request ID
+	// rather than generating its own
 	logging.PropagateHeadersFromContext(r.Context(), proxyReq)
 
 	// Add dealership context header (only if present)
@@ -105,17 +96,50 @@ func (s *Server) doProxyRequest(w http.ResponseWriter, r *http.Request, targetSe
 	if email := GetEmailFromContext(r.Context()); email != "" {
 		proxyReq.Header.Set("X-User-Email", email)
 	}
+	// Services authorize on the role, so only trust the token's claim
+	proxyReq.Header.Del("X-User-Role")
 	if role := GetRoleFromContext(r.Context()); role != "" {
 		proxyReq.Header.Set("X-User-Role", role)
 	}
 
+	// Services throttle by client address, so pass the one the gateway rate limits on
+	proxyReq.Header.Set("X-Real-IP", getClientIP(r))
+
+	// Scopes grant access to sensitive fields, so only trust the token's claims
+	proxyReq.Header.Del("X-User-Scopes")
+	if scopes := GetScopesFromContext(r.Context()); len(scopes) > 0 {
+		proxyReq.Header.Set("X-User-Scopes", strings.Join(scopes, ","))
+	}
+
+	// Event streams are long-lived and flushed as they arrive
+	if isEventStreamRequest(r) {
+		s.streamEvents(w, r, proxyReq, targetURL)
+		return
+	}
+
+	// Skip upstreams that keep failing, serving the last good response
+	// where the route allows it
+	breaker := s.breakerFor(targetServiceURL)
+	staleMaxAge, staleAllowed := s.config.StaleCacheConfig.match(r)
+	if !breaker.Allow() {
+		if staleAllowed && s.serveStale(w, r, staleMaxAge) {
+			return
+		}
+		apierror.WriteError(w, http.StatusServiceUnavailable, "CIRCUIT_OPEN", "Service unavailable: circuit open")
+		return
+	}
+
 	// Execute request
-	resp, err := httpClient.Do(proxyReq)
+	resp, err := s.sendProxyRequest(proxyReq)
 	if err != nil {
+		breaker.Failure()
 		ctxLogger.WithError(err).WithFields(map[string]interface{}{
 			"target_url": targetURL,
 		}).Error("Proxy request failed")
-		http.Error(w, fmt.Sprintf(`{"error":"Service unavailable: %v"}`, err), http.StatusServiceUnavailable)
+		if staleAllowed && s.serveStale(w, r, staleMaxAge) {
+			return
+		}
+		writeProxyError(w, err)
 		return
 	}
 	defer resp.Body.Close()
@@ -123,11 +147,24 @@ func (s *Server) doProxyRequest(w http.ResponseWriter, r *http.Request, targetSe
 	// Read response body
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
+		breaker.Failure()
 		ctxLogger.WithError(err).Error("Failed to read response body")
-		http.Error(w, `{"error":"Failed to read service response"}`, http.StatusInternalServerError)
+		writeProxyError(w, err)
 		return
 	}
 
+	if isUpstreamFailure(resp.StatusCode) {
+		breaker.Failure()
+		if staleAllowed && s.serveStale(w, r, staleMaxAge) {
+			return
+		}
+	} else {
+		breaker.Success()
+		if staleAllowed && resp.StatusCode == http.StatusOK {
+			s.staleCache.Put(staleCacheKey(r), resp.StatusCode, resp.Header, respBody)
+		}
+	}
+
 	// Copy response headers
 	for name, values := range resp.Header {
 		for _, value := range values {
@@ -147,10 +184,23 @@ func (s *Server) doProxyRequest(w http.ResponseWriter, r *http.Request, targetSe
 	}).Debug("Proxy request completed")
 }
 
+// writeProxyError answers a request the service didn't respond to: 504 if it
+// ran out of time, 502 otherwise
+func writeProxyError(w http.ResponseWriter, err error) {
+	if isTimeout(err) {
+		apierror.WriteError(w, http.StatusGatewayTimeout, "GATEWAY_TIMEOUT", "Service did not respond in time")
+		return
+	}
+	apierror.WriteError(w, http.StatusBadGateway, "BAD_GATEWAY", fmt.Sprintf("Service unavailable: %v", err))
+}
+
 // WebSocket upgrader for the API gateway
 var wsUpgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	// Echoed to clients that authenticated with the bearer subprotocol,
+	// which browsers require before they accept the connection
+	Subprotocols: []string{WebSocketBearerProtocol},
 	CheckOrigin: func(r *http.Request) bool {
 		return true // Allow all origins in development
 	},
@@ -165,7 +215,7 @@ func (s *Server) proxyWebSocket(w http.ResponseWriter, r *http.Request, targetSe
 	targetURL, err := url.Parse(targetServiceURL)
 	if err != nil {
 		ctxLogger.WithError(err).Error("Failed to parse target URL")
-		http.Error(w, `{"error":"Invalid target URL"}`, http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Invalid target URL")
 		return
 	}
 
@@ -183,14 +233,30 @@ func (s *Server) proxyWebSocket(w http.ResponseWriter, r *http.Request, targetSe
 
 	ctxLogger.WithField("target", wsTarget).Debug("Proxying WebSocket connection")
 
+	// Pass the request ID, and the identity of authenticated connections, to
+	// the backend
+	backendHeader := http.Header{}
+	if requestID := logging.GetTraceID(r.Context()); requestID != "" {
+		backendHeader.Set(logging.RequestIDHeader, requestID)
+	}
+	if dealershipID := GetDealershipIDFromContext(r.Context()); dealershipID != "" {
+		backendHeader.Set("X-Dealership-ID", dealershipID)
+	}
+	if userID := GetUserIDFromContext(r.Context()); userID != "" {
+		backendHeader.Set("X-User-ID", userID)
+	}
+	if role := GetRoleFromContext(r.Context()); role != "" {
+		backendHeader.Set("X-User-Role", role)
+	}
+
 	// Connect to the backend WebSocket service
-	backendConn, resp, err := websocket.DefaultDialer.Dial(wsTarget, nil)
+	backendConn, resp, err := websocket.DefaultDialer.Dial(wsTarget, backendHeader)
 	if err != nil {
 		ctxLogger.WithError(err).Error("Failed to connect to backend WebSocket")
 		if resp != nil {
 			ctxLogger.WithField("backend_status", resp.StatusCode).Error("Backend response status")
 		}
-		http.Error(w, `{"error":"Failed to connect to backend"}`, http.StatusServiceUnavailable)
+		apierror.WriteError(w, http.StatusServiceUnavailable, apierror.CodeServiceUnavailable, "Failed to connect to backend")
 		return
 	}
 	defer backendConn.Close()
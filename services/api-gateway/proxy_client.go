@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"autolytiq/shared/tracing"
+)
+
+// ProxyConfig controls the requests the gateway proxies to services
+type ProxyConfig struct {
+	// Timeout bounds each attempt at a proxied request, including reading
+	// the response. Requests that run past it are answered 504.
+	Timeout time.Duration
+
+	// MaxRetries is how many times a GET that got no response is retried.
+	// Other methods are never retried, so a create is never sent twice.
+	MaxRetries int
+
+	// RetryBackoff is the wait before the first retry, doubling for each
+	// retry after it
+	RetryBackoff time.Duration
+}
+
+// DefaultProxyConfig returns the default proxy configuration
+func DefaultProxyConfig() *ProxyConfig {
+	return &ProxyConfig{
+		Timeout:      10 * time.Second,
+		MaxRetries:   2,
+		RetryBackoff: 100 * time.Millisecond,
+	}
+}
+
+// newProxyClient returns the HTTP client for proxied requests. It sends the
+// trace on to the services it calls.
+func newProxyClient(config *ProxyConfig) *http.Client {
+	return &http.Client{
+		Timeout: config.Timeout,
+		Transport: tracing.Transport(&http.Transport{
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     90 * time.Second,
+		}),
+	}
+}
+
+// sendProxyRequest sends a proxied request, retrying a GET with exponential
+// backoff while the service can't be reached or doesn't answer in time.
+// Requests the service answered, even with an error, are not retried.
+func (s *Server) sendProxyRequest(req *http.Request) (*http.Response, error) {
+	attempts := 1
+	if req.Method == http.MethodGet {
+		attempts += s.config.ProxyConfig.MaxRetries
+	}
+	backoff := s.config.ProxyConfig.RetryBackoff
+
+	for attempt := 1; ; attempt++ {
+		resp, err := s.proxyClient.Do(req)
+		if err == nil || attempt >= attempts || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		s.logger.WithContext(req.Context()).WithError(err).WithFields(map[string]interface{}{
+			"target_url": req.URL.String(),
+			"attempt":    attempt,
+		}).Warn("Proxy request failed, retrying")
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, err
+		}
+		backoff *= 2
+
+		// The body was consumed by the failed attempt
+		if req.GetBody != nil {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// isTimeout reports whether a proxied request failed by running out of time
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func setupProxyClientServer(t *testing.T, backendURL string, proxyConfig *ProxyConfig) *Server {
+	t.Helper()
+
+	config := &Config{
+		Port:               "8080",
+		DealServiceURL:     backendURL,
+		SettingsServiceURL: backendURL,
+		AllowedOrigins:     "*",
+		JWTSecret:          "development-secret-change-in-production-testing",
+		JWTIssuer:          "test-issuer",
+		ProxyConfig:        proxyConfig,
+	}
+	return NewServer(config, proxyTestLogger())
+}
+
+func proxyClientRequest(t *testing.T, server *Server, method, path string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	token, err := GenerateToken(server.jwtConfig, "user-123", "dealer-1", "user@example.com", "MANAGER")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body *strings.Reader
+	if method == http.MethodGet {
+		body = strings.NewReader("")
+	} else {
+		body = strings.NewReader(`{"customer_id":"customer-1"}`)
+	}
+	req := httptest.NewRequest(method, path, body)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func errorCode(t *testing.T, rr *httptest.ResponseRecorder) string {
+	t.Helper()
+	var resp errorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Expected a JSON error, got %q", rr.Body.String())
+	}
+	return resp.Error.Code
+}
+
+func TestProxyTimeout(t *testing.T) {
+	var hits int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer backend.Close()
+
+	server := setupProxyClientServer(t, backend.URL, &ProxyConfig{
+		Timeout:      50 * time.Millisecond,
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+	})
+
+	tests := []struct {
+		method   string
+		path     string
+		wantHits int32
+	}{
+		{"GET", "/api/v1/deals", 3},
+		{"POST", "/api/v1/deals", 1},
+	}
+	for _, tt := range tests {
+		atomic.StoreInt32(&hits, 0)
+		rr := proxyClientRequest(t, server, tt.method, tt.path)
+		if rr.Code != http.StatusGatewayTimeout {
+			t.Errorf("%s: expected 504, got %d: %s", tt.method, rr.Code, rr.Body.String())
+		} else if code := errorCode(t, rr); code != "GATEWAY_TIMEOUT" {
+			t.Errorf("%s: expected GATEWAY_TIMEOUT, got %q", tt.method, code)
+		}
+		if got := atomic.LoadInt32(&hits); got != tt.wantHits {
+			t.Errorf("%s: expected %d attempts, got %d", tt.method, tt.wantHits, got)
+		}
+	}
+}
+
+// droppingBackend closes the connection without a response for the first
+// drops requests, then answers 200
+func droppingBackend(t *testing.T, drops int32, hits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(hits, 1) <= drops {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("Hijack failed: %v", err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"deals":[]}`))
+	}))
+}
+
+func TestProxyRetriesGet(t *testing.T) {
+	var hits int32
+	backend := droppingBackend(t, 2, &hits)
+	defer backend.Close()
+
+	server := setupProxyClientServer(t, backend.URL, &ProxyConfig{
+		Timeout:      time.Second,
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+	})
+
+	rr := proxyClientRequest(t, server, "GET", "/api/v1/deals")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected the retried GET to succeed, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := atomic.LoadInt32(&hits); got != 3 {
+		t.Errorf("Expected 3 attempts, got %d", got)
+	}
+}
+
+func TestProxyDoesNotRetryWrites(t *testing.T) {
+	writes := []struct{ method, path string }{
+		{"POST", "/api/v1/deals"},
+		{"PUT", "/api/v1/deals/6f1c2b9e-3a4d-4c5e-8f7a-9b0c1d2e3f40"},
+		{"PATCH", "/api/v1/settings/user/notifications"},
+	}
+	for _, write := range writes {
+		var hits int32
+		backend := droppingBackend(t, 1, &hits)
+
+		server := setupProxyClientServer(t, backend.URL, &ProxyConfig{
+			Timeout:      time.Second,
+			MaxRetries:   2,
+			RetryBackoff: time.Millisecond,
+		})
+
+		rr := proxyClientRequest(t, server, write.method, write.path)
+		if rr.Code != http.StatusBadGateway {
+			t.Errorf("%s: expected 502, got %d: %s", write.method, rr.Code, rr.Body.String())
+		} else if code := errorCode(t, rr); code != "BAD_GATEWAY" {
+			t.Errorf("%s: expected BAD_GATEWAY, got %q", write.method, code)
+		}
+		if got := atomic.LoadInt32(&hits); got != 1 {
+			t.Errorf("%s: expected a single attempt, got %d", write.method, got)
+		}
+		backend.Close()
+	}
+}
+
+func TestProxyRetriesExhausted(t *testing.T) {
+	var hits int32
+	backend := droppingBackend(t, 10, &hits)
+	defer backend.Close()
+
+	server := setupProxyClientServer(t, backend.URL, &ProxyConfig{
+		Timeout:      time.Second,
+		MaxRetries:   1,
+		RetryBackoff: time.Millisecond,
+	})
+
+	rr := proxyClientRequest(t, server, "GET", "/api/v1/deals")
+	if rr.Code != http.StatusBadGateway {
+		t.Errorf("Expected 502 once retries run out, got %d", rr.Code)
+	}
+	if got := atomic.LoadInt32(&hits); got != 2 {
+		t.Errorf("Expected 2 attempts, got %d", got)
+	}
+}
+
+func TestLoadProxyConfig(t *testing.T) {
+	t.Setenv("PROXY_TIMEOUT", "3s")
+	t.Setenv("PROXY_MAX_RETRIES", "0")
+	t.Setenv("PROXY_RETRY_BACKOFF", "not-a-duration")
+
+	config := loadProxyConfig()
+	if config.Timeout != 3*time.Second {
+		t.Errorf("Expected a 3s timeout, got %v", config.Timeout)
+	}
+	if config.MaxRetries != 0 {
+		t.Errorf("Expected retries off, got %d", config.MaxRetries)
+	}
+	if config.RetryBackoff != DefaultProxyConfig().RetryBackoff {
+		t.Errorf("Expected the default backoff for an invalid setting, got %v", config.RetryBackoff)
+	}
+}
@@ -1,14 +1,19 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"autolytiq/shared/logging"
+
+	"github.com/gorilla/websocket"
 )
 
 // proxyTestLogger creates a logger for proxy tests
@@ -198,6 +203,86 @@ func TestProxyRequest_HeaderForwarding(t *testing.T) {
 	}
 }
 
+func TestProxyRequest_ScopesFromClaimsOnly(t *testing.T) {
+	tests := []struct {
+		name     string
+		scopes   []string
+		expected string
+	}{
+		{"scopes from claims", []string{"inventory:read", "inventory:cost"}, "inventory:read,inventory:cost"},
+		{"no scopes in claims", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received string
+			mockService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = r.Header.Get("X-User-Scopes")
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer mockService.Close()
+
+			config := &Config{
+				Port:                "8080",
+				InventoryServiceURL: mockService.URL,
+				AllowedOrigins:      "*",
+				JWTSecret:           "development-secret-change-in-production-testing",
+				JWTIssuer:           "test-issuer",
+			}
+			server := NewServer(config, proxyTestLogger())
+
+			// A client-supplied scopes header must never reach the backend
+			req := httptest.NewRequest("GET", "/api/v1/inventory/vehicles", nil)
+			req.Header.Set("X-User-Scopes", "inventory:cost")
+
+			ctx := req.Context()
+			ctx = context.WithValue(ctx, ContextKeyDealershipID, "test-dealership-123")
+			ctx = context.WithValue(ctx, ContextKeyScopes, tt.scopes)
+			req = req.WithContext(ctx)
+
+			rr := httptest.NewRecorder()
+			server.proxyToInventoryService(rr, req)
+
+			if received != tt.expected {
+				t.Errorf("Expected X-User-Scopes %q, got %q", tt.expected, received)
+			}
+		})
+	}
+}
+
+func TestProxyRequest_RoleFromClaimsOnly(t *testing.T) {
+	var received string
+	mockService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("X-User-Role")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockService.Close()
+
+	config := &Config{
+		Port:             "8080",
+		ConfigServiceURL: mockService.URL,
+		AllowedOrigins:   "*",
+		JWTSecret:        "development-secret-change-in-production-testing",
+		JWTIssuer:        "test-issuer",
+	}
+	server := NewServer(config, proxyTestLogger())
+
+	for _, role := range []string{"salesperson", ""} {
+		// A client-supplied role header must never reach the backend
+		req := httptest.NewRequest("GET", "/api/v1/config/settings", nil)
+		req.Header.Set("X-User-Role", "admin")
+		ctx := context.WithValue(req.Context(), ContextKeyDealershipID, "test-dealership-123")
+		ctx = context.WithValue(ctx, ContextKeyRole, role)
+
+		rr := httptest.NewRecorder()
+		server.proxyToConfigService(rr, req.WithContext(ctx))
+
+		if received != role {
+			t.Errorf("Expected X-User-Role %q, got %q", role, received)
+		}
+	}
+}
+
 func TestProxyRequest_QueryParameters(t *testing.T) {
 	// Create mock backend service that checks query parameters
 	mockService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -269,9 +354,9 @@ func TestProxyRequest_ErrorHandling(t *testing.T) {
 	// Execute proxy request
 	server.proxyToDealService(rr, req)
 
-	// Verify response - should return 503 Service Unavailable
-	if status := rr.Code; status != http.StatusServiceUnavailable {
-		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusServiceUnavailable)
+	// Verify response - should return 502 Bad Gateway
+	if status := rr.Code; status != http.StatusBadGateway {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadGateway)
 	}
 
 	// Verify error message
@@ -368,3 +453,159 @@ func TestProxyRequest_AllServices(t *testing.T) {
 		})
 	}
 }
+
+// loggedRequestIDs returns the request IDs of the log lines in buf with the
+// given message
+func loggedRequestIDs(t *testing.T, buf *bytes.Buffer, message string) []string {
+	t.Helper()
+	var ids []string
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("Invalid log line %q: %v", scanner.Text(), err)
+		}
+		if line["message"] == message {
+			id, _ := line["trace_id"].(string)
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func TestProxyRequest_RequestIDInGatewayAndServiceLogs(t *testing.T) {
+	// The service logs from its handler, so the line is written before the
+	// gateway gets the response
+	var serviceLogs bytes.Buffer
+	serviceLogger := logging.New(logging.Config{Service: "deal-service", Output: &serviceLogs})
+	mockService := httptest.NewServer(logging.CombinedMiddleware(serviceLogger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logging.FromContext(r.Context(), serviceLogger).Info("listing deals")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"deals":[]}`))
+	})))
+	defer mockService.Close()
+
+	var gatewayLogs bytes.Buffer
+	config := &Config{
+		Port:           "8080",
+		DealServiceURL: mockService.URL,
+		AllowedOrigins: "*",
+		JWTSecret:      "development-secret-change-in-production-testing",
+		JWTIssuer:      "test-issuer",
+	}
+	server := NewServer(config, logging.New(logging.Config{Service: "api-gateway", Output: &gatewayLogs}))
+	token, err := GenerateToken(server.jwtConfig, "user-123", "dealer-1", "user@example.com", "MANAGER")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		clientRequestID string
+	}{
+		{"generated by the gateway", ""},
+		{"sent by the client", "client-request-1"},
+	}
+	for _, tt := range tests {
+		gatewayLogs.Reset()
+		serviceLogs.Reset()
+
+		req := httptest.NewRequest("GET", "/api/v1/deals", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if tt.clientRequestID != "" {
+			req.Header.Set(logging.RequestIDHeader, tt.clientRequestID)
+		}
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", tt.name, rr.Code, rr.Body.String())
+		}
+
+		requestID := rr.Header().Get(logging.RequestIDHeader)
+		if requestID == "" || (tt.clientRequestID != "" && requestID != tt.clientRequestID) {
+			t.Errorf("%s: unexpected response request ID %q", tt.name, requestID)
+		}
+		gatewayIDs := loggedRequestIDs(t, &gatewayLogs, "request completed")
+		serviceIDs := loggedRequestIDs(t, &serviceLogs, "listing deals")
+		if len(gatewayIDs) != 1 || gatewayIDs[0] != requestID {
+			t.Errorf("%s: expected the gateway to log request ID %q, got %v", tt.name, requestID, gatewayIDs)
+		}
+		if len(serviceIDs) != 1 || serviceIDs[0] != requestID {
+			t.Errorf("%s: expected the service to log request ID %q, got %v", tt.name, requestID, serviceIDs)
+		}
+	}
+}
+
+func TestProxyWebSocket_Authentication(t *testing.T) {
+	// The backend answers each connection with the identity it was given
+	backendUpgrader := websocket.Upgrader{}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity := r.URL.Path + " " + r.Header.Get("X-User-ID") + " " + r.Header.Get("X-Dealership-ID")
+		conn, err := backendUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteMessage(websocket.TextMessage, []byte(identity))
+		conn.ReadMessage()
+	}))
+	defer backend.Close()
+
+	config := &Config{
+		Port:                "8080",
+		ShowroomServiceURL:  backend.URL,
+		MessagingServiceURL: backend.URL,
+		AllowedOrigins:      "*",
+		JWTSecret:           "development-secret-change-in-production-testing",
+		JWTIssuer:           "test-issuer",
+	}
+	server := NewServer(config, proxyTestLogger())
+	gateway := httptest.NewServer(server.router)
+	defer gateway.Close()
+	wsURL := "ws" + strings.TrimPrefix(gateway.URL, "http")
+
+	token, err := GenerateToken(server.jwtConfig, "user-123", "dealer-1", "user@example.com", "SALES")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/ws/messaging", "/ws/showroom"} {
+		// Unauthenticated upgrades are rejected before reaching the service
+		_, resp, err := websocket.DefaultDialer.Dial(wsURL+path, nil)
+		if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401 without a token, got %v", path, resp)
+		}
+		_, resp, err = websocket.DefaultDialer.Dial(wsURL+path+"?access_token=not-a-token", nil)
+		if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401 with an invalid token, got %v", path, resp)
+		}
+
+		// Accepted upgrades carry the token's identity to the service
+		dialers := map[string]func() (*websocket.Conn, *http.Response, error){
+			"query": func() (*websocket.Conn, *http.Response, error) {
+				return websocket.DefaultDialer.Dial(wsURL+path+"?access_token="+token, nil)
+			},
+			"subprotocol": func() (*websocket.Conn, *http.Response, error) {
+				dialer := &websocket.Dialer{Subprotocols: []string{WebSocketBearerProtocol, token}}
+				return dialer.Dial(wsURL+path, nil)
+			},
+		}
+		for name, dial := range dialers {
+			conn, _, err := dial()
+			if err != nil {
+				t.Errorf("%s with a %s token: dial failed: %v", path, name, err)
+				continue
+			}
+			if name == "subprotocol" && conn.Subprotocol() != WebSocketBearerProtocol {
+				t.Errorf("%s: expected the bearer subprotocol to be accepted, got %q", path, conn.Subprotocol())
+			}
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				t.Errorf("%s with a %s token: read failed: %v", path, name, err)
+			} else if want := path + " user-123 dealer-1"; string(message) != want {
+				t.Errorf("%s with a %s token: expected %q, got %q", path, name, want, message)
+			}
+			conn.Close()
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -11,6 +12,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/gorilla/mux"
+
 	"autolytiq/shared/logging"
 
 	"github.com/redis/go-redis/v9"
@@ -28,6 +31,12 @@ type RateLimitConfig struct {
 	UserRateLimit       int // For authenticated users
 	DealershipRateLimit int // For dealership-wide limits
 
+	// RouteOverrides are limits for route templates such as
+	// /api/v1/gdpr/export/{customer_id}, counted per user, or per IP for
+	// unauthenticated requests. Precedence is route > dealership > user > IP:
+	// a route's override replaces the other limits for that route.
+	RouteOverrides map[string]int
+
 	// Window duration
 	WindowDuration time.Duration
 
@@ -36,8 +45,99 @@ type RateLimitConfig struct {
 
 	// Enable/disable rate limiting
 	Enabled bool
+
+	// FailMode decides requests while Redis is unavailable and LocalFallback
+	// is off: RateLimitFailOpen allows them, RateLimitFailClosed rejects them
+	FailMode string
+
+	// LocalFallback limits requests with a per-instance in-memory limiter
+	// while Redis is unavailable, instead of applying FailMode
+	LocalFallback bool
+
+	// InternalToken lets service-to-service calls that send it in
+	// X-Internal-Token skip rate limiting. Empty disables the bypass.
+	InternalToken string
+
+	// InternalNetworks are the networks the internal token is honored from,
+	// so a leaked token can't be used from the public internet
+	InternalNetworks []*net.IPNet
 }
 
+// InternalTokenHeader carries the shared secret of service-to-service calls
+const InternalTokenHeader = "X-Internal-Token"
+
+// DefaultInternalNetworks are the private and loopback ranges internal
+// calls come from unless INTERNAL_NETWORKS says otherwise
+var DefaultInternalNetworks = []string{
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"127.0.0.0/8",
+	"::1/128",
+	"fc00::/7",
+}
+
+// routeOverrideEnvPrefix starts the environment variables that set route
+// overrides, e.g. RATE_LIMIT_ROUTE_GDPR_EXPORT=/api/v1/gdpr/export/{customer_id}=10
+const routeOverrideEnvPrefix = "RATE_LIMIT_ROUTE_"
+
+// ParseRouteOverrides reads route overrides from RATE_LIMIT_ROUTE_<name>
+// variables in environ, each a "/route=limit" pair. The name is only a label.
+func ParseRouteOverrides(environ []string) (map[string]int, error) {
+	overrides := make(map[string]int)
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, routeOverrideEnvPrefix) {
+			continue
+		}
+		split := strings.Index(kv, "=")
+		key, value := kv[:split], kv[split+1:]
+
+		eq := strings.LastIndex(value, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("%s must be /route=limit, got %q", key, value)
+		}
+		route := strings.TrimSpace(value[:eq])
+		limit, err := strconv.Atoi(strings.TrimSpace(value[eq+1:]))
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("%s must have a positive limit, got %q", key, value)
+		}
+		if !strings.HasPrefix(route, "/") {
+			return nil, fmt.Errorf("%s must start with a route path, got %q", key, value)
+		}
+		overrides[route] = limit
+	}
+	return overrides, nil
+}
+
+// ParseCIDRs parses a list of CIDR ranges, ignoring blank entries
+func ParseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", cidr)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// Rate limiter fail modes
+const (
+	RateLimitFailOpen   = "open"
+	RateLimitFailClosed = "closed"
+)
+
+// degradedLocal labels degraded evaluations made by the in-memory limiter
+const degradedLocal = "local"
+
+// redisHealthCheckInterval is how often Redis availability is rechecked
+const redisHealthCheckInterval = 30 * time.Second
+
 // DefaultRateLimitConfig returns default rate limiting configuration
 func DefaultRateLimitConfig() *RateLimitConfig {
 	return &RateLimitConfig{
@@ -50,15 +150,30 @@ func DefaultRateLimitConfig() *RateLimitConfig {
 		WindowDuration:      time.Minute,
 		BypassPaths:         []string{"/health", "/api/v1/version", "/metrics", "/ready", "/live"},
 		Enabled:             true,
+		FailMode:            RateLimitFailOpen,
+		LocalFallback:       true,
+		InternalNetworks:    mustParseCIDRs(DefaultInternalNetworks),
 	}
 }
 
+func mustParseCIDRs(cidrs []string) []*net.IPNet {
+	networks, err := ParseCIDRs(cidrs)
+	if err != nil {
+		panic(err)
+	}
+	return networks
+}
+
 // RateLimitInfo contains rate limit state for a key
 type RateLimitInfo struct {
 	Limit     int       `json:"limit"`
 	Remaining int       `json:"remaining"`
 	ResetAt   time.Time `json:"reset_at"`
 	Exceeded  bool      `json:"exceeded"`
+
+	// Unavailable is set when Redis is unavailable and the limiter fails
+	// closed, so the request is rejected without being counted
+	Unavailable bool `json:"unavailable,omitempty"`
 }
 
 // RateLimiter handles distributed rate limiting via Redis
@@ -163,6 +278,12 @@ func NewRateLimiter(config *RateLimitConfig, metrics *RateLimitMetrics, logger *
 	if config == nil {
 		config = DefaultRateLimitConfig()
 	}
+	if config.FailMode == "" {
+		config.FailMode = RateLimitFailOpen
+	}
+	if config.FailMode != RateLimitFailOpen && config.FailMode != RateLimitFailClosed {
+		return nil, fmt.Errorf("invalid rate limit fail mode %q", config.FailMode)
+	}
 
 	limiter := &RateLimiter{
 		config:          config,
@@ -180,7 +301,7 @@ func NewRateLimiter(config *RateLimitConfig, metrics *RateLimitMetrics, logger *
 	// Parse Redis URL
 	opts, err := redis.ParseURL(config.RedisURL)
 	if err != nil {
-		logger.Warnf("Failed to parse Redis URL, using fallback in-memory limiter: %v", err)
+		logger.Warnf("Failed to parse Redis URL, rate limiting degraded (%s): %v", limiter.degradedMode(), err)
 		return limiter, nil
 	}
 
@@ -195,23 +316,24 @@ func NewRateLimiter(config *RateLimitConfig, metrics *RateLimitMetrics, logger *
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	// Start health check goroutine, which also picks Redis up if it comes
+	// up after the gateway
+	defer func() { go limiter.healthCheck() }()
+
 	if err := limiter.redis.Ping(ctx).Err(); err != nil {
-		logger.Warnf("Redis connection failed, using fallback in-memory limiter: %v", err)
+		logger.Warnf("Redis connection failed, rate limiting degraded (%s): %v", limiter.degradedMode(), err)
 		return limiter, nil
 	}
 
 	limiter.redisAvailable = true
 	logger.Info("Rate limiter initialized with Redis backend")
 
-	// Start health check goroutine
-	go limiter.healthCheck()
-
 	return limiter, nil
 }
 
 // healthCheck periodically checks Redis availability
 func (rl *RateLimiter) healthCheck() {
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(redisHealthCheckInterval)
 	defer ticker.Stop()
 
 	for range ticker.C {
@@ -226,7 +348,7 @@ func (rl *RateLimiter) healthCheck() {
 			if rl.redisAvailable {
 				rl.logger.Info("Redis connection restored")
 			} else {
-				rl.logger.Warnf("Redis connection lost, falling back to in-memory limiter: %v", err)
+				rl.logger.Warnf("Redis connection lost, rate limiting degraded (%s): %v", rl.degradedMode(), err)
 			}
 		}
 		rl.mu.Unlock()
@@ -252,14 +374,54 @@ func (rl *RateLimiter) Allow(ctx context.Context, key string, limit int) *RateLi
 	}
 
 	if rl.isRedisAvailable() {
-		return rl.allowRedis(ctx, key, limit)
+		if info, err := rl.allowRedis(ctx, key, limit); err == nil {
+			return info
+		}
 	}
 
-	return rl.fallbackLimiter.Allow(key, limit, rl.config.WindowDuration)
+	return rl.allowDegraded(key, limit)
+}
+
+// degradedMode returns how requests are limited while Redis is unavailable:
+// by the in-memory limiter, or by failing open or closed
+func (rl *RateLimiter) degradedMode() string {
+	if rl.config.LocalFallback {
+		return degradedLocal
+	}
+	return rl.config.FailMode
 }
 
-// allowRedis uses Redis for distributed rate limiting with sliding window
-func (rl *RateLimiter) allowRedis(ctx context.Context, key string, limit int) *RateLimitInfo {
+// allowDegraded evaluates a request while Redis is unavailable
+func (rl *RateLimiter) allowDegraded(key string, limit int) *RateLimitInfo {
+	mode := rl.degradedMode()
+	if rl.metrics != nil {
+		rl.metrics.RecordDegraded(mode)
+	}
+
+	now := time.Now()
+	switch mode {
+	case degradedLocal:
+		return rl.fallbackLimiter.Allow(key, limit, rl.config.WindowDuration)
+	case RateLimitFailClosed:
+		return &RateLimitInfo{
+			Limit:       limit,
+			Remaining:   0,
+			ResetAt:     now.Add(redisHealthCheckInterval),
+			Unavailable: true,
+		}
+	default:
+		return &RateLimitInfo{
+			Limit:     limit,
+			Remaining: limit,
+			ResetAt:   now.Add(rl.config.WindowDuration),
+			Exceeded:  false,
+		}
+	}
+}
+
+// allowRedis uses Redis for distributed rate limiting with sliding window.
+// On a Redis error it marks Redis unavailable until the next health check.
+func (rl *RateLimiter) allowRedis(ctx context.Context, key string, limit int) (*RateLimitInfo, error) {
 	now := time.Now()
 	windowStart := now.Add(-rl.config.WindowDuration)
 	resetAt := now.Add(rl.config.WindowDuration)
@@ -296,11 +458,11 @@ func (rl *RateLimiter) allowRedis(ctx context.Context, key string, limit int) *R
 
 	result, err := script.Run(ctx, rl.redis, []string{redisKey}, nowMs, windowMs, limit).Slice()
 	if err != nil {
-		rl.logger.Warnf("Redis rate limit error, falling back to in-memory: %v", err)
+		rl.logger.Warnf("Redis rate limit error, rate limiting degraded (%s): %v", rl.degradedMode(), err)
 		rl.mu.Lock()
 		rl.redisAvailable = false
 		rl.mu.Unlock()
-		return rl.fallbackLimiter.Allow(key, limit, rl.config.WindowDuration)
+		return nil, err
 	}
 
 	count := int(result[0].(int64))
@@ -322,7 +484,24 @@ func (rl *RateLimiter) allowRedis(ctx context.Context, key string, limit int) *R
 		Remaining: remaining,
 		ResetAt:   resetAt,
 		Exceeded:  exceeded,
+	}, nil
+}
+
+// routeLimit returns the override for the request's matched route template
+func (rl *RateLimiter) routeLimit(r *http.Request) (string, int, bool) {
+	if len(rl.config.RouteOverrides) == 0 {
+		return "", 0, false
 	}
+	current := mux.CurrentRoute(r)
+	if current == nil {
+		return "", 0, false
+	}
+	route, err := current.GetPathTemplate()
+	if err != nil {
+		return "", 0, false
+	}
+	limit, ok := rl.config.RouteOverrides[route]
+	return route, limit, ok
 }
 
 // shouldBypass checks if the request path should bypass rate limiting
@@ -335,6 +514,52 @@ func (rl *RateLimiter) shouldBypass(path string) bool {
 	return false
 }
 
+// isInternalCall reports whether the request carries the internal token and
+// comes from an internal network. Forwarded-for addresses count too, so a
+// public request relayed by an internal proxy isn't exempt.
+func (rl *RateLimiter) isInternalCall(r *http.Request) bool {
+	token := r.Header.Get(InternalTokenHeader)
+	if token == "" || rl.config.InternalToken == "" {
+		return false
+	}
+
+	sources := []string{r.RemoteAddr}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		sources[0] = host
+	}
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		sources = append(sources, strings.Split(xff, ",")...)
+	}
+	for _, source := range sources {
+		if !rl.isInternalIP(strings.TrimSpace(source)) {
+			rl.logger.WithFields(map[string]interface{}{
+				"path":      r.URL.Path,
+				"source_ip": source,
+			}).Warn("Internal token sent from outside the internal networks")
+			return false
+		}
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(rl.config.InternalToken)) != 1 {
+		rl.logger.WithField("path", r.URL.Path).Warn("Invalid internal token")
+		return false
+	}
+	return true
+}
+
+func (rl *RateLimiter) isInternalIP(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range rl.config.InternalNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 // getClientIP extracts the real client IP from the request
 func getClientIP(r *http.Request) string {
 	// Check X-Forwarded-For header (from load balancers/proxies)
@@ -376,6 +601,22 @@ func RateLimitMiddleware(limiter *RateLimiter, logger *logging.Logger) func(http
 				return
 			}
 
+			// Service-to-service calls skip the limiter. The token is
+			// removed either way so it never reaches an upstream.
+			internal := limiter.isInternalCall(r)
+			r.Header.Del(InternalTokenHeader)
+			if internal {
+				if limiter.metrics != nil {
+					limiter.metrics.RecordHit("internal")
+				}
+				logger.WithContext(r.Context()).WithFields(map[string]interface{}{
+					"path":      r.URL.Path,
+					"client_ip": getClientIP(r),
+				}).Info("Rate limit bypassed for internal call")
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			ctx := r.Context()
 
 			// Determine rate limit tier and key
@@ -387,13 +628,26 @@ func RateLimitMiddleware(limiter *RateLimiter, logger *logging.Logger) func(http
 			userID := GetUserIDFromContext(ctx)
 			dealershipID := GetDealershipIDFromContext(ctx)
 
-			if dealershipID != "" {
+			if route, routeLimit, ok := limiter.routeLimit(r); ok {
+				// Route overrides take precedence over every global limit
+				caller := fmt.Sprintf("ip:%s", getClientIP(r))
+				if dealershipID != "" && userID != "" {
+					caller = fmt.Sprintf("user:%s:%s", dealershipID, userID)
+				}
+				limitKey = fmt.Sprintf("route:%s:%s", route, caller)
+				limit = routeLimit
+				limitType = "route"
+			} else if dealershipID != "" {
 				// Check dealership-level rate limit first (highest priority)
 				limitKey = fmt.Sprintf("dealership:%s", dealershipID)
 				limit = limiter.config.DealershipRateLimit
 				limitType = "dealership"
 
 				dealershipInfo := limiter.Allow(ctx, limitKey, limit)
+				if dealershipInfo.Unavailable {
+					limiter.handleRateLimitUnavailable(w, dealershipInfo)
+					return
+				}
 				if dealershipInfo.Exceeded {
 					limiter.handleRateLimitExceeded(w, dealershipInfo, limitType)
 					return
@@ -430,6 +684,11 @@ func RateLimitMiddleware(limiter *RateLimiter, logger *logging.Logger) func(http
 				}
 			}
 
+			if info.Unavailable {
+				limiter.handleRateLimitUnavailable(w, info)
+				return
+			}
+
 			// Set rate limit headers
 			setRateLimitHeaders(w, info)
 
@@ -469,6 +728,25 @@ func (rl *RateLimiter) handleRateLimitExceeded(w http.ResponseWriter, info *Rate
 	}).Warn("Rate limit exceeded")
 }
 
+// handleRateLimitUnavailable sends HTTP 503 when the limiter fails closed
+// because Redis is unavailable
+func (rl *RateLimiter) handleRateLimitUnavailable(w http.ResponseWriter, info *RateLimitInfo) {
+	retryAfter := int(time.Until(info.ResetAt).Seconds())
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.WriteHeader(http.StatusServiceUnavailable)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":       "Rate limiting unavailable",
+		"message":     fmt.Sprintf("Requests cannot be accepted right now. Please retry after %d seconds.", retryAfter),
+		"retry_after": retryAfter,
+	})
+}
+
 // setRateLimitHeaders sets standard rate limit response headers
 func setRateLimitHeaders(w http.ResponseWriter, info *RateLimitInfo) {
 	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(info.Limit))
@@ -10,6 +10,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/gorilla/mux"
+
 	"autolytiq/shared/logging"
 )
 
@@ -737,3 +739,258 @@ func TestRateLimitResponse(t *testing.T) {
 		t.Errorf("limit_type = %v, expected 'ip'", response["limit_type"])
 	}
 }
+
+// newUnavailableRedisLimiter creates a limiter whose Redis refuses connections
+func newUnavailableRedisLimiter(t *testing.T, failMode string, localFallback bool, metrics *RateLimitMetrics) *RateLimiter {
+	t.Helper()
+
+	config := DefaultRateLimitConfig()
+	config.RedisURL = "redis://127.0.0.1:1"
+	config.IPRateLimit = 2
+	config.FailMode = failMode
+	config.LocalFallback = localFallback
+
+	limiter, err := NewRateLimiter(config, metrics, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create rate limiter: %v", err)
+	}
+	if limiter.isRedisAvailable() {
+		t.Fatal("expected Redis to be unavailable")
+	}
+	return limiter
+}
+
+// degradedCount scrapes the degraded evaluations counter for a mode
+func degradedCount(t *testing.T, metrics *RateLimitMetrics, mode string) string {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	prefix := `autolytiq_rate_limit_degraded_total{mode="` + mode + `",service="api-gateway"} `
+	for _, line := range strings.Split(rr.Body.String(), "\n") {
+		if strings.HasPrefix(line, prefix) {
+			return strings.TrimPrefix(line, prefix)
+		}
+	}
+	return "0"
+}
+
+func TestRateLimiter_RedisUnavailable(t *testing.T) {
+	testCases := []struct {
+		name          string
+		failMode      string
+		localFallback bool
+		expected      []int
+	}{
+		{"fail open", RateLimitFailOpen, false, []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusOK}},
+		{"fail closed", RateLimitFailClosed, false, []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable}},
+		{"local fallback", RateLimitFailClosed, true, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			metrics := NewRateLimitMetrics()
+			limiter := newUnavailableRedisLimiter(t, tc.failMode, tc.localFallback, metrics)
+			handler := RateLimitMiddleware(limiter, testLogger())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			for i, expected := range tc.expected {
+				req := httptest.NewRequest("GET", "/api/v1/test", nil)
+				req.RemoteAddr = "198.51.100.10:12345"
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+
+				if rr.Code != expected {
+					t.Errorf("request %d: expected status %d, got %d", i+1, expected, rr.Code)
+				}
+				if rr.Code == http.StatusServiceUnavailable && rr.Header().Get("Retry-After") == "" {
+					t.Errorf("request %d: missing Retry-After header on 503 response", i+1)
+				}
+			}
+
+			if got := degradedCount(t, metrics, limiter.degradedMode()); got != "4" {
+				t.Errorf("expected 4 degraded evaluations, got %s", got)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_RedisErrorDegrades(t *testing.T) {
+	for _, failMode := range []string{RateLimitFailOpen, RateLimitFailClosed} {
+		t.Run(failMode, func(t *testing.T) {
+			metrics := NewRateLimitMetrics()
+			limiter := newUnavailableRedisLimiter(t, failMode, false, metrics)
+
+			// Redis was healthy at the last check but fails this call
+			limiter.redisAvailable = true
+			info := limiter.Allow(context.Background(), "ip:198.51.100.20", 10)
+
+			if info.Unavailable != (failMode == RateLimitFailClosed) || info.Exceeded {
+				t.Errorf("unexpected result for fail mode %s: %+v", failMode, info)
+			}
+			if limiter.isRedisAvailable() {
+				t.Error("expected the Redis error to mark Redis unavailable")
+			}
+			if got := degradedCount(t, metrics, failMode); got != "1" {
+				t.Errorf("expected 1 degraded evaluation, got %s", got)
+			}
+		})
+	}
+}
+
+func TestNewRateLimiter_InvalidFailMode(t *testing.T) {
+	config := DefaultRateLimitConfig()
+	config.FailMode = "sometimes"
+
+	if _, err := NewRateLimiter(config, nil, testLogger()); err == nil {
+		t.Error("expected an invalid fail mode to be rejected")
+	}
+}
+
+// TestRateLimitMiddleware_InternalTokenBypass tests that service-to-service
+// calls skip the limiter only with the right token from an internal network
+func TestRateLimitMiddleware_InternalTokenBypass(t *testing.T) {
+	const token = "internal-token-0123456789abcdef0123"
+
+	config := DefaultRateLimitConfig()
+	config.IPRateLimit = 1
+	config.WindowDuration = time.Minute
+	config.InternalToken = token
+
+	logger := testLogger()
+	limiter, err := NewRateLimiter(config, NewRateLimitMetrics(), logger)
+	if err != nil {
+		t.Fatalf("failed to create rate limiter: %v", err)
+	}
+
+	var forwardedToken string
+	handler := RateLimitMiddleware(limiter, logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedToken = r.Header.Get(InternalTokenHeader)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(remoteAddr, token, xff string) int {
+		req := httptest.NewRequest("GET", "/api/v1/test", nil)
+		req.RemoteAddr = remoteAddr
+		if token != "" {
+			req.Header.Set(InternalTokenHeader, token)
+		}
+		if xff != "" {
+			req.Header.Set("X-Forwarded-For", xff)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := send("10.1.2.3:5000", token, ""); code != http.StatusOK {
+			t.Fatalf("internal request %d: expected 200, got %d", i+1, code)
+		}
+	}
+	if forwardedToken != "" {
+		t.Error("expected the internal token to be removed before proxying")
+	}
+
+	tests := []struct {
+		name, remoteAddr, token, xff string
+	}{
+		{"public source", "203.0.113.7:5000", token, ""},
+		{"wrong token", "10.1.2.4:5000", "not-the-internal-token-0123456789", ""},
+		{"relayed public client", "10.1.2.5:5000", token, "198.51.100.9"},
+	}
+	for _, tt := range tests {
+		send(tt.remoteAddr, tt.token, tt.xff)
+		if code := send(tt.remoteAddr, tt.token, tt.xff); code != http.StatusTooManyRequests {
+			t.Errorf("%s: expected the limiter to apply, got %d", tt.name, code)
+		}
+	}
+}
+
+func TestParseCIDRs(t *testing.T) {
+	networks, err := ParseCIDRs([]string{" 10.0.0.0/8", "", "fd00::/8 "})
+	if err != nil || len(networks) != 2 {
+		t.Fatalf("expected 2 networks, got %v, %v", networks, err)
+	}
+	if _, err := ParseCIDRs([]string{"10.0.0.1"}); err == nil {
+		t.Error("expected an error for an address without a prefix length")
+	}
+}
+
+// TestRateLimitMiddleware_RouteOverrides tests that a route with an override
+// is limited on its own while other routes keep the default limit
+func TestRateLimitMiddleware_RouteOverrides(t *testing.T) {
+	config := DefaultRateLimitConfig()
+	config.IPRateLimit = 4
+	config.WindowDuration = time.Minute
+	config.RouteOverrides = map[string]int{"/api/v1/gdpr/export/{customer_id}": 2}
+
+	logger := testLogger()
+	limiter, err := NewRateLimiter(config, NewRateLimitMetrics(), logger)
+	if err != nil {
+		t.Fatalf("failed to create rate limiter: %v", err)
+	}
+
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api/v1").Subrouter()
+	api.Use(RateLimitMiddleware(limiter, logger))
+	api.HandleFunc("/gdpr/export/{customer_id}", ok).Methods("POST")
+	api.HandleFunc("/deals", ok).Methods("GET")
+
+	send := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "172.16.9.1:12345"
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Different customers share the route's limit
+	for i, customerID := range []string{"c1", "c2"} {
+		rr := send("POST", "/api/v1/gdpr/export/"+customerID)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("export %d: expected 200, got %d", i+1, rr.Code)
+		}
+		if rr.Header().Get("X-RateLimit-Limit") != "2" {
+			t.Errorf("export %d: expected the route's limit, got %q", i+1, rr.Header().Get("X-RateLimit-Limit"))
+		}
+	}
+	if rr := send("POST", "/api/v1/gdpr/export/c3"); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the export route to hit its override, got %d", rr.Code)
+	}
+
+	for i := 0; i < config.IPRateLimit; i++ {
+		rr := send("GET", "/api/v1/deals")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("deals request %d: expected 200 on the default limit, got %d", i+1, rr.Code)
+		}
+		if rr.Header().Get("X-RateLimit-Limit") != "4" {
+			t.Errorf("deals request %d: expected the default limit, got %q", i+1, rr.Header().Get("X-RateLimit-Limit"))
+		}
+	}
+	if rr := send("GET", "/api/v1/deals"); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the default limit to apply to other routes, got %d", rr.Code)
+	}
+}
+
+func TestParseRouteOverrides(t *testing.T) {
+	overrides, err := ParseRouteOverrides([]string{
+		"PATH=/usr/bin",
+		"RATE_LIMIT_ROUTE_GDPR_EXPORT=/api/v1/gdpr/export/{customer_id}=10",
+		"RATE_LIMIT_ROUTE_VEHICLES= /api/v1/inventory/vehicles = 5000",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(overrides) != 2 || overrides["/api/v1/gdpr/export/{customer_id}"] != 10 || overrides["/api/v1/inventory/vehicles"] != 5000 {
+		t.Errorf("unexpected overrides: %v", overrides)
+	}
+
+	for _, value := range []string{"/api/v1/deals", "/api/v1/deals=0", "/api/v1/deals=many", "deals=10"} {
+		if _, err := ParseRouteOverrides([]string{"RATE_LIMIT_ROUTE_DEALS=" + value}); err == nil {
+			t.Errorf("expected an error for %q", value)
+		}
+	}
+}
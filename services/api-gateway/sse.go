@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/tracing"
+)
+
+// SSEConfig holds timeouts for Server-Sent Events streams. Streams bypass the
+// normal proxy client timeout; they're bounded by MaxDuration instead, and
+// clients are expected to reconnect when a stream ends.
+type SSEConfig struct {
+	// ConnectTimeout bounds how long the upstream has to start the stream
+	ConnectTimeout time.Duration
+	// HeartbeatInterval is how often a comment is sent while the upstream is
+	// quiet, so intermediate proxies don't drop an idle connection
+	HeartbeatInterval time.Duration
+	// MaxDuration closes streams open longer than this
+	MaxDuration time.Duration
+}
+
+// DefaultSSEConfig returns the default SSE timeouts
+func DefaultSSEConfig() *SSEConfig {
+	return &SSEConfig{
+		ConnectTimeout:    10 * time.Second,
+		HeartbeatInterval: 15 * time.Second,
+		MaxDuration:       time.Hour,
+	}
+}
+
+// newSSEClient returns an HTTP client for event streams. It has no overall
+// timeout since streams are long-lived.
+func newSSEClient(config *SSEConfig) *http.Client {
+	return &http.Client{
+		Transport: tracing.Transport(&http.Transport{
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   10,
+			IdleConnTimeout:       90 * time.Second,
+			ResponseHeaderTimeout: config.ConnectTimeout,
+		}),
+	}
+}
+
+// isEventStreamRequest reports whether the client asked for an event stream
+func isEventStreamRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// streamEvents proxies an event stream, flushing each chunk to the client as
+// it arrives from the upstream. Auth and rate limiting have already run for
+// the connect; nothing is re-checked while the stream is open. Upstream
+// responses that aren't event streams (errors, typically) are copied as-is.
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request, proxyReq *http.Request, targetURL string) {
+	ctxLogger := s.logger.WithContext(r.Context())
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		ctxLogger.Error("Response writer does not support streaming")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Streaming not supported")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(proxyReq.Context(), s.config.SSEConfig.MaxDuration)
+	defer cancel()
+
+	resp, err := s.sseClient.Do(proxyReq.WithContext(ctx))
+	if err != nil {
+		ctxLogger.WithError(err).WithField("target_url", targetURL).Error("Event stream request failed")
+		apierror.WriteError(w, http.StatusServiceUnavailable, apierror.CodeServiceUnavailable, fmt.Sprintf("Service unavailable: %v", err))
+		return
+	}
+	defer resp.Body.Close()
+
+	for name, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		w.WriteHeader(resp.StatusCode)
+		if _, err := io.Copy(w, resp.Body); err != nil {
+			ctxLogger.WithError(err).Error("Failed to write response")
+		}
+		return
+	}
+
+	w.Header().Del("Content-Length")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx response buffering
+	w.WriteHeader(resp.StatusCode)
+	flusher.Flush()
+
+	ctxLogger.WithField("target_url", targetURL).Debug("Event stream opened")
+
+	chunks := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := resp.Body.Read(buf)
+			if n > 0 {
+				chunk := make([]byte, n)
+				copy(chunk, buf[:n])
+				select {
+				case chunks <- chunk:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(s.config.SSEConfig.HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case chunk := <-chunks:
+			if _, err := w.Write(chunk); err != nil {
+				ctxLogger.WithError(err).Debug("Client write error")
+				return
+			}
+			flusher.Flush()
+			heartbeat.Reset(s.config.SSEConfig.HeartbeatInterval)
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				ctxLogger.WithError(err).Debug("Client write error")
+				return
+			}
+			flusher.Flush()
+		case err := <-readErr:
+			if err != io.EOF && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+				ctxLogger.WithError(err).Debug("Upstream read error")
+			}
+			ctxLogger.Debug("Event stream closed")
+			return
+		case <-ctx.Done():
+			ctxLogger.Debug("Event stream closed")
+			return
+		}
+	}
+}
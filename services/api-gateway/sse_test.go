@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSSETestGateway starts the gateway's full router in front of upstream,
+// proxied as the deal service
+func newSSETestGateway(t *testing.T, upstream *httptest.Server, sseConfig *SSEConfig) (*httptest.Server, string) {
+	t.Helper()
+
+	config := &Config{
+		Port:           "8080",
+		DealServiceURL: upstream.URL,
+		AllowedOrigins: "*",
+		JWTSecret:      "development-secret-change-in-production-testing",
+		JWTIssuer:      "test-issuer",
+		SSEConfig:      sseConfig,
+	}
+	server := NewServer(config, proxyTestLogger())
+	gateway := httptest.NewServer(server.router)
+	t.Cleanup(gateway.Close)
+
+	token, err := GenerateToken(server.jwtConfig, "user-456", "test-dealership-123", "test@example.com", "admin")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	return gateway, token
+}
+
+func openEventStream(t *testing.T, url, token string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open event stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// readLine reads one line from the stream, failing if it doesn't arrive in time
+func readLine(t *testing.T, reader *bufio.Reader) string {
+	t.Helper()
+
+	line := make(chan string, 1)
+	go func() {
+		s, _ := reader.ReadString('\n')
+		line <- s
+	}()
+
+	select {
+	case s := <-line:
+		return strings.TrimSuffix(s, "\n")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for event")
+		return ""
+	}
+}
+
+func TestSSEProxy_StreamsEventsAsTheyArrive(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Dealership-ID") != "test-dealership-123" {
+			t.Errorf("Expected dealership context on the stream, got %q", r.Header.Get("X-Dealership-ID"))
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, "event: notification\ndata: first\n\n")
+		w.(http.Flusher).Flush()
+
+		<-release
+		io.WriteString(w, "data: second\n\n")
+	}))
+	defer upstream.Close()
+
+	gateway, token := newSSETestGateway(t, upstream, nil)
+	resp := openEventStream(t, gateway.URL+"/api/v1/deals", token)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected text/event-stream, got %q", ct)
+	}
+	if resp.Header.Get("X-Accel-Buffering") != "no" {
+		t.Error("Expected response buffering to be disabled")
+	}
+	if resp.Header.Get("X-RateLimit-Limit") == "" {
+		t.Error("Expected rate limiting to apply when the stream connects")
+	}
+
+	// The first event arrives while the upstream is still holding the stream open
+	reader := bufio.NewReader(resp.Body)
+	if line := readLine(t, reader); line != "event: notification" {
+		t.Errorf("Expected the event name, got %q", line)
+	}
+	if line := readLine(t, reader); line != "data: first" {
+		t.Errorf("Expected the first event, got %q", line)
+	}
+	readLine(t, reader)
+
+	close(release)
+	if line := readLine(t, reader); line != "data: second" {
+		t.Errorf("Expected the second event, got %q", line)
+	}
+}
+
+func TestSSEProxy_RequiresAuthAtConnect(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected unauthenticated streams not to reach the upstream")
+	}))
+	defer upstream.Close()
+
+	gateway, _ := newSSETestGateway(t, upstream, nil)
+	resp := openEventStream(t, gateway.URL+"/api/v1/deals", "")
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401, got %d", resp.StatusCode)
+	}
+}
+
+func TestSSEProxy_HeartbeatAndMaxDuration(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer upstream.Close()
+
+	gateway, token := newSSETestGateway(t, upstream, &SSEConfig{
+		ConnectTimeout:    time.Second,
+		HeartbeatInterval: 20 * time.Millisecond,
+		MaxDuration:       200 * time.Millisecond,
+	})
+
+	start := time.Now()
+	resp := openEventStream(t, gateway.URL+"/api/v1/deals", token)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read stream: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the stream to close after its max duration, took %v", elapsed)
+	}
+	if !strings.Contains(string(body), ": heartbeat\n\n") {
+		t.Errorf("Expected heartbeats while the upstream was quiet, got %q", body)
+	}
+}
+
+func TestSSEProxy_UpstreamErrorPassedThrough(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"error":"not found"}`)
+	}))
+	defer upstream.Close()
+
+	gateway, token := newSSETestGateway(t, upstream, nil)
+	resp := openEventStream(t, gateway.URL+"/api/v1/deals", token)
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusNotFound || string(body) != `{"error":"not found"}` {
+		t.Errorf("Expected the upstream error, got %d %q", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Headers marking a response served from the stale cache
+const (
+	ServedStaleHeader = "X-Served-Stale"
+	staleWarning      = `110 - "Response is Stale"`
+)
+
+// StaleRoute allows a GET route to be served from the last good response
+// while its upstream is unavailable
+type StaleRoute struct {
+	// Route is the path template as registered, e.g. "/api/v1/deals"
+	Route string
+
+	// MaxAge is the oldest response served for the route. Zero uses the
+	// config's MaxAge.
+	MaxAge time.Duration
+}
+
+// StaleCacheConfig holds gateway stale serving configuration
+type StaleCacheConfig struct {
+	// Routes lists the routes that may be served stale
+	Routes []StaleRoute
+
+	// MaxAge is the oldest response served for routes without their own
+	MaxAge time.Duration
+
+	// MaxEntries bounds the number of cached responses
+	MaxEntries int
+}
+
+// DefaultStaleCacheConfig returns stale serving with no routes enabled
+func DefaultStaleCacheConfig() *StaleCacheConfig {
+	return &StaleCacheConfig{
+		MaxAge:     5 * time.Minute,
+		MaxEntries: 10000,
+	}
+}
+
+// ParseStaleRoutes parses a comma-separated list of "/route[=seconds]"
+// entries. Entries without a max age use the config's default.
+func ParseStaleRoutes(value string) ([]StaleRoute, error) {
+	var routes []StaleRoute
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		route := StaleRoute{Route: entry}
+		if eq := strings.LastIndex(entry, "="); eq >= 0 {
+			seconds, err := strconv.Atoi(strings.TrimSpace(entry[eq+1:]))
+			if err != nil || seconds < 1 {
+				return nil, fmt.Errorf("stale route %q must have a positive max age in seconds", entry)
+			}
+			route.Route = strings.TrimSpace(entry[:eq])
+			route.MaxAge = time.Duration(seconds) * time.Second
+		}
+		if !strings.HasPrefix(route.Route, "/") {
+			return nil, fmt.Errorf("stale route %q must be a path", entry)
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// match returns the max age for a request's route, if it may be served stale
+func (c *StaleCacheConfig) match(r *http.Request) (time.Duration, bool) {
+	if c == nil || len(c.Routes) == 0 || r.Method != http.MethodGet {
+		return 0, false
+	}
+
+	route := ""
+	if current := mux.CurrentRoute(r); current != nil {
+		route, _ = current.GetPathTemplate()
+	}
+	for _, rule := range c.Routes {
+		if rule.Route == route {
+			if rule.MaxAge > 0 {
+				return rule.MaxAge, true
+			}
+			return c.MaxAge, true
+		}
+	}
+	return 0, false
+}
+
+// cachedResponse is the last good response for a request
+type cachedResponse struct {
+	status   int
+	header   http.Header
+	body     []byte
+	storedAt time.Time
+}
+
+// StaleCache keeps the last good response for each caller and request URL
+type StaleCache struct {
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*cachedResponse
+}
+
+// NewStaleCache creates an empty stale cache holding up to maxEntries
+func NewStaleCache(maxEntries int) *StaleCache {
+	return &StaleCache{
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    make(map[string]*cachedResponse),
+	}
+}
+
+// staleCacheKey identifies a response by everything that changes what the
+// caller is allowed to see: dealership, user, scopes and request URL
+func staleCacheKey(r *http.Request) string {
+	ctx := r.Context()
+	return strings.Join([]string{
+		GetDealershipIDFromContext(ctx),
+		GetUserIDFromContext(ctx),
+		strings.Join(GetScopesFromContext(ctx), ","),
+		r.URL.RequestURI(),
+	}, "|")
+}
+
+// Put stores a response, evicting the oldest entry when the cache is full
+func (c *StaleCache) Put(key string, status int, header http.Header, body []byte) {
+	stored := &cachedResponse{status: status, header: header.Clone(), body: body, storedAt: c.now()}
+	stored.header.Del("Set-Cookie")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.entries[key]; !exists && c.maxEntries > 0 && len(c.entries) >= c.maxEntries {
+		oldestKey := ""
+		var oldest time.Time
+		for k, entry := range c.entries {
+			if oldestKey == "" || entry.storedAt.Before(oldest) {
+				oldestKey, oldest = k, entry.storedAt
+			}
+		}
+		delete(c.entries, oldestKey)
+	}
+	c.entries[key] = stored
+}
+
+// Get returns the stored response for key if it is no older than maxAge
+func (c *StaleCache) Get(key string, maxAge time.Duration) (*cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if c.now().Sub(entry.storedAt) > maxAge {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry, true
+}
+
+// serveStale writes the cached response for the request, if there is one
+// young enough, marked as stale. It reports whether a response was written.
+func (s *Server) serveStale(w http.ResponseWriter, r *http.Request, maxAge time.Duration) bool {
+	entry, ok := s.staleCache.Get(staleCacheKey(r), maxAge)
+	if !ok {
+		return false
+	}
+
+	for name, values := range entry.header {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	age := s.staleCache.now().Sub(entry.storedAt)
+	w.Header().Set("Age", strconv.Itoa(int(age/time.Second)))
+	w.Header().Set(ServedStaleHeader, "true")
+	w.Header().Add("Warning", staleWarning)
+
+	w.WriteHeader(entry.status)
+	if _, err := w.Write(entry.body); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to write response")
+	}
+
+	s.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
+		"path":        r.URL.Path,
+		"age_seconds": int(age / time.Second),
+	}).Warn("Upstream unavailable, served stale response")
+	return true
+}
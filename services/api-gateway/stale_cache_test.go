@@ -0,0 +1,170 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyBackend serves deals until down is set, then fails with 503
+func flakyBackend(down *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(down) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"deals":[{"id":"deal-1"}]}`))
+	}))
+}
+
+func setupStaleServer(t *testing.T, backendURL string, routes []StaleRoute) *Server {
+	t.Helper()
+
+	staleConfig := DefaultStaleCacheConfig()
+	staleConfig.Routes = routes
+	config := &Config{
+		Port:           "8080",
+		DealServiceURL: backendURL,
+		AllowedOrigins: "*",
+		JWTSecret:      "development-secret-change-in-production-testing",
+		JWTIssuer:      "test-issuer",
+		CircuitBreakerConfig: &CircuitBreakerConfig{
+			FailureThreshold: 2,
+			OpenDuration:     time.Minute,
+		},
+		StaleCacheConfig: staleConfig,
+	}
+	return NewServer(config, proxyTestLogger())
+}
+
+func staleRequest(t *testing.T, server *Server, path string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	token, err := GenerateToken(server.jwtConfig, "user-123", "dealer-1", "user@example.com", "MANAGER")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestStaleCache_OpenBreakerServesStale(t *testing.T) {
+	var down int32
+	backend := flakyBackend(&down)
+	defer backend.Close()
+
+	server := setupStaleServer(t, backend.URL, []StaleRoute{{Route: "/api/v1/deals"}})
+
+	rr := staleRequest(t, server, "/api/v1/deals?status=open")
+	if rr.Code != http.StatusOK || rr.Header().Get(ServedStaleHeader) != "" {
+		t.Fatalf("Expected a fresh 200, got %d (stale %q)", rr.Code, rr.Header().Get(ServedStaleHeader))
+	}
+
+	// Trip the breaker; failing responses are replaced by the cached one
+	atomic.StoreInt32(&down, 1)
+	for i := 0; i < 2; i++ {
+		if rr := staleRequest(t, server, "/api/v1/deals?status=open"); rr.Code != http.StatusOK {
+			t.Fatalf("Expected the cached response for a failing upstream, got %d", rr.Code)
+		}
+	}
+	if server.breakerFor(backend.URL).Allow() {
+		t.Fatal("Expected the breaker to be open")
+	}
+
+	rr = staleRequest(t, server, "/api/v1/deals?status=open")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected the cached response while the breaker is open, got %d", rr.Code)
+	}
+	if rr.Body.String() != `{"deals":[{"id":"deal-1"}]}` {
+		t.Errorf("Unexpected stale body: %s", rr.Body.String())
+	}
+	if rr.Header().Get(ServedStaleHeader) != "true" {
+		t.Errorf("Expected %s: true, got %q", ServedStaleHeader, rr.Header().Get(ServedStaleHeader))
+	}
+	if rr.Header().Get("Warning") != staleWarning {
+		t.Errorf("Expected a stale Warning header, got %q", rr.Header().Get("Warning"))
+	}
+	if rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected the cached headers, got Content-Type %q", rr.Header().Get("Content-Type"))
+	}
+
+	// Nothing was cached for another query
+	if rr := staleRequest(t, server, "/api/v1/deals?status=closed"); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without a cached response, got %d", rr.Code)
+	}
+}
+
+func TestStaleCache_UnconfiguredRouteFails(t *testing.T) {
+	var down int32
+	backend := flakyBackend(&down)
+	defer backend.Close()
+
+	server := setupStaleServer(t, backend.URL, []StaleRoute{{Route: "/api/v1/deals/{id}"}})
+
+	if rr := staleRequest(t, server, "/api/v1/deals"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected a fresh 200, got %d", rr.Code)
+	}
+
+	atomic.StoreInt32(&down, 1)
+	for i := 0; i < 2; i++ {
+		staleRequest(t, server, "/api/v1/deals")
+	}
+
+	rr := staleRequest(t, server, "/api/v1/deals")
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for a route without stale serving, got %d", rr.Code)
+	}
+	if rr.Header().Get(ServedStaleHeader) != "" {
+		t.Error("Expected no stale header")
+	}
+}
+
+func TestStaleCache_MaxAge(t *testing.T) {
+	var down int32
+	backend := flakyBackend(&down)
+	defer backend.Close()
+
+	server := setupStaleServer(t, backend.URL, []StaleRoute{{Route: "/api/v1/deals", MaxAge: time.Minute}})
+	now := time.Now()
+	server.staleCache.now = func() time.Time { return now }
+
+	if rr := staleRequest(t, server, "/api/v1/deals"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected a fresh 200, got %d", rr.Code)
+	}
+
+	atomic.StoreInt32(&down, 1)
+	now = now.Add(30 * time.Second)
+	rr := staleRequest(t, server, "/api/v1/deals")
+	if rr.Code != http.StatusOK || rr.Header().Get("Age") != "30" {
+		t.Errorf("Expected a 30s old stale response, got %d (Age %q)", rr.Code, rr.Header().Get("Age"))
+	}
+
+	now = now.Add(time.Minute)
+	if rr := staleRequest(t, server, "/api/v1/deals"); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 once the cached response is past its max age, got %d", rr.Code)
+	}
+}
+
+func TestParseStaleRoutes(t *testing.T) {
+	routes, err := ParseStaleRoutes(" /api/v1/deals , /api/v1/inventory/stats=600 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 2 || routes[0] != (StaleRoute{Route: "/api/v1/deals"}) ||
+		routes[1] != (StaleRoute{Route: "/api/v1/inventory/stats", MaxAge: 10 * time.Minute}) {
+		t.Errorf("Unexpected routes: %+v", routes)
+	}
+
+	for _, invalid := range []string{"deals", "/api/v1/deals=soon", "/api/v1/deals=0"} {
+		if _, err := ParseStaleRoutes(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
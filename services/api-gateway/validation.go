@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
+
+	"autolytiq/shared/apierror"
 )
 
 // ValidationError represents a single validation error
@@ -21,13 +23,6 @@ type ValidationErrors struct {
 	Errors []ValidationError `json:"errors"`
 }
 
-// ValidationErrorResponse is the standard error response format
-type ValidationErrorResponse struct {
-	Error   string            `json:"error"`
-	Code    string            `json:"code"`
-	Details []ValidationError `json:"details,omitempty"`
-}
-
 // Constants for validation
 const (
 	MaxBodySize        = 10 * 1024 * 1024 // 10MB max request body
@@ -316,23 +311,12 @@ func validateHeaders(r *http.Request) []ValidationError {
 
 // respondGatewayError writes an error response
 func respondGatewayError(w http.ResponseWriter, status int, message, code string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(ValidationErrorResponse{
-		Error: message,
-		Code:  code,
-	})
+	apierror.WriteError(w, status, code, message)
 }
 
 // respondGatewayValidationError writes a validation error response
 func respondGatewayValidationError(w http.ResponseWriter, errors []ValidationError) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusBadRequest)
-	json.NewEncoder(w).Encode(ValidationErrorResponse{
-		Error:   "Validation failed",
-		Code:    "VALIDATION_ERROR",
-		Details: errors,
-	})
+	apierror.WriteErrorDetails(w, http.StatusBadRequest, apierror.CodeValidation, "Validation failed", errors)
 }
 
 // Helper functions
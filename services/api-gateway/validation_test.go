@@ -469,6 +469,16 @@ func TestSanitizeRequestBody(t *testing.T) {
 	}
 }
 
+// errorResponse is the error body written by respondGatewayError
+type errorResponse struct {
+	Error struct {
+		Code      string            `json:"code"`
+		Message   string            `json:"message"`
+		RequestID string            `json:"request_id"`
+		Details   []ValidationError `json:"details"`
+	} `json:"error"`
+}
+
 func TestRespondGatewayError(t *testing.T) {
 	w := httptest.NewRecorder()
 
@@ -478,16 +488,16 @@ func TestRespondGatewayError(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
 	}
 
-	var resp ValidationErrorResponse
+	var resp errorResponse
 	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
 		t.Fatalf("Failed to decode response: %v", err)
 	}
 
-	if resp.Error != "Test error" {
-		t.Errorf("Expected error 'Test error', got '%s'", resp.Error)
+	if resp.Error.Message != "Test error" {
+		t.Errorf("Expected error 'Test error', got '%s'", resp.Error.Message)
 	}
-	if resp.Code != "TEST_CODE" {
-		t.Errorf("Expected code 'TEST_CODE', got '%s'", resp.Code)
+	if resp.Error.Code != "TEST_CODE" {
+		t.Errorf("Expected code 'TEST_CODE', got '%s'", resp.Error.Code)
 	}
 }
 
@@ -505,19 +515,19 @@ func TestRespondGatewayValidationError(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
 	}
 
-	var resp ValidationErrorResponse
+	var resp errorResponse
 	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
 		t.Fatalf("Failed to decode response: %v", err)
 	}
 
-	if resp.Error != "Validation failed" {
-		t.Errorf("Expected error 'Validation failed', got '%s'", resp.Error)
+	if resp.Error.Message != "Validation failed" {
+		t.Errorf("Expected error 'Validation failed', got '%s'", resp.Error.Message)
 	}
-	if resp.Code != "VALIDATION_ERROR" {
-		t.Errorf("Expected code 'VALIDATION_ERROR', got '%s'", resp.Code)
+	if resp.Error.Code != "VALIDATION_ERROR" {
+		t.Errorf("Expected code 'VALIDATION_ERROR', got '%s'", resp.Error.Code)
 	}
-	if len(resp.Details) != 2 {
-		t.Errorf("Expected 2 error details, got %d", len(resp.Details))
+	if len(resp.Error.Details) != 2 {
+		t.Errorf("Expected 2 error details, got %d", len(resp.Error.Details))
 	}
 }
 
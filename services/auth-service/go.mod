@@ -3,18 +3,23 @@ module auth-service
 go 1.18
 
 require (
+	autolytiq/shared/bodylimit v0.0.0
+	autolytiq/shared/configcheck v0.0.0
+	autolytiq/shared/graceful v0.0.0
 	autolytiq/shared/logging v0.0.0
 	autolytiq/shared/secrets v0.0.0
+	autolytiq/shared/tracing v0.0.0
 	github.com/go-redis/redis/v8 v8.11.5
 	github.com/golang-jwt/jwt/v4 v4.5.0
 	github.com/google/uuid v1.6.0
-	github.com/gorilla/mux v1.8.0
+	github.com/gorilla/mux v1.8.1
 	github.com/lib/pq v1.10.7
 	github.com/prometheus/client_golang v1.18.0
 	golang.org/x/crypto v0.0.0-20220722155217-630584e8d5aa
 )
 
 require (
+	autolytiq/shared/apierror v0.0.0 // indirect
 	github.com/aws/aws-sdk-go-v2 v1.24.0 // indirect
 	github.com/aws/aws-sdk-go-v2/config v1.26.1 // indirect
 	github.com/aws/aws-sdk-go-v2/credentials v1.16.12 // indirect
@@ -43,6 +48,16 @@ require (
 	google.golang.org/protobuf v1.31.0 // indirect
 )
 
+replace autolytiq/shared/configcheck => ../shared/configcheck
+
 replace autolytiq/shared/logging => ../shared/logging
 
 replace autolytiq/shared/secrets => ../shared/secrets
+
+replace autolytiq/shared/graceful => ../shared/graceful
+
+replace autolytiq/shared/bodylimit => ../shared/bodylimit
+
+replace autolytiq/shared/apierror => ../shared/apierror
+
+replace autolytiq/shared/tracing => ../shared/tracing
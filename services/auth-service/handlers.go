@@ -15,6 +15,7 @@ type RegisterRequest struct {
 	FirstName    string `json:"first_name"`
 	LastName     string `json:"last_name"`
 	DealershipID string `json:"dealership_id"`
+	InviteToken  string `json:"invite_token,omitempty"` // Required under the invite_only registration policy
 }
 
 // LoginRequest represents a login request
@@ -71,14 +72,25 @@ type UserResponse struct {
 
 // register handles user registration
 func (s *Server) register(w http.ResponseWriter, r *http.Request) {
+	if !s.checkIPThrottle(w, r) {
+		return
+	}
+
 	var req RegisterRequest
 	if !decodeAndValidate(r, w, &req) {
 		return
 	}
 
+	// Apply the registration policy before revealing whether the account exists
+	invite, ok := s.checkRegistration(w, r, &req)
+	if !ok {
+		return
+	}
+
 	// Check if user already exists
 	existing, _ := s.db.GetUserByEmail(req.Email)
 	if existing != nil {
+		s.recordIPFailure(r)
 		respondError(w, http.StatusConflict, "User with this email already exists")
 		return
 	}
@@ -103,27 +115,32 @@ func (s *Server) register(w http.ResponseWriter, r *http.Request) {
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
+	if invite != nil {
+		user.Role = invite.Role
+		user.DealershipID = invite.DealershipID
+	}
 
 	if err := s.db.CreateUser(user); err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to create user")
 		return
 	}
 
-	// Generate tokens
-	accessToken, err := s.jwtService.GenerateAccessToken(user)
+	// Generate tokens for a new session
+	sessionID := uuid.New().String()
+	accessToken, err := s.jwtService.GenerateAccessToken(user, sessionID)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to generate access token")
 		return
 	}
 
-	refreshToken, err := s.jwtService.GenerateRefreshToken(user)
+	refreshToken, err := s.jwtService.GenerateRefreshToken(user, sessionID)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to generate refresh token")
 		return
 	}
 
 	// Store refresh token in Redis
-	if err := s.redis.StoreRefreshToken(user.ID, refreshToken, s.config.RefreshTokenTTL); err != nil {
+	if err := s.redis.StoreRefreshToken(user.ID, sessionID, refreshToken, s.jwtService.RefreshTokenTTL(user.Role)); err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to store refresh token")
 		return
 	}
@@ -131,7 +148,7 @@ func (s *Server) register(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusCreated, AuthResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
-		ExpiresIn:    int64(s.config.AccessTokenTTL.Seconds()),
+		ExpiresIn:    int64(s.jwtService.AccessTokenTTL(user.Role).Seconds()),
 		TokenType:    "Bearer",
 		User: UserResponse{
 			ID:           user.ID,
@@ -146,6 +163,10 @@ func (s *Server) register(w http.ResponseWriter, r *http.Request) {
 
 // login handles user authentication
 func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	if !s.checkIPThrottle(w, r) {
+		return
+	}
+
 	var req LoginRequest
 	if !decodeAndValidate(r, w, &req) {
 		return
@@ -154,12 +175,14 @@ func (s *Server) login(w http.ResponseWriter, r *http.Request) {
 	// Get user by email
 	user, err := s.db.GetUserByEmail(strings.ToLower(req.Email))
 	if err != nil || user == nil {
+		s.recordIPFailure(r)
 		respondError(w, http.StatusUnauthorized, "Invalid email or password")
 		return
 	}
 
 	// Check if user is active
 	if !user.IsActive {
+		s.recordIPFailure(r)
 		respondError(w, http.StatusUnauthorized, "Account is deactivated")
 		return
 	}
@@ -168,6 +191,7 @@ func (s *Server) login(w http.ResponseWriter, r *http.Request) {
 	if !CheckPassword(req.Password, user.PasswordHash) {
 		// Update failed login attempts
 		s.db.IncrementFailedAttempts(user.ID)
+		s.recordIPFailure(r)
 		respondError(w, http.StatusUnauthorized, "Invalid email or password")
 		return
 	}
@@ -178,21 +202,28 @@ func (s *Server) login(w http.ResponseWriter, r *http.Request) {
 	// Update last login
 	s.db.UpdateLastLogin(user.ID)
 
-	// Generate tokens
-	accessToken, err := s.jwtService.GenerateAccessToken(user)
+	// Enrich claims with the user's current role and scopes
+	userClaims := s.enrichClaims(r.Context(), user, false)
+	if userClaims != nil {
+		user.Role = userClaims.Role
+	}
+
+	// Generate tokens for a new session; other devices stay signed in
+	sessionID := uuid.New().String()
+	accessToken, err := s.jwtService.GenerateEnrichedAccessToken(user, userClaims, sessionID)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to generate access token")
 		return
 	}
 
-	refreshToken, err := s.jwtService.GenerateRefreshToken(user)
+	refreshToken, err := s.jwtService.GenerateRefreshToken(user, sessionID)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to generate refresh token")
 		return
 	}
 
 	// Store refresh token in Redis
-	if err := s.redis.StoreRefreshToken(user.ID, refreshToken, s.config.RefreshTokenTTL); err != nil {
+	if err := s.redis.StoreRefreshToken(user.ID, sessionID, refreshToken, s.jwtService.RefreshTokenTTL(user.Role)); err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to store refresh token")
 		return
 	}
@@ -200,7 +231,7 @@ func (s *Server) login(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, AuthResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
-		ExpiresIn:    int64(s.config.AccessTokenTTL.Seconds()),
+		ExpiresIn:    int64(s.jwtService.AccessTokenTTL(user.Role).Seconds()),
 		TokenType:    "Bearer",
 		User: UserResponse{
 			ID:           user.ID,
@@ -244,12 +275,63 @@ func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Remove refresh token
-	s.redis.RemoveRefreshToken(claims.UserID)
+	// End this session; the user's other sessions stay signed in
+	if claims.SessionID != "" {
+		s.redis.RemoveRefreshToken(claims.UserID, claims.SessionID)
+	}
 
 	respondJSON(w, http.StatusOK, map[string]string{"message": "Logged out successfully"})
 }
 
+// logoutAll signs the user out of every session, including this one
+func (s *Server) logoutAll(w http.ResponseWriter, r *http.Request) {
+	// Get token from header
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		respondError(w, http.StatusUnauthorized, "Missing authorization header")
+		return
+	}
+
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		respondError(w, http.StatusUnauthorized, "Invalid authorization format")
+		return
+	}
+
+	token := parts[1]
+
+	// Check if token is blacklisted
+	blacklisted, _ := s.redis.IsTokenBlacklisted(token)
+	if blacklisted {
+		respondError(w, http.StatusUnauthorized, "Token has been revoked")
+		return
+	}
+
+	// Validate token
+	claims, err := s.jwtService.ValidateAccessToken(token)
+	if err != nil {
+		respondError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+	if s.sessionRevoked(claims) {
+		respondError(w, http.StatusUnauthorized, "Token has been revoked")
+		return
+	}
+
+	revoked, err := s.redis.RevokeUserSessions(claims.UserID, time.Now(), s.config.AccessTokenTTL)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).WithField("user_id", claims.UserID).Error("Failed to revoke sessions")
+		respondError(w, http.StatusInternalServerError, "Failed to logout")
+		return
+	}
+
+	s.logger.WithContext(r.Context()).WithField("user_id", claims.UserID).WithField("sessions_revoked", revoked).Info("Signed out of all sessions")
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message":          "Logged out of all sessions",
+		"sessions_revoked": revoked,
+	})
+}
+
 // refresh handles token refresh
 func (s *Server) refresh(w http.ResponseWriter, r *http.Request) {
 	var req RefreshRequest
@@ -264,9 +346,17 @@ func (s *Server) refresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if refresh token is valid in Redis
-	valid, err := s.redis.ValidateRefreshToken(claims.UserID, req.RefreshToken)
-	if err != nil || !valid {
+	// The token must be its session's current one. Presenting a token that
+	// has already been rotated means it was copied, so the whole session is
+	// ended rather than letting either holder keep refreshing.
+	current, err := s.redis.GetRefreshToken(claims.UserID, claims.SessionID)
+	if err != nil || current == "" {
+		respondError(w, http.StatusUnauthorized, "Refresh token expired or revoked")
+		return
+	}
+	if current != req.RefreshToken {
+		s.redis.RemoveRefreshToken(claims.UserID, claims.SessionID)
+		s.logger.WithContext(r.Context()).WithField("user_id", claims.UserID).Warn("Rotated refresh token reused, session revoked")
 		respondError(w, http.StatusUnauthorized, "Refresh token expired or revoked")
 		return
 	}
@@ -283,22 +373,27 @@ func (s *Server) refresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate new tokens
-	accessToken, err := s.jwtService.GenerateAccessToken(user)
+	// Re-fetch claims so role changes take effect on refresh
+	userClaims := s.enrichClaims(r.Context(), user, true)
+	if userClaims != nil {
+		user.Role = userClaims.Role
+	}
+
+	// Generate new tokens for the same session
+	accessToken, err := s.jwtService.GenerateEnrichedAccessToken(user, userClaims, claims.SessionID)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to generate access token")
 		return
 	}
 
-	newRefreshToken, err := s.jwtService.GenerateRefreshToken(user)
+	newRefreshToken, err := s.jwtService.GenerateRefreshToken(user, claims.SessionID)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to generate refresh token")
 		return
 	}
 
 	// Update refresh token in Redis (rotate)
-	s.redis.RemoveRefreshToken(user.ID)
-	if err := s.redis.StoreRefreshToken(user.ID, newRefreshToken, s.config.RefreshTokenTTL); err != nil {
+	if err := s.redis.StoreRefreshToken(user.ID, claims.SessionID, newRefreshToken, s.jwtService.RefreshTokenTTL(user.Role)); err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to store refresh token")
 		return
 	}
@@ -306,7 +401,7 @@ func (s *Server) refresh(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, AuthResponse{
 		AccessToken:  accessToken,
 		RefreshToken: newRefreshToken,
-		ExpiresIn:    int64(s.config.AccessTokenTTL.Seconds()),
+		ExpiresIn:    int64(s.jwtService.AccessTokenTTL(user.Role).Seconds()),
 		TokenType:    "Bearer",
 		User: UserResponse{
 			ID:           user.ID,
@@ -349,6 +444,10 @@ func (s *Server) me(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusUnauthorized, "Invalid token")
 		return
 	}
+	if s.sessionRevoked(claims) {
+		respondError(w, http.StatusUnauthorized, "Token has been revoked")
+		return
+	}
 
 	// Get user
 	user, err := s.db.GetUserByID(claims.UserID)
@@ -390,6 +489,10 @@ func (s *Server) changePassword(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusUnauthorized, "Invalid token")
 		return
 	}
+	if s.sessionRevoked(claims) {
+		respondError(w, http.StatusUnauthorized, "Token has been revoked")
+		return
+	}
 
 	var req ChangePasswordRequest
 	if !decodeAndValidate(r, w, &req) {
@@ -422,28 +525,59 @@ func (s *Server) changePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"message": "Password changed successfully"})
+	// Sign out everywhere, including this session, so every device has to
+	// log in with the new password
+	revoked, err := s.redis.RevokeUserSessions(user.ID, time.Now(), s.config.AccessTokenTTL)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).WithField("user_id", user.ID).Error("Failed to revoke sessions after password change")
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message":          "Password changed successfully",
+		"sessions_revoked": revoked,
+	})
 }
 
 // forgotPassword initiates password reset
 func (s *Server) forgotPassword(w http.ResponseWriter, r *http.Request) {
+	if !s.checkIPThrottle(w, r) {
+		return
+	}
+
 	var req ForgotPasswordRequest
 	if !decodeAndValidate(r, w, &req) {
 		return
 	}
 
+	// Rate limit by email rather than user so the limit does not reveal
+	// whether an account exists
+	count, err := s.redis.IncrementResetRequests(req.Email, s.config.ResetRequestWindow)
+	if err == nil && count > int64(s.config.ResetRequestLimit) {
+		respondErrorCode(w, http.StatusTooManyRequests, "Too many password reset requests, please try again later", "RATE_LIMITED")
+		return
+	}
+
 	// Get user by email (don't reveal if user exists)
 	user, _ := s.db.GetUserByEmail(strings.ToLower(req.Email))
 	if user != nil {
-		// Generate reset token
-		resetToken := uuid.New().String()
-
-		// Store reset token in Redis (valid for 1 hour)
-		s.redis.StoreResetToken(user.ID, resetToken, time.Hour)
+		resetToken, err := generateResetToken()
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to generate reset token")
+			return
+		}
+
+		// Only the hash is stored; issuing a new token invalidates any previous one
+		if err := s.redis.StoreResetToken(user.ID, hashResetToken(resetToken), s.config.ResetTokenTTL); err != nil {
+			s.logger.WithContext(r.Context()).WithError(err).Error("Failed to store reset token")
+			respondError(w, http.StatusInternalServerError, "Failed to process request")
+			return
+		}
 
 		// TODO: Send email with reset link
-		// For now, just log it
-		// log.Printf("Password reset token for %s: %s", user.Email, resetToken)
+	} else {
+		// Probing for accounts counts against the IP, but the response
+		// still doesn't reveal whether the account exists
+		s.recordIPFailure(r)
 	}
 
 	// Always return success to prevent email enumeration
@@ -460,9 +594,33 @@ func (s *Server) resetPassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate reset token
-	userID, err := s.redis.ValidateResetToken(req.Token)
-	if err != nil || userID == "" {
-		respondError(w, http.StatusBadRequest, "Invalid or expired reset token")
+	tokenHash := hashResetToken(req.Token)
+	record, err := s.redis.GetResetToken(tokenHash)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to validate reset token")
+		return
+	}
+	if record == nil {
+		respondErrorCode(w, http.StatusBadRequest, "Invalid reset token", codeResetTokenInvalid)
+		return
+	}
+	if record.Used {
+		respondErrorCode(w, http.StatusBadRequest, "Reset token has already been used", codeResetTokenUsed)
+		return
+	}
+	if record.Expired(time.Now()) {
+		respondErrorCode(w, http.StatusBadRequest, "Reset token has expired", codeResetTokenExpired)
+		return
+	}
+
+	// Mark the token used before changing the password so it cannot be replayed
+	consumed, err := s.redis.ConsumeResetToken(tokenHash)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to validate reset token")
+		return
+	}
+	if !consumed {
+		respondErrorCode(w, http.StatusBadRequest, "Reset token has already been used", codeResetTokenUsed)
 		return
 	}
 
@@ -474,13 +632,15 @@ func (s *Server) resetPassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update password
-	if err := s.db.UpdatePassword(userID, hashedPassword); err != nil {
+	if err := s.db.UpdatePassword(record.UserID, hashedPassword); err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to update password")
 		return
 	}
 
-	// Remove reset token
-	s.redis.RemoveResetToken(req.Token)
+	// Sign out everywhere: anyone holding the old password may have a session
+	if _, err := s.redis.RevokeUserSessions(record.UserID, time.Now(), s.config.AccessTokenTTL); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).WithField("user_id", record.UserID).Error("Failed to revoke sessions after password reset")
+	}
 
 	respondJSON(w, http.StatusOK, map[string]string{"message": "Password reset successfully"})
 }
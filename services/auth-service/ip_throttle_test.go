@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func (m *MockRedis) IncrementIPFailures(ip string, window time.Duration) (int64, error) {
+	m.ipFailures[ip]++
+	return m.ipFailures[ip], nil
+}
+
+func (m *MockRedis) GetIPFailures(ip string) (int64, error) {
+	return m.ipFailures[ip], nil
+}
+
+func (m *MockRedis) BlockIP(ip string, duration time.Duration) error {
+	m.ipBlocks[ip] = time.Now().Add(duration)
+	return nil
+}
+
+func (m *MockRedis) IPBlockedFor(ip string) (time.Duration, error) {
+	if remaining := time.Until(m.ipBlocks[ip]); remaining > 0 {
+		return remaining, nil
+	}
+	return 0, nil
+}
+
+// setupThrottledServer returns a server that blocks an IP after limit failures
+func setupThrottledServer(limit int) *Server {
+	server := setupTestServer()
+	server.config.IPFailureLimit = limit
+	server.config.IPFailureWindow = 15 * time.Minute
+	server.config.IPBlockDuration = 30 * time.Minute
+	server.config.IPDelayAfter = limit
+	server.config.IPDelayStep = time.Millisecond
+	return server
+}
+
+func postFromIP(server *Server, path, ip string, body interface{}) *httptest.ResponseRecorder {
+	jsonBody, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", path, bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderClientIP, ip)
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIPBlockedAfterCrossAccountFailures(t *testing.T) {
+	server := setupThrottledServer(5)
+	const attacker = "203.0.113.7"
+
+	// Each attempt targets a different account, so no single account locks
+	for i := 0; i < 4; i++ {
+		registerTestUser(t, server, fmt.Sprintf("victim%d@example.com", i))
+	}
+	registerTestUser(t, server, "owner@example.com")
+
+	for i := 0; i < 5; i++ {
+		w := postFromIP(server, "/auth/login", attacker, LoginRequest{
+			Email:    fmt.Sprintf("victim%d@example.com", i),
+			Password: "Guess123",
+		})
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("Attempt %d: expected 401, got %d", i+1, w.Code)
+		}
+	}
+
+	// The IP is now blocked, even with valid credentials
+	w := postFromIP(server, "/auth/login", attacker, LoginRequest{Email: "owner@example.com", Password: "Password123"})
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429 for a blocked IP, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected a Retry-After header")
+	}
+
+	var resp map[string]string
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp["code"] != "IP_BLOCKED" {
+		t.Errorf("Expected code IP_BLOCKED, got %q", resp["code"])
+	}
+
+	// The block applies across credential endpoints
+	w = postFromIP(server, "/auth/forgot-password", attacker, ForgotPasswordRequest{Email: "owner@example.com"})
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected forgot-password to be blocked, got %d", w.Code)
+	}
+
+	// Other IPs are unaffected
+	w = postFromIP(server, "/auth/login", "198.51.100.20", LoginRequest{Email: "owner@example.com", Password: "Password123"})
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected another IP to log in, got %d", w.Code)
+	}
+}
+
+func TestIPFailuresCountAcrossEndpoints(t *testing.T) {
+	server := setupThrottledServer(3)
+	const attacker = "203.0.113.8"
+	registerTestUser(t, server, "taken@example.com")
+
+	postFromIP(server, "/auth/register", attacker, RegisterRequest{Email: "taken@example.com", Password: "Password123"})
+	postFromIP(server, "/auth/forgot-password", attacker, ForgotPasswordRequest{Email: "nobody@example.com"})
+
+	// Successful attempts don't count
+	w := postFromIP(server, "/auth/login", attacker, LoginRequest{Email: "taken@example.com", Password: "Password123"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected login to succeed before the limit, got %d", w.Code)
+	}
+
+	postFromIP(server, "/auth/login", attacker, LoginRequest{Email: "nobody@example.com", Password: "Password123"})
+
+	w = postFromIP(server, "/auth/register", attacker, RegisterRequest{Email: "new@example.com", Password: "Password123"})
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected register to be blocked after 3 failures, got %d", w.Code)
+	}
+}
+
+func TestIPThrottleDisabled(t *testing.T) {
+	server := setupTestServer()
+	for i := 0; i < 30; i++ {
+		w := postFromIP(server, "/auth/login", "203.0.113.9", LoginRequest{Email: "nobody@example.com", Password: "Password123"})
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("Attempt %d: expected 401 with throttling disabled, got %d", i+1, w.Code)
+		}
+	}
+}
+
+func TestIPFailureDelay(t *testing.T) {
+	server := setupTestServer()
+	server.config.IPDelayAfter = 5
+	server.config.IPDelayStep = 250 * time.Millisecond
+	server.config.IPMaxDelay = time.Second
+
+	tests := []struct {
+		failures int64
+		want     time.Duration
+	}{
+		{0, 0},
+		{5, 0},
+		{6, 250 * time.Millisecond},
+		{8, 750 * time.Millisecond},
+		{50, time.Second},
+	}
+	for _, tt := range tests {
+		if got := server.ipFailureDelay(tt.failures); got != tt.want {
+			t.Errorf("ipFailureDelay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest("POST", "/auth/login", nil)
+	req.RemoteAddr = "10.0.0.5:43210"
+	if got := clientIP(req); got != "10.0.0.5" {
+		t.Errorf("Expected the remote address, got %q", got)
+	}
+
+	req.Header.Set(HeaderClientIP, "203.0.113.7")
+	if got := clientIP(req); got != "203.0.113.7" {
+		t.Errorf("Expected the gateway-supplied address, got %q", got)
+	}
+
+	req.Header.Set(HeaderClientIP, "not-an-ip")
+	if got := clientIP(req); got != "10.0.0.5" {
+		t.Errorf("Expected an invalid header to be ignored, got %q", got)
+	}
+}
@@ -13,21 +13,37 @@ type JWTService struct {
 	issuer          string
 	accessTokenTTL  time.Duration
 	refreshTokenTTL time.Duration
+
+	// Per-role overrides, keyed by lowercase role
+	roleAccessTTLs  map[string]time.Duration
+	roleRefreshTTLs map[string]time.Duration
 }
 
 // AccessTokenClaims represents claims in an access token
 type AccessTokenClaims struct {
 	jwt.RegisteredClaims
-	UserID       string `json:"user_id"`
-	Email        string `json:"email"`
-	Role         string `json:"role"`
-	DealershipID string `json:"dealership_id"`
+	UserID       string   `json:"user_id"`
+	Email        string   `json:"email"`
+	Role         string   `json:"role"`
+	DealershipID string   `json:"dealership_id"`
+	Scopes       []string `json:"scopes,omitempty"`
+
+	// ClaimsDegraded marks a token issued while user-service was unavailable;
+	// it carries the stored role but no scopes
+	ClaimsDegraded bool `json:"claims_degraded,omitempty"`
+
+	// SessionID identifies the login the token was issued for
+	SessionID string `json:"sid,omitempty"`
 }
 
 // RefreshTokenClaims represents claims in a refresh token
 type RefreshTokenClaims struct {
 	jwt.RegisteredClaims
 	UserID string `json:"user_id"`
+
+	// SessionID identifies the login the token was issued for. Refreshing
+	// rotates the token but keeps the session.
+	SessionID string `json:"sid"`
 }
 
 // NewJWTService creates a new JWT service
@@ -40,37 +56,57 @@ func NewJWTService(secret, issuer string, accessTTL, refreshTTL time.Duration) *
 	}
 }
 
-// GenerateAccessToken generates a new access token for a user
-func (j *JWTService) GenerateAccessToken(user *User) (string, error) {
+// GenerateAccessToken generates a new access token for a user's session
+func (j *JWTService) GenerateAccessToken(user *User, sessionID string) (string, error) {
+	return j.GenerateEnrichedAccessToken(user, nil, sessionID)
+}
+
+// GenerateEnrichedAccessToken generates an access token carrying the user's
+// claims from user-service. Nil claims use the role and dealership stored
+// with the user.
+func (j *JWTService) GenerateEnrichedAccessToken(user *User, enriched *UserClaims, sessionID string) (string, error) {
+	role := user.Role
+	if enriched != nil {
+		role = enriched.Role
+	}
+
 	now := time.Now()
 	claims := AccessTokenClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    j.issuer,
 			Subject:   user.ID,
 			IssuedAt:  jwt.NewNumericDate(now),
-			ExpiresAt: jwt.NewNumericDate(now.Add(j.accessTokenTTL)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(j.AccessTokenTTL(role))),
 		},
 		UserID:       user.ID,
 		Email:        user.Email,
 		Role:         user.Role,
 		DealershipID: user.DealershipID,
+		SessionID:    sessionID,
+	}
+	if enriched != nil {
+		claims.Role = enriched.Role
+		claims.DealershipID = enriched.DealershipID
+		claims.Scopes = enriched.Scopes
+		claims.ClaimsDegraded = enriched.Degraded
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(j.secret))
 }
 
-// GenerateRefreshToken generates a new refresh token for a user
-func (j *JWTService) GenerateRefreshToken(user *User) (string, error) {
+// GenerateRefreshToken generates a new refresh token for a user's session
+func (j *JWTService) GenerateRefreshToken(user *User, sessionID string) (string, error) {
 	now := time.Now()
 	claims := RefreshTokenClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    j.issuer,
 			Subject:   user.ID,
 			IssuedAt:  jwt.NewNumericDate(now),
-			ExpiresAt: jwt.NewNumericDate(now.Add(j.refreshTokenTTL)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(j.RefreshTokenTTL(user.Role))),
 		},
-		UserID: user.ID,
+		UserID:    user.ID,
+		SessionID: sessionID,
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
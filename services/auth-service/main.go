@@ -5,11 +5,15 @@ import (
 	"encoding/json"
 	"net/http"
 	"os"
-	"strings"
+	"strconv"
 	"time"
 
+	"autolytiq/shared/bodylimit"
+	"autolytiq/shared/configcheck"
+	"autolytiq/shared/graceful"
 	"autolytiq/shared/logging"
 	"autolytiq/shared/secrets"
+	"autolytiq/shared/tracing"
 
 	"github.com/gorilla/mux"
 )
@@ -23,6 +27,35 @@ type Config struct {
 	JWTIssuer       string
 	AccessTokenTTL  time.Duration
 	RefreshTokenTTL time.Duration
+
+	// Shorter token lifetimes for higher-privilege roles, keyed by lowercase
+	// role. See role_ttl.go.
+	RoleAccessTokenTTLs  map[string]time.Duration
+	RoleRefreshTokenTTLs map[string]time.Duration
+
+	// Password reset settings
+	ResetTokenTTL      time.Duration
+	ResetRequestLimit  int
+	ResetRequestWindow time.Duration
+
+	// IP throttling of credential attempts across accounts. Disabled when
+	// IPFailureLimit is zero.
+	IPFailureLimit  int
+	IPFailureWindow time.Duration
+	IPBlockDuration time.Duration
+	IPDelayAfter    int
+	IPDelayStep     time.Duration
+	IPMaxDelay      time.Duration
+
+	// Who may create accounts (see registration.go) and how long invites
+	// stay valid
+	RegistrationPolicy string
+	InviteTTL          time.Duration
+
+	// Claim enrichment from user-service
+	UserServiceURL     string
+	UserServiceTimeout time.Duration
+	UserClaimsCacheTTL time.Duration
 }
 
 // Server represents the Auth service server
@@ -32,7 +65,9 @@ type Server struct {
 	db         AuthDatabase
 	redis      TokenStore
 	jwtService *JWTService
+	claims     UserClaimsProvider
 	logger     *logging.Logger
+	tracer     *tracing.Tracer
 }
 
 func main() {
@@ -43,6 +78,9 @@ func main() {
 
 	ctx := context.Background()
 	config := loadConfig(ctx, logger)
+	if err := validateConfig(config); err != nil {
+		logger.Fatal(err.Error())
+	}
 
 	// Initialize database
 	db, err := NewPostgresDB(config.DatabaseURL, logger)
@@ -65,12 +103,19 @@ func main() {
 
 	// Initialize JWT service
 	jwtService := NewJWTService(config.JWTSecret, config.JWTIssuer, config.AccessTokenTTL, config.RefreshTokenTTL)
+	jwtService.SetRoleTTLs(config.RoleAccessTokenTTLs, config.RoleRefreshTokenTTLs)
 
 	// Create server
 	server := NewServer(config, db, redis, jwtService, logger)
+	defer server.tracer.Shutdown(context.Background())
 
+	// Stop serving on SIGINT or SIGTERM, once in-flight requests finish
+	ctx, stop := graceful.SignalContext(ctx)
+	defer stop()
+
+	srv := &http.Server{Addr: ":" + config.Port, Handler: server.router}
 	logger.Infof("Auth service starting on port %s", config.Port)
-	if err := http.ListenAndServe(":"+config.Port, server.router); err != nil {
+	if err := graceful.ListenAndServe(ctx, srv, graceful.DefaultTimeout); err != nil {
 		logger.Fatal(err.Error())
 	}
 }
@@ -124,6 +169,10 @@ func loadConfig(ctx context.Context, logger *logging.Logger) *Config {
 		jwtIssuer = getEnv("JWT_ISSUER", "autolytiq")
 	}
 
+	// Invalid values are reported by validateConfig
+	roleAccessTTLs, _ := parseRoleTTLs(getEnv("ROLE_ACCESS_TOKEN_TTLS", defaultRoleAccessTokenTTLs))
+	roleRefreshTTLs, _ := parseRoleTTLs(getEnv("ROLE_REFRESH_TOKEN_TTLS", defaultRoleRefreshTokenTTLs))
+
 	return &Config{
 		Port:            getEnv("PORT", "8087"),
 		DatabaseURL:     databaseURL,
@@ -132,6 +181,27 @@ func loadConfig(ctx context.Context, logger *logging.Logger) *Config {
 		JWTIssuer:       jwtIssuer,
 		AccessTokenTTL:  parseDuration(getEnv("ACCESS_TOKEN_TTL", "15m")),
 		RefreshTokenTTL: parseDuration(getEnv("REFRESH_TOKEN_TTL", "7d")),
+
+		RoleAccessTokenTTLs:  roleAccessTTLs,
+		RoleRefreshTokenTTLs: roleRefreshTTLs,
+
+		ResetTokenTTL:      parseDuration(getEnv("RESET_TOKEN_TTL", "30m")),
+		ResetRequestLimit:  getEnvInt("RESET_REQUEST_LIMIT", 3),
+		ResetRequestWindow: parseDuration(getEnv("RESET_REQUEST_WINDOW", "1h")),
+
+		IPFailureLimit:  getEnvInt("IP_FAILURE_LIMIT", 20),
+		IPFailureWindow: parseDuration(getEnv("IP_FAILURE_WINDOW", "15m")),
+		IPBlockDuration: parseDuration(getEnv("IP_BLOCK_DURATION", "30m")),
+		IPDelayAfter:    getEnvInt("IP_DELAY_AFTER", 5),
+		IPDelayStep:     parseDuration(getEnv("IP_DELAY_STEP", "250ms")),
+		IPMaxDelay:      parseDuration(getEnv("IP_MAX_DELAY", "3s")),
+
+		RegistrationPolicy: getEnv("REGISTRATION_POLICY", defaultRegistrationPolicy),
+		InviteTTL:          parseDuration(getEnv("INVITE_TTL", "7d")),
+
+		UserServiceURL:     getEnv("USER_SERVICE_URL", "http://localhost:8085"),
+		UserServiceTimeout: parseDuration(getEnv("USER_SERVICE_TIMEOUT", "2s")),
+		UserClaimsCacheTTL: parseDuration(getEnv("USER_CLAIMS_CACHE_TTL", "1m")),
 	}
 }
 
@@ -142,27 +212,63 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-func parseDuration(s string) time.Duration {
-	// Handle "7d" format
-	if strings.HasSuffix(s, "d") {
-		days := s[:len(s)-1]
-		var d int
-		if _, err := json.Number(days).Int64(); err == nil {
-			d = int(json.Number(days).String()[0] - '0')
-		}
-		if d == 0 {
-			d = 7
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
 		}
-		return time.Duration(d) * 24 * time.Hour
 	}
+	return defaultValue
+}
 
-	duration, err := time.ParseDuration(s)
+func parseDuration(s string) time.Duration {
+	duration, err := configcheck.ParseDuration(s)
 	if err != nil {
 		return 15 * time.Minute
 	}
 	return duration
 }
 
+// minJWTSecretLength matches the api-gateway's requirement for the shared
+// signing secret
+const minJWTSecretLength = 32
+
+// durationSettings are the environment variables read with parseDuration
+var durationSettings = []string{
+	"ACCESS_TOKEN_TTL", "REFRESH_TOKEN_TTL", "RESET_TOKEN_TTL", "RESET_REQUEST_WINDOW", "INVITE_TTL",
+	"USER_SERVICE_TIMEOUT", "USER_CLAIMS_CACHE_TTL",
+	"IP_FAILURE_WINDOW", "IP_BLOCK_DURATION", "IP_DELAY_STEP", "IP_MAX_DELAY",
+}
+
+// validateConfig checks the loaded configuration, plus the raw values of
+// settings that silently fall back to a default when they don't parse, and
+// reports every problem at once
+func validateConfig(config *Config) error {
+	c := configcheck.New()
+
+	c.Port("PORT", config.Port)
+	c.Required("DATABASE_URL", config.DatabaseURL)
+	c.URL("DATABASE_URL", config.DatabaseURL)
+	c.Required("REDIS_URL", config.RedisURL)
+	c.URL("REDIS_URL", config.RedisURL)
+	c.Secret("JWT_SECRET", config.JWTSecret, minJWTSecretLength)
+	c.URL("USER_SERVICE_URL", config.UserServiceURL)
+
+	for _, key := range durationSettings {
+		c.Duration(key, os.Getenv(key))
+	}
+	c.Int("RESET_REQUEST_LIMIT", os.Getenv("RESET_REQUEST_LIMIT"), 1)
+	c.Int("IP_FAILURE_LIMIT", os.Getenv("IP_FAILURE_LIMIT"), 0)
+	c.Int("IP_DELAY_AFTER", os.Getenv("IP_DELAY_AFTER"), 0)
+	if err := validateRegistrationPolicy(config.RegistrationPolicy); config.RegistrationPolicy != "" && err != nil {
+		c.Addf("REGISTRATION_POLICY", "%v, got %q", err, config.RegistrationPolicy)
+	}
+	checkRoleTTLs(c, "ROLE_ACCESS_TOKEN_TTLS", getEnv("ROLE_ACCESS_TOKEN_TTLS", defaultRoleAccessTokenTTLs), config.AccessTokenTTL)
+	checkRoleTTLs(c, "ROLE_REFRESH_TOKEN_TTLS", getEnv("ROLE_REFRESH_TOKEN_TTLS", defaultRoleRefreshTokenTTLs), config.RefreshTokenTTL)
+
+	return c.Err()
+}
+
 // NewServer creates a new Auth service server
 func NewServer(config *Config, db AuthDatabase, redis TokenStore, jwtService *JWTService, logger *logging.Logger) *Server {
 	s := &Server{
@@ -172,6 +278,13 @@ func NewServer(config *Config, db AuthDatabase, redis TokenStore, jwtService *JW
 		redis:      redis,
 		jwtService: jwtService,
 		logger:     logger,
+		tracer: tracing.New(tracing.Config{
+			Service: "auth-service",
+			OnError: func(err error) { logger.WithError(err).Warn("Failed to export trace spans") },
+		}),
+	}
+	if config.UserServiceURL != "" {
+		s.claims = NewUserServiceClaimsProvider(config.UserServiceURL, config.UserServiceTimeout, config.UserClaimsCacheTTL)
 	}
 	s.setupMiddleware()
 	s.setupRoutes()
@@ -179,8 +292,10 @@ func NewServer(config *Config, db AuthDatabase, redis TokenStore, jwtService *JW
 }
 
 func (s *Server) setupMiddleware() {
+	s.router.Use(s.tracer.Middleware)
 	s.router.Use(logging.RequestIDMiddleware)
 	s.router.Use(logging.RequestLoggingMiddleware(s.logger))
+	s.router.Use(bodylimit.Middleware(bodylimit.Config{}))
 }
 
 func (s *Server) setupRoutes() {
@@ -191,12 +306,17 @@ func (s *Server) setupRoutes() {
 	s.router.HandleFunc("/auth/register", s.register).Methods("POST")
 	s.router.HandleFunc("/auth/login", s.login).Methods("POST")
 	s.router.HandleFunc("/auth/logout", s.logout).Methods("POST")
+	s.router.HandleFunc("/auth/logout-all", s.logoutAll).Methods("POST")
 	s.router.HandleFunc("/auth/refresh", s.refresh).Methods("POST")
 	s.router.HandleFunc("/auth/me", s.me).Methods("GET")
 	s.router.HandleFunc("/auth/change-password", s.changePassword).Methods("POST")
 	s.router.HandleFunc("/auth/forgot-password", s.forgotPassword).Methods("POST")
 	s.router.HandleFunc("/auth/reset-password", s.resetPassword).Methods("POST")
 	s.router.HandleFunc("/auth/verify-email", s.verifyEmail).Methods("POST")
+
+	// Admin account provisioning
+	s.router.HandleFunc("/auth/invites", s.createInvite).Methods("POST")
+	s.router.HandleFunc("/auth/users", s.createUser).Methods("POST")
 }
 
 // Response helpers
@@ -210,6 +330,10 @@ func respondError(w http.ResponseWriter, status int, message string) {
 	respondJSON(w, status, map[string]string{"error": message})
 }
 
+func respondErrorCode(w http.ResponseWriter, status int, message, code string) {
+	respondJSON(w, status, map[string]string{"error": message, "code": code})
+}
+
 // Health check handler
 func (s *Server) healthCheck(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, map[string]interface{}{
@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"autolytiq/shared/logging"
@@ -13,17 +15,27 @@ import (
 // TokenStore defines the interface for token storage operations
 type TokenStore interface {
 	Close() error
-	StoreRefreshToken(userID, token string, ttl time.Duration) error
-	ValidateRefreshToken(userID, token string) (bool, error)
-	RemoveRefreshToken(userID string) error
+	StoreRefreshToken(userID, sessionID, token string, ttl time.Duration) error
+	GetRefreshToken(userID, sessionID string) (string, error)
+	RemoveRefreshToken(userID, sessionID string) error
 	BlacklistToken(token string, ttl time.Duration) error
 	IsTokenBlacklisted(token string) (bool, error)
-	StoreResetToken(userID, token string, ttl time.Duration) error
-	ValidateResetToken(token string) (string, error)
-	RemoveResetToken(token string) error
+	StoreResetToken(userID, tokenHash string, ttl time.Duration) error
+	GetResetToken(tokenHash string) (*ResetTokenRecord, error)
+	ConsumeResetToken(tokenHash string) (bool, error)
+	IncrementResetRequests(email string, window time.Duration) (int64, error)
+	RevokeUserSessions(userID string, at time.Time, ttl time.Duration) (int, error)
+	SessionsRevokedAt(userID string) (time.Time, error)
 	StoreEmailToken(userID, token string, ttl time.Duration) error
 	ValidateEmailToken(token string) (string, error)
 	RemoveEmailToken(token string) error
+	IncrementIPFailures(ip string, window time.Duration) (int64, error)
+	GetIPFailures(ip string) (int64, error)
+	BlockIP(ip string, duration time.Duration) error
+	IPBlockedFor(ip string) (time.Duration, error)
+	StoreInvite(tokenHash string, invite *InviteRecord, ttl time.Duration) error
+	GetInvite(tokenHash string) (*InviteRecord, error)
+	ConsumeInvite(tokenHash string) (bool, error)
 }
 
 // RedisStore implements TokenStore using Redis
@@ -34,10 +46,21 @@ type RedisStore struct {
 }
 
 const (
-	refreshTokenPrefix = "refresh_token:"
-	blacklistPrefix    = "blacklist:"
-	resetTokenPrefix   = "reset_token:"
-	emailTokenPrefix   = "email_token:"
+	refreshTokensPrefix = "refresh_tokens:"
+	blacklistPrefix     = "blacklist:"
+	resetTokenPrefix    = "reset_token:"
+	emailTokenPrefix    = "email_token:"
+	invitePrefix        = "invite:"
+
+	resetTokenUserPrefix = "reset_token_user:"
+	resetRequestsPrefix  = "reset_requests:"
+	sessionsRevokedKey   = "sessions_revoked:"
+	ipFailuresPrefix     = "ip_failures:"
+	ipBlockedPrefix      = "ip_blocked:"
+
+	// resetTokenRetention keeps expired reset tokens around long enough to
+	// report them as expired rather than unknown
+	resetTokenRetention = 24 * time.Hour
 )
 
 // NewRedisStore creates a new Redis store
@@ -69,29 +92,56 @@ func (r *RedisStore) Close() error {
 	return r.client.Close()
 }
 
-// StoreRefreshToken stores a refresh token for a user
-func (r *RedisStore) StoreRefreshToken(userID, token string, ttl time.Duration) error {
-	key := refreshTokenPrefix + userID
-	return r.client.Set(r.ctx, key, token, ttl).Err()
+// StoreRefreshToken stores the current refresh token of one of a user's
+// sessions, replacing the token it was rotated from. Each user's sessions
+// share a hash; a session's entry records when its token expires so
+// abandoned sessions aren't counted.
+func (r *RedisStore) StoreRefreshToken(userID, sessionID, token string, ttl time.Duration) error {
+	key := refreshTokensPrefix + userID
+	value := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10) + ":" + token
+
+	pipe := r.client.TxPipeline()
+	pipe.HSet(r.ctx, key, sessionID, value)
+	pipe.Expire(r.ctx, key, ttl)
+	_, err := pipe.Exec(r.ctx)
+	return err
 }
 
-// ValidateRefreshToken validates a refresh token for a user
-func (r *RedisStore) ValidateRefreshToken(userID, token string) (bool, error) {
-	key := refreshTokenPrefix + userID
-	storedToken, err := r.client.Get(r.ctx, key).Result()
+// GetRefreshToken returns the current refresh token of a session, or "" if
+// the session has ended or expired
+func (r *RedisStore) GetRefreshToken(userID, sessionID string) (string, error) {
+	value, err := r.client.HGet(r.ctx, refreshTokensPrefix+userID, sessionID).Result()
 	if err == redis.Nil {
-		return false, nil
+		return "", nil
 	}
 	if err != nil {
-		return false, err
+		return "", err
 	}
-	return storedToken == token, nil
+
+	token, live := parseRefreshEntry(value, time.Now())
+	if !live {
+		return "", nil
+	}
+	return token, nil
 }
 
-// RemoveRefreshToken removes a refresh token for a user
-func (r *RedisStore) RemoveRefreshToken(userID string) error {
-	key := refreshTokenPrefix + userID
-	return r.client.Del(r.ctx, key).Err()
+// RemoveRefreshToken ends one of a user's sessions
+func (r *RedisStore) RemoveRefreshToken(userID, sessionID string) error {
+	return r.client.HDel(r.ctx, refreshTokensPrefix+userID, sessionID).Err()
+}
+
+// parseRefreshEntry splits a stored session entry into its token and whether
+// the token is still live at now
+func parseRefreshEntry(value string, now time.Time) (string, bool) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 {
+		return "", false
+	}
+	expiresAt, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || now.Unix() >= expiresAt {
+		return "", false
+	}
+	return parts[1], true
 }
 
 // BlacklistToken adds a token to the blacklist
@@ -110,29 +160,110 @@ func (r *RedisStore) IsTokenBlacklisted(token string) (bool, error) {
 	return exists > 0, nil
 }
 
-// StoreResetToken stores a password reset token
-func (r *RedisStore) StoreResetToken(userID, token string, ttl time.Duration) error {
-	key := resetTokenPrefix + token
-	return r.client.Set(r.ctx, key, userID, ttl).Err()
+// StoreResetToken stores the hash of a password reset token, replacing any
+// token previously issued to the user
+func (r *RedisStore) StoreResetToken(userID, tokenHash string, ttl time.Duration) error {
+	userKey := resetTokenUserPrefix + userID
+	previous, err := r.client.Get(r.ctx, userKey).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	key := resetTokenPrefix + tokenHash
+	expiresAt := time.Now().Add(ttl)
+
+	pipe := r.client.TxPipeline()
+	if previous != "" {
+		pipe.Del(r.ctx, resetTokenPrefix+previous)
+	}
+	pipe.HSet(r.ctx, key, "user_id", userID, "expires_at", expiresAt.Unix())
+	pipe.Expire(r.ctx, key, ttl+resetTokenRetention)
+	pipe.Set(r.ctx, userKey, tokenHash, ttl+resetTokenRetention)
+	_, err = pipe.Exec(r.ctx)
+	return err
 }
 
-// ValidateResetToken validates a password reset token and returns the user ID
-func (r *RedisStore) ValidateResetToken(token string) (string, error) {
-	key := resetTokenPrefix + token
-	userID, err := r.client.Get(r.ctx, key).Result()
-	if err == redis.Nil {
-		return "", nil
+// GetResetToken returns the reset token record for a token hash, or nil if
+// the token is unknown or has been replaced
+func (r *RedisStore) GetResetToken(tokenHash string) (*ResetTokenRecord, error) {
+	values, err := r.client.HGetAll(r.ctx, resetTokenPrefix+tokenHash).Result()
+	if err != nil {
+		return nil, err
 	}
+	if values["user_id"] == "" {
+		return nil, nil
+	}
+
+	expiresAt, err := strconv.ParseInt(values["expires_at"], 10, 64)
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("invalid reset token expiry: %w", err)
 	}
-	return userID, nil
+
+	return &ResetTokenRecord{
+		UserID:    values["user_id"],
+		ExpiresAt: time.Unix(expiresAt, 0),
+		Used:      values["used_at"] != "",
+	}, nil
 }
 
-// RemoveResetToken removes a password reset token
-func (r *RedisStore) RemoveResetToken(token string) error {
-	key := resetTokenPrefix + token
-	return r.client.Del(r.ctx, key).Err()
+// ConsumeResetToken marks a reset token as used. It returns false if the
+// token was already used, so concurrent requests cannot both succeed.
+func (r *RedisStore) ConsumeResetToken(tokenHash string) (bool, error) {
+	return r.client.HSetNX(r.ctx, resetTokenPrefix+tokenHash, "used_at", time.Now().Unix()).Result()
+}
+
+// IncrementResetRequests counts reset requests for an email within a window
+// and returns the count including this request
+func (r *RedisStore) IncrementResetRequests(email string, window time.Duration) (int64, error) {
+	key := resetRequestsPrefix + email
+	count, err := r.client.Incr(r.ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		r.client.Expire(r.ctx, key, window)
+	}
+	return count, nil
+}
+
+// RevokeUserSessions ends all of the user's sessions and records the time
+// before which access tokens are no longer accepted. ttl should cover the
+// lifetime of any outstanding access token. It returns the number of live
+// sessions ended.
+func (r *RedisStore) RevokeUserSessions(userID string, at time.Time, ttl time.Duration) (int, error) {
+	key := refreshTokensPrefix + userID
+	sessions, err := r.client.HGetAll(r.ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.Del(r.ctx, key)
+	pipe.Set(r.ctx, sessionsRevokedKey+userID, at.UnixNano(), ttl)
+	if _, err := pipe.Exec(r.ctx); err != nil {
+		return 0, err
+	}
+
+	revoked := 0
+	for _, value := range sessions {
+		if _, live := parseRefreshEntry(value, at); live {
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+// SessionsRevokedAt returns when the user's sessions were last revoked, or the
+// zero time if they have not been
+func (r *RedisStore) SessionsRevokedAt(userID string) (time.Time, error) {
+	value, err := r.client.Get(r.ctx, sessionsRevokedKey+userID).Int64()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, value), nil
 }
 
 // StoreEmailToken stores an email verification token
@@ -159,3 +290,91 @@ func (r *RedisStore) RemoveEmailToken(token string) error {
 	key := emailTokenPrefix + token
 	return r.client.Del(r.ctx, key).Err()
 }
+
+// IncrementIPFailures counts failed credential attempts from an IP within a
+// window and returns the count including this failure
+func (r *RedisStore) IncrementIPFailures(ip string, window time.Duration) (int64, error) {
+	key := ipFailuresPrefix + ip
+	count, err := r.client.Incr(r.ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		r.client.Expire(r.ctx, key, window)
+	}
+	return count, nil
+}
+
+// GetIPFailures returns the failed credential attempts from an IP in the
+// current window
+func (r *RedisStore) GetIPFailures(ip string) (int64, error) {
+	count, err := r.client.Get(r.ctx, ipFailuresPrefix+ip).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return count, err
+}
+
+// BlockIP rejects credential attempts from an IP for the given duration
+func (r *RedisStore) BlockIP(ip string, duration time.Duration) error {
+	return r.client.Set(r.ctx, ipBlockedPrefix+ip, time.Now().Unix(), duration).Err()
+}
+
+// IPBlockedFor returns how much longer an IP is blocked, or zero if it isn't
+func (r *RedisStore) IPBlockedFor(ip string) (time.Duration, error) {
+	ttl, err := r.client.TTL(r.ctx, ipBlockedPrefix+ip).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// StoreInvite stores an invite under the hash of its token
+func (r *RedisStore) StoreInvite(tokenHash string, invite *InviteRecord, ttl time.Duration) error {
+	key := invitePrefix + tokenHash
+
+	pipe := r.client.TxPipeline()
+	pipe.HSet(r.ctx, key,
+		"email", invite.Email,
+		"dealership_id", invite.DealershipID,
+		"role", invite.Role,
+		"invited_by", invite.InvitedBy,
+		"expires_at", invite.ExpiresAt.Unix())
+	pipe.Expire(r.ctx, key, ttl+resetTokenRetention)
+	_, err := pipe.Exec(r.ctx)
+	return err
+}
+
+// GetInvite returns the invite for a token hash, or nil if it is unknown
+func (r *RedisStore) GetInvite(tokenHash string) (*InviteRecord, error) {
+	values, err := r.client.HGetAll(r.ctx, invitePrefix+tokenHash).Result()
+	if err != nil {
+		return nil, err
+	}
+	if values["email"] == "" {
+		return nil, nil
+	}
+
+	expiresAt, err := strconv.ParseInt(values["expires_at"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid invite expiry: %w", err)
+	}
+
+	return &InviteRecord{
+		Email:        values["email"],
+		DealershipID: values["dealership_id"],
+		Role:         values["role"],
+		InvitedBy:    values["invited_by"],
+		ExpiresAt:    time.Unix(expiresAt, 0),
+		Used:         values["used_at"] != "",
+	}, nil
+}
+
+// ConsumeInvite marks an invite as used. It returns false if the invite was
+// already used, so an invite creates at most one account.
+func (r *RedisStore) ConsumeInvite(tokenHash string) (bool, error) {
+	return r.client.HSetNX(r.ctx, invitePrefix+tokenHash, "used_at", time.Now().Unix()).Result()
+}
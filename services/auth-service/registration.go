@@ -0,0 +1,311 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Registration policies, set per deployment with REGISTRATION_POLICY.
+// Self-registration is open only in open mode; in invite_only mode it needs
+// an invite issued by a dealership admin, and in admin_only mode accounts are
+// created by admins only.
+const (
+	RegistrationOpen       = "open"
+	RegistrationInviteOnly = "invite_only"
+	RegistrationAdminOnly  = "admin_only"
+)
+
+// defaultRegistrationPolicy is used when REGISTRATION_POLICY isn't set
+const defaultRegistrationPolicy = RegistrationInviteOnly
+
+// Registration error codes
+const (
+	codeRegistrationClosed = "REGISTRATION_CLOSED"
+	codeInviteInvalid      = "INVITE_INVALID"
+	codeInviteExpired      = "INVITE_EXPIRED"
+	codeInviteUsed         = "INVITE_USED"
+)
+
+// Roles that may invite and create users
+var userAdminRoles = map[string]bool{
+	"SUPER_ADMIN": true,
+	"ADMIN":       true,
+}
+
+// Roles an invited or admin-created user may be given. SUPER_ADMIN is
+// provisioned out of band.
+var assignableRoles = map[string]bool{
+	"ADMIN":           true,
+	"MANAGER":         true,
+	"FINANCE_MANAGER": true,
+	"SALESPERSON":     true,
+	"BDC_AGENT":       true,
+	"SERVICE_ADVISOR": true,
+	"VIEWER":          true,
+}
+
+// InviteRecord is the stored state of an invite token. Invite tokens are
+// generated and stored hashed like reset tokens.
+type InviteRecord struct {
+	Email        string
+	DealershipID string
+	Role         string
+	InvitedBy    string
+	ExpiresAt    time.Time
+	Used         bool
+}
+
+// Expired reports whether the invite is past its expiry
+func (i *InviteRecord) Expired(now time.Time) bool {
+	return !now.Before(i.ExpiresAt)
+}
+
+// CreateInviteRequest represents an invite to join the admin's dealership
+type CreateInviteRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role,omitempty"`
+}
+
+// InviteResponse is returned when an invite is created. The token is only
+// returned here and must be sent to the invitee.
+type InviteResponse struct {
+	InviteToken  string    `json:"invite_token"`
+	Email        string    `json:"email"`
+	Role         string    `json:"role"`
+	DealershipID string    `json:"dealership_id"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// CreateUserRequest represents an admin creating a user in their dealership
+type CreateUserRequest struct {
+	Email     string `json:"email"`
+	Password  string `json:"password"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Role      string `json:"role,omitempty"`
+}
+
+// validateRegistrationPolicy returns an error for an unknown policy
+func validateRegistrationPolicy(policy string) error {
+	switch policy {
+	case RegistrationOpen, RegistrationInviteOnly, RegistrationAdminOnly:
+		return nil
+	}
+	return fmt.Errorf("must be %s, %s or %s", RegistrationOpen, RegistrationInviteOnly, RegistrationAdminOnly)
+}
+
+// registrationPolicy returns the configured policy. An unset policy is
+// treated as invite_only so a misconfigured server doesn't allow sign-ups.
+func (s *Server) registrationPolicy() string {
+	if s.config.RegistrationPolicy == "" {
+		return defaultRegistrationPolicy
+	}
+	return s.config.RegistrationPolicy
+}
+
+// checkRegistration applies the registration policy to a public registration.
+// For an invited registration it consumes the invite and returns it, and the
+// user is created in the invite's dealership with its role. It writes an
+// error and reports false when registration isn't allowed.
+func (s *Server) checkRegistration(w http.ResponseWriter, r *http.Request, req *RegisterRequest) (*InviteRecord, bool) {
+	policy := s.registrationPolicy()
+	if policy == RegistrationAdminOnly {
+		respondErrorCode(w, http.StatusForbidden, "Public registration is disabled; ask your dealership admin for an account", codeRegistrationClosed)
+		return nil, false
+	}
+	if req.InviteToken == "" {
+		if policy == RegistrationInviteOnly {
+			respondErrorCode(w, http.StatusForbidden, "Registration requires an invitation", codeRegistrationClosed)
+			return nil, false
+		}
+		return nil, true
+	}
+
+	tokenHash := hashResetToken(req.InviteToken)
+	invite, err := s.redis.GetInvite(tokenHash)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to validate invite")
+		return nil, false
+	}
+	// An invite is bound to the address it was sent to
+	if invite == nil || invite.Email != req.Email {
+		s.recordIPFailure(r)
+		respondErrorCode(w, http.StatusForbidden, "Invalid invite token", codeInviteInvalid)
+		return nil, false
+	}
+	if invite.Used {
+		respondErrorCode(w, http.StatusForbidden, "Invite has already been used", codeInviteUsed)
+		return nil, false
+	}
+	if invite.Expired(time.Now()) {
+		respondErrorCode(w, http.StatusForbidden, "Invite has expired", codeInviteExpired)
+		return nil, false
+	}
+
+	consumed, err := s.redis.ConsumeInvite(tokenHash)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to validate invite")
+		return nil, false
+	}
+	if !consumed {
+		respondErrorCode(w, http.StatusForbidden, "Invite has already been used", codeInviteUsed)
+		return nil, false
+	}
+
+	return invite, true
+}
+
+// requireUserAdmin validates the bearer token and checks that it belongs to
+// a dealership admin. It writes an error and reports false otherwise.
+func (s *Server) requireUserAdmin(w http.ResponseWriter, r *http.Request) (*AccessTokenClaims, bool) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		respondError(w, http.StatusUnauthorized, "Missing authorization header")
+		return nil, false
+	}
+
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		respondError(w, http.StatusUnauthorized, "Invalid authorization format")
+		return nil, false
+	}
+
+	token := parts[1]
+
+	blacklisted, _ := s.redis.IsTokenBlacklisted(token)
+	if blacklisted {
+		respondError(w, http.StatusUnauthorized, "Token has been revoked")
+		return nil, false
+	}
+
+	claims, err := s.jwtService.ValidateAccessToken(token)
+	if err != nil {
+		respondError(w, http.StatusUnauthorized, "Invalid token")
+		return nil, false
+	}
+	if s.sessionRevoked(claims) {
+		respondError(w, http.StatusUnauthorized, "Token has been revoked")
+		return nil, false
+	}
+
+	if !userAdminRoles[strings.ToUpper(claims.Role)] {
+		respondError(w, http.StatusForbidden, "Only dealership admins can manage users")
+		return nil, false
+	}
+	if claims.DealershipID == "" {
+		respondError(w, http.StatusForbidden, "Admin is not assigned to a dealership")
+		return nil, false
+	}
+	return claims, true
+}
+
+// createInvite handles POST /auth/invites, inviting someone to register in
+// the admin's dealership
+func (s *Server) createInvite(w http.ResponseWriter, r *http.Request) {
+	claims, ok := s.requireUserAdmin(w, r)
+	if !ok {
+		return
+	}
+	if s.registrationPolicy() == RegistrationAdminOnly {
+		respondErrorCode(w, http.StatusForbidden, "Invites are disabled; create the user directly", codeRegistrationClosed)
+		return
+	}
+
+	var req CreateInviteRequest
+	if !decodeAndValidate(r, w, &req) {
+		return
+	}
+
+	existing, _ := s.db.GetUserByEmail(req.Email)
+	if existing != nil {
+		respondError(w, http.StatusConflict, "User with this email already exists")
+		return
+	}
+
+	token, err := generateResetToken()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to generate invite token")
+		return
+	}
+
+	invite := &InviteRecord{
+		Email:        req.Email,
+		DealershipID: claims.DealershipID,
+		Role:         req.Role,
+		InvitedBy:    claims.UserID,
+		ExpiresAt:    time.Now().Add(s.config.InviteTTL),
+	}
+	if err := s.redis.StoreInvite(hashResetToken(token), invite, s.config.InviteTTL); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to store invite")
+		respondError(w, http.StatusInternalServerError, "Failed to create invite")
+		return
+	}
+
+	// TODO: Send email with invite link
+	s.logger.WithContext(r.Context()).WithField("user_id", claims.UserID).WithField("role", invite.Role).Info("Invite created")
+
+	respondJSON(w, http.StatusCreated, InviteResponse{
+		InviteToken:  token,
+		Email:        invite.Email,
+		Role:         invite.Role,
+		DealershipID: invite.DealershipID,
+		ExpiresAt:    invite.ExpiresAt,
+	})
+}
+
+// createUser handles POST /auth/users, an admin creating an account in their
+// dealership. It is allowed under every registration policy.
+func (s *Server) createUser(w http.ResponseWriter, r *http.Request) {
+	claims, ok := s.requireUserAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateUserRequest
+	if !decodeAndValidate(r, w, &req) {
+		return
+	}
+
+	existing, _ := s.db.GetUserByEmail(req.Email)
+	if existing != nil {
+		respondError(w, http.StatusConflict, "User with this email already exists")
+		return
+	}
+
+	hashedPassword, err := HashPassword(req.Password)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to process password")
+		return
+	}
+
+	user := &User{
+		ID:           uuid.New().String(),
+		Email:        req.Email,
+		PasswordHash: hashedPassword,
+		FirstName:    req.FirstName,
+		LastName:     req.LastName,
+		Role:         req.Role,
+		DealershipID: claims.DealershipID,
+		IsActive:     true,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+
+	if err := s.db.CreateUser(user); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to create user")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, UserResponse{
+		ID:           user.ID,
+		Email:        user.Email,
+		FirstName:    user.FirstName,
+		LastName:     user.LastName,
+		Role:         user.Role,
+		DealershipID: user.DealershipID,
+	})
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const registrationDealershipID = "550e8400-e29b-41d4-a716-446655440000"
+
+// loginTestAdmin creates a dealership admin and logs them in
+func loginTestAdmin(t *testing.T, server *Server) string {
+	t.Helper()
+
+	hash, _ := HashPassword("Password123")
+	server.db.CreateUser(&User{
+		ID:           "admin-1",
+		Email:        "admin@example.com",
+		PasswordHash: hash,
+		Role:         "ADMIN",
+		DealershipID: registrationDealershipID,
+		IsActive:     true,
+	})
+	return loginTestUser(t, server, "admin@example.com", "Password123").AccessToken
+}
+
+func createTestInvite(t *testing.T, server *Server, adminToken, email, role string) InviteResponse {
+	t.Helper()
+
+	w := authenticatedRequest(server, "POST", "/auth/invites", adminToken, CreateInviteRequest{Email: email, Role: role})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Failed to create invite: %d %s", w.Code, w.Body.String())
+	}
+	var invite InviteResponse
+	json.Unmarshal(w.Body.Bytes(), &invite)
+	return invite
+}
+
+func TestInviteOnlyRegistration(t *testing.T) {
+	server := setupTestServer()
+	server.config.RegistrationPolicy = RegistrationInviteOnly
+	adminToken := loginTestAdmin(t, server)
+
+	register := func(email, inviteToken string) *RegisterRequest {
+		return &RegisterRequest{Email: email, Password: "Password123", InviteToken: inviteToken}
+	}
+
+	// Without an invite
+	w := postFromIP(server, "/auth/register", "192.0.2.1", register("new@example.com", ""))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403 without an invite, got %d: %s", w.Code, w.Body.String())
+	}
+	var body map[string]string
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if body["code"] != codeRegistrationClosed {
+		t.Errorf("Expected %s, got %v", codeRegistrationClosed, body)
+	}
+
+	// With a made-up invite
+	if w := postFromIP(server, "/auth/register", "192.0.2.1", register("new@example.com", "not-a-real-invite")); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for an unknown invite, got %d", w.Code)
+	}
+
+	invite := createTestInvite(t, server, adminToken, "New@Example.com", "manager")
+	if invite.Email != "new@example.com" || invite.Role != "MANAGER" || invite.DealershipID != registrationDealershipID {
+		t.Errorf("Unexpected invite: %+v", invite)
+	}
+
+	// The invite is bound to the invited address
+	if w := postFromIP(server, "/auth/register", "192.0.2.1", register("other@example.com", invite.InviteToken)); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 using another address's invite, got %d", w.Code)
+	}
+
+	// The invited user joins the admin's dealership with the invited role,
+	// whatever dealership they ask for
+	req := register("new@example.com", invite.InviteToken)
+	req.DealershipID = "11111111-1111-1111-1111-111111111111"
+	w = postFromIP(server, "/auth/register", "192.0.2.1", req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 with an invite, got %d: %s", w.Code, w.Body.String())
+	}
+	var response AuthResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response.User.Role != "MANAGER" || response.User.DealershipID != registrationDealershipID {
+		t.Errorf("Expected an invited manager in the admin's dealership, got %+v", response.User)
+	}
+
+	// An invite creates one account
+	w = postFromIP(server, "/auth/register", "192.0.2.1", register("new@example.com", invite.InviteToken))
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusForbidden || body["code"] != codeInviteUsed {
+		t.Errorf("Expected 403 %s reusing the invite, got %d: %v", codeInviteUsed, w.Code, body)
+	}
+}
+
+func TestExpiredInviteIsRejected(t *testing.T) {
+	server := setupTestServer()
+	server.config.RegistrationPolicy = RegistrationInviteOnly
+	server.config.InviteTTL = time.Millisecond
+	invite := createTestInvite(t, server, loginTestAdmin(t, server), "late@example.com", "")
+	time.Sleep(5 * time.Millisecond)
+
+	w := postFromIP(server, "/auth/register", "192.0.2.1", RegisterRequest{Email: "late@example.com", Password: "Password123", InviteToken: invite.InviteToken})
+	var body map[string]string
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusForbidden || body["code"] != codeInviteExpired {
+		t.Errorf("Expected 403 %s, got %d: %v", codeInviteExpired, w.Code, body)
+	}
+}
+
+func TestAdminOnlyRegistration(t *testing.T) {
+	server := setupTestServer()
+	server.config.RegistrationPolicy = RegistrationAdminOnly
+	adminToken := loginTestAdmin(t, server)
+
+	if w := postFromIP(server, "/auth/register", "192.0.2.1", RegisterRequest{Email: "new@example.com", Password: "Password123"}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for public registration, got %d", w.Code)
+	}
+	if w := authenticatedRequest(server, "POST", "/auth/invites", adminToken, CreateInviteRequest{Email: "new@example.com"}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected invites to be disabled, got %d", w.Code)
+	}
+
+	w := authenticatedRequest(server, "POST", "/auth/users", adminToken, CreateUserRequest{Email: "new@example.com", Password: "Password123", Role: "finance_manager"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 for an admin-created user, got %d: %s", w.Code, w.Body.String())
+	}
+	var user UserResponse
+	json.Unmarshal(w.Body.Bytes(), &user)
+	if user.Role != "FINANCE_MANAGER" || user.DealershipID != registrationDealershipID {
+		t.Errorf("Expected a finance manager in the admin's dealership, got %+v", user)
+	}
+
+	// Only admins provision accounts
+	staffToken := loginTestUser(t, server, "new@example.com", "Password123").AccessToken
+	if w := authenticatedRequest(server, "POST", "/auth/users", staffToken, CreateUserRequest{Email: "another@example.com", Password: "Password123"}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a non-admin, got %d", w.Code)
+	}
+}
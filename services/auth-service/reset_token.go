@@ -0,0 +1,60 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+// Reset token error codes returned by resetPassword
+const (
+	codeResetTokenInvalid = "RESET_TOKEN_INVALID"
+	codeResetTokenExpired = "RESET_TOKEN_EXPIRED"
+	codeResetTokenUsed    = "RESET_TOKEN_USED"
+)
+
+// resetTokenBytes is the amount of randomness in a reset token
+const resetTokenBytes = 32
+
+// ResetTokenRecord is the stored state of a password reset token
+type ResetTokenRecord struct {
+	UserID    string
+	ExpiresAt time.Time
+	Used      bool
+}
+
+// Expired reports whether the token is past its expiry
+func (t *ResetTokenRecord) Expired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+// generateResetToken returns a new cryptographically random reset token. Only
+// its hash is stored; the token itself is sent to the user.
+func generateResetToken() (string, error) {
+	b := make([]byte, resetTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashResetToken returns the storage key for a reset token
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// sessionRevoked reports whether an access token was issued before the
+// user's sessions were revoked (for example by a password reset)
+func (s *Server) sessionRevoked(claims *AccessTokenClaims) bool {
+	revokedAt, err := s.redis.SessionsRevokedAt(claims.UserID)
+	if err != nil || revokedAt.IsZero() {
+		return false
+	}
+	if claims.IssuedAt == nil {
+		return true
+	}
+	return !claims.IssuedAt.Time.After(revokedAt)
+}
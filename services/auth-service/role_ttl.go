@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"autolytiq/shared/configcheck"
+)
+
+// Per-role token lifetimes. A stolen token is only useful until it expires,
+// and a token belonging to a user who can export customer data, change
+// dealership settings or manage staff does far more damage in that window
+// than a salesperson's. Higher-privilege roles therefore get shorter access
+// tokens, and shorter refresh windows so an idle admin session is not kept
+// alive for the full global REFRESH_TOKEN_TTL. Roles without an entry use
+// the global TTLs.
+//
+// Both settings are comma-separated role=duration pairs, e.g.
+// "admin=5m,manager=10m". Role names are case-insensitive.
+const (
+	defaultRoleAccessTokenTTLs  = "admin=5m,manager=10m"
+	defaultRoleRefreshTokenTTLs = "admin=1d,manager=3d"
+)
+
+// parseRoleTTLs parses role=duration pairs into a map keyed by lowercase role
+func parseRoleTTLs(value string) (map[string]time.Duration, error) {
+	ttls := make(map[string]time.Duration)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		role := strings.ToLower(strings.TrimSpace(parts[0]))
+		if len(parts) != 2 || role == "" {
+			return nil, fmt.Errorf("expected role=duration, got %q", pair)
+		}
+		ttl, err := configcheck.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid duration for role %s: %q", role, parts[1])
+		}
+		ttls[role] = ttl
+	}
+	return ttls, nil
+}
+
+// checkRoleTTLs records a problem if a per-role TTL setting doesn't parse or
+// gives a role a longer lifetime than the global TTL. Per-role TTLs may only
+// shorten token lifetimes, so the global TTL stays the longest any token
+// lives (session revocation relies on this).
+func checkRoleTTLs(c *configcheck.Checker, key, value string, global time.Duration) {
+	ttls, err := parseRoleTTLs(value)
+	if err != nil {
+		c.Addf(key, "%v", err)
+		return
+	}
+	for role, ttl := range ttls {
+		if ttl > global {
+			c.Addf(key, "TTL for role %s (%s) exceeds the global TTL (%s)", role, ttl, global)
+		}
+	}
+}
+
+// SetRoleTTLs sets per-role access and refresh token lifetimes, keyed by
+// lowercase role. Roles without an entry use the service's default TTLs.
+func (j *JWTService) SetRoleTTLs(access, refresh map[string]time.Duration) {
+	j.roleAccessTTLs = access
+	j.roleRefreshTTLs = refresh
+}
+
+// AccessTokenTTL returns the access token lifetime for a role
+func (j *JWTService) AccessTokenTTL(role string) time.Duration {
+	if ttl, ok := j.roleAccessTTLs[strings.ToLower(role)]; ok {
+		return ttl
+	}
+	return j.accessTokenTTL
+}
+
+// RefreshTokenTTL returns the refresh token lifetime for a role
+func (j *JWTService) RefreshTokenTTL(role string) time.Duration {
+	if ttl, ok := j.roleRefreshTTLs[strings.ToLower(role)]; ok {
+		return ttl
+	}
+	return j.refreshTokenTTL
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRoleTTLs_AdminTokensExpireSooner(t *testing.T) {
+	access, _ := parseRoleTTLs(defaultRoleAccessTokenTTLs)
+	refresh, _ := parseRoleTTLs(defaultRoleRefreshTokenTTLs)
+	jwtService := NewJWTService("test-secret", "test-issuer", 15*time.Minute, 7*24*time.Hour)
+	jwtService.SetRoleTTLs(access, refresh)
+
+	expiry := func(role string) (time.Time, time.Time) {
+		user := &User{ID: uuid.New().String(), Email: role + "@example.com", Role: role}
+		accessToken, err := jwtService.GenerateAccessToken(user, "session-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		refreshToken, err := jwtService.GenerateRefreshToken(user, "session-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		accessClaims, err := jwtService.ValidateAccessToken(accessToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		refreshClaims, err := jwtService.ValidateRefreshToken(refreshToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return accessClaims.ExpiresAt.Time, refreshClaims.ExpiresAt.Time
+	}
+
+	adminAccess, adminRefresh := expiry("ADMIN")
+	salesAccess, salesRefresh := expiry("SALESPERSON")
+
+	if !adminAccess.Before(salesAccess) {
+		t.Errorf("Expected admin access token (%v) to expire before salesperson's (%v)", adminAccess, salesAccess)
+	}
+	if !adminRefresh.Before(salesRefresh) {
+		t.Errorf("Expected admin refresh token (%v) to expire before salesperson's (%v)", adminRefresh, salesRefresh)
+	}
+	if ttl := time.Until(salesAccess); ttl < 14*time.Minute || ttl > 15*time.Minute {
+		t.Errorf("Expected roles without an override to use the global TTL, got %v", ttl)
+	}
+}
+
+func TestRoleTTLs_LoginReportsRoleExpiry(t *testing.T) {
+	server := setupTestServer()
+	server.jwtService.SetRoleTTLs(map[string]time.Duration{"admin": 5 * time.Minute}, nil)
+
+	db := server.db.(*MockDB)
+	for _, role := range []string{"ADMIN", "SALESPERSON"} {
+		hash, _ := HashPassword("Password123")
+		db.CreateUser(&User{ID: uuid.New().String(), Email: strings.ToLower(role) + "@example.com", PasswordHash: hash, Role: role, IsActive: true})
+	}
+
+	login := func(email string) AuthResponse {
+		body, _ := json.Marshal(LoginRequest{Email: email, Password: "Password123"})
+		req := httptest.NewRequest("POST", "/auth/login", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var response AuthResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response
+	}
+
+	if got := login("admin@example.com").ExpiresIn; got != 300 {
+		t.Errorf("Expected admin expires_in 300, got %d", got)
+	}
+	if got := login("salesperson@example.com").ExpiresIn; got != 900 {
+		t.Errorf("Expected salesperson expires_in 900, got %d", got)
+	}
+}
+
+func TestValidateConfig_RoleTTLs(t *testing.T) {
+	t.Setenv("ROLE_ACCESS_TOKEN_TTLS", "admin=1h")
+	t.Setenv("ROLE_REFRESH_TOKEN_TTLS", "manager")
+
+	err := validateConfig(validTestConfig())
+	if err == nil {
+		t.Fatal("Expected invalid role TTLs to be rejected")
+	}
+	for _, want := range []string{
+		"ROLE_ACCESS_TOKEN_TTLS: TTL for role admin (1h0m0s) exceeds the global TTL (15m0s)",
+		"ROLE_REFRESH_TOKEN_TTLS: expected role=duration",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to contain %q, got %q", want, err.Error())
+		}
+	}
+}
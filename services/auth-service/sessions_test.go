@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// loginTestUser logs in, starting a new session
+func loginTestUser(t *testing.T, server *Server, email, password string) AuthResponse {
+	t.Helper()
+
+	jsonBody, _ := json.Marshal(LoginRequest{Email: email, Password: password})
+	req := httptest.NewRequest("POST", "/auth/login", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Failed to log in: %d %s", w.Code, w.Body.String())
+	}
+
+	var response AuthResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return response
+}
+
+// refreshStatus exchanges a refresh token and returns the status and response
+func refreshStatus(server *Server, refreshToken string) (int, AuthResponse) {
+	jsonBody, _ := json.Marshal(RefreshRequest{RefreshToken: refreshToken})
+	req := httptest.NewRequest("POST", "/auth/refresh", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+
+	var response AuthResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+// authenticatedRequest sends a request with an access token
+func authenticatedRequest(server *Server, method, path, accessToken string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestLoginsOnSeveralDevicesStaySignedIn(t *testing.T) {
+	server := setupTestServer()
+	registerTestUser(t, server, "devices@example.com")
+
+	laptop := loginTestUser(t, server, "devices@example.com", "Password123")
+	phone := loginTestUser(t, server, "devices@example.com", "Password123")
+
+	if status, _ := refreshStatus(server, laptop.RefreshToken); status != http.StatusOK {
+		t.Errorf("Expected the first device to still refresh, got %d", status)
+	}
+
+	// Logging out ends only that device's session
+	if w := authenticatedRequest(server, "POST", "/auth/logout", phone.AccessToken, nil); w.Code != http.StatusOK {
+		t.Fatalf("Expected logout to succeed, got %d", w.Code)
+	}
+	if status, _ := refreshStatus(server, phone.RefreshToken); status != http.StatusUnauthorized {
+		t.Errorf("Expected the logged out session to be ended, got %d", status)
+	}
+	if sessions := server.redis.(*MockRedis).refreshTokens[laptop.User.ID]; len(sessions) != 2 {
+		t.Errorf("Expected the other sessions to remain, got %d", len(sessions))
+	}
+}
+
+func TestLogoutAllRevokesEverySession(t *testing.T) {
+	server := setupTestServer()
+	registered := registerTestUser(t, server, "everywhere@example.com")
+	laptop := loginTestUser(t, server, "everywhere@example.com", "Password123")
+	phone := loginTestUser(t, server, "everywhere@example.com", "Password123")
+
+	w := authenticatedRequest(server, "POST", "/auth/logout-all", laptop.AccessToken, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response["sessions_revoked"] != float64(3) {
+		t.Errorf("Expected 3 sessions revoked, got %v", response["sessions_revoked"])
+	}
+
+	for name, auth := range map[string]AuthResponse{"registration": registered, "laptop": laptop, "phone": phone} {
+		if status, _ := refreshStatus(server, auth.RefreshToken); status != http.StatusUnauthorized {
+			t.Errorf("Expected the %s refresh token to be rejected, got %d", name, status)
+		}
+		if w := authenticatedRequest(server, "GET", "/auth/me", auth.AccessToken, nil); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected the %s access token to be rejected, got %d", name, w.Code)
+		}
+	}
+}
+
+func TestLogoutAllRequiresValidToken(t *testing.T) {
+	server := setupTestServer()
+
+	if w := authenticatedRequest(server, "POST", "/auth/logout-all", "not-a-token", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401, got %d", w.Code)
+	}
+}
+
+func TestChangePasswordSignsOutEverywhere(t *testing.T) {
+	server := setupTestServer()
+	registerTestUser(t, server, "changer@example.com")
+	laptop := loginTestUser(t, server, "changer@example.com", "Password123")
+	phone := loginTestUser(t, server, "changer@example.com", "Password123")
+
+	w := authenticatedRequest(server, "POST", "/auth/change-password", laptop.AccessToken, ChangePasswordRequest{
+		CurrentPassword: "Password123",
+		NewPassword:     "NewPassword456",
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response["sessions_revoked"] != float64(3) {
+		t.Errorf("Expected 3 sessions revoked, got %v", response["sessions_revoked"])
+	}
+
+	// Every device, including the one that changed the password, must log in again
+	for name, auth := range map[string]AuthResponse{"laptop": laptop, "phone": phone} {
+		if status, _ := refreshStatus(server, auth.RefreshToken); status != http.StatusUnauthorized {
+			t.Errorf("Expected the %s refresh token to be rejected, got %d", name, status)
+		}
+		if w := authenticatedRequest(server, "GET", "/auth/me", auth.AccessToken, nil); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected the %s access token to be rejected, got %d", name, w.Code)
+		}
+	}
+}
+
+func TestReusedRefreshTokenRevokesSession(t *testing.T) {
+	server := setupTestServer()
+	registerTestUser(t, server, "reuse@example.com")
+	auth := loginTestUser(t, server, "reuse@example.com", "Password123")
+
+	// Simulate the session having since rotated to a newer token
+	mock := server.redis.(*MockRedis)
+	claims, _ := server.jwtService.ValidateRefreshToken(auth.RefreshToken)
+	mock.refreshTokens[auth.User.ID][claims.SessionID] = "rotated-token"
+
+	if status, _ := refreshStatus(server, auth.RefreshToken); status != http.StatusUnauthorized {
+		t.Fatalf("Expected the rotated-away token to be rejected, got %d", status)
+	}
+	if _, ok := mock.refreshTokens[auth.User.ID][claims.SessionID]; ok {
+		t.Error("Expected reuse of a rotated token to end the session")
+	}
+}
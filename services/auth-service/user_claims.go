@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"autolytiq/shared/tracing"
+)
+
+// UserClaims are the authorization claims user-service holds for a user
+type UserClaims struct {
+	Role         string
+	DealershipID string
+	Scopes       []string
+
+	// Degraded is set when user-service could not be reached and the claims
+	// fall back to what auth-service stores
+	Degraded bool
+}
+
+// UserClaimsProvider looks up a user's current authorization claims. When
+// fresh is set, any cached claims are bypassed.
+type UserClaimsProvider interface {
+	GetUserClaims(ctx context.Context, userID, dealershipID string, fresh bool) (*UserClaims, error)
+}
+
+// userServicePermissions is the response of user-service's
+// GET /users/{id}/permissions
+type userServicePermissions struct {
+	Role        string `json:"role"`
+	Permissions []struct {
+		Resource string `json:"resource"`
+		Action   string `json:"action"`
+	} `json:"permissions"`
+}
+
+type cachedUserClaims struct {
+	claims    *UserClaims
+	expiresAt time.Time
+}
+
+// UserServiceClaimsProvider fetches claims from user-service and caches them
+// in memory
+type UserServiceClaimsProvider struct {
+	baseURL    string
+	httpClient *http.Client
+	cacheTTL   time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedUserClaims
+}
+
+// NewUserServiceClaimsProvider creates a claims provider backed by user-service
+func NewUserServiceClaimsProvider(baseURL string, timeout, cacheTTL time.Duration) *UserServiceClaimsProvider {
+	return &UserServiceClaimsProvider{
+		baseURL:    baseURL,
+		httpClient: &http.Client{Transport: tracing.Transport(nil), Timeout: timeout},
+		cacheTTL:   cacheTTL,
+		cache:      make(map[string]cachedUserClaims),
+	}
+}
+
+// GetUserClaims returns the user's role, dealership and scopes
+func (p *UserServiceClaimsProvider) GetUserClaims(ctx context.Context, userID, dealershipID string, fresh bool) (*UserClaims, error) {
+	key := dealershipID + "/" + userID
+
+	if !fresh {
+		p.mu.Lock()
+		cached, ok := p.cache[key]
+		p.mu.Unlock()
+		if ok && time.Now().Before(cached.expiresAt) {
+			return cached.claims, nil
+		}
+	}
+
+	endpoint := fmt.Sprintf("%s/users/%s/permissions?dealership_id=%s",
+		p.baseURL, url.PathEscape(userID), url.QueryEscape(dealershipID))
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user-service request: %w", err)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call user-service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("user-service returned status %d", resp.StatusCode)
+	}
+
+	var body userServicePermissions
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode user-service response: %w", err)
+	}
+	if body.Role == "" {
+		return nil, fmt.Errorf("user-service returned no role")
+	}
+
+	// Scopes are "resource:action"; the ownership scope of each permission is
+	// enforced by the owning service, not the gateway
+	seen := make(map[string]bool)
+	scopes := []string{}
+	for _, perm := range body.Permissions {
+		scope := perm.Resource + ":" + perm.Action
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	sort.Strings(scopes)
+
+	claims := &UserClaims{
+		Role:         body.Role,
+		DealershipID: dealershipID,
+		Scopes:       scopes,
+	}
+
+	p.mu.Lock()
+	p.cache[key] = cachedUserClaims{claims: claims, expiresAt: time.Now().Add(p.cacheTTL)}
+	p.mu.Unlock()
+
+	return claims, nil
+}
+
+// enrichClaims looks up the user's current claims for a new access token. If
+// user-service cannot be reached the token is issued with the role stored in
+// auth-service, no scopes, and the degraded flag set.
+func (s *Server) enrichClaims(ctx context.Context, user *User, fresh bool) *UserClaims {
+	if s.claims == nil {
+		return nil
+	}
+
+	claims, err := s.claims.GetUserClaims(ctx, user.ID, user.DealershipID, fresh)
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).WithField("user_id", user.ID).Warn("Failed to enrich token claims, issuing minimal token")
+		return &UserClaims{Role: user.Role, DealershipID: user.DealershipID, Degraded: true}
+	}
+
+	return claims
+}
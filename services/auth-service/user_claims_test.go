@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeUserService serves GET /users/{id}/permissions with a settable role
+type fakeUserService struct {
+	mu    sync.Mutex
+	role  string
+	calls int
+}
+
+func (f *fakeUserService) setRole(role string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.role = role
+}
+
+func (f *fakeUserService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+
+	permissions := []map[string]string{
+		{"resource": "deals", "action": "read", "scope": "own"},
+		{"resource": "deals", "action": "read", "scope": "dealership"},
+	}
+	if f.role == "manager" {
+		permissions = append(permissions, map[string]string{"resource": "inventory", "action": "pricing", "scope": "dealership"})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"role":        f.role,
+		"permissions": permissions,
+	})
+}
+
+// registerAndLogin registers a user and logs in, returning the login response
+func registerAndLogin(t *testing.T, server *Server, email string) AuthResponse {
+	t.Helper()
+
+	jsonBody, _ := json.Marshal(RegisterRequest{
+		Email:        email,
+		Password:     "Password123",
+		DealershipID: "11111111-1111-1111-1111-111111111111",
+	})
+	req := httptest.NewRequest("POST", "/auth/register", bytes.NewBuffer(jsonBody))
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Register failed: %d: %s", w.Code, w.Body.String())
+	}
+
+	jsonBody, _ = json.Marshal(LoginRequest{Email: email, Password: "Password123"})
+	req = httptest.NewRequest("POST", "/auth/login", bytes.NewBuffer(jsonBody))
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Login failed: %d: %s", w.Code, w.Body.String())
+	}
+
+	var response AuthResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return response
+}
+
+func accessTokenClaims(t *testing.T, server *Server, token string) *AccessTokenClaims {
+	t.Helper()
+	claims, err := server.jwtService.ValidateAccessToken(token)
+	if err != nil {
+		t.Fatalf("Invalid access token: %v", err)
+	}
+	return claims
+}
+
+func TestLoginEnrichesClaims(t *testing.T) {
+	users := &fakeUserService{role: "salesperson"}
+	userService := httptest.NewServer(users)
+	defer userService.Close()
+
+	server := setupTestServer()
+	server.claims = NewUserServiceClaimsProvider(userService.URL, time.Second, time.Minute)
+
+	response := registerAndLogin(t, server, "enrich@example.com")
+	claims := accessTokenClaims(t, server, response.AccessToken)
+
+	if claims.Role != "salesperson" {
+		t.Errorf("Expected role salesperson, got %s", claims.Role)
+	}
+	if claims.DealershipID != "11111111-1111-1111-1111-111111111111" {
+		t.Errorf("Expected dealership to be set, got %s", claims.DealershipID)
+	}
+	if !reflect.DeepEqual(claims.Scopes, []string{"deals:read"}) {
+		t.Errorf("Expected scopes [deals:read], got %v", claims.Scopes)
+	}
+	if claims.ClaimsDegraded {
+		t.Error("Expected claims not to be degraded")
+	}
+	if response.User.Role != "salesperson" {
+		t.Errorf("Expected response role salesperson, got %s", response.User.Role)
+	}
+}
+
+func TestRefreshReflectsRoleChange(t *testing.T) {
+	users := &fakeUserService{role: "salesperson"}
+	userService := httptest.NewServer(users)
+	defer userService.Close()
+
+	server := setupTestServer()
+	server.claims = NewUserServiceClaimsProvider(userService.URL, time.Second, time.Hour)
+
+	response := registerAndLogin(t, server, "promoted@example.com")
+	users.setRole("manager")
+
+	jsonBody, _ := json.Marshal(RefreshRequest{RefreshToken: response.RefreshToken})
+	req := httptest.NewRequest("POST", "/auth/refresh", bytes.NewBuffer(jsonBody))
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Refresh failed: %d: %s", w.Code, w.Body.String())
+	}
+
+	var refreshed AuthResponse
+	json.Unmarshal(w.Body.Bytes(), &refreshed)
+	claims := accessTokenClaims(t, server, refreshed.AccessToken)
+
+	// Refresh bypasses the cache even though the cached claims have not expired
+	if claims.Role != "manager" {
+		t.Errorf("Expected role manager after refresh, got %s", claims.Role)
+	}
+	if !reflect.DeepEqual(claims.Scopes, []string{"deals:read", "inventory:pricing"}) {
+		t.Errorf("Expected manager scopes, got %v", claims.Scopes)
+	}
+}
+
+func TestLoginUsesCachedClaims(t *testing.T) {
+	users := &fakeUserService{role: "salesperson"}
+	userService := httptest.NewServer(users)
+	defer userService.Close()
+
+	provider := NewUserServiceClaimsProvider(userService.URL, time.Second, time.Hour)
+	for i := 0; i < 3; i++ {
+		if _, err := provider.GetUserClaims(context.Background(), "user-1", "dealer-1", false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if users.calls != 1 {
+		t.Errorf("Expected 1 user-service call with caching, got %d", users.calls)
+	}
+
+	if _, err := provider.GetUserClaims(context.Background(), "user-1", "dealer-1", true); err != nil {
+		t.Fatal(err)
+	}
+	if users.calls != 2 {
+		t.Errorf("Expected fresh lookup to call user-service, got %d calls", users.calls)
+	}
+}
+
+func TestLoginWithUserServiceDown(t *testing.T) {
+	userService := httptest.NewServer(http.NotFoundHandler())
+	userService.Close()
+
+	server := setupTestServer()
+	server.claims = NewUserServiceClaimsProvider(userService.URL, time.Second, time.Minute)
+
+	response := registerAndLogin(t, server, "degraded@example.com")
+	claims := accessTokenClaims(t, server, response.AccessToken)
+
+	if !claims.ClaimsDegraded {
+		t.Error("Expected claims to be marked degraded")
+	}
+	if len(claims.Scopes) != 0 {
+		t.Errorf("Expected no scopes on a degraded token, got %v", claims.Scopes)
+	}
+	if claims.UserID == "" || claims.Role == "" {
+		t.Errorf("Expected minimal claims to keep user and stored role, got %+v", claims)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"regexp"
 	"strings"
 	"unicode"
+
+	"autolytiq/shared/bodylimit"
 )
 
 // ValidationError represents a single validation error
@@ -122,6 +124,7 @@ func (r *RegisterRequest) Sanitize() {
 	r.FirstName = strings.TrimSpace(r.FirstName)
 	r.LastName = strings.TrimSpace(r.LastName)
 	r.DealershipID = strings.TrimSpace(r.DealershipID)
+	r.InviteToken = strings.TrimSpace(r.InviteToken)
 }
 
 // Validate validates LoginRequest
@@ -302,6 +305,77 @@ func (r *VerifyEmailRequest) Sanitize() {
 	r.Token = strings.TrimSpace(r.Token)
 }
 
+// Validate validates CreateInviteRequest
+func (r *CreateInviteRequest) Validate() *ValidationErrors {
+	var errors []ValidationError
+
+	if r.Email == "" {
+		errors = append(errors, ValidationError{
+			Field:   "email",
+			Message: "Email is required",
+		})
+	} else if !isValidEmail(r.Email) {
+		errors = append(errors, ValidationError{
+			Field:   "email",
+			Message: "Must be a valid email address",
+		})
+	}
+
+	if !assignableRoles[r.Role] {
+		errors = append(errors, ValidationError{
+			Field:   "role",
+			Message: "Invalid role",
+		})
+	}
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize sanitizes CreateInviteRequest
+func (r *CreateInviteRequest) Sanitize() {
+	r.Email = strings.TrimSpace(strings.ToLower(r.Email))
+	r.Role = strings.TrimSpace(strings.ToUpper(r.Role))
+	if r.Role == "" {
+		r.Role = "SALESPERSON"
+	}
+}
+
+// Validate validates CreateUserRequest
+func (r *CreateUserRequest) Validate() *ValidationErrors {
+	// The account fields follow the same rules as self-registration
+	register := RegisterRequest{Email: r.Email, Password: r.Password, FirstName: r.FirstName, LastName: r.LastName}
+	var errors []ValidationError
+	if errs := register.Validate(); errs != nil {
+		errors = errs.Errors
+	}
+
+	if !assignableRoles[r.Role] {
+		errors = append(errors, ValidationError{
+			Field:   "role",
+			Message: "Invalid role",
+		})
+	}
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize sanitizes CreateUserRequest
+func (r *CreateUserRequest) Sanitize() {
+	r.Email = strings.TrimSpace(strings.ToLower(r.Email))
+	r.FirstName = strings.TrimSpace(r.FirstName)
+	r.LastName = strings.TrimSpace(r.LastName)
+	r.Role = strings.TrimSpace(strings.ToUpper(r.Role))
+	if r.Role == "" {
+		r.Role = "SALESPERSON"
+	}
+}
+
 // =============================================================================
 // Validation Helpers
 // =============================================================================
@@ -340,6 +414,10 @@ func isStrongPassword(password string) bool {
 // decodeAndValidate decodes JSON and validates the request
 func decodeAndValidate(r *http.Request, w http.ResponseWriter, dst interface{}) bool {
 	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		if bodylimit.IsTooLarge(err) {
+			bodylimit.RespondTooLarge(w)
+			return false
+		}
 		respondError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
 		return false
 	}
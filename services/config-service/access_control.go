@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Caller identity headers, set by the API gateway from the JWT
+const (
+	HeaderUserID   = "X-User-ID"
+	HeaderUserRole = "X-User-Role"
+)
+
+// Role groups used by the default access policy. Roles are compared
+// case-insensitively since services disagree on casing.
+var (
+	adminRoles   = []string{"admin", "super_admin", "owner"}
+	managerRoles = append([]string{"manager", "general_manager", "sales_manager"}, adminRoles...)
+	financeRoles = append([]string{"finance", "f_and_i"}, managerRoles...)
+)
+
+// CategoryAccess lists the roles that may read and write a category's
+// settings. An empty list allows any role.
+type CategoryAccess struct {
+	Read  []string `json:"read"`
+	Write []string `json:"write"`
+}
+
+// AccessPolicy maps each setting category to its access rules. Categories
+// missing from the policy are admin-only.
+type AccessPolicy map[string]CategoryAccess
+
+// defaultAccessPolicy lets everyone read what the desk needs day to day and
+// keeps dealership-wide and financing changes with admins
+var defaultAccessPolicy = AccessPolicy{
+	"dealership":    {Write: adminRoles},
+	"sales":         {Write: managerRoles},
+	"financing":     {Read: financeRoles, Write: adminRoles},
+	"notifications": {Write: managerRoles},
+	"ui":            {},
+}
+
+// parseAccessPolicy reads a JSON policy, such as from CONFIG_ACCESS_POLICY.
+// Categories it leaves out keep their default rules.
+func parseAccessPolicy(raw string) (AccessPolicy, error) {
+	policy := AccessPolicy{}
+	for category, access := range defaultAccessPolicy {
+		policy[category] = access
+	}
+	if strings.TrimSpace(raw) == "" {
+		return policy, nil
+	}
+
+	var overrides AccessPolicy
+	if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+		return nil, fmt.Errorf("invalid access policy: %w", err)
+	}
+	for category, access := range overrides {
+		if !validCategories[category] {
+			return nil, fmt.Errorf("invalid access policy: unknown category %q", category)
+		}
+		policy[category] = access
+	}
+	return policy, nil
+}
+
+// caller is who a request is made on behalf of
+type caller struct {
+	internal bool
+	role     string
+}
+
+// requestCaller identifies the caller. Requests from users come through the
+// gateway, which always forwards their identity; requests with none are
+// service-to-service calls and are not restricted.
+func requestCaller(r *http.Request) caller {
+	userID := r.Header.Get(HeaderUserID)
+	role := strings.ToLower(strings.TrimSpace(r.Header.Get(HeaderUserRole)))
+	return caller{internal: userID == "" && role == "", role: role}
+}
+
+func roleAllowed(role string, allowed []string) bool {
+	for _, a := range allowed {
+		if strings.EqualFold(role, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// canAccess reports whether the caller may read, or write, settings in the
+// category
+func (s *Server) canAccess(c caller, category string, write bool) bool {
+	if c.internal {
+		return true
+	}
+	if c.role == "" {
+		return false
+	}
+
+	policy := s.accessPolicy
+	if policy == nil {
+		policy = defaultAccessPolicy
+	}
+	access, ok := policy[category]
+	if !ok {
+		return roleAllowed(c.role, adminRoles)
+	}
+
+	allowed := access.Read
+	if write {
+		allowed = access.Write
+	}
+	return len(allowed) == 0 || roleAllowed(c.role, allowed)
+}
+
+// requireAccess responds 403 and returns false if the caller may not read,
+// or write, settings in the category
+func (s *Server) requireAccess(w http.ResponseWriter, r *http.Request, category string, write bool) bool {
+	c := requestCaller(r)
+	if s.canAccess(c, category, write) {
+		return true
+	}
+
+	action := "read"
+	if write {
+		action = "write"
+	}
+	s.logger.WithContext(r.Context()).
+		WithField("role", c.role).
+		WithField("category", category).
+		Warn("Config access denied")
+	respondError(w, http.StatusForbidden, fmt.Sprintf("role %q cannot %s %s settings", c.role, action, category))
+	return false
+}
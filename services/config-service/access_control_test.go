@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// makeUserRequest makes a request as a gateway-authenticated user with the role
+func makeUserRequest(t *testing.T, server *Server, method, path string, body interface{}, dealershipID, role string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var bodyBytes []byte
+	if body != nil {
+		var err error
+		if bodyBytes, err = json.Marshal(body); err != nil {
+			t.Fatalf("Failed to marshal request body: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(method, path, bytes.NewBuffer(bodyBytes))
+	req.Header.Set("X-Dealership-ID", dealershipID)
+	req.Header.Set(HeaderUserID, "user-1")
+	req.Header.Set(HeaderUserRole, role)
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestFinancingSettingWriteRequiresAdmin(t *testing.T) {
+	db := NewMockDatabase()
+	server := NewServer(db, testLogger())
+	dealershipID := "dealer-1"
+
+	setReq := SetSettingRequest{Value: "4.9", Type: "string", Category: "financing"}
+
+	rr := makeUserRequest(t, server, "PUT", "/config/settings/default_apr", setReq, dealershipID, "salesperson")
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403 for a salesperson, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if config, _ := db.GetConfig(dealershipID, "", "default_apr"); config != nil {
+		t.Error("Expected the rejected setting not to be saved")
+	}
+
+	rr = makeUserRequest(t, server, "PUT", "/config/settings/default_apr", setReq, dealershipID, "admin")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for an admin, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Nor can a salesperson delete it, or move it into a category they can write
+	rr = makeUserRequest(t, server, "DELETE", "/config/settings/default_apr", nil, dealershipID, "salesperson")
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 deleting as a salesperson, got %d", rr.Code)
+	}
+	moved := SetSettingRequest{Value: "0", Type: "string", Category: "ui"}
+	rr = makeUserRequest(t, server, "PUT", "/config/settings/default_apr", moved, dealershipID, "salesperson")
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 moving the setting as a salesperson, got %d", rr.Code)
+	}
+}
+
+func TestFinancingSettingReadRestricted(t *testing.T) {
+	db := NewMockDatabase()
+	server := NewServer(db, testLogger())
+	dealershipID := "dealer-1"
+	db.SetConfig(dealershipID, "", "default_apr", "4.9", "string", "financing", "")
+	db.SetConfig(dealershipID, "", "theme", "dark", "string", "ui", "")
+
+	for role, want := range map[string]int{"salesperson": http.StatusForbidden, "F_AND_I": http.StatusOK, "manager": http.StatusOK} {
+		rr := makeUserRequest(t, server, "GET", "/config/settings/default_apr", nil, dealershipID, role)
+		if rr.Code != want {
+			t.Errorf("Expected %d reading financing as %s, got %d", want, role, rr.Code)
+		}
+	}
+
+	rr := makeUserRequest(t, server, "GET", "/config/categories/financing", nil, dealershipID, "salesperson")
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 reading the financing category as a salesperson, got %d", rr.Code)
+	}
+
+	// All settings leaves out what the caller can't read
+	rr = makeUserRequest(t, server, "GET", "/config/settings", nil, dealershipID, "salesperson")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+	var all map[string][]DealershipConfig
+	json.Unmarshal(rr.Body.Bytes(), &all)
+	if _, ok := all["financing"]; ok {
+		t.Error("Expected financing settings to be left out for a salesperson")
+	}
+	if len(all["ui"]) != 1 {
+		t.Errorf("Expected the ui setting, got %v", all)
+	}
+}
+
+func TestSettingAccessWithoutRole(t *testing.T) {
+	db := NewMockDatabase()
+	server := NewServer(db, testLogger())
+	dealershipID := "dealer-1"
+	setReq := SetSettingRequest{Value: "true", Type: "boolean", Category: "sales"}
+
+	// A user whose token carries no role can't write restricted categories
+	rr := makeUserRequest(t, server, "PUT", "/config/settings/allow_discounts", setReq, dealershipID, "")
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without a role, got %d", rr.Code)
+	}
+
+	// Service-to-service calls carry no user and are not restricted
+	rr = makeRequest(t, server, "PUT", "/config/settings/allow_discounts", setReq, dealershipID)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 for an internal call, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestParseAccessPolicy(t *testing.T) {
+	policy, err := parseAccessPolicy(`{"ui": {"write": ["manager"]}}`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(policy["ui"].Write) != 1 || len(policy["financing"].Write) == 0 {
+		t.Errorf("Expected ui overridden and financing kept, got %+v", policy)
+	}
+
+	if _, err := parseAccessPolicy(`{"payroll": {}}`); err == nil {
+		t.Error("Expected an error for an unknown category")
+	}
+	if _, err := parseAccessPolicy(`not json`); err == nil {
+		t.Error("Expected an error for invalid JSON")
+	}
+}
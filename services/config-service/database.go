@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -41,6 +42,11 @@ func (p *PostgresConfigDB) Close() error {
 	return p.db.Close()
 }
 
+// Ping checks the database is reachable
+func (p *PostgresConfigDB) Ping(ctx context.Context) error {
+	return p.db.PingContext(ctx)
+}
+
 // InitSchema creates all required tables and indexes
 func (p *PostgresConfigDB) InitSchema() error {
 	schema := `
@@ -54,12 +60,33 @@ func (p *PostgresConfigDB) InitSchema() error {
 			description TEXT,
 			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			PRIMARY KEY (dealership_id, key)
+			environment VARCHAR(50) NOT NULL DEFAULT '',
+			PRIMARY KEY (dealership_id, key, environment)
 		);
 
+		-- Settings created before environments existed become the defaults
+		DO $$
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM information_schema.columns
+				WHERE table_name = 'dealership_config' AND column_name = 'environment'
+			) THEN
+				ALTER TABLE dealership_config ADD COLUMN environment VARCHAR(50) NOT NULL DEFAULT '';
+				ALTER TABLE dealership_config DROP CONSTRAINT dealership_config_pkey;
+				ALTER TABLE dealership_config ADD PRIMARY KEY (dealership_id, key, environment);
+			END IF;
+		END $$;
+
 		CREATE INDEX IF NOT EXISTS idx_config_dealership ON dealership_config(dealership_id);
 		CREATE INDEX IF NOT EXISTS idx_config_category ON dealership_config(dealership_id, category);
 
+		-- Per-dealership config version, bumped on every change so clients can invalidate caches
+		CREATE TABLE IF NOT EXISTS dealership_config_version (
+			dealership_id VARCHAR(255) PRIMARY KEY,
+			version BIGINT NOT NULL DEFAULT 0,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
 		-- Feature flags
 		CREATE TABLE IF NOT EXISTS feature_flags (
 			id VARCHAR(36) PRIMARY KEY,
@@ -100,12 +127,12 @@ func (p *PostgresConfigDB) InitSchema() error {
 	return nil
 }
 
-// SetConfig creates or updates a configuration setting
-func (p *PostgresConfigDB) SetConfig(dealershipID, key, value, configType, category, description string) error {
+// SetConfig creates or updates a configuration setting in an environment
+func (p *PostgresConfigDB) SetConfig(dealershipID, environment, key, value, configType, category, description string) error {
 	query := `
-		INSERT INTO dealership_config (dealership_id, key, value, type, category, description, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
-		ON CONFLICT (dealership_id, key)
+		INSERT INTO dealership_config (dealership_id, key, value, type, category, description, created_at, updated_at, environment)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7, $8)
+		ON CONFLICT (dealership_id, key, environment)
 		DO UPDATE SET
 			value = EXCLUDED.value,
 			type = EXCLUDED.type,
@@ -114,25 +141,72 @@ func (p *PostgresConfigDB) SetConfig(dealershipID, key, value, configType, categ
 			updated_at = EXCLUDED.updated_at
 	`
 
-	now := time.Now()
-	_, err := p.db.Exec(query, dealershipID, key, value, configType, category, description, now)
+	tx, err := p.db.Begin()
 	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if _, err := tx.Exec(query, dealershipID, key, value, configType, category, description, now, environment); err != nil {
 		return fmt.Errorf("failed to set config: %w", err)
 	}
 
+	if err := bumpConfigVersion(tx, dealershipID, now); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// bumpConfigVersion increments the dealership's config version within a transaction
+func bumpConfigVersion(tx *sql.Tx, dealershipID string, now time.Time) error {
+	query := `
+		INSERT INTO dealership_config_version (dealership_id, version, updated_at)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (dealership_id)
+		DO UPDATE SET
+			version = dealership_config_version.version + 1,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	if _, err := tx.Exec(query, dealershipID, now); err != nil {
+		return fmt.Errorf("failed to bump config version: %w", err)
+	}
+
 	return nil
 }
 
-// GetConfig retrieves a specific configuration setting
-func (p *PostgresConfigDB) GetConfig(dealershipID, key string) (*DealershipConfig, error) {
+// GetConfigVersion returns the dealership's current config version, or 0 if
+// its configuration has never changed
+func (p *PostgresConfigDB) GetConfigVersion(dealershipID string) (int64, error) {
+	query := `SELECT version FROM dealership_config_version WHERE dealership_id = $1`
+
+	var version int64
+	err := p.db.QueryRow(query, dealershipID).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get config version: %w", err)
+	}
+
+	return version, nil
+}
+
+// GetConfig retrieves a specific configuration setting, preferring the
+// environment's value over the default
+func (p *PostgresConfigDB) GetConfig(dealershipID, environment, key string) (*DealershipConfig, error) {
 	query := `
-		SELECT dealership_id, key, value, type, category, description, created_at, updated_at
+		SELECT dealership_id, key, value, type, category, description, created_at, updated_at, environment
 		FROM dealership_config
-		WHERE dealership_id = $1 AND key = $2
+		WHERE dealership_id = $1 AND key = $2 AND environment IN ('', $3)
+		ORDER BY environment DESC
+		LIMIT 1
 	`
 
 	var config DealershipConfig
-	err := p.db.QueryRow(query, dealershipID, key).Scan(
+	err := p.db.QueryRow(query, dealershipID, key, environment).Scan(
 		&config.DealershipID,
 		&config.Key,
 		&config.Value,
@@ -141,6 +215,7 @@ func (p *PostgresConfigDB) GetConfig(dealershipID, key string) (*DealershipConfi
 		&config.Description,
 		&config.CreatedAt,
 		&config.UpdatedAt,
+		&config.Environment,
 	)
 
 	if err == sql.ErrNoRows {
@@ -153,16 +228,17 @@ func (p *PostgresConfigDB) GetConfig(dealershipID, key string) (*DealershipConfi
 	return &config, nil
 }
 
-// GetConfigsByCategory retrieves all configuration settings for a category
-func (p *PostgresConfigDB) GetConfigsByCategory(dealershipID, category string) ([]DealershipConfig, error) {
+// GetConfigsByCategory retrieves all configuration settings for a category,
+// with each key's environment value in place of its default
+func (p *PostgresConfigDB) GetConfigsByCategory(dealershipID, environment, category string) ([]DealershipConfig, error) {
 	query := `
-		SELECT dealership_id, key, value, type, category, description, created_at, updated_at
+		SELECT DISTINCT ON (key) dealership_id, key, value, type, category, description, created_at, updated_at, environment
 		FROM dealership_config
-		WHERE dealership_id = $1 AND category = $2
-		ORDER BY key
+		WHERE dealership_id = $1 AND category = $2 AND environment IN ('', $3)
+		ORDER BY key, environment DESC
 	`
 
-	rows, err := p.db.Query(query, dealershipID, category)
+	rows, err := p.db.Query(query, dealershipID, category, environment)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get configs by category: %w", err)
 	}
@@ -180,6 +256,7 @@ func (p *PostgresConfigDB) GetConfigsByCategory(dealershipID, category string) (
 			&config.Description,
 			&config.CreatedAt,
 			&config.UpdatedAt,
+			&config.Environment,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan config: %w", err)
@@ -194,11 +271,17 @@ func (p *PostgresConfigDB) GetConfigsByCategory(dealershipID, category string) (
 	return configs, nil
 }
 
-// DeleteConfig deletes a configuration setting
-func (p *PostgresConfigDB) DeleteConfig(dealershipID, key string) error {
-	query := `DELETE FROM dealership_config WHERE dealership_id = $1 AND key = $2`
+// DeleteConfig deletes a configuration setting from an environment
+func (p *PostgresConfigDB) DeleteConfig(dealershipID, environment, key string) error {
+	query := `DELETE FROM dealership_config WHERE dealership_id = $1 AND key = $2 AND environment = $3`
 
-	result, err := p.db.Exec(query, dealershipID, key)
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(query, dealershipID, key, environment)
 	if err != nil {
 		return fmt.Errorf("failed to delete config: %w", err)
 	}
@@ -212,7 +295,11 @@ func (p *PostgresConfigDB) DeleteConfig(dealershipID, key string) error {
 		return fmt.Errorf("config not found")
 	}
 
-	return nil
+	if err := bumpConfigVersion(tx, dealershipID, time.Now()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // CreateFeatureFlag creates a new feature flag
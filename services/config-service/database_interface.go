@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"time"
 )
@@ -15,6 +16,10 @@ type DealershipConfig struct {
 	Description  string    `json:"description"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
+
+	// Environment is the environment the value is set for, or empty for the
+	// default value shared by all environments
+	Environment string `json:"environment,omitempty"`
 }
 
 // FeatureFlag represents a feature flag with rollout controls
@@ -51,11 +56,17 @@ type ConfigDatabase interface {
 	Close() error
 	InitSchema() error
 
-	// Configuration settings
-	SetConfig(dealershipID, key, value, configType, category, description string) error
-	GetConfig(dealershipID, key string) (*DealershipConfig, error)
-	GetConfigsByCategory(dealershipID, category string) ([]DealershipConfig, error)
-	DeleteConfig(dealershipID, key string) error
+	// Ping checks the database is reachable, for health checks
+	Ping(ctx context.Context) error
+
+	// Configuration settings. An empty environment addresses the default
+	// values; reads in a named environment return its value for each key,
+	// falling back to the default value.
+	SetConfig(dealershipID, environment, key, value, configType, category, description string) error
+	GetConfig(dealershipID, environment, key string) (*DealershipConfig, error)
+	GetConfigsByCategory(dealershipID, environment, category string) ([]DealershipConfig, error)
+	DeleteConfig(dealershipID, environment, key string) error
+	GetConfigVersion(dealershipID string) (int64, error)
 
 	// Feature flags
 	CreateFeatureFlag(flagKey string, enabled bool, rolloutPercentage int, constraintsJSON json.RawMessage, description string) (*FeatureFlag, error)
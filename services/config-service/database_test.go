@@ -65,13 +65,13 @@ func TestDatabaseSetAndGetConfig(t *testing.T) {
 	description := "Test description"
 
 	// Set config
-	err := db.SetConfig(dealershipID, key, value, configType, category, description)
+	err := db.SetConfig(dealershipID, "", key, value, configType, category, description)
 	if err != nil {
 		t.Fatalf("Failed to set config: %v", err)
 	}
 
 	// Get config
-	config, err := db.GetConfig(dealershipID, key)
+	config, err := db.GetConfig(dealershipID, "", key)
 	if err != nil {
 		t.Fatalf("Failed to get config: %v", err)
 	}
@@ -105,13 +105,13 @@ func TestDatabaseUpdateConfig(t *testing.T) {
 	key := "test_key"
 
 	// Set initial config
-	db.SetConfig(dealershipID, key, "value1", "string", "dealership", "desc1")
+	db.SetConfig(dealershipID, "", key, "value1", "string", "dealership", "desc1")
 
 	// Update config
-	db.SetConfig(dealershipID, key, "value2", "string", "dealership", "desc2")
+	db.SetConfig(dealershipID, "", key, "value2", "string", "dealership", "desc2")
 
 	// Get config
-	config, _ := db.GetConfig(dealershipID, key)
+	config, _ := db.GetConfig(dealershipID, "", key)
 
 	if config.Value != "value2" {
 		t.Errorf("Value not updated: got %v want %v", config.Value, "value2")
@@ -125,7 +125,7 @@ func TestDatabaseGetConfigNotFound(t *testing.T) {
 	db := getTestDB(t)
 	defer db.Close()
 
-	config, err := db.GetConfig("dealer-nonexistent", "nonexistent_key")
+	config, err := db.GetConfig("dealer-nonexistent", "", "nonexistent_key")
 	if err != nil {
 		t.Fatalf("Should not error on not found: %v", err)
 	}
@@ -142,12 +142,12 @@ func TestDatabaseGetConfigsByCategory(t *testing.T) {
 	dealershipID := "dealer-test-1"
 
 	// Set multiple configs
-	db.SetConfig(dealershipID, "key1", "value1", "string", "dealership", "desc1")
-	db.SetConfig(dealershipID, "key2", "value2", "string", "dealership", "desc2")
-	db.SetConfig(dealershipID, "key3", "value3", "string", "sales", "desc3")
+	db.SetConfig(dealershipID, "", "key1", "value1", "string", "dealership", "desc1")
+	db.SetConfig(dealershipID, "", "key2", "value2", "string", "dealership", "desc2")
+	db.SetConfig(dealershipID, "", "key3", "value3", "string", "sales", "desc3")
 
 	// Get configs by category
-	configs, err := db.GetConfigsByCategory(dealershipID, "dealership")
+	configs, err := db.GetConfigsByCategory(dealershipID, "", "dealership")
 	if err != nil {
 		t.Fatalf("Failed to get configs by category: %v", err)
 	}
@@ -165,16 +165,16 @@ func TestDatabaseDeleteConfig(t *testing.T) {
 	key := "test_key"
 
 	// Set config
-	db.SetConfig(dealershipID, key, "value", "string", "dealership", "desc")
+	db.SetConfig(dealershipID, "", key, "value", "string", "dealership", "desc")
 
 	// Delete config
-	err := db.DeleteConfig(dealershipID, key)
+	err := db.DeleteConfig(dealershipID, "", key)
 	if err != nil {
 		t.Fatalf("Failed to delete config: %v", err)
 	}
 
 	// Verify it's gone
-	config, _ := db.GetConfig(dealershipID, key)
+	config, _ := db.GetConfig(dealershipID, "", key)
 	if config != nil {
 		t.Error("Config should be deleted")
 	}
@@ -184,7 +184,7 @@ func TestDatabaseDeleteConfigNotFound(t *testing.T) {
 	db := getTestDB(t)
 	defer db.Close()
 
-	err := db.DeleteConfig("dealer-test-1", "nonexistent_key")
+	err := db.DeleteConfig("dealer-test-1", "", "nonexistent_key")
 	if err == nil {
 		t.Error("Should error when deleting non-existent config")
 	}
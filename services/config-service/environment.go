@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// EnvironmentHeader selects the environment whose settings a request reads
+// and writes
+const EnvironmentHeader = "X-Config-Environment"
+
+// DefaultEnvironment names the shared settings every environment falls back
+// to. They are stored with an empty environment.
+const DefaultEnvironment = "default"
+
+// environmentPattern matches valid environment names
+var environmentPattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,49}$`)
+
+// normalizeEnvironment returns the stored form of an environment name: empty
+// for the default settings
+func normalizeEnvironment(environment string) (string, error) {
+	environment = strings.ToLower(strings.TrimSpace(environment))
+	if environment == "" || environment == DefaultEnvironment {
+		return "", nil
+	}
+	if !environmentPattern.MatchString(environment) {
+		return "", fmt.Errorf("invalid environment %q", environment)
+	}
+	return environment, nil
+}
+
+// requestEnvironment returns the environment a request is scoped to: the
+// X-Config-Environment header when set, otherwise the service's configured
+// environment. Without either, requests use the default settings only.
+func (s *Server) requestEnvironment(r *http.Request) (string, error) {
+	if environment := r.Header.Get(EnvironmentHeader); environment != "" {
+		return normalizeEnvironment(environment)
+	}
+	return normalizeEnvironment(s.environment)
+}
+
+// requireEnvironment resolves the request's environment, responding with 400
+// if it is invalid
+func (s *Server) requireEnvironment(w http.ResponseWriter, r *http.Request) (string, bool) {
+	environment, err := s.requestEnvironment(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return "", false
+	}
+	return environment, true
+}
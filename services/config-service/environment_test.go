@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// makeEnvRequest makes a request scoped to an environment with the
+// X-Config-Environment header
+func makeEnvRequest(t *testing.T, server *Server, method, path string, body interface{}, dealershipID, environment string) *httptest.ResponseRecorder {
+	t.Helper()
+	var bodyBytes []byte
+	if body != nil {
+		bodyBytes, _ = json.Marshal(body)
+	}
+
+	req := httptest.NewRequest(method, path, bytes.NewBuffer(bodyBytes))
+	req.Header.Set("X-Dealership-ID", dealershipID)
+	req.Header.Set("Content-Type", "application/json")
+	if environment != "" {
+		req.Header.Set(EnvironmentHeader, environment)
+	}
+
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func getSettingValue(t *testing.T, rr *httptest.ResponseRecorder) DealershipConfig {
+	t.Helper()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var config DealershipConfig
+	if err := json.Unmarshal(rr.Body.Bytes(), &config); err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestEnvironmentScopedSettingsResolve(t *testing.T) {
+	server := NewServer(NewMockDatabase(), testLogger())
+	dealershipID := "dealer-1"
+
+	set := func(environment, value string) {
+		t.Helper()
+		req := SetSettingRequest{Value: value, Type: "string", Category: "notifications"}
+		if rr := makeEnvRequest(t, server, "PUT", "/config/settings/sms_sender_id", req, dealershipID, environment); rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 setting %s value, got %d: %s", environment, rr.Code, rr.Body.String())
+		}
+	}
+	set("", "AUTOLYTIQ")
+	set("staging", "AUTOLYTIQ-STG")
+
+	// Requests without an environment keep reading the default value
+	if config := getSettingValue(t, makeRequest(t, server, "GET", "/config/settings/sms_sender_id", nil, dealershipID)); config.Value != "AUTOLYTIQ" || config.Environment != "" {
+		t.Errorf("Expected the default value, got %+v", config)
+	}
+
+	config := getSettingValue(t, makeEnvRequest(t, server, "GET", "/config/settings/sms_sender_id", nil, dealershipID, "Staging"))
+	if config.Value != "AUTOLYTIQ-STG" || config.Environment != "staging" {
+		t.Errorf("Expected the staging value, got %+v", config)
+	}
+
+	// An environment without its own value falls back to the default
+	if config := getSettingValue(t, makeEnvRequest(t, server, "GET", "/config/settings/sms_sender_id", nil, dealershipID, "production")); config.Value != "AUTOLYTIQ" {
+		t.Errorf("Expected production to fall back to the default, got %+v", config)
+	}
+
+	// Category reads resolve each key the same way
+	rr := makeEnvRequest(t, server, "GET", "/config/categories/notifications", nil, dealershipID, "staging")
+	var configs []DealershipConfig
+	json.Unmarshal(rr.Body.Bytes(), &configs)
+	if len(configs) != 1 || configs[0].Value != "AUTOLYTIQ-STG" {
+		t.Errorf("Expected one resolved staging setting, got %+v", configs)
+	}
+
+	// Deleting the staging value leaves the default in effect there
+	if rr := makeEnvRequest(t, server, "DELETE", "/config/settings/sms_sender_id", nil, dealershipID, "staging"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 deleting the staging value, got %d", rr.Code)
+	}
+	if config := getSettingValue(t, makeEnvRequest(t, server, "GET", "/config/settings/sms_sender_id", nil, dealershipID, "staging")); config.Value != "AUTOLYTIQ" {
+		t.Errorf("Expected staging to fall back to the default after delete, got %+v", config)
+	}
+}
+
+func TestServiceEnvironmentUsedWithoutHeader(t *testing.T) {
+	db := NewMockDatabase()
+	server := NewServer(db, testLogger())
+	server.environment = "staging"
+	dealershipID := "dealer-1"
+
+	db.SetConfig(dealershipID, "", "dealership_name", "Acme Motors", "string", "dealership", "")
+	db.SetConfig(dealershipID, "staging", "dealership_name", "Acme Motors (staging)", "string", "dealership", "")
+
+	if config := getSettingValue(t, makeRequest(t, server, "GET", "/config/settings/dealership_name", nil, dealershipID)); config.Value != "Acme Motors (staging)" {
+		t.Errorf("Expected the service's environment to apply, got %+v", config)
+	}
+
+	// The header overrides it, and "default" addresses the shared value
+	if config := getSettingValue(t, makeEnvRequest(t, server, "GET", "/config/settings/dealership_name", nil, dealershipID, DefaultEnvironment)); config.Value != "Acme Motors" {
+		t.Errorf("Expected the default value, got %+v", config)
+	}
+
+	if rr := makeEnvRequest(t, server, "GET", "/config/settings/dealership_name", nil, dealershipID, "not valid!"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid environment, got %d", rr.Code)
+	}
+}
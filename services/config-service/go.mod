@@ -3,7 +3,11 @@ module github.com/autolytiq/config-service
 go 1.18
 
 require (
+	autolytiq/shared/bodylimit v0.0.0
+	autolytiq/shared/graceful v0.0.0
+	autolytiq/shared/health v0.0.0
 	autolytiq/shared/logging v0.0.0
+	autolytiq/shared/tracing v0.0.0
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/mux v1.8.1
 	github.com/lib/pq v1.10.9
@@ -11,6 +15,7 @@ require (
 )
 
 require (
+	autolytiq/shared/apierror v0.0.0 // indirect
 	github.com/beorn7/perks v1.0.1 // indirect
 	github.com/cespare/xxhash/v2 v2.2.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
@@ -25,3 +30,13 @@ require (
 )
 
 replace autolytiq/shared/logging => ../shared/logging
+
+replace autolytiq/shared/health => ../shared/health
+
+replace autolytiq/shared/graceful => ../shared/graceful
+
+replace autolytiq/shared/bodylimit => ../shared/bodylimit
+
+replace autolytiq/shared/apierror => ../shared/apierror
+
+replace autolytiq/shared/tracing => ../shared/tracing
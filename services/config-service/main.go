@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
+	"autolytiq/shared/bodylimit"
+	"autolytiq/shared/graceful"
+	"autolytiq/shared/health"
 	"autolytiq/shared/logging"
+	"autolytiq/shared/tracing"
 
 	"github.com/gorilla/mux"
 )
@@ -17,6 +23,15 @@ type Server struct {
 	db     ConfigDatabase
 	router *mux.Router
 	logger *logging.Logger
+	health *health.HealthChecker
+	tracer *tracing.Tracer
+
+	// environment scopes requests that don't name one, e.g. "staging"
+	environment string
+
+	// accessPolicy says which roles can read and write each category;
+	// nil uses defaultAccessPolicy
+	accessPolicy AccessPolicy
 }
 
 // NewServer creates a new config service server
@@ -25,6 +40,11 @@ func NewServer(db ConfigDatabase, logger *logging.Logger) *Server {
 		db:     db,
 		router: mux.NewRouter(),
 		logger: logger,
+		health: health.NewHealthChecker("config-service", db.Ping),
+		tracer: tracing.New(tracing.Config{
+			Service: "config-service",
+			OnError: func(err error) { logger.WithError(err).Warn("Failed to export trace spans") },
+		}),
 	}
 
 	s.setupMiddleware()
@@ -34,14 +54,16 @@ func NewServer(db ConfigDatabase, logger *logging.Logger) *Server {
 
 // setupMiddleware configures middleware
 func (s *Server) setupMiddleware() {
+	s.router.Use(s.tracer.Middleware)
 	s.router.Use(logging.RequestIDMiddleware)
 	s.router.Use(logging.RequestLoggingMiddleware(s.logger))
+	s.router.Use(bodylimit.Middleware(bodylimit.Config{}))
 }
 
 // setupRoutes configures all HTTP routes
 func (s *Server) setupRoutes() {
 	// Health check
-	s.router.HandleFunc("/health", s.handleHealth).Methods("GET")
+	s.router.Handle("/health", s.health).Methods("GET")
 
 	// Configuration settings
 	s.router.HandleFunc("/config/settings", s.handleGetAllSettings).Methods("GET")
@@ -49,6 +71,8 @@ func (s *Server) setupRoutes() {
 	s.router.HandleFunc("/config/settings/{key}", s.handleSetSetting).Methods("PUT")
 	s.router.HandleFunc("/config/settings/{key}", s.handleDeleteSetting).Methods("DELETE")
 	s.router.HandleFunc("/config/categories/{category}", s.handleGetCategory).Methods("GET")
+	s.router.HandleFunc("/config/version", s.handleGetVersion).Methods("GET")
+	s.router.HandleFunc("/config/validate", s.handleValidateConfig).Methods("POST")
 
 	// Feature flags
 	s.router.HandleFunc("/config/features", s.handleCreateFeatureFlag).Methods("POST")
@@ -66,11 +90,6 @@ func (s *Server) setupRoutes() {
 	s.router.HandleFunc("/config/integrations/{id}", s.handleDeleteIntegration).Methods("DELETE")
 }
 
-// handleHealth returns service health status
-func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]string{"status": "healthy"})
-}
-
 // handleGetAllSettings retrieves all settings for a dealership
 func (s *Server) handleGetAllSettings(w http.ResponseWriter, r *http.Request) {
 	dealershipID := r.Header.Get("X-Dealership-ID")
@@ -79,12 +98,21 @@ func (s *Server) handleGetAllSettings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	environment, ok := s.requireEnvironment(w, r)
+	if !ok {
+		return
+	}
+
 	// Get all categories
-	categories := []string{"dealership", "sales", "financing", "notifications", "ui"}
 	allSettings := make(map[string][]DealershipConfig)
 
-	for _, category := range categories {
-		configs, err := s.db.GetConfigsByCategory(dealershipID, category)
+	// Categories the caller can't read are left out
+	caller := requestCaller(r)
+	for _, category := range configCategories {
+		if !s.canAccess(caller, category, false) {
+			continue
+		}
+		configs, err := s.db.GetConfigsByCategory(dealershipID, environment, category)
 		if err != nil {
 			s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get configs by category")
 			respondError(w, http.StatusInternalServerError, err.Error())
@@ -106,10 +134,15 @@ func (s *Server) handleGetSetting(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	environment, ok := s.requireEnvironment(w, r)
+	if !ok {
+		return
+	}
+
 	vars := mux.Vars(r)
 	key := vars["key"]
 
-	config, err := s.db.GetConfig(dealershipID, key)
+	config, err := s.db.GetConfig(dealershipID, environment, key)
 	if err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get config")
 		respondError(w, http.StatusInternalServerError, err.Error())
@@ -121,9 +154,49 @@ func (s *Server) handleGetSetting(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !s.requireAccess(w, r, config.Category, false) {
+		return
+	}
+
+	s.setVersionHeader(w, r, dealershipID)
 	respondJSON(w, http.StatusOK, config)
 }
 
+// ConfigVersionHeader carries the dealership's config version on setting reads
+const ConfigVersionHeader = "X-Config-Version"
+
+// setVersionHeader adds the dealership's config version to the response so
+// clients can detect changes. Failures are logged and the header omitted.
+func (s *Server) setVersionHeader(w http.ResponseWriter, r *http.Request, dealershipID string) {
+	version, err := s.db.GetConfigVersion(dealershipID)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Warn("Failed to get config version")
+		return
+	}
+	w.Header().Set(ConfigVersionHeader, strconv.FormatInt(version, 10))
+}
+
+// handleGetVersion returns the dealership's current config version
+func (s *Server) handleGetVersion(w http.ResponseWriter, r *http.Request) {
+	dealershipID := r.Header.Get("X-Dealership-ID")
+	if dealershipID == "" {
+		respondError(w, http.StatusBadRequest, "X-Dealership-ID header required")
+		return
+	}
+
+	version, err := s.db.GetConfigVersion(dealershipID)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get config version")
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"dealership_id": dealershipID,
+		"version":       version,
+	})
+}
+
 // SetSettingRequest represents a request to set a configuration value
 type SetSettingRequest struct {
 	Value       string `json:"value"`
@@ -132,7 +205,8 @@ type SetSettingRequest struct {
 	Description string `json:"description"`
 }
 
-// handleSetSetting creates or updates a setting
+// handleSetSetting creates or updates a setting in the request's
+// environment
 func (s *Server) handleSetSetting(w http.ResponseWriter, r *http.Request) {
 	dealershipID := r.Header.Get("X-Dealership-ID")
 	if dealershipID == "" {
@@ -140,6 +214,11 @@ func (s *Server) handleSetSetting(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	environment, ok := s.requireEnvironment(w, r)
+	if !ok {
+		return
+	}
+
 	vars := mux.Vars(r)
 	key := vars["key"]
 
@@ -163,25 +242,46 @@ func (s *Server) handleSetSetting(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := s.db.SetConfig(dealershipID, key, req.Value, req.Type, req.Category, req.Description)
+	// The caller needs write access to the setting's current category as
+	// well, so a setting can't be moved out of a category they can't write
+	if !s.requireAccess(w, r, req.Category, true) {
+		return
+	}
+	existing, err := s.db.GetConfig(dealershipID, environment, key)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get config")
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if existing != nil && existing.Category != req.Category && !s.requireAccess(w, r, existing.Category, true) {
+		return
+	}
+
+	// Reject values that break a dependency between settings
+	if !s.checkSettingChange(w, r, dealershipID, environment, key, &req.Value) {
+		return
+	}
+
+	err = s.db.SetConfig(dealershipID, environment, key, req.Value, req.Type, req.Category, req.Description)
 	if err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to set config")
 		respondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	config, err := s.db.GetConfig(dealershipID, key)
+	config, err := s.db.GetConfig(dealershipID, environment, key)
 	if err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get config")
 		respondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	s.logger.WithContext(r.Context()).WithField("key", key).Info("Config setting updated")
+	s.logger.WithContext(r.Context()).WithField("key", key).WithField("environment", environment).Info("Config setting updated")
 	respondJSON(w, http.StatusOK, config)
 }
 
-// handleDeleteSetting deletes a setting
+// handleDeleteSetting deletes a setting from the request's environment.
+// Deleting an environment's value leaves the default value in effect there.
 func (s *Server) handleDeleteSetting(w http.ResponseWriter, r *http.Request) {
 	dealershipID := r.Header.Get("X-Dealership-ID")
 	if dealershipID == "" {
@@ -189,17 +289,50 @@ func (s *Server) handleDeleteSetting(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	environment, ok := s.requireEnvironment(w, r)
+	if !ok {
+		return
+	}
+
 	vars := mux.Vars(r)
 	key := vars["key"]
 
-	err := s.db.DeleteConfig(dealershipID, key)
+	existing, err := s.db.GetConfig(dealershipID, environment, key)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get config")
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if existing != nil && !s.requireAccess(w, r, existing.Category, true) {
+		return
+	}
+
+	var remaining *string
+	if environment != "" {
+		fallback, err := s.db.GetConfig(dealershipID, "", key)
+		if err != nil {
+			s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get config")
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if fallback != nil {
+			remaining = &fallback.Value
+		}
+	}
+
+	// Don't delete a setting another enabled setting depends on
+	if !s.checkSettingChange(w, r, dealershipID, environment, key, remaining) {
+		return
+	}
+
+	err = s.db.DeleteConfig(dealershipID, environment, key)
 	if err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to delete config")
 		respondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	s.logger.WithContext(r.Context()).WithField("key", key).Info("Config setting deleted")
+	s.logger.WithContext(r.Context()).WithField("key", key).WithField("environment", environment).Info("Config setting deleted")
 	respondJSON(w, http.StatusOK, map[string]string{"message": "config deleted"})
 }
 
@@ -211,6 +344,11 @@ func (s *Server) handleGetCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	environment, ok := s.requireEnvironment(w, r)
+	if !ok {
+		return
+	}
+
 	vars := mux.Vars(r)
 	category := vars["category"]
 
@@ -221,7 +359,11 @@ func (s *Server) handleGetCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	configs, err := s.db.GetConfigsByCategory(dealershipID, category)
+	if !s.requireAccess(w, r, category, false) {
+		return
+	}
+
+	configs, err := s.db.GetConfigsByCategory(dealershipID, environment, category)
 	if err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get category configs")
 		respondError(w, http.StatusInternalServerError, err.Error())
@@ -549,6 +691,15 @@ func main() {
 
 	// Create and start server
 	server := NewServer(db, logger)
+	defer server.tracer.Shutdown(context.Background())
+	server.environment = os.Getenv("CONFIG_ENVIRONMENT")
+	if _, err := normalizeEnvironment(server.environment); err != nil {
+		logger.Fatalf("Invalid CONFIG_ENVIRONMENT: %v", err)
+	}
+	server.accessPolicy, err = parseAccessPolicy(os.Getenv("CONFIG_ACCESS_POLICY"))
+	if err != nil {
+		logger.Fatalf("Invalid CONFIG_ACCESS_POLICY: %v", err)
+	}
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -556,9 +707,14 @@ func main() {
 	}
 
 	addr := fmt.Sprintf(":%s", port)
+	// Stop serving on SIGINT or SIGTERM, once in-flight requests finish
+	ctx, stop := graceful.SignalContext(context.Background())
+	defer stop()
+
+	srv := &http.Server{Addr: addr, Handler: server.router}
 	logger.Infof("Config service listening on %s", addr)
 
-	if err := http.ListenAndServe(addr, server.router); err != nil {
+	if err := graceful.ListenAndServe(ctx, srv, graceful.DefaultTimeout); err != nil {
 		logger.Fatal(err.Error())
 	}
 }
@@ -2,19 +2,37 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sort"
 	"testing"
 	"time"
+
+	"autolytiq/shared/logging"
 )
 
+// testLogger creates a logger that discards output during tests
+func testLogger() *logging.Logger {
+	return logging.New(logging.Config{
+		Service: "config-service-test",
+		Level:   logging.LevelError,
+		Output:  &bytes.Buffer{},
+	})
+}
+
 // MockDatabase implements ConfigDatabase for testing
 type MockDatabase struct {
-	configs      map[string]map[string]*DealershipConfig // dealershipID -> key -> config
+	configs      map[string]map[string]*DealershipConfig // dealershipID -> environment/key -> config
 	flags        map[string]*FeatureFlag                 // flagKey -> flag
 	integrations map[string]*Integration                 // id -> integration
+	versions     map[string]int64                        // dealershipID -> config version
+
+	// pingErr is returned by Ping, as if the database were down
+	pingErr error
 }
 
 // NewMockDatabase creates a new mock database
@@ -23,6 +41,7 @@ func NewMockDatabase() *MockDatabase {
 		configs:      make(map[string]map[string]*DealershipConfig),
 		flags:        make(map[string]*FeatureFlag),
 		integrations: make(map[string]*Integration),
+		versions:     make(map[string]int64),
 	}
 }
 
@@ -30,17 +49,26 @@ func (m *MockDatabase) Close() error {
 	return nil
 }
 
+func (m *MockDatabase) Ping(ctx context.Context) error {
+	return m.pingErr
+}
+
 func (m *MockDatabase) InitSchema() error {
 	return nil
 }
 
-func (m *MockDatabase) SetConfig(dealershipID, key, value, configType, category, description string) error {
+// mockConfigKey keys a mock setting by environment and key
+func mockConfigKey(environment, key string) string {
+	return environment + "/" + key
+}
+
+func (m *MockDatabase) SetConfig(dealershipID, environment, key, value, configType, category, description string) error {
 	if m.configs[dealershipID] == nil {
 		m.configs[dealershipID] = make(map[string]*DealershipConfig)
 	}
 
 	now := time.Now()
-	config, exists := m.configs[dealershipID][key]
+	config, exists := m.configs[dealershipID][mockConfigKey(environment, key)]
 	if exists {
 		config.Value = value
 		config.Type = configType
@@ -48,7 +76,7 @@ func (m *MockDatabase) SetConfig(dealershipID, key, value, configType, category,
 		config.Description = description
 		config.UpdatedAt = now
 	} else {
-		m.configs[dealershipID][key] = &DealershipConfig{
+		m.configs[dealershipID][mockConfigKey(environment, key)] = &DealershipConfig{
 			DealershipID: dealershipID,
 			Key:          key,
 			Value:        value,
@@ -57,39 +85,56 @@ func (m *MockDatabase) SetConfig(dealershipID, key, value, configType, category,
 			Description:  description,
 			CreatedAt:    now,
 			UpdatedAt:    now,
+			Environment:  environment,
 		}
 	}
 
+	m.versions[dealershipID]++
 	return nil
 }
 
-func (m *MockDatabase) GetConfig(dealershipID, key string) (*DealershipConfig, error) {
+func (m *MockDatabase) GetConfig(dealershipID, environment, key string) (*DealershipConfig, error) {
 	if m.configs[dealershipID] == nil {
 		return nil, nil
 	}
-	return m.configs[dealershipID][key], nil
+	if config, ok := m.configs[dealershipID][mockConfigKey(environment, key)]; ok {
+		return config, nil
+	}
+	return m.configs[dealershipID][mockConfigKey("", key)], nil
 }
 
-func (m *MockDatabase) GetConfigsByCategory(dealershipID, category string) ([]DealershipConfig, error) {
-	var configs []DealershipConfig
-	if m.configs[dealershipID] != nil {
-		for _, config := range m.configs[dealershipID] {
-			if config.Category == category {
-				configs = append(configs, *config)
-			}
+func (m *MockDatabase) GetConfigsByCategory(dealershipID, environment, category string) ([]DealershipConfig, error) {
+	resolved := make(map[string]*DealershipConfig)
+	for _, config := range m.configs[dealershipID] {
+		if config.Category != category || (config.Environment != "" && config.Environment != environment) {
+			continue
+		}
+		if existing, ok := resolved[config.Key]; !ok || existing.Environment == "" {
+			resolved[config.Key] = config
 		}
 	}
+
+	var configs []DealershipConfig
+	for _, config := range resolved {
+		configs = append(configs, *config)
+	}
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Key < configs[j].Key })
 	return configs, nil
 }
 
-func (m *MockDatabase) DeleteConfig(dealershipID, key string) error {
-	if m.configs[dealershipID] == nil || m.configs[dealershipID][key] == nil {
+func (m *MockDatabase) DeleteConfig(dealershipID, environment, key string) error {
+	if m.configs[dealershipID] == nil || m.configs[dealershipID][mockConfigKey(environment, key)] == nil {
 		return fmt.Errorf("config not found")
 	}
-	delete(m.configs[dealershipID], key)
+	delete(m.configs[dealershipID], mockConfigKey(environment, key))
+	m.versions[dealershipID]++
 	return nil
 }
 
+func (m *MockDatabase) GetConfigVersion(dealershipID string) (int64, error) {
+	return m.versions[dealershipID], nil
+}
+
 func (m *MockDatabase) CreateFeatureFlag(flagKey string, enabled bool, rolloutPercentage int, constraintsJSON json.RawMessage, description string) (*FeatureFlag, error) {
 	if m.flags[flagKey] != nil {
 		return nil, fmt.Errorf("feature flag already exists")
@@ -270,7 +315,7 @@ func makeRequest(t *testing.T, server *Server, method, path string, body interfa
 
 func TestHealthEndpoint(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	rr := makeRequest(t, server, "GET", "/health", nil, "")
 
@@ -288,9 +333,28 @@ func TestHealthEndpoint(t *testing.T) {
 	}
 }
 
+func TestHealthCheckDatabaseDown(t *testing.T) {
+	db := NewMockDatabase()
+	db.pingErr = errors.New("connection refused")
+	server := NewServer(db, testLogger())
+
+	rr := makeRequest(t, server, "GET", "/health", nil, "")
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 with the database down, got %d", rr.Code)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response["status"] != "unhealthy" || response["db"] != "down" {
+		t.Errorf("Expected an unhealthy status with the database down, got %v", response)
+	}
+}
+
 func TestSetAndGetConfig(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 	dealershipID := "dealer-1"
 
 	// Set a config
@@ -329,7 +393,7 @@ func TestSetAndGetConfig(t *testing.T) {
 
 func TestGetConfigNotFound(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 	dealershipID := "dealer-1"
 
 	rr := makeRequest(t, server, "GET", "/config/settings/nonexistent", nil, dealershipID)
@@ -341,7 +405,7 @@ func TestGetConfigNotFound(t *testing.T) {
 
 func TestSetConfigInvalidType(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 	dealershipID := "dealer-1"
 
 	setReq := SetSettingRequest{
@@ -359,7 +423,7 @@ func TestSetConfigInvalidType(t *testing.T) {
 
 func TestSetConfigInvalidCategory(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 	dealershipID := "dealer-1"
 
 	setReq := SetSettingRequest{
@@ -377,7 +441,7 @@ func TestSetConfigInvalidCategory(t *testing.T) {
 
 func TestDeleteConfig(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 	dealershipID := "dealer-1"
 
 	// Set a config first
@@ -403,9 +467,53 @@ func TestDeleteConfig(t *testing.T) {
 	}
 }
 
+func TestConfigVersionBumpsOnChange(t *testing.T) {
+	db := NewMockDatabase()
+	server := NewServer(db, testLogger())
+	dealershipID := "dealer-1"
+
+	version := func() float64 {
+		rr := makeRequest(t, server, "GET", "/config/version", nil, dealershipID)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("version handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		var response map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		return response["version"].(float64)
+	}
+
+	if v := version(); v != 0 {
+		t.Errorf("expected initial version 0, got %v", v)
+	}
+
+	setReq := SetSettingRequest{Value: "60", Type: "integer", Category: "financing"}
+	makeRequest(t, server, "PUT", "/config/settings/default_term_months", setReq, dealershipID)
+	if v := version(); v != 1 {
+		t.Errorf("expected version 1 after set, got %v", v)
+	}
+
+	rr := makeRequest(t, server, "GET", "/config/settings/default_term_months", nil, dealershipID)
+	if got := rr.Header().Get(ConfigVersionHeader); got != "1" {
+		t.Errorf("expected %s header 1, got %q", ConfigVersionHeader, got)
+	}
+
+	makeRequest(t, server, "DELETE", "/config/settings/default_term_months", nil, dealershipID)
+	if v := version(); v != 2 {
+		t.Errorf("expected version 2 after delete, got %v", v)
+	}
+
+	// Other dealerships are unaffected
+	rr = makeRequest(t, server, "GET", "/config/version", nil, "dealer-2")
+	if !bytes.Contains(rr.Body.Bytes(), []byte(`"version":0`)) {
+		t.Errorf("expected dealer-2 version 0, got %s", rr.Body.String())
+	}
+}
+
 func TestGetConfigsByCategory(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 	dealershipID := "dealer-1"
 
 	// Set multiple configs in same category
@@ -446,7 +554,7 @@ func TestGetConfigsByCategory(t *testing.T) {
 
 func TestGetAllSettings(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 	dealershipID := "dealer-1"
 
 	// Set configs in different categories
@@ -494,7 +602,7 @@ func TestGetAllSettings(t *testing.T) {
 
 func TestMultiTenantIsolation(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	// Set config for dealer-1
 	setReq := SetSettingRequest{
@@ -526,7 +634,7 @@ func TestMultiTenantIsolation(t *testing.T) {
 
 func TestCreateFeatureFlag(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	createReq := CreateFeatureFlagRequest{
 		FlagKey:           "new_ui",
@@ -559,7 +667,7 @@ func TestCreateFeatureFlag(t *testing.T) {
 
 func TestListFeatureFlags(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	// Create multiple flags
 	flags := []string{"flag1", "flag2", "flag3"}
@@ -590,7 +698,7 @@ func TestListFeatureFlags(t *testing.T) {
 
 func TestGetFeatureFlag(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	// Create a flag
 	createReq := CreateFeatureFlagRequest{
@@ -619,7 +727,7 @@ func TestGetFeatureFlag(t *testing.T) {
 
 func TestUpdateFeatureFlag(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	// Create a flag
 	createReq := CreateFeatureFlagRequest{
@@ -655,7 +763,7 @@ func TestUpdateFeatureFlag(t *testing.T) {
 
 func TestDeleteFeatureFlag(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	// Create a flag
 	createReq := CreateFeatureFlagRequest{
@@ -681,7 +789,7 @@ func TestDeleteFeatureFlag(t *testing.T) {
 
 func TestEvaluateFeatureFlagEnabled(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	// Create an enabled flag
 	createReq := CreateFeatureFlagRequest{
@@ -713,7 +821,7 @@ func TestEvaluateFeatureFlagEnabled(t *testing.T) {
 
 func TestEvaluateFeatureFlagDisabled(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	// Create a disabled flag
 	createReq := CreateFeatureFlagRequest{
@@ -744,7 +852,7 @@ func TestEvaluateFeatureFlagDisabled(t *testing.T) {
 
 func TestEvaluateFeatureFlagWithConstraints(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	// Create a flag with dealership constraints
 	constraints := FlagConstraints{
@@ -784,7 +892,7 @@ func TestEvaluateFeatureFlagWithConstraints(t *testing.T) {
 
 func TestCreateIntegration(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	configJSON := json.RawMessage(`{"api_key": "test123"}`)
 	createReq := CreateIntegrationRequest{
@@ -815,7 +923,7 @@ func TestCreateIntegration(t *testing.T) {
 
 func TestCreateIntegrationInvalidProvider(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	createReq := CreateIntegrationRequest{
 		DealershipID: "dealer-1",
@@ -833,7 +941,7 @@ func TestCreateIntegrationInvalidProvider(t *testing.T) {
 
 func TestListIntegrations(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	// Create multiple integrations
 	providers := []string{"credit_bureau", "inventory_feed", "accounting"}
@@ -866,7 +974,7 @@ func TestListIntegrations(t *testing.T) {
 
 func TestGetIntegration(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	// Create an integration
 	createReq := CreateIntegrationRequest{
@@ -899,7 +1007,7 @@ func TestGetIntegration(t *testing.T) {
 
 func TestUpdateIntegration(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	// Create an integration
 	createReq := CreateIntegrationRequest{
@@ -941,7 +1049,7 @@ func TestUpdateIntegration(t *testing.T) {
 
 func TestDeleteIntegration(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	// Create an integration
 	createReq := CreateIntegrationRequest{
@@ -972,7 +1080,7 @@ func TestDeleteIntegration(t *testing.T) {
 
 func TestIntegrationMultiTenantIsolation(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	// Create integrations for different dealerships
 	createReq := CreateIntegrationRequest{
@@ -1003,7 +1111,7 @@ func TestIntegrationMultiTenantIsolation(t *testing.T) {
 
 func TestMissingDealershipHeader(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	// Try to get settings without dealership header
 	rr := makeRequest(t, server, "GET", "/config/settings/test", nil, "")
@@ -1015,7 +1123,7 @@ func TestMissingDealershipHeader(t *testing.T) {
 
 func TestInvalidRolloutPercentage(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	// Test creating flag with invalid rollout percentage
 	createReq := CreateFeatureFlagRequest{
@@ -1033,7 +1141,7 @@ func TestInvalidRolloutPercentage(t *testing.T) {
 
 func TestSetConfigMissingDealershipHeader(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	setReq := SetSettingRequest{
 		Value:    "test",
@@ -1050,7 +1158,7 @@ func TestSetConfigMissingDealershipHeader(t *testing.T) {
 
 func TestDeleteConfigMissingDealershipHeader(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	rr := makeRequest(t, server, "DELETE", "/config/settings/test", nil, "")
 
@@ -1061,7 +1169,7 @@ func TestDeleteConfigMissingDealershipHeader(t *testing.T) {
 
 func TestGetCategoryMissingDealershipHeader(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	rr := makeRequest(t, server, "GET", "/config/categories/dealership", nil, "")
 
@@ -1072,7 +1180,7 @@ func TestGetCategoryMissingDealershipHeader(t *testing.T) {
 
 func TestGetAllSettingsMissingDealershipHeader(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	rr := makeRequest(t, server, "GET", "/config/settings", nil, "")
 
@@ -1083,7 +1191,7 @@ func TestGetAllSettingsMissingDealershipHeader(t *testing.T) {
 
 func TestGetCategoryInvalidCategory(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	rr := makeRequest(t, server, "GET", "/config/categories/invalid_category", nil, "dealer-1")
 
@@ -1094,7 +1202,7 @@ func TestGetCategoryInvalidCategory(t *testing.T) {
 
 func TestGetFeatureFlagNotFound(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	rr := makeRequest(t, server, "GET", "/config/features/nonexistent", nil, "")
 
@@ -1105,7 +1213,7 @@ func TestGetFeatureFlagNotFound(t *testing.T) {
 
 func TestUpdateFeatureFlagNotFound(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	updateReq := UpdateFeatureFlagRequest{
 		Enabled:           true,
@@ -1121,7 +1229,7 @@ func TestUpdateFeatureFlagNotFound(t *testing.T) {
 
 func TestDeleteFeatureFlagNotFound(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	rr := makeRequest(t, server, "DELETE", "/config/features/nonexistent", nil, "")
 
@@ -1132,7 +1240,7 @@ func TestDeleteFeatureFlagNotFound(t *testing.T) {
 
 func TestUpdateFeatureFlagInvalidRollout(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	// Create a flag first
 	createReq := CreateFeatureFlagRequest{
@@ -1156,7 +1264,7 @@ func TestUpdateFeatureFlagInvalidRollout(t *testing.T) {
 
 func TestEvaluateFeatureFlagNotFound(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	evalReq := EvaluateFeatureFlagRequest{
 		DealershipID: "dealer-1",
@@ -1171,7 +1279,7 @@ func TestEvaluateFeatureFlagNotFound(t *testing.T) {
 
 func TestCreateIntegrationInvalidStatus(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	createReq := CreateIntegrationRequest{
 		DealershipID: "dealer-1",
@@ -1189,7 +1297,7 @@ func TestCreateIntegrationInvalidStatus(t *testing.T) {
 
 func TestListIntegrationsMissingDealershipHeader(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	rr := makeRequest(t, server, "GET", "/config/integrations", nil, "")
 
@@ -1200,7 +1308,7 @@ func TestListIntegrationsMissingDealershipHeader(t *testing.T) {
 
 func TestGetIntegrationNotFound(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	rr := makeRequest(t, server, "GET", "/config/integrations/nonexistent-id", nil, "")
 
@@ -1211,7 +1319,7 @@ func TestGetIntegrationNotFound(t *testing.T) {
 
 func TestUpdateIntegrationNotFound(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	updateReq := UpdateIntegrationRequest{
 		ConfigJSON: json.RawMessage(`{}`),
@@ -1227,7 +1335,7 @@ func TestUpdateIntegrationNotFound(t *testing.T) {
 
 func TestUpdateIntegrationInvalidStatus(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	// Create an integration first
 	createReq := CreateIntegrationRequest{
@@ -1256,7 +1364,7 @@ func TestUpdateIntegrationInvalidStatus(t *testing.T) {
 
 func TestDeleteIntegrationNotFound(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	rr := makeRequest(t, server, "DELETE", "/config/integrations/nonexistent-id", nil, "")
 
@@ -1267,7 +1375,7 @@ func TestDeleteIntegrationNotFound(t *testing.T) {
 
 func TestSetConfigInvalidJSON(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	req := httptest.NewRequest("PUT", "/config/settings/test", bytes.NewBuffer([]byte("invalid json")))
 	req.Header.Set("X-Dealership-ID", "dealer-1")
@@ -1283,7 +1391,7 @@ func TestSetConfigInvalidJSON(t *testing.T) {
 
 func TestCreateFeatureFlagInvalidJSON(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	req := httptest.NewRequest("POST", "/config/features", bytes.NewBuffer([]byte("invalid json")))
 	req.Header.Set("Content-Type", "application/json")
@@ -1298,7 +1406,7 @@ func TestCreateFeatureFlagInvalidJSON(t *testing.T) {
 
 func TestUpdateFeatureFlagInvalidJSON(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	req := httptest.NewRequest("PUT", "/config/features/test", bytes.NewBuffer([]byte("invalid json")))
 	req.Header.Set("Content-Type", "application/json")
@@ -1313,7 +1421,7 @@ func TestUpdateFeatureFlagInvalidJSON(t *testing.T) {
 
 func TestEvaluateFeatureFlagInvalidJSON(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	req := httptest.NewRequest("POST", "/config/features/test/evaluate", bytes.NewBuffer([]byte("invalid json")))
 	req.Header.Set("Content-Type", "application/json")
@@ -1328,7 +1436,7 @@ func TestEvaluateFeatureFlagInvalidJSON(t *testing.T) {
 
 func TestCreateIntegrationInvalidJSON(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	req := httptest.NewRequest("POST", "/config/integrations", bytes.NewBuffer([]byte("invalid json")))
 	req.Header.Set("Content-Type", "application/json")
@@ -1343,7 +1451,7 @@ func TestCreateIntegrationInvalidJSON(t *testing.T) {
 
 func TestUpdateIntegrationInvalidJSON(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	req := httptest.NewRequest("PUT", "/config/integrations/test-id", bytes.NewBuffer([]byte("invalid json")))
 	req.Header.Set("Content-Type", "application/json")
@@ -1358,7 +1466,7 @@ func TestUpdateIntegrationInvalidJSON(t *testing.T) {
 
 func TestConfigTypeValidation(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	tests := []struct {
 		configType string
@@ -1392,7 +1500,7 @@ func TestConfigTypeValidation(t *testing.T) {
 
 func TestCategoryValidation(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	tests := []struct {
 		category   string
@@ -1427,7 +1535,7 @@ func TestCategoryValidation(t *testing.T) {
 
 func TestProviderValidation(t *testing.T) {
 	db := NewMockDatabase()
-	server := NewServer(db)
+	server := NewServer(db, testLogger())
 
 	tests := []struct {
 		provider   string
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Conditions a setting's value can be checked against
+const (
+	ConditionSet      = "set"      // present and non-empty
+	ConditionTrue     = "true"     // a true boolean
+	ConditionPositive = "positive" // a number greater than zero
+)
+
+// configCategories are the categories a dealership's settings are grouped in
+var configCategories = []string{"dealership", "sales", "financing", "notifications", "ui"}
+
+// SettingCondition is a condition on one setting's value
+type SettingCondition struct {
+	Key       string `json:"key"`
+	Condition string `json:"condition"`
+}
+
+// SettingRule declares that when a setting meets a condition, other settings
+// must meet theirs. Rules are checked against a dealership's full config.
+type SettingRule struct {
+	When     SettingCondition   `json:"when"`
+	Requires []SettingCondition `json:"requires"`
+	Message  string             `json:"message"`
+}
+
+// settingRules are the cross-setting rules enforced on every change
+var settingRules = []SettingRule{
+	{
+		When:     SettingCondition{"require_co_signer_over_amount", ConditionTrue},
+		Requires: []SettingCondition{{"co_signer_amount_threshold", ConditionPositive}},
+		Message:  "Requiring a co-signer needs co_signer_amount_threshold set to a positive amount",
+	},
+	{
+		When:     SettingCondition{"sms_notifications_enabled", ConditionTrue},
+		Requires: []SettingCondition{{"sms_from_number", ConditionSet}},
+		Message:  "SMS notifications need sms_from_number set",
+	},
+}
+
+// SettingViolation is a rule a dealership's config breaks
+type SettingViolation struct {
+	Key     string   `json:"key"`
+	Missing []string `json:"missing"`
+	Message string   `json:"message"`
+}
+
+// meets reports whether value satisfies condition
+func meets(value, condition string) bool {
+	value = strings.TrimSpace(value)
+	switch condition {
+	case ConditionSet:
+		return value != ""
+	case ConditionTrue:
+		b, err := strconv.ParseBool(value)
+		return err == nil && b
+	case ConditionPositive:
+		n, err := strconv.ParseFloat(value, 64)
+		return err == nil && n > 0
+	}
+	return false
+}
+
+// checkSettingRules returns the violations of rules in a set of settings
+func checkSettingRules(rules []SettingRule, settings map[string]string) []SettingViolation {
+	var violations []SettingViolation
+	for _, rule := range rules {
+		if !meets(settings[rule.When.Key], rule.When.Condition) {
+			continue
+		}
+
+		var missing []string
+		for _, required := range rule.Requires {
+			if !meets(settings[required.Key], required.Condition) {
+				missing = append(missing, required.Key)
+			}
+		}
+		if len(missing) > 0 {
+			violations = append(violations, SettingViolation{
+				Key:     rule.When.Key,
+				Missing: missing,
+				Message: rule.Message,
+			})
+		}
+	}
+	return violations
+}
+
+// involves reports whether a violation concerns the given setting, either as
+// the dependent setting or a missing prerequisite
+func (v SettingViolation) involves(key string) bool {
+	if v.Key == key {
+		return true
+	}
+	for _, missing := range v.Missing {
+		if missing == key {
+			return true
+		}
+	}
+	return false
+}
+
+// loadSettings returns a dealership's settings in an environment as
+// key/value pairs
+func (s *Server) loadSettings(dealershipID, environment string) (map[string]string, error) {
+	settings := make(map[string]string)
+	for _, category := range configCategories {
+		configs, err := s.db.GetConfigsByCategory(dealershipID, environment, category)
+		if err != nil {
+			return nil, err
+		}
+		for _, config := range configs {
+			settings[config.Key] = config.Value
+		}
+	}
+	return settings, nil
+}
+
+// checkSettingChange validates a change to one setting against the rules,
+// responding with 400 if the change would leave a feature half-configured.
+// value is nil when the setting is being deleted. Violations that don't
+// involve the changed setting are left to the validate endpoint.
+func (s *Server) checkSettingChange(w http.ResponseWriter, r *http.Request, dealershipID, environment, key string, value *string) bool {
+	settings, err := s.loadSettings(dealershipID, environment)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to load settings for validation")
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+
+	if value != nil {
+		settings[key] = *value
+	} else {
+		delete(settings, key)
+	}
+
+	var errors []ValidationError
+	for _, v := range checkSettingRules(settingRules, settings) {
+		if v.involves(key) {
+			errors = append(errors, ValidationError{
+				Field:   v.Key,
+				Message: fmt.Sprintf("%s (missing: %s)", v.Message, strings.Join(v.Missing, ", ")),
+			})
+		}
+	}
+
+	if len(errors) > 0 {
+		respondValidationErrorV2(w, &ValidationErrors{Errors: errors})
+		return false
+	}
+	return true
+}
+
+// handleValidateConfig checks a dealership's full config against the setting
+// rules and reports every violation
+func (s *Server) handleValidateConfig(w http.ResponseWriter, r *http.Request) {
+	dealershipID := r.Header.Get("X-Dealership-ID")
+	if dealershipID == "" {
+		respondError(w, http.StatusBadRequest, "X-Dealership-ID header required")
+		return
+	}
+
+	environment, ok := s.requireEnvironment(w, r)
+	if !ok {
+		return
+	}
+
+	settings, err := s.loadSettings(dealershipID, environment)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to load settings for validation")
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	violations := checkSettingRules(settingRules, settings)
+	if violations == nil {
+		violations = []SettingViolation{}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"dealership_id": dealershipID,
+		"valid":         len(violations) == 0,
+		"violations":    violations,
+	})
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSetDependentSettingWithoutPrerequisite(t *testing.T) {
+	db := NewMockDatabase()
+	server := NewServer(db, testLogger())
+	dealershipID := "dealer-1"
+
+	enable := SetSettingRequest{Value: "true", Type: "boolean", Category: "financing"}
+	rr := makeRequest(t, server, "PUT", "/config/settings/require_co_signer_over_amount", enable, dealershipID)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 without a threshold, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), "co_signer_amount_threshold") {
+		t.Errorf("Expected the message to name the missing setting, got %s", rr.Body.String())
+	}
+	if config, _ := db.GetConfig(dealershipID, "", "require_co_signer_over_amount"); config != nil {
+		t.Error("Expected the rejected setting not to be saved")
+	}
+
+	// A threshold that isn't a positive amount doesn't satisfy the rule
+	threshold := SetSettingRequest{Value: "0", Type: "integer", Category: "financing"}
+	makeRequest(t, server, "PUT", "/config/settings/co_signer_amount_threshold", threshold, dealershipID)
+	rr = makeRequest(t, server, "PUT", "/config/settings/require_co_signer_over_amount", enable, dealershipID)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 with a zero threshold, got %d", rr.Code)
+	}
+
+	threshold.Value = "25000"
+	makeRequest(t, server, "PUT", "/config/settings/co_signer_amount_threshold", threshold, dealershipID)
+	rr = makeRequest(t, server, "PUT", "/config/settings/require_co_signer_over_amount", enable, dealershipID)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 once the threshold is set, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// The prerequisite can't be removed while the dependent setting is on
+	rr = makeRequest(t, server, "DELETE", "/config/settings/co_signer_amount_threshold", nil, dealershipID)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 deleting a prerequisite, got %d", rr.Code)
+	}
+
+	// Turning the dependent setting off doesn't need the prerequisite
+	enable.Value = "false"
+	rr = makeRequest(t, server, "PUT", "/config/settings/require_co_signer_over_amount", enable, dealershipID)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 disabling the setting, got %d", rr.Code)
+	}
+}
+
+func TestValidateConfigReportsAllViolations(t *testing.T) {
+	db := NewMockDatabase()
+	server := NewServer(db, testLogger())
+	dealershipID := "dealer-1"
+
+	// Stored before the rules existed
+	db.SetConfig(dealershipID, "", "require_co_signer_over_amount", "true", "boolean", "financing", "")
+	db.SetConfig(dealershipID, "", "sms_notifications_enabled", "true", "boolean", "notifications", "")
+	db.SetConfig(dealershipID, "", "dealership_name", "Test Motors", "string", "dealership", "")
+
+	rr := makeRequest(t, server, "POST", "/config/validate", nil, dealershipID)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp struct {
+		Valid      bool               `json:"valid"`
+		Violations []SettingViolation `json:"violations"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+
+	if resp.Valid || len(resp.Violations) != 2 {
+		t.Fatalf("Expected 2 violations, got %+v", resp)
+	}
+
+	// Unrelated settings can still be changed
+	setReq := SetSettingRequest{Value: "New Name", Type: "string", Category: "dealership"}
+	rr = makeRequest(t, server, "PUT", "/config/settings/dealership_name", setReq, dealershipID)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected unrelated settings to save, got %d", rr.Code)
+	}
+
+	rr = makeRequest(t, server, "POST", "/config/validate", nil, "dealer-2")
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if !resp.Valid || len(resp.Violations) != 0 {
+		t.Errorf("Expected an empty config to be valid, got %+v", resp)
+	}
+}
+
+func TestCheckSettingRules(t *testing.T) {
+	rules := []SettingRule{{
+		When:     SettingCondition{"feature", ConditionTrue},
+		Requires: []SettingCondition{{"amount", ConditionPositive}, {"name", ConditionSet}},
+		Message:  "feature needs amount and name",
+	}}
+
+	tests := []struct {
+		settings map[string]string
+		missing  []string
+	}{
+		{map[string]string{}, nil},
+		{map[string]string{"feature": "false"}, nil},
+		{map[string]string{"feature": "true"}, []string{"amount", "name"}},
+		{map[string]string{"feature": "true", "amount": "abc", "name": " "}, []string{"amount", "name"}},
+		{map[string]string{"feature": "true", "amount": "10", "name": "x"}, nil},
+	}
+	for _, tt := range tests {
+		violations := checkSettingRules(rules, tt.settings)
+		if tt.missing == nil {
+			if len(violations) != 0 {
+				t.Errorf("%v: expected no violations, got %+v", tt.settings, violations)
+			}
+			continue
+		}
+		if len(violations) != 1 || strings.Join(violations[0].Missing, ",") != strings.Join(tt.missing, ",") {
+			t.Errorf("%v: expected missing %v, got %+v", tt.settings, tt.missing, violations)
+		}
+	}
+}
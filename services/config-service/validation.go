@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
+
+	"autolytiq/shared/bodylimit"
 )
 
 // ValidationError represents a single validation error
@@ -324,6 +326,10 @@ type Sanitizable interface {
 // decodeAndValidateConfig decodes JSON and validates the request
 func decodeAndValidateConfig(r *http.Request, w http.ResponseWriter, dst interface{}) bool {
 	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		if bodylimit.IsTooLarge(err) {
+			bodylimit.RespondTooLarge(w)
+			return false
+		}
 		respondError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
 		return false
 	}
@@ -1,16 +1,20 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"autolytiq/shared/logging"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 
 	"autolytiq/shared/encryption"
+	"autolytiq/shared/outbox"
 )
 
 // Database wraps the SQL database connection
@@ -55,6 +59,11 @@ func (db *Database) Close() error {
 	return db.conn.Close()
 }
 
+// Ping checks the database is reachable
+func (db *Database) Ping(ctx context.Context) error {
+	return db.conn.PingContext(ctx)
+}
+
 // InitSchema creates the customers table if it doesn't exist
 func (db *Database) InitSchema() error {
 	schema := `
@@ -86,18 +95,66 @@ func (db *Database) InitSchema() error {
 	CREATE INDEX IF NOT EXISTS idx_customers_email ON customers(email);
 	CREATE INDEX IF NOT EXISTS idx_customers_name ON customers(last_name, first_name);
 	CREATE INDEX IF NOT EXISTS idx_customers_pii_encryption_version ON customers(pii_encryption_version) WHERE pii_encryption_version IS NULL;
-	`
+
+	ALTER TABLE customers ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+	ALTER TABLE customers ADD COLUMN IF NOT EXISTS anonymized_at TIMESTAMP;
+	ALTER TABLE customers ADD COLUMN IF NOT EXISTS date_of_birth DATE;
+
+	CREATE TABLE IF NOT EXISTS customer_segments (
+		id VARCHAR(36) PRIMARY KEY,
+		dealership_id VARCHAR(36) NOT NULL,
+		name VARCHAR(200) NOT NULL,
+		description TEXT,
+		channel VARCHAR(20) NOT NULL,
+		filter JSONB NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_customer_segments_dealership ON customer_segments(dealership_id);
+
+	CREATE TABLE IF NOT EXISTS customer_tags (
+		customer_id VARCHAR(36) NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+		dealership_id VARCHAR(36) NOT NULL,
+		tag VARCHAR(50) NOT NULL,
+		created_by VARCHAR(36),
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_customer_tags_customer ON customer_tags(customer_id, LOWER(tag));
+	CREATE INDEX IF NOT EXISTS idx_customer_tags_dealership ON customer_tags(dealership_id, LOWER(tag));
+	` + outbox.Schema
 
 	if _, err := db.conn.Exec(schema); err != nil {
 		return fmt.Errorf("failed to initialize schema: %w", err)
 	}
 
+	// Trigram indexes keep the customer search from scanning the table. The
+	// extension needs a privileged role, so without it search still works,
+	// unindexed.
+	if _, err := db.conn.Exec(customerSearchSchema); err != nil && db.logger != nil {
+		db.logger.WithError(err).Warn("Customer search indexes not created; create the pg_trgm extension to index customer search")
+	}
+
 	if db.logger != nil {
 		db.logger.Info("Database schema initialized")
 	}
 	return nil
 }
 
+// customerSearchSchema creates the trigram indexes used by the q search on
+// ListCustomers
+const customerSearchSchema = `
+	CREATE EXTENSION IF NOT EXISTS pg_trgm;
+	CREATE INDEX IF NOT EXISTS idx_customers_first_name_trgm ON customers USING GIN (first_name gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS idx_customers_last_name_trgm ON customers USING GIN (last_name gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS idx_customers_email_trgm ON customers USING GIN (email gin_trgm_ops);
+`
+
+// likeEscaper escapes the LIKE wildcards in a search term so it matches
+// literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 // CreateCustomer inserts a new customer into the database
 func (db *Database) CreateCustomer(customer *Customer) error {
 	// Encrypt PII fields if encryptor is available
@@ -147,8 +204,8 @@ func (db *Database) CreateCustomer(customer *Customer) error {
 			credit_score, ssn_last4, drivers_license_number, monthly_income,
 			ssn_last4_encrypted, drivers_license_number_encrypted,
 			credit_score_encrypted, monthly_income_encrypted,
-			pii_encryption_version, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+			pii_encryption_version, created_at, updated_at, date_of_birth
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, NULLIF($22, '')::date)
 	`
 
 	// If encryption is enabled, store nulls in plaintext columns
@@ -179,7 +236,7 @@ func (db *Database) CreateCustomer(customer *Customer) error {
 		customer.State, customer.ZipCode,
 		plainCreditScore, plainSSN, plainDL, plainIncome,
 		ssnEncrypted, dlEncrypted, creditEncrypted, incomeEncrypted,
-		piiVersion, customer.CreatedAt, customer.UpdatedAt,
+		piiVersion, customer.CreatedAt, customer.UpdatedAt, customer.DateOfBirth,
 	)
 
 	if err != nil {
@@ -197,7 +254,8 @@ func (db *Database) GetCustomer(id string) (*Customer, error) {
 		       credit_score, ssn_last4, drivers_license_number, monthly_income,
 		       ssn_last4_encrypted, drivers_license_number_encrypted,
 		       credit_score_encrypted, monthly_income_encrypted,
-		       pii_encryption_version, created_at, updated_at
+		       pii_encryption_version, created_at, updated_at, ` + customerTagsColumn + `,
+		       COALESCE(TO_CHAR(date_of_birth, 'YYYY-MM-DD'), '')
 		FROM customers
 		WHERE id = $1
 	`
@@ -214,7 +272,8 @@ func (db *Database) GetCustomer(id string) (*Customer, error) {
 		&customer.State, &customer.ZipCode,
 		&plainCreditScore, &plainSSN, &plainDL, &plainIncome,
 		&ssnEncrypted, &dlEncrypted, &creditEncrypted, &incomeEncrypted,
-		&piiVersion, &customer.CreatedAt, &customer.UpdatedAt,
+		&piiVersion, &customer.CreatedAt, &customer.UpdatedAt, pq.Array(&customer.Tags),
+		&customer.DateOfBirth,
 	)
 
 	if err == sql.ErrNoRows {
@@ -278,35 +337,157 @@ func (db *Database) GetCustomer(id string) (*Customer, error) {
 	return &customer, nil
 }
 
-// ListCustomers retrieves all customers, optionally filtered by dealership
-func (db *Database) ListCustomers(dealershipID string) ([]*Customer, error) {
-	var rows *sql.Rows
-	var err error
+// GetCustomerPII retrieves a customer's encrypted PII columns as stored,
+// without decrypting them
+func (db *Database) GetCustomerPII(id string) (*CustomerPII, error) {
+	query := `
+		SELECT COALESCE(ssn_last4_encrypted, ''), COALESCE(drivers_license_number_encrypted, ''),
+		       COALESCE(credit_score_encrypted, ''), COALESCE(monthly_income_encrypted, ''),
+		       COALESCE(pii_encryption_version, '')
+		FROM customers
+		WHERE id = $1
+	`
 
-	baseQuery := `
+	pii := CustomerPII{CustomerID: id}
+	err := db.conn.QueryRow(query, id).Scan(
+		&pii.SSNLast4Encrypted, &pii.DriversLicenseNumberEncrypted,
+		&pii.CreditScoreEncrypted, &pii.MonthlyIncomeEncrypted,
+		&pii.PIIEncryptionVersion,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer PII: %w", err)
+	}
+	return &pii, nil
+}
+
+// ListCustomerPII retrieves the encrypted PII of up to limit customers with
+// IDs after afterID, in ID order, for walking the table in batches
+func (db *Database) ListCustomerPII(afterID string, limit int) ([]*CustomerPII, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, COALESCE(ssn_last4_encrypted, ''), COALESCE(drivers_license_number_encrypted, ''),
+		       COALESCE(credit_score_encrypted, ''), COALESCE(monthly_income_encrypted, ''),
+		       pii_encryption_version
+		FROM customers
+		WHERE pii_encryption_version IS NOT NULL AND id > $1
+		ORDER BY id
+		LIMIT $2
+	`, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list customer PII: %w", err)
+	}
+	defer rows.Close()
+
+	var batch []*CustomerPII
+	for rows.Next() {
+		var pii CustomerPII
+		if err := rows.Scan(
+			&pii.CustomerID, &pii.SSNLast4Encrypted, &pii.DriversLicenseNumberEncrypted,
+			&pii.CreditScoreEncrypted, &pii.MonthlyIncomeEncrypted, &pii.PIIEncryptionVersion,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan customer PII: %w", err)
+		}
+		batch = append(batch, &pii)
+	}
+	return batch, rows.Err()
+}
+
+// ReplaceCustomerPII writes re-encrypted PII for a customer, only if its
+// stored PII still matches before. It reports false, writing nothing, when
+// the customer changed since before was read.
+func (db *Database) ReplaceCustomerPII(before, after *CustomerPII) (bool, error) {
+	result, err := db.conn.Exec(`
+		UPDATE customers SET
+			ssn_last4_encrypted = NULLIF($2, ''),
+			drivers_license_number_encrypted = NULLIF($3, ''),
+			credit_score_encrypted = NULLIF($4, ''),
+			monthly_income_encrypted = NULLIF($5, ''),
+			pii_encryption_version = $6
+		WHERE id = $1
+		  AND COALESCE(ssn_last4_encrypted, '') = $7
+		  AND COALESCE(drivers_license_number_encrypted, '') = $8
+		  AND COALESCE(credit_score_encrypted, '') = $9
+		  AND COALESCE(monthly_income_encrypted, '') = $10
+		  AND pii_encryption_version = $11
+	`,
+		before.CustomerID,
+		after.SSNLast4Encrypted, after.DriversLicenseNumberEncrypted,
+		after.CreditScoreEncrypted, after.MonthlyIncomeEncrypted, after.PIIEncryptionVersion,
+		before.SSNLast4Encrypted, before.DriversLicenseNumberEncrypted,
+		before.CreditScoreEncrypted, before.MonthlyIncomeEncrypted, before.PIIEncryptionVersion,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to replace customer PII: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return n == 1, nil
+}
+
+// ListCustomers retrieves a page of the customers matching the filter, with
+// the total number of matches
+func (db *Database) ListCustomers(filter CustomerListFilter) (*CustomerListResult, error) {
+	query := `
 		SELECT id, dealership_id, first_name, last_name,
 		       email, phone, address, city, state, zip_code,
 		       credit_score, ssn_last4, drivers_license_number, monthly_income,
 		       ssn_last4_encrypted, drivers_license_number_encrypted,
 		       credit_score_encrypted, monthly_income_encrypted,
-		       pii_encryption_version, created_at, updated_at
+		       pii_encryption_version, created_at, updated_at, ` + customerTagsColumn + `,
+		       COALESCE(TO_CHAR(date_of_birth, 'YYYY-MM-DD'), '')
 		FROM customers
 	`
+	countQuery := "SELECT COUNT(*) FROM customers"
 
-	if dealershipID != "" {
-		query := baseQuery + " WHERE dealership_id = $1 ORDER BY last_name, first_name"
-		rows, err = db.conn.Query(query, dealershipID)
-	} else {
-		query := baseQuery + " ORDER BY last_name, first_name"
-		rows, err = db.conn.Query(query)
+	var conditions []string
+	var args []interface{}
+	if filter.DealershipID != "" {
+		args = append(args, filter.DealershipID)
+		conditions = append(conditions, fmt.Sprintf("dealership_id = $%d", len(args)))
+	}
+	if filter.Tag != "" {
+		args = append(args, filter.Tag)
+		conditions = append(conditions, fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM customer_tags t WHERE t.customer_id = customers.id AND LOWER(t.tag) = LOWER($%d))", len(args)))
+	}
+	if filter.Query != "" {
+		args = append(args, "%"+likeEscaper.Replace(filter.Query)+"%")
+		conditions = append(conditions, fmt.Sprintf(
+			"(first_name ILIKE $%[1]d OR last_name ILIKE $%[1]d OR email ILIKE $%[1]d)", len(args)))
+	}
+	if len(conditions) > 0 {
+		where := " WHERE " + strings.Join(conditions, " AND ")
+		query += where
+		countQuery += where
 	}
 
+	var total int
+	if err := db.conn.QueryRow(countQuery, args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to count customers: %w", err)
+	}
+
+	query += " ORDER BY last_name, first_name, id"
+	offset := filter.Offset
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+		args = append(args, filter.Limit, offset)
+	} else if offset > 0 {
+		query += fmt.Sprintf(" OFFSET $%d", len(args)+1)
+		args = append(args, offset)
+	}
+
+	rows, err := db.conn.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list customers: %w", err)
 	}
 	defer rows.Close()
 
-	var customers []*Customer
+	customers := []*Customer{}
 	for rows.Next() {
 		var customer Customer
 		var plainCreditScore sql.NullInt64
@@ -320,7 +501,8 @@ func (db *Database) ListCustomers(dealershipID string) ([]*Customer, error) {
 			&customer.State, &customer.ZipCode,
 			&plainCreditScore, &plainSSN, &plainDL, &plainIncome,
 			&ssnEncrypted, &dlEncrypted, &creditEncrypted, &incomeEncrypted,
-			&piiVersion, &customer.CreatedAt, &customer.UpdatedAt,
+			&piiVersion, &customer.CreatedAt, &customer.UpdatedAt, pq.Array(&customer.Tags),
+			&customer.DateOfBirth,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan customer: %w", err)
@@ -380,7 +562,16 @@ func (db *Database) ListCustomers(dealershipID string) ([]*Customer, error) {
 		customers = append(customers, &customer)
 	}
 
-	return customers, nil
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating customers: %w", err)
+	}
+
+	return &CustomerListResult{
+		Customers:  customers,
+		Total:      total,
+		HasMore:    offset+len(customers) < total,
+		NextOffset: offset + len(customers),
+	}, nil
 }
 
 // UpdateCustomer updates an existing customer
@@ -445,7 +636,8 @@ func (db *Database) UpdateCustomer(customer *Customer) error {
 			credit_score_encrypted = $17,
 			monthly_income_encrypted = $18,
 			pii_encryption_version = $19,
-			updated_at = $20
+			updated_at = $20,
+			date_of_birth = NULLIF($21, '')::date
 		WHERE id = $1
 	`
 
@@ -477,7 +669,7 @@ func (db *Database) UpdateCustomer(customer *Customer) error {
 		customer.State, customer.ZipCode,
 		plainCreditScore, plainSSN, plainDL, plainIncome,
 		ssnEncrypted, dlEncrypted, creditEncrypted, incomeEncrypted,
-		piiVersion, customer.UpdatedAt,
+		piiVersion, customer.UpdatedAt, customer.DateOfBirth,
 	)
 
 	if err != nil {
@@ -557,6 +749,7 @@ func (db *Database) AnonymizeCustomer(id string) error {
 			drivers_license_number_encrypted = NULL,
 			credit_score_encrypted = NULL,
 			monthly_income_encrypted = NULL,
+			date_of_birth = NULL,
 			anonymized_at = NOW(),
 			updated_at = NOW()
 		WHERE id = $1
@@ -576,6 +769,11 @@ func (db *Database) AnonymizeCustomer(id string) error {
 		return fmt.Errorf("customer not found: %s", id)
 	}
 
+	// Free-text tags may identify the customer
+	if _, err := db.conn.Exec(`DELETE FROM customer_tags WHERE customer_id = $1`, id); err != nil {
+		return fmt.Errorf("failed to remove customer tags: %w", err)
+	}
+
 	return nil
 }
 
@@ -722,3 +920,496 @@ func (db *Database) SetRetentionExpiry(id string, expiresAt time.Time) error {
 
 	return nil
 }
+
+// CreateSegment inserts a new segment definition
+func (db *Database) CreateSegment(segment *Segment) error {
+	filter, err := json.Marshal(segment.Filter)
+	if err != nil {
+		return fmt.Errorf("failed to encode segment filter: %w", err)
+	}
+
+	query := `
+		INSERT INTO customer_segments (id, dealership_id, name, description, channel, filter, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err = db.conn.Exec(query,
+		segment.ID, segment.DealershipID, segment.Name, segment.Description,
+		segment.Channel, filter, segment.CreatedAt, segment.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create segment: %w", err)
+	}
+
+	return nil
+}
+
+// GetSegment retrieves a segment by ID
+func (db *Database) GetSegment(id string) (*Segment, error) {
+	query := `
+		SELECT id, dealership_id, name, COALESCE(description, ''), channel, filter, created_at, updated_at
+		FROM customer_segments
+		WHERE id = $1
+	`
+
+	segment, err := scanSegment(db.conn.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get segment: %w", err)
+	}
+
+	return segment, nil
+}
+
+// ListSegments retrieves segments, optionally filtered by dealership
+func (db *Database) ListSegments(dealershipID string) ([]*Segment, error) {
+	var rows *sql.Rows
+	var err error
+
+	baseQuery := `
+		SELECT id, dealership_id, name, COALESCE(description, ''), channel, filter, created_at, updated_at
+		FROM customer_segments
+	`
+
+	if dealershipID != "" {
+		rows, err = db.conn.Query(baseQuery+" WHERE dealership_id = $1 ORDER BY name", dealershipID)
+	} else {
+		rows, err = db.conn.Query(baseQuery + " ORDER BY name")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list segments: %w", err)
+	}
+	defer rows.Close()
+
+	var segments []*Segment
+	for rows.Next() {
+		segment, err := scanSegment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan segment: %w", err)
+		}
+		segments = append(segments, segment)
+	}
+
+	return segments, rows.Err()
+}
+
+// ResolveSegment returns a page of customers matching the segment filter,
+// along with the total match count. Only customers who have opted into
+// marketing on the segment's channel and have contact details for it are
+// included; deleted and anonymized customers are always excluded.
+func (db *Database) ResolveSegment(segment *Segment, limit, offset int) ([]SegmentMember, int, error) {
+	channel, ok := segmentChannelConsent[segment.Channel]
+	if !ok {
+		return nil, 0, fmt.Errorf("unsupported segment channel: %s", segment.Channel)
+	}
+
+	args := []interface{}{segment.DealershipID}
+	condition, err := segment.Filter.toSQL(&args)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to compile segment filter: %w", err)
+	}
+
+	from := fmt.Sprintf(`
+		FROM customers c
+		JOIN customer_consent cc
+		  ON cc.customer_id::text = c.id AND cc.dealership_id::text = c.dealership_id
+		WHERE c.dealership_id = $1
+		  AND c.deleted_at IS NULL
+		  AND c.anonymized_at IS NULL
+		  AND cc.%s = true
+		  AND COALESCE(c.%s, '') <> ''
+		  AND %s
+	`, channel.consent, channel.contact, condition)
+
+	var total int
+	if err := db.conn.QueryRow("SELECT COUNT(*) "+from, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count segment members: %w", err)
+	}
+
+	pageArgs := append(args, limit, offset)
+	query := fmt.Sprintf(`
+		SELECT c.id, c.first_name, c.last_name, COALESCE(c.email, ''), COALESCE(c.phone, '')
+		%s
+		ORDER BY c.last_name, c.first_name, c.id
+		LIMIT $%d OFFSET $%d
+	`, from, len(pageArgs)-1, len(pageArgs))
+
+	rows, err := db.conn.Query(query, pageArgs...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to resolve segment: %w", err)
+	}
+	defer rows.Close()
+
+	var members []SegmentMember
+	for rows.Next() {
+		var m SegmentMember
+		if err := rows.Scan(&m.CustomerID, &m.FirstName, &m.LastName, &m.Email, &m.Phone); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan segment member: %w", err)
+		}
+		members = append(members, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to resolve segment: %w", err)
+	}
+
+	return members, total, nil
+}
+
+// segmentScanner is satisfied by *sql.Row and *sql.Rows
+type segmentScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSegment scans a customer_segments row
+func scanSegment(row segmentScanner) (*Segment, error) {
+	var segment Segment
+	var filter []byte
+
+	err := row.Scan(
+		&segment.ID, &segment.DealershipID, &segment.Name, &segment.Description,
+		&segment.Channel, &filter, &segment.CreatedAt, &segment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(filter, &segment.Filter); err != nil {
+		return nil, fmt.Errorf("failed to decode segment filter: %w", err)
+	}
+
+	return &segment, nil
+}
+
+// customerTagsColumn selects a customer's tags, alphabetically, from a query
+// over customers
+const customerTagsColumn = `ARRAY(SELECT t.tag FROM customer_tags t WHERE t.customer_id = customers.id ORDER BY LOWER(t.tag))`
+
+// AddCustomerTags tags a customer and returns all of the customer's tags.
+// Tags the customer already has, in any case, are left as they are.
+func (db *Database) AddCustomerTags(customerID, dealershipID, userID string, tags []string) ([]string, error) {
+	query := `
+		INSERT INTO customer_tags (customer_id, dealership_id, tag, created_by, created_at)
+		SELECT $1, $2, t.tag, NULLIF($3, ''), NOW()
+		FROM unnest($4::text[]) AS t(tag)
+		ON CONFLICT DO NOTHING
+	`
+
+	if _, err := db.conn.Exec(query, customerID, dealershipID, userID, pq.Array(tags)); err != nil {
+		return nil, fmt.Errorf("failed to tag customer: %w", err)
+	}
+
+	return db.getCustomerTags(customerID)
+}
+
+// RemoveCustomerTags removes tags, compared case-insensitively, from a
+// customer and returns the remaining tags
+func (db *Database) RemoveCustomerTags(customerID string, tags []string) ([]string, error) {
+	query := `
+		DELETE FROM customer_tags
+		WHERE customer_id = $1
+		  AND LOWER(tag) IN (SELECT LOWER(t) FROM unnest($2::text[]) AS t)
+	`
+
+	if _, err := db.conn.Exec(query, customerID, pq.Array(tags)); err != nil {
+		return nil, fmt.Errorf("failed to remove customer tags: %w", err)
+	}
+
+	return db.getCustomerTags(customerID)
+}
+
+func (db *Database) getCustomerTags(customerID string) ([]string, error) {
+	query := `SELECT tag FROM customer_tags WHERE customer_id = $1 ORDER BY LOWER(tag)`
+
+	rows, err := db.conn.Query(query, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("failed to scan customer tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// ListTagVocabulary returns the tags in use at a dealership, with the number
+// of active customers that have each
+func (db *Database) ListTagVocabulary(dealershipID string) ([]TagUsage, error) {
+	query := `
+		SELECT MIN(t.tag), COUNT(DISTINCT t.customer_id)
+		FROM customer_tags t
+		JOIN customers c ON c.id = t.customer_id
+		WHERE t.dealership_id = $1
+		  AND c.deleted_at IS NULL
+		  AND c.anonymized_at IS NULL
+		GROUP BY LOWER(t.tag)
+		ORDER BY LOWER(t.tag)
+	`
+
+	rows, err := db.conn.Query(query, dealershipID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []TagUsage
+	for rows.Next() {
+		var usage TagUsage
+		if err := rows.Scan(&usage.Tag, &usage.CustomerCount); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, usage)
+	}
+
+	return tags, rows.Err()
+}
+
+// BulkTagCustomers tags every active customer in a dealership matching the
+// filter and returns how many customers matched
+func (db *Database) BulkTagCustomers(dealershipID, userID string, filter SegmentFilter, tags []string) (int, error) {
+	args := []interface{}{dealershipID}
+	condition, err := filter.toSQL(&args)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compile tag filter: %w", err)
+	}
+
+	args = append(args, userID, pq.Array(tags))
+	query := fmt.Sprintf(`
+		WITH matched AS (
+			SELECT c.id
+			FROM customers c
+			WHERE c.dealership_id = $1
+			  AND c.deleted_at IS NULL
+			  AND c.anonymized_at IS NULL
+			  AND %s
+		), tagged AS (
+			INSERT INTO customer_tags (customer_id, dealership_id, tag, created_by, created_at)
+			SELECT m.id, $1, t.tag, NULLIF($%d, ''), NOW()
+			FROM matched m CROSS JOIN unnest($%d::text[]) AS t(tag)
+			ON CONFLICT DO NOTHING
+		)
+		SELECT COUNT(*) FROM matched
+	`, condition, len(args)-1, len(args))
+
+	var count int
+	if err := db.conn.QueryRow(query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to bulk tag customers: %w", err)
+	}
+
+	return count, nil
+}
+
+// ListMilestoneCandidates returns the customers with a date of birth or a
+// purchase, with their purchase dates and marketing email consent. Deleted
+// and anonymized customers are excluded.
+func (db *Database) ListMilestoneCandidates(dealershipID string) ([]MilestoneCandidate, error) {
+	rows, err := db.conn.Query(`
+		SELECT c.id, c.dealership_id, c.first_name, c.last_name, COALESCE(c.email, ''),
+		       c.date_of_birth, COALESCE(cc.marketing_email, false),
+		       ARRAY(
+		           SELECT TO_CHAR(COALESCE(d.funded_at, d.created_at), 'YYYY-MM-DD') FROM deals d
+		           WHERE (d.customer_id = c.id OR d.co_customer_id = c.id)
+		             AND d.dealership_id = c.dealership_id
+		             AND d.status IN ('funded', 'delivered')
+		       )
+		FROM customers c
+		LEFT JOIN customer_consent cc
+		  ON cc.customer_id::text = c.id AND cc.dealership_id::text = c.dealership_id
+		WHERE ($1 = '' OR c.dealership_id = $1)
+		  AND c.deleted_at IS NULL
+		  AND c.anonymized_at IS NULL
+		  AND (c.date_of_birth IS NOT NULL OR EXISTS (
+		      SELECT 1 FROM deals d
+		      WHERE (d.customer_id = c.id OR d.co_customer_id = c.id)
+		        AND d.dealership_id = c.dealership_id
+		        AND d.status IN ('funded', 'delivered')
+		  ))
+	`, dealershipID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list milestone candidates: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []MilestoneCandidate
+	for rows.Next() {
+		var c MilestoneCandidate
+		var dob sql.NullTime
+		var purchases []string
+		if err := rows.Scan(&c.CustomerID, &c.DealershipID, &c.FirstName, &c.LastName, &c.Email,
+			&dob, &c.MarketingEmail, pq.Array(&purchases)); err != nil {
+			return nil, fmt.Errorf("failed to scan milestone candidate: %w", err)
+		}
+		if dob.Valid {
+			c.DateOfBirth = &dob.Time
+		}
+		for _, p := range purchases {
+			purchased, err := time.Parse(dateOfBirthLayout, p)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse purchase date %q: %w", p, err)
+			}
+			c.PurchaseDates = append(c.PurchaseDates, purchased)
+		}
+		candidates = append(candidates, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list milestone candidates: %w", err)
+	}
+
+	return candidates, nil
+}
+
+// WriteMilestoneEvents adds milestone events to the outbox. Events already
+// written for the same milestone are skipped.
+func (db *Database) WriteMilestoneEvents(events []*outbox.Event) error {
+	ctx := context.Background()
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, event := range events {
+		if err := outbox.Write(ctx, tx, event); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit milestone events: %w", err)
+	}
+	return nil
+}
+
+// MergeCustomers moves everything linked to the duplicate customer to the
+// survivor and soft-deletes the duplicate, in one transaction. Both must be
+// live customers of the dealership. Email logs are linked to customers by
+// recipient address, so they follow the survivor when it has the
+// duplicate's address; a survivor with no email inherits the duplicate's.
+func (db *Database) MergeCustomers(survivorID, duplicateID, dealershipID string) (*CustomerMergeSummary, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock both customers so neither changes or is merged elsewhere meanwhile
+	rows, err := tx.Query(`
+		SELECT id, dealership_id, COALESCE(email, ''), COALESCE(phone, '')
+		FROM customers
+		WHERE id IN ($1, $2) AND deleted_at IS NULL
+		ORDER BY id
+		FOR UPDATE
+	`, survivorID, duplicateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock customers: %w", err)
+	}
+	type mergeCustomer struct{ dealershipID, email, phone string }
+	customers := make(map[string]mergeCustomer)
+	for rows.Next() {
+		var id string
+		var c mergeCustomer
+		if err := rows.Scan(&id, &c.dealershipID, &c.email, &c.phone); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan customer: %w", err)
+		}
+		customers[id] = c
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to lock customers: %w", err)
+	}
+
+	survivor, ok := customers[survivorID]
+	duplicate, dupOK := customers[duplicateID]
+	if !ok || !dupOK {
+		return nil, ErrMergeCustomerNotFound
+	}
+	if survivor.dealershipID != dealershipID || duplicate.dealershipID != dealershipID {
+		return nil, ErrMergeCrossDealership
+	}
+
+	summary := &CustomerMergeSummary{SurvivorID: survivorID, DuplicateID: duplicateID}
+	statements := []struct {
+		count *int
+		query string
+		args  []interface{}
+	}{
+		// A deal with both customers on it keeps the survivor once, as
+		// buyer. Deal versions are bumped so open edits of these deals fail.
+		{&summary.CoBuyerDeals, `
+			UPDATE deals SET co_customer_id = NULL, version = version + 1, updated_at = NOW()
+			WHERE customer_id = $1 AND co_customer_id = $2 AND dealership_id = $3
+		`, []interface{}{survivorID, duplicateID, dealershipID}},
+		{&summary.Deals, `
+			UPDATE deals SET customer_id = $1, co_customer_id = NULL, version = version + 1, updated_at = NOW()
+			WHERE customer_id = $2 AND co_customer_id = $1 AND dealership_id = $3
+		`, []interface{}{survivorID, duplicateID, dealershipID}},
+		{&summary.Deals, `
+			UPDATE deals SET customer_id = $1, version = version + 1, updated_at = NOW()
+			WHERE customer_id = $2 AND dealership_id = $3
+		`, []interface{}{survivorID, duplicateID, dealershipID}},
+		{&summary.CoBuyerDeals, `
+			UPDATE deals SET co_customer_id = $1, version = version + 1, updated_at = NOW()
+			WHERE co_customer_id = $2 AND dealership_id = $3
+		`, []interface{}{survivorID, duplicateID, dealershipID}},
+		{&summary.ShowroomVisits, `
+			UPDATE showroom_visits SET customer_id = $1, updated_at = NOW()
+			WHERE customer_id = $2 AND dealership_id = $3
+		`, []interface{}{survivorID, duplicateID, dealershipID}},
+		{&summary.Tags, `
+			INSERT INTO customer_tags (customer_id, dealership_id, tag, created_by, created_at)
+			SELECT $1, dealership_id, tag, created_by, created_at
+			FROM customer_tags
+			WHERE customer_id = $2
+			ON CONFLICT DO NOTHING
+		`, []interface{}{survivorID, duplicateID}},
+		{nil, `DELETE FROM customer_tags WHERE customer_id = $1`, []interface{}{duplicateID}},
+		// The survivor keeps its own contact details, filling gaps from the
+		// duplicate's
+		{nil, `
+			UPDATE customers SET
+				email = COALESCE(NULLIF(email, ''), NULLIF($2, '')),
+				phone = COALESCE(NULLIF(phone, ''), NULLIF($3, '')),
+				updated_at = NOW()
+			WHERE id = $1
+		`, []interface{}{survivorID, duplicate.email, duplicate.phone}},
+		{nil, `UPDATE customers SET deleted_at = NOW(), updated_at = NOW() WHERE id = $1`, []interface{}{duplicateID}},
+	}
+	for _, stmt := range statements {
+		result, err := tx.Exec(stmt.query, stmt.args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to merge customers: %w", err)
+		}
+		if stmt.count == nil {
+			continue
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		*stmt.count += int(n)
+	}
+
+	if duplicate.email != "" && (survivor.email == "" || strings.EqualFold(survivor.email, duplicate.email)) {
+		err := tx.QueryRow(`
+			SELECT COUNT(*) FROM email_logs
+			WHERE LOWER(recipient_email) = LOWER($1) AND dealership_id = $2
+		`, duplicate.email, dealershipID).Scan(&summary.EmailLogs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count email logs: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit customer merge: %w", err)
+	}
+	return summary, nil
+}
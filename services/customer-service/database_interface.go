@@ -1,14 +1,22 @@
 package main
 
-import "time"
+import (
+	"context"
+	"time"
+
+	"autolytiq/shared/outbox"
+)
 
 // CustomerDatabase defines the interface for customer database operations
 type CustomerDatabase interface {
 	Close() error
 	InitSchema() error
+
+	// Ping checks the database is reachable, for health checks
+	Ping(ctx context.Context) error
 	CreateCustomer(customer *Customer) error
 	GetCustomer(id string) (*Customer, error)
-	ListCustomers(dealershipID string) ([]*Customer, error)
+	ListCustomers(filter CustomerListFilter) (*CustomerListResult, error)
 	UpdateCustomer(customer *Customer) error
 	DeleteCustomer(id string) error
 
@@ -18,6 +26,53 @@ type CustomerDatabase interface {
 	GetCustomerWithGDPRFields(id string) (*CustomerWithGDPR, error)
 	UpdateLastActivity(id string) error
 	SetRetentionExpiry(id string, expiresAt time.Time) error
+
+	// Segments
+	CreateSegment(segment *Segment) error
+	GetSegment(id string) (*Segment, error)
+	ListSegments(dealershipID string) ([]*Segment, error)
+	ResolveSegment(segment *Segment, limit, offset int) ([]SegmentMember, int, error)
+
+	// Tags
+	AddCustomerTags(customerID, dealershipID, userID string, tags []string) ([]string, error)
+	RemoveCustomerTags(customerID string, tags []string) ([]string, error)
+	ListTagVocabulary(dealershipID string) ([]TagUsage, error)
+	BulkTagCustomers(dealershipID, userID string, filter SegmentFilter, tags []string) (int, error)
+
+	// Milestones. An empty dealership ID lists candidates across dealerships.
+	ListMilestoneCandidates(dealershipID string) ([]MilestoneCandidate, error)
+	WriteMilestoneEvents(events []*outbox.Event) error
+
+	// GetCustomerPII returns a customer's stored PII ciphertext, or nil if
+	// the customer does not exist
+	GetCustomerPII(id string) (*CustomerPII, error)
+
+	// PII key rotation. ListCustomerPII pages through customers with
+	// encrypted PII by ID.
+	ListCustomerPII(afterID string, limit int) ([]*CustomerPII, error)
+	ReplaceCustomerPII(before, after *CustomerPII) (bool, error)
+
+	// MergeCustomers moves the duplicate's related records to the survivor
+	// and soft-deletes the duplicate, all or nothing
+	MergeCustomers(survivorID, duplicateID, dealershipID string) (*CustomerMergeSummary, error)
+}
+
+// CustomerListFilter narrows ListCustomers. Empty fields match everything,
+// and a zero Limit returns every match.
+type CustomerListFilter struct {
+	DealershipID string
+	Tag          string
+	Query        string // Case-insensitive partial match on first name, last name or email
+	Limit        int
+	Offset       int
+}
+
+// CustomerListResult represents paginated customer results
+type CustomerListResult struct {
+	Customers  []*Customer `json:"customers"`
+	Total      int         `json:"total"`
+	HasMore    bool        `json:"has_more"`
+	NextOffset int         `json:"next_offset"`
 }
 
 // CustomerWithGDPR extends Customer with GDPR-specific fields
@@ -3,8 +3,15 @@ module autolytiq/customer-service
 go 1.18
 
 require (
+	autolytiq/shared/apierror v0.0.0
+	autolytiq/shared/bodylimit v0.0.0
 	autolytiq/shared/encryption v0.0.0
+	autolytiq/shared/graceful v0.0.0
+	autolytiq/shared/health v0.0.0
+	autolytiq/shared/httpmetrics v0.0.0
 	autolytiq/shared/logging v0.0.0
+	autolytiq/shared/outbox v0.0.0
+	autolytiq/shared/tracing v0.0.0
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/mux v1.8.1
 	github.com/lib/pq v1.10.9
@@ -45,3 +52,17 @@ replace autolytiq/shared/logging => ../shared/logging
 replace autolytiq/shared/encryption => ../shared/encryption
 
 replace autolytiq/shared/secrets => ../shared/secrets
+
+replace autolytiq/shared/outbox => ../shared/outbox
+
+replace autolytiq/shared/health => ../shared/health
+
+replace autolytiq/shared/graceful => ../shared/graceful
+
+replace autolytiq/shared/apierror => ../shared/apierror
+
+replace autolytiq/shared/bodylimit => ../shared/bodylimit
+
+replace autolytiq/shared/httpmetrics => ../shared/httpmetrics
+
+replace autolytiq/shared/tracing => ../shared/tracing
@@ -1,20 +1,36 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/bodylimit"
+	"autolytiq/shared/graceful"
+	"autolytiq/shared/health"
+	"autolytiq/shared/httpmetrics"
 	"autolytiq/shared/logging"
+	"autolytiq/shared/tracing"
 
 	"autolytiq/shared/encryption"
+	"autolytiq/shared/outbox"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
+// Customer list page sizes
+const (
+	defaultCustomerListLimit = 50
+	maxCustomerListLimit     = 200
+)
+
 // Customer represents a customer entity with PII fields
 type Customer struct {
 	ID                   string    `json:"id"`
@@ -31,12 +47,20 @@ type Customer struct {
 	SSNLast4             string    `json:"ssn_last4,omitempty"`
 	DriversLicenseNumber string    `json:"drivers_license_number,omitempty"`
 	MonthlyIncome        float64   `json:"monthly_income,omitempty"`
+	Tags                 []string  `json:"tags"`
 	CreatedAt            time.Time `json:"created_at"`
 	UpdatedAt            time.Time `json:"updated_at"`
+
+	// DateOfBirth is in YYYY-MM-DD format, empty when unknown
+	DateOfBirth string `json:"date_of_birth,omitempty"`
+
+	// PIIRedacted is set when sensitive PII was masked for the caller's role
+	PIIRedacted bool `json:"pii_redacted,omitempty"`
 }
 
 // CustomerPII holds the encrypted PII fields (internal use)
 type CustomerPII struct {
+	CustomerID                    string
 	SSNLast4Encrypted             string
 	DriversLicenseNumberEncrypted string
 	CreditScoreEncrypted          string
@@ -50,6 +74,12 @@ type Config struct {
 	DatabaseURL    string
 	EncryptionKey  string
 	EncryptEnabled bool
+
+	// MilestoneEventsURL receives customer milestone events. The milestone
+	// job only runs when it is set.
+	MilestoneEventsURL   string
+	MilestoneLeadDays    int
+	MilestoneJobInterval time.Duration
 }
 
 // Server represents the Customer service server
@@ -59,15 +89,24 @@ type Server struct {
 	db        CustomerDatabase
 	encryptor *encryption.FieldEncryptor
 	logger    *logging.Logger
+	health    *health.HealthChecker
+	metrics   *httpmetrics.Metrics
+	tracer    *tracing.Tracer
 }
 
 // NewServer creates a new Customer service server
 func NewServer(config *Config, db CustomerDatabase, logger *logging.Logger) *Server {
 	s := &Server{
-		router: mux.NewRouter(),
-		config: config,
-		db:     db,
-		logger: logger,
+		router:  mux.NewRouter(),
+		config:  config,
+		db:      db,
+		logger:  logger,
+		health:  health.NewHealthChecker("customer-service", db.Ping),
+		metrics: httpmetrics.New("customer-service"),
+		tracer: tracing.New(tracing.Config{
+			Service: "customer-service",
+			OnError: func(err error) { logger.WithError(err).Warn("Failed to export trace spans") },
+		}),
 	}
 
 	// Initialize encryption if enabled
@@ -88,49 +127,99 @@ func NewServer(config *Config, db CustomerDatabase, logger *logging.Logger) *Ser
 
 // setupMiddleware configures middleware
 func (s *Server) setupMiddleware() {
+	s.router.Use(s.tracer.Middleware)
 	s.router.Use(logging.RequestIDMiddleware)
 	s.router.Use(logging.RequestLoggingMiddleware(s.logger))
+	s.router.Use(s.metrics.Middleware)
+	s.router.Use(bodylimit.Middleware(bodylimit.Config{
+		BulkRoutes: []string{"/customers/bulk-tags"},
+	}))
 }
 
 // setupRoutes configures all routes
 func (s *Server) setupRoutes() {
-	s.router.HandleFunc("/health", s.healthCheck).Methods("GET")
+	s.router.Handle("/health", s.health).Methods("GET")
+	s.router.Handle("/metrics", s.metrics.Handler()).Methods("GET")
+	s.router.HandleFunc("/ready", s.health.ReadinessHandler).Methods("GET")
+	s.router.HandleFunc("/live", s.health.LivenessHandler).Methods("GET")
 	s.router.HandleFunc("/customers", s.listCustomers).Methods("GET")
 	s.router.HandleFunc("/customers", s.createCustomer).Methods("POST")
+
+	// Segments, tags, milestones and admin jobs are registered before
+	// /customers/{id} so their paths are not taken as customer IDs
+	s.router.HandleFunc("/customers/segments", s.listSegments).Methods("GET")
+	s.router.HandleFunc("/customers/segments", s.createSegment).Methods("POST")
+	s.router.HandleFunc("/customers/segments/{id}", s.getSegment).Methods("GET")
+	s.router.HandleFunc("/customers/segments/{id}/members", s.getSegmentMembers).Methods("GET")
+	s.router.HandleFunc("/customers/tags", s.listTags).Methods("GET")
+	s.router.HandleFunc("/customers/bulk-tags", s.bulkTagCustomers).Methods("POST")
+	s.router.HandleFunc("/customers/upcoming-milestones", s.getUpcomingMilestones).Methods("GET")
+	s.router.HandleFunc("/customers/admin/reencrypt", s.reencryptPII).Methods("POST")
+
 	s.router.HandleFunc("/customers/{id}", s.getCustomer).Methods("GET")
 	s.router.HandleFunc("/customers/{id}", s.updateCustomer).Methods("PUT")
 	s.router.HandleFunc("/customers/{id}", s.deleteCustomer).Methods("DELETE")
-}
+	s.router.HandleFunc("/customers/{id}/tags", s.addCustomerTags).Methods("POST")
+	s.router.HandleFunc("/customers/{id}/tags", s.removeCustomerTags).Methods("DELETE")
+	s.router.HandleFunc("/customers/{id}/merge", s.mergeCustomers).Methods("POST")
+	s.router.HandleFunc("/customers/{id}/pii-health", s.getCustomerPIIHealth).Methods("GET")
 
-// healthCheck handler
-func (s *Server) healthCheck(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":    "healthy",
-		"service":   "customer-service",
-		"timestamp": time.Now().Format(time.RFC3339),
-	})
+	// Internal routes for other services; the gateway does not proxy these
+	s.router.HandleFunc("/internal/customers/{id}/export", s.exportCustomer).Methods("GET")
 }
 
-// listCustomers returns all customers
+// listCustomers returns a page of customers, paginated with limit and offset.
+// paginated=false returns every customer as a bare array, the response
+// before pagination; it is deprecated and will be removed.
 func (s *Server) listCustomers(w http.ResponseWriter, r *http.Request) {
-	// Optional dealership filter
-	dealershipID := r.URL.Query().Get("dealership_id")
+	query := r.URL.Query()
+
+	// Optional dealership, tag and name or email search filters
+	filter := CustomerListFilter{
+		DealershipID: query.Get("dealership_id"),
+		Tag:          normalizeTag(query.Get("tag")),
+		Query:        strings.TrimSpace(query.Get("q")),
+	}
+
+	paginated := query.Get("paginated") != "false"
+	if paginated {
+		filter.Limit = defaultCustomerListLimit
+		if l := query.Get("limit"); l != "" {
+			if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+				filter.Limit = parsed
+			}
+		}
+		if filter.Limit > maxCustomerListLimit {
+			filter.Limit = maxCustomerListLimit
+		}
+		if o := query.Get("offset"); o != "" {
+			if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+				filter.Offset = parsed
+			}
+		}
+	}
 
-	customers, err := s.db.ListCustomers(dealershipID)
+	result, err := s.db.ListCustomers(filter)
 	if err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list customers")
-		http.Error(w, fmt.Sprintf("Failed to list customers: %v", err), http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to list customers: %v", err))
 		return
 	}
 
-	if customers == nil {
-		customers = []*Customer{}
+	if result.Customers == nil {
+		result.Customers = []*Customer{}
+	}
+	role := logging.GetUserRole(r.Context())
+	for i, customer := range result.Customers {
+		result.Customers[i] = customer.Redact(role)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(customers)
+	if !paginated {
+		json.NewEncoder(w).Encode(result.Customers)
+		return
+	}
+	json.NewEncoder(w).Encode(result)
 }
 
 // createCustomer creates a new customer
@@ -156,6 +245,8 @@ func (s *Server) createCustomer(w http.ResponseWriter, r *http.Request) {
 		DriversLicenseNumber: req.DriversLicenseNumber,
 		CreditScore:          req.CreditScore,
 		MonthlyIncome:        req.MonthlyIncome,
+		DateOfBirth:          req.DateOfBirth,
+		Tags:                 []string{},
 		CreatedAt:            time.Now(),
 		UpdatedAt:            time.Now(),
 	}
@@ -163,7 +254,7 @@ func (s *Server) createCustomer(w http.ResponseWriter, r *http.Request) {
 	// Save to database
 	if err := s.db.CreateCustomer(&customer); err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to create customer")
-		http.Error(w, fmt.Sprintf("Failed to create customer: %v", err), http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to create customer: %v", err))
 		return
 	}
 
@@ -182,17 +273,17 @@ func (s *Server) getCustomer(w http.ResponseWriter, r *http.Request) {
 	customer, err := s.db.GetCustomer(id)
 	if err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get customer")
-		http.Error(w, fmt.Sprintf("Failed to get customer: %v", err), http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get customer: %v", err))
 		return
 	}
 
 	if customer == nil {
-		http.Error(w, "Customer not found", http.StatusNotFound)
+		apierror.WriteError(w, http.StatusNotFound, "CUSTOMER_NOT_FOUND", "Customer not found")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(customer)
+	json.NewEncoder(w).Encode(customer.Redact(logging.GetUserRole(r.Context())))
 }
 
 // updateCustomer updates a specific customer
@@ -213,11 +304,11 @@ func (s *Server) updateCustomer(w http.ResponseWriter, r *http.Request) {
 	existingCustomer, err := s.db.GetCustomer(id)
 	if err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get customer")
-		http.Error(w, fmt.Sprintf("Failed to get customer: %v", err), http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get customer: %v", err))
 		return
 	}
 	if existingCustomer == nil {
-		http.Error(w, "Customer not found", http.StatusNotFound)
+		apierror.WriteError(w, http.StatusNotFound, "CUSTOMER_NOT_FOUND", "Customer not found")
 		return
 	}
 
@@ -258,14 +349,17 @@ func (s *Server) updateCustomer(w http.ResponseWriter, r *http.Request) {
 	if req.MonthlyIncome > 0 {
 		existingCustomer.MonthlyIncome = req.MonthlyIncome
 	}
+	if req.DateOfBirth != "" {
+		existingCustomer.DateOfBirth = req.DateOfBirth
+	}
 	existingCustomer.UpdatedAt = time.Now()
 
 	if err := s.db.UpdateCustomer(existingCustomer); err != nil {
 		if err.Error() == fmt.Sprintf("customer not found: %s", id) {
-			http.Error(w, "Customer not found", http.StatusNotFound)
+			apierror.WriteError(w, http.StatusNotFound, "CUSTOMER_NOT_FOUND", "Customer not found")
 		} else {
 			s.logger.WithContext(r.Context()).WithError(err).Error("Failed to update customer")
-			http.Error(w, fmt.Sprintf("Failed to update customer: %v", err), http.StatusInternalServerError)
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to update customer: %v", err))
 		}
 		return
 	}
@@ -283,10 +377,10 @@ func (s *Server) deleteCustomer(w http.ResponseWriter, r *http.Request) {
 
 	if err := s.db.DeleteCustomer(id); err != nil {
 		if err.Error() == fmt.Sprintf("customer not found: %s", id) {
-			http.Error(w, "Customer not found", http.StatusNotFound)
+			apierror.WriteError(w, http.StatusNotFound, "CUSTOMER_NOT_FOUND", "Customer not found")
 		} else {
 			s.logger.WithContext(r.Context()).WithError(err).Error("Failed to delete customer")
-			http.Error(w, fmt.Sprintf("Failed to delete customer: %v", err), http.StatusInternalServerError)
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to delete customer: %v", err))
 		}
 		return
 	}
@@ -296,10 +390,12 @@ func (s *Server) deleteCustomer(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// Start starts the Customer service server
-func (s *Server) Start() error {
+// Start serves the Customer service until ctx is cancelled, then waits for
+// in-flight requests to finish
+func (s *Server) Start(ctx context.Context) error {
 	s.logger.Infof("Starting Customer Service on port %s", s.config.Port)
-	return http.ListenAndServe(":"+s.config.Port, s.router)
+	srv := &http.Server{Addr: ":" + s.config.Port, Handler: s.router}
+	return graceful.ListenAndServe(ctx, srv, graceful.DefaultTimeout)
 }
 
 func loadConfig() *Config {
@@ -308,6 +404,10 @@ func loadConfig() *Config {
 		DatabaseURL:    getEnv("DATABASE_URL", "postgresql://localhost:5432/autolytiq"),
 		EncryptionKey:  os.Getenv("PII_ENCRYPTION_KEY"),
 		EncryptEnabled: os.Getenv("PII_ENCRYPTION_KEY") != "",
+
+		MilestoneEventsURL:   os.Getenv("MILESTONE_EVENTS_URL"),
+		MilestoneLeadDays:    getEnvInt("MILESTONE_LEAD_DAYS", 0),
+		MilestoneJobInterval: time.Duration(getEnvInt("MILESTONE_JOB_INTERVAL_MINUTES", 60)) * time.Minute,
 	}
 }
 
@@ -318,6 +418,15 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
 func main() {
 	// Initialize logger
 	logger := logging.New(logging.Config{
@@ -344,14 +453,47 @@ func main() {
 		}
 	}
 
+	// Stop serving on SIGINT or SIGTERM, once in-flight requests finish
+	ctx, stop := graceful.SignalContext(context.Background())
+	defer stop()
+
+	// Start serving before the schema is initialized, so /live answers and
+	// /ready reports not ready until startup finishes
+	server := NewServer(config, db, logger)
+	defer server.tracer.Shutdown(context.Background())
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.Start(ctx) }()
+
 	// Initialize schema
 	if err := db.InitSchema(); err != nil {
 		logger.Fatalf("Failed to initialize schema: %v", err)
 	}
+	server.health.MarkReady()
+
+	// Emit birthday and purchase anniversary events, delivered through the
+	// outbox to the configured consumer
+	if config.MilestoneEventsURL != "" {
+		milestones := NewMilestoneJob(db, config.MilestoneJobInterval, config.MilestoneLeadDays, logger)
+		milestones.Start()
+		defer milestones.Stop()
+
+		dispatcherConfig := outbox.DefaultDispatcherConfig()
+		dispatcherConfig.OnError = func(event *outbox.Event, err error, dead bool) {
+			logger.WithError(err).WithFields(map[string]interface{}{
+				"event_id": event.ID,
+				"dead":     dead,
+			}).Warn("Failed to publish outbox event")
+		}
+		dispatcher := outbox.NewDispatcher(
+			outbox.NewSQLStore(db.conn),
+			outbox.NewHTTPPublisher(map[string]string{MilestoneEventTopic: config.MilestoneEventsURL}, 10*time.Second),
+			dispatcherConfig,
+		)
+		dispatcher.Start()
+		defer dispatcher.Stop()
+	}
 
-	// Create and start server
-	server := NewServer(config, db, logger)
-	if err := server.Start(); err != nil {
+	if err := <-serveErr; err != nil {
 		logger.Fatal(err.Error())
 	}
 }
@@ -2,24 +2,40 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	"autolytiq/shared/logging"
+	"autolytiq/shared/outbox"
+
 	"github.com/google/uuid"
 )
 
-// MockDatabase is a mock implementation of the Database for testing
+// MockDatabase is a mock implementation of the Database for testing. Methods
+// not overridden below panic via the embedded nil interface.
 type MockDatabase struct {
+	CustomerDatabase
 	customers map[string]*Customer
+
+	milestoneCandidates []MilestoneCandidate
+	milestoneEvents     map[string]*outbox.Event
+
+	// pingErr is returned by Ping, as if the database were down
+	pingErr error
 }
 
 func NewMockDatabase() *MockDatabase {
 	return &MockDatabase{
-		customers: make(map[string]*Customer),
+		customers:       make(map[string]*Customer),
+		milestoneEvents: make(map[string]*outbox.Event),
 	}
 }
 
@@ -27,6 +43,10 @@ func (db *MockDatabase) Close() error {
 	return nil
 }
 
+func (db *MockDatabase) Ping(ctx context.Context) error {
+	return db.pingErr
+}
+
 func (db *MockDatabase) InitSchema() error {
 	return nil
 }
@@ -44,14 +64,49 @@ func (db *MockDatabase) GetCustomer(id string) (*Customer, error) {
 	return customer, nil
 }
 
-func (db *MockDatabase) ListCustomers(dealershipID string) ([]*Customer, error) {
+func (db *MockDatabase) ListCustomers(filter CustomerListFilter) (*CustomerListResult, error) {
 	var customers []*Customer
 	for _, customer := range db.customers {
-		if dealershipID == "" || customer.DealershipID == dealershipID {
-			customers = append(customers, customer)
+		if filter.DealershipID != "" && customer.DealershipID != filter.DealershipID {
+			continue
+		}
+		if filter.Tag != "" && !hasTag(customer.Tags, filter.Tag) {
+			continue
 		}
+		if q := strings.ToLower(filter.Query); q != "" &&
+			!strings.Contains(strings.ToLower(customer.FirstName), q) &&
+			!strings.Contains(strings.ToLower(customer.LastName), q) &&
+			!strings.Contains(strings.ToLower(customer.Email), q) {
+			continue
+		}
+		customers = append(customers, customer)
 	}
-	return customers, nil
+	sort.Slice(customers, func(i, j int) bool {
+		if customers[i].LastName != customers[j].LastName {
+			return customers[i].LastName < customers[j].LastName
+		}
+		if customers[i].FirstName != customers[j].FirstName {
+			return customers[i].FirstName < customers[j].FirstName
+		}
+		return customers[i].ID < customers[j].ID
+	})
+
+	total := len(customers)
+	start := filter.Offset
+	if start > total {
+		start = total
+	}
+	end := total
+	if filter.Limit > 0 && start+filter.Limit < total {
+		end = start + filter.Limit
+	}
+	page := customers[start:end]
+	return &CustomerListResult{
+		Customers:  page,
+		Total:      total,
+		HasMore:    start+len(page) < total,
+		NextOffset: start + len(page),
+	}, nil
 }
 
 func (db *MockDatabase) UpdateCustomer(customer *Customer) error {
@@ -76,7 +131,27 @@ func setupTestServer() *Server {
 		DatabaseURL: "mock",
 	}
 	db := NewMockDatabase()
-	return NewServer(config, db)
+	return NewServer(config, db, testLogger())
+}
+
+// errorResponse is the body of error responses written by apierror, with
+// validation details
+type errorResponse struct {
+	Error struct {
+		Code      string            `json:"code"`
+		Message   string            `json:"message"`
+		RequestID string            `json:"request_id"`
+		Details   []ValidationError `json:"details"`
+	} `json:"error"`
+}
+
+// testLogger creates a logger that discards output during tests
+func testLogger() *logging.Logger {
+	return logging.New(logging.Config{
+		Service: "customer-service-test",
+		Level:   logging.LevelError,
+		Output:  &bytes.Buffer{},
+	})
 }
 
 func TestHealthCheck(t *testing.T) {
@@ -105,6 +180,65 @@ func TestHealthCheck(t *testing.T) {
 	}
 }
 
+func TestHealthCheckDatabaseDown(t *testing.T) {
+	server := setupTestServer()
+	server.db.(*MockDatabase).pingErr = errors.New("connection refused")
+
+	req, err := http.NewRequest("GET", "/health", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 with the database down, got %d", rr.Code)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response["status"] != "unhealthy" || response["db"] != "down" {
+		t.Errorf("Expected an unhealthy status with the database down, got %v", response)
+	}
+}
+
+func TestReadinessCheck(t *testing.T) {
+	server := setupTestServer()
+
+	getStatus := func(path string) int {
+		req, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// Not ready until the schema has been initialized
+	if code := getStatus("/ready"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 before startup finished, got %d", code)
+	}
+	if code := getStatus("/live"); code != http.StatusOK {
+		t.Errorf("Expected /live to answer during startup, got %d", code)
+	}
+
+	server.health.MarkReady()
+	if code := getStatus("/ready"); code != http.StatusOK {
+		t.Errorf("Expected 200 once ready, got %d", code)
+	}
+
+	server.db.(*MockDatabase).pingErr = errors.New("connection refused")
+	if code := getStatus("/ready"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 with the database down, got %d", code)
+	}
+	if code := getStatus("/live"); code != http.StatusOK {
+		t.Errorf("Expected /live to ignore the database, got %d", code)
+	}
+}
+
 func TestCreateCustomer(t *testing.T) {
 	server := setupTestServer()
 
@@ -113,7 +247,7 @@ func TestCreateCustomer(t *testing.T) {
 		FirstName:    "John",
 		LastName:     "Doe",
 		Email:        "john.doe@example.com",
-		Phone:        "555-1234",
+		Phone:        "317-555-1234",
 		Address:      "123 Main St",
 		City:         "Indianapolis",
 		State:        "IN",
@@ -170,7 +304,7 @@ func TestGetCustomer(t *testing.T) {
 		FirstName:    "Jane",
 		LastName:     "Smith",
 		Email:        "jane.smith@example.com",
-		Phone:        "555-5678",
+		Phone:        "317-555-5678",
 		CreditScore:  680,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
@@ -254,13 +388,128 @@ func TestListCustomers(t *testing.T) {
 			status, http.StatusOK)
 	}
 
+	var result CustomerListResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Customers) != 3 || result.Total != 3 || result.HasMore {
+		t.Errorf("Expected all 3 customers on one page, got %d of %d", len(result.Customers), result.Total)
+	}
+}
+
+func TestListCustomersPagination(t *testing.T) {
+	server := setupTestServer()
+	mockDB := server.db.(*MockDatabase)
+
+	dealershipID := uuid.New().String()
+	for i := 0; i < 250; i++ {
+		customer := &Customer{
+			ID:           uuid.New().String(),
+			DealershipID: dealershipID,
+			FirstName:    fmt.Sprintf("Customer%03d", i),
+			LastName:     "Test",
+		}
+		mockDB.customers[customer.ID] = customer
+	}
+	// Another dealership's customer isn't counted
+	other := &Customer{ID: uuid.New().String(), DealershipID: uuid.New().String(), FirstName: "Other"}
+	mockDB.customers[other.ID] = other
+
+	list := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/customers?dealership_id="+dealershipID+query, nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200 for %q, got %d: %s", query, rr.Code, rr.Body.String())
+		}
+		return rr
+	}
+	page := func(query string) CustomerListResult {
+		var result CustomerListResult
+		if err := json.Unmarshal(list(query).Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	tests := []struct {
+		query   string
+		count   int
+		first   string
+		hasMore bool
+	}{
+		{"", 50, "Customer000", true},
+		{"&limit=10&offset=20", 10, "Customer020", true},
+		{"&limit=1000", 200, "Customer000", true},
+		{"&limit=100&offset=200", 50, "Customer200", false},
+		{"&offset=300", 0, "", false},
+	}
+	for _, tt := range tests {
+		result := page(tt.query)
+		if len(result.Customers) != tt.count || result.Total != 250 || result.HasMore != tt.hasMore {
+			t.Errorf("%q: expected %d customers of 250 with has_more=%v, got %d of %d with has_more=%v",
+				tt.query, tt.count, tt.hasMore, len(result.Customers), result.Total, result.HasMore)
+			continue
+		}
+		if tt.count > 0 && result.Customers[0].FirstName != tt.first {
+			t.Errorf("%q: expected the page to start at %s, got %s", tt.query, tt.first, result.Customers[0].FirstName)
+		}
+	}
+
+	// The unpaginated response is the bare array of every customer
 	var customers []*Customer
-	if err := json.Unmarshal(rr.Body.Bytes(), &customers); err != nil {
+	if err := json.Unmarshal(list("&paginated=false").Body.Bytes(), &customers); err != nil {
 		t.Fatal(err)
 	}
+	if len(customers) != 250 {
+		t.Errorf("Expected all 250 customers unpaginated, got %d", len(customers))
+	}
+}
+
+func TestListCustomersSearch(t *testing.T) {
+	server := setupTestServer()
+	mockDB := server.db.(*MockDatabase)
 
-	if len(customers) != 3 {
-		t.Errorf("Expected 3 customers, got %d", len(customers))
+	dealershipID := uuid.New().String()
+	for _, c := range []struct{ first, last, email string }{
+		{"Maria", "Smith", "maria@example.com"},
+		{"John", "Smithers", "jsmith@example.com"},
+		{"Anna", "Lee", "anna.lee@example.com"},
+		{"Smitty", "Brown", "brown@example.com"},
+	} {
+		customer := &Customer{ID: uuid.New().String(), DealershipID: dealershipID, FirstName: c.first, LastName: c.last, Email: c.email}
+		mockDB.customers[customer.ID] = customer
+	}
+
+	search := func(q string) []string {
+		req := httptest.NewRequest("GET", "/customers?dealership_id="+dealershipID+"&q="+q, nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200 searching %q, got %d", q, rr.Code)
+		}
+		var result CustomerListResult
+		json.Unmarshal(rr.Body.Bytes(), &result)
+		var names []string
+		for _, c := range result.Customers {
+			names = append(names, c.FirstName+" "+c.LastName)
+		}
+		return names
+	}
+
+	tests := map[string]string{
+		"SMIT":     "Smitty Brown,Maria Smith,John Smithers",
+		"jsmith":   "John Smithers",
+		"anna.lee": "Anna Lee",
+		"brown":    "Smitty Brown",
+		"nobody":   "",
+		"":         "Smitty Brown,Anna Lee,Maria Smith,John Smithers",
+	}
+	for q, expected := range tests {
+		if got := strings.Join(search(q), ","); got != expected {
+			t.Errorf("Search %q: expected %q, got %q", q, expected, got)
+		}
 	}
 }
 
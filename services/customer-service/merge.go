@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"autolytiq/shared/apierror"
+
+	"github.com/gorilla/mux"
+)
+
+// Customer merge errors
+var (
+	// ErrMergeCustomerNotFound is returned when either customer of a merge
+	// does not exist or has already been deleted
+	ErrMergeCustomerNotFound = errors.New("customer not found or already deleted")
+
+	// ErrMergeCrossDealership is returned when the customers of a merge
+	// belong to different dealerships
+	ErrMergeCrossDealership = errors.New("customers belong to different dealerships")
+)
+
+// MergeCustomersRequest names the duplicate to merge into the customer in
+// the path
+type MergeCustomersRequest struct {
+	DuplicateID string `json:"duplicate_id"`
+}
+
+// Validate validates MergeCustomersRequest
+func (r *MergeCustomersRequest) Validate() *ValidationErrors {
+	var errors []ValidationError
+
+	if r.DuplicateID == "" {
+		errors = append(errors, ValidationError{
+			Field:   "duplicate_id",
+			Message: "Duplicate ID is required",
+		})
+	} else if !uuidRegex.MatchString(r.DuplicateID) {
+		errors = append(errors, ValidationError{
+			Field:   "duplicate_id",
+			Message: "Invalid UUID format",
+		})
+	}
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize sanitizes MergeCustomersRequest
+func (r *MergeCustomersRequest) Sanitize() {
+	r.DuplicateID = strings.TrimSpace(r.DuplicateID)
+}
+
+// CustomerMergeSummary reports how many related rows a merge moved from the
+// duplicate to the surviving customer
+type CustomerMergeSummary struct {
+	SurvivorID  string `json:"survivor_id"`
+	DuplicateID string `json:"duplicate_id"`
+
+	Deals          int `json:"deals"`
+	CoBuyerDeals   int `json:"co_buyer_deals"`
+	ShowroomVisits int `json:"showroom_visits"`
+	EmailLogs      int `json:"email_logs"`
+	Tags           int `json:"tags"`
+}
+
+// mergeCustomers merges the duplicate named in the body into the customer in
+// the path: the duplicate's deals, showroom visits, email logs, and tags move
+// to the survivor and the duplicate is soft-deleted
+func (s *Server) mergeCustomers(w http.ResponseWriter, r *http.Request) {
+	survivorID := mux.Vars(r)["id"]
+
+	if !validateUUID(w, survivorID, "id") {
+		return
+	}
+
+	var req MergeCustomersRequest
+	if !decodeAndValidate(r, w, &req) {
+		return
+	}
+	if req.DuplicateID == survivorID {
+		respondErrorJSON(w, http.StatusBadRequest, "A customer cannot be merged into itself", "SELF_MERGE")
+		return
+	}
+
+	survivor, err := s.db.GetCustomer(survivorID)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get customer")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get customer: %v", err))
+		return
+	}
+	if survivor == nil {
+		apierror.WriteError(w, http.StatusNotFound, "CUSTOMER_NOT_FOUND", "Customer not found")
+		return
+	}
+
+	summary, err := s.db.MergeCustomers(survivorID, req.DuplicateID, survivor.DealershipID)
+	switch {
+	case errors.Is(err, ErrMergeCustomerNotFound):
+		apierror.WriteError(w, http.StatusNotFound, "CUSTOMER_NOT_FOUND", "Customer not found")
+		return
+	case errors.Is(err, ErrMergeCrossDealership):
+		respondErrorJSON(w, http.StatusConflict, "Customers from different dealerships cannot be merged", "CROSS_DEALERSHIP_MERGE")
+		return
+	case err != nil:
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to merge customers")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to merge customers: %v", err))
+		return
+	}
+
+	s.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
+		"customer_id":  survivorID,
+		"duplicate_id": req.DuplicateID,
+	}).Info("Customers merged")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+const (
+	mergeSurvivorID  = "44444444-4444-4444-4444-444444444444"
+	mergeDuplicateID = "55555555-5555-5555-5555-555555555555"
+	mergeOtherID     = "66666666-6666-6666-6666-666666666666"
+)
+
+func (db *MockDatabase) MergeCustomers(survivorID, duplicateID, dealershipID string) (*CustomerMergeSummary, error) {
+	survivor, duplicate := db.customers[survivorID], db.customers[duplicateID]
+	if survivor == nil || duplicate == nil {
+		return nil, ErrMergeCustomerNotFound
+	}
+	if survivor.DealershipID != dealershipID || duplicate.DealershipID != dealershipID {
+		return nil, ErrMergeCrossDealership
+	}
+
+	summary := &CustomerMergeSummary{SurvivorID: survivorID, DuplicateID: duplicateID}
+	for _, tag := range duplicate.Tags {
+		if !hasTag(survivor.Tags, tag) {
+			survivor.Tags = append(survivor.Tags, tag)
+			summary.Tags++
+		}
+	}
+	if survivor.Email == "" {
+		survivor.Email = duplicate.Email
+	}
+	delete(db.customers, duplicateID)
+	return summary, nil
+}
+
+func setupMergeTestServer() (*Server, *MockDatabase) {
+	db := NewMockDatabase()
+	db.customers[mergeSurvivorID] = &Customer{ID: mergeSurvivorID, DealershipID: segmentTestDealership, FirstName: "Alice", Tags: []string{"VIP"}}
+	db.customers[mergeDuplicateID] = &Customer{ID: mergeDuplicateID, DealershipID: segmentTestDealership, FirstName: "Alice", Email: "alice@example.com", Tags: []string{"vip", "trade-in"}}
+	db.customers[mergeOtherID] = &Customer{ID: mergeOtherID, DealershipID: "22222222-2222-2222-2222-222222222222", FirstName: "Alice"}
+	return NewServer(&Config{Port: "8082", DatabaseURL: "mock"}, db, testLogger()), db
+}
+
+func TestMergeCustomers(t *testing.T) {
+	server, db := setupMergeTestServer()
+
+	rr := sendTagRequest(t, server, "POST", "/customers/"+mergeSurvivorID+"/merge", `{"duplicate_id": "`+mergeDuplicateID+`"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("merge returned %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var summary CustomerMergeSummary
+	if err := json.Unmarshal(rr.Body.Bytes(), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.SurvivorID != mergeSurvivorID || summary.DuplicateID != mergeDuplicateID || summary.Tags != 1 {
+		t.Errorf("Unexpected merge summary: %+v", summary)
+	}
+	if _, exists := db.customers[mergeDuplicateID]; exists {
+		t.Error("Expected the duplicate to be deleted")
+	}
+	if survivor := db.customers[mergeSurvivorID]; survivor.Email != "alice@example.com" {
+		t.Errorf("Expected the survivor to take the duplicate's email, got %q", survivor.Email)
+	}
+}
+
+func TestMergeCustomersRejected(t *testing.T) {
+	server, db := setupMergeTestServer()
+
+	tests := []struct {
+		name, path, body string
+		want             int
+	}{
+		{"self merge", "/customers/" + mergeSurvivorID + "/merge", `{"duplicate_id": "` + mergeSurvivorID + `"}`, http.StatusBadRequest},
+		{"missing duplicate ID", "/customers/" + mergeSurvivorID + "/merge", `{}`, http.StatusBadRequest},
+		{"duplicate ID not a UUID", "/customers/" + mergeSurvivorID + "/merge", `{"duplicate_id": "c-bob"}`, http.StatusBadRequest},
+		{"unknown survivor", "/customers/77777777-7777-7777-7777-777777777777/merge", `{"duplicate_id": "` + mergeDuplicateID + `"}`, http.StatusNotFound},
+		{"unknown duplicate", "/customers/" + mergeSurvivorID + "/merge", `{"duplicate_id": "77777777-7777-7777-7777-777777777777"}`, http.StatusNotFound},
+		{"other dealership", "/customers/" + mergeSurvivorID + "/merge", `{"duplicate_id": "` + mergeOtherID + `"}`, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		if rr := sendTagRequest(t, server, "POST", tt.path, tt.body); rr.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, rr.Code, rr.Body.String())
+		}
+	}
+	if len(db.customers) != 3 {
+		t.Errorf("Expected no customer to be merged away, got %d customers", len(db.customers))
+	}
+}
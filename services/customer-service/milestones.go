@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/logging"
+	"autolytiq/shared/outbox"
+)
+
+// Milestone types
+const (
+	MilestoneBirthday            = "birthday"
+	MilestonePurchaseAnniversary = "purchase_anniversary"
+)
+
+// MilestoneEventTopic is the outbox topic for milestones reached by
+// customers who have opted into marketing email
+const MilestoneEventTopic = "customer.milestone"
+
+// dateOfBirthLayout is the format of dates of birth and milestone dates
+const dateOfBirthLayout = "2006-01-02"
+
+// Upcoming milestone windows
+const (
+	defaultMilestoneWindowDays = 30
+	maxMilestoneWindowDays     = 365
+)
+
+// MilestoneCandidate is a customer with dates that recur yearly
+type MilestoneCandidate struct {
+	CustomerID     string
+	DealershipID   string
+	FirstName      string
+	LastName       string
+	Email          string
+	DateOfBirth    *time.Time
+	PurchaseDates  []time.Time
+	MarketingEmail bool
+}
+
+// Milestone is a customer's upcoming birthday or purchase anniversary
+type Milestone struct {
+	CustomerID   string `json:"customer_id"`
+	DealershipID string `json:"dealership_id"`
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name"`
+	Email        string `json:"email,omitempty"`
+	Type         string `json:"type"`
+	Date         string `json:"date"`
+	DaysUntil    int    `json:"days_until"`
+
+	// Years is the age turned or the years since the purchase
+	Years int `json:"years"`
+
+	// MarketingEmail reports whether the customer has opted into marketing
+	// email; milestone events are only emitted for those who have
+	MarketingEmail bool `json:"marketing_email"`
+}
+
+// UpcomingMilestonesResponse is the response for upcoming milestones
+type UpcomingMilestonesResponse struct {
+	DealershipID string      `json:"dealership_id"`
+	From         string      `json:"from"`
+	To           string      `json:"to"`
+	Milestones   []Milestone `json:"milestones"`
+}
+
+// nextOccurrence returns the first anniversary of date on or after from.
+// February 29 falls on February 28 in years that are not leap years.
+func nextOccurrence(date, from time.Time) time.Time {
+	occurrence := anniversaryIn(date, from.Year())
+	if occurrence.Before(from) {
+		occurrence = anniversaryIn(date, from.Year()+1)
+	}
+	return occurrence
+}
+
+// anniversaryIn returns the anniversary of date in year, as a UTC date
+func anniversaryIn(date time.Time, year int) time.Time {
+	month, day := date.Month(), date.Day()
+	if month == time.February && day == 29 && !isLeapYear(year) {
+		day = 28
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// isLeapYear reports whether year has a February 29
+func isLeapYear(year int) bool {
+	return year%4 == 0 && (year%100 != 0 || year%400 == 0)
+}
+
+// findMilestones returns the milestones of the candidates falling between
+// from and withinDays after it, inclusive, soonest first
+func findMilestones(candidates []MilestoneCandidate, from time.Time, withinDays int) []Milestone {
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, withinDays)
+
+	milestones := []Milestone{}
+	add := func(c MilestoneCandidate, milestoneType string, date time.Time) {
+		occurrence := nextOccurrence(date, from)
+		years := occurrence.Year() - date.Year()
+		if occurrence.After(to) || years < 1 {
+			return
+		}
+		milestones = append(milestones, Milestone{
+			CustomerID:     c.CustomerID,
+			DealershipID:   c.DealershipID,
+			FirstName:      c.FirstName,
+			LastName:       c.LastName,
+			Email:          c.Email,
+			Type:           milestoneType,
+			Date:           occurrence.Format(dateOfBirthLayout),
+			DaysUntil:      int(occurrence.Sub(from).Hours() / 24),
+			Years:          years,
+			MarketingEmail: c.MarketingEmail,
+		})
+	}
+
+	for _, c := range candidates {
+		if c.DateOfBirth != nil {
+			add(c, MilestoneBirthday, *c.DateOfBirth)
+		}
+		// A customer with several purchases on the same day has one anniversary
+		seen := make(map[string]bool)
+		for _, purchased := range c.PurchaseDates {
+			key := purchased.Format(dateOfBirthLayout)
+			if !seen[key] {
+				seen[key] = true
+				add(c, MilestonePurchaseAnniversary, purchased)
+			}
+		}
+	}
+
+	sort.SliceStable(milestones, func(i, j int) bool {
+		a, b := milestones[i], milestones[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		if a.LastName != b.LastName {
+			return a.LastName < b.LastName
+		}
+		return a.FirstName < b.FirstName
+	})
+	return milestones
+}
+
+// milestoneEvent creates the outbox event for a milestone. The key makes
+// each milestone occurrence produce one event however often the job runs.
+func milestoneEvent(m Milestone) (*outbox.Event, error) {
+	key := MilestoneEventTopic + ":" + m.Type + ":" + m.CustomerID + ":" + m.Date
+	return outbox.NewEvent(MilestoneEventTopic, key, m)
+}
+
+// getUpcomingMilestones handles GET /customers/upcoming-milestones
+func (s *Server) getUpcomingMilestones(w http.ResponseWriter, r *http.Request) {
+	dealershipID := r.URL.Query().Get("dealership_id")
+	if dealershipID == "" {
+		respondValidationError(w, &ValidationErrors{
+			Errors: []ValidationError{{Field: "dealership_id", Message: "Dealership ID is required"}},
+		})
+		return
+	}
+	if !validateUUID(w, dealershipID, "dealership_id") {
+		return
+	}
+
+	withinDays := defaultMilestoneWindowDays
+	if v := r.URL.Query().Get("within_days"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 0 || parsed > maxMilestoneWindowDays {
+			respondValidationError(w, &ValidationErrors{
+				Errors: []ValidationError{{Field: "within_days", Message: "Must be between 0 and 365"}},
+			})
+			return
+		}
+		withinDays = parsed
+	}
+
+	candidates, err := s.db.ListMilestoneCandidates(dealershipID)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list milestone candidates")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list upcoming milestones")
+		return
+	}
+
+	from := time.Now().UTC()
+	milestones := findMilestones(candidates, from, withinDays)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(UpcomingMilestonesResponse{
+		DealershipID: dealershipID,
+		From:         from.Format(dateOfBirthLayout),
+		To:           from.AddDate(0, 0, withinDays).Format(dateOfBirthLayout),
+		Milestones:   milestones,
+	})
+}
+
+// MilestoneJob periodically writes an outbox event for each milestone
+// reached by a customer who has opted into marketing email, for the outbox
+// dispatcher to deliver (e.g. to trigger a templated email). Events are keyed
+// by milestone occurrence, so runs can overlap without duplicating them.
+type MilestoneJob struct {
+	db       CustomerDatabase
+	interval time.Duration
+	leadDays int
+	now      func() time.Time
+	logger   *logging.Logger
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+	running  bool
+	mu       sync.Mutex
+}
+
+// NewMilestoneJob creates a milestone job. Milestones are emitted up to
+// leadDays before they occur; zero emits them on the day.
+func NewMilestoneJob(db CustomerDatabase, interval time.Duration, leadDays int, logger *logging.Logger) *MilestoneJob {
+	return &MilestoneJob{
+		db:       db,
+		interval: interval,
+		leadDays: leadDays,
+		now:      time.Now,
+		logger:   logger,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start begins running the job
+func (j *MilestoneJob) Start() {
+	j.mu.Lock()
+	if j.running {
+		j.mu.Unlock()
+		return
+	}
+	j.running = true
+	j.mu.Unlock()
+
+	j.logger.WithField("interval", j.interval.String()).Info("Starting customer milestone job")
+
+	j.wg.Add(1)
+	go j.run()
+}
+
+// Stop stops the job and waits for an in-progress run to finish
+func (j *MilestoneJob) Stop() {
+	j.mu.Lock()
+	if !j.running {
+		j.mu.Unlock()
+		return
+	}
+	j.running = false
+	j.mu.Unlock()
+
+	close(j.stopChan)
+	j.wg.Wait()
+}
+
+func (j *MilestoneJob) run() {
+	defer j.wg.Done()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	// Run immediately on start so today's milestones aren't missed after
+	// a restart
+	j.RunOnce()
+
+	for {
+		select {
+		case <-ticker.C:
+			j.RunOnce()
+		case <-j.stopChan:
+			return
+		}
+	}
+}
+
+// RunOnce writes events for the milestones due to be emitted now, returning
+// how many were written. Milestones already emitted are skipped by the outbox.
+func (j *MilestoneJob) RunOnce() int {
+	candidates, err := j.db.ListMilestoneCandidates("")
+	if err != nil {
+		j.logger.WithError(err).Error("Failed to list milestone candidates")
+		return 0
+	}
+
+	var events []*outbox.Event
+	for _, m := range findMilestones(candidates, j.now().UTC(), j.leadDays) {
+		if !m.MarketingEmail || m.Email == "" {
+			continue
+		}
+		event, err := milestoneEvent(m)
+		if err != nil {
+			j.logger.WithError(err).WithField("customer_id", m.CustomerID).Error("Failed to create milestone event")
+			continue
+		}
+		events = append(events, event)
+	}
+
+	if len(events) == 0 {
+		return 0
+	}
+	if err := j.db.WriteMilestoneEvents(events); err != nil {
+		j.logger.WithError(err).Error("Failed to write milestone events")
+		return 0
+	}
+
+	j.logger.WithField("count", len(events)).Info("Emitted customer milestone events")
+	return len(events)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"autolytiq/shared/outbox"
+
+	"github.com/google/uuid"
+)
+
+func (db *MockDatabase) ListMilestoneCandidates(dealershipID string) ([]MilestoneCandidate, error) {
+	var candidates []MilestoneCandidate
+	for _, c := range db.milestoneCandidates {
+		if dealershipID == "" || c.DealershipID == dealershipID {
+			candidates = append(candidates, c)
+		}
+	}
+	return candidates, nil
+}
+
+func (db *MockDatabase) WriteMilestoneEvents(events []*outbox.Event) error {
+	for _, event := range events {
+		if _, exists := db.milestoneEvents[event.Key]; !exists {
+			db.milestoneEvents[event.Key] = event
+		}
+	}
+	return nil
+}
+
+func date(value string) time.Time {
+	d, err := time.Parse(dateOfBirthLayout, value)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func datePtr(value string) *time.Time {
+	d := date(value)
+	return &d
+}
+
+func TestFindMilestonesWithinWindow(t *testing.T) {
+	candidates := []MilestoneCandidate{
+		{CustomerID: "soon", LastName: "A", DateOfBirth: datePtr("1990-03-12")},
+		{CustomerID: "later", LastName: "B", DateOfBirth: datePtr("1990-03-20")},
+		{CustomerID: "passed", LastName: "C", DateOfBirth: datePtr("1990-03-09")},
+		{CustomerID: "buyer", LastName: "D", PurchaseDates: []time.Time{
+			date("2024-03-17"), date("2024-03-17"), date("2026-03-12"),
+		}},
+	}
+
+	milestones := findMilestones(candidates, date("2026-03-10"), 7)
+	if len(milestones) != 2 {
+		t.Fatalf("Expected 2 milestones, got %+v", milestones)
+	}
+
+	birthday := milestones[0]
+	if birthday.CustomerID != "soon" || birthday.Type != MilestoneBirthday || birthday.Date != "2026-03-12" ||
+		birthday.DaysUntil != 2 || birthday.Years != 36 {
+		t.Errorf("Unexpected birthday: %+v", birthday)
+	}
+
+	// The window end is inclusive, repeat purchases on a day count once, and
+	// this year's purchase has no anniversary yet
+	anniversary := milestones[1]
+	if anniversary.CustomerID != "buyer" || anniversary.Type != MilestonePurchaseAnniversary ||
+		anniversary.Date != "2026-03-17" || anniversary.Years != 2 {
+		t.Errorf("Unexpected anniversary: %+v", anniversary)
+	}
+}
+
+func TestFindMilestonesAcrossYearEnd(t *testing.T) {
+	candidates := []MilestoneCandidate{{CustomerID: "new-year", DateOfBirth: datePtr("1980-01-02")}}
+
+	milestones := findMilestones(candidates, date("2026-12-28"), 7)
+	if len(milestones) != 1 || milestones[0].Date != "2027-01-02" || milestones[0].Years != 47 {
+		t.Errorf("Expected next year's birthday, got %+v", milestones)
+	}
+}
+
+func TestFindMilestonesLeapDay(t *testing.T) {
+	candidates := []MilestoneCandidate{{CustomerID: "leap", DateOfBirth: datePtr("2000-02-29")}}
+
+	tests := []struct {
+		name       string
+		from       string
+		withinDays int
+		want       string
+	}{
+		{"non-leap year falls on February 28", "2027-02-25", 3, "2027-02-28"},
+		{"leap year keeps February 29", "2028-02-28", 1, "2028-02-29"},
+		{"not yet due", "2027-02-25", 2, ""},
+		{"already passed this year", "2027-03-01", 30, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			milestones := findMilestones(candidates, date(tt.from), tt.withinDays)
+			got := ""
+			if len(milestones) > 0 {
+				got = milestones[0].Date
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMilestoneJobRespectsConsent(t *testing.T) {
+	db := NewMockDatabase()
+	dealershipID := uuid.New().String()
+	db.milestoneCandidates = []MilestoneCandidate{
+		{CustomerID: "opted-in", DealershipID: dealershipID, Email: "in@example.com", MarketingEmail: true, DateOfBirth: datePtr("1985-06-01")},
+		{CustomerID: "opted-out", DealershipID: dealershipID, Email: "out@example.com", DateOfBirth: datePtr("1985-06-01")},
+		{CustomerID: "no-email", DealershipID: dealershipID, MarketingEmail: true, DateOfBirth: datePtr("1985-06-01")},
+		{CustomerID: "next-week", DealershipID: dealershipID, Email: "later@example.com", MarketingEmail: true, DateOfBirth: datePtr("1985-06-08")},
+	}
+
+	job := NewMilestoneJob(db, time.Hour, 0, testLogger())
+	job.now = func() time.Time { return date("2026-06-01").Add(9 * time.Hour) }
+
+	if written := job.RunOnce(); written != 1 {
+		t.Errorf("Expected one event, got %d", written)
+	}
+	// A second run on the same day does not duplicate the event
+	job.RunOnce()
+
+	if len(db.milestoneEvents) != 1 {
+		t.Fatalf("Expected one milestone event, got %d", len(db.milestoneEvents))
+	}
+	event := db.milestoneEvents["customer.milestone:birthday:opted-in:2026-06-01"]
+	if event == nil || event.Topic != MilestoneEventTopic {
+		t.Fatalf("Expected the opted-in customer's birthday event, got %+v", db.milestoneEvents)
+	}
+	var payload Milestone
+	json.Unmarshal(event.Payload, &payload)
+	if payload.Email != "in@example.com" || payload.Years != 41 {
+		t.Errorf("Unexpected event payload: %+v", payload)
+	}
+}
+
+func TestGetUpcomingMilestones(t *testing.T) {
+	server := setupTestServer()
+	db := server.db.(*MockDatabase)
+	dealershipID := uuid.New().String()
+
+	soon := time.Now().UTC().AddDate(-30, 0, 3).Format(dateOfBirthLayout)
+	db.milestoneCandidates = []MilestoneCandidate{
+		{CustomerID: "mine", DealershipID: dealershipID, DateOfBirth: datePtr(soon)},
+		{CustomerID: "other", DealershipID: uuid.New().String(), DateOfBirth: datePtr(soon)},
+	}
+
+	req := httptest.NewRequest("GET", "/customers/upcoming-milestones?dealership_id="+dealershipID+"&within_days=7", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp UpcomingMilestonesResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if len(resp.Milestones) != 1 || resp.Milestones[0].CustomerID != "mine" || resp.Milestones[0].DaysUntil != 3 {
+		t.Errorf("Expected only this dealership's milestone, got %+v", resp.Milestones)
+	}
+
+	for _, query := range []string{"", "?dealership_id=" + dealershipID + "&within_days=400", "?dealership_id=" + dealershipID + "&within_days=soon"} {
+		req := httptest.NewRequest("GET", "/customers/upcoming-milestones"+query, nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %q, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestCreateCustomerDateOfBirthValidation(t *testing.T) {
+	server := setupTestServer()
+
+	for _, dob := range []string{"1990-13-01", "12/01/1990", time.Now().AddDate(0, 0, 2).Format(dateOfBirthLayout)} {
+		body, _ := json.Marshal(CreateCustomerRequest{
+			DealershipID: uuid.New().String(),
+			FirstName:    "Jane",
+			LastName:     "Doe",
+			DateOfBirth:  dob,
+		})
+		req := httptest.NewRequest("POST", "/customers", bytes.NewBuffer(body))
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for date of birth %q, got %d", dob, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"autolytiq/shared/encryption"
+)
+
+const piiTestCustomerID = "88888888-8888-8888-8888-888888888888"
+
+// piiTestDB serves stored PII ciphertext for the PII health check
+type piiTestDB struct {
+	*MockDatabase
+	pii map[string]*CustomerPII
+}
+
+func (db *piiTestDB) GetCustomerPII(id string) (*CustomerPII, error) {
+	return db.pii[id], nil
+}
+
+func newTestFieldEncryptor(t *testing.T, keys map[string][]byte, primary string) *encryption.FieldEncryptor {
+	t.Helper()
+	km, err := encryption.NewKeyManagerWithKeys(keys, primary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encryption.NewFieldEncryptor(encryption.NewEncryptor(km))
+}
+
+func getPIIHealth(t *testing.T, server *Server, role string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/customers/"+piiTestCustomerID+"/pii-health", nil)
+	if role != "" {
+		req.Header.Set("X-User-Role", role)
+	}
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestCustomerPIIHealth(t *testing.T) {
+	oldKey, newKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	writer := newTestFieldEncryptor(t, map[string][]byte{"k1": oldKey}, "k1")
+	rotated := newTestFieldEncryptor(t, map[string][]byte{"k2": newKey}, "k2")
+
+	ssn, _ := writer.Encrypt("1234")
+	income, _ := rotated.Encrypt("5200.00")
+	db := &piiTestDB{MockDatabase: NewMockDatabase(), pii: map[string]*CustomerPII{
+		piiTestCustomerID: {
+			SSNLast4Encrypted:      ssn,
+			CreditScoreEncrypted:   "720",
+			MonthlyIncomeEncrypted: income,
+			PIIEncryptionVersion:   "v1",
+		},
+	}}
+	server := NewServer(&Config{Port: "8082", DatabaseURL: "mock"}, db, testLogger())
+	server.encryptor = rotated
+
+	rr := getPIIHealth(t, server, "ADMIN")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if bytes.Contains(rr.Body.Bytes(), []byte("1234")) || bytes.Contains(rr.Body.Bytes(), []byte("5200")) {
+		t.Fatalf("Expected no decrypted values in the response, got %s", rr.Body.String())
+	}
+
+	var resp PIIHealthResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Healthy || resp.PIIEncryptionVersion != "v1" {
+		t.Errorf("Expected an unhealthy v1 report, got %+v", resp)
+	}
+
+	want := map[string]string{
+		"ssn_last4":              PIIStatusError, // written with a key that was rotated out
+		"drivers_license_number": PIIStatusNotSet,
+		"credit_score":           PIIStatusError, // plaintext in an encrypted column
+		"monthly_income":         PIIStatusOK,
+	}
+	for _, field := range resp.Fields {
+		if field.Status != want[field.Field] {
+			t.Errorf("%s: expected %s, got %+v", field.Field, want[field.Field], field)
+		}
+	}
+	if len(resp.Fields) != len(want) {
+		t.Errorf("Expected %d fields, got %+v", len(want), resp.Fields)
+	}
+}
+
+func TestCustomerPIIHealthRequiresAdmin(t *testing.T) {
+	db := &piiTestDB{MockDatabase: NewMockDatabase(), pii: map[string]*CustomerPII{}}
+	server := NewServer(&Config{Port: "8082", DatabaseURL: "mock"}, db, testLogger())
+
+	if rr := getPIIHealth(t, server, "salesperson"); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a salesperson, got %d", rr.Code)
+	}
+	if rr := getPIIHealth(t, server, "admin"); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without an encryptor, got %d", rr.Code)
+	}
+
+	server.encryptor = newTestFieldEncryptor(t, map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)}, "k1")
+	if rr := getPIIHealth(t, server, "admin"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown customer, got %d", rr.Code)
+	}
+}
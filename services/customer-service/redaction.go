@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"autolytiq/shared/apierror"
+
+	"github.com/gorilla/mux"
+)
+
+// redactedValue replaces sensitive strings a caller may not see
+const redactedValue = "***"
+
+// piiReadRoles may see customers' sensitive PII unmasked. Roles are compared
+// case-insensitively since services disagree on casing.
+var piiReadRoles = append([]string{"finance", "finance_manager", "f_and_i"}, piiAdminRoles...)
+
+// Redact returns the customer as a caller with role may see it. Roles outside
+// piiReadRoles get a copy with the SSN and license masked and the credit
+// score and income left out; c itself is never modified.
+func (c *Customer) Redact(role string) *Customer {
+	if hasRole(role, piiReadRoles) {
+		return c
+	}
+
+	redacted := *c
+	if redacted.SSNLast4 != "" {
+		redacted.SSNLast4 = redactedValue
+	}
+	if redacted.DriversLicenseNumber != "" {
+		redacted.DriversLicenseNumber = redactedValue
+	}
+	redacted.CreditScore = 0
+	redacted.MonthlyIncome = 0
+	redacted.PIIRedacted = true
+	return &redacted
+}
+
+// exportCustomer returns a customer with every field unredacted, for GDPR
+// data exports. It is only served on the internal route, which the gateway
+// does not proxy.
+func (s *Server) exportCustomer(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if !validateUUID(w, id, "id") {
+		return
+	}
+
+	customer, err := s.db.GetCustomerWithGDPRFields(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get customer for export")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get customer: %v", err))
+		return
+	}
+	if customer == nil {
+		apierror.WriteError(w, http.StatusNotFound, "CUSTOMER_NOT_FOUND", "Customer not found")
+		return
+	}
+
+	s.logger.WithContext(r.Context()).WithField("customer_id", id).Info("Customer exported")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(customer)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const redactionTestCustomerID = "99999999-9999-9999-9999-999999999999"
+
+func (db *MockDatabase) GetCustomerWithGDPRFields(id string) (*CustomerWithGDPR, error) {
+	customer := db.customers[id]
+	if customer == nil {
+		return nil, nil
+	}
+	return &CustomerWithGDPR{Customer: *customer}, nil
+}
+
+func setupRedactionTestServer() (*Server, *MockDatabase) {
+	db := NewMockDatabase()
+	db.customers[redactionTestCustomerID] = &Customer{
+		ID:                   redactionTestCustomerID,
+		DealershipID:         segmentTestDealership,
+		FirstName:            "Dana",
+		SSNLast4:             "4321",
+		DriversLicenseNumber: "D7654321",
+		CreditScore:          710,
+		MonthlyIncome:        6100,
+		Tags:                 []string{},
+	}
+	return NewServer(&Config{Port: "8082", DatabaseURL: "mock"}, db, testLogger()), db
+}
+
+func TestCustomerRedact(t *testing.T) {
+	customer := &Customer{SSNLast4: "4321", DriversLicenseNumber: "D7654321", CreditScore: 710, MonthlyIncome: 6100}
+
+	for _, role := range []string{"finance", "ADMIN", "f_and_i"} {
+		if got := customer.Redact(role); got.SSNLast4 != "4321" || got.CreditScore != 710 || got.PIIRedacted {
+			t.Errorf("%s: expected unredacted PII, got %+v", role, got)
+		}
+	}
+
+	for _, role := range []string{"sales", "salesperson", ""} {
+		got := customer.Redact(role)
+		if got.SSNLast4 != redactedValue || got.DriversLicenseNumber != redactedValue ||
+			got.CreditScore != 0 || got.MonthlyIncome != 0 || !got.PIIRedacted {
+			t.Errorf("%q: expected redacted PII, got %+v", role, got)
+		}
+	}
+	if customer.SSNLast4 != "4321" {
+		t.Error("Expected Redact to leave the customer unmodified")
+	}
+}
+
+func TestSalesNeverSeesRawSSN(t *testing.T) {
+	server, _ := setupRedactionTestServer()
+
+	for _, path := range []string{
+		"/customers/" + redactionTestCustomerID,
+		"/customers",
+		"/customers?paginated=false",
+	} {
+		rr := sendRequestAs(t, server, "sales", "GET", path, "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", path, rr.Code, rr.Body.String())
+		}
+		body := rr.Body.String()
+		if strings.Contains(body, "4321") || strings.Contains(body, "D7654321") || strings.Contains(body, "6100") {
+			t.Errorf("%s: expected no raw PII for sales, got %s", path, body)
+		}
+		if !strings.Contains(body, `"ssn_last4":"***"`) {
+			t.Errorf("%s: expected a masked SSN, got %s", path, body)
+		}
+	}
+
+	rr := sendRequestAs(t, server, "finance", "GET", "/customers/"+redactionTestCustomerID, "")
+	var customer Customer
+	json.Unmarshal(rr.Body.Bytes(), &customer)
+	if customer.SSNLast4 != "4321" || customer.MonthlyIncome != 6100 {
+		t.Errorf("Expected finance to see the raw PII, got %+v", customer)
+	}
+}
+
+func TestExportCustomerIsUnredacted(t *testing.T) {
+	server, _ := setupRedactionTestServer()
+
+	rr := sendRequestAs(t, server, "sales", "GET", "/internal/customers/"+redactionTestCustomerID+"/export", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var customer CustomerWithGDPR
+	json.Unmarshal(rr.Body.Bytes(), &customer)
+	if customer.SSNLast4 != "4321" || customer.CreditScore != 710 {
+		t.Errorf("Expected the export to carry the full PII, got %+v", customer)
+	}
+}
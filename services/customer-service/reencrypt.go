@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/encryption"
+	"autolytiq/shared/logging"
+)
+
+// Re-encryption batching
+const (
+	defaultReencryptBatchSize = 100
+	maxReencryptBatchSize     = 1000
+
+	// maxReportedFailures caps the failed customer IDs listed in a result
+	maxReportedFailures = 100
+)
+
+// PIIReencryptRequest starts re-encrypting stored PII after
+// PII_ENCRYPTION_KEY changed. OldKey is the hex key the existing ciphertext
+// was written with.
+type PIIReencryptRequest struct {
+	OldKey    string `json:"old_key"`
+	DryRun    bool   `json:"dry_run"`
+	BatchSize int    `json:"batch_size,omitempty"`
+}
+
+// Validate validates PIIReencryptRequest
+func (r *PIIReencryptRequest) Validate() *ValidationErrors {
+	var errors []ValidationError
+
+	if r.OldKey == "" {
+		errors = append(errors, ValidationError{
+			Field:   "old_key",
+			Message: "Old key is required",
+		})
+	} else if err := encryption.ValidateKeyHex(r.OldKey); err != nil {
+		errors = append(errors, ValidationError{
+			Field:   "old_key",
+			Message: "Old key must be a 64-character hex AES-256 key",
+		})
+	}
+
+	if r.BatchSize < 0 || r.BatchSize > maxReencryptBatchSize {
+		errors = append(errors, ValidationError{
+			Field:   "batch_size",
+			Message: fmt.Sprintf("Batch size must be between 1 and %d", maxReencryptBatchSize),
+		})
+	}
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize sanitizes PIIReencryptRequest
+func (r *PIIReencryptRequest) Sanitize() {
+	r.OldKey = strings.TrimSpace(r.OldKey)
+}
+
+// PIIReencryptResult counts the customers a re-encryption run processed.
+// In a dry run Succeeded counts the customers that would be rewritten.
+type PIIReencryptResult struct {
+	DryRun     bool   `json:"dry_run"`
+	KeyVersion string `json:"key_version"`
+	Processed  int    `json:"processed"`
+	Succeeded  int    `json:"succeeded"`
+	Failed     int    `json:"failed"`
+	Skipped    int    `json:"skipped"`
+
+	// FailedCustomerIDs lists up to maxReportedFailures customers whose PII
+	// decrypted with neither key
+	FailedCustomerIDs []string `json:"failed_customer_ids"`
+}
+
+// piiReencryptor moves ciphertext written with an old key onto the current
+// primary key
+type piiReencryptor struct {
+	current *encryption.FieldEncryptor
+	version string
+	oldKey  []byte
+
+	// old holds an encryptor for the old key under each version label seen,
+	// since a changed PII_ENCRYPTION_KEY keeps the label of the key it replaced
+	old map[string]*encryption.FieldEncryptor
+}
+
+func newPIIReencryptor(current *encryption.FieldEncryptor, oldKeyHex string) (*piiReencryptor, error) {
+	version, err := current.PrimaryKeyVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get primary key version: %w", err)
+	}
+	oldKey, err := hex.DecodeString(oldKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid old key: %w", err)
+	}
+	return &piiReencryptor{
+		current: current,
+		version: version,
+		oldKey:  oldKey,
+		old:     make(map[string]*encryption.FieldEncryptor),
+	}, nil
+}
+
+// reencryptField returns ciphertext under the current primary key and
+// whether it differs from the stored value
+func (re *piiReencryptor) reencryptField(ciphertext string) (string, bool, error) {
+	if ciphertext == "" {
+		return "", false, nil
+	}
+	if !re.current.IsEncrypted(ciphertext) {
+		return "", false, fmt.Errorf("value is not encrypted")
+	}
+	label, err := re.current.GetKeyVersion(ciphertext)
+	if err != nil {
+		return "", false, err
+	}
+
+	plaintext, err := re.current.Decrypt(ciphertext)
+	if err == nil && label == re.version {
+		return ciphertext, false, nil
+	}
+	if err != nil {
+		old, err := re.oldEncryptor(label)
+		if err != nil {
+			return "", false, err
+		}
+		if plaintext, err = old.Decrypt(ciphertext); err != nil {
+			return "", false, fmt.Errorf("failed to decrypt with current or old key: %w", err)
+		}
+	}
+
+	reencrypted, err := re.current.Encrypt(plaintext)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to encrypt with current key: %w", err)
+	}
+	return reencrypted, true, nil
+}
+
+func (re *piiReencryptor) oldEncryptor(label string) (*encryption.FieldEncryptor, error) {
+	if enc, ok := re.old[label]; ok {
+		return enc, nil
+	}
+	km, err := encryption.NewKeyManagerWithKeys(map[string][]byte{label: re.oldKey}, label)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load old key: %w", err)
+	}
+	enc := encryption.NewFieldEncryptor(encryption.NewEncryptor(km))
+	re.old[label] = enc
+	return enc, nil
+}
+
+// reencrypt returns the customer's PII under the current key and whether
+// anything needs writing
+func (re *piiReencryptor) reencrypt(pii *CustomerPII) (*CustomerPII, bool, error) {
+	after := &CustomerPII{CustomerID: pii.CustomerID, PIIEncryptionVersion: re.version}
+	changed := pii.PIIEncryptionVersion != re.version
+
+	fields := []struct {
+		name string
+		from string
+		to   *string
+	}{
+		{"ssn_last4", pii.SSNLast4Encrypted, &after.SSNLast4Encrypted},
+		{"drivers_license_number", pii.DriversLicenseNumberEncrypted, &after.DriversLicenseNumberEncrypted},
+		{"credit_score", pii.CreditScoreEncrypted, &after.CreditScoreEncrypted},
+		{"monthly_income", pii.MonthlyIncomeEncrypted, &after.MonthlyIncomeEncrypted},
+	}
+	for _, f := range fields {
+		value, fieldChanged, err := re.reencryptField(f.from)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %w", f.name, err)
+		}
+		*f.to = value
+		changed = changed || fieldChanged
+	}
+	return after, changed, nil
+}
+
+// reencryptCustomers walks every customer with encrypted PII in ID order,
+// one batch at a time, so no long-running transaction holds the table. Each
+// customer is written on its own and only if unchanged since it was read.
+func (s *Server) reencryptCustomers(ctx context.Context, re *piiReencryptor, batchSize int, dryRun bool) (*PIIReencryptResult, error) {
+	result := &PIIReencryptResult{DryRun: dryRun, KeyVersion: re.version, FailedCustomerIDs: []string{}}
+	logger := s.logger.WithContext(ctx)
+
+	cursor := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		batch, err := s.db.ListCustomerPII(cursor, batchSize)
+		if err != nil {
+			return result, err
+		}
+
+		for _, pii := range batch {
+			result.Processed++
+
+			after, changed, err := re.reencrypt(pii)
+			if err != nil {
+				logger.WithError(err).WithField("customer_id", pii.CustomerID).Warn("Failed to re-encrypt customer PII")
+				result.Failed++
+				if len(result.FailedCustomerIDs) < maxReportedFailures {
+					result.FailedCustomerIDs = append(result.FailedCustomerIDs, pii.CustomerID)
+				}
+				continue
+			}
+			if !changed {
+				result.Skipped++
+				continue
+			}
+			if dryRun {
+				result.Succeeded++
+				continue
+			}
+
+			replaced, err := s.db.ReplaceCustomerPII(pii, after)
+			if err != nil {
+				return result, err
+			}
+			if replaced {
+				result.Succeeded++
+			} else {
+				// Updated meanwhile, so already written with the current key
+				result.Skipped++
+			}
+		}
+
+		if len(batch) < batchSize {
+			return result, nil
+		}
+		cursor = batch[len(batch)-1].CustomerID
+	}
+}
+
+// reencryptPII re-encrypts customers' stored PII with the current key after
+// a key change
+func (s *Server) reencryptPII(w http.ResponseWriter, r *http.Request) {
+	if !hasRole(logging.GetUserRole(r.Context()), piiAdminRoles) {
+		respondErrorJSON(w, http.StatusForbidden, "Re-encrypting PII requires an admin role", "FORBIDDEN")
+		return
+	}
+	if s.encryptor == nil {
+		respondErrorJSON(w, http.StatusServiceUnavailable, "PII encryption is not enabled", "ENCRYPTION_DISABLED")
+		return
+	}
+
+	var req PIIReencryptRequest
+	if !decodeAndValidate(r, w, &req) {
+		return
+	}
+	batchSize := req.BatchSize
+	if batchSize == 0 {
+		batchSize = defaultReencryptBatchSize
+	}
+
+	re, err := newPIIReencryptor(s.encryptor, req.OldKey)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to start PII re-encryption")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to start PII re-encryption: %v", err))
+		return
+	}
+
+	result, err := s.reencryptCustomers(r.Context(), re, batchSize, req.DryRun)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).WithField("processed", result.Processed).Error("PII re-encryption stopped")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("PII re-encryption stopped after %d customers: %v", result.Processed, err))
+		return
+	}
+
+	s.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
+		"dry_run":   result.DryRun,
+		"processed": result.Processed,
+		"succeeded": result.Succeeded,
+		"failed":    result.Failed,
+		"skipped":   result.Skipped,
+	}).Info("PII re-encryption finished")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// sendRequestAs sends a request as a gateway-authenticated user with role
+func sendRequestAs(t *testing.T, server *Server, role, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Role", role)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func (db *piiTestDB) ListCustomerPII(afterID string, limit int) ([]*CustomerPII, error) {
+	var ids []string
+	for id := range db.pii {
+		if id > afterID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	var batch []*CustomerPII
+	for _, id := range ids {
+		pii := *db.pii[id]
+		batch = append(batch, &pii)
+	}
+	return batch, nil
+}
+
+func (db *piiTestDB) ReplaceCustomerPII(before, after *CustomerPII) (bool, error) {
+	if stored := db.pii[before.CustomerID]; stored == nil || *stored != *before {
+		return false, nil
+	}
+	stored := *after
+	db.pii[before.CustomerID] = &stored
+	return true, nil
+}
+
+func TestReencryptPII(t *testing.T) {
+	oldKey, newKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	// A changed PII_ENCRYPTION_KEY keeps the v1 label
+	before := newTestFieldEncryptor(t, map[string][]byte{"v1": oldKey}, "v1")
+	after := newTestFieldEncryptor(t, map[string][]byte{"v1": newKey}, "v1")
+	unrelated := newTestFieldEncryptor(t, map[string][]byte{"v1": bytes.Repeat([]byte{3}, 32)}, "v1")
+
+	encrypt := func(enc interface{ Encrypt(string) (string, error) }, value string) string {
+		ciphertext, err := enc.Encrypt(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ciphertext
+	}
+	seed := func() map[string]*CustomerPII {
+		return map[string]*CustomerPII{
+			"c-1": {CustomerID: "c-1", SSNLast4Encrypted: encrypt(before, "1234"), MonthlyIncomeEncrypted: encrypt(before, "5200.00"), PIIEncryptionVersion: "v1"},
+			"c-2": {CustomerID: "c-2", DriversLicenseNumberEncrypted: encrypt(before, "D1234567"), PIIEncryptionVersion: "v1"},
+			"c-3": {CustomerID: "c-3", SSNLast4Encrypted: encrypt(after, "9876"), PIIEncryptionVersion: "v1"},
+			"c-4": {CustomerID: "c-4", SSNLast4Encrypted: encrypt(unrelated, "5555"), PIIEncryptionVersion: "v1"},
+		}
+	}
+
+	reencrypt := func(db *piiTestDB, dryRun bool) PIIReencryptResult {
+		t.Helper()
+		server := NewServer(&Config{Port: "8082", DatabaseURL: "mock"}, db, testLogger())
+		server.encryptor = after
+
+		body, _ := json.Marshal(PIIReencryptRequest{OldKey: hex.EncodeToString(oldKey), DryRun: dryRun, BatchSize: 3})
+		rr := sendRequestAs(t, server, "admin", "POST", "/customers/admin/reencrypt", string(body))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var result PIIReencryptResult
+		if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	// A dry run reports what would change and writes nothing
+	db := &piiTestDB{MockDatabase: NewMockDatabase(), pii: seed()}
+	stored := *db.pii["c-1"]
+	result := reencrypt(db, true)
+	if result.Processed != 4 || result.Succeeded != 2 || result.Skipped != 1 || result.Failed != 1 || !result.DryRun {
+		t.Errorf("Unexpected dry run result: %+v", result)
+	}
+	if *db.pii["c-1"] != stored {
+		t.Error("Expected a dry run to leave stored PII alone")
+	}
+
+	result = reencrypt(db, false)
+	if result.Processed != 4 || result.Succeeded != 2 || result.Skipped != 1 || result.Failed != 1 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if len(result.FailedCustomerIDs) != 1 || result.FailedCustomerIDs[0] != "c-4" {
+		t.Errorf("Expected c-4 to fail, got %v", result.FailedCustomerIDs)
+	}
+
+	if ssn, err := after.Decrypt(db.pii["c-1"].SSNLast4Encrypted); err != nil || ssn != "1234" {
+		t.Errorf("Expected the SSN to decrypt with the current key, got %q, %v", ssn, err)
+	}
+	if income, err := after.DecryptFloat(db.pii["c-1"].MonthlyIncomeEncrypted); err != nil || income != 5200 {
+		t.Errorf("Expected the income to decrypt with the current key, got %v, %v", income, err)
+	}
+	if dl, err := after.Decrypt(db.pii["c-2"].DriversLicenseNumberEncrypted); err != nil || dl != "D1234567" {
+		t.Errorf("Expected the license to decrypt with the current key, got %q, %v", dl, err)
+	}
+
+	// Everything decryptable is now current, so a second run skips it
+	result = reencrypt(db, false)
+	if result.Succeeded != 0 || result.Skipped != 3 || result.Failed != 1 {
+		t.Errorf("Unexpected rerun result: %+v", result)
+	}
+}
+
+func TestReencryptPIIValidation(t *testing.T) {
+	db := &piiTestDB{MockDatabase: NewMockDatabase(), pii: map[string]*CustomerPII{}}
+	server := NewServer(&Config{Port: "8082", DatabaseURL: "mock"}, db, testLogger())
+	server.encryptor = newTestFieldEncryptor(t, map[string][]byte{"v1": bytes.Repeat([]byte{2}, 32)}, "v1")
+	oldKey := hex.EncodeToString(bytes.Repeat([]byte{1}, 32))
+
+	tests := []struct {
+		name, role, body string
+		want             int
+	}{
+		{"not an admin", "salesperson", `{"old_key": "` + oldKey + `"}`, http.StatusForbidden},
+		{"missing key", "admin", `{}`, http.StatusBadRequest},
+		{"short key", "admin", `{"old_key": "abcd"}`, http.StatusBadRequest},
+		{"batch too large", "admin", `{"old_key": "` + oldKey + `", "batch_size": 5000}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rr := sendRequestAs(t, server, tt.role, "POST", "/customers/admin/reencrypt", tt.body)
+		if rr.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, rr.Code, rr.Body.String())
+		}
+	}
+}
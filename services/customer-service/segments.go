@@ -0,0 +1,448 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"autolytiq/shared/apierror"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// Segment limits keep resolution queries bounded
+const (
+	maxSegmentFilterDepth      = 5
+	maxSegmentFilterConditions = 50
+	defaultSegmentMembersLimit = 50
+	maxSegmentMembersLimit     = 500
+)
+
+// Segment channels. Members must have opted into marketing on the channel.
+const (
+	SegmentChannelEmail = "email"
+	SegmentChannelSMS   = "sms"
+	SegmentChannelPhone = "phone"
+)
+
+// segmentChannelConsent maps a channel to its customer_consent column and the
+// customer contact column that must be present
+var segmentChannelConsent = map[string]struct{ consent, contact string }{
+	SegmentChannelEmail: {consent: "marketing_email", contact: "email"},
+	SegmentChannelSMS:   {consent: "marketing_sms", contact: "phone"},
+	SegmentChannelPhone: {consent: "marketing_phone", contact: "phone"},
+}
+
+// Segment filter fields
+const (
+	SegmentFieldState      = "state"
+	SegmentFieldCity       = "city"
+	SegmentFieldZipCode    = "zip_code"
+	SegmentFieldDealAmount = "deal_amount"
+	SegmentFieldDealCount  = "deal_count"
+	SegmentFieldDealStatus = "deal_status"
+	SegmentFieldTag        = "tag"
+)
+
+// segmentCustomerColumns maps customer filter fields to their columns
+var segmentCustomerColumns = map[string]string{
+	SegmentFieldState:   "c.state",
+	SegmentFieldCity:    "c.city",
+	SegmentFieldZipCode: "c.zip_code",
+}
+
+// segmentComparisons maps numeric filter operators to SQL
+var segmentComparisons = map[string]string{
+	"eq":  "=",
+	"gt":  ">",
+	"gte": ">=",
+	"lt":  "<",
+	"lte": "<=",
+}
+
+// Segment is a saved marketing audience definition
+type Segment struct {
+	ID           string        `json:"id"`
+	DealershipID string        `json:"dealership_id"`
+	Name         string        `json:"name"`
+	Description  string        `json:"description,omitempty"`
+	Channel      string        `json:"channel"`
+	Filter       SegmentFilter `json:"filter"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
+
+// SegmentFilter is a segment condition tree. A node is either a group that
+// combines child conditions with "and" or "or", or a single condition on a
+// field, e.g. {"field": "deal_amount", "op": "gt", "value": 30000, "within_days": 365}.
+type SegmentFilter struct {
+	And []SegmentFilter `json:"and,omitempty"`
+	Or  []SegmentFilter `json:"or,omitempty"`
+
+	Field string      `json:"field,omitempty"`
+	Op    string      `json:"op,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+
+	// WithinDays limits deal conditions to deals created in the last N days
+	WithinDays int `json:"within_days,omitempty"`
+}
+
+// SegmentMember is a customer resolved from a segment. Only contact fields
+// are returned; sensitive PII is never included.
+type SegmentMember struct {
+	CustomerID string `json:"customer_id"`
+	FirstName  string `json:"first_name"`
+	LastName   string `json:"last_name"`
+	Email      string `json:"email,omitempty"`
+	Phone      string `json:"phone,omitempty"`
+}
+
+// SegmentMembersResponse is the response for resolving segment members
+type SegmentMembersResponse struct {
+	SegmentID string          `json:"segment_id"`
+	Members   []SegmentMember `json:"members"`
+	Total     int             `json:"total"`
+	Limit     int             `json:"limit"`
+	Offset    int             `json:"offset"`
+	HasMore   bool            `json:"has_more"`
+}
+
+// isGroup reports whether the node combines child conditions
+func (f *SegmentFilter) isGroup() bool {
+	return len(f.And) > 0 || len(f.Or) > 0
+}
+
+// Validate checks the filter tree, returning errors keyed by path
+func (f *SegmentFilter) Validate(path string) []ValidationError {
+	count := 0
+	return f.validate(path, 1, &count)
+}
+
+func (f *SegmentFilter) validate(path string, depth int, count *int) []ValidationError {
+	var errors []ValidationError
+
+	if depth > maxSegmentFilterDepth {
+		return []ValidationError{{Field: path, Message: fmt.Sprintf("Filter cannot be nested more than %d levels", maxSegmentFilterDepth)}}
+	}
+
+	if f.isGroup() {
+		if len(f.And) > 0 && len(f.Or) > 0 {
+			return []ValidationError{{Field: path, Message: "A filter group must use either and or or, not both"}}
+		}
+		if f.Field != "" {
+			return []ValidationError{{Field: path, Message: "A filter group cannot also have a field"}}
+		}
+		key, children := "and", f.And
+		if len(f.Or) > 0 {
+			key, children = "or", f.Or
+		}
+		for i := range children {
+			errors = append(errors, children[i].validate(fmt.Sprintf("%s.%s[%d]", path, key, i), depth+1, count)...)
+		}
+		return errors
+	}
+
+	*count++
+	if *count > maxSegmentFilterConditions {
+		return []ValidationError{{Field: path, Message: fmt.Sprintf("Filter cannot have more than %d conditions", maxSegmentFilterConditions)}}
+	}
+
+	switch f.Field {
+	case SegmentFieldState, SegmentFieldCity, SegmentFieldZipCode, SegmentFieldDealStatus, SegmentFieldTag:
+		switch f.Op {
+		case "eq", "neq":
+			if f.Field == SegmentFieldDealStatus && f.Op == "neq" {
+				errors = append(errors, ValidationError{Field: path + ".op", Message: "deal_status supports eq and in"})
+			} else if _, ok := filterString(f.Value); !ok {
+				errors = append(errors, ValidationError{Field: path + ".value", Message: "Value must be a non-empty string"})
+			}
+		case "in":
+			if _, ok := filterStrings(f.Value); !ok {
+				errors = append(errors, ValidationError{Field: path + ".value", Message: "Value must be a non-empty list of strings"})
+			}
+		default:
+			errors = append(errors, ValidationError{Field: path + ".op", Message: "Operator must be one of: eq, neq, in"})
+		}
+	case SegmentFieldDealAmount, SegmentFieldDealCount:
+		if _, ok := segmentComparisons[f.Op]; !ok {
+			errors = append(errors, ValidationError{Field: path + ".op", Message: "Operator must be one of: eq, gt, gte, lt, lte"})
+		}
+		if n, ok := filterNumber(f.Value); !ok || n < 0 {
+			errors = append(errors, ValidationError{Field: path + ".value", Message: "Value must be a non-negative number"})
+		}
+	case "":
+		errors = append(errors, ValidationError{Field: path, Message: "Filter must have a field or an and/or group"})
+	default:
+		errors = append(errors, ValidationError{Field: path + ".field", Message: "Unsupported filter field: " + f.Field})
+	}
+
+	if f.WithinDays < 0 {
+		errors = append(errors, ValidationError{Field: path + ".within_days", Message: "within_days cannot be negative"})
+	} else if f.WithinDays > 0 && (segmentCustomerColumns[f.Field] != "" || f.Field == SegmentFieldTag) {
+		errors = append(errors, ValidationError{Field: path + ".within_days", Message: "within_days only applies to deal fields"})
+	}
+
+	return errors
+}
+
+// toSQL compiles a validated filter into a SQL condition on customers c,
+// appending bind values to args
+func (f *SegmentFilter) toSQL(args *[]interface{}) (string, error) {
+	bind := func(v interface{}) string {
+		*args = append(*args, v)
+		return "$" + strconv.Itoa(len(*args))
+	}
+
+	if f.isGroup() {
+		joiner, children := " AND ", f.And
+		if len(f.Or) > 0 {
+			joiner, children = " OR ", f.Or
+		}
+		parts := make([]string, 0, len(children))
+		for i := range children {
+			part, err := children[i].toSQL(args)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, part)
+		}
+		return "(" + strings.Join(parts, joiner) + ")", nil
+	}
+
+	if column, ok := segmentCustomerColumns[f.Field]; ok {
+		switch f.Op {
+		case "eq":
+			s, _ := filterString(f.Value)
+			return fmt.Sprintf("%s = %s", column, bind(s)), nil
+		case "neq":
+			s, _ := filterString(f.Value)
+			return fmt.Sprintf("%s IS DISTINCT FROM %s", column, bind(s)), nil
+		case "in":
+			values, _ := filterStrings(f.Value)
+			return fmt.Sprintf("%s = ANY(%s)", column, bind(pq.Array(values))), nil
+		}
+		return "", fmt.Errorf("unsupported operator %q for %s", f.Op, f.Field)
+	}
+
+	if f.Field == SegmentFieldTag {
+		hasTag := "EXISTS (SELECT 1 FROM customer_tags t WHERE t.customer_id = c.id AND LOWER(t.tag) %s)"
+		switch f.Op {
+		case "eq":
+			s, _ := filterString(f.Value)
+			return fmt.Sprintf(hasTag, "= LOWER("+bind(s)+")"), nil
+		case "neq":
+			s, _ := filterString(f.Value)
+			return "NOT " + fmt.Sprintf(hasTag, "= LOWER("+bind(s)+")"), nil
+		case "in":
+			values, _ := filterStrings(f.Value)
+			lowered := make([]string, len(values))
+			for i, v := range values {
+				lowered[i] = strings.ToLower(v)
+			}
+			return fmt.Sprintf(hasTag, "= ANY("+bind(pq.Array(lowered))+")"), nil
+		}
+		return "", fmt.Errorf("unsupported operator %q for %s", f.Op, f.Field)
+	}
+
+	dealScope := "d.customer_id = c.id AND d.dealership_id = c.dealership_id"
+	if f.WithinDays > 0 {
+		dealScope += fmt.Sprintf(" AND d.created_at >= NOW() - make_interval(days => %s)", bind(f.WithinDays))
+	}
+
+	switch f.Field {
+	case SegmentFieldDealAmount:
+		n, _ := filterNumber(f.Value)
+		return fmt.Sprintf("EXISTS (SELECT 1 FROM deals d WHERE %s AND d.total_amount %s %s)",
+			dealScope, segmentComparisons[f.Op], bind(n)), nil
+	case SegmentFieldDealCount:
+		n, _ := filterNumber(f.Value)
+		return fmt.Sprintf("(SELECT COUNT(*) FROM deals d WHERE %s) %s %s",
+			dealScope, segmentComparisons[f.Op], bind(n)), nil
+	case SegmentFieldDealStatus:
+		if f.Op == "in" {
+			values, _ := filterStrings(f.Value)
+			return fmt.Sprintf("EXISTS (SELECT 1 FROM deals d WHERE %s AND d.status = ANY(%s))", dealScope, bind(pq.Array(values))), nil
+		}
+		s, _ := filterString(f.Value)
+		return fmt.Sprintf("EXISTS (SELECT 1 FROM deals d WHERE %s AND d.status = %s)", dealScope, bind(s)), nil
+	}
+
+	return "", fmt.Errorf("unsupported filter field: %s", f.Field)
+}
+
+// filterString returns a non-empty string filter value
+func filterString(v interface{}) (string, bool) {
+	s, ok := v.(string)
+	return s, ok && s != ""
+}
+
+// filterStrings returns a non-empty list of string filter values
+func filterStrings(v interface{}) ([]string, bool) {
+	var values []string
+	switch list := v.(type) {
+	case []string:
+		values = list
+	case []interface{}:
+		for _, item := range list {
+			s, ok := filterString(item)
+			if !ok {
+				return nil, false
+			}
+			values = append(values, s)
+		}
+	default:
+		return nil, false
+	}
+	return values, len(values) > 0
+}
+
+// filterNumber returns a numeric filter value
+func filterNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// createSegment creates a new segment definition
+func (s *Server) createSegment(w http.ResponseWriter, r *http.Request) {
+	var req CreateSegmentRequest
+	if !decodeAndValidate(r, w, &req) {
+		return
+	}
+
+	segment := Segment{
+		ID:           uuid.New().String(),
+		DealershipID: req.DealershipID,
+		Name:         req.Name,
+		Description:  req.Description,
+		Channel:      req.Channel,
+		Filter:       req.Filter,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+
+	if err := s.db.CreateSegment(&segment); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to create segment")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to create segment: %v", err))
+		return
+	}
+
+	s.logger.WithContext(r.Context()).WithField("segment_id", segment.ID).Info("Segment created")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(segment)
+}
+
+// listSegments returns segments, optionally filtered by dealership
+func (s *Server) listSegments(w http.ResponseWriter, r *http.Request) {
+	dealershipID := r.URL.Query().Get("dealership_id")
+
+	segments, err := s.db.ListSegments(dealershipID)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list segments")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to list segments: %v", err))
+		return
+	}
+
+	if segments == nil {
+		segments = []*Segment{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(segments)
+}
+
+// getSegment retrieves a segment definition
+func (s *Server) getSegment(w http.ResponseWriter, r *http.Request) {
+	segment, ok := s.loadSegment(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(segment)
+}
+
+// getSegmentMembers resolves a segment to the consenting customers matching
+// its filter, paginated with limit and offset
+func (s *Server) getSegmentMembers(w http.ResponseWriter, r *http.Request) {
+	segment, ok := s.loadSegment(w, r)
+	if !ok {
+		return
+	}
+
+	limit := defaultSegmentMembersLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+	if limit > maxSegmentMembersLimit {
+		limit = maxSegmentMembersLimit
+	}
+	offset := 0
+	if o := r.URL.Query().Get("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+
+	members, total, err := s.db.ResolveSegment(segment, limit, offset)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).WithField("segment_id", segment.ID).Error("Failed to resolve segment")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to resolve segment: %v", err))
+		return
+	}
+
+	if members == nil {
+		members = []SegmentMember{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SegmentMembersResponse{
+		SegmentID: segment.ID,
+		Members:   members,
+		Total:     total,
+		Limit:     limit,
+		Offset:    offset,
+		HasMore:   offset+len(members) < total,
+	})
+}
+
+// loadSegment fetches the segment named in the path. A dealership_id query
+// parameter, when given, must match the segment's dealership.
+func (s *Server) loadSegment(w http.ResponseWriter, r *http.Request) (*Segment, bool) {
+	id := mux.Vars(r)["id"]
+
+	if !validateUUID(w, id, "id") {
+		return nil, false
+	}
+
+	segment, err := s.db.GetSegment(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get segment")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get segment: %v", err))
+		return nil, false
+	}
+
+	dealershipID := r.URL.Query().Get("dealership_id")
+	if segment == nil || (dealershipID != "" && segment.DealershipID != dealershipID) {
+		apierror.WriteError(w, http.StatusNotFound, "SEGMENT_NOT_FOUND", "Segment not found")
+		return nil, false
+	}
+
+	return segment, true
+}
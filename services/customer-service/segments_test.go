@@ -0,0 +1,449 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+const segmentTestDealership = "11111111-1111-1111-1111-111111111111"
+
+// segmentTestDeal is a seeded deal for segment resolution
+type segmentTestDeal struct {
+	CustomerID  string
+	TotalAmount float64
+	Status      string
+	CreatedAt   time.Time
+}
+
+// segmentTestConsent is seeded marketing consent keyed by customer
+type segmentTestConsent struct {
+	Email, SMS, Phone bool
+}
+
+// segmentTestDB extends MockDatabase with segments and seeded deal/consent
+// data. ResolveSegment evaluates filters in memory with the same semantics as
+// the SQL compiled by SegmentFilter.toSQL.
+type segmentTestDB struct {
+	*MockDatabase
+	segments map[string]*Segment
+	deals    []segmentTestDeal
+	consent  map[string]segmentTestConsent
+	deleted  map[string]bool
+}
+
+func newSegmentTestDB() *segmentTestDB {
+	return &segmentTestDB{
+		MockDatabase: NewMockDatabase(),
+		segments:     make(map[string]*Segment),
+		consent:      make(map[string]segmentTestConsent),
+		deleted:      make(map[string]bool),
+	}
+}
+
+func (db *segmentTestDB) CreateSegment(segment *Segment) error {
+	db.segments[segment.ID] = segment
+	return nil
+}
+
+func (db *segmentTestDB) GetSegment(id string) (*Segment, error) {
+	return db.segments[id], nil
+}
+
+func (db *segmentTestDB) ListSegments(dealershipID string) ([]*Segment, error) {
+	var segments []*Segment
+	for _, segment := range db.segments {
+		if dealershipID == "" || segment.DealershipID == dealershipID {
+			segments = append(segments, segment)
+		}
+	}
+	return segments, nil
+}
+
+func (db *segmentTestDB) ResolveSegment(segment *Segment, limit, offset int) ([]SegmentMember, int, error) {
+	var matched []*Customer
+	for _, c := range db.customers {
+		if c.DealershipID != segment.DealershipID || db.deleted[c.ID] {
+			continue
+		}
+		consent := db.consent[c.ID]
+		switch segment.Channel {
+		case SegmentChannelEmail:
+			if !consent.Email || c.Email == "" {
+				continue
+			}
+		case SegmentChannelSMS:
+			if !consent.SMS || c.Phone == "" {
+				continue
+			}
+		case SegmentChannelPhone:
+			if !consent.Phone || c.Phone == "" {
+				continue
+			}
+		}
+		if db.matches(&segment.Filter, c) {
+			matched = append(matched, c)
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].LastName != matched[j].LastName {
+			return matched[i].LastName < matched[j].LastName
+		}
+		return matched[i].FirstName < matched[j].FirstName
+	})
+
+	var members []SegmentMember
+	for i := offset; i < len(matched) && i < offset+limit; i++ {
+		c := matched[i]
+		members = append(members, SegmentMember{CustomerID: c.ID, FirstName: c.FirstName, LastName: c.LastName, Email: c.Email, Phone: c.Phone})
+	}
+	return members, len(matched), nil
+}
+
+func (db *segmentTestDB) matches(f *SegmentFilter, c *Customer) bool {
+	if len(f.And) > 0 {
+		for i := range f.And {
+			if !db.matches(&f.And[i], c) {
+				return false
+			}
+		}
+		return true
+	}
+	if len(f.Or) > 0 {
+		for i := range f.Or {
+			if db.matches(&f.Or[i], c) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var deals []segmentTestDeal
+	for _, d := range db.deals {
+		if d.CustomerID != c.ID {
+			continue
+		}
+		if f.WithinDays > 0 && d.CreatedAt.Before(time.Now().AddDate(0, 0, -f.WithinDays)) {
+			continue
+		}
+		deals = append(deals, d)
+	}
+
+	switch f.Field {
+	case SegmentFieldState, SegmentFieldCity, SegmentFieldZipCode:
+		value := map[string]string{SegmentFieldState: c.State, SegmentFieldCity: c.City, SegmentFieldZipCode: c.ZipCode}[f.Field]
+		return matchString(f, value)
+	case SegmentFieldDealStatus:
+		for _, d := range deals {
+			if matchString(f, d.Status) {
+				return true
+			}
+		}
+	case SegmentFieldDealAmount:
+		for _, d := range deals {
+			if compareNumber(f, d.TotalAmount) {
+				return true
+			}
+		}
+	case SegmentFieldDealCount:
+		return compareNumber(f, float64(len(deals)))
+	case SegmentFieldTag:
+		switch f.Op {
+		case "eq":
+			s, _ := filterString(f.Value)
+			return hasTag(c.Tags, s)
+		case "neq":
+			s, _ := filterString(f.Value)
+			return !hasTag(c.Tags, s)
+		case "in":
+			values, _ := filterStrings(f.Value)
+			for _, v := range values {
+				if hasTag(c.Tags, v) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func matchString(f *SegmentFilter, value string) bool {
+	switch f.Op {
+	case "eq":
+		s, _ := filterString(f.Value)
+		return value == s
+	case "neq":
+		s, _ := filterString(f.Value)
+		return value != s
+	case "in":
+		values, _ := filterStrings(f.Value)
+		for _, v := range values {
+			if v == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func compareNumber(f *SegmentFilter, value float64) bool {
+	n, _ := filterNumber(f.Value)
+	switch f.Op {
+	case "eq":
+		return value == n
+	case "gt":
+		return value > n
+	case "gte":
+		return value >= n
+	case "lt":
+		return value < n
+	case "lte":
+		return value <= n
+	}
+	return false
+}
+
+// seedSegmentData seeds customers, deals and consent for segment tests
+func seedSegmentData(db *segmentTestDB) {
+	now := time.Now()
+	customers := []*Customer{
+		{ID: "c-alice", FirstName: "Alice", LastName: "Adams", Email: "alice@example.com", State: "IN"},
+		{ID: "c-bob", FirstName: "Bob", LastName: "Brown", Email: "bob@example.com", State: "OH"},
+		{ID: "c-carol", FirstName: "Carol", LastName: "Clark", Email: "carol@example.com", State: "IN"},
+		{ID: "c-dave", FirstName: "Dave", LastName: "Davis", Email: "dave@example.com", State: "IN"},
+		{ID: "c-erin", FirstName: "Erin", LastName: "Evans", Email: "", Phone: "3175550100", State: "IN"},
+		{ID: "c-frank", FirstName: "Frank", LastName: "Foster", Email: "frank@example.com", State: "IN"},
+		{ID: "c-other", FirstName: "Gina", LastName: "Green", Email: "gina@example.com", State: "IN"},
+	}
+	for _, c := range customers {
+		c.DealershipID = segmentTestDealership
+		db.customers[c.ID] = c
+	}
+	db.customers["c-other"].DealershipID = "22222222-2222-2222-2222-222222222222"
+
+	db.deals = []segmentTestDeal{
+		{CustomerID: "c-alice", TotalAmount: 42000, Status: "completed", CreatedAt: now.AddDate(0, -2, 0)},
+		{CustomerID: "c-bob", TotalAmount: 35000, Status: "completed", CreatedAt: now.AddDate(0, -6, 0)},
+		// Big deal, but too old
+		{CustomerID: "c-carol", TotalAmount: 50000, Status: "completed", CreatedAt: now.AddDate(-2, 0, 0)},
+		{CustomerID: "c-carol", TotalAmount: 18000, Status: "completed", CreatedAt: now.AddDate(0, -1, 0)},
+		// Big recent deal, but no email consent
+		{CustomerID: "c-dave", TotalAmount: 61000, Status: "completed", CreatedAt: now.AddDate(0, -3, 0)},
+		// Consented but no email address
+		{CustomerID: "c-erin", TotalAmount: 39000, Status: "completed", CreatedAt: now.AddDate(0, -3, 0)},
+		// Deleted customer
+		{CustomerID: "c-frank", TotalAmount: 45000, Status: "completed", CreatedAt: now.AddDate(0, -1, 0)},
+		{CustomerID: "c-other", TotalAmount: 70000, Status: "completed", CreatedAt: now.AddDate(0, -1, 0)},
+	}
+
+	db.consent = map[string]segmentTestConsent{
+		"c-alice": {Email: true},
+		"c-bob":   {Email: true},
+		"c-carol": {Email: true},
+		"c-dave":  {SMS: true},
+		"c-erin":  {Email: true, SMS: true},
+		"c-frank": {Email: true},
+		"c-other": {Email: true},
+	}
+	db.deleted["c-frank"] = true
+}
+
+func setupSegmentTestServer() (*Server, *segmentTestDB) {
+	db := newSegmentTestDB()
+	seedSegmentData(db)
+	return NewServer(&Config{Port: "8082", DatabaseURL: "mock"}, db, testLogger()), db
+}
+
+func createTestSegment(t *testing.T, server *Server, body string) Segment {
+	t.Helper()
+
+	req, err := http.NewRequest("POST", "/customers/segments", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create segment returned %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var segment Segment
+	if err := json.Unmarshal(rr.Body.Bytes(), &segment); err != nil {
+		t.Fatal(err)
+	}
+	return segment
+}
+
+func getTestSegmentMembers(t *testing.T, server *Server, segmentID, query string) SegmentMembersResponse {
+	t.Helper()
+
+	req, err := http.NewRequest("GET", "/customers/segments/"+segmentID+"/members"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("get members returned %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp SegmentMembersResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func memberIDs(members []SegmentMember) []string {
+	ids := make([]string, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.CustomerID)
+	}
+	return ids
+}
+
+func TestSegmentResolvesConsentingMembers(t *testing.T) {
+	server, _ := setupSegmentTestServer()
+
+	// Customers with a deal over $30k in the last year who opted into email
+	segment := createTestSegment(t, server, `{
+		"dealership_id": "`+segmentTestDealership+`",
+		"name": "Recent big buyers",
+		"channel": "email",
+		"filter": {"field": "deal_amount", "op": "gt", "value": 30000, "within_days": 365}
+	}`)
+
+	resp := getTestSegmentMembers(t, server, segment.ID, "")
+
+	got := strings.Join(memberIDs(resp.Members), ",")
+	if got != "c-alice,c-bob" {
+		t.Errorf("Expected members c-alice,c-bob, got %s", got)
+	}
+	if resp.Total != 2 || resp.HasMore {
+		t.Errorf("Expected total 2 with no more pages, got total %d has_more %v", resp.Total, resp.HasMore)
+	}
+}
+
+func TestSegmentAndOrConditions(t *testing.T) {
+	server, _ := setupSegmentTestServer()
+
+	// In Indiana AND (recent big deal OR more than one deal ever)
+	segment := createTestSegment(t, server, `{
+		"dealership_id": "`+segmentTestDealership+`",
+		"name": "Indiana repeat or big buyers",
+		"channel": "email",
+		"filter": {"and": [
+			{"field": "state", "op": "eq", "value": "IN"},
+			{"or": [
+				{"field": "deal_amount", "op": "gt", "value": 30000, "within_days": 365},
+				{"field": "deal_count", "op": "gte", "value": 2}
+			]}
+		]}
+	}`)
+
+	resp := getTestSegmentMembers(t, server, segment.ID, "")
+
+	got := strings.Join(memberIDs(resp.Members), ",")
+	if got != "c-alice,c-carol" {
+		t.Errorf("Expected members c-alice,c-carol, got %s", got)
+	}
+}
+
+func TestSegmentMembersPagination(t *testing.T) {
+	server, _ := setupSegmentTestServer()
+
+	segment := createTestSegment(t, server, `{
+		"dealership_id": "`+segmentTestDealership+`",
+		"name": "All completed buyers",
+		"channel": "email",
+		"filter": {"field": "deal_status", "op": "in", "value": ["completed"]}
+	}`)
+
+	first := getTestSegmentMembers(t, server, segment.ID, "?limit=2")
+	if strings.Join(memberIDs(first.Members), ",") != "c-alice,c-bob" || first.Total != 3 || !first.HasMore {
+		t.Errorf("Unexpected first page: %+v", first)
+	}
+
+	second := getTestSegmentMembers(t, server, segment.ID, "?limit=2&offset=2")
+	if strings.Join(memberIDs(second.Members), ",") != "c-carol" || second.HasMore {
+		t.Errorf("Unexpected second page: %+v", second)
+	}
+}
+
+func TestCreateSegmentValidation(t *testing.T) {
+	server, _ := setupSegmentTestServer()
+
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"unknown channel", `{"dealership_id": "` + segmentTestDealership + `", "name": "x", "channel": "fax", "filter": {"field": "state", "op": "eq", "value": "IN"}}`, "channel"},
+		{"unknown field", `{"dealership_id": "` + segmentTestDealership + `", "name": "x", "channel": "email", "filter": {"field": "ssn_last4", "op": "eq", "value": "1234"}}`, "filter.field"},
+		{"bad operator", `{"dealership_id": "` + segmentTestDealership + `", "name": "x", "channel": "email", "filter": {"field": "deal_amount", "op": "like", "value": 1}}`, "filter.op"},
+		{"mixed group", `{"dealership_id": "` + segmentTestDealership + `", "name": "x", "channel": "email", "filter": {"and": [{"field": "state", "op": "eq", "value": "IN"}], "or": [{"field": "state", "op": "eq", "value": "OH"}]}}`, "filter"},
+		{"empty filter", `{"dealership_id": "` + segmentTestDealership + `", "name": "x", "channel": "email", "filter": {}}`, "filter"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/customers/segments", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("Expected 400, got %d", rr.Code)
+			}
+
+			var resp errorResponse
+			json.Unmarshal(rr.Body.Bytes(), &resp)
+			found := false
+			for _, d := range resp.Error.Details {
+				if d.Field == tt.field {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("Expected error on %s, got %+v", tt.field, resp.Error.Details)
+			}
+		})
+	}
+}
+
+func TestSegmentFilterToSQL(t *testing.T) {
+	filter := SegmentFilter{
+		And: []SegmentFilter{
+			{Field: SegmentFieldState, Op: "eq", Value: "IN"},
+			{Or: []SegmentFilter{
+				{Field: SegmentFieldDealAmount, Op: "gt", Value: float64(30000), WithinDays: 365},
+				{Field: SegmentFieldCity, Op: "in", Value: []interface{}{"Carmel", "Fishers"}},
+			}},
+		},
+	}
+
+	args := []interface{}{segmentTestDealership}
+	sql, err := filter.toSQL(&args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "(c.state = $2 AND (EXISTS (SELECT 1 FROM deals d WHERE d.customer_id = c.id AND d.dealership_id = c.dealership_id" +
+		" AND d.created_at >= NOW() - make_interval(days => $3) AND d.total_amount > $4) OR c.city = ANY($5)))"
+	if sql != expected {
+		t.Errorf("Unexpected SQL:\n got: %s\nwant: %s", sql, expected)
+	}
+	if len(args) != 5 || args[1] != "IN" || args[2] != 365 || args[3] != float64(30000) {
+		t.Errorf("Unexpected args: %v", args)
+	}
+}
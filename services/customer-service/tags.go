@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/logging"
+
+	"github.com/gorilla/mux"
+)
+
+// Tag limits
+const (
+	maxTagLength      = 50
+	maxTagsPerRequest = 20
+)
+
+// TagUsage is a tag in a dealership's vocabulary and how many customers have it
+type TagUsage struct {
+	Tag           string `json:"tag"`
+	CustomerCount int    `json:"customer_count"`
+}
+
+// CustomerTagsRequest adds or removes tags on one customer
+type CustomerTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// BulkTagRequest tags every customer in a dealership matching a filter. The
+// filter uses the same conditions as segments.
+type BulkTagRequest struct {
+	DealershipID string        `json:"dealership_id"`
+	Filter       SegmentFilter `json:"filter"`
+	Tags         []string      `json:"tags"`
+}
+
+// CustomerTagsResponse is a customer's tags after a change
+type CustomerTagsResponse struct {
+	CustomerID string   `json:"customer_id"`
+	Tags       []string `json:"tags"`
+}
+
+// BulkTagResponse reports how many customers matched a bulk tagging filter
+type BulkTagResponse struct {
+	Tagged int `json:"tagged"`
+}
+
+// normalizeTag collapses whitespace in a tag. Tags keep their case but are
+// compared case-insensitively.
+func normalizeTag(tag string) string {
+	return strings.Join(strings.Fields(tag), " ")
+}
+
+// validateTags validates the tags in a tagging request
+func validateTags(tags []string) []ValidationError {
+	var errors []ValidationError
+
+	if len(tags) == 0 {
+		errors = append(errors, ValidationError{
+			Field:   "tags",
+			Message: "At least one tag is required",
+		})
+	} else if len(tags) > maxTagsPerRequest {
+		errors = append(errors, ValidationError{
+			Field:   "tags",
+			Message: fmt.Sprintf("At most %d tags can be applied at once", maxTagsPerRequest),
+		})
+	}
+
+	for i, tag := range tags {
+		if tag == "" {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("tags[%d]", i),
+				Message: "Tag is required",
+			})
+		} else if len(tag) > maxTagLength {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("tags[%d]", i),
+				Message: fmt.Sprintf("Tag must be %d characters or less", maxTagLength),
+			})
+		}
+	}
+
+	return errors
+}
+
+// Validate validates CustomerTagsRequest
+func (r *CustomerTagsRequest) Validate() *ValidationErrors {
+	if errors := validateTags(r.Tags); len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize sanitizes CustomerTagsRequest
+func (r *CustomerTagsRequest) Sanitize() {
+	for i := range r.Tags {
+		r.Tags[i] = normalizeTag(r.Tags[i])
+	}
+}
+
+// Validate validates BulkTagRequest
+func (r *BulkTagRequest) Validate() *ValidationErrors {
+	var errors []ValidationError
+
+	if r.DealershipID == "" {
+		errors = append(errors, ValidationError{
+			Field:   "dealership_id",
+			Message: "Dealership ID is required",
+		})
+	} else if !uuidRegex.MatchString(r.DealershipID) {
+		errors = append(errors, ValidationError{
+			Field:   "dealership_id",
+			Message: "Must be a valid UUID",
+		})
+	}
+
+	errors = append(errors, r.Filter.Validate("filter")...)
+	errors = append(errors, validateTags(r.Tags)...)
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize sanitizes BulkTagRequest
+func (r *BulkTagRequest) Sanitize() {
+	for i := range r.Tags {
+		r.Tags[i] = normalizeTag(r.Tags[i])
+	}
+}
+
+// listTags returns a dealership's tag vocabulary
+func (s *Server) listTags(w http.ResponseWriter, r *http.Request) {
+	dealershipID := r.URL.Query().Get("dealership_id")
+	if !validateUUID(w, dealershipID, "dealership_id") {
+		return
+	}
+
+	tags, err := s.db.ListTagVocabulary(dealershipID)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list tags")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to list tags: %v", err))
+		return
+	}
+
+	if tags == nil {
+		tags = []TagUsage{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+// addCustomerTags tags a customer
+func (s *Server) addCustomerTags(w http.ResponseWriter, r *http.Request) {
+	customer, ok := s.loadTaggedCustomer(w, r)
+	if !ok {
+		return
+	}
+
+	var req CustomerTagsRequest
+	if !decodeAndValidate(r, w, &req) {
+		return
+	}
+
+	tags, err := s.db.AddCustomerTags(customer.ID, customer.DealershipID, logging.GetUserID(r.Context()), req.Tags)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to tag customer")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to tag customer: %v", err))
+		return
+	}
+
+	s.logger.WithContext(r.Context()).WithField("customer_id", customer.ID).Info("Customer tagged")
+	respondCustomerTags(w, customer.ID, tags)
+}
+
+// removeCustomerTags removes tags from a customer. Tags the customer doesn't
+// have are ignored.
+func (s *Server) removeCustomerTags(w http.ResponseWriter, r *http.Request) {
+	customer, ok := s.loadTaggedCustomer(w, r)
+	if !ok {
+		return
+	}
+
+	var req CustomerTagsRequest
+	if !decodeAndValidate(r, w, &req) {
+		return
+	}
+
+	tags, err := s.db.RemoveCustomerTags(customer.ID, req.Tags)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to remove customer tags")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to remove customer tags: %v", err))
+		return
+	}
+
+	s.logger.WithContext(r.Context()).WithField("customer_id", customer.ID).Info("Customer tags removed")
+	respondCustomerTags(w, customer.ID, tags)
+}
+
+// bulkTagCustomers tags every customer matching a filter
+func (s *Server) bulkTagCustomers(w http.ResponseWriter, r *http.Request) {
+	var req BulkTagRequest
+	if !decodeAndValidate(r, w, &req) {
+		return
+	}
+
+	tagged, err := s.db.BulkTagCustomers(req.DealershipID, logging.GetUserID(r.Context()), req.Filter, req.Tags)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to bulk tag customers")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to bulk tag customers: %v", err))
+		return
+	}
+
+	s.logger.WithContext(r.Context()).WithField("dealership_id", req.DealershipID).WithField("tagged", tagged).Info("Customers bulk tagged")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BulkTagResponse{Tagged: tagged})
+}
+
+// loadTaggedCustomer fetches the customer named in the path
+func (s *Server) loadTaggedCustomer(w http.ResponseWriter, r *http.Request) (*Customer, bool) {
+	id := mux.Vars(r)["id"]
+
+	if !validateUUID(w, id, "id") {
+		return nil, false
+	}
+
+	customer, err := s.db.GetCustomer(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get customer")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get customer: %v", err))
+		return nil, false
+	}
+	if customer == nil {
+		apierror.WriteError(w, http.StatusNotFound, "CUSTOMER_NOT_FOUND", "Customer not found")
+		return nil, false
+	}
+
+	return customer, true
+}
+
+func respondCustomerTags(w http.ResponseWriter, customerID string, tags []string) {
+	if tags == nil {
+		tags = []string{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CustomerTagsResponse{CustomerID: customerID, Tags: tags})
+}
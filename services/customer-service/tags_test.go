@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// hasTag reports whether tags contains tag, ignoring case
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func (db *MockDatabase) AddCustomerTags(customerID, dealershipID, userID string, tags []string) ([]string, error) {
+	customer := db.customers[customerID]
+	for _, tag := range tags {
+		if !hasTag(customer.Tags, tag) {
+			customer.Tags = append(customer.Tags, tag)
+		}
+	}
+	return customer.Tags, nil
+}
+
+func (db *MockDatabase) RemoveCustomerTags(customerID string, tags []string) ([]string, error) {
+	customer := db.customers[customerID]
+	kept := []string{}
+	for _, t := range customer.Tags {
+		if !hasTag(tags, t) {
+			kept = append(kept, t)
+		}
+	}
+	customer.Tags = kept
+	return kept, nil
+}
+
+func (db *MockDatabase) ListTagVocabulary(dealershipID string) ([]TagUsage, error) {
+	counts := make(map[string]*TagUsage)
+	for _, customer := range db.customers {
+		if customer.DealershipID != dealershipID {
+			continue
+		}
+		for _, tag := range customer.Tags {
+			key := strings.ToLower(tag)
+			if counts[key] == nil {
+				counts[key] = &TagUsage{Tag: tag}
+			}
+			counts[key].CustomerCount++
+		}
+	}
+
+	var usage []TagUsage
+	for _, u := range counts {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool { return strings.ToLower(usage[i].Tag) < strings.ToLower(usage[j].Tag) })
+	return usage, nil
+}
+
+func (db *segmentTestDB) BulkTagCustomers(dealershipID, userID string, filter SegmentFilter, tags []string) (int, error) {
+	tagged := 0
+	for _, c := range db.customers {
+		if c.DealershipID != dealershipID || db.deleted[c.ID] || !db.matches(&filter, c) {
+			continue
+		}
+		db.AddCustomerTags(c.ID, dealershipID, userID, tags)
+		tagged++
+	}
+	return tagged, nil
+}
+
+func sendTagRequest(t *testing.T, server *Server, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req, err := http.NewRequest(method, path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func seedTaggedCustomers(db *MockDatabase) {
+	db.customers["c-alice"] = &Customer{ID: "c-alice", DealershipID: segmentTestDealership, FirstName: "Alice", Tags: []string{"VIP"}}
+	db.customers["c-bob"] = &Customer{ID: "c-bob", DealershipID: segmentTestDealership, FirstName: "Bob", Tags: []string{"service-only"}}
+	db.customers["c-carol"] = &Customer{ID: "c-carol", DealershipID: segmentTestDealership, FirstName: "Carol", Tags: []string{"vip", "needs-follow-up"}}
+	db.customers["c-other"] = &Customer{ID: "c-other", DealershipID: "22222222-2222-2222-2222-222222222222", FirstName: "Gina", Tags: []string{"VIP"}}
+}
+
+func TestAddCustomerTags(t *testing.T) {
+	db := NewMockDatabase()
+	server := NewServer(&Config{Port: "8082", DatabaseURL: "mock"}, db, testLogger())
+
+	customerID := "33333333-3333-3333-3333-333333333333"
+	db.customers[customerID] = &Customer{ID: customerID, DealershipID: segmentTestDealership, Tags: []string{}}
+
+	rr := sendTagRequest(t, server, "POST", "/customers/"+customerID+"/tags", `{"tags": ["VIP", "  needs   follow-up "]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("add tags returned %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Adding a tag again in a different case does not duplicate it
+	rr = sendTagRequest(t, server, "POST", "/customers/"+customerID+"/tags", `{"tags": ["vip"]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("add tags returned %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp CustomerTagsResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(resp.Tags, ",") != "VIP,needs follow-up" {
+		t.Errorf("Expected tags [VIP needs follow-up], got %v", resp.Tags)
+	}
+
+	rr = sendTagRequest(t, server, "DELETE", "/customers/"+customerID+"/tags", `{"tags": ["vip"]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("remove tags returned %d: %s", rr.Code, rr.Body.String())
+	}
+	if tags := db.customers[customerID].Tags; len(tags) != 1 || tags[0] != "needs follow-up" {
+		t.Errorf("Expected only 'needs follow-up' to remain, got %v", tags)
+	}
+}
+
+func TestCustomerTagsValidation(t *testing.T) {
+	db := NewMockDatabase()
+	server := NewServer(&Config{Port: "8082", DatabaseURL: "mock"}, db, testLogger())
+
+	customerID := "33333333-3333-3333-3333-333333333333"
+	db.customers[customerID] = &Customer{ID: customerID, DealershipID: segmentTestDealership}
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+	}{
+		{"no tags", "/customers/" + customerID + "/tags", `{"tags": []}`, http.StatusBadRequest},
+		{"blank tag", "/customers/" + customerID + "/tags", `{"tags": ["   "]}`, http.StatusBadRequest},
+		{"tag too long", "/customers/" + customerID + "/tags", `{"tags": ["` + strings.Repeat("x", maxTagLength+1) + `"]}`, http.StatusBadRequest},
+		{"unknown customer", "/customers/44444444-4444-4444-4444-444444444444/tags", `{"tags": ["VIP"]}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := sendTagRequest(t, server, "POST", tt.path, tt.body)
+			if rr.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestListCustomersFilterByTag(t *testing.T) {
+	db := NewMockDatabase()
+	server := NewServer(&Config{Port: "8082", DatabaseURL: "mock"}, db, testLogger())
+	seedTaggedCustomers(db)
+
+	rr := sendTagRequest(t, server, "GET", "/customers?dealership_id="+segmentTestDealership+"&tag=vip", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("list customers returned %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var result CustomerListResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, c := range result.Customers {
+		names = append(names, c.FirstName)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "Alice,Carol" {
+		t.Errorf("Expected Alice and Carol, got %v", names)
+	}
+}
+
+func TestListTagVocabulary(t *testing.T) {
+	db := NewMockDatabase()
+	server := NewServer(&Config{Port: "8082", DatabaseURL: "mock"}, db, testLogger())
+	seedTaggedCustomers(db)
+
+	rr := sendTagRequest(t, server, "GET", "/customers/tags?dealership_id="+segmentTestDealership, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("list tags returned %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var usage []TagUsage
+	if err := json.Unmarshal(rr.Body.Bytes(), &usage); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(usage) != 3 {
+		t.Fatalf("Expected 3 tags, got %+v", usage)
+	}
+	if !strings.EqualFold(usage[2].Tag, "vip") || usage[2].CustomerCount != 2 {
+		t.Errorf("Expected VIP on 2 customers, got %+v", usage[2])
+	}
+
+	rr = sendTagRequest(t, server, "GET", "/customers/tags", "")
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without dealership_id, got %d", rr.Code)
+	}
+}
+
+func TestBulkTagCustomers(t *testing.T) {
+	server, db := setupSegmentTestServer()
+
+	body := `{
+		"dealership_id": "` + segmentTestDealership + `",
+		"filter": {"field": "deal_amount", "op": "gt", "value": 40000, "within_days": 365},
+		"tags": ["VIP"]
+	}`
+	rr := sendTagRequest(t, server, "POST", "/customers/bulk-tags", body)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("bulk tag returned %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp BulkTagResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	// Alice and Dave have recent large deals; Frank is deleted and Gina is
+	// in another dealership
+	if resp.Tagged != 2 {
+		t.Errorf("Expected 2 customers tagged, got %d", resp.Tagged)
+	}
+	for id, want := range map[string]bool{"c-alice": true, "c-dave": true, "c-bob": false, "c-frank": false, "c-other": false} {
+		if got := hasTag(db.customers[id].Tags, "vip"); got != want {
+			t.Errorf("%s tagged = %v, want %v", id, got, want)
+		}
+	}
+
+	rr = sendTagRequest(t, server, "POST", "/customers/bulk-tags", `{"dealership_id": "`+segmentTestDealership+`", "filter": {"field": "tag", "op": "within_days"}, "tags": ["x"]}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid filter, got %d", rr.Code)
+	}
+}
+
+func TestSegmentWithTagCondition(t *testing.T) {
+	server, db := setupSegmentTestServer()
+	db.customers["c-alice"].Tags = []string{"VIP"}
+	db.customers["c-bob"].Tags = []string{"vip", "service-only"}
+	db.customers["c-carol"].Tags = []string{"service-only"}
+
+	segment := createTestSegment(t, server, `{
+		"dealership_id": "`+segmentTestDealership+`",
+		"name": "VIPs not service-only",
+		"channel": "email",
+		"filter": {"and": [
+			{"field": "tag", "op": "eq", "value": "Vip"},
+			{"field": "tag", "op": "neq", "value": "service-only"}
+		]}
+	}`)
+
+	resp := getTestSegmentMembers(t, server, segment.ID, "")
+	if ids := memberIDs(resp.Members); len(ids) != 1 || ids[0] != "c-alice" {
+		t.Errorf("Expected only c-alice, got %v", ids)
+	}
+}
+
+func TestTagFilterToSQL(t *testing.T) {
+	values := []interface{}{"VIP", "Service-Only"}
+	filter := SegmentFilter{Or: []SegmentFilter{
+		{Field: SegmentFieldTag, Op: "in", Value: values},
+		{Field: SegmentFieldTag, Op: "neq", Value: "Lost"},
+	}}
+
+	args := []interface{}{segmentTestDealership}
+	sql, err := filter.toSQL(&args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "(EXISTS (SELECT 1 FROM customer_tags t WHERE t.customer_id = c.id AND LOWER(t.tag) = ANY($2))" +
+		" OR NOT EXISTS (SELECT 1 FROM customer_tags t WHERE t.customer_id = c.id AND LOWER(t.tag) = LOWER($3)))"
+	if sql != expected {
+		t.Errorf("Unexpected SQL:\n got: %s\nwant: %s", sql, expected)
+	}
+	if len(args) != 3 || args[2] != "Lost" {
+		t.Errorf("Unexpected args: %v", args)
+	}
+	if values[0] != "VIP" {
+		t.Errorf("Filter values were modified: %v", values)
+	}
+}
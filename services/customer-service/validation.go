@@ -5,6 +5,10 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
+	"time"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/bodylimit"
 )
 
 // ValidationError represents a single validation error
@@ -18,13 +22,6 @@ type ValidationErrors struct {
 	Errors []ValidationError `json:"errors"`
 }
 
-// ValidationErrorResponse is the standard error response format
-type ValidationErrorResponse struct {
-	Error   string            `json:"error"`
-	Code    string            `json:"code"`
-	Details []ValidationError `json:"details,omitempty"`
-}
-
 // CreateCustomerRequest represents a request to create a customer
 type CreateCustomerRequest struct {
 	DealershipID         string  `json:"dealership_id"`
@@ -40,6 +37,9 @@ type CreateCustomerRequest struct {
 	DriversLicenseNumber string  `json:"drivers_license_number,omitempty"`
 	CreditScore          int     `json:"credit_score,omitempty"`
 	MonthlyIncome        float64 `json:"monthly_income,omitempty"`
+
+	// DateOfBirth is optional, in YYYY-MM-DD format
+	DateOfBirth string `json:"date_of_birth,omitempty"`
 }
 
 // UpdateCustomerRequest represents a request to update a customer
@@ -56,6 +56,18 @@ type UpdateCustomerRequest struct {
 	DriversLicenseNumber string  `json:"drivers_license_number,omitempty"`
 	CreditScore          int     `json:"credit_score,omitempty"`
 	MonthlyIncome        float64 `json:"monthly_income,omitempty"`
+
+	// DateOfBirth is optional, in YYYY-MM-DD format
+	DateOfBirth string `json:"date_of_birth,omitempty"`
+}
+
+// CreateSegmentRequest represents a request to create a customer segment
+type CreateSegmentRequest struct {
+	DealershipID string        `json:"dealership_id"`
+	Name         string        `json:"name"`
+	Description  string        `json:"description,omitempty"`
+	Channel      string        `json:"channel"`
+	Filter       SegmentFilter `json:"filter"`
 }
 
 var (
@@ -82,6 +94,22 @@ var (
 	}
 )
 
+// validateDateOfBirth checks a YYYY-MM-DD date of birth, returning a message
+// describing the problem or "" if it is valid
+func validateDateOfBirth(value string) string {
+	dob, err := time.Parse(dateOfBirthLayout, value)
+	if err != nil {
+		return "Must be a date in YYYY-MM-DD format"
+	}
+	if dob.After(time.Now()) {
+		return "Date of birth cannot be in the future"
+	}
+	if dob.Year() < 1900 {
+		return "Date of birth must be after 1900"
+	}
+	return ""
+}
+
 // Validate validates CreateCustomerRequest
 func (r *CreateCustomerRequest) Validate() *ValidationErrors {
 	var errors []ValidationError
@@ -189,6 +217,16 @@ func (r *CreateCustomerRequest) Validate() *ValidationErrors {
 		})
 	}
 
+	// Date of birth validation
+	if r.DateOfBirth != "" {
+		if message := validateDateOfBirth(r.DateOfBirth); message != "" {
+			errors = append(errors, ValidationError{
+				Field:   "date_of_birth",
+				Message: message,
+			})
+		}
+	}
+
 	// Address length validation
 	if len(r.Address) > 500 {
 		errors = append(errors, ValidationError{
@@ -224,6 +262,7 @@ func (r *CreateCustomerRequest) Sanitize() {
 	r.ZipCode = strings.TrimSpace(r.ZipCode)
 	r.SSNLast4 = strings.TrimSpace(r.SSNLast4)
 	r.DriversLicenseNumber = strings.TrimSpace(strings.ToUpper(r.DriversLicenseNumber))
+	r.DateOfBirth = strings.TrimSpace(r.DateOfBirth)
 }
 
 // Validate validates UpdateCustomerRequest
@@ -310,6 +349,16 @@ func (r *UpdateCustomerRequest) Validate() *ValidationErrors {
 		})
 	}
 
+	// Date of birth validation
+	if r.DateOfBirth != "" {
+		if message := validateDateOfBirth(r.DateOfBirth); message != "" {
+			errors = append(errors, ValidationError{
+				Field:   "date_of_birth",
+				Message: message,
+			})
+		}
+	}
+
 	if len(errors) > 0 {
 		return &ValidationErrors{Errors: errors}
 	}
@@ -328,27 +377,78 @@ func (r *UpdateCustomerRequest) Sanitize() {
 	r.ZipCode = strings.TrimSpace(r.ZipCode)
 	r.SSNLast4 = strings.TrimSpace(r.SSNLast4)
 	r.DriversLicenseNumber = strings.TrimSpace(strings.ToUpper(r.DriversLicenseNumber))
+	r.DateOfBirth = strings.TrimSpace(r.DateOfBirth)
+}
+
+// Validate validates CreateSegmentRequest
+func (r *CreateSegmentRequest) Validate() *ValidationErrors {
+	var errors []ValidationError
+
+	// Dealership ID validation
+	if r.DealershipID == "" {
+		errors = append(errors, ValidationError{
+			Field:   "dealership_id",
+			Message: "Dealership ID is required",
+		})
+	} else if !uuidRegex.MatchString(r.DealershipID) {
+		errors = append(errors, ValidationError{
+			Field:   "dealership_id",
+			Message: "Must be a valid UUID",
+		})
+	}
+
+	// Name validation
+	if r.Name == "" {
+		errors = append(errors, ValidationError{
+			Field:   "name",
+			Message: "Name is required",
+		})
+	} else if len(r.Name) > 200 {
+		errors = append(errors, ValidationError{
+			Field:   "name",
+			Message: "Name must be 200 characters or less",
+		})
+	}
+
+	if len(r.Description) > 1000 {
+		errors = append(errors, ValidationError{
+			Field:   "description",
+			Message: "Description must be 1000 characters or less",
+		})
+	}
+
+	// Channel validation
+	if _, ok := segmentChannelConsent[r.Channel]; !ok {
+		errors = append(errors, ValidationError{
+			Field:   "channel",
+			Message: "Channel must be one of: email, sms, phone",
+		})
+	}
+
+	// Filter validation
+	errors = append(errors, r.Filter.Validate("filter")...)
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize sanitizes CreateSegmentRequest
+func (r *CreateSegmentRequest) Sanitize() {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Description = strings.TrimSpace(r.Description)
+	r.Channel = strings.TrimSpace(strings.ToLower(r.Channel))
 }
 
 // respondValidationError writes a validation error response
 func respondValidationError(w http.ResponseWriter, errors *ValidationErrors) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusBadRequest)
-	json.NewEncoder(w).Encode(ValidationErrorResponse{
-		Error:   "Validation failed",
-		Code:    "VALIDATION_ERROR",
-		Details: errors.Errors,
-	})
+	apierror.WriteErrorDetails(w, http.StatusBadRequest, apierror.CodeValidation, "Validation failed", errors.Errors)
 }
 
 // respondErrorJSON writes a JSON error response
 func respondErrorJSON(w http.ResponseWriter, status int, message, code string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(ValidationErrorResponse{
-		Error: message,
-		Code:  code,
-	})
+	apierror.WriteError(w, status, code, message)
 }
 
 // Validatable interface for types that can validate themselves
@@ -364,6 +464,10 @@ type Sanitizable interface {
 // decodeAndValidate decodes JSON and validates the request
 func decodeAndValidate(r *http.Request, w http.ResponseWriter, dst interface{}) bool {
 	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		if bodylimit.IsTooLarge(err) {
+			bodylimit.RespondTooLarge(w)
+			return false
+		}
 		respondErrorJSON(w, http.StatusBadRequest, "Invalid request body: "+err.Error(), "INVALID_JSON")
 		return false
 	}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"autolytiq/shared/residency"
+
+	"github.com/google/uuid"
+)
+
+// archivableEntityTypes are the entity types the archive retention action
+// supports
+var archivableEntityTypes = map[string]bool{
+	"customer": true,
+}
+
+// archiveEncryptor encrypts archives before they leave the database
+type archiveEncryptor interface {
+	Encrypt(plaintext string) (string, error)
+}
+
+// retentionArchiveStore is the storage used by the archive retention action
+type retentionArchiveStore interface {
+	GetCustomerWithRelatedData(ctx context.Context, customerID, dealershipID string) (*CustomerExportData, error)
+	CreateRetentionArchive(ctx context.Context, archive *RetentionArchive) error
+	PurgeCustomer(ctx context.Context, customerID, dealershipID string) error
+}
+
+// archiveCustomer exports a customer and their related records to cold
+// storage in the customer's data region, gzipped and encrypted, records the
+// archive location, then removes the records from the database. Nothing is
+// removed unless the archive was written and recorded.
+func (s *RetentionService) archiveCustomer(ctx context.Context, policy *RetentionPolicy, customerID, dealershipID string) (*RetentionArchive, error) {
+	if !s.archiveStorage.Enabled() {
+		return nil, fmt.Errorf("no archive storage configured")
+	}
+	if s.encryptor == nil {
+		return nil, fmt.Errorf("archive encryption is not configured")
+	}
+
+	export, err := s.archives.GetCustomerWithRelatedData(ctx, customerID, dealershipID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export customer: %w", err)
+	}
+
+	region, err := dataRegion(ctx, s.settings, dealershipID, export.Customer.Region)
+	if err != nil {
+		return nil, err
+	}
+	export.Customer.Region = region
+
+	backend, err := s.archiveStorage.Backend(region)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := sealArchive(s.encryptor, export)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := s.newArchiveStore(ctx, backend)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := &RetentionArchive{
+		ID:           uuid.New().String(),
+		PolicyID:     policy.ID,
+		DealershipID: dealershipID,
+		EntityType:   "customer",
+		EntityID:     customerID,
+		Region:       backend.Region,
+		Bucket:       backend.Bucket,
+		RecordCounts: exportedCounts(export),
+		ArchivedAt:   time.Now(),
+	}
+	archive.Key = fmt.Sprintf("retention-archives/%s/customer/%s/%s.json.gz.enc", dealershipID, customerID, archive.ID)
+
+	if err := store.PutObject(ctx, archive.Key, "application/octet-stream", body); err != nil {
+		return nil, err
+	}
+	if err := s.archives.CreateRetentionArchive(ctx, archive); err != nil {
+		return nil, fmt.Errorf("failed to record archive: %w", err)
+	}
+	if err := s.archives.PurgeCustomer(ctx, customerID, dealershipID); err != nil {
+		return archive, fmt.Errorf("failed to remove archived customer: %w", err)
+	}
+
+	s.logger.WithField("customer_id", customerID).
+		WithField("region", archive.Region).
+		WithField("key", archive.Key).
+		Info("Customer archived to cold storage")
+
+	return archive, nil
+}
+
+// sealArchive encodes an export as gzipped JSON and encrypts it
+func sealArchive(encryptor archiveEncryptor, export *CustomerExportData) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(export); err != nil {
+		return nil, fmt.Errorf("failed to encode archive: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress archive: %w", err)
+	}
+
+	sealed, err := encryptor.Encrypt(buf.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt archive: %w", err)
+	}
+	return []byte(sealed), nil
+}
+
+// newArchiveStorage returns the cold storage router, falling back to a
+// router with no backends when none is configured
+func newArchiveStorage(router *residency.Router) *residency.Router {
+	if router == nil {
+		return residency.NewRouter(false)
+	}
+	return router
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"autolytiq/services/shared/logging"
+	"autolytiq/shared/encryption"
+	"autolytiq/shared/residency"
+)
+
+// fakeArchiveStore holds customers in memory for the archive action
+type fakeArchiveStore struct {
+	customers map[string]*CustomerExportData
+	archives  []*RetentionArchive
+	purged    []string
+}
+
+func (f *fakeArchiveStore) GetCustomerWithRelatedData(ctx context.Context, customerID, dealershipID string) (*CustomerExportData, error) {
+	export, ok := f.customers[customerID]
+	if !ok || export.Customer.DealershipID != dealershipID {
+		return nil, errors.New("customer not found")
+	}
+	return export, nil
+}
+
+func (f *fakeArchiveStore) CreateRetentionArchive(ctx context.Context, archive *RetentionArchive) error {
+	f.archives = append(f.archives, archive)
+	return nil
+}
+
+func (f *fakeArchiveStore) PurgeCustomer(ctx context.Context, customerID, dealershipID string) error {
+	delete(f.customers, customerID)
+	f.purged = append(f.purged, customerID)
+	return nil
+}
+
+// failingExportStore rejects every upload
+type failingExportStore struct{}
+
+func (failingExportStore) PutObject(ctx context.Context, key, contentType string, body []byte) error {
+	return errors.New("bucket unavailable")
+}
+
+// newArchiveTestService returns a retention service with US and EU archive
+// backends and the stores created for them, keyed by data region
+func newArchiveTestService(t *testing.T) (*RetentionService, *fakeArchiveStore, map[string]*fakeExportStore, *encryption.Encryptor) {
+	t.Helper()
+	key, err := encryption.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	km, err := encryption.NewKeyManagerWithKeys(map[string][]byte{"v1": key}, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptor := encryption.NewEncryptor(km)
+
+	archives := &fakeArchiveStore{customers: map[string]*CustomerExportData{
+		"cust-1": {
+			CustomerID: "cust-1",
+			Customer: CustomerData{
+				ID: "cust-1", DealershipID: "dealer-1", Region: residency.RegionEU,
+				FirstName: "Ada", Email: "ada@example.com", Tags: []string{"VIP"},
+			},
+			Deals: []DealData{{ID: "deal-1"}, {ID: "deal-2"}},
+		},
+	}}
+	stores := make(map[string]*fakeExportStore)
+	service := &RetentionService{
+		logger:   logging.New(logging.Config{Service: "data-retention-service-test"}),
+		archives: archives,
+		archiveStorage: residency.NewRouter(false,
+			residency.Backend{Region: residency.RegionUS, Bucket: "retention-archive-us"},
+			residency.Backend{Region: residency.RegionEU, Bucket: "retention-archive-eu"},
+		),
+		newArchiveStore: func(ctx context.Context, backend residency.Backend) (ExportStore, error) {
+			store, ok := stores[backend.Region]
+			if !ok {
+				store = &fakeExportStore{backend: backend}
+				stores[backend.Region] = store
+			}
+			return store, nil
+		},
+		encryptor: encryptor,
+	}
+	return service, archives, stores, encryptor
+}
+
+func TestArchiveCustomer_ExportsThenRemoves(t *testing.T) {
+	service, archives, stores, encryptor := newArchiveTestService(t)
+	policy := &RetentionPolicy{ID: "policy-1", EntityType: "customer", Action: "archive"}
+
+	archive, err := service.archiveCustomer(context.Background(), policy, "cust-1", "dealer-1")
+	if err != nil {
+		t.Fatalf("Expected customer to be archived, got %v", err)
+	}
+
+	eu := stores[residency.RegionEU]
+	if eu == nil || len(eu.keys) != 1 {
+		t.Fatalf("Expected one archive in the EU store, got %+v", stores)
+	}
+	if _, ok := stores[residency.RegionUS]; ok {
+		t.Error("Expected the US store not to be used for an EU customer")
+	}
+	if bytes.Contains(eu.bodies[0], []byte("ada@example.com")) {
+		t.Error("Expected the archive to be encrypted")
+	}
+
+	plaintext, err := encryptor.Decrypt(string(eu.bodies[0]))
+	if err != nil {
+		t.Fatalf("Expected the archive to decrypt, got %v", err)
+	}
+	zr, err := gzip.NewReader(strings.NewReader(plaintext))
+	if err != nil {
+		t.Fatalf("Expected the archive to be gzipped, got %v", err)
+	}
+	var stored CustomerExportData
+	if err := json.NewDecoder(zr).Decode(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Customer.Email != "ada@example.com" || len(stored.Deals) != 2 || len(stored.Customer.Tags) != 1 {
+		t.Errorf("Expected the archive to hold the customer and related records, got %+v", stored)
+	}
+
+	if len(archives.archives) != 1 || archives.archives[0] != archive {
+		t.Fatalf("Expected the archive to be recorded, got %+v", archives.archives)
+	}
+	if archive.Key != eu.keys[0] || archive.Bucket != "retention-archive-eu" || archive.Region != residency.RegionEU {
+		t.Errorf("Expected the record to point at the stored archive, got %+v", archive)
+	}
+	if archive.PolicyID != "policy-1" || archive.RecordCounts[ExportEntityDeals] != 2 {
+		t.Errorf("Expected the policy and record counts to be recorded, got %+v", archive)
+	}
+
+	if len(archives.purged) != 1 || archives.customers["cust-1"] != nil {
+		t.Errorf("Expected the customer to be removed, got %v", archives.purged)
+	}
+}
+
+func TestArchiveCustomer_KeepsRecordsWhenUploadFails(t *testing.T) {
+	service, archives, _, _ := newArchiveTestService(t)
+	service.newArchiveStore = func(ctx context.Context, backend residency.Backend) (ExportStore, error) {
+		return failingExportStore{}, nil
+	}
+
+	if _, err := service.archiveCustomer(context.Background(), &RetentionPolicy{ID: "policy-1"}, "cust-1", "dealer-1"); err == nil {
+		t.Fatal("Expected the failed upload to be returned")
+	}
+	if len(archives.purged) != 0 || len(archives.archives) != 0 {
+		t.Errorf("Expected nothing to be removed or recorded, got %v, %+v", archives.purged, archives.archives)
+	}
+}
+
+func TestArchiveCustomer_RequiresEncryption(t *testing.T) {
+	service, archives, stores, _ := newArchiveTestService(t)
+	service.encryptor = nil
+
+	if _, err := service.archiveCustomer(context.Background(), &RetentionPolicy{ID: "policy-1"}, "cust-1", "dealer-1"); err == nil {
+		t.Fatal("Expected archiving without an encryption key to fail")
+	}
+	if len(stores) != 0 || len(archives.purged) != 0 {
+		t.Error("Expected nothing to be stored or removed")
+	}
+}
+
+func TestValidatePolicy_Archive(t *testing.T) {
+	service := &RetentionService{}
+
+	if err := service.validatePolicy(&RetentionPolicy{Name: "customers", EntityType: "customer", RetentionDays: 30, Action: "archive"}); err != nil {
+		t.Errorf("Expected archive to be accepted for customers, got %v", err)
+	}
+	if err := service.validatePolicy(&RetentionPolicy{Name: "sessions", EntityType: "session", RetentionDays: 30, Action: "archive"}); err == nil {
+		t.Error("Expected archive to be rejected for an entity type without archive support")
+	}
+	if err := service.validatePolicy(&RetentionPolicy{Name: "customers", EntityType: "customer", RetentionDays: 30, Action: "shred"}); err == nil {
+		t.Error("Expected an unknown action to be rejected")
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"autolytiq/services/shared/logging"
+)
+
+// maxAuditLogBodySize is the largest audit log body accepted from other
+// services
+const maxAuditLogBodySize = 256 << 10
+
+// ingestEntityTypes are the entity types other services may record audit
+// logs for
+var ingestEntityTypes = map[string]bool{
+	"customer":       true,
+	"deal":           true,
+	"vehicle":        true,
+	"showroom_visit": true,
+}
+
+// ingestActions are the actions other services may record
+var ingestActions = map[string]bool{
+	"create":    true,
+	"read":      true,
+	"update":    true,
+	"delete":    true,
+	"export":    true,
+	"anonymize": true,
+}
+
+// auditLogWriter is the storage used to record audit logs sent by other
+// services
+type auditLogWriter interface {
+	CreateAuditLog(ctx context.Context, log *AuditLog) error
+}
+
+// AuditLogCreatedResponse is the response to recording an audit log
+type AuditLogCreatedResponse struct {
+	ID string `json:"id"`
+}
+
+// createAuditLog records an audit log sent by another service. The request
+// ID is kept in the log's metadata so the entry can be matched to the trace
+// of the change it records.
+func (s *Server) createAuditLog(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxAuditLogBodySize)
+
+	var log AuditLog
+	if err := json.NewDecoder(r.Body).Decode(&log); err != nil {
+		if strings.Contains(err.Error(), "request body too large") {
+			http.Error(w, fmt.Sprintf("Audit log body exceeds %d bytes", maxAuditLogBodySize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	log.EntityType = strings.TrimSpace(log.EntityType)
+	log.Action = strings.TrimSpace(log.Action)
+	if !ingestEntityTypes[log.EntityType] {
+		http.Error(w, "Invalid entity_type", http.StatusBadRequest)
+		return
+	}
+	if !isValidUUID(log.EntityID) {
+		http.Error(w, "Invalid entity_id format", http.StatusBadRequest)
+		return
+	}
+	if !ingestActions[log.Action] {
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	if log.PerformedBy == "" {
+		log.PerformedBy = r.Header.Get("X-User-ID")
+	}
+	if log.PerformedBy == "" {
+		log.PerformedBy = "system"
+	}
+	if traceID := logging.GetTraceID(r.Context()); traceID != "" {
+		if log.Metadata == nil {
+			log.Metadata = map[string]interface{}{}
+		}
+		log.Metadata["request_id"] = traceID
+	}
+
+	if err := s.auditLogs.CreateAuditLog(r.Context(), &log); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to create audit log")
+		http.Error(w, fmt.Sprintf("Failed to create audit log: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(AuditLogCreatedResponse{ID: log.ID})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"autolytiq/services/shared/logging"
+
+	"github.com/gorilla/mux"
+)
+
+// fakeAuditLogWriter keeps created audit logs in memory
+type fakeAuditLogWriter struct {
+	logs []*AuditLog
+}
+
+func (f *fakeAuditLogWriter) CreateAuditLog(ctx context.Context, log *AuditLog) error {
+	log.ID = "log-1"
+	f.logs = append(f.logs, log)
+	return nil
+}
+
+func newAuditLogServer() (*Server, *fakeAuditLogWriter) {
+	store := &fakeAuditLogWriter{}
+	server := &Server{
+		router:    mux.NewRouter(),
+		logger:    logging.New(logging.Config{Service: "data-retention-service-test"}),
+		auditLogs: store,
+	}
+	server.router.Use(logging.RequestIDMiddleware)
+	server.router.HandleFunc("/audit/logs", server.createAuditLog).Methods("POST")
+	return server, store
+}
+
+func postAuditLog(server *Server, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/audit/logs", strings.NewReader(body))
+	req.Header.Set("X-Request-ID", "req-42")
+	req.Header.Set("X-User-ID", "user-7")
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestCreateAuditLog(t *testing.T) {
+	server, store := newAuditLogServer()
+
+	rr := postAuditLog(server, `{
+		"dealership_id": "d1",
+		"entity_type": "deal",
+		"entity_id": "6f1c2b9e-3a4d-4c5e-8f7a-9b0c1d2e3f40",
+		"action": "update",
+		"old_data": {"status": "draft"},
+		"new_data": {"status": "pending"}
+	}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created AuditLogCreatedResponse
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	if created.ID != "log-1" {
+		t.Errorf("Expected the created log's ID, got %+v", created)
+	}
+
+	if len(store.logs) != 1 {
+		t.Fatalf("Expected 1 stored log, got %d", len(store.logs))
+	}
+	log := store.logs[0]
+	if log.PerformedBy != "user-7" || log.NewData["status"] != "pending" || log.Metadata["request_id"] != "req-42" {
+		t.Errorf("Expected the caller and request ID to be recorded, got %+v", log)
+	}
+	if rr.Header().Get("X-Request-ID") != "req-42" {
+		t.Errorf("Expected the request ID to be echoed, got %q", rr.Header().Get("X-Request-ID"))
+	}
+}
+
+func TestCreateAuditLogValidation(t *testing.T) {
+	server, store := newAuditLogServer()
+	const entityID = "6f1c2b9e-3a4d-4c5e-8f7a-9b0c1d2e3f40"
+
+	tests := []struct {
+		name, body string
+		want       int
+	}{
+		{"malformed", `{"entity_type":`, http.StatusBadRequest},
+		{"unknown entity type", `{"entity_type":"invoice","entity_id":"` + entityID + `","action":"create"}`, http.StatusBadRequest},
+		{"entity ID not a UUID", `{"entity_type":"deal","entity_id":"deal-1","action":"create"}`, http.StatusBadRequest},
+		{"unknown action", `{"entity_type":"deal","entity_id":"` + entityID + `","action":"purge"}`, http.StatusBadRequest},
+		{"too large", `{"entity_type":"deal","entity_id":"` + entityID + `","action":"create","metadata":{"note":"` +
+			strings.Repeat("x", maxAuditLogBodySize) + `"}}`, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		if rr := postAuditLog(server, tt.body); rr.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, rr.Code, rr.Body.String())
+		}
+	}
+	if len(store.logs) != 0 {
+		t.Errorf("Expected nothing stored, got %d logs", len(store.logs))
+	}
+}
@@ -2,11 +2,182 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"regexp"
+	"time"
 
 	"autolytiq/services/shared/logging"
 )
 
+// defaultConsentVersion is recorded for consent given before any version has
+// been registered for the dealership
+const defaultConsentVersion = "1.0"
+
+// maxConsentVersionLength matches the consent_version column size
+const maxConsentVersionLength = 20
+
+// Consent version errors
+var (
+	ErrInvalidConsentVersion    = errors.New("invalid consent version")
+	ErrConsentVersionExists     = errors.New("consent version already registered")
+	ErrConsentVersionNotCurrent = errors.New("consent version is not the current version")
+	ErrNoConsentVersion         = errors.New("no consent version is in effect")
+	ErrInvalidConsentSource     = errors.New("invalid consent source")
+)
+
+// defaultConsentSource is recorded when an update does not name its channel
+const defaultConsentSource = "api"
+
+// consentSources are the channels consent can be captured through
+var consentSources = map[string]bool{
+	"web_form":  true,
+	"kiosk":     true,
+	"in_person": true,
+	"phone":     true,
+	"email":     true,
+	"sms":       true,
+	"import":    true,
+	"api":       true,
+
+	// Marketing opt-out links
+	"unsubscribe": true,
+}
+
+// documentHashPattern matches a hex-encoded SHA-256
+var documentHashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// setConsentDocumentHash records the hash of a version's policy text. The
+// text itself is not stored; a caller that keeps the text elsewhere may pass
+// just its hash, which must match the text when both are given.
+func setConsentDocumentHash(version *ConsentVersion, documentText string) error {
+	if documentText == "" {
+		if version.DocumentHash != "" && !documentHashPattern.MatchString(version.DocumentHash) {
+			return fmt.Errorf("%w: document_hash must be a hex-encoded SHA-256", ErrInvalidConsentVersion)
+		}
+		return nil
+	}
+
+	sum := sha256.Sum256([]byte(documentText))
+	hash := hex.EncodeToString(sum[:])
+	if version.DocumentHash != "" && version.DocumentHash != hash {
+		return fmt.Errorf("%w: document_hash does not match document_text", ErrInvalidConsentVersion)
+	}
+	version.DocumentHash = hash
+	return nil
+}
+
+// consentSnapshot captures every consent flag of a consent record
+func consentSnapshot(consent *CustomerConsent) *ConsentSnapshot {
+	return &ConsentSnapshot{
+		MarketingEmail:    consent.MarketingEmail,
+		MarketingSMS:      consent.MarketingSMS,
+		MarketingPhone:    consent.MarketingPhone,
+		DataProcessing:    consent.DataProcessing,
+		ThirdPartySharing: consent.ThirdPartySharing,
+		Analytics:         consent.Analytics,
+		ConsentVersion:    consent.ConsentVersion,
+	}
+}
+
+// requestFingerprint hashes the request context so a history row can be
+// matched against request logs without comparing each field
+func requestFingerprint(req *ConsentRequestContext) string {
+	if req == nil {
+		return ""
+	}
+	encoded, _ := json.Marshal(req)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// consentChange is a single consent flag changed by an update
+type consentChange struct {
+	Type     string
+	OldValue bool
+	NewValue bool
+}
+
+// consentHistoryRecords builds the history rows for an update's changes.
+// Each row carries the full consent state after the update and the version
+// in effect, so it is a self-contained proof of what the customer agreed to.
+func consentHistoryRecords(update *ConsentUpdate, consent *CustomerConsent, version *ConsentVersion, changes []consentChange) []*ConsentHistory {
+	var documentHash string
+	if version != nil && version.Version == consent.ConsentVersion {
+		documentHash = version.DocumentHash
+	}
+	snapshot := consentSnapshot(consent)
+	fingerprint := requestFingerprint(update.Request)
+
+	records := make([]*ConsentHistory, 0, len(changes))
+	for _, change := range changes {
+		oldValue := change.OldValue
+		records = append(records, &ConsentHistory{
+			CustomerID:         update.CustomerID,
+			DealershipID:       update.DealershipID,
+			ConsentType:        change.Type,
+			OldValue:           &oldValue,
+			NewValue:           change.NewValue,
+			ChangedBy:          update.UpdatedBy,
+			IPAddress:          update.IPAddress,
+			UserAgent:          update.UserAgent,
+			ConsentVersion:     consent.ConsentVersion,
+			DocumentHash:       documentHash,
+			Source:             update.Source,
+			Request:            update.Request,
+			RequestFingerprint: fingerprint,
+			Snapshot:           snapshot,
+		})
+	}
+	return records
+}
+
+// currentConsentVersion returns the version in effect at now: the latest
+// effective date that has passed. versions must be ordered by effective date.
+func currentConsentVersion(versions []*ConsentVersion, now time.Time) *ConsentVersion {
+	var current *ConsentVersion
+	for _, v := range versions {
+		if v.EffectiveAt.After(now) {
+			break
+		}
+		current = v
+	}
+	return current
+}
+
+// flagReconsent marks the consent as needing re-consent when it was given
+// under a version other than the current one. The consent values themselves
+// are never changed.
+func flagReconsent(consent *CustomerConsent, current *ConsentVersion) {
+	if current == nil {
+		return
+	}
+	consent.CurrentConsentVersion = current.Version
+	consent.NeedsReconsent = consent.ConsentVersion != current.Version
+}
+
+// validateNewConsentVersion checks a version against those already
+// registered. Versions must be unique and take effect after every existing
+// version, so the current version only ever moves forward.
+func validateNewConsentVersion(version *ConsentVersion, existing []*ConsentVersion) error {
+	if version.Version == "" || len(version.Version) > maxConsentVersionLength {
+		return fmt.Errorf("%w: version must be 1-%d characters", ErrInvalidConsentVersion, maxConsentVersionLength)
+	}
+	for _, v := range existing {
+		if v.Version == version.Version {
+			return fmt.Errorf("%w: %s", ErrConsentVersionExists, version.Version)
+		}
+		if !version.EffectiveAt.After(v.EffectiveAt) {
+			return fmt.Errorf("%w: effective date must be after version %s (%s)",
+				ErrInvalidConsentVersion, v.Version, v.EffectiveAt.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
 // ConsentService handles customer consent management
 type ConsentService struct {
 	db     *Database
@@ -27,9 +198,138 @@ func (s *ConsentService) GetConsent(ctx context.Context, customerID, dealershipI
 	if err != nil {
 		return nil, fmt.Errorf("failed to get consent: %w", err)
 	}
+
+	current, err := s.GetCurrentVersion(ctx, dealershipID)
+	if err != nil {
+		return nil, err
+	}
+	// Customers who have never consented have nothing to re-consent to
+	if consent.ID != "" {
+		flagReconsent(consent, current)
+	}
+
 	return consent, nil
 }
 
+// GetCurrentVersion returns the dealership's consent version in effect now,
+// or nil if none has been registered
+func (s *ConsentService) GetCurrentVersion(ctx context.Context, dealershipID string) (*ConsentVersion, error) {
+	versions, err := s.db.ListConsentVersions(ctx, dealershipID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list consent versions: %w", err)
+	}
+	return currentConsentVersion(versions, time.Now()), nil
+}
+
+// ListVersions lists a dealership's registered consent versions
+func (s *ConsentService) ListVersions(ctx context.Context, dealershipID string) ([]*ConsentVersion, error) {
+	versions, err := s.db.ListConsentVersions(ctx, dealershipID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list consent versions: %w", err)
+	}
+	return versions, nil
+}
+
+// RegisterVersion registers a new consent version, hashing its policy text
+// when given. Existing consent is not changed; customers on older versions
+// are flagged once it takes effect.
+func (s *ConsentService) RegisterVersion(ctx context.Context, version *ConsentVersion, documentText string) (*ConsentVersion, error) {
+	if err := setConsentDocumentHash(version, documentText); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.db.ListConsentVersions(ctx, version.DealershipID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list consent versions: %w", err)
+	}
+	if err := validateNewConsentVersion(version, existing); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.CreateConsentVersion(ctx, version); err != nil {
+		return nil, fmt.Errorf("failed to register consent version: %w", err)
+	}
+
+	s.db.CreateAuditLog(ctx, &AuditLog{
+		DealershipID: version.DealershipID,
+		EntityType:   "consent_version",
+		EntityID:     version.ID,
+		Action:       "create",
+		PerformedBy:  version.CreatedBy,
+		Metadata: map[string]interface{}{
+			"version":       version.Version,
+			"effective_at":  version.EffectiveAt,
+			"document_hash": version.DocumentHash,
+		},
+	})
+
+	s.logger.WithField("dealership_id", version.DealershipID).
+		WithField("version", version.Version).
+		Info("Consent version registered")
+
+	return version, nil
+}
+
+// ListOutdated lists customers whose consent predates the current version.
+// It is empty when no version has been registered.
+func (s *ConsentService) ListOutdated(ctx context.Context, dealershipID string) (*ConsentVersion, []*OutdatedConsent, error) {
+	current, err := s.GetCurrentVersion(ctx, dealershipID)
+	if err != nil || current == nil {
+		return current, nil, err
+	}
+
+	outdated, err := s.db.ListOutdatedConsents(ctx, dealershipID, current.Version)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list outdated consents: %w", err)
+	}
+	return current, outdated, nil
+}
+
+// RequestReconsent records a re-consent request for every customer on an
+// older version. Consent is not changed until the customer accepts the
+// current version.
+func (s *ConsentService) RequestReconsent(ctx context.Context, dealershipID, requestedBy string) (*ReconsentRequestResult, error) {
+	current, err := s.GetCurrentVersion(ctx, dealershipID)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, ErrNoConsentVersion
+	}
+
+	customerIDs, err := s.db.MarkReconsentRequested(ctx, dealershipID, current.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request re-consent: %w", err)
+	}
+	if customerIDs == nil {
+		customerIDs = []string{}
+	}
+
+	s.db.CreateAuditLog(ctx, &AuditLog{
+		DealershipID: dealershipID,
+		EntityType:   "consent",
+		EntityID:     current.ID,
+		Action:       "reconsent_requested",
+		PerformedBy:  requestedBy,
+		Metadata: map[string]interface{}{
+			"version":        current.Version,
+			"customer_count": len(customerIDs),
+		},
+	})
+
+	s.logger.WithField("dealership_id", dealershipID).
+		WithField("version", current.Version).
+		WithField("customer_count", len(customerIDs)).
+		Info("Re-consent requested")
+
+	return &ReconsentRequestResult{
+		DealershipID:   dealershipID,
+		CurrentVersion: current.Version,
+		RequestedCount: len(customerIDs),
+		CustomerIDs:    customerIDs,
+	}, nil
+}
+
 // UpdateConsent updates customer consent preferences
 func (s *ConsentService) UpdateConsent(ctx context.Context, update *ConsentUpdate) (*CustomerConsent, error) {
 	// Get existing consent
@@ -44,70 +344,62 @@ func (s *ConsentService) UpdateConsent(ctx context.Context, update *ConsentUpdat
 			MarketingPhone: false,
 			DataProcessing: true,
 			Analytics:      true,
-			ConsentVersion: "1.0",
+			ConsentVersion: defaultConsentVersion,
 		}
 	}
 
+	if update.Source == "" {
+		update.Source = defaultConsentSource
+	}
+	if !consentSources[update.Source] {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidConsentSource, update.Source)
+	}
+
 	// Track changes for history
-	changes := []struct {
-		Type     string
-		OldValue bool
-		NewValue bool
-	}{}
+	var changes []consentChange
+	flags := []struct {
+		consentType string
+		requested   *bool
+		current     *bool
+	}{
+		{"marketing_email", update.MarketingEmail, &existing.MarketingEmail},
+		{"marketing_sms", update.MarketingSMS, &existing.MarketingSMS},
+		{"marketing_phone", update.MarketingPhone, &existing.MarketingPhone},
+		{"data_processing", update.DataProcessing, &existing.DataProcessing},
+		{"third_party_sharing", update.ThirdPartySharing, &existing.ThirdPartySharing},
+		{"analytics", update.Analytics, &existing.Analytics},
+	}
 
 	// Apply updates
-	if update.MarketingEmail != nil && *update.MarketingEmail != existing.MarketingEmail {
-		changes = append(changes, struct {
-			Type     string
-			OldValue bool
-			NewValue bool
-		}{"marketing_email", existing.MarketingEmail, *update.MarketingEmail})
-		existing.MarketingEmail = *update.MarketingEmail
-	}
-
-	if update.MarketingSMS != nil && *update.MarketingSMS != existing.MarketingSMS {
-		changes = append(changes, struct {
-			Type     string
-			OldValue bool
-			NewValue bool
-		}{"marketing_sms", existing.MarketingSMS, *update.MarketingSMS})
-		existing.MarketingSMS = *update.MarketingSMS
-	}
-
-	if update.MarketingPhone != nil && *update.MarketingPhone != existing.MarketingPhone {
-		changes = append(changes, struct {
-			Type     string
-			OldValue bool
-			NewValue bool
-		}{"marketing_phone", existing.MarketingPhone, *update.MarketingPhone})
-		existing.MarketingPhone = *update.MarketingPhone
-	}
-
-	if update.DataProcessing != nil && *update.DataProcessing != existing.DataProcessing {
-		changes = append(changes, struct {
-			Type     string
-			OldValue bool
-			NewValue bool
-		}{"data_processing", existing.DataProcessing, *update.DataProcessing})
-		existing.DataProcessing = *update.DataProcessing
-	}
-
-	if update.ThirdPartySharing != nil && *update.ThirdPartySharing != existing.ThirdPartySharing {
-		changes = append(changes, struct {
-			Type     string
-			OldValue bool
-			NewValue bool
-		}{"third_party_sharing", existing.ThirdPartySharing, *update.ThirdPartySharing})
-		existing.ThirdPartySharing = *update.ThirdPartySharing
-	}
-
-	if update.Analytics != nil && *update.Analytics != existing.Analytics {
-		changes = append(changes, struct {
-			Type     string
-			OldValue bool
-			NewValue bool
-		}{"analytics", existing.Analytics, *update.Analytics})
-		existing.Analytics = *update.Analytics
+	for _, flag := range flags {
+		if flag.requested != nil && *flag.requested != *flag.current {
+			changes = append(changes, consentChange{flag.consentType, *flag.current, *flag.requested})
+			*flag.current = *flag.requested
+		}
+	}
+
+	// A customer accepting a policy version, or consenting for the first time,
+	// is recorded against the current version
+	versions, err := s.db.ListConsentVersions(ctx, update.DealershipID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list consent versions: %w", err)
+	}
+	if update.ConsentVersion != "" || existing.ID == "" {
+		current := currentConsentVersion(versions, time.Now())
+		if update.ConsentVersion != "" && (current == nil || update.ConsentVersion != current.Version) {
+			return nil, fmt.Errorf("%w: %s", ErrConsentVersionNotCurrent, update.ConsentVersion)
+		}
+		if current != nil {
+			existing.ConsentVersion = current.Version
+		}
+	}
+
+	// The version the consent is held under, which may predate the current one
+	var heldVersion *ConsentVersion
+	for _, v := range versions {
+		if v.Version == existing.ConsentVersion {
+			heldVersion = v
+		}
 	}
 
 	// Update metadata
@@ -120,17 +412,7 @@ func (s *ConsentService) UpdateConsent(ctx context.Context, update *ConsentUpdat
 	}
 
 	// Record history for each change
-	for _, change := range changes {
-		history := &ConsentHistory{
-			CustomerID:   update.CustomerID,
-			DealershipID: update.DealershipID,
-			ConsentType:  change.Type,
-			OldValue:     &change.OldValue,
-			NewValue:     change.NewValue,
-			ChangedBy:    update.UpdatedBy,
-			IPAddress:    update.IPAddress,
-			UserAgent:    update.UserAgent,
-		}
+	for _, history := range consentHistoryRecords(update, existing, heldVersion, changes) {
 		if err := s.db.CreateConsentHistory(ctx, history); err != nil {
 			s.logger.WithError(err).Warn("Failed to record consent history")
 		}
@@ -145,7 +427,9 @@ func (s *ConsentService) UpdateConsent(ctx context.Context, update *ConsentUpdat
 		PerformedBy:  update.UpdatedBy,
 		IPAddress:    update.IPAddress,
 		Metadata: map[string]interface{}{
-			"changes": changes,
+			"changes":         changes,
+			"consent_version": existing.ConsentVersion,
+			"source":          update.Source,
 		},
 	})
 
@@ -166,7 +450,7 @@ func (s *ConsentService) GetConsentHistory(ctx context.Context, customerID, deal
 }
 
 // ProcessMarketingOptOut handles marketing opt-out requests
-func (s *ConsentService) ProcessMarketingOptOut(ctx context.Context, email, customerID, dealershipID, ipAddress string) error {
+func (s *ConsentService) ProcessMarketingOptOut(ctx context.Context, email, customerID, dealershipID string, request *ConsentRequestContext) error {
 	// Find customer by email if not provided
 	if customerID == "" && email != "" {
 		foundID, err := s.db.FindCustomerByEmail(ctx, email, dealershipID)
@@ -201,7 +485,10 @@ func (s *ConsentService) ProcessMarketingOptOut(ctx context.Context, email, cust
 		MarketingSMS:   &falseValue,
 		MarketingPhone: &falseValue,
 		UpdatedBy:      "customer_self_service",
-		IPAddress:      ipAddress,
+		IPAddress:      request.IPAddress,
+		UserAgent:      request.UserAgent,
+		Source:         "unsubscribe",
+		Request:        request,
 	}
 
 	_, err := s.UpdateConsent(ctx, update)
@@ -216,7 +503,7 @@ func (s *ConsentService) ProcessMarketingOptOut(ctx context.Context, email, cust
 		EntityID:     customerID,
 		Action:       "marketing_opt_out",
 		PerformedBy:  "customer_self_service",
-		IPAddress:    ipAddress,
+		IPAddress:    request.IPAddress,
 		Metadata: map[string]interface{}{
 			"email":  email,
 			"source": "self_service",
@@ -259,6 +546,17 @@ func (s *ConsentService) VerifyConsentForOperation(ctx context.Context, customer
 func (s *ConsentService) RecordConsentAtSignup(ctx context.Context, customerID, dealershipID, ipAddress, userAgent string,
 	marketingEmail, marketingSMS, marketingPhone, thirdPartySharing bool) (*CustomerConsent, error) {
 
+	version := defaultConsentVersion
+	var documentHash string
+	current, err := s.GetCurrentVersion(ctx, dealershipID)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil {
+		version = current.Version
+		documentHash = current.DocumentHash
+	}
+
 	consent := &CustomerConsent{
 		CustomerID:        customerID,
 		DealershipID:      dealershipID,
@@ -268,7 +566,7 @@ func (s *ConsentService) RecordConsentAtSignup(ctx context.Context, customerID,
 		DataProcessing:    true, // Required for service
 		ThirdPartySharing: thirdPartySharing,
 		Analytics:         true, // Default on
-		ConsentVersion:    "1.0",
+		ConsentVersion:    version,
 		IPAddress:         ipAddress,
 		UserAgent:         userAgent,
 	}
@@ -290,16 +588,20 @@ func (s *ConsentService) RecordConsentAtSignup(ctx context.Context, customerID,
 		{"analytics", true},
 	}
 
+	snapshot := consentSnapshot(consent)
 	for _, ct := range consentTypes {
 		history := &ConsentHistory{
-			CustomerID:   customerID,
-			DealershipID: dealershipID,
-			ConsentType:  ct.Type,
-			OldValue:     nil, // No previous value at signup
-			NewValue:     ct.Value,
-			ChangedBy:    "signup",
-			IPAddress:    ipAddress,
-			UserAgent:    userAgent,
+			CustomerID:     customerID,
+			DealershipID:   dealershipID,
+			ConsentType:    ct.Type,
+			OldValue:       nil, // No previous value at signup
+			NewValue:       ct.Value,
+			ChangedBy:      "signup",
+			IPAddress:      ipAddress,
+			UserAgent:      userAgent,
+			ConsentVersion: version,
+			DocumentHash:   documentHash,
+			Snapshot:       snapshot,
 		}
 		s.db.CreateConsentHistory(ctx, history)
 	}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBumpingConsentVersionFlagsOlderConsents(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	versions := []*ConsentVersion{
+		{Version: "1.0", EffectiveAt: now.AddDate(-1, 0, 0)},
+	}
+
+	consents := []*CustomerConsent{
+		{ID: "c1", ConsentVersion: "1.0"},
+		{ID: "c2", ConsentVersion: "1.0"},
+	}
+	for _, c := range consents {
+		flagReconsent(c, currentConsentVersion(versions, now))
+		if c.NeedsReconsent {
+			t.Fatalf("Expected consent on the current version not to be flagged")
+		}
+	}
+
+	bump := &ConsentVersion{Version: "2.0", EffectiveAt: now.Add(-time.Hour)}
+	if err := validateNewConsentVersion(bump, versions); err != nil {
+		t.Fatal(err)
+	}
+	versions = append(versions, bump)
+
+	// One customer re-consents under the new version
+	consents[1].ConsentVersion = "2.0"
+
+	current := currentConsentVersion(versions, now)
+	for _, c := range consents {
+		flagReconsent(c, current)
+	}
+
+	if !consents[0].NeedsReconsent {
+		t.Error("Expected consent on version 1.0 to need re-consent")
+	}
+	if consents[0].ConsentVersion != "1.0" {
+		t.Errorf("Expected flagged consent to keep its version, got %s", consents[0].ConsentVersion)
+	}
+	if consents[1].NeedsReconsent {
+		t.Error("Expected consent on version 2.0 not to need re-consent")
+	}
+	if consents[0].CurrentConsentVersion != "2.0" {
+		t.Errorf("Expected current version 2.0, got %s", consents[0].CurrentConsentVersion)
+	}
+}
+
+func TestCurrentConsentVersion_FutureEffectiveDate(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	versions := []*ConsentVersion{
+		{Version: "1.0", EffectiveAt: now.AddDate(0, -1, 0)},
+		{Version: "2.0", EffectiveAt: now.AddDate(0, 0, 7)},
+	}
+
+	if current := currentConsentVersion(versions, now); current == nil || current.Version != "1.0" {
+		t.Errorf("Expected 1.0 before 2.0 takes effect, got %+v", current)
+	}
+	if current := currentConsentVersion(versions, now.AddDate(0, 0, 8)); current == nil || current.Version != "2.0" {
+		t.Errorf("Expected 2.0 once effective, got %+v", current)
+	}
+
+	consent := &CustomerConsent{ID: "c1", ConsentVersion: "1.0"}
+	flagReconsent(consent, currentConsentVersion(versions, now))
+	if consent.NeedsReconsent {
+		t.Error("Expected no re-consent before the new version takes effect")
+	}
+
+	if current := currentConsentVersion(nil, now); current != nil {
+		t.Errorf("Expected no current version, got %+v", current)
+	}
+}
+
+func TestValidateNewConsentVersion(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	existing := []*ConsentVersion{{Version: "2.0", EffectiveAt: now}}
+
+	tests := []struct {
+		name    string
+		version *ConsentVersion
+		wantErr error
+	}{
+		{"valid", &ConsentVersion{Version: "3.0", EffectiveAt: now.AddDate(0, 1, 0)}, nil},
+		{"duplicate", &ConsentVersion{Version: "2.0", EffectiveAt: now.AddDate(0, 1, 0)}, ErrConsentVersionExists},
+		{"effective before existing", &ConsentVersion{Version: "3.0", EffectiveAt: now.AddDate(0, -1, 0)}, ErrInvalidConsentVersion},
+		{"empty", &ConsentVersion{EffectiveAt: now.AddDate(0, 1, 0)}, ErrInvalidConsentVersion},
+		{"too long", &ConsentVersion{Version: "2024-06-01-privacy-policy", EffectiveAt: now.AddDate(0, 1, 0)}, ErrInvalidConsentVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateNewConsentVersion(tt.version, existing)
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConsentHistoryRecordsFullSnapshot(t *testing.T) {
+	optIn := true
+	update := &ConsentUpdate{
+		CustomerID:   "cust-1",
+		DealershipID: "dealer-1",
+		MarketingSMS: &optIn,
+		UpdatedBy:    "user-1",
+		IPAddress:    "203.0.113.7",
+		UserAgent:    "Mozilla/5.0",
+		Source:       "web_form",
+		Request: &ConsentRequestContext{
+			RequestID: "req-1",
+			IPAddress: "203.0.113.7",
+			UserAgent: "Mozilla/5.0",
+		},
+	}
+	consent := &CustomerConsent{
+		MarketingEmail: true,
+		MarketingSMS:   true,
+		DataProcessing: true,
+		Analytics:      false,
+		ConsentVersion: "2.0",
+	}
+	version := &ConsentVersion{Version: "2.0"}
+	if err := setConsentDocumentHash(version, "Privacy policy v2"); err != nil {
+		t.Fatal(err)
+	}
+
+	records := consentHistoryRecords(update, consent, version, []consentChange{
+		{Type: "marketing_sms", OldValue: false, NewValue: true},
+	})
+	if len(records) != 1 {
+		t.Fatalf("Expected one history record, got %d", len(records))
+	}
+
+	h := records[0]
+	want := ConsentSnapshot{
+		MarketingEmail: true,
+		MarketingSMS:   true,
+		DataProcessing: true,
+		ConsentVersion: "2.0",
+	}
+	if h.Snapshot == nil || *h.Snapshot != want {
+		t.Errorf("Expected snapshot of every flag %+v, got %+v", want, h.Snapshot)
+	}
+	if h.ConsentVersion != "2.0" || h.DocumentHash != version.DocumentHash || h.DocumentHash == "" {
+		t.Errorf("Expected version 2.0 with its document hash, got %q %q", h.ConsentVersion, h.DocumentHash)
+	}
+	if h.Source != "web_form" || h.UserAgent != "Mozilla/5.0" {
+		t.Errorf("Expected source and user agent to be recorded, got %q %q", h.Source, h.UserAgent)
+	}
+	if h.OldValue == nil || *h.OldValue || !h.NewValue {
+		t.Errorf("Expected the change false -> true, got %v -> %v", h.OldValue, h.NewValue)
+	}
+	if h.RequestFingerprint != requestFingerprint(update.Request) || len(h.RequestFingerprint) != 64 {
+		t.Errorf("Expected the request fingerprint, got %q", h.RequestFingerprint)
+	}
+
+	// The snapshot is a copy: later changes to the consent do not alter it
+	consent.Analytics = true
+	if h.Snapshot.Analytics {
+		t.Error("Expected the snapshot not to follow later consent changes")
+	}
+
+	// A customer still on an older version is not credited with the current
+	// version's document
+	consent.ConsentVersion = "1.0"
+	records = consentHistoryRecords(update, consent, version, []consentChange{{Type: "analytics", NewValue: true}})
+	if records[0].ConsentVersion != "1.0" || records[0].DocumentHash != "" {
+		t.Errorf("Expected version 1.0 without the 2.0 document hash, got %q %q", records[0].ConsentVersion, records[0].DocumentHash)
+	}
+}
+
+func TestSetConsentDocumentHash(t *testing.T) {
+	version := &ConsentVersion{Version: "2.0"}
+	if err := setConsentDocumentHash(version, "Privacy policy v2"); err != nil {
+		t.Fatal(err)
+	}
+	if len(version.DocumentHash) != 64 {
+		t.Fatalf("Expected a SHA-256 hex digest, got %q", version.DocumentHash)
+	}
+	hash := version.DocumentHash
+
+	matching := &ConsentVersion{Version: "2.0", DocumentHash: hash}
+	if err := setConsentDocumentHash(matching, "Privacy policy v2"); err != nil {
+		t.Errorf("Expected a matching hash to be accepted, got %v", err)
+	}
+
+	mismatched := &ConsentVersion{Version: "2.0", DocumentHash: hash}
+	if err := setConsentDocumentHash(mismatched, "Privacy policy v3"); !errors.Is(err, ErrInvalidConsentVersion) {
+		t.Errorf("Expected a mismatched hash to be rejected, got %v", err)
+	}
+
+	if err := setConsentDocumentHash(&ConsentVersion{DocumentHash: hash}, ""); err != nil {
+		t.Errorf("Expected a bare hash to be accepted, got %v", err)
+	}
+	if err := setConsentDocumentHash(&ConsentVersion{DocumentHash: "not-a-hash"}, ""); !errors.Is(err, ErrInvalidConsentVersion) {
+		t.Errorf("Expected a malformed hash to be rejected, got %v", err)
+	}
+}
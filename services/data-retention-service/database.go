@@ -81,11 +81,31 @@ func (db *Database) InitSchema() error {
 		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
 	);
 
+	ALTER TABLE gdpr_requests ADD COLUMN IF NOT EXISTS retain_for_legal BOOLEAN NOT NULL DEFAULT false;
+	ALTER TABLE gdpr_requests ADD COLUMN IF NOT EXISTS approved_by VARCHAR(100);
+	ALTER TABLE gdpr_requests ADD COLUMN IF NOT EXISTS approved_at TIMESTAMP;
+	ALTER TABLE gdpr_requests ADD COLUMN IF NOT EXISTS source VARCHAR(50);
+
 	CREATE INDEX IF NOT EXISTS idx_gdpr_requests_customer ON gdpr_requests(customer_id);
 	CREATE INDEX IF NOT EXISTS idx_gdpr_requests_dealership ON gdpr_requests(dealership_id);
 	CREATE INDEX IF NOT EXISTS idx_gdpr_requests_status ON gdpr_requests(status);
 	CREATE INDEX IF NOT EXISTS idx_gdpr_requests_type ON gdpr_requests(request_type);
 
+	-- Pending email verifications of customer self-service GDPR requests.
+	-- Only a hash of the emailed token is stored.
+	CREATE TABLE IF NOT EXISTS gdpr_verifications (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		token_hash VARCHAR(64) NOT NULL UNIQUE,
+		customer_id UUID NOT NULL,
+		dealership_id UUID NOT NULL,
+		request_type VARCHAR(50) NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		verified_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_gdpr_verifications_expires ON gdpr_verifications(expires_at);
+
 	-- Customer Consent
 	CREATE TABLE IF NOT EXISTS customer_consent (
 		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -105,9 +125,27 @@ func (db *Database) InitSchema() error {
 		UNIQUE(customer_id, dealership_id)
 	);
 
+	ALTER TABLE customer_consent ADD COLUMN IF NOT EXISTS reconsent_requested_at TIMESTAMP;
+
 	CREATE INDEX IF NOT EXISTS idx_customer_consent_customer ON customer_consent(customer_id);
 	CREATE INDEX IF NOT EXISTS idx_customer_consent_dealership ON customer_consent(dealership_id);
 
+	-- Consent Versions (privacy policy revisions)
+	CREATE TABLE IF NOT EXISTS consent_versions (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		dealership_id UUID NOT NULL,
+		version VARCHAR(20) NOT NULL,
+		effective_at TIMESTAMP NOT NULL,
+		description TEXT,
+		created_by VARCHAR(100),
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		UNIQUE(dealership_id, version)
+	);
+
+	ALTER TABLE consent_versions ADD COLUMN IF NOT EXISTS document_hash VARCHAR(64);
+
+	CREATE INDEX IF NOT EXISTS idx_consent_versions_dealership ON consent_versions(dealership_id, effective_at);
+
 	-- Consent History (Audit Trail)
 	CREATE TABLE IF NOT EXISTS consent_history (
 		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -122,6 +160,13 @@ func (db *Database) InitSchema() error {
 		created_at TIMESTAMP NOT NULL DEFAULT NOW()
 	);
 
+	ALTER TABLE consent_history ADD COLUMN IF NOT EXISTS consent_version VARCHAR(20);
+	ALTER TABLE consent_history ADD COLUMN IF NOT EXISTS document_hash VARCHAR(64);
+	ALTER TABLE consent_history ADD COLUMN IF NOT EXISTS source VARCHAR(50);
+	ALTER TABLE consent_history ADD COLUMN IF NOT EXISTS request_context JSONB;
+	ALTER TABLE consent_history ADD COLUMN IF NOT EXISTS request_fingerprint VARCHAR(64);
+	ALTER TABLE consent_history ADD COLUMN IF NOT EXISTS consent_snapshot JSONB;
+
 	CREATE INDEX IF NOT EXISTS idx_consent_history_customer ON consent_history(customer_id);
 	CREATE INDEX IF NOT EXISTS idx_consent_history_dealership ON consent_history(dealership_id);
 
@@ -145,6 +190,23 @@ func (db *Database) InitSchema() error {
 	CREATE INDEX IF NOT EXISTS idx_data_audit_log_action ON data_audit_log(action);
 	CREATE INDEX IF NOT EXISTS idx_data_audit_log_created ON data_audit_log(created_at);
 
+	-- Records archived to cold storage by retention policies
+	CREATE TABLE IF NOT EXISTS retention_archives (
+		id UUID PRIMARY KEY,
+		policy_id UUID REFERENCES retention_policies(id) ON DELETE SET NULL,
+		dealership_id UUID NOT NULL,
+		entity_type VARCHAR(50) NOT NULL,
+		entity_id UUID NOT NULL,
+		region VARCHAR(10) NOT NULL,
+		bucket VARCHAR(255) NOT NULL,
+		object_key TEXT NOT NULL,
+		record_counts JSONB,
+		archived_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_retention_archives_entity ON retention_archives(entity_type, entity_id);
+	CREATE INDEX IF NOT EXISTS idx_retention_archives_dealership ON retention_archives(dealership_id);
+
 	-- Add GDPR columns to customers table if not exists
 	DO $$
 	BEGIN
@@ -173,6 +235,9 @@ func (db *Database) InitSchema() error {
 		END IF;
 	END $$;
 
+	-- Data residency region overriding the dealership's data_region setting
+	ALTER TABLE customers ADD COLUMN IF NOT EXISTS region VARCHAR(10);
+
 	-- Add GDPR columns to deals table if not exists
 	DO $$
 	BEGIN
@@ -324,12 +389,13 @@ func (db *Database) CreateGDPRRequest(ctx context.Context, req *GDPRRequest) err
 	req.UpdatedAt = time.Now()
 
 	query := `
-		INSERT INTO gdpr_requests (id, customer_id, dealership_id, request_type, status, requested_by, reason, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO gdpr_requests (id, customer_id, dealership_id, request_type, status, requested_by, reason, retain_for_legal, source, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11)
 	`
 	_, err := db.conn.ExecContext(ctx, query,
 		req.ID, req.CustomerID, req.DealershipID, req.RequestType,
-		req.Status, req.RequestedBy, req.Reason, req.CreatedAt, req.UpdatedAt)
+		req.Status, req.RequestedBy, req.Reason, req.RetainForLegal,
+		req.Source, req.CreatedAt, req.UpdatedAt)
 
 	return err
 }
@@ -337,19 +403,21 @@ func (db *Database) CreateGDPRRequest(ctx context.Context, req *GDPRRequest) err
 // GetGDPRRequest retrieves a GDPR request by ID
 func (db *Database) GetGDPRRequest(ctx context.Context, id string) (*GDPRRequest, error) {
 	query := `
-		SELECT id, customer_id, dealership_id, request_type, status, requested_by, reason, notes, processed_at, created_at, updated_at
+		SELECT id, customer_id, dealership_id, request_type, status, requested_by, reason, notes, processed_at, created_at, updated_at,
+		       retain_for_legal, approved_by, approved_at, source
 		FROM gdpr_requests
 		WHERE id = $1
 	`
 
 	var req GDPRRequest
-	var processedAt sql.NullTime
-	var notes sql.NullString
+	var processedAt, approvedAt sql.NullTime
+	var notes, approvedBy, source sql.NullString
 
 	err := db.conn.QueryRowContext(ctx, query, id).Scan(
 		&req.ID, &req.CustomerID, &req.DealershipID, &req.RequestType,
 		&req.Status, &req.RequestedBy, &req.Reason, &notes, &processedAt,
-		&req.CreatedAt, &req.UpdatedAt)
+		&req.CreatedAt, &req.UpdatedAt,
+		&req.RetainForLegal, &approvedBy, &approvedAt, &source)
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("request not found")
@@ -364,6 +432,15 @@ func (db *Database) GetGDPRRequest(ctx context.Context, id string) (*GDPRRequest
 	if notes.Valid {
 		req.Notes = notes.String
 	}
+	if approvedBy.Valid {
+		req.ApprovedBy = approvedBy.String
+	}
+	if approvedAt.Valid {
+		req.ApprovedAt = &approvedAt.Time
+	}
+	if source.Valid {
+		req.Source = source.String
+	}
 
 	return &req, nil
 }
@@ -371,7 +448,8 @@ func (db *Database) GetGDPRRequest(ctx context.Context, id string) (*GDPRRequest
 // ListGDPRRequests lists GDPR requests with optional filters
 func (db *Database) ListGDPRRequests(ctx context.Context, dealershipID, requestType, status string) ([]*GDPRRequest, error) {
 	query := `
-		SELECT id, customer_id, dealership_id, request_type, status, requested_by, reason, notes, processed_at, created_at, updated_at
+		SELECT id, customer_id, dealership_id, request_type, status, requested_by, reason, notes, processed_at, created_at, updated_at,
+		       retain_for_legal, approved_by, approved_at, source
 		FROM gdpr_requests
 		WHERE 1=1
 	`
@@ -404,13 +482,14 @@ func (db *Database) ListGDPRRequests(ctx context.Context, dealershipID, requestT
 	var requests []*GDPRRequest
 	for rows.Next() {
 		var req GDPRRequest
-		var processedAt sql.NullTime
-		var notes sql.NullString
+		var processedAt, approvedAt sql.NullTime
+		var notes, approvedBy, source sql.NullString
 
 		err := rows.Scan(
 			&req.ID, &req.CustomerID, &req.DealershipID, &req.RequestType,
 			&req.Status, &req.RequestedBy, &req.Reason, &notes, &processedAt,
-			&req.CreatedAt, &req.UpdatedAt)
+			&req.CreatedAt, &req.UpdatedAt,
+			&req.RetainForLegal, &approvedBy, &approvedAt, &source)
 		if err != nil {
 			return nil, err
 		}
@@ -421,6 +500,15 @@ func (db *Database) ListGDPRRequests(ctx context.Context, dealershipID, requestT
 		if notes.Valid {
 			req.Notes = notes.String
 		}
+		if approvedBy.Valid {
+			req.ApprovedBy = approvedBy.String
+		}
+		if approvedAt.Valid {
+			req.ApprovedAt = &approvedAt.Time
+		}
+		if source.Valid {
+			req.Source = source.String
+		}
 
 		requests = append(requests, &req)
 	}
@@ -456,23 +544,102 @@ func (db *Database) UpdateGDPRRequestStatus(ctx context.Context, id, status, not
 	return nil
 }
 
+// ApproveGDPRRequest records the approver of a request awaiting approval and
+// moves it to processing. It reports false if the request was not awaiting
+// approval, so concurrent approvals cannot both proceed.
+func (db *Database) ApproveGDPRRequest(ctx context.Context, id, approvedBy string) (bool, error) {
+	query := `
+		UPDATE gdpr_requests
+		SET status = 'processing', approved_by = $2, approved_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND status = 'awaiting_approval'
+	`
+
+	result, err := db.conn.ExecContext(ctx, query, id, approvedBy)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// CreateGDPRVerification stores a pending self-service request verification
+func (db *Database) CreateGDPRVerification(ctx context.Context, v *GDPRVerification) error {
+	v.ID = uuid.New().String()
+	v.CreatedAt = time.Now()
+
+	query := `
+		INSERT INTO gdpr_verifications (id, token_hash, customer_id, dealership_id, request_type, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := db.conn.ExecContext(ctx, query,
+		v.ID, v.TokenHash, v.CustomerID, v.DealershipID, v.RequestType, v.ExpiresAt, v.CreatedAt)
+	return err
+}
+
+// ClaimGDPRVerification marks the unexpired, unverified verification with
+// the token hash as verified and returns it. It returns nil if there is
+// none, so a link can only be verified once.
+func (db *Database) ClaimGDPRVerification(ctx context.Context, tokenHash string) (*GDPRVerification, error) {
+	query := `
+		UPDATE gdpr_verifications
+		SET verified_at = NOW()
+		WHERE token_hash = $1 AND verified_at IS NULL AND expires_at > NOW()
+		RETURNING id, token_hash, customer_id, dealership_id, request_type, expires_at, created_at
+	`
+
+	var v GDPRVerification
+	err := db.conn.QueryRowContext(ctx, query, tokenHash).Scan(
+		&v.ID, &v.TokenHash, &v.CustomerID, &v.DealershipID, &v.RequestType, &v.ExpiresAt, &v.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// ReleaseGDPRVerification clears a claimed verification so its link can be
+// used again
+func (db *Database) ReleaseGDPRVerification(ctx context.Context, id string) error {
+	_, err := db.conn.ExecContext(ctx, `UPDATE gdpr_verifications SET verified_at = NULL WHERE id = $1`, id)
+	return err
+}
+
+// DeleteExpiredGDPRVerifications removes verifications whose links have
+// expired
+func (db *Database) DeleteExpiredGDPRVerifications(ctx context.Context) (int64, error) {
+	result, err := db.conn.ExecContext(ctx, `DELETE FROM gdpr_verifications WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 // Consent Operations
 
 // GetConsent retrieves consent for a customer
 func (db *Database) GetConsent(ctx context.Context, customerID, dealershipID string) (*CustomerConsent, error) {
 	query := `
 		SELECT id, customer_id, dealership_id, marketing_email, marketing_sms, marketing_phone,
-		       data_processing, third_party_sharing, analytics, consent_version, created_at, updated_at
+		       data_processing, third_party_sharing, analytics, consent_version, created_at, updated_at,
+		       reconsent_requested_at
 		FROM customer_consent
 		WHERE customer_id = $1 AND dealership_id = $2
 	`
 
 	var consent CustomerConsent
+	var reconsentRequestedAt sql.NullTime
 	err := db.conn.QueryRowContext(ctx, query, customerID, dealershipID).Scan(
 		&consent.ID, &consent.CustomerID, &consent.DealershipID,
 		&consent.MarketingEmail, &consent.MarketingSMS, &consent.MarketingPhone,
 		&consent.DataProcessing, &consent.ThirdPartySharing, &consent.Analytics,
-		&consent.ConsentVersion, &consent.CreatedAt, &consent.UpdatedAt)
+		&consent.ConsentVersion, &consent.CreatedAt, &consent.UpdatedAt,
+		&reconsentRequestedAt)
 
 	if err == sql.ErrNoRows {
 		// Return default consent (all marketing off, processing on)
@@ -492,6 +659,10 @@ func (db *Database) GetConsent(ctx context.Context, customerID, dealershipID str
 		return nil, err
 	}
 
+	if reconsentRequestedAt.Valid {
+		consent.ReconsentRequestedAt = &reconsentRequestedAt.Time
+	}
+
 	return &consent, nil
 }
 
@@ -511,6 +682,10 @@ func (db *Database) UpsertConsent(ctx context.Context, consent *CustomerConsent)
 			third_party_sharing = EXCLUDED.third_party_sharing,
 			analytics = EXCLUDED.analytics,
 			consent_version = EXCLUDED.consent_version,
+			reconsent_requested_at = CASE
+				WHEN customer_consent.consent_version = EXCLUDED.consent_version THEN customer_consent.reconsent_requested_at
+				ELSE NULL
+			END,
 			ip_address = EXCLUDED.ip_address,
 			user_agent = EXCLUDED.user_agent,
 			updated_at = NOW()
@@ -532,17 +707,23 @@ func (db *Database) UpsertConsent(ctx context.Context, consent *CustomerConsent)
 	return err
 }
 
-// CreateConsentHistory records a consent change
+// CreateConsentHistory records a consent change along with its proof
 func (db *Database) CreateConsentHistory(ctx context.Context, history *ConsentHistory) error {
 	query := `
-		INSERT INTO consent_history (id, customer_id, dealership_id, consent_type, old_value, new_value, changed_by, ip_address, user_agent, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		INSERT INTO consent_history (id, customer_id, dealership_id, consent_type, old_value, new_value, changed_by, ip_address, user_agent,
+			consent_version, document_hash, source, request_context, request_fingerprint, consent_snapshot, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 	`
 
+	requestJSON, _ := json.Marshal(history.Request)
+	snapshotJSON, _ := json.Marshal(history.Snapshot)
+
 	_, err := db.conn.ExecContext(ctx, query,
 		uuid.New().String(), history.CustomerID, history.DealershipID,
 		history.ConsentType, history.OldValue, history.NewValue,
-		history.ChangedBy, history.IPAddress, history.UserAgent, time.Now())
+		history.ChangedBy, history.IPAddress, history.UserAgent,
+		history.ConsentVersion, history.DocumentHash, history.Source,
+		requestJSON, history.RequestFingerprint, snapshotJSON, time.Now())
 
 	return err
 }
@@ -550,7 +731,9 @@ func (db *Database) CreateConsentHistory(ctx context.Context, history *ConsentHi
 // GetConsentHistory retrieves consent history for a customer
 func (db *Database) GetConsentHistory(ctx context.Context, customerID, dealershipID string) ([]*ConsentHistory, error) {
 	query := `
-		SELECT id, customer_id, dealership_id, consent_type, old_value, new_value, changed_by, ip_address, created_at
+		SELECT id, customer_id, dealership_id, consent_type, old_value, new_value, changed_by, ip_address, user_agent,
+			COALESCE(consent_version, ''), COALESCE(document_hash, ''), COALESCE(source, ''),
+			request_context, COALESCE(request_fingerprint, ''), consent_snapshot, created_at
 		FROM consent_history
 		WHERE customer_id = $1 AND dealership_id = $2
 		ORDER BY created_at DESC
@@ -567,10 +750,13 @@ func (db *Database) GetConsentHistory(ctx context.Context, customerID, dealershi
 	for rows.Next() {
 		var h ConsentHistory
 		var oldValue sql.NullBool
-		var ipAddress sql.NullString
+		var ipAddress, userAgent sql.NullString
+		var requestJSON, snapshotJSON []byte
 
 		err := rows.Scan(&h.ID, &h.CustomerID, &h.DealershipID, &h.ConsentType,
-			&oldValue, &h.NewValue, &h.ChangedBy, &ipAddress, &h.CreatedAt)
+			&oldValue, &h.NewValue, &h.ChangedBy, &ipAddress, &userAgent,
+			&h.ConsentVersion, &h.DocumentHash, &h.Source,
+			&requestJSON, &h.RequestFingerprint, &snapshotJSON, &h.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -581,6 +767,15 @@ func (db *Database) GetConsentHistory(ctx context.Context, customerID, dealershi
 		if ipAddress.Valid {
 			h.IPAddress = ipAddress.String
 		}
+		if userAgent.Valid {
+			h.UserAgent = userAgent.String
+		}
+		if len(requestJSON) > 0 {
+			json.Unmarshal(requestJSON, &h.Request)
+		}
+		if len(snapshotJSON) > 0 {
+			json.Unmarshal(snapshotJSON, &h.Snapshot)
+		}
 
 		history = append(history, &h)
 	}
@@ -588,6 +783,115 @@ func (db *Database) GetConsentHistory(ctx context.Context, customerID, dealershi
 	return history, nil
 }
 
+// CreateConsentVersion registers a new consent version
+func (db *Database) CreateConsentVersion(ctx context.Context, version *ConsentVersion) error {
+	query := `
+		INSERT INTO consent_versions (id, dealership_id, version, effective_at, description, created_by, document_hash, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8)
+	`
+
+	if version.ID == "" {
+		version.ID = uuid.New().String()
+	}
+	version.CreatedAt = time.Now()
+
+	_, err := db.conn.ExecContext(ctx, query,
+		version.ID, version.DealershipID, version.Version, version.EffectiveAt,
+		version.Description, version.CreatedBy, version.DocumentHash, version.CreatedAt)
+
+	return err
+}
+
+// ListConsentVersions lists a dealership's consent versions, oldest first
+func (db *Database) ListConsentVersions(ctx context.Context, dealershipID string) ([]*ConsentVersion, error) {
+	query := `
+		SELECT id, dealership_id, version, effective_at, COALESCE(description, ''), COALESCE(created_by, ''),
+			COALESCE(document_hash, ''), created_at
+		FROM consent_versions
+		WHERE dealership_id = $1
+		ORDER BY effective_at ASC, created_at ASC
+	`
+
+	rows, err := db.conn.QueryContext(ctx, query, dealershipID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []*ConsentVersion
+	for rows.Next() {
+		var v ConsentVersion
+		if err := rows.Scan(&v.ID, &v.DealershipID, &v.Version, &v.EffectiveAt,
+			&v.Description, &v.CreatedBy, &v.DocumentHash, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		versions = append(versions, &v)
+	}
+
+	return versions, rows.Err()
+}
+
+// ListOutdatedConsents lists customers whose consent is on a version other
+// than currentVersion
+func (db *Database) ListOutdatedConsents(ctx context.Context, dealershipID, currentVersion string) ([]*OutdatedConsent, error) {
+	query := `
+		SELECT customer_id, consent_version, updated_at, reconsent_requested_at
+		FROM customer_consent
+		WHERE dealership_id = $1 AND consent_version <> $2
+		ORDER BY updated_at ASC
+	`
+
+	rows, err := db.conn.QueryContext(ctx, query, dealershipID, currentVersion)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var outdated []*OutdatedConsent
+	for rows.Next() {
+		var o OutdatedConsent
+		var requestedAt sql.NullTime
+		if err := rows.Scan(&o.CustomerID, &o.ConsentVersion, &o.ConsentedAt, &requestedAt); err != nil {
+			return nil, err
+		}
+		if requestedAt.Valid {
+			o.ReconsentRequestedAt = &requestedAt.Time
+		}
+		outdated = append(outdated, &o)
+	}
+
+	return outdated, rows.Err()
+}
+
+// MarkReconsentRequested records that re-consent was requested from every
+// customer not on currentVersion and returns their IDs. Consent values are
+// left unchanged.
+func (db *Database) MarkReconsentRequested(ctx context.Context, dealershipID, currentVersion string) ([]string, error) {
+	query := `
+		UPDATE customer_consent
+		SET reconsent_requested_at = NOW()
+		WHERE dealership_id = $1 AND consent_version <> $2
+		RETURNING customer_id
+	`
+
+	rows, err := db.conn.QueryContext(ctx, query, dealershipID, currentVersion)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var customerIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		customerIDs = append(customerIDs, id)
+	}
+
+	return customerIDs, rows.Err()
+}
+
 // Retention Policy Operations
 
 // ListRetentionPolicies lists all retention policies
@@ -750,8 +1054,12 @@ func (db *Database) DeleteRetentionPolicy(ctx context.Context, id string) error
 
 // Audit Log Operations
 
-// CreateAuditLog creates an audit log entry
+// CreateAuditLog creates an audit log entry, setting its ID and creation
+// time
 func (db *Database) CreateAuditLog(ctx context.Context, log *AuditLog) error {
+	log.ID = uuid.New().String()
+	log.CreatedAt = time.Now()
+
 	oldDataJSON, _ := json.Marshal(log.OldData)
 	newDataJSON, _ := json.Marshal(log.NewData)
 	metadataJSON, _ := json.Marshal(log.Metadata)
@@ -762,9 +1070,9 @@ func (db *Database) CreateAuditLog(ctx context.Context, log *AuditLog) error {
 	`
 
 	_, err := db.conn.ExecContext(ctx, query,
-		uuid.New().String(), log.DealershipID, log.EntityType, log.EntityID,
+		log.ID, log.DealershipID, log.EntityType, log.EntityID,
 		log.Action, log.PerformedBy, log.IPAddress,
-		oldDataJSON, newDataJSON, metadataJSON, time.Now())
+		oldDataJSON, newDataJSON, metadataJSON, log.CreatedAt)
 
 	return err
 }
@@ -901,7 +1209,7 @@ func (db *Database) GetCustomerWithRelatedData(ctx context.Context, customerID,
 	// Get customer data
 	customerQuery := `
 		SELECT id, dealership_id, first_name, last_name, email, phone, address, city, state, zip_code,
-		       credit_score, notes, source, created_at, updated_at, last_activity_at
+		       credit_score, notes, source, created_at, updated_at, last_activity_at, COALESCE(region, '')
 		FROM customers
 		WHERE id = $1 AND dealership_id = $2 AND deleted_at IS NULL
 	`
@@ -911,7 +1219,7 @@ func (db *Database) GetCustomerWithRelatedData(ctx context.Context, customerID,
 		&customer.ID, &customer.DealershipID, &customer.FirstName, &customer.LastName,
 		&customer.Email, &customer.Phone, &customer.Address, &customer.City,
 		&customer.State, &customer.ZipCode, &customer.CreditScore, &customer.Notes,
-		&customer.Source, &customer.CreatedAt, &customer.UpdatedAt, &lastActivity)
+		&customer.Source, &customer.CreatedAt, &customer.UpdatedAt, &lastActivity, &customer.Region)
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("customer not found")
@@ -923,14 +1231,34 @@ func (db *Database) GetCustomerWithRelatedData(ctx context.Context, customerID,
 	if lastActivity.Valid {
 		customer.LastActivityAt = &lastActivity.Time
 	}
+
+	// Get tags
+	tagRows, err := db.conn.QueryContext(ctx, `
+		SELECT tag FROM customer_tags
+		WHERE customer_id = $1 AND dealership_id = $2
+		ORDER BY LOWER(tag)
+	`, customerID, dealershipID)
+	if err != nil {
+		return nil, err
+	}
+	defer tagRows.Close()
+
+	for tagRows.Next() {
+		var tag string
+		if err := tagRows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		customer.Tags = append(customer.Tags, tag)
+	}
 	export.Customer = customer
 
 	// Get deals
 	dealsQuery := `
 		SELECT id, vehicle_id, type, status, sale_price, trade_in_value, down_payment,
-		       financing_term, interest_rate, monthly_payment, taxes, fees, total_price, created_at
+		       financing_term, interest_rate, monthly_payment, taxes, fees, total_price,
+		       COALESCE(co_customer_id = $1, false), created_at
 		FROM deals
-		WHERE customer_id = $1 AND dealership_id = $2 AND deleted_at IS NULL
+		WHERE (customer_id = $1 OR co_customer_id = $1) AND dealership_id = $2 AND deleted_at IS NULL
 	`
 	rows, err := db.conn.QueryContext(ctx, dealsQuery, customerID, dealershipID)
 	if err != nil {
@@ -943,7 +1271,7 @@ func (db *Database) GetCustomerWithRelatedData(ctx context.Context, customerID,
 		err := rows.Scan(&deal.ID, &deal.VehicleID, &deal.Type, &deal.Status,
 			&deal.SalePrice, &deal.TradeInValue, &deal.DownPayment,
 			&deal.FinancingTerm, &deal.InterestRate, &deal.MonthlyPayment,
-			&deal.Taxes, &deal.Fees, &deal.TotalPrice, &deal.CreatedAt)
+			&deal.Taxes, &deal.Fees, &deal.TotalPrice, &deal.CoBuyer, &deal.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -1018,6 +1346,67 @@ func (db *Database) GetCustomerWithRelatedData(ctx context.Context, customerID,
 		export.EmailLogs = append(export.EmailLogs, email)
 	}
 
+	// Get deal document references
+	documentsQuery := `
+		SELECT dd.id, dd.deal_id, dd.document_type, dd.filename, dd.content_type,
+		       dd.size_bytes, dd.uploaded_by, dd.created_at
+		FROM deal_documents dd
+		JOIN deals d ON d.id = dd.deal_id
+		WHERE d.customer_id = $1 AND d.dealership_id = $2
+		ORDER BY dd.created_at
+	`
+	documentRows, err := db.conn.QueryContext(ctx, documentsQuery, customerID, dealershipID)
+	if err != nil {
+		return nil, err
+	}
+	defer documentRows.Close()
+
+	for documentRows.Next() {
+		var doc DealDocumentData
+		var uploadedBy sql.NullString
+
+		err := documentRows.Scan(&doc.ID, &doc.DealID, &doc.DocumentType, &doc.Filename,
+			&doc.ContentType, &doc.Size, &uploadedBy, &doc.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if uploadedBy.Valid {
+			doc.UploadedBy = uploadedBy.String
+		}
+
+		export.DealDocuments = append(export.DealDocuments, doc)
+	}
+
+	// Get deal notes
+	notesQuery := `
+		SELECT n.id, n.deal_id, n.body, n.created_by, n.created_at
+		FROM deal_notes n
+		JOIN deals d ON d.id = n.deal_id
+		WHERE (d.customer_id = $1 OR d.co_customer_id = $1) AND d.dealership_id = $2 AND d.deleted_at IS NULL
+		ORDER BY n.created_at
+	`
+	noteRows, err := db.conn.QueryContext(ctx, notesQuery, customerID, dealershipID)
+	if err != nil {
+		return nil, err
+	}
+	defer noteRows.Close()
+
+	for noteRows.Next() {
+		var note DealNoteData
+		var createdBy sql.NullString
+
+		if err := noteRows.Scan(&note.ID, &note.DealID, &note.Body, &createdBy, &note.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		if createdBy.Valid {
+			note.CreatedBy = createdBy.String
+		}
+
+		export.DealNotes = append(export.DealNotes, note)
+	}
+
 	// Get consent
 	consent, _ := db.GetConsent(ctx, customerID, dealershipID)
 	export.Consent = consent
@@ -1025,6 +1414,42 @@ func (db *Database) GetCustomerWithRelatedData(ctx context.Context, customerID,
 	return export, nil
 }
 
+// CountCustomerRelatedData counts a customer's records in each table an
+// export reads, with direct COUNT(*) queries that don't share the export's
+// joins, so an export can be cross-checked against them
+func (db *Database) CountCustomerRelatedData(ctx context.Context, customerID, dealershipID string) (map[string]int, error) {
+	queries := []struct {
+		entity string
+		query  string
+	}{
+		{ExportEntityTags, `SELECT COUNT(*) FROM customer_tags WHERE customer_id = $1 AND dealership_id = $2`},
+		{ExportEntityDeals, `SELECT COUNT(*) FROM deals
+			WHERE (customer_id = $1 OR co_customer_id = $1) AND dealership_id = $2 AND deleted_at IS NULL`},
+		{ExportEntityShowroomVisits, `SELECT COUNT(*) FROM showroom_visits
+			WHERE customer_id = $1 AND dealership_id = $2 AND deleted_at IS NULL`},
+		{ExportEntityEmailLogs, `SELECT COUNT(*) FROM email_logs
+			WHERE recipient_email = (SELECT email FROM customers WHERE id = $1)
+			  AND dealership_id = $2 AND deleted_at IS NULL`},
+		{ExportEntityDealDocuments, `SELECT COUNT(*) FROM deal_documents WHERE deal_id IN (
+			SELECT id FROM deals WHERE customer_id = $1 AND dealership_id = $2
+		)`},
+		{ExportEntityDealNotes, `SELECT COUNT(*) FROM deal_notes WHERE deal_id IN (
+			SELECT id FROM deals
+			WHERE (customer_id = $1 OR co_customer_id = $1) AND dealership_id = $2 AND deleted_at IS NULL
+		)`},
+	}
+
+	counts := make(map[string]int, len(queries))
+	for _, q := range queries {
+		var count int
+		if err := db.conn.QueryRowContext(ctx, q.query, customerID, dealershipID).Scan(&count); err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", q.entity, err)
+		}
+		counts[q.entity] = count
+	}
+	return counts, nil
+}
+
 // SoftDeleteCustomer marks a customer as deleted
 func (db *Database) SoftDeleteCustomer(ctx context.Context, customerID, dealershipID string) error {
 	query := `UPDATE customers SET deleted_at = NOW(), updated_at = NOW() WHERE id = $1 AND dealership_id = $2`
@@ -1042,6 +1467,34 @@ func (db *Database) SoftDeleteCustomerDeals(ctx context.Context, customerID, dea
 	return result.RowsAffected()
 }
 
+// ReleaseCoBuyerDeals detaches a customer being deleted from deals shared
+// with a co-buyer, so the other buyer's deals survive. Deals where the
+// customer is the co-buyer lose the co-buyer; deals where they are the buyer
+// pass to the co-buyer. Returns the number of deals changed.
+func (db *Database) ReleaseCoBuyerDeals(ctx context.Context, customerID, dealershipID string) (int64, error) {
+	result, err := db.conn.ExecContext(ctx, `
+		UPDATE deals SET co_customer_id = NULL, updated_at = NOW()
+		WHERE co_customer_id = $1 AND dealership_id = $2
+	`, customerID, dealershipID)
+	if err != nil {
+		return 0, err
+	}
+	released, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	result, err = db.conn.ExecContext(ctx, `
+		UPDATE deals SET customer_id = co_customer_id, co_customer_id = NULL, updated_at = NOW()
+		WHERE customer_id = $1 AND dealership_id = $2 AND co_customer_id IS NOT NULL AND deleted_at IS NULL
+	`, customerID, dealershipID)
+	if err != nil {
+		return released, err
+	}
+	reassigned, err := result.RowsAffected()
+	return released + reassigned, err
+}
+
 // SoftDeleteCustomerVisits marks all customer showroom visits as deleted
 func (db *Database) SoftDeleteCustomerVisits(ctx context.Context, customerID, dealershipID string) (int64, error) {
 	query := `UPDATE showroom_visits SET deleted_at = NOW(), updated_at = NOW() WHERE customer_id = $1 AND dealership_id = $2`
@@ -1066,6 +1519,50 @@ func (db *Database) SoftDeleteCustomerEmails(ctx context.Context, customerID, de
 	return result.RowsAffected()
 }
 
+// CreateRetentionArchive records where an archived record was stored
+func (db *Database) CreateRetentionArchive(ctx context.Context, archive *RetentionArchive) error {
+	counts, _ := json.Marshal(archive.RecordCounts)
+
+	_, err := db.conn.ExecContext(ctx, `
+		INSERT INTO retention_archives (id, policy_id, dealership_id, entity_type, entity_id, region, bucket, object_key, record_counts, archived_at)
+		VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, archive.ID, archive.PolicyID, archive.DealershipID, archive.EntityType, archive.EntityID,
+		archive.Region, archive.Bucket, archive.Key, counts, archive.ArchivedAt)
+	return err
+}
+
+// PurgeCustomer permanently removes a customer and the records exported
+// with them. Deals shared with a co-buyer pass to the co-buyer first; deal
+// notes and documents go with the customer's deals.
+func (db *Database) PurgeCustomer(ctx context.Context, customerID, dealershipID string) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`UPDATE deals SET co_customer_id = NULL, updated_at = NOW()
+			WHERE co_customer_id = $1 AND dealership_id = $2`,
+		`UPDATE deals SET customer_id = co_customer_id, co_customer_id = NULL, updated_at = NOW()
+			WHERE customer_id = $1 AND dealership_id = $2 AND co_customer_id IS NOT NULL AND deleted_at IS NULL`,
+		`DELETE FROM deals WHERE customer_id = $1 AND dealership_id = $2`,
+		`DELETE FROM showroom_visits WHERE customer_id = $1 AND dealership_id = $2`,
+		`DELETE FROM email_logs
+			WHERE recipient_email = (SELECT email FROM customers WHERE id = $1)
+			  AND dealership_id = $2`,
+		`DELETE FROM customer_tags WHERE customer_id = $1 AND dealership_id = $2`,
+		`DELETE FROM customers WHERE id = $1 AND dealership_id = $2`,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt, customerID, dealershipID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
 // AnonymizeCustomer replaces customer PII with anonymized data
 func (db *Database) AnonymizeCustomer(ctx context.Context, customerID, dealershipID string) error {
 	query := `
@@ -1090,7 +1587,11 @@ func (db *Database) AnonymizeCustomer(ctx context.Context, customerID, dealershi
 			updated_at = NOW()
 		WHERE id = $1 AND dealership_id = $2
 	`
-	_, err := db.conn.ExecContext(ctx, query, customerID, dealershipID)
+	if _, err := db.conn.ExecContext(ctx, query, customerID, dealershipID); err != nil {
+		return err
+	}
+
+	_, err := db.conn.ExecContext(ctx, `DELETE FROM customer_tags WHERE customer_id = $1 AND dealership_id = $2`, customerID, dealershipID)
 	return err
 }
 
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"autolytiq/shared/configclient"
+)
+
+// settingDeletionRequiresApproval is the config-service key that enables the
+// two-person rule for a dealership's GDPR deletions
+const settingDeletionRequiresApproval = "gdpr_deletion_requires_approval"
+
+// statusAwaitingApproval is the status of a deletion request waiting for a
+// second admin
+const statusAwaitingApproval = "awaiting_approval"
+
+// Deletion approval errors
+var (
+	ErrRequestNotFound       = errors.New("request not found")
+	ErrNotAwaitingApproval   = errors.New("request is not awaiting approval")
+	ErrSelfApproval          = errors.New("a deletion request cannot be approved by its requester")
+	ErrApproverNotAdmin      = errors.New("only an admin can approve a deletion request")
+	ErrApproverNotIdentified = errors.New("approver must be identified")
+)
+
+// adminRoles may approve deletion requests. Roles are compared
+// case-insensitively since services disagree on casing.
+var adminRoles = []string{"admin", "super_admin"}
+
+// DealershipSettings reads dealership settings from config-service
+type DealershipSettings interface {
+	GetBool(ctx context.Context, dealershipID, key string) (bool, error)
+	GetString(ctx context.Context, dealershipID, key string) (string, error)
+}
+
+// deletionRequestStore is the storage used by the deletion approval flow
+type deletionRequestStore interface {
+	GetGDPRRequest(ctx context.Context, id string) (*GDPRRequest, error)
+	ApproveGDPRRequest(ctx context.Context, id, approvedBy string) (bool, error)
+	UpdateGDPRRequestStatus(ctx context.Context, id, status, notes string) error
+	CreateAuditLog(ctx context.Context, log *AuditLog) error
+}
+
+// newSettingsClient creates a cached config-service client
+func newSettingsClient(baseURL string) *configclient.Client {
+	cfg := configclient.DefaultConfig()
+	cfg.BaseURL = baseURL
+	cfg.CacheTTL = time.Minute
+	return configclient.NewClient(cfg)
+}
+
+// isAdminRole reports whether the role may approve deletions
+func isAdminRole(role string) bool {
+	for _, admin := range adminRoles {
+		if strings.EqualFold(role, admin) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeletionRequiresApproval reports whether the dealership requires a second
+// admin to approve deletions. If the setting cannot be read, approval is
+// required, since deletion cannot be undone.
+func (s *GDPRService) DeletionRequiresApproval(ctx context.Context, dealershipID string) bool {
+	if s.settings == nil {
+		return false
+	}
+
+	required, err := s.settings.GetBool(ctx, dealershipID, settingDeletionRequiresApproval)
+	if errors.Is(err, configclient.ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("dealership_id", dealershipID).Warn("Failed to load deletion approval setting, requiring approval")
+		return true
+	}
+	return required
+}
+
+// ApproveDeletionRequest approves a deletion request awaiting a second admin
+// and processes it. The approver must be an admin other than the requester.
+func (s *GDPRService) ApproveDeletionRequest(ctx context.Context, requestID, approvedBy, approverRole string) (*GDPRRequest, *DeletionResult, error) {
+	if approvedBy == "" {
+		return nil, nil, ErrApproverNotIdentified
+	}
+	if !isAdminRole(approverRole) {
+		return nil, nil, ErrApproverNotAdmin
+	}
+
+	request, err := s.requests.GetGDPRRequest(ctx, requestID)
+	if err != nil || request == nil {
+		return nil, nil, ErrRequestNotFound
+	}
+	if request.RequestType != "delete" || request.Status != statusAwaitingApproval {
+		return nil, nil, fmt.Errorf("%w: status is %s", ErrNotAwaitingApproval, request.Status)
+	}
+
+	if approvedBy == request.RequestedBy {
+		s.requests.CreateAuditLog(ctx, &AuditLog{
+			DealershipID: request.DealershipID,
+			EntityType:   "gdpr_request",
+			EntityID:     request.ID,
+			Action:       "gdpr_deletion_self_approval_rejected",
+			PerformedBy:  approvedBy,
+			Metadata: map[string]interface{}{
+				"customer_id":  request.CustomerID,
+				"requested_by": request.RequestedBy,
+			},
+		})
+		return nil, nil, ErrSelfApproval
+	}
+
+	approved, err := s.requests.ApproveGDPRRequest(ctx, request.ID, approvedBy)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to approve request: %w", err)
+	}
+	if !approved {
+		return nil, nil, fmt.Errorf("%w: already approved", ErrNotAwaitingApproval)
+	}
+
+	now := time.Now()
+	request.Status = "processing"
+	request.ApprovedBy = approvedBy
+	request.ApprovedAt = &now
+
+	s.requests.CreateAuditLog(ctx, &AuditLog{
+		DealershipID: request.DealershipID,
+		EntityType:   "gdpr_request",
+		EntityID:     request.ID,
+		Action:       "gdpr_deletion_approved",
+		PerformedBy:  approvedBy,
+		Metadata: map[string]interface{}{
+			"customer_id":  request.CustomerID,
+			"requested_by": request.RequestedBy,
+			"approved_by":  approvedBy,
+		},
+	})
+
+	s.logger.WithField("request_id", request.ID).
+		WithField("requested_by", request.RequestedBy).
+		WithField("approved_by", approvedBy).
+		Info("GDPR deletion request approved")
+
+	result, err := s.deleteData(ctx, request.CustomerID, request.DealershipID, request.RetainForLegal)
+	if err != nil {
+		s.requests.UpdateGDPRRequestStatus(ctx, request.ID, "failed", err.Error())
+		request.Status = "failed"
+		return request, nil, fmt.Errorf("failed to delete customer data: %w", err)
+	}
+
+	s.requests.UpdateGDPRRequestStatus(ctx, request.ID, "completed", "")
+	request.Status = "completed"
+	return request, result, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"autolytiq/services/shared/logging"
+)
+
+// fakeRequestStore holds GDPR requests and audit logs in memory
+type fakeRequestStore struct {
+	requests map[string]*GDPRRequest
+	audits   []*AuditLog
+}
+
+func (f *fakeRequestStore) GetGDPRRequest(ctx context.Context, id string) (*GDPRRequest, error) {
+	req, ok := f.requests[id]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	copied := *req
+	return &copied, nil
+}
+
+func (f *fakeRequestStore) ApproveGDPRRequest(ctx context.Context, id, approvedBy string) (bool, error) {
+	req, ok := f.requests[id]
+	if !ok || req.Status != statusAwaitingApproval {
+		return false, nil
+	}
+	req.Status = "processing"
+	req.ApprovedBy = approvedBy
+	return true, nil
+}
+
+func (f *fakeRequestStore) UpdateGDPRRequestStatus(ctx context.Context, id, status, notes string) error {
+	f.requests[id].Status = status
+	return nil
+}
+
+func (f *fakeRequestStore) CreateAuditLog(ctx context.Context, log *AuditLog) error {
+	f.audits = append(f.audits, log)
+	return nil
+}
+
+// newApprovalTestService returns a service with one deletion request awaiting
+// approval, requested by admin-1, and a counter of deletions run
+func newApprovalTestService() (*GDPRService, *fakeRequestStore, *int) {
+	store := &fakeRequestStore{requests: map[string]*GDPRRequest{
+		"req-1": {
+			ID:             "req-1",
+			CustomerID:     "cust-1",
+			DealershipID:   "dealer-1",
+			RequestType:    "delete",
+			Status:         statusAwaitingApproval,
+			RequestedBy:    "admin-1",
+			RetainForLegal: true,
+		},
+	}}
+
+	deletions := 0
+	service := &GDPRService{
+		logger:   logging.New(logging.Config{Service: "data-retention-service-test"}),
+		requests: store,
+		deleteData: func(ctx context.Context, customerID, dealershipID string, retainForLegal bool) (*DeletionResult, error) {
+			if !retainForLegal {
+				return nil, errors.New("expected retain_for_legal to be carried from the request")
+			}
+			deletions++
+			return &DeletionResult{CustomerDeleted: true, RetainedForLegal: retainForLegal}, nil
+		},
+	}
+	return service, store, &deletions
+}
+
+func TestApproveDeletionRequest_SelfApprovalRejected(t *testing.T) {
+	service, store, deletions := newApprovalTestService()
+
+	_, _, err := service.ApproveDeletionRequest(context.Background(), "req-1", "admin-1", "ADMIN")
+	if !errors.Is(err, ErrSelfApproval) {
+		t.Fatalf("Expected ErrSelfApproval, got %v", err)
+	}
+	if *deletions != 0 {
+		t.Error("Expected no deletion on self-approval")
+	}
+	if status := store.requests["req-1"].Status; status != statusAwaitingApproval {
+		t.Errorf("Expected request to stay awaiting approval, got %s", status)
+	}
+}
+
+func TestApproveDeletionRequest_SecondAdminTriggersProcessing(t *testing.T) {
+	service, store, deletions := newApprovalTestService()
+
+	request, result, err := service.ApproveDeletionRequest(context.Background(), "req-1", "admin-2", "admin")
+	if err != nil {
+		t.Fatalf("Expected approval to succeed, got %v", err)
+	}
+	if *deletions != 1 || result == nil || !result.CustomerDeleted {
+		t.Fatalf("Expected deletion to run once, got %d runs and result %+v", *deletions, result)
+	}
+	if request.Status != "completed" || store.requests["req-1"].Status != "completed" {
+		t.Errorf("Expected request completed, got %s", store.requests["req-1"].Status)
+	}
+	if request.RequestedBy != "admin-1" || request.ApprovedBy != "admin-2" {
+		t.Errorf("Expected requester admin-1 and approver admin-2, got %s and %s", request.RequestedBy, request.ApprovedBy)
+	}
+
+	var audit *AuditLog
+	for _, log := range store.audits {
+		if log.Action == "gdpr_deletion_approved" {
+			audit = log
+		}
+	}
+	if audit == nil {
+		t.Fatal("Expected approval to be audited")
+	}
+	if audit.Metadata["requested_by"] != "admin-1" || audit.Metadata["approved_by"] != "admin-2" {
+		t.Errorf("Expected audit to record both identities, got %+v", audit.Metadata)
+	}
+
+	// A second approval is rejected once processing has run
+	if _, _, err := service.ApproveDeletionRequest(context.Background(), "req-1", "admin-3", "admin"); !errors.Is(err, ErrNotAwaitingApproval) {
+		t.Errorf("Expected ErrNotAwaitingApproval, got %v", err)
+	}
+	if *deletions != 1 {
+		t.Errorf("Expected deletion to run once, got %d", *deletions)
+	}
+}
+
+func TestApproveDeletionRequest_RequiresAdmin(t *testing.T) {
+	service, _, deletions := newApprovalTestService()
+
+	_, _, err := service.ApproveDeletionRequest(context.Background(), "req-1", "sales-1", "SALESPERSON")
+	if !errors.Is(err, ErrApproverNotAdmin) {
+		t.Fatalf("Expected ErrApproverNotAdmin, got %v", err)
+	}
+	if *deletions != 0 {
+		t.Error("Expected no deletion for a non-admin approver")
+	}
+}
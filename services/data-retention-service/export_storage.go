@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"autolytiq/shared/configclient"
+	"autolytiq/shared/residency"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ExportStore stores GDPR exports in one region's storage backend
+type ExportStore interface {
+	PutObject(ctx context.Context, key, contentType string, body []byte) error
+}
+
+// ExportLocation is where a stored export was written
+type ExportLocation struct {
+	Region string `json:"region"`
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+}
+
+// s3ExportStore writes exports to an S3 bucket
+type s3ExportStore struct {
+	client *s3.Client
+	bucket string
+}
+
+// newS3ExportStore creates a store for a region's backend
+func newS3ExportStore(ctx context.Context, backend residency.Backend) (ExportStore, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(backend.StorageRegion))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if backend.Endpoint != "" {
+			o.BaseEndpoint = aws.String(backend.Endpoint)
+		}
+	})
+
+	return &s3ExportStore{client: client, bucket: backend.Bucket}, nil
+}
+
+// PutObject uploads an export to the bucket
+func (s *s3ExportStore) PutObject(ctx context.Context, key, contentType string, body []byte) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		Body:          bytes.NewReader(body),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(int64(len(body))),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload export: %w", err)
+	}
+	return nil
+}
+
+// DataRegion returns the region a customer's data must reside in: the
+// customer's own region when set, otherwise the dealership's data_region
+// setting. An error reading the setting is returned rather than assuming the
+// default region.
+func (s *GDPRService) DataRegion(ctx context.Context, dealershipID, customerRegion string) (string, error) {
+	return dataRegion(ctx, s.settings, dealershipID, customerRegion)
+}
+
+// dataRegion resolves a customer's data region, reading the dealership's
+// setting only when the customer has no region of their own
+func dataRegion(ctx context.Context, settings DealershipSettings, dealershipID, customerRegion string) (string, error) {
+	var dealershipRegion string
+	if strings.TrimSpace(customerRegion) == "" && settings != nil {
+		value, err := settings.GetString(ctx, dealershipID, residency.SettingKey)
+		if err != nil && !errors.Is(err, configclient.ErrNotFound) {
+			return "", fmt.Errorf("failed to load data region: %w", err)
+		}
+		dealershipRegion = value
+	}
+	return residency.Resolve(customerRegion, dealershipRegion)
+}
+
+// StoreExport writes an export to the storage backend for the customer's
+// data region. destinationRegion, if set, must be the customer's region
+// unless cross-region transfers are allowed. It returns nil when no export
+// storage is configured.
+func (s *GDPRService) StoreExport(ctx context.Context, requestID string, export *CustomerExportData, destinationRegion string) (*ExportLocation, error) {
+	if !s.storage.Enabled() {
+		return nil, nil
+	}
+
+	region, err := s.DataRegion(ctx, export.Customer.DealershipID, export.Customer.Region)
+	if err != nil {
+		return nil, err
+	}
+	export.Customer.Region = region
+
+	backend, err := s.storage.Target(region, destinationRegion)
+	if err != nil {
+		s.requests.CreateAuditLog(ctx, &AuditLog{
+			DealershipID: export.Customer.DealershipID,
+			EntityType:   "customer",
+			EntityID:     export.CustomerID,
+			Action:       "gdpr_export_storage_rejected",
+			Metadata: map[string]interface{}{
+				"request_id":         requestID,
+				"data_region":        export.Customer.Region,
+				"destination_region": destinationRegion,
+				"reason":             err.Error(),
+			},
+		})
+		return nil, err
+	}
+
+	body, err := json.Marshal(export)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode export: %w", err)
+	}
+
+	store, err := s.newExportStore(ctx, backend)
+	if err != nil {
+		return nil, err
+	}
+
+	location := &ExportLocation{
+		Region: backend.Region,
+		Bucket: backend.Bucket,
+		Key:    fmt.Sprintf("gdpr-exports/%s/%s/%s.json", export.Customer.DealershipID, export.CustomerID, requestID),
+	}
+	if err := store.PutObject(ctx, location.Key, "application/json", body); err != nil {
+		return nil, err
+	}
+
+	s.logger.WithField("request_id", requestID).
+		WithField("region", location.Region).
+		WithField("bucket", location.Bucket).
+		Info("GDPR export stored")
+
+	return location, nil
+}
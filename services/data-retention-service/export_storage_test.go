@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"autolytiq/services/shared/logging"
+	"autolytiq/shared/configclient"
+	"autolytiq/shared/residency"
+)
+
+// fakeExportStore records the exports written to one backend
+type fakeExportStore struct {
+	backend residency.Backend
+	keys    []string
+	bodies  [][]byte
+}
+
+func (f *fakeExportStore) PutObject(ctx context.Context, key, contentType string, body []byte) error {
+	f.keys = append(f.keys, key)
+	f.bodies = append(f.bodies, body)
+	return nil
+}
+
+// newExportTestService returns a service with US and EU export backends and
+// the stores created for them, keyed by data region
+func newExportTestService(allowCrossRegion bool) (*GDPRService, map[string]*fakeExportStore, *fakeRequestStore) {
+	stores := make(map[string]*fakeExportStore)
+	requests := &fakeRequestStore{requests: map[string]*GDPRRequest{}}
+	service := &GDPRService{
+		logger:   logging.New(logging.Config{Service: "data-retention-service-test"}),
+		requests: requests,
+		storage: residency.NewRouter(allowCrossRegion,
+			residency.Backend{Region: residency.RegionUS, Bucket: "gdpr-exports-us", StorageRegion: "us-east-1"},
+			residency.Backend{Region: residency.RegionEU, Bucket: "gdpr-exports-eu", StorageRegion: "eu-west-1"},
+		),
+		newExportStore: func(ctx context.Context, backend residency.Backend) (ExportStore, error) {
+			store, ok := stores[backend.Region]
+			if !ok {
+				store = &fakeExportStore{backend: backend}
+				stores[backend.Region] = store
+			}
+			return store, nil
+		},
+	}
+	return service, stores, requests
+}
+
+func euExport() *CustomerExportData {
+	return &CustomerExportData{
+		CustomerID: "cust-1",
+		Customer:   CustomerData{ID: "cust-1", DealershipID: "dealer-1", Region: residency.RegionEU},
+	}
+}
+
+func TestStoreExport_EUCustomerUsesEUBackend(t *testing.T) {
+	service, stores, _ := newExportTestService(false)
+
+	location, err := service.StoreExport(context.Background(), "req-1", euExport(), "")
+	if err != nil {
+		t.Fatalf("Expected export to be stored, got %v", err)
+	}
+	if location.Region != residency.RegionEU || location.Bucket != "gdpr-exports-eu" {
+		t.Errorf("Expected the EU backend, got %+v", location)
+	}
+
+	eu := stores[residency.RegionEU]
+	if eu == nil || len(eu.keys) != 1 || eu.keys[0] != "gdpr-exports/dealer-1/cust-1/req-1.json" {
+		t.Fatalf("Expected one export in the EU store, got %+v", eu)
+	}
+	if eu.backend.StorageRegion != "eu-west-1" {
+		t.Errorf("Expected the EU store to use eu-west-1, got %s", eu.backend.StorageRegion)
+	}
+	if _, ok := stores[residency.RegionUS]; ok {
+		t.Error("Expected the US store not to be used for an EU customer")
+	}
+}
+
+// fakeSettings serves dealership settings from memory
+type fakeSettings map[string]string
+
+func (f fakeSettings) GetBool(ctx context.Context, dealershipID, key string) (bool, error) {
+	return f[key] == "true", nil
+}
+
+func (f fakeSettings) GetString(ctx context.Context, dealershipID, key string) (string, error) {
+	value, ok := f[key]
+	if !ok {
+		return "", configclient.ErrNotFound
+	}
+	return value, nil
+}
+
+func TestStoreExport_DealershipRegion(t *testing.T) {
+	service, stores, _ := newExportTestService(false)
+	service.settings = fakeSettings{residency.SettingKey: "EU"}
+
+	export := euExport()
+	export.Customer.Region = ""
+
+	location, err := service.StoreExport(context.Background(), "req-1", export, "")
+	if err != nil || location.Region != residency.RegionEU || stores[residency.RegionEU] == nil {
+		t.Fatalf("Expected the dealership's EU region to select the EU backend, got %+v, %v", location, err)
+	}
+	if export.Customer.Region != residency.RegionEU {
+		t.Errorf("Expected the export to record its region, got %q", export.Customer.Region)
+	}
+
+	// Dealerships without the setting use the default region
+	service, _, _ = newExportTestService(false)
+	service.settings = fakeSettings{}
+	export.Customer.Region = ""
+	if location, err := service.StoreExport(context.Background(), "req-2", export, ""); err != nil || location.Region != residency.DefaultRegion {
+		t.Errorf("Expected the default region, got %+v, %v", location, err)
+	}
+}
+
+func TestStoreExport_RejectsCrossRegion(t *testing.T) {
+	service, stores, requests := newExportTestService(false)
+
+	_, err := service.StoreExport(context.Background(), "req-1", euExport(), residency.RegionUS)
+	if !errors.Is(err, residency.ErrCrossRegion) {
+		t.Fatalf("Expected ErrCrossRegion, got %v", err)
+	}
+	if len(stores) != 0 {
+		t.Error("Expected nothing to be stored on a rejected transfer")
+	}
+	if len(requests.audits) != 1 || requests.audits[0].Action != "gdpr_export_storage_rejected" {
+		t.Errorf("Expected the rejected transfer to be audited, got %+v", requests.audits)
+	}
+
+	// Explicitly allowed transfers go to the requested region
+	service, stores, _ = newExportTestService(true)
+	location, err := service.StoreExport(context.Background(), "req-1", euExport(), residency.RegionUS)
+	if err != nil || location.Bucket != "gdpr-exports-us" || stores[residency.RegionUS] == nil {
+		t.Errorf("Expected an allowed transfer to use the US backend, got %+v, %v", location, err)
+	}
+}
+
+func TestStoreExport_IncludesCustomerTags(t *testing.T) {
+	service, stores, _ := newExportTestService(false)
+
+	export := euExport()
+	export.Customer.Tags = []string{"needs-follow-up", "VIP"}
+	if _, err := service.StoreExport(context.Background(), "req-1", export, ""); err != nil {
+		t.Fatalf("Expected export to be stored, got %v", err)
+	}
+
+	var stored CustomerExportData
+	if err := json.Unmarshal(stores[residency.RegionEU].bodies[0], &stored); err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Customer.Tags) != 2 || stored.Customer.Tags[0] != "needs-follow-up" || stored.Customer.Tags[1] != "VIP" {
+		t.Errorf("Expected the export to include the customer's tags, got %v", stored.Customer.Tags)
+	}
+}
+
+func TestStoreExport_NoStorageConfigured(t *testing.T) {
+	service, _, _ := newExportTestService(false)
+	service.storage = residency.NewRouter(false)
+
+	location, err := service.StoreExport(context.Background(), "req-1", euExport(), "")
+	if err != nil || location != nil {
+		t.Errorf("Expected export storage to be skipped, got %+v, %v", location, err)
+	}
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// sourceCustomerSelfService is the source and requester of GDPR requests a
+// customer made and verified themselves
+const sourceCustomerSelfService = "customer_self_service"
+
+// selfServiceVerificationTTL is how long a verification link keeps working
+const selfServiceVerificationTTL = 24 * time.Hour
+
+// Self-service request limits. Attempts count whether or not the email
+// belongs to a customer, so the limits reveal nothing about who is on file.
+const (
+	selfServiceWindow     = time.Hour
+	selfServiceIPLimit    = 10
+	selfServiceEmailLimit = 3
+)
+
+// selfServiceRequestTypes are the requests customers can make themselves
+var selfServiceRequestTypes = map[string]string{
+	"export": "a copy of your personal data",
+	"delete": "deletion of your personal data",
+}
+
+// Self-service errors
+var (
+	ErrSelfServiceRateLimited   = errors.New("too many requests")
+	ErrInvalidSelfServiceType   = errors.New("request_type must be export or delete")
+	ErrVerificationInvalid      = errors.New("verification link is invalid, expired or already used")
+	ErrSelfServiceNotConfigured = errors.New("self-service requests are not configured")
+)
+
+// GDPRVerification is a pending email verification of a self-service request
+type GDPRVerification struct {
+	ID           string
+	TokenHash    string
+	CustomerID   string
+	DealershipID string
+	RequestType  string
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+}
+
+// SelfServiceRequest is a customer's request to export or delete their data
+type SelfServiceRequest struct {
+	Email        string `json:"email"`
+	DealershipID string `json:"dealership_id"`
+	RequestType  string `json:"request_type"`
+}
+
+// selfServiceStore is the storage used by the self-service request flow
+type selfServiceStore interface {
+	FindCustomerByEmail(ctx context.Context, email, dealershipID string) (string, error)
+	CreateGDPRVerification(ctx context.Context, v *GDPRVerification) error
+	ClaimGDPRVerification(ctx context.Context, tokenHash string) (*GDPRVerification, error)
+	ReleaseGDPRVerification(ctx context.Context, id string) error
+	CreateGDPRRequest(ctx context.Context, req *GDPRRequest) error
+	CreateAuditLog(ctx context.Context, log *AuditLog) error
+}
+
+// verificationMailer emails customers the link that verifies their request
+type verificationMailer interface {
+	SendVerification(ctx context.Context, dealershipID, to, requestType, link string) error
+}
+
+// emailServiceMailer sends verification emails through email-service
+type emailServiceMailer struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// SendVerification sends the verification link for a self-service request
+func (m *emailServiceMailer) SendVerification(ctx context.Context, dealershipID, to, requestType, link string) error {
+	escaped := html.EscapeString(link)
+	body, err := json.Marshal(map[string]string{
+		"dealership_id": dealershipID,
+		"to":            to,
+		"subject":       "Confirm your data request",
+		"body_html": fmt.Sprintf(`<p>We received a request for %s.</p>`+
+			`<p><a href="%s">Confirm your request</a></p>`+
+			`<p>The link expires in 24 hours. If you did not make this request, you can ignore this email.</p>`,
+			selfServiceRequestTypes[requestType], escaped),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.baseURL+"/email/send", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build email request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Dealership-ID", dealershipID)
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("email service returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// attemptLimiter counts attempts per key in a sliding window
+type attemptLimiter struct {
+	mu       sync.Mutex
+	window   time.Duration
+	now      func() time.Time
+	attempts map[string][]time.Time
+}
+
+func newAttemptLimiter(window time.Duration) *attemptLimiter {
+	return &attemptLimiter{window: window, now: time.Now, attempts: make(map[string][]time.Time)}
+}
+
+// allow records an attempt for every key and reports whether each is within
+// its limit. The attempt counts even when it is refused.
+func (l *attemptLimiter) allow(limits map[string]int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	cutoff := now.Add(-l.window)
+	allowed := true
+	for key, limit := range limits {
+		recent := l.attempts[key][:0]
+		for _, at := range l.attempts[key] {
+			if at.After(cutoff) {
+				recent = append(recent, at)
+			}
+		}
+		recent = append(recent, now)
+		l.attempts[key] = recent
+		if len(recent) > limit {
+			allowed = false
+		}
+	}
+
+	// Keep the map from growing with keys that have gone quiet
+	if len(l.attempts) > 10000 {
+		for key, times := range l.attempts {
+			if !times[len(times)-1].After(cutoff) {
+				delete(l.attempts, key)
+			}
+		}
+	}
+	return allowed
+}
+
+// hashVerificationToken returns the stored form of a verification token
+func hashVerificationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// selfServiceVerifyURL returns the public link for a verification token
+func (s *GDPRService) selfServiceVerifyURL(token string) string {
+	return strings.TrimRight(s.config.PublicBaseURL, "/") + "/api/v1/gdpr/public/verify/" + token
+}
+
+// RequestSelfService starts a customer's self-service request by emailing
+// them a verification link. The result is the same whether or not the email
+// belongs to a customer of the dealership, so it cannot be used to find out.
+func (s *GDPRService) RequestSelfService(ctx context.Context, req SelfServiceRequest, ipAddress string) error {
+	email := strings.TrimSpace(req.Email)
+	if _, ok := selfServiceRequestTypes[req.RequestType]; !ok {
+		return ErrInvalidSelfServiceType
+	}
+	if s.selfService == nil || s.mailer == nil {
+		return ErrSelfServiceNotConfigured
+	}
+
+	if !s.selfServiceLimiter.allow(map[string]int{
+		"ip:" + ipAddress: selfServiceIPLimit,
+		"email:" + req.DealershipID + ":" + strings.ToLower(email): selfServiceEmailLimit,
+	}) {
+		return ErrSelfServiceRateLimited
+	}
+
+	log := s.logger.WithContext(ctx).WithField("dealership_id", req.DealershipID)
+
+	customerID, err := s.selfService.FindCustomerByEmail(ctx, email, req.DealershipID)
+	if err != nil {
+		return fmt.Errorf("failed to find customer: %w", err)
+	}
+	if customerID == "" {
+		log.Info("Self-service GDPR request for unknown email ignored")
+		return nil
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return fmt.Errorf("failed to generate verification token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	verification := &GDPRVerification{
+		TokenHash:    hashVerificationToken(token),
+		CustomerID:   customerID,
+		DealershipID: req.DealershipID,
+		RequestType:  req.RequestType,
+		ExpiresAt:    time.Now().Add(selfServiceVerificationTTL),
+	}
+	if err := s.selfService.CreateGDPRVerification(ctx, verification); err != nil {
+		return fmt.Errorf("failed to store verification: %w", err)
+	}
+
+	// A failed send is only logged: reporting it would reveal that the email
+	// belongs to a customer. The customer can ask again.
+	if err := s.mailer.SendVerification(ctx, req.DealershipID, email, req.RequestType, s.selfServiceVerifyURL(token)); err != nil {
+		log.WithError(err).WithField("customer_id", customerID).Error("Failed to send GDPR verification email")
+		return nil
+	}
+
+	log.WithField("customer_id", customerID).WithField("request_type", req.RequestType).Info("GDPR verification email sent")
+	return nil
+}
+
+// VerifySelfService verifies a self-service request from its emailed link
+// and creates the GDPR request for staff to fulfil. Deletions go through the
+// dealership's approval rule like any other.
+func (s *GDPRService) VerifySelfService(ctx context.Context, token string) (*GDPRRequest, error) {
+	if s.selfService == nil {
+		return nil, ErrSelfServiceNotConfigured
+	}
+
+	verification, err := s.selfService.ClaimGDPRVerification(ctx, hashVerificationToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim verification: %w", err)
+	}
+	if verification == nil {
+		return nil, ErrVerificationInvalid
+	}
+
+	request := &GDPRRequest{
+		CustomerID:   verification.CustomerID,
+		DealershipID: verification.DealershipID,
+		RequestType:  verification.RequestType,
+		Status:       "pending",
+		RequestedBy:  sourceCustomerSelfService,
+		Reason:       "Data subject access request",
+		Source:       sourceCustomerSelfService,
+	}
+	if request.RequestType == "delete" {
+		request.Reason = "Data subject erasure request"
+		if s.DeletionRequiresApproval(ctx, request.DealershipID) {
+			request.Status = statusAwaitingApproval
+		}
+	}
+
+	if err := s.selfService.CreateGDPRRequest(ctx, request); err != nil {
+		// Let the customer retry the same link
+		if err := s.selfService.ReleaseGDPRVerification(ctx, verification.ID); err != nil {
+			s.logger.WithContext(ctx).WithError(err).Error("Failed to release GDPR verification")
+		}
+		return nil, fmt.Errorf("failed to create %s request: %w", request.RequestType, err)
+	}
+
+	action := "gdpr_export_request"
+	if request.RequestType == "delete" {
+		action = "gdpr_deletion_request"
+	}
+	s.selfService.CreateAuditLog(ctx, &AuditLog{
+		DealershipID: request.DealershipID,
+		EntityType:   "customer",
+		EntityID:     request.CustomerID,
+		Action:       action,
+		PerformedBy:  sourceCustomerSelfService,
+		Metadata: map[string]interface{}{
+			"request_id":      request.ID,
+			"source":          sourceCustomerSelfService,
+			"verification_id": verification.ID,
+		},
+	})
+
+	return request, nil
+}
+
+// requestSelfServiceGDPR handles POST /gdpr/public/request. It is public:
+// the request is only created once the customer follows the emailed link.
+func (s *Server) requestSelfServiceGDPR(w http.ResponseWriter, r *http.Request) {
+	var req SelfServiceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !strings.Contains(req.Email, "@") || len(req.Email) > 254 {
+		http.Error(w, "A valid email is required", http.StatusBadRequest)
+		return
+	}
+	if !isValidUUID(req.DealershipID) {
+		http.Error(w, "A valid dealership_id is required", http.StatusBadRequest)
+		return
+	}
+
+	ip := r.Header.Get("X-Real-IP")
+	if ip == "" {
+		ip = r.RemoteAddr
+	}
+
+	err := s.gdprService.RequestSelfService(r.Context(), req, ip)
+	switch {
+	case errors.Is(err, ErrInvalidSelfServiceType):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, ErrSelfServiceRateLimited):
+		w.Header().Set("Retry-After", strconv.Itoa(int(selfServiceWindow.Seconds())))
+		http.Error(w, "Too many requests, try again later", http.StatusTooManyRequests)
+		return
+	case errors.Is(err, ErrSelfServiceNotConfigured):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to start self-service GDPR request")
+		http.Error(w, "Failed to process request", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "If the email belongs to a customer, a verification link has been sent to it",
+	})
+}
+
+// verifySelfServiceGDPR handles GET and POST /gdpr/public/verify/{token}.
+// GET only shows a confirmation form, so mail scanners that prefetch links
+// cannot verify a request; POST verifies it.
+func (s *Server) verifySelfServiceGDPR(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		respondSelfServicePage(w, http.StatusOK, "Confirm your data request.", true)
+		return
+	}
+
+	request, err := s.gdprService.VerifySelfService(r.Context(), mux.Vars(r)["token"])
+	if errors.Is(err, ErrVerificationInvalid) {
+		respondSelfServicePage(w, http.StatusGone, "This link is invalid, has expired or has already been used. You can make a new request.", false)
+		return
+	}
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to verify self-service GDPR request")
+		respondSelfServicePage(w, http.StatusInternalServerError, "We couldn't process your request. Please try again later.", false)
+		return
+	}
+
+	s.logger.WithContext(r.Context()).
+		WithField("request_id", request.ID).
+		WithField("request_type", request.RequestType).
+		Info("Self-service GDPR request verified")
+	respondSelfServicePage(w, http.StatusOK, "Thank you. Your request has been received and will be handled by the dealership.", false)
+}
+
+// respondSelfServicePage writes a minimal HTML page for verification links,
+// which customers open in a browser
+func respondSelfServicePage(w http.ResponseWriter, status int, message string, confirm bool) {
+	form := ""
+	if confirm {
+		form = `<form method="post"><button type="submit">Confirm request</button></form>`
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<!DOCTYPE html><html><head><meta charset="utf-8"><title>Data request</title></head><body><p>%s</p>%s</body></html>`, html.EscapeString(message), form)
+}
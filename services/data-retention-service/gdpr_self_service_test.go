@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"autolytiq/services/shared/logging"
+	"github.com/gorilla/mux"
+)
+
+const (
+	selfServiceDealershipID = "11111111-1111-1111-1111-111111111111"
+	selfServiceCustomerID   = "22222222-2222-2222-2222-222222222222"
+)
+
+// fakeSelfServiceStore holds one customer, verifications and created
+// requests in memory
+type fakeSelfServiceStore struct {
+	verifications map[string]*GDPRVerification
+	claimed       map[string]bool
+	requests      []*GDPRRequest
+	audits        []*AuditLog
+	createErr     error
+}
+
+func newFakeSelfServiceStore() *fakeSelfServiceStore {
+	return &fakeSelfServiceStore{
+		verifications: make(map[string]*GDPRVerification),
+		claimed:       make(map[string]bool),
+	}
+}
+
+func (f *fakeSelfServiceStore) FindCustomerByEmail(ctx context.Context, email, dealershipID string) (string, error) {
+	if email == "jane@example.com" && dealershipID == selfServiceDealershipID {
+		return selfServiceCustomerID, nil
+	}
+	return "", nil
+}
+
+func (f *fakeSelfServiceStore) CreateGDPRVerification(ctx context.Context, v *GDPRVerification) error {
+	v.ID = fmt.Sprintf("verification-%d", len(f.verifications)+1)
+	f.verifications[v.TokenHash] = v
+	return nil
+}
+
+func (f *fakeSelfServiceStore) ClaimGDPRVerification(ctx context.Context, tokenHash string) (*GDPRVerification, error) {
+	v, ok := f.verifications[tokenHash]
+	if !ok || f.claimed[v.ID] || time.Now().After(v.ExpiresAt) {
+		return nil, nil
+	}
+	f.claimed[v.ID] = true
+	return v, nil
+}
+
+func (f *fakeSelfServiceStore) ReleaseGDPRVerification(ctx context.Context, id string) error {
+	delete(f.claimed, id)
+	return nil
+}
+
+func (f *fakeSelfServiceStore) CreateGDPRRequest(ctx context.Context, req *GDPRRequest) error {
+	if f.createErr != nil {
+		return f.createErr
+	}
+	req.ID = fmt.Sprintf("request-%d", len(f.requests)+1)
+	f.requests = append(f.requests, req)
+	return nil
+}
+
+func (f *fakeSelfServiceStore) CreateAuditLog(ctx context.Context, log *AuditLog) error {
+	f.audits = append(f.audits, log)
+	return nil
+}
+
+// recordingMailer captures the verification links sent
+type recordingMailer struct {
+	links []string
+}
+
+func (m *recordingMailer) SendVerification(ctx context.Context, dealershipID, to, requestType, link string) error {
+	m.links = append(m.links, link)
+	return nil
+}
+
+func setupSelfServiceServer() (*Server, *fakeSelfServiceStore, *recordingMailer) {
+	store := newFakeSelfServiceStore()
+	mailer := &recordingMailer{}
+	logger := logging.New(logging.Config{Service: "data-retention-service-test"})
+
+	server := &Server{
+		router: mux.NewRouter(),
+		logger: logger,
+		gdprService: &GDPRService{
+			logger:             logger,
+			config:             &Config{PublicBaseURL: "https://app.autolytiq.com/"},
+			selfService:        store,
+			mailer:             mailer,
+			selfServiceLimiter: newAttemptLimiter(selfServiceWindow),
+		},
+	}
+	server.router.HandleFunc("/gdpr/public/request", server.requestSelfServiceGDPR).Methods("POST")
+	server.router.HandleFunc("/gdpr/public/verify/{token}", server.verifySelfServiceGDPR).Methods("GET", "POST")
+	return server, store, mailer
+}
+
+func requestSelfService(server *Server, ip, email, requestType string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(SelfServiceRequest{Email: email, DealershipID: selfServiceDealershipID, RequestType: requestType})
+	req := httptest.NewRequest("POST", "/gdpr/public/request", bytes.NewReader(body))
+	req.Header.Set("X-Real-IP", ip)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func verifySelfService(server *Server, method, link string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/gdpr/public/verify/"+link[strings.LastIndex(link, "/")+1:], nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestSelfServiceVerifyCreatesRequest(t *testing.T) {
+	server, store, mailer := setupSelfServiceServer()
+
+	rr := requestSelfService(server, "203.0.113.7", "jane@example.com", "export")
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(mailer.links) != 1 || !strings.HasPrefix(mailer.links[0], "https://app.autolytiq.com/api/v1/gdpr/public/verify/") {
+		t.Fatalf("Expected one verification link sent, got %v", mailer.links)
+	}
+	link := mailer.links[0]
+	for hash := range store.verifications {
+		if strings.Contains(link, hash) {
+			t.Error("Expected only the token's hash to be stored")
+		}
+	}
+
+	// Opening the link only shows the confirmation form
+	rr = verifySelfService(server, "GET", link)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `<form method="post">`) {
+		t.Fatalf("Expected a confirmation form, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(store.requests) != 0 {
+		t.Fatalf("Expected no request created before confirming, got %d", len(store.requests))
+	}
+
+	rr = verifySelfService(server, "POST", link)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(store.requests) != 1 {
+		t.Fatalf("Expected 1 request created, got %d", len(store.requests))
+	}
+	req := store.requests[0]
+	if req.CustomerID != selfServiceCustomerID || req.DealershipID != selfServiceDealershipID ||
+		req.RequestType != "export" || req.Status != "pending" ||
+		req.Source != sourceCustomerSelfService || req.RequestedBy != sourceCustomerSelfService {
+		t.Errorf("Unexpected request: %+v", req)
+	}
+	if len(store.audits) != 1 || store.audits[0].Action != "gdpr_export_request" || store.audits[0].PerformedBy != sourceCustomerSelfService {
+		t.Errorf("Expected the request audited, got %+v", store.audits)
+	}
+
+	// The link works once
+	if rr := verifySelfService(server, "POST", link); rr.Code != http.StatusGone {
+		t.Errorf("Expected a used link to be rejected, got %d", rr.Code)
+	}
+	if len(store.requests) != 1 {
+		t.Errorf("Expected a used link not to create another request, got %d", len(store.requests))
+	}
+}
+
+func TestSelfServiceDeletionFollowsApprovalRule(t *testing.T) {
+	server, store, mailer := setupSelfServiceServer()
+	server.gdprService.settings = fakeSettings{settingDeletionRequiresApproval: "true"}
+
+	requestSelfService(server, "203.0.113.7", "jane@example.com", "delete")
+	if rr := verifySelfService(server, "POST", mailer.links[0]); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if len(store.requests) != 1 || store.requests[0].RequestType != "delete" || store.requests[0].Status != statusAwaitingApproval {
+		t.Errorf("Expected a deletion awaiting approval, got %+v", store.requests)
+	}
+}
+
+func TestSelfServiceUnknownEmailLooksTheSame(t *testing.T) {
+	server, store, mailer := setupSelfServiceServer()
+
+	known := requestSelfService(server, "203.0.113.7", "jane@example.com", "export")
+	unknown := requestSelfService(server, "203.0.113.7", "nobody@example.com", "export")
+	if unknown.Code != known.Code || unknown.Body.String() != known.Body.String() {
+		t.Errorf("Expected the same response for unknown emails, got %d %q and %d %q",
+			known.Code, known.Body.String(), unknown.Code, unknown.Body.String())
+	}
+	if len(mailer.links) != 1 || len(store.verifications) != 1 {
+		t.Errorf("Expected nothing sent for the unknown email, got %d links", len(mailer.links))
+	}
+}
+
+func TestSelfServiceRejectsBadLinks(t *testing.T) {
+	server, store, mailer := setupSelfServiceServer()
+
+	if rr := verifySelfService(server, "POST", "not-a-token"); rr.Code != http.StatusGone {
+		t.Errorf("Expected an unknown token to be rejected, got %d", rr.Code)
+	}
+
+	requestSelfService(server, "203.0.113.7", "jane@example.com", "export")
+	for _, v := range store.verifications {
+		v.ExpiresAt = time.Now().Add(-time.Minute)
+	}
+	if rr := verifySelfService(server, "POST", mailer.links[0]); rr.Code != http.StatusGone {
+		t.Errorf("Expected an expired link to be rejected, got %d", rr.Code)
+	}
+	if len(store.requests) != 0 {
+		t.Errorf("Expected no requests created, got %d", len(store.requests))
+	}
+}
+
+func TestSelfServiceLinkCanRetryAfterFailure(t *testing.T) {
+	server, store, mailer := setupSelfServiceServer()
+	requestSelfService(server, "203.0.113.7", "jane@example.com", "export")
+
+	store.createErr = fmt.Errorf("database unavailable")
+	if rr := verifySelfService(server, "POST", mailer.links[0]); rr.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status 500, got %d", rr.Code)
+	}
+
+	store.createErr = nil
+	if rr := verifySelfService(server, "POST", mailer.links[0]); rr.Code != http.StatusOK || len(store.requests) != 1 {
+		t.Errorf("Expected the retry to create the request, got %d with %d requests", rr.Code, len(store.requests))
+	}
+}
+
+func TestSelfServiceRateLimited(t *testing.T) {
+	t.Run("per email", func(t *testing.T) {
+		server, _, mailer := setupSelfServiceServer()
+
+		for i := 0; i < selfServiceEmailLimit; i++ {
+			ip := fmt.Sprintf("203.0.113.%d", i+1)
+			if rr := requestSelfService(server, ip, "jane@example.com", "export"); rr.Code != http.StatusAccepted {
+				t.Fatalf("Request %d: expected status 202, got %d", i+1, rr.Code)
+			}
+		}
+
+		// Changing the address's case or the IP does not get around the limit
+		rr := requestSelfService(server, "198.51.100.1", "JANE@example.com", "export")
+		if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+			t.Errorf("Expected status 429 with Retry-After, got %d", rr.Code)
+		}
+		if len(mailer.links) != selfServiceEmailLimit {
+			t.Errorf("Expected %d emails sent, got %d", selfServiceEmailLimit, len(mailer.links))
+		}
+
+		// The limit resets once the window passes
+		server.gdprService.selfServiceLimiter.now = func() time.Time { return time.Now().Add(selfServiceWindow + time.Minute) }
+		if rr := requestSelfService(server, "198.51.100.1", "jane@example.com", "export"); rr.Code != http.StatusAccepted {
+			t.Errorf("Expected status 202 after the window, got %d", rr.Code)
+		}
+	})
+
+	t.Run("per IP", func(t *testing.T) {
+		server, _, _ := setupSelfServiceServer()
+
+		for i := 0; i < selfServiceIPLimit; i++ {
+			email := fmt.Sprintf("person%d@example.com", i)
+			if rr := requestSelfService(server, "203.0.113.7", email, "export"); rr.Code != http.StatusAccepted {
+				t.Fatalf("Request %d: expected status 202, got %d", i+1, rr.Code)
+			}
+		}
+
+		if rr := requestSelfService(server, "203.0.113.7", "jane@example.com", "export"); rr.Code != http.StatusTooManyRequests {
+			t.Errorf("Expected status 429, got %d", rr.Code)
+		}
+		if rr := requestSelfService(server, "198.51.100.1", "jane@example.com", "export"); rr.Code != http.StatusAccepted {
+			t.Errorf("Expected another IP to be allowed, got %d", rr.Code)
+		}
+	})
+}
+
+func TestSelfServiceRequestValidation(t *testing.T) {
+	server, _, _ := setupSelfServiceServer()
+
+	if rr := requestSelfService(server, "203.0.113.7", "not-an-email", "export"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid email to be rejected, got %d", rr.Code)
+	}
+	if rr := requestSelfService(server, "203.0.113.7", "jane@example.com", "anonymize"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unsupported request type to be rejected, got %d", rr.Code)
+	}
+}
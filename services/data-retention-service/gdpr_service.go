@@ -1,27 +1,59 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"strings"
 	"time"
 
 	"autolytiq/services/shared/logging"
+	"autolytiq/shared/residency"
+	"autolytiq/shared/tracing"
 )
 
 // GDPRService handles GDPR data subject rights operations
 type GDPRService struct {
-	db     *Database
-	logger *logging.Logger
-	config *Config
+	db         *Database
+	logger     *logging.Logger
+	config     *Config
+	httpClient *http.Client
+	requests   deletionRequestStore
+	settings   DealershipSettings
+	deleteData func(ctx context.Context, customerID, dealershipID string, retainForLegal bool) (*DeletionResult, error)
+
+	storage        *residency.Router
+	newExportStore func(ctx context.Context, backend residency.Backend) (ExportStore, error)
+
+	selfService        selfServiceStore
+	mailer             verificationMailer
+	selfServiceLimiter *attemptLimiter
 }
 
 // NewGDPRService creates a new GDPR service
 func NewGDPRService(db *Database, logger *logging.Logger, config *Config) *GDPRService {
-	return &GDPRService{
-		db:     db,
-		logger: logger,
-		config: config,
+	s := &GDPRService{
+		db:         db,
+		logger:     logger,
+		config:     config,
+		httpClient: &http.Client{Transport: tracing.Transport(nil), Timeout: 30 * time.Second},
+		requests:   db,
+		storage:    config.ExportStorage,
+
+		selfService:        db,
+		selfServiceLimiter: newAttemptLimiter(selfServiceWindow),
 	}
+	if config.EmailServiceURL != "" {
+		s.mailer = &emailServiceMailer{baseURL: strings.TrimRight(config.EmailServiceURL, "/"), httpClient: &http.Client{Transport: tracing.Transport(nil), Timeout: 10 * time.Second}}
+	}
+	if config.ConfigServiceURL != "" {
+		s.settings = newSettingsClient(config.ConfigServiceURL)
+	}
+	s.deleteData = s.DeleteCustomerData
+	s.newExportStore = newS3ExportStore
+	return s
 }
 
 // CreateExportRequest creates a GDPR export request
@@ -54,15 +86,22 @@ func (s *GDPRService) CreateExportRequest(ctx context.Context, customerID, deale
 	return request, nil
 }
 
-// CreateDeletionRequest creates a GDPR deletion request
-func (s *GDPRService) CreateDeletionRequest(ctx context.Context, customerID, dealershipID, requestedBy, reason string) (*GDPRRequest, error) {
+// CreateDeletionRequest creates a GDPR deletion request. When awaitingApproval
+// is set, the request is not processed until a second admin approves it.
+func (s *GDPRService) CreateDeletionRequest(ctx context.Context, customerID, dealershipID, requestedBy, reason string, retainForLegal, awaitingApproval bool) (*GDPRRequest, error) {
+	status := "pending"
+	if awaitingApproval {
+		status = statusAwaitingApproval
+	}
+
 	request := &GDPRRequest{
-		CustomerID:   customerID,
-		DealershipID: dealershipID,
-		RequestType:  "delete",
-		Status:       "pending",
-		RequestedBy:  requestedBy,
-		Reason:       reason,
+		CustomerID:     customerID,
+		DealershipID:   dealershipID,
+		RequestType:    "delete",
+		Status:         status,
+		RequestedBy:    requestedBy,
+		Reason:         reason,
+		RetainForLegal: retainForLegal,
 	}
 
 	if err := s.db.CreateGDPRRequest(ctx, request); err != nil {
@@ -77,8 +116,9 @@ func (s *GDPRService) CreateDeletionRequest(ctx context.Context, customerID, dea
 		Action:       "gdpr_deletion_request",
 		PerformedBy:  requestedBy,
 		Metadata: map[string]interface{}{
-			"request_id": request.ID,
-			"reason":     reason,
+			"request_id":        request.ID,
+			"reason":            reason,
+			"awaiting_approval": awaitingApproval,
 		},
 	})
 
@@ -93,6 +133,17 @@ func (s *GDPRService) ExportCustomerData(ctx context.Context, customerID, dealer
 		return nil, fmt.Errorf("failed to export customer data: %w", err)
 	}
 
+	expected, err := s.db.CountCustomerRelatedData(ctx, customerID, dealershipID)
+	if err != nil {
+		s.logger.WithError(err).WithField("customer_id", customerID).Warn("Failed to count customer data for export verification")
+	}
+	exportData.Metadata = verifyExportCompleteness(exportData, expected)
+	if exportData.Metadata.Completeness != ExportCompletenessVerified {
+		s.logger.WithField("customer_id", customerID).
+			WithField("anomalies", len(exportData.Metadata.Anomalies)).
+			Warn("Customer data export is partial")
+	}
+
 	// Log the export
 	s.db.CreateAuditLog(ctx, &AuditLog{
 		DealershipID: dealershipID,
@@ -105,6 +156,9 @@ func (s *GDPRService) ExportCustomerData(ctx context.Context, customerID, dealer
 			"deals_count":       len(exportData.Deals),
 			"visits_count":      len(exportData.ShowroomVisits),
 			"email_logs_count":  len(exportData.EmailLogs),
+			"documents_count":   len(exportData.DealDocuments),
+			"completeness":      exportData.Metadata.Completeness,
+			"anomalies":         exportData.Metadata.Anomalies,
 		},
 	})
 
@@ -113,6 +167,53 @@ func (s *GDPRService) ExportCustomerData(ctx context.Context, customerID, dealer
 	return exportData, nil
 }
 
+// verifyExportCompleteness compares the records in an export with the
+// expected count of each entity type. Any mismatch, or a count that couldn't
+// be taken, marks the export partial.
+func verifyExportCompleteness(export *CustomerExportData, expected map[string]int) *ExportMetadata {
+	exported := exportedCounts(export)
+
+	metadata := &ExportMetadata{
+		Completeness: ExportCompletenessVerified,
+		EntityCounts: exported,
+	}
+	for _, entity := range exportEntities {
+		count, ok := expected[entity]
+		switch {
+		case !ok:
+			metadata.Anomalies = append(metadata.Anomalies, ExportAnomaly{
+				EntityType: entity,
+				Exported:   exported[entity],
+				Message:    "Record count could not be verified",
+			})
+		case count != exported[entity]:
+			metadata.Anomalies = append(metadata.Anomalies, ExportAnomaly{
+				EntityType: entity,
+				Exported:   exported[entity],
+				Expected:   &count,
+				Message:    fmt.Sprintf("Exported %d of %d records", exported[entity], count),
+			})
+		}
+	}
+	if len(metadata.Anomalies) > 0 {
+		metadata.Completeness = ExportCompletenessPartial
+	}
+	return metadata
+}
+
+// exportedCounts returns the number of records of each entity type in an
+// export
+func exportedCounts(export *CustomerExportData) map[string]int {
+	return map[string]int{
+		ExportEntityTags:           len(export.Customer.Tags),
+		ExportEntityDeals:          len(export.Deals),
+		ExportEntityShowroomVisits: len(export.ShowroomVisits),
+		ExportEntityEmailLogs:      len(export.EmailLogs),
+		ExportEntityDealDocuments:  len(export.DealDocuments),
+		ExportEntityDealNotes:      len(export.DealNotes),
+	}
+}
+
 // DeleteCustomerData deletes customer data (right to erasure)
 func (s *GDPRService) DeleteCustomerData(ctx context.Context, customerID, dealershipID string, retainForLegal bool) (*DeletionResult, error) {
 	result := &DeletionResult{}
@@ -143,7 +244,8 @@ func (s *GDPRService) DeleteCustomerData(ctx context.Context, customerID, dealer
 		}
 		result.CustomerDeleted = true
 
-		// Soft delete related data
+		// Soft delete related data, keeping deals shared with a co-buyer
+		result.CoBuyerDealsUpdated = s.releaseCoBuyerDeals(ctx, customerID, dealershipID)
 		result.DealsDeleted, _ = s.db.SoftDeleteCustomerDeals(ctx, customerID, dealershipID)
 		result.VisitsDeleted, _ = s.db.SoftDeleteCustomerVisits(ctx, customerID, dealershipID)
 		result.EmailsDeleted, _ = s.db.SoftDeleteCustomerEmails(ctx, customerID, dealershipID)
@@ -155,7 +257,17 @@ func (s *GDPRService) DeleteCustomerData(ctx context.Context, customerID, dealer
 		}
 		result.CustomerDeleted = true
 
-		// Soft delete related data
+		// Stored documents (IDs, contracts) hold PII, so remove them outright.
+		// This runs while the customer is still the buyer on deals that pass
+		// to a co-buyer, since those documents may be theirs.
+		documentsDeleted, err := s.purgeDealDocuments(ctx, customerID, dealershipID)
+		if err != nil {
+			s.logger.WithError(err).WithField("customer_id", customerID).Error("Failed to purge deal documents")
+		}
+		result.DocumentsDeleted = documentsDeleted
+
+		// Soft delete related data, keeping deals shared with a co-buyer
+		result.CoBuyerDealsUpdated = s.releaseCoBuyerDeals(ctx, customerID, dealershipID)
 		result.DealsDeleted, _ = s.db.SoftDeleteCustomerDeals(ctx, customerID, dealershipID)
 		result.VisitsDeleted, _ = s.db.SoftDeleteCustomerVisits(ctx, customerID, dealershipID)
 		result.EmailsDeleted, _ = s.db.SoftDeleteCustomerEmails(ctx, customerID, dealershipID)
@@ -182,6 +294,8 @@ func (s *GDPRService) DeleteCustomerData(ctx context.Context, customerID, dealer
 			"deals_deleted":      result.DealsDeleted,
 			"visits_deleted":     result.VisitsDeleted,
 			"emails_deleted":     result.EmailsDeleted,
+			"documents_deleted":  result.DocumentsDeleted,
+			"co_buyer_deals_updated": result.CoBuyerDealsUpdated,
 		},
 	})
 
@@ -192,6 +306,54 @@ func (s *GDPRService) DeleteCustomerData(ctx context.Context, customerID, dealer
 	return result, nil
 }
 
+// releaseCoBuyerDeals detaches the customer from deals shared with a
+// co-buyer. Failures are logged; the shared deals are then soft deleted with
+// the customer's other deals.
+func (s *GDPRService) releaseCoBuyerDeals(ctx context.Context, customerID, dealershipID string) int64 {
+	updated, err := s.db.ReleaseCoBuyerDeals(ctx, customerID, dealershipID)
+	if err != nil {
+		s.logger.WithError(err).WithField("customer_id", customerID).Error("Failed to release co-buyer deals")
+	}
+	return updated
+}
+
+// purgeDealDocuments asks deal-service to remove every document stored on the
+// customer's deals, returning the number removed
+func (s *GDPRService) purgeDealDocuments(ctx context.Context, customerID, dealershipID string) (int64, error) {
+	body, err := json.Marshal(map[string]string{
+		"customer_id":   customerID,
+		"dealership_id": dealershipID,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.config.DealServiceURL+"/deals/documents/purge", bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create purge request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to call deal service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("deal service returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		DocumentsDeleted int64 `json:"documents_deleted"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("failed to decode purge response: %w", err)
+	}
+
+	return result.DocumentsDeleted, nil
+}
+
 // AnonymizeCustomerData anonymizes customer PII
 func (s *GDPRService) AnonymizeCustomerData(ctx context.Context, customerID, dealershipID, requestedBy string) (*AnonymizationResult, error) {
 	result := &AnonymizationResult{
@@ -0,0 +1,73 @@
+package main
+
+import "testing"
+
+func completenessTestExport() *CustomerExportData {
+	return &CustomerExportData{
+		CustomerID: "cust-1",
+		Customer:   CustomerData{ID: "cust-1", Tags: []string{"vip"}},
+		Deals:      []DealData{{ID: "deal-1"}, {ID: "deal-2"}},
+		DealNotes:  []DealNoteData{{ID: "note-1", DealID: "deal-1"}},
+	}
+}
+
+func TestVerifyExportCompleteness_Verified(t *testing.T) {
+	expected := map[string]int{
+		ExportEntityTags:           1,
+		ExportEntityDeals:          2,
+		ExportEntityShowroomVisits: 0,
+		ExportEntityEmailLogs:      0,
+		ExportEntityDealDocuments:  0,
+		ExportEntityDealNotes:      1,
+	}
+
+	metadata := verifyExportCompleteness(completenessTestExport(), expected)
+
+	if metadata.Completeness != ExportCompletenessVerified || len(metadata.Anomalies) != 0 {
+		t.Fatalf("Expected a verified export, got %+v", metadata)
+	}
+	if metadata.EntityCounts[ExportEntityDeals] != 2 || metadata.EntityCounts[ExportEntityDealNotes] != 1 {
+		t.Errorf("Expected entity counts to be recorded, got %v", metadata.EntityCounts)
+	}
+}
+
+func TestVerifyExportCompleteness_CountMismatchIsPartial(t *testing.T) {
+	// The database holds a document the export's join missed
+	expected := map[string]int{
+		ExportEntityTags:           1,
+		ExportEntityDeals:          2,
+		ExportEntityShowroomVisits: 0,
+		ExportEntityEmailLogs:      0,
+		ExportEntityDealDocuments:  1,
+		ExportEntityDealNotes:      1,
+	}
+
+	metadata := verifyExportCompleteness(completenessTestExport(), expected)
+
+	if metadata.Completeness != ExportCompletenessPartial {
+		t.Fatalf("Expected a partial export, got %s", metadata.Completeness)
+	}
+	if len(metadata.Anomalies) != 1 {
+		t.Fatalf("Expected 1 anomaly, got %+v", metadata.Anomalies)
+	}
+	anomaly := metadata.Anomalies[0]
+	if anomaly.EntityType != ExportEntityDealDocuments || anomaly.Exported != 0 || anomaly.Expected == nil || *anomaly.Expected != 1 {
+		t.Errorf("Unexpected anomaly %+v", anomaly)
+	}
+}
+
+func TestVerifyExportCompleteness_UnverifiedCountsArePartial(t *testing.T) {
+	metadata := verifyExportCompleteness(completenessTestExport(), nil)
+
+	if metadata.Completeness != ExportCompletenessPartial {
+		t.Fatalf("Expected a partial export without counts, got %s", metadata.Completeness)
+	}
+	if len(metadata.Anomalies) != len(exportEntities) {
+		t.Errorf("Expected every entity type flagged, got %+v", metadata.Anomalies)
+	}
+	for _, a := range metadata.Anomalies {
+		if a.Expected != nil {
+			t.Errorf("Expected no count for %s, got %d", a.EntityType, *a.Expected)
+		}
+	}
+}
@@ -4,6 +4,15 @@ go 1.18
 
 require (
 	autolytiq/services/shared/logging v0.0.0
+	autolytiq/shared/bodylimit v0.0.0
+	autolytiq/shared/configclient v0.0.0
+	autolytiq/shared/encryption v0.0.0
+	autolytiq/shared/graceful v0.0.0
+	autolytiq/shared/residency v0.0.0
+	autolytiq/shared/tracing v0.0.0
+	github.com/aws/aws-sdk-go-v2 v1.24.0
+	github.com/aws/aws-sdk-go-v2/config v1.26.1
+	github.com/aws/aws-sdk-go-v2/service/s3 v1.47.5
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/mux v1.8.1
 	github.com/lib/pq v1.10.9
@@ -12,9 +21,43 @@ require (
 require github.com/rs/zerolog v1.31.0 // indirect
 
 require (
+	autolytiq/shared/apierror v0.0.0 // indirect
+	autolytiq/shared/secrets v0.0.0 // indirect
+	github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream v1.5.4 // indirect
+	github.com/aws/aws-sdk-go-v2/credentials v1.16.12 // indirect
+	github.com/aws/aws-sdk-go-v2/feature/ec2/imds v1.14.10 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/configsources v1.2.9 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/endpoints/v2 v2.5.9 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/ini v1.7.2 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/v4a v1.2.9 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/accept-encoding v1.10.4 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/checksum v1.2.9 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/presigned-url v1.10.9 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/s3shared v1.16.9 // indirect
+	github.com/aws/aws-sdk-go-v2/service/secretsmanager v1.26.0 // indirect
+	github.com/aws/aws-sdk-go-v2/service/sso v1.18.5 // indirect
+	github.com/aws/aws-sdk-go-v2/service/ssooidc v1.21.5 // indirect
+	github.com/aws/aws-sdk-go-v2/service/sts v1.26.5 // indirect
+	github.com/aws/smithy-go v1.19.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	golang.org/x/sys v0.14.0 // indirect
 )
 
 replace autolytiq/services/shared/logging => ../shared/logging
+
+replace autolytiq/shared/configclient => ../shared/configclient
+
+replace autolytiq/shared/encryption => ../shared/encryption
+
+replace autolytiq/shared/residency => ../shared/residency
+
+replace autolytiq/shared/secrets => ../shared/secrets
+
+replace autolytiq/shared/graceful => ../shared/graceful
+
+replace autolytiq/shared/bodylimit => ../shared/bodylimit
+
+replace autolytiq/shared/apierror => ../shared/apierror
+
+replace autolytiq/shared/tracing => ../shared/tracing
@@ -1,13 +1,20 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"autolytiq/services/shared/logging"
+	"autolytiq/shared/bodylimit"
+	"autolytiq/shared/graceful"
+	"autolytiq/shared/residency"
+	"autolytiq/shared/tracing"
 
 	"github.com/gorilla/mux"
 )
@@ -19,6 +26,10 @@ type Config struct {
 	CustomerServiceURL    string
 	DealServiceURL        string
 	EmailServiceURL       string
+	ConfigServiceURL      string
+	PublicBaseURL         string
+	ExportStorage         *residency.Router
+	ArchiveStorage        *residency.Router
 	EncryptionKey         string
 	DataRetentionEnabled  bool
 	AnonymizationEnabled  bool
@@ -34,19 +45,26 @@ type Server struct {
 	gdprService      *GDPRService
 	consentService   *ConsentService
 	scheduler        *Scheduler
+	auditLogs        auditLogWriter
+	tracer           *tracing.Tracer
 }
 
 // NewServer creates a new Data Retention service server
 func NewServer(config *Config, db *Database, logger *logging.Logger) *Server {
 	s := &Server{
-		router: mux.NewRouter(),
-		config: config,
-		db:     db,
-		logger: logger,
+		router:    mux.NewRouter(),
+		config:    config,
+		db:        db,
+		logger:    logger,
+		auditLogs: db,
+		tracer: tracing.New(tracing.Config{
+			Service: "data-retention-service",
+			OnError: func(err error) { logger.WithError(err).Warn("Failed to export trace spans") },
+		}),
 	}
 
 	// Initialize services
-	s.retentionService = NewRetentionService(db, logger)
+	s.retentionService = NewRetentionService(db, logger, config)
 	s.gdprService = NewGDPRService(db, logger, config)
 	s.consentService = NewConsentService(db, logger)
 	s.scheduler = NewScheduler(s.retentionService, s.gdprService, logger)
@@ -58,8 +76,10 @@ func NewServer(config *Config, db *Database, logger *logging.Logger) *Server {
 
 // setupMiddleware configures middleware
 func (s *Server) setupMiddleware() {
+	s.router.Use(s.tracer.Middleware)
 	s.router.Use(logging.RequestIDMiddleware)
 	s.router.Use(logging.RequestLoggingMiddleware(s.logger))
+	s.router.Use(bodylimit.Middleware(bodylimit.Config{}))
 }
 
 // setupRoutes configures all routes
@@ -78,8 +98,17 @@ func (s *Server) setupRoutes() {
 	s.router.HandleFunc("/gdpr/requests", s.listGDPRRequests).Methods("GET")
 	s.router.HandleFunc("/gdpr/requests/{id}", s.getGDPRRequest).Methods("GET")
 	s.router.HandleFunc("/gdpr/requests/{id}/status", s.updateGDPRRequestStatus).Methods("PUT")
+	s.router.HandleFunc("/gdpr/requests/{id}/approve", s.approveGDPRRequest).Methods("POST")
+
+	// Customer self-service requests (public, verified by emailed link)
+	s.router.HandleFunc("/gdpr/public/request", s.requestSelfServiceGDPR).Methods("POST")
+	s.router.HandleFunc("/gdpr/public/verify/{token}", s.verifySelfServiceGDPR).Methods("GET", "POST")
 
 	// Consent Management
+	s.router.HandleFunc("/consent/versions", s.listConsentVersions).Methods("GET")
+	s.router.HandleFunc("/consent/versions", s.registerConsentVersion).Methods("POST")
+	s.router.HandleFunc("/consent/outdated", s.listOutdatedConsents).Methods("GET")
+	s.router.HandleFunc("/consent/reconsent-requests", s.requestReconsent).Methods("POST")
 	s.router.HandleFunc("/consent/{customer_id}", s.getCustomerConsent).Methods("GET")
 	s.router.HandleFunc("/consent/{customer_id}", s.updateCustomerConsent).Methods("PUT")
 	s.router.HandleFunc("/consent/{customer_id}/history", s.getConsentHistory).Methods("GET")
@@ -94,6 +123,7 @@ func (s *Server) setupRoutes() {
 
 	// Audit Log
 	s.router.HandleFunc("/audit/logs", s.listAuditLogs).Methods("GET")
+	s.router.HandleFunc("/audit/logs", s.createAuditLog).Methods("POST")
 	s.router.HandleFunc("/audit/logs/{id}", s.getAuditLog).Methods("GET")
 
 	// Manual triggers (admin only)
@@ -159,8 +189,30 @@ func (s *Server) exportCustomerData(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Store the export in the customer's data region
+	location, err := s.gdprService.StoreExport(r.Context(), request.ID, exportData, r.URL.Query().Get("destination_region"))
+	if err != nil {
+		s.gdprService.UpdateRequestStatus(r.Context(), request.ID, "failed", err.Error())
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to store customer data export")
+		switch {
+		case errors.Is(err, residency.ErrCrossRegion):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, residency.ErrUnknownRegion):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, residency.ErrNoBackend):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		default:
+			http.Error(w, fmt.Sprintf("Failed to store customer data export: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
 	// Update request status
-	s.gdprService.UpdateRequestStatus(r.Context(), request.ID, "completed", "")
+	notes := ""
+	if location != nil {
+		notes = fmt.Sprintf("stored in %s: %s/%s", location.Region, location.Bucket, location.Key)
+	}
+	s.gdprService.UpdateRequestStatus(r.Context(), request.ID, "completed", notes)
 
 	s.logger.WithContext(r.Context()).
 		WithField("customer_id", customerID).
@@ -168,6 +220,9 @@ func (s *Server) exportCustomerData(w http.ResponseWriter, r *http.Request) {
 		Info("Customer data exported successfully")
 
 	w.Header().Set("Content-Type", "application/json")
+	if location != nil {
+		w.Header().Set("X-Data-Region", location.Region)
+	}
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=customer_%s_export.json", customerID))
 	json.NewEncoder(w).Encode(exportData)
 }
@@ -204,14 +259,38 @@ func (s *Server) deleteCustomerData(w http.ResponseWriter, r *http.Request) {
 		deleteReq.RetainForLegal = true
 	}
 
+	// A second admin must approve the deletion when the dealership requires it,
+	// so the requester has to be identified
+	requiresApproval := s.gdprService.DeletionRequiresApproval(r.Context(), dealershipID)
+	if requiresApproval && r.Header.Get("X-User-ID") == "" {
+		http.Error(w, "X-User-ID is required when deletions need approval", http.StatusBadRequest)
+		return
+	}
+
 	// Create GDPR request
-	request, err := s.gdprService.CreateDeletionRequest(r.Context(), customerID, dealershipID, requestedBy, deleteReq.Reason)
+	request, err := s.gdprService.CreateDeletionRequest(r.Context(), customerID, dealershipID, requestedBy, deleteReq.Reason, deleteReq.RetainForLegal, requiresApproval)
 	if err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to create deletion request")
 		http.Error(w, fmt.Sprintf("Failed to create deletion request: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	if requiresApproval {
+		s.logger.WithContext(r.Context()).
+			WithField("customer_id", customerID).
+			WithField("request_id", request.ID).
+			Info("Deletion request awaiting approval")
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":     statusAwaitingApproval,
+			"request_id": request.ID,
+			"message":    "Deletion request must be approved by a second admin",
+		})
+		return
+	}
+
 	// Process deletion
 	result, err := s.gdprService.DeleteCustomerData(r.Context(), customerID, dealershipID, deleteReq.RetainForLegal)
 	if err != nil {
@@ -372,6 +451,43 @@ func (s *Server) updateGDPRRequestStatus(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(map[string]string{"status": "updated"})
 }
 
+// approveGDPRRequest approves a deletion request awaiting a second admin and
+// processes the deletion
+func (s *Server) approveGDPRRequest(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	requestID := vars["id"]
+
+	approvedBy := r.Header.Get("X-User-ID")
+	role := r.Header.Get("X-User-Role")
+
+	request, result, err := s.gdprService.ApproveDeletionRequest(r.Context(), requestID, approvedBy, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrApproverNotIdentified):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, ErrSelfApproval), errors.Is(err, ErrApproverNotAdmin):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, ErrRequestNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, ErrNotAwaitingApproval):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			s.logger.WithContext(r.Context()).WithError(err).WithField("request_id", requestID).Error("Failed to process approved deletion request")
+			http.Error(w, fmt.Sprintf("Failed to process deletion request: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":      "success",
+		"request_id":  request.ID,
+		"approved_by": request.ApprovedBy,
+		"message":     "Customer data has been deleted",
+		"details":     result,
+	})
+}
+
 // getCustomerConsent gets consent status for a customer
 func (s *Server) getCustomerConsent(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -414,9 +530,17 @@ func (s *Server) updateCustomerConsent(w http.ResponseWriter, r *http.Request) {
 	consent.CustomerID = customerID
 	consent.DealershipID = dealershipID
 	consent.UpdatedBy = r.Header.Get("X-User-ID")
-	consent.IPAddress = r.RemoteAddr
+	consent.Request = consentRequestContext(r)
+	consent.IPAddress = consent.Request.IPAddress
+	if consent.UserAgent == "" {
+		consent.UserAgent = consent.Request.UserAgent
+	}
 
 	updatedConsent, err := s.consentService.UpdateConsent(r.Context(), &consent)
+	if errors.Is(err, ErrConsentVersionNotCurrent) || errors.Is(err, ErrInvalidConsentSource) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to update customer consent")
 		http.Error(w, fmt.Sprintf("Failed to update consent: %v", err), http.StatusInternalServerError)
@@ -431,7 +555,25 @@ func (s *Server) updateCustomerConsent(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(updatedConsent)
 }
 
-// getConsentHistory gets consent change history for a customer
+// consentRequestContext describes the request a consent change arrived in.
+// The client address is the one the gateway saw when it proxied the request.
+func consentRequestContext(r *http.Request) *ConsentRequestContext {
+	ip := r.Header.Get("X-Real-IP")
+	if ip == "" {
+		ip = r.RemoteAddr
+	}
+	return &ConsentRequestContext{
+		RequestID:      logging.GetTraceID(r.Context()),
+		IPAddress:      ip,
+		UserAgent:      r.UserAgent(),
+		AcceptLanguage: r.Header.Get("Accept-Language"),
+		Origin:         r.Header.Get("Origin"),
+		Referer:        r.Referer(),
+	}
+}
+
+// getConsentHistory gets consent change history for a customer. Each entry
+// includes the consent snapshot, policy version and request it was made in.
 func (s *Server) getConsentHistory(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	customerID := vars["customer_id"]
@@ -453,6 +595,165 @@ func (s *Server) getConsentHistory(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(history)
 }
 
+// listConsentVersions lists a dealership's consent versions
+func (s *Server) listConsentVersions(w http.ResponseWriter, r *http.Request) {
+	dealershipID := r.URL.Query().Get("dealership_id")
+	if dealershipID == "" {
+		http.Error(w, "dealership_id is required", http.StatusBadRequest)
+		return
+	}
+
+	versions, err := s.consentService.ListVersions(r.Context(), dealershipID)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list consent versions")
+		http.Error(w, fmt.Sprintf("Failed to list consent versions: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if versions == nil {
+		versions = []*ConsentVersion{}
+	}
+
+	current := currentConsentVersion(versions, time.Now())
+	var currentVersion string
+	if current != nil {
+		currentVersion = current.Version
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"current_version": currentVersion,
+		"versions":        versions,
+	})
+}
+
+// registerConsentVersion registers a new consent version for a dealership.
+// The effective date defaults to now.
+func (s *Server) registerConsentVersion(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		DealershipID  string `json:"dealership_id"`
+		Version       string `json:"version"`
+		EffectiveDate string `json:"effective_date"`
+		Description   string `json:"description"`
+		DocumentText  string `json:"document_text"`
+		DocumentHash  string `json:"document_hash"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !isValidUUID(req.DealershipID) {
+		http.Error(w, "Invalid dealership_id format", http.StatusBadRequest)
+		return
+	}
+
+	effectiveAt, err := parseEffectiveDate(req.EffectiveDate, time.Now())
+	if err != nil {
+		http.Error(w, "effective_date must be an RFC3339 timestamp or YYYY-MM-DD date", http.StatusBadRequest)
+		return
+	}
+
+	version, err := s.consentService.RegisterVersion(r.Context(), &ConsentVersion{
+		DealershipID: req.DealershipID,
+		Version:      req.Version,
+		EffectiveAt:  effectiveAt,
+		Description:  req.Description,
+		CreatedBy:    r.Header.Get("X-User-ID"),
+		DocumentHash: strings.ToLower(req.DocumentHash),
+	}, req.DocumentText)
+	switch {
+	case errors.Is(err, ErrInvalidConsentVersion):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, ErrConsentVersionExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to register consent version")
+		http.Error(w, fmt.Sprintf("Failed to register consent version: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(version)
+}
+
+// parseEffectiveDate parses an RFC3339 timestamp or a YYYY-MM-DD date
+// (midnight UTC), defaulting to now when empty
+func parseEffectiveDate(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return now.UTC(), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// listOutdatedConsents lists customers who need to re-consent
+func (s *Server) listOutdatedConsents(w http.ResponseWriter, r *http.Request) {
+	dealershipID := r.URL.Query().Get("dealership_id")
+	if dealershipID == "" {
+		http.Error(w, "dealership_id is required", http.StatusBadRequest)
+		return
+	}
+
+	current, outdated, err := s.consentService.ListOutdated(r.Context(), dealershipID)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list outdated consents")
+		http.Error(w, fmt.Sprintf("Failed to list outdated consents: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if outdated == nil {
+		outdated = []*OutdatedConsent{}
+	}
+
+	var currentVersion string
+	if current != nil {
+		currentVersion = current.Version
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"current_version": currentVersion,
+		"customers":       outdated,
+		"total":           len(outdated),
+	})
+}
+
+// requestReconsent requests re-consent from every customer on an older
+// consent version
+func (s *Server) requestReconsent(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		DealershipID string `json:"dealership_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !isValidUUID(req.DealershipID) {
+		http.Error(w, "Invalid dealership_id format", http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.consentService.RequestReconsent(r.Context(), req.DealershipID, r.Header.Get("X-User-ID"))
+	if errors.Is(err, ErrNoConsentVersion) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to request re-consent")
+		http.Error(w, fmt.Sprintf("Failed to request re-consent: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(result)
+}
+
 // marketingOptOut handles marketing opt-out requests
 func (s *Server) marketingOptOut(w http.ResponseWriter, r *http.Request) {
 	var req struct {
@@ -470,7 +771,7 @@ func (s *Server) marketingOptOut(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := s.consentService.ProcessMarketingOptOut(r.Context(), req.Email, req.CustomerID, req.DealershipID, r.RemoteAddr)
+	err := s.consentService.ProcessMarketingOptOut(r.Context(), req.Email, req.CustomerID, req.DealershipID, consentRequestContext(r))
 	if err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to process marketing opt-out")
 		http.Error(w, fmt.Sprintf("Failed to process opt-out: %v", err), http.StatusInternalServerError)
@@ -648,18 +949,24 @@ func (s *Server) generateRetentionReport(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(report)
 }
 
-// Start starts the Data Retention service server
-func (s *Server) Start() error {
+// Start serves the Data Retention service until ctx is cancelled, then
+// waits for in-flight requests to finish. The scheduler keeps running until
+// Stop.
+func (s *Server) Start(ctx context.Context) error {
 	// Start scheduler for background jobs
 	s.scheduler.Start()
 
 	s.logger.Infof("Starting Data Retention Service on port %s", s.config.Port)
-	return http.ListenAndServe(":"+s.config.Port, s.router)
+	srv := &http.Server{Addr: ":" + s.config.Port, Handler: s.router}
+	return graceful.ListenAndServe(ctx, srv, graceful.DefaultTimeout)
 }
 
 // Stop gracefully stops the server
 func (s *Server) Stop() {
 	s.scheduler.Stop()
+	if err := s.tracer.Shutdown(context.Background()); err != nil {
+		s.logger.WithError(err).Warn("Failed to flush trace spans")
+	}
 }
 
 func loadConfig() *Config {
@@ -669,9 +976,19 @@ func loadConfig() *Config {
 		CustomerServiceURL:    getEnv("CUSTOMER_SERVICE_URL", "http://localhost:8082"),
 		DealServiceURL:        getEnv("DEAL_SERVICE_URL", "http://localhost:8081"),
 		EmailServiceURL:       getEnv("EMAIL_SERVICE_URL", "http://localhost:8084"),
+		ConfigServiceURL:      getEnv("CONFIG_SERVICE_URL", "http://localhost:8086"),
+		PublicBaseURL:         getEnv("PUBLIC_BASE_URL", "http://localhost:8080"),
 		EncryptionKey:         os.Getenv("PII_ENCRYPTION_KEY"),
 		DataRetentionEnabled:  getEnvBool("DATA_RETENTION_ENABLED", true),
 		AnonymizationEnabled:  getEnvBool("ANONYMIZATION_ENABLED", true),
+		ExportStorage: residency.FromEnv("GDPR_EXPORT", residency.Backend{
+			Bucket:        os.Getenv("GDPR_EXPORT_BUCKET"),
+			StorageRegion: getEnv("AWS_REGION", "us-east-1"),
+		}),
+		ArchiveStorage: residency.FromEnv("RETENTION_ARCHIVE", residency.Backend{
+			Bucket:        os.Getenv("RETENTION_ARCHIVE_BUCKET"),
+			StorageRegion: getEnv("AWS_REGION", "us-east-1"),
+		}),
 	}
 }
 
@@ -733,11 +1050,15 @@ func main() {
 		logger.Warnf("Failed to seed default policies: %v", err)
 	}
 
+	// Stop serving on SIGINT or SIGTERM, once in-flight requests finish
+	ctx, stop := graceful.SignalContext(context.Background())
+	defer stop()
+
 	// Create and start server
 	server := NewServer(config, db, logger)
 	defer server.Stop()
 
-	if err := server.Start(); err != nil {
+	if err := server.Start(ctx); err != nil {
 		logger.Fatal(err.Error())
 	}
 }
@@ -10,13 +10,25 @@ type GDPRRequest struct {
 	CustomerID   string     `json:"customer_id"`
 	DealershipID string     `json:"dealership_id"`
 	RequestType  string     `json:"request_type"` // export, delete, anonymize
-	Status       string     `json:"status"`       // pending, processing, completed, failed
+	Status       string     `json:"status"`       // awaiting_approval, pending, processing, completed, failed
 	RequestedBy  string     `json:"requested_by"`
 	Reason       string     `json:"reason,omitempty"`
 	Notes        string     `json:"notes,omitempty"`
 	ProcessedAt  *time.Time `json:"processed_at,omitempty"`
 	CreatedAt    time.Time  `json:"created_at"`
 	UpdatedAt    time.Time  `json:"updated_at"`
+
+	// RetainForLegal is the deletion option the request will be processed with
+	RetainForLegal bool `json:"retain_for_legal,omitempty"`
+
+	// ApprovedBy is the second admin who approved a deletion under the
+	// two-person rule
+	ApprovedBy string     `json:"approved_by,omitempty"`
+	ApprovedAt *time.Time `json:"approved_at,omitempty"`
+
+	// Source is where the request came from. Requests customers make and
+	// verify themselves are customer_self_service; staff requests have none.
+	Source string `json:"source,omitempty"`
 }
 
 // CustomerConsent represents customer consent preferences
@@ -35,6 +47,44 @@ type CustomerConsent struct {
 	UserAgent         string    `json:"user_agent,omitempty"`
 	CreatedAt         time.Time `json:"created_at"`
 	UpdatedAt         time.Time `json:"updated_at"`
+
+	// NeedsReconsent is set when the consent was given under an older version
+	// than the dealership's current consent version
+	NeedsReconsent        bool       `json:"needs_reconsent"`
+	CurrentConsentVersion string     `json:"current_consent_version,omitempty"`
+	ReconsentRequestedAt  *time.Time `json:"reconsent_requested_at,omitempty"`
+}
+
+// ConsentVersion is a registered version of a dealership's privacy policy.
+// The current version is the one with the latest effective date that has
+// passed.
+type ConsentVersion struct {
+	ID           string    `json:"id"`
+	DealershipID string    `json:"dealership_id"`
+	Version      string    `json:"version"`
+	EffectiveAt  time.Time `json:"effective_at"`
+	Description  string    `json:"description,omitempty"`
+	CreatedBy    string    `json:"created_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	// DocumentHash is the SHA-256 of the exact policy text of this version
+	DocumentHash string `json:"document_hash,omitempty"`
+}
+
+// OutdatedConsent is a customer whose consent predates the current version
+type OutdatedConsent struct {
+	CustomerID           string     `json:"customer_id"`
+	ConsentVersion       string     `json:"consent_version"`
+	ConsentedAt          time.Time  `json:"consented_at"`
+	ReconsentRequestedAt *time.Time `json:"reconsent_requested_at,omitempty"`
+}
+
+// ReconsentRequestResult summarizes a bulk re-consent request
+type ReconsentRequestResult struct {
+	DealershipID   string   `json:"dealership_id"`
+	CurrentVersion string   `json:"current_version"`
+	RequestedCount int      `json:"requested_count"`
+	CustomerIDs    []string `json:"customer_ids"`
 }
 
 // ConsentUpdate represents a request to update consent
@@ -50,6 +100,40 @@ type ConsentUpdate struct {
 	UpdatedBy         string `json:"updated_by,omitempty"`
 	IPAddress         string `json:"ip_address,omitempty"`
 	UserAgent         string `json:"user_agent,omitempty"`
+
+	// ConsentVersion is the policy version the customer accepted with this
+	// update. It must be the current version; omit it to keep the version the
+	// customer previously consented to.
+	ConsentVersion string `json:"consent_version,omitempty"`
+
+	// Source is the channel the consent was captured through
+	Source string `json:"source,omitempty"`
+
+	// Request describes the HTTP request that carried the update. It is set
+	// by the handler, not the caller.
+	Request *ConsentRequestContext `json:"-"`
+}
+
+// ConsentSnapshot is the full set of a customer's consent flags at a point
+// in time
+type ConsentSnapshot struct {
+	MarketingEmail    bool   `json:"marketing_email"`
+	MarketingSMS      bool   `json:"marketing_sms"`
+	MarketingPhone    bool   `json:"marketing_phone"`
+	DataProcessing    bool   `json:"data_processing"`
+	ThirdPartySharing bool   `json:"third_party_sharing"`
+	Analytics         bool   `json:"analytics"`
+	ConsentVersion    string `json:"consent_version"`
+}
+
+// ConsentRequestContext is the request a consent change was made in
+type ConsentRequestContext struct {
+	RequestID      string `json:"request_id,omitempty"`
+	IPAddress      string `json:"ip_address,omitempty"`
+	UserAgent      string `json:"user_agent,omitempty"`
+	AcceptLanguage string `json:"accept_language,omitempty"`
+	Origin         string `json:"origin,omitempty"`
+	Referer        string `json:"referer,omitempty"`
 }
 
 // ConsentHistory represents a consent change record
@@ -64,6 +148,16 @@ type ConsentHistory struct {
 	IPAddress    string    `json:"ip_address,omitempty"`
 	UserAgent    string    `json:"user_agent,omitempty"`
 	CreatedAt    time.Time `json:"created_at"`
+
+	// Proof of consent: each record carries the policy version and document
+	// hash in effect, the capture channel, the request it was made in and
+	// every consent flag after the change, so it stands on its own
+	ConsentVersion     string                 `json:"consent_version,omitempty"`
+	DocumentHash       string                 `json:"document_hash,omitempty"`
+	Source             string                 `json:"source,omitempty"`
+	Request            *ConsentRequestContext `json:"request,omitempty"`
+	RequestFingerprint string                 `json:"request_fingerprint,omitempty"`
+	Snapshot           *ConsentSnapshot       `json:"snapshot,omitempty"`
 }
 
 // RetentionPolicy defines data retention rules
@@ -80,6 +174,22 @@ type RetentionPolicy struct {
 	UpdatedAt     time.Time `json:"updated_at"`
 }
 
+// RetentionArchive records where a retention policy archived a record
+// before removing it from the database, so it can be restored if legally
+// required
+type RetentionArchive struct {
+	ID           string         `json:"id"`
+	PolicyID     string         `json:"policy_id"`
+	DealershipID string         `json:"dealership_id"`
+	EntityType   string         `json:"entity_type"`
+	EntityID     string         `json:"entity_id"`
+	Region       string         `json:"region"`
+	Bucket       string         `json:"bucket"`
+	Key          string         `json:"key"`
+	RecordCounts map[string]int `json:"record_counts"`
+	ArchivedAt   time.Time      `json:"archived_at"`
+}
+
 // AuditLog represents a data operation audit entry
 type AuditLog struct {
 	ID           string                 `json:"id"`
@@ -103,7 +213,53 @@ type CustomerExportData struct {
 	Deals          []DealData          `json:"deals,omitempty"`
 	ShowroomVisits []ShowroomVisitData `json:"showroom_visits,omitempty"`
 	EmailLogs      []EmailLogData      `json:"email_logs,omitempty"`
+	DealDocuments  []DealDocumentData  `json:"deal_documents,omitempty"`
+	DealNotes      []DealNoteData      `json:"deal_notes,omitempty"`
 	Consent        *CustomerConsent    `json:"consent,omitempty"`
+	Metadata       *ExportMetadata     `json:"metadata,omitempty"`
+}
+
+// Export completeness results
+const (
+	ExportCompletenessVerified = "verified"
+	ExportCompletenessPartial  = "partial"
+)
+
+// Entity types counted in an export
+const (
+	ExportEntityTags           = "customer_tags"
+	ExportEntityDeals          = "deals"
+	ExportEntityShowroomVisits = "showroom_visits"
+	ExportEntityEmailLogs      = "email_logs"
+	ExportEntityDealDocuments  = "deal_documents"
+	ExportEntityDealNotes      = "deal_notes"
+)
+
+// exportEntities are the entity types an export is verified on, in order
+var exportEntities = []string{
+	ExportEntityTags,
+	ExportEntityDeals,
+	ExportEntityShowroomVisits,
+	ExportEntityEmailLogs,
+	ExportEntityDealDocuments,
+	ExportEntityDealNotes,
+}
+
+// ExportMetadata records what an export contains and whether it was
+// verified against the source tables
+type ExportMetadata struct {
+	Completeness string          `json:"completeness"` // verified, partial
+	EntityCounts map[string]int  `json:"entity_counts"`
+	Anomalies    []ExportAnomaly `json:"anomalies,omitempty"`
+}
+
+// ExportAnomaly is an entity type whose exported records don't match the
+// source table
+type ExportAnomaly struct {
+	EntityType string `json:"entity_type"`
+	Exported   int    `json:"exported"`
+	Expected   *int   `json:"expected,omitempty"` // nil when the count couldn't be taken
+	Message    string `json:"message"`
 }
 
 // CustomerData represents customer personal data
@@ -121,6 +277,8 @@ type CustomerData struct {
 	CreditScore    *int       `json:"credit_score,omitempty"`
 	Notes          string     `json:"notes,omitempty"`
 	Source         string     `json:"source,omitempty"`
+	Tags           []string   `json:"tags,omitempty"`
+	Region         string     `json:"region,omitempty"` // data region; empty to use the dealership's
 	CreatedAt      time.Time  `json:"created_at"`
 	UpdatedAt      time.Time  `json:"updated_at"`
 	LastActivityAt *time.Time `json:"last_activity_at,omitempty"`
@@ -141,6 +299,7 @@ type DealData struct {
 	Taxes          float64   `json:"taxes,omitempty"`
 	Fees           float64   `json:"fees,omitempty"`
 	TotalPrice     float64   `json:"total_price,omitempty"`
+	CoBuyer        bool      `json:"co_buyer,omitempty"` // the customer is the deal's co-buyer
 	CreatedAt      time.Time `json:"created_at"`
 }
 
@@ -165,12 +324,36 @@ type EmailLogData struct {
 	CreatedAt time.Time  `json:"created_at"`
 }
 
+// DealDocumentData references a document stored on a customer's deal. The
+// document contents are not included in exports.
+type DealDocumentData struct {
+	ID           string    `json:"id"`
+	DealID       string    `json:"deal_id"`
+	DocumentType string    `json:"document_type"`
+	Filename     string    `json:"filename"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	UploadedBy   string    `json:"uploaded_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// DealNoteData represents a note left on a customer's deal
+type DealNoteData struct {
+	ID        string    `json:"id"`
+	DealID    string    `json:"deal_id"`
+	Body      string    `json:"body"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // DeletionResult represents the result of a deletion operation
 type DeletionResult struct {
 	CustomerDeleted     bool  `json:"customer_deleted"`
 	DealsDeleted        int64 `json:"deals_deleted"`
 	VisitsDeleted       int64 `json:"visits_deleted"`
 	EmailsDeleted       int64 `json:"emails_deleted"`
+	DocumentsDeleted    int64 `json:"documents_deleted"`
+	CoBuyerDealsUpdated int64 `json:"co_buyer_deals_updated"`
 	RetainedForLegal    bool  `json:"retained_for_legal"`
 	RetentionExpiresAt  *time.Time `json:"retention_expires_at,omitempty"`
 }
@@ -218,6 +401,7 @@ type CleanupResult struct {
 	CustomersProcessed int       `json:"customers_processed"`
 	CustomersDeleted   int       `json:"customers_deleted"`
 	CustomersAnonymized int      `json:"customers_anonymized"`
+	CustomersArchived  int       `json:"customers_archived"`
 	Errors             []string  `json:"errors,omitempty"`
 }
 
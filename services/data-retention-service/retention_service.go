@@ -6,20 +6,40 @@ import (
 	"time"
 
 	"autolytiq/services/shared/logging"
+	"autolytiq/shared/encryption"
+	"autolytiq/shared/residency"
 )
 
 // RetentionService handles data retention operations
 type RetentionService struct {
-	db     *Database
-	logger *logging.Logger
+	db       *Database
+	logger   *logging.Logger
+	archives retentionArchiveStore
+	settings DealershipSettings
+
+	archiveStorage  *residency.Router
+	newArchiveStore func(ctx context.Context, backend residency.Backend) (ExportStore, error)
+	encryptor       archiveEncryptor
 }
 
 // NewRetentionService creates a new retention service
-func NewRetentionService(db *Database, logger *logging.Logger) *RetentionService {
-	return &RetentionService{
-		db:     db,
-		logger: logger,
-	}
+func NewRetentionService(db *Database, logger *logging.Logger, config *Config) *RetentionService {
+	s := &RetentionService{
+		db:              db,
+		logger:          logger,
+		archives:        db,
+		archiveStorage:  newArchiveStorage(config.ArchiveStorage),
+		newArchiveStore: newS3ExportStore,
+	}
+	if config.ConfigServiceURL != "" {
+		s.settings = newSettingsClient(config.ConfigServiceURL)
+	}
+	if encryptor, err := encryption.NewEncryptorFromEnv(); err == nil {
+		s.encryptor = encryptor
+	} else if s.archiveStorage.Enabled() {
+		logger.WithError(err).Warn("Archive retention disabled: no encryption key configured")
+	}
+	return s
 }
 
 // GetRetentionStatus returns the overall retention status
@@ -241,6 +261,7 @@ func (s *RetentionService) RunRetentionCleanup(ctx context.Context) (*CleanupRes
 			"customers_processed":   result.CustomersProcessed,
 			"customers_deleted":     result.CustomersDeleted,
 			"customers_anonymized":  result.CustomersAnonymized,
+			"customers_archived":    result.CustomersArchived,
 			"duration_seconds":      result.CompletedAt.Sub(result.StartedAt).Seconds(),
 			"errors_count":          len(result.Errors),
 		},
@@ -249,6 +270,7 @@ func (s *RetentionService) RunRetentionCleanup(ctx context.Context) (*CleanupRes
 	s.logger.WithField("customers_processed", result.CustomersProcessed).
 		WithField("customers_deleted", result.CustomersDeleted).
 		WithField("customers_anonymized", result.CustomersAnonymized).
+		WithField("customers_archived", result.CustomersArchived).
 		Info("Retention cleanup completed")
 
 	return result, nil
@@ -271,6 +293,11 @@ func (s *RetentionService) processCustomerRetention(ctx context.Context, policy
 			continue
 		}
 
+		metadata := map[string]interface{}{
+			"policy_id":      policy.ID,
+			"retention_days": policy.RetentionDays,
+		}
+
 		switch policy.Action {
 		case "delete":
 			if err := s.db.SoftDeleteCustomer(ctx, customerID, dealershipID); err != nil {
@@ -285,6 +312,22 @@ func (s *RetentionService) processCustomerRetention(ctx context.Context, policy
 				continue
 			}
 			result.CustomersAnonymized++
+
+		case "archive":
+			archive, err := s.archiveCustomer(ctx, policy, customerID, dealershipID)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("Failed to archive customer %s: %v", customerID, err))
+				if archive == nil {
+					continue
+				}
+			} else {
+				result.CustomersArchived++
+			}
+			metadata["archive_id"] = archive.ID
+			metadata["archive_region"] = archive.Region
+			metadata["archive_bucket"] = archive.Bucket
+			metadata["archive_key"] = archive.Key
+			metadata["removed"] = err == nil
 		}
 
 		// Log individual action
@@ -294,10 +337,7 @@ func (s *RetentionService) processCustomerRetention(ctx context.Context, policy
 			EntityID:     customerID,
 			Action:       fmt.Sprintf("retention_%s", policy.Action),
 			PerformedBy:  "retention_job",
-			Metadata: map[string]interface{}{
-				"policy_id":      policy.ID,
-				"retention_days": policy.RetentionDays,
-			},
+			Metadata:     metadata,
 		})
 	}
 
@@ -394,5 +434,8 @@ func (s *RetentionService) validatePolicy(policy *RetentionPolicy) error {
 	if policy.Action != "delete" && policy.Action != "anonymize" && policy.Action != "archive" {
 		return fmt.Errorf("action must be one of: delete, anonymize, archive")
 	}
+	if policy.Action == "archive" && !archivableEntityTypes[policy.EntityType] {
+		return fmt.Errorf("entity type %s does not support the archive action", policy.EntityType)
+	}
 	return nil
 }
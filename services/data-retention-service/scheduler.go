@@ -55,6 +55,17 @@ func (s *Scheduler) Start() {
 			Info("Daily retention cleanup completed")
 	})
 
+	// Daily cleanup of expired self-service verification links (runs at 2:30 AM)
+	s.wg.Add(1)
+	go s.runDailyJob("gdpr_verification_cleanup", 2, 30, func(ctx context.Context) {
+		deleted, err := s.gdprService.db.DeleteExpiredGDPRVerifications(ctx)
+		if err != nil {
+			s.logger.WithError(err).Error("GDPR verification cleanup failed")
+			return
+		}
+		s.logger.WithField("verifications_deleted", deleted).Info("GDPR verification cleanup completed")
+	})
+
 	// Weekly anonymization job (runs on Sunday at 3 AM)
 	s.wg.Add(1)
 	go s.runWeeklyJob("anonymization", time.Sunday, 3, 0, func(ctx context.Context) {
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"autolytiq/shared/apierror"
+
+	"github.com/google/uuid"
+)
+
+// exportDateLayout is the format of the export's from and to parameters
+const exportDateLayout = "2006-01-02"
+
+// exportFlushRows is how many rows are buffered before flushing to the client
+const exportFlushRows = 100
+
+// Export formats
+const (
+	ExportFormatCSV        = "csv"
+	ExportFormatAccounting = "accounting"
+)
+
+// AccountingExportFilter selects the deals in an accounting export
+type AccountingExportFilter struct {
+	DealershipID string
+	Status       string
+	// From and To bound the deal's funding date, falling back to its
+	// creation date for deals that were never funded. To is exclusive.
+	From *time.Time
+	To   *time.Time
+	// UnexportedOnly skips deals included in a previous export
+	UnexportedOnly bool
+}
+
+// AccountingDeal is a deal with the references an accounting export needs
+type AccountingDeal struct {
+	Deal        *Deal
+	VIN         string
+	StockNumber string
+	FundedAt    *time.Time
+	// ExportedAt is when the deal was last exported, if ever
+	ExportedAt *time.Time
+}
+
+// AccountingMapping is the deal export format configured on a dealership's
+// accounting integration, under the "deal_export" key of its config
+type AccountingMapping struct {
+	// Columns lists the exported fields, in order, with the header the
+	// accounting system expects for each
+	Columns []AccountingColumn `json:"columns"`
+	// DateFormat is a Go time layout for date fields. Defaults to RFC 3339.
+	DateFormat string `json:"date_format,omitempty"`
+}
+
+// AccountingColumn maps an export field to an accounting system header
+type AccountingColumn struct {
+	Field  string `json:"field"`
+	Header string `json:"header"`
+}
+
+// accountingExportFields are the export's fields in default column order
+var accountingExportFields = []string{
+	"deal_id", "dealership_id", "status",
+	"customer_id", "co_customer_id",
+	"vehicle_id", "vin", "stock_number",
+	"vehicle_price", "trade_in_value", "trade_in_payoff", "down_payment",
+	"tax_amount", "fees", "amount_financed", "total_amount",
+	"term_months", "sell_rate",
+	"created_at", "funded_at", "previously_exported_at",
+}
+
+// accountingExportValue formats one field of an exported deal, with derived
+// amounts rounded
+// under the dealership's rounding policy
+func accountingExportValue(d *AccountingDeal, field, dateFormat string, policy RoundingPolicy) string {
+	money := func(amount float64) string { return strconv.FormatFloat(roundCents(amount), 'f', 2, 64) }
+	date := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format(dateFormat)
+	}
+
+	deal := d.Deal
+	switch field {
+	case "deal_id":
+		return deal.ID
+	case "dealership_id":
+		return deal.DealershipID
+	case "status":
+		return deal.Status
+	case "customer_id":
+		return deal.CustomerID
+	case "co_customer_id":
+		return deal.CoCustomerID
+	case "vehicle_id":
+		return deal.VehicleID
+	case "vin":
+		return d.VIN
+	case "stock_number":
+		return d.StockNumber
+	case "vehicle_price":
+		return money(deal.VehiclePrice)
+	case "trade_in_value":
+		return money(deal.TradeInValue)
+	case "trade_in_payoff":
+		return money(deal.TradeInPayoff)
+	case "down_payment":
+		return money(deal.DownPayment)
+	case "tax_amount":
+		return money(deal.TaxAmount)
+	case "fees":
+		return money(deal.fees(policy))
+	case "amount_financed":
+		return money(deal.amountFinanced(policy))
+	case "total_amount":
+		return money(deal.TotalAmount)
+	case "term_months":
+		return strconv.Itoa(deal.TermMonths)
+	case "sell_rate":
+		return strconv.FormatFloat(roundRate(deal.SellRate), 'f', -1, 64)
+	case "created_at":
+		return date(&deal.CreatedAt)
+	case "funded_at":
+		return date(d.FundedAt)
+	case "previously_exported_at":
+		return date(d.ExportedAt)
+	}
+	return ""
+}
+
+// validAccountingMapping reports whether every mapped column names a known
+// export field
+func validAccountingMapping(m *AccountingMapping) bool {
+	if len(m.Columns) == 0 {
+		return false
+	}
+	for _, c := range m.Columns {
+		known := false
+		for _, f := range accountingExportFields {
+			if c.Field == f {
+				known = true
+				break
+			}
+		}
+		if !known || c.Header == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// parseExportFilter reads the export's query parameters
+func parseExportFilter(r *http.Request) (AccountingExportFilter, *ValidationErrors) {
+	q := r.URL.Query()
+	filter := AccountingExportFilter{
+		DealershipID:   q.Get("dealership_id"),
+		Status:         strings.ToLower(q.Get("status")),
+		UnexportedOnly: q.Get("incremental") == "true",
+	}
+	if filter.Status == "" {
+		filter.Status = "funded"
+	}
+
+	var errs []ValidationError
+	if filter.DealershipID == "" {
+		errs = append(errs, ValidationError{Field: "dealership_id", Message: "Dealership ID is required"})
+	}
+	if !validStatuses[filter.Status] {
+		errs = append(errs, ValidationError{Field: "status", Message: "Invalid status. Must be one of: draft, pending, approved, funded, delivered, cancelled"})
+	}
+	for _, p := range []struct {
+		field string
+		dst   **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		value := q.Get(p.field)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(exportDateLayout, value)
+		if err != nil {
+			errs = append(errs, ValidationError{Field: p.field, Message: "Date must be in YYYY-MM-DD format"})
+			continue
+		}
+		*p.dst = &t
+	}
+	if filter.To != nil {
+		// Include the whole of the to date
+		end := filter.To.AddDate(0, 0, 1)
+		filter.To = &end
+	}
+	if len(errs) == 0 && filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		errs = append(errs, ValidationError{Field: "from", Message: "From date must not be after to date"})
+	}
+
+	if len(errs) > 0 {
+		return filter, &ValidationErrors{Errors: errs}
+	}
+	return filter, nil
+}
+
+// exportDeals streams deals as CSV for accounting. format=accounting applies
+// the column mapping from the dealership's accounting integration. Every
+// exported deal is marked, and incremental=true limits the export to deals
+// not yet exported, so repeated incremental exports each pick up only new
+// deals.
+func (s *Server) exportDeals(w http.ResponseWriter, r *http.Request) {
+	filter, errs := parseExportFilter(r)
+	if errs != nil {
+		respondValidationError(w, errs)
+		return
+	}
+
+	columns := make([]AccountingColumn, len(accountingExportFields))
+	for i, f := range accountingExportFields {
+		columns[i] = AccountingColumn{Field: f, Header: f}
+	}
+	dateFormat := time.RFC3339
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", ExportFormatCSV:
+	case ExportFormatAccounting:
+		mapping, err := s.db.GetAccountingMapping(filter.DealershipID)
+		if err != nil {
+			s.logger.WithContext(r.Context()).WithError(err).Error("Failed to load accounting mapping")
+			respondErrorJSON(w, http.StatusInternalServerError, "Failed to load accounting mapping", "INTERNAL_ERROR")
+			return
+		}
+		if mapping == nil {
+			respondErrorJSON(w, http.StatusConflict, "No active accounting integration is configured for this dealership", "ACCOUNTING_NOT_CONFIGURED")
+			return
+		}
+		if !validAccountingMapping(mapping) {
+			respondErrorJSON(w, http.StatusConflict, "The accounting integration's deal export mapping is invalid", "ACCOUNTING_MAPPING_INVALID")
+			return
+		}
+		columns = mapping.Columns
+		if mapping.DateFormat != "" {
+			dateFormat = mapping.DateFormat
+		}
+	default:
+		respondErrorJSON(w, http.StatusBadRequest, "Invalid format. Must be one of: csv, accounting", "INVALID_FORMAT")
+		return
+	}
+
+	batchID := uuid.New().String()
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="deals-`+filter.DealershipID+`.csv"`)
+	w.Header().Set("X-Export-Batch-ID", batchID)
+
+	cw := csv.NewWriter(w)
+	headers := make([]string, len(columns))
+	for i, c := range columns {
+		headers[i] = c.Header
+	}
+	cw.Write(headers)
+
+	policy := s.roundingPolicy(r, filter.DealershipID)
+	var exported []string
+	record := make([]string, len(columns))
+	err := s.db.StreamAccountingDeals(filter, func(d *AccountingDeal) error {
+		for i, c := range columns {
+			record[i] = accountingExportValue(d, c.Field, dateFormat, policy)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+		exported = append(exported, d.Deal.ID)
+		if len(exported)%exportFlushRows == 0 {
+			cw.Flush()
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+		return cw.Error()
+	})
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to export deals")
+		if len(exported) == 0 {
+			// Only the buffered header row has been written, so the error
+			// can still be reported in its place
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to export deals: "+err.Error())
+		}
+		return
+	}
+	cw.Flush()
+
+	// Deals are marked only once the whole export has been written, so a
+	// failed export is picked up by the next incremental run
+	if err := cw.Error(); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Warn("Deal export was not delivered")
+		return
+	}
+	if len(exported) > 0 {
+		if err := s.db.MarkDealsExported(exported, batchID, time.Now()); err != nil {
+			s.logger.WithContext(r.Context()).WithError(err).WithField("batch_id", batchID).Error("Failed to mark exported deals")
+			return
+		}
+	}
+
+	s.logger.WithContext(r.Context()).
+		WithField("dealership_id", filter.DealershipID).
+		WithField("batch_id", batchID).
+		WithField("deals", len(exported)).
+		Info("Deals exported for accounting")
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func addExportDeal(db *MockDatabase, dealershipID, status string, fundedAt time.Time) *Deal {
+	deal := &Deal{
+		ID:           uuid.New().String(),
+		DealershipID: dealershipID,
+		CustomerID:   uuid.New().String(),
+		VehicleID:    uuid.New().String(),
+		VehiclePrice: 30000,
+		TradeInValue: 5000,
+		DownPayment:  2000,
+		TaxAmount:    1800,
+		TotalAmount:  32299.5,
+		TermMonths:   60,
+		SellRate:     6.9,
+		Status:       status,
+		CreatedAt:    fundedAt.Add(-72 * time.Hour),
+		UpdatedAt:    fundedAt,
+	}
+	db.deals[deal.ID] = deal
+	return deal
+}
+
+func exportDealsCSV(t *testing.T, server *Server, query string) (*httptest.ResponseRecorder, [][]string) {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/deals/export?"+query, nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		return rr, nil
+	}
+
+	records, err := csv.NewReader(strings.NewReader(rr.Body.String())).ReadAll()
+	if err != nil {
+		t.Fatalf("Export is not valid CSV: %v", err)
+	}
+	return rr, records
+}
+
+func TestExportDealsCSVStructure(t *testing.T) {
+	server := setupTestServer()
+	db := server.db.(*MockDatabase)
+	dealershipID := uuid.New().String()
+	fundedAt := time.Date(2024, 3, 15, 14, 30, 0, 0, time.UTC)
+	deal := addExportDeal(db, dealershipID, "funded", fundedAt)
+	addExportDeal(db, dealershipID, "pending", fundedAt)
+	addExportDeal(db, uuid.New().String(), "funded", fundedAt)
+	addExportDeal(db, dealershipID, "funded", fundedAt.AddDate(0, 1, 0))
+
+	rr, records := exportDealsCSV(t, server, "dealership_id="+dealershipID+"&from=2024-03-01&to=2024-03-31&format=csv")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("Expected CSV content type, got %q", ct)
+	}
+	if rr.Header().Get("X-Export-Batch-ID") == "" {
+		t.Error("Expected an export batch ID")
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("Expected a header and one funded deal in range, got %d rows", len(records))
+	}
+	if strings.Join(records[0], ",") != strings.Join(accountingExportFields, ",") {
+		t.Errorf("Unexpected header: %v", records[0])
+	}
+
+	row := make(map[string]string)
+	for i, field := range records[0] {
+		row[field] = records[1][i]
+	}
+	want := map[string]string{
+		"deal_id":                deal.ID,
+		"status":                 "funded",
+		"customer_id":            deal.CustomerID,
+		"vehicle_id":             deal.VehicleID,
+		"vehicle_price":          "30000.00",
+		"trade_in_value":         "5000.00",
+		"down_payment":           "2000.00",
+		"tax_amount":             "1800.00",
+		"fees":                   "499.50",
+		"amount_financed":        "24800.00",
+		"total_amount":           "32299.50",
+		"term_months":            "60",
+		"sell_rate":              "6.9",
+		"funded_at":              "2024-03-15T14:30:00Z",
+		"created_at":             "2024-03-12T14:30:00Z",
+		"previously_exported_at": "",
+	}
+	for field, value := range want {
+		if row[field] != value {
+			t.Errorf("Expected %s to be %q, got %q", field, value, row[field])
+		}
+	}
+}
+
+func TestExportDealsMarksIncrementalExports(t *testing.T) {
+	server := setupTestServer()
+	db := server.db.(*MockDatabase)
+	dealershipID := uuid.New().String()
+	first := addExportDeal(db, dealershipID, "funded", time.Now().Add(-48*time.Hour))
+
+	_, records := exportDealsCSV(t, server, "dealership_id="+dealershipID+"&incremental=true")
+	if len(records) != 2 || records[1][0] != first.ID {
+		t.Fatalf("Expected the first incremental export to include the deal, got %v", records)
+	}
+	if _, ok := db.exported[first.ID]; !ok {
+		t.Fatal("Expected the exported deal to be marked")
+	}
+
+	second := addExportDeal(db, dealershipID, "funded", time.Now().Add(-time.Hour))
+	_, records = exportDealsCSV(t, server, "dealership_id="+dealershipID+"&incremental=true")
+	if len(records) != 2 || records[1][0] != second.ID {
+		t.Fatalf("Expected the second incremental export to include only the new deal, got %v", records)
+	}
+
+	_, records = exportDealsCSV(t, server, "dealership_id="+dealershipID+"&incremental=true")
+	if len(records) != 1 {
+		t.Errorf("Expected an incremental export with nothing new to have only a header, got %d rows", len(records))
+	}
+
+	// A full export still includes marked deals and reports when they were
+	// last exported
+	_, records = exportDealsCSV(t, server, "dealership_id="+dealershipID)
+	if len(records) != 3 {
+		t.Fatalf("Expected a full export to include both deals, got %d rows", len(records))
+	}
+	last := len(accountingExportFields) - 1
+	for _, record := range records[1:] {
+		if record[last] == "" {
+			t.Errorf("Expected deal %s to report its previous export", record[0])
+		}
+	}
+}
+
+func TestExportDealsAccountingMapping(t *testing.T) {
+	server := setupTestServer()
+	db := server.db.(*MockDatabase)
+	dealershipID := uuid.New().String()
+	deal := addExportDeal(db, dealershipID, "funded", time.Date(2024, 3, 15, 14, 30, 0, 0, time.UTC))
+
+	rr, _ := exportDealsCSV(t, server, "dealership_id="+dealershipID+"&format=accounting")
+	if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), "ACCOUNTING_NOT_CONFIGURED") {
+		t.Fatalf("Expected 409 without an accounting integration, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(db.exported) != 0 {
+		t.Error("Expected a rejected export not to mark deals")
+	}
+
+	db.accounting[dealershipID] = &AccountingMapping{
+		Columns: []AccountingColumn{
+			{Field: "deal_id", Header: "DocNumber"},
+			{Field: "funded_at", Header: "TxnDate"},
+			{Field: "total_amount", Header: "Amount"},
+		},
+		DateFormat: "01/02/2006",
+	}
+	rr, records := exportDealsCSV(t, server, "dealership_id="+dealershipID+"&format=accounting")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected a header and one deal, got %v", records)
+	}
+	if got := strings.Join(records[0], ","); got != "DocNumber,TxnDate,Amount" {
+		t.Errorf("Expected mapped headers, got %s", got)
+	}
+	if got := strings.Join(records[1], ","); got != deal.ID+",03/15/2024,32299.50" {
+		t.Errorf("Expected mapped values, got %s", got)
+	}
+
+	db.accounting[dealershipID].Columns[0].Field = "unknown"
+	rr, _ = exportDealsCSV(t, server, "dealership_id="+dealershipID+"&format=accounting")
+	if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), "ACCOUNTING_MAPPING_INVALID") {
+		t.Errorf("Expected 409 for an invalid mapping, got %d", rr.Code)
+	}
+}
+
+func TestExportDealsValidation(t *testing.T) {
+	server := setupTestServer()
+	dealershipID := uuid.New().String()
+
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{"missing dealership", "from=2024-01-01", http.StatusBadRequest},
+		{"bad status", "dealership_id=" + dealershipID + "&status=sold", http.StatusBadRequest},
+		{"bad date", "dealership_id=" + dealershipID + "&from=01/02/2024", http.StatusBadRequest},
+		{"reversed range", "dealership_id=" + dealershipID + "&from=2024-02-01&to=2024-01-01", http.StatusBadRequest},
+		{"bad format", "dealership_id=" + dealershipID + "&format=xml", http.StatusBadRequest},
+		{"single day", "dealership_id=" + dealershipID + "&from=2024-01-01&to=2024-01-01", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr, _ := exportDealsCSV(t, server, tt.query)
+			if rr.Code != tt.want {
+				t.Errorf("Expected status %d, got %d: %s", tt.want, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
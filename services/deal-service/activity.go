@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/logging"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Deal activity event types
+const (
+	ActivityStatusChange     = "status_change"
+	ActivityNote             = "note"
+	ActivityApproval         = "approval"
+	ActivityDocumentUploaded = "document_uploaded"
+	ActivityDocumentDeleted  = "document_deleted"
+)
+
+var validActivityTypes = map[string]bool{
+	ActivityStatusChange:     true,
+	ActivityNote:             true,
+	ActivityApproval:         true,
+	ActivityDocumentUploaded: true,
+	ActivityDocumentDeleted:  true,
+}
+
+// historyFieldDocumentDeleted is the deal history field recording a deleted
+// document, whose metadata is otherwise removed
+const historyFieldDocumentDeleted = "document_deleted"
+
+// maxDealNoteLength is the longest note body accepted
+const maxDealNoteLength = 5000
+
+// DealNote is a free-text note left on a deal
+type DealNote struct {
+	ID        string    `json:"id"`
+	DealID    string    `json:"deal_id"`
+	Body      string    `json:"body"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateDealNoteRequest represents a request to add a note to a deal
+type CreateDealNoteRequest struct {
+	Body string `json:"body"`
+}
+
+// DealActivity is one event in a deal's activity feed
+type DealActivity struct {
+	Type       string                 `json:"type"`
+	Actor      string                 `json:"actor,omitempty"`
+	OccurredAt time.Time              `json:"occurred_at"`
+	Details    map[string]interface{} `json:"details"`
+}
+
+// createDealNote adds a note to a deal
+func (s *Server) createDealNote(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if !validateUUID(w, id, "id") {
+		return
+	}
+
+	var req CreateDealNoteRequest
+	if !decodeAndValidate(r, w, &req) {
+		return
+	}
+
+	deal, err := s.db.GetDeal(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get deal")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get deal: %v", err))
+		return
+	}
+	if deal == nil {
+		apierror.WriteError(w, http.StatusNotFound, "DEAL_NOT_FOUND", "Deal not found")
+		return
+	}
+
+	note := &DealNote{
+		ID:        uuid.New().String(),
+		DealID:    deal.ID,
+		Body:      req.Body,
+		CreatedBy: logging.GetUserID(r.Context()),
+		CreatedAt: time.Now(),
+	}
+	if err := s.db.CreateDealNote(note); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to create deal note")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to create deal note: %v", err))
+		return
+	}
+
+	s.logger.WithContext(r.Context()).WithField("deal_id", deal.ID).WithField("note_id", note.ID).Info("Deal note added")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(note)
+}
+
+// recordDocumentDeleted keeps a deal history entry for a deleted document,
+// holding its metadata so the upload stays in the activity feed. History
+// failures are logged but do not fail the delete.
+func (s *Server) recordDocumentDeleted(r *http.Request, doc *DealDocument) {
+	metadata, err := json.Marshal(doc)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).WithField("deal_id", doc.DealID).Warn("Failed to record deal history")
+		return
+	}
+
+	entry := &DealHistoryEntry{
+		ID:        uuid.New().String(),
+		DealID:    doc.DealID,
+		Field:     historyFieldDocumentDeleted,
+		OldValue:  string(metadata),
+		ChangedBy: logging.GetUserID(r.Context()),
+		ChangedAt: time.Now(),
+	}
+	if err := s.db.CreateDealHistory(entry); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).WithField("deal_id", doc.DealID).Warn("Failed to record deal history")
+	}
+}
+
+// parseActivityTypes reads the comma-separated type filter. A nil result
+// means all types.
+func parseActivityTypes(w http.ResponseWriter, value string) (map[string]bool, bool) {
+	if value == "" {
+		return nil, true
+	}
+
+	types := make(map[string]bool)
+	for _, t := range strings.Split(value, ",") {
+		t = strings.TrimSpace(t)
+		if !validActivityTypes[t] {
+			respondValidationError(w, &ValidationErrors{
+				Errors: []ValidationError{{
+					Field:   "type",
+					Message: fmt.Sprintf("Unknown activity type %q", t),
+				}},
+			})
+			return nil, false
+		}
+		types[t] = true
+	}
+	return types, true
+}
+
+// statusAndApprovalActivity derives status transitions and approvals from a
+// deal's version snapshots, which record every change in order
+func statusAndApprovalActivity(versions []*DealVersion) []DealActivity {
+	var events []DealActivity
+	previous := ""
+	for _, v := range versions {
+		if v.Snapshot.Status != previous {
+			details := map[string]interface{}{"to": v.Snapshot.Status, "version": v.Version}
+			if previous != "" {
+				details["from"] = previous
+			}
+			events = append(events, DealActivity{
+				Type:       ActivityStatusChange,
+				Actor:      v.CreatedBy,
+				OccurredAt: v.CreatedAt,
+				Details:    details,
+			})
+			previous = v.Snapshot.Status
+		}
+
+		if v.ChangeType == VersionChangeApprove {
+			events = append(events, DealActivity{
+				Type:       ActivityApproval,
+				Actor:      v.CreatedBy,
+				OccurredAt: v.CreatedAt,
+				Details:    map[string]interface{}{"version": v.Version},
+			})
+		}
+	}
+	return events
+}
+
+// documentActivityDetails describes a document in the activity feed
+func documentActivityDetails(doc *DealDocument) map[string]interface{} {
+	return map[string]interface{}{
+		"document_id":   doc.ID,
+		"document_type": doc.DocumentType,
+		"filename":      doc.Filename,
+	}
+}
+
+// getDealActivity returns a deal's status transitions, notes, approvals and
+// document events, oldest first. The type query parameter takes a
+// comma-separated list of event types to include.
+func (s *Server) getDealActivity(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if !validateUUID(w, id, "id") {
+		return
+	}
+
+	types, ok := parseActivityTypes(w, r.URL.Query().Get("type"))
+	if !ok {
+		return
+	}
+
+	deal, err := s.db.GetDeal(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get deal")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get deal: %v", err))
+		return
+	}
+	if deal == nil {
+		apierror.WriteError(w, http.StatusNotFound, "DEAL_NOT_FOUND", "Deal not found")
+		return
+	}
+
+	versions, err := s.db.ListDealVersions(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list deal versions")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to list deal activity: %v", err))
+		return
+	}
+	notes, err := s.db.ListDealNotes(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list deal notes")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to list deal activity: %v", err))
+		return
+	}
+	documents, err := s.db.ListDealDocuments(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list deal documents")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to list deal activity: %v", err))
+		return
+	}
+	history, err := s.db.ListDealHistory(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list deal history")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to list deal activity: %v", err))
+		return
+	}
+
+	events := statusAndApprovalActivity(versions)
+	for _, note := range notes {
+		events = append(events, DealActivity{
+			Type:       ActivityNote,
+			Actor:      note.CreatedBy,
+			OccurredAt: note.CreatedAt,
+			Details:    map[string]interface{}{"note_id": note.ID, "body": note.Body},
+		})
+	}
+	for _, entry := range history {
+		if entry.Field != historyFieldDocumentDeleted {
+			continue
+		}
+		var doc DealDocument
+		if err := json.Unmarshal([]byte(entry.OldValue), &doc); err != nil {
+			s.logger.WithContext(r.Context()).WithError(err).WithField("history_id", entry.ID).Warn("Skipping unreadable document history")
+			continue
+		}
+		documents = append(documents, &doc)
+		events = append(events, DealActivity{
+			Type:       ActivityDocumentDeleted,
+			Actor:      entry.ChangedBy,
+			OccurredAt: entry.ChangedAt,
+			Details:    documentActivityDetails(&doc),
+		})
+	}
+	for _, doc := range documents {
+		events = append(events, DealActivity{
+			Type:       ActivityDocumentUploaded,
+			Actor:      doc.UploadedBy,
+			OccurredAt: doc.CreatedAt,
+			Details:    documentActivityDetails(doc),
+		})
+	}
+
+	filtered := make([]DealActivity, 0, len(events))
+	for _, e := range events {
+		if types == nil || types[e.Type] {
+			filtered = append(filtered, e)
+		}
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return filtered[i].OccurredAt.Before(filtered[j].OccurredAt)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(filtered)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func addDealNote(t *testing.T, server *Server, dealID, body string) {
+	t.Helper()
+	rr := sendDealRequest(t, server, "POST", "/deals/"+dealID+"/notes", CreateDealNoteRequest{Body: body}, "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func getDealActivity(t *testing.T, server *Server, dealID, query string) []DealActivity {
+	t.Helper()
+	rr := sendDealRequest(t, server, "GET", "/deals/"+dealID+"/activity"+query, nil, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var events []DealActivity
+	json.Unmarshal(rr.Body.Bytes(), &events)
+	return events
+}
+
+func TestDealActivityAggregatesEventsInOrder(t *testing.T) {
+	server, dealershipID, vehicleID := setupGuardrailServer()
+	store, err := NewLocalDocumentStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create document store: %v", err)
+	}
+	server.documents = store
+
+	rr := sendDealRequest(t, server, "POST", "/deals", CreateDealRequest{
+		DealershipID: dealershipID,
+		CustomerID:   uuid.New().String(),
+		VehicleID:    vehicleID,
+		VehiclePrice: 25000,
+	}, "")
+	var deal Deal
+	json.Unmarshal(rr.Body.Bytes(), &deal)
+
+	addDealNote(t, server, deal.ID, "Customer wants a lower payment")
+	if rr := sendDealRequest(t, server, "POST", "/deals/"+deal.ID+"/approve", nil, "deals:manage"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected approval, got %d: %s", rr.Code, rr.Body.String())
+	}
+	doc := uploadDocument(t, server, deal.ID, "title", testPDF)
+	addDealNote(t, server, deal.ID, "Title received")
+
+	req := httptest.NewRequest("DELETE", "/deals/"+deal.ID+"/documents/"+doc.ID, nil)
+	req.Header.Set("X-User-ID", "manager-1")
+	server.router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if rr := sendDealRequest(t, server, "PUT", "/deals/"+deal.ID, UpdateDealRequest{Status: "funded"}, ""); rr.Code != http.StatusOK {
+		t.Fatalf("Expected funding, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	events := getDealActivity(t, server, deal.ID, "")
+
+	want := []string{
+		ActivityStatusChange, // draft
+		ActivityNote,
+		ActivityStatusChange, // approved
+		ActivityApproval,
+		ActivityDocumentUploaded,
+		ActivityNote,
+		ActivityDocumentDeleted,
+		ActivityStatusChange, // funded
+	}
+	var got []string
+	for _, e := range events {
+		got = append(got, e.Type)
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("Expected events %v, got %v", want, got)
+	}
+
+	for i := 1; i < len(events); i++ {
+		if events[i].OccurredAt.Before(events[i-1].OccurredAt) {
+			t.Errorf("Event %d is out of order", i)
+		}
+	}
+	for _, e := range events {
+		// The upload helper sends no user
+		if e.Type != ActivityDocumentUploaded && e.Actor != "manager-1" {
+			t.Errorf("Expected %s event actor manager-1, got %q", e.Type, e.Actor)
+		}
+	}
+	if last := events[len(events)-1]; last.Details["from"] != "approved" || last.Details["to"] != "funded" {
+		t.Errorf("Expected approved -> funded, got %+v", last.Details)
+	}
+
+	// Filtering by type
+	filtered := getDealActivity(t, server, deal.ID, "?type=note,approval")
+	if len(filtered) != 3 || filtered[0].Type != ActivityNote || filtered[1].Type != ActivityApproval {
+		t.Errorf("Expected notes and approval only, got %+v", filtered)
+	}
+	if filtered[0].Details["body"] != "Customer wants a lower payment" {
+		t.Errorf("Expected note body in details, got %+v", filtered[0].Details)
+	}
+}
+
+func TestDealActivityValidation(t *testing.T) {
+	server := setupTestServer()
+	deal := createVersionedDeal(t, server)
+
+	rr := sendDealRequest(t, server, "GET", "/deals/"+deal.ID+"/activity?type=bogus", nil, "")
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown type, got %d", rr.Code)
+	}
+
+	rr = sendDealRequest(t, server, "POST", "/deals/"+deal.ID+"/notes", CreateDealNoteRequest{Body: "   "}, "")
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an empty note, got %d", rr.Code)
+	}
+
+	rr = sendDealRequest(t, server, "POST", "/deals/"+uuid.New().String()+"/notes", CreateDealNoteRequest{Body: "Hello"}, "")
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown deal, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"autolytiq/shared/logging"
+)
+
+// Audit queue defaults
+const (
+	defaultAuditQueueSize = 1000
+	defaultAuditWorkers   = 2
+)
+
+// Audit actions recorded for deals
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// auditEntityDeal is the entity type deal audit events are recorded under
+const auditEntityDeal = "deal"
+
+// AuditEvent is a change to record in data-retention-service's audit log.
+// It matches the retention service's AuditLog body.
+type AuditEvent struct {
+	DealershipID string                 `json:"dealership_id,omitempty"`
+	EntityType   string                 `json:"entity_type"`
+	EntityID     string                 `json:"entity_id"`
+	Action       string                 `json:"action"`
+	PerformedBy  string                 `json:"performed_by"`
+	IPAddress    string                 `json:"ip_address,omitempty"`
+	OldData      interface{}            `json:"old_data,omitempty"`
+	NewData      interface{}            `json:"new_data,omitempty"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+
+	// requestID is sent as the request ID header so the audit write can be
+	// traced back to the change
+	requestID string
+}
+
+// AuditSink records audit events. Record must not block the caller.
+type AuditSink interface {
+	Record(event AuditEvent)
+}
+
+// AuditClient posts audit events to data-retention-service from a bounded
+// queue drained by background workers, so audit latency never slows deal
+// writes. Events are dropped, with a warning, when the queue is full, and
+// logged when the retention service can't be reached.
+type AuditClient struct {
+	baseURL    string
+	httpClient *http.Client
+	logger     *logging.Logger
+
+	queue chan AuditEvent
+	wg    sync.WaitGroup
+}
+
+// NewAuditClient creates a client posting to the retention service at
+// baseURL and starts its workers
+func NewAuditClient(baseURL string, queueSize, workers int, logger *logging.Logger) *AuditClient {
+	if queueSize <= 0 {
+		queueSize = defaultAuditQueueSize
+	}
+	if workers <= 0 {
+		workers = defaultAuditWorkers
+	}
+
+	c := &AuditClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		logger:     logger,
+		queue:      make(chan AuditEvent, queueSize),
+	}
+	for i := 0; i < workers; i++ {
+		c.wg.Add(1)
+		go c.work()
+	}
+	return c
+}
+
+// Record queues an event, dropping it if the queue is full
+func (c *AuditClient) Record(event AuditEvent) {
+	select {
+	case c.queue <- event:
+	default:
+		c.logger.WithFields(map[string]interface{}{
+			"entity_type": event.EntityType,
+			"entity_id":   event.EntityID,
+			"action":      event.Action,
+		}).Warn("Audit queue full, dropping audit event")
+	}
+}
+
+// Close stops accepting events and waits for queued ones to be sent
+func (c *AuditClient) Close() {
+	close(c.queue)
+	c.wg.Wait()
+}
+
+func (c *AuditClient) work() {
+	defer c.wg.Done()
+	for event := range c.queue {
+		if err := c.send(event); err != nil {
+			c.logger.WithError(err).WithFields(map[string]interface{}{
+				"entity_type": event.EntityType,
+				"entity_id":   event.EntityID,
+				"action":      event.Action,
+			}).Warn("Failed to record audit event")
+		}
+	}
+}
+
+// send posts one event to the retention service's audit endpoint
+func (c *AuditClient) send(event AuditEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.httpClient.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/audit/logs", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build audit request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if event.requestID != "" {
+		req.Header.Set(logging.RequestIDHeader, event.requestID)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("audit service unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("audit service returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// auditDeal records a change to a deal. before is nil for a create and
+// after is nil for a delete.
+func (s *Server) auditDeal(r *http.Request, action string, before, after *Deal) {
+	if s.audit == nil {
+		return
+	}
+
+	event := AuditEvent{
+		EntityType:  auditEntityDeal,
+		Action:      action,
+		PerformedBy: logging.GetUserID(r.Context()),
+		IPAddress:   clientIP(r),
+		requestID:   logging.GetTraceID(r.Context()),
+	}
+	if before != nil {
+		snapshot := *before
+		event.OldData = &snapshot
+		event.EntityID = before.ID
+		event.DealershipID = before.DealershipID
+	}
+	if after != nil {
+		snapshot := *after
+		event.NewData = &snapshot
+		event.EntityID = after.ID
+		event.DealershipID = after.DealershipID
+	}
+	s.audit.Record(event)
+}
+
+// clientIP returns the address of the client that made the request, as
+// forwarded by the gateway when present
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
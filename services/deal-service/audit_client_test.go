@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// recordingAuditSink keeps the events recorded by handlers
+type recordingAuditSink struct {
+	mu     sync.Mutex
+	events []AuditEvent
+}
+
+func (s *recordingAuditSink) Record(event AuditEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+}
+
+func TestDealChangesAreAudited(t *testing.T) {
+	server := setupTestServer()
+	sink := &recordingAuditSink{}
+	server.audit = sink
+	dealershipID := uuid.New().String()
+
+	rr := sendDealRequest(t, server, "POST", "/deals", CreateDealRequest{
+		DealershipID: dealershipID,
+		CustomerID:   uuid.New().String(),
+		VehiclePrice: 25000,
+	}, "")
+	var deal Deal
+	json.Unmarshal(rr.Body.Bytes(), &deal)
+	sendDealRequest(t, server, "PUT", "/deals/"+deal.ID, UpdateDealRequest{VehiclePrice: 26000}, "")
+	sendDealRequest(t, server, "DELETE", "/deals/"+deal.ID, nil, "")
+
+	if len(sink.events) != 3 {
+		t.Fatalf("Expected 3 audit events, got %+v", sink.events)
+	}
+	for i, action := range []string{AuditActionCreate, AuditActionUpdate, AuditActionDelete} {
+		event := sink.events[i]
+		if event.Action != action || event.EntityType != "deal" || event.EntityID != deal.ID ||
+			event.DealershipID != dealershipID || event.PerformedBy != "manager-1" {
+			t.Errorf("Event %d: expected a %s of the deal by manager-1, got %+v", i, action, event)
+		}
+	}
+
+	if sink.events[0].OldData != nil || sink.events[2].NewData != nil {
+		t.Error("Expected no old data on create and no new data on delete")
+	}
+	update := sink.events[1]
+	if update.OldData.(*Deal).VehiclePrice != 25000 || update.NewData.(*Deal).VehiclePrice != 26000 {
+		t.Errorf("Expected the update's old and new deal, got %+v and %+v", update.OldData, update.NewData)
+	}
+}
+
+func TestAuditClientPostsEvents(t *testing.T) {
+	received := make(chan map[string]interface{}, 1)
+	var requestID string
+	retention := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/audit/logs" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		requestID = r.Header.Get("X-Request-ID")
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusCreated)
+		received <- body
+	}))
+	defer retention.Close()
+
+	client := NewAuditClient(retention.URL, 10, 1, testLogger())
+	client.Record(AuditEvent{
+		EntityType:  auditEntityDeal,
+		EntityID:    "deal-1",
+		Action:      AuditActionUpdate,
+		PerformedBy: "user-1",
+		NewData:     &Deal{ID: "deal-1", Status: "pending"},
+		requestID:   "req-1",
+	})
+	client.Close()
+
+	body := <-received
+	if body["entity_id"] != "deal-1" || body["action"] != "update" || body["performed_by"] != "user-1" {
+		t.Errorf("Unexpected audit body: %+v", body)
+	}
+	if newData, ok := body["new_data"].(map[string]interface{}); !ok || newData["status"] != "pending" {
+		t.Errorf("Expected the new deal as an object, got %+v", body["new_data"])
+	}
+	if _, ok := body["old_data"]; ok {
+		t.Errorf("Expected no old data, got %+v", body["old_data"])
+	}
+	if requestID != "req-1" {
+		t.Errorf("Expected the request ID to be forwarded, got %q", requestID)
+	}
+}
+
+func TestAuditClientDoesNotBlock(t *testing.T) {
+	// Nothing listens here, and the queue holds a single event
+	client := NewAuditClient("http://127.0.0.1:1", 1, 1, testLogger())
+	for i := 0; i < 100; i++ {
+		client.Record(AuditEvent{EntityType: auditEntityDeal, EntityID: "deal-1", Action: AuditActionCreate})
+	}
+	client.Close()
+}
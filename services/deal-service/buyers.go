@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"autolytiq/shared/apierror"
+
+	"github.com/gorilla/mux"
+)
+
+// Applicant roles on a deal
+const (
+	ApplicantRoleBuyer   = "buyer"
+	ApplicantRoleCoBuyer = "co_buyer"
+)
+
+// FinancingApplicant is one buyer's credit and income on a deal
+type FinancingApplicant struct {
+	CustomerID    string  `json:"customer_id"`
+	Role          string  `json:"role"`
+	Name          string  `json:"name"`
+	CreditScore   int     `json:"credit_score,omitempty"`
+	MonthlyIncome float64 `json:"monthly_income"`
+}
+
+// DealFinancing aggregates the buyer and co-buyer for financing. Lenders
+// qualify joint applications on combined income and the lower credit score.
+type DealFinancing struct {
+	DealID                string               `json:"deal_id"`
+	Applicants            []FinancingApplicant `json:"applicants"`
+	CombinedMonthlyIncome float64              `json:"combined_monthly_income"`
+	QualifyingCreditScore int                  `json:"qualifying_credit_score,omitempty"`
+	AmountFinanced        float64              `json:"amount_financed"`
+	MonthlyPayment        float64              `json:"monthly_payment"`
+
+	// PaymentToIncome is the monthly payment as a percentage of combined
+	// income, omitted when no income is on file
+	PaymentToIncome float64 `json:"payment_to_income,omitempty"`
+}
+
+// fullName returns the customer's display name
+func (c *DealCustomer) fullName() string {
+	return strings.TrimSpace(c.FirstName + " " + c.LastName)
+}
+
+// validateDealCustomers checks that the deal's buyer and co-buyer exist and
+// belong to the deal's dealership. Lookups are skipped when customer-service
+// is not configured.
+func (s *Server) validateDealCustomers(r *http.Request, deal *Deal) (*ValidationErrors, error) {
+	var errors []ValidationError
+
+	if deal.CoCustomerID != "" {
+		if deal.CustomerID == "" {
+			errors = append(errors, ValidationError{
+				Field:   "co_customer_id",
+				Message: "A co-buyer requires a customer",
+			})
+		} else if strings.EqualFold(deal.CoCustomerID, deal.CustomerID) {
+			errors = append(errors, ValidationError{
+				Field:   "co_customer_id",
+				Message: "Co-buyer must be a different customer",
+			})
+		}
+	}
+
+	if len(errors) == 0 && s.customers != nil {
+		for _, c := range []struct{ field, id string }{
+			{"customer_id", deal.CustomerID},
+			{"co_customer_id", deal.CoCustomerID},
+		} {
+			if c.id == "" {
+				continue
+			}
+			customer, err := s.customers.GetCustomer(r, c.id)
+			if err != nil {
+				return nil, err
+			}
+			if customer == nil {
+				errors = append(errors, ValidationError{Field: c.field, Message: "Customer not found"})
+			} else if customer.DealershipID != deal.DealershipID {
+				errors = append(errors, ValidationError{Field: c.field, Message: "Customer does not belong to the dealership"})
+			}
+		}
+	}
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}, nil
+	}
+	return nil, nil
+}
+
+// checkDealCustomers validates the deal's customers, responding on failure
+func (s *Server) checkDealCustomers(w http.ResponseWriter, r *http.Request, deal *Deal) bool {
+	errs, err := s.validateDealCustomers(r, deal)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to look up deal customers")
+		apierror.WriteError(w, http.StatusBadGateway, "CUSTOMER_SERVICE_ERROR", fmt.Sprintf("Failed to look up deal customers: %v", err))
+		return false
+	}
+	if errs != nil {
+		respondValidationError(w, errs)
+		return false
+	}
+	return true
+}
+
+// loadDealCustomers fetches the deal and its buyer and co-buyer, responding
+// on failure. The co-buyer is nil when the deal has none.
+func (s *Server) loadDealCustomers(w http.ResponseWriter, r *http.Request) (*Deal, *DealCustomer, *DealCustomer, bool) {
+	if s.customers == nil {
+		respondErrorJSON(w, http.StatusServiceUnavailable, "Customer lookup is not configured", "CUSTOMERS_UNAVAILABLE")
+		return nil, nil, nil, false
+	}
+	return s.loadDealCustomersWith(w, r, s.customers.GetCustomer)
+}
+
+// loadDealCustomersWith is loadDealCustomers with the given customer lookup
+func (s *Server) loadDealCustomersWith(w http.ResponseWriter, r *http.Request, lookup func(*http.Request, string) (*DealCustomer, error)) (*Deal, *DealCustomer, *DealCustomer, bool) {
+	id := mux.Vars(r)["id"]
+	if !validateUUID(w, id, "id") {
+		return nil, nil, nil, false
+	}
+
+	deal, err := s.db.GetDeal(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get deal")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get deal: %v", err))
+		return nil, nil, nil, false
+	}
+	if deal == nil {
+		apierror.WriteError(w, http.StatusNotFound, "DEAL_NOT_FOUND", "Deal not found")
+		return nil, nil, nil, false
+	}
+	if deal.CustomerID == "" {
+		respondErrorJSON(w, http.StatusConflict, "Deal has no customer", "NO_CUSTOMER")
+		return nil, nil, nil, false
+	}
+
+	var customers [2]*DealCustomer
+	for i, customerID := range []string{deal.CustomerID, deal.CoCustomerID} {
+		if customerID == "" {
+			continue
+		}
+		customer, err := lookup(r, customerID)
+		if err != nil {
+			s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get deal customer")
+			apierror.WriteError(w, http.StatusBadGateway, "CUSTOMER_SERVICE_ERROR", fmt.Sprintf("Failed to get deal customer: %v", err))
+			return nil, nil, nil, false
+		}
+		if customer == nil {
+			apierror.WriteError(w, http.StatusNotFound, "DEAL_CUSTOMER_NOT_FOUND", "Deal customer not found")
+			return nil, nil, nil, false
+		}
+		customers[i] = customer
+	}
+
+	return deal, customers[0], customers[1], true
+}
+
+// getDealFinancing aggregates credit and income across the deal's buyers
+func (s *Server) getDealFinancing(w http.ResponseWriter, r *http.Request) {
+	deal, buyer, coBuyer, ok := s.loadDealCustomers(w, r)
+	if !ok {
+		return
+	}
+
+	policy := s.roundingPolicy(r, deal.DealershipID)
+	financing := DealFinancing{
+		DealID:         deal.ID,
+		AmountFinanced: deal.amountFinanced(policy),
+		MonthlyPayment: deal.monthlyPaymentAmount(policy),
+	}
+
+	applicants := []struct {
+		customer *DealCustomer
+		role     string
+	}{{buyer, ApplicantRoleBuyer}, {coBuyer, ApplicantRoleCoBuyer}}
+	for _, a := range applicants {
+		if a.customer == nil {
+			continue
+		}
+		financing.Applicants = append(financing.Applicants, FinancingApplicant{
+			CustomerID:    a.customer.ID,
+			Role:          a.role,
+			Name:          a.customer.fullName(),
+			CreditScore:   a.customer.CreditScore,
+			MonthlyIncome: a.customer.MonthlyIncome,
+		})
+		financing.CombinedMonthlyIncome += a.customer.MonthlyIncome
+		if score := a.customer.CreditScore; score > 0 && (financing.QualifyingCreditScore == 0 || score < financing.QualifyingCreditScore) {
+			financing.QualifyingCreditScore = score
+		}
+	}
+	financing.CombinedMonthlyIncome = roundCents(financing.CombinedMonthlyIncome)
+
+	if financing.CombinedMonthlyIncome > 0 {
+		financing.PaymentToIncome = roundCents(financing.MonthlyPayment / financing.CombinedMonthlyIncome * 100)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(financing)
+}
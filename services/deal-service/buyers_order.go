@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"autolytiq/shared/apierror"
+)
+
+// buyersOrderTemplate renders a deal's buyer's order. Values are escaped by
+// html/template.
+var buyersOrderTemplate = template.Must(template.New("buyers_order").Funcs(template.FuncMap{
+	"money":   func(amount float64) string { return fmt.Sprintf("$%.2f", amount) },
+	"product": func(productType string) string { return productTypeLabels[productType] },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Buyer's Order {{.Deal.ID}}</title></head>
+<body>
+<h1>Buyer's Order</h1>
+<p>Deal {{.Deal.ID}} &middot; {{.GeneratedAt.Format "January 2, 2006"}}</p>
+{{range .Buyers}}
+<section class="buyer">
+<h2>{{.Label}}</h2>
+<p>{{.Customer.FirstName}} {{.Customer.LastName}}<br>
+{{.Customer.Address}}<br>
+{{.Customer.City}}, {{.Customer.State}} {{.Customer.ZipCode}}<br>
+{{.Customer.Phone}} {{.Customer.Email}}</p>
+</section>
+{{end}}
+<table>
+<tr><td>Vehicle price</td><td>{{money .Deal.VehiclePrice}}</td></tr>
+<tr><td>Trade-in allowance</td><td>{{money .Deal.TradeInValue}}</td></tr>
+<tr><td>Trade-in payoff</td><td>{{money .Deal.TradeInPayoff}}</td></tr>
+<tr><td>Down payment</td><td>{{money .Deal.DownPayment}}</td></tr>
+<tr><td>Tax</td><td>{{money .Deal.TaxAmount}}</td></tr>
+{{range .Deal.Products}}<tr class="product"><td>{{product .Type}} &middot; {{.Provider}} &middot; {{.TermMonths}} months</td><td>{{money .Price}}</td></tr>
+{{end}}<tr><td>Amount financed</td><td>{{money .AmountFinanced}}</td></tr>
+{{if .Deal.TermMonths}}<tr><td>Term</td><td>{{.Deal.TermMonths}} months at {{.Deal.SellRate}}% APR</td></tr>{{end}}
+</table>
+{{range .Buyers}}
+<p class="signature">{{.Label}} signature: ______________________ {{.Customer.FirstName}} {{.Customer.LastName}}</p>
+{{end}}
+</body>
+</html>
+`))
+
+// buyersOrderParty is a buyer listed on the buyer's order
+type buyersOrderParty struct {
+	Label    string
+	Customer *DealCustomer
+}
+
+// getBuyersOrder generates the deal's buyer's order, listing the co-buyer
+// alongside the buyer when the deal has one
+func (s *Server) getBuyersOrder(w http.ResponseWriter, r *http.Request) {
+	deal, buyer, coBuyer, ok := s.loadDealCustomers(w, r)
+	if !ok {
+		return
+	}
+
+	order, err := s.renderBuyersOrder(r, deal, buyer, coBuyer)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to render buyer's order")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to render buyer's order: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(order)
+}
+
+// renderBuyersOrder renders the deal's buyer's order as HTML. coBuyer may be
+// nil.
+func (s *Server) renderBuyersOrder(r *http.Request, deal *Deal, buyer, coBuyer *DealCustomer) ([]byte, error) {
+	buyers := []buyersOrderParty{{Label: "Buyer", Customer: buyer}}
+	if coBuyer != nil {
+		buyers = append(buyers, buyersOrderParty{Label: "Co-Buyer", Customer: coBuyer})
+	}
+
+	var buf bytes.Buffer
+	err := buyersOrderTemplate.Execute(&buf, map[string]interface{}{
+		"Deal":           deal,
+		"Buyers":         buyers,
+		"AmountFinanced": deal.amountFinanced(s.roundingPolicy(r, deal.DealershipID)),
+		"GeneratedAt":    time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// stubCustomers serves fixed customers keyed by ID
+type stubCustomers struct {
+	customers map[string]*DealCustomer
+}
+
+func (c *stubCustomers) GetCustomer(r *http.Request, customerID string) (*DealCustomer, error) {
+	return c.customers[customerID], nil
+}
+
+// setupBuyersServer returns a server with a buyer and co-buyer in one
+// dealership and a customer of another dealership
+func setupBuyersServer() (server *Server, dealershipID, buyerID, coBuyerID, otherID string) {
+	dealershipID = uuid.New().String()
+	buyerID = uuid.New().String()
+	coBuyerID = uuid.New().String()
+	otherID = uuid.New().String()
+
+	server = setupTestServer()
+	server.customers = &stubCustomers{customers: map[string]*DealCustomer{
+		buyerID: {ID: buyerID, DealershipID: dealershipID, FirstName: "Jordan", LastName: "Reyes",
+			Address: "12 Elm St", City: "Carmel", State: "IN", ZipCode: "46032", CreditScore: 740, MonthlyIncome: 5200},
+		coBuyerID: {ID: coBuyerID, DealershipID: dealershipID, FirstName: "Sam", LastName: "Reyes",
+			Address: "12 Elm St", City: "Carmel", State: "IN", ZipCode: "46032", CreditScore: 680, MonthlyIncome: 3800},
+		otherID: {ID: otherID, DealershipID: uuid.New().String(), FirstName: "Other", LastName: "Dealer"},
+	}}
+	return server, dealershipID, buyerID, coBuyerID, otherID
+}
+
+func createCoBuyerDeal(t *testing.T, server *Server, dealershipID, buyerID, coBuyerID string) Deal {
+	t.Helper()
+
+	rr := sendDealRequest(t, server, "POST", "/deals", CreateDealRequest{
+		DealershipID: dealershipID,
+		CustomerID:   buyerID,
+		CoCustomerID: coBuyerID,
+		VehiclePrice: 32000,
+		DownPayment:  2000,
+		TaxAmount:    2100,
+		TermMonths:   60,
+		SellRate:     6.9,
+	}, "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create deal returned %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var deal Deal
+	if err := json.Unmarshal(rr.Body.Bytes(), &deal); err != nil {
+		t.Fatal(err)
+	}
+	return deal
+}
+
+func TestCreateDealWithCoBuyer(t *testing.T) {
+	server, dealershipID, buyerID, coBuyerID, _ := setupBuyersServer()
+
+	deal := createCoBuyerDeal(t, server, dealershipID, buyerID, coBuyerID)
+	if deal.CustomerID != buyerID || deal.CoCustomerID != coBuyerID {
+		t.Errorf("Expected buyer %s and co-buyer %s, got %s and %s", buyerID, coBuyerID, deal.CustomerID, deal.CoCustomerID)
+	}
+
+	// The deal appears in both customers' deal lists
+	for _, customerID := range []string{buyerID, coBuyerID} {
+		rr := sendDealRequest(t, server, "GET", "/deals?customer_id="+customerID, nil, "")
+		var deals []Deal
+		if err := json.Unmarshal(rr.Body.Bytes(), &deals); err != nil {
+			t.Fatal(err)
+		}
+		if len(deals) != 1 || deals[0].ID != deal.ID {
+			t.Errorf("Expected the deal in customer %s's deals, got %+v", customerID, deals)
+		}
+	}
+
+	// Removing the co-buyer drops the deal from their list
+	rr := sendDealRequest(t, server, "PUT", "/deals/"+deal.ID, map[string]interface{}{"remove_co_customer": true}, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("update deal returned %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = sendDealRequest(t, server, "GET", "/deals?customer_id="+coBuyerID, nil, "")
+	if strings.TrimSpace(rr.Body.String()) != "[]" {
+		t.Errorf("Expected no deals for the removed co-buyer, got %s", rr.Body.String())
+	}
+}
+
+func TestCoBuyerValidation(t *testing.T) {
+	server, dealershipID, buyerID, _, otherID := setupBuyersServer()
+
+	tests := []struct {
+		name         string
+		customerID   string
+		coCustomerID string
+		message      string
+	}{
+		{"same as buyer", buyerID, buyerID, "Co-buyer must be a different customer"},
+		{"without buyer", "", buyerID, "A co-buyer requires a customer"},
+		{"unknown co-buyer", buyerID, uuid.New().String(), "Customer not found"},
+		{"other dealership", buyerID, otherID, "Customer does not belong to the dealership"},
+		{"invalid UUID", buyerID, "not-a-uuid", "Must be a valid UUID"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := sendDealRequest(t, server, "POST", "/deals", CreateDealRequest{
+				DealershipID: dealershipID,
+				CustomerID:   tt.customerID,
+				CoCustomerID: tt.coCustomerID,
+				VehiclePrice: 30000,
+			}, "")
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("Expected 400, got %d: %s", rr.Code, rr.Body.String())
+			}
+
+			var resp errorResponse
+			json.Unmarshal(rr.Body.Bytes(), &resp)
+			if len(resp.Error.Details) != 1 || resp.Error.Details[0].Field != "co_customer_id" || resp.Error.Details[0].Message != tt.message {
+				t.Errorf("Expected co_customer_id error %q, got %+v", tt.message, resp.Error.Details)
+			}
+		})
+	}
+}
+
+func TestBuyersOrderListsBothBuyers(t *testing.T) {
+	server, dealershipID, buyerID, coBuyerID, _ := setupBuyersServer()
+	deal := createCoBuyerDeal(t, server, dealershipID, buyerID, coBuyerID)
+
+	rr := sendDealRequest(t, server, "GET", "/deals/"+deal.ID+"/buyers-order", nil, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("buyer's order returned %d: %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Expected HTML, got %q", ct)
+	}
+
+	body := rr.Body.String()
+	for _, want := range []string{"<h2>Buyer</h2>", "Jordan Reyes", "<h2>Co-Buyer</h2>", "Sam Reyes", "Co-Buyer signature", "$32100.00"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected buyer's order to contain %q", want)
+		}
+	}
+
+	// Without a co-buyer only the buyer is listed
+	sendDealRequest(t, server, "PUT", "/deals/"+deal.ID, map[string]interface{}{"remove_co_customer": true}, "")
+	rr = sendDealRequest(t, server, "GET", "/deals/"+deal.ID+"/buyers-order", nil, "")
+	if strings.Contains(rr.Body.String(), "Co-Buyer") || strings.Contains(rr.Body.String(), "Sam Reyes") {
+		t.Error("Expected no co-buyer on the buyer's order")
+	}
+}
+
+func TestDealFinancingAggregatesBuyers(t *testing.T) {
+	server, dealershipID, buyerID, coBuyerID, _ := setupBuyersServer()
+	deal := createCoBuyerDeal(t, server, dealershipID, buyerID, coBuyerID)
+
+	rr := sendDealRequest(t, server, "GET", "/deals/"+deal.ID+"/financing", nil, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("financing returned %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var financing DealFinancing
+	if err := json.Unmarshal(rr.Body.Bytes(), &financing); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(financing.Applicants) != 2 || financing.Applicants[1].Role != ApplicantRoleCoBuyer {
+		t.Fatalf("Expected buyer and co-buyer applicants, got %+v", financing.Applicants)
+	}
+	if financing.CombinedMonthlyIncome != 9000 {
+		t.Errorf("Expected combined income 9000, got %v", financing.CombinedMonthlyIncome)
+	}
+	if financing.QualifyingCreditScore != 680 {
+		t.Errorf("Expected the lower credit score 680, got %d", financing.QualifyingCreditScore)
+	}
+	if financing.AmountFinanced != 32100 || financing.MonthlyPayment <= 0 || financing.PaymentToIncome <= 0 {
+		t.Errorf("Unexpected financing totals: %+v", financing)
+	}
+}
+
+func TestBuyersRequireCustomerLookup(t *testing.T) {
+	server := setupTestServer()
+
+	rr := sendDealRequest(t, server, "GET", "/deals/"+uuid.New().String()+"/financing", nil, "")
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without customer lookup, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/logging"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// scopeDealsCredit is required to submit a deal's credit application, which
+// sends the buyers' identity fields to lenders
+const scopeDealsCredit = "deals:credit"
+
+// maxCreditLenders is the most lenders one application can be sent to
+const maxCreditLenders = 10
+
+// Credit application and lender decision statuses
+const (
+	CreditStatusApproved    = "approved"
+	CreditStatusConditional = "conditional"
+	CreditStatusPending     = "pending"
+	CreditStatusDeclined    = "declined"
+	CreditStatusError       = "error"
+)
+
+// creditStatusRank orders statuses from best to worst. An application takes
+// the best status among its lenders' decisions.
+var creditStatusRank = map[string]int{
+	CreditStatusApproved:    0,
+	CreditStatusConditional: 1,
+	CreditStatusPending:     2,
+	CreditStatusDeclined:    3,
+	CreditStatusError:       4,
+}
+
+// CreditApplicant is one buyer's details as sent to a lender
+type CreditApplicant struct {
+	CustomerID           string  `json:"customer_id"`
+	Role                 string  `json:"role"`
+	FirstName            string  `json:"first_name"`
+	LastName             string  `json:"last_name"`
+	Email                string  `json:"email,omitempty"`
+	Phone                string  `json:"phone,omitempty"`
+	Address              string  `json:"address,omitempty"`
+	City                 string  `json:"city,omitempty"`
+	State                string  `json:"state,omitempty"`
+	ZipCode              string  `json:"zip_code,omitempty"`
+	SSNLast4             string  `json:"ssn_last4,omitempty"`
+	DriversLicenseNumber string  `json:"drivers_license_number,omitempty"`
+	CreditScore          int     `json:"credit_score,omitempty"`
+	MonthlyIncome        float64 `json:"monthly_income"`
+}
+
+// CreditSubmission is a credit application sent to one lender. It carries
+// decrypted identity fields and is never stored.
+type CreditSubmission struct {
+	ApplicationID  string            `json:"application_id"`
+	LenderID       string            `json:"lender_id"`
+	DealID         string            `json:"deal_id"`
+	DealershipID   string            `json:"dealership_id"`
+	Applicants     []CreditApplicant `json:"applicants"`
+	VehiclePrice   float64           `json:"vehicle_price"`
+	DownPayment    float64           `json:"down_payment"`
+	TradeInValue   float64           `json:"trade_in_value"`
+	TradeInPayoff  float64           `json:"trade_in_payoff"`
+	AmountFinanced float64           `json:"amount_financed"`
+	TermMonths     int               `json:"term_months,omitempty"`
+}
+
+// LenderDecision is one lender's response to a credit application
+type LenderDecision struct {
+	LenderID        string    `json:"lender_id"`
+	Status          string    `json:"status"`
+	ReferenceNumber string    `json:"reference_number,omitempty"`
+	ApprovedAmount  float64   `json:"approved_amount,omitempty"`
+	BuyRate         float64   `json:"buy_rate,omitempty"`
+	TermMonths      int       `json:"term_months,omitempty"`
+	Stipulations    []string  `json:"stipulations,omitempty"`
+	Message         string    `json:"message,omitempty"`
+	RespondedAt     time.Time `json:"responded_at"`
+}
+
+// CreditApplication records a deal's submission to one or more lenders and
+// each lender's decision. Applicants are referenced by customer ID only.
+type CreditApplication struct {
+	ID             string           `json:"id"`
+	DealID         string           `json:"deal_id"`
+	DealershipID   string           `json:"dealership_id"`
+	Status         string           `json:"status"`
+	ApplicantIDs   []string         `json:"applicant_ids"`
+	AmountFinanced float64          `json:"amount_financed"`
+	TermMonths     int              `json:"term_months,omitempty"`
+	SubmittedBy    string           `json:"submitted_by,omitempty"`
+	SubmittedAt    time.Time        `json:"submitted_at"`
+	Decisions      []LenderDecision `json:"decisions"`
+}
+
+// CreditProvider submits credit applications to lenders
+type CreditProvider interface {
+	// Submit sends the application to submission.LenderID. An error means
+	// no decision was received.
+	Submit(r *http.Request, submission *CreditSubmission) (*LenderDecision, error)
+}
+
+// SubmitCreditApplicationRequest represents a request to submit a deal's
+// credit application
+type SubmitCreditApplicationRequest struct {
+	LenderIDs []string `json:"lender_ids"`
+}
+
+// Validate validates SubmitCreditApplicationRequest
+func (r *SubmitCreditApplicationRequest) Validate() *ValidationErrors {
+	var errors []ValidationError
+
+	if len(r.LenderIDs) == 0 {
+		errors = append(errors, ValidationError{
+			Field:   "lender_ids",
+			Message: "At least one lender is required",
+		})
+	} else if len(r.LenderIDs) > maxCreditLenders {
+		errors = append(errors, ValidationError{
+			Field:   "lender_ids",
+			Message: fmt.Sprintf("Must be at most %d lenders", maxCreditLenders),
+		})
+	}
+
+	seen := make(map[string]bool)
+	for _, id := range r.LenderIDs {
+		if id == "" || len(id) > 100 {
+			errors = append(errors, ValidationError{
+				Field:   "lender_ids",
+				Message: "Lender IDs must be 1 to 100 characters",
+			})
+			break
+		}
+		if seen[id] {
+			errors = append(errors, ValidationError{
+				Field:   "lender_ids",
+				Message: fmt.Sprintf("Duplicate lender %q", id),
+			})
+			break
+		}
+		seen[id] = true
+	}
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize sanitizes SubmitCreditApplicationRequest
+func (r *SubmitCreditApplicationRequest) Sanitize() {
+	for i, id := range r.LenderIDs {
+		r.LenderIDs[i] = strings.TrimSpace(id)
+	}
+}
+
+// creditApplicant builds the applicant sent to lenders from a customer
+func creditApplicant(customer *DealCustomer, role string) CreditApplicant {
+	return CreditApplicant{
+		CustomerID:           customer.ID,
+		Role:                 role,
+		FirstName:            customer.FirstName,
+		LastName:             customer.LastName,
+		Email:                customer.Email,
+		Phone:                customer.Phone,
+		Address:              customer.Address,
+		City:                 customer.City,
+		State:                customer.State,
+		ZipCode:              customer.ZipCode,
+		SSNLast4:             customer.SSNLast4,
+		DriversLicenseNumber: customer.DriversLicenseNumber,
+		CreditScore:          customer.CreditScore,
+		MonthlyIncome:        customer.MonthlyIncome,
+	}
+}
+
+// submitToLenders sends the submission to each lender concurrently and
+// returns their decisions in request order. Failed submissions are recorded
+// as error decisions.
+func (s *Server) submitToLenders(r *http.Request, submission CreditSubmission, lenderIDs []string) []LenderDecision {
+	decisions := make([]LenderDecision, len(lenderIDs))
+
+	var wg sync.WaitGroup
+	for i, lenderID := range lenderIDs {
+		wg.Add(1)
+		go func(i int, lenderID string) {
+			defer wg.Done()
+
+			sub := submission
+			sub.LenderID = lenderID
+			decision, err := s.credit.Submit(r, &sub)
+			if err == nil && decision != nil {
+				if _, ok := creditStatusRank[decision.Status]; !ok || decision.Status == CreditStatusError {
+					err = fmt.Errorf("lender returned unknown status %q", decision.Status)
+				}
+			} else if err == nil {
+				err = fmt.Errorf("lender returned no decision")
+			}
+
+			if err != nil {
+				s.logger.WithContext(r.Context()).WithError(err).WithFields(map[string]interface{}{
+					"deal_id":   submission.DealID,
+					"lender_id": lenderID,
+				}).Warn("Credit application submission failed")
+				decision = &LenderDecision{Status: CreditStatusError, Message: err.Error()}
+			}
+
+			decision.LenderID = lenderID
+			if decision.RespondedAt.IsZero() {
+				decision.RespondedAt = time.Now()
+			}
+			decisions[i] = *decision
+		}(i, lenderID)
+	}
+	wg.Wait()
+
+	return decisions
+}
+
+// creditApplicationStatus returns the best status among the decisions
+func creditApplicationStatus(decisions []LenderDecision) string {
+	status := CreditStatusError
+	for _, d := range decisions {
+		if creditStatusRank[d.Status] < creditStatusRank[status] {
+			status = d.Status
+		}
+	}
+	return status
+}
+
+// submitCreditApplication sends the deal's buyers to the requested lenders
+// and records each lender's decision
+func (s *Server) submitCreditApplication(w http.ResponseWriter, r *http.Request) {
+	if s.credit == nil || s.customerPII == nil {
+		respondErrorJSON(w, http.StatusServiceUnavailable, "Credit applications are not configured", "CREDIT_UNAVAILABLE")
+		return
+	}
+
+	if !hasScope(r, scopeDealsCredit) {
+		respondErrorJSON(w, http.StatusForbidden, "Submitting credit applications requires the "+scopeDealsCredit+" scope", "INSUFFICIENT_SCOPE")
+		return
+	}
+
+	var req SubmitCreditApplicationRequest
+	if !decodeAndValidate(r, w, &req) {
+		return
+	}
+
+	deal, buyer, coBuyer, ok := s.loadDealCustomersWith(w, r, s.customerPII.GetCustomerPII)
+	if !ok {
+		return
+	}
+
+	amountFinanced := deal.amountFinanced(s.roundingPolicy(r, deal.DealershipID))
+	if amountFinanced <= 0 {
+		respondErrorJSON(w, http.StatusConflict, "Deal has no amount to finance", "NOTHING_TO_FINANCE")
+		return
+	}
+
+	application := &CreditApplication{
+		ID:             uuid.New().String(),
+		DealID:         deal.ID,
+		DealershipID:   deal.DealershipID,
+		AmountFinanced: amountFinanced,
+		TermMonths:     deal.TermMonths,
+		SubmittedBy:    logging.GetUserID(r.Context()),
+		SubmittedAt:    time.Now(),
+	}
+	submission := CreditSubmission{
+		ApplicationID:  application.ID,
+		DealID:         deal.ID,
+		DealershipID:   deal.DealershipID,
+		VehiclePrice:   deal.VehiclePrice,
+		DownPayment:    deal.DownPayment,
+		TradeInValue:   deal.TradeInValue,
+		TradeInPayoff:  deal.TradeInPayoff,
+		AmountFinanced: amountFinanced,
+		TermMonths:     deal.TermMonths,
+	}
+
+	applicants := []struct {
+		customer *DealCustomer
+		role     string
+	}{{buyer, ApplicantRoleBuyer}, {coBuyer, ApplicantRoleCoBuyer}}
+	for _, a := range applicants {
+		if a.customer == nil {
+			continue
+		}
+		submission.Applicants = append(submission.Applicants, creditApplicant(a.customer, a.role))
+		application.ApplicantIDs = append(application.ApplicantIDs, a.customer.ID)
+	}
+
+	application.Decisions = s.submitToLenders(r, submission, req.LenderIDs)
+	application.Status = creditApplicationStatus(application.Decisions)
+
+	if err := s.db.CreateCreditApplication(application); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to save credit application")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to save credit application: %v", err))
+		return
+	}
+
+	// The audit entry names the lenders and outcome, never applicant details
+	entry := &DealHistoryEntry{
+		ID:        uuid.New().String(),
+		DealID:    deal.ID,
+		Field:     "credit_application",
+		NewValue:  fmt.Sprintf("%s %s (%s)", application.ID, application.Status, strings.Join(req.LenderIDs, ", ")),
+		ChangedBy: application.SubmittedBy,
+		ChangedAt: application.SubmittedAt,
+	}
+	if err := s.db.CreateDealHistory(entry); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).WithField("deal_id", deal.ID).Warn("Failed to record deal history")
+	}
+
+	s.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
+		"deal_id":        deal.ID,
+		"application_id": application.ID,
+		"status":         application.Status,
+		"lenders":        len(req.LenderIDs),
+	}).Info("Credit application submitted")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(application)
+}
+
+// listCreditApplications returns a deal's credit applications, newest first
+func (s *Server) listCreditApplications(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if !validateUUID(w, id, "id") {
+		return
+	}
+
+	applications, err := s.db.ListCreditApplications(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list credit applications")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to list credit applications: %v", err))
+		return
+	}
+
+	if applications == nil {
+		applications = []*CreditApplication{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(applications)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// piiCustomers serves the stub customers with their identity fields and
+// records the lookups made through it
+type piiCustomers struct {
+	*stubCustomers
+	lookups int
+}
+
+func (c *piiCustomers) GetCustomerPII(r *http.Request, customerID string) (*DealCustomer, error) {
+	c.lookups++
+	return c.customers[customerID], nil
+}
+
+// mockLenders returns a fixed decision or error per lender and records the
+// submissions it receives
+type mockLenders struct {
+	mu          sync.Mutex
+	decisions   map[string]*LenderDecision
+	errors      map[string]error
+	submissions []CreditSubmission
+}
+
+func (l *mockLenders) Submit(r *http.Request, submission *CreditSubmission) (*LenderDecision, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.submissions = append(l.submissions, *submission)
+	if err := l.errors[submission.LenderID]; err != nil {
+		return nil, err
+	}
+	decision := *l.decisions[submission.LenderID]
+	return &decision, nil
+}
+
+// setupCreditServer returns a server with a co-buyer deal whose buyers have
+// identity fields on file
+func setupCreditServer(t *testing.T) (*Server, *piiCustomers, *mockLenders, Deal) {
+	t.Helper()
+	server, dealershipID, buyerID, coBuyerID, _ := setupBuyersServer()
+	stub := server.customers.(*stubCustomers)
+	stub.customers[buyerID].SSNLast4 = "1234"
+	stub.customers[coBuyerID].SSNLast4 = "5678"
+
+	customers := &piiCustomers{stubCustomers: stub}
+	lenders := &mockLenders{decisions: map[string]*LenderDecision{}, errors: map[string]error{}}
+	server.customerPII = customers
+	server.credit = lenders
+
+	return server, customers, lenders, createCoBuyerDeal(t, server, dealershipID, buyerID, coBuyerID)
+}
+
+func TestSubmitCreditApplicationToMultipleLenders(t *testing.T) {
+	server, customers, lenders, deal := setupCreditServer(t)
+	lenders.decisions["ally"] = &LenderDecision{Status: CreditStatusConditional, ReferenceNumber: "ALY-1", ApprovedAmount: 30000, BuyRate: 5.9, TermMonths: 60, Stipulations: []string{"proof_of_income"}}
+	lenders.decisions["chase"] = &LenderDecision{Status: CreditStatusDeclined, ReferenceNumber: "CH-9", Message: "Debt to income too high"}
+	lenders.errors["wells"] = errors.New("connection refused")
+
+	rr := sendDealRequest(t, server, "POST", "/deals/"+deal.ID+"/credit-application",
+		SubmitCreditApplicationRequest{LenderIDs: []string{"ally", "chase", "wells"}}, scopeDealsCredit)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var application CreditApplication
+	if err := json.Unmarshal(rr.Body.Bytes(), &application); err != nil {
+		t.Fatal(err)
+	}
+
+	// The application takes the best lender outcome
+	if application.Status != CreditStatusConditional {
+		t.Errorf("Expected status conditional, got %s", application.Status)
+	}
+	if application.AmountFinanced != 32100 || len(application.ApplicantIDs) != 2 {
+		t.Errorf("Expected $32,100 financed for both buyers, got %+v", application)
+	}
+
+	// Each lender's response is tracked in request order
+	if len(application.Decisions) != 3 {
+		t.Fatalf("Expected 3 decisions, got %+v", application.Decisions)
+	}
+	ally, chase, wells := application.Decisions[0], application.Decisions[1], application.Decisions[2]
+	if ally.LenderID != "ally" || ally.ReferenceNumber != "ALY-1" || ally.BuyRate != 5.9 || len(ally.Stipulations) != 1 {
+		t.Errorf("Unexpected ally decision: %+v", ally)
+	}
+	if chase.LenderID != "chase" || chase.Status != CreditStatusDeclined || chase.Message != "Debt to income too high" {
+		t.Errorf("Unexpected chase decision: %+v", chase)
+	}
+	if wells.LenderID != "wells" || wells.Status != CreditStatusError || !strings.Contains(wells.Message, "connection refused") {
+		t.Errorf("Expected the failed submission recorded as an error, got %+v", wells)
+	}
+	if ally.RespondedAt.IsZero() || wells.RespondedAt.IsZero() {
+		t.Error("Expected every decision to have a response time")
+	}
+
+	// Lenders receive both applicants' identity fields, read with the PII lookup
+	if customers.lookups != 2 {
+		t.Errorf("Expected 2 PII lookups, got %d", customers.lookups)
+	}
+	if len(lenders.submissions) != 3 {
+		t.Fatalf("Expected 3 submissions, got %d", len(lenders.submissions))
+	}
+	sub := lenders.submissions[0]
+	if sub.ApplicationID != application.ID || len(sub.Applicants) != 2 ||
+		sub.Applicants[0].SSNLast4 != "1234" || sub.Applicants[1].Role != ApplicantRoleCoBuyer || sub.Applicants[1].SSNLast4 != "5678" {
+		t.Errorf("Unexpected submission: %+v", sub)
+	}
+
+	// The application is stored with its decisions
+	rr = sendDealRequest(t, server, "GET", "/deals/"+deal.ID+"/credit-applications", nil, "")
+	var stored []CreditApplication
+	if err := json.Unmarshal(rr.Body.Bytes(), &stored); err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 || stored[0].ID != application.ID || len(stored[0].Decisions) != 3 {
+		t.Errorf("Expected the stored application, got %+v", stored)
+	}
+
+	// The submission is audited without applicant details
+	history := server.db.(*MockDatabase).history[deal.ID]
+	var audited bool
+	for _, entry := range history {
+		if entry.Field != "credit_application" {
+			continue
+		}
+		audited = true
+		if entry.ChangedBy != "manager-1" || !strings.Contains(entry.NewValue, application.ID) || !strings.Contains(entry.NewValue, "ally, chase, wells") {
+			t.Errorf("Unexpected audit entry: %+v", entry)
+		}
+		if strings.Contains(entry.NewValue, "1234") {
+			t.Errorf("Audit entry leaks identity fields: %+v", entry)
+		}
+	}
+	if !audited {
+		t.Error("Expected the submission in deal history")
+	}
+}
+
+func TestCreditApplicationAllLendersFail(t *testing.T) {
+	server, _, lenders, deal := setupCreditServer(t)
+	lenders.errors["ally"] = errors.New("timeout")
+	lenders.decisions["chase"] = &LenderDecision{Status: "maybe"}
+
+	rr := sendDealRequest(t, server, "POST", "/deals/"+deal.ID+"/credit-application",
+		SubmitCreditApplicationRequest{LenderIDs: []string{"ally", "chase"}}, scopeDealsCredit)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var application CreditApplication
+	json.Unmarshal(rr.Body.Bytes(), &application)
+	if application.Status != CreditStatusError {
+		t.Errorf("Expected status error, got %s", application.Status)
+	}
+	if d := application.Decisions[1]; d.Status != CreditStatusError || !strings.Contains(d.Message, "unknown status") {
+		t.Errorf("Expected an unknown lender status rejected, got %+v", d)
+	}
+}
+
+func TestSubmitCreditApplicationRequiresScope(t *testing.T) {
+	server, customers, lenders, deal := setupCreditServer(t)
+
+	rr := sendDealRequest(t, server, "POST", "/deals/"+deal.ID+"/credit-application",
+		SubmitCreditApplicationRequest{LenderIDs: []string{"ally"}}, "")
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if customers.lookups != 0 || len(lenders.submissions) != 0 {
+		t.Error("Expected no identity lookups or submissions without the scope")
+	}
+}
+
+func TestSubmitCreditApplicationValidation(t *testing.T) {
+	server, _, lenders, deal := setupCreditServer(t)
+
+	for _, lenderIDs := range [][]string{nil, {"ally", "ally"}, {" "}} {
+		rr := sendDealRequest(t, server, "POST", "/deals/"+deal.ID+"/credit-application",
+			SubmitCreditApplicationRequest{LenderIDs: lenderIDs}, scopeDealsCredit)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for lenders %q, got %d", lenderIDs, rr.Code)
+		}
+	}
+	if len(lenders.submissions) != 0 {
+		t.Error("Expected no submissions for invalid requests")
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"autolytiq/shared/logging"
+)
+
+// integrationProviderCreditBureau is the config-service integration provider
+// used to submit credit applications
+const integrationProviderCreditBureau = "credit_bureau"
+
+// creditBureauIntegration is the subset of a config-service integration
+// used here
+type creditBureauIntegration struct {
+	Provider   string          `json:"provider"`
+	Status     string          `json:"status"`
+	ConfigJSON json.RawMessage `json:"config_json"`
+}
+
+// creditBureauSettings is a credit_bureau integration's configuration
+type creditBureauSettings struct {
+	BaseURL string `json:"base_url"`
+	APIKey  string `json:"api_key"`
+}
+
+// CreditBureauClient submits credit applications through each dealership's
+// credit_bureau integration, read from config-service
+type CreditBureauClient struct {
+	configURL  string
+	httpClient *http.Client
+}
+
+// NewCreditBureauClient creates a client that reads integrations from the
+// config-service at configURL
+func NewCreditBureauClient(configURL string) *CreditBureauClient {
+	return &CreditBureauClient{
+		configURL:  configURL,
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Submit posts the application to the dealership's credit bureau for one
+// lender and returns the lender's decision
+func (c *CreditBureauClient) Submit(r *http.Request, submission *CreditSubmission) (*LenderDecision, error) {
+	settings, err := c.integration(r, submission.DealershipID)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(submission)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode credit application: %w", err)
+	}
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, strings.TrimRight(settings.BaseURL, "/")+"/applications", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build credit application request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if traceID := logging.GetTraceID(r.Context()); traceID != "" {
+		req.Header.Set(logging.RequestIDHeader, traceID)
+	}
+	if settings.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+settings.APIKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit credit application: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("credit bureau returned status %d", resp.StatusCode)
+	}
+
+	var decision LenderDecision
+	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		return nil, fmt.Errorf("failed to decode lender decision: %w", err)
+	}
+	return &decision, nil
+}
+
+// integration returns the dealership's active credit_bureau integration
+func (c *CreditBureauClient) integration(r *http.Request, dealershipID string) (*creditBureauSettings, error) {
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, c.configURL+"/config/integrations", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build integrations request: %w", err)
+	}
+	logging.PropagateHeaders(r, req)
+	req.Header.Set(logging.DealershipIDHeader, dealershipID)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch integrations: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("config-service returned status %d", resp.StatusCode)
+	}
+
+	var integrations []creditBureauIntegration
+	if err := json.NewDecoder(resp.Body).Decode(&integrations); err != nil {
+		return nil, fmt.Errorf("failed to decode integrations: %w", err)
+	}
+
+	for _, integration := range integrations {
+		if integration.Provider != integrationProviderCreditBureau || integration.Status != "active" {
+			continue
+		}
+		var settings creditBureauSettings
+		if err := json.Unmarshal(integration.ConfigJSON, &settings); err != nil {
+			return nil, fmt.Errorf("invalid credit_bureau integration config: %w", err)
+		}
+		if settings.BaseURL == "" {
+			return nil, fmt.Errorf("credit_bureau integration has no base_url")
+		}
+		return &settings, nil
+	}
+	return nil, fmt.Errorf("dealership has no active credit_bureau integration")
+}
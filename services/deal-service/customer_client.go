@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"autolytiq/shared/logging"
+	"autolytiq/shared/tracing"
+)
+
+// DealCustomer is the subset of customer-service's customer used by deals
+type DealCustomer struct {
+	ID            string  `json:"id"`
+	DealershipID  string  `json:"dealership_id"`
+	FirstName     string  `json:"first_name"`
+	LastName      string  `json:"last_name"`
+	Email         string  `json:"email"`
+	Phone         string  `json:"phone"`
+	Address       string  `json:"address"`
+	City          string  `json:"city"`
+	State         string  `json:"state"`
+	ZipCode       string  `json:"zip_code"`
+	CreditScore   int     `json:"credit_score"`
+	MonthlyIncome float64 `json:"monthly_income"`
+
+	// Identity fields are only used for credit applications and are only
+	// returned to requests carrying the customers:pii scope
+	SSNLast4             string `json:"ssn_last4,omitempty"`
+	DriversLicenseNumber string `json:"drivers_license_number,omitempty"`
+}
+
+// scopeCustomerPII grants access to decrypted customer identity fields in
+// customer-service
+const scopeCustomerPII = "customers:pii"
+
+// CustomerLookup fetches the customers on a deal from customer-service
+type CustomerLookup interface {
+	// GetCustomer returns nil if the customer does not exist
+	GetCustomer(r *http.Request, customerID string) (*DealCustomer, error)
+}
+
+// CustomerPIILookup fetches customers with their decrypted identity fields
+// for server-side use, regardless of the caller's scopes
+type CustomerPIILookup interface {
+	// GetCustomerPII returns nil if the customer does not exist
+	GetCustomerPII(r *http.Request, customerID string) (*DealCustomer, error)
+}
+
+// CustomerClient calls customer-service on behalf of an incoming request
+type CustomerClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewCustomerClient creates a new customer-service client
+func NewCustomerClient(baseURL string) *CustomerClient {
+	return &CustomerClient{
+		baseURL:    baseURL,
+		httpClient: &http.Client{Transport: tracing.Transport(nil), Timeout: 5 * time.Second},
+	}
+}
+
+// GetCustomer fetches a customer, forwarding the caller's identity
+func (c *CustomerClient) GetCustomer(r *http.Request, customerID string) (*DealCustomer, error) {
+	return c.getCustomer(r, customerID, "")
+}
+
+// GetCustomerPII fetches a customer with the customers:pii scope on
+// deal-service's own behalf. Callers must check the request is allowed to
+// use the identity fields before calling it.
+func (c *CustomerClient) GetCustomerPII(r *http.Request, customerID string) (*DealCustomer, error) {
+	return c.getCustomer(r, customerID, scopeCustomerPII)
+}
+
+// getCustomer fetches a customer with the given scopes, returning nil if it
+// does not exist
+func (c *CustomerClient) getCustomer(r *http.Request, customerID, scopes string) (*DealCustomer, error) {
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, c.baseURL+"/customers/"+url.PathEscape(customerID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build customer request: %w", err)
+	}
+	logging.PropagateHeaders(r, req)
+	if scopes != "" {
+		req.Header.Set(scopesHeader, scopes)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch customer: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("customer-service returned status %d", resp.StatusCode)
+	}
+
+	var customer DealCustomer
+	if err := json.NewDecoder(resp.Body).Decode(&customer); err != nil {
+		return nil, fmt.Errorf("failed to decode customer: %w", err)
+	}
+
+	return &customer, nil
+}
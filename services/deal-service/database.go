@@ -1,13 +1,16 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"autolytiq/shared/logging"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // Database wraps the SQL database connection
@@ -43,6 +46,11 @@ func (db *Database) Close() error {
 	return db.conn.Close()
 }
 
+// Ping checks the database is reachable
+func (db *Database) Ping(ctx context.Context) error {
+	return db.conn.PingContext(ctx)
+}
+
 // InitSchema creates the deals table if it doesn't exist
 func (db *Database) InitSchema() error {
 	schema := `
@@ -61,9 +69,137 @@ func (db *Database) InitSchema() error {
 		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
 	);
 
+	ALTER TABLE deals ADD COLUMN IF NOT EXISTS term_months INTEGER DEFAULT 0;
+	ALTER TABLE deals ADD COLUMN IF NOT EXISTS buy_rate DECIMAL(6, 3) DEFAULT 0;
+	ALTER TABLE deals ADD COLUMN IF NOT EXISTS sell_rate DECIMAL(6, 3) DEFAULT 0;
+	ALTER TABLE deals ADD COLUMN IF NOT EXISTS vehicle_id VARCHAR(36);
+	ALTER TABLE deals ADD COLUMN IF NOT EXISTS requires_approval BOOLEAN NOT NULL DEFAULT false;
+	ALTER TABLE deals ADD COLUMN IF NOT EXISTS guardrail_violations JSONB;
+	ALTER TABLE deals ADD COLUMN IF NOT EXISTS approved_by VARCHAR(36);
+	ALTER TABLE deals ADD COLUMN IF NOT EXISTS approved_at TIMESTAMP;
+	ALTER TABLE deals ADD COLUMN IF NOT EXISTS co_customer_id VARCHAR(36);
+	ALTER TABLE deals ADD COLUMN IF NOT EXISTS funded_at TIMESTAMP;
+	ALTER TABLE deals ADD COLUMN IF NOT EXISTS products JSONB;
+	ALTER TABLE deals ADD COLUMN IF NOT EXISTS lost_reason VARCHAR(50);
+	ALTER TABLE deals ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMP;
+	ALTER TABLE deals ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+	CREATE TABLE IF NOT EXISTS deal_history (
+		id VARCHAR(36) PRIMARY KEY,
+		deal_id VARCHAR(36) NOT NULL REFERENCES deals(id) ON DELETE CASCADE,
+		field VARCHAR(100) NOT NULL,
+		old_value TEXT,
+		new_value TEXT,
+		changed_by VARCHAR(36),
+		changed_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS deal_versions (
+		id VARCHAR(36) PRIMARY KEY,
+		deal_id VARCHAR(36) NOT NULL REFERENCES deals(id) ON DELETE CASCADE,
+		version INTEGER NOT NULL,
+		change_type VARCHAR(20) NOT NULL,
+		reverted_from INTEGER,
+		snapshot JSONB NOT NULL,
+		created_by VARCHAR(36),
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		UNIQUE (deal_id, version)
+	);
+
+	CREATE TABLE IF NOT EXISTS deal_documents (
+		id VARCHAR(36) PRIMARY KEY,
+		deal_id VARCHAR(36) NOT NULL REFERENCES deals(id) ON DELETE CASCADE,
+		dealership_id VARCHAR(36) NOT NULL,
+		document_type VARCHAR(50) NOT NULL,
+		filename VARCHAR(255) NOT NULL,
+		content_type VARCHAR(100) NOT NULL,
+		size_bytes BIGINT NOT NULL,
+		encrypted BOOLEAN NOT NULL DEFAULT false,
+		storage_key VARCHAR(255) NOT NULL,
+		uploaded_by VARCHAR(36),
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS deal_notes (
+		id VARCHAR(36) PRIMARY KEY,
+		deal_id VARCHAR(36) NOT NULL REFERENCES deals(id) ON DELETE CASCADE,
+		body TEXT NOT NULL,
+		created_by VARCHAR(36),
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
 	CREATE INDEX IF NOT EXISTS idx_deals_dealership ON deals(dealership_id);
 	CREATE INDEX IF NOT EXISTS idx_deals_customer ON deals(customer_id);
+	CREATE INDEX IF NOT EXISTS idx_deals_co_customer ON deals(co_customer_id) WHERE co_customer_id IS NOT NULL;
+	CREATE INDEX IF NOT EXISTS idx_deal_documents_deal ON deal_documents(deal_id);
 	CREATE INDEX IF NOT EXISTS idx_deals_status ON deals(status);
+	CREATE INDEX IF NOT EXISTS idx_deal_history_deal ON deal_history(deal_id, changed_at);
+	CREATE INDEX IF NOT EXISTS idx_deal_notes_deal ON deal_notes(deal_id, created_at);
+
+	CREATE TABLE IF NOT EXISTS deal_accounting_exports (
+		deal_id VARCHAR(36) PRIMARY KEY REFERENCES deals(id) ON DELETE CASCADE,
+		batch_id VARCHAR(36) NOT NULL,
+		exported_at TIMESTAMP NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_deals_funded ON deals(dealership_id, funded_at) WHERE funded_at IS NOT NULL;
+	UPDATE deals SET funded_at = updated_at WHERE status IN ('funded', 'delivered') AND funded_at IS NULL;
+
+	CREATE TABLE IF NOT EXISTS deal_credit_applications (
+		id VARCHAR(36) PRIMARY KEY,
+		deal_id VARCHAR(36) NOT NULL REFERENCES deals(id) ON DELETE CASCADE,
+		dealership_id VARCHAR(36) NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		applicant_ids TEXT[] NOT NULL,
+		amount_financed DECIMAL(10, 2) NOT NULL,
+		term_months INTEGER NOT NULL DEFAULT 0,
+		submitted_by VARCHAR(36),
+		submitted_at TIMESTAMP NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS deal_credit_decisions (
+		application_id VARCHAR(36) NOT NULL REFERENCES deal_credit_applications(id) ON DELETE CASCADE,
+		lender_id VARCHAR(100) NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		reference_number VARCHAR(100),
+		approved_amount DECIMAL(10, 2),
+		buy_rate DECIMAL(6, 3),
+		term_months INTEGER,
+		stipulations JSONB,
+		message TEXT,
+		responded_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (application_id, lender_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_deal_credit_applications_deal ON deal_credit_applications(deal_id, submitted_at);
+
+	CREATE TABLE IF NOT EXISTS deal_signature_requests (
+		id VARCHAR(36) PRIMARY KEY,
+		deal_id VARCHAR(36) NOT NULL REFERENCES deals(id) ON DELETE CASCADE,
+		dealership_id VARCHAR(36) NOT NULL,
+		envelope_id VARCHAR(255) NOT NULL UNIQUE,
+		status VARCHAR(20) NOT NULL,
+		documents JSONB NOT NULL,
+		signers JSONB NOT NULL,
+		signed_documents JSONB NOT NULL DEFAULT '[]',
+		requested_by VARCHAR(36),
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL,
+		completed_at TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_deal_signature_requests_deal ON deal_signature_requests(deal_id, created_at);
+
+	CREATE TABLE IF NOT EXISTS deal_quotes (
+		id VARCHAR(36) PRIMARY KEY,
+		dealership_id VARCHAR(36) NOT NULL,
+		quote JSONB NOT NULL,
+		deal_id VARCHAR(36),
+		created_at TIMESTAMP NOT NULL,
+		expires_at TIMESTAMP NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_deal_quotes_expires ON deal_quotes(expires_at);
 	`
 
 	if _, err := db.conn.Exec(schema); err != nil {
@@ -82,22 +218,40 @@ func (db *Database) CreateDeal(deal *Deal) error {
 		INSERT INTO deals (
 			id, dealership_id, customer_id, vehicle_price,
 			trade_in_value, trade_in_payoff, down_payment,
-			tax_amount, total_amount, status, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			tax_amount, total_amount, term_months, buy_rate, sell_rate,
+			status, created_at, updated_at, vehicle_id,
+			requires_approval, guardrail_violations, approved_by, approved_at,
+			co_customer_id, funded_at, products, lost_reason, cancelled_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NULLIF($16, ''),
+			$17, $18, NULLIF($19, ''), $20, NULLIF($21, ''),
+			CASE WHEN $13 = 'funded' THEN $14::timestamp END, $22, NULLIF($23, ''), $24)
 	`
 
-	_, err := db.conn.Exec(
+	violations, err := marshalGuardrailViolations(deal.GuardrailViolations)
+	if err != nil {
+		return err
+	}
+	products, err := marshalProducts(deal.Products)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.conn.Exec(
 		query,
 		deal.ID, deal.DealershipID, deal.CustomerID, deal.VehiclePrice,
 		deal.TradeInValue, deal.TradeInPayoff, deal.DownPayment,
-		deal.TaxAmount, deal.TotalAmount, deal.Status,
-		deal.CreatedAt, deal.UpdatedAt,
+		deal.TaxAmount, deal.TotalAmount, deal.TermMonths,
+		deal.BuyRate, deal.SellRate, deal.Status,
+		deal.CreatedAt, deal.UpdatedAt, deal.VehicleID,
+		deal.RequiresApproval, violations, deal.ApprovedBy, deal.ApprovedAt,
+		deal.CoCustomerID, products, deal.LostReason, deal.CancelledAt,
 	)
 
 	if err != nil {
 		return fmt.Errorf("failed to create deal: %w", err)
 	}
 
+	deal.Version = 1
 	return nil
 }
 
@@ -106,19 +260,16 @@ func (db *Database) GetDeal(id string) (*Deal, error) {
 	query := `
 		SELECT id, dealership_id, customer_id, vehicle_price,
 			   trade_in_value, trade_in_payoff, down_payment,
-			   tax_amount, total_amount, status, created_at, updated_at
+			   tax_amount, total_amount, term_months, buy_rate, sell_rate,
+			   status, created_at, updated_at, COALESCE(vehicle_id, ''),
+			   requires_approval, guardrail_violations, COALESCE(approved_by, ''), approved_at,
+			   COALESCE(co_customer_id, ''), products,
+			   COALESCE(lost_reason, ''), cancelled_at, version
 		FROM deals
 		WHERE id = $1
 	`
 
-	var deal Deal
-	err := db.conn.QueryRow(query, id).Scan(
-		&deal.ID, &deal.DealershipID, &deal.CustomerID, &deal.VehiclePrice,
-		&deal.TradeInValue, &deal.TradeInPayoff, &deal.DownPayment,
-		&deal.TaxAmount, &deal.TotalAmount, &deal.Status,
-		&deal.CreatedAt, &deal.UpdatedAt,
-	)
-
+	deal, err := scanDeal(db.conn.QueryRow(query, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -126,36 +277,42 @@ func (db *Database) GetDeal(id string) (*Deal, error) {
 		return nil, fmt.Errorf("failed to get deal: %w", err)
 	}
 
-	return &deal, nil
+	return deal, nil
 }
 
-// ListDeals retrieves all deals, optionally filtered by dealership
-func (db *Database) ListDeals(dealershipID string) ([]*Deal, error) {
-	var query string
-	var rows *sql.Rows
-	var err error
-
-	if dealershipID != "" {
-		query = `
-			SELECT id, dealership_id, customer_id, vehicle_price,
-				   trade_in_value, trade_in_payoff, down_payment,
-				   tax_amount, total_amount, status, created_at, updated_at
-			FROM deals
-			WHERE dealership_id = $1
-			ORDER BY created_at DESC
-		`
-		rows, err = db.conn.Query(query, dealershipID)
-	} else {
-		query = `
-			SELECT id, dealership_id, customer_id, vehicle_price,
-				   trade_in_value, trade_in_payoff, down_payment,
-				   tax_amount, total_amount, status, created_at, updated_at
-			FROM deals
-			ORDER BY created_at DESC
-		`
-		rows, err = db.conn.Query(query)
+// ListDeals retrieves all deals, optionally filtered by dealership and by
+// customer. A customer filter matches both the buyer and the co-buyer.
+func (db *Database) ListDeals(filter DealListFilter) ([]*Deal, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.DealershipID != "" {
+		args = append(args, filter.DealershipID)
+		conditions = append(conditions, fmt.Sprintf("dealership_id = $%d", len(args)))
+	}
+	if filter.CustomerID != "" {
+		args = append(args, filter.CustomerID)
+		conditions = append(conditions, fmt.Sprintf("(customer_id = $%d OR co_customer_id = $%d)", len(args), len(args)))
 	}
 
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query := `
+		SELECT id, dealership_id, customer_id, vehicle_price,
+			   trade_in_value, trade_in_payoff, down_payment,
+			   tax_amount, total_amount, term_months, buy_rate, sell_rate,
+			   status, created_at, updated_at, COALESCE(vehicle_id, ''),
+			   requires_approval, guardrail_violations, COALESCE(approved_by, ''), approved_at,
+			   COALESCE(co_customer_id, ''), products,
+			   COALESCE(lost_reason, ''), cancelled_at, version
+		FROM deals
+		` + where + `
+		ORDER BY created_at DESC
+	`
+
+	rows, err := db.conn.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list deals: %w", err)
 	}
@@ -163,23 +320,20 @@ func (db *Database) ListDeals(dealershipID string) ([]*Deal, error) {
 
 	var deals []*Deal
 	for rows.Next() {
-		var deal Deal
-		err := rows.Scan(
-			&deal.ID, &deal.DealershipID, &deal.CustomerID, &deal.VehiclePrice,
-			&deal.TradeInValue, &deal.TradeInPayoff, &deal.DownPayment,
-			&deal.TaxAmount, &deal.TotalAmount, &deal.Status,
-			&deal.CreatedAt, &deal.UpdatedAt,
-		)
+		deal, err := scanDeal(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan deal: %w", err)
 		}
-		deals = append(deals, &deal)
+		deals = append(deals, deal)
 	}
 
 	return deals, nil
 }
 
-// UpdateDeal updates an existing deal
+// UpdateDeal updates an existing deal if it is still at deal.Version,
+// incrementing the version in the same statement. It returns
+// ErrVersionConflict if the deal has been updated since it was read, and
+// sets deal.Version to the new version on success.
 func (db *Database) UpdateDeal(deal *Deal) error {
 	query := `
 		UPDATE deals SET
@@ -191,16 +345,43 @@ func (db *Database) UpdateDeal(deal *Deal) error {
 			down_payment = $7,
 			tax_amount = $8,
 			total_amount = $9,
-			status = $10,
-			updated_at = $11
-		WHERE id = $1
+			term_months = $10,
+			buy_rate = $11,
+			sell_rate = $12,
+			status = $13,
+			updated_at = $14,
+			vehicle_id = NULLIF($15, ''),
+			requires_approval = $16,
+			guardrail_violations = $17,
+			approved_by = NULLIF($18, ''),
+			approved_at = $19,
+			co_customer_id = NULLIF($20, ''),
+			funded_at = CASE WHEN $13 = 'funded' THEN COALESCE(funded_at, $14) ELSE funded_at END,
+			products = $21,
+			lost_reason = NULLIF($22, ''),
+			cancelled_at = $23,
+			version = version + 1
+		WHERE id = $1 AND version = $24
 	`
 
+	violations, err := marshalGuardrailViolations(deal.GuardrailViolations)
+	if err != nil {
+		return err
+	}
+	products, err := marshalProducts(deal.Products)
+	if err != nil {
+		return err
+	}
+
 	result, err := db.conn.Exec(
 		query,
 		deal.ID, deal.DealershipID, deal.CustomerID, deal.VehiclePrice,
 		deal.TradeInValue, deal.TradeInPayoff, deal.DownPayment,
-		deal.TaxAmount, deal.TotalAmount, deal.Status, deal.UpdatedAt,
+		deal.TaxAmount, deal.TotalAmount, deal.TermMonths,
+		deal.BuyRate, deal.SellRate, deal.Status, deal.UpdatedAt,
+		deal.VehicleID, deal.RequiresApproval, violations,
+		deal.ApprovedBy, deal.ApprovedAt, deal.CoCustomerID, products,
+		deal.LostReason, deal.CancelledAt, deal.Version,
 	)
 
 	if err != nil {
@@ -213,17 +394,102 @@ func (db *Database) UpdateDeal(deal *Deal) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("deal not found: %s", deal.ID)
+		return db.versionMissError(deal.ID)
 	}
 
+	deal.Version++
 	return nil
 }
 
-// DeleteDeal deletes a deal by ID
-func (db *Database) DeleteDeal(id string) error {
-	query := `DELETE FROM deals WHERE id = $1`
+// versionMissError explains why a write conditional on a deal's version
+// matched no rows: the deal is gone, or it is at another version
+func (db *Database) versionMissError(id string) error {
+	var exists bool
+	if err := db.conn.QueryRow(`SELECT EXISTS(SELECT 1 FROM deals WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check deal: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("deal not found: %s", id)
+	}
+	return ErrVersionConflict
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanDeal scans a deal selected with the standard deal column list
+func scanDeal(row rowScanner) (*Deal, error) {
+	var deal Deal
+	var violations, products []byte
+	var approvedAt, cancelledAt sql.NullTime
+	err := row.Scan(
+		&deal.ID, &deal.DealershipID, &deal.CustomerID, &deal.VehiclePrice,
+		&deal.TradeInValue, &deal.TradeInPayoff, &deal.DownPayment,
+		&deal.TaxAmount, &deal.TotalAmount, &deal.TermMonths,
+		&deal.BuyRate, &deal.SellRate, &deal.Status,
+		&deal.CreatedAt, &deal.UpdatedAt, &deal.VehicleID,
+		&deal.RequiresApproval, &violations, &deal.ApprovedBy, &approvedAt,
+		&deal.CoCustomerID, &products,
+		&deal.LostReason, &cancelledAt, &deal.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(violations) > 0 {
+		if err := json.Unmarshal(violations, &deal.GuardrailViolations); err != nil {
+			return nil, fmt.Errorf("failed to decode guardrail violations: %w", err)
+		}
+	}
+	if len(products) > 0 {
+		if err := json.Unmarshal(products, &deal.Products); err != nil {
+			return nil, fmt.Errorf("failed to decode deal products: %w", err)
+		}
+	}
+	if approvedAt.Valid {
+		deal.ApprovedAt = &approvedAt.Time
+	}
+	if cancelledAt.Valid {
+		deal.CancelledAt = &cancelledAt.Time
+	}
+
+	return &deal, nil
+}
+
+// marshalGuardrailViolations encodes violations for the JSONB column, storing
+// NULL when there are none
+func marshalGuardrailViolations(violations []GuardrailViolation) (interface{}, error) {
+	if len(violations) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(violations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode guardrail violations: %w", err)
+	}
+	return data, nil
+}
+
+// marshalProducts encodes deal products for storage, storing no products as
+// NULL
+func marshalProducts(products []DealProduct) (interface{}, error) {
+	if len(products) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(products)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode deal products: %w", err)
+	}
+	return data, nil
+}
+
+// DeleteDeal deletes a deal by ID if it is still at the given version,
+// returning ErrVersionConflict if it is not
+func (db *Database) DeleteDeal(id string, version int) error {
+	query := `DELETE FROM deals WHERE id = $1 AND version = $2`
 
-	result, err := db.conn.Exec(query, id)
+	result, err := db.conn.Exec(query, id, version)
 	if err != nil {
 		return fmt.Errorf("failed to delete deal: %w", err)
 	}
@@ -234,8 +500,734 @@ func (db *Database) DeleteDeal(id string) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("deal not found: %s", id)
+		return db.versionMissError(id)
+	}
+
+	return nil
+}
+
+// CreateDealHistory records a change to a tracked deal field
+func (db *Database) CreateDealHistory(entry *DealHistoryEntry) error {
+	query := `
+		INSERT INTO deal_history (id, deal_id, field, old_value, new_value, changed_by, changed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := db.conn.Exec(
+		query,
+		entry.ID, entry.DealID, entry.Field, entry.OldValue,
+		entry.NewValue, entry.ChangedBy, entry.ChangedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create deal history: %w", err)
+	}
+
+	return nil
+}
+
+// ListDealHistory retrieves the change history for a deal, oldest first
+func (db *Database) ListDealHistory(dealID string) ([]*DealHistoryEntry, error) {
+	query := `
+		SELECT id, deal_id, field, COALESCE(old_value, ''), COALESCE(new_value, ''),
+			   COALESCE(changed_by, ''), changed_at
+		FROM deal_history
+		WHERE deal_id = $1
+		ORDER BY changed_at ASC
+	`
+
+	rows, err := db.conn.Query(query, dealID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deal history: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*DealHistoryEntry
+	for rows.Next() {
+		var entry DealHistoryEntry
+		if err := rows.Scan(
+			&entry.ID, &entry.DealID, &entry.Field, &entry.OldValue,
+			&entry.NewValue, &entry.ChangedBy, &entry.ChangedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan deal history: %w", err)
+		}
+		entries = append(entries, &entry)
+	}
+
+	return entries, nil
+}
+
+// CreateDealVersion records a deal snapshot as the deal's next version and
+// sets version.Version. Concurrent snapshots of the same deal conflict on the
+// (deal_id, version) constraint rather than sharing a number.
+func (db *Database) CreateDealVersion(version *DealVersion) error {
+	snapshot, err := json.Marshal(version.Snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode deal snapshot: %w", err)
+	}
+
+	query := `
+		INSERT INTO deal_versions (id, deal_id, version, change_type, reverted_from, snapshot, created_by, created_at)
+		SELECT $1, $2, COALESCE(MAX(version), 0) + 1, $3, NULLIF($4, 0), $5, NULLIF($6, ''), $7
+		FROM deal_versions
+		WHERE deal_id = $2
+		RETURNING version
+	`
+
+	err = db.conn.QueryRow(
+		query,
+		version.ID, version.DealID, version.ChangeType, version.RevertedFrom,
+		snapshot, version.CreatedBy, version.CreatedAt,
+	).Scan(&version.Version)
+	if err != nil {
+		return fmt.Errorf("failed to create deal version: %w", err)
+	}
+
+	return nil
+}
+
+// dealVersionColumns is the column list scanned by scanDealVersion
+const dealVersionColumns = `
+	id, deal_id, version, change_type, COALESCE(reverted_from, 0), snapshot,
+	COALESCE(created_by, ''), created_at
+`
+
+// ListDealVersions retrieves a deal's versions, oldest first
+func (db *Database) ListDealVersions(dealID string) ([]*DealVersion, error) {
+	query := `SELECT ` + dealVersionColumns + ` FROM deal_versions WHERE deal_id = $1 ORDER BY version ASC`
+
+	rows, err := db.conn.Query(query, dealID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deal versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []*DealVersion
+	for rows.Next() {
+		version, err := scanDealVersion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan deal version: %w", err)
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
+
+// GetDealVersion retrieves one version of a deal
+func (db *Database) GetDealVersion(dealID string, version int) (*DealVersion, error) {
+	query := `SELECT ` + dealVersionColumns + ` FROM deal_versions WHERE deal_id = $1 AND version = $2`
+
+	v, err := scanDealVersion(db.conn.QueryRow(query, dealID, version))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deal version: %w", err)
+	}
+
+	return v, nil
+}
+
+// scanDealVersion scans a version selected with dealVersionColumns
+func scanDealVersion(row rowScanner) (*DealVersion, error) {
+	var version DealVersion
+	var snapshot []byte
+	err := row.Scan(
+		&version.ID, &version.DealID, &version.Version, &version.ChangeType,
+		&version.RevertedFrom, &snapshot, &version.CreatedBy, &version.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(snapshot, &version.Snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode deal snapshot: %w", err)
+	}
+
+	return &version, nil
+}
+
+// dealDocumentColumns is the column list scanned by scanDealDocuments
+const dealDocumentColumns = `
+	id, deal_id, dealership_id, document_type, filename, content_type,
+	size_bytes, encrypted, storage_key, COALESCE(uploaded_by, ''), created_at
+`
+
+// CreateDealDocument records a deal document's metadata
+func (db *Database) CreateDealDocument(doc *DealDocument) error {
+	query := `
+		INSERT INTO deal_documents (
+			id, deal_id, dealership_id, document_type, filename, content_type,
+			size_bytes, encrypted, storage_key, uploaded_by, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), $11)
+	`
+
+	_, err := db.conn.Exec(
+		query,
+		doc.ID, doc.DealID, doc.DealershipID, doc.DocumentType, doc.Filename, doc.ContentType,
+		doc.Size, doc.Encrypted, doc.StorageKey, doc.UploadedBy, doc.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create deal document: %w", err)
+	}
+
+	return nil
+}
+
+// GetDealDocument retrieves a document on a deal, or nil if it does not exist
+func (db *Database) GetDealDocument(dealID, id string) (*DealDocument, error) {
+	query := `SELECT ` + dealDocumentColumns + ` FROM deal_documents WHERE id = $1 AND deal_id = $2`
+
+	rows, err := db.conn.Query(query, id, dealID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deal document: %w", err)
+	}
+	defer rows.Close()
+
+	docs, err := scanDealDocuments(rows)
+	if err != nil || len(docs) == 0 {
+		return nil, err
+	}
+	return docs[0], nil
+}
+
+// ListDealDocuments retrieves a deal's documents, newest first
+func (db *Database) ListDealDocuments(dealID string) ([]*DealDocument, error) {
+	query := `SELECT ` + dealDocumentColumns + ` FROM deal_documents WHERE deal_id = $1 ORDER BY created_at DESC`
+
+	rows, err := db.conn.Query(query, dealID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deal documents: %w", err)
+	}
+	defer rows.Close()
+
+	return scanDealDocuments(rows)
+}
+
+// ListCustomerDealDocuments retrieves documents on all of a customer's deals
+func (db *Database) ListCustomerDealDocuments(customerID, dealershipID string) ([]*DealDocument, error) {
+	query := `
+		SELECT ` + dealDocumentColumns + `
+		FROM deal_documents
+		WHERE dealership_id = $2
+		  AND deal_id IN (SELECT id FROM deals WHERE customer_id = $1 AND dealership_id = $2)
+	`
+
+	rows, err := db.conn.Query(query, customerID, dealershipID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list customer deal documents: %w", err)
 	}
+	defer rows.Close()
+
+	return scanDealDocuments(rows)
+}
+
+// DeleteDealDocument deletes a document's metadata
+func (db *Database) DeleteDealDocument(id string) error {
+	if _, err := db.conn.Exec(`DELETE FROM deal_documents WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete deal document: %w", err)
+	}
+	return nil
+}
 
+// CreateDealNote inserts a note on a deal
+func (db *Database) CreateDealNote(note *DealNote) error {
+	query := `
+		INSERT INTO deal_notes (id, deal_id, body, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	if _, err := db.conn.Exec(query, note.ID, note.DealID, note.Body, note.CreatedBy, note.CreatedAt); err != nil {
+		return fmt.Errorf("failed to create deal note: %w", err)
+	}
 	return nil
 }
+
+// ListDealNotes retrieves a deal's notes, oldest first
+func (db *Database) ListDealNotes(dealID string) ([]*DealNote, error) {
+	query := `
+		SELECT id, deal_id, body, COALESCE(created_by, ''), created_at
+		FROM deal_notes
+		WHERE deal_id = $1
+		ORDER BY created_at ASC
+	`
+
+	rows, err := db.conn.Query(query, dealID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deal notes: %w", err)
+	}
+	defer rows.Close()
+
+	var notes []*DealNote
+	for rows.Next() {
+		var note DealNote
+		if err := rows.Scan(&note.ID, &note.DealID, &note.Body, &note.CreatedBy, &note.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan deal note: %w", err)
+		}
+		notes = append(notes, &note)
+	}
+
+	return notes, rows.Err()
+}
+
+// CreateCreditApplication stores a credit application and its lenders'
+// decisions in one transaction
+func (db *Database) CreateCreditApplication(application *CreditApplication) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO deal_credit_applications (
+			id, deal_id, dealership_id, status, applicant_ids,
+			amount_financed, term_months, submitted_by, submitted_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9)
+	`,
+		application.ID, application.DealID, application.DealershipID, application.Status,
+		pq.Array(application.ApplicantIDs), application.AmountFinanced, application.TermMonths,
+		application.SubmittedBy, application.SubmittedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create credit application: %w", err)
+	}
+
+	for _, d := range application.Decisions {
+		stipulations, err := json.Marshal(d.Stipulations)
+		if err != nil {
+			return fmt.Errorf("failed to encode stipulations: %w", err)
+		}
+		_, err = tx.Exec(`
+			INSERT INTO deal_credit_decisions (
+				application_id, lender_id, status, reference_number, approved_amount,
+				buy_rate, term_months, stipulations, message, responded_at
+			) VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, NULLIF($9, ''), $10)
+		`,
+			application.ID, d.LenderID, d.Status, d.ReferenceNumber, d.ApprovedAmount,
+			d.BuyRate, d.TermMonths, stipulations, d.Message, d.RespondedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create lender decision: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit credit application: %w", err)
+	}
+	return nil
+}
+
+// ListCreditApplications retrieves a deal's credit applications with their
+// decisions, newest first
+func (db *Database) ListCreditApplications(dealID string) ([]*CreditApplication, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, deal_id, dealership_id, status, applicant_ids,
+			   amount_financed, term_months, COALESCE(submitted_by, ''), submitted_at
+		FROM deal_credit_applications
+		WHERE deal_id = $1
+		ORDER BY submitted_at DESC
+	`, dealID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credit applications: %w", err)
+	}
+	defer rows.Close()
+
+	var applications []*CreditApplication
+	byID := make(map[string]*CreditApplication)
+	for rows.Next() {
+		var a CreditApplication
+		if err := rows.Scan(
+			&a.ID, &a.DealID, &a.DealershipID, &a.Status, pq.Array(&a.ApplicantIDs),
+			&a.AmountFinanced, &a.TermMonths, &a.SubmittedBy, &a.SubmittedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan credit application: %w", err)
+		}
+		a.Decisions = []LenderDecision{}
+		applications = append(applications, &a)
+		byID[a.ID] = &a
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(applications) == 0 {
+		return nil, nil
+	}
+
+	decisionRows, err := db.conn.Query(`
+		SELECT d.application_id, d.lender_id, d.status, COALESCE(d.reference_number, ''),
+			   COALESCE(d.approved_amount, 0), COALESCE(d.buy_rate, 0), COALESCE(d.term_months, 0),
+			   d.stipulations, COALESCE(d.message, ''), d.responded_at
+		FROM deal_credit_decisions d
+		JOIN deal_credit_applications a ON a.id = d.application_id
+		WHERE a.deal_id = $1
+		ORDER BY d.responded_at ASC
+	`, dealID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lender decisions: %w", err)
+	}
+	defer decisionRows.Close()
+
+	for decisionRows.Next() {
+		var applicationID string
+		var d LenderDecision
+		var stipulations []byte
+		if err := decisionRows.Scan(
+			&applicationID, &d.LenderID, &d.Status, &d.ReferenceNumber,
+			&d.ApprovedAmount, &d.BuyRate, &d.TermMonths,
+			&stipulations, &d.Message, &d.RespondedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan lender decision: %w", err)
+		}
+		if len(stipulations) > 0 {
+			if err := json.Unmarshal(stipulations, &d.Stipulations); err != nil {
+				return nil, fmt.Errorf("failed to decode stipulations: %w", err)
+			}
+		}
+		if a, ok := byID[applicationID]; ok {
+			a.Decisions = append(a.Decisions, d)
+		}
+	}
+
+	return applications, decisionRows.Err()
+}
+
+// signatureRequestColumns are the columns read by scanSignatureRequest
+const signatureRequestColumns = `
+	id, deal_id, dealership_id, envelope_id, status, documents, signers,
+	signed_documents, COALESCE(requested_by, ''), created_at, updated_at, completed_at`
+
+// scanSignatureRequest scans a row of signatureRequestColumns
+func scanSignatureRequest(row interface{ Scan(...interface{}) error }) (*SignatureRequest, error) {
+	var req SignatureRequest
+	var documents, signers, signedDocuments []byte
+	var completedAt sql.NullTime
+	if err := row.Scan(
+		&req.ID, &req.DealID, &req.DealershipID, &req.EnvelopeID, &req.Status, &documents, &signers,
+		&signedDocuments, &req.RequestedBy, &req.CreatedAt, &req.UpdatedAt, &completedAt,
+	); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(documents, &req.Documents); err != nil {
+		return nil, fmt.Errorf("failed to decode signature documents: %w", err)
+	}
+	if err := json.Unmarshal(signers, &req.Signers); err != nil {
+		return nil, fmt.Errorf("failed to decode signers: %w", err)
+	}
+	if err := json.Unmarshal(signedDocuments, &req.SignedDocuments); err != nil {
+		return nil, fmt.Errorf("failed to decode signed documents: %w", err)
+	}
+	if completedAt.Valid {
+		req.CompletedAt = &completedAt.Time
+	}
+	return &req, nil
+}
+
+// signatureRequestJSON encodes a signature request's JSONB columns
+func signatureRequestJSON(req *SignatureRequest) (documents, signers, signedDocuments []byte, err error) {
+	if documents, err = json.Marshal(req.Documents); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to encode signature documents: %w", err)
+	}
+	if signers, err = json.Marshal(req.Signers); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to encode signers: %w", err)
+	}
+	signed := req.SignedDocuments
+	if signed == nil {
+		signed = []SignedDocument{}
+	}
+	if signedDocuments, err = json.Marshal(signed); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to encode signed documents: %w", err)
+	}
+	return documents, signers, signedDocuments, nil
+}
+
+// CreateSignatureRequest stores a new signature request
+func (db *Database) CreateSignatureRequest(req *SignatureRequest) error {
+	documents, signers, signedDocuments, err := signatureRequestJSON(req)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.conn.Exec(`
+		INSERT INTO deal_signature_requests (
+			id, deal_id, dealership_id, envelope_id, status, documents, signers,
+			signed_documents, requested_by, created_at, updated_at, completed_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11, $12)
+	`,
+		req.ID, req.DealID, req.DealershipID, req.EnvelopeID, req.Status, documents, signers,
+		signedDocuments, req.RequestedBy, req.CreatedAt, req.UpdatedAt, req.CompletedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create signature request: %w", err)
+	}
+	return nil
+}
+
+// UpdateSignatureRequest saves a signature request's status, signers and
+// signed documents
+func (db *Database) UpdateSignatureRequest(req *SignatureRequest) error {
+	_, signers, signedDocuments, err := signatureRequestJSON(req)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.conn.Exec(`
+		UPDATE deal_signature_requests
+		SET status = $2, signers = $3, signed_documents = $4, updated_at = $5, completed_at = $6
+		WHERE id = $1
+	`, req.ID, req.Status, signers, signedDocuments, req.UpdatedAt, req.CompletedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update signature request: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("signature request not found: %s", req.ID)
+	}
+	return nil
+}
+
+// GetSignatureRequestByEnvelope retrieves the signature request for a
+// provider envelope, or nil if there is none
+func (db *Database) GetSignatureRequestByEnvelope(envelopeID string) (*SignatureRequest, error) {
+	row := db.conn.QueryRow(`SELECT `+signatureRequestColumns+`
+		FROM deal_signature_requests
+		WHERE envelope_id = $1
+	`, envelopeID)
+
+	req, err := scanSignatureRequest(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signature request: %w", err)
+	}
+	return req, nil
+}
+
+// ListSignatureRequests retrieves a deal's signature requests, newest first
+func (db *Database) ListSignatureRequests(dealID string) ([]*SignatureRequest, error) {
+	rows, err := db.conn.Query(`SELECT `+signatureRequestColumns+`
+		FROM deal_signature_requests
+		WHERE deal_id = $1
+		ORDER BY created_at DESC
+	`, dealID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signature requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []*SignatureRequest
+	for rows.Next() {
+		req, err := scanSignatureRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan signature request: %w", err)
+		}
+		requests = append(requests, req)
+	}
+	return requests, rows.Err()
+}
+
+// CreateDealQuote stores a new quote
+func (db *Database) CreateDealQuote(quote *DealQuote) error {
+	data, err := json.Marshal(quote)
+	if err != nil {
+		return fmt.Errorf("failed to encode quote: %w", err)
+	}
+
+	_, err = db.conn.Exec(`
+		INSERT INTO deal_quotes (id, dealership_id, quote, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, quote.ID, quote.DealershipID, data, quote.CreatedAt, quote.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create quote: %w", err)
+	}
+	return nil
+}
+
+// GetDealQuote retrieves a quote by ID, or nil if there is none
+func (db *Database) GetDealQuote(id string) (*DealQuote, error) {
+	var data []byte
+	var dealID string
+	err := db.conn.QueryRow(`
+		SELECT quote, COALESCE(deal_id, '') FROM deal_quotes WHERE id = $1
+	`, id).Scan(&data, &dealID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quote: %w", err)
+	}
+
+	var quote DealQuote
+	if err := json.Unmarshal(data, &quote); err != nil {
+		return nil, fmt.Errorf("failed to decode quote: %w", err)
+	}
+	quote.DealID = dealID
+	return &quote, nil
+}
+
+// ClaimDealQuote records the deal a quote is being turned into. It reports
+// false, without error, when the quote was already used or has expired.
+func (db *Database) ClaimDealQuote(id, dealID string, at time.Time) (bool, error) {
+	result, err := db.conn.Exec(`
+		UPDATE deal_quotes SET deal_id = $2
+		WHERE id = $1 AND deal_id IS NULL AND expires_at > $3
+	`, id, dealID, at)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim quote: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows == 1, nil
+}
+
+// ReleaseDealQuote makes a claimed quote usable again
+func (db *Database) ReleaseDealQuote(id string) error {
+	if _, err := db.conn.Exec(`UPDATE deal_quotes SET deal_id = NULL WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to release quote: %w", err)
+	}
+	return nil
+}
+
+// StreamAccountingDeals calls fn for each deal matching an accounting export
+// filter, in funding order, without loading the whole range into memory.
+// Vehicle references are read from inventory's vehicles table.
+func (db *Database) StreamAccountingDeals(filter AccountingExportFilter, fn func(*AccountingDeal) error) error {
+	args := []interface{}{filter.DealershipID, filter.Status}
+	conditions := []string{"d.dealership_id = $1", "d.status = $2"}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conditions = append(conditions, fmt.Sprintf("COALESCE(d.funded_at, d.created_at) >= $%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conditions = append(conditions, fmt.Sprintf("COALESCE(d.funded_at, d.created_at) < $%d", len(args)))
+	}
+	if filter.UnexportedOnly {
+		conditions = append(conditions, "x.deal_id IS NULL")
+	}
+
+	query := `
+		SELECT d.id, d.dealership_id, d.customer_id, d.vehicle_price,
+			   d.trade_in_value, d.trade_in_payoff, d.down_payment,
+			   d.tax_amount, d.total_amount, d.term_months, d.buy_rate, d.sell_rate,
+			   d.status, d.created_at, d.updated_at, COALESCE(d.vehicle_id, ''),
+			   d.requires_approval, d.guardrail_violations, COALESCE(d.approved_by, ''), d.approved_at,
+			   COALESCE(d.co_customer_id, ''), d.products,
+			   COALESCE(d.lost_reason, ''), d.cancelled_at, d.version,
+			   COALESCE(v.vin, ''), COALESCE(v.stock_number, ''), d.funded_at, x.exported_at
+		FROM deals d
+		LEFT JOIN vehicles v ON v.id = d.vehicle_id
+		LEFT JOIN deal_accounting_exports x ON x.deal_id = d.id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY COALESCE(d.funded_at, d.created_at), d.id
+	`
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query accounting deals: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d AccountingDeal
+		var fundedAt, exportedAt sql.NullTime
+		deal, err := scanDeal(withExtraColumns(rows, &d.VIN, &d.StockNumber, &fundedAt, &exportedAt))
+		if err != nil {
+			return fmt.Errorf("failed to scan accounting deal: %w", err)
+		}
+		d.Deal = deal
+		if fundedAt.Valid {
+			d.FundedAt = &fundedAt.Time
+		}
+		if exportedAt.Valid {
+			d.ExportedAt = &exportedAt.Time
+		}
+		if err := fn(&d); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// MarkDealsExported records that deals were included in an accounting export
+func (db *Database) MarkDealsExported(dealIDs []string, batchID string, exportedAt time.Time) error {
+	query := `
+		INSERT INTO deal_accounting_exports (deal_id, batch_id, exported_at)
+		SELECT unnest($1::varchar[]), $2, $3
+		ON CONFLICT (deal_id) DO UPDATE SET batch_id = EXCLUDED.batch_id, exported_at = EXCLUDED.exported_at
+	`
+
+	if _, err := db.conn.Exec(query, pq.Array(dealIDs), batchID, exportedAt); err != nil {
+		return fmt.Errorf("failed to mark deals exported: %w", err)
+	}
+	return nil
+}
+
+// GetAccountingMapping reads the deal export mapping from the dealership's
+// active accounting integration in config-service's integrations table. It
+// returns nil if there is no active integration or it has no mapping.
+func (db *Database) GetAccountingMapping(dealershipID string) (*AccountingMapping, error) {
+	query := `
+		SELECT config_json -> 'deal_export'
+		FROM integrations
+		WHERE dealership_id = $1 AND provider = 'accounting' AND status = 'active'
+		ORDER BY updated_at DESC
+		LIMIT 1
+	`
+
+	var raw []byte
+	err := db.conn.QueryRow(query, dealershipID).Scan(&raw)
+	if err == sql.ErrNoRows || (err == nil && raw == nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounting integration: %w", err)
+	}
+
+	var mapping AccountingMapping
+	if err := json.Unmarshal(raw, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to decode accounting mapping: %w", err)
+	}
+	return &mapping, nil
+}
+
+// extraColumns scans columns selected after the standard deal column list
+type extraColumns struct {
+	rowScanner
+	extra []interface{}
+}
+
+// withExtraColumns lets scanDeal read a row with additional trailing columns
+func withExtraColumns(row rowScanner, extra ...interface{}) rowScanner {
+	return extraColumns{rowScanner: row, extra: extra}
+}
+
+// Scan scans the deal columns into dest and the trailing columns into extra
+func (e extraColumns) Scan(dest ...interface{}) error {
+	return e.rowScanner.Scan(append(dest, e.extra...)...)
+}
+
+// scanDealDocuments scans rows selected with dealDocumentColumns
+func scanDealDocuments(rows *sql.Rows) ([]*DealDocument, error) {
+	var docs []*DealDocument
+	for rows.Next() {
+		var doc DealDocument
+		if err := rows.Scan(
+			&doc.ID, &doc.DealID, &doc.DealershipID, &doc.DocumentType, &doc.Filename, &doc.ContentType,
+			&doc.Size, &doc.Encrypted, &doc.StorageKey, &doc.UploadedBy, &doc.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan deal document: %w", err)
+		}
+		docs = append(docs, &doc)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read deal documents: %w", err)
+	}
+
+	return docs, nil
+}
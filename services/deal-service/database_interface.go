@@ -1,12 +1,72 @@
 package main
 
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrVersionConflict is returned when a deal is written at a version it is
+// no longer at because it was updated concurrently
+var ErrVersionConflict = errors.New("deal version conflict")
+
 // DealDatabase defines the interface for deal database operations
 type DealDatabase interface {
 	Close() error
 	InitSchema() error
+
+	// Ping checks the database is reachable, for health checks
+	Ping(ctx context.Context) error
 	CreateDeal(deal *Deal) error
 	GetDeal(id string) (*Deal, error)
-	ListDeals(dealershipID string) ([]*Deal, error)
+	ListDeals(filter DealListFilter) ([]*Deal, error)
 	UpdateDeal(deal *Deal) error
-	DeleteDeal(id string) error
+	DeleteDeal(id string, version int) error
+	CreateDealHistory(entry *DealHistoryEntry) error
+	ListDealHistory(dealID string) ([]*DealHistoryEntry, error)
+
+	// Deal versions
+	CreateDealVersion(version *DealVersion) error
+	ListDealVersions(dealID string) ([]*DealVersion, error)
+	GetDealVersion(dealID string, version int) (*DealVersion, error)
+
+	// Deal documents
+	CreateDealDocument(doc *DealDocument) error
+	GetDealDocument(dealID, id string) (*DealDocument, error)
+	ListDealDocuments(dealID string) ([]*DealDocument, error)
+	ListCustomerDealDocuments(customerID, dealershipID string) ([]*DealDocument, error)
+	DeleteDealDocument(id string) error
+
+	// Deal notes
+	CreateDealNote(note *DealNote) error
+	ListDealNotes(dealID string) ([]*DealNote, error)
+
+	// Credit applications
+	CreateCreditApplication(application *CreditApplication) error
+	ListCreditApplications(dealID string) ([]*CreditApplication, error)
+
+	// Signature requests
+	CreateSignatureRequest(request *SignatureRequest) error
+	UpdateSignatureRequest(request *SignatureRequest) error
+	GetSignatureRequestByEnvelope(envelopeID string) (*SignatureRequest, error)
+	ListSignatureRequests(dealID string) ([]*SignatureRequest, error)
+
+	// Quotes. Claiming a quote reports false when it was already used or
+	// has expired; a claimed quote can be released if its deal wasn't created.
+	CreateDealQuote(quote *DealQuote) error
+	GetDealQuote(id string) (*DealQuote, error)
+	ClaimDealQuote(id, dealID string, at time.Time) (bool, error)
+	ReleaseDealQuote(id string) error
+
+	// Accounting export
+	StreamAccountingDeals(filter AccountingExportFilter, fn func(*AccountingDeal) error) error
+	MarkDealsExported(dealIDs []string, batchID string, exportedAt time.Time) error
+	GetAccountingMapping(dealershipID string) (*AccountingMapping, error)
+}
+
+// DealListFilter narrows ListDeals. Empty fields are not filtered on.
+type DealListFilter struct {
+	DealershipID string
+	// CustomerID matches deals where the customer is the buyer or co-buyer
+	CustomerID string
 }
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrDocumentNotFound is returned by a DocumentStore when no object exists for a key
+var ErrDocumentNotFound = errors.New("document not found")
+
+// DocumentStore stores deal document contents. Implementations must treat
+// keys as opaque; they are generated by the service, never by callers.
+type DocumentStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// LocalDocumentStore stores documents on the local filesystem
+type LocalDocumentStore struct {
+	dir string
+}
+
+// NewLocalDocumentStore creates a filesystem document store rooted at dir
+func NewLocalDocumentStore(dir string) (*LocalDocumentStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create document directory: %w", err)
+	}
+	return &LocalDocumentStore{dir: dir}, nil
+}
+
+// path resolves a key inside the store directory, rejecting keys that would
+// escape it
+func (s *LocalDocumentStore) path(key string) (string, error) {
+	p := filepath.Join(s.dir, filepath.FromSlash(key))
+	if !strings.HasPrefix(p, filepath.Clean(s.dir)+string(os.PathSeparator)) {
+		return "", fmt.Errorf("invalid document key: %s", key)
+	}
+	return p, nil
+}
+
+// Put writes a document
+func (s *LocalDocumentStore) Put(ctx context.Context, key string, data []byte) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return fmt.Errorf("failed to create document directory: %w", err)
+	}
+	if err := os.WriteFile(p, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write document: %w", err)
+	}
+	return nil
+}
+
+// Get reads a document
+func (s *LocalDocumentStore) Get(ctx context.Context, key string) ([]byte, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+	return data, nil
+}
+
+// Delete removes a document. Deleting a missing document is not an error.
+func (s *LocalDocumentStore) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete document: %w", err)
+	}
+	return nil
+}
+
+// documentURLSigner issues and verifies time-limited document download URLs
+type documentURLSigner struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// newDocumentURLSigner creates a signer. An empty secret generates a random
+// one, so URLs only remain valid for the life of the process.
+func newDocumentURLSigner(secret string, ttl time.Duration) (*documentURLSigner, error) {
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate document URL secret: %w", err)
+		}
+	}
+	return &documentURLSigner{secret: key, ttl: ttl, now: time.Now}, nil
+}
+
+func (s *documentURLSigner) signature(dealID, documentID string, expires int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "%s:%s:%d", dealID, documentID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignedURL returns a download URL for the document and its expiry
+func (s *documentURLSigner) SignedURL(dealID, documentID string) (string, time.Time) {
+	expiresAt := s.now().Add(s.ttl).Truncate(time.Second)
+	expires := expiresAt.Unix()
+
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("signature", s.signature(dealID, documentID, expires))
+
+	return fmt.Sprintf("/api/v1/deals/%s/documents/%s/content?%s", dealID, documentID, q.Encode()), expiresAt
+}
+
+// Verify checks a download URL's expiry and signature
+func (s *documentURLSigner) Verify(dealID, documentID, expiresParam, signature string) bool {
+	expires, err := strconv.ParseInt(expiresParam, 10, 64)
+	if err != nil || s.now().Unix() > expires {
+		return false
+	}
+	expected := s.signature(dealID, documentID, expires)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
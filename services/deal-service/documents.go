@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/logging"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// maxDocumentSize is the largest deal document accepted for upload
+const maxDocumentSize = 20 << 20
+
+// maxDocumentUploadSize caps an upload's body, leaving headroom for the other
+// multipart fields
+const maxDocumentUploadSize = maxDocumentSize + 1<<20
+
+// allowedDocumentContentTypes lists the MIME types accepted for deal
+// documents. The type is sniffed from the content, not taken from the client.
+var allowedDocumentContentTypes = map[string]bool{
+	"application/pdf": true,
+	"image/jpeg":      true,
+	"image/png":       true,
+}
+
+// documentTypes lists valid deal document types. Sensitive types are
+// encrypted at rest.
+var documentTypes = map[string]struct{ sensitive bool }{
+	"contract":           {sensitive: true},
+	"drivers_license":    {sensitive: true},
+	"proof_of_income":    {sensitive: true},
+	"proof_of_residence": {sensitive: true},
+	"insurance":          {sensitive: false},
+	"title":              {sensitive: false},
+	"other":              {sensitive: false},
+}
+
+// DealDocument is the metadata for a document attached to a deal
+type DealDocument struct {
+	ID           string    `json:"id"`
+	DealID       string    `json:"deal_id"`
+	DealershipID string    `json:"dealership_id"`
+	DocumentType string    `json:"document_type"`
+	Filename     string    `json:"filename"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	Encrypted    bool      `json:"encrypted"`
+	UploadedBy   string    `json:"uploaded_by,omitempty"`
+	StorageKey   string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	// URL is a signed, time-limited download link, set on responses only
+	URL          string     `json:"url,omitempty"`
+	URLExpiresAt *time.Time `json:"url_expires_at,omitempty"`
+}
+
+// PurgeCustomerDocumentsRequest asks for every document on a customer's deals
+// to be removed, used by GDPR erasure
+type PurgeCustomerDocumentsRequest struct {
+	CustomerID   string `json:"customer_id"`
+	DealershipID string `json:"dealership_id"`
+}
+
+// documentsAvailable reports whether document storage is configured,
+// responding with 503 if not
+func (s *Server) documentsAvailable(w http.ResponseWriter) bool {
+	if s.documents == nil || s.documentSigner == nil {
+		respondErrorJSON(w, http.StatusServiceUnavailable, "Document storage is not configured", "DOCUMENTS_UNAVAILABLE")
+		return false
+	}
+	return true
+}
+
+// withSignedURL sets a download URL on the document
+func (s *Server) withSignedURL(doc *DealDocument) *DealDocument {
+	url, expiresAt := s.documentSigner.SignedURL(doc.DealID, doc.ID)
+	doc.URL = url
+	doc.URLExpiresAt = &expiresAt
+	return doc
+}
+
+// uploadDealDocument handles multipart upload of a deal document. The form
+// must contain "file" and "document_type".
+func (s *Server) uploadDealDocument(w http.ResponseWriter, r *http.Request) {
+	if !s.documentsAvailable(w) {
+		return
+	}
+	dealID := mux.Vars(r)["id"]
+
+	if !validateUUID(w, dealID, "id") {
+		return
+	}
+
+	deal, err := s.db.GetDeal(dealID)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get deal")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get deal: %v", err))
+		return
+	}
+	if deal == nil {
+		apierror.WriteError(w, http.StatusNotFound, "DEAL_NOT_FOUND", "Deal not found")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxDocumentUploadSize)
+	if err := r.ParseMultipartForm(maxDocumentSize); err != nil {
+		respondErrorJSON(w, http.StatusRequestEntityTooLarge, "Document too large (max 20MB)", "DOCUMENT_TOO_LARGE")
+		return
+	}
+
+	documentType := strings.TrimSpace(strings.ToLower(r.FormValue("document_type")))
+	docType, ok := documentTypes[documentType]
+	if !ok {
+		respondValidationError(w, &ValidationErrors{Errors: []ValidationError{{
+			Field:   "document_type",
+			Message: "Document type must be one of: contract, drivers_license, proof_of_income, proof_of_residence, insurance, title, other",
+		}}})
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		respondValidationError(w, &ValidationErrors{Errors: []ValidationError{{Field: "file", Message: "File is required"}}})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxDocumentSize+1))
+	if err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, "INVALID_DOCUMENT", "Failed to read document")
+		return
+	}
+	if len(data) > maxDocumentSize {
+		respondErrorJSON(w, http.StatusRequestEntityTooLarge, "Document too large (max 20MB)", "DOCUMENT_TOO_LARGE")
+		return
+	}
+	if len(data) == 0 {
+		respondValidationError(w, &ValidationErrors{Errors: []ValidationError{{Field: "file", Message: "File is empty"}}})
+		return
+	}
+
+	contentType := http.DetectContentType(data)
+	if !allowedDocumentContentTypes[contentType] {
+		respondErrorJSON(w, http.StatusUnsupportedMediaType, "Unsupported document type: "+contentType, "UNSUPPORTED_MEDIA_TYPE")
+		return
+	}
+
+	stored := data
+	if docType.sensitive {
+		if s.documentEncryptor == nil {
+			s.logger.WithContext(r.Context()).Error("Document encryption is not configured")
+			respondErrorJSON(w, http.StatusServiceUnavailable, "Sensitive document storage is not configured", "ENCRYPTION_UNAVAILABLE")
+			return
+		}
+		encrypted, err := s.documentEncryptor.Encrypt(string(data))
+		if err != nil {
+			s.logger.WithContext(r.Context()).WithError(err).Error("Failed to encrypt document")
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to store document")
+			return
+		}
+		stored = []byte(encrypted)
+	}
+
+	doc := &DealDocument{
+		ID:           uuid.New().String(),
+		DealID:       deal.ID,
+		DealershipID: deal.DealershipID,
+		DocumentType: documentType,
+		Filename:     filepath.Base(header.Filename),
+		ContentType:  contentType,
+		Size:         int64(len(data)),
+		Encrypted:    docType.sensitive,
+		UploadedBy:   logging.GetUserID(r.Context()),
+		CreatedAt:    time.Now(),
+	}
+	doc.StorageKey = fmt.Sprintf("%s/%s/%s", deal.DealershipID, deal.ID, doc.ID)
+
+	if err := s.documents.Put(r.Context(), doc.StorageKey, stored); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to store document")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to store document")
+		return
+	}
+
+	if err := s.db.CreateDealDocument(doc); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to save document metadata")
+		if err := s.documents.Delete(r.Context(), doc.StorageKey); err != nil {
+			s.logger.WithContext(r.Context()).WithError(err).Warn("Failed to clean up stored document")
+		}
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to save document: %v", err))
+		return
+	}
+
+	s.logger.WithContext(r.Context()).
+		WithField("deal_id", deal.ID).
+		WithField("document_id", doc.ID).
+		WithField("document_type", documentType).
+		Info("Deal document uploaded")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(s.withSignedURL(doc))
+}
+
+// listDealDocuments returns a deal's documents with signed download URLs
+func (s *Server) listDealDocuments(w http.ResponseWriter, r *http.Request) {
+	if !s.documentsAvailable(w) {
+		return
+	}
+	dealID := mux.Vars(r)["id"]
+
+	if !validateUUID(w, dealID, "id") {
+		return
+	}
+
+	docs, err := s.db.ListDealDocuments(dealID)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list deal documents")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to list deal documents: %v", err))
+		return
+	}
+
+	if docs == nil {
+		docs = []*DealDocument{}
+	}
+	for _, doc := range docs {
+		s.withSignedURL(doc)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(docs)
+}
+
+// downloadDealDocument serves a document's contents. It is reached through a
+// signed URL, so it is authorized by the signature rather than a session.
+func (s *Server) downloadDealDocument(w http.ResponseWriter, r *http.Request) {
+	if !s.documentsAvailable(w) {
+		return
+	}
+	vars := mux.Vars(r)
+	dealID, documentID := vars["id"], vars["document_id"]
+
+	q := r.URL.Query()
+	if !s.documentSigner.Verify(dealID, documentID, q.Get("expires"), q.Get("signature")) {
+		respondErrorJSON(w, http.StatusForbidden, "Invalid or expired document link", "INVALID_SIGNATURE")
+		return
+	}
+
+	doc, err := s.db.GetDealDocument(dealID, documentID)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get deal document")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get deal document: %v", err))
+		return
+	}
+	if doc == nil {
+		apierror.WriteError(w, http.StatusNotFound, "DOCUMENT_NOT_FOUND", "Document not found")
+		return
+	}
+
+	data, err := s.documents.Get(r.Context(), doc.StorageKey)
+	if errors.Is(err, ErrDocumentNotFound) {
+		apierror.WriteError(w, http.StatusNotFound, "DOCUMENT_NOT_FOUND", "Document not found")
+		return
+	}
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to read document")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read document")
+		return
+	}
+
+	if doc.Encrypted {
+		if s.documentEncryptor == nil {
+			respondErrorJSON(w, http.StatusServiceUnavailable, "Sensitive document storage is not configured", "ENCRYPTION_UNAVAILABLE")
+			return
+		}
+		decrypted, err := s.documentEncryptor.Decrypt(string(data))
+		if err != nil {
+			s.logger.WithContext(r.Context()).WithError(err).WithField("document_id", doc.ID).Error("Failed to decrypt document")
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read document")
+			return
+		}
+		data = []byte(decrypted)
+	}
+
+	w.Header().Set("Content-Type", doc.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", doc.Filename))
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.ServeContent(w, r, doc.Filename, doc.CreatedAt, bytes.NewReader(data))
+}
+
+// deleteDealDocument removes a document's contents and metadata
+func (s *Server) deleteDealDocument(w http.ResponseWriter, r *http.Request) {
+	if !s.documentsAvailable(w) {
+		return
+	}
+	vars := mux.Vars(r)
+	dealID, documentID := vars["id"], vars["document_id"]
+
+	if !validateUUID(w, dealID, "id") || !validateUUID(w, documentID, "document_id") {
+		return
+	}
+
+	doc, err := s.db.GetDealDocument(dealID, documentID)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get deal document")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get deal document: %v", err))
+		return
+	}
+	if doc == nil {
+		apierror.WriteError(w, http.StatusNotFound, "DOCUMENT_NOT_FOUND", "Document not found")
+		return
+	}
+
+	if err := s.removeDocuments(r, []*DealDocument{doc}); err != nil {
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to delete document: %v", err))
+		return
+	}
+	s.recordDocumentDeleted(r, doc)
+
+	s.logger.WithContext(r.Context()).
+		WithField("deal_id", dealID).
+		WithField("document_id", documentID).
+		Info("Deal document deleted")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// purgeCustomerDocuments removes every document on a customer's deals. It is
+// called by data-retention-service for GDPR erasure and is not exposed
+// through the gateway.
+func (s *Server) purgeCustomerDocuments(w http.ResponseWriter, r *http.Request) {
+	if !s.documentsAvailable(w) {
+		return
+	}
+	var req PurgeCustomerDocumentsRequest
+	if !decodeAndValidate(r, w, &req) {
+		return
+	}
+
+	docs, err := s.db.ListCustomerDealDocuments(req.CustomerID, req.DealershipID)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list customer documents")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to list customer documents: %v", err))
+		return
+	}
+
+	if err := s.removeDocuments(r, docs); err != nil {
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to purge documents: %v", err))
+		return
+	}
+
+	s.logger.WithContext(r.Context()).
+		WithField("customer_id", req.CustomerID).
+		WithField("documents_deleted", len(docs)).
+		Info("Customer deal documents purged")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"documents_deleted": len(docs),
+	})
+}
+
+// removeDocuments deletes document contents, then their metadata. Contents
+// go first so a failure never leaves stored data without a record of it.
+func (s *Server) removeDocuments(r *http.Request, docs []*DealDocument) error {
+	for _, doc := range docs {
+		if err := s.documents.Delete(r.Context(), doc.StorageKey); err != nil {
+			s.logger.WithContext(r.Context()).WithError(err).WithField("document_id", doc.ID).Error("Failed to delete stored document")
+			return err
+		}
+		if err := s.db.DeleteDealDocument(doc.ID); err != nil {
+			s.logger.WithContext(r.Context()).WithError(err).WithField("document_id", doc.ID).Error("Failed to delete document metadata")
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"autolytiq/shared/encryption"
+
+	"github.com/google/uuid"
+)
+
+var testPDF = []byte("%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\ntrailer\n<< /Root 1 0 R >>\n%%EOF\n")
+
+// setupDocumentServer creates a test server with filesystem document storage
+// and a test deal
+func setupDocumentServer(t *testing.T) (*Server, *Deal) {
+	t.Helper()
+	server := setupTestServer()
+
+	store, err := NewLocalDocumentStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create document store: %v", err)
+	}
+	server.documents = store
+
+	km, err := encryption.NewKeyManagerWithKeys(map[string][]byte{"v1": bytes.Repeat([]byte("k"), 32)}, "v1")
+	if err != nil {
+		t.Fatalf("Failed to create key manager: %v", err)
+	}
+	server.documentEncryptor = encryption.NewEncryptor(km)
+
+	deal := &Deal{
+		ID:           uuid.New().String(),
+		DealershipID: uuid.New().String(),
+		CustomerID:   uuid.New().String(),
+		VehicleID:    uuid.New().String(),
+		VehiclePrice: 30000,
+		Status:       "PENDING",
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	server.db.CreateDeal(deal)
+
+	return server, deal
+}
+
+func uploadRequest(t *testing.T, dealID, documentType, filename string, content []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("document_type", documentType)
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	mw.Close()
+
+	req, err := http.NewRequest("POST", "/deals/"+dealID+"/documents", &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func uploadDocument(t *testing.T, server *Server, dealID, documentType string, content []byte) *DealDocument {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, uploadRequest(t, dealID, documentType, "file.pdf", content))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("upload returned wrong status code: got %v want %v: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+
+	var doc DealDocument
+	if err := json.NewDecoder(rr.Body).Decode(&doc); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return &doc
+}
+
+// contentPath strips the gateway prefix from a signed URL
+func contentPath(url string) string {
+	return strings.TrimPrefix(url, "/api/v1")
+}
+
+func TestUploadAndDownloadDocument(t *testing.T) {
+	server, deal := setupDocumentServer(t)
+
+	doc := uploadDocument(t, server, deal.ID, "insurance", testPDF)
+	if doc.ContentType != "application/pdf" {
+		t.Errorf("expected content type application/pdf, got %s", doc.ContentType)
+	}
+	if doc.Size != int64(len(testPDF)) {
+		t.Errorf("expected size %d, got %d", len(testPDF), doc.Size)
+	}
+	if doc.Encrypted {
+		t.Error("expected insurance document to be stored unencrypted")
+	}
+	if doc.URL == "" || doc.URLExpiresAt == nil {
+		t.Fatal("expected a signed URL in the response")
+	}
+
+	req, _ := http.NewRequest("GET", "/deals/"+deal.ID+"/documents", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("list returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var docs []DealDocument
+	json.NewDecoder(rr.Body).Decode(&docs)
+	if len(docs) != 1 || docs[0].ID != doc.ID || docs[0].URL == "" {
+		t.Fatalf("expected the uploaded document with a signed URL, got %+v", docs)
+	}
+
+	req, _ = http.NewRequest("GET", contentPath(docs[0].URL), nil)
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("download returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if !bytes.Equal(rr.Body.Bytes(), testPDF) {
+		t.Error("downloaded content does not match upload")
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/pdf" {
+		t.Errorf("expected Content-Type application/pdf, got %s", ct)
+	}
+}
+
+func TestDownloadDocumentRejectsBadSignature(t *testing.T) {
+	server, deal := setupDocumentServer(t)
+	doc := uploadDocument(t, server, deal.ID, "title", testPDF)
+
+	tampered := strings.Replace(contentPath(doc.URL), "signature=", "signature=00", 1)
+	req, _ := http.NewRequest("GET", tampered, nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for tampered signature, got %v", rr.Code)
+	}
+
+	// Links for one document must not open another
+	other := uploadDocument(t, server, deal.ID, "title", testPDF)
+	swapped := strings.Replace(contentPath(doc.URL), doc.ID, other.ID, 1)
+	req, _ = http.NewRequest("GET", swapped, nil)
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for signature from another document, got %v", rr.Code)
+	}
+
+	server.documentSigner.now = func() time.Time { return time.Now().Add(time.Hour) }
+	req, _ = http.NewRequest("GET", contentPath(doc.URL), nil)
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for expired link, got %v", rr.Code)
+	}
+}
+
+func TestUploadDocumentLimits(t *testing.T) {
+	server, deal := setupDocumentServer(t)
+
+	tests := []struct {
+		name         string
+		documentType string
+		content      []byte
+		wantStatus   int
+	}{
+		{"plain text", "other", []byte("just some text"), http.StatusUnsupportedMediaType},
+		{"html", "other", []byte("<html><body>hi</body></html>"), http.StatusUnsupportedMediaType},
+		{"unknown document type", "selfie", testPDF, http.StatusBadRequest},
+		{"empty file", "other", []byte{}, http.StatusBadRequest},
+		{"too large", "other", append(append([]byte{}, testPDF...), make([]byte, maxDocumentSize)...), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, uploadRequest(t, deal.ID, tt.documentType, "upload", tt.content))
+			if rr.Code != tt.wantStatus {
+				t.Errorf("expected status %v, got %v: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	if len(server.db.(*MockDatabase).documents) != 0 {
+		t.Error("expected no documents to be saved")
+	}
+}
+
+func TestSensitiveDocumentEncryptedAtRest(t *testing.T) {
+	server, deal := setupDocumentServer(t)
+
+	doc := uploadDocument(t, server, deal.ID, "drivers_license", testPDF)
+	if !doc.Encrypted {
+		t.Fatal("expected drivers_license to be encrypted")
+	}
+
+	stored := server.db.(*MockDatabase).documents[doc.ID]
+	raw, err := server.documents.Get(context.Background(), stored.StorageKey)
+	if err != nil {
+		t.Fatalf("Failed to read stored document: %v", err)
+	}
+	if bytes.Contains(raw, []byte("%PDF")) || !server.documentEncryptor.IsEncrypted(string(raw)) {
+		t.Error("expected stored content to be encrypted")
+	}
+
+	req, _ := http.NewRequest("GET", contentPath(doc.URL), nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("download returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if !bytes.Equal(rr.Body.Bytes(), testPDF) {
+		t.Error("expected decrypted content on download")
+	}
+}
+
+func TestSensitiveDocumentRequiresEncryption(t *testing.T) {
+	server, deal := setupDocumentServer(t)
+	server.documentEncryptor = nil
+
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, uploadRequest(t, deal.ID, "contract", "contract.pdf", testPDF))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without encryption, got %v", rr.Code)
+	}
+}
+
+func TestDocumentsUnavailableWithoutStorage(t *testing.T) {
+	server := setupTestServer()
+	dealID := uuid.New().String()
+
+	req, _ := http.NewRequest("GET", "/deals/"+dealID+"/documents", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without document storage, got %v", rr.Code)
+	}
+}
+
+func TestDeleteDocument(t *testing.T) {
+	server, deal := setupDocumentServer(t)
+	doc := uploadDocument(t, server, deal.ID, "title", testPDF)
+	storageKey := server.db.(*MockDatabase).documents[doc.ID].StorageKey
+
+	req, _ := http.NewRequest("DELETE", "/deals/"+deal.ID+"/documents/"+doc.ID, nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("delete returned wrong status code: got %v want %v", rr.Code, http.StatusNoContent)
+	}
+
+	if _, err := server.documents.Get(context.Background(), storageKey); err != ErrDocumentNotFound {
+		t.Errorf("expected stored document to be removed, got %v", err)
+	}
+	if _, exists := server.db.(*MockDatabase).documents[doc.ID]; exists {
+		t.Error("expected document metadata to be removed")
+	}
+}
+
+func TestPurgeCustomerDocuments(t *testing.T) {
+	server, deal := setupDocumentServer(t)
+	uploadDocument(t, server, deal.ID, "contract", testPDF)
+	uploadDocument(t, server, deal.ID, "insurance", testPDF)
+
+	otherDeal := &Deal{
+		ID:           uuid.New().String(),
+		DealershipID: deal.DealershipID,
+		CustomerID:   uuid.New().String(),
+	}
+	server.db.CreateDeal(otherDeal)
+	kept := uploadDocument(t, server, otherDeal.ID, "insurance", testPDF)
+
+	body, _ := json.Marshal(PurgeCustomerDocumentsRequest{
+		CustomerID:   deal.CustomerID,
+		DealershipID: deal.DealershipID,
+	})
+	req, _ := http.NewRequest("POST", "/deals/documents/purge", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("purge returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	var resp map[string]int
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp["documents_deleted"] != 2 {
+		t.Errorf("expected 2 documents deleted, got %d", resp["documents_deleted"])
+	}
+
+	docs := server.db.(*MockDatabase).documents
+	if len(docs) != 1 || docs[kept.ID] == nil {
+		t.Errorf("expected only the other customer's document to remain, got %d", len(docs))
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"autolytiq/shared/logging"
+)
+
+// esignSignatureHeader carries the hex HMAC-SHA256 of a webhook body,
+// prefixed with "sha256="
+const esignSignatureHeader = "X-ESign-Signature"
+
+// ESignClient sends envelopes to an HTTP e-sign provider and verifies its
+// webhooks with a shared secret
+type ESignClient struct {
+	baseURL       string
+	apiKey        string
+	webhookSecret []byte
+	httpClient    *http.Client
+}
+
+// NewESignClient creates a client for the provider at baseURL
+func NewESignClient(baseURL, apiKey, webhookSecret string) *ESignClient {
+	return &ESignClient{
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		apiKey:        apiKey,
+		webhookSecret: []byte(webhookSecret),
+		httpClient:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Send posts the envelope to the provider and returns its envelope ID
+func (c *ESignClient) Send(r *http.Request, envelope *SignatureEnvelope) (string, error) {
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode envelope: %w", err)
+	}
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, c.baseURL+"/envelopes", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to build envelope request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if traceID := logging.GetTraceID(r.Context()); traceID != "" {
+		req.Header.Set(logging.RequestIDHeader, traceID)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send envelope: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("e-sign provider returned status %d", resp.StatusCode)
+	}
+
+	var created struct {
+		EnvelopeID string `json:"envelope_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", fmt.Errorf("failed to decode envelope response: %w", err)
+	}
+	if created.EnvelopeID == "" {
+		return "", fmt.Errorf("e-sign provider returned no envelope ID")
+	}
+	return created.EnvelopeID, nil
+}
+
+// ParseWebhook checks the body's signature and decodes its event
+func (c *ESignClient) ParseWebhook(r *http.Request, body []byte) (*SignatureEvent, error) {
+	signature, err := hex.DecodeString(strings.TrimPrefix(r.Header.Get(esignSignatureHeader), "sha256="))
+	if err != nil || len(signature) == 0 {
+		return nil, fmt.Errorf("missing or malformed %s header", esignSignatureHeader)
+	}
+
+	mac := hmac.New(sha256.New, c.webhookSecret)
+	mac.Write(body)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, fmt.Errorf("webhook signature mismatch")
+	}
+
+	var event SignatureEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook: %w", err)
+	}
+	return &event, nil
+}
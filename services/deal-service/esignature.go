@@ -0,0 +1,463 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/logging"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// maxSignatureWebhookSize is the largest e-sign webhook body accepted
+const maxSignatureWebhookSize = 1 << 20
+
+// Signature request and signer statuses. A request is signed once every
+// signer has signed, and declined as soon as any signer declines.
+const (
+	SignatureStatusSent     = "sent"
+	SignatureStatusViewed   = "viewed"
+	SignatureStatusSigned   = "signed"
+	SignatureStatusDeclined = "declined"
+)
+
+// signatureStatusRank orders a signer's statuses. Events never move a signer
+// back, and signed and declined are final.
+var signatureStatusRank = map[string]int{
+	SignatureStatusSent:     0,
+	SignatureStatusViewed:   1,
+	SignatureStatusSigned:   2,
+	SignatureStatusDeclined: 2,
+}
+
+// buyersOrderDocumentName names the buyer's order in signature envelopes
+const buyersOrderDocumentName = "buyers_order.html"
+
+// SignatureSigner is a buyer asked to sign a deal's documents
+type SignatureSigner struct {
+	CustomerID string     `json:"customer_id"`
+	Role       string     `json:"role"`
+	Name       string     `json:"name"`
+	Email      string     `json:"email"`
+	Status     string     `json:"status"`
+	SignedAt   *time.Time `json:"signed_at,omitempty"`
+}
+
+// SignedDocument references a signed copy held by the e-sign provider
+type SignedDocument struct {
+	Name      string `json:"name"`
+	Reference string `json:"reference"`
+	URL       string `json:"url,omitempty"`
+}
+
+// SignatureRequest tracks a deal's documents sent to its buyers for
+// signature
+type SignatureRequest struct {
+	ID              string            `json:"id"`
+	DealID          string            `json:"deal_id"`
+	DealershipID    string            `json:"dealership_id"`
+	EnvelopeID      string            `json:"envelope_id"`
+	Status          string            `json:"status"`
+	Documents       []string          `json:"documents"`
+	Signers         []SignatureSigner `json:"signers"`
+	SignedDocuments []SignedDocument  `json:"signed_documents"`
+	RequestedBy     string            `json:"requested_by,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	CompletedAt     *time.Time        `json:"completed_at,omitempty"`
+}
+
+// EnvelopeDocument is a document sent for signature. Content is base64
+// encoded in JSON.
+type EnvelopeDocument struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Content     []byte `json:"content"`
+}
+
+// SignatureEnvelope is what is sent to the e-sign provider. It carries
+// document contents and is never stored.
+type SignatureEnvelope struct {
+	RequestID    string             `json:"request_id"`
+	DealID       string             `json:"deal_id"`
+	DealershipID string             `json:"dealership_id"`
+	Documents    []EnvelopeDocument `json:"documents"`
+	Signers      []SignatureSigner  `json:"signers"`
+}
+
+// SignatureEvent is a status update from the e-sign provider. Events that
+// name no signer apply to every signer.
+type SignatureEvent struct {
+	EnvelopeID      string           `json:"envelope_id"`
+	Status          string           `json:"status"`
+	SignerEmail     string           `json:"signer_email,omitempty"`
+	SignedDocuments []SignedDocument `json:"signed_documents,omitempty"`
+	OccurredAt      time.Time        `json:"occurred_at"`
+}
+
+// ESignProvider sends documents for signature and reports their progress
+// through webhook callbacks
+type ESignProvider interface {
+	// Send creates an envelope and returns the provider's ID for it
+	Send(r *http.Request, envelope *SignatureEnvelope) (string, error)
+
+	// ParseWebhook authenticates a webhook callback and returns its event
+	ParseWebhook(r *http.Request, body []byte) (*SignatureEvent, error)
+}
+
+// apply updates the request's signers from the event and returns whether
+// anything changed. Completed requests are not changed.
+func (req *SignatureRequest) apply(event *SignatureEvent) bool {
+	if req.Status == SignatureStatusSigned || req.Status == SignatureStatusDeclined {
+		return false
+	}
+
+	changed := false
+	for i := range req.Signers {
+		signer := &req.Signers[i]
+		if event.SignerEmail != "" && !strings.EqualFold(signer.Email, event.SignerEmail) {
+			continue
+		}
+		if signatureStatusRank[event.Status] <= signatureStatusRank[signer.Status] {
+			continue
+		}
+		signer.Status = event.Status
+		if event.Status == SignatureStatusSigned {
+			signedAt := event.OccurredAt
+			signer.SignedAt = &signedAt
+		}
+		changed = true
+	}
+	if len(event.SignedDocuments) > 0 {
+		req.SignedDocuments = append(req.SignedDocuments, event.SignedDocuments...)
+		changed = true
+	}
+	if !changed {
+		return false
+	}
+
+	req.Status = signatureRequestStatus(req.Signers)
+	req.UpdatedAt = event.OccurredAt
+	if req.Status == SignatureStatusSigned || req.Status == SignatureStatusDeclined {
+		completedAt := event.OccurredAt
+		req.CompletedAt = &completedAt
+	}
+	return true
+}
+
+// signatureRequestStatus derives a request's status from its signers
+func signatureRequestStatus(signers []SignatureSigner) string {
+	signed, viewed := 0, false
+	for _, signer := range signers {
+		switch signer.Status {
+		case SignatureStatusDeclined:
+			return SignatureStatusDeclined
+		case SignatureStatusSigned:
+			signed++
+			viewed = true
+		case SignatureStatusViewed:
+			viewed = true
+		}
+	}
+	switch {
+	case len(signers) > 0 && signed == len(signers):
+		return SignatureStatusSigned
+	case viewed:
+		return SignatureStatusViewed
+	}
+	return SignatureStatusSent
+}
+
+// signatureDocuments packages the buyer's order and the deal's contracts
+func (s *Server) signatureDocuments(r *http.Request, deal *Deal, buyer, coBuyer *DealCustomer) ([]EnvelopeDocument, error) {
+	order, err := s.renderBuyersOrder(r, deal, buyer, coBuyer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render buyer's order: %w", err)
+	}
+	documents := []EnvelopeDocument{{Name: buyersOrderDocumentName, ContentType: "text/html", Content: order}}
+
+	if s.documents == nil {
+		return documents, nil
+	}
+	docs, err := s.db.ListDealDocuments(deal.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deal documents: %w", err)
+	}
+	for _, doc := range docs {
+		if doc.DocumentType != "contract" {
+			continue
+		}
+		data, err := s.documents.Get(r.Context(), doc.StorageKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read document %s: %w", doc.ID, err)
+		}
+		if doc.Encrypted {
+			if s.documentEncryptor == nil {
+				return nil, fmt.Errorf("document %s is encrypted and encryption is not configured", doc.ID)
+			}
+			decrypted, err := s.documentEncryptor.Decrypt(string(data))
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt document %s: %w", doc.ID, err)
+			}
+			data = []byte(decrypted)
+		}
+		documents = append(documents, EnvelopeDocument{Name: doc.Filename, ContentType: doc.ContentType, Content: data})
+	}
+	return documents, nil
+}
+
+// latestSignatureRequest returns the deal's newest signature request, or nil
+func (s *Server) latestSignatureRequest(dealID string) (*SignatureRequest, error) {
+	requests, err := s.db.ListSignatureRequests(dealID)
+	if err != nil || len(requests) == 0 {
+		return nil, err
+	}
+	return requests[0], nil
+}
+
+// checkFundingSignatures responds and returns false if the deal can't be
+// funded because its latest signature request isn't signed. Deals that never
+// had signatures requested can be funded unless signatures are required.
+func (s *Server) checkFundingSignatures(w http.ResponseWriter, r *http.Request, deal *Deal) bool {
+	latest, err := s.latestSignatureRequest(deal.ID)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get signature requests")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get signature requests: %v", err))
+		return false
+	}
+
+	switch {
+	case latest == nil && !s.config.RequireSignaturesForFunding:
+		return true
+	case latest == nil:
+		respondErrorJSON(w, http.StatusConflict, "Deal documents must be signed before funding", "SIGNATURES_REQUIRED")
+		return false
+	case latest.Status != SignatureStatusSigned:
+		respondErrorJSON(w, http.StatusConflict, "Deal signatures are "+latest.Status+" and must be complete before funding", "SIGNATURES_INCOMPLETE")
+		return false
+	}
+	return true
+}
+
+// requestDealSignatures sends the deal's buyer's order and contracts to its
+// buyers for signature
+func (s *Server) requestDealSignatures(w http.ResponseWriter, r *http.Request) {
+	if s.esign == nil {
+		respondErrorJSON(w, http.StatusServiceUnavailable, "E-signature is not configured", "ESIGN_UNAVAILABLE")
+		return
+	}
+
+	deal, buyer, coBuyer, ok := s.loadDealCustomers(w, r)
+	if !ok {
+		return
+	}
+
+	switch deal.Status {
+	case "funded", "delivered", "cancelled":
+		respondErrorJSON(w, http.StatusConflict, "Cannot request signatures on a "+deal.Status+" deal", "INVALID_DEAL_STATUS")
+		return
+	}
+
+	latest, err := s.latestSignatureRequest(deal.ID)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get signature requests")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get signature requests: %v", err))
+		return
+	}
+	if latest != nil && (latest.Status == SignatureStatusSent || latest.Status == SignatureStatusViewed) {
+		respondErrorJSON(w, http.StatusConflict, "Deal already has a signature request awaiting signatures", "SIGNATURE_PENDING")
+		return
+	}
+
+	var signers []SignatureSigner
+	applicants := []struct {
+		customer *DealCustomer
+		role     string
+		label    string
+	}{{buyer, ApplicantRoleBuyer, "buyer"}, {coBuyer, ApplicantRoleCoBuyer, "co-buyer"}}
+	for _, a := range applicants {
+		if a.customer == nil {
+			continue
+		}
+		if a.customer.Email == "" {
+			respondErrorJSON(w, http.StatusConflict, "The "+a.label+" has no email address to send the signing request to", "SIGNER_EMAIL_MISSING")
+			return
+		}
+		signers = append(signers, SignatureSigner{
+			CustomerID: a.customer.ID,
+			Role:       a.role,
+			Name:       a.customer.fullName(),
+			Email:      a.customer.Email,
+			Status:     SignatureStatusSent,
+		})
+	}
+
+	documents, err := s.signatureDocuments(r, deal, buyer, coBuyer)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).WithField("deal_id", deal.ID).Error("Failed to package signature documents")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to package documents for signature")
+		return
+	}
+
+	now := time.Now()
+	request := &SignatureRequest{
+		ID:              uuid.New().String(),
+		DealID:          deal.ID,
+		DealershipID:    deal.DealershipID,
+		Status:          SignatureStatusSent,
+		Signers:         signers,
+		SignedDocuments: []SignedDocument{},
+		RequestedBy:     logging.GetUserID(r.Context()),
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	for _, doc := range documents {
+		request.Documents = append(request.Documents, doc.Name)
+	}
+
+	request.EnvelopeID, err = s.esign.Send(r, &SignatureEnvelope{
+		RequestID:    request.ID,
+		DealID:       deal.ID,
+		DealershipID: deal.DealershipID,
+		Documents:    documents,
+		Signers:      signers,
+	})
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).WithField("deal_id", deal.ID).Error("Failed to send signature request")
+		respondErrorJSON(w, http.StatusBadGateway, "Failed to send signature request", "ESIGN_FAILED")
+		return
+	}
+
+	if err := s.db.CreateSignatureRequest(request); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to save signature request")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to save signature request: %v", err))
+		return
+	}
+	s.recordSignatureStatus(r, request, "")
+
+	s.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
+		"deal_id":     deal.ID,
+		"request_id":  request.ID,
+		"envelope_id": request.EnvelopeID,
+		"signers":     len(signers),
+		"documents":   len(documents),
+	}).Info("Signature request sent")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(request)
+}
+
+// listSignatureRequests returns a deal's signature requests, newest first
+func (s *Server) listSignatureRequests(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if !validateUUID(w, id, "id") {
+		return
+	}
+
+	requests, err := s.db.ListSignatureRequests(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list signature requests")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to list signature requests: %v", err))
+		return
+	}
+
+	if requests == nil {
+		requests = []*SignatureRequest{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(requests)
+}
+
+// handleSignatureWebhook applies a status update from the e-sign provider.
+// It is called by the provider, so it is authorized by the provider's
+// signature rather than a session.
+func (s *Server) handleSignatureWebhook(w http.ResponseWriter, r *http.Request) {
+	if s.esign == nil {
+		respondErrorJSON(w, http.StatusServiceUnavailable, "E-signature is not configured", "ESIGN_UNAVAILABLE")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSignatureWebhookSize))
+	if err != nil {
+		respondErrorJSON(w, http.StatusRequestEntityTooLarge, "Webhook body too large", "BODY_TOO_LARGE")
+		return
+	}
+
+	event, err := s.esign.ParseWebhook(r, body)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Warn("Rejected e-sign webhook")
+		respondErrorJSON(w, http.StatusUnauthorized, "Invalid webhook signature", "INVALID_SIGNATURE")
+		return
+	}
+	if _, ok := signatureStatusRank[event.Status]; !ok || event.EnvelopeID == "" {
+		respondValidationError(w, &ValidationErrors{Errors: []ValidationError{{
+			Field:   "status",
+			Message: "Webhook must name an envelope and a status of sent, viewed, signed or declined",
+		}}})
+		return
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	request, err := s.db.GetSignatureRequestByEnvelope(event.EnvelopeID)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get signature request")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get signature request: %v", err))
+		return
+	}
+	if request == nil {
+		apierror.WriteError(w, http.StatusNotFound, "SIGNATURE_REQUEST_NOT_FOUND", "Signature request not found")
+		return
+	}
+
+	previous := request.Status
+	if request.apply(event) {
+		if err := s.db.UpdateSignatureRequest(request); err != nil {
+			s.logger.WithContext(r.Context()).WithError(err).Error("Failed to update signature request")
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to update signature request: %v", err))
+			return
+		}
+		if request.Status != previous {
+			s.recordSignatureStatus(r, request, previous)
+		}
+
+		s.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
+			"deal_id":    request.DealID,
+			"request_id": request.ID,
+			"event":      event.Status,
+			"status":     request.Status,
+		}).Info("Signature request updated")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(request)
+}
+
+// recordSignatureStatus records a signature request's status change in the
+// deal's history
+func (s *Server) recordSignatureStatus(r *http.Request, request *SignatureRequest, previous string) {
+	entry := &DealHistoryEntry{
+		ID:        uuid.New().String(),
+		DealID:    request.DealID,
+		Field:     "signature_request",
+		NewValue:  fmt.Sprintf("%s %s", request.ID, request.Status),
+		ChangedBy: logging.GetUserID(r.Context()),
+		ChangedAt: request.UpdatedAt,
+	}
+	if previous != "" {
+		entry.OldValue = fmt.Sprintf("%s %s", request.ID, previous)
+	}
+	if err := s.db.CreateDealHistory(entry); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).WithField("deal_id", request.DealID).Warn("Failed to record deal history")
+	}
+}
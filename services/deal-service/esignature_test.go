@@ -0,0 +1,227 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// mockESign records the envelopes it is sent and accepts webhooks whose
+// X-ESign-Signature header is "valid"
+type mockESign struct {
+	envelopes []SignatureEnvelope
+	sendErr   error
+}
+
+func (p *mockESign) Send(r *http.Request, envelope *SignatureEnvelope) (string, error) {
+	if p.sendErr != nil {
+		return "", p.sendErr
+	}
+	p.envelopes = append(p.envelopes, *envelope)
+	return fmt.Sprintf("env-%d", len(p.envelopes)), nil
+}
+
+func (p *mockESign) ParseWebhook(r *http.Request, body []byte) (*SignatureEvent, error) {
+	if r.Header.Get(esignSignatureHeader) != "valid" {
+		return nil, errors.New("invalid signature")
+	}
+	var event SignatureEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// setupESignServer returns a server with a co-buyer deal whose buyers have
+// email addresses on file
+func setupESignServer(t *testing.T) (*Server, *mockESign, Deal) {
+	t.Helper()
+	server, dealershipID, buyerID, coBuyerID, _ := setupBuyersServer()
+	stub := server.customers.(*stubCustomers)
+	stub.customers[buyerID].Email = "jordan@example.com"
+	stub.customers[coBuyerID].Email = "sam@example.com"
+
+	provider := &mockESign{}
+	server.esign = provider
+	deal := createCoBuyerDeal(t, server, dealershipID, buyerID, coBuyerID)
+	moveDeal(t, server, deal.ID, "pending", "approved")
+	return server, provider, deal
+}
+
+func sendSignatureWebhook(t *testing.T, server *Server, event SignatureEvent, signature string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(event)
+	req := httptest.NewRequest("POST", "/deals/signature/webhook", strings.NewReader(string(body)))
+	req.Header.Set(esignSignatureHeader, signature)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestSignatureRequestLifecycle(t *testing.T) {
+	server, provider, deal := setupESignServer(t)
+
+	rr := sendDealRequest(t, server, "POST", "/deals/"+deal.ID+"/signature-request", nil, "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var request SignatureRequest
+	json.Unmarshal(rr.Body.Bytes(), &request)
+	if request.Status != SignatureStatusSent || request.EnvelopeID != "env-1" || len(request.Signers) != 2 {
+		t.Fatalf("Expected a sent request for both buyers, got %+v", request)
+	}
+
+	// The envelope carries the buyer's order for both signers
+	if len(provider.envelopes) != 1 {
+		t.Fatalf("Expected one envelope, got %d", len(provider.envelopes))
+	}
+	envelope := provider.envelopes[0]
+	if len(envelope.Documents) != 1 || envelope.Documents[0].Name != buyersOrderDocumentName ||
+		!strings.Contains(string(envelope.Documents[0].Content), "Jordan") {
+		t.Errorf("Expected the buyer's order in the envelope, got %+v", envelope.Documents)
+	}
+	if envelope.Signers[0].Email != "jordan@example.com" || envelope.Signers[1].Email != "sam@example.com" {
+		t.Errorf("Expected both buyers as signers, got %+v", envelope.Signers)
+	}
+
+	// A second request while this one is outstanding is refused
+	if rr := sendDealRequest(t, server, "POST", "/deals/"+deal.ID+"/signature-request", nil, ""); rr.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a second request, got %d", rr.Code)
+	}
+
+	// Funding is blocked until both buyers sign
+	fund := UpdateDealRequest{Status: "funded"}
+	if rr := sendDealRequest(t, server, "PUT", "/deals/"+deal.ID, fund, ""); rr.Code != http.StatusConflict {
+		t.Fatalf("Expected funding to be blocked, got %d", rr.Code)
+	}
+
+	events := []struct {
+		event SignatureEvent
+		want  string
+	}{
+		{SignatureEvent{EnvelopeID: "env-1", Status: SignatureStatusViewed}, SignatureStatusViewed},
+		{SignatureEvent{EnvelopeID: "env-1", Status: SignatureStatusSigned, SignerEmail: "Jordan@example.com"}, SignatureStatusViewed},
+		{SignatureEvent{EnvelopeID: "env-1", Status: SignatureStatusSigned, SignerEmail: "sam@example.com",
+			SignedDocuments: []SignedDocument{{Name: buyersOrderDocumentName, Reference: "doc-signed-1"}}}, SignatureStatusSigned},
+	}
+	for _, e := range events {
+		rr := sendSignatureWebhook(t, server, e.event, "valid")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200 from webhook, got %d: %s", rr.Code, rr.Body.String())
+		}
+		json.Unmarshal(rr.Body.Bytes(), &request)
+		if request.Status != e.want {
+			t.Errorf("Expected status %s after %s event, got %s", e.want, e.event.Status, request.Status)
+		}
+	}
+
+	if request.CompletedAt == nil || len(request.SignedDocuments) != 1 || request.SignedDocuments[0].Reference != "doc-signed-1" {
+		t.Errorf("Expected a completed request with the signed document reference, got %+v", request)
+	}
+	for _, signer := range request.Signers {
+		if signer.Status != SignatureStatusSigned || signer.SignedAt == nil {
+			t.Errorf("Expected %s to have signed, got %+v", signer.Email, signer)
+		}
+	}
+
+	// Completed requests ignore late events
+	sendSignatureWebhook(t, server, SignatureEvent{EnvelopeID: "env-1", Status: SignatureStatusDeclined}, "valid")
+	requests, _ := server.db.ListSignatureRequests(deal.ID)
+	if requests[0].Status != SignatureStatusSigned {
+		t.Errorf("Expected the signed request to stay signed, got %s", requests[0].Status)
+	}
+
+	if rr := sendDealRequest(t, server, "PUT", "/deals/"+deal.ID, fund, ""); rr.Code != http.StatusOK {
+		t.Fatalf("Expected funding to succeed once signed, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestDeclinedSignatureBlocksFunding(t *testing.T) {
+	server, _, deal := setupESignServer(t)
+
+	if rr := sendDealRequest(t, server, "POST", "/deals/"+deal.ID+"/signature-request", nil, ""); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", rr.Code)
+	}
+	sendSignatureWebhook(t, server, SignatureEvent{EnvelopeID: "env-1", Status: SignatureStatusDeclined, SignerEmail: "sam@example.com"}, "valid")
+
+	if rr := sendDealRequest(t, server, "PUT", "/deals/"+deal.ID, UpdateDealRequest{Status: "funded"}, ""); rr.Code != http.StatusConflict {
+		t.Errorf("Expected funding to be blocked after a decline, got %d", rr.Code)
+	}
+
+	// A declined request can be replaced with a new one
+	if rr := sendDealRequest(t, server, "POST", "/deals/"+deal.ID+"/signature-request", nil, ""); rr.Code != http.StatusCreated {
+		t.Errorf("Expected a new request after a decline, got %d", rr.Code)
+	}
+}
+
+func TestSignatureWebhookRejectsInvalidSignature(t *testing.T) {
+	server, _, deal := setupESignServer(t)
+	sendDealRequest(t, server, "POST", "/deals/"+deal.ID+"/signature-request", nil, "")
+
+	rr := sendSignatureWebhook(t, server, SignatureEvent{EnvelopeID: "env-1", Status: SignatureStatusSigned}, "forged")
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a forged webhook, got %d", rr.Code)
+	}
+	requests, _ := server.db.ListSignatureRequests(deal.ID)
+	if requests[0].Status != SignatureStatusSent {
+		t.Errorf("Expected the request to be unchanged, got %s", requests[0].Status)
+	}
+
+	rr = sendSignatureWebhook(t, server, SignatureEvent{EnvelopeID: "unknown", Status: SignatureStatusSigned}, "valid")
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown envelope, got %d", rr.Code)
+	}
+}
+
+func TestSignatureRequestRequiresSignerEmail(t *testing.T) {
+	server, provider, deal := setupESignServer(t)
+	server.customers.(*stubCustomers).customers[deal.CoCustomerID].Email = ""
+
+	rr := sendDealRequest(t, server, "POST", "/deals/"+deal.ID+"/signature-request", nil, "")
+	if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), "co-buyer") {
+		t.Errorf("Expected 409 naming the co-buyer, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(provider.envelopes) != 0 {
+		t.Error("Expected nothing to be sent")
+	}
+}
+
+func TestRequireSignaturesForFunding(t *testing.T) {
+	server, _, deal := setupESignServer(t)
+	server.config.RequireSignaturesForFunding = true
+
+	rr := sendDealRequest(t, server, "PUT", "/deals/"+deal.ID, UpdateDealRequest{Status: "funded"}, "")
+	if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), "SIGNATURES_REQUIRED") {
+		t.Errorf("Expected funding without signatures to be blocked, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestESignClientVerifiesWebhookSignature(t *testing.T) {
+	client := NewESignClient("http://esign.example", "", "webhook-secret")
+	body := []byte(`{"envelope_id":"env-1","status":"signed"}`)
+
+	mac := hmac.New(sha256.New, []byte("webhook-secret"))
+	mac.Write(body)
+	req := httptest.NewRequest("POST", "/deals/signature/webhook", nil)
+	req.Header.Set(esignSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	event, err := client.ParseWebhook(req, body)
+	if err != nil || event.EnvelopeID != "env-1" || event.Status != SignatureStatusSigned {
+		t.Fatalf("Expected the signed webhook to parse, got %+v, %v", event, err)
+	}
+
+	if _, err := client.ParseWebhook(req, []byte(`{"envelope_id":"env-1","status":"declined"}`)); err == nil {
+		t.Error("Expected a tampered body to be rejected")
+	}
+	req.Header.Del(esignSignatureHeader)
+	if _, err := client.ParseWebhook(req, body); err == nil {
+		t.Error("Expected a missing signature to be rejected")
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"autolytiq/shared/apierror"
+
+	"github.com/gorilla/mux"
+)
+
+// PaymentScheduleRequest is the financing to build a deal's payment schedule
+// for
+type PaymentScheduleRequest struct {
+	APR        float64 `json:"apr"`
+	TermMonths int     `json:"term_months"`
+
+	// FirstPaymentDate is in YYYY-MM-DD format. It defaults to a month from
+	// today.
+	FirstPaymentDate string `json:"first_payment_date,omitempty"`
+}
+
+// AmortizationPeriod is one payment of an amortization schedule
+type AmortizationPeriod struct {
+	Period      int     `json:"period"`
+	PaymentDate string  `json:"payment_date"`
+	Payment     float64 `json:"payment"`
+	Principal   float64 `json:"principal"`
+	Interest    float64 `json:"interest"`
+	Balance     float64 `json:"balance"`
+}
+
+// PaymentSchedule is the response for a deal's payment schedule
+type PaymentSchedule struct {
+	DealID          string               `json:"deal_id"`
+	Principal       float64              `json:"principal"`
+	APR             float64              `json:"apr"`
+	TermMonths      int                  `json:"term_months"`
+	MonthlyPayment  float64              `json:"monthly_payment"`
+	TotalInterest   float64              `json:"total_interest"`
+	TotalOfPayments float64              `json:"total_of_payments"`
+	Schedule        []AmortizationPeriod `json:"schedule"`
+}
+
+// Validate validates PaymentScheduleRequest
+func (r *PaymentScheduleRequest) Validate() *ValidationErrors {
+	var errors []ValidationError
+
+	if r.APR < 0 || r.APR > 100 {
+		errors = append(errors, ValidationError{
+			Field:   "apr",
+			Message: "APR must be between 0 and 100",
+		})
+	}
+
+	if r.TermMonths < 1 || r.TermMonths > 120 {
+		errors = append(errors, ValidationError{
+			Field:   "term_months",
+			Message: "Term must be between 1 and 120 months",
+		})
+	}
+
+	if r.FirstPaymentDate != "" {
+		if _, err := time.Parse("2006-01-02", r.FirstPaymentDate); err != nil {
+			errors = append(errors, ValidationError{
+				Field:   "first_payment_date",
+				Message: "Must be a date in YYYY-MM-DD format",
+			})
+		}
+	}
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize sanitizes PaymentScheduleRequest
+func (r *PaymentScheduleRequest) Sanitize() {
+	r.APR = roundRate(r.APR)
+}
+
+// AmortizationSchedule returns the level monthly payment for a principal at
+// an APR (in percent) over the given number of months, and the payment
+// schedule. Payments and interest are rounded to cents, and the final
+// payment pays off the remaining balance so the rounding drift doesn't
+// carry past the term. A 0% APR divides the principal evenly. Payment dates
+// are left for the caller to fill in.
+func AmortizationSchedule(principal, apr float64, months int) (float64, []AmortizationPeriod) {
+	if principal <= 0 || months <= 0 {
+		return 0, nil
+	}
+
+	rate := apr / 100 / 12
+	paymentCents := RoundingCent.Cents(monthlyPayment(principal, apr, months))
+	balanceCents := RoundingCent.Cents(principal)
+
+	schedule := make([]AmortizationPeriod, 0, months)
+	for period := 1; period <= months; period++ {
+		interestCents := RoundingCent.Cents(fromCents(balanceCents) * rate)
+
+		principalCents := paymentCents - interestCents
+		if period == months || principalCents > balanceCents {
+			principalCents = balanceCents
+		}
+		balanceCents -= principalCents
+
+		schedule = append(schedule, AmortizationPeriod{
+			Period:    period,
+			Payment:   fromCents(principalCents + interestCents),
+			Principal: fromCents(principalCents),
+			Interest:  fromCents(interestCents),
+			Balance:   fromCents(balanceCents),
+		})
+	}
+
+	return fromCents(paymentCents), schedule
+}
+
+// addMonths returns the date months after t, on the same day of the month or
+// the month's last day when it is shorter
+func addMonths(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, 0, 0, 0, 0, t.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, t.Location())
+}
+
+// getPaymentSchedule handles POST /deals/{id}/payment-schedule, the monthly
+// payment and amortization table for financing the deal's balance. The
+// principal is the deal's total, which is already net of the down payment.
+func (s *Server) getPaymentSchedule(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if !validateUUID(w, id, "id") {
+		return
+	}
+
+	var req PaymentScheduleRequest
+	if !decodeAndValidate(r, w, &req) {
+		return
+	}
+
+	deal, err := s.db.GetDeal(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get deal")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get deal: %v", err))
+		return
+	}
+	if deal == nil {
+		apierror.WriteError(w, http.StatusNotFound, "DEAL_NOT_FOUND", "Deal not found")
+		return
+	}
+
+	// Recalculated so deals saved before totals were stored are scheduled
+	// on the same basis
+	principal := deal.CalculateTotal()
+	if principal <= 0 {
+		respondValidationError(w, &ValidationErrors{Errors: []ValidationError{{
+			Field:   "total_amount",
+			Message: "Deal has no balance to finance",
+		}}})
+		return
+	}
+
+	firstPayment := addMonths(time.Now().UTC(), 1)
+	if req.FirstPaymentDate != "" {
+		firstPayment, _ = time.Parse("2006-01-02", req.FirstPaymentDate)
+	}
+
+	payment, schedule := AmortizationSchedule(principal, req.APR, req.TermMonths)
+	var interestCents, paidCents int64
+	for i := range schedule {
+		schedule[i].PaymentDate = addMonths(firstPayment, i).Format("2006-01-02")
+		interestCents += RoundingCent.Cents(schedule[i].Interest)
+		paidCents += RoundingCent.Cents(schedule[i].Payment)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PaymentSchedule{
+		DealID:          deal.ID,
+		Principal:       principal,
+		APR:             req.APR,
+		TermMonths:      req.TermMonths,
+		MonthlyPayment:  payment,
+		TotalInterest:   fromCents(interestCents),
+		TotalOfPayments: fromCents(paidCents),
+		Schedule:        schedule,
+	})
+}
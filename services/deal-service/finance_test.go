@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// scheduleTotals sums a schedule's payments and principal in cents
+func scheduleTotals(schedule []AmortizationPeriod) (payments, principal int64) {
+	for _, p := range schedule {
+		payments += RoundingCent.Cents(p.Payment)
+		principal += RoundingCent.Cents(p.Principal)
+	}
+	return payments, principal
+}
+
+func TestAmortizationSchedule(t *testing.T) {
+	payment, schedule := AmortizationSchedule(20000, 6.5, 60)
+	if payment != 391.32 {
+		t.Errorf("Expected a monthly payment of 391.32, got %v", payment)
+	}
+	if len(schedule) != 60 {
+		t.Fatalf("Expected 60 periods, got %d", len(schedule))
+	}
+
+	// 20000 at 6.5% accrues 108.33 of interest in the first month
+	first := schedule[0]
+	if first.Interest != 108.33 || first.Principal != 282.99 || first.Balance != 19717.01 {
+		t.Errorf("Unexpected first period: %+v", first)
+	}
+	for _, p := range schedule[:59] {
+		if p.Payment != payment {
+			t.Fatalf("Expected level payments, got %+v", p)
+		}
+	}
+
+	// The final payment absorbs the rounding drift and clears the balance
+	last := schedule[59]
+	if last.Balance != 0 || last.Payment == payment || last.Payment-payment > 1 || payment-last.Payment > 1 {
+		t.Errorf("Expected the final payment to clear the balance within a dollar of the others, got %+v", last)
+	}
+	if _, principal := scheduleTotals(schedule); principal != 2000000 {
+		t.Errorf("Expected the principal paid to total 20000.00, got %d cents", principal)
+	}
+}
+
+func TestAmortizationScheduleZeroAPR(t *testing.T) {
+	payment, schedule := AmortizationSchedule(1000, 0, 3)
+	if payment != 333.33 {
+		t.Errorf("Expected a monthly payment of 333.33, got %v", payment)
+	}
+	expected := []AmortizationPeriod{
+		{Period: 1, Payment: 333.33, Principal: 333.33, Balance: 666.67},
+		{Period: 2, Payment: 333.33, Principal: 333.33, Balance: 333.34},
+		{Period: 3, Payment: 333.34, Principal: 333.34, Balance: 0},
+	}
+	for i := range expected {
+		if schedule[i] != expected[i] {
+			t.Errorf("Period %d: expected %+v, got %+v", i+1, expected[i], schedule[i])
+		}
+	}
+
+	if payment, schedule := AmortizationSchedule(0, 5, 12); payment != 0 || schedule != nil {
+		t.Errorf("Expected no schedule without a principal, got %v %v", payment, schedule)
+	}
+}
+
+func TestAddMonths(t *testing.T) {
+	start := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+	for months, expected := range map[int]string{0: "2026-01-31", 1: "2026-02-28", 2: "2026-03-31", 13: "2027-02-28"} {
+		if got := addMonths(start, months).Format("2006-01-02"); got != expected {
+			t.Errorf("addMonths(%d): expected %s, got %s", months, expected, got)
+		}
+	}
+}
+
+func TestPaymentScheduleEndpoint(t *testing.T) {
+	server := setupTestServer()
+	deal := &Deal{
+		ID:           uuid.New().String(),
+		DealershipID: uuid.New().String(),
+		CustomerID:   uuid.New().String(),
+		VehiclePrice: 24000,
+		TradeInValue: 3000,
+		DownPayment:  2000,
+		TaxAmount:    1000,
+		Status:       "pending",
+	}
+	server.db.CreateDeal(deal)
+	path := "/deals/" + deal.ID + "/payment-schedule"
+
+	rr := sendDealRequest(t, server, "POST", path, PaymentScheduleRequest{APR: 4.9, TermMonths: 48, FirstPaymentDate: "2026-05-15"}, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var schedule PaymentSchedule
+	json.Unmarshal(rr.Body.Bytes(), &schedule)
+
+	// 24000 - 3000 - 2000 + 1000, the down payment counted once
+	if schedule.Principal != 20000 || len(schedule.Schedule) != 48 {
+		t.Fatalf("Expected 48 payments on 20000, got %+v", schedule)
+	}
+	if schedule.Schedule[0].PaymentDate != "2026-05-15" || schedule.Schedule[47].PaymentDate != "2030-04-15" {
+		t.Errorf("Unexpected payment dates %s to %s", schedule.Schedule[0].PaymentDate, schedule.Schedule[47].PaymentDate)
+	}
+	payments, _ := scheduleTotals(schedule.Schedule)
+	if RoundingCent.Cents(schedule.TotalOfPayments) != payments ||
+		RoundingCent.Cents(schedule.TotalOfPayments)-RoundingCent.Cents(schedule.TotalInterest) != 2000000 {
+		t.Errorf("Expected the totals to add up, got %+v", schedule)
+	}
+
+	for _, req := range []PaymentScheduleRequest{
+		{APR: 4.9, TermMonths: 0},
+		{APR: -1, TermMonths: 36},
+		{APR: 4.9, TermMonths: 36, FirstPaymentDate: "15/05/2026"},
+	} {
+		if rr := sendDealRequest(t, server, "POST", path, req, ""); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %+v, got %d", req, rr.Code)
+		}
+	}
+
+	if rr := sendDealRequest(t, server, "POST", "/deals/"+uuid.New().String()+"/payment-schedule", PaymentScheduleRequest{APR: 4.9, TermMonths: 36}, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown deal, got %d", rr.Code)
+	}
+}
@@ -3,7 +3,16 @@ module autolytiq/deal-service
 go 1.18
 
 require (
+	autolytiq/shared/apierror v0.0.0
+	autolytiq/shared/bodylimit v0.0.0
+	autolytiq/shared/configclient v0.0.0
+	autolytiq/shared/encryption v0.0.0
+	autolytiq/shared/etag v0.0.0
+	autolytiq/shared/graceful v0.0.0
+	autolytiq/shared/health v0.0.0
+	autolytiq/shared/httpmetrics v0.0.0
 	autolytiq/shared/logging v0.0.0
+	autolytiq/shared/tracing v0.0.0
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/mux v1.8.1
 	github.com/lib/pq v1.10.9
@@ -11,6 +20,21 @@ require (
 )
 
 require (
+	autolytiq/shared/secrets v0.0.0 // indirect
+	github.com/aws/aws-sdk-go-v2 v1.24.0 // indirect
+	github.com/aws/aws-sdk-go-v2/config v1.26.1 // indirect
+	github.com/aws/aws-sdk-go-v2/credentials v1.16.12 // indirect
+	github.com/aws/aws-sdk-go-v2/feature/ec2/imds v1.14.10 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/configsources v1.2.9 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/endpoints/v2 v2.5.9 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/ini v1.7.2 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/accept-encoding v1.10.4 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/presigned-url v1.10.9 // indirect
+	github.com/aws/aws-sdk-go-v2/service/secretsmanager v1.26.0 // indirect
+	github.com/aws/aws-sdk-go-v2/service/sso v1.18.5 // indirect
+	github.com/aws/aws-sdk-go-v2/service/ssooidc v1.21.5 // indirect
+	github.com/aws/aws-sdk-go-v2/service/sts v1.26.5 // indirect
+	github.com/aws/smithy-go v1.19.0 // indirect
 	github.com/beorn7/perks v1.0.1 // indirect
 	github.com/cespare/xxhash/v2 v2.2.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
@@ -25,3 +49,23 @@ require (
 )
 
 replace autolytiq/shared/logging => ../shared/logging
+
+replace autolytiq/shared/configclient => ../shared/configclient
+
+replace autolytiq/shared/encryption => ../shared/encryption
+
+replace autolytiq/shared/etag => ../shared/etag
+
+replace autolytiq/shared/secrets => ../shared/secrets
+
+replace autolytiq/shared/health => ../shared/health
+
+replace autolytiq/shared/graceful => ../shared/graceful
+
+replace autolytiq/shared/apierror => ../shared/apierror
+
+replace autolytiq/shared/bodylimit => ../shared/bodylimit
+
+replace autolytiq/shared/httpmetrics => ../shared/httpmetrics
+
+replace autolytiq/shared/tracing => ../shared/tracing
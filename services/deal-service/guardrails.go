@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/configclient"
+	"autolytiq/shared/logging"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// settingPricingGuardrails is the config-service key holding a dealership's
+// pricing guardrails as JSON
+const settingPricingGuardrails = "pricing_guardrails"
+
+// scopeDealsManage is required to approve a deal that breaches a guardrail
+const scopeDealsManage = "deals:manage"
+
+// Guardrail names reported in violations
+const (
+	GuardrailMinMargin   = "min_margin_percent"
+	GuardrailMaxDiscount = "max_discount"
+)
+
+// PricingGuardrails are a dealership's pricing limits. A zero value disables
+// the corresponding check.
+type PricingGuardrails struct {
+	// MinMarginPercent is the minimum front-end margin as a percentage of the
+	// selling price
+	MinMarginPercent float64 `json:"min_margin_percent"`
+
+	// MaxDiscount is the largest allowed reduction from the vehicle's list price
+	MaxDiscount float64 `json:"max_discount"`
+}
+
+// GuardrailViolation describes a guardrail breached by a deal
+type GuardrailViolation struct {
+	Guardrail string  `json:"guardrail"`
+	Limit     float64 `json:"limit"`
+	Message   string  `json:"message"`
+}
+
+// GuardrailErrorResponse is returned when a deal cannot proceed until approved
+type GuardrailErrorResponse struct {
+	Error      string               `json:"error"`
+	Code       string               `json:"code"`
+	Violations []GuardrailViolation `json:"violations"`
+}
+
+// CheckPricingGuardrails returns the guardrails a deal breaches. Checks that
+// need inventory pricing are skipped when it is unknown. The margin message
+// omits the actual margin so vehicle cost is not revealed to the caller.
+func CheckPricingGuardrails(deal *Deal, pricing *VehiclePricing, guardrails *PricingGuardrails) []GuardrailViolation {
+	if guardrails == nil || pricing == nil {
+		return nil
+	}
+
+	var violations []GuardrailViolation
+
+	if guardrails.MinMarginPercent > 0 && pricing.Cost != nil && deal.VehiclePrice > 0 {
+		margin := (deal.VehiclePrice - *pricing.Cost) / deal.VehiclePrice * 100
+		if roundRate(margin) < guardrails.MinMarginPercent {
+			violations = append(violations, GuardrailViolation{
+				Guardrail: GuardrailMinMargin,
+				Limit:     guardrails.MinMarginPercent,
+				Message:   fmt.Sprintf("Margin is below the %.2f%% minimum", guardrails.MinMarginPercent),
+			})
+		}
+	}
+
+	if guardrails.MaxDiscount > 0 && pricing.ListPrice > 0 {
+		discount := roundCents(pricing.ListPrice - deal.VehiclePrice)
+		if discount > guardrails.MaxDiscount {
+			violations = append(violations, GuardrailViolation{
+				Guardrail: GuardrailMaxDiscount,
+				Limit:     guardrails.MaxDiscount,
+				Message:   fmt.Sprintf("Discount of %.2f exceeds the maximum of %.2f", discount, guardrails.MaxDiscount),
+			})
+		}
+	}
+
+	return violations
+}
+
+// evaluateGuardrails checks the deal against its dealership's guardrails and
+// sets RequiresApproval and GuardrailViolations. Any earlier approval is
+// cleared, since it applied to different pricing. Lookup failures are logged
+// and leave the deal's flags unchanged.
+func (s *Server) evaluateGuardrails(r *http.Request, deal *Deal) {
+	if s.settings == nil || s.pricing == nil || deal.VehicleID == "" {
+		return
+	}
+
+	var guardrails PricingGuardrails
+	if err := s.settings.GetJSON(r.Context(), deal.DealershipID, settingPricingGuardrails, &guardrails); err != nil {
+		if !errors.Is(err, configclient.ErrNotFound) {
+			s.logger.WithContext(r.Context()).WithError(err).WithField("dealership_id", deal.DealershipID).Warn("Failed to load pricing guardrails")
+		}
+		return
+	}
+
+	pricing, err := s.pricing.GetVehiclePricing(r, deal.VehicleID)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).WithField("vehicle_id", deal.VehicleID).Warn("Failed to get vehicle pricing for guardrails")
+		return
+	}
+
+	deal.GuardrailViolations = CheckPricingGuardrails(deal, pricing, &guardrails)
+	deal.RequiresApproval = len(deal.GuardrailViolations) > 0
+	deal.ApprovedBy = ""
+	deal.ApprovedAt = nil
+
+	if deal.RequiresApproval {
+		s.logger.WithContext(r.Context()).WithField("deal_id", deal.ID).WithField("violations", len(deal.GuardrailViolations)).Info("Deal breaches pricing guardrails")
+	}
+}
+
+// awaitingApproval reports whether the deal breaches a guardrail and has not
+// been approved
+func (d *Deal) awaitingApproval() bool {
+	return d.RequiresApproval && d.ApprovedAt == nil
+}
+
+// respondApprovalRequired rejects a transition that needs guardrail approval
+func respondApprovalRequired(w http.ResponseWriter, deal *Deal) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(GuardrailErrorResponse{
+		Error:      "Deal breaches pricing guardrails and must be approved before funding",
+		Code:       "APPROVAL_REQUIRED",
+		Violations: deal.GuardrailViolations,
+	})
+}
+
+// hasScope reports whether the request carries the given scope. Scopes are
+// comma or space separated.
+func hasScope(r *http.Request, scope string) bool {
+	fields := strings.FieldsFunc(r.Header.Get(scopesHeader), func(c rune) bool {
+		return c == ',' || c == ' '
+	})
+	for _, f := range fields {
+		if f == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// approveDeal approves a deal that breaches a pricing guardrail, allowing it
+// to be funded. Draft and pending deals move to approved.
+func (s *Server) approveDeal(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if !validateUUID(w, id, "id") {
+		return
+	}
+
+	if !hasScope(r, scopeDealsManage) {
+		respondErrorJSON(w, http.StatusForbidden, "Approving deals requires the "+scopeDealsManage+" scope", "INSUFFICIENT_SCOPE")
+		return
+	}
+
+	deal, err := s.db.GetDeal(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get deal")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get deal: %v", err))
+		return
+	}
+	if deal == nil {
+		apierror.WriteError(w, http.StatusNotFound, "DEAL_NOT_FOUND", "Deal not found")
+		return
+	}
+
+	if !deal.awaitingApproval() {
+		respondErrorJSON(w, http.StatusConflict, "Deal does not require approval", "APPROVAL_NOT_REQUIRED")
+		return
+	}
+
+	previous := *deal
+	now := time.Now()
+	deal.ApprovedBy = logging.GetUserID(r.Context())
+	deal.ApprovedAt = &now
+	if deal.Status == "draft" || deal.Status == "pending" {
+		deal.Status = "approved"
+	}
+	deal.UpdatedAt = now
+
+	if err := s.db.UpdateDeal(deal); err != nil {
+		if err == ErrVersionConflict {
+			respondConcurrentUpdate(w)
+			return
+		}
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to approve deal")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to approve deal: %v", err))
+		return
+	}
+
+	entry := &DealHistoryEntry{
+		ID:        uuid.New().String(),
+		DealID:    deal.ID,
+		Field:     "approved_by",
+		NewValue:  deal.ApprovedBy,
+		ChangedBy: deal.ApprovedBy,
+		ChangedAt: now,
+	}
+	if err := s.db.CreateDealHistory(entry); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).WithField("deal_id", deal.ID).Warn("Failed to record deal history")
+	}
+	s.recordVersion(r, deal, VersionChangeApprove, 0)
+	s.auditDeal(r, AuditActionUpdate, &previous, deal)
+
+	s.logger.WithContext(r.Context()).WithField("deal_id", deal.ID).Info("Deal approved")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deal)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// stubPricing serves fixed vehicle pricing keyed by vehicle ID
+type stubPricing struct {
+	vehicles map[string]*VehiclePricing
+}
+
+func (p *stubPricing) GetVehiclePricing(r *http.Request, vehicleID string) (*VehiclePricing, error) {
+	return p.vehicles[vehicleID], nil
+}
+
+func TestCheckPricingGuardrails(t *testing.T) {
+	guardrails := &PricingGuardrails{MinMarginPercent: 8, MaxDiscount: 1500}
+
+	tests := []struct {
+		name         string
+		vehiclePrice float64
+		pricing      *VehiclePricing
+		expected     []string
+	}{
+		{"within guardrails", 27000, &VehiclePricing{ListPrice: 28000, Cost: floatPtr(24000)}, nil},
+		{"below min margin", 25000, &VehiclePricing{ListPrice: 25500, Cost: floatPtr(24000)}, []string{GuardrailMinMargin}},
+		{"over max discount", 26000, &VehiclePricing{ListPrice: 28000, Cost: floatPtr(20000)}, []string{GuardrailMaxDiscount}},
+		{"both breached", 24500, &VehiclePricing{ListPrice: 28000, Cost: floatPtr(24000)}, []string{GuardrailMinMargin, GuardrailMaxDiscount}},
+		{"unknown cost skips margin", 25000, &VehiclePricing{ListPrice: 25000}, nil},
+		{"unknown vehicle", 25000, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := CheckPricingGuardrails(&Deal{VehiclePrice: tt.vehiclePrice}, tt.pricing, guardrails)
+			if len(violations) != len(tt.expected) {
+				t.Fatalf("Expected violations %v, got %+v", tt.expected, violations)
+			}
+			for i, v := range violations {
+				if v.Guardrail != tt.expected[i] {
+					t.Errorf("Expected guardrail %s, got %s", tt.expected[i], v.Guardrail)
+				}
+			}
+		})
+	}
+}
+
+// setupGuardrailServer returns a server whose dealership requires an 8%
+// margin, with a vehicle listed at 25500 that cost 24000
+func setupGuardrailServer() (*Server, string, string) {
+	dealershipID := uuid.New().String()
+	vehicleID := uuid.New().String()
+
+	server := setupTestServer()
+	server.settings = &stubSettings{json: map[string]string{
+		dealershipID + "/" + settingPricingGuardrails: `{"min_margin_percent": 8}`,
+	}}
+	server.pricing = &stubPricing{vehicles: map[string]*VehiclePricing{
+		vehicleID: {ListPrice: 25500, Cost: floatPtr(24000)},
+	}}
+
+	return server, dealershipID, vehicleID
+}
+
+func sendDealRequest(t *testing.T, server *Server, method, path string, body interface{}, scopes string) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-ID", "manager-1")
+	if scopes != "" {
+		req.Header.Set("X-User-Scopes", scopes)
+	}
+	// Writes need an If-Match; tests of anything but concurrency take any version
+	if method == "PUT" || method == "DELETE" {
+		req.Header.Set("If-Match", "*")
+	}
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestBelowMarginDealRequiresApprovalToFund(t *testing.T) {
+	server, dealershipID, vehicleID := setupGuardrailServer()
+
+	rr := sendDealRequest(t, server, "POST", "/deals", CreateDealRequest{
+		DealershipID: dealershipID,
+		CustomerID:   uuid.New().String(),
+		VehicleID:    vehicleID,
+		VehiclePrice: 25000,
+	}, "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var created Deal
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	if !created.RequiresApproval {
+		t.Fatal("Expected below-margin deal to require approval")
+	}
+	if len(created.GuardrailViolations) != 1 || created.GuardrailViolations[0].Guardrail != GuardrailMinMargin {
+		t.Fatalf("Expected min margin violation, got %+v", created.GuardrailViolations)
+	}
+
+	// Funding is blocked until the deal is approved
+	moveDeal(t, server, created.ID, "pending", "approved")
+	rr = sendDealRequest(t, server, "PUT", "/deals/"+created.ID, UpdateDealRequest{Status: "funded"}, "")
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var blocked GuardrailErrorResponse
+	json.Unmarshal(rr.Body.Bytes(), &blocked)
+	if blocked.Code != "APPROVAL_REQUIRED" || len(blocked.Violations) != 1 || blocked.Violations[0].Guardrail != GuardrailMinMargin {
+		t.Errorf("Expected APPROVAL_REQUIRED with the breached guardrail, got %+v", blocked)
+	}
+	if stored, _ := server.db.GetDeal(created.ID); stored.Status != "approved" {
+		t.Errorf("Expected rejected update not to change status, got %s", stored.Status)
+	}
+
+	// Approval requires the deals:manage scope
+	rr = sendDealRequest(t, server, "POST", "/deals/"+created.ID+"/approve", nil, "deals:update")
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403, got %d", rr.Code)
+	}
+
+	rr = sendDealRequest(t, server, "POST", "/deals/"+created.ID+"/approve", nil, "deals:read,deals:manage")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var approved Deal
+	json.Unmarshal(rr.Body.Bytes(), &approved)
+	if approved.Status != "approved" || approved.ApprovedBy != "manager-1" || approved.ApprovedAt == nil {
+		t.Errorf("Expected approved deal, got status=%s by=%s at=%v", approved.Status, approved.ApprovedBy, approved.ApprovedAt)
+	}
+
+	rr = sendDealRequest(t, server, "PUT", "/deals/"+created.ID, UpdateDealRequest{Status: "funded"}, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected approved deal to fund, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestCreateFundedDealBelowMarginRejected(t *testing.T) {
+	server, dealershipID, vehicleID := setupGuardrailServer()
+
+	rr := sendDealRequest(t, server, "POST", "/deals", CreateDealRequest{
+		DealershipID: dealershipID,
+		CustomerID:   uuid.New().String(),
+		VehicleID:    vehicleID,
+		VehiclePrice: 25000,
+		Status:       "funded",
+	}, "")
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(server.db.(*MockDatabase).deals) != 0 {
+		t.Error("Expected rejected deal not to be saved")
+	}
+}
+
+func TestRepricingClearsApproval(t *testing.T) {
+	server, dealershipID, vehicleID := setupGuardrailServer()
+
+	rr := sendDealRequest(t, server, "POST", "/deals", CreateDealRequest{
+		DealershipID: dealershipID,
+		CustomerID:   uuid.New().String(),
+		VehicleID:    vehicleID,
+		VehiclePrice: 25000,
+	}, "")
+	var created Deal
+	json.Unmarshal(rr.Body.Bytes(), &created)
+
+	sendDealRequest(t, server, "POST", "/deals/"+created.ID+"/approve", nil, "deals:manage")
+
+	// A further discount needs a new approval
+	rr = sendDealRequest(t, server, "PUT", "/deals/"+created.ID, UpdateDealRequest{VehiclePrice: 24800}, "")
+	var repriced Deal
+	json.Unmarshal(rr.Body.Bytes(), &repriced)
+	if !repriced.RequiresApproval || repriced.ApprovedAt != nil {
+		t.Errorf("Expected repriced deal to need approval again, got %+v", repriced)
+	}
+
+	// Raising the price above the minimum margin clears the flag
+	rr = sendDealRequest(t, server, "PUT", "/deals/"+created.ID, UpdateDealRequest{VehiclePrice: 26500}, "")
+	var compliant Deal
+	json.Unmarshal(rr.Body.Bytes(), &compliant)
+	if compliant.RequiresApproval || len(compliant.GuardrailViolations) != 0 {
+		t.Errorf("Expected compliant deal not to require approval, got %+v", compliant)
+	}
+}
+
+func TestApproveDealNotRequired(t *testing.T) {
+	server := setupTestServer()
+	deal := &Deal{ID: uuid.New().String(), Status: "draft"}
+	server.db.CreateDeal(deal)
+
+	rr := sendDealRequest(t, server, "POST", "/deals/"+deal.ID+"/approve", nil, "deals:manage")
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", rr.Code)
+	}
+}
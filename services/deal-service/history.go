@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/logging"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// DealHistoryEntry records a single change to a tracked deal field
+type DealHistoryEntry struct {
+	ID        string    `json:"id"`
+	DealID    string    `json:"deal_id"`
+	Field     string    `json:"field"`
+	OldValue  string    `json:"old_value"`
+	NewValue  string    `json:"new_value"`
+	ChangedBy string    `json:"changed_by,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// formatRate formats an interest rate for storage in deal history
+func formatRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', -1, 64)
+}
+
+// recordRateChanges writes a history entry for each financing rate that changed.
+// History failures are logged but do not fail the update.
+func (s *Server) recordRateChanges(r *http.Request, before, after *Deal) {
+	changes := []struct {
+		field    string
+		old, new float64
+	}{
+		{"buy_rate", before.BuyRate, after.BuyRate},
+		{"sell_rate", before.SellRate, after.SellRate},
+	}
+
+	changedBy := logging.GetUserID(r.Context())
+	for _, c := range changes {
+		if c.old == c.new {
+			continue
+		}
+
+		entry := &DealHistoryEntry{
+			ID:        uuid.New().String(),
+			DealID:    after.ID,
+			Field:     c.field,
+			OldValue:  formatRate(c.old),
+			NewValue:  formatRate(c.new),
+			ChangedBy: changedBy,
+			ChangedAt: after.UpdatedAt,
+		}
+		if err := s.db.CreateDealHistory(entry); err != nil {
+			s.logger.WithContext(r.Context()).WithError(err).WithField("deal_id", after.ID).Warn("Failed to record deal history")
+		}
+	}
+}
+
+// getDealHistory returns the change history for a deal
+func (s *Server) getDealHistory(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if !validateUUID(w, id, "id") {
+		return
+	}
+
+	entries, err := s.db.ListDealHistory(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list deal history")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to list deal history: %v", err))
+		return
+	}
+
+	if entries == nil {
+		entries = []*DealHistoryEntry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"autolytiq/shared/logging"
+	"autolytiq/shared/tracing"
+)
+
+// scopesHeader carries the caller's scopes, set by the API gateway from the JWT
+const scopesHeader = "X-User-Scopes"
+
+// scopeInventoryCost grants access to vehicle cost in inventory-service
+const scopeInventoryCost = "inventory:cost"
+
+// VehicleCostLookup fetches the cost basis of a vehicle from inventory
+type VehicleCostLookup interface {
+	// GetVehicleCost returns the vehicle's cost, or nil if the vehicle has no
+	// cost recorded or the caller is not allowed to see it
+	GetVehicleCost(r *http.Request, vehicleID string) (*float64, error)
+}
+
+// InventoryClient calls inventory-service on behalf of an incoming request
+type InventoryClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewInventoryClient creates a new inventory-service client
+func NewInventoryClient(baseURL string) *InventoryClient {
+	return &InventoryClient{
+		baseURL:    baseURL,
+		httpClient: &http.Client{Transport: tracing.Transport(nil), Timeout: 5 * time.Second},
+	}
+}
+
+// VehiclePricing is the inventory pricing used to evaluate deal guardrails
+type VehiclePricing struct {
+	ListPrice float64
+	Cost      *float64
+}
+
+// VehiclePricingLookup fetches the list price and cost of a vehicle for
+// server-side checks, regardless of the caller's scopes
+type VehiclePricingLookup interface {
+	// GetVehiclePricing returns nil if the vehicle does not exist
+	GetVehiclePricing(r *http.Request, vehicleID string) (*VehiclePricing, error)
+}
+
+// inventoryVehicle is the subset of inventory-service's vehicle used here
+type inventoryVehicle struct {
+	Price float64  `json:"price"`
+	Cost  *float64 `json:"cost"`
+}
+
+// GetVehicleCost fetches a vehicle from inventory-service, forwarding the
+// caller's identity and scopes so cost is only returned when permitted
+func (c *InventoryClient) GetVehicleCost(r *http.Request, vehicleID string) (*float64, error) {
+	vehicle, err := c.getVehicle(r, vehicleID, r.Header.Get(scopesHeader))
+	if err != nil || vehicle == nil {
+		return nil, err
+	}
+	return vehicle.Cost, nil
+}
+
+// GetVehiclePricing fetches a vehicle's list price and cost. The request
+// carries the inventory:cost scope on deal-service's own behalf; callers must
+// not receive the cost unless they hold the scope themselves.
+func (c *InventoryClient) GetVehiclePricing(r *http.Request, vehicleID string) (*VehiclePricing, error) {
+	vehicle, err := c.getVehicle(r, vehicleID, scopeInventoryCost)
+	if err != nil || vehicle == nil {
+		return nil, err
+	}
+	return &VehiclePricing{ListPrice: vehicle.Price, Cost: vehicle.Cost}, nil
+}
+
+// getVehicle fetches a vehicle with the given scopes, returning nil if it
+// does not exist
+func (c *InventoryClient) getVehicle(r *http.Request, vehicleID, scopes string) (*inventoryVehicle, error) {
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, c.baseURL+"/vehicles/"+url.PathEscape(vehicleID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build inventory request: %w", err)
+	}
+	logging.PropagateHeaders(r, req)
+	if scopes != "" {
+		req.Header.Set(scopesHeader, scopes)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch vehicle: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("inventory-service returned status %d", resp.StatusCode)
+	}
+
+	var vehicle inventoryVehicle
+	if err := json.NewDecoder(resp.Body).Decode(&vehicle); err != nil {
+		return nil, fmt.Errorf("failed to decode vehicle: %w", err)
+	}
+
+	return &vehicle, nil
+}
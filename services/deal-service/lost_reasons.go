@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultLostReasons are the reasons a deal can be cancelled for when
+// LOST_REASONS isn't set. The showroom service records visits closed without
+// a deal against the same list.
+var defaultLostReasons = []string{"price", "financing", "inventory", "competitor", "timing"}
+
+// parseLostReasons parses a comma separated list of lost reasons. An empty
+// list gives the defaults.
+func parseLostReasons(list string) []string {
+	var reasons []string
+	seen := make(map[string]bool)
+	for _, r := range strings.Split(list, ",") {
+		reason := strings.ToLower(strings.TrimSpace(r))
+		if reason == "" || seen[reason] {
+			continue
+		}
+		seen[reason] = true
+		reasons = append(reasons, reason)
+	}
+	if len(reasons) == 0 {
+		return defaultLostReasons
+	}
+	return reasons
+}
+
+// lostReasons returns the configured lost reasons
+func (s *Server) lostReasons() []string {
+	if len(s.config.LostReasons) == 0 {
+		return defaultLostReasons
+	}
+	return s.config.LostReasons
+}
+
+// applyLostReason records why a deal was cancelled. Cancelling requires a
+// reason from the configured list, and a reason can only be given for a
+// cancelled deal. Reopening a cancelled deal clears it. It writes a
+// validation error and reports false if the reason is missing or invalid.
+func (s *Server) applyLostReason(w http.ResponseWriter, deal *Deal, previousStatus, reason string) bool {
+	if deal.Status != "cancelled" {
+		if reason != "" {
+			respondValidationError(w, &ValidationErrors{Errors: []ValidationError{{
+				Field:   "lost_reason",
+				Message: "A lost reason can only be given when cancelling a deal",
+			}}})
+			return false
+		}
+		deal.LostReason = ""
+		deal.CancelledAt = nil
+		return true
+	}
+
+	if reason == "" {
+		if previousStatus == "cancelled" {
+			return true
+		}
+		respondValidationError(w, &ValidationErrors{Errors: []ValidationError{{
+			Field:   "lost_reason",
+			Message: "A lost reason is required to cancel a deal. Must be one of: " + strings.Join(s.lostReasons(), ", "),
+		}}})
+		return false
+	}
+
+	valid := false
+	for _, r := range s.lostReasons() {
+		if r == reason {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		respondValidationError(w, &ValidationErrors{Errors: []ValidationError{{
+			Field:   "lost_reason",
+			Message: "Invalid lost reason. Must be one of: " + strings.Join(s.lostReasons(), ", "),
+		}}})
+		return false
+	}
+
+	deal.LostReason = reason
+	if deal.CancelledAt == nil {
+		now := time.Now()
+		deal.CancelledAt = &now
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestParseLostReasons(t *testing.T) {
+	if got := parseLostReasons(" Price, trade-in,,price "); !reflect.DeepEqual(got, []string{"price", "trade-in"}) {
+		t.Errorf("Expected [price trade-in], got %v", got)
+	}
+	if got := parseLostReasons(""); !reflect.DeepEqual(got, defaultLostReasons) {
+		t.Errorf("Expected the defaults, got %v", got)
+	}
+}
+
+func TestCancelledDealRequiresLostReason(t *testing.T) {
+	server := setupTestServer()
+
+	rr := sendDealRequest(t, server, "POST", "/deals", CreateDealRequest{
+		DealershipID: uuid.New().String(),
+		CustomerID:   uuid.New().String(),
+		VehiclePrice: 25000,
+	}, "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var deal Deal
+	json.Unmarshal(rr.Body.Bytes(), &deal)
+	path := "/deals/" + deal.ID
+
+	// No reason, or one that isn't configured
+	for _, reason := range []string{"", "weather"} {
+		rr = sendDealRequest(t, server, "PUT", path, UpdateDealRequest{Status: "cancelled", LostReason: reason}, "")
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 cancelling with reason %q, got %d", reason, rr.Code)
+		}
+	}
+	if stored, _ := server.db.GetDeal(deal.ID); stored.Status == "cancelled" {
+		t.Fatal("Expected the deal not to be cancelled without a valid reason")
+	}
+
+	// A reason is only for cancelled deals
+	rr = sendDealRequest(t, server, "PUT", path, UpdateDealRequest{Status: "pending", LostReason: "price"}, "")
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a reason on a pending deal, got %d", rr.Code)
+	}
+
+	rr = sendDealRequest(t, server, "PUT", path, UpdateDealRequest{Status: "cancelled", LostReason: "Competitor"}, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 cancelling with a reason, got %d: %s", rr.Code, rr.Body.String())
+	}
+	stored, _ := server.db.GetDeal(deal.ID)
+	if stored.LostReason != "competitor" || stored.CancelledAt == nil {
+		t.Errorf("Expected the lost reason and cancellation time to be recorded, got %+v", stored)
+	}
+
+	// Reopening the deal clears the reason
+	rr = sendDealRequest(t, server, "PUT", path, UpdateDealRequest{Status: "pending"}, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 reopening, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if stored, _ := server.db.GetDeal(deal.ID); stored.LostReason != "" || stored.CancelledAt != nil {
+		t.Errorf("Expected the lost reason to be cleared, got %+v", stored)
+	}
+}
+
+func TestConfiguredLostReasons(t *testing.T) {
+	server := setupTestServer()
+	server.config.LostReasons = []string{"credit-declined"}
+	deal := &Deal{ID: uuid.New().String(), DealershipID: uuid.New().String(), CustomerID: uuid.New().String(), VehiclePrice: 20000, Status: "pending"}
+	server.db.CreateDeal(deal)
+
+	if rr := sendDealRequest(t, server, "PUT", "/deals/"+deal.ID, UpdateDealRequest{Status: "cancelled", LostReason: "price"}, ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a default reason that isn't configured, got %d", rr.Code)
+	}
+	if rr := sendDealRequest(t, server, "PUT", "/deals/"+deal.ID, UpdateDealRequest{Status: "cancelled", LostReason: "credit-declined"}, ""); rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 for a configured reason, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
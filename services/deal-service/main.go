@@ -1,13 +1,23 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/bodylimit"
+	"autolytiq/shared/encryption"
+	"autolytiq/shared/etag"
+	"autolytiq/shared/graceful"
+	"autolytiq/shared/health"
+	"autolytiq/shared/httpmetrics"
 	"autolytiq/shared/logging"
+	"autolytiq/shared/tracing"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -18,38 +28,164 @@ type Deal struct {
 	ID            string    `json:"id"`
 	DealershipID  string    `json:"dealership_id"`
 	CustomerID    string    `json:"customer_id"`
+	CoCustomerID  string    `json:"co_customer_id,omitempty"`
+	VehicleID     string    `json:"vehicle_id,omitempty"`
 	VehiclePrice  float64   `json:"vehicle_price"`
 	TradeInValue  float64   `json:"trade_in_value"`
 	TradeInPayoff float64   `json:"trade_in_payoff"`
 	DownPayment   float64   `json:"down_payment"`
 	TaxAmount     float64   `json:"tax_amount"`
 	TotalAmount   float64   `json:"total_amount"`
+	TermMonths    int       `json:"term_months,omitempty"`
+	BuyRate       float64   `json:"buy_rate,omitempty"`
+	SellRate      float64   `json:"sell_rate,omitempty"`
 	Status        string    `json:"status"`
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
+
+	// Version is incremented on every update and sent as the deal's ETag.
+	// Updates and deletes must send it back in If-Match.
+	Version int `json:"version"`
+
+	// Products are the F&I products sold on the deal
+	Products []DealProduct `json:"products,omitempty"`
+
+	// RequiresApproval is set when the deal breaches a dealership pricing
+	// guardrail. Such deals cannot be funded until approved.
+	RequiresApproval    bool                 `json:"requires_approval"`
+	GuardrailViolations []GuardrailViolation `json:"guardrail_violations,omitempty"`
+	ApprovedBy          string               `json:"approved_by,omitempty"`
+	ApprovedAt          *time.Time           `json:"approved_at,omitempty"`
+
+	// LostReason is why a cancelled deal was lost, one of the configured
+	// lost reasons
+	LostReason  string     `json:"lost_reason,omitempty"`
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
 }
 
 // Config holds application configuration
 type Config struct {
 	Port        string
 	DatabaseURL string
+
+	// MaxRateMarkup is the maximum allowed difference, in percentage points,
+	// between a deal's sell rate and the lender's buy rate
+	MaxRateMarkup float64
+
+	// InventoryServiceURL is used to look up vehicle cost for profitability
+	InventoryServiceURL string
+
+	// CustomerServiceURL is used to validate a deal's buyer and co-buyer and
+	// to read their credit and contact details
+	CustomerServiceURL string
+
+	// ConfigServiceURL is used to read dealership defaults such as the
+	// default financing term
+	ConfigServiceURL string
+
+	// DocumentStorageDir is where deal documents are stored. Documents are
+	// disabled when empty.
+	DocumentStorageDir string
+
+	// DocumentURLSecret signs document download URLs, which are valid for
+	// DocumentURLTTL. It must be shared by all replicas.
+	DocumentURLSecret string
+	DocumentURLTTL    time.Duration
+
+	// EncryptEnabled enables encryption at rest for sensitive documents,
+	// using the PII encryption key
+	EncryptEnabled bool
+
+	// ESignProviderURL is the e-sign provider deal documents are sent to for
+	// signature. ESignWebhookSecret verifies its webhooks; e-signature is
+	// disabled unless both are set.
+	ESignProviderURL   string
+	ESignAPIKey        string
+	ESignWebhookSecret string
+
+	// RequireSignaturesForFunding blocks funding deals whose documents were
+	// never sent for signature. Deals with a signature request can't be
+	// funded until it is signed either way.
+	RequireSignaturesForFunding bool
+
+	// QuoteTTL is how long a quote can be turned into a deal
+	QuoteTTL time.Duration
+
+	// LostReasons are the reasons a deal can be cancelled for. The defaults
+	// are used when empty.
+	LostReasons []string
+
+	// AuditServiceURL is the data-retention-service deal changes are
+	// recorded in. Auditing is disabled when empty.
+	AuditServiceURL string
 }
 
 // Server represents the Deal service server
 type Server struct {
-	router *mux.Router
-	config *Config
-	db     DealDatabase
-	logger *logging.Logger
+	router    *mux.Router
+	config    *Config
+	db        DealDatabase
+	logger    *logging.Logger
+	health    *health.HealthChecker
+	metrics   *httpmetrics.Metrics
+	tracer    *tracing.Tracer
+	inventory VehicleCostLookup
+	pricing   VehiclePricingLookup
+	customers CustomerLookup
+	settings  DealershipSettings
+
+	// customerPII and credit submit credit applications to lenders
+	customerPII CustomerPIILookup
+	credit      CreditProvider
+
+	documents         DocumentStore
+	documentSigner    *documentURLSigner
+	documentEncryptor *encryption.Encryptor
+
+	esign ESignProvider
+
+	audit AuditSink
 }
 
 // NewServer creates a new Deal service server
 func NewServer(config *Config, db DealDatabase, logger *logging.Logger) *Server {
 	s := &Server{
-		router: mux.NewRouter(),
-		config: config,
-		db:     db,
-		logger: logger,
+		router:  mux.NewRouter(),
+		config:  config,
+		db:      db,
+		logger:  logger,
+		health:  health.NewHealthChecker("deal-service", db.Ping),
+		metrics: httpmetrics.New("deal-service"),
+		tracer: tracing.New(tracing.Config{
+			Service: "deal-service",
+			OnError: func(err error) { logger.WithError(err).Warn("Failed to export trace spans") },
+		}),
+	}
+	if config.InventoryServiceURL != "" {
+		client := NewInventoryClient(config.InventoryServiceURL)
+		s.inventory = client
+		s.pricing = client
+	}
+	if config.CustomerServiceURL != "" {
+		client := NewCustomerClient(config.CustomerServiceURL)
+		s.customers = client
+		s.customerPII = client
+	}
+	if config.ConfigServiceURL != "" {
+		s.settings = newSettingsClient(config.ConfigServiceURL)
+		s.credit = NewCreditBureauClient(config.ConfigServiceURL)
+	}
+	s.setupDocuments()
+	if config.ESignProviderURL != "" {
+		if config.ESignWebhookSecret == "" {
+			logger.Warn("E-signature disabled: ESIGN_WEBHOOK_SECRET is not set")
+		} else {
+			s.esign = NewESignClient(config.ESignProviderURL, config.ESignAPIKey, config.ESignWebhookSecret)
+		}
+	}
+
+	if config.AuditServiceURL != "" {
+		s.audit = NewAuditClient(config.AuditServiceURL, defaultAuditQueueSize, defaultAuditWorkers, logger)
 	}
 
 	s.setupMiddleware()
@@ -59,40 +195,107 @@ func NewServer(config *Config, db DealDatabase, logger *logging.Logger) *Server
 
 // setupMiddleware configures middleware
 func (s *Server) setupMiddleware() {
+	s.router.Use(s.tracer.Middleware)
 	s.router.Use(logging.RequestIDMiddleware)
 	s.router.Use(logging.RequestLoggingMiddleware(s.logger))
+	s.router.Use(s.metrics.Middleware)
+	s.router.Use(bodylimit.Middleware(bodylimit.Config{
+		Routes: map[string]int64{"/deals/{id}/documents": maxDocumentUploadSize},
+	}))
 }
 
 // setupRoutes configures all routes
 func (s *Server) setupRoutes() {
-	s.router.HandleFunc("/health", s.healthCheck).Methods("GET")
+	s.router.Handle("/health", s.health).Methods("GET")
+	s.router.Handle("/metrics", s.metrics.Handler()).Methods("GET")
+	s.router.HandleFunc("/ready", s.health.ReadinessHandler).Methods("GET")
+	s.router.HandleFunc("/live", s.health.LivenessHandler).Methods("GET")
 	s.router.HandleFunc("/deals", s.listDeals).Methods("GET")
 	s.router.HandleFunc("/deals", s.createDeal).Methods("POST")
+	s.router.HandleFunc("/deals/export", s.exportDeals).Methods("GET")
+	s.router.HandleFunc("/deals/quote", s.createQuote).Methods("POST")
+	s.router.HandleFunc("/deals/quotes/{quote_id}/document", s.getQuoteDocument).Methods("GET")
+	s.router.HandleFunc("/deals/from-quote/{quote_id}", s.createDealFromQuote).Methods("POST")
+	s.router.HandleFunc("/deals/signature/webhook", s.handleSignatureWebhook).Methods("POST")
 	s.router.HandleFunc("/deals/{id}", s.getDeal).Methods("GET")
 	s.router.HandleFunc("/deals/{id}", s.updateDeal).Methods("PUT")
 	s.router.HandleFunc("/deals/{id}", s.deleteDeal).Methods("DELETE")
+	s.router.HandleFunc("/deals/{id}/history", s.getDealHistory).Methods("GET")
+	s.router.HandleFunc("/deals/{id}/allowed-transitions", s.getAllowedTransitions).Methods("GET")
+	s.router.HandleFunc("/deals/{id}/notes", s.createDealNote).Methods("POST")
+	s.router.HandleFunc("/deals/{id}/activity", s.getDealActivity).Methods("GET")
+	s.router.HandleFunc("/deals/{id}/versions", s.listDealVersions).Methods("GET")
+	s.router.HandleFunc("/deals/{id}/revert/{version}", s.revertDeal).Methods("POST")
+	s.router.HandleFunc("/deals/{id}/approve", s.approveDeal).Methods("POST")
+	s.router.HandleFunc("/deals/{id}/profitability", s.getDealProfitability).Methods("GET")
+	s.router.HandleFunc("/deals/{id}/financing", s.getDealFinancing).Methods("GET")
+	s.router.HandleFunc("/deals/{id}/payment-schedule", s.getPaymentSchedule).Methods("POST")
+	s.router.HandleFunc("/deals/{id}/buyers-order", s.getBuyersOrder).Methods("GET")
+	s.router.HandleFunc("/deals/{id}/credit-application", s.submitCreditApplication).Methods("POST")
+	s.router.HandleFunc("/deals/{id}/credit-applications", s.listCreditApplications).Methods("GET")
+	s.router.HandleFunc("/deals/{id}/signature-request", s.requestDealSignatures).Methods("POST")
+	s.router.HandleFunc("/deals/{id}/signature-requests", s.listSignatureRequests).Methods("GET")
+	s.router.HandleFunc("/deals/{id}/documents", s.listDealDocuments).Methods("GET")
+	s.router.HandleFunc("/deals/{id}/documents", s.uploadDealDocument).Methods("POST")
+	s.router.HandleFunc("/deals/{id}/documents/{document_id}", s.deleteDealDocument).Methods("DELETE")
+	s.router.HandleFunc("/deals/{id}/documents/{document_id}/content", s.downloadDealDocument).Methods("GET")
+	s.router.HandleFunc("/deals/documents/purge", s.purgeCustomerDocuments).Methods("POST")
 }
 
-// healthCheck handler
-func (s *Server) healthCheck(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":    "healthy",
-		"service":   "deal-service",
-		"timestamp": time.Now().Format(time.RFC3339),
-	})
+// setupDocuments configures document storage, URL signing and encryption.
+// Failures are logged and leave documents disabled rather than stopping the
+// service.
+func (s *Server) setupDocuments() {
+	if s.config.DocumentStorageDir != "" {
+		store, err := NewLocalDocumentStore(s.config.DocumentStorageDir)
+		if err != nil {
+			s.logger.Warnf("Deal documents disabled - %v", err)
+		} else {
+			s.documents = store
+		}
+	}
+
+	if s.config.DocumentURLSecret == "" {
+		s.logger.Warn("DOCUMENT_URL_SECRET not set - document links will not survive restarts")
+	}
+	ttl := s.config.DocumentURLTTL
+	if ttl <= 0 {
+		ttl = 15 * time.Minute
+	}
+	signer, err := newDocumentURLSigner(s.config.DocumentURLSecret, ttl)
+	if err != nil {
+		s.logger.Warnf("Deal documents disabled - %v", err)
+		s.documents = nil
+	}
+	s.documentSigner = signer
+
+	if s.config.EncryptEnabled {
+		enc, err := encryption.NewEncryptorFromEnv()
+		if err != nil {
+			s.logger.Warnf("Sensitive document encryption disabled - %v", err)
+		} else {
+			s.documentEncryptor = enc
+			s.logger.Info("Sensitive document encryption enabled")
+		}
+	}
 }
 
 // listDeals returns all deals
 func (s *Server) listDeals(w http.ResponseWriter, r *http.Request) {
-	// Optional dealership filter
-	dealershipID := r.URL.Query().Get("dealership_id")
+	// Optional dealership and customer filters. A customer's deals include
+	// those where they are the co-buyer.
+	filter := DealListFilter{
+		DealershipID: r.URL.Query().Get("dealership_id"),
+		CustomerID:   r.URL.Query().Get("customer_id"),
+	}
+	if filter.CustomerID != "" && !validateUUID(w, filter.CustomerID, "customer_id") {
+		return
+	}
 
-	deals, err := s.db.ListDeals(dealershipID)
+	deals, err := s.db.ListDeals(filter)
 	if err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list deals")
-		http.Error(w, fmt.Sprintf("Failed to list deals: %v", err), http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to list deals: %v", err))
 		return
 	}
 
@@ -116,32 +319,81 @@ func (s *Server) createDeal(w http.ResponseWriter, r *http.Request) {
 		ID:            uuid.New().String(),
 		DealershipID:  req.DealershipID,
 		CustomerID:    req.CustomerID,
+		CoCustomerID:  req.CoCustomerID,
+		VehicleID:     req.VehicleID,
 		VehiclePrice:  req.VehiclePrice,
 		TradeInValue:  req.TradeInValue,
 		TradeInPayoff: req.TradeInPayoff,
 		DownPayment:   req.DownPayment,
 		TaxAmount:     req.TaxAmount,
+		TermMonths:    req.TermMonths,
+		BuyRate:       req.BuyRate,
+		SellRate:      req.SellRate,
 		Status:        req.Status,
+		Products:      req.Products,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
 
+	if errs := validateDealTotal(&deal); errs != nil {
+		respondValidationError(w, errs)
+		return
+	}
+
+	if !s.saveNewDeal(w, r, &deal) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(deal)
+}
+
+// saveNewDeal checks a new deal against the dealership's defaults, rules and
+// funding requirements, then saves it and records its first version. It
+// writes an error response and reports false if the deal can't be created.
+func (s *Server) saveNewDeal(w http.ResponseWriter, r *http.Request, deal *Deal) bool {
 	if deal.Status == "" {
 		deal.Status = "draft"
 	}
 
+	if !s.checkDealCustomers(w, r, deal) {
+		return false
+	}
+
+	s.applyDealershipDefaults(r, deal)
+	deal.roundDealAmounts(s.roundingPolicy(r, deal.DealershipID))
+
+	if errs := validateRateMarkup(deal.BuyRate, deal.SellRate, s.config.MaxRateMarkup); errs != nil {
+		respondValidationError(w, errs)
+		return false
+	}
+	if errs := s.checkProductCatalog(r, deal); errs != nil {
+		respondValidationError(w, errs)
+		return false
+	}
+
+	s.evaluateGuardrails(r, deal)
+	if deal.Status == "funded" && deal.awaitingApproval() {
+		respondApprovalRequired(w, deal)
+		return false
+	}
+	if deal.Status == "funded" && !s.checkFundingSignatures(w, r, deal) {
+		return false
+	}
+
 	// Save to database
-	if err := s.db.CreateDeal(&deal); err != nil {
+	if err := s.db.CreateDeal(deal); err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to create deal")
-		http.Error(w, fmt.Sprintf("Failed to create deal: %v", err), http.StatusInternalServerError)
-		return
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to create deal: %v", err))
+		return false
 	}
 
-	s.logger.WithContext(r.Context()).WithField("deal_id", deal.ID).Info("Deal created")
+	s.recordVersion(r, deal, VersionChangeCreate, 0)
+	s.auditDeal(r, AuditActionCreate, nil, deal)
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(deal)
+	s.logger.WithContext(r.Context()).WithField("deal_id", deal.ID).Info("Deal created")
+	return true
 }
 
 // getDeal retrieves a specific deal
@@ -152,20 +404,49 @@ func (s *Server) getDeal(w http.ResponseWriter, r *http.Request) {
 	deal, err := s.db.GetDeal(id)
 	if err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get deal")
-		http.Error(w, fmt.Sprintf("Failed to get deal: %v", err), http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get deal: %v", err))
 		return
 	}
 
 	if deal == nil {
-		http.Error(w, "Deal not found", http.StatusNotFound)
+		apierror.WriteError(w, http.StatusNotFound, "DEAL_NOT_FOUND", "Deal not found")
 		return
 	}
 
+	etag.Set(w, deal.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(deal)
 }
 
-// updateDeal updates a specific deal
+// checkDealVersion checks the If-Match header of a write against the deal's
+// current version, writing 428 if it is missing and 412 if it is stale
+func checkDealVersion(w http.ResponseWriter, r *http.Request, deal *Deal) bool {
+	switch err := etag.Check(r, deal.Version); err {
+	case nil:
+		return true
+	case etag.ErrMissing:
+		respondErrorJSON(w, http.StatusPreconditionRequired, "If-Match header with the deal's ETag is required", "PRECONDITION_REQUIRED")
+	default:
+		respondVersionConflict(w, deal.ID)
+	}
+	return false
+}
+
+// respondVersionConflict writes 412 for a write to a deal that has been
+// updated since the client read it
+func respondVersionConflict(w http.ResponseWriter, id string) {
+	respondErrorJSON(w, http.StatusPreconditionFailed, fmt.Sprintf("Deal %s has been modified; fetch it again and retry", id), "PRECONDITION_FAILED")
+}
+
+// respondConcurrentUpdate writes 409 for a write the server made without a
+// client precondition that lost a race with another update
+func respondConcurrentUpdate(w http.ResponseWriter) {
+	respondErrorJSON(w, http.StatusConflict, "Deal was updated concurrently; retry", "CONCURRENT_UPDATE")
+}
+
+// updateDeal updates a specific deal. The If-Match header must carry the
+// ETag from the deal's last read; the update fails with 412 if the deal has
+// changed since.
 func (s *Server) updateDeal(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
@@ -183,18 +464,32 @@ func (s *Server) updateDeal(w http.ResponseWriter, r *http.Request) {
 	existingDeal, err := s.db.GetDeal(id)
 	if err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get deal")
-		http.Error(w, fmt.Sprintf("Failed to get deal: %v", err), http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get deal: %v", err))
 		return
 	}
 	if existingDeal == nil {
-		http.Error(w, "Deal not found", http.StatusNotFound)
+		apierror.WriteError(w, http.StatusNotFound, "DEAL_NOT_FOUND", "Deal not found")
 		return
 	}
+	if !checkDealVersion(w, r, existingDeal) {
+		return
+	}
+
+	// Snapshot values tracked in deal history before applying updates
+	previous := *existingDeal
 
 	// Apply updates
 	if req.CustomerID != "" {
 		existingDeal.CustomerID = req.CustomerID
 	}
+	if req.CoCustomerID != "" {
+		existingDeal.CoCustomerID = req.CoCustomerID
+	} else if req.RemoveCoCustomer {
+		existingDeal.CoCustomerID = ""
+	}
+	if req.VehicleID != "" {
+		existingDeal.VehicleID = req.VehicleID
+	}
 	if req.VehiclePrice > 0 {
 		existingDeal.VehiclePrice = req.VehiclePrice
 	}
@@ -210,57 +505,175 @@ func (s *Server) updateDeal(w http.ResponseWriter, r *http.Request) {
 	if req.TaxAmount > 0 {
 		existingDeal.TaxAmount = req.TaxAmount
 	}
+	if req.TermMonths > 0 {
+		existingDeal.TermMonths = req.TermMonths
+	}
+	if req.BuyRate > 0 {
+		existingDeal.BuyRate = req.BuyRate
+	}
+	if req.SellRate > 0 {
+		existingDeal.SellRate = req.SellRate
+	}
 	if req.Status != "" {
+		if !canTransition(existingDeal.Status, req.Status) {
+			respondInvalidTransition(w, existingDeal.Status, req.Status)
+			return
+		}
 		existingDeal.Status = req.Status
 	}
+	if req.Products != nil {
+		existingDeal.Products = *req.Products
+	}
 	existingDeal.UpdatedAt = time.Now()
 
+	if !s.applyLostReason(w, existingDeal, previous.Status, req.LostReason) {
+		return
+	}
+
+	if existingDeal.CustomerID != previous.CustomerID || existingDeal.CoCustomerID != previous.CoCustomerID {
+		if !s.checkDealCustomers(w, r, existingDeal) {
+			return
+		}
+	}
+
+	if errs := validateDealTotal(existingDeal); errs != nil {
+		respondValidationError(w, errs)
+		return
+	}
+
+	if errs := validateRateMarkup(existingDeal.BuyRate, existingDeal.SellRate, s.config.MaxRateMarkup); errs != nil {
+		respondValidationError(w, errs)
+		return
+	}
+	if req.Products != nil {
+		if errs := s.checkProductCatalog(r, existingDeal); errs != nil {
+			respondValidationError(w, errs)
+			return
+		}
+	}
+
+	existingDeal.roundDealAmounts(s.roundingPolicy(r, existingDeal.DealershipID))
+
+	// Guardrails are re-checked only when the pricing they depend on changes,
+	// so an approval stands until the deal is repriced
+	if existingDeal.VehiclePrice != previous.VehiclePrice || existingDeal.VehicleID != previous.VehicleID {
+		s.evaluateGuardrails(r, existingDeal)
+	}
+	if existingDeal.Status == "funded" && previous.Status != "funded" && existingDeal.awaitingApproval() {
+		respondApprovalRequired(w, existingDeal)
+		return
+	}
+	if existingDeal.Status == "funded" && previous.Status != "funded" && !s.checkFundingSignatures(w, r, existingDeal) {
+		return
+	}
+
 	if err := s.db.UpdateDeal(existingDeal); err != nil {
-		if err.Error() == fmt.Sprintf("deal not found: %s", id) {
-			http.Error(w, "Deal not found", http.StatusNotFound)
+		if err == ErrVersionConflict {
+			respondVersionConflict(w, id)
+		} else if err.Error() == fmt.Sprintf("deal not found: %s", id) {
+			apierror.WriteError(w, http.StatusNotFound, "DEAL_NOT_FOUND", "Deal not found")
 		} else {
 			s.logger.WithContext(r.Context()).WithError(err).Error("Failed to update deal")
-			http.Error(w, fmt.Sprintf("Failed to update deal: %v", err), http.StatusInternalServerError)
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to update deal: %v", err))
 		}
 		return
 	}
 
+	s.recordRateChanges(r, &previous, existingDeal)
+	s.recordVersion(r, existingDeal, VersionChangeUpdate, 0)
+	s.auditDeal(r, AuditActionUpdate, &previous, existingDeal)
+
 	s.logger.WithContext(r.Context()).WithField("deal_id", id).Info("Deal updated")
 
+	etag.Set(w, existingDeal.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(existingDeal)
 }
 
-// deleteDeal deletes a specific deal
+// deleteDeal deletes a specific deal. Like updates, deletes must send the
+// deal's ETag in If-Match.
 func (s *Server) deleteDeal(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	if err := s.db.DeleteDeal(id); err != nil {
-		if err.Error() == fmt.Sprintf("deal not found: %s", id) {
-			http.Error(w, "Deal not found", http.StatusNotFound)
+	deal, err := s.db.GetDeal(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get deal")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get deal: %v", err))
+		return
+	}
+	if deal == nil {
+		apierror.WriteError(w, http.StatusNotFound, "DEAL_NOT_FOUND", "Deal not found")
+		return
+	}
+	if !checkDealVersion(w, r, deal) {
+		return
+	}
+
+	// Stored document contents are not covered by the metadata cascade
+	if s.documents != nil {
+		docs, err := s.db.ListDealDocuments(id)
+		if err == nil {
+			err = s.removeDocuments(r, docs)
+		}
+		if err != nil {
+			s.logger.WithContext(r.Context()).WithError(err).Error("Failed to delete deal documents")
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to delete deal documents: %v", err))
+			return
+		}
+	}
+
+	if err := s.db.DeleteDeal(id, deal.Version); err != nil {
+		if err == ErrVersionConflict {
+			respondVersionConflict(w, id)
+		} else if err.Error() == fmt.Sprintf("deal not found: %s", id) {
+			apierror.WriteError(w, http.StatusNotFound, "DEAL_NOT_FOUND", "Deal not found")
 		} else {
 			s.logger.WithContext(r.Context()).WithError(err).Error("Failed to delete deal")
-			http.Error(w, fmt.Sprintf("Failed to delete deal: %v", err), http.StatusInternalServerError)
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to delete deal: %v", err))
 		}
 		return
 	}
 
+	s.auditDeal(r, AuditActionDelete, deal, nil)
+
 	s.logger.WithContext(r.Context()).WithField("deal_id", id).Info("Deal deleted")
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// Start starts the Deal service server
-func (s *Server) Start() error {
+// Start serves the Deal service until ctx is cancelled, then waits for
+// in-flight requests to finish
+func (s *Server) Start(ctx context.Context) error {
 	s.logger.Infof("Starting Deal Service on port %s", s.config.Port)
-	return http.ListenAndServe(":"+s.config.Port, s.router)
+	srv := &http.Server{Addr: ":" + s.config.Port, Handler: s.router}
+	return graceful.ListenAndServe(ctx, srv, graceful.DefaultTimeout)
 }
 
 func loadConfig() *Config {
 	return &Config{
-		Port:        getEnv("PORT", "8081"),
-		DatabaseURL: getEnv("DATABASE_URL", "postgresql://localhost:5432/autolytiq"),
+		Port:          getEnv("PORT", "8081"),
+		DatabaseURL:   getEnv("DATABASE_URL", "postgresql://localhost:5432/autolytiq"),
+		MaxRateMarkup: getEnvFloat("MAX_RATE_MARKUP", 2.5),
+
+		InventoryServiceURL: getEnv("INVENTORY_SERVICE_URL", "http://localhost:8083"),
+		CustomerServiceURL:  getEnv("CUSTOMER_SERVICE_URL", "http://localhost:8082"),
+		ConfigServiceURL:    getEnv("CONFIG_SERVICE_URL", "http://localhost:8086"),
+
+		DocumentStorageDir: getEnv("DOCUMENT_STORAGE_DIR", "/var/lib/deal-service/documents"),
+		DocumentURLSecret:  os.Getenv("DOCUMENT_URL_SECRET"),
+		DocumentURLTTL:     getEnvDuration("DOCUMENT_URL_TTL", 15*time.Minute),
+		EncryptEnabled:     os.Getenv("PII_ENCRYPTION_KEY") != "",
+
+		ESignProviderURL:            os.Getenv("ESIGN_PROVIDER_URL"),
+		ESignAPIKey:                 os.Getenv("ESIGN_API_KEY"),
+		ESignWebhookSecret:          os.Getenv("ESIGN_WEBHOOK_SECRET"),
+		RequireSignaturesForFunding: os.Getenv("REQUIRE_SIGNATURES_FOR_FUNDING") == "true",
+
+		QuoteTTL:    getEnvDuration("QUOTE_TTL", defaultQuoteTTL),
+		LostReasons: parseLostReasons(os.Getenv("LOST_REASONS")),
+
+		AuditServiceURL: getEnv("DATA_RETENTION_SERVICE_URL", "http://localhost:8091"),
 	}
 }
 
@@ -271,6 +684,24 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
 func main() {
 	// Initialize logger
 	logger := logging.New(logging.Config{
@@ -286,14 +717,24 @@ func main() {
 	}
 	defer db.Close()
 
+	// Stop serving on SIGINT or SIGTERM, once in-flight requests finish
+	ctx, stop := graceful.SignalContext(context.Background())
+	defer stop()
+
+	// Start serving before the schema is initialized, so /live answers and
+	// /ready reports not ready until startup finishes
+	server := NewServer(config, db, logger)
+	defer server.tracer.Shutdown(context.Background())
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.Start(ctx) }()
+
 	// Initialize schema
 	if err := db.InitSchema(); err != nil {
 		logger.Fatalf("Failed to initialize schema: %v", err)
 	}
+	server.health.MarkReady()
 
-	// Create and start server
-	server := NewServer(config, db, logger)
-	if err := server.Start(); err != nil {
+	if err := <-serveErr; err != nil {
 		logger.Fatal(err.Error())
 	}
 }
@@ -2,24 +2,56 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	"autolytiq/shared/configclient"
+	"autolytiq/shared/logging"
+
 	"github.com/google/uuid"
 )
 
 // MockDatabase is a mock implementation of the Database for testing
 type MockDatabase struct {
-	deals map[string]*Deal
+	deals     map[string]*Deal
+	history   map[string][]*DealHistoryEntry
+	versions  map[string][]*DealVersion
+	documents map[string]*DealDocument
+	notes     map[string][]*DealNote
+
+	exported   map[string]time.Time
+	accounting map[string]*AccountingMapping
+
+	creditApplications map[string][]*CreditApplication
+	signatureRequests  map[string][]*SignatureRequest
+	quotes             map[string]*DealQuote
+
+	// pingErr is returned by Ping, as if the database were down
+	pingErr error
 }
 
 func NewMockDatabase() *MockDatabase {
 	return &MockDatabase{
-		deals: make(map[string]*Deal),
+		deals:     make(map[string]*Deal),
+		history:   make(map[string][]*DealHistoryEntry),
+		versions:  make(map[string][]*DealVersion),
+		documents: make(map[string]*DealDocument),
+		notes:     make(map[string][]*DealNote),
+
+		exported:   make(map[string]time.Time),
+		accounting: make(map[string]*AccountingMapping),
+
+		creditApplications: make(map[string][]*CreditApplication),
+		signatureRequests:  make(map[string][]*SignatureRequest),
+		quotes:             make(map[string]*DealQuote),
 	}
 }
 
@@ -27,11 +59,16 @@ func (db *MockDatabase) Close() error {
 	return nil
 }
 
+func (db *MockDatabase) Ping(ctx context.Context) error {
+	return db.pingErr
+}
+
 func (db *MockDatabase) InitSchema() error {
 	return nil
 }
 
 func (db *MockDatabase) CreateDeal(deal *Deal) error {
+	deal.Version = 1
 	db.deals[deal.ID] = deal
 	return nil
 }
@@ -41,42 +78,276 @@ func (db *MockDatabase) GetDeal(id string) (*Deal, error) {
 	if !exists {
 		return nil, nil
 	}
-	return deal, nil
+	// Return a copy so rejected updates do not leak into stored state
+	copied := *deal
+	return &copied, nil
 }
 
-func (db *MockDatabase) ListDeals(dealershipID string) ([]*Deal, error) {
+func (db *MockDatabase) ListDeals(filter DealListFilter) ([]*Deal, error) {
 	var deals []*Deal
 	for _, deal := range db.deals {
-		if dealershipID == "" || deal.DealershipID == dealershipID {
-			deals = append(deals, deal)
+		if filter.DealershipID != "" && deal.DealershipID != filter.DealershipID {
+			continue
+		}
+		if filter.CustomerID != "" && deal.CustomerID != filter.CustomerID && deal.CoCustomerID != filter.CustomerID {
+			continue
 		}
+		deals = append(deals, deal)
 	}
 	return deals, nil
 }
 
 func (db *MockDatabase) UpdateDeal(deal *Deal) error {
-	if _, exists := db.deals[deal.ID]; !exists {
+	existing, exists := db.deals[deal.ID]
+	if !exists {
 		return fmt.Errorf("deal not found: %s", deal.ID)
 	}
+	if existing.Version != deal.Version {
+		return ErrVersionConflict
+	}
+	deal.Version++
 	db.deals[deal.ID] = deal
 	return nil
 }
 
-func (db *MockDatabase) DeleteDeal(id string) error {
-	if _, exists := db.deals[id]; !exists {
+func (db *MockDatabase) DeleteDeal(id string, version int) error {
+	existing, exists := db.deals[id]
+	if !exists {
 		return fmt.Errorf("deal not found: %s", id)
 	}
+	if existing.Version != version {
+		return ErrVersionConflict
+	}
 	delete(db.deals, id)
 	return nil
 }
 
+func (db *MockDatabase) CreateDealHistory(entry *DealHistoryEntry) error {
+	db.history[entry.DealID] = append(db.history[entry.DealID], entry)
+	return nil
+}
+
+func (db *MockDatabase) ListDealHistory(dealID string) ([]*DealHistoryEntry, error) {
+	return db.history[dealID], nil
+}
+
+func (db *MockDatabase) CreateDealVersion(version *DealVersion) error {
+	version.Version = len(db.versions[version.DealID]) + 1
+	db.versions[version.DealID] = append(db.versions[version.DealID], version)
+	return nil
+}
+
+func (db *MockDatabase) ListDealVersions(dealID string) ([]*DealVersion, error) {
+	return db.versions[dealID], nil
+}
+
+func (db *MockDatabase) GetDealVersion(dealID string, version int) (*DealVersion, error) {
+	versions := db.versions[dealID]
+	if version < 1 || version > len(versions) {
+		return nil, nil
+	}
+	return versions[version-1], nil
+}
+
+func (db *MockDatabase) CreateDealDocument(doc *DealDocument) error {
+	db.documents[doc.ID] = doc
+	return nil
+}
+
+func (db *MockDatabase) GetDealDocument(dealID, id string) (*DealDocument, error) {
+	doc, exists := db.documents[id]
+	if !exists || doc.DealID != dealID {
+		return nil, nil
+	}
+	return doc, nil
+}
+
+func (db *MockDatabase) ListDealDocuments(dealID string) ([]*DealDocument, error) {
+	var docs []*DealDocument
+	for _, doc := range db.documents {
+		if doc.DealID == dealID {
+			docs = append(docs, doc)
+		}
+	}
+	return docs, nil
+}
+
+func (db *MockDatabase) ListCustomerDealDocuments(customerID, dealershipID string) ([]*DealDocument, error) {
+	var docs []*DealDocument
+	for _, doc := range db.documents {
+		deal, exists := db.deals[doc.DealID]
+		if exists && deal.CustomerID == customerID && deal.DealershipID == dealershipID {
+			docs = append(docs, doc)
+		}
+	}
+	return docs, nil
+}
+
+func (db *MockDatabase) DeleteDealDocument(id string) error {
+	if _, exists := db.documents[id]; !exists {
+		return fmt.Errorf("document not found: %s", id)
+	}
+	delete(db.documents, id)
+	return nil
+}
+
+func (db *MockDatabase) CreateDealNote(note *DealNote) error {
+	db.notes[note.DealID] = append(db.notes[note.DealID], note)
+	return nil
+}
+
+func (db *MockDatabase) ListDealNotes(dealID string) ([]*DealNote, error) {
+	return db.notes[dealID], nil
+}
+
+func (db *MockDatabase) CreateCreditApplication(application *CreditApplication) error {
+	db.creditApplications[application.DealID] = append([]*CreditApplication{application}, db.creditApplications[application.DealID]...)
+	return nil
+}
+
+func (db *MockDatabase) ListCreditApplications(dealID string) ([]*CreditApplication, error) {
+	return db.creditApplications[dealID], nil
+}
+
+func (db *MockDatabase) CreateSignatureRequest(request *SignatureRequest) error {
+	copied := *request
+	db.signatureRequests[request.DealID] = append([]*SignatureRequest{&copied}, db.signatureRequests[request.DealID]...)
+	return nil
+}
+
+func (db *MockDatabase) UpdateSignatureRequest(request *SignatureRequest) error {
+	for i, existing := range db.signatureRequests[request.DealID] {
+		if existing.ID == request.ID {
+			copied := *request
+			db.signatureRequests[request.DealID][i] = &copied
+			return nil
+		}
+	}
+	return fmt.Errorf("signature request not found: %s", request.ID)
+}
+
+func (db *MockDatabase) GetSignatureRequestByEnvelope(envelopeID string) (*SignatureRequest, error) {
+	for _, requests := range db.signatureRequests {
+		for _, request := range requests {
+			if request.EnvelopeID == envelopeID {
+				copied := *request
+				copied.Signers = append([]SignatureSigner(nil), request.Signers...)
+				return &copied, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (db *MockDatabase) ListSignatureRequests(dealID string) ([]*SignatureRequest, error) {
+	return db.signatureRequests[dealID], nil
+}
+
+func (db *MockDatabase) CreateDealQuote(quote *DealQuote) error {
+	copied := *quote
+	db.quotes[quote.ID] = &copied
+	return nil
+}
+
+func (db *MockDatabase) GetDealQuote(id string) (*DealQuote, error) {
+	quote, ok := db.quotes[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *quote
+	return &copied, nil
+}
+
+func (db *MockDatabase) ClaimDealQuote(id, dealID string, at time.Time) (bool, error) {
+	quote, ok := db.quotes[id]
+	if !ok || quote.DealID != "" || !at.Before(quote.ExpiresAt) {
+		return false, nil
+	}
+	quote.DealID = dealID
+	return true, nil
+}
+
+func (db *MockDatabase) ReleaseDealQuote(id string) error {
+	if quote, ok := db.quotes[id]; ok {
+		quote.DealID = ""
+	}
+	return nil
+}
+
+// StreamAccountingDeals treats a funded deal's last update as its funding date
+func (db *MockDatabase) StreamAccountingDeals(filter AccountingExportFilter, fn func(*AccountingDeal) error) error {
+	var matched []*AccountingDeal
+	for _, deal := range db.deals {
+		if deal.DealershipID != filter.DealershipID || deal.Status != filter.Status {
+			continue
+		}
+		d := &AccountingDeal{Deal: deal}
+		date := deal.CreatedAt
+		if deal.Status == "funded" {
+			fundedAt := deal.UpdatedAt
+			d.FundedAt = &fundedAt
+			date = fundedAt
+		}
+		if (filter.From != nil && date.Before(*filter.From)) || (filter.To != nil && !date.Before(*filter.To)) {
+			continue
+		}
+		if exportedAt, ok := db.exported[deal.ID]; ok {
+			if filter.UnexportedOnly {
+				continue
+			}
+			d.ExportedAt = &exportedAt
+		}
+		matched = append(matched, d)
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Deal.ID < matched[j].Deal.ID })
+
+	for _, d := range matched {
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *MockDatabase) MarkDealsExported(dealIDs []string, batchID string, exportedAt time.Time) error {
+	for _, id := range dealIDs {
+		db.exported[id] = exportedAt
+	}
+	return nil
+}
+
+func (db *MockDatabase) GetAccountingMapping(dealershipID string) (*AccountingMapping, error) {
+	return db.accounting[dealershipID], nil
+}
+
+// testLogger creates a logger that discards output during tests
+func testLogger() *logging.Logger {
+	return logging.New(logging.Config{
+		Service: "deal-service-test",
+		Level:   logging.LevelError,
+		Output:  &bytes.Buffer{},
+	})
+}
+
 func setupTestServer() *Server {
 	config := &Config{
-		Port:        "8081",
-		DatabaseURL: "mock",
+		Port:          "8081",
+		DatabaseURL:   "mock",
+		MaxRateMarkup: 2.5,
 	}
 	db := NewMockDatabase()
-	return NewServer(config, db)
+	return NewServer(config, db, testLogger())
+}
+
+// errorResponse is the body of error responses written by apierror, with
+// validation details
+type errorResponse struct {
+	Error struct {
+		Code      string            `json:"code"`
+		Message   string            `json:"message"`
+		RequestID string            `json:"request_id"`
+		Details   []ValidationError `json:"details"`
+	} `json:"error"`
 }
 
 func TestHealthCheck(t *testing.T) {
@@ -105,6 +376,85 @@ func TestHealthCheck(t *testing.T) {
 	}
 }
 
+func TestHealthCheckDatabaseDown(t *testing.T) {
+	server := setupTestServer()
+	server.db.(*MockDatabase).pingErr = errors.New("connection refused")
+
+	req, err := http.NewRequest("GET", "/health", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 with the database down, got %d", rr.Code)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response["status"] != "unhealthy" || response["db"] != "down" {
+		t.Errorf("Expected an unhealthy status with the database down, got %v", response)
+	}
+}
+
+func TestReadinessCheck(t *testing.T) {
+	server := setupTestServer()
+
+	getStatus := func(path string) int {
+		req, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// Not ready until the schema has been initialized
+	if code := getStatus("/ready"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 before startup finished, got %d", code)
+	}
+	if code := getStatus("/live"); code != http.StatusOK {
+		t.Errorf("Expected /live to answer during startup, got %d", code)
+	}
+
+	server.health.MarkReady()
+	if code := getStatus("/ready"); code != http.StatusOK {
+		t.Errorf("Expected 200 once ready, got %d", code)
+	}
+
+	server.db.(*MockDatabase).pingErr = errors.New("connection refused")
+	if code := getStatus("/ready"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 with the database down, got %d", code)
+	}
+	if code := getStatus("/live"); code != http.StatusOK {
+		t.Errorf("Expected /live to ignore the database, got %d", code)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	server := setupTestServer()
+
+	for _, id := range []string{uuid.New().String(), uuid.New().String()} {
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest("GET", "/deals/"+id, nil))
+	}
+
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+	// Requests are labeled by route template, not by deal ID
+	want := `autolytiq_http_requests_total{method="GET",route="/deals/{id}",service="deal-service",status="404"} 2`
+	if !strings.Contains(rr.Body.String(), want) {
+		t.Errorf("Expected %q in the metrics, got:\n%s", want, rr.Body.String())
+	}
+}
+
 func TestCreateDeal(t *testing.T) {
 	server := setupTestServer()
 
@@ -153,6 +503,86 @@ func TestCreateDeal(t *testing.T) {
 	}
 }
 
+// stubSettings serves fixed settings keyed by dealership and key
+type stubSettings struct {
+	values map[string]int
+	json   map[string]string
+
+	strings map[string]string
+}
+
+func (s *stubSettings) GetString(ctx context.Context, dealershipID, key string) (string, error) {
+	v, ok := s.strings[dealershipID+"/"+key]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", configclient.ErrNotFound, key)
+	}
+	return v, nil
+}
+
+func (s *stubSettings) GetInt(ctx context.Context, dealershipID, key string) (int, error) {
+	v, ok := s.values[dealershipID+"/"+key]
+	if !ok {
+		return 0, fmt.Errorf("config setting not found: %s", key)
+	}
+	return v, nil
+}
+
+func (s *stubSettings) GetJSON(ctx context.Context, dealershipID, key string, v interface{}) error {
+	raw, ok := s.json[dealershipID+"/"+key]
+	if !ok {
+		return fmt.Errorf("%w: %s", configclient.ErrNotFound, key)
+	}
+	return json.Unmarshal([]byte(raw), v)
+}
+
+func TestCreateDealAppliesDefaultTerm(t *testing.T) {
+	configured := uuid.New().String()
+	unconfigured := uuid.New().String()
+
+	tests := []struct {
+		name         string
+		dealershipID string
+		termMonths   int
+		expectedTerm int
+	}{
+		{"default applied", configured, 0, 72},
+		{"explicit term kept", configured, 36, 36},
+		{"lookup failure leaves term unset", unconfigured, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestServer()
+			server.settings = &stubSettings{values: map[string]int{
+				configured + "/" + settingDefaultTermMonths: 72,
+			}}
+
+			body, _ := json.Marshal(CreateDealRequest{
+				DealershipID: tt.dealershipID,
+				CustomerID:   uuid.New().String(),
+				VehiclePrice: 30000.00,
+				TermMonths:   tt.termMonths,
+			})
+			req := httptest.NewRequest("POST", "/deals", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusCreated {
+				t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+			}
+
+			var created Deal
+			if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+				t.Fatal(err)
+			}
+			if created.TermMonths != tt.expectedTerm {
+				t.Errorf("Expected term %d, got %d", tt.expectedTerm, created.TermMonths)
+			}
+		})
+	}
+}
+
 func TestGetDeal(t *testing.T) {
 	server := setupTestServer()
 	mockDB := server.db.(*MockDatabase)
@@ -270,6 +700,7 @@ func TestUpdateDeal(t *testing.T) {
 		Status:       "draft",
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
+		Version:      1,
 	}
 	mockDB.deals[dealID] = testDeal
 
@@ -278,7 +709,7 @@ func TestUpdateDeal(t *testing.T) {
 		DealershipID: testDeal.DealershipID,
 		CustomerID:   testDeal.CustomerID,
 		VehiclePrice: 28000.00,
-		Status:       "submitted",
+		Status:       "pending",
 	}
 
 	body, err := json.Marshal(updatedDeal)
@@ -291,6 +722,7 @@ func TestUpdateDeal(t *testing.T) {
 		t.Fatal(err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 
 	rr := httptest.NewRecorder()
 	server.router.ServeHTTP(rr, req)
@@ -309,8 +741,64 @@ func TestUpdateDeal(t *testing.T) {
 		t.Errorf("Expected vehicle price 28000.00, got %f", result.VehiclePrice)
 	}
 
-	if result.Status != "submitted" {
-		t.Errorf("Expected status 'submitted', got '%s'", result.Status)
+	if result.Status != "pending" {
+		t.Errorf("Expected status 'pending', got '%s'", result.Status)
+	}
+
+	if result.Version != 2 || rr.Header().Get("ETag") != `"2"` {
+		t.Errorf("Expected version 2 and its ETag, got %d and %s", result.Version, rr.Header().Get("ETag"))
+	}
+}
+
+func TestUpdateDealRequiresCurrentVersion(t *testing.T) {
+	server := setupTestServer()
+	deal := &Deal{ID: uuid.New().String(), DealershipID: uuid.New().String(), VehiclePrice: 25000, Status: "draft"}
+	server.db.CreateDeal(deal)
+
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("GET", "/deals/"+deal.ID, nil))
+	tag := rr.Header().Get("ETag")
+	if tag != `"1"` {
+		t.Fatalf(`Expected ETag "1", got %q`, tag)
+	}
+
+	update := func(ifMatch string, price float64) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(UpdateDealRequest{VehiclePrice: price})
+		req := httptest.NewRequest("PUT", "/deals/"+deal.ID, bytes.NewBuffer(body))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := update("", 26000); rr.Code != http.StatusPreconditionRequired {
+		t.Errorf("Expected 428 without If-Match, got %d", rr.Code)
+	}
+	if rr := update(tag, 26000); rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 with the current ETag, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// A second writer holding the same ETag has lost the race
+	rr = update(tag, 27000)
+	if rr.Code != http.StatusPreconditionFailed || !strings.Contains(rr.Body.String(), "PRECONDITION_FAILED") {
+		t.Errorf("Expected 412 with a stale ETag, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if stored, _ := server.db.GetDeal(deal.ID); stored.VehiclePrice != 26000 || stored.Version != 2 {
+		t.Errorf("Expected the first update only, got price %.2f at version %d", stored.VehiclePrice, stored.Version)
+	}
+
+	// Deletes are held to the same precondition
+	req := httptest.NewRequest("DELETE", "/deals/"+deal.ID, nil)
+	req.Header.Set("If-Match", tag)
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 deleting with a stale ETag, got %d", rr.Code)
+	}
+	if stored, _ := server.db.GetDeal(deal.ID); stored == nil {
+		t.Error("Expected the deal not to be deleted")
 	}
 }
 
@@ -328,6 +816,7 @@ func TestDeleteDeal(t *testing.T) {
 		Status:       "draft",
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
+		Version:      1,
 	}
 	mockDB.deals[dealID] = testDeal
 
@@ -335,6 +824,7 @@ func TestDeleteDeal(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	req.Header.Set("If-Match", `"1"`)
 
 	rr := httptest.NewRecorder()
 	server.router.ServeHTTP(rr, req)
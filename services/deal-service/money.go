@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+
+	"autolytiq/shared/configclient"
+)
+
+// settingRoundingPolicy is the config-service key for how a dealership rounds
+// deal money amounts
+const settingRoundingPolicy = "money_rounding_policy"
+
+// RoundingPolicy is how deal money amounts are rounded
+type RoundingPolicy string
+
+// Rounding policies
+const (
+	// RoundingCent rounds to the nearest cent, halves away from zero
+	RoundingCent RoundingPolicy = "cent"
+	// RoundingBankers rounds to the nearest cent, halves to the even cent
+	RoundingBankers RoundingPolicy = "bankers"
+	// RoundingDollar rounds to the nearest whole dollar, halves away from zero
+	RoundingDollar RoundingPolicy = "dollar"
+)
+
+// DefaultRoundingPolicy is used for dealerships without a policy configured
+const DefaultRoundingPolicy = RoundingCent
+
+// Valid reports whether p is a known rounding policy
+func (p RoundingPolicy) Valid() bool {
+	switch p {
+	case RoundingCent, RoundingBankers, RoundingDollar:
+		return true
+	}
+	return false
+}
+
+// microsPerCent is the resolution amounts are read at before rounding. Going
+// through integer micro-dollars keeps amounts like 1.005, stored in binary as
+// 1.00499..., from rounding the wrong way.
+const microsPerCent = 10000
+
+// toMicros converts a dollar amount to integer micro-dollars
+func toMicros(amount float64) int64 {
+	return int64(math.Round(amount * 100 * microsPerCent))
+}
+
+// fromCents converts integer cents to a dollar amount
+func fromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
+
+// Cents rounds a dollar amount under the policy and returns it in cents
+func (p RoundingPolicy) Cents(amount float64) int64 {
+	return p.roundMicros(toMicros(amount))
+}
+
+// Round rounds a dollar amount under the policy
+func (p RoundingPolicy) Round(amount float64) float64 {
+	return fromCents(p.Cents(amount))
+}
+
+// RoundCents rounds an amount already in cents under the policy, which only
+// changes it when rounding to whole dollars
+func (p RoundingPolicy) RoundCents(cents int64) int64 {
+	return p.roundMicros(cents * microsPerCent)
+}
+
+// roundMicros rounds micro-dollars to the policy's unit and returns cents
+func (p RoundingPolicy) roundMicros(micros int64) int64 {
+	unit := int64(microsPerCent)
+	if p == RoundingDollar {
+		unit = 100 * microsPerCent
+	}
+
+	negative := micros < 0
+	if negative {
+		micros = -micros
+	}
+	units, remainder := micros/unit, micros%unit
+	switch {
+	case remainder*2 > unit:
+		units++
+	case remainder*2 == unit && (p != RoundingBankers || units%2 == 1):
+		units++
+	}
+	if negative {
+		units = -units
+	}
+	return units * unit / microsPerCent
+}
+
+// roundCents rounds a money amount to the nearest cent
+func roundCents(amount float64) float64 {
+	return RoundingCent.Round(amount)
+}
+
+// amountFinanced returns the balance the customer finances on the deal,
+// including its products, summed in whole cents and rounded under the policy
+func (d *Deal) amountFinanced(p RoundingPolicy) float64 {
+	cents := RoundingCent.Cents(d.VehiclePrice) - RoundingCent.Cents(d.TradeInValue) +
+		RoundingCent.Cents(d.TradeInPayoff) - RoundingCent.Cents(d.DownPayment) + RoundingCent.Cents(d.TaxAmount) +
+		d.productPriceCents()
+	if cents < 0 {
+		return 0
+	}
+	return fromCents(p.RoundCents(cents))
+}
+
+// CalculateTotal sets and returns the deal's total: the vehicle price less
+// the trade-in value and down payment, plus the trade-in payoff and tax,
+// summed in whole cents. The total is negative when the customer's credits
+// exceed what they owe.
+func (d *Deal) CalculateTotal() float64 {
+	cents := RoundingCent.Cents(d.VehiclePrice) - RoundingCent.Cents(d.TradeInValue) +
+		RoundingCent.Cents(d.TradeInPayoff) - RoundingCent.Cents(d.DownPayment) + RoundingCent.Cents(d.TaxAmount)
+	d.TotalAmount = fromCents(cents)
+	return d.TotalAmount
+}
+
+// fees returns the part of the deal total not accounted for by the vehicle
+// price and tax, such as documentation and registration fees
+func (d *Deal) fees(p RoundingPolicy) float64 {
+	cents := RoundingCent.Cents(d.TotalAmount) - RoundingCent.Cents(d.VehiclePrice) - RoundingCent.Cents(d.TaxAmount)
+	if cents < 0 {
+		return 0
+	}
+	return fromCents(p.RoundCents(cents))
+}
+
+// monthlyPaymentAmount returns the deal's level monthly payment at its sell
+// rate, rounded under the policy
+func (d *Deal) monthlyPaymentAmount(p RoundingPolicy) float64 {
+	return p.Round(monthlyPayment(d.amountFinanced(p), d.SellRate, d.TermMonths))
+}
+
+// roundDealAmounts rounds the deal's stored tax, total and product amounts
+// under the policy
+func (d *Deal) roundDealAmounts(p RoundingPolicy) {
+	d.TaxAmount = p.Round(d.TaxAmount)
+	d.TotalAmount = p.Round(d.TotalAmount)
+	for i := range d.Products {
+		d.Products[i].Cost = p.Round(d.Products[i].Cost)
+		d.Products[i].Price = p.Round(d.Products[i].Price)
+	}
+}
+
+// roundingPolicy returns the dealership's rounding policy. Dealerships
+// without one, or whose policy can't be read, use the default.
+func (s *Server) roundingPolicy(r *http.Request, dealershipID string) RoundingPolicy {
+	if s.settings == nil {
+		return DefaultRoundingPolicy
+	}
+
+	value, err := s.settings.GetString(r.Context(), dealershipID, settingRoundingPolicy)
+	if err != nil {
+		if !errors.Is(err, configclient.ErrNotFound) {
+			s.logger.WithContext(r.Context()).WithError(err).WithField("dealership_id", dealershipID).Warn("Failed to load rounding policy")
+		}
+		return DefaultRoundingPolicy
+	}
+
+	policy := RoundingPolicy(value)
+	if !policy.Valid() {
+		s.logger.WithContext(r.Context()).WithField("dealership_id", dealershipID).WithField("policy", value).Warn("Unknown rounding policy, using default")
+		return DefaultRoundingPolicy
+	}
+	return policy
+}
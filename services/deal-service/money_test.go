@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRoundingPolicyRound(t *testing.T) {
+	tests := []struct {
+		amount  float64
+		cent    float64
+		bankers float64
+		dollar  float64
+	}{
+		{2.345, 2.35, 2.34, 2},
+		{2.355, 2.36, 2.36, 2},
+		// 1.005 is stored as 1.00499..., which naive rounding takes down
+		{1.005, 1.01, 1.00, 1},
+		{12.50, 12.50, 12.50, 13},
+		{13.499, 13.50, 13.50, 13},
+		{-2.345, -2.35, -2.34, -2},
+		{0, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		for policy, want := range map[RoundingPolicy]float64{
+			RoundingCent:    tt.cent,
+			RoundingBankers: tt.bankers,
+			RoundingDollar:  tt.dollar,
+		} {
+			if got := policy.Round(tt.amount); got != want {
+				t.Errorf("%s rounding of %v = %v, want %v", policy, tt.amount, got, want)
+			}
+		}
+	}
+}
+
+func TestDealAmountsUnderRoundingPolicies(t *testing.T) {
+	tests := []struct {
+		policy         RoundingPolicy
+		tax            float64
+		total          float64
+		amountFinanced float64
+		fees           float64
+		payment        float64
+		reserve        float64
+	}{
+		{RoundingCent, 1500.13, 26510.75, 21500.12, 10.63, 415.66, 1182.01},
+		{RoundingBankers, 1500.12, 26510.74, 21500.11, 10.63, 415.66, 1182.01},
+		{RoundingDollar, 1500, 26511, 21500, 11, 416, 1182},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			deal := &Deal{
+				VehiclePrice: 24999.99,
+				TradeInValue: 3000,
+				DownPayment:  2000,
+				TaxAmount:    1500.125,
+				TotalAmount:  26510.745,
+				TermMonths:   60,
+				BuyRate:      4.0,
+				SellRate:     6.0,
+			}
+			deal.roundDealAmounts(tt.policy)
+
+			if deal.TaxAmount != tt.tax || deal.TotalAmount != tt.total {
+				t.Errorf("Expected tax %v and total %v, got %v and %v", tt.tax, tt.total, deal.TaxAmount, deal.TotalAmount)
+			}
+			if got := deal.amountFinanced(tt.policy); got != tt.amountFinanced {
+				t.Errorf("Expected amount financed %v, got %v", tt.amountFinanced, got)
+			}
+			if got := deal.fees(tt.policy); got != tt.fees {
+				t.Errorf("Expected fees %v, got %v", tt.fees, got)
+			}
+			if got := deal.monthlyPaymentAmount(tt.policy); got != tt.payment {
+				t.Errorf("Expected monthly payment %v, got %v", tt.payment, got)
+			}
+			if report := BuildProfitabilityReport(deal, nil, tt.policy); report.Reserve != tt.reserve {
+				t.Errorf("Expected reserve %v, got %v", tt.reserve, report.Reserve)
+			}
+		})
+	}
+}
+
+func TestCreateDealAppliesDealershipRoundingPolicy(t *testing.T) {
+	dollars := uuid.New().String()
+	unknown := uuid.New().String()
+	unconfigured := uuid.New().String()
+
+	tests := []struct {
+		name         string
+		dealershipID string
+		tax          float64
+	}{
+		{"dealership rounds to dollars", dollars, 1235},
+		{"unknown policy uses cents", unknown, 1234.56},
+		{"no policy uses cents", unconfigured, 1234.56},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestServer()
+			server.settings = &stubSettings{strings: map[string]string{
+				dollars + "/" + settingRoundingPolicy: "dollar",
+				unknown + "/" + settingRoundingPolicy: "nearest_nickel",
+			}}
+
+			body, _ := json.Marshal(CreateDealRequest{
+				DealershipID: tt.dealershipID,
+				CustomerID:   uuid.New().String(),
+				VehiclePrice: 25000,
+				TaxAmount:    1234.56,
+			})
+			req := httptest.NewRequest("POST", "/deals", bytes.NewBuffer(body))
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusCreated {
+				t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+			}
+			var deal Deal
+			json.Unmarshal(rr.Body.Bytes(), &deal)
+			if deal.TaxAmount != tt.tax {
+				t.Errorf("Expected tax %v, got %v", tt.tax, deal.TaxAmount)
+			}
+		})
+	}
+}
+
+func TestCalculateTotal(t *testing.T) {
+	tests := []struct {
+		name  string
+		deal  Deal
+		total float64
+	}{
+		{"zero down payment", Deal{VehiclePrice: 30000, TradeInValue: 5000, TaxAmount: 1750}, 26750},
+		{"payoff exceeds trade-in value", Deal{VehiclePrice: 30000, TradeInValue: 8000, TradeInPayoff: 11000.50, DownPayment: 2000, TaxAmount: 1500}, 32500.50},
+		{"amounts summed in cents", Deal{VehiclePrice: 0.1, TaxAmount: 0.2}, 0.3},
+		{"credits exceed the price", Deal{VehiclePrice: 10000, TradeInValue: 6000, DownPayment: 5000}, -1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.deal.CalculateTotal(); got != tt.total || tt.deal.TotalAmount != tt.total {
+				t.Errorf("Expected total %v, got %v (stored %v)", tt.total, got, tt.deal.TotalAmount)
+			}
+		})
+	}
+}
+
+func TestDealTotalIsCalculatedAndNeverNegative(t *testing.T) {
+	server := setupTestServer()
+
+	rr := sendDealRequest(t, server, "POST", "/deals", CreateDealRequest{
+		DealershipID:  uuid.New().String(),
+		CustomerID:    uuid.New().String(),
+		VehiclePrice:  25000,
+		TradeInValue:  4000,
+		TradeInPayoff: 6500,
+		TaxAmount:     1400,
+	}, "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var deal Deal
+	json.Unmarshal(rr.Body.Bytes(), &deal)
+	if deal.TotalAmount != 28900 {
+		t.Errorf("Expected a total of 28900, got %v", deal.TotalAmount)
+	}
+	if stored, _ := server.db.GetDeal(deal.ID); stored.TotalAmount != 28900 {
+		t.Errorf("Expected the total to be stored, got %v", stored.TotalAmount)
+	}
+
+	// The update recalculates the total
+	rr = sendDealRequest(t, server, "PUT", "/deals/"+deal.ID, UpdateDealRequest{DownPayment: 3000}, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	json.Unmarshal(rr.Body.Bytes(), &deal)
+	if deal.TotalAmount != 25900 {
+		t.Errorf("Expected a total of 25900 after the down payment, got %v", deal.TotalAmount)
+	}
+
+	// An update that would make it negative is rejected
+	rr = sendDealRequest(t, server, "PUT", "/deals/"+deal.ID, UpdateDealRequest{DownPayment: 40000}, "")
+	if rr.Code != http.StatusBadRequest || !bytes.Contains(rr.Body.Bytes(), []byte("total_amount")) {
+		t.Errorf("Expected 400 for a negative total, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if stored, _ := server.db.GetDeal(deal.ID); stored.DownPayment != 3000 {
+		t.Errorf("Expected the rejected update not to be saved, got down payment %v", stored.DownPayment)
+	}
+
+	rr = sendDealRequest(t, server, "POST", "/deals", CreateDealRequest{
+		DealershipID: uuid.New().String(),
+		CustomerID:   uuid.New().String(),
+		VehiclePrice: 10000,
+		TradeInValue: 12000,
+	}, "")
+	if rr.Code != http.StatusBadRequest || !bytes.Contains(rr.Body.Bytes(), []byte("total_amount")) {
+		t.Errorf("Expected 400 creating a deal with a negative total, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"autolytiq/shared/configclient"
+)
+
+// settingProductCatalog is the config-service key holding a dealership's F&I
+// product catalog as JSON
+const settingProductCatalog = "fi_product_catalog"
+
+// F&I product types
+const (
+	ProductWarranty    = "warranty"
+	ProductGAP         = "gap"
+	ProductMaintenance = "maintenance"
+	ProductTireWheel   = "tire_wheel"
+	ProductAppearance  = "appearance"
+	ProductTheft       = "theft"
+)
+
+// productTypeLabels are the valid product types and the names they are
+// listed under on documents
+var productTypeLabels = map[string]string{
+	ProductWarranty:    "Extended warranty",
+	ProductGAP:         "GAP coverage",
+	ProductMaintenance: "Maintenance plan",
+	ProductTireWheel:   "Tire & wheel protection",
+	ProductAppearance:  "Appearance protection",
+	ProductTheft:       "Theft protection",
+}
+
+// Product limits
+const (
+	maxDealProducts        = 20
+	maxProductTermMonths   = 120
+	maxProductProviderName = 100
+)
+
+// DealProduct is an F&I product sold on a deal, such as a warranty or GAP.
+// Its price is financed with the deal and its profit is back-end gross.
+type DealProduct struct {
+	Type       string  `json:"type"`
+	Provider   string  `json:"provider"`
+	Cost       float64 `json:"cost"`
+	Price      float64 `json:"price"`
+	TermMonths int     `json:"term_months"`
+}
+
+// ProductCatalog is the F&I products a dealership offers. When a dealership
+// has a catalog, deals may only carry products listed in it.
+type ProductCatalog struct {
+	Products []CatalogProduct `json:"products"`
+}
+
+// CatalogProduct is a product type offered through a provider, with the
+// terms it is sold for. A zero term bound is not checked.
+type CatalogProduct struct {
+	Type          string `json:"type"`
+	Provider      string `json:"provider"`
+	MinTermMonths int    `json:"min_term_months"`
+	MaxTermMonths int    `json:"max_term_months"`
+}
+
+// find returns the catalog entry for a product's type and provider
+func (c *ProductCatalog) find(product DealProduct) *CatalogProduct {
+	for i, p := range c.Products {
+		if p.Type == product.Type && strings.EqualFold(p.Provider, product.Provider) {
+			return &c.Products[i]
+		}
+	}
+	return nil
+}
+
+// ProductProfit is the profit on one product in a profitability report
+type ProductProfit struct {
+	Type     string  `json:"type"`
+	Provider string  `json:"provider"`
+	Price    float64 `json:"price"`
+	Cost     float64 `json:"cost"`
+	Gross    float64 `json:"gross"`
+}
+
+// productPriceCents returns the total price of the deal's products in cents
+func (d *Deal) productPriceCents() int64 {
+	var cents int64
+	for _, p := range d.Products {
+		cents += RoundingCent.Cents(p.Price)
+	}
+	return cents
+}
+
+// productProfits itemizes the profit on the deal's products and returns the
+// total product gross, rounded under the policy
+func (d *Deal) productProfits(p RoundingPolicy) ([]ProductProfit, float64) {
+	var profits []ProductProfit
+	var totalCents int64
+	for _, product := range d.Products {
+		cents := RoundingCent.Cents(product.Price) - RoundingCent.Cents(product.Cost)
+		totalCents += cents
+		profits = append(profits, ProductProfit{
+			Type:     product.Type,
+			Provider: product.Provider,
+			Price:    product.Price,
+			Cost:     product.Cost,
+			Gross:    fromCents(p.RoundCents(cents)),
+		})
+	}
+	return profits, fromCents(p.RoundCents(totalCents))
+}
+
+// sanitizeProducts normalizes product types and provider names
+func sanitizeProducts(products []DealProduct) {
+	for i := range products {
+		products[i].Type = strings.TrimSpace(strings.ToLower(products[i].Type))
+		products[i].Provider = strings.TrimSpace(products[i].Provider)
+	}
+}
+
+// validateProducts validates deal products shared by create and update
+// requests. Catalog rules are checked separately, once the dealership is known.
+func validateProducts(products []DealProduct) []ValidationError {
+	var errors []ValidationError
+
+	if len(products) > maxDealProducts {
+		return []ValidationError{{
+			Field:   "products",
+			Message: fmt.Sprintf("A deal can have at most %d products", maxDealProducts),
+		}}
+	}
+
+	for i, p := range products {
+		field := fmt.Sprintf("products[%d]", i)
+
+		if _, ok := productTypeLabels[p.Type]; !ok {
+			errors = append(errors, ValidationError{
+				Field:   field + ".type",
+				Message: "Invalid product type. Must be one of: warranty, gap, maintenance, tire_wheel, appearance, theft",
+			})
+		}
+
+		if p.Provider == "" {
+			errors = append(errors, ValidationError{
+				Field:   field + ".provider",
+				Message: "Provider is required",
+			})
+		} else if len(p.Provider) > maxProductProviderName {
+			errors = append(errors, ValidationError{
+				Field:   field + ".provider",
+				Message: fmt.Sprintf("Must be at most %d characters", maxProductProviderName),
+			})
+		}
+
+		if p.Cost < 0 {
+			errors = append(errors, ValidationError{
+				Field:   field + ".cost",
+				Message: "Cost cannot be negative",
+			})
+		}
+
+		if p.Price < p.Cost {
+			errors = append(errors, ValidationError{
+				Field:   field + ".price",
+				Message: "Price cannot be lower than cost",
+			})
+		}
+
+		if p.TermMonths < 1 || p.TermMonths > maxProductTermMonths {
+			errors = append(errors, ValidationError{
+				Field:   field + ".term_months",
+				Message: fmt.Sprintf("Term must be between 1 and %d months", maxProductTermMonths),
+			})
+		}
+	}
+
+	return errors
+}
+
+// checkProductCatalog validates the deal's products against its dealership's
+// product catalog. Dealerships without a catalog accept any valid product;
+// catalog lookup failures are logged and the catalog not enforced.
+func (s *Server) checkProductCatalog(r *http.Request, deal *Deal) *ValidationErrors {
+	if s.settings == nil || len(deal.Products) == 0 {
+		return nil
+	}
+
+	var catalog ProductCatalog
+	if err := s.settings.GetJSON(r.Context(), deal.DealershipID, settingProductCatalog, &catalog); err != nil {
+		if !errors.Is(err, configclient.ErrNotFound) {
+			s.logger.WithContext(r.Context()).WithError(err).WithField("dealership_id", deal.DealershipID).Warn("Failed to load product catalog")
+		}
+		return nil
+	}
+
+	var errs []ValidationError
+	for i, p := range deal.Products {
+		field := fmt.Sprintf("products[%d]", i)
+
+		entry := catalog.find(p)
+		if entry == nil {
+			errs = append(errs, ValidationError{
+				Field:   field,
+				Message: fmt.Sprintf("The dealership does not offer %s products from %s", p.Type, p.Provider),
+			})
+			continue
+		}
+
+		if entry.MinTermMonths > 0 && p.TermMonths < entry.MinTermMonths {
+			errs = append(errs, ValidationError{
+				Field:   field + ".term_months",
+				Message: fmt.Sprintf("Term must be at least %d months for this product", entry.MinTermMonths),
+			})
+		} else if entry.MaxTermMonths > 0 && p.TermMonths > entry.MaxTermMonths {
+			errs = append(errs, ValidationError{
+				Field:   field + ".term_months",
+				Message: fmt.Sprintf("Term must be at most %d months for this product", entry.MaxTermMonths),
+			})
+		}
+	}
+
+	if len(errs) > 0 {
+		return &ValidationErrors{Errors: errs}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func testProducts() []DealProduct {
+	return []DealProduct{
+		{Type: ProductWarranty, Provider: "Fidelity Warranty", Cost: 1200, Price: 2495, TermMonths: 60},
+		{Type: ProductGAP, Provider: "Allied", Cost: 350.5, Price: 895, TermMonths: 72},
+	}
+}
+
+func TestProductsUpdateFinancedAmountAndBackEndGross(t *testing.T) {
+	deal := &Deal{
+		ID:           uuid.New().String(),
+		VehiclePrice: 25000,
+		TradeInValue: 3000,
+		DownPayment:  2000,
+		TermMonths:   60,
+		BuyRate:      4.0,
+		SellRate:     6.0,
+	}
+	without := BuildProfitabilityReport(deal, nil, RoundingCent)
+
+	deal.Products = testProducts()
+	report := BuildProfitabilityReport(deal, nil, RoundingCent)
+
+	if report.AmountFinanced != 23390 {
+		t.Errorf("Expected products financed with the deal (23390), got %.2f", report.AmountFinanced)
+	}
+	if report.ProductGross != 1839.5 {
+		t.Errorf("Expected product gross 1839.50, got %.2f", report.ProductGross)
+	}
+	if report.Reserve <= without.Reserve {
+		t.Errorf("Expected a larger reserve on the larger balance, got %.2f and %.2f", without.Reserve, report.Reserve)
+	}
+	if got := roundCents(report.Reserve + report.ProductGross); report.BackEndGross != got || report.TotalGross != got {
+		t.Errorf("Expected back-end and total gross %.2f, got %.2f and %.2f", got, report.BackEndGross, report.TotalGross)
+	}
+
+	if len(report.Products) != 2 {
+		t.Fatalf("Expected 2 itemized products, got %+v", report.Products)
+	}
+	if p := report.Products[0]; p.Type != ProductWarranty || p.Gross != 1295 {
+		t.Errorf("Unexpected warranty profit: %+v", p)
+	}
+	if p := report.Products[1]; p.Type != ProductGAP || p.Gross != 544.5 {
+		t.Errorf("Unexpected GAP profit: %+v", p)
+	}
+}
+
+func TestCreateDealWithProducts(t *testing.T) {
+	server := setupTestServer()
+
+	rr := sendDealRequest(t, server, "POST", "/deals", CreateDealRequest{
+		DealershipID: uuid.New().String(),
+		CustomerID:   uuid.New().String(),
+		VehiclePrice: 30000,
+		Products: []DealProduct{
+			{Type: " Warranty ", Provider: " Fidelity Warranty ", Cost: 1200.004, Price: 2495.005, TermMonths: 60},
+		},
+	}, "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var created Deal
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	want := DealProduct{Type: ProductWarranty, Provider: "Fidelity Warranty", Cost: 1200, Price: 2495.01, TermMonths: 60}
+	if len(created.Products) != 1 || created.Products[0] != want {
+		t.Errorf("Expected products %+v, got %+v", want, created.Products)
+	}
+
+	// Products can be replaced, then removed
+	rr = sendDealRequest(t, server, "PUT", "/deals/"+created.ID, map[string]interface{}{"products": testProducts()}, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = sendDealRequest(t, server, "GET", "/deals/"+created.ID+"/profitability", nil, "")
+	var report ProfitabilityReport
+	json.Unmarshal(rr.Body.Bytes(), &report)
+	if report.AmountFinanced != 33390 || report.ProductGross != 1839.5 || report.BackEndGross != 1839.5 {
+		t.Errorf("Expected the replaced products in the report, got %+v", report)
+	}
+
+	rr = sendDealRequest(t, server, "PUT", "/deals/"+created.ID, map[string]interface{}{"products": []DealProduct{}}, "")
+	var updated Deal
+	json.Unmarshal(rr.Body.Bytes(), &updated)
+	if len(updated.Products) != 0 {
+		t.Errorf("Expected products removed, got %+v", updated.Products)
+	}
+}
+
+func TestDealProductValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		product DealProduct
+		field   string
+	}{
+		{"unknown type", DealProduct{Type: "paint", Provider: "Acme", Cost: 100, Price: 200, TermMonths: 12}, "products[0].type"},
+		{"no provider", DealProduct{Type: ProductGAP, Cost: 100, Price: 200, TermMonths: 12}, "products[0].provider"},
+		{"negative cost", DealProduct{Type: ProductGAP, Provider: "Acme", Cost: -1, Price: 200, TermMonths: 12}, "products[0].cost"},
+		{"price below cost", DealProduct{Type: ProductGAP, Provider: "Acme", Cost: 300, Price: 200, TermMonths: 12}, "products[0].price"},
+		{"no term", DealProduct{Type: ProductGAP, Provider: "Acme", Cost: 100, Price: 200}, "products[0].term_months"},
+		{"term too long", DealProduct{Type: ProductGAP, Provider: "Acme", Cost: 100, Price: 200, TermMonths: 121}, "products[0].term_months"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestServer()
+			rr := sendDealRequest(t, server, "POST", "/deals", CreateDealRequest{
+				DealershipID: uuid.New().String(),
+				CustomerID:   uuid.New().String(),
+				VehiclePrice: 30000,
+				Products:     []DealProduct{tt.product},
+			}, "")
+
+			if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), `"field":"`+tt.field+`"`) {
+				t.Errorf("Expected a 400 naming %s, got %d: %s", tt.field, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestDealProductsCheckedAgainstCatalog(t *testing.T) {
+	configured := uuid.New().String()
+	server := setupTestServer()
+	server.settings = &stubSettings{json: map[string]string{
+		configured + "/" + settingProductCatalog: `{"products": [
+			{"type": "warranty", "provider": "Fidelity Warranty", "min_term_months": 36, "max_term_months": 84},
+			{"type": "gap", "provider": "Allied"}
+		]}`,
+	}}
+
+	tests := []struct {
+		name         string
+		dealershipID string
+		products     []DealProduct
+		expected     int
+	}{
+		{"listed products", configured, testProducts(), http.StatusCreated},
+		{"provider matched case-insensitively", configured, []DealProduct{{Type: ProductGAP, Provider: "ALLIED", Cost: 300, Price: 800, TermMonths: 48}}, http.StatusCreated},
+		{"unlisted provider", configured, []DealProduct{{Type: ProductGAP, Provider: "Other", Cost: 300, Price: 800, TermMonths: 48}}, http.StatusBadRequest},
+		{"unlisted type", configured, []DealProduct{{Type: ProductTheft, Provider: "Allied", Cost: 100, Price: 400, TermMonths: 48}}, http.StatusBadRequest},
+		{"term below catalog minimum", configured, []DealProduct{{Type: ProductWarranty, Provider: "Fidelity Warranty", Cost: 900, Price: 1500, TermMonths: 24}}, http.StatusBadRequest},
+		{"no catalog", uuid.New().String(), []DealProduct{{Type: ProductTheft, Provider: "Other", Cost: 100, Price: 400, TermMonths: 48}}, http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := sendDealRequest(t, server, "POST", "/deals", CreateDealRequest{
+				DealershipID: tt.dealershipID,
+				CustomerID:   uuid.New().String(),
+				VehiclePrice: 30000,
+				Products:     tt.products,
+			}, "")
+			if rr.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d: %s", tt.expected, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestBuyersOrderListsProducts(t *testing.T) {
+	server, dealershipID, buyerID, coBuyerID, _ := setupBuyersServer()
+	deal := createCoBuyerDeal(t, server, dealershipID, buyerID, coBuyerID)
+	sendDealRequest(t, server, "PUT", "/deals/"+deal.ID, map[string]interface{}{"products": testProducts()}, "")
+
+	rr := sendDealRequest(t, server, "GET", "/deals/"+deal.ID+"/buyers-order", nil, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("buyer's order returned %d: %s", rr.Code, rr.Body.String())
+	}
+
+	body := rr.Body.String()
+	for _, want := range []string{"Extended warranty &middot; Fidelity Warranty &middot; 60 months", "$2495.00", "GAP coverage", "$895.00", "$35490.00"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected buyer's order to contain %q", want)
+		}
+	}
+	if strings.Contains(body, "1200.00") {
+		t.Error("Expected product cost not to appear on the buyer's order")
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+
+	"autolytiq/shared/apierror"
+
+	"github.com/gorilla/mux"
+)
+
+// ProfitabilityReport breaks down the gross profit on a deal
+type ProfitabilityReport struct {
+	DealID         string  `json:"deal_id"`
+	AmountFinanced float64 `json:"amount_financed"`
+	BuyRate        float64 `json:"buy_rate"`
+	SellRate       float64 `json:"sell_rate"`
+	RateMarkup     float64 `json:"rate_markup"`
+	Reserve        float64 `json:"reserve"`
+	BackEndGross   float64 `json:"back_end_gross"`
+	TotalGross     float64 `json:"total_gross"`
+
+	// ProductGross is the profit on the deal's F&I products, itemized in
+	// Products. It is included in back-end gross with the reserve.
+	ProductGross float64         `json:"product_gross"`
+	Products     []ProductProfit `json:"products,omitempty"`
+
+	// VehicleCost and FrontEndGross are only present when the caller may see
+	// vehicle cost (inventory:cost scope)
+	VehicleCost   *float64 `json:"vehicle_cost,omitempty"`
+	FrontEndGross *float64 `json:"front_end_gross,omitempty"`
+}
+
+// roundRate rounds a rate to three decimals, matching the stored precision
+func roundRate(rate float64) float64 {
+	return math.Round(rate*1000) / 1000
+}
+
+// monthlyPayment returns the level monthly payment for a principal at an APR
+// (in percent) over the given number of months
+func monthlyPayment(principal, apr float64, months int) float64 {
+	if months <= 0 {
+		return 0
+	}
+	if apr == 0 {
+		return principal / float64(months)
+	}
+	rate := apr / 100 / 12
+	return principal * rate / (1 - math.Pow(1+rate, -float64(months)))
+}
+
+// CalculateReserve returns the dealer reserve earned on a rate markup: the
+// additional finance charge the customer pays at the sell rate compared to
+// the lender's buy rate over the life of the loan, rounded under the policy.
+func CalculateReserve(principal, buyRate, sellRate float64, months int, p RoundingPolicy) float64 {
+	if principal <= 0 || months <= 0 || sellRate <= buyRate {
+		return 0
+	}
+	sellPayment := monthlyPayment(principal, sellRate, months)
+	buyPayment := monthlyPayment(principal, buyRate, months)
+	return p.Round((sellPayment - buyPayment) * float64(months))
+}
+
+// BuildProfitabilityReport computes the profitability breakdown for a deal.
+// vehicleCost may be nil when the cost is unknown or hidden from the caller,
+// in which case the report covers back-end gross only. Amounts are rounded
+// under the dealership's rounding policy.
+func BuildProfitabilityReport(deal *Deal, vehicleCost *float64, p RoundingPolicy) *ProfitabilityReport {
+	principal := deal.amountFinanced(p)
+	reserve := CalculateReserve(principal, deal.BuyRate, deal.SellRate, deal.TermMonths, p)
+	products, productGross := deal.productProfits(p)
+	backEnd := fromCents(RoundingCent.Cents(reserve) + RoundingCent.Cents(productGross))
+
+	markup := 0.0
+	if deal.BuyRate > 0 && deal.SellRate > deal.BuyRate {
+		markup = roundRate(deal.SellRate - deal.BuyRate)
+	}
+
+	report := &ProfitabilityReport{
+		DealID:         deal.ID,
+		AmountFinanced: principal,
+		BuyRate:        deal.BuyRate,
+		SellRate:       deal.SellRate,
+		RateMarkup:     markup,
+		Reserve:        reserve,
+		ProductGross:   productGross,
+		Products:       products,
+		BackEndGross:   backEnd,
+		TotalGross:     backEnd,
+	}
+
+	if vehicleCost != nil {
+		frontEnd := fromCents(p.RoundCents(RoundingCent.Cents(deal.VehiclePrice) - RoundingCent.Cents(*vehicleCost)))
+		report.VehicleCost = vehicleCost
+		report.FrontEndGross = &frontEnd
+		report.TotalGross = fromCents(RoundingCent.Cents(frontEnd) + RoundingCent.Cents(report.BackEndGross))
+	}
+
+	return report
+}
+
+// getDealProfitability returns the profitability report for a deal
+func (s *Server) getDealProfitability(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	deal, err := s.db.GetDeal(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get deal")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get deal: %v", err))
+		return
+	}
+
+	if deal == nil {
+		apierror.WriteError(w, http.StatusNotFound, "DEAL_NOT_FOUND", "Deal not found")
+		return
+	}
+
+	var vehicleCost *float64
+	if deal.VehicleID != "" && s.inventory != nil {
+		vehicleCost, err = s.inventory.GetVehicleCost(r, deal.VehicleID)
+		if err != nil {
+			// Report back-end gross only rather than failing the whole report
+			s.logger.WithContext(r.Context()).WithError(err).WithField("vehicle_id", deal.VehicleID).Warn("Failed to get vehicle cost")
+			vehicleCost = nil
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BuildProfitabilityReport(deal, vehicleCost, s.roundingPolicy(r, deal.DealershipID)))
+}
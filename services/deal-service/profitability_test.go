@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCalculateReserve(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal float64
+		buyRate   float64
+		sellRate  float64
+		months    int
+		expected  float64
+	}{
+		{"two point markup", 20000, 4.0, 6.0, 60, 1099.54},
+		{"no markup", 20000, 5.0, 5.0, 60, 0},
+		{"zero buy rate", 12000, 0, 1.0, 12, 65.10},
+		{"no term", 20000, 4.0, 6.0, 0, 0},
+		{"no principal", 0, 4.0, 6.0, 60, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CalculateReserve(tt.principal, tt.buyRate, tt.sellRate, tt.months, RoundingCent)
+			if got != tt.expected {
+				t.Errorf("CalculateReserve() = %.2f, want %.2f", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBuildProfitabilityReport(t *testing.T) {
+	deal := &Deal{
+		ID:           uuid.New().String(),
+		VehiclePrice: 25000,
+		TradeInValue: 3000,
+		DownPayment:  2000,
+		TermMonths:   60,
+		BuyRate:      4.0,
+		SellRate:     6.0,
+	}
+
+	report := BuildProfitabilityReport(deal, nil, RoundingCent)
+
+	if report.AmountFinanced != 20000 {
+		t.Errorf("Expected amount financed 20000, got %.2f", report.AmountFinanced)
+	}
+	if report.RateMarkup != 2.0 {
+		t.Errorf("Expected markup 2.0, got %.3f", report.RateMarkup)
+	}
+	if report.Reserve != 1099.54 {
+		t.Errorf("Expected reserve 1099.54, got %.2f", report.Reserve)
+	}
+	if report.BackEndGross != report.Reserve {
+		t.Errorf("Expected back-end gross to include reserve, got %.2f", report.BackEndGross)
+	}
+	if report.FrontEndGross != nil {
+		t.Errorf("Expected no front-end gross without vehicle cost, got %.2f", *report.FrontEndGross)
+	}
+
+	cost := 21500.0
+	report = BuildProfitabilityReport(deal, &cost, RoundingCent)
+
+	if report.FrontEndGross == nil || *report.FrontEndGross != 3500 {
+		t.Fatalf("Expected front-end gross 3500, got %v", report.FrontEndGross)
+	}
+	if report.TotalGross != 4599.54 {
+		t.Errorf("Expected total gross 4599.54, got %.2f", report.TotalGross)
+	}
+}
+
+func TestDealProfitabilityVehicleCost(t *testing.T) {
+	// Simulate inventory-service, which only returns cost with the inventory:cost scope
+	inventory := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(r.Header.Get("X-User-Scopes"), "inventory:cost") {
+			w.Write([]byte(`{"id":"v1","price":25000,"cost":21500}`))
+			return
+		}
+		w.Write([]byte(`{"id":"v1","price":25000}`))
+	}))
+	defer inventory.Close()
+
+	server := setupTestServer()
+	server.inventory = NewInventoryClient(inventory.URL)
+
+	deal := &Deal{
+		ID:           uuid.New().String(),
+		VehicleID:    uuid.New().String(),
+		VehiclePrice: 25000,
+		Status:       "draft",
+	}
+	server.db.CreateDeal(deal)
+
+	tests := []struct {
+		name          string
+		scopes        string
+		expectedFront *float64
+	}{
+		{"with cost scope", "inventory:cost", floatPtr(3500)},
+		{"without cost scope", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/deals/"+deal.ID+"/profitability", nil)
+			if tt.scopes != "" {
+				req.Header.Set("X-User-Scopes", tt.scopes)
+			}
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+			}
+
+			var report ProfitabilityReport
+			if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+				t.Fatal(err)
+			}
+
+			switch {
+			case tt.expectedFront == nil && report.FrontEndGross != nil:
+				t.Errorf("Expected no front-end gross, got %.2f", *report.FrontEndGross)
+			case tt.expectedFront != nil && (report.FrontEndGross == nil || *report.FrontEndGross != *tt.expectedFront):
+				t.Errorf("Expected front-end gross %.2f, got %v", *tt.expectedFront, report.FrontEndGross)
+			}
+		})
+	}
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func TestCreateDealRateMarkup(t *testing.T) {
+	tests := []struct {
+		name           string
+		buyRate        float64
+		sellRate       float64
+		expectedStatus int
+	}{
+		{"within cap", 4.0, 6.5, http.StatusCreated},
+		{"exceeds cap", 4.0, 6.75, http.StatusBadRequest},
+		{"sell below buy", 6.0, 5.0, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestServer()
+
+			body, _ := json.Marshal(CreateDealRequest{
+				DealershipID: uuid.New().String(),
+				CustomerID:   uuid.New().String(),
+				VehiclePrice: 30000,
+				TermMonths:   72,
+				BuyRate:      tt.buyRate,
+				SellRate:     tt.sellRate,
+			})
+
+			req := httptest.NewRequest("POST", "/deals", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestUpdateDealRecordsRateHistory(t *testing.T) {
+	server := setupTestServer()
+	mockDB := server.db.(*MockDatabase)
+
+	dealID := uuid.New().String()
+	mockDB.deals[dealID] = &Deal{
+		ID:           dealID,
+		DealershipID: uuid.New().String(),
+		CustomerID:   uuid.New().String(),
+		VehiclePrice: 25000,
+		TermMonths:   60,
+		BuyRate:      4.0,
+		SellRate:     5.0,
+		Status:       "draft",
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+
+	body, _ := json.Marshal(UpdateDealRequest{SellRate: 6.0})
+	req := httptest.NewRequest("PUT", "/deals/"+dealID, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", "*")
+	req.Header.Set("X-User-ID", "fi-manager-1")
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	history := mockDB.history[dealID]
+	if len(history) != 1 {
+		t.Fatalf("Expected 1 history entry, got %d", len(history))
+	}
+	entry := history[0]
+	if entry.Field != "sell_rate" || entry.OldValue != "5" || entry.NewValue != "6" {
+		t.Errorf("Unexpected history entry: %+v", entry)
+	}
+	if entry.ChangedBy != "fi-manager-1" {
+		t.Errorf("Expected changed_by fi-manager-1, got %s", entry.ChangedBy)
+	}
+}
+
+func TestUpdateDealRejectsMarkupOverCap(t *testing.T) {
+	server := setupTestServer()
+	mockDB := server.db.(*MockDatabase)
+
+	dealID := uuid.New().String()
+	mockDB.deals[dealID] = &Deal{
+		ID:           dealID,
+		DealershipID: uuid.New().String(),
+		VehiclePrice: 25000,
+		TermMonths:   60,
+		BuyRate:      4.0,
+		SellRate:     5.0,
+		Status:       "draft",
+	}
+
+	body, _ := json.Marshal(UpdateDealRequest{SellRate: 9.0})
+	req := httptest.NewRequest("PUT", "/deals/"+dealID, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", "*")
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rr.Code)
+	}
+	if len(mockDB.history[dealID]) != 0 {
+		t.Error("Expected no history for rejected update")
+	}
+}
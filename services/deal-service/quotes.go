@@ -0,0 +1,448 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/logging"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// defaultQuoteTTL is how long a quote can be turned into a deal when
+// QUOTE_TTL is not set
+const defaultQuoteTTL = 72 * time.Hour
+
+// maxQuoteFees is the most fees one quote can list
+const maxQuoteFees = 20
+
+// QuoteFee is a fee charged on a quote, such as documentation or registration
+type QuoteFee struct {
+	Name   string  `json:"name"`
+	Amount float64 `json:"amount"`
+}
+
+// CreateQuoteRequest represents a request to quote a deal without creating it.
+// Tax is either given as an amount or computed from TaxRate on the vehicle
+// price less the trade-in allowance.
+type CreateQuoteRequest struct {
+	DealershipID  string        `json:"dealership_id"`
+	VehicleID     string        `json:"vehicle_id,omitempty"`
+	VehiclePrice  float64       `json:"vehicle_price"`
+	TradeInValue  float64       `json:"trade_in_value"`
+	TradeInPayoff float64       `json:"trade_in_payoff"`
+	DownPayment   float64       `json:"down_payment"`
+	TaxRate       float64       `json:"tax_rate,omitempty"`
+	TaxAmount     float64       `json:"tax_amount,omitempty"`
+	Fees          []QuoteFee    `json:"fees,omitempty"`
+	TermMonths    int           `json:"term_months,omitempty"`
+	BuyRate       float64       `json:"buy_rate,omitempty"`
+	SellRate      float64       `json:"sell_rate,omitempty"`
+	Products      []DealProduct `json:"products,omitempty"`
+}
+
+// DealQuote is a priced deal that has not been created. It can be turned into
+// a deal once, until it expires.
+type DealQuote struct {
+	ID            string        `json:"id"`
+	DealershipID  string        `json:"dealership_id"`
+	VehicleID     string        `json:"vehicle_id,omitempty"`
+	VehiclePrice  float64       `json:"vehicle_price"`
+	TradeInValue  float64       `json:"trade_in_value"`
+	TradeInPayoff float64       `json:"trade_in_payoff"`
+	DownPayment   float64       `json:"down_payment"`
+	TaxRate       float64       `json:"tax_rate,omitempty"`
+	TaxAmount     float64       `json:"tax_amount"`
+	Fees          []QuoteFee    `json:"fees,omitempty"`
+	TermMonths    int           `json:"term_months,omitempty"`
+	BuyRate       float64       `json:"buy_rate,omitempty"`
+	SellRate      float64       `json:"sell_rate,omitempty"`
+	Products      []DealProduct `json:"products,omitempty"`
+
+	// Totals, computed as the deal would compute them. The total is the
+	// vehicle price plus tax and fees; products are financed on top.
+	FeesTotal      float64 `json:"fees_total"`
+	ProductsTotal  float64 `json:"products_total"`
+	TotalAmount    float64 `json:"total_amount"`
+	AmountFinanced float64 `json:"amount_financed"`
+	MonthlyPayment float64 `json:"monthly_payment"`
+
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// DealID is the deal the quote was turned into, if any
+	DealID string `json:"deal_id,omitempty"`
+}
+
+// CreateDealFromQuoteRequest names the buyers of a deal made from a quote
+type CreateDealFromQuoteRequest struct {
+	CustomerID   string `json:"customer_id"`
+	CoCustomerID string `json:"co_customer_id"`
+}
+
+// Validate validates CreateQuoteRequest
+func (r *CreateQuoteRequest) Validate() *ValidationErrors {
+	var errors []ValidationError
+
+	if r.DealershipID == "" {
+		errors = append(errors, ValidationError{
+			Field:   "dealership_id",
+			Message: "Dealership ID is required",
+		})
+	} else if !uuidRegex.MatchString(r.DealershipID) {
+		errors = append(errors, ValidationError{
+			Field:   "dealership_id",
+			Message: "Must be a valid UUID",
+		})
+	}
+
+	if r.VehicleID != "" && !uuidRegex.MatchString(r.VehicleID) {
+		errors = append(errors, ValidationError{
+			Field:   "vehicle_id",
+			Message: "Must be a valid UUID",
+		})
+	}
+
+	amounts := []struct {
+		field, label string
+		value        float64
+	}{
+		{"vehicle_price", "Vehicle price", r.VehiclePrice},
+		{"trade_in_value", "Trade-in value", r.TradeInValue},
+		{"trade_in_payoff", "Trade-in payoff", r.TradeInPayoff},
+		{"down_payment", "Down payment", r.DownPayment},
+		{"tax_amount", "Tax amount", r.TaxAmount},
+	}
+	for _, a := range amounts {
+		if a.value < 0 {
+			errors = append(errors, ValidationError{
+				Field:   a.field,
+				Message: a.label + " cannot be negative",
+			})
+		}
+	}
+
+	if r.TaxRate < 0 || r.TaxRate > 100 {
+		errors = append(errors, ValidationError{
+			Field:   "tax_rate",
+			Message: "Tax rate must be between 0 and 100",
+		})
+	} else if r.TaxRate > 0 && r.TaxAmount > 0 {
+		errors = append(errors, ValidationError{
+			Field:   "tax_rate",
+			Message: "Give either a tax rate or a tax amount, not both",
+		})
+	}
+
+	if len(r.Fees) > maxQuoteFees {
+		errors = append(errors, ValidationError{
+			Field:   "fees",
+			Message: fmt.Sprintf("A quote can have at most %d fees", maxQuoteFees),
+		})
+	}
+	for i, fee := range r.Fees {
+		field := fmt.Sprintf("fees[%d]", i)
+		if fee.Name == "" || len(fee.Name) > 100 {
+			errors = append(errors, ValidationError{
+				Field:   field + ".name",
+				Message: "Fee name must be 1 to 100 characters",
+			})
+		}
+		if fee.Amount < 0 {
+			errors = append(errors, ValidationError{
+				Field:   field + ".amount",
+				Message: "Fee amount cannot be negative",
+			})
+		}
+	}
+
+	errors = append(errors, validateFinancingTerms(r.TermMonths, r.BuyRate, r.SellRate)...)
+	errors = append(errors, validateProducts(r.Products)...)
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize sanitizes CreateQuoteRequest
+func (r *CreateQuoteRequest) Sanitize() {
+	r.DealershipID = strings.TrimSpace(r.DealershipID)
+	r.VehicleID = strings.TrimSpace(r.VehicleID)
+	for i := range r.Fees {
+		r.Fees[i].Name = strings.TrimSpace(r.Fees[i].Name)
+	}
+	sanitizeProducts(r.Products)
+}
+
+// Validate validates CreateDealFromQuoteRequest
+func (r *CreateDealFromQuoteRequest) Validate() *ValidationErrors {
+	var errors []ValidationError
+
+	if r.CustomerID != "" && !uuidRegex.MatchString(r.CustomerID) {
+		errors = append(errors, ValidationError{
+			Field:   "customer_id",
+			Message: "Must be a valid UUID",
+		})
+	}
+
+	if r.CoCustomerID != "" && !uuidRegex.MatchString(r.CoCustomerID) {
+		errors = append(errors, ValidationError{
+			Field:   "co_customer_id",
+			Message: "Must be a valid UUID",
+		})
+	}
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize sanitizes CreateDealFromQuoteRequest
+func (r *CreateDealFromQuoteRequest) Sanitize() {
+	r.CustomerID = strings.TrimSpace(r.CustomerID)
+	r.CoCustomerID = strings.TrimSpace(r.CoCustomerID)
+}
+
+// deal returns the draft deal the quote describes
+func (q *DealQuote) deal() Deal {
+	return Deal{
+		DealershipID:  q.DealershipID,
+		VehicleID:     q.VehicleID,
+		VehiclePrice:  q.VehiclePrice,
+		TradeInValue:  q.TradeInValue,
+		TradeInPayoff: q.TradeInPayoff,
+		DownPayment:   q.DownPayment,
+		TaxAmount:     q.TaxAmount,
+		TotalAmount:   q.TotalAmount,
+		TermMonths:    q.TermMonths,
+		BuyRate:       q.BuyRate,
+		SellRate:      q.SellRate,
+		Products:      append([]DealProduct(nil), q.Products...),
+	}
+}
+
+// price computes the quote's tax and totals under the rounding policy.
+// Amounts are summed in whole cents, as deals sum them.
+func (q *DealQuote) price(p RoundingPolicy) {
+	if q.TaxRate > 0 {
+		taxable := RoundingCent.Cents(q.VehiclePrice) - RoundingCent.Cents(q.TradeInValue)
+		if taxable < 0 {
+			taxable = 0
+		}
+		q.TaxAmount = p.Round(fromCents(taxable) * q.TaxRate / 100)
+	}
+
+	var feeCents int64
+	for _, fee := range q.Fees {
+		feeCents += RoundingCent.Cents(fee.Amount)
+	}
+	q.FeesTotal = fromCents(p.RoundCents(feeCents))
+	q.TotalAmount = fromCents(p.RoundCents(RoundingCent.Cents(q.VehiclePrice) + RoundingCent.Cents(q.TaxAmount) + feeCents))
+
+	deal := q.deal()
+	deal.roundDealAmounts(p)
+	q.Products = deal.Products
+	q.ProductsTotal = fromCents(p.RoundCents(deal.productPriceCents()))
+	q.AmountFinanced = deal.amountFinanced(p)
+	q.MonthlyPayment = deal.monthlyPaymentAmount(p)
+}
+
+// quoteTTL returns how long new quotes stay valid
+func (s *Server) quoteTTL() time.Duration {
+	if s.config.QuoteTTL > 0 {
+		return s.config.QuoteTTL
+	}
+	return defaultQuoteTTL
+}
+
+// createQuote handles POST /deals/quote, pricing a deal from the request
+// without creating it
+func (s *Server) createQuote(w http.ResponseWriter, r *http.Request) {
+	var req CreateQuoteRequest
+	if !decodeAndValidate(r, w, &req) {
+		return
+	}
+
+	now := time.Now()
+	quote := &DealQuote{
+		ID:            uuid.New().String(),
+		DealershipID:  req.DealershipID,
+		VehicleID:     req.VehicleID,
+		VehiclePrice:  req.VehiclePrice,
+		TradeInValue:  req.TradeInValue,
+		TradeInPayoff: req.TradeInPayoff,
+		DownPayment:   req.DownPayment,
+		TaxRate:       req.TaxRate,
+		TaxAmount:     req.TaxAmount,
+		Fees:          req.Fees,
+		TermMonths:    req.TermMonths,
+		BuyRate:       req.BuyRate,
+		SellRate:      req.SellRate,
+		Products:      req.Products,
+		CreatedBy:     logging.GetUserID(r.Context()),
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(s.quoteTTL()),
+	}
+
+	// The quote is checked against the dealership's rules now, so turning it
+	// into a deal doesn't fail on them later
+	deal := quote.deal()
+	s.applyDealershipDefaults(r, &deal)
+	quote.TermMonths = deal.TermMonths
+	if errs := validateRateMarkup(quote.BuyRate, quote.SellRate, s.config.MaxRateMarkup); errs != nil {
+		respondValidationError(w, errs)
+		return
+	}
+	if errs := s.checkProductCatalog(r, &deal); errs != nil {
+		respondValidationError(w, errs)
+		return
+	}
+
+	quote.price(s.roundingPolicy(r, quote.DealershipID))
+
+	if err := s.db.CreateDealQuote(quote); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to create quote")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to create quote: %v", err))
+		return
+	}
+
+	s.logger.WithContext(r.Context()).WithField("quote_id", quote.ID).Info("Deal quote created")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(quote)
+}
+
+// loadQuote loads the quote named in the URL, writing an error response and
+// returning nil if it doesn't exist or has expired
+func (s *Server) loadQuote(w http.ResponseWriter, r *http.Request) *DealQuote {
+	id := mux.Vars(r)["quote_id"]
+	if !validateUUID(w, id, "quote_id") {
+		return nil
+	}
+
+	quote, err := s.db.GetDealQuote(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get quote")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get quote: %v", err))
+		return nil
+	}
+	if quote == nil {
+		apierror.WriteError(w, http.StatusNotFound, "QUOTE_NOT_FOUND", "Quote not found")
+		return nil
+	}
+	if !time.Now().Before(quote.ExpiresAt) {
+		respondErrorJSON(w, http.StatusGone, "Quote has expired", "QUOTE_EXPIRED")
+		return nil
+	}
+	return quote
+}
+
+// quoteDocumentTemplate renders a quote sheet for the customer. Values are
+// escaped by html/template.
+var quoteDocumentTemplate = template.Must(template.New("quote").Funcs(template.FuncMap{
+	"money":   func(amount float64) string { return fmt.Sprintf("$%.2f", amount) },
+	"product": func(productType string) string { return productTypeLabels[productType] },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Quote {{.ID}}</title></head>
+<body>
+<h1>Vehicle Quote</h1>
+<p>Quote {{.ID}} &middot; {{.CreatedAt.Format "January 2, 2006"}} &middot; valid until {{.ExpiresAt.Format "January 2, 2006 3:04 PM MST"}}</p>
+<table>
+<tr><td>Vehicle price</td><td>{{money .VehiclePrice}}</td></tr>
+<tr><td>Trade-in allowance</td><td>{{money .TradeInValue}}</td></tr>
+<tr><td>Trade-in payoff</td><td>{{money .TradeInPayoff}}</td></tr>
+<tr><td>Down payment</td><td>{{money .DownPayment}}</td></tr>
+<tr><td>Tax{{if .TaxRate}} ({{.TaxRate}}%){{end}}</td><td>{{money .TaxAmount}}</td></tr>
+{{range .Fees}}<tr class="fee"><td>{{.Name}}</td><td>{{money .Amount}}</td></tr>
+{{end}}<tr><td>Total</td><td>{{money .TotalAmount}}</td></tr>
+{{range .Products}}<tr class="product"><td>{{product .Type}} &middot; {{.Provider}} &middot; {{.TermMonths}} months</td><td>{{money .Price}}</td></tr>
+{{end}}<tr><td>Amount financed</td><td>{{money .AmountFinanced}}</td></tr>
+{{if .TermMonths}}<tr><td>Payment</td><td>{{money .MonthlyPayment}}/month for {{.TermMonths}} months at {{.SellRate}}% APR</td></tr>{{end}}
+</table>
+<p>This quote is an estimate and is not an offer to sell or extend credit.</p>
+</body>
+</html>
+`))
+
+// getQuoteDocument handles GET /deals/quotes/{quote_id}/document, rendering
+// the quote sheet
+func (s *Server) getQuoteDocument(w http.ResponseWriter, r *http.Request) {
+	quote := s.loadQuote(w, r)
+	if quote == nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := quoteDocumentTemplate.Execute(&buf, quote); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to render quote")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to render quote: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(buf.Bytes())
+}
+
+// createDealFromQuote handles POST /deals/from-quote/{quote_id}, creating a
+// draft deal from an unexpired quote. Each quote makes at most one deal.
+func (s *Server) createDealFromQuote(w http.ResponseWriter, r *http.Request) {
+	var req CreateDealFromQuoteRequest
+	if r.ContentLength != 0 && !decodeAndValidate(r, w, &req) {
+		return
+	}
+
+	quote := s.loadQuote(w, r)
+	if quote == nil {
+		return
+	}
+	if quote.DealID != "" {
+		respondErrorJSON(w, http.StatusConflict, fmt.Sprintf("Quote was already turned into deal %s", quote.DealID), "QUOTE_USED")
+		return
+	}
+
+	now := time.Now()
+	deal := quote.deal()
+	deal.ID = uuid.New().String()
+	deal.CustomerID = req.CustomerID
+	deal.CoCustomerID = req.CoCustomerID
+	deal.CreatedAt = now
+	deal.UpdatedAt = now
+
+	// Claim the quote first so two requests can't both turn it into a deal
+	claimed, err := s.db.ClaimDealQuote(quote.ID, deal.ID, now)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to claim quote")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to claim quote: %v", err))
+		return
+	}
+	if !claimed {
+		respondErrorJSON(w, http.StatusConflict, "Quote was already used or has expired", "QUOTE_USED")
+		return
+	}
+
+	if !s.saveNewDeal(w, r, &deal) {
+		if err := s.db.ReleaseDealQuote(quote.ID); err != nil {
+			s.logger.WithContext(r.Context()).WithError(err).WithField("quote_id", quote.ID).Error("Failed to release quote")
+		}
+		return
+	}
+
+	s.logger.WithContext(r.Context()).WithField("quote_id", quote.ID).WithField("deal_id", deal.ID).Info("Deal created from quote")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(deal)
+}
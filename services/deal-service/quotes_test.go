@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func createTestQuote(t *testing.T, server *Server, req CreateQuoteRequest) DealQuote {
+	t.Helper()
+	rr := sendDealRequest(t, server, "POST", "/deals/quote", req, "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var quote DealQuote
+	json.Unmarshal(rr.Body.Bytes(), &quote)
+	return quote
+}
+
+func walkInQuote(dealershipID string) CreateQuoteRequest {
+	return CreateQuoteRequest{
+		DealershipID:  dealershipID,
+		VehiclePrice:  30000,
+		TradeInValue:  5000,
+		TradeInPayoff: 2000,
+		DownPayment:   3000,
+		TaxRate:       7,
+		Fees:          []QuoteFee{{Name: "Documentation", Amount: 499}, {Name: "Registration", Amount: 150.50}},
+		TermMonths:    60,
+		Products:      []DealProduct{{Type: ProductWarranty, Provider: "Acme", Cost: 800, Price: 1200, TermMonths: 48}},
+	}
+}
+
+func TestQuoteComputesTotals(t *testing.T) {
+	server := setupTestServer()
+	quote := createTestQuote(t, server, walkInQuote(uuid.New().String()))
+
+	// Tax is 7% of the price less the trade-in allowance
+	if quote.TaxAmount != 1750 {
+		t.Errorf("Expected tax of 1750, got %v", quote.TaxAmount)
+	}
+	if quote.FeesTotal != 649.50 || quote.ProductsTotal != 1200 {
+		t.Errorf("Expected fees of 649.50 and products of 1200, got %v and %v", quote.FeesTotal, quote.ProductsTotal)
+	}
+	if quote.TotalAmount != 32399.50 {
+		t.Errorf("Expected a total of 32399.50, got %v", quote.TotalAmount)
+	}
+	// 30000 - 5000 + 2000 - 3000 + 1750 tax + 1200 warranty
+	if quote.AmountFinanced != 26950 {
+		t.Errorf("Expected 26950 financed, got %v", quote.AmountFinanced)
+	}
+	if quote.MonthlyPayment != 449.17 {
+		t.Errorf("Expected a payment of 449.17 at 0%% over 60 months, got %v", quote.MonthlyPayment)
+	}
+	if quote.ID == "" || !quote.ExpiresAt.After(time.Now()) {
+		t.Errorf("Expected an unexpired quote ID, got %+v", quote)
+	}
+
+	// Nothing was saved as a deal
+	if deals, _ := server.db.ListDeals(DealListFilter{}); len(deals) != 0 {
+		t.Errorf("Expected no deals, got %d", len(deals))
+	}
+
+	rr := sendDealRequest(t, server, "GET", "/deals/quotes/"+quote.ID+"/document", nil, "")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "$32399.50") || !strings.Contains(rr.Body.String(), "Registration") {
+		t.Errorf("Expected the quote sheet with its total and fees, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestQuoteRejectsTaxRateAndAmount(t *testing.T) {
+	server := setupTestServer()
+	req := walkInQuote(uuid.New().String())
+	req.TaxAmount = 100
+
+	if rr := sendDealRequest(t, server, "POST", "/deals/quote", req, ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 with both a tax rate and amount, got %d", rr.Code)
+	}
+}
+
+func TestDealFromQuote(t *testing.T) {
+	server := setupTestServer()
+	quote := createTestQuote(t, server, walkInQuote(uuid.New().String()))
+
+	customerID := uuid.New().String()
+	rr := sendDealRequest(t, server, "POST", "/deals/from-quote/"+quote.ID, CreateDealFromQuoteRequest{CustomerID: customerID}, "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var deal Deal
+	json.Unmarshal(rr.Body.Bytes(), &deal)
+
+	if deal.CustomerID != customerID || deal.Status != "draft" || deal.DealershipID != quote.DealershipID {
+		t.Errorf("Expected a draft deal for the customer, got %+v", deal)
+	}
+	if deal.TaxAmount != 1750 || deal.TotalAmount != 32399.50 || len(deal.Products) != 1 {
+		t.Errorf("Expected the quote's amounts on the deal, got %+v", deal)
+	}
+	policy := DefaultRoundingPolicy
+	if deal.amountFinanced(policy) != quote.AmountFinanced || deal.monthlyPaymentAmount(policy) != quote.MonthlyPayment {
+		t.Errorf("Expected the deal to finance what was quoted")
+	}
+	if stored, _ := server.db.GetDeal(deal.ID); stored == nil {
+		t.Error("Expected the deal to be saved")
+	}
+
+	// A quote makes one deal
+	rr = sendDealRequest(t, server, "POST", "/deals/from-quote/"+quote.ID, nil, "")
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected 409 reusing a quote, got %d", rr.Code)
+	}
+}
+
+func TestExpiredQuoteCannotBeConverted(t *testing.T) {
+	server := setupTestServer()
+	server.config.QuoteTTL = time.Millisecond
+	quote := createTestQuote(t, server, walkInQuote(uuid.New().String()))
+	time.Sleep(5 * time.Millisecond)
+
+	rr := sendDealRequest(t, server, "POST", "/deals/from-quote/"+quote.ID, nil, "")
+	if rr.Code != http.StatusGone {
+		t.Errorf("Expected 410 for an expired quote, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if deals, _ := server.db.ListDeals(DealListFilter{}); len(deals) != 0 {
+		t.Errorf("Expected no deals, got %d", len(deals))
+	}
+
+	rr = sendDealRequest(t, server, "POST", "/deals/from-quote/"+uuid.New().String(), nil, "")
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown quote, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"autolytiq/shared/configclient"
+)
+
+// settingDefaultTermMonths is the config-service key for a dealership's
+// default financing term
+const settingDefaultTermMonths = "default_term_months"
+
+// DealershipSettings reads typed dealership settings from config-service
+type DealershipSettings interface {
+	GetString(ctx context.Context, dealershipID, key string) (string, error)
+	GetInt(ctx context.Context, dealershipID, key string) (int, error)
+	GetJSON(ctx context.Context, dealershipID, key string, v interface{}) error
+}
+
+// newSettingsClient creates a cached config-service client for deal settings
+func newSettingsClient(baseURL string) *configclient.Client {
+	cfg := configclient.DefaultConfig()
+	cfg.BaseURL = baseURL
+	cfg.CacheTTL = 5 * time.Minute
+	return configclient.NewClient(cfg)
+}
+
+// applyDealershipDefaults fills in fields the request left empty from the
+// dealership's settings. Lookup failures are logged and the field left unset.
+func (s *Server) applyDealershipDefaults(r *http.Request, deal *Deal) {
+	if s.settings == nil || deal.TermMonths != 0 {
+		return
+	}
+
+	term, err := s.settings.GetInt(r.Context(), deal.DealershipID, settingDefaultTermMonths)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).WithField("dealership_id", deal.DealershipID).Warn("Failed to load default term")
+		return
+	}
+	if term > 0 {
+		deal.TermMonths = term
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"autolytiq/shared/apierror"
+
+	"github.com/gorilla/mux"
+)
+
+// validTransitions lists the statuses a deal in each status may move to.
+// Deals advance draft → pending → approved → funded → delivered, can step
+// back before funding, and can be cancelled until funded. A cancelled deal
+// can be reopened; a delivered deal is final.
+var validTransitions = map[string][]string{
+	"draft":     {"pending", "cancelled"},
+	"pending":   {"draft", "approved", "cancelled"},
+	"approved":  {"pending", "funded", "cancelled"},
+	"funded":    {"delivered"},
+	"delivered": {},
+	"cancelled": {"draft", "pending"},
+}
+
+// canTransition reports whether a deal may move from one status to another.
+// Keeping the current status is always allowed.
+func canTransition(from, to string) bool {
+	if from == to {
+		return true
+	}
+	for _, next := range validTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// InvalidTransitionResponse is the 409 response for an illegal status change
+type InvalidTransitionResponse struct {
+	Error   string   `json:"error"`
+	Code    string   `json:"code"`
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	Allowed []string `json:"allowed"`
+}
+
+// respondInvalidTransition writes 409 for a status change the state machine
+// doesn't allow
+func respondInvalidTransition(w http.ResponseWriter, from, to string) {
+	allowed := validTransitions[from]
+	if allowed == nil {
+		allowed = []string{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(InvalidTransitionResponse{
+		Error:   fmt.Sprintf("A %s deal cannot be moved to %s", from, to),
+		Code:    "INVALID_STATUS_TRANSITION",
+		From:    from,
+		To:      to,
+		Allowed: allowed,
+	})
+}
+
+// AllowedTransitionsResponse is the response for a deal's allowed transitions
+type AllowedTransitionsResponse struct {
+	DealID  string   `json:"deal_id"`
+	Status  string   `json:"status"`
+	Allowed []string `json:"allowed"`
+}
+
+// getAllowedTransitions returns the statuses a deal can be moved to, so
+// clients can offer only valid status changes
+func (s *Server) getAllowedTransitions(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if !validateUUID(w, id, "id") {
+		return
+	}
+
+	deal, err := s.db.GetDeal(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get deal")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get deal: %v", err))
+		return
+	}
+	if deal == nil {
+		apierror.WriteError(w, http.StatusNotFound, "DEAL_NOT_FOUND", "Deal not found")
+		return
+	}
+
+	allowed := validTransitions[deal.Status]
+	if allowed == nil {
+		allowed = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AllowedTransitionsResponse{
+		DealID:  deal.ID,
+		Status:  deal.Status,
+		Allowed: allowed,
+	})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// moveDeal walks a deal through the given statuses, failing the test if
+// any step is refused
+func moveDeal(t *testing.T, server *Server, id string, statuses ...string) {
+	t.Helper()
+	for _, status := range statuses {
+		if rr := sendDealRequest(t, server, "PUT", "/deals/"+id, UpdateDealRequest{Status: status}, ""); rr.Code != http.StatusOK {
+			t.Fatalf("Expected the deal to move to %s, got %d: %s", status, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{"draft", "draft", true},
+		{"draft", "pending", true},
+		{"draft", "approved", false},
+		{"draft", "funded", false},
+		{"draft", "cancelled", true},
+		{"pending", "draft", true},
+		{"pending", "approved", true},
+		{"pending", "funded", false},
+		{"pending", "cancelled", true},
+		{"approved", "pending", true},
+		{"approved", "funded", true},
+		{"approved", "draft", false},
+		{"approved", "cancelled", true},
+		{"funded", "delivered", true},
+		{"funded", "draft", false},
+		{"funded", "approved", false},
+		{"funded", "cancelled", false},
+		{"delivered", "funded", false},
+		{"delivered", "cancelled", false},
+		{"cancelled", "draft", true},
+		{"cancelled", "pending", true},
+		{"cancelled", "funded", false},
+	}
+
+	for _, tt := range tests {
+		if got := canTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("canTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestValidTransitionsCoverEveryStatus(t *testing.T) {
+	for status := range validStatuses {
+		if _, ok := validTransitions[status]; !ok {
+			t.Errorf("Expected transitions for status %s", status)
+		}
+	}
+	for from, targets := range validTransitions {
+		for _, to := range targets {
+			if !validStatuses[to] {
+				t.Errorf("Transition %s -> %s targets an unknown status", from, to)
+			}
+		}
+	}
+}
+
+func TestUpdateDealRejectsIllegalTransition(t *testing.T) {
+	server := setupTestServer()
+	deal := &Deal{ID: uuid.New().String(), DealershipID: uuid.New().String(), VehiclePrice: 25000, Status: "draft"}
+	server.db.CreateDeal(deal)
+	moveDeal(t, server, deal.ID, "pending", "approved", "funded")
+
+	rr := sendDealRequest(t, server, "PUT", "/deals/"+deal.ID, UpdateDealRequest{Status: "draft"}, "")
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("Expected 409 moving a funded deal to draft, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response InvalidTransitionResponse
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if response.Code != "INVALID_STATUS_TRANSITION" || response.From != "funded" || response.To != "draft" ||
+		len(response.Allowed) != 1 || response.Allowed[0] != "delivered" {
+		t.Errorf("Expected the attempted transition and the allowed ones, got %+v", response)
+	}
+	if stored, _ := server.db.GetDeal(deal.ID); stored.Status != "funded" {
+		t.Errorf("Expected the deal to stay funded, got %s", stored.Status)
+	}
+
+	// Updates that keep the status are not transitions
+	if rr := sendDealRequest(t, server, "PUT", "/deals/"+deal.ID, UpdateDealRequest{Status: "funded", DownPayment: 1000}, ""); rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 keeping the status, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestGetAllowedTransitions(t *testing.T) {
+	server := setupTestServer()
+	deal := &Deal{ID: uuid.New().String(), DealershipID: uuid.New().String(), VehiclePrice: 25000, Status: "pending"}
+	server.db.CreateDeal(deal)
+
+	rr := sendDealRequest(t, server, "GET", "/deals/"+deal.ID+"/allowed-transitions", nil, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response AllowedTransitionsResponse
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	sort.Strings(response.Allowed)
+	if response.Status != "pending" || len(response.Allowed) != 3 ||
+		response.Allowed[0] != "approved" || response.Allowed[1] != "cancelled" || response.Allowed[2] != "draft" {
+		t.Errorf("Expected pending's transitions, got %+v", response)
+	}
+
+	// A delivered deal has none, reported as an empty list
+	server.db.(*MockDatabase).deals[deal.ID].Status = "delivered"
+	rr = sendDealRequest(t, server, "GET", "/deals/"+deal.ID+"/allowed-transitions", nil, "")
+	if body := rr.Body.String(); rr.Code != http.StatusOK || !strings.Contains(body, `"allowed":[]`) {
+		t.Errorf("Expected an empty allowed list, got %d: %s", rr.Code, body)
+	}
+
+	if rr := sendDealRequest(t, server, "GET", "/deals/"+uuid.New().String()+"/allowed-transitions", nil, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing deal, got %d", rr.Code)
+	}
+}
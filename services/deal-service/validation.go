@@ -2,9 +2,13 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"regexp"
 	"strings"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/bodylimit"
 )
 
 // ValidationError represents a single validation error
@@ -18,17 +22,11 @@ type ValidationErrors struct {
 	Errors []ValidationError `json:"errors"`
 }
 
-// ValidationErrorResponse is the standard error response format
-type ValidationErrorResponse struct {
-	Error   string            `json:"error"`
-	Code    string            `json:"code"`
-	Details []ValidationError `json:"details,omitempty"`
-}
-
 // CreateDealRequest represents a request to create a deal
 type CreateDealRequest struct {
 	DealershipID  string  `json:"dealership_id"`
 	CustomerID    string  `json:"customer_id"`
+	CoCustomerID  string  `json:"co_customer_id"`
 	VehicleID     string  `json:"vehicle_id"`
 	SalespersonID string  `json:"salesperson_id"`
 	VehiclePrice  float64 `json:"vehicle_price"`
@@ -36,12 +34,18 @@ type CreateDealRequest struct {
 	TradeInPayoff float64 `json:"trade_in_payoff"`
 	DownPayment   float64 `json:"down_payment"`
 	TaxAmount     float64 `json:"tax_amount"`
+	TermMonths    int     `json:"term_months"`
+	BuyRate       float64 `json:"buy_rate"`
+	SellRate      float64 `json:"sell_rate"`
 	Status        string  `json:"status"`
+
+	Products []DealProduct `json:"products"`
 }
 
 // UpdateDealRequest represents a request to update a deal
 type UpdateDealRequest struct {
 	CustomerID    string  `json:"customer_id,omitempty"`
+	CoCustomerID  string  `json:"co_customer_id,omitempty"`
 	VehicleID     string  `json:"vehicle_id,omitempty"`
 	SalespersonID string  `json:"salesperson_id,omitempty"`
 	VehiclePrice  float64 `json:"vehicle_price,omitempty"`
@@ -49,11 +53,24 @@ type UpdateDealRequest struct {
 	TradeInPayoff float64 `json:"trade_in_payoff,omitempty"`
 	DownPayment   float64 `json:"down_payment,omitempty"`
 	TaxAmount     float64 `json:"tax_amount,omitempty"`
+	TermMonths    int     `json:"term_months,omitempty"`
+	BuyRate       float64 `json:"buy_rate,omitempty"`
+	SellRate      float64 `json:"sell_rate,omitempty"`
 	Status        string  `json:"status,omitempty"`
+
+	// LostReason is required when cancelling the deal
+	LostReason string `json:"lost_reason,omitempty"`
+
+	// RemoveCoCustomer removes the deal's co-buyer
+	RemoveCoCustomer bool `json:"remove_co_customer,omitempty"`
+
+	// Products replaces the deal's products when present; an empty list
+	// removes them all
+	Products *[]DealProduct `json:"products,omitempty"`
 }
 
 var (
-	uuidRegex    = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	uuidRegex     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
 	validStatuses = map[string]bool{
 		"draft":     true,
 		"pending":   true,
@@ -89,6 +106,14 @@ func (r *CreateDealRequest) Validate() *ValidationErrors {
 		})
 	}
 
+	// Co-buyer ID validation (optional but if provided, must be valid)
+	if r.CoCustomerID != "" && !uuidRegex.MatchString(r.CoCustomerID) {
+		errors = append(errors, ValidationError{
+			Field:   "co_customer_id",
+			Message: "Must be a valid UUID",
+		})
+	}
+
 	// Vehicle ID validation (optional but if provided, must be valid)
 	if r.VehicleID != "" && !uuidRegex.MatchString(r.VehicleID) {
 		errors = append(errors, ValidationError{
@@ -137,6 +162,9 @@ func (r *CreateDealRequest) Validate() *ValidationErrors {
 		})
 	}
 
+	errors = append(errors, validateFinancingTerms(r.TermMonths, r.BuyRate, r.SellRate)...)
+	errors = append(errors, validateProducts(r.Products)...)
+
 	// Status validation
 	if r.Status != "" && !validStatuses[strings.ToLower(r.Status)] {
 		errors = append(errors, ValidationError{
@@ -155,9 +183,11 @@ func (r *CreateDealRequest) Validate() *ValidationErrors {
 func (r *CreateDealRequest) Sanitize() {
 	r.DealershipID = strings.TrimSpace(r.DealershipID)
 	r.CustomerID = strings.TrimSpace(r.CustomerID)
+	r.CoCustomerID = strings.TrimSpace(r.CoCustomerID)
 	r.VehicleID = strings.TrimSpace(r.VehicleID)
 	r.SalespersonID = strings.TrimSpace(r.SalespersonID)
 	r.Status = strings.TrimSpace(strings.ToLower(r.Status))
+	sanitizeProducts(r.Products)
 }
 
 // Validate validates UpdateDealRequest
@@ -172,6 +202,14 @@ func (r *UpdateDealRequest) Validate() *ValidationErrors {
 		})
 	}
 
+	// Co-buyer ID validation (optional but if provided, must be valid)
+	if r.CoCustomerID != "" && !uuidRegex.MatchString(r.CoCustomerID) {
+		errors = append(errors, ValidationError{
+			Field:   "co_customer_id",
+			Message: "Must be a valid UUID",
+		})
+	}
+
 	// Vehicle ID validation (optional but if provided, must be valid)
 	if r.VehicleID != "" && !uuidRegex.MatchString(r.VehicleID) {
 		errors = append(errors, ValidationError{
@@ -220,6 +258,11 @@ func (r *UpdateDealRequest) Validate() *ValidationErrors {
 		})
 	}
 
+	errors = append(errors, validateFinancingTerms(r.TermMonths, r.BuyRate, r.SellRate)...)
+	if r.Products != nil {
+		errors = append(errors, validateProducts(*r.Products)...)
+	}
+
 	// Status validation
 	if r.Status != "" && !validStatuses[strings.ToLower(r.Status)] {
 		errors = append(errors, ValidationError{
@@ -237,30 +280,137 @@ func (r *UpdateDealRequest) Validate() *ValidationErrors {
 // Sanitize sanitizes UpdateDealRequest
 func (r *UpdateDealRequest) Sanitize() {
 	r.CustomerID = strings.TrimSpace(r.CustomerID)
+	r.CoCustomerID = strings.TrimSpace(r.CoCustomerID)
 	r.VehicleID = strings.TrimSpace(r.VehicleID)
 	r.SalespersonID = strings.TrimSpace(r.SalespersonID)
 	r.Status = strings.TrimSpace(strings.ToLower(r.Status))
+	r.LostReason = strings.TrimSpace(strings.ToLower(r.LostReason))
+	if r.Products != nil {
+		sanitizeProducts(*r.Products)
+	}
+}
+
+// Validate validates PurgeCustomerDocumentsRequest
+func (r *PurgeCustomerDocumentsRequest) Validate() *ValidationErrors {
+	var errors []ValidationError
+
+	if !uuidRegex.MatchString(r.CustomerID) {
+		errors = append(errors, ValidationError{
+			Field:   "customer_id",
+			Message: "Must be a valid UUID",
+		})
+	}
+	if !uuidRegex.MatchString(r.DealershipID) {
+		errors = append(errors, ValidationError{
+			Field:   "dealership_id",
+			Message: "Must be a valid UUID",
+		})
+	}
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Validate validates CreateDealNoteRequest
+func (r *CreateDealNoteRequest) Validate() *ValidationErrors {
+	var errors []ValidationError
+
+	if r.Body == "" {
+		errors = append(errors, ValidationError{
+			Field:   "body",
+			Message: "Note body is required",
+		})
+	} else if len(r.Body) > maxDealNoteLength {
+		errors = append(errors, ValidationError{
+			Field:   "body",
+			Message: fmt.Sprintf("Must be at most %d characters", maxDealNoteLength),
+		})
+	}
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize sanitizes CreateDealNoteRequest
+func (r *CreateDealNoteRequest) Sanitize() {
+	r.Body = strings.TrimSpace(r.Body)
+}
+
+// validateFinancingTerms validates term and rate fields shared by create and update requests
+func validateFinancingTerms(termMonths int, buyRate, sellRate float64) []ValidationError {
+	var errors []ValidationError
+
+	if termMonths < 0 || termMonths > 120 {
+		errors = append(errors, ValidationError{
+			Field:   "term_months",
+			Message: "Term must be between 0 and 120 months",
+		})
+	}
+
+	if buyRate < 0 || buyRate > 100 {
+		errors = append(errors, ValidationError{
+			Field:   "buy_rate",
+			Message: "Buy rate must be between 0 and 100",
+		})
+	}
+
+	if sellRate < 0 || sellRate > 100 {
+		errors = append(errors, ValidationError{
+			Field:   "sell_rate",
+			Message: "Sell rate must be between 0 and 100",
+		})
+	}
+
+	return errors
+}
+
+// validateDealTotal recalculates the deal's total and rejects a negative one
+func validateDealTotal(deal *Deal) *ValidationErrors {
+	if total := deal.CalculateTotal(); total < 0 {
+		return &ValidationErrors{Errors: []ValidationError{{
+			Field:   "total_amount",
+			Message: fmt.Sprintf("Deal total cannot be negative: the trade-in value and down payment exceed the vehicle price, trade-in payoff and tax by %.2f", -total),
+		}}}
+	}
+	return nil
+}
+
+// validateRateMarkup enforces sell_rate >= buy_rate and the configured markup cap.
+// Rates are only checked once both are set on the deal.
+func validateRateMarkup(buyRate, sellRate, maxMarkup float64) *ValidationErrors {
+	if buyRate == 0 || sellRate == 0 {
+		return nil
+	}
+
+	if sellRate < buyRate {
+		return &ValidationErrors{Errors: []ValidationError{{
+			Field:   "sell_rate",
+			Message: "Sell rate cannot be lower than buy rate",
+		}}}
+	}
+
+	if maxMarkup > 0 && roundRate(sellRate-buyRate) > maxMarkup {
+		return &ValidationErrors{Errors: []ValidationError{{
+			Field:   "sell_rate",
+			Message: fmt.Sprintf("Rate markup of %.2f points exceeds the maximum of %.2f points", sellRate-buyRate, maxMarkup),
+		}}}
+	}
+
+	return nil
 }
 
 // respondValidationError writes a validation error response
 func respondValidationError(w http.ResponseWriter, errors *ValidationErrors) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusBadRequest)
-	json.NewEncoder(w).Encode(ValidationErrorResponse{
-		Error:   "Validation failed",
-		Code:    "VALIDATION_ERROR",
-		Details: errors.Errors,
-	})
+	apierror.WriteErrorDetails(w, http.StatusBadRequest, apierror.CodeValidation, "Validation failed", errors.Errors)
 }
 
 // respondErrorJSON writes a JSON error response
 func respondErrorJSON(w http.ResponseWriter, status int, message, code string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(ValidationErrorResponse{
-		Error: message,
-		Code:  code,
-	})
+	apierror.WriteError(w, status, code, message)
 }
 
 // Validatable interface for types that can validate themselves
@@ -276,6 +426,10 @@ type Sanitizable interface {
 // decodeAndValidate decodes JSON and validates the request
 func decodeAndValidate(r *http.Request, w http.ResponseWriter, dst interface{}) bool {
 	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		if bodylimit.IsTooLarge(err) {
+			bodylimit.RespondTooLarge(w)
+			return false
+		}
 		respondErrorJSON(w, http.StatusBadRequest, "Invalid request body: "+err.Error(), "INVALID_JSON")
 		return false
 	}
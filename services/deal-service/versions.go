@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/logging"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Deal version change types
+const (
+	VersionChangeCreate  = "create"
+	VersionChangeUpdate  = "update"
+	VersionChangeApprove = "approve"
+	VersionChangeRevert  = "revert"
+)
+
+// DealVersion is a snapshot of a deal's full state after a change. Versions
+// are numbered from 1 per deal and never modified; a revert restores a
+// snapshot by recording it as a new version.
+type DealVersion struct {
+	ID           string    `json:"id"`
+	DealID       string    `json:"deal_id"`
+	Version      int       `json:"version"`
+	ChangeType   string    `json:"change_type"`
+	RevertedFrom int       `json:"reverted_from,omitempty"`
+	Snapshot     Deal      `json:"snapshot"`
+	CreatedBy    string    `json:"created_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// recordVersion snapshots the deal's current state. Version failures are
+// logged but do not fail the change.
+func (s *Server) recordVersion(r *http.Request, deal *Deal, changeType string, revertedFrom int) {
+	version := &DealVersion{
+		ID:           uuid.New().String(),
+		DealID:       deal.ID,
+		ChangeType:   changeType,
+		RevertedFrom: revertedFrom,
+		Snapshot:     *deal,
+		CreatedBy:    logging.GetUserID(r.Context()),
+		CreatedAt:    deal.UpdatedAt,
+	}
+	if err := s.db.CreateDealVersion(version); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).WithField("deal_id", deal.ID).Warn("Failed to record deal version")
+	}
+}
+
+// listDealVersions returns a deal's version snapshots, oldest first
+func (s *Server) listDealVersions(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if !validateUUID(w, id, "id") {
+		return
+	}
+
+	versions, err := s.db.ListDealVersions(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list deal versions")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to list deal versions: %v", err))
+		return
+	}
+
+	if versions == nil {
+		versions = []*DealVersion{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versions)
+}
+
+// revertDeal restores a deal to a prior version's snapshot. The restored
+// state is recorded as a new version, so history is kept. Funded deals cannot
+// be reverted, and a deal cannot be reverted into a funded state.
+func (s *Server) revertDeal(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if !validateUUID(w, id, "id") {
+		return
+	}
+
+	versionNumber, err := strconv.Atoi(vars["version"])
+	if err != nil || versionNumber < 1 {
+		apierror.WriteError(w, http.StatusBadRequest, "INVALID_VERSION", "Invalid version")
+		return
+	}
+
+	if !hasScope(r, scopeDealsManage) {
+		respondErrorJSON(w, http.StatusForbidden, "Reverting deals requires the "+scopeDealsManage+" scope", "INSUFFICIENT_SCOPE")
+		return
+	}
+
+	deal, err := s.db.GetDeal(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get deal")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get deal: %v", err))
+		return
+	}
+	if deal == nil {
+		apierror.WriteError(w, http.StatusNotFound, "DEAL_NOT_FOUND", "Deal not found")
+		return
+	}
+
+	if deal.Status == "funded" {
+		respondErrorJSON(w, http.StatusConflict, "Funded deals cannot be reverted", "DEAL_FUNDED")
+		return
+	}
+
+	version, err := s.db.GetDealVersion(id, versionNumber)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get deal version")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get deal version: %v", err))
+		return
+	}
+	if version == nil {
+		apierror.WriteError(w, http.StatusNotFound, "DEAL_VERSION_NOT_FOUND", "Deal version not found")
+		return
+	}
+
+	if version.Snapshot.Status == "funded" {
+		respondErrorJSON(w, http.StatusConflict, "Deals cannot be reverted to a funded version", "VERSION_FUNDED")
+		return
+	}
+
+	previous := *deal
+	restored := version.Snapshot
+	restored.ID = deal.ID
+	restored.CreatedAt = deal.CreatedAt
+	restored.UpdatedAt = time.Now()
+	restored.Version = deal.Version
+
+	if err := s.db.UpdateDeal(&restored); err != nil {
+		if err == ErrVersionConflict {
+			respondConcurrentUpdate(w)
+			return
+		}
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to revert deal")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to revert deal: %v", err))
+		return
+	}
+
+	s.recordRateChanges(r, &previous, &restored)
+	s.recordVersion(r, &restored, VersionChangeRevert, versionNumber)
+	s.auditDeal(r, AuditActionUpdate, &previous, &restored)
+
+	s.logger.WithContext(r.Context()).WithField("deal_id", id).WithField("version", versionNumber).Info("Deal reverted")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(restored)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func createVersionedDeal(t *testing.T, server *Server) Deal {
+	t.Helper()
+	rr := sendDealRequest(t, server, "POST", "/deals", CreateDealRequest{
+		DealershipID: uuid.New().String(),
+		CustomerID:   uuid.New().String(),
+		VehicleID:    uuid.New().String(),
+		VehiclePrice: 30000,
+		DownPayment:  2000,
+	}, "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var deal Deal
+	json.Unmarshal(rr.Body.Bytes(), &deal)
+	return deal
+}
+
+func listVersions(t *testing.T, server *Server, dealID string) []DealVersion {
+	t.Helper()
+	rr := sendDealRequest(t, server, "GET", "/deals/"+dealID+"/versions", nil, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var versions []DealVersion
+	json.Unmarshal(rr.Body.Bytes(), &versions)
+	return versions
+}
+
+func TestUpdateDealRecordsVersion(t *testing.T) {
+	server := setupTestServer()
+	deal := createVersionedDeal(t, server)
+
+	sendDealRequest(t, server, "PUT", "/deals/"+deal.ID, UpdateDealRequest{VehiclePrice: 28500}, "")
+
+	versions := listVersions(t, server, deal.ID)
+	if len(versions) != 2 {
+		t.Fatalf("Expected 2 versions, got %d", len(versions))
+	}
+	if versions[0].Version != 1 || versions[0].ChangeType != VersionChangeCreate || versions[0].Snapshot.VehiclePrice != 30000 {
+		t.Errorf("Expected version 1 to snapshot the created deal, got %+v", versions[0])
+	}
+	if versions[1].Version != 2 || versions[1].ChangeType != VersionChangeUpdate || versions[1].Snapshot.VehiclePrice != 28500 {
+		t.Errorf("Expected version 2 to snapshot the update, got %+v", versions[1])
+	}
+	if versions[1].CreatedBy != "manager-1" {
+		t.Errorf("Expected version to record its author, got %q", versions[1].CreatedBy)
+	}
+}
+
+func TestRevertDeal(t *testing.T) {
+	server := setupTestServer()
+	deal := createVersionedDeal(t, server)
+	sendDealRequest(t, server, "PUT", "/deals/"+deal.ID, UpdateDealRequest{VehiclePrice: 28500, DownPayment: 5000}, "")
+
+	rr := sendDealRequest(t, server, "POST", "/deals/"+deal.ID+"/revert/1", nil, "")
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without the manage scope, got %d", rr.Code)
+	}
+
+	rr = sendDealRequest(t, server, "POST", "/deals/"+deal.ID+"/revert/1", nil, scopeDealsManage)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var reverted Deal
+	json.Unmarshal(rr.Body.Bytes(), &reverted)
+	if reverted.VehiclePrice != 30000 || reverted.DownPayment != 2000 {
+		t.Errorf("Expected version 1's numbers to be restored, got %+v", reverted)
+	}
+
+	stored, _ := server.db.GetDeal(deal.ID)
+	if stored.VehiclePrice != 30000 {
+		t.Errorf("Expected the revert to be saved, got %v", stored.VehiclePrice)
+	}
+
+	// The revert is a new version; earlier versions are kept
+	versions := listVersions(t, server, deal.ID)
+	if len(versions) != 3 {
+		t.Fatalf("Expected 3 versions, got %d", len(versions))
+	}
+	if versions[2].ChangeType != VersionChangeRevert || versions[2].RevertedFrom != 1 {
+		t.Errorf("Expected version 3 to record the revert from version 1, got %+v", versions[2])
+	}
+	if versions[1].Snapshot.VehiclePrice != 28500 {
+		t.Errorf("Expected version 2 to be unchanged, got %v", versions[1].Snapshot.VehiclePrice)
+	}
+
+	rr = sendDealRequest(t, server, "POST", "/deals/"+deal.ID+"/revert/9", nil, scopeDealsManage)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing version, got %d", rr.Code)
+	}
+}
+
+func TestRevertFundedDealRejected(t *testing.T) {
+	server := setupTestServer()
+	deal := createVersionedDeal(t, server)
+	moveDeal(t, server, deal.ID, "pending", "approved", "funded")
+
+	rr := sendDealRequest(t, server, "POST", "/deals/"+deal.ID+"/revert/1", nil, scopeDealsManage)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 reverting a funded deal, got %d", rr.Code)
+	}
+
+	stored, _ := server.db.GetDeal(deal.ID)
+	if stored.Status != "funded" {
+		t.Errorf("Expected the funded deal to be unchanged, got status %s", stored.Status)
+	}
+}
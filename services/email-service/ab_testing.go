@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// abMinSendsForWinner is the number of sends each variant needs before a
+// winner is reported
+const abMinSendsForWinner = 100
+
+// Email log events recorded by tracking and provider webhooks
+const (
+	LogEventOpen      = "open"
+	LogEventClick     = "click"
+	LogEventBounce    = "bounce"
+	LogEventComplaint = "complaint"
+)
+
+// BounceTypeHard marks a permanent bounce, which suppresses the recipient
+const BounceTypeHard = "hard"
+
+// trackingPixel is a transparent 1x1 GIF served for open tracking
+var trackingPixel, _ = base64.StdEncoding.DecodeString("R0lGODlhAQABAIAAAAAAAP///yH5BAEAAAAALAAAAAABAAEAAAIBRAA7")
+
+// TemplateVariant is an A/B variant of a template's subject and body
+type TemplateVariant struct {
+	ID       string `json:"id"`
+	Subject  string `json:"subject"`
+	BodyHTML string `json:"body_html"`
+	Weight   int    `json:"weight"`
+}
+
+// VariantStats holds send and engagement counts for a template variant
+type VariantStats struct {
+	Variant string `json:"variant"`
+	Sent    int    `json:"sent"`
+	Opened  int    `json:"opened"`
+	Clicked int    `json:"clicked"`
+}
+
+// VariantResult is a variant's engagement rates in an A/B report
+type VariantResult struct {
+	VariantStats
+	OpenRate  float64 `json:"open_rate"`
+	ClickRate float64 `json:"click_rate"`
+}
+
+// ABResults is the A/B test report for a template
+type ABResults struct {
+	TemplateID string          `json:"template_id"`
+	Variants   []VariantResult `json:"variants"`
+	Winner     *string         `json:"winner"`
+}
+
+// LogEventRequest records an engagement event against an email log
+type LogEventRequest struct {
+	Event string `json:"event"`
+
+	// BounceType is "hard" or "soft" for bounces. Hard bounces, like
+	// complaints, add the recipient to the suppression list.
+	BounceType string `json:"bounce_type,omitempty"`
+}
+
+// selectVariant picks a variant for a recipient by weight. The choice is a
+// hash of the template and recipient, so resends to the same recipient get
+// the same variant. Returns nil if no variant has a positive weight.
+func selectVariant(templateID, recipient string, variants []TemplateVariant) *TemplateVariant {
+	total := 0
+	for _, v := range variants {
+		if v.Weight > 0 {
+			total += v.Weight
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(templateID + ":" + strings.ToLower(strings.TrimSpace(recipient))))
+	bucket := int(h.Sum64() % uint64(total))
+
+	for i := range variants {
+		if variants[i].Weight <= 0 {
+			continue
+		}
+		if bucket < variants[i].Weight {
+			return &variants[i]
+		}
+		bucket -= variants[i].Weight
+	}
+	return nil
+}
+
+// validateVariants checks template variants, returning errors for the
+// "variants" field
+func validateVariants(variants []TemplateVariant) []ValidationError {
+	var errors []ValidationError
+	if len(variants) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	total := 0
+	for i, v := range variants {
+		field := fmt.Sprintf("variants[%d]", i)
+		if v.ID == "" {
+			errors = append(errors, ValidationError{Field: field + ".id", Message: "Variant ID is required"})
+		} else if len(v.ID) > 50 {
+			errors = append(errors, ValidationError{Field: field + ".id", Message: "Variant ID must be 50 characters or less"})
+		} else if seen[v.ID] {
+			errors = append(errors, ValidationError{Field: field + ".id", Message: "Variant IDs must be unique"})
+		}
+		seen[v.ID] = true
+
+		if v.Subject == "" {
+			errors = append(errors, ValidationError{Field: field + ".subject", Message: "Subject is required"})
+		} else if len(v.Subject) > 500 {
+			errors = append(errors, ValidationError{Field: field + ".subject", Message: "Subject must be 500 characters or less"})
+		}
+		if v.BodyHTML == "" {
+			errors = append(errors, ValidationError{Field: field + ".body_html", Message: "Body is required"})
+		} else if len(v.BodyHTML) > 100000 {
+			errors = append(errors, ValidationError{Field: field + ".body_html", Message: "Body must be 100000 characters or less"})
+		}
+		if v.Weight < 0 {
+			errors = append(errors, ValidationError{Field: field + ".weight", Message: "Weight cannot be negative"})
+		}
+		total += v.Weight
+	}
+
+	if total <= 0 {
+		errors = append(errors, ValidationError{Field: "variants", Message: "At least one variant must have a positive weight"})
+	}
+	if len(variants) > 10 {
+		errors = append(errors, ValidationError{Field: "variants", Message: "A template can have at most 10 variants"})
+	}
+
+	return errors
+}
+
+// buildABResults computes rates and picks a winner by click rate, then open
+// rate. No winner is reported until every variant has enough sends.
+func buildABResults(templateID string, variants []TemplateVariant, stats []VariantStats) *ABResults {
+	byVariant := make(map[string]VariantStats, len(stats))
+	for _, s := range stats {
+		byVariant[s.Variant] = s
+	}
+
+	results := &ABResults{TemplateID: templateID, Variants: []VariantResult{}}
+	enoughData := len(variants) > 1
+	var best *VariantResult
+
+	for _, v := range variants {
+		s := byVariant[v.ID]
+		s.Variant = v.ID
+
+		result := VariantResult{VariantStats: s}
+		if s.Sent > 0 {
+			result.OpenRate = float64(s.Opened) / float64(s.Sent)
+			result.ClickRate = float64(s.Clicked) / float64(s.Sent)
+		}
+		if s.Sent < abMinSendsForWinner {
+			enoughData = false
+		}
+		results.Variants = append(results.Variants, result)
+	}
+
+	if !enoughData {
+		return results
+	}
+
+	for i := range results.Variants {
+		r := &results.Variants[i]
+		if best == nil || r.ClickRate > best.ClickRate ||
+			(r.ClickRate == best.ClickRate && r.OpenRate > best.OpenRate) {
+			best = r
+		}
+	}
+
+	// A tie on both rates has no winner
+	for i := range results.Variants {
+		r := &results.Variants[i]
+		if r != best && r.ClickRate == best.ClickRate && r.OpenRate == best.OpenRate {
+			return results
+		}
+	}
+
+	winner := best.Variant
+	results.Winner = &winner
+	return results
+}
+
+// withTrackingPixel appends an open-tracking pixel for the log to the body
+func (s *Server) withTrackingPixel(bodyHTML, logID string) string {
+	if s.config.TrackingBaseURL == "" {
+		return bodyHTML
+	}
+	pixel := fmt.Sprintf(`<img src="%s/email/track/%s/open.gif" width="1" height="1" alt="" style="display:none">`,
+		strings.TrimRight(s.config.TrackingBaseURL, "/"), logID)
+	return bodyHTML + pixel
+}
+
+// GetABResultsHandler handles GET /email/templates/{id}/ab-results
+func (s *Server) GetABResultsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	templateID := vars["id"]
+
+	// Validate UUID
+	if !validateUUID(w, templateID, "id") {
+		return
+	}
+
+	dealershipID := r.URL.Query().Get("dealership_id")
+	if dealershipID == "" {
+		respondValidationError(w, &ValidationErrors{
+			Errors: []ValidationError{{Field: "dealership_id", Message: "dealership_id is required"}},
+		})
+		return
+	}
+	if !validateUUID(w, dealershipID, "dealership_id") {
+		return
+	}
+
+	template, err := s.db.GetTemplate(templateID, dealershipID)
+	if err != nil {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	stats, err := s.db.GetVariantStats(templateID, dealershipID)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get variant stats")
+		http.Error(w, "Failed to get A/B results", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buildABResults(templateID, template.Variants, stats))
+}
+
+// TrackOpenHandler handles the open-tracking pixel. It always serves the
+// pixel so tracking failures are invisible to the recipient.
+func (s *Server) TrackOpenHandler(w http.ResponseWriter, r *http.Request) {
+	logID := mux.Vars(r)["id"]
+
+	if uuidRegex.MatchString(logID) {
+		if err := s.db.RecordLogEvent(logID, LogEventOpen, time.Now()); err != nil {
+			s.logger.WithContext(r.Context()).WithError(err).WithField("log_id", logID).Debug("Failed to record open")
+		}
+	}
+
+	w.Header().Set("Content-Type", "image/gif")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Write(trackingPixel)
+}
+
+// RecordLogEventHandler handles POST /email/logs/{id}/events, used by link
+// redirectors and provider webhooks to report opens, clicks, bounces and
+// complaints
+func (s *Server) RecordLogEventHandler(w http.ResponseWriter, r *http.Request) {
+	logID := mux.Vars(r)["id"]
+
+	// Validate UUID
+	if !validateUUID(w, logID, "id") {
+		return
+	}
+
+	var req LogEventRequest
+	if !decodeAndValidate(r, w, &req) {
+		return
+	}
+
+	now := time.Now()
+	if req.Event != LogEventComplaint {
+		if err := s.db.RecordLogEvent(logID, req.Event, now); err != nil {
+			http.Error(w, "Log not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	// Recipients who complain or hard bounce are not emailed again
+	reason := ""
+	if req.Event == LogEventComplaint {
+		reason = SuppressionReasonComplaint
+	} else if req.Event == LogEventBounce && req.BounceType == BounceTypeHard {
+		reason = SuppressionReasonHardBounce
+	}
+	if reason != "" {
+		if err := s.db.SuppressLogRecipient(logID, reason, now); err != nil {
+			s.logger.WithContext(r.Context()).WithError(err).WithField("log_id", logID).Error("Failed to suppress recipient")
+			http.Error(w, "Log not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
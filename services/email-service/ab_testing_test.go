@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func abTestVariants() []TemplateVariant {
+	return []TemplateVariant{
+		{ID: "a", Subject: "Subject A", BodyHTML: "<p>A {{name}}</p>", Weight: 70},
+		{ID: "b", Subject: "Subject B", BodyHTML: "<p>B {{name}}</p>", Weight: 30},
+	}
+}
+
+func TestSelectVariantStable(t *testing.T) {
+	templateID := "22222222-2222-2222-2222-222222222222"
+	variants := abTestVariants()
+
+	for i := 0; i < 50; i++ {
+		recipient := fmt.Sprintf("customer%d@example.com", i)
+		first := selectVariant(templateID, recipient, variants)
+		if first == nil {
+			t.Fatalf("expected a variant for %s", recipient)
+		}
+
+		for j := 0; j < 5; j++ {
+			again := selectVariant(templateID, recipient, variants)
+			if again.ID != first.ID {
+				t.Fatalf("variant for %s changed from %s to %s", recipient, first.ID, again.ID)
+			}
+		}
+
+		// Recipient case and surrounding whitespace must not affect the choice
+		normalized := selectVariant(templateID, fmt.Sprintf("  CUSTOMER%d@Example.com ", i), variants)
+		if normalized.ID != first.ID {
+			t.Errorf("variant for %s differs by case: %s vs %s", recipient, first.ID, normalized.ID)
+		}
+	}
+}
+
+func TestSelectVariantWeights(t *testing.T) {
+	templateID := "22222222-2222-2222-2222-222222222222"
+	variants := abTestVariants()
+
+	counts := make(map[string]int)
+	total := 10000
+	for i := 0; i < total; i++ {
+		v := selectVariant(templateID, fmt.Sprintf("recipient%d@example.com", i), variants)
+		counts[v.ID]++
+	}
+
+	share := float64(counts["a"]) / float64(total)
+	if share < 0.65 || share > 0.75 {
+		t.Errorf("expected variant a on ~70%% of sends, got %.1f%%", share*100)
+	}
+}
+
+func TestSelectVariantZeroWeight(t *testing.T) {
+	templateID := "22222222-2222-2222-2222-222222222222"
+	variants := []TemplateVariant{
+		{ID: "a", Subject: "A", BodyHTML: "A", Weight: 1},
+		{ID: "off", Subject: "Off", BodyHTML: "Off", Weight: 0},
+	}
+
+	for i := 0; i < 500; i++ {
+		v := selectVariant(templateID, fmt.Sprintf("r%d@example.com", i), variants)
+		if v == nil || v.ID != "a" {
+			t.Fatalf("expected only variant a to be selected, got %+v", v)
+		}
+	}
+
+	if v := selectVariant(templateID, "r@example.com", nil); v != nil {
+		t.Errorf("expected no variant for template without variants, got %s", v.ID)
+	}
+}
+
+func TestValidateVariants(t *testing.T) {
+	if errs := validateVariants(abTestVariants()); len(errs) != 0 {
+		t.Errorf("expected valid variants, got %v", errs)
+	}
+
+	invalid := []TemplateVariant{
+		{ID: "a", Subject: "A", BodyHTML: "A", Weight: 0},
+		{ID: "a", Subject: "", BodyHTML: "A", Weight: -1},
+	}
+	errs := validateVariants(invalid)
+	fields := make(map[string]bool)
+	for _, e := range errs {
+		fields[e.Field] = true
+	}
+	for _, field := range []string{"variants[1].id", "variants[1].subject", "variants[1].weight", "variants"} {
+		if !fields[field] {
+			t.Errorf("expected validation error for %s, got %v", field, errs)
+		}
+	}
+}
+
+func TestSendTemplateEmailRecordsVariant(t *testing.T) {
+	server := setupTestServer()
+	server.config.TrackingBaseURL = "https://mail.example.com"
+	mockSMTP := server.smtpClient.(*MockSMTPClient)
+	mockDB := server.db.(*MockDatabase)
+
+	template := &EmailTemplate{
+		ID:           "22222222-2222-2222-2222-222222222222",
+		DealershipID: "11111111-1111-1111-1111-111111111111",
+		Name:         "Promo",
+		Subject:      "Default subject",
+		BodyHTML:     "<p>Default</p>",
+		Variables:    []string{"name"},
+		Variants:     abTestVariants(),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	server.db.CreateTemplate(template)
+
+	recipient := "customer@example.com"
+	expected := selectVariant(template.ID, recipient, template.Variants)
+
+	reqBody := SendTemplateEmailRequest{
+		DealershipID: template.DealershipID,
+		To:           recipient,
+		TemplateID:   template.ID,
+		Variables:    map[string]string{"name": "Jane"},
+	}
+	body, _ := json.Marshal(reqBody)
+	req, err := http.NewRequest("POST", "/email/send-template", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(server.SendTemplateEmailHandler).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	if len(mockSMTP.sentEmails) != 1 {
+		t.Fatalf("expected 1 sent email, got %d", len(mockSMTP.sentEmails))
+	}
+	if mockSMTP.sentEmails[0].Subject != expected.Subject {
+		t.Errorf("expected subject %q, got %q", expected.Subject, mockSMTP.sentEmails[0].Subject)
+	}
+
+	var log *EmailLog
+	for _, l := range mockDB.logs {
+		log = l
+	}
+	if log == nil || log.Variant == nil || *log.Variant != expected.ID {
+		t.Fatalf("expected log to record variant %s, got %+v", expected.ID, log)
+	}
+
+	pixel := fmt.Sprintf("https://mail.example.com/email/track/%s/open.gif", log.ID)
+	if !bytes.Contains([]byte(mockSMTP.sentEmails[0].BodyHTML), []byte(pixel)) {
+		t.Errorf("expected tracking pixel %s in body, got %s", pixel, mockSMTP.sentEmails[0].BodyHTML)
+	}
+}
+
+func TestBuildABResults(t *testing.T) {
+	templateID := "22222222-2222-2222-2222-222222222222"
+	variants := abTestVariants()
+
+	results := buildABResults(templateID, variants, []VariantStats{
+		{Variant: "a", Sent: 200, Opened: 80, Clicked: 10},
+		{Variant: "b", Sent: 150, Opened: 45, Clicked: 15},
+	})
+	if results.Winner == nil || *results.Winner != "b" {
+		t.Errorf("expected variant b to win on click rate, got %v", results.Winner)
+	}
+	if results.Variants[0].OpenRate != 0.4 {
+		t.Errorf("expected open rate 0.4 for variant a, got %v", results.Variants[0].OpenRate)
+	}
+
+	results = buildABResults(templateID, variants, []VariantStats{
+		{Variant: "a", Sent: 200, Opened: 80, Clicked: 10},
+		{Variant: "b", Sent: 20, Opened: 15, Clicked: 10},
+	})
+	if results.Winner != nil {
+		t.Errorf("expected no winner below %d sends, got %s", abMinSendsForWinner, *results.Winner)
+	}
+
+	results = buildABResults(templateID, variants, []VariantStats{
+		{Variant: "a", Sent: 100, Opened: 40, Clicked: 10},
+		{Variant: "b", Sent: 100, Opened: 40, Clicked: 10},
+	})
+	if results.Winner != nil {
+		t.Errorf("expected no winner on a tie, got %s", *results.Winner)
+	}
+}
+
+func TestGetABResults(t *testing.T) {
+	server := setupTestServer()
+	templateID := "22222222-2222-2222-2222-222222222222"
+	dealershipID := "11111111-1111-1111-1111-111111111111"
+	server.db.CreateTemplate(&EmailTemplate{
+		ID:           templateID,
+		DealershipID: dealershipID,
+		Name:         "Promo",
+		Subject:      "Default",
+		BodyHTML:     "<p>Default</p>",
+		Variants:     abTestVariants(),
+	})
+
+	variantA := "a"
+	opened := time.Now()
+	server.db.CreateLog(&EmailLog{
+		ID:           "33333333-3333-3333-3333-333333333333",
+		DealershipID: dealershipID,
+		TemplateID:   &templateID,
+		Status:       "sent",
+		Variant:      &variantA,
+		OpenedAt:     &opened,
+	})
+
+	req, err := http.NewRequest("GET", "/email/templates/"+templateID+"/ab-results?dealership_id="+dealershipID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": templateID})
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(server.GetABResultsHandler).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var results ABResults
+	if err := json.NewDecoder(rr.Body).Decode(&results); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(results.Variants) != 2 {
+		t.Fatalf("expected 2 variants, got %d", len(results.Variants))
+	}
+	if results.Variants[0].Sent != 1 || results.Variants[0].Opened != 1 {
+		t.Errorf("expected variant a to have 1 send and 1 open, got %+v", results.Variants[0])
+	}
+	if results.Winner != nil {
+		t.Errorf("expected no winner, got %s", *results.Winner)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,6 +12,9 @@ import (
 	"strconv"
 	"time"
 
+	"autolytiq/shared/configclient"
+	"autolytiq/shared/residency"
+
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -30,21 +34,25 @@ type S3Client struct {
 	urlExpiry  time.Duration
 }
 
-// NewS3Client creates a new S3 client
-func NewS3Client(bucket, region string) (*S3Client, error) {
+// NewS3Client creates a new S3 client for a storage backend
+func NewS3Client(backend residency.Backend) (*S3Client, error) {
 	cfg, err := config.LoadDefaultConfig(context.Background(),
-		config.WithRegion(region),
+		config.WithRegion(backend.StorageRegion),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
-	client := s3.NewFromConfig(cfg)
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if backend.Endpoint != "" {
+			o.BaseEndpoint = aws.String(backend.Endpoint)
+		}
+	})
 
 	return &S3Client{
 		client:    client,
-		bucket:    bucket,
-		region:    region,
+		bucket:    backend.Bucket,
+		region:    backend.StorageRegion,
 		urlExpiry: 15 * time.Minute, // Presigned URLs expire in 15 minutes
 	}, nil
 }
@@ -122,10 +130,19 @@ func (c *S3Client) GetPresignedUploadURL(ctx context.Context, key string, conten
 // ATTACHMENT HANDLERS
 // =====================================================
 
+// Attachment upload limits
+const (
+	maxAttachmentSize = 25 << 20
+
+	// maxAttachmentUploadSize caps an upload's body, leaving headroom for the
+	// other multipart fields
+	maxAttachmentUploadSize = maxAttachmentSize + 1<<20
+)
+
 // UploadAttachmentHandler handles file upload for attachments
 func (s *Server) UploadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse multipart form (max 25MB per file)
-	if err := r.ParseMultipartForm(25 << 20); err != nil {
+	if err := r.ParseMultipartForm(maxAttachmentSize); err != nil {
 		http.Error(w, "File too large (max 25MB)", http.StatusBadRequest)
 		return
 	}
@@ -156,21 +173,17 @@ func (s *Server) UploadAttachmentHandler(w http.ResponseWriter, r *http.Request)
 		contentType = "application/octet-stream"
 	}
 
-	// Get S3 client or use mock for development
-	s3Bucket := os.Getenv("S3_BUCKET")
-	s3Region := os.Getenv("AWS_REGION")
-
-	if s3Bucket == "" {
-		s3Bucket = "autolytiq-attachments"
-	}
-	if s3Region == "" {
-		s3Region = "us-east-1"
+	// Store in the bucket for the dealership's data region
+	backend, err := s.attachmentBackend(r.Context(), dealershipID, r.FormValue("region"))
+	if err != nil {
+		s.respondStorageError(w, r, err)
+		return
 	}
 
 	// For development, we'll store metadata without actual S3 upload
 	// In production, this would upload to S3
 	if os.Getenv("ENV") == "production" {
-		s3Client, err := NewS3Client(s3Bucket, s3Region)
+		s3Client, err := NewS3Client(backend)
 		if err != nil {
 			s.logger.WithContext(r.Context()).WithError(err).Error("Failed to create S3 client")
 			http.Error(w, "Storage service unavailable", http.StatusServiceUnavailable)
@@ -192,7 +205,7 @@ func (s *Server) UploadAttachmentHandler(w http.ResponseWriter, r *http.Request)
 		ContentType:  contentType,
 		Size:         header.Size,
 		S3Key:        s3Key,
-		S3Bucket:     s3Bucket,
+		S3Bucket:     backend.Bucket,
 		CreatedAt:    time.Now(),
 	}
 
@@ -209,6 +222,7 @@ func (s *Server) UploadAttachmentHandler(w http.ResponseWriter, r *http.Request)
 	s.logger.WithContext(r.Context()).
 		WithField("attachment_id", attachmentID).
 		WithField("filename", header.Filename).
+		WithField("region", backend.Region).
 		Info("Attachment uploaded")
 
 	w.Header().Set("Content-Type", "application/json")
@@ -238,7 +252,7 @@ func (s *Server) GetAttachmentHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Generate presigned download URL for production
 	if os.Getenv("ENV") == "production" {
-		s3Client, err := NewS3Client(attachment.S3Bucket, os.Getenv("AWS_REGION"))
+		s3Client, err := NewS3Client(s.bucketBackend(attachment.S3Bucket))
 		if err == nil {
 			downloadURL, err := s3Client.GetPresignedURL(r.Context(), attachment.S3Key, attachment.Filename)
 			if err == nil {
@@ -276,7 +290,7 @@ func (s *Server) DownloadAttachmentHandler(w http.ResponseWriter, r *http.Reques
 
 	// In production, redirect to S3 presigned URL
 	if os.Getenv("ENV") == "production" {
-		s3Client, err := NewS3Client(attachment.S3Bucket, os.Getenv("AWS_REGION"))
+		s3Client, err := NewS3Client(s.bucketBackend(attachment.S3Bucket))
 		if err != nil {
 			http.Error(w, "Storage service unavailable", http.StatusServiceUnavailable)
 			return
@@ -323,7 +337,7 @@ func (s *Server) DeleteAttachmentHandler(w http.ResponseWriter, r *http.Request)
 
 	// Delete from S3 in production
 	if os.Getenv("ENV") == "production" {
-		s3Client, err := NewS3Client(attachment.S3Bucket, os.Getenv("AWS_REGION"))
+		s3Client, err := NewS3Client(s.bucketBackend(attachment.S3Bucket))
 		if err == nil {
 			s3Client.DeleteFile(r.Context(), attachment.S3Key)
 		}
@@ -357,18 +371,16 @@ func (s *Server) ListEmailAttachmentsHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// Generate download URLs
-	s3Bucket := os.Getenv("S3_BUCKET")
-	s3Region := os.Getenv("AWS_REGION")
-
-	if os.Getenv("ENV") == "production" && s3Bucket != "" {
-		s3Client, err := NewS3Client(s3Bucket, s3Region)
-		if err == nil {
-			for _, attachment := range attachments {
-				url, err := s3Client.GetPresignedURL(r.Context(), attachment.S3Key, attachment.Filename)
-				if err == nil {
-					attachment.DownloadURL = url
-				}
+	// Generate download URLs from each attachment's own bucket
+	if os.Getenv("ENV") == "production" {
+		for _, attachment := range attachments {
+			s3Client, err := NewS3Client(s.bucketBackend(attachment.S3Bucket))
+			if err != nil {
+				continue
+			}
+			url, err := s3Client.GetPresignedURL(r.Context(), attachment.S3Key, attachment.Filename)
+			if err == nil {
+				attachment.DownloadURL = url
 			}
 		}
 	} else {
@@ -428,20 +440,16 @@ func (s *Server) GetUploadURLHandler(w http.ResponseWriter, r *http.Request) {
 	ext := filepath.Ext(filename)
 	s3Key := fmt.Sprintf("attachments/%s/%s%s", dealershipID, attachmentID, ext)
 
-	s3Bucket := os.Getenv("S3_BUCKET")
-	s3Region := os.Getenv("AWS_REGION")
-
-	if s3Bucket == "" {
-		s3Bucket = "autolytiq-attachments"
-	}
-	if s3Region == "" {
-		s3Region = "us-east-1"
+	backend, err := s.attachmentBackend(r.Context(), dealershipID, r.URL.Query().Get("region"))
+	if err != nil {
+		s.respondStorageError(w, r, err)
+		return
 	}
 
 	var uploadURL string
 
 	if os.Getenv("ENV") == "production" {
-		s3Client, err := NewS3Client(s3Bucket, s3Region)
+		s3Client, err := NewS3Client(backend)
 		if err != nil {
 			http.Error(w, "Storage service unavailable", http.StatusServiceUnavailable)
 			return
@@ -463,11 +471,78 @@ func (s *Server) GetUploadURLHandler(w http.ResponseWriter, r *http.Request) {
 		"upload_url":    uploadURL,
 		"attachment_id": attachmentID,
 		"s3_key":        s3Key,
-		"s3_bucket":     s3Bucket,
+		"s3_bucket":     backend.Bucket,
+		"region":        backend.Region,
 		"expires_in":    "15m",
 	})
 }
 
+// =====================================================
+// DATA RESIDENCY
+// =====================================================
+
+// regionSettings reads a dealership's data region from config-service
+type regionSettings interface {
+	GetString(ctx context.Context, dealershipID, key string) (string, error)
+}
+
+// attachmentStorageFromEnv configures a bucket per data region, e.g.
+// S3_BUCKET_EU. S3_BUCKET and AWS_REGION remain the default region's bucket.
+func attachmentStorageFromEnv() *residency.Router {
+	bucket := os.Getenv("S3_BUCKET")
+	if bucket == "" {
+		bucket = "autolytiq-attachments"
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	return residency.FromEnv("S3", residency.Backend{Bucket: bucket, StorageRegion: region})
+}
+
+// attachmentBackend returns the storage backend for a dealership's
+// attachments. requestedRegion, if set, must be the dealership's region
+// unless cross-region transfers are allowed. If the dealership's region
+// cannot be read, no backend is returned rather than assuming the default.
+func (s *Server) attachmentBackend(ctx context.Context, dealershipID, requestedRegion string) (residency.Backend, error) {
+	var dealershipRegion string
+	if s.settings != nil {
+		value, err := s.settings.GetString(ctx, dealershipID, residency.SettingKey)
+		if err != nil && !errors.Is(err, configclient.ErrNotFound) {
+			return residency.Backend{}, fmt.Errorf("failed to load data region: %w", err)
+		}
+		dealershipRegion = value
+	}
+
+	region, err := residency.Normalize(dealershipRegion)
+	if err != nil {
+		return residency.Backend{}, err
+	}
+	return s.storage.Target(region, requestedRegion)
+}
+
+// bucketBackend returns the backend for a stored attachment's bucket.
+// Buckets no longer configured are assumed to be in AWS_REGION.
+func (s *Server) bucketBackend(bucket string) residency.Backend {
+	if backend, ok := s.storage.BackendForBucket(bucket); ok {
+		return backend
+	}
+	return residency.Backend{Bucket: bucket, StorageRegion: os.Getenv("AWS_REGION")}
+}
+
+// respondStorageError maps a storage selection error to a response
+func (s *Server) respondStorageError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, residency.ErrCrossRegion):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, residency.ErrUnknownRegion):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to select attachment storage")
+		http.Error(w, "Storage service unavailable", http.StatusServiceUnavailable)
+	}
+}
+
 // =====================================================
 // HELPER FUNCTIONS
 // =====================================================
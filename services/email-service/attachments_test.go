@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"autolytiq/shared/configclient"
+	"autolytiq/shared/residency"
+
+	"github.com/google/uuid"
+)
+
+// fakeRegionSettings serves each dealership's data region from memory
+type fakeRegionSettings map[string]string
+
+func (f fakeRegionSettings) GetString(ctx context.Context, dealershipID, key string) (string, error) {
+	region, ok := f[dealershipID]
+	if !ok || key != residency.SettingKey {
+		return "", configclient.ErrNotFound
+	}
+	return region, nil
+}
+
+// setupResidencyTestServer returns a server with US and EU attachment buckets
+// and one dealership in each region
+func setupResidencyTestServer() (*Server, string, string) {
+	usDealer, euDealer := uuid.New().String(), uuid.New().String()
+
+	server := setupTestServer()
+	server.storage = residency.NewRouter(false,
+		residency.Backend{Region: residency.RegionUS, Bucket: "attachments-us", StorageRegion: "us-east-1"},
+		residency.Backend{Region: residency.RegionEU, Bucket: "attachments-eu", StorageRegion: "eu-west-1"},
+	)
+	server.settings = fakeRegionSettings{euDealer: "eu"}
+	return server, usDealer, euDealer
+}
+
+func uploadAttachment(server *Server, dealershipID, region string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("dealership_id", dealershipID)
+	if region != "" {
+		form.WriteField("region", region)
+	}
+	part, _ := form.CreateFormFile("file", "trade-in.pdf")
+	part.Write([]byte("%PDF-1.4"))
+	form.Close()
+
+	req := httptest.NewRequest("POST", "/api/v1/email/attachments/upload", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rr := httptest.NewRecorder()
+	server.UploadAttachmentHandler(rr, req)
+	return rr
+}
+
+func TestUploadAttachmentUsesDealershipRegion(t *testing.T) {
+	server, usDealer, euDealer := setupResidencyTestServer()
+
+	rr := uploadAttachment(server, euDealer, "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var attachment Attachment
+	json.Unmarshal(rr.Body.Bytes(), &attachment)
+	if attachment.S3Bucket != "attachments-eu" {
+		t.Errorf("Expected EU dealership's attachment in the EU bucket, got %s", attachment.S3Bucket)
+	}
+
+	rr = uploadAttachment(server, usDealer, "")
+	json.Unmarshal(rr.Body.Bytes(), &attachment)
+	if attachment.S3Bucket != "attachments-us" {
+		t.Errorf("Expected a dealership with no region in the default bucket, got %s", attachment.S3Bucket)
+	}
+}
+
+func TestUploadAttachmentRejectsCrossRegion(t *testing.T) {
+	server, _, euDealer := setupResidencyTestServer()
+
+	rr := uploadAttachment(server, euDealer, "us")
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 storing EU data in the US, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/email/attachments/upload-url?dealership_id="+euDealer+"&filename=a.pdf&region=us", nil)
+	rr = httptest.NewRecorder()
+	server.GetUploadURLHandler(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for a cross-region upload URL, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/email/attachments/upload-url?dealership_id="+euDealer+"&filename=a.pdf", nil)
+	rr = httptest.NewRecorder()
+	server.GetUploadURLHandler(rr, req)
+	var resp map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp["s3_bucket"] != "attachments-eu" || resp["region"] != residency.RegionEU {
+		t.Errorf("Expected the EU bucket for the upload URL, got %+v", resp)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"autolytiq/shared/tracing"
+)
+
+// MarketingOptOut is a customer opting out of marketing on one channel
+type MarketingOptOut struct {
+	CustomerID   string
+	DealershipID string
+	Channel      string
+
+	// IPAddress and UserAgent describe the request that made the opt-out,
+	// for the consent audit trail
+	IPAddress string
+	UserAgent string
+}
+
+// ConsentClient records marketing opt-outs with the consent service
+type ConsentClient interface {
+	RecordMarketingOptOut(ctx context.Context, optOut MarketingOptOut) error
+}
+
+// HTTPConsentClient records opt-outs through the data-retention service's
+// consent API
+type HTTPConsentClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewHTTPConsentClient creates a consent client for the service at baseURL
+func NewHTTPConsentClient(baseURL string) *HTTPConsentClient {
+	return &HTTPConsentClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Transport: tracing.Transport(nil), Timeout: 5 * time.Second},
+	}
+}
+
+// RecordMarketingOptOut withdraws the customer's marketing consent for the
+// opt-out's channel, leaving their other consents as they were
+func (c *HTTPConsentClient) RecordMarketingOptOut(ctx context.Context, optOut MarketingOptOut) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"marketing_" + optOut.Channel: false,
+		"source":                      "unsubscribe",
+		"user_agent":                  optOut.UserAgent,
+	})
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/consent/%s?dealership_id=%s",
+		c.baseURL, url.PathEscape(optOut.CustomerID), url.QueryEscape(optOut.DealershipID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build consent request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-ID", "customer_self_service")
+	if optOut.IPAddress != "" {
+		req.Header.Set("X-Real-IP", optOut.IPAddress)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to record opt-out: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("consent service returned status %d", resp.StatusCode)
+	}
+	return nil
+}
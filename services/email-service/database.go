@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -39,6 +41,11 @@ func (p *PostgresEmailDatabase) Close() error {
 	return p.db.Close()
 }
 
+// Ping checks the database is reachable
+func (p *PostgresEmailDatabase) Ping(ctx context.Context) error {
+	return p.db.PingContext(ctx)
+}
+
 // InitSchema initializes the database schema
 func (p *PostgresEmailDatabase) InitSchema() error {
 	schema := `
@@ -83,6 +90,61 @@ func (p *PostgresEmailDatabase) InitSchema() error {
 		CREATE INDEX IF NOT EXISTS idx_email_logs_template
 			ON email_logs(template_id);
 
+		-- A/B testing: template variants and per-send engagement
+		ALTER TABLE email_templates ADD COLUMN IF NOT EXISTS variants JSONB NOT NULL DEFAULT '[]';
+		ALTER TABLE email_logs ADD COLUMN IF NOT EXISTS variant VARCHAR(50);
+		ALTER TABLE email_logs ADD COLUMN IF NOT EXISTS opened_at TIMESTAMP;
+		ALTER TABLE email_logs ADD COLUMN IF NOT EXISTS clicked_at TIMESTAMP;
+
+		CREATE INDEX IF NOT EXISTS idx_email_logs_template_variant
+			ON email_logs(template_id, variant) WHERE variant IS NOT NULL;
+
+		-- Sandbox mode: rendered content of emails that were not sent
+		ALTER TABLE email_logs ADD COLUMN IF NOT EXISTS body_html TEXT;
+		ALTER TABLE email_logs ADD COLUMN IF NOT EXISTS redirected_to VARCHAR(255);
+
+		-- Template usage analytics: daily send counts per template, and
+		-- engagement from the tracked logs
+		ALTER TABLE email_logs ADD COLUMN IF NOT EXISTS bounced_at TIMESTAMP;
+
+		CREATE TABLE IF NOT EXISTS email_template_usage (
+			dealership_id UUID NOT NULL,
+			template_id UUID NOT NULL,
+			usage_date DATE NOT NULL,
+			sends INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (dealership_id, usage_date, template_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_email_template_usage_template
+			ON email_template_usage(template_id, usage_date);
+		CREATE INDEX IF NOT EXISTS idx_email_logs_template_created
+			ON email_logs(template_id, created_at) WHERE template_id IS NOT NULL;
+
+		-- Marketing templates get unsubscribe links, each usable once
+		ALTER TABLE email_templates ADD COLUMN IF NOT EXISTS category VARCHAR(20) NOT NULL DEFAULT 'transactional';
+
+		CREATE TABLE IF NOT EXISTS email_unsubscribe_tokens (
+			token_id VARCHAR(64) PRIMARY KEY,
+			customer_id UUID NOT NULL,
+			dealership_id UUID NOT NULL,
+			channel VARCHAR(20) NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			used_at TIMESTAMP NOT NULL DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_email_unsubscribe_tokens_expires
+			ON email_unsubscribe_tokens(expires_at);
+
+		-- Suppression list: addresses no non-critical email is sent to
+		CREATE TABLE IF NOT EXISTS email_suppressions (
+			dealership_id UUID NOT NULL,
+			email VARCHAR(255) NOT NULL,
+			reason VARCHAR(20) NOT NULL,
+			log_id UUID,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (dealership_id, email)
+		);
+
 		-- =====================================================
 		-- INBOX TABLES - Gmail/Outlook-like functionality
 		-- =====================================================
@@ -257,17 +319,24 @@ func (p *PostgresEmailDatabase) InitSchema() error {
 // CreateTemplate creates a new email template
 func (p *PostgresEmailDatabase) CreateTemplate(template *EmailTemplate) error {
 	query := `
-		INSERT INTO email_templates (id, dealership_id, name, subject, body_html, variables, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO email_templates (id, dealership_id, name, subject, body_html, variables, variants, category, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 	`
 
-	_, err := p.db.Exec(query,
+	variants, err := marshalVariants(template.Variants)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.db.Exec(query,
 		template.ID,
 		template.DealershipID,
 		template.Name,
 		template.Subject,
 		template.BodyHTML,
 		pq.Array(template.Variables),
+		variants,
+		template.Category,
 		template.CreatedAt,
 		template.UpdatedAt,
 	)
@@ -282,13 +351,14 @@ func (p *PostgresEmailDatabase) CreateTemplate(template *EmailTemplate) error {
 // GetTemplate retrieves a template by ID
 func (p *PostgresEmailDatabase) GetTemplate(id string, dealershipID string) (*EmailTemplate, error) {
 	query := `
-		SELECT id, dealership_id, name, subject, body_html, variables, created_at, updated_at
+		SELECT id, dealership_id, name, subject, body_html, variables, variants, category, created_at, updated_at
 		FROM email_templates
 		WHERE id = $1 AND dealership_id = $2
 	`
 
 	template := &EmailTemplate{}
 	var variables pq.StringArray
+	var variants []byte
 
 	err := p.db.QueryRow(query, id, dealershipID).Scan(
 		&template.ID,
@@ -297,6 +367,8 @@ func (p *PostgresEmailDatabase) GetTemplate(id string, dealershipID string) (*Em
 		&template.Subject,
 		&template.BodyHTML,
 		&variables,
+		&variants,
+		&template.Category,
 		&template.CreatedAt,
 		&template.UpdatedAt,
 	)
@@ -309,6 +381,9 @@ func (p *PostgresEmailDatabase) GetTemplate(id string, dealershipID string) (*Em
 	}
 
 	template.Variables = variables
+	if err := json.Unmarshal(variants, &template.Variants); err != nil {
+		return nil, fmt.Errorf("failed to decode template variants: %w", err)
+	}
 
 	return template, nil
 }
@@ -316,7 +391,7 @@ func (p *PostgresEmailDatabase) GetTemplate(id string, dealershipID string) (*Em
 // ListTemplates retrieves all templates for a dealership
 func (p *PostgresEmailDatabase) ListTemplates(dealershipID string, limit int, offset int) ([]*EmailTemplate, error) {
 	query := `
-		SELECT id, dealership_id, name, subject, body_html, variables, created_at, updated_at
+		SELECT id, dealership_id, name, subject, body_html, variables, variants, category, created_at, updated_at
 		FROM email_templates
 		WHERE dealership_id = $1
 		ORDER BY created_at DESC
@@ -333,6 +408,7 @@ func (p *PostgresEmailDatabase) ListTemplates(dealershipID string, limit int, of
 	for rows.Next() {
 		template := &EmailTemplate{}
 		var variables pq.StringArray
+		var variants []byte
 
 		err := rows.Scan(
 			&template.ID,
@@ -341,6 +417,8 @@ func (p *PostgresEmailDatabase) ListTemplates(dealershipID string, limit int, of
 			&template.Subject,
 			&template.BodyHTML,
 			&variables,
+			&variants,
+			&template.Category,
 			&template.CreatedAt,
 			&template.UpdatedAt,
 		)
@@ -349,6 +427,9 @@ func (p *PostgresEmailDatabase) ListTemplates(dealershipID string, limit int, of
 		}
 
 		template.Variables = variables
+		if err := json.Unmarshal(variants, &template.Variants); err != nil {
+			return nil, fmt.Errorf("failed to decode template variants: %w", err)
+		}
 		templates = append(templates, template)
 	}
 
@@ -363,10 +444,15 @@ func (p *PostgresEmailDatabase) ListTemplates(dealershipID string, limit int, of
 func (p *PostgresEmailDatabase) UpdateTemplate(template *EmailTemplate) error {
 	query := `
 		UPDATE email_templates
-		SET name = $1, subject = $2, body_html = $3, variables = $4, updated_at = $5
+		SET name = $1, subject = $2, body_html = $3, variables = $4, variants = $8, category = $9, updated_at = $5
 		WHERE id = $6 AND dealership_id = $7
 	`
 
+	variants, err := marshalVariants(template.Variants)
+	if err != nil {
+		return err
+	}
+
 	result, err := p.db.Exec(query,
 		template.Name,
 		template.Subject,
@@ -375,6 +461,8 @@ func (p *PostgresEmailDatabase) UpdateTemplate(template *EmailTemplate) error {
 		time.Now(),
 		template.ID,
 		template.DealershipID,
+		variants,
+		template.Category,
 	)
 
 	if err != nil {
@@ -417,8 +505,9 @@ func (p *PostgresEmailDatabase) DeleteTemplate(id string, dealershipID string) e
 // CreateLog creates a new email log entry
 func (p *PostgresEmailDatabase) CreateLog(log *EmailLog) error {
 	query := `
-		INSERT INTO email_logs (id, dealership_id, recipient, subject, template_id, status, sent_at, error, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO email_logs (id, dealership_id, recipient, subject, template_id, status, sent_at, error, created_at, variant,
+			body_html, redirected_to)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), $12)
 	`
 
 	_, err := p.db.Exec(query,
@@ -431,6 +520,9 @@ func (p *PostgresEmailDatabase) CreateLog(log *EmailLog) error {
 		log.SentAt,
 		log.Error,
 		log.CreatedAt,
+		log.Variant,
+		log.BodyHTML,
+		log.RedirectedTo,
 	)
 
 	if err != nil {
@@ -443,7 +535,8 @@ func (p *PostgresEmailDatabase) CreateLog(log *EmailLog) error {
 // GetLog retrieves a log entry by ID
 func (p *PostgresEmailDatabase) GetLog(id string, dealershipID string) (*EmailLog, error) {
 	query := `
-		SELECT id, dealership_id, recipient, subject, template_id, status, sent_at, error, created_at
+		SELECT id, dealership_id, recipient, subject, template_id, status, sent_at, error, created_at,
+			variant, opened_at, clicked_at, bounced_at, COALESCE(body_html, ''), redirected_to
 		FROM email_logs
 		WHERE id = $1 AND dealership_id = $2
 	`
@@ -460,6 +553,12 @@ func (p *PostgresEmailDatabase) GetLog(id string, dealershipID string) (*EmailLo
 		&log.SentAt,
 		&log.Error,
 		&log.CreatedAt,
+		&log.Variant,
+		&log.OpenedAt,
+		&log.ClickedAt,
+		&log.BouncedAt,
+		&log.BodyHTML,
+		&log.RedirectedTo,
 	)
 
 	if err == sql.ErrNoRows {
@@ -475,7 +574,8 @@ func (p *PostgresEmailDatabase) GetLog(id string, dealershipID string) (*EmailLo
 // ListLogs retrieves all logs for a dealership
 func (p *PostgresEmailDatabase) ListLogs(dealershipID string, limit int, offset int) ([]*EmailLog, error) {
 	query := `
-		SELECT id, dealership_id, recipient, subject, template_id, status, sent_at, error, created_at
+		SELECT id, dealership_id, recipient, subject, template_id, status, sent_at, error, created_at,
+			variant, opened_at, clicked_at, bounced_at, COALESCE(body_html, ''), redirected_to
 		FROM email_logs
 		WHERE dealership_id = $1
 		ORDER BY created_at DESC
@@ -502,6 +602,12 @@ func (p *PostgresEmailDatabase) ListLogs(dealershipID string, limit int, offset
 			&log.SentAt,
 			&log.Error,
 			&log.CreatedAt,
+			&log.Variant,
+			&log.OpenedAt,
+			&log.ClickedAt,
+			&log.BouncedAt,
+			&log.BodyHTML,
+			&log.RedirectedTo,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan log: %w", err)
@@ -541,3 +647,321 @@ func (p *PostgresEmailDatabase) UpdateLogStatus(id string, status string, sentAt
 
 	return nil
 }
+
+// RecordLogEvent records the first open, click or bounce for a log entry. A
+// click implies an open, so it also sets opened_at if unset.
+func (p *PostgresEmailDatabase) RecordLogEvent(id string, event string, at time.Time) error {
+	var query string
+	switch event {
+	case LogEventOpen:
+		query = `UPDATE email_logs SET opened_at = COALESCE(opened_at, $1) WHERE id = $2`
+	case LogEventClick:
+		query = `
+			UPDATE email_logs
+			SET clicked_at = COALESCE(clicked_at, $1), opened_at = COALESCE(opened_at, $1)
+			WHERE id = $2
+		`
+	case LogEventBounce:
+		query = `UPDATE email_logs SET bounced_at = COALESCE(bounced_at, $1) WHERE id = $2`
+	default:
+		return fmt.Errorf("unknown log event: %s", event)
+	}
+
+	result, err := p.db.Exec(query, at, id)
+	if err != nil {
+		return fmt.Errorf("failed to record log event: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("log not found")
+	}
+
+	return nil
+}
+
+// GetVariantStats aggregates sends, opens and clicks per A/B variant of a template
+func (p *PostgresEmailDatabase) GetVariantStats(templateID string, dealershipID string) ([]VariantStats, error) {
+	query := `
+		SELECT variant,
+			COUNT(*) FILTER (WHERE status = 'sent'),
+			COUNT(opened_at),
+			COUNT(clicked_at)
+		FROM email_logs
+		WHERE template_id = $1 AND dealership_id = $2 AND variant IS NOT NULL
+		GROUP BY variant
+	`
+
+	rows, err := p.db.Query(query, templateID, dealershipID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get variant stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := []VariantStats{}
+	for rows.Next() {
+		var vs VariantStats
+		if err := rows.Scan(&vs.Variant, &vs.Sent, &vs.Opened, &vs.Clicked); err != nil {
+			return nil, fmt.Errorf("failed to scan variant stats: %w", err)
+		}
+		stats = append(stats, vs)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating variant stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// IncrementTemplateUsage counts a send of a template on the day it was sent
+func (p *PostgresEmailDatabase) IncrementTemplateUsage(templateID string, dealershipID string, at time.Time) error {
+	query := `
+		INSERT INTO email_template_usage (dealership_id, template_id, usage_date, sends)
+		VALUES ($1, $2, $3, 1)
+		ON CONFLICT (dealership_id, usage_date, template_id)
+		DO UPDATE SET sends = email_template_usage.sends + 1
+	`
+
+	if _, err := p.db.Exec(query, dealershipID, templateID, at.UTC().Format("2006-01-02")); err != nil {
+		return fmt.Errorf("failed to increment template usage: %w", err)
+	}
+	return nil
+}
+
+// GetTemplateEngagement totals a template's sends from the usage counts, and
+// the opens, clicks and bounces of the emails it sent, over a date range
+func (p *PostgresEmailDatabase) GetTemplateEngagement(templateID string, dealershipID string, from time.Time, to time.Time) (*TemplateEngagement, error) {
+	engagement := &TemplateEngagement{}
+
+	err := p.db.QueryRow(`
+		SELECT COALESCE(SUM(sends), 0)
+		FROM email_template_usage
+		WHERE template_id = $1 AND dealership_id = $2 AND usage_date >= $3 AND usage_date < $4
+	`, templateID, dealershipID, from.Format("2006-01-02"), to.Format("2006-01-02")).Scan(&engagement.Sends)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get template usage: %w", err)
+	}
+
+	err = p.db.QueryRow(`
+		SELECT COUNT(opened_at), COUNT(clicked_at), COUNT(bounced_at)
+		FROM email_logs
+		WHERE template_id = $1 AND dealership_id = $2 AND created_at >= $3 AND created_at < $4
+	`, templateID, dealershipID, from, to).Scan(&engagement.Opens, &engagement.Clicks, &engagement.Bounces)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get template engagement: %w", err)
+	}
+
+	return engagement, nil
+}
+
+// ListTemplateLeaderboard returns a dealership's most-sent templates over a
+// date range with their engagement, most sends first
+func (p *PostgresEmailDatabase) ListTemplateLeaderboard(dealershipID string, from time.Time, to time.Time, limit int) ([]TemplateUsage, error) {
+	query := `
+		WITH template_sends AS (
+			SELECT template_id, SUM(sends) AS sends
+			FROM email_template_usage
+			WHERE dealership_id = $1 AND usage_date >= $2 AND usage_date < $3
+			GROUP BY template_id
+		), engagement AS (
+			SELECT template_id, COUNT(opened_at) AS opens, COUNT(clicked_at) AS clicks,
+				COUNT(bounced_at) AS bounces
+			FROM email_logs
+			WHERE dealership_id = $1 AND created_at >= $4 AND created_at < $5
+				AND template_id IN (SELECT template_id FROM template_sends)
+			GROUP BY template_id
+		)
+		SELECT u.template_id, COALESCE(t.name, ''), u.sends,
+			COALESCE(e.opens, 0), COALESCE(e.clicks, 0), COALESCE(e.bounces, 0)
+		FROM template_sends u
+		LEFT JOIN email_templates t ON t.id = u.template_id
+		LEFT JOIN engagement e ON e.template_id = u.template_id
+		ORDER BY u.sends DESC, t.name ASC
+		LIMIT $6
+	`
+
+	rows, err := p.db.Query(query, dealershipID, from.Format("2006-01-02"), to.Format("2006-01-02"), from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list template leaderboard: %w", err)
+	}
+	defer rows.Close()
+
+	usage := []TemplateUsage{}
+	for rows.Next() {
+		var u TemplateUsage
+		if err := rows.Scan(&u.TemplateID, &u.Name, &u.Sends, &u.Opens, &u.Clicks, &u.Bounces); err != nil {
+			return nil, fmt.Errorf("failed to scan template usage: %w", err)
+		}
+		usage = append(usage, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating template usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+// ClaimUnsubscribeToken records an unsubscribe token as used. It reports
+// false, without error, when the token was already used.
+func (p *PostgresEmailDatabase) ClaimUnsubscribeToken(token *UnsubscribeToken) (bool, error) {
+	query := `
+		INSERT INTO email_unsubscribe_tokens (token_id, customer_id, dealership_id, channel, expires_at, used_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (token_id) DO NOTHING
+	`
+
+	result, err := p.db.Exec(query, token.ID, token.CustomerID, token.DealershipID, token.Channel, token.ExpiresAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim unsubscribe token: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// ReleaseUnsubscribeToken makes a claimed unsubscribe token usable again
+func (p *PostgresEmailDatabase) ReleaseUnsubscribeToken(tokenID string) error {
+	if _, err := p.db.Exec(`DELETE FROM email_unsubscribe_tokens WHERE token_id = $1`, tokenID); err != nil {
+		return fmt.Errorf("failed to release unsubscribe token: %w", err)
+	}
+	return nil
+}
+
+// AddSuppression adds an address to a dealership's suppression list, or
+// updates the reason of one already on it
+func (p *PostgresEmailDatabase) AddSuppression(suppression *EmailSuppression) error {
+	query := `
+		INSERT INTO email_suppressions (dealership_id, email, reason, log_id, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (dealership_id, email) DO UPDATE
+		SET reason = EXCLUDED.reason, log_id = EXCLUDED.log_id
+	`
+
+	_, err := p.db.Exec(query, suppression.DealershipID, suppression.Email, suppression.Reason, suppression.LogID, suppression.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to add suppression: %w", err)
+	}
+	return nil
+}
+
+// GetSuppression returns an address's suppression, or nil if it has none
+func (p *PostgresEmailDatabase) GetSuppression(dealershipID string, email string) (*EmailSuppression, error) {
+	query := `
+		SELECT dealership_id, email, reason, log_id, created_at
+		FROM email_suppressions
+		WHERE dealership_id = $1 AND email = LOWER($2)
+	`
+
+	suppression := &EmailSuppression{}
+	err := p.db.QueryRow(query, dealershipID, email).Scan(
+		&suppression.DealershipID, &suppression.Email, &suppression.Reason, &suppression.LogID, &suppression.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get suppression: %w", err)
+	}
+	return suppression, nil
+}
+
+// ListSuppressions lists a dealership's suppressed addresses, newest first
+func (p *PostgresEmailDatabase) ListSuppressions(dealershipID string) ([]*EmailSuppression, error) {
+	query := `
+		SELECT dealership_id, email, reason, log_id, created_at
+		FROM email_suppressions
+		WHERE dealership_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := p.db.Query(query, dealershipID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list suppressions: %w", err)
+	}
+	defer rows.Close()
+
+	suppressions := []*EmailSuppression{}
+	for rows.Next() {
+		suppression := &EmailSuppression{}
+		if err := rows.Scan(&suppression.DealershipID, &suppression.Email, &suppression.Reason, &suppression.LogID, &suppression.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan suppression: %w", err)
+		}
+		suppressions = append(suppressions, suppression)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating suppressions: %w", err)
+	}
+
+	return suppressions, nil
+}
+
+// RemoveSuppression takes an address off a dealership's suppression list
+func (p *PostgresEmailDatabase) RemoveSuppression(dealershipID string, email string) error {
+	result, err := p.db.Exec(`DELETE FROM email_suppressions WHERE dealership_id = $1 AND email = LOWER($2)`, dealershipID, email)
+	if err != nil {
+		return fmt.Errorf("failed to remove suppression: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("suppression not found")
+	}
+
+	return nil
+}
+
+// SuppressLogRecipient adds the recipient of a log entry to its dealership's
+// suppression list
+func (p *PostgresEmailDatabase) SuppressLogRecipient(logID string, reason string, at time.Time) error {
+	query := `
+		INSERT INTO email_suppressions (dealership_id, email, reason, log_id, created_at)
+		SELECT dealership_id, LOWER(recipient), $2, id, $3
+		FROM email_logs
+		WHERE id = $1
+		ON CONFLICT (dealership_id, email) DO UPDATE
+		SET reason = EXCLUDED.reason, log_id = EXCLUDED.log_id
+	`
+
+	result, err := p.db.Exec(query, logID, reason, at)
+	if err != nil {
+		return fmt.Errorf("failed to suppress log recipient: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("log not found")
+	}
+
+	return nil
+}
+
+// marshalVariants encodes template variants for the JSONB column
+func marshalVariants(variants []TemplateVariant) ([]byte, error) {
+	if variants == nil {
+		variants = []TemplateVariant{}
+	}
+	data, err := json.Marshal(variants)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode template variants: %w", err)
+	}
+	return data, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"time"
 )
 
@@ -16,6 +17,8 @@ type EmailTemplate struct {
 	Subject      string    `json:"subject"`
 	BodyHTML     string    `json:"body_html"`
 	Variables    []string  `json:"variables"` // List of available variables like ["customer_name", "deal_amount"]
+	Variants     []TemplateVariant `json:"variants,omitempty"` // Optional A/B variants of subject and body
+	Category     string    `json:"category"`  // "transactional", or "marketing" for sends that need an unsubscribe link
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
@@ -27,10 +30,19 @@ type EmailLog struct {
 	Recipient    string     `json:"recipient"`
 	Subject      string     `json:"subject"`
 	TemplateID   *string    `json:"template_id,omitempty"` // Optional template reference
-	Status       string     `json:"status"`                // "pending", "sent", "failed"
+	Variant      *string    `json:"variant,omitempty"`     // A/B variant sent, if any
+	Status       string     `json:"status"`                // "pending", "sent", "failed", "sandboxed", "suppressed"
 	SentAt       *time.Time `json:"sent_at,omitempty"`
+	OpenedAt     *time.Time `json:"opened_at,omitempty"`
+	ClickedAt    *time.Time `json:"clicked_at,omitempty"`
+	BouncedAt    *time.Time `json:"bounced_at,omitempty"`
 	Error        *string    `json:"error,omitempty"`
 	CreatedAt    time.Time  `json:"created_at"`
+
+	// Sandboxed sends keep their rendered body, and the override address
+	// they were redirected to, for QA review
+	BodyHTML     string  `json:"body_html,omitempty"`
+	RedirectedTo *string `json:"redirected_to,omitempty"`
 }
 
 // =====================================================
@@ -238,6 +250,9 @@ type EmailDatabase interface {
 	Close() error
 	InitSchema() error
 
+	// Ping checks the database is reachable, for health checks
+	Ping(ctx context.Context) error
+
 	// Template operations
 	CreateTemplate(template *EmailTemplate) error
 	GetTemplate(id string, dealershipID string) (*EmailTemplate, error)
@@ -250,6 +265,26 @@ type EmailDatabase interface {
 	GetLog(id string, dealershipID string) (*EmailLog, error)
 	ListLogs(dealershipID string, limit int, offset int) ([]*EmailLog, error)
 	UpdateLogStatus(id string, status string, sentAt *time.Time, errorMsg *string) error
+	RecordLogEvent(id string, event string, at time.Time) error
+	GetVariantStats(templateID string, dealershipID string) ([]VariantStats, error)
+
+	// Template usage analytics. Ranges are [from, to).
+	IncrementTemplateUsage(templateID string, dealershipID string, at time.Time) error
+	GetTemplateEngagement(templateID string, dealershipID string, from time.Time, to time.Time) (*TemplateEngagement, error)
+	ListTemplateLeaderboard(dealershipID string, from time.Time, to time.Time, limit int) ([]TemplateUsage, error)
+
+	// Unsubscribe links. Claiming a token reports false when it was already
+	// used; a claimed token can be released if the opt-out couldn't be recorded.
+	ClaimUnsubscribeToken(token *UnsubscribeToken) (bool, error)
+	ReleaseUnsubscribeToken(tokenID string) error
+
+	// Suppression list. GetSuppression returns nil when the address isn't
+	// suppressed; SuppressLogRecipient suppresses the recipient of a log.
+	AddSuppression(suppression *EmailSuppression) error
+	GetSuppression(dealershipID string, email string) (*EmailSuppression, error)
+	ListSuppressions(dealershipID string) ([]*EmailSuppression, error)
+	RemoveSuppression(dealershipID string, email string) error
+	SuppressLogRecipient(logID string, reason string, at time.Time) error
 
 	// =====================================================
 	// INBOX OPERATIONS
@@ -3,8 +3,19 @@ module github.com/autolytiq/email-service
 go 1.18
 
 require (
+	autolytiq/shared/bodylimit v0.0.0
+	autolytiq/shared/configcheck v0.0.0
+	autolytiq/shared/configclient v0.0.0
+	autolytiq/shared/graceful v0.0.0
+	autolytiq/shared/health v0.0.0
+	autolytiq/shared/httpmetrics v0.0.0
 	autolytiq/shared/logging v0.0.0
+	autolytiq/shared/residency v0.0.0
 	autolytiq/shared/secrets v0.0.0
+	autolytiq/shared/tracing v0.0.0
+	github.com/aws/aws-sdk-go-v2 v1.24.0
+	github.com/aws/aws-sdk-go-v2/config v1.26.1
+	github.com/aws/aws-sdk-go-v2/service/s3 v1.47.5
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/mux v1.8.1
 	github.com/lib/pq v1.10.9
@@ -13,15 +24,18 @@ require (
 )
 
 require (
-	github.com/aws/aws-sdk-go-v2 v1.24.0 // indirect
-	github.com/aws/aws-sdk-go-v2/config v1.26.1 // indirect
+	autolytiq/shared/apierror v0.0.0 // indirect
+	github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream v1.5.4 // indirect
 	github.com/aws/aws-sdk-go-v2/credentials v1.16.12 // indirect
 	github.com/aws/aws-sdk-go-v2/feature/ec2/imds v1.14.10 // indirect
 	github.com/aws/aws-sdk-go-v2/internal/configsources v1.2.9 // indirect
 	github.com/aws/aws-sdk-go-v2/internal/endpoints/v2 v2.5.9 // indirect
 	github.com/aws/aws-sdk-go-v2/internal/ini v1.7.2 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/v4a v1.2.9 // indirect
 	github.com/aws/aws-sdk-go-v2/service/internal/accept-encoding v1.10.4 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/checksum v1.2.9 // indirect
 	github.com/aws/aws-sdk-go-v2/service/internal/presigned-url v1.10.9 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/s3shared v1.16.9 // indirect
 	github.com/aws/aws-sdk-go-v2/service/secretsmanager v1.26.0 // indirect
 	github.com/aws/aws-sdk-go-v2/service/sso v1.18.5 // indirect
 	github.com/aws/aws-sdk-go-v2/service/ssooidc v1.21.5 // indirect
@@ -41,6 +55,24 @@ require (
 	gopkg.in/alexcesaro/quotedprintable.v3 v3.0.0-20150716171945-2caba252f4dc // indirect
 )
 
+replace autolytiq/shared/configcheck => ../shared/configcheck
+
+replace autolytiq/shared/configclient => ../shared/configclient
+
 replace autolytiq/shared/logging => ../shared/logging
 
+replace autolytiq/shared/residency => ../shared/residency
+
 replace autolytiq/shared/secrets => ../shared/secrets
+
+replace autolytiq/shared/health => ../shared/health
+
+replace autolytiq/shared/graceful => ../shared/graceful
+
+replace autolytiq/shared/bodylimit => ../shared/bodylimit
+
+replace autolytiq/shared/apierror => ../shared/apierror
+
+replace autolytiq/shared/httpmetrics => ../shared/httpmetrics
+
+replace autolytiq/shared/tracing => ../shared/tracing
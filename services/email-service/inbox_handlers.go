@@ -322,7 +322,7 @@ func (s *Server) ComposeEmailHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Send via SMTP
 	recipients := strings.Join(req.To, ", ")
-	if err := s.smtpClient.SendEmail(recipients, req.Subject, req.BodyHTML); err != nil {
+	if err := s.deliver(r, recipients, req.Subject, req.BodyHTML); err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to send email via SMTP")
 		// Email is saved but not sent
 		http.Error(w, "Email saved but failed to send", http.StatusAccepted)
@@ -783,7 +783,7 @@ func (s *Server) SendDraftHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Send email
 	recipients := strings.Join(draft.ToEmails, ", ")
-	if err := s.smtpClient.SendEmail(recipients, draft.Subject, draft.BodyHTML); err != nil {
+	if err := s.deliver(r, recipients, draft.Subject, draft.BodyHTML); err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to send draft")
 		http.Error(w, "Failed to send email", http.StatusInternalServerError)
 		return
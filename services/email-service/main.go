@@ -7,10 +7,19 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"autolytiq/shared/bodylimit"
+	"autolytiq/shared/configcheck"
+	"autolytiq/shared/configclient"
+	"autolytiq/shared/graceful"
+	"autolytiq/shared/health"
+	"autolytiq/shared/httpmetrics"
 	"autolytiq/shared/logging"
+	"autolytiq/shared/residency"
 	"autolytiq/shared/secrets"
+	"autolytiq/shared/tracing"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -26,6 +35,27 @@ type Config struct {
 	SMTPPassword  string
 	SMTPFromEmail string
 	SMTPFromName  string
+
+	// TrackingBaseURL is the public base URL for open-tracking pixels.
+	// Tracking pixels are only added to A/B tested emails when set.
+	TrackingBaseURL string
+
+	// ConfigServiceURL is where dealership data regions are read from
+	ConfigServiceURL string
+
+	// Sandbox logs rendered emails instead of sending them. When
+	// SandboxRedirectTo is set, sandboxed emails are sent there instead.
+	Sandbox           bool
+	SandboxRedirectTo string
+
+	// PublicBaseURL is the API gateway's public URL, used for unsubscribe
+	// links in marketing emails. UnsubscribeSecret signs those links.
+	PublicBaseURL     string
+	UnsubscribeSecret string
+
+	// ConsentServiceURL is the data-retention service, where marketing
+	// opt-outs are recorded
+	ConsentServiceURL string
 }
 
 // Server holds application dependencies
@@ -35,6 +65,24 @@ type Server struct {
 	config     Config
 	logger     *logging.Logger
 	router     *mux.Router
+
+	// storage selects the attachment bucket for a dealership's data region
+	storage  *residency.Router
+	settings regionSettings
+
+	// unsubscribe signs marketing unsubscribe links, and consent records
+	// the opt-outs they make
+	unsubscribe *unsubscribeSigner
+	consent     ConsentClient
+
+	// health serves readiness and liveness checks
+	health *health.HealthChecker
+
+	// metrics records request metrics, served on /metrics
+	metrics *httpmetrics.Metrics
+
+	// tracer records a span for each request
+	tracer *tracing.Tracer
 }
 
 // SendEmailRequest represents a simple email send request
@@ -43,6 +91,10 @@ type SendEmailRequest struct {
 	To           string `json:"to"`
 	Subject      string `json:"subject"`
 	BodyHTML     string `json:"body_html"`
+
+	// ForceCritical sends even to a suppressed recipient, for transactional
+	// and legal notices that must be delivered
+	ForceCritical bool `json:"force_critical,omitempty"`
 }
 
 // SendTemplateEmailRequest represents a template-based email send request
@@ -51,23 +103,35 @@ type SendTemplateEmailRequest struct {
 	To           string            `json:"to"`
 	TemplateID   string            `json:"template_id"`
 	Variables    map[string]string `json:"variables"`
+
+	// CustomerID is the recipient's customer record. Marketing templates
+	// require it for the unsubscribe link.
+	CustomerID string `json:"customer_id,omitempty"`
+
+	// ForceCritical sends even to a suppressed recipient. Marketing
+	// templates can't use it.
+	ForceCritical bool `json:"force_critical,omitempty"`
 }
 
 // CreateTemplateRequest represents a template creation request
 type CreateTemplateRequest struct {
-	DealershipID string   `json:"dealership_id"`
-	Name         string   `json:"name"`
-	Subject      string   `json:"subject"`
-	BodyHTML     string   `json:"body_html"`
-	Variables    []string `json:"variables,omitempty"`
+	DealershipID string            `json:"dealership_id"`
+	Name         string            `json:"name"`
+	Subject      string            `json:"subject"`
+	BodyHTML     string            `json:"body_html"`
+	Variables    []string          `json:"variables,omitempty"`
+	Variants     []TemplateVariant `json:"variants,omitempty"`
+	Category     string            `json:"category,omitempty"`
 }
 
 // UpdateTemplateRequest represents a template update request
 type UpdateTemplateRequest struct {
-	Name      string   `json:"name"`
-	Subject   string   `json:"subject"`
-	BodyHTML  string   `json:"body_html"`
-	Variables []string `json:"variables,omitempty"`
+	Name      string            `json:"name"`
+	Subject   string            `json:"subject"`
+	BodyHTML  string            `json:"body_html"`
+	Variables []string          `json:"variables,omitempty"`
+	Variants  []TemplateVariant `json:"variants,omitempty"`
+	Category  string            `json:"category,omitempty"`
 }
 
 // NewServer creates a new server instance
@@ -78,11 +142,6 @@ func NewServer(config Config, logger *logging.Logger) (*Server, error) {
 		return nil, fmt.Errorf("failed to create database: %w", err)
 	}
 
-	// Initialize schema
-	if err := db.InitSchema(); err != nil {
-		return nil, fmt.Errorf("failed to initialize schema: %w", err)
-	}
-
 	// Initialize SMTP client
 	smtpConfig := SMTPConfig{
 		Host:      config.SMTPHost,
@@ -100,8 +159,33 @@ func NewServer(config Config, logger *logging.Logger) (*Server, error) {
 		config:     config,
 		logger:     logger,
 		router:     mux.NewRouter(),
+		storage:    attachmentStorageFromEnv(),
+		health:     health.NewHealthChecker("email-service", db.Ping),
+		metrics:    httpmetrics.New("email-service"),
+	}
+	s.tracer = tracing.New(tracing.Config{
+		Service: "email-service",
+		OnError: func(err error) { logger.WithError(err).Warn("Failed to export trace spans") },
+	})
+	if config.SMTPHost != "" {
+		s.health.AddDependency("smtp", smtpClient.Ping)
+	}
+	if config.ConfigServiceURL != "" {
+		cfg := configclient.DefaultConfig()
+		cfg.BaseURL = config.ConfigServiceURL
+		s.settings = configclient.NewClient(cfg)
 	}
 
+	if config.UnsubscribeSecret == "" {
+		logger.Warn("EMAIL_UNSUBSCRIBE_SECRET not set - unsubscribe links will not survive restarts")
+	}
+	signer, err := newUnsubscribeSigner(config.UnsubscribeSecret, unsubscribeTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	s.unsubscribe = signer
+	s.consent = NewHTTPConsentClient(config.ConsentServiceURL)
+
 	s.setupMiddleware()
 	s.setupRoutes()
 
@@ -110,14 +194,22 @@ func NewServer(config Config, logger *logging.Logger) (*Server, error) {
 
 // setupMiddleware configures middleware
 func (s *Server) setupMiddleware() {
+	s.router.Use(s.tracer.Middleware)
 	s.router.Use(logging.RequestIDMiddleware)
 	s.router.Use(logging.RequestLoggingMiddleware(s.logger))
+	s.router.Use(s.metrics.Middleware)
+	s.router.Use(bodylimit.Middleware(bodylimit.Config{
+		Routes: map[string]int64{"/email/attachments/upload": maxAttachmentUploadSize},
+	}))
 }
 
 // setupRoutes configures all routes
 func (s *Server) setupRoutes() {
 	// Health check
 	s.router.HandleFunc("/health", s.HealthCheckHandler).Methods("GET")
+	s.router.Handle("/metrics", s.metrics.Handler()).Methods("GET")
+	s.router.HandleFunc("/ready", s.health.ReadinessHandler).Methods("GET")
+	s.router.HandleFunc("/live", s.health.LivenessHandler).Methods("GET")
 
 	// Email sending (legacy)
 	s.router.HandleFunc("/email/send", s.SendEmailHandler).Methods("POST")
@@ -126,13 +218,29 @@ func (s *Server) setupRoutes() {
 	// Template management
 	s.router.HandleFunc("/email/templates", s.CreateTemplateHandler).Methods("POST")
 	s.router.HandleFunc("/email/templates", s.ListTemplatesHandler).Methods("GET")
+	s.router.HandleFunc("/email/templates/leaderboard", s.GetTemplateLeaderboardHandler).Methods("GET")
 	s.router.HandleFunc("/email/templates/{id}", s.GetTemplateHandler).Methods("GET")
 	s.router.HandleFunc("/email/templates/{id}", s.UpdateTemplateHandler).Methods("PUT")
 	s.router.HandleFunc("/email/templates/{id}", s.DeleteTemplateHandler).Methods("DELETE")
+	s.router.HandleFunc("/email/templates/{id}/ab-results", s.GetABResultsHandler).Methods("GET")
+	s.router.HandleFunc("/email/templates/{id}/stats", s.GetTemplateStatsHandler).Methods("GET")
 
 	// Log management
 	s.router.HandleFunc("/email/logs", s.ListLogsHandler).Methods("GET")
 	s.router.HandleFunc("/email/logs/{id}", s.GetLogHandler).Methods("GET")
+	s.router.HandleFunc("/email/logs/{id}/events", s.RecordLogEventHandler).Methods("POST")
+
+	// Suppression list
+	s.router.HandleFunc("/email/suppressions", s.AddSuppressionHandler).Methods("POST")
+	s.router.HandleFunc("/email/suppressions", s.ListSuppressionsHandler).Methods("GET")
+	s.router.HandleFunc("/email/suppressions/{email}", s.RemoveSuppressionHandler).Methods("DELETE")
+
+	// Engagement tracking
+	s.router.HandleFunc("/email/track/{id}/open.gif", s.TrackOpenHandler).Methods("GET")
+
+	// Marketing unsubscribe links. POST is the one-click unsubscribe mail
+	// clients send from the List-Unsubscribe header.
+	s.router.HandleFunc("/email/unsubscribe/{token}", s.UnsubscribeHandler).Methods("GET", "POST")
 
 	// =====================================================
 	// INBOX API - Gmail/Outlook-like functionality
@@ -206,6 +314,11 @@ func (s *Server) SendEmailHandler(w http.ResponseWriter, r *http.Request) {
 		Status:       "pending",
 		CreatedAt:    time.Now(),
 	}
+	s.prepareLog(emailLog, req.BodyHTML)
+
+	if s.skipSuppressed(w, r, emailLog, req.ForceCritical) {
+		return
+	}
 
 	if err := s.db.CreateLog(emailLog); err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to create log")
@@ -214,7 +327,7 @@ func (s *Server) SendEmailHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Send email
-	err := s.smtpClient.SendEmail(req.To, req.Subject, req.BodyHTML)
+	err := s.deliver(r, req.To, req.Subject, req.BodyHTML)
 	if err != nil {
 		// Update log with error
 		errMsg := err.Error()
@@ -227,7 +340,8 @@ func (s *Server) SendEmailHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Update log with success
 	sentAt := time.Now()
-	s.db.UpdateLogStatus(logID, "sent", &sentAt, nil)
+	status := s.deliveredStatus()
+	s.db.UpdateLogStatus(logID, status, &sentAt, nil)
 
 	s.logger.WithContext(r.Context()).WithField("log_id", logID).Info("Email sent successfully")
 
@@ -236,6 +350,7 @@ func (s *Server) SendEmailHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{
 		"message": "Email sent successfully",
 		"log_id":  logID,
+		"status":  status,
 	})
 }
 
@@ -253,9 +368,54 @@ func (s *Server) SendTemplateEmailHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// Pick an A/B variant for the recipient, if the template has any
+	subjectTemplate, bodyTemplate := template.Subject, template.BodyHTML
+	var variantID *string
+	if variant := selectVariant(template.ID, req.To, template.Variants); variant != nil {
+		subjectTemplate, bodyTemplate = variant.Subject, variant.BodyHTML
+		variantID = &variant.ID
+	}
+
+	// Marketing emails carry a link opting the customer out of marketing
+	variables := req.Variables
+	var unsubscribeLink string
+	if template.Category == TemplateCategoryMarketing {
+		if req.ForceCritical {
+			respondValidationError(w, &ValidationErrors{
+				Errors: []ValidationError{{Field: "force_critical", Message: "Marketing templates can't override the suppression list"}},
+			})
+			return
+		}
+		if req.CustomerID == "" {
+			respondValidationError(w, &ValidationErrors{
+				Errors: []ValidationError{{Field: "customer_id", Message: "Customer ID is required for marketing templates"}},
+			})
+			return
+		}
+		token, err := s.unsubscribe.Issue(req.CustomerID, req.DealershipID, UnsubscribeChannelEmail)
+		if err != nil {
+			s.logger.WithContext(r.Context()).WithError(err).Error("Failed to issue unsubscribe token")
+			http.Error(w, "Failed to create unsubscribe link", http.StatusInternalServerError)
+			return
+		}
+		unsubscribeLink = s.unsubscribeURL(token)
+
+		variables = make(map[string]string, len(req.Variables)+1)
+		for k, v := range req.Variables {
+			variables[k] = v
+		}
+		variables[unsubscribeURLVariable] = unsubscribeLink
+	}
+
 	// Render template
-	subject := RenderTemplate(template.Subject, req.Variables)
-	bodyHTML := RenderTemplate(template.BodyHTML, req.Variables)
+	subject := RenderTemplate(subjectTemplate, variables)
+	bodyHTML := RenderTemplate(bodyTemplate, variables)
+
+	var headers map[string]string
+	if unsubscribeLink != "" {
+		bodyHTML = withUnsubscribeLink(bodyHTML, unsubscribeLink)
+		headers = unsubscribeHeaders(unsubscribeLink)
+	}
 
 	// Create log entry
 	logID := uuid.New().String()
@@ -265,10 +425,20 @@ func (s *Server) SendTemplateEmailHandler(w http.ResponseWriter, r *http.Request
 		Recipient:    req.To,
 		Subject:      subject,
 		TemplateID:   &req.TemplateID,
+		Variant:      variantID,
 		Status:       "pending",
 		CreatedAt:    time.Now(),
 	}
 
+	if variantID != nil {
+		bodyHTML = s.withTrackingPixel(bodyHTML, logID)
+	}
+	s.prepareLog(emailLog, bodyHTML)
+
+	if s.skipSuppressed(w, r, emailLog, req.ForceCritical) {
+		return
+	}
+
 	if err := s.db.CreateLog(emailLog); err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to create log")
 		http.Error(w, "Failed to create log", http.StatusInternalServerError)
@@ -276,7 +446,7 @@ func (s *Server) SendTemplateEmailHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	// Send email
-	err = s.smtpClient.SendEmail(req.To, subject, bodyHTML)
+	err = s.deliverWithHeaders(r, req.To, subject, bodyHTML, headers)
 	if err != nil {
 		// Update log with error
 		errMsg := err.Error()
@@ -289,7 +459,9 @@ func (s *Server) SendTemplateEmailHandler(w http.ResponseWriter, r *http.Request
 
 	// Update log with success
 	sentAt := time.Now()
-	s.db.UpdateLogStatus(logID, "sent", &sentAt, nil)
+	status := s.deliveredStatus()
+	s.db.UpdateLogStatus(logID, status, &sentAt, nil)
+	s.recordTemplateUsage(r, template.ID, req.DealershipID)
 
 	s.logger.WithContext(r.Context()).WithField("log_id", logID).Info("Template email sent successfully")
 
@@ -298,6 +470,7 @@ func (s *Server) SendTemplateEmailHandler(w http.ResponseWriter, r *http.Request
 	json.NewEncoder(w).Encode(map[string]string{
 		"message": "Email sent successfully",
 		"log_id":  logID,
+		"status":  status,
 	})
 }
 
@@ -329,6 +502,11 @@ func (s *Server) CreateTemplateHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	category := req.Category
+	if category == "" {
+		category = TemplateCategoryTransactional
+	}
+
 	template := &EmailTemplate{
 		ID:           uuid.New().String(),
 		DealershipID: req.DealershipID,
@@ -336,6 +514,8 @@ func (s *Server) CreateTemplateHandler(w http.ResponseWriter, r *http.Request) {
 		Subject:      req.Subject,
 		BodyHTML:     req.BodyHTML,
 		Variables:    variables,
+		Variants:     req.Variants,
+		Category:     category,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
@@ -472,6 +652,17 @@ func (s *Server) UpdateTemplateHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Updates that don't name a category keep the template's current one
+	category := req.Category
+	if category == "" {
+		existing, err := s.db.GetTemplate(templateID, dealershipID)
+		if err != nil {
+			http.Error(w, "Template not found", http.StatusNotFound)
+			return
+		}
+		category = existing.Category
+	}
+
 	template := &EmailTemplate{
 		ID:           templateID,
 		DealershipID: dealershipID,
@@ -479,6 +670,8 @@ func (s *Server) UpdateTemplateHandler(w http.ResponseWriter, r *http.Request) {
 		Subject:      req.Subject,
 		BodyHTML:     req.BodyHTML,
 		Variables:    variables,
+		Variants:     req.Variants,
+		Category:     category,
 		UpdatedAt:    time.Now(),
 	}
 
@@ -689,6 +882,15 @@ func LoadConfig(ctx context.Context, logger *logging.Logger) Config {
 		smtpFromName = envName
 	}
 
+	publicBaseURL := os.Getenv("PUBLIC_BASE_URL")
+	if publicBaseURL == "" {
+		publicBaseURL = "http://localhost:8080"
+	}
+	consentServiceURL := os.Getenv("DATA_RETENTION_SERVICE_URL")
+	if consentServiceURL == "" {
+		consentServiceURL = "http://localhost:8091"
+	}
+
 	return Config{
 		Port:          port,
 		DatabaseURL:   databaseURL,
@@ -698,9 +900,46 @@ func LoadConfig(ctx context.Context, logger *logging.Logger) Config {
 		SMTPPassword:  smtpPassword,
 		SMTPFromEmail: smtpFromEmail,
 		SMTPFromName:  smtpFromName,
+
+		TrackingBaseURL:  os.Getenv("EMAIL_TRACKING_BASE_URL"),
+		ConfigServiceURL: os.Getenv("CONFIG_SERVICE_URL"),
+
+		Sandbox:           os.Getenv("EMAIL_SANDBOX") == "true",
+		SandboxRedirectTo: os.Getenv("EMAIL_SANDBOX_REDIRECT_TO"),
+
+		PublicBaseURL:     publicBaseURL,
+		UnsubscribeSecret: os.Getenv("EMAIL_UNSUBSCRIBE_SECRET"),
+		ConsentServiceURL: consentServiceURL,
 	}
 }
 
+// validateConfig checks the loaded configuration, plus the raw values of
+// settings that silently fall back to a default when they don't parse, and
+// reports every problem at once
+func validateConfig(config Config) error {
+	c := configcheck.New()
+
+	c.Port("PORT", config.Port)
+	c.Required("DATABASE_URL", config.DatabaseURL)
+	c.URL("DATABASE_URL", config.DatabaseURL)
+	c.Required("SMTP_HOST", config.SMTPHost)
+	c.Port("SMTP_PORT", os.Getenv("SMTP_PORT"))
+	c.URL("EMAIL_TRACKING_BASE_URL", config.TrackingBaseURL)
+	c.URL("CONFIG_SERVICE_URL", config.ConfigServiceURL)
+	c.URL("PUBLIC_BASE_URL", config.PublicBaseURL)
+	c.URL("DATA_RETENTION_SERVICE_URL", config.ConsentServiceURL)
+	c.Bool("EMAIL_SANDBOX", os.Getenv("EMAIL_SANDBOX"))
+	if config.SandboxRedirectTo != "" && !emailRegex.MatchString(config.SandboxRedirectTo) {
+		c.Addf("EMAIL_SANDBOX_REDIRECT_TO", "must be an email address (got %q)", config.SandboxRedirectTo)
+	}
+	for _, region := range residency.Regions {
+		key := "S3_ENDPOINT_" + strings.ToUpper(region)
+		c.URL(key, os.Getenv(key))
+	}
+
+	return c.Err()
+}
+
 func main() {
 	// Initialize logger
 	logger := logging.New(logging.Config{
@@ -709,15 +948,35 @@ func main() {
 
 	ctx := context.Background()
 	config := LoadConfig(ctx, logger)
+	if err := validateConfig(config); err != nil {
+		logger.Fatal(err.Error())
+	}
 
 	server, err := NewServer(config, logger)
 	if err != nil {
 		logger.Fatalf("Failed to create server: %v", err)
 	}
 	defer server.db.Close()
+	defer server.tracer.Shutdown(context.Background())
+
+	// Stop serving on SIGINT or SIGTERM, once in-flight requests finish
+	ctx, stop := graceful.SignalContext(ctx)
+	defer stop()
 
+	// Start serving before the schema is initialized, so /live answers and
+	// /ready reports not ready until startup finishes
+	srv := &http.Server{Addr: ":" + config.Port, Handler: server.router}
 	logger.Infof("Email Service starting on port %s", config.Port)
-	if err := http.ListenAndServe(":"+config.Port, server.router); err != nil {
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- graceful.ListenAndServe(ctx, srv, graceful.DefaultTimeout) }()
+
+	// Initialize schema
+	if err := server.db.InitSchema(); err != nil {
+		logger.Fatalf("Failed to initialize schema: %v", err)
+	}
+	server.health.MarkReady()
+
+	if err := <-serveErr; err != nil {
 		logger.Fatal(err.Error())
 	}
 }
@@ -0,0 +1,50 @@
+package main
+
+import "net/http"
+
+// deliver sends an email through SMTP. In sandbox mode the rendered email is
+// logged instead and only sent on to the sandbox override address, if one is
+// configured, so staging never emails real customers.
+func (s *Server) deliver(r *http.Request, to, subject, bodyHTML string) error {
+	return s.deliverWithHeaders(r, to, subject, bodyHTML, nil)
+}
+
+// deliverWithHeaders is deliver for emails that carry extra headers
+func (s *Server) deliverWithHeaders(r *http.Request, to, subject, bodyHTML string, headers map[string]string) error {
+	if !s.config.Sandbox {
+		return s.smtpClient.SendEmailWithHeaders(to, subject, bodyHTML, headers)
+	}
+
+	s.logger.WithContext(r.Context()).
+		WithField("to", to).
+		WithField("redirect_to", s.config.SandboxRedirectTo).
+		WithField("subject", subject).
+		WithField("body_html", bodyHTML).
+		Info("Sandboxed email")
+
+	if s.config.SandboxRedirectTo == "" {
+		return nil
+	}
+	return s.smtpClient.SendEmailWithHeaders(s.config.SandboxRedirectTo, subject, bodyHTML, headers)
+}
+
+// prepareLog records the rendered email on a log entry about to be sent when
+// sandbox mode is on, so QA can review it through the logs API
+func (s *Server) prepareLog(emailLog *EmailLog, bodyHTML string) {
+	if !s.config.Sandbox {
+		return
+	}
+	emailLog.BodyHTML = bodyHTML
+	if s.config.SandboxRedirectTo != "" {
+		redirectTo := s.config.SandboxRedirectTo
+		emailLog.RedirectedTo = &redirectTo
+	}
+}
+
+// deliveredStatus is the log status of a successfully delivered email
+func (s *Server) deliveredStatus() string {
+	if s.config.Sandbox {
+		return "sandboxed"
+	}
+	return "sent"
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const sandboxDealershipID = "11111111-1111-1111-1111-111111111111"
+
+func setupSandboxServer(redirectTo string) *Server {
+	server := setupTestServer()
+	server.config.Sandbox = true
+	server.config.SandboxRedirectTo = redirectTo
+	return server
+}
+
+func sendJSON(handler http.HandlerFunc, path string, body interface{}) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", path, bytes.NewBuffer(data))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestSandboxSkipsSMTP(t *testing.T) {
+	server := setupSandboxServer("")
+
+	rr := sendJSON(server.SendEmailHandler, "/email/send", SendEmailRequest{
+		DealershipID: sandboxDealershipID,
+		To:           "customer@example.com",
+		Subject:      "Your deal",
+		BodyHTML:     "<p>Your payment is $412/mo</p>",
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if sent := server.smtpClient.(*MockSMTPClient).sentEmails; len(sent) != 0 {
+		t.Errorf("Expected no SMTP sends in sandbox mode, got %+v", sent)
+	}
+
+	var resp map[string]string
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp["status"] != "sandboxed" {
+		t.Errorf("Expected sandboxed status in response, got %q", resp["status"])
+	}
+
+	// The rendered email is available through the logs API
+	req := httptest.NewRequest("GET", "/email/logs/"+resp["log_id"]+"?dealership_id="+sandboxDealershipID, nil)
+	req = mux.SetURLVars(req, map[string]string{"id": resp["log_id"]})
+	rr = httptest.NewRecorder()
+	server.GetLogHandler(rr, req)
+
+	var emailLog EmailLog
+	json.Unmarshal(rr.Body.Bytes(), &emailLog)
+	if emailLog.Status != "sandboxed" || emailLog.BodyHTML != "<p>Your payment is $412/mo</p>" {
+		t.Errorf("Expected a sandboxed log with the rendered body, got %+v", emailLog)
+	}
+	if emailLog.Recipient != "customer@example.com" || emailLog.RedirectedTo != nil {
+		t.Errorf("Expected the intended recipient and no redirect, got %+v", emailLog)
+	}
+}
+
+func TestSandboxRedirectsTemplateEmail(t *testing.T) {
+	server := setupSandboxServer("qa@autolytiq.com")
+	server.db.CreateTemplate(&EmailTemplate{
+		ID:           "22222222-2222-2222-2222-222222222222",
+		DealershipID: sandboxDealershipID,
+		Name:         "Welcome Email",
+		Subject:      "Welcome {{customer_name}}!",
+		BodyHTML:     "<h1>Hello {{customer_name}}</h1>",
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	})
+
+	rr := sendJSON(server.SendTemplateEmailHandler, "/email/send-template", SendTemplateEmailRequest{
+		DealershipID: sandboxDealershipID,
+		To:           "customer@example.com",
+		TemplateID:   "22222222-2222-2222-2222-222222222222",
+		Variables:    map[string]string{"customer_name": "John Doe"},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	sent := server.smtpClient.(*MockSMTPClient).sentEmails
+	if len(sent) != 1 || sent[0].To != "qa@autolytiq.com" {
+		t.Fatalf("Expected one send to the override address, got %+v", sent)
+	}
+
+	var resp map[string]string
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	emailLog, _ := server.db.GetLog(resp["log_id"], sandboxDealershipID)
+	if emailLog.Status != "sandboxed" || emailLog.Subject != "Welcome John Doe!" || emailLog.BodyHTML != "<h1>Hello John Doe</h1>" {
+		t.Errorf("Expected the rendered template on the sandboxed log, got %+v", emailLog)
+	}
+	if emailLog.RedirectedTo == nil || *emailLog.RedirectedTo != "qa@autolytiq.com" {
+		t.Errorf("Expected the redirect to be recorded, got %v", emailLog.RedirectedTo)
+	}
+}
+
+func TestSendEmailOutsideSandboxKeepsNoBody(t *testing.T) {
+	server := setupTestServer()
+
+	rr := sendJSON(server.SendEmailHandler, "/email/send", SendEmailRequest{
+		DealershipID: sandboxDealershipID,
+		To:           "customer@example.com",
+		Subject:      "Your deal",
+		BodyHTML:     "<p>Hi</p>",
+	})
+	var resp map[string]string
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+
+	emailLog, _ := server.db.GetLog(resp["log_id"], sandboxDealershipID)
+	if emailLog.Status != "sent" || emailLog.BodyHTML != "" {
+		t.Errorf("Expected a sent log without stored content, got %+v", emailLog)
+	}
+}
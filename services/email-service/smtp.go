@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"gopkg.in/gomail.v2"
@@ -21,6 +24,10 @@ type SMTPConfig struct {
 // SMTPClient interface for sending emails
 type SMTPClient interface {
 	SendEmail(to string, subject string, bodyHTML string) error
+
+	// SendEmailWithHeaders sends an email with extra headers, such as
+	// List-Unsubscribe
+	SendEmailWithHeaders(to string, subject string, bodyHTML string, headers map[string]string) error
 }
 
 // GoMailSMTPClient implements SMTPClient using gomail
@@ -39,8 +46,25 @@ func NewGoMailSMTPClient(config SMTPConfig) *GoMailSMTPClient {
 	}
 }
 
+// Ping checks the SMTP server accepts connections, for readiness checks. It
+// only opens a TCP connection, so no login is attempted.
+func (c *GoMailSMTPClient) Ping(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(c.config.Host, strconv.Itoa(c.config.Port)))
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	return conn.Close()
+}
+
 // SendEmail sends an email using the configured SMTP server
 func (c *GoMailSMTPClient) SendEmail(to string, subject string, bodyHTML string) error {
+	return c.SendEmailWithHeaders(to, subject, bodyHTML, nil)
+}
+
+// SendEmailWithHeaders sends an email with extra headers using the configured
+// SMTP server
+func (c *GoMailSMTPClient) SendEmailWithHeaders(to string, subject string, bodyHTML string, headers map[string]string) error {
 	m := gomail.NewMessage()
 
 	// Set headers
@@ -52,6 +76,9 @@ func (c *GoMailSMTPClient) SendEmail(to string, subject string, bodyHTML string)
 
 	m.SetHeader("To", to)
 	m.SetHeader("Subject", subject)
+	for name, value := range headers {
+		m.SetHeader(name, value)
+	}
 	m.SetBody("text/html", bodyHTML)
 
 	// Send email
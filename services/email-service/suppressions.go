@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// LogStatusSuppressed is the log status of a send skipped because the
+// recipient is on the dealership's suppression list
+const LogStatusSuppressed = "suppressed"
+
+// Suppression reasons. Hard bounces and complaints are added automatically
+// from log events; the rest are added by operators.
+const (
+	SuppressionReasonManual      = "manual"
+	SuppressionReasonHardBounce  = "hard_bounce"
+	SuppressionReasonComplaint   = "complaint"
+	SuppressionReasonRoleAccount = "role_account"
+	SuppressionReasonInvalid     = "invalid"
+)
+
+// suppressionReasons are the reasons a suppression can be recorded with
+var suppressionReasons = map[string]bool{
+	SuppressionReasonManual:      true,
+	SuppressionReasonHardBounce:  true,
+	SuppressionReasonComplaint:   true,
+	SuppressionReasonRoleAccount: true,
+	SuppressionReasonInvalid:     true,
+}
+
+// EmailSuppression blocks all non-critical sends to an address for a dealership
+type EmailSuppression struct {
+	DealershipID string    `json:"dealership_id"`
+	Email        string    `json:"email"`
+	Reason       string    `json:"reason"`
+	LogID        *string   `json:"log_id,omitempty"` // Log whose bounce or complaint added it, if any
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AddSuppressionRequest adds an address to a dealership's suppression list
+type AddSuppressionRequest struct {
+	DealershipID string `json:"dealership_id"`
+	Email        string `json:"email"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// Validate validates AddSuppressionRequest
+func (r *AddSuppressionRequest) Validate() *ValidationErrors {
+	var errors []ValidationError
+
+	if r.DealershipID == "" {
+		errors = append(errors, ValidationError{Field: "dealership_id", Message: "Dealership ID is required"})
+	} else if !uuidRegex.MatchString(r.DealershipID) {
+		errors = append(errors, ValidationError{Field: "dealership_id", Message: "Must be a valid UUID"})
+	}
+
+	if r.Email == "" {
+		errors = append(errors, ValidationError{Field: "email", Message: "Email is required"})
+	} else if !emailRegex.MatchString(r.Email) {
+		errors = append(errors, ValidationError{Field: "email", Message: "Must be a valid email address"})
+	}
+
+	if !suppressionReasons[r.Reason] {
+		errors = append(errors, ValidationError{
+			Field:   "reason",
+			Message: "Reason must be manual, hard_bounce, complaint, role_account or invalid",
+		})
+	}
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize sanitizes AddSuppressionRequest
+func (r *AddSuppressionRequest) Sanitize() {
+	r.DealershipID = strings.TrimSpace(r.DealershipID)
+	r.Email = strings.TrimSpace(strings.ToLower(r.Email))
+	r.Reason = strings.TrimSpace(strings.ToLower(r.Reason))
+	if r.Reason == "" {
+		r.Reason = SuppressionReasonManual
+	}
+}
+
+// skipSuppressed checks the recipient of a send against the dealership's
+// suppression list. A suppressed send is logged with the suppressed status
+// and answered without delivering, and skipSuppressed reports true. Critical
+// sends bypass the list. It also reports true after writing an error.
+func (s *Server) skipSuppressed(w http.ResponseWriter, r *http.Request, emailLog *EmailLog, forceCritical bool) bool {
+	suppression, err := s.db.GetSuppression(emailLog.DealershipID, emailLog.Recipient)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to check suppression list")
+		http.Error(w, "Failed to check suppression list", http.StatusInternalServerError)
+		return true
+	}
+	if suppression == nil {
+		return false
+	}
+	if forceCritical {
+		s.logger.WithContext(r.Context()).
+			WithField("log_id", emailLog.ID).
+			WithField("reason", suppression.Reason).
+			Info("Sending critical email to suppressed recipient")
+		return false
+	}
+
+	emailLog.Status = LogStatusSuppressed
+	if err := s.db.CreateLog(emailLog); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to create log")
+		http.Error(w, "Failed to create log", http.StatusInternalServerError)
+		return true
+	}
+
+	s.logger.WithContext(r.Context()).
+		WithField("log_id", emailLog.ID).
+		WithField("reason", suppression.Reason).
+		Info("Skipped email to suppressed recipient")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Recipient is suppressed; email not sent",
+		"log_id":  emailLog.ID,
+		"status":  LogStatusSuppressed,
+	})
+	return true
+}
+
+// AddSuppressionHandler handles POST /email/suppressions. Adding an address
+// that is already suppressed updates its reason.
+func (s *Server) AddSuppressionHandler(w http.ResponseWriter, r *http.Request) {
+	var req AddSuppressionRequest
+	if !decodeAndValidate(r, w, &req) {
+		return
+	}
+
+	suppression := &EmailSuppression{
+		DealershipID: req.DealershipID,
+		Email:        req.Email,
+		Reason:       req.Reason,
+		CreatedAt:    time.Now(),
+	}
+	if err := s.db.AddSuppression(suppression); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to add suppression")
+		http.Error(w, "Failed to add suppression", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(suppression)
+}
+
+// ListSuppressionsHandler handles GET /email/suppressions
+func (s *Server) ListSuppressionsHandler(w http.ResponseWriter, r *http.Request) {
+	dealershipID := r.URL.Query().Get("dealership_id")
+	if dealershipID == "" {
+		respondValidationError(w, &ValidationErrors{
+			Errors: []ValidationError{{Field: "dealership_id", Message: "dealership_id is required"}},
+		})
+		return
+	}
+	if !validateUUID(w, dealershipID, "dealership_id") {
+		return
+	}
+
+	suppressions, err := s.db.ListSuppressions(dealershipID)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list suppressions")
+		http.Error(w, "Failed to list suppressions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(suppressions)
+}
+
+// RemoveSuppressionHandler handles DELETE /email/suppressions/{email}
+func (s *Server) RemoveSuppressionHandler(w http.ResponseWriter, r *http.Request) {
+	email := strings.TrimSpace(strings.ToLower(mux.Vars(r)["email"]))
+
+	dealershipID := r.URL.Query().Get("dealership_id")
+	if dealershipID == "" {
+		respondValidationError(w, &ValidationErrors{
+			Errors: []ValidationError{{Field: "dealership_id", Message: "dealership_id is required"}},
+		})
+		return
+	}
+	if !validateUUID(w, dealershipID, "dealership_id") {
+		return
+	}
+
+	if err := s.db.RemoveSuppression(dealershipID, email); err != nil {
+		http.Error(w, "Suppression not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const suppressionDealershipID = "33333333-3333-3333-3333-333333333333"
+
+func setupSuppressionServer() *Server {
+	server := setupTestServer()
+	server.router = mux.NewRouter()
+	server.router.HandleFunc("/email/send", server.SendEmailHandler).Methods("POST")
+	server.router.HandleFunc("/email/send-template", server.SendTemplateEmailHandler).Methods("POST")
+	server.router.HandleFunc("/email/logs/{id}/events", server.RecordLogEventHandler).Methods("POST")
+	server.router.HandleFunc("/email/suppressions", server.AddSuppressionHandler).Methods("POST")
+	server.router.HandleFunc("/email/suppressions", server.ListSuppressionsHandler).Methods("GET")
+	server.router.HandleFunc("/email/suppressions/{email}", server.RemoveSuppressionHandler).Methods("DELETE")
+	return server
+}
+
+func serveJSON(server *Server, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewBuffer(data))
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func suppressionSend(to string, forceCritical bool) SendEmailRequest {
+	return SendEmailRequest{
+		DealershipID:  suppressionDealershipID,
+		To:            to,
+		Subject:       "Your deal",
+		BodyHTML:      "<p>Your documents are ready</p>",
+		ForceCritical: forceCritical,
+	}
+}
+
+func TestSuppressedRecipientIsBlocked(t *testing.T) {
+	server := setupSuppressionServer()
+
+	rr := serveJSON(server, "POST", "/email/suppressions", AddSuppressionRequest{
+		DealershipID: suppressionDealershipID,
+		Email:        "Info@Example.com",
+		Reason:       SuppressionReasonRoleAccount,
+	})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = serveJSON(server, "POST", "/email/send", suppressionSend("info@example.com", false))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp map[string]string
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp["status"] != LogStatusSuppressed {
+		t.Errorf("Expected suppressed status, got %q", resp["status"])
+	}
+	if sent := server.smtpClient.(*MockSMTPClient).sentEmails; len(sent) != 0 {
+		t.Errorf("Expected nothing sent to a suppressed recipient, got %+v", sent)
+	}
+	emailLog, err := server.db.GetLog(resp["log_id"], suppressionDealershipID)
+	if err != nil || emailLog.Status != LogStatusSuppressed {
+		t.Errorf("Expected a suppressed log entry, got %+v, %v", emailLog, err)
+	}
+
+	// Other dealerships' lists don't apply
+	other := suppressionSend("info@example.com", false)
+	other.DealershipID = "44444444-4444-4444-4444-444444444444"
+	serveJSON(server, "POST", "/email/send", other)
+	if sent := server.smtpClient.(*MockSMTPClient).sentEmails; len(sent) != 1 {
+		t.Errorf("Expected the other dealership's email to be sent, got %d sends", len(sent))
+	}
+
+	// Removing the suppression allows sends again
+	rr = serveJSON(server, "DELETE", "/email/suppressions/info@example.com?dealership_id="+suppressionDealershipID, nil)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d", rr.Code)
+	}
+	serveJSON(server, "POST", "/email/send", suppressionSend("info@example.com", false))
+	if sent := server.smtpClient.(*MockSMTPClient).sentEmails; len(sent) != 2 {
+		t.Errorf("Expected sends to resume after removal, got %d sends", len(sent))
+	}
+}
+
+func TestForceCriticalBypassesSuppression(t *testing.T) {
+	server := setupSuppressionServer()
+	server.db.AddSuppression(&EmailSuppression{
+		DealershipID: suppressionDealershipID,
+		Email:        "customer@example.com",
+		Reason:       SuppressionReasonManual,
+		CreatedAt:    time.Now(),
+	})
+
+	rr := serveJSON(server, "POST", "/email/send", suppressionSend("customer@example.com", true))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp map[string]string
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp["status"] != "sent" {
+		t.Errorf("Expected the critical email to be sent, got %q", resp["status"])
+	}
+	if sent := server.smtpClient.(*MockSMTPClient).sentEmails; len(sent) != 1 {
+		t.Errorf("Expected one send, got %d", len(sent))
+	}
+
+	// Marketing templates can't use the override
+	server.db.CreateTemplate(&EmailTemplate{
+		ID:           "55555555-5555-5555-5555-555555555555",
+		DealershipID: suppressionDealershipID,
+		Name:         "Spring Sale",
+		Subject:      "Spring deals",
+		BodyHTML:     "<p>Save big</p>",
+		Category:     TemplateCategoryMarketing,
+	})
+	rr = serveJSON(server, "POST", "/email/send-template", SendTemplateEmailRequest{
+		DealershipID:  suppressionDealershipID,
+		To:            "customer@example.com",
+		TemplateID:    "55555555-5555-5555-5555-555555555555",
+		CustomerID:    "66666666-6666-6666-6666-666666666666",
+		ForceCritical: true,
+	})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 forcing a marketing template, got %d", rr.Code)
+	}
+}
+
+func TestBounceAndComplaintSuppressRecipient(t *testing.T) {
+	server := setupSuppressionServer()
+	logs := map[string]string{
+		"77777777-7777-7777-7777-777777777771": "soft@example.com",
+		"77777777-7777-7777-7777-777777777772": "hard@example.com",
+		"77777777-7777-7777-7777-777777777773": "complainer@example.com",
+	}
+	for id, recipient := range logs {
+		server.db.CreateLog(&EmailLog{ID: id, DealershipID: suppressionDealershipID, Recipient: recipient, Status: "sent", CreatedAt: time.Now()})
+	}
+
+	events := []struct {
+		logID string
+		event LogEventRequest
+	}{
+		{"77777777-7777-7777-7777-777777777771", LogEventRequest{Event: LogEventBounce, BounceType: "soft"}},
+		{"77777777-7777-7777-7777-777777777772", LogEventRequest{Event: LogEventBounce, BounceType: BounceTypeHard}},
+		{"77777777-7777-7777-7777-777777777773", LogEventRequest{Event: LogEventComplaint}},
+	}
+	for _, e := range events {
+		if rr := serveJSON(server, "POST", "/email/logs/"+e.logID+"/events", e.event); rr.Code != http.StatusNoContent {
+			t.Fatalf("Expected 204 for %+v, got %d: %s", e.event, rr.Code, rr.Body.String())
+		}
+	}
+
+	want := map[string]string{
+		"soft@example.com":       "",
+		"hard@example.com":       SuppressionReasonHardBounce,
+		"complainer@example.com": SuppressionReasonComplaint,
+	}
+	for email, reason := range want {
+		suppression, _ := server.db.GetSuppression(suppressionDealershipID, email)
+		if reason == "" {
+			if suppression != nil {
+				t.Errorf("Expected %s not to be suppressed, got %+v", email, suppression)
+			}
+			continue
+		}
+		if suppression == nil || suppression.Reason != reason || suppression.LogID == nil {
+			t.Errorf("Expected %s to be suppressed for %s, got %+v", email, reason, suppression)
+		}
+	}
+
+	if rr := serveJSON(server, "POST", "/email/logs/77777777-7777-7777-7777-777777777779/events", LogEventRequest{Event: LogEventComplaint}); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a complaint about an unknown log, got %d", rr.Code)
+	}
+	if rr := serveJSON(server, "POST", "/email/logs/77777777-7777-7777-7777-777777777771/events", LogEventRequest{Event: LogEventOpen, BounceType: BounceTypeHard}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bounce type on an open, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Template stats date ranges
+const (
+	templateStatsDefaultDays = 30
+	templateStatsMaxDays     = 366
+	templateLeaderboardLimit = 10
+	templateLeaderboardMax   = 100
+)
+
+// TemplateEngagement holds a template's send and engagement counts
+type TemplateEngagement struct {
+	Sends   int `json:"sends"`
+	Opens   int `json:"opens"`
+	Clicks  int `json:"clicks"`
+	Bounces int `json:"bounces"`
+}
+
+// TemplateEngagementRates are engagement counts as a fraction of sends
+type TemplateEngagementRates struct {
+	OpenRate   float64 `json:"open_rate"`
+	ClickRate  float64 `json:"click_rate"`
+	BounceRate float64 `json:"bounce_rate"`
+}
+
+// TemplateStats is a template's usage report over a date range
+type TemplateStats struct {
+	TemplateID string `json:"template_id"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	TemplateEngagement
+	TemplateEngagementRates
+}
+
+// TemplateUsage is a template's entry in the dealership leaderboard
+type TemplateUsage struct {
+	TemplateID string `json:"template_id"`
+	Name       string `json:"name"`
+	TemplateEngagement
+	TemplateEngagementRates
+}
+
+// rates computes the engagement rates of the counts
+func (e TemplateEngagement) rates() TemplateEngagementRates {
+	if e.Sends == 0 {
+		return TemplateEngagementRates{}
+	}
+	sends := float64(e.Sends)
+	return TemplateEngagementRates{
+		OpenRate:   float64(e.Opens) / sends,
+		ClickRate:  float64(e.Clicks) / sends,
+		BounceRate: float64(e.Bounces) / sends,
+	}
+}
+
+// parseStatsRange reads the inclusive from and to dates (YYYY-MM-DD) of a
+// stats request, defaulting to the last 30 days. It returns the range as
+// [from, to) in UTC, responding with a validation error if it is invalid.
+func parseStatsRange(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if v := r.URL.Query().Get("to"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			respondValidationError(w, &ValidationErrors{
+				Errors: []ValidationError{{Field: "to", Message: "Must be a date in YYYY-MM-DD format"}},
+			})
+			return time.Time{}, time.Time{}, false
+		}
+		to = parsed
+	}
+	to = to.AddDate(0, 0, 1)
+
+	from := to.AddDate(0, 0, -templateStatsDefaultDays)
+	if v := r.URL.Query().Get("from"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			respondValidationError(w, &ValidationErrors{
+				Errors: []ValidationError{{Field: "from", Message: "Must be a date in YYYY-MM-DD format"}},
+			})
+			return time.Time{}, time.Time{}, false
+		}
+		from = parsed
+	}
+
+	if !from.Before(to) {
+		respondValidationError(w, &ValidationErrors{
+			Errors: []ValidationError{{Field: "from", Message: "Must not be after to"}},
+		})
+		return time.Time{}, time.Time{}, false
+	}
+	if to.Sub(from) > templateStatsMaxDays*24*time.Hour {
+		respondValidationError(w, &ValidationErrors{
+			Errors: []ValidationError{{Field: "from", Message: "Range must be at most 366 days"}},
+		})
+		return time.Time{}, time.Time{}, false
+	}
+
+	return from, to, true
+}
+
+// requireDealershipQuery reads and validates the dealership_id query parameter
+func requireDealershipQuery(w http.ResponseWriter, r *http.Request) (string, bool) {
+	dealershipID := r.URL.Query().Get("dealership_id")
+	if dealershipID == "" {
+		respondValidationError(w, &ValidationErrors{
+			Errors: []ValidationError{{Field: "dealership_id", Message: "dealership_id is required"}},
+		})
+		return "", false
+	}
+	if !validateUUID(w, dealershipID, "dealership_id") {
+		return "", false
+	}
+	return dealershipID, true
+}
+
+// recordTemplateUsage counts a template send. Failures are logged and do not
+// fail the send.
+func (s *Server) recordTemplateUsage(r *http.Request, templateID, dealershipID string) {
+	if err := s.db.IncrementTemplateUsage(templateID, dealershipID, time.Now()); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).WithField("template_id", templateID).Warn("Failed to record template usage")
+	}
+}
+
+// GetTemplateStatsHandler handles GET /email/templates/{id}/stats
+func (s *Server) GetTemplateStatsHandler(w http.ResponseWriter, r *http.Request) {
+	templateID := mux.Vars(r)["id"]
+
+	// Validate UUID
+	if !validateUUID(w, templateID, "id") {
+		return
+	}
+
+	dealershipID, ok := requireDealershipQuery(w, r)
+	if !ok {
+		return
+	}
+	from, to, ok := parseStatsRange(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := s.db.GetTemplate(templateID, dealershipID); err != nil {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	engagement, err := s.db.GetTemplateEngagement(templateID, dealershipID, from, to)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get template engagement")
+		http.Error(w, "Failed to get template stats", http.StatusInternalServerError)
+		return
+	}
+
+	stats := TemplateStats{
+		TemplateID:              templateID,
+		From:                    from.Format("2006-01-02"),
+		To:                      to.AddDate(0, 0, -1).Format("2006-01-02"),
+		TemplateEngagement:      *engagement,
+		TemplateEngagementRates: engagement.rates(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// GetTemplateLeaderboardHandler handles GET /email/templates/leaderboard,
+// ranking a dealership's templates by sends
+func (s *Server) GetTemplateLeaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	dealershipID, ok := requireDealershipQuery(w, r)
+	if !ok {
+		return
+	}
+	from, to, ok := parseStatsRange(w, r)
+	if !ok {
+		return
+	}
+
+	limit := templateLeaderboardLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > templateLeaderboardMax {
+			respondValidationError(w, &ValidationErrors{
+				Errors: []ValidationError{{Field: "limit", Message: "Must be between 1 and 100"}},
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	usage, err := s.db.ListTemplateLeaderboard(dealershipID, from, to, limit)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list template leaderboard")
+		http.Error(w, "Failed to list template leaderboard", http.StatusInternalServerError)
+		return
+	}
+
+	for i := range usage {
+		usage[i].TemplateEngagementRates = usage[i].TemplateEngagement.rates()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usage)
+}
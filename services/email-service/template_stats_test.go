@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	statsDealershipID = "11111111-1111-1111-1111-111111111111"
+	statsTemplateID   = "22222222-2222-2222-2222-222222222222"
+)
+
+func createStatsTemplate(server *Server, id, name string) {
+	server.db.CreateTemplate(&EmailTemplate{
+		ID:           id,
+		DealershipID: statsDealershipID,
+		Name:         name,
+		Subject:      "Hello {{name}}",
+		BodyHTML:     "<p>Hi {{name}}</p>",
+	})
+}
+
+func sendTemplate(t *testing.T, server *Server, templateID string) int {
+	t.Helper()
+	body, _ := json.Marshal(SendTemplateEmailRequest{
+		DealershipID: statsDealershipID,
+		To:           "customer@example.com",
+		TemplateID:   templateID,
+		Variables:    map[string]string{"name": "Jane"},
+	})
+	req := httptest.NewRequest("POST", "/email/send-template", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(server.SendTemplateEmailHandler).ServeHTTP(rr, req)
+	return rr.Code
+}
+
+func TestSendTemplateEmailIncrementsUsage(t *testing.T) {
+	server := setupTestServer()
+	mockDB := server.db.(*MockDatabase)
+	createStatsTemplate(server, statsTemplateID, "Welcome")
+
+	for i := 0; i < 2; i++ {
+		if code := sendTemplate(t, server, statsTemplateID); code != http.StatusOK {
+			t.Fatalf("send returned %d", code)
+		}
+	}
+
+	// Failed sends are not counted as usage
+	server.smtpClient.(*MockSMTPClient).shouldFail = true
+	if code := sendTemplate(t, server, statsTemplateID); code != http.StatusInternalServerError {
+		t.Fatalf("expected the failed send to return 500, got %d", code)
+	}
+
+	key := templateUsageKey{statsDealershipID, statsTemplateID, time.Now().UTC().Format("2006-01-02")}
+	if sends := mockDB.templateUsage[key]; sends != 2 {
+		t.Errorf("expected 2 sends counted for today, got %d", sends)
+	}
+}
+
+func TestGetTemplateStatsAggregates(t *testing.T) {
+	server := setupTestServer()
+	mockDB := server.db.(*MockDatabase)
+	createStatsTemplate(server, statsTemplateID, "Welcome")
+
+	inRange := time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC)
+	outOfRange := time.Date(2026, 2, 1, 15, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		mockDB.IncrementTemplateUsage(statsTemplateID, statsDealershipID, inRange)
+	}
+	mockDB.IncrementTemplateUsage(statsTemplateID, statsDealershipID, outOfRange)
+
+	templateID := statsTemplateID
+	logs := []struct {
+		id      string
+		created time.Time
+		events  []string
+	}{
+		{"33333333-3333-3333-3333-333333333331", inRange, []string{LogEventClick}},
+		{"33333333-3333-3333-3333-333333333332", inRange, []string{LogEventOpen}},
+		{"33333333-3333-3333-3333-333333333333", inRange, []string{LogEventBounce}},
+		{"33333333-3333-3333-3333-333333333334", inRange, nil},
+		{"33333333-3333-3333-3333-333333333335", outOfRange, []string{LogEventClick}},
+	}
+	for _, l := range logs {
+		mockDB.CreateLog(&EmailLog{ID: l.id, DealershipID: statsDealershipID, TemplateID: &templateID, Status: "sent", CreatedAt: l.created})
+		for _, event := range l.events {
+			body, _ := json.Marshal(LogEventRequest{Event: event})
+			req := httptest.NewRequest("POST", "/email/logs/"+l.id+"/events", bytes.NewBuffer(body))
+			req = mux.SetURLVars(req, map[string]string{"id": l.id})
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(server.RecordLogEventHandler).ServeHTTP(rr, req)
+			if rr.Code != http.StatusNoContent {
+				t.Fatalf("recording %s returned %d: %s", event, rr.Code, rr.Body.String())
+			}
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/email/templates/"+statsTemplateID+"/stats?dealership_id="+statsDealershipID+"&from=2026-03-01&to=2026-03-31", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": statsTemplateID})
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(server.GetTemplateStatsHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var stats TemplateStats
+	if err := json.NewDecoder(rr.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.From != "2026-03-01" || stats.To != "2026-03-31" {
+		t.Errorf("expected the requested range, got %s to %s", stats.From, stats.To)
+	}
+	// A click implies an open
+	if stats.Sends != 4 || stats.Opens != 2 || stats.Clicks != 1 || stats.Bounces != 1 {
+		t.Errorf("expected 4 sends, 2 opens, 1 click and 1 bounce, got %+v", stats.TemplateEngagement)
+	}
+	if stats.OpenRate != 0.5 || stats.ClickRate != 0.25 || stats.BounceRate != 0.25 {
+		t.Errorf("unexpected rates: %+v", stats.TemplateEngagementRates)
+	}
+}
+
+func TestGetTemplateStatsValidation(t *testing.T) {
+	server := setupTestServer()
+	createStatsTemplate(server, statsTemplateID, "Welcome")
+
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{"missing dealership", "", http.StatusBadRequest},
+		{"bad date", "?dealership_id=" + statsDealershipID + "&from=March", http.StatusBadRequest},
+		{"reversed range", "?dealership_id=" + statsDealershipID + "&from=2026-03-10&to=2026-03-01", http.StatusBadRequest},
+		{"range too long", "?dealership_id=" + statsDealershipID + "&from=2024-01-01&to=2026-01-01", http.StatusBadRequest},
+		{"other dealership", "?dealership_id=44444444-4444-4444-4444-444444444444", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/email/templates/"+statsTemplateID+"/stats"+tt.query, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": statsTemplateID})
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(server.GetTemplateStatsHandler).ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("expected %d, got %d: %s", tt.want, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetTemplateLeaderboard(t *testing.T) {
+	server := setupTestServer()
+	mockDB := server.db.(*MockDatabase)
+
+	popular := "22222222-2222-2222-2222-222222222221"
+	quiet := "22222222-2222-2222-2222-222222222223"
+	createStatsTemplate(server, popular, "Service reminder")
+	createStatsTemplate(server, quiet, "Birthday")
+	createStatsTemplate(server, statsTemplateID, "Unused")
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		mockDB.IncrementTemplateUsage(popular, statsDealershipID, now)
+	}
+	mockDB.IncrementTemplateUsage(quiet, statsDealershipID, now)
+	mockDB.IncrementTemplateUsage(quiet, "44444444-4444-4444-4444-444444444444", now)
+
+	req := httptest.NewRequest("GET", "/email/templates/leaderboard?dealership_id="+statsDealershipID, nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(server.GetTemplateLeaderboardHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var usage []TemplateUsage
+	if err := json.NewDecoder(rr.Body).Decode(&usage); err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 2 {
+		t.Fatalf("expected the 2 used templates, got %+v", usage)
+	}
+	if usage[0].TemplateID != popular || usage[0].Name != "Service reminder" || usage[0].Sends != 3 {
+		t.Errorf("expected the most-used template first, got %+v", usage[0])
+	}
+	if usage[1].TemplateID != quiet || usage[1].Sends != 1 {
+		t.Errorf("expected the other dealership's sends excluded, got %+v", usage[1])
+	}
+
+	req = httptest.NewRequest("GET", "/email/templates/leaderboard?dealership_id="+statsDealershipID+"&limit=1", nil)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(server.GetTemplateLeaderboardHandler).ServeHTTP(rr, req)
+	json.NewDecoder(rr.Body).Decode(&usage)
+	if len(usage) != 1 || usage[0].TemplateID != popular {
+		t.Errorf("expected only the top template with limit=1, got %+v", usage)
+	}
+}
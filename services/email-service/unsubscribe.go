@@ -0,0 +1,220 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Template categories. Marketing templates get an unsubscribe link and
+// List-Unsubscribe header; transactional ones never do.
+const (
+	TemplateCategoryTransactional = "transactional"
+	TemplateCategoryMarketing     = "marketing"
+)
+
+// UnsubscribeChannelEmail is the consent channel email unsubscribe links opt
+// the customer out of
+const UnsubscribeChannelEmail = "email"
+
+// unsubscribeChannels are the marketing consent channels a token can name
+var unsubscribeChannels = map[string]bool{
+	"email": true,
+	"sms":   true,
+	"phone": true,
+}
+
+// unsubscribeTokenTTL is how long an unsubscribe link keeps working. It must
+// comfortably exceed the 30 days CAN-SPAM requires.
+const unsubscribeTokenTTL = 60 * 24 * time.Hour
+
+// unsubscribeURLVariable is the template variable a marketing template can
+// use to place the unsubscribe link itself
+const unsubscribeURLVariable = "unsubscribe_url"
+
+// Unsubscribe token errors
+var (
+	ErrInvalidUnsubscribeToken = errors.New("invalid unsubscribe token")
+	ErrUnsubscribeTokenExpired = errors.New("unsubscribe token expired")
+)
+
+// UnsubscribeToken identifies who an unsubscribe link opts out, and of what
+type UnsubscribeToken struct {
+	ID           string
+	CustomerID   string
+	DealershipID string
+	Channel      string
+	ExpiresAt    time.Time
+}
+
+// unsubscribeSigner issues and verifies signed unsubscribe tokens
+type unsubscribeSigner struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// newUnsubscribeSigner creates a signer. An empty secret generates a random
+// one, so links only remain valid for the life of the process.
+func newUnsubscribeSigner(secret string, ttl time.Duration) (*unsubscribeSigner, error) {
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate unsubscribe secret: %w", err)
+		}
+	}
+	return &unsubscribeSigner{secret: key, ttl: ttl, now: time.Now}, nil
+}
+
+func (s *unsubscribeSigner) signature(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Issue returns a new single-use token opting the customer out of marketing
+// on the channel
+func (s *unsubscribeSigner) Issue(customerID, dealershipID, channel string) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate unsubscribe token: %w", err)
+	}
+	expires := s.now().Add(s.ttl).Unix()
+
+	payload := strings.Join([]string{hex.EncodeToString(id), customerID, dealershipID, channel, strconv.FormatInt(expires, 10)}, ":")
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + s.signature(payload), nil
+}
+
+// Parse verifies a token's signature and expiry and returns what it encodes
+func (s *unsubscribeSigner) Parse(token string) (*UnsubscribeToken, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+	payload := string(raw)
+	if !hmac.Equal([]byte(s.signature(payload)), []byte(signature)) {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+
+	parts := strings.Split(payload, ":")
+	if len(parts) != 5 || !unsubscribeChannels[parts[3]] {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+	expires, err := strconv.ParseInt(parts[4], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+	if s.now().Unix() > expires {
+		return nil, ErrUnsubscribeTokenExpired
+	}
+
+	return &UnsubscribeToken{
+		ID:           parts[0],
+		CustomerID:   parts[1],
+		DealershipID: parts[2],
+		Channel:      parts[3],
+		ExpiresAt:    time.Unix(expires, 0),
+	}, nil
+}
+
+// unsubscribeURL returns the public link for an unsubscribe token
+func (s *Server) unsubscribeURL(token string) string {
+	return strings.TrimRight(s.config.PublicBaseURL, "/") + "/api/v1/email/unsubscribe/" + token
+}
+
+// withUnsubscribeLink appends an unsubscribe footer to a rendered body,
+// unless the template already placed the link with {{unsubscribe_url}}
+func withUnsubscribeLink(bodyHTML, link string) string {
+	if strings.Contains(bodyHTML, link) {
+		return bodyHTML
+	}
+	return bodyHTML + fmt.Sprintf(`<p style="font-size:12px;color:#666666">Don't want these emails? <a href="%s">Unsubscribe</a></p>`, html.EscapeString(link))
+}
+
+// unsubscribeHeaders returns the List-Unsubscribe headers for a link, which
+// let mail clients offer one-click unsubscribe (RFC 8058)
+func unsubscribeHeaders(link string) map[string]string {
+	return map[string]string{
+		"List-Unsubscribe":      "<" + link + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+}
+
+// UnsubscribeHandler handles GET and POST /email/unsubscribe/{token}. It is
+// public, so the signed token is the only credential. The opt-out is
+// recorded with the consent service and the customer shown a confirmation.
+func (s *Server) UnsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := s.unsubscribe.Parse(mux.Vars(r)["token"])
+	if errors.Is(err, ErrUnsubscribeTokenExpired) {
+		respondUnsubscribePage(w, http.StatusGone, "This unsubscribe link has expired. Use the link in a more recent email, or contact the dealership.")
+		return
+	}
+	if err != nil {
+		respondUnsubscribePage(w, http.StatusBadRequest, "This unsubscribe link is not valid.")
+		return
+	}
+
+	log := s.logger.WithContext(r.Context()).
+		WithField("customer_id", token.CustomerID).
+		WithField("dealership_id", token.DealershipID)
+
+	claimed, err := s.db.ClaimUnsubscribeToken(token)
+	if err != nil {
+		log.WithError(err).Error("Failed to claim unsubscribe token")
+		respondUnsubscribePage(w, http.StatusInternalServerError, "We couldn't process your request. Please try again later.")
+		return
+	}
+	if !claimed {
+		respondUnsubscribePage(w, http.StatusOK, "You have already been unsubscribed.")
+		return
+	}
+
+	ip := r.Header.Get("X-Real-IP")
+	if ip == "" {
+		ip = r.RemoteAddr
+	}
+	err = s.consent.RecordMarketingOptOut(r.Context(), MarketingOptOut{
+		CustomerID:   token.CustomerID,
+		DealershipID: token.DealershipID,
+		Channel:      token.Channel,
+		IPAddress:    ip,
+		UserAgent:    r.UserAgent(),
+	})
+	if err != nil {
+		log.WithError(err).Error("Failed to record marketing opt-out")
+		// Let the customer retry the same link
+		if err := s.db.ReleaseUnsubscribeToken(token.ID); err != nil {
+			log.WithError(err).Error("Failed to release unsubscribe token")
+		}
+		respondUnsubscribePage(w, http.StatusBadGateway, "We couldn't process your request. Please try again later.")
+		return
+	}
+
+	log.WithField("channel", token.Channel).Info("Marketing opt-out recorded")
+	respondUnsubscribePage(w, http.StatusOK, "You have been unsubscribed and will no longer receive marketing emails from us.")
+}
+
+// respondUnsubscribePage writes a minimal HTML page for unsubscribe links,
+// which customers open in a browser
+func respondUnsubscribePage(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<!DOCTYPE html><html><head><meta charset="utf-8"><title>Unsubscribe</title></head><body><p>%s</p></body></html>`, html.EscapeString(message))
+}
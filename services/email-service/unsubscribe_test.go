@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	unsubscribeDealershipID = "33333333-3333-3333-3333-333333333333"
+	unsubscribeCustomerID   = "44444444-4444-4444-4444-444444444444"
+	marketingTemplateID     = "55555555-5555-5555-5555-555555555555"
+)
+
+// recordingConsentClient captures the opt-outs sent to the consent service
+type recordingConsentClient struct {
+	optOuts []MarketingOptOut
+	err     error
+}
+
+func (c *recordingConsentClient) RecordMarketingOptOut(ctx context.Context, optOut MarketingOptOut) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.optOuts = append(c.optOuts, optOut)
+	return nil
+}
+
+func setupUnsubscribeServer(t *testing.T) (*Server, *recordingConsentClient) {
+	t.Helper()
+
+	server := setupTestServer()
+	server.config.PublicBaseURL = "https://app.autolytiq.com/"
+	signer, err := newUnsubscribeSigner("test-secret", unsubscribeTokenTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.unsubscribe = signer
+	consent := &recordingConsentClient{}
+	server.consent = consent
+	server.router = mux.NewRouter()
+	server.router.HandleFunc("/email/unsubscribe/{token}", server.UnsubscribeHandler).Methods("GET", "POST")
+	return server, consent
+}
+
+func createTestTemplate(server *Server, category, bodyHTML string) {
+	server.db.CreateTemplate(&EmailTemplate{
+		ID:           marketingTemplateID,
+		DealershipID: unsubscribeDealershipID,
+		Name:         "Spring Sale",
+		Subject:      "Spring deals for {{customer_name}}",
+		BodyHTML:     bodyHTML,
+		Category:     category,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	})
+}
+
+func sendTestTemplate(server *Server, customerID string) *httptest.ResponseRecorder {
+	return sendJSON(server.SendTemplateEmailHandler, "/email/send-template", SendTemplateEmailRequest{
+		DealershipID: unsubscribeDealershipID,
+		To:           "customer@example.com",
+		TemplateID:   marketingTemplateID,
+		Variables:    map[string]string{"customer_name": "Jane"},
+		CustomerID:   customerID,
+	})
+}
+
+func TestMarketingSendInjectsUnsubscribeLink(t *testing.T) {
+	server, _ := setupUnsubscribeServer(t)
+	createTestTemplate(server, TemplateCategoryMarketing, "<p>Hi {{customer_name}}, save big this spring.</p>")
+
+	rr := sendTestTemplate(server, unsubscribeCustomerID)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	sent := server.smtpClient.(*MockSMTPClient).sentEmails
+	if len(sent) != 1 {
+		t.Fatalf("Expected 1 email sent, got %d", len(sent))
+	}
+
+	header := sent[0].Headers["List-Unsubscribe"]
+	prefix := "<https://app.autolytiq.com/api/v1/email/unsubscribe/"
+	if !strings.HasPrefix(header, prefix) || !strings.HasSuffix(header, ">") {
+		t.Fatalf("Expected a List-Unsubscribe header with the link, got %q", header)
+	}
+	if sent[0].Headers["List-Unsubscribe-Post"] != "List-Unsubscribe=One-Click" {
+		t.Errorf("Expected one-click unsubscribe, got %q", sent[0].Headers["List-Unsubscribe-Post"])
+	}
+
+	link := strings.Trim(header, "<>")
+	if !strings.Contains(sent[0].BodyHTML, `<a href="`+link+`">Unsubscribe</a>`) {
+		t.Errorf("Expected the unsubscribe link in the body, got %s", sent[0].BodyHTML)
+	}
+
+	token, err := server.unsubscribe.Parse(link[strings.LastIndex(link, "/")+1:])
+	if err != nil {
+		t.Fatalf("Expected a valid token, got %v", err)
+	}
+	if token.CustomerID != unsubscribeCustomerID || token.DealershipID != unsubscribeDealershipID || token.Channel != UnsubscribeChannelEmail {
+		t.Errorf("Unexpected token: %+v", token)
+	}
+}
+
+func TestMarketingTemplateCanPlaceUnsubscribeLink(t *testing.T) {
+	server, _ := setupUnsubscribeServer(t)
+	createTestTemplate(server, TemplateCategoryMarketing, `<p>Hi {{customer_name}}</p><footer><a href="{{unsubscribe_url}}">Opt out</a></footer>`)
+
+	if rr := sendTestTemplate(server, unsubscribeCustomerID); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	body := server.smtpClient.(*MockSMTPClient).sentEmails[0].BodyHTML
+	if strings.Contains(body, "{{unsubscribe_url}}") || strings.Count(body, "/api/v1/email/unsubscribe/") != 1 {
+		t.Errorf("Expected the template's own link and no footer, got %s", body)
+	}
+}
+
+func TestMarketingSendRequiresCustomer(t *testing.T) {
+	server, _ := setupUnsubscribeServer(t)
+	createTestTemplate(server, TemplateCategoryMarketing, "<p>Hi</p>")
+
+	if rr := sendTestTemplate(server, ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rr.Code)
+	}
+	if sent := server.smtpClient.(*MockSMTPClient).sentEmails; len(sent) != 0 {
+		t.Errorf("Expected nothing sent, got %d emails", len(sent))
+	}
+}
+
+func TestTransactionalSendSkipsUnsubscribeLink(t *testing.T) {
+	server, _ := setupUnsubscribeServer(t)
+	createTestTemplate(server, TemplateCategoryTransactional, "<p>Your payment was received.</p>")
+
+	if rr := sendTestTemplate(server, unsubscribeCustomerID); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	sent := server.smtpClient.(*MockSMTPClient).sentEmails[0]
+	if sent.BodyHTML != "<p>Your payment was received.</p>" || len(sent.Headers) != 0 {
+		t.Errorf("Expected no unsubscribe link or headers, got %q %v", sent.BodyHTML, sent.Headers)
+	}
+}
+
+func unsubscribe(server *Server, method, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/email/unsubscribe/"+token, nil)
+	req.Header.Set("X-Real-IP", "203.0.113.7")
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestUnsubscribeRecordsOptOut(t *testing.T) {
+	server, consent := setupUnsubscribeServer(t)
+	token, _ := server.unsubscribe.Issue(unsubscribeCustomerID, unsubscribeDealershipID, UnsubscribeChannelEmail)
+
+	rr := unsubscribe(server, "GET", token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/html") || !strings.Contains(rr.Body.String(), "You have been unsubscribed") {
+		t.Errorf("Expected an HTML confirmation, got %s", rr.Body.String())
+	}
+
+	if len(consent.optOuts) != 1 {
+		t.Fatalf("Expected 1 opt-out recorded, got %d", len(consent.optOuts))
+	}
+	optOut := consent.optOuts[0]
+	if optOut.CustomerID != unsubscribeCustomerID || optOut.DealershipID != unsubscribeDealershipID ||
+		optOut.Channel != UnsubscribeChannelEmail || optOut.IPAddress != "203.0.113.7" {
+		t.Errorf("Unexpected opt-out: %+v", optOut)
+	}
+
+	// The link is single-use
+	rr = unsubscribe(server, "POST", token)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "already been unsubscribed") {
+		t.Errorf("Expected the already unsubscribed page, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(consent.optOuts) != 1 {
+		t.Errorf("Expected a reused link not to record another opt-out, got %d", len(consent.optOuts))
+	}
+}
+
+func TestUnsubscribeRejectsBadTokens(t *testing.T) {
+	server, consent := setupUnsubscribeServer(t)
+	token, _ := server.unsubscribe.Issue(unsubscribeCustomerID, unsubscribeDealershipID, UnsubscribeChannelEmail)
+
+	// Alter the payload without re-signing it
+	encoded, signature, _ := strings.Cut(token, ".")
+	tampered := strings.Replace(encoded, encoded[:8], "AAAAAAAA", 1) + "." + signature
+	if rr := unsubscribe(server, "GET", tampered); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected a tampered token to be rejected, got %d", rr.Code)
+	}
+	if rr := unsubscribe(server, "GET", "not-a-token"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected a malformed token to be rejected, got %d", rr.Code)
+	}
+
+	server.unsubscribe.now = func() time.Time { return time.Now().Add(unsubscribeTokenTTL + time.Hour) }
+	if rr := unsubscribe(server, "GET", token); rr.Code != http.StatusGone {
+		t.Errorf("Expected an expired token to be rejected, got %d", rr.Code)
+	}
+
+	if len(consent.optOuts) != 0 {
+		t.Errorf("Expected no opt-outs recorded, got %d", len(consent.optOuts))
+	}
+}
+
+func TestUnsubscribeCanRetryAfterConsentFailure(t *testing.T) {
+	server, consent := setupUnsubscribeServer(t)
+	token, _ := server.unsubscribe.Issue(unsubscribeCustomerID, unsubscribeDealershipID, UnsubscribeChannelEmail)
+
+	consent.err = errors.New("consent service unavailable")
+	if rr := unsubscribe(server, "GET", token); rr.Code != http.StatusBadGateway {
+		t.Fatalf("Expected status 502, got %d", rr.Code)
+	}
+
+	consent.err = nil
+	if rr := unsubscribe(server, "GET", token); rr.Code != http.StatusOK || len(consent.optOuts) != 1 {
+		t.Errorf("Expected the retry to record the opt-out, got %d with %d opt-outs", rr.Code, len(consent.optOuts))
+	}
+}
+
+func TestHTTPConsentClientWithdrawsChannelConsent(t *testing.T) {
+	var gotPath, gotQuery, gotIP, gotUser string
+	var gotBody map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotQuery = r.Method+" "+r.URL.Path, r.URL.RawQuery
+		gotIP, gotUser = r.Header.Get("X-Real-IP"), r.Header.Get("X-User-ID")
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &gotBody)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	err := NewHTTPConsentClient(ts.URL).RecordMarketingOptOut(context.Background(), MarketingOptOut{
+		CustomerID:   unsubscribeCustomerID,
+		DealershipID: unsubscribeDealershipID,
+		Channel:      UnsubscribeChannelEmail,
+		IPAddress:    "203.0.113.7",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gotPath != "PUT /consent/"+unsubscribeCustomerID || gotQuery != "dealership_id="+unsubscribeDealershipID {
+		t.Errorf("Unexpected request %s?%s", gotPath, gotQuery)
+	}
+	if gotBody["marketing_email"] != false || gotBody["source"] != "unsubscribe" {
+		t.Errorf("Expected email marketing consent withdrawn via unsubscribe, got %v", gotBody)
+	}
+	if _, ok := gotBody["marketing_sms"]; ok {
+		t.Errorf("Expected other channels untouched, got %v", gotBody)
+	}
+	if gotIP != "203.0.113.7" || gotUser != "customer_self_service" {
+		t.Errorf("Expected the customer's request details, got %q %q", gotIP, gotUser)
+	}
+}
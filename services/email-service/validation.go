@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
+
+	"autolytiq/shared/bodylimit"
 )
 
 // ValidationError represents a single validation error
@@ -142,6 +144,14 @@ func (r *SendTemplateEmailRequest) Validate() *ValidationErrors {
 		})
 	}
 
+	// Customer ID validation (optional, required for marketing templates)
+	if r.CustomerID != "" && !uuidRegex.MatchString(r.CustomerID) {
+		errors = append(errors, ValidationError{
+			Field:   "customer_id",
+			Message: "Must be a valid UUID",
+		})
+	}
+
 	if len(errors) > 0 {
 		return &ValidationErrors{Errors: errors}
 	}
@@ -153,6 +163,7 @@ func (r *SendTemplateEmailRequest) Sanitize() {
 	r.DealershipID = strings.TrimSpace(r.DealershipID)
 	r.To = strings.TrimSpace(strings.ToLower(r.To))
 	r.TemplateID = strings.TrimSpace(r.TemplateID)
+	r.CustomerID = strings.TrimSpace(r.CustomerID)
 }
 
 // Validate validates CreateTemplateRequest
@@ -211,6 +222,12 @@ func (r *CreateTemplateRequest) Validate() *ValidationErrors {
 		})
 	}
 
+	// A/B variant validation (optional)
+	errors = append(errors, validateVariants(r.Variants)...)
+
+	// Category validation (optional)
+	errors = append(errors, validateTemplateCategory(r.Category)...)
+
 	if len(errors) > 0 {
 		return &ValidationErrors{Errors: errors}
 	}
@@ -222,6 +239,7 @@ func (r *CreateTemplateRequest) Sanitize() {
 	r.DealershipID = strings.TrimSpace(r.DealershipID)
 	r.Name = strings.TrimSpace(r.Name)
 	r.Subject = strings.TrimSpace(r.Subject)
+	r.Category = strings.TrimSpace(strings.ToLower(r.Category))
 }
 
 // Validate validates UpdateTemplateRequest
@@ -267,6 +285,12 @@ func (r *UpdateTemplateRequest) Validate() *ValidationErrors {
 		})
 	}
 
+	// A/B variant validation (optional)
+	errors = append(errors, validateVariants(r.Variants)...)
+
+	// Category validation (optional)
+	errors = append(errors, validateTemplateCategory(r.Category)...)
+
 	if len(errors) > 0 {
 		return &ValidationErrors{Errors: errors}
 	}
@@ -277,6 +301,35 @@ func (r *UpdateTemplateRequest) Validate() *ValidationErrors {
 func (r *UpdateTemplateRequest) Sanitize() {
 	r.Name = strings.TrimSpace(r.Name)
 	r.Subject = strings.TrimSpace(r.Subject)
+	r.Category = strings.TrimSpace(strings.ToLower(r.Category))
+}
+
+// validateTemplateCategory checks an optional template category
+func validateTemplateCategory(category string) []ValidationError {
+	if category == "" || category == TemplateCategoryTransactional || category == TemplateCategoryMarketing {
+		return nil
+	}
+	return []ValidationError{{
+		Field:   "category",
+		Message: "Category must be transactional or marketing",
+	}}
+}
+
+// Validate validates LogEventRequest
+func (r *LogEventRequest) Validate() *ValidationErrors {
+	if r.Event != LogEventOpen && r.Event != LogEventClick && r.Event != LogEventBounce && r.Event != LogEventComplaint {
+		return &ValidationErrors{Errors: []ValidationError{{
+			Field:   "event",
+			Message: "Event must be 'open', 'click', 'bounce' or 'complaint'",
+		}}}
+	}
+	if r.BounceType != "" && (r.Event != LogEventBounce || (r.BounceType != BounceTypeHard && r.BounceType != "soft")) {
+		return &ValidationErrors{Errors: []ValidationError{{
+			Field:   "bounce_type",
+			Message: "Bounce type must be 'hard' or 'soft', and only given for bounces",
+		}}}
+	}
+	return nil
 }
 
 // respondValidationError writes a validation error response
@@ -313,6 +366,10 @@ type Sanitizable interface {
 // decodeAndValidate decodes JSON and validates the request
 func decodeAndValidate(r *http.Request, w http.ResponseWriter, dst interface{}) bool {
 	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		if bodylimit.IsTooLarge(err) {
+			bodylimit.RespondTooLarge(w)
+			return false
+		}
 		respondErrorJSON(w, http.StatusBadRequest, "Invalid request body: "+err.Error(), "INVALID_JSON")
 		return false
 	}
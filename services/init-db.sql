@@ -63,6 +63,7 @@ CREATE TABLE IF NOT EXISTS customers (
     credit_score INTEGER,
     notes TEXT,
     source VARCHAR(100),
+    region VARCHAR(10), -- data residency region; NULL uses the dealership's data_region setting
     created_at TIMESTAMP NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMP NOT NULL DEFAULT NOW()
 );
@@ -109,6 +110,7 @@ CREATE TABLE IF NOT EXISTS deals (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     dealership_id UUID NOT NULL REFERENCES dealerships(id),
     customer_id UUID NOT NULL REFERENCES customers(id),
+    co_customer_id UUID REFERENCES customers(id),
     vehicle_id UUID NOT NULL REFERENCES vehicles(id),
     salesperson_id UUID REFERENCES auth_users(id),
     type VARCHAR(20) NOT NULL DEFAULT 'CASH',
@@ -124,13 +126,17 @@ CREATE TABLE IF NOT EXISTS deals (
     fees DECIMAL(12, 2),
     total_price DECIMAL(12, 2),
     notes TEXT,
+    lost_reason VARCHAR(50),
+    cancelled_at TIMESTAMP,
     closed_at TIMESTAMP,
+    version INTEGER NOT NULL DEFAULT 1,
     created_at TIMESTAMP NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMP NOT NULL DEFAULT NOW()
 );
 
 CREATE INDEX IF NOT EXISTS idx_deals_dealership ON deals(dealership_id);
 CREATE INDEX IF NOT EXISTS idx_deals_customer ON deals(customer_id);
+CREATE INDEX IF NOT EXISTS idx_deals_co_customer ON deals(co_customer_id) WHERE co_customer_id IS NOT NULL;
 CREATE INDEX IF NOT EXISTS idx_deals_vehicle ON deals(vehicle_id);
 CREATE INDEX IF NOT EXISTS idx_deals_status ON deals(status);
 
@@ -218,7 +224,9 @@ CREATE TABLE IF NOT EXISTS showroom_visits (
     workflow_stage INTEGER DEFAULT 0,
 
     source VARCHAR(50),
+    campaign VARCHAR(100),
     appointment_id UUID,
+    lost_reason VARCHAR(50),
 
     created_at TIMESTAMP NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMP NOT NULL DEFAULT NOW()
@@ -228,6 +236,7 @@ CREATE INDEX IF NOT EXISTS idx_showroom_visits_dealership ON showroom_visits(dea
 CREATE INDEX IF NOT EXISTS idx_showroom_visits_customer ON showroom_visits(customer_id);
 CREATE INDEX IF NOT EXISTS idx_showroom_visits_status ON showroom_visits(status);
 CREATE INDEX IF NOT EXISTS idx_showroom_visits_checkin ON showroom_visits(check_in_time);
+CREATE INDEX IF NOT EXISTS idx_showroom_visits_source ON showroom_visits(dealership_id, source, campaign);
 
 -- ===========================================
 -- SHOWROOM: Activity Timers
@@ -236,6 +245,7 @@ CREATE TABLE IF NOT EXISTS visit_timers (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     visit_id UUID NOT NULL REFERENCES showroom_visits(id) ON DELETE CASCADE,
     timer_type VARCHAR(50) NOT NULL,
+    category VARCHAR(50),
     start_time TIMESTAMP NOT NULL DEFAULT NOW(),
     end_time TIMESTAMP,
     duration_seconds INTEGER,
@@ -245,6 +255,8 @@ CREATE TABLE IF NOT EXISTS visit_timers (
 
 CREATE INDEX IF NOT EXISTS idx_visit_timers_visit ON visit_timers(visit_id);
 CREATE INDEX IF NOT EXISTS idx_visit_timers_type ON visit_timers(timer_type);
+-- One running timer per category per visit
+CREATE UNIQUE INDEX IF NOT EXISTS idx_visit_timers_active_category ON visit_timers(visit_id, category) WHERE end_time IS NULL;
 
 -- ===========================================
 -- SHOWROOM: Visit Events (Audit Trail)
@@ -279,6 +291,18 @@ CREATE TABLE IF NOT EXISTS visit_notes (
 CREATE INDEX IF NOT EXISTS idx_visit_notes_visit ON visit_notes(visit_id);
 CREATE INDEX IF NOT EXISTS idx_visit_notes_creator ON visit_notes(created_by_id);
 
+CREATE TABLE IF NOT EXISTS note_mentions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    note_id UUID NOT NULL REFERENCES visit_notes(id) ON DELETE CASCADE,
+    visit_id UUID NOT NULL REFERENCES showroom_visits(id) ON DELETE CASCADE,
+    dealership_id UUID NOT NULL,
+    mentioned_user_id UUID NOT NULL REFERENCES auth_users(id),
+    mentioned_by_id UUID NOT NULL REFERENCES auth_users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_note_mentions_user ON note_mentions(dealership_id, mentioned_user_id, created_at DESC);
+
 -- ===========================================
 -- USER SETTINGS (Personal preferences)
 -- ===========================================
@@ -446,6 +470,8 @@ CREATE TABLE IF NOT EXISTS messaging_participants (
 
 CREATE INDEX IF NOT EXISTS idx_messaging_participants_conversation ON messaging_participants(conversation_id);
 CREATE INDEX IF NOT EXISTS idx_messaging_participants_user ON messaging_participants(user_id);
+-- Participant filters on conversation lists look up (user, conversation) pairs
+CREATE INDEX IF NOT EXISTS idx_messaging_participants_user_conversation ON messaging_participants(user_id, conversation_id);
 
 -- ===========================================
 -- MESSAGING: Messages
@@ -467,6 +493,7 @@ CREATE TABLE IF NOT EXISTS messaging_messages (
     is_ephemeral BOOLEAN NOT NULL DEFAULT false,
     ephemeral_seconds INTEGER,
     ephemeral_expires_at TIMESTAMP,
+    scheduled_at TIMESTAMP,
     link_preview JSONB,
     created_at TIMESTAMP NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMP NOT NULL DEFAULT NOW()
@@ -475,6 +502,7 @@ CREATE TABLE IF NOT EXISTS messaging_messages (
 CREATE INDEX IF NOT EXISTS idx_messaging_messages_conversation ON messaging_messages(conversation_id);
 CREATE INDEX IF NOT EXISTS idx_messaging_messages_sender ON messaging_messages(sender_id);
 CREATE INDEX IF NOT EXISTS idx_messaging_messages_created ON messaging_messages(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_messaging_messages_scheduled ON messaging_messages(scheduled_at) WHERE status = 'SCHEDULED';
 
 -- ===========================================
 -- MESSAGING: Reactions
@@ -511,6 +539,64 @@ CREATE TABLE IF NOT EXISTS messaging_attachments (
 
 CREATE INDEX IF NOT EXISTS idx_messaging_attachments_message ON messaging_attachments(message_id);
 
+-- ===========================================
+-- MESSAGING: Conversation Tags
+-- ===========================================
+CREATE TABLE IF NOT EXISTS messaging_tags (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    dealership_id UUID NOT NULL REFERENCES dealerships(id),
+    name VARCHAR(50) NOT NULL,
+    color VARCHAR(7),
+    created_by_id UUID REFERENCES auth_users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messaging_tags_name ON messaging_tags(dealership_id, LOWER(name));
+
+CREATE TABLE IF NOT EXISTS messaging_conversation_tags (
+    conversation_id UUID NOT NULL REFERENCES messaging_conversations(id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES messaging_tags(id) ON DELETE CASCADE,
+    tagged_by_id UUID REFERENCES auth_users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (conversation_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_messaging_conversation_tags_tag ON messaging_conversation_tags(tag_id);
+
+-- ===========================================
+-- MESSAGING: User Preferences
+-- ===========================================
+CREATE TABLE IF NOT EXISTS messaging_user_preferences (
+    dealership_id UUID NOT NULL REFERENCES dealerships(id),
+    user_id UUID NOT NULL REFERENCES auth_users(id),
+    send_read_receipts BOOLEAN NOT NULL DEFAULT true,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (dealership_id, user_id)
+);
+
+-- ===========================================
+-- MESSAGING: Announcements
+-- ===========================================
+CREATE TABLE IF NOT EXISTS messaging_announcements (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    dealership_id UUID NOT NULL REFERENCES dealerships(id),
+    sender_id UUID NOT NULL REFERENCES auth_users(id),
+    content TEXT NOT NULL,
+    target_roles TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_messaging_announcements_dealership ON messaging_announcements(dealership_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS messaging_announcement_recipients (
+    announcement_id UUID NOT NULL REFERENCES messaging_announcements(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    read_at TIMESTAMP,
+    PRIMARY KEY (announcement_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_messaging_announcement_recipients_user ON messaging_announcement_recipients(user_id);
+
 -- Log completion
 DO $$
 BEGIN
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"autolytiq/shared/apierror"
+)
+
+// agingBucketRange is a days-in-inventory range of the aging report. MaxDays
+// is 0 for the open-ended last bucket.
+type agingBucketRange struct {
+	Label   string
+	MinDays int
+	MaxDays int
+}
+
+// agingBuckets are the aging report's buckets, youngest first
+var agingBuckets = []agingBucketRange{
+	{Label: "0-30", MinDays: 0, MaxDays: 30},
+	{Label: "31-60", MinDays: 31, MaxDays: 60},
+	{Label: "61-90", MinDays: 61, MaxDays: 90},
+	{Label: "90+", MinDays: 91},
+}
+
+// AgingBucket is the available vehicles that have been in inventory for a
+// range of days, with the oldest of them
+type AgingBucket struct {
+	Bucket     string  `json:"bucket"`
+	MinDays    int     `json:"min_days"`
+	MaxDays    *int    `json:"max_days"` // null for the last bucket
+	Count      int     `json:"count"`
+	TotalValue float64 `json:"total_value"`
+
+	OldestVehicleID   string `json:"oldest_vehicle_id,omitempty"`
+	OldestStockNumber string `json:"oldest_stock_number,omitempty"`
+	OldestDays        int    `json:"oldest_days,omitempty"`
+}
+
+// AgingReport is the response for the inventory aging report
+type AgingReport struct {
+	DealershipID string         `json:"dealership_id,omitempty"`
+	Buckets      []*AgingBucket `json:"buckets"`
+	TotalCount   int            `json:"total_count"`
+	TotalValue   float64        `json:"total_value"`
+}
+
+// agingBucketCase returns a SQL CASE expression labelling a days column
+// with its aging bucket
+func agingBucketCase(days string) string {
+	var b strings.Builder
+	b.WriteString("CASE")
+	for _, bucket := range agingBuckets {
+		if bucket.MaxDays == 0 {
+			fmt.Fprintf(&b, " ELSE '%s'", bucket.Label)
+		} else {
+			fmt.Fprintf(&b, " WHEN %s <= %d THEN '%s'", days, bucket.MaxDays, bucket.Label)
+		}
+	}
+	b.WriteString(" END")
+	return b.String()
+}
+
+// newAgingBuckets returns an empty bucket for each aging range, in order
+func newAgingBuckets() []*AgingBucket {
+	buckets := make([]*AgingBucket, len(agingBuckets))
+	for i, bucket := range agingBuckets {
+		buckets[i] = &AgingBucket{Bucket: bucket.Label, MinDays: bucket.MinDays}
+		if bucket.MaxDays != 0 {
+			maxDays := bucket.MaxDays
+			buckets[i].MaxDays = &maxDays
+		}
+	}
+	return buckets
+}
+
+// getAgingReport groups available vehicles by how long they have been in
+// inventory, counted from when they were created, optionally for one
+// dealership
+func (s *Server) getAgingReport(w http.ResponseWriter, r *http.Request) {
+	dealershipID := r.URL.Query().Get("dealership_id")
+	if dealershipID != "" && !validateUUID(w, dealershipID, "dealership_id") {
+		return
+	}
+
+	buckets, err := s.db.GetAgingReport(dealershipID)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get aging report")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get aging report: %v", err))
+		return
+	}
+
+	report := AgingReport{DealershipID: dealershipID, Buckets: buckets}
+	for _, bucket := range buckets {
+		report.TotalCount += bucket.Count
+		report.TotalValue += bucket.TotalValue
+	}
+	report.TotalValue = math.Round(report.TotalValue*100) / 100
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestAgingBucketCase(t *testing.T) {
+	expected := "CASE WHEN days <= 30 THEN '0-30' WHEN days <= 60 THEN '31-60' WHEN days <= 90 THEN '61-90' ELSE '90+' END"
+	if got := agingBucketCase("days"); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+func TestGetAgingReport(t *testing.T) {
+	server := setupTestServer()
+	dealershipID := uuid.New().String()
+	add := func(dealershipID, stockNumber, status string, days int, price float64) {
+		server.db.CreateVehicle(&Vehicle{
+			ID:           uuid.New().String(),
+			DealershipID: dealershipID,
+			StockNumber:  stockNumber,
+			Status:       status,
+			Price:        price,
+			CreatedAt:    time.Now().AddDate(0, 0, -days).Format(time.RFC3339),
+		})
+	}
+	add(dealershipID, "A1", "available", 5, 20000)
+	add(dealershipID, "A2", "available", 30, 15000.50)
+	add(dealershipID, "B1", "available", 45, 18000)
+	add(dealershipID, "D1", "available", 91, 9000)
+	add(dealershipID, "D2", "available", 200, 7000)
+	add(dealershipID, "S1", "sold", 120, 30000)
+	add(uuid.New().String(), "X1", "available", 100, 12000)
+
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("GET", "/vehicles/aging?dealership_id="+dealershipID, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var report AgingReport
+	json.Unmarshal(rr.Body.Bytes(), &report)
+
+	expected := []struct {
+		bucket string
+		count  int
+		value  float64
+		oldest string
+	}{
+		{"0-30", 2, 35000.50, "A2"},
+		{"31-60", 1, 18000, "B1"},
+		{"61-90", 0, 0, ""},
+		{"90+", 2, 16000, "D2"},
+	}
+	if len(report.Buckets) != len(expected) {
+		t.Fatalf("Expected %d buckets, got %+v", len(expected), report.Buckets)
+	}
+	for i, e := range expected {
+		b := report.Buckets[i]
+		if b.Bucket != e.bucket || b.Count != e.count || b.TotalValue != e.value || b.OldestStockNumber != e.oldest {
+			t.Errorf("Bucket %d: expected %+v, got %+v", i, e, b)
+		}
+	}
+	if report.Buckets[3].MaxDays != nil || report.Buckets[2].MaxDays == nil || *report.Buckets[2].MaxDays != 90 {
+		t.Errorf("Expected only the last bucket to be open-ended, got %+v", report.Buckets)
+	}
+	if report.TotalCount != 5 || report.TotalValue != 69000.50 {
+		t.Errorf("Unexpected totals: %d vehicles, %v", report.TotalCount, report.TotalValue)
+	}
+
+	// Without a dealership every dealership's available vehicles are counted
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("GET", "/vehicles/aging", nil))
+	json.Unmarshal(rr.Body.Bytes(), &report)
+	if report.TotalCount != 6 {
+		t.Errorf("Expected 6 vehicles across dealerships, got %d", report.TotalCount)
+	}
+
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("GET", "/vehicles/aging?dealership_id=nope", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid dealership ID, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/bodylimit"
+)
+
+// maxBulkVehicles is the most vehicles one bulk import may create. The body
+// is capped by the bulk limit of bodylimit.Middleware.
+const maxBulkVehicles = 1000
+
+// Bulk vehicle import row statuses
+const (
+	BulkRowCreated = "created"
+	BulkRowFailed  = "failed"
+	// BulkRowSkipped is a valid row that was not saved because the import
+	// was all or nothing and another row failed
+	BulkRowSkipped = "skipped"
+)
+
+// BulkVehicleRowResult reports what happened to one row of a bulk import
+type BulkVehicleRowResult struct {
+	Index     int    `json:"index"`
+	Status    string `json:"status"`
+	VehicleID string `json:"vehicle_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// BulkVehicleImportResult is the response to a bulk vehicle import
+type BulkVehicleImportResult struct {
+	Created    int                    `json:"created"`
+	Failed     int                    `json:"failed"`
+	RolledBack bool                   `json:"rolled_back"`
+	Results    []BulkVehicleRowResult `json:"results"`
+}
+
+// csvVehicleColumns are the columns a CSV import may have, named after the
+// JSON fields of CreateVehicleRequest
+var csvVehicleColumns = map[string]bool{
+	"dealership_id": true,
+	"vin":           true,
+	"make":          true,
+	"model":         true,
+	"year":          true,
+	"condition":     true,
+	"status":        true,
+	"price":         true,
+	"mileage":       true,
+	"color":         true,
+	"description":   true,
+	"stock_number":  true,
+	"cost":          true,
+}
+
+// parseBulkVehiclesJSON reads a JSON array of vehicles. A row that doesn't
+// decode gets an error at its index rather than failing the whole body.
+func parseBulkVehiclesJSON(body io.Reader) ([]CreateVehicleRequest, []error, error) {
+	var rows []json.RawMessage
+	if err := json.NewDecoder(body).Decode(&rows); err != nil {
+		return nil, nil, err
+	}
+
+	reqs := make([]CreateVehicleRequest, len(rows))
+	errs := make([]error, len(rows))
+	for i, row := range rows {
+		errs[i] = json.Unmarshal(row, &reqs[i])
+	}
+	return reqs, errs, nil
+}
+
+// parseBulkVehiclesCSV reads vehicles from a CSV with a header row. A row
+// with a value that doesn't parse gets an error at its index.
+func parseBulkVehiclesCSV(body io.Reader) ([]CreateVehicleRequest, []error, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+		if !csvVehicleColumns[header[i]] {
+			return nil, nil, fmt.Errorf("unknown column %q", column)
+		}
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reqs := make([]CreateVehicleRequest, len(records))
+	errs := make([]error, len(records))
+	for i, record := range records {
+		if len(record) > len(header) {
+			errs[i] = fmt.Errorf("row has %d fields, the header has %d", len(record), len(header))
+			continue
+		}
+		for j, value := range record {
+			if err := setCSVVehicleField(&reqs[i], header[j], value); err != nil {
+				errs[i] = err
+				break
+			}
+		}
+	}
+	return reqs, errs, nil
+}
+
+// setCSVVehicleField sets the field of req for a CSV column. Empty numbers
+// are left unset.
+func setCSVVehicleField(req *CreateVehicleRequest, column, value string) error {
+	value = strings.TrimSpace(value)
+
+	switch column {
+	case "dealership_id":
+		req.DealershipID = value
+	case "vin":
+		req.VIN = value
+	case "make":
+		req.Make = value
+	case "model":
+		req.Model = value
+	case "condition":
+		req.Condition = value
+	case "status":
+		req.Status = value
+	case "color":
+		req.Color = value
+	case "description":
+		req.Description = value
+	case "stock_number":
+		req.StockNumber = value
+	default:
+		if value == "" {
+			return nil
+		}
+		switch column {
+		case "year", "mileage":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s: %q is not a whole number", column, value)
+			}
+			if column == "year" {
+				req.Year = n
+			} else {
+				req.Mileage = n
+			}
+		case "price", "cost":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("%s: %q is not a number", column, value)
+			}
+			if column == "price" {
+				req.Price = n
+			} else {
+				req.Cost = &n
+			}
+		}
+	}
+	return nil
+}
+
+// bulkCreateVehicles imports up to maxBulkVehicles vehicles from a JSON array
+// or, with Content-Type: text/csv, a CSV. Each row is validated like a single
+// create and a result is returned for every row. Rows that fail are reported
+// and the rest saved, unless all_or_nothing=true, in which case any failure
+// saves nothing.
+func (s *Server) bulkCreateVehicles(w http.ResponseWriter, r *http.Request) {
+	var reqs []CreateVehicleRequest
+	var rowErrs []error
+	var err error
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		reqs, rowErrs, err = parseBulkVehiclesCSV(r.Body)
+	} else {
+		reqs, rowErrs, err = parseBulkVehiclesJSON(r.Body)
+	}
+	switch {
+	case bodylimit.IsTooLarge(err):
+		bodylimit.RespondTooLarge(w)
+		return
+	case err != nil && mediaType == "text/csv":
+		respondErrorJSON(w, http.StatusBadRequest, "Invalid CSV: "+err.Error(), "INVALID_CSV")
+		return
+	case err != nil:
+		respondErrorJSON(w, http.StatusBadRequest, "Invalid request body: "+err.Error(), "INVALID_JSON")
+		return
+	}
+
+	if len(reqs) == 0 {
+		respondErrorJSON(w, http.StatusBadRequest, "At least one vehicle is required", "EMPTY_BATCH")
+		return
+	}
+	if len(reqs) > maxBulkVehicles {
+		respondErrorJSON(w, http.StatusBadRequest, fmt.Sprintf("A bulk import is limited to %d vehicles, got %d", maxBulkVehicles, len(reqs)), "BATCH_TOO_LARGE")
+		return
+	}
+	allOrNothing := r.URL.Query().Get("all_or_nothing") == "true"
+
+	result := BulkVehicleImportResult{Results: make([]BulkVehicleRowResult, len(reqs))}
+	var vehicles []*Vehicle
+	var indexes []int
+	for i := range reqs {
+		result.Results[i] = BulkVehicleRowResult{Index: i}
+
+		if rowErrs[i] == nil {
+			reqs[i].Sanitize()
+			if errs := reqs[i].Validate(); errs != nil {
+				rowErrs[i] = errs
+			} else if reqs[i].Cost != nil && !hasScope(r, ScopeInventoryCost) {
+				rowErrs[i] = errors.New("setting cost requires the " + ScopeInventoryCost + " scope")
+			}
+		}
+		if rowErrs[i] != nil {
+			result.Results[i].Status = BulkRowFailed
+			result.Results[i].Error = rowErrs[i].Error()
+			continue
+		}
+
+		vehicle := s.newVehicle(&reqs[i])
+		vehicles = append(vehicles, &vehicle)
+		indexes = append(indexes, i)
+	}
+
+	rolledBack := allOrNothing && len(vehicles) < len(reqs)
+	if !rolledBack && len(vehicles) > 0 {
+		saveErrs, err := s.db.BulkCreateVehicles(vehicles, allOrNothing)
+		if err != nil {
+			s.logger.WithContext(r.Context()).WithError(err).Error("Failed to import vehicles")
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to import vehicles: %v", err))
+			return
+		}
+		for j, saveErr := range saveErrs {
+			if saveErr != nil {
+				result.Results[indexes[j]].Status = BulkRowFailed
+				result.Results[indexes[j]].Error = saveErr.Error()
+				rolledBack = allOrNothing
+			}
+		}
+	}
+
+	for j, vehicle := range vehicles {
+		row := &result.Results[indexes[j]]
+		if row.Status == BulkRowFailed {
+			continue
+		}
+		if rolledBack {
+			row.Status = BulkRowSkipped
+			continue
+		}
+		row.Status = BulkRowCreated
+		row.VehicleID = vehicle.ID
+		s.publishVehicleEvents(nil, vehicle)
+	}
+	for _, row := range result.Results {
+		switch row.Status {
+		case BulkRowCreated:
+			result.Created++
+		case BulkRowFailed:
+			result.Failed++
+		}
+	}
+	result.RolledBack = rolledBack
+
+	s.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
+		"created":     result.Created,
+		"failed":      result.Failed,
+		"rolled_back": result.RolledBack,
+	}).Info("Bulk vehicle import finished")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
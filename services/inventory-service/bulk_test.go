@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func sendBulkVehicles(t *testing.T, server *Server, query, contentType, body string) (*httptest.ResponseRecorder, BulkVehicleImportResult) {
+	t.Helper()
+
+	req := httptest.NewRequest("POST", "/vehicles/bulk"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	var result BulkVehicleImportResult
+	json.Unmarshal(rr.Body.Bytes(), &result)
+	return rr, result
+}
+
+func bulkVehiclesJSON(t *testing.T, reqs ...interface{}) string {
+	t.Helper()
+	body, err := json.Marshal(reqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestBulkCreateVehicles(t *testing.T) {
+	server := setupTestServer()
+	mockDB := server.db.(*MockDatabase)
+	dealershipID := uuid.New().String()
+	vehicle := func(vin string) CreateVehicleRequest {
+		return CreateVehicleRequest{DealershipID: dealershipID, VIN: vin, Make: "Honda", Model: "Accord", Year: 2003, Price: 5500}
+	}
+
+	body := bulkVehiclesJSON(t,
+		vehicle("1HGCM82633A004352"),
+		CreateVehicleRequest{DealershipID: dealershipID, Make: "Honda"},
+		map[string]interface{}{"dealership_id": dealershipID, "make": "Honda", "year": "2003"},
+		vehicle("1M8GDM9AXKP042788"),
+		vehicle("1HGCM82633A004352"),
+	)
+	rr, result := sendBulkVehicles(t, server, "", "application/json", body)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	expected := []string{BulkRowCreated, BulkRowFailed, BulkRowFailed, BulkRowCreated, BulkRowFailed}
+	for i, status := range expected {
+		row := result.Results[i]
+		if row.Index != i || row.Status != status {
+			t.Errorf("Row %d: expected %s, got %+v", i, status, row)
+		}
+		if (status == BulkRowCreated) != (row.VehicleID != "" && row.Error == "") {
+			t.Errorf("Row %d: expected a vehicle ID only for created rows, got %+v", i, row)
+		}
+	}
+	if !strings.Contains(result.Results[1].Error, "Model is required") || !strings.Contains(result.Results[4].Error, "duplicate VIN") {
+		t.Errorf("Expected the validation and insert errors to be reported, got %+v", result.Results)
+	}
+	if result.Created != 2 || result.Failed != 3 || result.RolledBack {
+		t.Errorf("Unexpected totals: %+v", result)
+	}
+	if len(mockDB.vehicles) != 2 {
+		t.Errorf("Expected 2 vehicles saved, got %d", len(mockDB.vehicles))
+	}
+}
+
+func TestBulkCreateVehiclesAllOrNothing(t *testing.T) {
+	server := setupTestServer()
+	mockDB := server.db.(*MockDatabase)
+	dealershipID := uuid.New().String()
+	vehicle := func(vin string) CreateVehicleRequest {
+		return CreateVehicleRequest{DealershipID: dealershipID, VIN: vin, Make: "Honda", Model: "Accord", Year: 2003, Price: 5500}
+	}
+
+	// A row failing validation saves nothing
+	_, result := sendBulkVehicles(t, server, "?all_or_nothing=true", "application/json", bulkVehiclesJSON(t,
+		vehicle("1HGCM82633A004352"),
+		CreateVehicleRequest{DealershipID: dealershipID},
+	))
+	if !result.RolledBack || result.Created != 0 || result.Failed != 1 || result.Results[0].Status != BulkRowSkipped {
+		t.Errorf("Expected the import to be rolled back, got %+v", result)
+	}
+
+	// So does a row failing to insert
+	_, result = sendBulkVehicles(t, server, "?all_or_nothing=true", "application/json", bulkVehiclesJSON(t,
+		vehicle("1HGCM82633A004352"),
+		vehicle("1HGCM82633A004352"),
+	))
+	if !result.RolledBack || result.Results[0].Status != BulkRowSkipped || result.Results[0].VehicleID != "" || result.Results[1].Status != BulkRowFailed {
+		t.Errorf("Expected the import to be rolled back, got %+v", result)
+	}
+	if len(mockDB.vehicles) != 0 {
+		t.Errorf("Expected no vehicles saved, got %d", len(mockDB.vehicles))
+	}
+
+	_, result = sendBulkVehicles(t, server, "?all_or_nothing=true", "application/json", bulkVehiclesJSON(t,
+		vehicle("1HGCM82633A004352"),
+		vehicle("1M8GDM9AXKP042788"),
+	))
+	if result.RolledBack || result.Created != 2 || len(mockDB.vehicles) != 2 {
+		t.Errorf("Expected both vehicles saved, got %+v", result)
+	}
+}
+
+func TestBulkCreateVehiclesCSV(t *testing.T) {
+	server := setupTestServer()
+	dealershipID := uuid.New().String()
+
+	csv := "Dealership_ID,VIN,Make,Model,Year,Price,Mileage,Cost\n" +
+		dealershipID + ",1HGCM82633A004352,Honda,Accord,2003,5500,120000,\n" +
+		dealershipID + ",1M8GDM9AXKP042788,Ford,F-150,1989,abc,,\n" +
+		dealershipID + ",,Chevy,Malibu,2020,18000\n"
+	rr, result := sendBulkVehicles(t, server, "", "text/csv; charset=utf-8", csv)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if result.Created != 2 || result.Results[1].Status != BulkRowFailed || !strings.Contains(result.Results[1].Error, "price") {
+		t.Fatalf("Expected rows 0 and 2 created and a price error on row 1, got %+v", result)
+	}
+
+	created, _ := server.db.GetVehicle(result.Results[0].VehicleID)
+	if created == nil || created.Mileage != 120000 || created.Cost != nil || created.Status != "available" {
+		t.Errorf("Expected the CSV values with defaults, got %+v", created)
+	}
+	if malibu, _ := server.db.GetVehicle(result.Results[2].VehicleID); malibu == nil || malibu.Make != "Chevrolet" {
+		t.Errorf("Expected the make to be normalized, got %+v", malibu)
+	}
+
+	if rr, _ := sendBulkVehicles(t, server, "", "text/csv", "vin,trim\n1HGCM82633A004352,EX\n"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown column, got %d", rr.Code)
+	}
+}
+
+func TestBulkCreateVehiclesLimits(t *testing.T) {
+	server := setupTestServer()
+
+	for name, body := range map[string]string{
+		"empty":     "[]",
+		"not array": `{"vin":"1HGCM82633A004352"}`,
+		"too many":  "[" + strings.TrimSuffix(strings.Repeat("{},", maxBulkVehicles+1), ",") + "]",
+	} {
+		if rr, _ := sendBulkVehicles(t, server, "", "application/json", body); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, rr.Code)
+		}
+	}
+
+	// Setting cost still requires the cost scope
+	cost := 4000.0
+	body := bulkVehiclesJSON(t, CreateVehicleRequest{DealershipID: uuid.New().String(), Make: "Honda", Model: "Accord", Year: 2003, Price: 5500, Cost: &cost})
+	_, result := sendBulkVehicles(t, server, "", "application/json", body)
+	if result.Failed != 1 || !strings.Contains(result.Results[0].Error, ScopeInventoryCost) {
+		t.Errorf("Expected the row to need the cost scope, got %+v", result)
+	}
+
+	req := httptest.NewRequest("POST", "/vehicles/bulk", bytes.NewBufferString(body))
+	req.Header.Set(HeaderUserScopes, ScopeInventoryCost)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), `"created":1`) {
+		t.Errorf("Expected the row to be created with the cost scope, got %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/logging"
+)
+
+// Vehicle comparison limits
+const (
+	minCompareVehicles = 2
+	maxCompareVehicles = 5
+)
+
+// conditionRank orders vehicle conditions from least to most desirable
+var conditionRank = map[string]int{
+	"used":      1,
+	"certified": 2,
+	"new":       3,
+}
+
+// CompareVehiclesRequest represents a request to compare vehicles side by side
+type CompareVehiclesRequest struct {
+	VehicleIDs []string `json:"vehicle_ids"`
+}
+
+// Validate validates CompareVehiclesRequest
+func (r *CompareVehiclesRequest) Validate() *ValidationErrors {
+	var errors []ValidationError
+
+	if len(r.VehicleIDs) < minCompareVehicles || len(r.VehicleIDs) > maxCompareVehicles {
+		errors = append(errors, ValidationError{
+			Field:   "vehicle_ids",
+			Message: fmt.Sprintf("Must list between %d and %d different vehicles", minCompareVehicles, maxCompareVehicles),
+		})
+	}
+	for _, id := range r.VehicleIDs {
+		if !uuidRegex.MatchString(id) {
+			errors = append(errors, ValidationError{
+				Field:   "vehicle_ids",
+				Message: fmt.Sprintf("%q is not a valid UUID", id),
+			})
+		}
+	}
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize trims and de-duplicates the vehicle IDs, keeping their order
+func (r *CompareVehiclesRequest) Sanitize() {
+	seen := make(map[string]bool)
+	ids := make([]string, 0, len(r.VehicleIDs))
+	for _, id := range r.VehicleIDs {
+		id = strings.ToLower(strings.TrimSpace(id))
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	r.VehicleIDs = ids
+}
+
+// ComparedVehicle identifies a column of the comparison
+type ComparedVehicle struct {
+	ID          string `json:"id"`
+	VIN         string `json:"vin"`
+	StockNumber string `json:"stock_number"`
+	Year        int    `json:"year"`
+	Make        string `json:"make"`
+	Model       string `json:"model"`
+	Trim        string `json:"trim,omitempty"`
+	Status      string `json:"status"`
+	ImageURL    string `json:"image_url,omitempty"`
+}
+
+// ComparisonAttribute is a row of the comparison. Values line up with the
+// comparison's vehicles and are null where a vehicle has no value.
+type ComparisonAttribute struct {
+	Key    string        `json:"key"`
+	Label  string        `json:"label"`
+	Values []interface{} `json:"values"`
+
+	// Best lists the vehicles with the best value. It is empty when fewer
+	// than two vehicles have a value or they all have the same one.
+	Best []string `json:"best"`
+}
+
+// VehicleComparison is a side by side comparison of vehicles
+type VehicleComparison struct {
+	DealershipID string                `json:"dealership_id"`
+	Vehicles     []ComparedVehicle     `json:"vehicles"`
+	Attributes   []ComparisonAttribute `json:"attributes"`
+
+	// Highlights names the best vehicles for each attribute with a best
+	// value, e.g. "lowest_price" and "fewest_miles"
+	Highlights map[string][]string `json:"highlights"`
+}
+
+// comparisonAttribute describes how to read and rank one attribute. value
+// returns the displayed value and its score, where a higher score is better;
+// ok is false when the vehicle has no value.
+type comparisonAttribute struct {
+	key       string
+	label     string
+	highlight string
+	value     func(v *Vehicle) (value interface{}, score float64, ok bool)
+}
+
+// comparisonAttributes are the rows of a comparison, in order
+var comparisonAttributes = []comparisonAttribute{
+	{
+		key: "price", label: "Price", highlight: "lowest_price",
+		value: func(v *Vehicle) (interface{}, float64, bool) {
+			return v.Price, -v.Price, v.Price > 0
+		},
+	},
+	{
+		key: "year", label: "Year", highlight: "newest",
+		value: func(v *Vehicle) (interface{}, float64, bool) {
+			return v.Year, float64(v.Year), v.Year > 0
+		},
+	},
+	{
+		// Zero miles is a real reading for new vehicles, so mileage is
+		// always present
+		key: "mileage", label: "Mileage", highlight: "fewest_miles",
+		value: func(v *Vehicle) (interface{}, float64, bool) {
+			return v.Mileage, -float64(v.Mileage), true
+		},
+	},
+	{
+		key: "condition", label: "Condition", highlight: "best_condition",
+		value: func(v *Vehicle) (interface{}, float64, bool) {
+			condition := strings.ToLower(strings.TrimSpace(v.Condition))
+			rank, ok := conditionRank[condition]
+			return condition, float64(rank), ok
+		},
+	},
+	{
+		key: "features", label: "Features", highlight: "most_features",
+		value: func(v *Vehicle) (interface{}, float64, bool) {
+			features := parseFeatures(v.Features)
+			return features, float64(len(features)), len(features) > 0
+		},
+	},
+}
+
+// parseFeatures reads a vehicle's features, stored as a JSON array. Values
+// that aren't JSON are treated as a comma-separated list.
+func parseFeatures(raw string) []string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil
+	}
+
+	var parsed []string
+	if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
+		parsed = strings.Split(raw, ",")
+	}
+
+	features := make([]string, 0, len(parsed))
+	for _, f := range parsed {
+		if f = strings.TrimSpace(f); f != "" {
+			features = append(features, f)
+		}
+	}
+	return features
+}
+
+// compareVehicles builds the comparison matrix for vehicles, in order
+func compareVehicles(vehicles []*Vehicle) *VehicleComparison {
+	comparison := &VehicleComparison{
+		Vehicles:   make([]ComparedVehicle, len(vehicles)),
+		Attributes: make([]ComparisonAttribute, 0, len(comparisonAttributes)),
+		Highlights: make(map[string][]string),
+	}
+	if len(vehicles) > 0 {
+		comparison.DealershipID = vehicles[0].DealershipID
+	}
+
+	for i, v := range vehicles {
+		comparison.Vehicles[i] = ComparedVehicle{
+			ID:          v.ID,
+			VIN:         v.VIN,
+			StockNumber: v.StockNumber,
+			Year:        v.Year,
+			Make:        v.Make,
+			Model:       v.Model,
+			Trim:        v.Trim,
+			Status:      v.Status,
+			ImageURL:    v.ImageURL,
+		}
+	}
+
+	for _, attr := range comparisonAttributes {
+		row := ComparisonAttribute{
+			Key:    attr.key,
+			Label:  attr.label,
+			Values: make([]interface{}, len(vehicles)),
+			Best:   []string{},
+		}
+
+		scores := make(map[string]float64)
+		var best, worst float64
+		for i, v := range vehicles {
+			value, score, ok := attr.value(v)
+			if !ok {
+				continue
+			}
+			row.Values[i] = value
+			if len(scores) == 0 || score > best {
+				best = score
+			}
+			if len(scores) == 0 || score < worst {
+				worst = score
+			}
+			scores[v.ID] = score
+		}
+
+		if len(scores) >= 2 && best != worst {
+			for _, v := range vehicles {
+				if score, ok := scores[v.ID]; ok && score == best {
+					row.Best = append(row.Best, v.ID)
+				}
+			}
+			comparison.Highlights[attr.highlight] = row.Best
+		}
+		comparison.Attributes = append(comparison.Attributes, row)
+	}
+
+	return comparison
+}
+
+// compareVehiclesHandler compares vehicles side by side. All vehicles must
+// exist and be at the same dealership; when the caller's dealership is known,
+// vehicles at other dealerships are reported as not found.
+func (s *Server) compareVehiclesHandler(w http.ResponseWriter, r *http.Request) {
+	var req CompareVehiclesRequest
+	if !decodeAndValidate(r, w, &req) {
+		return
+	}
+
+	dealershipID := logging.GetDealershipID(r.Context())
+
+	vehicles := make([]*Vehicle, 0, len(req.VehicleIDs))
+	var missing []string
+	for _, id := range req.VehicleIDs {
+		vehicle, err := s.db.GetVehicle(id)
+		if err != nil {
+			s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get vehicle")
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to compare vehicles: %v", err))
+			return
+		}
+		if vehicle == nil || (dealershipID != "" && vehicle.DealershipID != dealershipID) {
+			missing = append(missing, id)
+			continue
+		}
+		vehicles = append(vehicles, vehicle)
+	}
+
+	if len(missing) > 0 {
+		respondErrorJSON(w, http.StatusNotFound, "Vehicles not found: "+strings.Join(missing, ", "), "VEHICLE_NOT_FOUND")
+		return
+	}
+	for _, v := range vehicles[1:] {
+		if v.DealershipID != vehicles[0].DealershipID {
+			respondErrorJSON(w, http.StatusBadRequest, "Vehicles must all be at the same dealership", "DIFFERENT_DEALERSHIPS")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(compareVehicles(vehicles))
+}
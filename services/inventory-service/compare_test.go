@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func compareRequest(server *Server, dealershipID string, ids ...string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(CompareVehiclesRequest{VehicleIDs: ids})
+	req := httptest.NewRequest("POST", "/vehicles/compare", bytes.NewBuffer(body))
+	if dealershipID != "" {
+		req.Header.Set("X-Dealership-ID", dealershipID)
+	}
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func addCompareVehicle(db *MockDatabase, dealershipID string, v Vehicle) *Vehicle {
+	v.ID = uuid.New().String()
+	v.DealershipID = dealershipID
+	db.vehicles[v.ID] = &v
+	return &v
+}
+
+func TestCompareVehicles(t *testing.T) {
+	server := setupTestServer()
+	db := server.db.(*MockDatabase)
+	dealershipID := uuid.New().String()
+
+	cheap := addCompareVehicle(db, dealershipID, Vehicle{
+		Make: "Honda", Model: "Civic", Year: 2019, Condition: "used", Price: 18500, Mileage: 42000,
+		Features: `["Bluetooth","Backup Camera"]`,
+	})
+	newest := addCompareVehicle(db, dealershipID, Vehicle{
+		Make: "Toyota", Model: "Camry", Year: 2024, Condition: "new", Price: 29900, Mileage: 12,
+		Features: `["Bluetooth","Backup Camera","Sunroof"]`,
+	})
+	// No price and no features
+	unpriced := addCompareVehicle(db, dealershipID, Vehicle{
+		Make: "Ford", Model: "Focus", Year: 2018, Condition: "used", Mileage: 61000,
+	})
+
+	rr := compareRequest(server, dealershipID, cheap.ID, newest.ID, unpriced.ID)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var comparison VehicleComparison
+	json.Unmarshal(rr.Body.Bytes(), &comparison)
+
+	if comparison.DealershipID != dealershipID || len(comparison.Vehicles) != 3 || comparison.Vehicles[1].ID != newest.ID {
+		t.Fatalf("Expected the vehicles in request order, got %+v", comparison.Vehicles)
+	}
+
+	rows := make(map[string]ComparisonAttribute)
+	for _, row := range comparison.Attributes {
+		if len(row.Values) != 3 {
+			t.Errorf("Expected a value per vehicle for %s, got %v", row.Key, row.Values)
+		}
+		rows[row.Key] = row
+	}
+
+	expectBest := map[string][]string{
+		"price":     {cheap.ID},
+		"year":      {newest.ID},
+		"mileage":   {newest.ID},
+		"condition": {newest.ID},
+		"features":  {newest.ID},
+	}
+	for key, want := range expectBest {
+		if got := rows[key].Best; !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected best %s to be %v, got %v", key, want, got)
+		}
+	}
+
+	if rows["price"].Values[2] != nil || rows["features"].Values[2] != nil {
+		t.Errorf("Expected missing attributes to be null, got price %v features %v", rows["price"].Values[2], rows["features"].Values[2])
+	}
+	if !reflect.DeepEqual(comparison.Highlights["lowest_price"], []string{cheap.ID}) ||
+		!reflect.DeepEqual(comparison.Highlights["fewest_miles"], []string{newest.ID}) {
+		t.Errorf("Unexpected highlights: %+v", comparison.Highlights)
+	}
+}
+
+func TestCompareVehiclesTiesAndEqualValues(t *testing.T) {
+	vehicles := []*Vehicle{
+		{ID: "a", Price: 20000, Mileage: 30000, Condition: "used"},
+		{ID: "b", Price: 20000, Mileage: 30000, Condition: "used"},
+		{ID: "c", Price: 25000, Mileage: 30000, Condition: "Used"},
+	}
+
+	comparison := compareVehicles(vehicles)
+	for _, row := range comparison.Attributes {
+		switch row.Key {
+		case "price":
+			if !reflect.DeepEqual(row.Best, []string{"a", "b"}) {
+				t.Errorf("Expected tied vehicles to share the lowest price, got %v", row.Best)
+			}
+		case "mileage", "condition", "year", "features":
+			if len(row.Best) != 0 {
+				t.Errorf("Expected no best %s when nothing differs, got %v", row.Key, row.Best)
+			}
+		}
+	}
+	if _, ok := comparison.Highlights["fewest_miles"]; ok {
+		t.Errorf("Expected no mileage highlight, got %+v", comparison.Highlights)
+	}
+}
+
+func TestCompareVehiclesValidation(t *testing.T) {
+	server := setupTestServer()
+	db := server.db.(*MockDatabase)
+	dealershipID := uuid.New().String()
+	otherDealershipID := uuid.New().String()
+
+	mine := addCompareVehicle(db, dealershipID, Vehicle{Price: 20000})
+	alsoMine := addCompareVehicle(db, dealershipID, Vehicle{Price: 21000})
+	theirs := addCompareVehicle(db, otherDealershipID, Vehicle{Price: 22000})
+
+	tooMany := []string{}
+	for i := 0; i <= maxCompareVehicles; i++ {
+		tooMany = append(tooMany, uuid.New().String())
+	}
+
+	tests := []struct {
+		name         string
+		dealershipID string
+		ids          []string
+		want         int
+	}{
+		{"single vehicle", dealershipID, []string{mine.ID}, http.StatusBadRequest},
+		{"duplicate vehicle", dealershipID, []string{mine.ID, mine.ID}, http.StatusBadRequest},
+		{"too many vehicles", dealershipID, tooMany, http.StatusBadRequest},
+		{"invalid ID", dealershipID, []string{mine.ID, "not-a-uuid"}, http.StatusBadRequest},
+		{"unknown vehicle", dealershipID, []string{mine.ID, uuid.New().String()}, http.StatusNotFound},
+		{"another dealership's vehicle", dealershipID, []string{mine.ID, theirs.ID}, http.StatusNotFound},
+		{"mixed dealerships", "", []string{mine.ID, theirs.ID}, http.StatusBadRequest},
+		{"same dealership", dealershipID, []string{mine.ID, alsoMine.ID}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rr := compareRequest(server, tt.dealershipID, tt.ids...); rr.Code != tt.want {
+				t.Errorf("Expected status %d, got %d: %s", tt.want, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/logging"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// ScopeInventoryCost grants access to vehicle cost and margin
+const ScopeInventoryCost = "inventory:cost"
+
+// HeaderUserScopes carries the caller's scopes, set by the API gateway from the JWT
+const HeaderUserScopes = "X-User-Scopes"
+
+// hasScope reports whether the request carries the given scope. Scopes are
+// comma or space separated.
+func hasScope(r *http.Request, scope string) bool {
+	fields := strings.FieldsFunc(r.Header.Get(HeaderUserScopes), func(c rune) bool {
+		return c == ',' || c == ' '
+	})
+	for _, f := range fields {
+		if f == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// computeMargin sets Margin from Price and Cost
+func (v *Vehicle) computeMargin() {
+	if v.Cost == nil {
+		v.Margin = nil
+		return
+	}
+	margin := math.Round((v.Price-*v.Cost)*100) / 100
+	v.Margin = &margin
+}
+
+// priceBelowCost reports whether the vehicle is listed for less than it cost
+func (v *Vehicle) priceBelowCost() bool {
+	return v.Cost != nil && v.Price < *v.Cost
+}
+
+// presentVehicle returns a copy of the vehicle suitable for the caller,
+// with cost and margin omitted unless the request has the inventory:cost scope
+func presentVehicle(r *http.Request, vehicle *Vehicle) *Vehicle {
+	out := *vehicle
+	if hasScope(r, ScopeInventoryCost) {
+		out.computeMargin()
+	} else {
+		out.Cost = nil
+		out.Margin = nil
+	}
+	return &out
+}
+
+// presentVehicles applies presentVehicle to each vehicle in a list
+func presentVehicles(r *http.Request, vehicles []*Vehicle) []*Vehicle {
+	out := make([]*Vehicle, len(vehicles))
+	for i, v := range vehicles {
+		out[i] = presentVehicle(r, v)
+	}
+	return out
+}
+
+// requireCostScope rejects the request if it attempts to set cost without the
+// inventory:cost scope
+func requireCostScope(w http.ResponseWriter, r *http.Request, cost *float64) bool {
+	if cost != nil && !hasScope(r, ScopeInventoryCost) {
+		respondErrorJSON(w, http.StatusForbidden, "Setting cost requires the "+ScopeInventoryCost+" scope", "INSUFFICIENT_SCOPE")
+		return false
+	}
+	return true
+}
+
+// warnIfBelowCost logs when a vehicle is priced below cost and adds a warning
+// to the response copy if the caller is allowed to see cost
+func (s *Server) warnIfBelowCost(r *http.Request, vehicle, out *Vehicle) {
+	if !vehicle.priceBelowCost() {
+		return
+	}
+	s.logger.WithContext(r.Context()).WithField("vehicle_id", vehicle.ID).Warn("Vehicle priced below cost")
+	if out.Cost != nil {
+		out.Warnings = append(out.Warnings, "Price is below cost")
+	}
+}
+
+// recordPriceChanges writes a history entry for price and cost changes.
+// History failures are logged but do not fail the update.
+func (s *Server) recordPriceChanges(r *http.Request, before, after *Vehicle) {
+	type change struct {
+		field    string
+		old, new float64
+	}
+	var changes []change
+
+	if before.Price != after.Price {
+		changes = append(changes, change{"price", before.Price, after.Price})
+	}
+	if after.Cost != nil && (before.Cost == nil || *before.Cost != *after.Cost) {
+		oldCost := 0.0
+		if before.Cost != nil {
+			oldCost = *before.Cost
+		}
+		changes = append(changes, change{"cost", oldCost, *after.Cost})
+	}
+
+	changedBy := logging.GetUserID(r.Context())
+	for _, c := range changes {
+		entry := &PriceHistoryEntry{
+			ID:        uuid.New().String(),
+			VehicleID: after.ID,
+			Field:     c.field,
+			OldValue:  c.old,
+			NewValue:  c.new,
+			ChangedBy: changedBy,
+			ChangedAt: after.UpdatedAt,
+		}
+		if err := s.db.CreatePriceHistory(entry); err != nil {
+			s.logger.WithContext(r.Context()).WithError(err).WithField("vehicle_id", after.ID).Warn("Failed to record price history")
+		}
+	}
+}
+
+// getPriceHistory returns the price history for a vehicle. Cost entries are
+// only included for callers with the inventory:cost scope.
+func (s *Server) getPriceHistory(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if !validateUUID(w, id, "id") {
+		return
+	}
+
+	entries, err := s.db.ListPriceHistory(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list price history")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to list price history: %v", err))
+		return
+	}
+
+	canSeeCost := hasScope(r, ScopeInventoryCost)
+	visible := []*PriceHistoryEntry{}
+	for _, entry := range entries {
+		if entry.Field == "cost" && !canSeeCost {
+			continue
+		}
+		visible = append(visible, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(visible)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+// seedCostedVehicle stores a vehicle with a known cost directly in the mock database
+func seedCostedVehicle(server *Server, price, cost float64) *Vehicle {
+	vehicle := &Vehicle{
+		ID:           uuid.New().String(),
+		DealershipID: uuid.New().String(),
+		Make:         "Honda",
+		Model:        "Accord",
+		Year:         2022,
+		Condition:    "used",
+		Status:       "available",
+		Price:        price,
+		Cost:         floatPtr(cost),
+	}
+	server.db.CreateVehicle(vehicle)
+	return vehicle
+}
+
+func getVehicleJSON(t *testing.T, server *Server, id, scopes string) map[string]interface{} {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", "/vehicles/"+id, nil)
+	if scopes != "" {
+		req.Header.Set(HeaderUserScopes, scopes)
+	}
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestGetVehicleOmitsCostWithoutScope(t *testing.T) {
+	server := setupTestServer()
+	vehicle := seedCostedVehicle(server, 25000, 21000)
+
+	body := getVehicleJSON(t, server, vehicle.ID, "inventory:read")
+
+	if _, ok := body["cost"]; ok {
+		t.Errorf("Expected cost to be omitted, got %v", body["cost"])
+	}
+	if _, ok := body["margin"]; ok {
+		t.Errorf("Expected margin to be omitted, got %v", body["margin"])
+	}
+
+	// The stored vehicle must not be altered by masking
+	if vehicle.Cost == nil || *vehicle.Cost != 21000 {
+		t.Error("Expected stored cost to be unchanged")
+	}
+}
+
+func TestGetVehicleIncludesCostWithScope(t *testing.T) {
+	server := setupTestServer()
+	vehicle := seedCostedVehicle(server, 25000, 21000)
+
+	body := getVehicleJSON(t, server, vehicle.ID, "inventory:read,inventory:cost")
+
+	if body["cost"] != 21000.0 {
+		t.Errorf("Expected cost 21000, got %v", body["cost"])
+	}
+	if body["margin"] != 4000.0 {
+		t.Errorf("Expected margin 4000, got %v", body["margin"])
+	}
+}
+
+func TestListVehiclesMasksCost(t *testing.T) {
+	server := setupTestServer()
+	seedCostedVehicle(server, 25000, 21000)
+
+	req := httptest.NewRequest("GET", "/vehicles", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if strings.Contains(rr.Body.String(), `"cost"`) {
+		t.Errorf("Expected cost to be omitted from list, got %s", rr.Body.String())
+	}
+}
+
+func TestCreateVehicleCost(t *testing.T) {
+	tests := []struct {
+		name         string
+		scopes       string
+		cost         float64
+		price        float64
+		expectedCode int
+		expectWarn   bool
+	}{
+		{"with scope", ScopeInventoryCost, 20000, 25000, http.StatusCreated, false},
+		{"price below cost warns", ScopeInventoryCost, 26000, 25000, http.StatusCreated, true},
+		{"negative cost", ScopeInventoryCost, -1, 25000, http.StatusBadRequest, false},
+		{"without scope", "", 20000, 25000, http.StatusForbidden, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestServer()
+
+			body, _ := json.Marshal(CreateVehicleRequest{
+				DealershipID: uuid.New().String(),
+				Make:         "Honda",
+				Model:        "Civic",
+				Year:         2022,
+				Price:        tt.price,
+				Cost:         floatPtr(tt.cost),
+			})
+			req := httptest.NewRequest("POST", "/vehicles", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.scopes != "" {
+				req.Header.Set(HeaderUserScopes, tt.scopes)
+			}
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedCode {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedCode, rr.Code, rr.Body.String())
+			}
+			if rr.Code != http.StatusCreated {
+				return
+			}
+
+			var created Vehicle
+			if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+				t.Fatal(err)
+			}
+			if created.Cost == nil || *created.Cost != tt.cost {
+				t.Errorf("Expected cost %v, got %v", tt.cost, created.Cost)
+			}
+			if got := len(created.Warnings) > 0; got != tt.expectWarn {
+				t.Errorf("Expected warning %v, got %v", tt.expectWarn, created.Warnings)
+			}
+		})
+	}
+}
+
+func TestUpdateVehicleRecordsPriceHistory(t *testing.T) {
+	server := setupTestServer()
+	vehicle := seedCostedVehicle(server, 25000, 21000)
+
+	body, _ := json.Marshal(UpdateVehicleRequest{Price: 24000, Cost: floatPtr(20500)})
+	req := httptest.NewRequest("PUT", "/vehicles/"+vehicle.ID, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderUserScopes, ScopeInventoryCost)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	history := func(scopes string) []PriceHistoryEntry {
+		req := httptest.NewRequest("GET", "/vehicles/"+vehicle.ID+"/price-history", nil)
+		if scopes != "" {
+			req.Header.Set(HeaderUserScopes, scopes)
+		}
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+
+		var entries []PriceHistoryEntry
+		if err := json.Unmarshal(rr.Body.Bytes(), &entries); err != nil {
+			t.Fatal(err)
+		}
+		return entries
+	}
+
+	entries := history(ScopeInventoryCost)
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 history entries, got %d", len(entries))
+	}
+	for _, entry := range entries {
+		switch entry.Field {
+		case "price":
+			if entry.OldValue != 25000 || entry.NewValue != 24000 {
+				t.Errorf("Unexpected price entry: %+v", entry)
+			}
+		case "cost":
+			if entry.OldValue != 21000 || entry.NewValue != 20500 {
+				t.Errorf("Unexpected cost entry: %+v", entry)
+			}
+		default:
+			t.Errorf("Unexpected field %q", entry.Field)
+		}
+	}
+
+	entries = history("")
+	if len(entries) != 1 || entries[0].Field != "price" {
+		t.Errorf("Expected only the price entry without the cost scope, got %+v", entries)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"regexp"
@@ -45,6 +46,11 @@ func (db *Database) Close() error {
 	return db.conn.Close()
 }
 
+// Ping checks the database is reachable
+func (db *Database) Ping(ctx context.Context) error {
+	return db.conn.PingContext(ctx)
+}
+
 // InitSchema creates the vehicles table if it doesn't exist
 func (db *Database) InitSchema() error {
 	schema := `
@@ -77,6 +83,99 @@ func (db *Database) InitSchema() error {
 	CREATE INDEX IF NOT EXISTS idx_vehicles_vin ON vehicles(vin);
 	CREATE INDEX IF NOT EXISTS idx_vehicles_status ON vehicles(status);
 	CREATE INDEX IF NOT EXISTS idx_vehicles_make_model ON vehicles(make, model);
+
+	ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS cost DECIMAL(10, 2);
+	ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS make_raw VARCHAR(100);
+	ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS model_raw VARCHAR(100);
+	ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS lot_arrived_at TIMESTAMP;
+	ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+	CREATE TABLE IF NOT EXISTS vehicle_synonyms (
+		id VARCHAR(36) PRIMARY KEY,
+		field VARCHAR(10) NOT NULL,
+		make VARCHAR(50) NOT NULL DEFAULT '',
+		synonym VARCHAR(100) NOT NULL,
+		canonical VARCHAR(50) NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_vehicle_synonyms_unique ON vehicle_synonyms(field, LOWER(make), LOWER(synonym));
+
+	CREATE TABLE IF NOT EXISTS vehicle_price_history (
+		id VARCHAR(36) PRIMARY KEY,
+		vehicle_id VARCHAR(36) NOT NULL REFERENCES vehicles(id) ON DELETE CASCADE,
+		field VARCHAR(20) NOT NULL,
+		old_value DECIMAL(10, 2),
+		new_value DECIMAL(10, 2),
+		changed_by VARCHAR(36),
+		changed_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_vehicle_price_history_vehicle ON vehicle_price_history(vehicle_id);
+
+	CREATE TABLE IF NOT EXISTS inventory_snapshots (
+		dealership_id VARCHAR(36) NOT NULL,
+		snapshot_date DATE NOT NULL,
+		vehicle_count INTEGER NOT NULL DEFAULT 0,
+		average_price DECIMAL(10, 2) NOT NULL DEFAULT 0,
+		average_days_on_lot DECIMAL(8, 1) NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (dealership_id, snapshot_date)
+	);
+
+	CREATE TABLE IF NOT EXISTS vehicle_watches (
+		id VARCHAR(36) PRIMARY KEY,
+		vehicle_id VARCHAR(36) NOT NULL REFERENCES vehicles(id) ON DELETE CASCADE,
+		dealership_id VARCHAR(36) NOT NULL,
+		customer_id VARCHAR(36),
+		email VARCHAR(255) NOT NULL,
+		alert_price DECIMAL(10, 2) NOT NULL,
+		unsubscribe_token VARCHAR(64) NOT NULL UNIQUE,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		removed_at TIMESTAMP
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_vehicle_watches_email ON vehicle_watches(vehicle_id, LOWER(email));
+
+	CREATE TABLE IF NOT EXISTS vehicle_transfers (
+		id VARCHAR(36) PRIMARY KEY,
+		vehicle_id VARCHAR(36) NOT NULL REFERENCES vehicles(id) ON DELETE CASCADE,
+		from_dealership_id VARCHAR(36) NOT NULL,
+		to_dealership_id VARCHAR(36) NOT NULL,
+		dealer_group_id VARCHAR(100) NOT NULL,
+		reason TEXT,
+		transferred_by VARCHAR(36),
+		transferred_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_vehicle_transfers_vehicle ON vehicle_transfers(vehicle_id);
+
+	CREATE TABLE IF NOT EXISTS vehicle_photos (
+		id VARCHAR(36) PRIMARY KEY,
+		vehicle_id VARCHAR(36) NOT NULL REFERENCES vehicles(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		url TEXT NOT NULL,
+		content_type VARCHAR(50) NOT NULL,
+		filename VARCHAR(255),
+		is_primary BOOLEAN NOT NULL DEFAULT false,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_vehicle_photos_vehicle ON vehicle_photos(vehicle_id, position);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_vehicle_photos_primary ON vehicle_photos(vehicle_id) WHERE is_primary;
+
+	-- Search analytics are daily aggregates; no shopper identity is stored
+	CREATE TABLE IF NOT EXISTS search_analytics (
+		dealership_id VARCHAR(36) NOT NULL,
+		search_date DATE NOT NULL,
+		make VARCHAR(100) NOT NULL DEFAULT '',
+		model VARCHAR(100) NOT NULL DEFAULT '',
+		price_band VARCHAR(30) NOT NULL DEFAULT '',
+		query VARCHAR(100) NOT NULL DEFAULT '',
+		searches INTEGER NOT NULL DEFAULT 0,
+		no_result_searches INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (dealership_id, search_date, make, model, price_band, query)
+	);
 	`
 
 	if _, err := db.conn.Exec(schema); err != nil {
@@ -91,30 +190,85 @@ func (db *Database) InitSchema() error {
 
 // CreateVehicle inserts a new vehicle into the database
 func (db *Database) CreateVehicle(vehicle *Vehicle) error {
-	query := `
-		INSERT INTO vehicles (
-			id, dealership_id, vin, stock_number, make, model, year, trim,
-			condition, status, price, mileage, color, transmission, engine,
-			fuel_type, drive_type, body_style, image_url, features,
-			created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)
-	`
+	_, err := db.conn.Exec(insertVehicleQuery, insertVehicleArgs(vehicle)...)
+	if err != nil {
+		return fmt.Errorf("failed to create vehicle: %w", err)
+	}
 
-	_, err := db.conn.Exec(
-		query,
+	return nil
+}
+
+// insertVehicleQuery inserts a vehicle with insertVehicleArgs
+const insertVehicleQuery = `
+	INSERT INTO vehicles (
+		id, dealership_id, vin, stock_number, make, model, year, trim,
+		condition, status, price, mileage, color, transmission, engine,
+		fuel_type, drive_type, body_style, image_url, features,
+		created_at, updated_at, cost, make_raw, model_raw
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25)
+`
+
+// insertVehicleArgs returns the arguments of insertVehicleQuery for a vehicle
+func insertVehicleArgs(vehicle *Vehicle) []interface{} {
+	return []interface{}{
 		vehicle.ID, vehicle.DealershipID, vehicle.VIN, vehicle.StockNumber,
 		vehicle.Make, vehicle.Model, vehicle.Year, vehicle.Trim,
 		vehicle.Condition, vehicle.Status, vehicle.Price, vehicle.Mileage,
 		vehicle.Color, vehicle.Transmission, vehicle.Engine, vehicle.FuelType,
 		vehicle.DriveType, vehicle.BodyStyle, vehicle.ImageURL, vehicle.Features,
-		vehicle.CreatedAt, vehicle.UpdatedAt,
-	)
+		vehicle.CreatedAt, vehicle.UpdatedAt, vehicle.Cost, vehicle.MakeRaw, vehicle.ModelRaw,
+	}
+}
 
+// BulkCreateVehicles inserts vehicles in one transaction with a prepared
+// statement. Each vehicle that fails to insert gets an error at its index;
+// the others are saved. With allOrNothing the import stops at the first
+// failure and nothing is saved.
+func (db *Database) BulkCreateVehicles(vehicles []*Vehicle, allOrNothing bool) ([]error, error) {
+	tx, err := db.conn.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to create vehicle: %w", err)
+		return nil, fmt.Errorf("failed to begin vehicle import: %w", err)
 	}
+	defer tx.Rollback()
 
-	return nil
+	stmt, err := tx.Prepare(insertVehicleQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare vehicle import: %w", err)
+	}
+	defer stmt.Close()
+
+	errs := make([]error, len(vehicles))
+	for i, vehicle := range vehicles {
+		// A failed insert aborts the whole transaction, so when the other
+		// vehicles are to be kept each insert gets a savepoint to roll back to
+		if !allOrNothing {
+			if _, err := tx.Exec(`SAVEPOINT bulk_vehicle`); err != nil {
+				return nil, fmt.Errorf("failed to create savepoint: %w", err)
+			}
+		}
+
+		if _, err := stmt.Exec(insertVehicleArgs(vehicle)...); err != nil {
+			errs[i] = fmt.Errorf("failed to create vehicle: %w", err)
+			if allOrNothing {
+				return errs, nil
+			}
+			if _, err := tx.Exec(`ROLLBACK TO SAVEPOINT bulk_vehicle`); err != nil {
+				return nil, fmt.Errorf("failed to roll back to savepoint: %w", err)
+			}
+			continue
+		}
+
+		if !allOrNothing {
+			if _, err := tx.Exec(`RELEASE SAVEPOINT bulk_vehicle`); err != nil {
+				return nil, fmt.Errorf("failed to release savepoint: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit vehicle import: %w", err)
+	}
+	return errs, nil
 }
 
 // GetVehicle retrieves a vehicle by ID
@@ -123,19 +277,23 @@ func (db *Database) GetVehicle(id string) (*Vehicle, error) {
 		SELECT id, dealership_id, vin, stock_number, make, model, year, trim,
 			   condition, status, price, mileage, color, transmission, engine,
 			   fuel_type, drive_type, body_style, image_url, features,
-			   created_at, updated_at
+			   created_at, updated_at, cost, COALESCE(make_raw, make), COALESCE(model_raw, model),
+			   COALESCE(lot_arrived_at, created_at), deleted_at
 		FROM vehicles
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 
 	var vehicle Vehicle
+	var cost sql.NullFloat64
+	var deletedAt sql.NullString
 	err := db.conn.QueryRow(query, id).Scan(
 		&vehicle.ID, &vehicle.DealershipID, &vehicle.VIN, &vehicle.StockNumber,
 		&vehicle.Make, &vehicle.Model, &vehicle.Year, &vehicle.Trim,
 		&vehicle.Condition, &vehicle.Status, &vehicle.Price, &vehicle.Mileage,
 		&vehicle.Color, &vehicle.Transmission, &vehicle.Engine, &vehicle.FuelType,
 		&vehicle.DriveType, &vehicle.BodyStyle, &vehicle.ImageURL, &vehicle.Features,
-		&vehicle.CreatedAt, &vehicle.UpdatedAt,
+		&vehicle.CreatedAt, &vehicle.UpdatedAt, &cost, &vehicle.MakeRaw, &vehicle.ModelRaw,
+		&vehicle.LotArrivedAt, &deletedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -144,17 +302,25 @@ func (db *Database) GetVehicle(id string) (*Vehicle, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get vehicle: %w", err)
 	}
+	if cost.Valid {
+		vehicle.Cost = &cost.Float64
+	}
+	if deletedAt.Valid {
+		vehicle.DeletedAt = &deletedAt.String
+	}
 
 	return &vehicle, nil
 }
 
-// ListVehicles retrieves all vehicles with optional filters
+// ListVehicles retrieves all vehicles with optional filters. Deleted vehicles
+// are only included with the include_deleted filter.
 func (db *Database) ListVehicles(dealershipID string, filters map[string]interface{}) ([]*Vehicle, error) {
 	query := `
 		SELECT id, dealership_id, vin, stock_number, make, model, year, trim,
 			   condition, status, price, mileage, color, transmission, engine,
 			   fuel_type, drive_type, body_style, image_url, features,
-			   created_at, updated_at
+			   created_at, updated_at, cost, COALESCE(make_raw, make), COALESCE(model_raw, model),
+			   COALESCE(lot_arrived_at, created_at), deleted_at
 		FROM vehicles
 		WHERE 1=1
 	`
@@ -162,6 +328,10 @@ func (db *Database) ListVehicles(dealershipID string, filters map[string]interfa
 	var args []interface{}
 	argIndex := 1
 
+	if includeDeleted, _ := filters["include_deleted"].(bool); !includeDeleted {
+		query += " AND deleted_at IS NULL"
+	}
+
 	// Add dealership filter
 	if dealershipID != "" {
 		query += fmt.Sprintf(" AND dealership_id = $%d", argIndex)
@@ -213,6 +383,15 @@ func (db *Database) ListVehicles(dealershipID string, filters map[string]interfa
 		argIndex++
 	}
 
+	// Every word of a free-text search must match one of the text columns
+	if q, ok := filters["q"].(string); ok && q != "" {
+		for _, word := range strings.Fields(q) {
+			query += fmt.Sprintf(" AND (make ILIKE $%[1]d OR model ILIKE $%[1]d OR trim ILIKE $%[1]d OR vin ILIKE $%[1]d OR stock_number ILIKE $%[1]d)", argIndex)
+			args = append(args, "%"+word+"%")
+			argIndex++
+		}
+	}
+
 	query += " ORDER BY created_at DESC"
 
 	rows, err := db.conn.Query(query, args...)
@@ -224,17 +403,26 @@ func (db *Database) ListVehicles(dealershipID string, filters map[string]interfa
 	var vehicles []*Vehicle
 	for rows.Next() {
 		var vehicle Vehicle
+		var cost sql.NullFloat64
+		var deletedAt sql.NullString
 		err := rows.Scan(
 			&vehicle.ID, &vehicle.DealershipID, &vehicle.VIN, &vehicle.StockNumber,
 			&vehicle.Make, &vehicle.Model, &vehicle.Year, &vehicle.Trim,
 			&vehicle.Condition, &vehicle.Status, &vehicle.Price, &vehicle.Mileage,
 			&vehicle.Color, &vehicle.Transmission, &vehicle.Engine, &vehicle.FuelType,
 			&vehicle.DriveType, &vehicle.BodyStyle, &vehicle.ImageURL, &vehicle.Features,
-			&vehicle.CreatedAt, &vehicle.UpdatedAt,
+			&vehicle.CreatedAt, &vehicle.UpdatedAt, &cost, &vehicle.MakeRaw, &vehicle.ModelRaw,
+			&vehicle.LotArrivedAt, &deletedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan vehicle: %w", err)
 		}
+		if cost.Valid {
+			vehicle.Cost = &cost.Float64
+		}
+		if deletedAt.Valid {
+			vehicle.DeletedAt = &deletedAt.String
+		}
 		vehicles = append(vehicles, &vehicle)
 	}
 
@@ -264,7 +452,10 @@ func (db *Database) UpdateVehicle(vehicle *Vehicle) error {
 			body_style = $18,
 			image_url = $19,
 			features = $20,
-			updated_at = $21
+			updated_at = $21,
+			cost = $22,
+			make_raw = $23,
+			model_raw = $24
 		WHERE id = $1
 	`
 
@@ -275,7 +466,7 @@ func (db *Database) UpdateVehicle(vehicle *Vehicle) error {
 		vehicle.Condition, vehicle.Status, vehicle.Price, vehicle.Mileage,
 		vehicle.Color, vehicle.Transmission, vehicle.Engine, vehicle.FuelType,
 		vehicle.DriveType, vehicle.BodyStyle, vehicle.ImageURL, vehicle.Features,
-		vehicle.UpdatedAt,
+		vehicle.UpdatedAt, vehicle.Cost, vehicle.MakeRaw, vehicle.ModelRaw,
 	)
 
 	if err != nil {
@@ -294,9 +485,10 @@ func (db *Database) UpdateVehicle(vehicle *Vehicle) error {
 	return nil
 }
 
-// DeleteVehicle deletes a vehicle by ID
+// DeleteVehicle soft-deletes a vehicle by ID, keeping the row for the deals
+// and audit history that reference it
 func (db *Database) DeleteVehicle(id string) error {
-	query := `DELETE FROM vehicles WHERE id = $1`
+	query := `UPDATE vehicles SET deleted_at = NOW(), updated_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
 
 	result, err := db.conn.Exec(query, id)
 	if err != nil {
@@ -315,6 +507,27 @@ func (db *Database) DeleteVehicle(id string) error {
 	return nil
 }
 
+// RestoreVehicle undoes the soft delete of a vehicle
+func (db *Database) RestoreVehicle(id string) error {
+	query := `UPDATE vehicles SET deleted_at = NULL, updated_at = NOW() WHERE id = $1 AND deleted_at IS NOT NULL`
+
+	result, err := db.conn.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to restore vehicle: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("vehicle not found: %s", id)
+	}
+
+	return nil
+}
+
 // ValidateVIN validates a VIN (Vehicle Identification Number)
 func (db *Database) ValidateVIN(vin string) (bool, error) {
 	// VIN must be exactly 17 characters
@@ -338,7 +551,7 @@ func (db *Database) GetInventoryStats(dealershipID string) (map[string]interface
 	// Total count
 	var totalCount int
 	err := db.conn.QueryRow(`
-		SELECT COUNT(*) FROM vehicles WHERE dealership_id = $1
+		SELECT COUNT(*) FROM vehicles WHERE dealership_id = $1 AND deleted_at IS NULL
 	`, dealershipID).Scan(&totalCount)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get total count: %w", err)
@@ -349,7 +562,7 @@ func (db *Database) GetInventoryStats(dealershipID string) (map[string]interface
 	statusRows, err := db.conn.Query(`
 		SELECT status, COUNT(*) as count
 		FROM vehicles
-		WHERE dealership_id = $1
+		WHERE dealership_id = $1 AND deleted_at IS NULL
 		GROUP BY status
 	`, dealershipID)
 	if err != nil {
@@ -372,7 +585,7 @@ func (db *Database) GetInventoryStats(dealershipID string) (map[string]interface
 	conditionRows, err := db.conn.Query(`
 		SELECT condition, COUNT(*) as count
 		FROM vehicles
-		WHERE dealership_id = $1
+		WHERE dealership_id = $1 AND deleted_at IS NULL
 		GROUP BY condition
 	`, dealershipID)
 	if err != nil {
@@ -394,7 +607,7 @@ func (db *Database) GetInventoryStats(dealershipID string) (map[string]interface
 	// Average price
 	var avgPrice sql.NullFloat64
 	err = db.conn.QueryRow(`
-		SELECT AVG(price) FROM vehicles WHERE dealership_id = $1
+		SELECT AVG(price) FROM vehicles WHERE dealership_id = $1 AND deleted_at IS NULL
 	`, dealershipID).Scan(&avgPrice)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get average price: %w", err)
@@ -407,3 +620,687 @@ func (db *Database) GetInventoryStats(dealershipID string) (map[string]interface
 
 	return stats, nil
 }
+
+// GetAgingReport returns the aging buckets of available vehicles, in order,
+// with the count, total price and oldest vehicle of each. Days in inventory
+// are counted from created_at. An empty dealershipID covers all dealerships.
+func (db *Database) GetAgingReport(dealershipID string) ([]*AgingBucket, error) {
+	query := `
+		WITH aged AS (
+			SELECT id, COALESCE(stock_number, '') AS stock_number, price,
+				   CURRENT_DATE - created_at::date AS days
+			FROM vehicles
+			WHERE status = 'available' AND deleted_at IS NULL`
+
+	var args []interface{}
+	if dealershipID != "" {
+		query += ` AND dealership_id = $1`
+		args = append(args, dealershipID)
+	}
+
+	query += fmt.Sprintf(`
+		), bucketed AS (
+			SELECT *, %s AS bucket FROM aged
+		)
+		SELECT DISTINCT ON (bucket) bucket, COUNT(*) OVER buckets, SUM(price) OVER buckets,
+			   id, stock_number, days
+		FROM bucketed
+		WINDOW buckets AS (PARTITION BY bucket)
+		ORDER BY bucket, days DESC, id
+	`, agingBucketCase("days"))
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get aging report: %w", err)
+	}
+	defer rows.Close()
+
+	buckets := newAgingBuckets()
+	byLabel := make(map[string]*AgingBucket, len(buckets))
+	for _, bucket := range buckets {
+		byLabel[bucket.Bucket] = bucket
+	}
+
+	for rows.Next() {
+		var label string
+		var row AgingBucket
+		if err := rows.Scan(&label, &row.Count, &row.TotalValue, &row.OldestVehicleID, &row.OldestStockNumber, &row.OldestDays); err != nil {
+			return nil, fmt.Errorf("failed to scan aging bucket: %w", err)
+		}
+		if bucket := byLabel[label]; bucket != nil {
+			bucket.Count = row.Count
+			bucket.TotalValue = row.TotalValue
+			bucket.OldestVehicleID = row.OldestVehicleID
+			bucket.OldestStockNumber = row.OldestStockNumber
+			bucket.OldestDays = row.OldestDays
+		}
+	}
+
+	return buckets, rows.Err()
+}
+
+// CreatePriceHistory records a change to a vehicle's price or cost
+func (db *Database) CreatePriceHistory(entry *PriceHistoryEntry) error {
+	query := `
+		INSERT INTO vehicle_price_history (id, vehicle_id, field, old_value, new_value, changed_by, changed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := db.conn.Exec(
+		query,
+		entry.ID, entry.VehicleID, entry.Field, entry.OldValue,
+		entry.NewValue, entry.ChangedBy, entry.ChangedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create price history: %w", err)
+	}
+
+	return nil
+}
+
+// ListPriceHistory retrieves the price and cost history for a vehicle, oldest first
+func (db *Database) ListPriceHistory(vehicleID string) ([]*PriceHistoryEntry, error) {
+	query := `
+		SELECT id, vehicle_id, field, COALESCE(old_value, 0), COALESCE(new_value, 0),
+			   COALESCE(changed_by, ''), changed_at
+		FROM vehicle_price_history
+		WHERE vehicle_id = $1
+		ORDER BY changed_at ASC
+	`
+
+	rows, err := db.conn.Query(query, vehicleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list price history: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*PriceHistoryEntry
+	for rows.Next() {
+		var entry PriceHistoryEntry
+		if err := rows.Scan(
+			&entry.ID, &entry.VehicleID, &entry.Field, &entry.OldValue,
+			&entry.NewValue, &entry.ChangedBy, &entry.ChangedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan price history: %w", err)
+		}
+		entries = append(entries, &entry)
+	}
+
+	return entries, nil
+}
+
+// ListDealershipIDs returns every dealership that has vehicles in inventory
+func (db *Database) ListDealershipIDs() ([]string, error) {
+	rows, err := db.conn.Query(`SELECT DISTINCT dealership_id FROM vehicles WHERE deleted_at IS NULL ORDER BY dealership_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dealerships: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan dealership: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// UpsertInventorySnapshot saves a dealership's snapshot, replacing any
+// existing snapshot for the same day
+func (db *Database) UpsertInventorySnapshot(snapshot *InventorySnapshot) error {
+	query := `
+		INSERT INTO inventory_snapshots (
+			dealership_id, snapshot_date, vehicle_count, average_price, average_days_on_lot, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (dealership_id, snapshot_date) DO UPDATE SET
+			vehicle_count = EXCLUDED.vehicle_count,
+			average_price = EXCLUDED.average_price,
+			average_days_on_lot = EXCLUDED.average_days_on_lot,
+			created_at = EXCLUDED.created_at
+	`
+
+	_, err := db.conn.Exec(
+		query,
+		snapshot.DealershipID, snapshot.SnapshotDate, snapshot.VehicleCount,
+		snapshot.AveragePrice, snapshot.AverageDaysOnLot, snapshot.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save inventory snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// ListInventorySnapshots retrieves a dealership's snapshots between from and
+// to inclusive, oldest first
+func (db *Database) ListInventorySnapshots(dealershipID string, from, to time.Time) ([]*InventorySnapshot, error) {
+	query := `
+		SELECT dealership_id, TO_CHAR(snapshot_date, 'YYYY-MM-DD'), vehicle_count,
+			   average_price, average_days_on_lot, created_at
+		FROM inventory_snapshots
+		WHERE dealership_id = $1 AND snapshot_date BETWEEN $2 AND $3
+		ORDER BY snapshot_date ASC
+	`
+
+	rows, err := db.conn.Query(query, dealershipID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list inventory snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []*InventorySnapshot
+	for rows.Next() {
+		var snapshot InventorySnapshot
+		err := rows.Scan(
+			&snapshot.DealershipID, &snapshot.SnapshotDate, &snapshot.VehicleCount,
+			&snapshot.AveragePrice, &snapshot.AverageDaysOnLot, &snapshot.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan inventory snapshot: %w", err)
+		}
+		snapshots = append(snapshots, &snapshot)
+	}
+
+	return snapshots, rows.Err()
+}
+
+// ListVehicleFacets returns the canonical values of field (make or model) with
+// their vehicle counts, alphabetically. Models can be limited to a make.
+func (db *Database) ListVehicleFacets(dealershipID, field, make string) ([]*VehicleFacet, error) {
+	column := "make"
+	if field == SynonymFieldModel {
+		column = "model"
+	}
+
+	query := fmt.Sprintf("SELECT %s, COUNT(*) FROM vehicles WHERE deleted_at IS NULL", column)
+
+	var args []interface{}
+	argIndex := 1
+
+	if dealershipID != "" {
+		query += fmt.Sprintf(" AND dealership_id = $%d", argIndex)
+		args = append(args, dealershipID)
+		argIndex++
+	}
+
+	if field == SynonymFieldModel && make != "" {
+		query += fmt.Sprintf(" AND make = $%d", argIndex)
+		args = append(args, make)
+		argIndex++
+	}
+
+	query += fmt.Sprintf(" GROUP BY %s ORDER BY %s", column, column)
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vehicle facets: %w", err)
+	}
+	defer rows.Close()
+
+	var facets []*VehicleFacet
+	for rows.Next() {
+		var facet VehicleFacet
+		if err := rows.Scan(&facet.Value, &facet.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan vehicle facet: %w", err)
+		}
+		facets = append(facets, &facet)
+	}
+
+	return facets, rows.Err()
+}
+
+// ListVehicleSynonyms retrieves all stored make and model synonyms
+func (db *Database) ListVehicleSynonyms() ([]*VehicleSynonym, error) {
+	query := `
+		SELECT id, field, make, synonym, canonical, created_at
+		FROM vehicle_synonyms
+		ORDER BY field, make, synonym
+	`
+
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vehicle synonyms: %w", err)
+	}
+	defer rows.Close()
+
+	var synonyms []*VehicleSynonym
+	for rows.Next() {
+		var synonym VehicleSynonym
+		if err := rows.Scan(
+			&synonym.ID, &synonym.Field, &synonym.Make,
+			&synonym.Synonym, &synonym.Canonical, &synonym.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan vehicle synonym: %w", err)
+		}
+		synonyms = append(synonyms, &synonym)
+	}
+
+	return synonyms, rows.Err()
+}
+
+// UpsertVehicleSynonym saves a synonym, replacing the canonical value of an
+// existing synonym for the same field and make
+func (db *Database) UpsertVehicleSynonym(synonym *VehicleSynonym) error {
+	query := `
+		INSERT INTO vehicle_synonyms (id, field, make, synonym, canonical, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (field, LOWER(make), LOWER(synonym)) DO UPDATE SET
+			canonical = EXCLUDED.canonical
+		RETURNING id, created_at
+	`
+
+	err := db.conn.QueryRow(
+		query,
+		synonym.ID, synonym.Field, synonym.Make,
+		synonym.Synonym, synonym.Canonical, synonym.CreatedAt,
+	).Scan(&synonym.ID, &synonym.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save vehicle synonym: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteVehicleSynonym deletes a synonym by ID
+func (db *Database) DeleteVehicleSynonym(id string) error {
+	result, err := db.conn.Exec(`DELETE FROM vehicle_synonyms WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete vehicle synonym: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("synonym not found: %s", id)
+	}
+
+	return nil
+}
+
+// ApplyVehicleSynonym sets the canonical value on vehicles whose raw make or
+// model matches the synonym, returning the number of vehicles updated
+func (db *Database) ApplyVehicleSynonym(synonym *VehicleSynonym) (int64, error) {
+	var result sql.Result
+	var err error
+
+	if synonym.Field == SynonymFieldMake {
+		result, err = db.conn.Exec(`
+			UPDATE vehicles SET make = $2, updated_at = NOW()
+			WHERE LOWER(TRIM(COALESCE(make_raw, make))) = LOWER($1) AND make <> $2
+		`, synonym.Synonym, synonym.Canonical)
+	} else {
+		result, err = db.conn.Exec(`
+			UPDATE vehicles SET model = $2, updated_at = NOW()
+			WHERE LOWER(TRIM(COALESCE(model_raw, model))) = LOWER($1) AND model <> $2
+			  AND ($3 = '' OR make = $3)
+		`, synonym.Synonym, synonym.Canonical, synonym.Make)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to apply vehicle synonym: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// vehicleWatchColumns are the columns scanned by scanVehicleWatch
+const vehicleWatchColumns = `id, vehicle_id, dealership_id, COALESCE(customer_id, ''), email,
+	alert_price, unsubscribe_token, created_at`
+
+func scanVehicleWatch(row interface{ Scan(...interface{}) error }) (*VehicleWatch, error) {
+	var watch VehicleWatch
+	err := row.Scan(
+		&watch.ID, &watch.VehicleID, &watch.DealershipID, &watch.CustomerID, &watch.Email,
+		&watch.AlertPrice, &watch.UnsubscribeToken, &watch.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &watch, nil
+}
+
+// CreateVehicleWatch saves a watch unless the email already watches the
+// vehicle. A removed watch is reactivated from the current price.
+func (db *Database) CreateVehicleWatch(watch *VehicleWatch) (bool, error) {
+	var existing VehicleWatch
+	var removed bool
+	err := db.conn.QueryRow(`
+		SELECT id, COALESCE(customer_id, ''), alert_price, unsubscribe_token, created_at, removed_at IS NOT NULL
+		FROM vehicle_watches
+		WHERE vehicle_id = $1 AND LOWER(email) = LOWER($2)
+	`, watch.VehicleID, watch.Email).Scan(
+		&existing.ID, &existing.CustomerID, &existing.AlertPrice,
+		&existing.UnsubscribeToken, &existing.CreatedAt, &removed,
+	)
+
+	switch {
+	case err == nil && !removed:
+		watch.ID = existing.ID
+		watch.CustomerID = existing.CustomerID
+		watch.AlertPrice = existing.AlertPrice
+		watch.UnsubscribeToken = existing.UnsubscribeToken
+		watch.CreatedAt = existing.CreatedAt
+		return false, nil
+
+	case err == nil:
+		_, err = db.conn.Exec(`
+			UPDATE vehicle_watches
+			SET removed_at = NULL, alert_price = $2, created_at = $3,
+				customer_id = COALESCE(NULLIF($4, ''), customer_id)
+			WHERE id = $1
+		`, existing.ID, watch.AlertPrice, watch.CreatedAt, watch.CustomerID)
+		if err != nil {
+			return false, fmt.Errorf("failed to reactivate vehicle watch: %w", err)
+		}
+		watch.ID = existing.ID
+		watch.UnsubscribeToken = existing.UnsubscribeToken
+		if watch.CustomerID == "" {
+			watch.CustomerID = existing.CustomerID
+		}
+		return true, nil
+
+	case err != sql.ErrNoRows:
+		return false, fmt.Errorf("failed to get vehicle watch: %w", err)
+	}
+
+	_, err = db.conn.Exec(`
+		INSERT INTO vehicle_watches (
+			id, vehicle_id, dealership_id, customer_id, email, alert_price, unsubscribe_token, created_at
+		) VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8)
+	`, watch.ID, watch.VehicleID, watch.DealershipID, watch.CustomerID, watch.Email,
+		watch.AlertPrice, watch.UnsubscribeToken, watch.CreatedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to create vehicle watch: %w", err)
+	}
+
+	return true, nil
+}
+
+// queryVehicleWatches runs a vehicle watch query and scans the results
+func (db *Database) queryVehicleWatches(query string, args ...interface{}) ([]*VehicleWatch, error) {
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var watches []*VehicleWatch
+	for rows.Next() {
+		watch, err := scanVehicleWatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		watches = append(watches, watch)
+	}
+
+	return watches, rows.Err()
+}
+
+// ListVehicleWatches retrieves a vehicle's active watches, oldest first
+func (db *Database) ListVehicleWatches(vehicleID string) ([]*VehicleWatch, error) {
+	watches, err := db.queryVehicleWatches(`
+		SELECT `+vehicleWatchColumns+`
+		FROM vehicle_watches
+		WHERE vehicle_id = $1 AND removed_at IS NULL
+		ORDER BY created_at ASC
+	`, vehicleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vehicle watches: %w", err)
+	}
+	return watches, nil
+}
+
+// ListNotifiableWatches retrieves the active watches to alert about a drop
+// to price. Customers whose consent record withdraws marketing email are
+// skipped; consent is kept in customer_consent by data-retention-service.
+func (db *Database) ListNotifiableWatches(vehicleID string, price float64) ([]*VehicleWatch, error) {
+	watches, err := db.queryVehicleWatches(`
+		SELECT `+vehicleWatchColumns+`
+		FROM vehicle_watches w
+		WHERE w.vehicle_id = $1 AND w.removed_at IS NULL AND w.alert_price > $2
+		  AND NOT EXISTS (
+			SELECT 1 FROM customer_consent cc
+			WHERE cc.customer_id::text = w.customer_id
+			  AND cc.dealership_id::text = w.dealership_id
+			  AND NOT cc.marketing_email
+		  )
+	`, vehicleID, price)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notifiable vehicle watches: %w", err)
+	}
+	return watches, nil
+}
+
+// MarkWatchNotified records the price a watcher was last alerted about
+func (db *Database) MarkWatchNotified(id string, price float64) error {
+	if _, err := db.conn.Exec(`UPDATE vehicle_watches SET alert_price = $2 WHERE id = $1`, id, price); err != nil {
+		return fmt.Errorf("failed to update vehicle watch: %w", err)
+	}
+	return nil
+}
+
+// RemoveVehicleWatch ends the watch with the given unsubscribe token.
+// Removing an already removed watch succeeds.
+func (db *Database) RemoveVehicleWatch(token string) (*VehicleWatch, error) {
+	watch, err := scanVehicleWatch(db.conn.QueryRow(`
+		UPDATE vehicle_watches SET removed_at = COALESCE(removed_at, NOW())
+		WHERE unsubscribe_token = $1
+		RETURNING `+vehicleWatchColumns, token))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove vehicle watch: %w", err)
+	}
+	return watch, nil
+}
+
+// HasActiveDeal reports whether the vehicle is on a draft, pending or
+// approved deal. Deals are kept in the deals table by deal-service.
+func (db *Database) HasActiveDeal(vehicleID string) (bool, error) {
+	var exists bool
+	err := db.conn.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM deals
+			WHERE vehicle_id = $1 AND status IN ('draft', 'pending', 'approved')
+		)
+	`, vehicleID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check active deals: %w", err)
+	}
+	return exists, nil
+}
+
+// TransferVehicle moves a vehicle to another dealership and records the
+// transfer in one transaction. The move only applies while the vehicle is
+// still at the transfer's source dealership.
+func (db *Database) TransferVehicle(transfer *VehicleTransfer) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transfer: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE vehicles SET dealership_id = $3, lot_arrived_at = $4, updated_at = $4
+		WHERE id = $1 AND dealership_id = $2
+	`, transfer.VehicleID, transfer.FromDealershipID, transfer.ToDealershipID, transfer.TransferredAt)
+	if err != nil {
+		return fmt.Errorf("failed to transfer vehicle: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to transfer vehicle: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("vehicle not found: %s", transfer.VehicleID)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO vehicle_transfers (
+			id, vehicle_id, from_dealership_id, to_dealership_id, dealer_group_id,
+			reason, transferred_by, transferred_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, transfer.ID, transfer.VehicleID, transfer.FromDealershipID, transfer.ToDealershipID,
+		transfer.DealerGroupID, transfer.Reason, transfer.TransferredBy, transfer.TransferredAt)
+	if err != nil {
+		return fmt.Errorf("failed to record vehicle transfer: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transfer: %w", err)
+	}
+	return nil
+}
+
+// ListVehicleTransfers retrieves a vehicle's transfers, oldest first
+func (db *Database) ListVehicleTransfers(vehicleID string) ([]*VehicleTransfer, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, vehicle_id, from_dealership_id, to_dealership_id, dealer_group_id,
+			   COALESCE(reason, ''), COALESCE(transferred_by, ''), transferred_at
+		FROM vehicle_transfers
+		WHERE vehicle_id = $1
+		ORDER BY transferred_at ASC
+	`, vehicleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vehicle transfers: %w", err)
+	}
+	defer rows.Close()
+
+	var transfers []*VehicleTransfer
+	for rows.Next() {
+		var transfer VehicleTransfer
+		err := rows.Scan(
+			&transfer.ID, &transfer.VehicleID, &transfer.FromDealershipID, &transfer.ToDealershipID,
+			&transfer.DealerGroupID, &transfer.Reason, &transfer.TransferredBy, &transfer.TransferredAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan vehicle transfer: %w", err)
+		}
+		transfers = append(transfers, &transfer)
+	}
+
+	return transfers, rows.Err()
+}
+
+// ListVehiclePhotos retrieves a vehicle's photos ordered by position
+func (db *Database) ListVehiclePhotos(vehicleID string) ([]*VehiclePhoto, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, vehicle_id, position, url, content_type, COALESCE(filename, ''), is_primary, created_at
+		FROM vehicle_photos
+		WHERE vehicle_id = $1
+		ORDER BY position ASC
+	`, vehicleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vehicle photos: %w", err)
+	}
+	defer rows.Close()
+
+	var photos []*VehiclePhoto
+	for rows.Next() {
+		var photo VehiclePhoto
+		err := rows.Scan(
+			&photo.ID, &photo.VehicleID, &photo.Position, &photo.URL,
+			&photo.ContentType, &photo.Filename, &photo.IsPrimary, &photo.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan vehicle photo: %w", err)
+		}
+		photos = append(photos, &photo)
+	}
+
+	return photos, rows.Err()
+}
+
+// AddVehiclePhotos records photos for a vehicle in one transaction. A
+// primary photo also becomes the vehicle's image_url.
+func (db *Database) AddVehiclePhotos(vehicleID string, photos []*VehiclePhoto) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin photo import: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, photo := range photos {
+		_, err := tx.Exec(`
+			INSERT INTO vehicle_photos (id, vehicle_id, position, url, content_type, filename, is_primary, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, photo.ID, vehicleID, photo.Position, photo.URL, photo.ContentType, photo.Filename, photo.IsPrimary, photo.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to add vehicle photo: %w", err)
+		}
+
+		if photo.IsPrimary {
+			if _, err := tx.Exec(`UPDATE vehicles SET image_url = $2, updated_at = NOW() WHERE id = $1`, vehicleID, photo.URL); err != nil {
+				return fmt.Errorf("failed to set primary photo: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit photo import: %w", err)
+	}
+	return nil
+}
+
+// RecordSearch adds a search to its day's aggregate
+func (db *Database) RecordSearch(event *SearchEvent) error {
+	noResults := 0
+	if event.ResultCount == 0 {
+		noResults = 1
+	}
+
+	query := `
+		INSERT INTO search_analytics (
+			dealership_id, search_date, make, model, price_band, query, searches, no_result_searches
+		) VALUES ($1, $2, $3, $4, $5, $6, 1, $7)
+		ON CONFLICT (dealership_id, search_date, make, model, price_band, query) DO UPDATE SET
+			searches = search_analytics.searches + 1,
+			no_result_searches = search_analytics.no_result_searches + EXCLUDED.no_result_searches
+	`
+
+	_, err := db.conn.Exec(
+		query,
+		event.DealershipID, event.SearchDate, event.Make, event.Model,
+		event.PriceBand, event.Query, noResults,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record search: %w", err)
+	}
+
+	return nil
+}
+
+// ListSearchAggregates sums a dealership's searches between from and to
+// inclusive
+func (db *Database) ListSearchAggregates(dealershipID string, from, to time.Time) ([]*SearchAggregate, error) {
+	query := `
+		SELECT make, model, price_band, query, SUM(searches), SUM(no_result_searches)
+		FROM search_analytics
+		WHERE dealership_id = $1 AND search_date BETWEEN $2 AND $3
+		GROUP BY make, model, price_band, query
+	`
+
+	rows, err := db.conn.Query(query, dealershipID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list search analytics: %w", err)
+	}
+	defer rows.Close()
+
+	var aggregates []*SearchAggregate
+	for rows.Next() {
+		var a SearchAggregate
+		if err := rows.Scan(&a.Make, &a.Model, &a.PriceBand, &a.Query, &a.Searches, &a.NoResultSearches); err != nil {
+			return nil, fmt.Errorf("failed to scan search analytics: %w", err)
+		}
+		aggregates = append(aggregates, &a)
+	}
+
+	return aggregates, rows.Err()
+}
@@ -1,13 +1,20 @@
 package main
 
+import (
+	"context"
+	"time"
+)
+
 // Vehicle represents a vehicle in inventory
 type Vehicle struct {
 	ID           string  `json:"id"`
 	DealershipID string  `json:"dealership_id"`
 	VIN          string  `json:"vin"`
 	StockNumber  string  `json:"stock_number"`
-	Make         string  `json:"make"`
-	Model        string  `json:"model"`
+	Make         string  `json:"make"`  // canonical
+	Model        string  `json:"model"` // canonical
+	MakeRaw      string  `json:"make_raw,omitempty"`
+	ModelRaw     string  `json:"model_raw,omitempty"`
 	Year         int     `json:"year"`
 	Trim         string  `json:"trim"`
 	Condition    string  `json:"condition"` // new, used, certified
@@ -25,17 +32,107 @@ type Vehicle struct {
 	Features     string  `json:"features"` // JSON array stored as string
 	CreatedAt    string  `json:"created_at"`
 	UpdatedAt    string  `json:"updated_at"`
+
+	// LotArrivedAt is when the vehicle arrived at its current dealership's
+	// lot, used for days on lot. It is reset when the vehicle is transferred
+	// and falls back to CreatedAt.
+	LotArrivedAt string `json:"lot_arrived_at,omitempty"`
+
+	// DeletedAt is set when the vehicle has been deleted. Deleted vehicles
+	// are kept for the deals that reference them and can be restored.
+	DeletedAt *string `json:"deleted_at,omitempty"`
+
+	// Cost and Margin are only returned to callers with the inventory:cost scope
+	Cost   *float64 `json:"cost,omitempty"`
+	Margin *float64 `json:"margin,omitempty"` // price - cost, computed on read
+
+	// Warnings are returned on writes but never persisted
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// PriceHistoryEntry records a single change to a vehicle's price or cost
+type PriceHistoryEntry struct {
+	ID        string  `json:"id"`
+	VehicleID string  `json:"vehicle_id"`
+	Field     string  `json:"field"` // price, cost
+	OldValue  float64 `json:"old_value"`
+	NewValue  float64 `json:"new_value"`
+	ChangedBy string  `json:"changed_by,omitempty"`
+	ChangedAt string  `json:"changed_at"`
+}
+
+// InventorySnapshot is a daily summary of a dealership's on-lot inventory
+type InventorySnapshot struct {
+	DealershipID     string  `json:"dealership_id"`
+	SnapshotDate     string  `json:"snapshot_date"` // YYYY-MM-DD, UTC
+	VehicleCount     int     `json:"vehicle_count"`
+	AveragePrice     float64 `json:"average_price"`
+	AverageDaysOnLot float64 `json:"average_days_on_lot"`
+	CreatedAt        string  `json:"created_at"`
 }
 
 // VehicleDatabase defines the interface for vehicle database operations
 type VehicleDatabase interface {
 	Close() error
 	InitSchema() error
+
+	// Ping checks the database is reachable, for health checks
+	Ping(ctx context.Context) error
 	CreateVehicle(vehicle *Vehicle) error
+	// BulkCreateVehicles saves vehicles in one transaction and returns the
+	// error, if any, for each vehicle in order. With allOrNothing nothing is
+	// saved if any vehicle fails.
+	BulkCreateVehicles(vehicles []*Vehicle, allOrNothing bool) ([]error, error)
 	GetVehicle(id string) (*Vehicle, error)
 	ListVehicles(dealershipID string, filters map[string]interface{}) ([]*Vehicle, error)
 	UpdateVehicle(vehicle *Vehicle) error
 	DeleteVehicle(id string) error
+	RestoreVehicle(id string) error
 	ValidateVIN(vin string) (bool, error)
 	GetInventoryStats(dealershipID string) (map[string]interface{}, error)
+	GetAgingReport(dealershipID string) ([]*AgingBucket, error)
+	CreatePriceHistory(entry *PriceHistoryEntry) error
+	ListPriceHistory(vehicleID string) ([]*PriceHistoryEntry, error)
+	ListDealershipIDs() ([]string, error)
+	UpsertInventorySnapshot(snapshot *InventorySnapshot) error
+	ListInventorySnapshots(dealershipID string, from, to time.Time) ([]*InventorySnapshot, error)
+	ListVehicleFacets(dealershipID, field, make string) ([]*VehicleFacet, error)
+	ListVehicleSynonyms() ([]*VehicleSynonym, error)
+	UpsertVehicleSynonym(synonym *VehicleSynonym) error
+	DeleteVehicleSynonym(id string) error
+	ApplyVehicleSynonym(synonym *VehicleSynonym) (int64, error)
+
+	// CreateVehicleWatch saves a watch, or fills watch with the existing
+	// active watch for the same vehicle and email. A removed watch is
+	// reactivated. Reports whether a new watch was started.
+	CreateVehicleWatch(watch *VehicleWatch) (bool, error)
+	ListVehicleWatches(vehicleID string) ([]*VehicleWatch, error)
+	// ListNotifiableWatches returns active watches whose alert price is above
+	// price, excluding customers who have withdrawn marketing email consent
+	ListNotifiableWatches(vehicleID string, price float64) ([]*VehicleWatch, error)
+	MarkWatchNotified(id string, price float64) error
+	// RemoveVehicleWatch ends the watch with the given unsubscribe token and
+	// returns it, or nil if no watch has the token
+	RemoveVehicleWatch(token string) (*VehicleWatch, error)
+
+	// HasActiveDeal reports whether the vehicle is on a deal that is still
+	// being worked
+	HasActiveDeal(vehicleID string) (bool, error)
+	// TransferVehicle moves a vehicle to transfer.ToDealershipID, resetting
+	// its lot arrival time, and records the transfer
+	TransferVehicle(transfer *VehicleTransfer) error
+	ListVehicleTransfers(vehicleID string) ([]*VehicleTransfer, error)
+
+	// ListVehiclePhotos returns a vehicle's photos ordered by position
+	ListVehiclePhotos(vehicleID string) ([]*VehiclePhoto, error)
+	// AddVehiclePhotos records photos for a vehicle. A primary photo also
+	// becomes the vehicle's image_url.
+	AddVehiclePhotos(vehicleID string, photos []*VehiclePhoto) error
+
+	// RecordSearch adds a search to its day's aggregate for the same make,
+	// model, price band and query
+	RecordSearch(event *SearchEvent) error
+	// ListSearchAggregates sums a dealership's recorded searches between from
+	// and to inclusive
+	ListSearchAggregates(dealershipID string, from, to time.Time) ([]*SearchAggregate, error)
 }
@@ -3,14 +3,40 @@ module autolytiq/inventory-service
 go 1.18
 
 require (
+	autolytiq/shared/apierror v0.0.0
+	autolytiq/shared/bodylimit v0.0.0
+	autolytiq/shared/configclient v0.0.0
+	autolytiq/shared/graceful v0.0.0
+	autolytiq/shared/health v0.0.0
+	autolytiq/shared/httpmetrics v0.0.0
 	autolytiq/shared/logging v0.0.0
+	autolytiq/shared/tracing v0.0.0
+	github.com/aws/aws-sdk-go-v2 v1.24.0
+	github.com/aws/aws-sdk-go-v2/config v1.26.1
+	github.com/aws/aws-sdk-go-v2/service/s3 v1.47.5
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/mux v1.8.1
+	github.com/gorilla/websocket v1.5.0
 	github.com/lib/pq v1.10.9
 	github.com/prometheus/client_golang v1.18.0
 )
 
 require (
+	github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream v1.5.4 // indirect
+	github.com/aws/aws-sdk-go-v2/credentials v1.16.12 // indirect
+	github.com/aws/aws-sdk-go-v2/feature/ec2/imds v1.14.10 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/configsources v1.2.9 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/endpoints/v2 v2.5.9 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/ini v1.7.2 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/v4a v1.2.9 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/accept-encoding v1.10.4 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/checksum v1.2.9 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/presigned-url v1.10.9 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/s3shared v1.16.9 // indirect
+	github.com/aws/aws-sdk-go-v2/service/sso v1.18.5 // indirect
+	github.com/aws/aws-sdk-go-v2/service/ssooidc v1.21.5 // indirect
+	github.com/aws/aws-sdk-go-v2/service/sts v1.26.5 // indirect
+	github.com/aws/smithy-go v1.19.0 // indirect
 	github.com/beorn7/perks v1.0.1 // indirect
 	github.com/cespare/xxhash/v2 v2.2.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
@@ -24,4 +50,18 @@ require (
 	google.golang.org/protobuf v1.31.0 // indirect
 )
 
+replace autolytiq/shared/configclient => ../shared/configclient
+
 replace autolytiq/shared/logging => ../shared/logging
+
+replace autolytiq/shared/health => ../shared/health
+
+replace autolytiq/shared/graceful => ../shared/graceful
+
+replace autolytiq/shared/apierror => ../shared/apierror
+
+replace autolytiq/shared/bodylimit => ../shared/bodylimit
+
+replace autolytiq/shared/httpmetrics => ../shared/httpmetrics
+
+replace autolytiq/shared/tracing => ../shared/tracing
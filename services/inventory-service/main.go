@@ -1,14 +1,22 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/bodylimit"
+	"autolytiq/shared/graceful"
+	"autolytiq/shared/health"
+	"autolytiq/shared/httpmetrics"
 	"autolytiq/shared/logging"
+	"autolytiq/shared/tracing"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -18,23 +26,78 @@ import (
 type Config struct {
 	Port        string
 	DatabaseURL string
+
+	// EmailServiceURL is where price drop alerts are sent; alerts are
+	// disabled when empty
+	EmailServiceURL string
+
+	// PublicBaseURL is the API gateway's public URL, used for links in emails
+	PublicBaseURL string
+
+	// ConfigServiceURL is used to read the dealer group each dealership
+	// belongs to; transfers are unavailable when empty
+	ConfigServiceURL string
+
+	// Vehicle photo storage; bulk photo import is unavailable when
+	// PhotoBucket is empty. PhotoBaseURL is the public URL photos are served
+	// from, defaulting to the bucket's URL.
+	PhotoBucket  string
+	PhotoRegion  string
+	PhotoBaseURL string
+
+	// SearchSampleRate is the fraction of vehicle searches recorded for
+	// search insights, from 0 (off) to 1 (every search)
+	SearchSampleRate float64
 }
 
 // Server represents the Inventory service server
 type Server struct {
-	router *mux.Router
-	config *Config
-	db     VehicleDatabase
-	logger *logging.Logger
+	router     *mux.Router
+	config     *Config
+	db         VehicleDatabase
+	logger     *logging.Logger
+	health     *health.HealthChecker
+	metrics    *httpmetrics.Metrics
+	tracer     *tracing.Tracer
+	normalizer *Normalizer
+	notifier   PriceDropNotifier
+	photos     PhotoStore
+	hub        *Hub
+	vinDecoder VehicleInfoDecoder
+
+	dealerGroups DealerGroupSettings
 }
 
 // NewServer creates a new Inventory service server
 func NewServer(config *Config, db VehicleDatabase, logger *logging.Logger) *Server {
 	s := &Server{
-		router: mux.NewRouter(),
-		config: config,
-		db:     db,
-		logger: logger,
+		router:     mux.NewRouter(),
+		config:     config,
+		db:         db,
+		logger:     logger,
+		health:     health.NewHealthChecker("inventory-service", db.Ping),
+		metrics:    httpmetrics.New("inventory-service"),
+		normalizer: NewNormalizer(),
+		hub:        NewHub(logger),
+		vinDecoder: NewVINDecoderService(),
+		tracer: tracing.New(tracing.Config{
+			Service: "inventory-service",
+			OnError: func(err error) { logger.WithError(err).Warn("Failed to export trace spans") },
+		}),
+	}
+	go s.hub.Run()
+	if config.EmailServiceURL != "" {
+		s.notifier = NewEmailPriceDropNotifier(config.EmailServiceURL, config.PublicBaseURL)
+	}
+	if config.ConfigServiceURL != "" {
+		s.dealerGroups = newSettingsClient(config.ConfigServiceURL)
+	}
+	if config.PhotoBucket != "" {
+		if store, err := newS3PhotoStore(context.Background(), config.PhotoBucket, config.PhotoRegion, config.PhotoBaseURL); err != nil {
+			logger.WithError(err).Warn("Photo storage unavailable, bulk photo import disabled")
+		} else {
+			s.photos = store
+		}
 	}
 
 	s.setupMiddleware()
@@ -44,32 +107,51 @@ func NewServer(config *Config, db VehicleDatabase, logger *logging.Logger) *Serv
 
 // setupMiddleware configures middleware
 func (s *Server) setupMiddleware() {
+	s.router.Use(s.tracer.Middleware)
 	s.router.Use(logging.RequestIDMiddleware)
 	s.router.Use(logging.RequestLoggingMiddleware(s.logger))
+	s.router.Use(s.metrics.Middleware)
+	s.router.Use(bodylimit.Middleware(bodylimit.Config{
+		BulkRoutes: []string{"/vehicles/bulk"},
+		Routes:     map[string]int64{"/vehicles/photos/bulk": maxPhotoZipSize},
+	}))
 }
 
 // setupRoutes configures all routes
 func (s *Server) setupRoutes() {
-	s.router.HandleFunc("/health", s.healthCheck).Methods("GET")
+	s.router.Handle("/health", s.health).Methods("GET")
+	s.router.Handle("/metrics", s.metrics.Handler()).Methods("GET")
+	s.router.HandleFunc("/ready", s.health.ReadinessHandler).Methods("GET")
+	s.router.HandleFunc("/live", s.health.LivenessHandler).Methods("GET")
+	s.router.HandleFunc("/ws/inventory", s.serveWS).Methods("GET")
 	s.router.HandleFunc("/vehicles", s.listVehicles).Methods("GET")
 	s.router.HandleFunc("/vehicles", s.createVehicle).Methods("POST")
+	s.router.HandleFunc("/vehicles/bulk", s.bulkCreateVehicles).Methods("POST")
 	s.router.HandleFunc("/vehicles/stats", s.getInventoryStats).Methods("GET")
+	s.router.HandleFunc("/vehicles/stats/trend", s.getInventoryStatsTrend).Methods("GET")
+	s.router.HandleFunc("/vehicles/aging", s.getAgingReport).Methods("GET")
+	s.router.HandleFunc("/vehicles/search-insights", s.getSearchInsights).Methods("GET")
 	s.router.HandleFunc("/vehicles/validate-vin", s.validateVIN).Methods("POST")
 	s.router.HandleFunc("/vehicles/decode-vin", s.decodeVINHandler).Methods("POST")
+	s.router.HandleFunc("/vehicles/makes", s.listVehicleMakes).Methods("GET")
+	s.router.HandleFunc("/vehicles/models", s.listVehicleModels).Methods("GET")
+	s.router.HandleFunc("/vehicles/synonyms", s.listVehicleSynonyms).Methods("GET")
+	s.router.HandleFunc("/vehicles/synonyms", s.upsertVehicleSynonym).Methods("PUT")
+	s.router.HandleFunc("/vehicles/synonyms/{id}", s.deleteVehicleSynonym).Methods("DELETE")
+	s.router.HandleFunc("/vehicles/watches/unsubscribe", s.unsubscribeVehicleWatch).Methods("GET", "POST")
+	s.router.HandleFunc("/vehicles/photos/bulk", s.bulkImportPhotos).Methods("POST")
+	s.router.HandleFunc("/vehicles/compare", s.compareVehiclesHandler).Methods("POST")
 	s.router.HandleFunc("/vehicles/{id}", s.getVehicle).Methods("GET")
 	s.router.HandleFunc("/vehicles/{id}", s.updateVehicle).Methods("PUT")
+	s.router.HandleFunc("/vehicles/{id}", s.patchVehicle).Methods("PATCH")
 	s.router.HandleFunc("/vehicles/{id}", s.deleteVehicle).Methods("DELETE")
-}
-
-// healthCheck handler
-func (s *Server) healthCheck(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":    "healthy",
-		"service":   "inventory-service",
-		"timestamp": time.Now().Format(time.RFC3339),
-	})
+	s.router.HandleFunc("/vehicles/{id}/restore", s.restoreVehicle).Methods("POST")
+	s.router.HandleFunc("/vehicles/{id}/price-history", s.getPriceHistory).Methods("GET")
+	s.router.HandleFunc("/vehicles/{id}/watch", s.watchVehicle).Methods("POST")
+	s.router.HandleFunc("/vehicles/{id}/watchers", s.listVehicleWatchers).Methods("GET")
+	s.router.HandleFunc("/vehicles/{id}/transfer", s.transferVehicle).Methods("POST")
+	s.router.HandleFunc("/vehicles/{id}/transfers", s.listVehicleTransfers).Methods("GET")
+	s.router.HandleFunc("/vehicles/{id}/photos", s.listVehiclePhotos).Methods("GET")
 }
 
 // listVehicles returns all vehicles with optional filters
@@ -80,12 +162,15 @@ func (s *Server) listVehicles(w http.ResponseWriter, r *http.Request) {
 	// Build filters map from query parameters
 	filters := make(map[string]interface{})
 
-	if make := r.URL.Query().Get("make"); make != "" {
+	// Make and model filters match canonical values
+	make := r.URL.Query().Get("make")
+	if make != "" {
+		make = s.normalizer.NormalizeMake(make)
 		filters["make"] = make
 	}
 
 	if model := r.URL.Query().Get("model"); model != "" {
-		filters["model"] = model
+		filters["model"] = s.normalizer.NormalizeModel(make, model)
 	}
 
 	if yearStr := r.URL.Query().Get("year"); yearStr != "" {
@@ -114,35 +199,87 @@ func (s *Server) listVehicles(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Free-text search matches make, model, trim, VIN or stock number
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q != "" {
+		filters["q"] = q
+	}
+
+	if r.URL.Query().Get("include_deleted") == "true" {
+		if !hasScope(r, ScopeInventoryManage) {
+			respondErrorJSON(w, http.StatusForbidden, "Listing deleted vehicles requires the "+ScopeInventoryManage+" scope", "INSUFFICIENT_SCOPE")
+			return
+		}
+		filters["include_deleted"] = true
+	}
+
 	vehicles, err := s.db.ListVehicles(dealershipID, filters)
 	if err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list vehicles")
-		http.Error(w, fmt.Sprintf("Failed to list vehicles: %v", err), http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to list vehicles: %v", err))
 		return
 	}
 
+	s.recordSearch(r, dealershipID, filters, q, len(vehicles))
+
 	if vehicles == nil {
 		vehicles = []*Vehicle{}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(vehicles)
+	json.NewEncoder(w).Encode(presentVehicles(r, vehicles))
 }
 
 // createVehicle creates a new vehicle
 func (s *Server) createVehicle(w http.ResponseWriter, r *http.Request) {
 	var req CreateVehicleRequest
-	if !decodeAndValidate(r, w, &req) {
+	if r.URL.Query().Get("decode") == "true" {
+		// The make, model and year may be left for the VIN to fill in, so
+		// they are validated after decoding
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondErrorJSON(w, http.StatusBadRequest, "Invalid request body: "+err.Error(), "INVALID_JSON")
+			return
+		}
+		req.Sanitize()
+		if !s.populateFromVIN(w, r, &req) || !sanitizeAndValidate(w, &req) {
+			return
+		}
+	} else if !decodeAndValidate(r, w, &req) {
+		return
+	}
+	if !requireCostScope(w, r, req.Cost) {
+		return
+	}
+
+	vehicle := s.newVehicle(&req)
+
+	// Save to database
+	if err := s.db.CreateVehicle(&vehicle); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to create vehicle")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to create vehicle: %v", err))
 		return
 	}
 
-	// Map request to Vehicle
+	s.logger.WithContext(r.Context()).WithField("vehicle_id", vehicle.ID).Info("Vehicle created")
+	s.publishVehicleEvents(nil, &vehicle)
+
+	created := presentVehicle(r, &vehicle)
+	s.warnIfBelowCost(r, &vehicle, created)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// newVehicle maps a validated create request to a new vehicle with its
+// canonical make and model and default condition and status
+func (s *Server) newVehicle(req *CreateVehicleRequest) Vehicle {
 	vehicle := Vehicle{
 		ID:           uuid.New().String(),
 		DealershipID: req.DealershipID,
 		VIN:          req.VIN,
-		Make:         req.Make,
-		Model:        req.Model,
+		MakeRaw:      req.Make,
+		ModelRaw:     req.Model,
 		Year:         req.Year,
 		Condition:    req.Condition,
 		Status:       req.Status,
@@ -151,10 +288,13 @@ func (s *Server) createVehicle(w http.ResponseWriter, r *http.Request) {
 		Color:        req.Color,
 		Description:  req.Description,
 		StockNumber:  req.StockNumber,
+		Cost:         req.Cost,
 		CreatedAt:    time.Now().Format(time.RFC3339),
 		UpdatedAt:    time.Now().Format(time.RFC3339),
 	}
 
+	s.normalizer.Apply(&vehicle)
+
 	// Set defaults
 	if vehicle.Condition == "" {
 		vehicle.Condition = "used"
@@ -163,18 +303,7 @@ func (s *Server) createVehicle(w http.ResponseWriter, r *http.Request) {
 		vehicle.Status = "available"
 	}
 
-	// Save to database
-	if err := s.db.CreateVehicle(&vehicle); err != nil {
-		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to create vehicle")
-		http.Error(w, fmt.Sprintf("Failed to create vehicle: %v", err), http.StatusInternalServerError)
-		return
-	}
-
-	s.logger.WithContext(r.Context()).WithField("vehicle_id", vehicle.ID).Info("Vehicle created")
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(vehicle)
+	return vehicle
 }
 
 // getVehicle retrieves a specific vehicle
@@ -185,20 +314,22 @@ func (s *Server) getVehicle(w http.ResponseWriter, r *http.Request) {
 	vehicle, err := s.db.GetVehicle(id)
 	if err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get vehicle")
-		http.Error(w, fmt.Sprintf("Failed to get vehicle: %v", err), http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get vehicle: %v", err))
 		return
 	}
 
 	if vehicle == nil {
-		http.Error(w, "Vehicle not found", http.StatusNotFound)
+		apierror.WriteError(w, http.StatusNotFound, "VEHICLE_NOT_FOUND", "Vehicle not found")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(vehicle)
+	json.NewEncoder(w).Encode(presentVehicle(r, vehicle))
 }
 
-// updateVehicle updates a specific vehicle
+// updateVehicle handles PUT /vehicles/{id}, the full-replace update kept for
+// backward compatibility. Zero values are taken as not provided, so it can't
+// set a price or mileage of 0 or clear a field; use PATCH for that.
 func (s *Server) updateVehicle(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
@@ -211,69 +342,113 @@ func (s *Server) updateVehicle(w http.ResponseWriter, r *http.Request) {
 	if !decodeAndValidate(r, w, &req) {
 		return
 	}
+	if !requireCostScope(w, r, req.Cost) {
+		return
+	}
 
+	s.saveVehicleUpdate(w, r, id, func(vehicle *Vehicle) {
+		if req.VIN != "" {
+			vehicle.VIN = req.VIN
+		}
+		if req.Make != "" {
+			vehicle.MakeRaw = req.Make
+		}
+		if req.Model != "" {
+			vehicle.ModelRaw = req.Model
+		}
+		if req.Year != 0 {
+			vehicle.Year = req.Year
+		}
+		if req.Condition != "" {
+			vehicle.Condition = req.Condition
+		}
+		if req.Status != "" {
+			vehicle.Status = req.Status
+		}
+		if req.Price > 0 {
+			vehicle.Price = req.Price
+		}
+		if req.Mileage > 0 {
+			vehicle.Mileage = req.Mileage
+		}
+		if req.Color != "" {
+			vehicle.Color = req.Color
+		}
+		if req.Description != "" {
+			vehicle.Description = req.Description
+		}
+		if req.StockNumber != "" {
+			vehicle.StockNumber = req.StockNumber
+		}
+		if req.Cost != nil {
+			vehicle.Cost = req.Cost
+		}
+	})
+}
+
+// patchVehicle handles PATCH /vehicles/{id}, updating only the fields
+// present in the body. Unlike PUT, a field can be set to zero or empty.
+func (s *Server) patchVehicle(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if !validateUUID(w, id, "id") {
+		return
+	}
+
+	var req PatchVehicleRequest
+	if !decodeAndValidate(r, w, &req) {
+		return
+	}
+	if !requireCostScope(w, r, req.Cost) {
+		return
+	}
+
+	s.saveVehicleUpdate(w, r, id, req.Apply)
+}
+
+// saveVehicleUpdate applies an update to a vehicle, saves it and writes the
+// updated vehicle, recording and announcing price changes
+func (s *Server) saveVehicleUpdate(w http.ResponseWriter, r *http.Request, id string, apply func(vehicle *Vehicle)) {
 	// Get existing vehicle first
 	existingVehicle, err := s.db.GetVehicle(id)
 	if err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get vehicle")
-		http.Error(w, fmt.Sprintf("Failed to get vehicle: %v", err), http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get vehicle: %v", err))
 		return
 	}
 	if existingVehicle == nil {
-		http.Error(w, "Vehicle not found", http.StatusNotFound)
+		apierror.WriteError(w, http.StatusNotFound, "VEHICLE_NOT_FOUND", "Vehicle not found")
 		return
 	}
 
+	previous := *existingVehicle
+
 	// Apply updates
-	if req.VIN != "" {
-		existingVehicle.VIN = req.VIN
-	}
-	if req.Make != "" {
-		existingVehicle.Make = req.Make
-	}
-	if req.Model != "" {
-		existingVehicle.Model = req.Model
-	}
-	if req.Year != 0 {
-		existingVehicle.Year = req.Year
-	}
-	if req.Condition != "" {
-		existingVehicle.Condition = req.Condition
-	}
-	if req.Status != "" {
-		existingVehicle.Status = req.Status
-	}
-	if req.Price > 0 {
-		existingVehicle.Price = req.Price
-	}
-	if req.Mileage > 0 {
-		existingVehicle.Mileage = req.Mileage
-	}
-	if req.Color != "" {
-		existingVehicle.Color = req.Color
-	}
-	if req.Description != "" {
-		existingVehicle.Description = req.Description
-	}
-	if req.StockNumber != "" {
-		existingVehicle.StockNumber = req.StockNumber
-	}
+	apply(existingVehicle)
+	s.normalizer.Apply(existingVehicle)
 	existingVehicle.UpdatedAt = time.Now().Format(time.RFC3339)
 
 	if err := s.db.UpdateVehicle(existingVehicle); err != nil {
 		if err.Error() == fmt.Sprintf("vehicle not found: %s", id) {
-			http.Error(w, "Vehicle not found", http.StatusNotFound)
+			apierror.WriteError(w, http.StatusNotFound, "VEHICLE_NOT_FOUND", "Vehicle not found")
 		} else {
 			s.logger.WithContext(r.Context()).WithError(err).Error("Failed to update vehicle")
-			http.Error(w, fmt.Sprintf("Failed to update vehicle: %v", err), http.StatusInternalServerError)
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to update vehicle: %v", err))
 		}
 		return
 	}
 
+	s.recordPriceChanges(r, &previous, existingVehicle)
+	s.notifyPriceDrop(r, &previous, existingVehicle)
+	s.publishVehicleEvents(&previous, existingVehicle)
 	s.logger.WithContext(r.Context()).WithField("vehicle_id", id).Info("Vehicle updated")
 
+	updated := presentVehicle(r, existingVehicle)
+	s.warnIfBelowCost(r, existingVehicle, updated)
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(existingVehicle)
+	json.NewEncoder(w).Encode(updated)
 }
 
 // deleteVehicle deletes a specific vehicle
@@ -281,21 +456,64 @@ func (s *Server) deleteVehicle(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
+	// Look the vehicle up first so its dealership can be told it is gone
+	existing, err := s.db.GetVehicle(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Warn("Failed to get vehicle before delete")
+	}
+
 	if err := s.db.DeleteVehicle(id); err != nil {
 		if err.Error() == fmt.Sprintf("vehicle not found: %s", id) {
-			http.Error(w, "Vehicle not found", http.StatusNotFound)
+			apierror.WriteError(w, http.StatusNotFound, "VEHICLE_NOT_FOUND", "Vehicle not found")
 		} else {
 			s.logger.WithContext(r.Context()).WithError(err).Error("Failed to delete vehicle")
-			http.Error(w, fmt.Sprintf("Failed to delete vehicle: %v", err), http.StatusInternalServerError)
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to delete vehicle: %v", err))
 		}
 		return
 	}
 
 	s.logger.WithContext(r.Context()).WithField("vehicle_id", id).Info("Vehicle deleted")
+	if existing != nil {
+		s.publishVehicleEvents(existing, nil)
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// restoreVehicle undoes the delete of a vehicle
+func (s *Server) restoreVehicle(w http.ResponseWriter, r *http.Request) {
+	if !hasScope(r, ScopeInventoryManage) {
+		respondErrorJSON(w, http.StatusForbidden, "Restoring vehicles requires the "+ScopeInventoryManage+" scope", "INSUFFICIENT_SCOPE")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if err := s.db.RestoreVehicle(id); err != nil {
+		if err.Error() == fmt.Sprintf("vehicle not found: %s", id) {
+			apierror.WriteError(w, http.StatusNotFound, "DELETED_VEHICLE_NOT_FOUND", "Deleted vehicle not found")
+		} else {
+			s.logger.WithContext(r.Context()).WithError(err).Error("Failed to restore vehicle")
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to restore vehicle: %v", err))
+		}
+		return
+	}
+
+	vehicle, err := s.db.GetVehicle(id)
+	if err != nil || vehicle == nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get restored vehicle")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get restored vehicle: %v", err))
+		return
+	}
+
+	s.logger.WithContext(r.Context()).WithField("vehicle_id", id).Info("Vehicle restored")
+	s.publishVehicleEvents(nil, vehicle)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(presentVehicle(r, vehicle))
+}
+
 // validateVIN validates a VIN
 func (s *Server) validateVIN(w http.ResponseWriter, r *http.Request) {
 	var req ValidateVINRequest
@@ -306,7 +524,7 @@ func (s *Server) validateVIN(w http.ResponseWriter, r *http.Request) {
 	valid, err := s.db.ValidateVIN(req.VIN)
 	if err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to validate VIN")
-		http.Error(w, fmt.Sprintf("Failed to validate VIN: %v", err), http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to validate VIN: %v", err))
 		return
 	}
 
@@ -322,14 +540,14 @@ func (s *Server) getInventoryStats(w http.ResponseWriter, r *http.Request) {
 	// Required dealership filter
 	dealershipID := r.URL.Query().Get("dealership_id")
 	if dealershipID == "" {
-		http.Error(w, "dealership_id query parameter is required", http.StatusBadRequest)
+		apierror.WriteError(w, http.StatusBadRequest, "DEALERSHIP_ID_REQUIRED", "dealership_id query parameter is required")
 		return
 	}
 
 	stats, err := s.db.GetInventoryStats(dealershipID)
 	if err != nil {
 		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get inventory stats")
-		http.Error(w, fmt.Sprintf("Failed to get inventory stats: %v", err), http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get inventory stats: %v", err))
 		return
 	}
 
@@ -337,16 +555,25 @@ func (s *Server) getInventoryStats(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(stats)
 }
 
-// Start starts the Inventory service server
-func (s *Server) Start() error {
+// Start serves the Inventory service until ctx is cancelled, then waits for
+// in-flight requests to finish
+func (s *Server) Start(ctx context.Context) error {
 	s.logger.Infof("Starting Inventory Service on port %s", s.config.Port)
-	return http.ListenAndServe(":"+s.config.Port, s.router)
+	srv := &http.Server{Addr: ":" + s.config.Port, Handler: s.router}
+	return graceful.ListenAndServe(ctx, srv, graceful.DefaultTimeout)
 }
 
 func loadConfig() *Config {
 	return &Config{
-		Port:        getEnv("PORT", "8083"),
-		DatabaseURL: getEnv("DATABASE_URL", "postgresql://localhost:5432/autolytiq"),
+		Port:             getEnv("PORT", "8083"),
+		DatabaseURL:      getEnv("DATABASE_URL", "postgresql://localhost:5432/autolytiq"),
+		EmailServiceURL:  getEnv("EMAIL_SERVICE_URL", "http://localhost:8084"),
+		PublicBaseURL:    getEnv("PUBLIC_BASE_URL", "http://localhost:8080"),
+		ConfigServiceURL: getEnv("CONFIG_SERVICE_URL", "http://localhost:8086"),
+		PhotoBucket:      os.Getenv("AWS_S3_BUCKET"),
+		PhotoRegion:      getEnv("AWS_REGION", "us-east-1"),
+		PhotoBaseURL:     os.Getenv("PHOTO_BASE_URL"),
+		SearchSampleRate: getSearchSampleRate(),
 	}
 }
 
@@ -357,6 +584,27 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getSnapshotInterval returns how often the inventory snapshot job runs
+func getSnapshotInterval() time.Duration {
+	if value := os.Getenv("INVENTORY_SNAPSHOT_INTERVAL"); value != "" {
+		if interval, err := time.ParseDuration(value); err == nil && interval > 0 {
+			return interval
+		}
+	}
+	return time.Hour
+}
+
+// getSearchSampleRate returns the fraction of vehicle searches recorded for
+// search insights
+func getSearchSampleRate() float64 {
+	if value := os.Getenv("SEARCH_ANALYTICS_SAMPLE_RATE"); value != "" {
+		if rate, err := strconv.ParseFloat(value, 64); err == nil && rate >= 0 && rate <= 1 {
+			return rate
+		}
+	}
+	return 1
+}
+
 func main() {
 	// Initialize logger
 	logger := logging.New(logging.Config{
@@ -372,14 +620,32 @@ func main() {
 	}
 	defer db.Close()
 
+	// Stop serving on SIGINT or SIGTERM, once in-flight requests finish
+	ctx, stop := graceful.SignalContext(context.Background())
+	defer stop()
+
+	// Start serving before the schema is initialized, so /live answers and
+	// /ready reports not ready until startup finishes
+	server := NewServer(config, db, logger)
+	defer server.tracer.Shutdown(context.Background())
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.Start(ctx) }()
+
 	// Initialize schema
 	if err := db.InitSchema(); err != nil {
 		logger.Fatalf("Failed to initialize schema: %v", err)
 	}
+	if err := server.reloadSynonyms(); err != nil {
+		logger.WithError(err).Warn("Failed to load vehicle synonyms, using defaults")
+	}
+	server.health.MarkReady()
 
-	// Create and start server
-	server := NewServer(config, db, logger)
-	if err := server.Start(); err != nil {
+	// Record daily inventory snapshots for trend reporting
+	snapshotJob := NewInventorySnapshotJob(db, getSnapshotInterval(), logger)
+	snapshotJob.Start()
+	defer snapshotJob.Stop()
+
+	if err := <-serveErr; err != nil {
 		logger.Fatal(err.Error())
 	}
 }
@@ -2,24 +2,53 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	"autolytiq/shared/bodylimit"
+	"autolytiq/shared/logging"
+
 	"github.com/google/uuid"
 )
 
 // MockDatabase is a mock implementation of the Database for testing
 type MockDatabase struct {
-	vehicles map[string]*Vehicle
+	vehicles     map[string]*Vehicle
+	priceHistory map[string][]*PriceHistoryEntry
+	snapshots    map[string]*InventorySnapshot
+	synonyms     map[string]*VehicleSynonym
+	watches      map[string]*mockWatch
+	consent      map[string]bool // customer ID -> marketing email consent
+	activeDeals  map[string]bool // vehicle ID -> on an active deal
+	transfers    map[string][]*VehicleTransfer
+	photos       map[string][]*VehiclePhoto
+	searches     map[string]*SearchAggregate // dealership|date|make|model|band|query
+
+	// pingErr is returned by Ping, as if the database were down
+	pingErr error
 }
 
 func NewMockDatabase() *MockDatabase {
 	return &MockDatabase{
-		vehicles: make(map[string]*Vehicle),
+		vehicles:     make(map[string]*Vehicle),
+		priceHistory: make(map[string][]*PriceHistoryEntry),
+		snapshots:    make(map[string]*InventorySnapshot),
+		synonyms:     make(map[string]*VehicleSynonym),
+		watches:      make(map[string]*mockWatch),
+		consent:      make(map[string]bool),
+		activeDeals:  make(map[string]bool),
+		transfers:    make(map[string][]*VehicleTransfer),
+		photos:       make(map[string][]*VehiclePhoto),
+		searches:     make(map[string]*SearchAggregate),
 	}
 }
 
@@ -27,6 +56,10 @@ func (db *MockDatabase) Close() error {
 	return nil
 }
 
+func (db *MockDatabase) Ping(ctx context.Context) error {
+	return db.pingErr
+}
+
 func (db *MockDatabase) InitSchema() error {
 	return nil
 }
@@ -36,9 +69,36 @@ func (db *MockDatabase) CreateVehicle(vehicle *Vehicle) error {
 	return nil
 }
 
+func (db *MockDatabase) BulkCreateVehicles(vehicles []*Vehicle, allOrNothing bool) ([]error, error) {
+	// VINs are unique, as in the vehicles table
+	vins := make(map[string]bool)
+	for _, vehicle := range db.vehicles {
+		vins[vehicle.VIN] = true
+	}
+
+	errs := make([]error, len(vehicles))
+	var saved []*Vehicle
+	for i, vehicle := range vehicles {
+		if vehicle.VIN != "" && vins[vehicle.VIN] {
+			errs[i] = fmt.Errorf("failed to create vehicle: duplicate VIN %s", vehicle.VIN)
+			if allOrNothing {
+				return errs, nil
+			}
+			continue
+		}
+		vins[vehicle.VIN] = true
+		saved = append(saved, vehicle)
+	}
+
+	for _, vehicle := range saved {
+		db.vehicles[vehicle.ID] = vehicle
+	}
+	return errs, nil
+}
+
 func (db *MockDatabase) GetVehicle(id string) (*Vehicle, error) {
 	vehicle, exists := db.vehicles[id]
-	if !exists {
+	if !exists || vehicle.DeletedAt != nil {
 		return nil, nil
 	}
 	return vehicle, nil
@@ -47,6 +107,10 @@ func (db *MockDatabase) GetVehicle(id string) (*Vehicle, error) {
 func (db *MockDatabase) ListVehicles(dealershipID string, filters map[string]interface{}) ([]*Vehicle, error) {
 	var vehicles []*Vehicle
 	for _, vehicle := range db.vehicles {
+		if includeDeleted, _ := filters["include_deleted"].(bool); vehicle.DeletedAt != nil && !includeDeleted {
+			continue
+		}
+
 		// Check dealership filter
 		if dealershipID != "" && vehicle.DealershipID != dealershipID {
 			continue
@@ -95,6 +159,20 @@ func (db *MockDatabase) ListVehicles(dealershipID string, filters map[string]int
 			}
 		}
 
+		if q, ok := filters["q"].(string); ok && q != "" {
+			text := strings.ToLower(strings.Join([]string{vehicle.Make, vehicle.Model, vehicle.Trim, vehicle.VIN, vehicle.StockNumber}, " "))
+			matched := true
+			for _, word := range strings.Fields(strings.ToLower(q)) {
+				if !strings.Contains(text, word) {
+					matched = false
+					break
+				}
+			}
+			if !matched {
+				continue
+			}
+		}
+
 		vehicles = append(vehicles, vehicle)
 	}
 	return vehicles, nil
@@ -109,10 +187,21 @@ func (db *MockDatabase) UpdateVehicle(vehicle *Vehicle) error {
 }
 
 func (db *MockDatabase) DeleteVehicle(id string) error {
-	if _, exists := db.vehicles[id]; !exists {
+	vehicle, exists := db.vehicles[id]
+	if !exists || vehicle.DeletedAt != nil {
+		return fmt.Errorf("vehicle not found: %s", id)
+	}
+	deletedAt := time.Now().Format(time.RFC3339)
+	vehicle.DeletedAt = &deletedAt
+	return nil
+}
+
+func (db *MockDatabase) RestoreVehicle(id string) error {
+	vehicle, exists := db.vehicles[id]
+	if !exists || vehicle.DeletedAt == nil {
 		return fmt.Errorf("vehicle not found: %s", id)
 	}
-	delete(db.vehicles, id)
+	vehicle.DeletedAt = nil
 	return nil
 }
 
@@ -133,7 +222,7 @@ func (db *MockDatabase) GetInventoryStats(dealershipID string) (map[string]inter
 	totalPrice := 0.0
 
 	for _, vehicle := range db.vehicles {
-		if vehicle.DealershipID == dealershipID {
+		if vehicle.DealershipID == dealershipID && vehicle.DeletedAt == nil {
 			totalCount++
 			statusCounts[vehicle.Status]++
 			conditionCounts[vehicle.Condition]++
@@ -153,13 +242,176 @@ func (db *MockDatabase) GetInventoryStats(dealershipID string) (map[string]inter
 	return stats, nil
 }
 
+func (db *MockDatabase) GetAgingReport(dealershipID string) ([]*AgingBucket, error) {
+	buckets := newAgingBuckets()
+	for _, vehicle := range db.vehicles {
+		if vehicle.Status != "available" || vehicle.DeletedAt != nil || dealershipID != "" && vehicle.DealershipID != dealershipID {
+			continue
+		}
+		created, err := time.Parse(time.RFC3339, vehicle.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		days := int(time.Since(created).Hours() / 24)
+
+		for i, r := range agingBuckets {
+			if r.MaxDays != 0 && days > r.MaxDays {
+				continue
+			}
+			bucket := buckets[i]
+			bucket.Count++
+			bucket.TotalValue += vehicle.Price
+			if bucket.Count == 1 || days > bucket.OldestDays {
+				bucket.OldestVehicleID = vehicle.ID
+				bucket.OldestStockNumber = vehicle.StockNumber
+				bucket.OldestDays = days
+			}
+			break
+		}
+	}
+	return buckets, nil
+}
+
+func (db *MockDatabase) CreatePriceHistory(entry *PriceHistoryEntry) error {
+	db.priceHistory[entry.VehicleID] = append(db.priceHistory[entry.VehicleID], entry)
+	return nil
+}
+
+func (db *MockDatabase) ListPriceHistory(vehicleID string) ([]*PriceHistoryEntry, error) {
+	return db.priceHistory[vehicleID], nil
+}
+
+func (db *MockDatabase) ListDealershipIDs() ([]string, error) {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, vehicle := range db.vehicles {
+		if !seen[vehicle.DealershipID] && vehicle.DeletedAt == nil {
+			seen[vehicle.DealershipID] = true
+			ids = append(ids, vehicle.DealershipID)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (db *MockDatabase) UpsertInventorySnapshot(snapshot *InventorySnapshot) error {
+	db.snapshots[snapshot.DealershipID+"/"+snapshot.SnapshotDate] = snapshot
+	return nil
+}
+
+func (db *MockDatabase) ListInventorySnapshots(dealershipID string, from, to time.Time) ([]*InventorySnapshot, error) {
+	var snapshots []*InventorySnapshot
+	for _, snapshot := range db.snapshots {
+		date, _ := time.Parse("2006-01-02", snapshot.SnapshotDate)
+		if snapshot.DealershipID == dealershipID && !date.Before(from) && !date.After(to) {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].SnapshotDate < snapshots[j].SnapshotDate
+	})
+	return snapshots, nil
+}
+
+func (db *MockDatabase) ListVehicleFacets(dealershipID, field, make string) ([]*VehicleFacet, error) {
+	counts := map[string]int{}
+	for _, vehicle := range db.vehicles {
+		if vehicle.DeletedAt != nil || dealershipID != "" && vehicle.DealershipID != dealershipID {
+			continue
+		}
+		value := vehicle.Make
+		if field == SynonymFieldModel {
+			if make != "" && vehicle.Make != make {
+				continue
+			}
+			value = vehicle.Model
+		}
+		counts[value]++
+	}
+
+	var facets []*VehicleFacet
+	for value, count := range counts {
+		facets = append(facets, &VehicleFacet{Value: value, Count: count})
+	}
+	sort.Slice(facets, func(i, j int) bool {
+		return facets[i].Value < facets[j].Value
+	})
+	return facets, nil
+}
+
+func (db *MockDatabase) ListVehicleSynonyms() ([]*VehicleSynonym, error) {
+	var synonyms []*VehicleSynonym
+	for _, synonym := range db.synonyms {
+		synonyms = append(synonyms, synonym)
+	}
+	return synonyms, nil
+}
+
+func (db *MockDatabase) UpsertVehicleSynonym(synonym *VehicleSynonym) error {
+	for _, existing := range db.synonyms {
+		if existing.Field == synonym.Field && normalizeKey(existing.Make) == normalizeKey(synonym.Make) &&
+			normalizeKey(existing.Synonym) == normalizeKey(synonym.Synonym) {
+			existing.Canonical = synonym.Canonical
+			*synonym = *existing
+			return nil
+		}
+	}
+	db.synonyms[synonym.ID] = synonym
+	return nil
+}
+
+func (db *MockDatabase) DeleteVehicleSynonym(id string) error {
+	if _, exists := db.synonyms[id]; !exists {
+		return fmt.Errorf("synonym not found: %s", id)
+	}
+	delete(db.synonyms, id)
+	return nil
+}
+
+func (db *MockDatabase) ApplyVehicleSynonym(synonym *VehicleSynonym) (int64, error) {
+	var updated int64
+	for _, vehicle := range db.vehicles {
+		switch {
+		case synonym.Field == SynonymFieldMake && normalizeKey(vehicle.MakeRaw) == normalizeKey(synonym.Synonym) && vehicle.Make != synonym.Canonical:
+			vehicle.Make = synonym.Canonical
+		case synonym.Field == SynonymFieldModel && normalizeKey(vehicle.ModelRaw) == normalizeKey(synonym.Synonym) && vehicle.Model != synonym.Canonical &&
+			(synonym.Make == "" || vehicle.Make == synonym.Make):
+			vehicle.Model = synonym.Canonical
+		default:
+			continue
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// testLogger creates a logger that discards output during tests
+func testLogger() *logging.Logger {
+	return logging.New(logging.Config{
+		Service: "inventory-service-test",
+		Level:   logging.LevelError,
+		Output:  &bytes.Buffer{},
+	})
+}
+
 func setupTestServer() *Server {
 	config := &Config{
 		Port:        "8083",
 		DatabaseURL: "mock",
 	}
 	db := NewMockDatabase()
-	return NewServer(config, db)
+	return NewServer(config, db, testLogger())
+}
+
+// errorResponse is the body of error responses written by apierror, with
+// validation details
+type errorResponse struct {
+	Error struct {
+		Code      string            `json:"code"`
+		Message   string            `json:"message"`
+		RequestID string            `json:"request_id"`
+		Details   []ValidationError `json:"details"`
+	} `json:"error"`
 }
 
 func TestHealthCheck(t *testing.T) {
@@ -192,6 +444,107 @@ func TestHealthCheck(t *testing.T) {
 	}
 }
 
+func TestHealthCheckDatabaseDown(t *testing.T) {
+	server := setupTestServer()
+	server.db.(*MockDatabase).pingErr = errors.New("connection refused")
+
+	req, err := http.NewRequest("GET", "/health", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 with the database down, got %d", rr.Code)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response["status"] != "unhealthy" || response["db"] != "down" {
+		t.Errorf("Expected an unhealthy status with the database down, got %v", response)
+	}
+}
+
+func TestReadinessCheck(t *testing.T) {
+	server := setupTestServer()
+
+	getStatus := func(path string) int {
+		req, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// Not ready until the schema has been initialized
+	if code := getStatus("/ready"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 before startup finished, got %d", code)
+	}
+	if code := getStatus("/live"); code != http.StatusOK {
+		t.Errorf("Expected /live to answer during startup, got %d", code)
+	}
+
+	server.health.MarkReady()
+	if code := getStatus("/ready"); code != http.StatusOK {
+		t.Errorf("Expected 200 once ready, got %d", code)
+	}
+
+	server.db.(*MockDatabase).pingErr = errors.New("connection refused")
+	if code := getStatus("/ready"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 with the database down, got %d", code)
+	}
+	if code := getStatus("/live"); code != http.StatusOK {
+		t.Errorf("Expected /live to ignore the database, got %d", code)
+	}
+}
+
+func TestRequestBodyLimit(t *testing.T) {
+	server := setupTestServer()
+
+	post := func(path string, body io.Reader) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, body)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+	// padded is a JSON array padded with whitespace to size bytes
+	padded := func(size int) string {
+		return "[" + strings.Repeat(" ", size-2) + "]"
+	}
+
+	rr := post("/vehicles", strings.NewReader(padded(int(bodylimit.DefaultMaxBytes)+1)))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected 413 for a body over the default limit, got %d", rr.Code)
+	}
+	var resp errorResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp.Error.Code != bodylimit.CodeRequestTooLarge {
+		t.Errorf("Expected a %s error, got %+v", bodylimit.CodeRequestTooLarge, resp.Error)
+	}
+
+	// Without a Content-Length the body is cut off while decoding
+	rr = post("/vehicles", io.MultiReader(strings.NewReader(padded(int(bodylimit.DefaultMaxBytes)+1))))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for an unsized body over the limit, got %d", rr.Code)
+	}
+
+	// Bulk imports are allowed more
+	rr = post("/vehicles/bulk", strings.NewReader(padded(2*int(bodylimit.DefaultMaxBytes))))
+	if rr.Code == http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected the bulk limit for a bulk import, got %d", rr.Code)
+	}
+	rr = post("/vehicles/bulk", io.MultiReader(strings.NewReader(padded(int(bodylimit.DefaultBulkMaxBytes)+1))))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for a bulk import over the bulk limit, got %d", rr.Code)
+	}
+}
+
 func TestCreateVehicle(t *testing.T) {
 	server := setupTestServer()
 
@@ -502,6 +855,76 @@ func TestUpdateVehicle(t *testing.T) {
 	}
 }
 
+func TestPatchVehicle(t *testing.T) {
+	server := setupTestServer()
+	mockDB := server.db.(*MockDatabase)
+
+	vehicleID := uuid.New().String()
+	mockDB.vehicles[vehicleID] = &Vehicle{
+		ID:           vehicleID,
+		DealershipID: uuid.New().String(),
+		Make:         "Honda",
+		Model:        "Civic",
+		MakeRaw:      "Honda",
+		ModelRaw:     "Civic",
+		Year:         2021,
+		Price:        22000.00,
+		Mileage:      15000,
+		Color:        "Blue",
+		Description:  "One owner",
+		Status:       "available",
+		Condition:    "used",
+	}
+
+	patch := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", "/vehicles/"+vehicleID, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := patch(`{"price": 0, "mileage": 0, "description": ""}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var result Vehicle
+	json.Unmarshal(rr.Body.Bytes(), &result)
+	if result.Price != 0 || result.Mileage != 0 || result.Description != "" {
+		t.Errorf("Expected price and mileage of exactly 0 and no description, got %+v", result)
+	}
+
+	// Fields left out are unchanged
+	saved := mockDB.vehicles[vehicleID]
+	if saved.Price != 0 || saved.Color != "Blue" || saved.Year != 2021 || saved.Make != "Honda" || saved.Status != "available" {
+		t.Errorf("Expected only the patched fields to change, got %+v", saved)
+	}
+
+	rr = patch(`{"status": " SOLD ", "make": "chevy"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if saved := mockDB.vehicles[vehicleID]; saved.Status != "sold" || saved.Make != "Chevrolet" || saved.Price != 0 {
+		t.Errorf("Expected a sold Chevrolet still priced at 0, got %+v", saved)
+	}
+
+	for _, body := range []string{`{"price": -1}`, `{"make": ""}`, `{"year": 0}`, `{"status": ""}`, `{"vin": "123"}`} {
+		if rr := patch(body); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", body, rr.Code)
+		}
+	}
+	if rr := patch(`{"cost": 100}`); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 setting cost without the scope, got %d", rr.Code)
+	}
+
+	// The PUT still takes zero as not provided
+	req := httptest.NewRequest("PUT", "/vehicles/"+vehicleID, strings.NewReader(`{"price": 0, "color": "Red"}`))
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if saved := mockDB.vehicles[vehicleID]; rr.Code != http.StatusOK || saved.Color != "Red" || saved.Price != 0 {
+		t.Errorf("Expected PUT to keep its behavior, got %d %+v", rr.Code, saved)
+	}
+}
+
 func TestDeleteVehicle(t *testing.T) {
 	server := setupTestServer()
 	mockDB := server.db.(*MockDatabase)
@@ -535,9 +958,12 @@ func TestDeleteVehicle(t *testing.T) {
 			status, http.StatusNoContent)
 	}
 
-	// Verify vehicle was deleted
-	if _, exists := mockDB.vehicles[vehicleID]; exists {
-		t.Error("Expected vehicle to be deleted")
+	// Verify vehicle was soft-deleted
+	if mockDB.vehicles[vehicleID].DeletedAt == nil {
+		t.Error("Expected vehicle to be marked deleted")
+	}
+	if vehicle, _ := mockDB.GetVehicle(vehicleID); vehicle != nil {
+		t.Error("Expected a deleted vehicle not to be returned")
 	}
 }
 
@@ -558,6 +984,72 @@ func TestDeleteVehicleNotFound(t *testing.T) {
 	}
 }
 
+func TestRestoreDeletedVehicle(t *testing.T) {
+	server := setupTestServer()
+	dealershipID := uuid.New().String()
+	vehicle := &Vehicle{ID: uuid.New().String(), DealershipID: dealershipID, Make: "Nissan", Model: "Altima", Year: 2022, Price: 24000}
+	server.db.CreateVehicle(vehicle)
+
+	send := func(method, path, scopes string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if scopes != "" {
+			req.Header.Set(HeaderUserScopes, scopes)
+		}
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+	list := func(query, scopes string) []Vehicle {
+		rr := send("GET", "/vehicles?dealership_id="+dealershipID+query, scopes)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200 listing vehicles, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var vehicles []Vehicle
+		json.Unmarshal(rr.Body.Bytes(), &vehicles)
+		return vehicles
+	}
+
+	if rr := send("DELETE", "/vehicles/"+vehicle.ID, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d", rr.Code)
+	}
+	if rr := send("GET", "/vehicles/"+vehicle.ID, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a deleted vehicle, got %d", rr.Code)
+	}
+	if rr := send("DELETE", "/vehicles/"+vehicle.ID, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 deleting twice, got %d", rr.Code)
+	}
+	if vehicles := list("", ""); len(vehicles) != 0 {
+		t.Errorf("Expected deleted vehicles to be left out, got %d", len(vehicles))
+	}
+
+	if rr := send("GET", "/vehicles?include_deleted=true", ""); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 listing deleted vehicles without the scope, got %d", rr.Code)
+	}
+	vehicles := list("&include_deleted=true", ScopeInventoryManage)
+	if len(vehicles) != 1 || vehicles[0].DeletedAt == nil {
+		t.Fatalf("Expected the deleted vehicle with its deleted_at, got %+v", vehicles)
+	}
+
+	if rr := send("POST", "/vehicles/"+vehicle.ID+"/restore", ""); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 restoring without the scope, got %d", rr.Code)
+	}
+	rr := send("POST", "/vehicles/"+vehicle.ID+"/restore", ScopeInventoryManage)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 restoring, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var restored Vehicle
+	json.Unmarshal(rr.Body.Bytes(), &restored)
+	if restored.ID != vehicle.ID || restored.DeletedAt != nil {
+		t.Errorf("Expected the restored vehicle, got %+v", restored)
+	}
+	if vehicles := list("", ""); len(vehicles) != 1 {
+		t.Errorf("Expected the restored vehicle to be listed, got %d", len(vehicles))
+	}
+	if rr := send("POST", "/vehicles/"+vehicle.ID+"/restore", ScopeInventoryManage); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 restoring a vehicle that isn't deleted, got %d", rr.Code)
+	}
+}
+
 func TestValidateVIN(t *testing.T) {
 	server := setupTestServer()
 
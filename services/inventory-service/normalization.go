@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"autolytiq/shared/apierror"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// ScopeInventoryManage grants management of the make/model synonym map and
+// access to deleted vehicles
+const ScopeInventoryManage = "inventory:manage"
+
+// Synonym fields
+const (
+	SynonymFieldMake  = "make"
+	SynonymFieldModel = "model"
+)
+
+// VehicleSynonym maps a free-text make or model to its canonical value
+type VehicleSynonym struct {
+	ID        string `json:"id"`
+	Field     string `json:"field"`          // make, model
+	Make      string `json:"make,omitempty"` // canonical make a model synonym is limited to; empty for any make
+	Synonym   string `json:"synonym"`
+	Canonical string `json:"canonical"`
+	CreatedAt string `json:"created_at"`
+}
+
+// VehicleFacet is a canonical make or model with its vehicle count
+type VehicleFacet struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// defaultVehicleSynonyms are applied before any stored synonyms, which take
+// precedence
+var defaultVehicleSynonyms = []*VehicleSynonym{
+	{Field: SynonymFieldMake, Synonym: "Chevy", Canonical: "Chevrolet"},
+	{Field: SynonymFieldMake, Synonym: "VW", Canonical: "Volkswagen"},
+	{Field: SynonymFieldMake, Synonym: "Mercedes", Canonical: "Mercedes-Benz"},
+	{Field: SynonymFieldMake, Synonym: "Mercedes Benz", Canonical: "Mercedes-Benz"},
+	{Field: SynonymFieldMake, Synonym: "Benz", Canonical: "Mercedes-Benz"},
+	{Field: SynonymFieldMake, Synonym: "Caddy", Canonical: "Cadillac"},
+	{Field: SynonymFieldMake, Synonym: "Landrover", Canonical: "Land Rover"},
+	{Field: SynonymFieldModel, Make: "Ford", Synonym: "F150", Canonical: "F-150"},
+	{Field: SynonymFieldModel, Make: "Ford", Synonym: "F 150", Canonical: "F-150"},
+	{Field: SynonymFieldModel, Make: "Chevrolet", Synonym: "Silverado1500", Canonical: "Silverado 1500"},
+}
+
+// Normalizer maps free-text makes and models to canonical values. Lookups are
+// case-insensitive and ignore repeated whitespace. Values with no synonym are
+// returned trimmed but otherwise as entered.
+type Normalizer struct {
+	mu     sync.RWMutex
+	makes  map[string]string
+	models map[string]string
+}
+
+// NewNormalizer creates a normalizer with the default synonyms
+func NewNormalizer() *Normalizer {
+	n := &Normalizer{}
+	n.Load(nil)
+	return n
+}
+
+// normalizeKey returns the lookup key for a free-text value
+func normalizeKey(value string) string {
+	return strings.ToLower(strings.Join(strings.Fields(value), " "))
+}
+
+// cleanValue trims and collapses whitespace in a free-text value
+func cleanValue(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// modelKey returns the lookup key for a model synonym limited to a make
+func modelKey(make, model string) string {
+	return normalizeKey(make) + "|" + normalizeKey(model)
+}
+
+// Load replaces the synonym map with the defaults plus the given synonyms
+func (n *Normalizer) Load(synonyms []*VehicleSynonym) {
+	makes := make(map[string]string)
+	models := make(map[string]string)
+
+	for _, list := range [][]*VehicleSynonym{defaultVehicleSynonyms, synonyms} {
+		for _, s := range list {
+			canonical := cleanValue(s.Canonical)
+			switch s.Field {
+			case SynonymFieldMake:
+				makes[normalizeKey(s.Synonym)] = canonical
+				makes[normalizeKey(canonical)] = canonical
+			case SynonymFieldModel:
+				models[modelKey(s.Make, s.Synonym)] = canonical
+				models[modelKey(s.Make, canonical)] = canonical
+			}
+		}
+	}
+
+	n.mu.Lock()
+	n.makes = makes
+	n.models = models
+	n.mu.Unlock()
+}
+
+// NormalizeMake returns the canonical make for a free-text make
+func (n *Normalizer) NormalizeMake(raw string) string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if canonical, ok := n.makes[normalizeKey(raw)]; ok {
+		return canonical
+	}
+	return cleanValue(raw)
+}
+
+// NormalizeModel returns the canonical model for a free-text model of the
+// given canonical make. Synonyms limited to the make take precedence over
+// those for any make.
+func (n *Normalizer) NormalizeModel(make, raw string) string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if canonical, ok := n.models[modelKey(make, raw)]; ok {
+		return canonical
+	}
+	if canonical, ok := n.models[modelKey("", raw)]; ok {
+		return canonical
+	}
+	return cleanValue(raw)
+}
+
+// Apply sets the vehicle's canonical make and model from its raw values
+func (n *Normalizer) Apply(vehicle *Vehicle) {
+	if vehicle.MakeRaw == "" {
+		vehicle.MakeRaw = vehicle.Make
+	}
+	if vehicle.ModelRaw == "" {
+		vehicle.ModelRaw = vehicle.Model
+	}
+	vehicle.Make = n.NormalizeMake(vehicle.MakeRaw)
+	vehicle.Model = n.NormalizeModel(vehicle.Make, vehicle.ModelRaw)
+}
+
+// reloadSynonyms rebuilds the normalizer from the stored synonyms
+func (s *Server) reloadSynonyms() error {
+	synonyms, err := s.db.ListVehicleSynonyms()
+	if err != nil {
+		return err
+	}
+	s.normalizer.Load(synonyms)
+	return nil
+}
+
+// listVehicleMakes returns the canonical makes in inventory for dropdowns
+func (s *Server) listVehicleMakes(w http.ResponseWriter, r *http.Request) {
+	dealershipID := r.URL.Query().Get("dealership_id")
+
+	facets, err := s.db.ListVehicleFacets(dealershipID, SynonymFieldMake, "")
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list vehicle makes")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to list vehicle makes: %v", err))
+		return
+	}
+	if facets == nil {
+		facets = []*VehicleFacet{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(facets)
+}
+
+// listVehicleModels returns the canonical models in inventory for dropdowns,
+// optionally limited to a make
+func (s *Server) listVehicleModels(w http.ResponseWriter, r *http.Request) {
+	dealershipID := r.URL.Query().Get("dealership_id")
+
+	make := r.URL.Query().Get("make")
+	if make != "" {
+		make = s.normalizer.NormalizeMake(make)
+	}
+
+	facets, err := s.db.ListVehicleFacets(dealershipID, SynonymFieldModel, make)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list vehicle models")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to list vehicle models: %v", err))
+		return
+	}
+	if facets == nil {
+		facets = []*VehicleFacet{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(facets)
+}
+
+// listVehicleSynonyms returns the stored synonyms. Built-in defaults are not
+// included.
+func (s *Server) listVehicleSynonyms(w http.ResponseWriter, r *http.Request) {
+	synonyms, err := s.db.ListVehicleSynonyms()
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list vehicle synonyms")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to list vehicle synonyms: %v", err))
+		return
+	}
+	if synonyms == nil {
+		synonyms = []*VehicleSynonym{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(synonyms)
+}
+
+// upsertVehicleSynonym adds or replaces a synonym and re-normalizes existing
+// vehicles whose raw value matches it
+func (s *Server) upsertVehicleSynonym(w http.ResponseWriter, r *http.Request) {
+	if !hasScope(r, ScopeInventoryManage) {
+		respondErrorJSON(w, http.StatusForbidden, "Managing synonyms requires the "+ScopeInventoryManage+" scope", "INSUFFICIENT_SCOPE")
+		return
+	}
+
+	var req UpsertSynonymRequest
+	if !decodeAndValidate(r, w, &req) {
+		return
+	}
+
+	synonym := &VehicleSynonym{
+		ID:        uuid.New().String(),
+		Field:     req.Field,
+		Make:      req.Make,
+		Synonym:   req.Synonym,
+		Canonical: req.Canonical,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+	if synonym.Make != "" {
+		synonym.Make = s.normalizer.NormalizeMake(synonym.Make)
+	}
+
+	if err := s.db.UpsertVehicleSynonym(synonym); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to save vehicle synonym")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to save vehicle synonym: %v", err))
+		return
+	}
+
+	if err := s.reloadSynonyms(); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Warn("Failed to reload vehicle synonyms")
+	}
+
+	updated, err := s.db.ApplyVehicleSynonym(synonym)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).WithField("synonym", synonym.Synonym).Warn("Failed to re-normalize vehicles")
+	}
+
+	s.logger.WithContext(r.Context()).
+		WithField("field", synonym.Field).
+		WithField("synonym", synonym.Synonym).
+		WithField("canonical", synonym.Canonical).
+		WithField("vehicles_updated", updated).
+		Info("Vehicle synonym saved")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"synonym":          synonym,
+		"vehicles_updated": updated,
+	})
+}
+
+// deleteVehicleSynonym removes a stored synonym. Vehicles already normalized
+// with it keep their canonical value.
+func (s *Server) deleteVehicleSynonym(w http.ResponseWriter, r *http.Request) {
+	if !hasScope(r, ScopeInventoryManage) {
+		respondErrorJSON(w, http.StatusForbidden, "Managing synonyms requires the "+ScopeInventoryManage+" scope", "INSUFFICIENT_SCOPE")
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if !validateUUID(w, id, "id") {
+		return
+	}
+
+	if err := s.db.DeleteVehicleSynonym(id); err != nil {
+		if err.Error() == fmt.Sprintf("synonym not found: %s", id) {
+			apierror.WriteError(w, http.StatusNotFound, "SYNONYM_NOT_FOUND", "Synonym not found")
+		} else {
+			s.logger.WithContext(r.Context()).WithError(err).Error("Failed to delete vehicle synonym")
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to delete vehicle synonym: %v", err))
+		}
+		return
+	}
+
+	if err := s.reloadSynonyms(); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Warn("Failed to reload vehicle synonyms")
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
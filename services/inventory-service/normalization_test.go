@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestNormalizer(t *testing.T) {
+	n := NewNormalizer()
+
+	makes := map[string]string{
+		"Chevy":          "Chevrolet",
+		"  chevy ":       "Chevrolet",
+		"CHEVROLET":      "Chevrolet",
+		"VW":             "Volkswagen",
+		"mercedes  benz": "Mercedes-Benz",
+		"Toyota":         "Toyota",
+		" Subaru  ":      "Subaru",
+	}
+	for raw, expected := range makes {
+		if got := n.NormalizeMake(raw); got != expected {
+			t.Errorf("NormalizeMake(%q) = %q, want %q", raw, got, expected)
+		}
+	}
+
+	if got := n.NormalizeModel("Ford", "f150"); got != "F-150" {
+		t.Errorf("Expected Ford f150 to normalize to F-150, got %q", got)
+	}
+	if got := n.NormalizeModel("Toyota", "F150"); got != "F150" {
+		t.Errorf("Expected make-limited synonym not to apply to Toyota, got %q", got)
+	}
+
+	n.Load([]*VehicleSynonym{{Field: SynonymFieldMake, Synonym: "Chevy", Canonical: "Chevy Motors"}})
+	if got := n.NormalizeMake("chevy"); got != "Chevy Motors" {
+		t.Errorf("Expected stored synonym to override default, got %q", got)
+	}
+}
+
+func createNormalizedVehicle(t *testing.T, server *Server, dealershipID, make, model string) Vehicle {
+	t.Helper()
+
+	body, _ := json.Marshal(CreateVehicleRequest{
+		DealershipID: dealershipID,
+		Make:         make,
+		Model:        model,
+		Year:         2021,
+		Price:        30000,
+	})
+	req := httptest.NewRequest("POST", "/vehicles", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var created Vehicle
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	return created
+}
+
+func getFacets(t *testing.T, server *Server, path string) []VehicleFacet {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var facets []VehicleFacet
+	json.Unmarshal(rr.Body.Bytes(), &facets)
+	return facets
+}
+
+func TestCreateVehicleNormalizesMake(t *testing.T) {
+	server := setupTestServer()
+	dealershipID := uuid.New().String()
+
+	created := createNormalizedVehicle(t, server, dealershipID, "Chevy", "Silverado1500")
+	if created.Make != "Chevrolet" || created.MakeRaw != "Chevy" {
+		t.Errorf("Expected Chevy to be stored as Chevrolet with raw value, got make=%q raw=%q", created.Make, created.MakeRaw)
+	}
+	if created.Model != "Silverado 1500" || created.ModelRaw != "Silverado1500" {
+		t.Errorf("Expected model to be normalized for Chevrolet, got model=%q raw=%q", created.Model, created.ModelRaw)
+	}
+
+	createNormalizedVehicle(t, server, dealershipID, "Chevrolet", "Silverado 1500")
+	createNormalizedVehicle(t, server, dealershipID, "chevrolet", "Malibu")
+	createNormalizedVehicle(t, server, dealershipID, "Toyota", "Camry")
+
+	makes := getFacets(t, server, "/vehicles/makes?dealership_id="+dealershipID)
+	expected := []VehicleFacet{{Value: "Chevrolet", Count: 3}, {Value: "Toyota", Count: 1}}
+	if !reflect.DeepEqual(makes, expected) {
+		t.Errorf("Expected makes %+v, got %+v", expected, makes)
+	}
+
+	models := getFacets(t, server, "/vehicles/models?dealership_id="+dealershipID+"&make=Chevy")
+	expected = []VehicleFacet{{Value: "Malibu", Count: 1}, {Value: "Silverado 1500", Count: 2}}
+	if !reflect.DeepEqual(models, expected) {
+		t.Errorf("Expected models %+v, got %+v", expected, models)
+	}
+
+	// Filters match on canonical values
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("GET", "/vehicles?dealership_id="+dealershipID+"&make=chevy", nil))
+	var vehicles []Vehicle
+	json.Unmarshal(rr.Body.Bytes(), &vehicles)
+	if len(vehicles) != 3 {
+		t.Errorf("Expected 3 vehicles for make=chevy, got %d", len(vehicles))
+	}
+}
+
+func TestUpdateVehicleNormalizesMake(t *testing.T) {
+	server := setupTestServer()
+	created := createNormalizedVehicle(t, server, uuid.New().String(), "Toyota", "Camry")
+
+	body, _ := json.Marshal(UpdateVehicleRequest{Make: "VW", Model: "Jetta"})
+	req := httptest.NewRequest("PUT", "/vehicles/"+created.ID, bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var updated Vehicle
+	json.Unmarshal(rr.Body.Bytes(), &updated)
+	if updated.Make != "Volkswagen" || updated.MakeRaw != "VW" {
+		t.Errorf("Expected VW to normalize to Volkswagen, got make=%q raw=%q", updated.Make, updated.MakeRaw)
+	}
+}
+
+func TestUpsertVehicleSynonym(t *testing.T) {
+	server := setupTestServer()
+	dealershipID := uuid.New().String()
+	typo := createNormalizedVehicle(t, server, dealershipID, "Toyta", "Camry")
+	createNormalizedVehicle(t, server, dealershipID, "Toyota", "Corolla")
+
+	body, _ := json.Marshal(UpsertSynonymRequest{Field: "make", Synonym: "Toyta", Canonical: "Toyota"})
+
+	req := httptest.NewRequest("PUT", "/vehicles/synonyms", bytes.NewBuffer(body))
+	req.Header.Set(HeaderUserScopes, "inventory:update")
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403 without %s, got %d", ScopeInventoryManage, rr.Code)
+	}
+
+	req = httptest.NewRequest("PUT", "/vehicles/synonyms", bytes.NewBuffer(body))
+	req.Header.Set(HeaderUserScopes, ScopeInventoryManage)
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var response struct {
+		Synonym         VehicleSynonym `json:"synonym"`
+		VehiclesUpdated int64          `json:"vehicles_updated"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if response.VehiclesUpdated != 1 {
+		t.Errorf("Expected 1 vehicle re-normalized, got %d", response.VehiclesUpdated)
+	}
+
+	stored, _ := server.db.GetVehicle(typo.ID)
+	if stored.Make != "Toyota" || stored.MakeRaw != "Toyta" {
+		t.Errorf("Expected existing vehicle to be re-normalized keeping its raw make, got make=%q raw=%q", stored.Make, stored.MakeRaw)
+	}
+
+	makes := getFacets(t, server, "/vehicles/makes?dealership_id="+dealershipID)
+	if !reflect.DeepEqual(makes, []VehicleFacet{{Value: "Toyota", Count: 2}}) {
+		t.Errorf("Expected a single Toyota facet, got %+v", makes)
+	}
+
+	// New vehicles pick up the synonym
+	if created := createNormalizedVehicle(t, server, dealershipID, "TOYTA", "Prius"); created.Make != "Toyota" {
+		t.Errorf("Expected new vehicle to use stored synonym, got %q", created.Make)
+	}
+}
+
+func TestUpsertVehicleSynonymValidation(t *testing.T) {
+	server := setupTestServer()
+
+	body, _ := json.Marshal(UpsertSynonymRequest{Field: "trim", Synonym: "LX"})
+	req := httptest.NewRequest("PUT", "/vehicles/synonyms", bytes.NewBuffer(body))
+	req.Header.Set(HeaderUserScopes, ScopeInventoryManage)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// PhotoStore stores vehicle photos and returns the URL they are served from
+type PhotoStore interface {
+	PutPhoto(ctx context.Context, key, contentType string, body []byte) (string, error)
+}
+
+// s3PhotoStore writes photos to an S3 bucket
+type s3PhotoStore struct {
+	client  *s3.Client
+	bucket  string
+	baseURL string
+}
+
+// newS3PhotoStore creates a photo store for a bucket. Photos are served from
+// baseURL (e.g. a CDN in front of the bucket) when set, otherwise from the
+// bucket's own URL.
+func newS3PhotoStore(ctx context.Context, bucket, region, baseURL string) (*s3PhotoStore, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, region)
+	}
+
+	return &s3PhotoStore{
+		client:  s3.NewFromConfig(cfg),
+		bucket:  bucket,
+		baseURL: strings.TrimRight(baseURL, "/"),
+	}, nil
+}
+
+// PutPhoto uploads a photo to the bucket
+func (s *s3PhotoStore) PutPhoto(ctx context.Context, key, contentType string, body []byte) (string, error) {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		Body:          bytes.NewReader(body),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(int64(len(body))),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload photo: %w", err)
+	}
+	return s.baseURL + "/" + key, nil
+}
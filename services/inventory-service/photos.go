@@ -0,0 +1,355 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/logging"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Bulk photo import limits
+const (
+	maxPhotosPerVehicle = 40
+	maxPhotoSize        = 15 << 20  // per image
+	maxPhotoZipSize     = 500 << 20 // per upload
+)
+
+// photoContentTypes are the accepted image extensions
+var photoContentTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".webp": "image/webp",
+}
+
+// photoFilenamePattern matches photographer file names such as
+// 1HGCM82633A004352_01.jpg or STK1234-2.png: a VIN or stock number, a
+// separator, and the photo's position in the set
+var photoFilenamePattern = regexp.MustCompile(`^(.+?)[_\- ](\d+)$`)
+
+// VehiclePhoto is an image attached to a vehicle
+type VehiclePhoto struct {
+	ID          string `json:"id"`
+	VehicleID   string `json:"vehicle_id"`
+	Position    int    `json:"position"`
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Filename    string `json:"filename,omitempty"`
+	IsPrimary   bool   `json:"is_primary"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// MatchedPhoto reports an imported file and the vehicle it was attached to
+type MatchedPhoto struct {
+	Filename    string `json:"filename"`
+	VehicleID   string `json:"vehicle_id"`
+	VIN         string `json:"vin"`
+	StockNumber string `json:"stock_number,omitempty"`
+	PhotoID     string `json:"photo_id"`
+	Position    int    `json:"position"`
+	IsPrimary   bool   `json:"is_primary"`
+}
+
+// UnmatchedPhoto reports a file that was not imported, and why
+type UnmatchedPhoto struct {
+	Filename string `json:"filename"`
+	Reason   string `json:"reason"`
+}
+
+// BulkPhotoImportResult is the response to a bulk photo import
+type BulkPhotoImportResult struct {
+	Matched         []MatchedPhoto   `json:"matched"`
+	Unmatched       []UnmatchedPhoto `json:"unmatched"`
+	VehiclesUpdated int              `json:"vehicles_updated"`
+}
+
+// photoFile is an image from the upload, parsed from its file name
+type photoFile struct {
+	file     *zip.File
+	filename string
+	index    int
+}
+
+// parsePhotoFilename returns the vehicle reference and ordering index encoded
+// in a photo's file name
+func parsePhotoFilename(filename string) (ref string, index int, contentType string, ok bool) {
+	ext := strings.ToLower(path.Ext(filename))
+	contentType, ok = photoContentTypes[ext]
+	if !ok {
+		return "", 0, "", false
+	}
+
+	m := photoFilenamePattern.FindStringSubmatch(strings.TrimSuffix(filename, path.Ext(filename)))
+	if m == nil {
+		return "", 0, "", false
+	}
+	index, err := strconv.Atoi(m[2])
+	if err != nil {
+		return "", 0, "", false
+	}
+	return strings.ToUpper(strings.TrimSpace(m[1])), index, contentType, true
+}
+
+// isZipMetadata reports whether a zip entry is an OS artifact rather than a
+// photo, e.g. __MACOSX/ resource forks or .DS_Store
+func isZipMetadata(name string) bool {
+	return strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), ".")
+}
+
+// bulkImportPhotos attaches photos from a zip to the dealership's vehicles.
+// File names encode the vehicle's VIN or stock number and the photo's
+// position; each vehicle's photos are appended after its existing ones in
+// that order, up to maxPhotosPerVehicle. The first photo becomes primary when
+// the vehicle has none. Files that can't be matched are reported, not
+// rejected.
+func (s *Server) bulkImportPhotos(w http.ResponseWriter, r *http.Request) {
+	dealershipID := logging.GetDealershipID(r.Context())
+	if dealershipID == "" {
+		respondErrorJSON(w, http.StatusBadRequest, "X-Dealership-ID header is required", "MISSING_DEALERSHIP")
+		return
+	}
+	if s.photos == nil {
+		respondErrorJSON(w, http.StatusServiceUnavailable, "Photo storage is not configured", "STORAGE_UNAVAILABLE")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPhotoZipSize)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		respondErrorJSON(w, http.StatusBadRequest, "A zip file is required in the \"file\" field (max 500MB)", "INVALID_UPLOAD")
+		return
+	}
+	defer file.Close()
+
+	archive, err := zip.NewReader(file, header.Size)
+	if err != nil {
+		respondErrorJSON(w, http.StatusBadRequest, "Upload is not a valid zip file", "INVALID_ZIP")
+		return
+	}
+
+	vehicles, err := s.db.ListVehicles(dealershipID, map[string]interface{}{})
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list vehicles")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to import photos: %v", err))
+		return
+	}
+	byVIN := make(map[string]*Vehicle)
+	byStock := make(map[string]*Vehicle)
+	for _, v := range vehicles {
+		byVIN[strings.ToUpper(v.VIN)] = v
+		if v.StockNumber != "" {
+			byStock[strings.ToUpper(v.StockNumber)] = v
+		}
+	}
+
+	result := BulkPhotoImportResult{Matched: []MatchedPhoto{}, Unmatched: []UnmatchedPhoto{}}
+
+	// Group files by vehicle
+	files := make(map[string][]photoFile)
+	var order []string
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() || isZipMetadata(f.Name) {
+			continue
+		}
+		filename := path.Base(f.Name)
+
+		ref, index, _, ok := parsePhotoFilename(filename)
+		if !ok {
+			result.Unmatched = append(result.Unmatched, UnmatchedPhoto{Filename: f.Name, Reason: "File name must be <VIN or stock number>_<index> with a .jpg, .png or .webp extension"})
+			continue
+		}
+		vehicle := byVIN[ref]
+		if vehicle == nil {
+			vehicle = byStock[ref]
+		}
+		if vehicle == nil {
+			result.Unmatched = append(result.Unmatched, UnmatchedPhoto{Filename: f.Name, Reason: fmt.Sprintf("No vehicle with VIN or stock number %s", ref)})
+			continue
+		}
+		if f.UncompressedSize64 > maxPhotoSize {
+			result.Unmatched = append(result.Unmatched, UnmatchedPhoto{Filename: f.Name, Reason: fmt.Sprintf("Image exceeds %dMB", maxPhotoSize>>20)})
+			continue
+		}
+
+		if _, seen := files[vehicle.ID]; !seen {
+			order = append(order, vehicle.ID)
+		}
+		files[vehicle.ID] = append(files[vehicle.ID], photoFile{file: f, filename: f.Name, index: index})
+	}
+
+	vehiclesByID := make(map[string]*Vehicle, len(vehicles))
+	for _, v := range vehicles {
+		vehiclesByID[v.ID] = v
+	}
+
+	for _, vehicleID := range order {
+		vehicle := vehiclesByID[vehicleID]
+		set := files[vehicleID]
+		sort.SliceStable(set, func(i, j int) bool {
+			if set[i].index != set[j].index {
+				return set[i].index < set[j].index
+			}
+			return set[i].filename < set[j].filename
+		})
+
+		matched, unmatched, err := s.attachPhotos(r, vehicle, set)
+		if err != nil {
+			s.logger.WithContext(r.Context()).WithError(err).WithField("vehicle_id", vehicle.ID).Error("Failed to attach photos")
+		}
+		result.Matched = append(result.Matched, matched...)
+		result.Unmatched = append(result.Unmatched, unmatched...)
+		if len(matched) > 0 {
+			result.VehiclesUpdated++
+		}
+	}
+
+	s.logger.WithContext(r.Context()).WithField("matched", len(result.Matched)).WithField("unmatched", len(result.Unmatched)).Info("Bulk photos imported")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// attachPhotos uploads a vehicle's photos in order and records them after
+// its existing photos. Photos past the per-vehicle cap, or that fail to
+// upload, are returned as unmatched.
+func (s *Server) attachPhotos(r *http.Request, vehicle *Vehicle, set []photoFile) ([]MatchedPhoto, []UnmatchedPhoto, error) {
+	var unmatched []UnmatchedPhoto
+	fail := func(files []photoFile, reason string) {
+		for _, f := range files {
+			unmatched = append(unmatched, UnmatchedPhoto{Filename: f.filename, Reason: reason})
+		}
+	}
+
+	existing, err := s.db.ListVehiclePhotos(vehicle.ID)
+	if err != nil {
+		fail(set, "Failed to load the vehicle's photos")
+		return nil, unmatched, err
+	}
+
+	hasPrimary := false
+	nextPosition := 1
+	for _, p := range existing {
+		hasPrimary = hasPrimary || p.IsPrimary
+		if p.Position >= nextPosition {
+			nextPosition = p.Position + 1
+		}
+	}
+
+	if room := maxPhotosPerVehicle - len(existing); len(set) > room {
+		if room < 0 {
+			room = 0
+		}
+		fail(set[room:], fmt.Sprintf("Vehicle %s already has the maximum of %d photos", vehicle.VIN, maxPhotosPerVehicle))
+		set = set[:room]
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	var photos []*VehiclePhoto
+	var names []string
+	for _, f := range set {
+		body, err := readZipFile(f.file)
+		if err != nil {
+			fail([]photoFile{f}, "Could not be read from the zip")
+			continue
+		}
+		_, _, contentType, _ := parsePhotoFilename(path.Base(f.filename))
+
+		photo := &VehiclePhoto{
+			ID:          uuid.New().String(),
+			VehicleID:   vehicle.ID,
+			Position:    nextPosition,
+			ContentType: contentType,
+			Filename:    path.Base(f.filename),
+			IsPrimary:   !hasPrimary && len(photos) == 0,
+			CreatedAt:   now,
+		}
+		key := fmt.Sprintf("vehicles/%s/%s/%s%s", vehicle.DealershipID, vehicle.ID, photo.ID, strings.ToLower(path.Ext(f.filename)))
+		photo.URL, err = s.photos.PutPhoto(r.Context(), key, contentType, body)
+		if err != nil {
+			s.logger.WithContext(r.Context()).WithError(err).WithField("filename", f.filename).Error("Failed to upload photo")
+			fail([]photoFile{f}, "Failed to store image")
+			continue
+		}
+		photos = append(photos, photo)
+		names = append(names, f.filename)
+		nextPosition++
+	}
+
+	if len(photos) == 0 {
+		return nil, unmatched, nil
+	}
+	if err := s.db.AddVehiclePhotos(vehicle.ID, photos); err != nil {
+		for _, name := range names {
+			unmatched = append(unmatched, UnmatchedPhoto{Filename: name, Reason: "Failed to attach to vehicle"})
+		}
+		return nil, unmatched, err
+	}
+
+	matched := make([]MatchedPhoto, 0, len(photos))
+	for i, p := range photos {
+		matched = append(matched, MatchedPhoto{
+			Filename:    names[i],
+			VehicleID:   vehicle.ID,
+			VIN:         vehicle.VIN,
+			StockNumber: vehicle.StockNumber,
+			PhotoID:     p.ID,
+			Position:    p.Position,
+			IsPrimary:   p.IsPrimary,
+		})
+	}
+	return matched, unmatched, nil
+}
+
+// readZipFile reads an entry, refusing to inflate past maxPhotoSize in case
+// the entry's declared size is wrong
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	body, err := io.ReadAll(io.LimitReader(rc, maxPhotoSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxPhotoSize {
+		return nil, fmt.Errorf("image exceeds %d bytes", maxPhotoSize)
+	}
+	return body, nil
+}
+
+// listVehiclePhotos returns a vehicle's photos in display order
+func (s *Server) listVehiclePhotos(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if !validateUUID(w, id, "id") {
+		return
+	}
+
+	photos, err := s.db.ListVehiclePhotos(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list vehicle photos")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to list vehicle photos: %v", err))
+		return
+	}
+	if photos == nil {
+		photos = []*VehiclePhoto{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(photos)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func (db *MockDatabase) ListVehiclePhotos(vehicleID string) ([]*VehiclePhoto, error) {
+	return db.photos[vehicleID], nil
+}
+
+func (db *MockDatabase) AddVehiclePhotos(vehicleID string, photos []*VehiclePhoto) error {
+	for _, photo := range photos {
+		if photo.IsPrimary {
+			db.vehicles[vehicleID].ImageURL = photo.URL
+		}
+	}
+	db.photos[vehicleID] = append(db.photos[vehicleID], photos...)
+	return nil
+}
+
+// memoryPhotoStore keeps uploaded photos in memory
+type memoryPhotoStore struct {
+	objects map[string][]byte
+}
+
+func (m *memoryPhotoStore) PutPhoto(ctx context.Context, key, contentType string, body []byte) (string, error) {
+	m.objects[key] = body
+	return "https://photos.example.com/" + key, nil
+}
+
+// photoZipRequest builds a bulk import request holding a zip of files, each
+// containing its own name
+func photoZipRequest(t *testing.T, dealershipID string, names ...string) *http.Request {
+	t.Helper()
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	for _, name := range names {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(name))
+	}
+	zw.Close()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "photos.zip")
+	part.Write(zipped.Bytes())
+	mw.Close()
+
+	req := httptest.NewRequest("POST", "/vehicles/photos/bulk", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("X-Dealership-ID", dealershipID)
+	return req
+}
+
+func setupPhotoTestServer() (*Server, *MockDatabase, *memoryPhotoStore) {
+	server := setupTestServer()
+	db := server.db.(*MockDatabase)
+	store := &memoryPhotoStore{objects: make(map[string][]byte)}
+	server.photos = store
+	return server, db, store
+}
+
+func addTestVehicle(db *MockDatabase, dealershipID, vin, stockNumber string) *Vehicle {
+	v := &Vehicle{ID: uuid.New().String(), DealershipID: dealershipID, VIN: vin, StockNumber: stockNumber, Status: "available"}
+	db.vehicles[v.ID] = v
+	return v
+}
+
+func importPhotos(t *testing.T, server *Server, req *http.Request) BulkPhotoImportResult {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var result BulkPhotoImportResult
+	json.NewDecoder(rr.Body).Decode(&result)
+	return result
+}
+
+func TestBulkImportPhotosMatchesVehicles(t *testing.T) {
+	server, db, store := setupPhotoTestServer()
+	dealershipID := uuid.New().String()
+	byVIN := addTestVehicle(db, dealershipID, "1HGCM82633A004352", "A100")
+	byStock := addTestVehicle(db, dealershipID, "2T1BURHE0JC000001", "STK-77")
+	addTestVehicle(db, uuid.New().String(), "3VWFE21C04M000001", "B200")
+
+	result := importPhotos(t, server, photoZipRequest(t, dealershipID,
+		"shoot/1HGCM82633A004352_03.jpg",
+		"shoot/1HGCM82633A004352_01.jpg",
+		"shoot/1hgcm82633a004352-2.PNG",
+		"STK-77_1.webp",
+	))
+
+	if len(result.Matched) != 4 || len(result.Unmatched) != 0 || result.VehiclesUpdated != 2 {
+		t.Fatalf("Expected 4 photos on 2 vehicles, got %+v", result)
+	}
+	if len(store.objects) != 4 {
+		t.Errorf("Expected 4 uploaded images, got %d", len(store.objects))
+	}
+
+	photos := db.photos[byVIN.ID]
+	if len(photos) != 3 {
+		t.Fatalf("Expected 3 photos on the VIN-matched vehicle, got %d", len(photos))
+	}
+	for i, want := range []string{"1HGCM82633A004352_01.jpg", "1hgcm82633a004352-2.PNG", "1HGCM82633A004352_03.jpg"} {
+		if photos[i].Filename != want || photos[i].Position != i+1 {
+			t.Errorf("Expected photo %d to be %s, got %s at position %d", i+1, want, photos[i].Filename, photos[i].Position)
+		}
+	}
+	if !photos[0].IsPrimary || photos[1].IsPrimary || photos[2].IsPrimary {
+		t.Error("Expected only the first photo to be primary")
+	}
+	if byVIN.ImageURL != photos[0].URL {
+		t.Errorf("Expected the primary photo to become the vehicle image, got %q", byVIN.ImageURL)
+	}
+	if len(db.photos[byStock.ID]) != 1 || db.photos[byStock.ID][0].ContentType != "image/webp" {
+		t.Errorf("Expected the stock-number match to get one webp photo, got %+v", db.photos[byStock.ID])
+	}
+}
+
+func TestBulkImportPhotosReportsUnmatched(t *testing.T) {
+	server, db, _ := setupPhotoTestServer()
+	dealershipID := uuid.New().String()
+	addTestVehicle(db, dealershipID, "1HGCM82633A004352", "A100")
+	addTestVehicle(db, uuid.New().String(), "3VWFE21C04M000001", "B200")
+
+	result := importPhotos(t, server, photoZipRequest(t, dealershipID,
+		"A100_1.jpg",
+		"UNKNOWNVIN_1.jpg",
+		"3VWFE21C04M000001_1.jpg", // another dealership's vehicle
+		"A100.jpg",
+		"notes.txt",
+		"__MACOSX/._A100_2.jpg",
+		".DS_Store",
+	))
+
+	if len(result.Matched) != 1 || result.Matched[0].Filename != "A100_1.jpg" {
+		t.Errorf("Expected only A100_1.jpg to match, got %+v", result.Matched)
+	}
+	unmatched := make(map[string]bool)
+	for _, u := range result.Unmatched {
+		unmatched[u.Filename] = true
+	}
+	for _, name := range []string{"UNKNOWNVIN_1.jpg", "3VWFE21C04M000001_1.jpg", "A100.jpg", "notes.txt"} {
+		if !unmatched[name] {
+			t.Errorf("Expected %s to be reported unmatched, got %+v", name, result.Unmatched)
+		}
+	}
+	if len(result.Unmatched) != 4 {
+		t.Errorf("Expected zip metadata to be skipped, got %+v", result.Unmatched)
+	}
+}
+
+func TestBulkImportPhotosEnforcesCapAndKeepsPrimary(t *testing.T) {
+	server, db, _ := setupPhotoTestServer()
+	dealershipID := uuid.New().String()
+	vehicle := addTestVehicle(db, dealershipID, "1HGCM82633A004352", "A100")
+	for i := 1; i <= maxPhotosPerVehicle-1; i++ {
+		db.photos[vehicle.ID] = append(db.photos[vehicle.ID], &VehiclePhoto{ID: uuid.New().String(), Position: i, IsPrimary: i == 1})
+	}
+
+	result := importPhotos(t, server, photoZipRequest(t, dealershipID, "A100_1.jpg", "A100_2.jpg"))
+
+	if len(result.Matched) != 1 || result.Matched[0].Position != maxPhotosPerVehicle || result.Matched[0].IsPrimary {
+		t.Errorf("Expected one non-primary photo appended at position %d, got %+v", maxPhotosPerVehicle, result.Matched)
+	}
+	if len(result.Unmatched) != 1 || result.Unmatched[0].Filename != "A100_2.jpg" {
+		t.Errorf("Expected the photo over the cap to be reported, got %+v", result.Unmatched)
+	}
+	if got := len(db.photos[vehicle.ID]); got != maxPhotosPerVehicle {
+		t.Errorf("Expected %d photos, got %d", maxPhotosPerVehicle, got)
+	}
+}
+
+func TestBulkImportPhotosRequiresStorage(t *testing.T) {
+	server := setupTestServer()
+
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, photoZipRequest(t, uuid.New().String(), "A100_1.jpg"))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", rr.Code)
+	}
+}
+
+func TestParsePhotoFilename(t *testing.T) {
+	tests := []struct {
+		name  string
+		ref   string
+		index int
+		ok    bool
+	}{
+		{"1HGCM82633A004352_01.jpg", "1HGCM82633A004352", 1, true},
+		{"stk-77-12.JPEG", "STK-77", 12, true},
+		{"A100 3.png", "A100", 3, true},
+		{"A100.jpg", "", 0, false},
+		{"A100_1.gif", "", 0, false},
+	}
+	for _, tt := range tests {
+		ref, index, _, ok := parsePhotoFilename(tt.name)
+		if ok != tt.ok || ref != tt.ref || index != tt.index {
+			t.Errorf("parsePhotoFilename(%q) = %q, %d, %v; want %q, %d, %v", tt.name, ref, index, ok, tt.ref, tt.index, tt.ok)
+		}
+	}
+}
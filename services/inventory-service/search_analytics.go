@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"autolytiq/shared/apierror"
+)
+
+// searchPriceBandWidth is the width of the price bands searches are grouped by
+const searchPriceBandWidth = 5000
+
+// maxSearchQueryTerms bounds how many words of a search query are kept
+const maxSearchQueryTerms = 5
+
+// maxSearchQueryLength bounds the stored length of a search query
+const maxSearchQueryLength = 100
+
+// searchInsightsLimit is how many entries each insights list returns
+const searchInsightsLimit = 10
+
+// SearchEvent is one inventory search, reduced to the fields that are
+// aggregated. It carries nothing that identifies the shopper.
+type SearchEvent struct {
+	DealershipID string
+	SearchDate   string // YYYY-MM-DD, UTC
+	Make         string
+	Model        string
+	PriceBand    string
+	Query        string
+	ResultCount  int
+}
+
+// SearchAggregate is the number of searches with the same make, model, price
+// band and query over a date range
+type SearchAggregate struct {
+	Make             string
+	Model            string
+	PriceBand        string
+	Query            string
+	Searches         int
+	NoResultSearches int
+}
+
+// SearchInsight is a searched value with how often it was searched
+type SearchInsight struct {
+	Make             string `json:"make,omitempty"`
+	Model            string `json:"model,omitempty"`
+	PriceBand        string `json:"price_band,omitempty"`
+	Query            string `json:"query,omitempty"`
+	Searches         int    `json:"searches"`
+	NoResultSearches int    `json:"no_result_searches"`
+}
+
+// SearchInsightsResponse reports what shoppers searched for over a date range
+type SearchInsightsResponse struct {
+	DealershipID  string `json:"dealership_id"`
+	From          string `json:"from"`
+	To            string `json:"to"`
+	TotalSearches int    `json:"total_searches"`
+
+	// SampleRate is the fraction of searches currently recorded; counts
+	// cover only the sampled searches
+	SampleRate float64 `json:"sample_rate"`
+
+	TopMakes      []*SearchInsight `json:"top_makes"`
+	TopModels     []*SearchInsight `json:"top_models"`
+	TopPriceBands []*SearchInsight `json:"top_price_bands"`
+	NoResultsGaps []*SearchInsight `json:"no_results_gaps"`
+}
+
+// searchPriceBand groups a searched price range into fixed-width bands, e.g.
+// "20000-25000", "0-15000" or "30000+". It is empty when no price was given.
+func searchPriceBand(priceMin, priceMax float64) string {
+	if priceMin <= 0 && priceMax <= 0 {
+		return ""
+	}
+	lower := math.Floor(priceMin/searchPriceBandWidth) * searchPriceBandWidth
+	if priceMax <= 0 {
+		return fmt.Sprintf("%.0f+", lower)
+	}
+	upper := math.Ceil(priceMax/searchPriceBandWidth) * searchPriceBandWidth
+	if upper <= lower {
+		upper = lower + searchPriceBandWidth
+	}
+	return fmt.Sprintf("%.0f-%.0f", lower, upper)
+}
+
+// anonymizeSearchQuery reduces a free-text query to a few lowercase words.
+// Words that could identify a shopper - email addresses, phone numbers and
+// other long digit runs - are dropped.
+func anonymizeSearchQuery(q string) string {
+	var terms []string
+	for _, word := range strings.Fields(strings.ToLower(q)) {
+		if len(terms) == maxSearchQueryTerms {
+			break
+		}
+		if strings.Contains(word, "@") {
+			continue
+		}
+		digits := 0
+		for _, r := range word {
+			if unicode.IsDigit(r) {
+				digits++
+			}
+		}
+		if digits > 4 {
+			continue
+		}
+		word = strings.TrimFunc(word, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		if word != "" {
+			terms = append(terms, word)
+		}
+	}
+
+	query := strings.Join(terms, " ")
+	if len(query) > maxSearchQueryLength {
+		query = strings.TrimSpace(query[:maxSearchQueryLength])
+	}
+	return query
+}
+
+// newSearchEvent builds the event for a vehicle search. It returns nil when
+// the request was a plain listing rather than a shopper search.
+func newSearchEvent(dealershipID string, filters map[string]interface{}, q string, results int, now time.Time) *SearchEvent {
+	if dealershipID == "" {
+		return nil
+	}
+
+	event := &SearchEvent{
+		DealershipID: dealershipID,
+		SearchDate:   now.UTC().Format(snapshotDateLayout),
+		Query:        anonymizeSearchQuery(q),
+		ResultCount:  results,
+	}
+	event.Make, _ = filters["make"].(string)
+	event.Model, _ = filters["model"].(string)
+	priceMin, _ := filters["price_min"].(float64)
+	priceMax, _ := filters["price_max"].(float64)
+	event.PriceBand = searchPriceBand(priceMin, priceMax)
+
+	if event.Make == "" && event.Model == "" && event.PriceBand == "" && event.Query == "" {
+		return nil
+	}
+	return event
+}
+
+// recordSearch records a sampled vehicle search. Failures are logged and
+// never fail the search itself.
+func (s *Server) recordSearch(r *http.Request, dealershipID string, filters map[string]interface{}, q string, results int) {
+	rate := s.config.SearchSampleRate
+	if rate <= 0 || (rate < 1 && rand.Float64() >= rate) {
+		return
+	}
+
+	event := newSearchEvent(dealershipID, filters, q, results, time.Now())
+	if event == nil {
+		return
+	}
+	if err := s.db.RecordSearch(event); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Warn("Failed to record search analytics")
+	}
+}
+
+// summarizeSearchInsights ranks the aggregated searches by make, model and
+// price band, and lists the searches that found nothing
+func summarizeSearchInsights(aggregates []*SearchAggregate, limit int) *SearchInsightsResponse {
+	resp := &SearchInsightsResponse{}
+	makes := make(map[string]*SearchInsight)
+	models := make(map[string]*SearchInsight)
+	bands := make(map[string]*SearchInsight)
+	gaps := make(map[string]*SearchInsight)
+
+	add := func(groups map[string]*SearchInsight, key string, insight SearchInsight, a *SearchAggregate) {
+		group, ok := groups[key]
+		if !ok {
+			group = &insight
+			groups[key] = group
+		}
+		group.Searches += a.Searches
+		group.NoResultSearches += a.NoResultSearches
+	}
+
+	for _, a := range aggregates {
+		resp.TotalSearches += a.Searches
+		if a.Make != "" {
+			add(makes, a.Make, SearchInsight{Make: a.Make}, a)
+		}
+		if a.Model != "" {
+			add(models, a.Make+"|"+a.Model, SearchInsight{Make: a.Make, Model: a.Model}, a)
+		}
+		if a.PriceBand != "" {
+			add(bands, a.PriceBand, SearchInsight{PriceBand: a.PriceBand}, a)
+		}
+		if a.NoResultSearches > 0 {
+			key := strings.Join([]string{a.Make, a.Model, a.PriceBand, a.Query}, "|")
+			gap := SearchInsight{Make: a.Make, Model: a.Model, PriceBand: a.PriceBand, Query: a.Query}
+			group, ok := gaps[key]
+			if !ok {
+				group = &gap
+				gaps[key] = group
+			}
+			// A gap counts only the searches that came back empty
+			group.Searches += a.NoResultSearches
+			group.NoResultSearches += a.NoResultSearches
+		}
+	}
+
+	resp.TopMakes = rankSearchInsights(makes, limit)
+	resp.TopModels = rankSearchInsights(models, limit)
+	resp.TopPriceBands = rankSearchInsights(bands, limit)
+	resp.NoResultsGaps = rankSearchInsights(gaps, limit)
+	return resp
+}
+
+// rankSearchInsights orders insights by search count, most searched first
+func rankSearchInsights(groups map[string]*SearchInsight, limit int) []*SearchInsight {
+	ranked := make([]*SearchInsight, 0, len(groups))
+	for _, insight := range groups {
+		ranked = append(ranked, insight)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.Searches != b.Searches {
+			return a.Searches > b.Searches
+		}
+		return strings.Join([]string{a.Make, a.Model, a.PriceBand, a.Query}, "|") <
+			strings.Join([]string{b.Make, b.Model, b.PriceBand, b.Query}, "|")
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+// getSearchInsights reports the makes, models and price bands shoppers
+// searched for and the searches that found no vehicles
+func (s *Server) getSearchInsights(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	dealershipID := query.Get("dealership_id")
+	if dealershipID == "" {
+		apierror.WriteError(w, http.StatusBadRequest, "DEALERSHIP_ID_REQUIRED", "dealership_id query parameter is required")
+		return
+	}
+	if !validateUUID(w, dealershipID, "dealership_id") {
+		return
+	}
+
+	from, to, verrs := parseTrendRange(query.Get("from"), query.Get("to"), time.Now())
+	if verrs != nil {
+		respondValidationError(w, verrs)
+		return
+	}
+
+	aggregates, err := s.db.ListSearchAggregates(dealershipID, from, to)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list search analytics")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get search insights: %v", err))
+		return
+	}
+
+	resp := summarizeSearchInsights(aggregates, searchInsightsLimit)
+	resp.DealershipID = dealershipID
+	resp.From = from.Format(snapshotDateLayout)
+	resp.To = to.Format(snapshotDateLayout)
+	resp.SampleRate = s.config.SearchSampleRate
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
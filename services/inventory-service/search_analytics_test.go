@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func (db *MockDatabase) RecordSearch(event *SearchEvent) error {
+	key := strings.Join([]string{event.DealershipID, event.SearchDate, event.Make, event.Model, event.PriceBand, event.Query}, "|")
+	a, ok := db.searches[key]
+	if !ok {
+		a = &SearchAggregate{Make: event.Make, Model: event.Model, PriceBand: event.PriceBand, Query: event.Query}
+		db.searches[key] = a
+	}
+	a.Searches++
+	if event.ResultCount == 0 {
+		a.NoResultSearches++
+	}
+	return nil
+}
+
+func (db *MockDatabase) ListSearchAggregates(dealershipID string, from, to time.Time) ([]*SearchAggregate, error) {
+	var aggregates []*SearchAggregate
+	for key, a := range db.searches {
+		parts := strings.SplitN(key, "|", 3)
+		date, _ := time.Parse(snapshotDateLayout, parts[1])
+		if parts[0] != dealershipID || date.Before(from) || date.After(to) {
+			continue
+		}
+		aggregates = append(aggregates, a)
+	}
+	return aggregates, nil
+}
+
+func searchVehicles(t *testing.T, server *Server, query string) []*Vehicle {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/vehicles?"+query, nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var vehicles []*Vehicle
+	json.NewDecoder(rr.Body).Decode(&vehicles)
+	return vehicles
+}
+
+func getSearchInsightsResponse(t *testing.T, server *Server, dealershipID string) SearchInsightsResponse {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/vehicles/search-insights?dealership_id="+dealershipID, nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp SearchInsightsResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	return resp
+}
+
+func TestListVehiclesRecordsSearches(t *testing.T) {
+	server := setupTestServer()
+	server.config.SearchSampleRate = 1
+	db := server.db.(*MockDatabase)
+	dealershipID := uuid.New().String()
+	db.vehicles["v1"] = &Vehicle{ID: "v1", DealershipID: dealershipID, Make: "Toyota", Model: "Camry", Trim: "XSE", Price: 27000}
+
+	if got := searchVehicles(t, server, "dealership_id="+dealershipID+"&make=Toyota&price_max=28000"); len(got) != 1 {
+		t.Fatalf("Expected one matching vehicle, got %d", len(got))
+	}
+	searchVehicles(t, server, "dealership_id="+dealershipID+"&make=Toyota&price_max=28000")
+	if got := searchVehicles(t, server, "dealership_id="+dealershipID+"&q=camry+xse"); len(got) != 1 {
+		t.Fatalf("Expected the free-text search to match, got %d", len(got))
+	}
+
+	// Plain listings and searches without a dealership are not recorded
+	searchVehicles(t, server, "dealership_id="+dealershipID)
+	searchVehicles(t, server, "make=Toyota")
+
+	if len(db.searches) != 2 {
+		t.Fatalf("Expected two aggregated searches, got %d", len(db.searches))
+	}
+	for _, a := range db.searches {
+		switch {
+		case a.Make == "Toyota":
+			if a.Searches != 2 || a.PriceBand != "0-30000" || a.NoResultSearches != 0 {
+				t.Errorf("Expected two Toyota searches in the 0-30000 band, got %+v", a)
+			}
+		case a.Query == "camry xse":
+			if a.Searches != 1 {
+				t.Errorf("Expected one free-text search, got %+v", a)
+			}
+		default:
+			t.Errorf("Unexpected aggregate %+v", a)
+		}
+	}
+
+	server.config.SearchSampleRate = 0
+	searchVehicles(t, server, "dealership_id="+dealershipID+"&make=Honda")
+	if len(db.searches) != 2 {
+		t.Error("Expected no searches to be recorded with sampling off")
+	}
+}
+
+func TestSearchInsightsSurfacesNoResultGaps(t *testing.T) {
+	server := setupTestServer()
+	server.config.SearchSampleRate = 1
+	db := server.db.(*MockDatabase)
+	dealershipID := uuid.New().String()
+	db.vehicles["v1"] = &Vehicle{ID: "v1", DealershipID: dealershipID, Make: "Toyota", Model: "Camry", Price: 27000}
+
+	for i := 0; i < 3; i++ {
+		searchVehicles(t, server, "dealership_id="+dealershipID+"&make=Ford&model=Bronco")
+	}
+	searchVehicles(t, server, "dealership_id="+dealershipID+"&make=Toyota&model=Camry")
+	searchVehicles(t, server, "dealership_id="+dealershipID+"&make=Toyota&price_min=40000")
+
+	resp := getSearchInsightsResponse(t, server, dealershipID)
+	if resp.TotalSearches != 5 || resp.SampleRate != 1 {
+		t.Errorf("Expected 5 searches at sample rate 1, got %d at %v", resp.TotalSearches, resp.SampleRate)
+	}
+	if len(resp.TopMakes) != 2 || resp.TopMakes[0].Make != "Ford" || resp.TopMakes[0].Searches != 3 {
+		t.Errorf("Expected Ford to be the most searched make, got %+v", resp.TopMakes)
+	}
+	if len(resp.TopModels) != 2 || resp.TopModels[0].Model != "Bronco" {
+		t.Errorf("Expected Bronco to be the most searched model, got %+v", resp.TopModels)
+	}
+	if len(resp.TopPriceBands) != 1 || resp.TopPriceBands[0].PriceBand != "40000+" {
+		t.Errorf("Expected the 40000+ price band, got %+v", resp.TopPriceBands)
+	}
+
+	if len(resp.NoResultsGaps) != 2 {
+		t.Fatalf("Expected two no-results gaps, got %+v", resp.NoResultsGaps)
+	}
+	bronco := resp.NoResultsGaps[0]
+	if bronco.Make != "Ford" || bronco.Model != "Bronco" || bronco.Searches != 3 {
+		t.Errorf("Expected Ford Bronco to be the largest gap, got %+v", bronco)
+	}
+	if gap := resp.NoResultsGaps[1]; gap.Make != "Toyota" || gap.PriceBand != "40000+" {
+		t.Errorf("Expected Toyota over 40000 to be a gap, got %+v", gap)
+	}
+
+	// Searches at another dealership are not included
+	if other := getSearchInsightsResponse(t, server, uuid.New().String()); other.TotalSearches != 0 {
+		t.Errorf("Expected no searches for another dealership, got %d", other.TotalSearches)
+	}
+}
+
+func TestAnonymizeSearchQuery(t *testing.T) {
+	tests := []struct {
+		q    string
+		want string
+	}{
+		{"  Red  CAMRY 2021 ", "red camry 2021"},
+		{"camry for jane@example.com", "camry for"},
+		{"call 555-123-4567 about the f-150", "call about the f-150"},
+		{"truck, 4x4!", "truck 4x4"},
+		{"one two three four five six seven", "one two three four five"},
+	}
+	for _, tt := range tests {
+		if got := anonymizeSearchQuery(tt.q); got != tt.want {
+			t.Errorf("anonymizeSearchQuery(%q) = %q, want %q", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestSearchPriceBand(t *testing.T) {
+	tests := []struct {
+		min, max float64
+		want     string
+	}{
+		{0, 0, ""},
+		{0, 28000, "0-30000"},
+		{21000, 24000, "20000-25000"},
+		{40000, 0, "40000+"},
+		{25000, 25000, "25000-30000"},
+	}
+	for _, tt := range tests {
+		if got := searchPriceBand(tt.min, tt.max); got != tt.want {
+			t.Errorf("searchPriceBand(%v, %v) = %q, want %q", tt.min, tt.max, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/logging"
+)
+
+// snapshotDateLayout is the format of snapshot dates and trend range parameters
+const snapshotDateLayout = "2006-01-02"
+
+// maxTrendRangeDays bounds the date range of a single trend request
+const maxTrendRangeDays = 366
+
+// defaultTrendRangeDays is the range returned when from is omitted
+const defaultTrendRangeDays = 30
+
+// InventoryTrendResponse is the time series returned by the trend endpoint
+type InventoryTrendResponse struct {
+	DealershipID string               `json:"dealership_id"`
+	From         string               `json:"from"`
+	To           string               `json:"to"`
+	Snapshots    []*InventorySnapshot `json:"snapshots"`
+}
+
+// computeInventorySnapshot summarizes a dealership's on-lot inventory. Sold
+// vehicles are excluded; days on lot is measured from when the vehicle
+// arrived at the dealership's lot.
+func computeInventorySnapshot(dealershipID string, vehicles []*Vehicle, now time.Time) *InventorySnapshot {
+	snapshot := &InventorySnapshot{
+		DealershipID: dealershipID,
+		SnapshotDate: now.UTC().Format(snapshotDateLayout),
+		CreatedAt:    now.UTC().Format(time.RFC3339),
+	}
+
+	var totalPrice, totalDays float64
+	var datedCount int
+	for _, v := range vehicles {
+		if v.DealershipID != dealershipID || v.Status == "sold" {
+			continue
+		}
+		snapshot.VehicleCount++
+		totalPrice += v.Price
+
+		arrived := v.LotArrivedAt
+		if arrived == "" {
+			arrived = v.CreatedAt
+		}
+		if arrivedAt, err := time.Parse(time.RFC3339, arrived); err == nil {
+			days := now.Sub(arrivedAt).Hours() / 24
+			if days < 0 {
+				days = 0
+			}
+			totalDays += days
+			datedCount++
+		}
+	}
+
+	if snapshot.VehicleCount > 0 {
+		snapshot.AveragePrice = math.Round(totalPrice/float64(snapshot.VehicleCount)*100) / 100
+	}
+	if datedCount > 0 {
+		snapshot.AverageDaysOnLot = math.Round(totalDays/float64(datedCount)*10) / 10
+	}
+
+	return snapshot
+}
+
+// InventorySnapshotJob periodically records a snapshot of each dealership's
+// inventory. Each run overwrites the current day's snapshot, so the stored
+// value for a day reflects the last run on that day.
+type InventorySnapshotJob struct {
+	db       VehicleDatabase
+	interval time.Duration
+	logger   *logging.Logger
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+	running  bool
+	mu       sync.Mutex
+}
+
+// NewInventorySnapshotJob creates a new inventory snapshot job
+func NewInventorySnapshotJob(db VehicleDatabase, interval time.Duration, logger *logging.Logger) *InventorySnapshotJob {
+	return &InventorySnapshotJob{
+		db:       db,
+		interval: interval,
+		logger:   logger,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start begins recording snapshots
+func (j *InventorySnapshotJob) Start() {
+	j.mu.Lock()
+	if j.running {
+		j.mu.Unlock()
+		return
+	}
+	j.running = true
+	j.mu.Unlock()
+
+	j.logger.WithField("interval", j.interval.String()).Info("Starting inventory snapshot job")
+
+	j.wg.Add(1)
+	go j.run()
+}
+
+// Stop stops the job and waits for an in-progress run to finish
+func (j *InventorySnapshotJob) Stop() {
+	j.mu.Lock()
+	if !j.running {
+		j.mu.Unlock()
+		return
+	}
+	j.running = false
+	j.mu.Unlock()
+
+	close(j.stopChan)
+	j.wg.Wait()
+	j.logger.Info("Inventory snapshot job stopped")
+}
+
+func (j *InventorySnapshotJob) run() {
+	defer j.wg.Done()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	// Record today's snapshot on startup so a restart never leaves a gap
+	j.SnapshotAll(time.Now())
+
+	for {
+		select {
+		case <-j.stopChan:
+			return
+		case now := <-ticker.C:
+			j.SnapshotAll(now)
+		}
+	}
+}
+
+// SnapshotAll records a snapshot for every dealership with inventory and
+// returns the number recorded. A failure for one dealership does not stop the
+// others.
+func (j *InventorySnapshotJob) SnapshotAll(now time.Time) int {
+	dealershipIDs, err := j.db.ListDealershipIDs()
+	if err != nil {
+		j.logger.WithError(err).Error("Failed to list dealerships for inventory snapshot")
+		return 0
+	}
+
+	recorded := 0
+	for _, dealershipID := range dealershipIDs {
+		vehicles, err := j.db.ListVehicles(dealershipID, nil)
+		if err != nil {
+			j.logger.WithError(err).WithField("dealership_id", dealershipID).Error("Failed to list vehicles for inventory snapshot")
+			continue
+		}
+
+		snapshot := computeInventorySnapshot(dealershipID, vehicles, now)
+		if err := j.db.UpsertInventorySnapshot(snapshot); err != nil {
+			j.logger.WithError(err).WithField("dealership_id", dealershipID).Error("Failed to save inventory snapshot")
+			continue
+		}
+		recorded++
+	}
+
+	j.logger.WithField("count", recorded).Debug("Recorded inventory snapshots")
+	return recorded
+}
+
+// parseTrendRange parses the from and to query parameters. to defaults to
+// today and from to 30 days before to.
+func parseTrendRange(fromParam, toParam string, now time.Time) (time.Time, time.Time, *ValidationErrors) {
+	var errs []ValidationError
+
+	to, _ := time.Parse(snapshotDateLayout, now.UTC().Format(snapshotDateLayout))
+	if toParam != "" {
+		parsed, err := time.Parse(snapshotDateLayout, toParam)
+		if err != nil {
+			errs = append(errs, ValidationError{Field: "to", Message: "Date must be in YYYY-MM-DD format"})
+		} else {
+			to = parsed
+		}
+	}
+
+	from := to.AddDate(0, 0, -defaultTrendRangeDays)
+	if fromParam != "" {
+		parsed, err := time.Parse(snapshotDateLayout, fromParam)
+		if err != nil {
+			errs = append(errs, ValidationError{Field: "from", Message: "Date must be in YYYY-MM-DD format"})
+		} else {
+			from = parsed
+		}
+	}
+
+	if len(errs) == 0 {
+		if from.After(to) {
+			errs = append(errs, ValidationError{Field: "from", Message: "From date must not be after to date"})
+		} else if to.Sub(from).Hours()/24 > maxTrendRangeDays {
+			errs = append(errs, ValidationError{Field: "from", Message: fmt.Sprintf("Date range must not exceed %d days", maxTrendRangeDays)})
+		}
+	}
+
+	if len(errs) > 0 {
+		return time.Time{}, time.Time{}, &ValidationErrors{Errors: errs}
+	}
+	return from, to, nil
+}
+
+// getInventoryStatsTrend returns daily inventory snapshots for a date range
+func (s *Server) getInventoryStatsTrend(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	dealershipID := query.Get("dealership_id")
+	if dealershipID == "" {
+		apierror.WriteError(w, http.StatusBadRequest, "DEALERSHIP_ID_REQUIRED", "dealership_id query parameter is required")
+		return
+	}
+	if !validateUUID(w, dealershipID, "dealership_id") {
+		return
+	}
+
+	from, to, verrs := parseTrendRange(query.Get("from"), query.Get("to"), time.Now())
+	if verrs != nil {
+		respondValidationError(w, verrs)
+		return
+	}
+
+	snapshots, err := s.db.ListInventorySnapshots(dealershipID, from, to)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list inventory snapshots")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get inventory trend: %v", err))
+		return
+	}
+
+	if snapshots == nil {
+		snapshots = []*InventorySnapshot{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(InventoryTrendResponse{
+		DealershipID: dealershipID,
+		From:         from.Format(snapshotDateLayout),
+		To:           to.Format(snapshotDateLayout),
+		Snapshots:    snapshots,
+	})
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestComputeInventorySnapshot(t *testing.T) {
+	dealershipID := "11111111-1111-1111-1111-111111111111"
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	vehicles := []*Vehicle{
+		{DealershipID: dealershipID, Status: "available", Price: 20000, CreatedAt: now.AddDate(0, 0, -10).Format(time.RFC3339)},
+		{DealershipID: dealershipID, Status: "pending", Price: 30000, CreatedAt: now.AddDate(0, 0, -20).Format(time.RFC3339)},
+		{DealershipID: dealershipID, Status: "sold", Price: 90000, CreatedAt: now.AddDate(0, 0, -90).Format(time.RFC3339)},
+		{DealershipID: "other-dealership", Status: "available", Price: 5000, CreatedAt: now.Format(time.RFC3339)},
+	}
+
+	snapshot := computeInventorySnapshot(dealershipID, vehicles, now)
+
+	if snapshot.SnapshotDate != "2024-03-10" {
+		t.Errorf("Expected snapshot date 2024-03-10, got %s", snapshot.SnapshotDate)
+	}
+	if snapshot.VehicleCount != 2 {
+		t.Errorf("Expected 2 on-lot vehicles, got %d", snapshot.VehicleCount)
+	}
+	if snapshot.AveragePrice != 25000 {
+		t.Errorf("Expected average price 25000, got %v", snapshot.AveragePrice)
+	}
+	if snapshot.AverageDaysOnLot != 15 {
+		t.Errorf("Expected average days on lot 15, got %v", snapshot.AverageDaysOnLot)
+	}
+
+	empty := computeInventorySnapshot(dealershipID, nil, now)
+	if empty.VehicleCount != 0 || empty.AveragePrice != 0 || empty.AverageDaysOnLot != 0 {
+		t.Errorf("Expected empty snapshot, got %+v", empty)
+	}
+}
+
+func TestInventorySnapshotJob_SnapshotAll(t *testing.T) {
+	db := NewMockDatabase()
+	now := time.Now().UTC()
+
+	db.CreateVehicle(&Vehicle{ID: "v1", DealershipID: "dealer-a", Status: "available", Price: 10000, CreatedAt: now.Format(time.RFC3339)})
+	db.CreateVehicle(&Vehicle{ID: "v2", DealershipID: "dealer-a", Status: "available", Price: 20000, CreatedAt: now.Format(time.RFC3339)})
+	db.CreateVehicle(&Vehicle{ID: "v3", DealershipID: "dealer-b", Status: "available", Price: 40000, CreatedAt: now.Format(time.RFC3339)})
+
+	job := NewInventorySnapshotJob(db, time.Hour, testLogger())
+	if recorded := job.SnapshotAll(now); recorded != 2 {
+		t.Fatalf("Expected 2 snapshots recorded, got %d", recorded)
+	}
+
+	today := now.Format(snapshotDateLayout)
+	a := db.snapshots["dealer-a/"+today]
+	if a == nil || a.VehicleCount != 2 || a.AveragePrice != 15000 {
+		t.Errorf("Unexpected snapshot for dealer-a: %+v", a)
+	}
+	b := db.snapshots["dealer-b/"+today]
+	if b == nil || b.VehicleCount != 1 {
+		t.Errorf("Unexpected snapshot for dealer-b: %+v", b)
+	}
+
+	// A second run on the same day replaces the day's snapshot
+	db.vehicles["v2"].Status = "sold"
+	job.SnapshotAll(now)
+	if len(db.snapshots) != 2 {
+		t.Errorf("Expected snapshots to be replaced, got %d", len(db.snapshots))
+	}
+	if a := db.snapshots["dealer-a/"+today]; a.VehicleCount != 1 {
+		t.Errorf("Expected dealer-a snapshot to reflect the sale, got %+v", a)
+	}
+}
+
+func TestGetInventoryStatsTrend(t *testing.T) {
+	server := setupTestServer()
+	mockDB := server.db.(*MockDatabase)
+	dealershipID := "11111111-1111-1111-1111-111111111111"
+
+	for i, date := range []string{"2024-02-28", "2024-03-01", "2024-03-02", "2024-03-05"} {
+		mockDB.UpsertInventorySnapshot(&InventorySnapshot{
+			DealershipID: dealershipID,
+			SnapshotDate: date,
+			VehicleCount: 10 + i,
+		})
+	}
+	mockDB.UpsertInventorySnapshot(&InventorySnapshot{
+		DealershipID: "22222222-2222-2222-2222-222222222222",
+		SnapshotDate: "2024-03-01",
+		VehicleCount: 99,
+	})
+
+	req, _ := http.NewRequest("GET", "/vehicles/stats/trend?dealership_id="+dealershipID+"&from=2024-03-01&to=2024-03-04", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	var resp InventoryTrendResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.From != "2024-03-01" || resp.To != "2024-03-04" {
+		t.Errorf("Unexpected range %s to %s", resp.From, resp.To)
+	}
+	if len(resp.Snapshots) != 2 {
+		t.Fatalf("Expected 2 snapshots in range, got %d", len(resp.Snapshots))
+	}
+	if resp.Snapshots[0].SnapshotDate != "2024-03-01" || resp.Snapshots[1].SnapshotDate != "2024-03-02" {
+		t.Errorf("Expected snapshots in date order, got %s, %s", resp.Snapshots[0].SnapshotDate, resp.Snapshots[1].SnapshotDate)
+	}
+	if resp.Snapshots[0].VehicleCount != 11 {
+		t.Errorf("Expected vehicle count 11, got %d", resp.Snapshots[0].VehicleCount)
+	}
+}
+
+func TestGetInventoryStatsTrend_EmptyRange(t *testing.T) {
+	server := setupTestServer()
+	dealershipID := "11111111-1111-1111-1111-111111111111"
+
+	req, _ := http.NewRequest("GET", "/vehicles/stats/trend?dealership_id="+dealershipID, nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var resp InventoryTrendResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp.Snapshots == nil || len(resp.Snapshots) != 0 {
+		t.Errorf("Expected an empty snapshot list, got %v", resp.Snapshots)
+	}
+
+	to, _ := time.Parse(snapshotDateLayout, resp.To)
+	from, _ := time.Parse(snapshotDateLayout, resp.From)
+	if days := int(to.Sub(from).Hours() / 24); days != defaultTrendRangeDays {
+		t.Errorf("Expected default range of %d days, got %d", defaultTrendRangeDays, days)
+	}
+}
+
+func TestGetInventoryStatsTrend_InvalidParams(t *testing.T) {
+	server := setupTestServer()
+	dealershipID := "11111111-1111-1111-1111-111111111111"
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"missing dealership", "from=2024-03-01"},
+		{"invalid dealership", "dealership_id=not-a-uuid"},
+		{"bad date", "dealership_id=" + dealershipID + "&from=03/01/2024"},
+		{"from after to", "dealership_id=" + dealershipID + "&from=2024-03-05&to=2024-03-01"},
+		{"range too long", "dealership_id=" + dealershipID + "&from=2022-01-01&to=2024-01-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/vehicles/stats/trend?"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected 400, got %v", rr.Code)
+			}
+		})
+	}
+}
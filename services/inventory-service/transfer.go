@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/configclient"
+	"autolytiq/shared/logging"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// settingDealerGroupID is the config-service key naming the dealer group a
+// dealership belongs to. Vehicles can only move between dealerships in the
+// same group.
+const settingDealerGroupID = "dealer_group_id"
+
+// maxTransferReasonLength is the longest transfer reason accepted
+const maxTransferReasonLength = 500
+
+// nonTransferableStatuses are vehicle statuses that tie a vehicle to its lot
+var nonTransferableStatuses = map[string]bool{
+	"sold":     true,
+	"pending":  true,
+	"reserved": true,
+}
+
+// DealerGroupSettings reads dealership settings from config-service
+type DealerGroupSettings interface {
+	GetString(ctx context.Context, dealershipID, key string) (string, error)
+}
+
+// newSettingsClient creates a cached config-service client
+func newSettingsClient(baseURL string) *configclient.Client {
+	cfg := configclient.DefaultConfig()
+	cfg.BaseURL = baseURL
+	cfg.CacheTTL = 5 * time.Minute
+	return configclient.NewClient(cfg)
+}
+
+// VehicleTransfer records a vehicle moving between dealerships in a group
+type VehicleTransfer struct {
+	ID               string `json:"id"`
+	VehicleID        string `json:"vehicle_id"`
+	FromDealershipID string `json:"from_dealership_id"`
+	ToDealershipID   string `json:"to_dealership_id"`
+	DealerGroupID    string `json:"dealer_group_id"`
+	Reason           string `json:"reason,omitempty"`
+	TransferredBy    string `json:"transferred_by,omitempty"`
+	TransferredAt    string `json:"transferred_at"`
+}
+
+// TransferVehicleRequest represents a request to move a vehicle to another
+// dealership
+type TransferVehicleRequest struct {
+	TargetDealershipID string `json:"target_dealership_id"`
+	Reason             string `json:"reason,omitempty"`
+}
+
+// Validate validates TransferVehicleRequest
+func (r *TransferVehicleRequest) Validate() *ValidationErrors {
+	var errors []ValidationError
+
+	if r.TargetDealershipID == "" {
+		errors = append(errors, ValidationError{
+			Field:   "target_dealership_id",
+			Message: "Target dealership ID is required",
+		})
+	} else if !uuidRegex.MatchString(r.TargetDealershipID) {
+		errors = append(errors, ValidationError{
+			Field:   "target_dealership_id",
+			Message: "Must be a valid UUID",
+		})
+	}
+
+	if len(r.Reason) > maxTransferReasonLength {
+		errors = append(errors, ValidationError{
+			Field:   "reason",
+			Message: fmt.Sprintf("Must be at most %d characters", maxTransferReasonLength),
+		})
+	}
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize sanitizes TransferVehicleRequest
+func (r *TransferVehicleRequest) Sanitize() {
+	r.TargetDealershipID = strings.TrimSpace(r.TargetDealershipID)
+	r.Reason = strings.TrimSpace(r.Reason)
+}
+
+// dealerGroupID returns the dealer group a dealership belongs to, or "" if
+// it isn't in one
+func (s *Server) dealerGroupID(ctx context.Context, dealershipID string) (string, error) {
+	group, err := s.dealerGroups.GetString(ctx, dealershipID, settingDealerGroupID)
+	if errors.Is(err, configclient.ErrNotFound) {
+		return "", nil
+	}
+	return strings.TrimSpace(group), err
+}
+
+// sharedDealerGroup returns the dealer group both dealerships belong to, or
+// "" if they aren't in the same group
+func (s *Server) sharedDealerGroup(ctx context.Context, fromDealershipID, toDealershipID string) (string, error) {
+	from, err := s.dealerGroupID(ctx, fromDealershipID)
+	if err != nil || from == "" {
+		return "", err
+	}
+	to, err := s.dealerGroupID(ctx, toDealershipID)
+	if err != nil || to != from {
+		return "", err
+	}
+	return from, nil
+}
+
+// transferVehicle moves a vehicle to another dealership in the same dealer
+// group. Days on lot restart at the new dealership. Vehicles that are sold,
+// held, or on an active deal stay where they are.
+func (s *Server) transferVehicle(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if !validateUUID(w, id, "id") {
+		return
+	}
+
+	var req TransferVehicleRequest
+	if !decodeAndValidate(r, w, &req) {
+		return
+	}
+
+	vehicle, err := s.db.GetVehicle(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get vehicle")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get vehicle: %v", err))
+		return
+	}
+	if vehicle == nil {
+		apierror.WriteError(w, http.StatusNotFound, "VEHICLE_NOT_FOUND", "Vehicle not found")
+		return
+	}
+
+	if req.TargetDealershipID == vehicle.DealershipID {
+		respondErrorJSON(w, http.StatusBadRequest, "Vehicle is already at the target dealership", "SAME_DEALERSHIP")
+		return
+	}
+	if nonTransferableStatuses[vehicle.Status] {
+		respondErrorJSON(w, http.StatusConflict, fmt.Sprintf("A %s vehicle cannot be transferred", vehicle.Status), "VEHICLE_UNAVAILABLE")
+		return
+	}
+
+	onDeal, err := s.db.HasActiveDeal(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to check active deals")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to transfer vehicle: %v", err))
+		return
+	}
+	if onDeal {
+		respondErrorJSON(w, http.StatusConflict, "Vehicle is on an active deal", "ACTIVE_DEAL")
+		return
+	}
+
+	if s.dealerGroups == nil {
+		respondErrorJSON(w, http.StatusServiceUnavailable, "Dealer group settings are unavailable", "SETTINGS_UNAVAILABLE")
+		return
+	}
+	group, err := s.sharedDealerGroup(r.Context(), vehicle.DealershipID, req.TargetDealershipID)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to load dealer group")
+		respondErrorJSON(w, http.StatusBadGateway, "Failed to load dealer group: "+err.Error(), "SETTINGS_UNAVAILABLE")
+		return
+	}
+	if group == "" {
+		respondErrorJSON(w, http.StatusForbidden, "Vehicles can only be transferred between dealerships in the same dealer group", "DIFFERENT_DEALER_GROUP")
+		return
+	}
+
+	transfer := &VehicleTransfer{
+		ID:               uuid.New().String(),
+		VehicleID:        vehicle.ID,
+		FromDealershipID: vehicle.DealershipID,
+		ToDealershipID:   req.TargetDealershipID,
+		DealerGroupID:    group,
+		Reason:           req.Reason,
+		TransferredBy:    logging.GetUserID(r.Context()),
+		TransferredAt:    time.Now().Format(time.RFC3339),
+	}
+	if err := s.db.TransferVehicle(transfer); err != nil {
+		if err.Error() == fmt.Sprintf("vehicle not found: %s", id) {
+			respondErrorJSON(w, http.StatusConflict, "Vehicle was changed by another request", "CONFLICT")
+		} else {
+			s.logger.WithContext(r.Context()).WithError(err).Error("Failed to transfer vehicle")
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to transfer vehicle: %v", err))
+		}
+		return
+	}
+
+	s.logger.WithContext(r.Context()).WithField("vehicle_id", vehicle.ID).WithField("from_dealership_id", transfer.FromDealershipID).WithField("to_dealership_id", transfer.ToDealershipID).Info("Vehicle transferred")
+
+	vehicle.DealershipID = transfer.ToDealershipID
+	vehicle.LotArrivedAt = transfer.TransferredAt
+	vehicle.UpdatedAt = transfer.TransferredAt
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"vehicle":  presentVehicle(r, vehicle),
+		"transfer": transfer,
+	})
+}
+
+// listVehicleTransfers returns a vehicle's transfer history, oldest first
+func (s *Server) listVehicleTransfers(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if !validateUUID(w, id, "id") {
+		return
+	}
+
+	transfers, err := s.db.ListVehicleTransfers(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list vehicle transfers")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to list vehicle transfers: %v", err))
+		return
+	}
+	if transfers == nil {
+		transfers = []*VehicleTransfer{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transfers)
+}
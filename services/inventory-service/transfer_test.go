@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"autolytiq/shared/configclient"
+
+	"github.com/google/uuid"
+)
+
+func (db *MockDatabase) HasActiveDeal(vehicleID string) (bool, error) {
+	return db.activeDeals[vehicleID], nil
+}
+
+func (db *MockDatabase) TransferVehicle(transfer *VehicleTransfer) error {
+	vehicle, ok := db.vehicles[transfer.VehicleID]
+	if !ok || vehicle.DealershipID != transfer.FromDealershipID {
+		return fmt.Errorf("vehicle not found: %s", transfer.VehicleID)
+	}
+	vehicle.DealershipID = transfer.ToDealershipID
+	vehicle.LotArrivedAt = transfer.TransferredAt
+	vehicle.UpdatedAt = transfer.TransferredAt
+	db.transfers[transfer.VehicleID] = append(db.transfers[transfer.VehicleID], transfer)
+	return nil
+}
+
+func (db *MockDatabase) ListVehicleTransfers(vehicleID string) ([]*VehicleTransfer, error) {
+	return db.transfers[vehicleID], nil
+}
+
+// staticDealerGroups maps dealership IDs to dealer group IDs
+type staticDealerGroups map[string]string
+
+func (g staticDealerGroups) GetString(ctx context.Context, dealershipID, key string) (string, error) {
+	group, ok := g[dealershipID]
+	if !ok || key != settingDealerGroupID {
+		return "", configclient.ErrNotFound
+	}
+	return group, nil
+}
+
+func setupTransferServer() (*Server, *MockDatabase, *Vehicle, string) {
+	server := setupTestServer()
+	db := server.db.(*MockDatabase)
+
+	vehicle := &Vehicle{
+		ID:           uuid.New().String(),
+		DealershipID: uuid.New().String(),
+		Make:         "Honda",
+		Model:        "Civic",
+		Year:         2022,
+		Status:       "available",
+		Price:        24000,
+		CreatedAt:    time.Now().AddDate(0, 0, -45).Format(time.RFC3339),
+	}
+	db.CreateVehicle(vehicle)
+
+	target := uuid.New().String()
+	server.dealerGroups = staticDealerGroups{
+		vehicle.DealershipID: "group-1",
+		target:               "group-1",
+	}
+	return server, db, vehicle, target
+}
+
+func transferRequest(server *Server, vehicleID string, req TransferVehicleRequest) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(req)
+	httpReq := httptest.NewRequest("POST", "/vehicles/"+vehicleID+"/transfer", bytes.NewReader(body))
+	httpReq.Header.Set("X-User-ID", "manager-1")
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httpReq)
+	return rr
+}
+
+func TestTransferVehicleReassignsDealership(t *testing.T) {
+	server, db, vehicle, target := setupTransferServer()
+	source := vehicle.DealershipID
+
+	rr := transferRequest(server, vehicle.ID, TransferVehicleRequest{TargetDealershipID: target, Reason: "Customer request"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if vehicle.DealershipID != target {
+		t.Errorf("Expected dealership %s, got %s", target, vehicle.DealershipID)
+	}
+
+	// Days on lot restart at the new dealership
+	snapshot := computeInventorySnapshot(target, []*Vehicle{vehicle}, time.Now())
+	if snapshot.VehicleCount != 1 || snapshot.AverageDaysOnLot > 1 {
+		t.Errorf("Expected days on lot to reset, got %+v", snapshot)
+	}
+
+	transfers := db.transfers[vehicle.ID]
+	if len(transfers) != 1 {
+		t.Fatalf("Expected 1 transfer recorded, got %d", len(transfers))
+	}
+	got := transfers[0]
+	if got.FromDealershipID != source || got.ToDealershipID != target || got.DealerGroupID != "group-1" || got.TransferredBy != "manager-1" {
+		t.Errorf("Unexpected transfer record %+v", got)
+	}
+
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("GET", "/vehicles/"+vehicle.ID+"/transfers", nil))
+	var history []VehicleTransfer
+	json.Unmarshal(rr.Body.Bytes(), &history)
+	if rr.Code != http.StatusOK || len(history) != 1 || history[0].Reason != "Customer request" {
+		t.Errorf("Expected transfer history, got %d %+v", rr.Code, history)
+	}
+}
+
+func TestTransferVehicleBlocked(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(server *Server, db *MockDatabase, vehicle *Vehicle, target string) string
+		status int
+		code   string
+	}{
+		{
+			name: "active deal",
+			setup: func(server *Server, db *MockDatabase, vehicle *Vehicle, target string) string {
+				db.activeDeals[vehicle.ID] = true
+				return target
+			},
+			status: http.StatusConflict,
+			code:   "ACTIVE_DEAL",
+		},
+		{
+			name: "reserved",
+			setup: func(server *Server, db *MockDatabase, vehicle *Vehicle, target string) string {
+				vehicle.Status = "reserved"
+				return target
+			},
+			status: http.StatusConflict,
+			code:   "VEHICLE_UNAVAILABLE",
+		},
+		{
+			name: "different group",
+			setup: func(server *Server, db *MockDatabase, vehicle *Vehicle, target string) string {
+				server.dealerGroups.(staticDealerGroups)[target] = "group-2"
+				return target
+			},
+			status: http.StatusForbidden,
+			code:   "DIFFERENT_DEALER_GROUP",
+		},
+		{
+			name: "no group",
+			setup: func(server *Server, db *MockDatabase, vehicle *Vehicle, target string) string {
+				return uuid.New().String()
+			},
+			status: http.StatusForbidden,
+			code:   "DIFFERENT_DEALER_GROUP",
+		},
+		{
+			name: "same dealership",
+			setup: func(server *Server, db *MockDatabase, vehicle *Vehicle, target string) string {
+				return vehicle.DealershipID
+			},
+			status: http.StatusBadRequest,
+			code:   "SAME_DEALERSHIP",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, db, vehicle, target := setupTransferServer()
+			source := vehicle.DealershipID
+			target = tt.setup(server, db, vehicle, target)
+
+			rr := transferRequest(server, vehicle.ID, TransferVehicleRequest{TargetDealershipID: target})
+			if rr.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, rr.Code, rr.Body.String())
+			}
+			var resp errorResponse
+			json.Unmarshal(rr.Body.Bytes(), &resp)
+			if resp.Error.Code != tt.code {
+				t.Errorf("Expected code %s, got %s", tt.code, resp.Error.Code)
+			}
+			if vehicle.DealershipID != source || len(db.transfers[vehicle.ID]) != 0 {
+				t.Error("Expected the vehicle to stay at its dealership")
+			}
+		})
+	}
+}
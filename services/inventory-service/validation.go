@@ -6,6 +6,9 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/bodylimit"
 )
 
 // ValidationError represents a single validation error
@@ -19,42 +22,64 @@ type ValidationErrors struct {
 	Errors []ValidationError `json:"errors"`
 }
 
-// ValidationErrorResponse is the standard error response format
-type ValidationErrorResponse struct {
-	Error   string            `json:"error"`
-	Code    string            `json:"code"`
-	Details []ValidationError `json:"details,omitempty"`
+// Error implements error, so validation failures can be returned from
+// functions that aren't request validators
+func (e *ValidationErrors) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Field + ": " + err.Message
+	}
+	return strings.Join(messages, "; ")
 }
 
 // CreateVehicleRequest represents a request to create a vehicle
 type CreateVehicleRequest struct {
-	DealershipID string  `json:"dealership_id"`
-	VIN          string  `json:"vin"`
-	Make         string  `json:"make"`
-	Model        string  `json:"model"`
-	Year         int     `json:"year"`
-	Condition    string  `json:"condition"`
-	Status       string  `json:"status"`
-	Price        float64 `json:"price"`
-	Mileage      int     `json:"mileage"`
-	Color        string  `json:"color"`
-	Description  string  `json:"description"`
-	StockNumber  string  `json:"stock_number"`
+	DealershipID string   `json:"dealership_id"`
+	VIN          string   `json:"vin"`
+	Make         string   `json:"make"`
+	Model        string   `json:"model"`
+	Year         int      `json:"year"`
+	Condition    string   `json:"condition"`
+	Status       string   `json:"status"`
+	Price        float64  `json:"price"`
+	Mileage      int      `json:"mileage"`
+	Color        string   `json:"color"`
+	Description  string   `json:"description"`
+	StockNumber  string   `json:"stock_number"`
+	Cost         *float64 `json:"cost,omitempty"`
 }
 
 // UpdateVehicleRequest represents a request to update a vehicle
 type UpdateVehicleRequest struct {
-	VIN         string  `json:"vin,omitempty"`
-	Make        string  `json:"make,omitempty"`
-	Model       string  `json:"model,omitempty"`
-	Year        int     `json:"year,omitempty"`
-	Condition   string  `json:"condition,omitempty"`
-	Status      string  `json:"status,omitempty"`
-	Price       float64 `json:"price,omitempty"`
-	Mileage     int     `json:"mileage,omitempty"`
-	Color       string  `json:"color,omitempty"`
-	Description string  `json:"description,omitempty"`
-	StockNumber string  `json:"stock_number,omitempty"`
+	VIN         string   `json:"vin,omitempty"`
+	Make        string   `json:"make,omitempty"`
+	Model       string   `json:"model,omitempty"`
+	Year        int      `json:"year,omitempty"`
+	Condition   string   `json:"condition,omitempty"`
+	Status      string   `json:"status,omitempty"`
+	Price       float64  `json:"price,omitempty"`
+	Mileage     int      `json:"mileage,omitempty"`
+	Color       string   `json:"color,omitempty"`
+	Description string   `json:"description,omitempty"`
+	StockNumber string   `json:"stock_number,omitempty"`
+	Cost        *float64 `json:"cost,omitempty"`
+}
+
+// PatchVehicleRequest is a partial vehicle update. Only fields present in
+// the body are changed, and they may be set to zero or empty.
+type PatchVehicleRequest struct {
+	VIN         *string  `json:"vin"`
+	Make        *string  `json:"make"`
+	Model       *string  `json:"model"`
+	Year        *int     `json:"year"`
+	Condition   *string  `json:"condition"`
+	Status      *string  `json:"status"`
+	Price       *float64 `json:"price"`
+	Mileage     *int     `json:"mileage"`
+	Color       *string  `json:"color"`
+	Description *string  `json:"description"`
+	StockNumber *string  `json:"stock_number"`
+	Cost        *float64 `json:"cost"`
 }
 
 // ValidateVINRequest represents a VIN validation request
@@ -62,13 +87,22 @@ type ValidateVINRequest struct {
 	VIN string `json:"vin"`
 }
 
+// UpsertSynonymRequest represents a request to add or replace a make/model synonym
+type UpsertSynonymRequest struct {
+	Field     string `json:"field"`
+	Make      string `json:"make,omitempty"`
+	Synonym   string `json:"synonym"`
+	Canonical string `json:"canonical"`
+}
+
 var (
-	uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	uuidRegex  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
 
 	validConditions = map[string]bool{
-		"new":     true,
-		"used":    true,
-		"cpo":     true, // Certified Pre-Owned
+		"new":  true,
+		"used": true,
+		"cpo":  true, // Certified Pre-Owned
 	}
 
 	validStatuses = map[string]bool{
@@ -78,52 +112,8 @@ var (
 		"reserved":  true,
 		"transit":   true,
 	}
-
-	// VIN character transliteration map
-	vinTransliteration = map[rune]int{
-		'A': 1, 'B': 2, 'C': 3, 'D': 4, 'E': 5, 'F': 6, 'G': 7, 'H': 8,
-		'J': 1, 'K': 2, 'L': 3, 'M': 4, 'N': 5, 'P': 7, 'R': 9,
-		'S': 2, 'T': 3, 'U': 4, 'V': 5, 'W': 6, 'X': 7, 'Y': 8, 'Z': 9,
-		'0': 0, '1': 1, '2': 2, '3': 3, '4': 4, '5': 5, '6': 6, '7': 7, '8': 8, '9': 9,
-	}
-
-	// VIN checksum weights
-	vinWeights = []int{8, 7, 6, 5, 4, 3, 2, 10, 0, 9, 8, 7, 6, 5, 4, 3, 2}
 )
 
-// validateVINChecksum validates the VIN checksum
-func validateVINChecksum(vin string) bool {
-	vin = strings.ToUpper(vin)
-	if len(vin) != 17 {
-		return false
-	}
-
-	// Check for invalid characters
-	if strings.ContainsAny(vin, "IOQ") {
-		return false
-	}
-
-	// Calculate checksum
-	var sum int
-	for i, char := range vin {
-		value, ok := vinTransliteration[char]
-		if !ok {
-			return false
-		}
-		sum += value * vinWeights[i]
-	}
-
-	checkDigit := sum % 11
-	var expectedCheck rune
-	if checkDigit == 10 {
-		expectedCheck = 'X'
-	} else {
-		expectedCheck = rune('0' + checkDigit)
-	}
-
-	return rune(vin[8]) == expectedCheck
-}
-
 // Validate validates CreateVehicleRequest
 func (r *CreateVehicleRequest) Validate() *ValidationErrors {
 	var errors []ValidationError
@@ -233,6 +223,14 @@ func (r *CreateVehicleRequest) Validate() *ValidationErrors {
 		})
 	}
 
+	// Cost validation
+	if r.Cost != nil && *r.Cost < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "cost",
+			Message: "Cost cannot be negative",
+		})
+	}
+
 	// Description length validation
 	if len(r.Description) > 5000 {
 		errors = append(errors, ValidationError{
@@ -338,6 +336,14 @@ func (r *UpdateVehicleRequest) Validate() *ValidationErrors {
 		})
 	}
 
+	// Cost validation
+	if r.Cost != nil && *r.Cost < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "cost",
+			Message: "Cost cannot be negative",
+		})
+	}
+
 	// Description length validation
 	if len(r.Description) > 5000 {
 		errors = append(errors, ValidationError{
@@ -364,6 +370,178 @@ func (r *UpdateVehicleRequest) Sanitize() {
 	r.StockNumber = strings.TrimSpace(r.StockNumber)
 }
 
+// Validate validates PatchVehicleRequest
+func (r *PatchVehicleRequest) Validate() *ValidationErrors {
+	var errors []ValidationError
+
+	// VIN validation
+	if r.VIN != nil && *r.VIN != "" {
+		if len(*r.VIN) != 17 {
+			errors = append(errors, ValidationError{
+				Field:   "vin",
+				Message: "VIN must be exactly 17 characters",
+			})
+		} else if !validateVINChecksum(*r.VIN) {
+			errors = append(errors, ValidationError{
+				Field:   "vin",
+				Message: "Invalid VIN checksum",
+			})
+		}
+	}
+
+	// Make validation
+	if r.Make != nil {
+		if *r.Make == "" {
+			errors = append(errors, ValidationError{
+				Field:   "make",
+				Message: "Make cannot be empty",
+			})
+		} else if len(*r.Make) > 100 {
+			errors = append(errors, ValidationError{
+				Field:   "make",
+				Message: "Make must be 100 characters or less",
+			})
+		}
+	}
+
+	// Model validation
+	if r.Model != nil {
+		if *r.Model == "" {
+			errors = append(errors, ValidationError{
+				Field:   "model",
+				Message: "Model cannot be empty",
+			})
+		} else if len(*r.Model) > 100 {
+			errors = append(errors, ValidationError{
+				Field:   "model",
+				Message: "Model must be 100 characters or less",
+			})
+		}
+	}
+
+	// Year validation
+	if r.Year != nil {
+		currentYear := time.Now().Year()
+		if *r.Year < 1900 || *r.Year > currentYear+2 {
+			errors = append(errors, ValidationError{
+				Field:   "year",
+				Message: "Year must be valid",
+			})
+		}
+	}
+
+	// Condition validation
+	if r.Condition != nil && !validConditions[*r.Condition] {
+		errors = append(errors, ValidationError{
+			Field:   "condition",
+			Message: "Invalid condition. Must be one of: new, used, cpo",
+		})
+	}
+
+	// Status validation
+	if r.Status != nil && !validStatuses[*r.Status] {
+		errors = append(errors, ValidationError{
+			Field:   "status",
+			Message: "Invalid status. Must be one of: available, pending, sold, reserved, transit",
+		})
+	}
+
+	// Price validation
+	if r.Price != nil && *r.Price < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "price",
+			Message: "Price cannot be negative",
+		})
+	}
+
+	// Mileage validation
+	if r.Mileage != nil && *r.Mileage < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "mileage",
+			Message: "Mileage cannot be negative",
+		})
+	}
+
+	// Cost validation
+	if r.Cost != nil && *r.Cost < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "cost",
+			Message: "Cost cannot be negative",
+		})
+	}
+
+	// Description length validation
+	if r.Description != nil && len(*r.Description) > 5000 {
+		errors = append(errors, ValidationError{
+			Field:   "description",
+			Message: "Description must be 5000 characters or less",
+		})
+	}
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize sanitizes PatchVehicleRequest
+func (r *PatchVehicleRequest) Sanitize() {
+	for _, field := range []*string{r.VIN, r.Make, r.Model, r.Condition, r.Status, r.Color, r.Description, r.StockNumber} {
+		if field != nil {
+			*field = strings.TrimSpace(*field)
+		}
+	}
+	if r.VIN != nil {
+		*r.VIN = strings.ToUpper(*r.VIN)
+	}
+	if r.Condition != nil {
+		*r.Condition = strings.ToLower(*r.Condition)
+	}
+	if r.Status != nil {
+		*r.Status = strings.ToLower(*r.Status)
+	}
+}
+
+// Apply sets the fields present in the request on a vehicle
+func (r *PatchVehicleRequest) Apply(vehicle *Vehicle) {
+	if r.VIN != nil {
+		vehicle.VIN = *r.VIN
+	}
+	if r.Make != nil {
+		vehicle.MakeRaw = *r.Make
+	}
+	if r.Model != nil {
+		vehicle.ModelRaw = *r.Model
+	}
+	if r.Year != nil {
+		vehicle.Year = *r.Year
+	}
+	if r.Condition != nil {
+		vehicle.Condition = *r.Condition
+	}
+	if r.Status != nil {
+		vehicle.Status = *r.Status
+	}
+	if r.Price != nil {
+		vehicle.Price = *r.Price
+	}
+	if r.Mileage != nil {
+		vehicle.Mileage = *r.Mileage
+	}
+	if r.Color != nil {
+		vehicle.Color = *r.Color
+	}
+	if r.Description != nil {
+		vehicle.Description = *r.Description
+	}
+	if r.StockNumber != nil {
+		vehicle.StockNumber = *r.StockNumber
+	}
+	if r.Cost != nil {
+		vehicle.Cost = r.Cost
+	}
+}
+
 // Validate validates ValidateVINRequest
 func (r *ValidateVINRequest) Validate() *ValidationErrors {
 	var errors []ValidationError
@@ -386,25 +564,70 @@ func (r *ValidateVINRequest) Sanitize() {
 	r.VIN = strings.TrimSpace(strings.ToUpper(r.VIN))
 }
 
+// Validate validates UpsertSynonymRequest
+func (r *UpsertSynonymRequest) Validate() *ValidationErrors {
+	var errors []ValidationError
+
+	if r.Field != SynonymFieldMake && r.Field != SynonymFieldModel {
+		errors = append(errors, ValidationError{
+			Field:   "field",
+			Message: "Field must be make or model",
+		})
+	}
+
+	if r.Field == SynonymFieldMake && r.Make != "" {
+		errors = append(errors, ValidationError{
+			Field:   "make",
+			Message: "Make can only be set for model synonyms",
+		})
+	}
+
+	if r.Synonym == "" {
+		errors = append(errors, ValidationError{
+			Field:   "synonym",
+			Message: "Synonym is required",
+		})
+	} else if len(r.Synonym) > 100 {
+		errors = append(errors, ValidationError{
+			Field:   "synonym",
+			Message: "Synonym must be 100 characters or less",
+		})
+	}
+
+	if r.Canonical == "" {
+		errors = append(errors, ValidationError{
+			Field:   "canonical",
+			Message: "Canonical value is required",
+		})
+	} else if len(r.Canonical) > 50 {
+		errors = append(errors, ValidationError{
+			Field:   "canonical",
+			Message: "Canonical value must be 50 characters or less",
+		})
+	}
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize sanitizes UpsertSynonymRequest
+func (r *UpsertSynonymRequest) Sanitize() {
+	r.Field = strings.TrimSpace(strings.ToLower(r.Field))
+	r.Make = cleanValue(r.Make)
+	r.Synonym = cleanValue(r.Synonym)
+	r.Canonical = cleanValue(r.Canonical)
+}
+
 // respondValidationError writes a validation error response
 func respondValidationError(w http.ResponseWriter, errors *ValidationErrors) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusBadRequest)
-	json.NewEncoder(w).Encode(ValidationErrorResponse{
-		Error:   "Validation failed",
-		Code:    "VALIDATION_ERROR",
-		Details: errors.Errors,
-	})
+	apierror.WriteErrorDetails(w, http.StatusBadRequest, apierror.CodeValidation, "Validation failed", errors.Errors)
 }
 
 // respondErrorJSON writes a JSON error response
 func respondErrorJSON(w http.ResponseWriter, status int, message, code string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(ValidationErrorResponse{
-		Error: message,
-		Code:  code,
-	})
+	apierror.WriteError(w, status, code, message)
 }
 
 // Validatable interface for types that can validate themselves
@@ -420,10 +643,19 @@ type Sanitizable interface {
 // decodeAndValidate decodes JSON and validates the request
 func decodeAndValidate(r *http.Request, w http.ResponseWriter, dst interface{}) bool {
 	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		if bodylimit.IsTooLarge(err) {
+			bodylimit.RespondTooLarge(w)
+			return false
+		}
 		respondErrorJSON(w, http.StatusBadRequest, "Invalid request body: "+err.Error(), "INVALID_JSON")
 		return false
 	}
 
+	return sanitizeAndValidate(w, dst)
+}
+
+// sanitizeAndValidate sanitizes and validates an already decoded request
+func sanitizeAndValidate(w http.ResponseWriter, dst interface{}) bool {
 	// Sanitize if applicable
 	if sanitizable, ok := dst.(Sanitizable); ok {
 		sanitizable.Sanitize()
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	// VIN character transliteration map
+	vinTransliteration = map[rune]int{
+		'A': 1, 'B': 2, 'C': 3, 'D': 4, 'E': 5, 'F': 6, 'G': 7, 'H': 8,
+		'J': 1, 'K': 2, 'L': 3, 'M': 4, 'N': 5, 'P': 7, 'R': 9,
+		'S': 2, 'T': 3, 'U': 4, 'V': 5, 'W': 6, 'X': 7, 'Y': 8, 'Z': 9,
+		'0': 0, '1': 1, '2': 2, '3': 3, '4': 4, '5': 5, '6': 6, '7': 7, '8': 8, '9': 9,
+	}
+
+	// VIN checksum weights
+	vinWeights = []int{8, 7, 6, 5, 4, 3, 2, 10, 0, 9, 8, 7, 6, 5, 4, 3, 2}
+
+	// Model year codes for position 10, in order from 1980. The codes repeat
+	// every 30 years.
+	vinModelYearCodes = "ABCDEFGHJKLMNPRSTVWXY123456789"
+)
+
+// VINInfo is what can be decoded from a VIN without a lookup
+type VINInfo struct {
+	VIN string `json:"vin"`
+
+	// WMI is the World Manufacturer Identifier, positions 1-3
+	WMI       string `json:"wmi"`
+	ModelYear int    `json:"model_year"`
+
+	CheckDigit         string `json:"check_digit"`
+	ExpectedCheckDigit string `json:"expected_check_digit"`
+	CheckDigitValid    bool   `json:"check_digit_valid"`
+}
+
+// DecodeVIN decodes the manufacturer, model year, and check digit of a
+// 17-character VIN. A VIN that can't be decoded is reported as
+// *ValidationErrors on the vin field. A wrong check digit is not an error,
+// it is reported in CheckDigitValid.
+func DecodeVIN(vin string) (*VINInfo, error) {
+	vin = strings.TrimSpace(strings.ToUpper(vin))
+
+	var errors []ValidationError
+	if vin == "" {
+		errors = append(errors, ValidationError{
+			Field:   "vin",
+			Message: "VIN is required",
+		})
+	} else if len(vin) != 17 {
+		errors = append(errors, ValidationError{
+			Field:   "vin",
+			Message: "VIN must be exactly 17 characters",
+		})
+	} else {
+		for i, char := range vin {
+			switch {
+			case char == 'I' || char == 'O' || char == 'Q':
+				errors = append(errors, ValidationError{
+					Field:   "vin",
+					Message: fmt.Sprintf("VIN cannot contain letters I, O, or Q (found %c at position %d)", char, i+1),
+				})
+			case !isVINCharacter(char):
+				errors = append(errors, ValidationError{
+					Field:   "vin",
+					Message: fmt.Sprintf("Invalid character %q at position %d", char, i+1),
+				})
+			}
+		}
+	}
+	if len(errors) > 0 {
+		return nil, &ValidationErrors{Errors: errors}
+	}
+
+	modelYear := vinModelYear(vin)
+	if modelYear == 0 {
+		return nil, &ValidationErrors{Errors: []ValidationError{{
+			Field:   "vin",
+			Message: fmt.Sprintf("Invalid model year code %c at position 10", vin[9]),
+		}}}
+	}
+
+	expected := vinCheckDigit(vin)
+	return &VINInfo{
+		VIN:                vin,
+		WMI:                vin[:3],
+		ModelYear:          modelYear,
+		CheckDigit:         vin[8:9],
+		ExpectedCheckDigit: string(expected),
+		CheckDigitValid:    rune(vin[8]) == expected,
+	}, nil
+}
+
+// isVINCharacter reports whether char is a letter or digit with a
+// transliteration value
+func isVINCharacter(char rune) bool {
+	_, ok := vinTransliteration[char]
+	return ok
+}
+
+// vinCheckDigit calculates the check digit for position 9 of a 17-character
+// VIN of valid characters: each transliterated character times its
+// position's weight, summed mod 11, with 10 written as X
+func vinCheckDigit(vin string) rune {
+	var sum int
+	for i, char := range vin {
+		sum += vinTransliteration[char] * vinWeights[i]
+	}
+
+	checkDigit := sum % 11
+	if checkDigit == 10 {
+		return 'X'
+	}
+	return rune('0' + checkDigit)
+}
+
+// vinModelYear returns the model year encoded in position 10, or 0 if it
+// isn't a model year code. The code alone is ambiguous between two 30-year
+// cycles: a letter in position 7 places the vehicle in 2010 or later, a digit
+// before 2010.
+func vinModelYear(vin string) int {
+	index := strings.IndexByte(vinModelYearCodes, vin[9])
+	if index < 0 {
+		return 0
+	}
+
+	year := 1980 + index
+	if position7 := vin[6]; position7 < '0' || position7 > '9' {
+		year += 30
+	}
+	return year
+}
+
+// validateVINChecksum validates the VIN checksum
+func validateVINChecksum(vin string) bool {
+	vin = strings.ToUpper(vin)
+	if len(vin) != 17 {
+		return false
+	}
+
+	// Check for invalid characters
+	if strings.ContainsAny(vin, "IOQ") {
+		return false
+	}
+	for _, char := range vin {
+		if !isVINCharacter(char) {
+			return false
+		}
+	}
+
+	return rune(vin[8]) == vinCheckDigit(vin)
+}
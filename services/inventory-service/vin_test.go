@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeVIN(t *testing.T) {
+	info, err := DecodeVIN(" 1hgcm82633a004352 ")
+	if err != nil {
+		t.Fatalf("Expected the VIN to decode, got %v", err)
+	}
+	expected := VINInfo{
+		VIN:                "1HGCM82633A004352",
+		WMI:                "1HG",
+		ModelYear:          2003,
+		CheckDigit:         "3",
+		ExpectedCheckDigit: "3",
+		CheckDigitValid:    true,
+	}
+	if *info != expected {
+		t.Errorf("Expected %+v, got %+v", expected, *info)
+	}
+
+	// X is the check digit for a remainder of 10
+	if info, err := DecodeVIN("1M8GDM9AXKP042788"); err != nil || !info.CheckDigitValid || info.ModelYear != 1989 {
+		t.Errorf("Expected a valid 1989 VIN, got %+v %v", info, err)
+	}
+
+	info, err = DecodeVIN("1HGCM82643A004352")
+	if err != nil {
+		t.Fatalf("Expected a wrong check digit not to be an error, got %v", err)
+	}
+	if info.CheckDigitValid || info.CheckDigit != "4" || info.ExpectedCheckDigit != "3" {
+		t.Errorf("Expected the check digit to fail verification, got %+v", info)
+	}
+}
+
+func TestVINModelYear(t *testing.T) {
+	for vin, year := range map[string]int{
+		"1HGCM82633A004352": 2003,
+		"1HGCM8A633A004352": 2033,
+		"1HGCM82631A004352": 2001,
+		"1HGCM826XAA004352": 1980,
+		"1HGCM8A63AA004352": 2010,
+		"1HGCM8A63YA004352": 2030,
+		"1HGCM8A639A004352": 2039,
+		"1HGCM8263UA004352": 0,
+		"1HGCM82630A004352": 0,
+	} {
+		if got := vinModelYear(vin); got != year {
+			t.Errorf("vinModelYear(%s): expected %d, got %d", vin, year, got)
+		}
+	}
+}
+
+func TestDecodeVINFieldErrors(t *testing.T) {
+	tests := []struct {
+		vin     string
+		message string
+		errors  int
+	}{
+		{"", "VIN is required", 1},
+		{"1HGCM82633A00435", "exactly 17 characters", 1},
+		{"1HGCM8I633A0O4352", "I, O, or Q", 2},
+		{"1HGCM82633A00435Q", "position 17", 1},
+		{"1HGCM826-3A004352", "Invalid character", 1},
+		{"1HGCM82630A004352", "position 10", 1},
+	}
+
+	for _, tt := range tests {
+		info, err := DecodeVIN(tt.vin)
+		var validationErrs *ValidationErrors
+		if !errors.As(err, &validationErrs) {
+			t.Errorf("DecodeVIN(%q): expected validation errors, got %+v %v", tt.vin, info, err)
+			continue
+		}
+		if len(validationErrs.Errors) != tt.errors ||
+			validationErrs.Errors[0].Field != "vin" || !strings.Contains(validationErrs.Errors[0].Message, tt.message) {
+			t.Errorf("DecodeVIN(%q): expected %d vin errors mentioning %q, got %+v", tt.vin, tt.errors, tt.message, validationErrs.Errors)
+		}
+	}
+}
+
+func TestValidateVINChecksum(t *testing.T) {
+	for vin, valid := range map[string]bool{
+		"1HGCM82633A004352": true,
+		"1m8gdm9axkp042788": true,
+		"1HGCM82643A004352": false,
+		"1HGCM82633A00435":  false,
+		"1HGCM82O33A004352": false,
+	} {
+		if validateVINChecksum(vin) != valid {
+			t.Errorf("Expected validateVINChecksum(%s) = %v", vin, valid)
+		}
+	}
+}
+
+func TestDecodeVINHandlerRejectsInvalidVIN(t *testing.T) {
+	server := setupTestServer()
+
+	body, _ := json.Marshal(DecodeVINRequest{VIN: "1HGCM8I633A004352"})
+	req := httptest.NewRequest("POST", "/vehicles/decode-vin", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response errorResponse
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if len(response.Error.Details) != 1 || response.Error.Details[0].Field != "vin" || !strings.Contains(response.Error.Details[0].Message, "position 7") {
+		t.Errorf("Expected a vin field error for position 7, got %+v", response)
+	}
+}
+
+// fakeVINDecoder answers NHTSA lookups without the network
+type fakeVINDecoder struct {
+	info *DecodedVehicleInfo
+	err  error
+}
+
+func (d *fakeVINDecoder) DecodeVIN(vin string) (*DecodedVehicleInfo, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	info := *d.info
+	info.VIN = vin
+	return &info, nil
+}
+
+func TestCreateVehicleDecodesVIN(t *testing.T) {
+	server := setupTestServer()
+	decoder := &fakeVINDecoder{info: &DecodedVehicleInfo{Make: "HONDA", Model: "Accord", Year: 2003}}
+	server.vinDecoder = decoder
+	dealershipID := "8f14e45f-ceea-4e7a-9b1d-3c5e2f7a1b20"
+
+	create := func(query string, req CreateVehicleRequest) *httptest.ResponseRecorder {
+		req.DealershipID = dealershipID
+		req.VIN = "1HGCM82633A004352"
+		req.Price = 5500
+		body, _ := json.Marshal(req)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest("POST", "/vehicles"+query, bytes.NewBuffer(body)))
+		return rr
+	}
+
+	rr := create("?decode=true", CreateVehicleRequest{Color: "Silver"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created Vehicle
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	if created.MakeRaw != "HONDA" || created.ModelRaw != "Accord" || created.Year != 2003 {
+		t.Errorf("Expected the make, model and year from the VIN, got %+v", created)
+	}
+
+	// Supplied values that agree with the VIN are kept as entered
+	rr = create("?decode=true", CreateVehicleRequest{Make: "honda", Model: "ACCORD", Year: 2003})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	if created.MakeRaw != "honda" || created.ModelRaw != "ACCORD" {
+		t.Errorf("Expected the supplied make and model, got %+v", created)
+	}
+
+	rr = create("?decode=true", CreateVehicleRequest{Make: "Toyota", Year: 2023})
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("Expected 409, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var conflict VINMismatchResponse
+	json.Unmarshal(rr.Body.Bytes(), &conflict)
+	if conflict.Code != "VIN_MISMATCH" || len(conflict.Mismatches) != 2 ||
+		conflict.Mismatches[0].Field != "make" || conflict.Mismatches[0].Decoded != "HONDA" ||
+		conflict.Mismatches[1].Field != "year" || conflict.Mismatches[1].Supplied != float64(2023) {
+		t.Errorf("Expected make and year mismatches, got %+v", conflict)
+	}
+
+	// Without the flag the request is taken as sent
+	if rr := create("", CreateVehicleRequest{Make: "Toyota", Model: "Camry", Year: 2023}); rr.Code != http.StatusCreated {
+		t.Errorf("Expected 201 without decode, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := create("", CreateVehicleRequest{}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for missing fields without decode, got %d", rr.Code)
+	}
+
+	// Only the year can be decoded without NHTSA
+	decoder.err = errors.New("connection refused")
+	rr = create("?decode=true", CreateVehicleRequest{Make: "Honda", Model: "Accord"})
+	if rr.Code != http.StatusCreated || rr.Header().Get("X-VIN-Warning") == "" {
+		t.Fatalf("Expected 201 with a warning, got %d: %s", rr.Code, rr.Body.String())
+	}
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	if created.Year != 2003 {
+		t.Errorf("Expected the year from the VIN, got %d", created.Year)
+	}
+}
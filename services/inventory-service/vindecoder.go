@@ -15,6 +15,11 @@ type VINDecoderService struct {
 	httpClient *http.Client
 }
 
+// VehicleInfoDecoder looks up the vehicle a VIN was assigned to
+type VehicleInfoDecoder interface {
+	DecodeVIN(vin string) (*DecodedVehicleInfo, error)
+}
+
 // NewVINDecoderService creates a new VIN decoder service instance
 func NewVINDecoderService() *VINDecoderService {
 	return &VINDecoderService{
@@ -54,6 +59,9 @@ type DecodedVehicleInfo struct {
 	Manufacturer      string `json:"manufacturer"`
 	ErrorCode         string `json:"error_code,omitempty"`
 	ErrorText         string `json:"error_text,omitempty"`
+
+	// The WMI, model year and check digit decoded from the VIN itself
+	*VINInfo
 }
 
 // DecodeVINRequest represents the request to decode a VIN
@@ -63,31 +71,8 @@ type DecodeVINRequest struct {
 
 // Validate validates the DecodeVINRequest
 func (r *DecodeVINRequest) Validate() *ValidationErrors {
-	var errors []ValidationError
-
-	if r.VIN == "" {
-		errors = append(errors, ValidationError{
-			Field:   "vin",
-			Message: "VIN is required",
-		})
-	} else if len(r.VIN) != 17 {
-		errors = append(errors, ValidationError{
-			Field:   "vin",
-			Message: "VIN must be exactly 17 characters",
-		})
-	} else {
-		// Check for invalid characters
-		r.VIN = strings.ToUpper(r.VIN)
-		if strings.ContainsAny(r.VIN, "IOQ") {
-			errors = append(errors, ValidationError{
-				Field:   "vin",
-				Message: "VIN cannot contain letters I, O, or Q",
-			})
-		}
-	}
-
-	if len(errors) > 0 {
-		return &ValidationErrors{Errors: errors}
+	if _, err := DecodeVIN(r.VIN); err != nil {
+		return err.(*ValidationErrors)
 	}
 	return nil
 }
@@ -229,6 +214,75 @@ func (s *VINDecoderService) DecodeVIN(vin string) (*DecodedVehicleInfo, error) {
 	return info, nil
 }
 
+// VINMismatch is a field supplied when creating a vehicle that disagrees
+// with what its VIN decodes to
+type VINMismatch struct {
+	Field    string      `json:"field"`
+	Supplied interface{} `json:"supplied"`
+	Decoded  interface{} `json:"decoded"`
+}
+
+// VINMismatchResponse is the conflict response for VIN mismatches
+type VINMismatchResponse struct {
+	Error      string        `json:"error"`
+	Code       string        `json:"code"`
+	Mismatches []VINMismatch `json:"mismatches"`
+}
+
+// populateFromVIN fills in the make, model and year a create request left
+// empty from its VIN, and writes a 409 and returns false when supplied values
+// disagree with it. Makes and models are compared after normalization, so a
+// synonym of the decoded value is not a mismatch. A VIN that doesn't decode
+// or fails its checksum is left for validation to report. If NHTSA can't be
+// reached only the year is decoded.
+func (s *Server) populateFromVIN(w http.ResponseWriter, r *http.Request, req *CreateVehicleRequest) bool {
+	vinInfo, err := DecodeVIN(req.VIN)
+	if err != nil || !vinInfo.CheckDigitValid {
+		return true
+	}
+
+	decoded := DecodedVehicleInfo{Year: vinInfo.ModelYear}
+	if info, err := s.vinDecoder.DecodeVIN(vinInfo.VIN); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Warn("Failed to decode VIN with NHTSA")
+		w.Header().Set("X-VIN-Warning", "NHTSA lookup failed: "+err.Error())
+	} else {
+		decoded.Make = strings.TrimSpace(info.Make)
+		decoded.Model = strings.TrimSpace(info.Model)
+	}
+
+	var mismatches []VINMismatch
+	if req.Make == "" {
+		req.Make = decoded.Make
+	} else if decoded.Make != "" && !strings.EqualFold(s.normalizer.NormalizeMake(req.Make), s.normalizer.NormalizeMake(decoded.Make)) {
+		mismatches = append(mismatches, VINMismatch{Field: "make", Supplied: req.Make, Decoded: decoded.Make})
+	}
+
+	make := s.normalizer.NormalizeMake(req.Make)
+	if req.Model == "" {
+		req.Model = decoded.Model
+	} else if decoded.Model != "" && !strings.EqualFold(s.normalizer.NormalizeModel(make, req.Model), s.normalizer.NormalizeModel(make, decoded.Model)) {
+		mismatches = append(mismatches, VINMismatch{Field: "model", Supplied: req.Model, Decoded: decoded.Model})
+	}
+
+	if req.Year == 0 {
+		req.Year = decoded.Year
+	} else if req.Year != decoded.Year {
+		mismatches = append(mismatches, VINMismatch{Field: "year", Supplied: req.Year, Decoded: decoded.Year})
+	}
+
+	if len(mismatches) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(VINMismatchResponse{
+			Error:      "Vehicle details do not match the VIN",
+			Code:       "VIN_MISMATCH",
+			Mismatches: mismatches,
+		})
+		return false
+	}
+	return true
+}
+
 // decodeVINHandler handles VIN decoding requests
 func (s *Server) decodeVINHandler(w http.ResponseWriter, r *http.Request) {
 	var req DecodeVINRequest
@@ -236,23 +290,26 @@ func (s *Server) decodeVINHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create decoder service
-	decoder := NewVINDecoderService()
+	// Already validated, so this can't fail
+	vinInfo, _ := DecodeVIN(req.VIN)
 
 	// Decode the VIN
-	info, err := decoder.DecodeVIN(req.VIN)
+	info, err := s.vinDecoder.DecodeVIN(req.VIN)
 	if err != nil {
-		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to decode VIN")
-		respondErrorJSON(w, http.StatusBadGateway, "Failed to decode VIN: "+err.Error(), "VIN_DECODE_ERROR")
-		return
-	}
-
-	// Check if NHTSA reported errors
-	if info.ErrorCode != "" && info.ErrorCode != "0" {
+		// What the VIN itself encodes is still worth returning
+		s.logger.WithContext(r.Context()).WithError(err).Warn("Failed to decode VIN with NHTSA")
+		w.Header().Set("X-VIN-Warning", "NHTSA lookup failed: "+err.Error())
+		info = &DecodedVehicleInfo{VIN: vinInfo.VIN}
+	} else if info.ErrorCode != "" && info.ErrorCode != "0" {
 		// Return partial data with warning
 		w.Header().Set("X-VIN-Warning", info.ErrorText)
 	}
 
+	info.VINInfo = vinInfo
+	if info.Year == 0 {
+		info.Year = vinInfo.ModelYear
+	}
+
 	s.logger.WithContext(r.Context()).WithField("vin", req.VIN).Info("VIN decoded successfully")
 
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/logging"
+	"autolytiq/shared/tracing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// unsubscribeTokenBytes is the length of a watch's unsubscribe token
+const unsubscribeTokenBytes = 24
+
+// VehicleWatch is a customer's request to hear about price drops on a vehicle
+type VehicleWatch struct {
+	ID           string `json:"id"`
+	VehicleID    string `json:"vehicle_id"`
+	DealershipID string `json:"dealership_id"`
+	CustomerID   string `json:"customer_id,omitempty"`
+	Email        string `json:"email"`
+
+	// AlertPrice is the price the watcher last heard about. They are
+	// notified when the vehicle's price drops below it.
+	AlertPrice float64 `json:"alert_price"`
+
+	UnsubscribeToken string `json:"-"`
+	CreatedAt        string `json:"created_at"`
+}
+
+// WatchVehicleRequest represents a request to watch a vehicle for price drops
+type WatchVehicleRequest struct {
+	CustomerID string `json:"customer_id,omitempty"`
+	Email      string `json:"email"`
+}
+
+// Validate validates WatchVehicleRequest
+func (r *WatchVehicleRequest) Validate() *ValidationErrors {
+	var errors []ValidationError
+
+	if r.CustomerID != "" && !uuidRegex.MatchString(r.CustomerID) {
+		errors = append(errors, ValidationError{
+			Field:   "customer_id",
+			Message: "Must be a valid UUID",
+		})
+	}
+
+	if r.Email == "" {
+		errors = append(errors, ValidationError{
+			Field:   "email",
+			Message: "Email is required",
+		})
+	} else if !emailRegex.MatchString(r.Email) {
+		errors = append(errors, ValidationError{
+			Field:   "email",
+			Message: "Invalid email format",
+		})
+	}
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize sanitizes WatchVehicleRequest
+func (r *WatchVehicleRequest) Sanitize() {
+	r.CustomerID = strings.TrimSpace(r.CustomerID)
+	r.Email = strings.ToLower(strings.TrimSpace(r.Email))
+}
+
+// generateUnsubscribeToken returns a new random token for unsubscribe links
+func generateUnsubscribeToken() (string, error) {
+	b := make([]byte, unsubscribeTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// PriceDropNotifier tells a watcher that a vehicle's price has dropped
+type PriceDropNotifier interface {
+	NotifyPriceDrop(ctx context.Context, watch *VehicleWatch, vehicle *Vehicle) error
+}
+
+// EmailPriceDropNotifier sends price drop alerts through email-service
+type EmailPriceDropNotifier struct {
+	baseURL       string
+	publicBaseURL string
+	httpClient    *http.Client
+}
+
+// NewEmailPriceDropNotifier creates a notifier that emails price drop alerts.
+// publicBaseURL is the API gateway's public URL, used for unsubscribe links.
+func NewEmailPriceDropNotifier(baseURL, publicBaseURL string) *EmailPriceDropNotifier {
+	return &EmailPriceDropNotifier{
+		baseURL:       baseURL,
+		publicBaseURL: strings.TrimRight(publicBaseURL, "/"),
+		httpClient:    &http.Client{Transport: tracing.Transport(nil), Timeout: 5 * time.Second},
+	}
+}
+
+// unsubscribeURL returns the link a watcher follows to stop alerts
+func (n *EmailPriceDropNotifier) unsubscribeURL(watch *VehicleWatch) string {
+	return n.publicBaseURL + "/api/v1/inventory/vehicles/watches/unsubscribe?token=" + url.QueryEscape(watch.UnsubscribeToken)
+}
+
+// NotifyPriceDrop emails the watcher the vehicle's new price
+func (n *EmailPriceDropNotifier) NotifyPriceDrop(ctx context.Context, watch *VehicleWatch, vehicle *Vehicle) error {
+	title := strings.TrimSpace(fmt.Sprintf("%d %s %s", vehicle.Year, vehicle.Make, vehicle.Model))
+
+	body, err := json.Marshal(map[string]string{
+		"dealership_id": watch.DealershipID,
+		"to":            watch.Email,
+		"subject":       "Price drop: " + title,
+		"body_html": fmt.Sprintf(`<p>The %s you're watching dropped from $%.2f to $%.2f.</p><p><a href="%s">Stop price alerts for this vehicle</a></p>`,
+			html.EscapeString(title), watch.AlertPrice, vehicle.Price, html.EscapeString(n.unsubscribeURL(watch))),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.baseURL+"/email/send", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build price drop request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	logging.PropagateHeadersFromContext(ctx, req)
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send price drop alert: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("email-service returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// notifyPriceDrop alerts the vehicle's watchers when an update lowers its
+// price. Each watcher hears about a given price once; failures are logged and
+// retried on the next drop.
+func (s *Server) notifyPriceDrop(r *http.Request, before, after *Vehicle) {
+	if s.notifier == nil || after.Price >= before.Price || after.Status == "sold" {
+		return
+	}
+
+	log := s.logger.WithContext(r.Context()).WithField("vehicle_id", after.ID)
+
+	watches, err := s.db.ListNotifiableWatches(after.ID, after.Price)
+	if err != nil {
+		log.WithError(err).Warn("Failed to list vehicle watchers")
+		return
+	}
+
+	for _, watch := range watches {
+		if err := s.notifier.NotifyPriceDrop(r.Context(), watch, after); err != nil {
+			log.WithError(err).WithField("watch_id", watch.ID).Warn("Failed to send price drop alert")
+			continue
+		}
+		if err := s.db.MarkWatchNotified(watch.ID, after.Price); err != nil {
+			log.WithError(err).WithField("watch_id", watch.ID).Warn("Failed to record price drop alert")
+		}
+	}
+}
+
+// watchVehicle subscribes an email to price drops on a vehicle. Watching a
+// vehicle that is already watched by the same email returns the existing
+// watch.
+func (s *Server) watchVehicle(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if !validateUUID(w, id, "id") {
+		return
+	}
+
+	var req WatchVehicleRequest
+	if !decodeAndValidate(r, w, &req) {
+		return
+	}
+
+	vehicle, err := s.db.GetVehicle(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to get vehicle")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to get vehicle: %v", err))
+		return
+	}
+	if vehicle == nil {
+		apierror.WriteError(w, http.StatusNotFound, "VEHICLE_NOT_FOUND", "Vehicle not found")
+		return
+	}
+
+	token, err := generateUnsubscribeToken()
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to generate unsubscribe token")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to watch vehicle")
+		return
+	}
+
+	watch := &VehicleWatch{
+		ID:               uuid.New().String(),
+		VehicleID:        vehicle.ID,
+		DealershipID:     vehicle.DealershipID,
+		CustomerID:       req.CustomerID,
+		Email:            req.Email,
+		AlertPrice:       vehicle.Price,
+		UnsubscribeToken: token,
+		CreatedAt:        time.Now().Format(time.RFC3339),
+	}
+
+	created, err := s.db.CreateVehicleWatch(watch)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to watch vehicle")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to watch vehicle: %v", err))
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+		s.logger.WithContext(r.Context()).WithField("vehicle_id", vehicle.ID).WithField("watch_id", watch.ID).Info("Vehicle watched")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(watch)
+}
+
+// listVehicleWatchers returns a vehicle's active watchers
+func (s *Server) listVehicleWatchers(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if !validateUUID(w, id, "id") {
+		return
+	}
+
+	watches, err := s.db.ListVehicleWatches(id)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to list vehicle watchers")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to list vehicle watchers: %v", err))
+		return
+	}
+
+	if watches == nil {
+		watches = []*VehicleWatch{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(watches)
+}
+
+// unsubscribeVehicleWatch removes the watch identified by the token in a
+// price drop email. It is public, so the token is the only credential.
+func (s *Server) unsubscribeVehicleWatch(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		apierror.WriteError(w, http.StatusBadRequest, "TOKEN_REQUIRED", "token query parameter is required")
+		return
+	}
+
+	watch, err := s.db.RemoveVehicleWatch(token)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("Failed to unsubscribe vehicle watch")
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to unsubscribe: %v", err))
+		return
+	}
+	if watch == nil {
+		apierror.WriteError(w, http.StatusNotFound, "WATCH_NOT_FOUND", "Watch not found")
+		return
+	}
+
+	s.logger.WithContext(r.Context()).WithField("watch_id", watch.ID).Info("Vehicle watch unsubscribed")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"unsubscribed": true,
+		"vehicle_id":   watch.VehicleID,
+	})
+}
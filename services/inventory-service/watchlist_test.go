@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// mockWatch is a stored watch and whether it has been removed
+type mockWatch struct {
+	watch   VehicleWatch
+	removed bool
+}
+
+func (db *MockDatabase) CreateVehicleWatch(watch *VehicleWatch) (bool, error) {
+	for _, existing := range db.watches {
+		if existing.watch.VehicleID != watch.VehicleID || !strings.EqualFold(existing.watch.Email, watch.Email) {
+			continue
+		}
+		if !existing.removed {
+			*watch = existing.watch
+			return false, nil
+		}
+		existing.removed = false
+		existing.watch.AlertPrice = watch.AlertPrice
+		existing.watch.CreatedAt = watch.CreatedAt
+		if watch.CustomerID != "" {
+			existing.watch.CustomerID = watch.CustomerID
+		}
+		*watch = existing.watch
+		return true, nil
+	}
+
+	db.watches[watch.ID] = &mockWatch{watch: *watch}
+	return true, nil
+}
+
+func (db *MockDatabase) ListVehicleWatches(vehicleID string) ([]*VehicleWatch, error) {
+	var watches []*VehicleWatch
+	for _, w := range db.watches {
+		if w.watch.VehicleID == vehicleID && !w.removed {
+			watch := w.watch
+			watches = append(watches, &watch)
+		}
+	}
+	sort.Slice(watches, func(i, j int) bool { return watches[i].Email < watches[j].Email })
+	return watches, nil
+}
+
+func (db *MockDatabase) ListNotifiableWatches(vehicleID string, price float64) ([]*VehicleWatch, error) {
+	active, _ := db.ListVehicleWatches(vehicleID)
+
+	var watches []*VehicleWatch
+	for _, watch := range active {
+		if consent, ok := db.consent[watch.CustomerID]; ok && !consent {
+			continue
+		}
+		if watch.AlertPrice > price {
+			watches = append(watches, watch)
+		}
+	}
+	return watches, nil
+}
+
+func (db *MockDatabase) MarkWatchNotified(id string, price float64) error {
+	if w, ok := db.watches[id]; ok {
+		w.watch.AlertPrice = price
+	}
+	return nil
+}
+
+func (db *MockDatabase) RemoveVehicleWatch(token string) (*VehicleWatch, error) {
+	for _, w := range db.watches {
+		if w.watch.UnsubscribeToken == token {
+			w.removed = true
+			watch := w.watch
+			return &watch, nil
+		}
+	}
+	return nil, nil
+}
+
+// recordingNotifier records the price drop alerts it is asked to send
+type recordingNotifier struct {
+	sent []string // watcher emails
+}
+
+func (n *recordingNotifier) NotifyPriceDrop(ctx context.Context, watch *VehicleWatch, vehicle *Vehicle) error {
+	n.sent = append(n.sent, watch.Email)
+	return nil
+}
+
+func setupWatchServer() (*Server, *MockDatabase, *recordingNotifier, *Vehicle) {
+	server := setupTestServer()
+	notifier := &recordingNotifier{}
+	server.notifier = notifier
+
+	vehicle := &Vehicle{
+		ID:           uuid.New().String(),
+		DealershipID: uuid.New().String(),
+		Make:         "Toyota",
+		Model:        "RAV4",
+		Year:         2023,
+		Status:       "available",
+		Price:        30000,
+	}
+	db := server.db.(*MockDatabase)
+	db.CreateVehicle(vehicle)
+	return server, db, notifier, vehicle
+}
+
+func watchVehicleRequest(t *testing.T, server *Server, vehicleID string, req WatchVehicleRequest) (*httptest.ResponseRecorder, VehicleWatch) {
+	t.Helper()
+
+	body, _ := json.Marshal(req)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("POST", "/vehicles/"+vehicleID+"/watch", bytes.NewReader(body)))
+
+	var watch VehicleWatch
+	json.Unmarshal(rr.Body.Bytes(), &watch)
+	return rr, watch
+}
+
+func setVehiclePrice(t *testing.T, server *Server, vehicleID string, price float64) {
+	t.Helper()
+
+	body, _ := json.Marshal(UpdateVehicleRequest{Price: price})
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("PUT", "/vehicles/"+vehicleID, bytes.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("update vehicle returned %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func unsubscribeToken(db *MockDatabase, watchID string) string {
+	return db.watches[watchID].watch.UnsubscribeToken
+}
+
+func TestPriceDropNotifiesActiveWatchers(t *testing.T) {
+	server, db, notifier, vehicle := setupWatchServer()
+
+	_, active := watchVehicleRequest(t, server, vehicle.ID, WatchVehicleRequest{Email: "active@example.com"})
+	_, removed := watchVehicleRequest(t, server, vehicle.ID, WatchVehicleRequest{Email: "removed@example.com"})
+	optedOut := uuid.New().String()
+	watchVehicleRequest(t, server, vehicle.ID, WatchVehicleRequest{CustomerID: optedOut, Email: "optedout@example.com"})
+	db.consent[optedOut] = false
+
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("GET", "/vehicles/watches/unsubscribe?token="+unsubscribeToken(db, removed.ID), nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unsubscribe returned %d: %s", rr.Code, rr.Body.String())
+	}
+
+	setVehiclePrice(t, server, vehicle.ID, 28000)
+	if len(notifier.sent) != 1 || notifier.sent[0] != "active@example.com" {
+		t.Fatalf("Expected only the active watcher to be notified, got %v", notifier.sent)
+	}
+	if got := db.watches[active.ID].watch.AlertPrice; got != 28000 {
+		t.Errorf("Expected alert price 28000 after notifying, got %v", got)
+	}
+
+	// A price rise and a return to an announced price don't notify again
+	setVehiclePrice(t, server, vehicle.ID, 29000)
+	setVehiclePrice(t, server, vehicle.ID, 28000)
+	if len(notifier.sent) != 1 {
+		t.Errorf("Expected no repeat alerts, got %v", notifier.sent)
+	}
+
+	setVehiclePrice(t, server, vehicle.ID, 27500)
+	if len(notifier.sent) != 2 {
+		t.Errorf("Expected a second alert for a new low, got %v", notifier.sent)
+	}
+}
+
+func TestWatchVehicleDeduplicates(t *testing.T) {
+	server, db, _, vehicle := setupWatchServer()
+
+	rr, first := watchVehicleRequest(t, server, vehicle.ID, WatchVehicleRequest{Email: "buyer@example.com"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if first.AlertPrice != 30000 {
+		t.Errorf("Expected alert price to start at the current price, got %v", first.AlertPrice)
+	}
+
+	rr, second := watchVehicleRequest(t, server, vehicle.ID, WatchVehicleRequest{Email: " Buyer@Example.com "})
+	if rr.Code != http.StatusOK || second.ID != first.ID {
+		t.Errorf("Expected the existing watch with 200, got %d %+v", rr.Code, second)
+	}
+
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("GET", "/vehicles/"+vehicle.ID+"/watchers", nil))
+	var watchers []VehicleWatch
+	json.Unmarshal(rr.Body.Bytes(), &watchers)
+	if len(watchers) != 1 || watchers[0].Email != "buyer@example.com" {
+		t.Fatalf("Expected one watcher, got %+v", watchers)
+	}
+	if strings.Contains(rr.Body.String(), unsubscribeToken(db, first.ID)) {
+		t.Error("Expected unsubscribe tokens not to be listed")
+	}
+
+	// Watching again after unsubscribing restarts the watch
+	db.RemoveVehicleWatch(unsubscribeToken(db, first.ID))
+	rr, third := watchVehicleRequest(t, server, vehicle.ID, WatchVehicleRequest{Email: "buyer@example.com"})
+	if rr.Code != http.StatusCreated || third.ID != first.ID {
+		t.Errorf("Expected the watch to be reactivated, got %d %+v", rr.Code, third)
+	}
+}
+
+func TestWatchVehicleValidation(t *testing.T) {
+	server, _, _, vehicle := setupWatchServer()
+
+	rr, _ := watchVehicleRequest(t, server, vehicle.ID, WatchVehicleRequest{Email: "not-an-email"})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid email, got %d", rr.Code)
+	}
+
+	rr, _ = watchVehicleRequest(t, server, uuid.New().String(), WatchVehicleRequest{Email: "buyer@example.com"})
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown vehicle, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("POST", "/vehicles/watches/unsubscribe?token=unknown", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown token, got %d", rr.Code)
+	}
+}
+
+func TestEmailPriceDropNotifier(t *testing.T) {
+	var sent map[string]string
+	emailService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/email/send" {
+			t.Errorf("Expected /email/send, got %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&sent)
+	}))
+	defer emailService.Close()
+
+	notifier := NewEmailPriceDropNotifier(emailService.URL, "https://app.example.com/")
+	watch := &VehicleWatch{DealershipID: "dealer-1", Email: "buyer@example.com", AlertPrice: 30000, UnsubscribeToken: "tok123"}
+	vehicle := &Vehicle{Year: 2023, Make: "Toyota", Model: "RAV4", Price: 28000}
+
+	if err := notifier.NotifyPriceDrop(context.Background(), watch, vehicle); err != nil {
+		t.Fatalf("NotifyPriceDrop returned error: %v", err)
+	}
+
+	if sent["to"] != "buyer@example.com" || sent["dealership_id"] != "dealer-1" {
+		t.Errorf("Unexpected recipient: %+v", sent)
+	}
+	for _, want := range []string{"$30000.00", "$28000.00", "https://app.example.com/api/v1/inventory/vehicles/watches/unsubscribe?token=tok123"} {
+		if !strings.Contains(sent["body_html"], want) {
+			t.Errorf("Expected alert body to contain %q, got %s", want, sent["body_html"])
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"autolytiq/shared/apierror"
+	"autolytiq/shared/logging"
+
+	"github.com/gorilla/websocket"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		return true // Allow all origins in development
+	},
+}
+
+// Inventory WebSocket event types
+const (
+	WSEventVehicleCreated = "vehicle_created"
+	WSEventVehicleUpdated = "vehicle_updated"
+	WSEventVehicleSold    = "vehicle_sold"
+	WSEventPriceChanged   = "price_changed"
+	WSEventVehicleDeleted = "vehicle_deleted"
+)
+
+// Client represents a single WebSocket connection. Its dealership comes from
+// the authenticated upgrade request and cannot be changed by the client.
+type Client struct {
+	hub          *Hub
+	conn         *websocket.Conn
+	send         chan []byte
+	dealershipID string
+	logger       *logging.Logger
+}
+
+// Hub maintains the set of active clients and broadcasts inventory events
+// to the clients of the vehicle's dealership
+type Hub struct {
+	clients    map[*Client]bool
+	rooms      map[string]map[*Client]bool // dealership_id -> clients
+	broadcast  chan *BroadcastMessage
+	register   chan *Client
+	unregister chan *Client
+	mu         sync.RWMutex
+	logger     *logging.Logger
+}
+
+// BroadcastMessage is a message to be broadcast to clients
+type BroadcastMessage struct {
+	DealershipID string
+	Message      []byte
+}
+
+// WSMessage is the structure of WebSocket messages
+type WSMessage struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// SubscribeMessage is sent by clients to confirm their subscription. Only
+// the connection's own dealership can be subscribed to.
+type SubscribeMessage struct {
+	Type         string `json:"type"`
+	DealershipID string `json:"dealership_id"`
+}
+
+// VehicleEvent is the data of an inventory event. The vehicle never
+// includes cost, whatever the subscriber's scopes.
+type VehicleEvent struct {
+	Vehicle        *Vehicle `json:"vehicle"`
+	PreviousPrice  *float64 `json:"previous_price,omitempty"`
+	PreviousStatus string   `json:"previous_status,omitempty"`
+}
+
+// NewHub creates a new Hub instance
+func NewHub(logger *logging.Logger) *Hub {
+	return &Hub{
+		clients:    make(map[*Client]bool),
+		rooms:      make(map[string]map[*Client]bool),
+		broadcast:  make(chan *BroadcastMessage, 256),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		logger:     logger,
+	}
+}
+
+// Run starts the hub's main loop
+func (h *Hub) Run() {
+	for {
+		select {
+		case client := <-h.register:
+			h.mu.Lock()
+			h.clients[client] = true
+			if _, ok := h.rooms[client.dealershipID]; !ok {
+				h.rooms[client.dealershipID] = make(map[*Client]bool)
+			}
+			h.rooms[client.dealershipID][client] = true
+			total := len(h.clients)
+			h.mu.Unlock()
+			h.logger.WithFields(map[string]interface{}{
+				"dealership_id": client.dealershipID,
+				"total_clients": total,
+			}).Debug("Inventory WebSocket client connected")
+
+		case client := <-h.unregister:
+			h.mu.Lock()
+			if _, ok := h.clients[client]; ok {
+				h.remove(client)
+			}
+			total := len(h.clients)
+			h.mu.Unlock()
+			h.logger.WithFields(map[string]interface{}{
+				"total_clients": total,
+			}).Debug("Inventory WebSocket client disconnected")
+
+		case msg := <-h.broadcast:
+			h.mu.RLock()
+			for client := range h.rooms[msg.DealershipID] {
+				select {
+				case client.send <- msg.Message:
+				default:
+					// The client has stopped reading; it is removed once its
+					// read pump gives up on the connection
+					h.logger.WithField("dealership_id", msg.DealershipID).Warn("Dropped inventory event for slow client")
+				}
+			}
+			h.mu.RUnlock()
+		}
+	}
+}
+
+// remove drops a client from the hub and closes its send channel. Only the
+// unregister of a client whose read pump has exited removes it, so nothing
+// sends on the closed channel. The caller must hold h.mu.
+func (h *Hub) remove(client *Client) {
+	delete(h.clients, client)
+	close(client.send)
+	if room, ok := h.rooms[client.dealershipID]; ok {
+		delete(room, client)
+		if len(room) == 0 {
+			delete(h.rooms, client.dealershipID)
+		}
+	}
+}
+
+// Broadcast sends a message to all clients in a dealership room
+func (h *Hub) Broadcast(dealershipID string, messageType string, data interface{}) {
+	jsonData, err := json.Marshal(WSMessage{Type: messageType, Data: data})
+	if err != nil {
+		h.logger.WithError(err).Error("Error marshaling WebSocket message")
+		return
+	}
+
+	h.broadcast <- &BroadcastMessage{
+		DealershipID: dealershipID,
+		Message:      jsonData,
+	}
+}
+
+// publishVehicleEvents broadcasts the events for a vehicle being created,
+// updated or deleted. previous is nil for created vehicles, and current nil
+// for deleted ones.
+func (s *Server) publishVehicleEvents(previous, current *Vehicle) {
+	if s.hub == nil {
+		return
+	}
+
+	switch {
+	case previous == nil:
+		s.hub.Broadcast(current.DealershipID, WSEventVehicleCreated, VehicleEvent{Vehicle: eventVehicle(current)})
+
+	case current == nil:
+		s.hub.Broadcast(previous.DealershipID, WSEventVehicleDeleted, VehicleEvent{Vehicle: eventVehicle(previous)})
+
+	default:
+		vehicle := eventVehicle(current)
+		s.hub.Broadcast(current.DealershipID, WSEventVehicleUpdated, VehicleEvent{Vehicle: vehicle})
+		if current.Price != previous.Price {
+			price := previous.Price
+			s.hub.Broadcast(current.DealershipID, WSEventPriceChanged, VehicleEvent{Vehicle: vehicle, PreviousPrice: &price})
+		}
+		if current.Status == "sold" && previous.Status != "sold" {
+			s.hub.Broadcast(current.DealershipID, WSEventVehicleSold, VehicleEvent{Vehicle: vehicle, PreviousStatus: previous.Status})
+		}
+	}
+}
+
+// eventVehicle returns a copy of the vehicle without cost, margin or warnings
+func eventVehicle(vehicle *Vehicle) *Vehicle {
+	out := *vehicle
+	out.Cost = nil
+	out.Margin = nil
+	out.Warnings = nil
+	return &out
+}
+
+// sendMessage queues a message for the client
+func (c *Client) sendMessage(messageType string, data interface{}) {
+	jsonData, err := json.Marshal(WSMessage{Type: messageType, Data: data})
+	if err != nil {
+		c.logger.WithError(err).Error("Error marshaling WebSocket message")
+		return
+	}
+	select {
+	case c.send <- jsonData:
+	default:
+	}
+}
+
+// readPump pumps messages from the WebSocket connection to the hub
+func (c *Client) readPump() {
+	defer func() {
+		c.hub.unregister <- c
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(512)
+	c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	c.conn.SetPongHandler(func(string) error {
+		c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		return nil
+	})
+
+	for {
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				c.logger.WithError(err).Warn("WebSocket read error")
+			}
+			break
+		}
+
+		var subMsg SubscribeMessage
+		if err := json.Unmarshal(message, &subMsg); err != nil {
+			c.logger.WithError(err).Warn("Error parsing WebSocket message")
+			continue
+		}
+
+		if subMsg.Type == "subscribe" {
+			if subMsg.DealershipID != "" && subMsg.DealershipID != c.dealershipID {
+				c.logger.WithField("dealership_id", subMsg.DealershipID).Warn("Rejected inventory subscription to another dealership")
+				c.sendMessage("error", map[string]string{"message": "Cannot subscribe to another dealership"})
+				continue
+			}
+			c.sendMessage("subscribed", map[string]string{"dealership_id": c.dealershipID})
+		}
+	}
+}
+
+// writePump pumps messages from the hub to the WebSocket connection
+func (c *Client) writePump() {
+	ticker := time.NewTicker(54 * time.Second)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// serveWS handles GET /ws/inventory. The API gateway authenticates the
+// upgrade and sets X-Dealership-ID from the token; the connection receives
+// events for that dealership only.
+func (s *Server) serveWS(w http.ResponseWriter, r *http.Request) {
+	dealershipID := r.Header.Get("X-Dealership-ID")
+	if dealershipID == "" {
+		apierror.WriteError(w, http.StatusUnauthorized, "MISSING_DEALERSHIP_CONTEXT", "Missing dealership context")
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.logger.WithError(err).Error("WebSocket upgrade error")
+		return
+	}
+
+	client := &Client{
+		hub:          s.hub,
+		conn:         conn,
+		send:         make(chan []byte, 256),
+		dealershipID: dealershipID,
+		logger:       s.logger,
+	}
+
+	// Confirm the subscription first, so a client that has read the
+	// confirmation knows it is registered for events
+	client.sendMessage("subscribed", map[string]string{"dealership_id": dealershipID})
+	s.hub.register <- client
+
+	go client.writePump()
+	go client.readPump()
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// dialInventory connects to the inventory socket as the dealership and waits
+// for the subscription confirmation
+func dialInventory(t *testing.T, ts *httptest.Server, dealershipID string) *websocket.Conn {
+	t.Helper()
+
+	header := http.Header{}
+	header.Set("X-Dealership-ID", dealershipID)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws/inventory", header)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	if msg := readInventoryMessage(t, conn); msg.Type != "subscribed" {
+		t.Fatalf("Expected a subscription confirmation, got %+v", msg)
+	}
+	return conn
+}
+
+type inventoryMessage struct {
+	Type string       `json:"type"`
+	Data VehicleEvent `json:"data"`
+}
+
+func readInventoryMessage(t *testing.T, conn *websocket.Conn) inventoryMessage {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg inventoryMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("Failed to read message: %v", err)
+	}
+	return msg
+}
+
+func TestVehicleUpdateBroadcastsToDealership(t *testing.T) {
+	server := setupTestServer()
+	ts := httptest.NewServer(server.router)
+	defer ts.Close()
+
+	dealershipID := uuid.New().String()
+	cost := 30000.0
+	vehicle := &Vehicle{
+		ID:           uuid.New().String(),
+		DealershipID: dealershipID,
+		Make:         "Chevrolet",
+		Model:        "Silverado",
+		Year:         2022,
+		Price:        40000,
+		Status:       "available",
+		Cost:         &cost,
+	}
+	server.db.(*MockDatabase).vehicles[vehicle.ID] = vehicle
+
+	subscriber := dialInventory(t, ts, dealershipID)
+	other := dialInventory(t, ts, uuid.New().String())
+
+	body, _ := json.Marshal(UpdateVehicleRequest{Price: 38000, Status: "sold"})
+	req := httptest.NewRequest("PUT", "/vehicles/"+vehicle.ID, bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	updated := readInventoryMessage(t, subscriber)
+	if updated.Type != WSEventVehicleUpdated || updated.Data.Vehicle.ID != vehicle.ID || updated.Data.Vehicle.Price != 38000 {
+		t.Errorf("Expected a vehicle_updated event, got %+v", updated)
+	}
+	if updated.Data.Vehicle.Cost != nil {
+		t.Error("Expected cost to be left out of events")
+	}
+
+	priced := readInventoryMessage(t, subscriber)
+	if priced.Type != WSEventPriceChanged || priced.Data.PreviousPrice == nil || *priced.Data.PreviousPrice != 40000 {
+		t.Errorf("Expected a price_changed event from 40000, got %+v", priced)
+	}
+
+	sold := readInventoryMessage(t, subscriber)
+	if sold.Type != WSEventVehicleSold || sold.Data.PreviousStatus != "available" {
+		t.Errorf("Expected a vehicle_sold event, got %+v", sold)
+	}
+
+	// Other dealerships hear nothing
+	other.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if _, msg, err := other.ReadMessage(); err == nil {
+		t.Errorf("Expected no events for another dealership, got %s", msg)
+	}
+}
+
+func TestVehicleCreateAndDeleteBroadcast(t *testing.T) {
+	server := setupTestServer()
+	ts := httptest.NewServer(server.router)
+	defer ts.Close()
+
+	dealershipID := uuid.New().String()
+	subscriber := dialInventory(t, ts, dealershipID)
+
+	body, _ := json.Marshal(CreateVehicleRequest{
+		DealershipID: dealershipID,
+		VIN:          "1HGBH41JXMN109186",
+		Make:         "Honda",
+		Model:        "Accord",
+		Year:         2021,
+		Price:        25000,
+	})
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("POST", "/vehicles", bytes.NewReader(body)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created Vehicle
+	json.Unmarshal(rr.Body.Bytes(), &created)
+
+	if msg := readInventoryMessage(t, subscriber); msg.Type != WSEventVehicleCreated || msg.Data.Vehicle.ID != created.ID {
+		t.Errorf("Expected a vehicle_created event, got %+v", msg)
+	}
+
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("DELETE", "/vehicles/"+created.ID, nil))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", rr.Code)
+	}
+	if msg := readInventoryMessage(t, subscriber); msg.Type != WSEventVehicleDeleted || msg.Data.Vehicle.ID != created.ID {
+		t.Errorf("Expected a vehicle_deleted event, got %+v", msg)
+	}
+}
+
+func TestInventorySocketScopedToDealership(t *testing.T) {
+	server := setupTestServer()
+	ts := httptest.NewServer(server.router)
+	defer ts.Close()
+
+	// Upgrades without the gateway's dealership context are refused
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws/inventory", nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected the upgrade to be refused with 401, got %v", resp)
+	}
+
+	dealershipID := uuid.New().String()
+	conn := dialInventory(t, ts, dealershipID)
+
+	conn.WriteJSON(SubscribeMessage{Type: "subscribe", DealershipID: uuid.New().String()})
+	if msg := readInventoryMessage(t, conn); msg.Type != "error" {
+		t.Errorf("Expected subscribing to another dealership to fail, got %+v", msg)
+	}
+
+	conn.WriteJSON(SubscribeMessage{Type: "subscribe", DealershipID: dealershipID})
+	if msg := readInventoryMessage(t, conn); msg.Type != "subscribed" {
+		t.Errorf("Expected the own dealership subscription to be confirmed, got %+v", msg)
+	}
+}
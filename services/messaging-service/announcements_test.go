@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// mockStaffMember is a row of the users table the mock targets announcements at
+type mockStaffMember struct {
+	ID     string
+	Role   string
+	Active bool
+}
+
+func (db *MockDatabase) CreateAnnouncement(dealershipID, senderID string, req CreateAnnouncementRequest) (*Announcement, []string, error) {
+	roles := make(map[string]bool)
+	for _, role := range req.TargetRoles {
+		roles[role] = true
+	}
+
+	var recipients []string
+	for _, member := range db.staff[dealershipID] {
+		if !member.Active || member.ID == senderID {
+			continue
+		}
+		if len(roles) > 0 && !roles[strings.ToLower(member.Role)] {
+			continue
+		}
+		recipients = append(recipients, member.ID)
+	}
+
+	a := &Announcement{
+		ID:             uuid.New().String(),
+		DealershipID:   dealershipID,
+		SenderID:       senderID,
+		Content:        req.Content,
+		TargetRoles:    req.TargetRoles,
+		RecipientCount: len(recipients),
+		CreatedAt:      time.Now(),
+	}
+	if a.TargetRoles == nil {
+		a.TargetRoles = []string{}
+	}
+	db.announcements[a.ID] = a
+	db.annRecipients[a.ID] = make(map[string]*time.Time)
+	for _, id := range recipients {
+		db.annRecipients[a.ID][id] = nil
+	}
+	return a, recipients, nil
+}
+
+func (db *MockDatabase) ListAnnouncements(dealershipID, userID string, limit, offset int) ([]Announcement, int, int, error) {
+	var result []Announcement
+	unread := 0
+	for id, a := range db.announcements {
+		readAt, ok := db.annRecipients[id][userID]
+		if a.DealershipID != dealershipID || !ok {
+			continue
+		}
+		item := *a
+		item.ReadAt = readAt
+		for _, r := range db.annRecipients[id] {
+			if r != nil {
+				item.ReadCount++
+			}
+		}
+		if readAt == nil {
+			unread++
+		}
+		result = append(result, item)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.After(result[j].CreatedAt) })
+	return result, len(result), unread, nil
+}
+
+func (db *MockDatabase) MarkAnnouncementRead(id, dealershipID, userID string) error {
+	a, ok := db.announcements[id]
+	if !ok || a.DealershipID != dealershipID {
+		return errors.New("announcement not found")
+	}
+	readAt, ok := db.annRecipients[id][userID]
+	if !ok {
+		return errors.New("announcement not found")
+	}
+	if readAt == nil {
+		now := time.Now()
+		db.annRecipients[id][userID] = &now
+	}
+	return nil
+}
+
+func postAnnouncement(t *testing.T, router *mux.Router, req CreateAnnouncementRequest, dealershipID, userID, scopes string) *Announcement {
+	t.Helper()
+	rr := doScopedRequest(router, "POST", "/api/messaging/announcements", req, dealershipID, userID, scopes)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var a Announcement
+	json.NewDecoder(rr.Body).Decode(&a)
+	return &a
+}
+
+func doScopedRequest(router *mux.Router, method, path string, body interface{}, dealershipID, userID, scopes string) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(body)
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Dealership-ID", dealershipID)
+	req.Header.Set("X-User-ID", userID)
+	req.Header.Set("X-User-Scopes", scopes)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+// broadcastRecipients returns the users each queued announcement broadcast
+// was addressed to
+func broadcastRecipients(t *testing.T, hub *Hub) []string {
+	t.Helper()
+	var users []string
+	for {
+		select {
+		case msg := <-hub.broadcast:
+			var ws WSMessage
+			json.Unmarshal(msg.Message, &ws)
+			if ws.Type != WSEventAnnouncementPosted {
+				t.Errorf("unexpected %s broadcast", ws.Type)
+			}
+			if msg.ConversationID != "" || msg.UserID != "" {
+				t.Errorf("announcement should be addressed to users only, got %+v", msg)
+			}
+			users = append(users, msg.UserIDs...)
+		default:
+			sort.Strings(users)
+			return users
+		}
+	}
+}
+
+func TestAnnouncementReachesTargetedUsers(t *testing.T) {
+	dealershipID, manager := uuid.New().String(), uuid.New().String()
+	sales1, sales2, finance, inactive := uuid.New().String(), uuid.New().String(), uuid.New().String(), uuid.New().String()
+
+	tests := []struct {
+		name  string
+		roles []string
+		want  []string
+	}{
+		{"all staff", nil, []string{sales1, sales2, finance}},
+		{"by role", []string{"Salesperson"}, []string{sales1, sales2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, db, router := setupTestHandler()
+			db.staff[dealershipID] = []mockStaffMember{
+				{ID: manager, Role: "manager", Active: true},
+				{ID: sales1, Role: "salesperson", Active: true},
+				{ID: sales2, Role: "salesperson", Active: true},
+				{ID: finance, Role: "finance", Active: true},
+				{ID: inactive, Role: "salesperson", Active: false},
+			}
+			db.staff[uuid.New().String()] = []mockStaffMember{{ID: uuid.New().String(), Role: "salesperson", Active: true}}
+
+			a := postAnnouncement(t, router, CreateAnnouncementRequest{Content: " Lot closes early today ", TargetRoles: tt.roles}, dealershipID, manager, ScopeMessagingManage)
+			if a.Content != "Lot closes early today" {
+				t.Errorf("expected trimmed content, got %q", a.Content)
+			}
+			if a.RecipientCount != len(tt.want) {
+				t.Errorf("expected %d recipients, got %d", len(tt.want), a.RecipientCount)
+			}
+
+			want := append([]string(nil), tt.want...)
+			sort.Strings(want)
+			if got := broadcastRecipients(t, handler.hub); strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("expected broadcast to %v, got %v", want, got)
+			}
+
+			for _, userID := range tt.want {
+				var resp AnnouncementsResponse
+				rr := doRequest(router, "GET", "/api/messaging/announcements", nil, dealershipID, userID)
+				json.NewDecoder(rr.Body).Decode(&resp)
+				if resp.Total != 1 || resp.Unread != 1 || resp.Announcements[0].ID != a.ID {
+					t.Errorf("expected %s to have the announcement unread, got %+v", userID, resp)
+				}
+			}
+			if len(db.conversations) != 0 {
+				t.Errorf("expected no conversations to be created, got %d", len(db.conversations))
+			}
+		})
+	}
+}
+
+func TestAnnouncementReadTracking(t *testing.T) {
+	handler, db, router := setupTestHandler()
+	dealershipID, manager, reader, other := uuid.New().String(), uuid.New().String(), uuid.New().String(), uuid.New().String()
+	db.staff[dealershipID] = []mockStaffMember{
+		{ID: manager, Role: "manager", Active: true},
+		{ID: reader, Role: "salesperson", Active: true},
+		{ID: other, Role: "salesperson", Active: true},
+	}
+
+	a := postAnnouncement(t, router, CreateAnnouncementRequest{Content: "Team meeting at 9"}, dealershipID, manager, ScopeMessagingManage)
+	drainBroadcasts(handler.hub)
+
+	for i := 0; i < 2; i++ {
+		rr := doRequest(router, "POST", "/api/messaging/announcements/"+a.ID+"/read", nil, dealershipID, reader)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+	}
+
+	var resp AnnouncementsResponse
+	rr := doRequest(router, "GET", "/api/messaging/announcements", nil, dealershipID, reader)
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp.Unread != 0 || resp.Announcements[0].ReadAt == nil {
+		t.Errorf("expected announcement read for reader, got %+v", resp)
+	}
+	if resp.Announcements[0].ReadCount != 1 {
+		t.Errorf("expected read count 1, got %d", resp.Announcements[0].ReadCount)
+	}
+
+	rr = doRequest(router, "GET", "/api/messaging/announcements", nil, dealershipID, other)
+	resp = AnnouncementsResponse{}
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp.Unread != 1 || resp.Announcements[0].ReadAt != nil {
+		t.Errorf("expected announcement still unread for other user, got %+v", resp)
+	}
+
+	rr = doRequest(router, "POST", "/api/messaging/announcements/"+a.ID+"/read", nil, dealershipID, manager)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a non-recipient, got %d", rr.Code)
+	}
+}
+
+func TestCreateAnnouncementRequiresManageScope(t *testing.T) {
+	_, db, router := setupTestHandler()
+	dealershipID, userID := uuid.New().String(), uuid.New().String()
+
+	rr := doScopedRequest(router, "POST", "/api/messaging/announcements", CreateAnnouncementRequest{Content: "Hi all"}, dealershipID, userID, "messaging:read,messaging:write")
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", rr.Code)
+	}
+	if len(db.announcements) != 0 {
+		t.Error("expected no announcement to be saved")
+	}
+}
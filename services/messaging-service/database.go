@@ -10,7 +10,7 @@ import (
 	"autolytiq/shared/logging"
 
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // MessagingDatabase defines the database interface
@@ -29,7 +29,12 @@ type MessagingDatabase interface {
 	UpdateMessage(id, conversationID, userID string, req UpdateMessageRequest) (*Message, error)
 	DeleteMessage(id, conversationID, userID string) error
 	MarkAsDelivered(messageID, userID string) error
-	MarkAsRead(conversationID, userID string) error
+	MarkAsRead(conversationID, userID string, sendReceipt bool) error
+
+	// Scheduled messages
+	ListScheduledMessages(dealershipID, senderID string) ([]Message, error)
+	CancelScheduledMessage(id, dealershipID, senderID string) error
+	DispatchDueMessages(now time.Time) ([]Message, error)
 
 	// Reactions
 	AddReaction(messageID, userID, userName string, req AddReactionRequest) (*MessageReaction, error)
@@ -40,6 +45,23 @@ type MessagingDatabase interface {
 	RemoveParticipant(conversationID, userID, removedByID string) error
 	GetParticipants(conversationID string) ([]Participant, error)
 
+	// Tags
+	ListTags(dealershipID string) ([]ConversationTag, error)
+	AddConversationTags(conversationID, dealershipID, userID string, tags []TagInput) ([]ConversationTag, error)
+	RemoveConversationTag(conversationID, dealershipID, tagName string) ([]ConversationTag, error)
+
+	// Preferences
+	GetUserPreferences(dealershipID, userID string) (*UserPreferences, error)
+	UpdateUserPreferences(dealershipID, userID string, req UpdatePreferencesRequest) (*UserPreferences, error)
+
+	// Announcements
+	// CreateAnnouncement saves an announcement for the dealership's active
+	// users with the target roles, other than the sender, and returns it with
+	// the IDs of its recipients
+	CreateAnnouncement(dealershipID, senderID string, req CreateAnnouncementRequest) (*Announcement, []string, error)
+	ListAnnouncements(dealershipID, userID string, limit, offset int) ([]Announcement, int, int, error)
+	MarkAnnouncementRead(id, dealershipID, userID string) error
+
 	// Utilities
 	Close() error
 }
@@ -107,6 +129,25 @@ func (p *PostgresDB) ListConversations(dealershipID, userID string, filter Conve
 		argIdx++
 	}
 
+	if filter.Tag != nil && *filter.Tag != "" {
+		baseQuery += fmt.Sprintf(` AND EXISTS (
+			SELECT 1 FROM messaging_conversation_tags ct
+			INNER JOIN messaging_tags t ON t.id = ct.tag_id
+			WHERE ct.conversation_id = c.id AND LOWER(t.name) = LOWER($%d)
+		)`, argIdx)
+		args = append(args, *filter.Tag)
+		argIdx++
+	}
+
+	for _, participantID := range filter.ParticipantIDs {
+		baseQuery += fmt.Sprintf(` AND EXISTS (
+			SELECT 1 FROM messaging_participants fp
+			WHERE fp.conversation_id = c.id AND fp.user_id = $%d
+		)`, argIdx)
+		args = append(args, participantID)
+		argIdx++
+	}
+
 	baseQuery += " ORDER BY c.is_pinned DESC, c.updated_at DESC"
 	baseQuery += fmt.Sprintf(" LIMIT $%d OFFSET $%d", argIdx, argIdx+1)
 	args = append(args, filter.Limit, filter.Offset)
@@ -145,6 +186,7 @@ func (p *PostgresDB) ListConversations(dealershipID, userID string, filter Conve
 
 		// Calculate unread count
 		c.UnreadCount = p.getUnreadCount(c.ID, userID)
+		c.Tags = p.getConversationTags(c.ID)
 
 		conversations = append(conversations, c)
 	}
@@ -187,6 +229,7 @@ func (p *PostgresDB) GetConversation(id, dealershipID, userID string) (*Conversa
 	}
 
 	c.UnreadCount = p.getUnreadCount(c.ID, userID)
+	c.Tags = p.getConversationTags(c.ID)
 
 	return &c, nil
 }
@@ -332,10 +375,10 @@ func (p *PostgresDB) ListMessages(conversationID, dealershipID, userID string, f
 		SELECT m.id, m.conversation_id, m.sender_id, m.type, m.content, m.status,
 		       m.reply_to_id, m.is_edited, m.edited_at, m.is_deleted, m.deleted_at,
 		       m.delivered_at, m.read_at, m.is_ephemeral, m.ephemeral_seconds,
-		       m.ephemeral_expires_at, m.created_at, m.updated_at,
+		       m.ephemeral_expires_at, m.scheduled_at, m.created_at, m.updated_at,
 		       COUNT(*) OVER() as total_count
 		FROM messaging_messages m
-		WHERE m.conversation_id = $1 AND m.is_deleted = false
+		WHERE m.conversation_id = $1 AND m.is_deleted = false AND m.status != 'SCHEDULED'
 	`
 
 	args := []interface{}{conversationID}
@@ -372,7 +415,7 @@ func (p *PostgresDB) ListMessages(conversationID, dealershipID, userID string, f
 			&m.ID, &m.ConversationID, &m.SenderID, &m.Type, &m.Content, &m.Status,
 			&m.ReplyToID, &m.IsEdited, &m.EditedAt, &m.IsDeleted, &m.DeletedAt,
 			&m.DeliveredAt, &m.ReadAt, &m.IsEphemeral, &m.EphemeralSeconds,
-			&m.EphemeralExpiresAt, &m.CreatedAt, &m.UpdatedAt,
+			&m.EphemeralExpiresAt, &m.ScheduledAt, &m.CreatedAt, &m.UpdatedAt,
 			&total,
 		)
 		if err != nil {
@@ -413,7 +456,7 @@ func (p *PostgresDB) GetMessage(id, conversationID string) (*Message, error) {
 		SELECT m.id, m.conversation_id, m.sender_id, m.type, m.content, m.status,
 		       m.reply_to_id, m.is_edited, m.edited_at, m.is_deleted, m.deleted_at,
 		       m.delivered_at, m.read_at, m.is_ephemeral, m.ephemeral_seconds,
-		       m.ephemeral_expires_at, m.created_at, m.updated_at
+		       m.ephemeral_expires_at, m.scheduled_at, m.created_at, m.updated_at
 		FROM messaging_messages m
 		WHERE m.id = $1 AND m.conversation_id = $2
 	`
@@ -423,7 +466,7 @@ func (p *PostgresDB) GetMessage(id, conversationID string) (*Message, error) {
 		&m.ID, &m.ConversationID, &m.SenderID, &m.Type, &m.Content, &m.Status,
 		&m.ReplyToID, &m.IsEdited, &m.EditedAt, &m.IsDeleted, &m.DeletedAt,
 		&m.DeliveredAt, &m.ReadAt, &m.IsEphemeral, &m.EphemeralSeconds,
-		&m.EphemeralExpiresAt, &m.CreatedAt, &m.UpdatedAt,
+		&m.EphemeralExpiresAt, &m.ScheduledAt, &m.CreatedAt, &m.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("message not found")
@@ -452,30 +495,45 @@ func (p *PostgresDB) CreateMessage(conversationID, dealershipID, senderID string
 	id := uuid.New().String()
 	now := time.Now()
 
+	// Scheduled messages are stored now and delivered by the dispatcher at send_at
+	status := "SENT"
+	sendTime := now
+	if req.SendAt != nil {
+		status = MessageStatusScheduled
+		sendTime = *req.SendAt
+	}
+
 	var ephemeralExpiresAt *time.Time
 	if req.IsEphemeral && req.EphemeralSeconds != nil {
-		expires := now.Add(time.Duration(*req.EphemeralSeconds) * time.Second)
+		expires := sendTime.Add(time.Duration(*req.EphemeralSeconds) * time.Second)
 		ephemeralExpiresAt = &expires
 	}
 
 	query := `
 		INSERT INTO messaging_messages (
 			id, conversation_id, sender_id, type, content, status, reply_to_id,
-			is_ephemeral, ephemeral_seconds, ephemeral_expires_at, created_at, updated_at
+			is_ephemeral, ephemeral_seconds, ephemeral_expires_at, scheduled_at, created_at, updated_at
 		)
-		VALUES ($1, $2, $3, $4, $5, 'SENT', $6, $7, $8, $9, $10, $11)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 	`
 
-	_, err := p.db.Exec(query, id, conversationID, senderID, req.Type, req.Content,
-		req.ReplyToID, req.IsEphemeral, req.EphemeralSeconds, ephemeralExpiresAt, now, now)
+	_, err := p.db.Exec(query, id, conversationID, senderID, req.Type, req.Content, status,
+		req.ReplyToID, req.IsEphemeral, req.EphemeralSeconds, ephemeralExpiresAt, req.SendAt, now, now)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create message: %w", err)
 	}
 
 	// Update conversation updated_at
-	p.db.Exec("UPDATE messaging_conversations SET updated_at = $1 WHERE id = $2", now, conversationID)
+	if req.SendAt == nil {
+		p.db.Exec("UPDATE messaging_conversations SET updated_at = $1 WHERE id = $2", now, conversationID)
+	}
 
-	return p.GetMessage(id, conversationID)
+	message, err := p.GetMessage(id, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	message.DealershipID = dealershipID
+	return message, nil
 }
 
 // UpdateMessage updates a message
@@ -538,8 +596,11 @@ func (p *PostgresDB) MarkAsDelivered(messageID, userID string) error {
 	return err
 }
 
-// MarkAsRead marks all messages in a conversation as read for a user
-func (p *PostgresDB) MarkAsRead(conversationID, userID string) error {
+// MarkAsRead marks all messages in a conversation as read for a user. The
+// user's last read time, which drives their unread count, is always updated;
+// message statuses only change to READ when sendReceipt is set, since senders
+// see them.
+func (p *PostgresDB) MarkAsRead(conversationID, userID string, sendReceipt bool) error {
 	now := time.Now()
 
 	// Update participant's last_read_at
@@ -552,6 +613,10 @@ func (p *PostgresDB) MarkAsRead(conversationID, userID string) error {
 		return fmt.Errorf("failed to update last read: %w", err)
 	}
 
+	if !sendReceipt {
+		return nil
+	}
+
 	// Update message status for messages sent to this user
 	_, err = p.db.Exec(`
 		UPDATE messaging_messages
@@ -562,6 +627,105 @@ func (p *PostgresDB) MarkAsRead(conversationID, userID string) error {
 	return err
 }
 
+// ListScheduledMessages retrieves a sender's pending scheduled messages, soonest first
+func (p *PostgresDB) ListScheduledMessages(dealershipID, senderID string) ([]Message, error) {
+	query := `
+		SELECT m.id, m.conversation_id, m.sender_id, m.type, m.content, m.status,
+		       m.reply_to_id, m.is_ephemeral, m.ephemeral_seconds, m.scheduled_at,
+		       m.created_at, m.updated_at
+		FROM messaging_messages m
+		INNER JOIN messaging_conversations c ON c.id = m.conversation_id
+		WHERE c.dealership_id = $1 AND m.sender_id = $2 AND m.status = 'SCHEDULED'
+		ORDER BY m.scheduled_at ASC
+	`
+
+	rows, err := p.db.Query(query, dealershipID, senderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scheduled messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		var m Message
+		err := rows.Scan(
+			&m.ID, &m.ConversationID, &m.SenderID, &m.Type, &m.Content, &m.Status,
+			&m.ReplyToID, &m.IsEphemeral, &m.EphemeralSeconds, &m.ScheduledAt,
+			&m.CreatedAt, &m.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan scheduled message: %w", err)
+		}
+		messages = append(messages, m)
+	}
+
+	return messages, nil
+}
+
+// CancelScheduledMessage deletes a scheduled message that has not been dispatched yet
+func (p *PostgresDB) CancelScheduledMessage(id, dealershipID, senderID string) error {
+	query := `
+		DELETE FROM messaging_messages
+		WHERE id = $1 AND sender_id = $2 AND status = 'SCHEDULED'
+		  AND conversation_id IN (SELECT id FROM messaging_conversations WHERE dealership_id = $3)
+	`
+
+	result, err := p.db.Exec(query, id, senderID, dealershipID)
+	if err != nil {
+		return fmt.Errorf("failed to cancel scheduled message: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("scheduled message not found")
+	}
+
+	return nil
+}
+
+// DispatchDueMessages atomically moves scheduled messages whose send time has
+// passed to SENT and returns them. The conditional UPDATE claims each row once,
+// so concurrent dispatchers never deliver the same message twice.
+func (p *PostgresDB) DispatchDueMessages(now time.Time) ([]Message, error) {
+	rows, err := p.db.Query(`
+		UPDATE messaging_messages
+		SET status = 'SENT', created_at = $1, updated_at = $1
+		WHERE status = 'SCHEDULED' AND scheduled_at <= $1
+		RETURNING id, conversation_id,
+			(SELECT dealership_id FROM messaging_conversations WHERE id = conversation_id)
+	`, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dispatch scheduled messages: %w", err)
+	}
+
+	type dueMessage struct{ id, conversationID, dealershipID string }
+	var due []dueMessage
+	for rows.Next() {
+		var d dueMessage
+		if err := rows.Scan(&d.id, &d.conversationID, &d.dealershipID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan dispatched message: %w", err)
+		}
+		due = append(due, d)
+	}
+	rows.Close()
+
+	var messages []Message
+	for _, d := range due {
+		p.db.Exec("UPDATE messaging_conversations SET updated_at = $1 WHERE id = $2", now, d.conversationID)
+
+		message, err := p.GetMessage(d.id, d.conversationID)
+		if err != nil {
+			p.logger.WithError(err).WithField("message_id", d.id).Warn("Failed to load dispatched message")
+			continue
+		}
+		message.DealershipID = d.dealershipID
+		messages = append(messages, *message)
+	}
+
+	return messages, nil
+}
+
 // AddReaction adds a reaction to a message
 func (p *PostgresDB) AddReaction(messageID, userID, userName string, req AddReactionRequest) (*MessageReaction, error) {
 	// Remove existing reaction of same type from user
@@ -655,11 +819,14 @@ func (p *PostgresDB) RemoveParticipant(conversationID, userID, removedByID strin
 // GetParticipants retrieves all participants in a conversation
 func (p *PostgresDB) GetParticipants(conversationID string) ([]Participant, error) {
 	query := `
-		SELECT p.id, p.user_id, p.role, p.joined_at, p.last_read_at,
+		SELECT p.id, p.user_id, p.role, p.joined_at,
+		       CASE WHEN COALESCE(pref.send_read_receipts, true) THEN p.last_read_at END as last_read_at,
 		       COALESCE(u.first_name, 'Unknown') as first_name,
 		       COALESCE(u.last_name, 'User') as last_name
 		FROM messaging_participants p
+		INNER JOIN messaging_conversations c ON c.id = p.conversation_id
 		LEFT JOIN users u ON u.id = p.user_id
+		LEFT JOIN messaging_user_preferences pref ON pref.dealership_id = c.dealership_id AND pref.user_id = p.user_id
 		WHERE p.conversation_id = $1
 		ORDER BY p.joined_at
 	`
@@ -684,6 +851,44 @@ func (p *PostgresDB) GetParticipants(conversationID string) ([]Participant, erro
 	return participants, nil
 }
 
+// GetUserPreferences retrieves a user's preferences, falling back to the
+// defaults if they haven't set any
+func (p *PostgresDB) GetUserPreferences(dealershipID, userID string) (*UserPreferences, error) {
+	prefs := DefaultUserPreferences(dealershipID, userID)
+	err := p.db.QueryRow(`
+		SELECT send_read_receipts, updated_at
+		FROM messaging_user_preferences
+		WHERE dealership_id = $1 AND user_id = $2
+	`, dealershipID, userID).Scan(&prefs.SendReadReceipts, &prefs.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return prefs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get preferences: %w", err)
+	}
+	return prefs, nil
+}
+
+// UpdateUserPreferences creates or updates a user's preferences
+func (p *PostgresDB) UpdateUserPreferences(dealershipID, userID string, req UpdatePreferencesRequest) (*UserPreferences, error) {
+	prefs := DefaultUserPreferences(dealershipID, userID)
+	if req.SendReadReceipts != nil {
+		prefs.SendReadReceipts = *req.SendReadReceipts
+	}
+
+	err := p.db.QueryRow(`
+		INSERT INTO messaging_user_preferences (dealership_id, user_id, send_read_receipts, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (dealership_id, user_id) DO UPDATE
+		SET send_read_receipts = EXCLUDED.send_read_receipts, updated_at = EXCLUDED.updated_at
+		RETURNING send_read_receipts, updated_at
+	`, dealershipID, userID, prefs.SendReadReceipts).Scan(&prefs.SendReadReceipts, &prefs.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update preferences: %w", err)
+	}
+	return prefs, nil
+}
+
 // Helper functions
 
 func (p *PostgresDB) getParticipantInfo(conversationID, userID string) (*Participant, error) {
@@ -712,7 +917,7 @@ func (p *PostgresDB) getParticipantInfo(conversationID, userID string) (*Partici
 func (p *PostgresDB) getLastMessage(conversationID string) (*Message, error) {
 	query := `
 		SELECT id FROM messaging_messages
-		WHERE conversation_id = $1 AND is_deleted = false
+		WHERE conversation_id = $1 AND is_deleted = false AND status != 'SCHEDULED'
 		ORDER BY created_at DESC
 		LIMIT 1
 	`
@@ -734,6 +939,7 @@ func (p *PostgresDB) getUnreadCount(conversationID, userID string) int {
 		WHERE m.conversation_id = $1
 		  AND m.sender_id != $2
 		  AND m.is_deleted = false
+		  AND m.status != 'SCHEDULED'
 		  AND (p.last_read_at IS NULL OR m.created_at > p.last_read_at)
 	`
 
@@ -742,6 +948,123 @@ func (p *PostgresDB) getUnreadCount(conversationID, userID string) int {
 	return count
 }
 
+// ListTags retrieves a dealership's tag vocabulary
+func (p *PostgresDB) ListTags(dealershipID string) ([]ConversationTag, error) {
+	query := `
+		SELECT id, dealership_id, name, color, created_at
+		FROM messaging_tags
+		WHERE dealership_id = $1
+		ORDER BY LOWER(name)
+	`
+
+	rows, err := p.db.Query(query, dealershipID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []ConversationTag
+	for rows.Next() {
+		var t ConversationTag
+		if err := rows.Scan(&t.ID, &t.DealershipID, &t.Name, &t.Color, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, t)
+	}
+
+	return tags, rows.Err()
+}
+
+// AddConversationTags applies tags to a conversation, adding any new tags to
+// the dealership's vocabulary, and returns the conversation's tags
+func (p *PostgresDB) AddConversationTags(conversationID, dealershipID, userID string, tags []TagInput) ([]ConversationTag, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, tag := range tags {
+		var tagID string
+		err := tx.QueryRow(`
+			INSERT INTO messaging_tags (id, dealership_id, name, color, created_by_id, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (dealership_id, LOWER(name)) DO UPDATE SET
+				color = COALESCE(EXCLUDED.color, messaging_tags.color)
+			RETURNING id
+		`, uuid.New().String(), dealershipID, tag.Name, tag.Color, userID, now).Scan(&tagID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save tag: %w", err)
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO messaging_conversation_tags (conversation_id, tag_id, tagged_by_id, created_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT DO NOTHING
+		`, conversationID, tagID, userID, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to tag conversation: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return p.getConversationTags(conversationID), nil
+}
+
+// RemoveConversationTag removes a tag, by name, from a conversation and returns
+// the remaining tags. The tag stays in the dealership's vocabulary.
+func (p *PostgresDB) RemoveConversationTag(conversationID, dealershipID, tagName string) ([]ConversationTag, error) {
+	query := `
+		DELETE FROM messaging_conversation_tags ct
+		USING messaging_tags t
+		WHERE ct.tag_id = t.id AND ct.conversation_id = $1
+		  AND t.dealership_id = $2 AND LOWER(t.name) = LOWER($3)
+	`
+
+	result, err := p.db.Exec(query, conversationID, dealershipID, tagName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove tag: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return nil, fmt.Errorf("tag not found")
+	}
+
+	return p.getConversationTags(conversationID), nil
+}
+
+func (p *PostgresDB) getConversationTags(conversationID string) []ConversationTag {
+	query := `
+		SELECT t.id, t.dealership_id, t.name, t.color, t.created_at
+		FROM messaging_conversation_tags ct
+		INNER JOIN messaging_tags t ON t.id = ct.tag_id
+		WHERE ct.conversation_id = $1
+		ORDER BY LOWER(t.name)
+	`
+
+	tags := []ConversationTag{}
+
+	rows, err := p.db.Query(query, conversationID)
+	if err != nil {
+		return tags
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t ConversationTag
+		if err := rows.Scan(&t.ID, &t.DealershipID, &t.Name, &t.Color, &t.CreatedAt); err == nil {
+			tags = append(tags, t)
+		}
+	}
+
+	return tags
+}
+
 func (p *PostgresDB) getMessageReactions(messageID string) []MessageReaction {
 	query := `
 		SELECT id, message_id, user_id, user_name, reaction_type, created_at
@@ -814,3 +1137,130 @@ func (p *PostgresDB) getLinkPreview(messageID string) *LinkPreview {
 
 	return &preview
 }
+
+// CreateAnnouncement saves an announcement and one recipient row per targeted
+// user. Users are kept in the users table by user-service.
+func (p *PostgresDB) CreateAnnouncement(dealershipID, senderID string, req CreateAnnouncementRequest) (*Announcement, []string, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id FROM users
+		WHERE dealership_id = $1 AND status = 'active' AND id <> $2
+		  AND (cardinality($3::text[]) = 0 OR LOWER(role) = ANY($3))
+	`, dealershipID, senderID, pq.Array(req.TargetRoles))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query recipients: %w", err)
+	}
+	var recipients []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("failed to scan recipient: %w", err)
+		}
+		recipients = append(recipients, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to query recipients: %w", err)
+	}
+
+	announcement := &Announcement{
+		DealershipID:   dealershipID,
+		SenderID:       senderID,
+		Content:        req.Content,
+		TargetRoles:    req.TargetRoles,
+		RecipientCount: len(recipients),
+	}
+	err = tx.QueryRow(`
+		INSERT INTO messaging_announcements (dealership_id, sender_id, content, target_roles)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, dealershipID, senderID, req.Content, pq.Array(req.TargetRoles)).Scan(&announcement.ID, &announcement.CreatedAt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create announcement: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO messaging_announcement_recipients (announcement_id, user_id)
+		SELECT $1, UNNEST($2::uuid[])
+	`, announcement.ID, pq.Array(recipients))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to add announcement recipients: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit announcement: %w", err)
+	}
+	return announcement, recipients, nil
+}
+
+// ListAnnouncements retrieves the announcements a user received, newest
+// first, with the total and unread counts
+func (p *PostgresDB) ListAnnouncements(dealershipID, userID string, limit, offset int) ([]Announcement, int, int, error) {
+	var total, unread int
+	err := p.db.QueryRow(`
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE r.read_at IS NULL)
+		FROM messaging_announcement_recipients r
+		INNER JOIN messaging_announcements a ON a.id = r.announcement_id
+		WHERE a.dealership_id = $1 AND r.user_id = $2
+	`, dealershipID, userID).Scan(&total, &unread)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to count announcements: %w", err)
+	}
+
+	rows, err := p.db.Query(`
+		SELECT a.id, a.dealership_id, a.sender_id, a.content, a.target_roles, a.created_at, r.read_at,
+		       (SELECT COUNT(*) FROM messaging_announcement_recipients WHERE announcement_id = a.id),
+		       (SELECT COUNT(*) FROM messaging_announcement_recipients WHERE announcement_id = a.id AND read_at IS NOT NULL)
+		FROM messaging_announcement_recipients r
+		INNER JOIN messaging_announcements a ON a.id = r.announcement_id
+		WHERE a.dealership_id = $1 AND r.user_id = $2
+		ORDER BY a.created_at DESC
+		LIMIT $3 OFFSET $4
+	`, dealershipID, userID, limit, offset)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to query announcements: %w", err)
+	}
+	defer rows.Close()
+
+	var announcements []Announcement
+	for rows.Next() {
+		var a Announcement
+		var readAt sql.NullTime
+		err := rows.Scan(&a.ID, &a.DealershipID, &a.SenderID, &a.Content, pq.Array(&a.TargetRoles),
+			&a.CreatedAt, &readAt, &a.RecipientCount, &a.ReadCount)
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("failed to scan announcement: %w", err)
+		}
+		if readAt.Valid {
+			a.ReadAt = &readAt.Time
+		}
+		announcements = append(announcements, a)
+	}
+
+	return announcements, total, unread, rows.Err()
+}
+
+// MarkAnnouncementRead records that a recipient read an announcement.
+// Reading it again keeps the first read time.
+func (p *PostgresDB) MarkAnnouncementRead(id, dealershipID, userID string) error {
+	result, err := p.db.Exec(`
+		UPDATE messaging_announcement_recipients r
+		SET read_at = COALESCE(r.read_at, NOW())
+		FROM messaging_announcements a
+		WHERE a.id = r.announcement_id AND r.announcement_id = $1
+		  AND a.dealership_id = $2 AND r.user_id = $3
+	`, id, dealershipID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to mark announcement read: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("announcement not found")
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"autolytiq/shared/logging"
 
@@ -32,6 +33,21 @@ func getUserID(r *http.Request) string {
 	return r.Header.Get("X-User-ID")
 }
 
+// ScopeMessagingManage allows managing dealership-wide messaging, such as
+// posting announcements
+const ScopeMessagingManage = "messaging:manage"
+
+// hasScope reports whether the request carries the given scope in the
+// X-User-Scopes header set by the API gateway
+func hasScope(r *http.Request, scope string) bool {
+	for _, s := range strings.Split(r.Header.Get("X-User-Scopes"), ",") {
+		if strings.TrimSpace(s) == scope {
+			return true
+		}
+	}
+	return false
+}
+
 func getUserName(r *http.Request) string {
 	name := r.Header.Get("X-User-Name")
 	if name == "" {
@@ -88,6 +104,17 @@ func (h *Handler) ListConversations(w http.ResponseWriter, r *http.Request) {
 		filter.Search = &search
 	}
 
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		filter.Tag = &tag
+	}
+
+	participantIDs, errs := parseParticipantFilter(r.URL.Query()["participant"])
+	if errs != nil {
+		respondValidationErrorMessaging(w, errs)
+		return
+	}
+	filter.ParticipantIDs = participantIDs
+
 	if limit := r.URL.Query().Get("limit"); limit != "" {
 		if l, err := strconv.Atoi(limit); err == nil && l > 0 && l <= 100 {
 			filter.Limit = l
@@ -110,7 +137,7 @@ func (h *Handler) ListConversations(w http.ResponseWriter, r *http.Request) {
 	// Update presence for participants
 	for i := range conversations {
 		for j := range conversations[i].Participants {
-			conversations[i].Participants[j].Presence = h.hub.GetUserPresence(conversations[i].Participants[j].UserID)
+			conversations[i].Participants[j].Presence = h.hub.GetUserPresence(dealershipID, conversations[i].Participants[j].UserID)
 		}
 	}
 
@@ -144,7 +171,7 @@ func (h *Handler) GetConversation(w http.ResponseWriter, r *http.Request) {
 
 	// Update presence
 	for i := range conversation.Participants {
-		conversation.Participants[i].Presence = h.hub.GetUserPresence(conversation.Participants[i].UserID)
+		conversation.Participants[i].Presence = h.hub.GetUserPresence(dealershipID, conversation.Participants[i].UserID)
 	}
 
 	respondJSON(w, http.StatusOK, conversation)
@@ -187,7 +214,7 @@ func (h *Handler) CreateConversation(w http.ResponseWriter, r *http.Request) {
 
 	// Broadcast to all participants
 	for _, p := range conversation.Participants {
-		h.hub.BroadcastToUser(p.UserID, WSEventConversationCreated, conversation)
+		h.hub.BroadcastToUser(dealershipID, p.UserID, WSEventConversationCreated, conversation)
 	}
 
 	respondJSON(w, http.StatusCreated, conversation)
@@ -217,7 +244,7 @@ func (h *Handler) UpdateConversation(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Broadcast update
-	h.hub.BroadcastToConversation(conversationID, WSEventConversationUpdated, conversation, "")
+	h.hub.BroadcastToConversation(dealershipID, conversationID, WSEventConversationUpdated, conversation, "")
 
 	respondJSON(w, http.StatusOK, conversation)
 }
@@ -291,15 +318,77 @@ func (h *Handler) SendMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Broadcast to conversation (excluding sender)
-	h.hub.BroadcastToConversation(conversationID, WSEventMessageSent, message, userID)
-
 	// Clear typing indicator
-	h.hub.SetTyping(conversationID, userID, getUserName(r), false)
+	h.hub.SetTyping(dealershipID, conversationID, userID, getUserName(r), false)
+
+	// Scheduled messages are delivered later by the MessageScheduler
+	if message.Status != MessageStatusScheduled {
+		h.deliverMessage(message)
+	}
 
 	respondJSON(w, http.StatusCreated, message)
 }
 
+// deliverMessage broadcasts a sent message to the conversation (excluding sender)
+func (h *Handler) deliverMessage(message *Message) {
+	h.hub.BroadcastToConversation(message.DealershipID, message.ConversationID, WSEventMessageSent, message, message.SenderID)
+}
+
+// ListScheduledMessages lists the user's pending scheduled messages
+func (h *Handler) ListScheduledMessages(w http.ResponseWriter, r *http.Request) {
+	dealershipID := getDealershipID(r)
+	userID := getUserID(r)
+
+	if dealershipID == "" || userID == "" {
+		respondError(w, http.StatusBadRequest, "Missing required headers")
+		return
+	}
+
+	messages, err := h.db.ListScheduledMessages(dealershipID, userID)
+	if err != nil {
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to list scheduled messages")
+		respondError(w, http.StatusInternalServerError, "Failed to list scheduled messages")
+		return
+	}
+
+	if messages == nil {
+		messages = []Message{}
+	}
+
+	respondJSON(w, http.StatusOK, ScheduledMessagesResponse{
+		Messages: messages,
+		Total:    len(messages),
+	})
+}
+
+// CancelScheduledMessage cancels a scheduled message before it is sent
+func (h *Handler) CancelScheduledMessage(w http.ResponseWriter, r *http.Request) {
+	dealershipID := getDealershipID(r)
+	userID := getUserID(r)
+	messageID := mux.Vars(r)["messageId"]
+
+	if dealershipID == "" || userID == "" {
+		respondError(w, http.StatusBadRequest, "Missing required headers")
+		return
+	}
+
+	if !validateUUIDMessaging(w, messageID, "messageId") {
+		return
+	}
+
+	if err := h.db.CancelScheduledMessage(messageID, dealershipID, userID); err != nil {
+		if err.Error() == "scheduled message not found" {
+			respondError(w, http.StatusNotFound, "Scheduled message not found")
+			return
+		}
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to cancel scheduled message")
+		respondError(w, http.StatusInternalServerError, "Failed to cancel scheduled message")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"status": "cancelled"})
+}
+
 // GetMessage gets a single message
 func (h *Handler) GetMessage(w http.ResponseWriter, r *http.Request) {
 	conversationID := mux.Vars(r)["conversationId"]
@@ -321,6 +410,7 @@ func (h *Handler) GetMessage(w http.ResponseWriter, r *http.Request) {
 
 // UpdateMessage updates a message
 func (h *Handler) UpdateMessage(w http.ResponseWriter, r *http.Request) {
+	dealershipID := getDealershipID(r)
 	userID := getUserID(r)
 	conversationID := mux.Vars(r)["conversationId"]
 	messageID := mux.Vars(r)["messageId"]
@@ -343,13 +433,14 @@ func (h *Handler) UpdateMessage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Broadcast update
-	h.hub.BroadcastToConversation(conversationID, WSEventMessageUpdated, message, "")
+	h.hub.BroadcastToConversation(dealershipID, conversationID, WSEventMessageUpdated, message, "")
 
 	respondJSON(w, http.StatusOK, message)
 }
 
 // DeleteMessage deletes a message
 func (h *Handler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	dealershipID := getDealershipID(r)
 	userID := getUserID(r)
 	conversationID := mux.Vars(r)["conversationId"]
 	messageID := mux.Vars(r)["messageId"]
@@ -367,15 +458,17 @@ func (h *Handler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Broadcast deletion
-	h.hub.BroadcastToConversation(conversationID, WSEventMessageDeleted, map[string]string{
+	h.hub.BroadcastToConversation(dealershipID, conversationID, WSEventMessageDeleted, map[string]string{
 		"message_id": messageID,
 	}, "")
 
 	respondJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
 
-// MarkAsRead marks messages as read
+// MarkAsRead marks messages as read. The read receipt is only broadcast if
+// the user shares read receipts; their own unread count is cleared either way.
 func (h *Handler) MarkAsRead(w http.ResponseWriter, r *http.Request) {
+	dealershipID := getDealershipID(r)
 	userID := getUserID(r)
 	conversationID := mux.Vars(r)["conversationId"]
 
@@ -384,7 +477,9 @@ func (h *Handler) MarkAsRead(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.db.MarkAsRead(conversationID, userID)
+	sendReceipt := h.sendsReadReceipts(r, dealershipID, userID)
+
+	err := h.db.MarkAsRead(conversationID, userID, sendReceipt)
 	if err != nil {
 		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to mark as read")
 		respondError(w, http.StatusInternalServerError, "Failed to mark as read")
@@ -392,10 +487,175 @@ func (h *Handler) MarkAsRead(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Broadcast read receipt
-	h.hub.BroadcastToConversation(conversationID, WSEventMessageRead, map[string]string{
-		"user_id":         userID,
-		"conversation_id": conversationID,
-	}, userID)
+	if sendReceipt {
+		h.hub.BroadcastToConversation(dealershipID, conversationID, WSEventMessageRead, map[string]string{
+			"user_id":         userID,
+			"conversation_id": conversationID,
+		}, userID)
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"status": "read"})
+}
+
+// sendsReadReceipts reports whether a user shares their read state with
+// others. If the preference can't be loaded no receipt is sent.
+func (h *Handler) sendsReadReceipts(r *http.Request, dealershipID, userID string) bool {
+	prefs, err := h.db.GetUserPreferences(dealershipID, userID)
+	if err != nil {
+		h.logger.WithContext(r.Context()).WithError(err).Warn("Failed to get preferences, withholding read receipt")
+		return false
+	}
+	return prefs.SendReadReceipts
+}
+
+// Preference Handlers
+
+// GetPreferences returns the current user's messaging preferences
+func (h *Handler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	dealershipID := getDealershipID(r)
+	userID := getUserID(r)
+
+	if dealershipID == "" || userID == "" {
+		respondError(w, http.StatusBadRequest, "Missing dealership or user ID")
+		return
+	}
+
+	prefs, err := h.db.GetUserPreferences(dealershipID, userID)
+	if err != nil {
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to get preferences")
+		respondError(w, http.StatusInternalServerError, "Failed to get preferences")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, prefs)
+}
+
+// UpdatePreferences updates the current user's messaging preferences
+func (h *Handler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	dealershipID := getDealershipID(r)
+	userID := getUserID(r)
+
+	if dealershipID == "" || userID == "" {
+		respondError(w, http.StatusBadRequest, "Missing dealership or user ID")
+		return
+	}
+
+	var req UpdatePreferencesRequest
+	if !decodeAndValidateMessaging(r, w, &req) {
+		return
+	}
+
+	prefs, err := h.db.UpdateUserPreferences(dealershipID, userID, req)
+	if err != nil {
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to update preferences")
+		respondError(w, http.StatusInternalServerError, "Failed to update preferences")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, prefs)
+}
+
+// Announcement Handlers
+
+// CreateAnnouncement broadcasts an announcement to the dealership's staff,
+// optionally limited to some roles. Each recipient gets it over their
+// WebSocket connections and in their announcements feed.
+func (h *Handler) CreateAnnouncement(w http.ResponseWriter, r *http.Request) {
+	dealershipID := getDealershipID(r)
+	userID := getUserID(r)
+
+	if dealershipID == "" || userID == "" {
+		respondError(w, http.StatusBadRequest, "Missing required headers")
+		return
+	}
+
+	if !hasScope(r, ScopeMessagingManage) {
+		respondError(w, http.StatusForbidden, "Posting announcements requires the "+ScopeMessagingManage+" scope")
+		return
+	}
+
+	var req CreateAnnouncementRequest
+	if !decodeAndValidateMessaging(r, w, &req) {
+		return
+	}
+
+	announcement, recipients, err := h.db.CreateAnnouncement(dealershipID, userID, req)
+	if err != nil {
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to create announcement")
+		respondError(w, http.StatusInternalServerError, "Failed to create announcement")
+		return
+	}
+
+	h.hub.BroadcastToUsers(dealershipID, recipients, WSEventAnnouncementPosted, announcement)
+
+	h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
+		"announcement_id": announcement.ID,
+		"recipients":      len(recipients),
+	}).Info("Announcement posted")
+
+	respondJSON(w, http.StatusCreated, announcement)
+}
+
+// ListAnnouncements lists the announcements the user received, newest first
+func (h *Handler) ListAnnouncements(w http.ResponseWriter, r *http.Request) {
+	dealershipID := getDealershipID(r)
+	userID := getUserID(r)
+
+	if dealershipID == "" || userID == "" {
+		respondError(w, http.StatusBadRequest, "Missing required headers")
+		return
+	}
+
+	limit, offset := 50, 0
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	announcements, total, unread, err := h.db.ListAnnouncements(dealershipID, userID, limit, offset)
+	if err != nil {
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to list announcements")
+		respondError(w, http.StatusInternalServerError, "Failed to list announcements")
+		return
+	}
+
+	if announcements == nil {
+		announcements = []Announcement{}
+	}
+
+	respondJSON(w, http.StatusOK, AnnouncementsResponse{
+		Announcements: announcements,
+		Total:         total,
+		Unread:        unread,
+	})
+}
+
+// MarkAnnouncementRead marks an announcement read for the user
+func (h *Handler) MarkAnnouncementRead(w http.ResponseWriter, r *http.Request) {
+	dealershipID := getDealershipID(r)
+	userID := getUserID(r)
+	announcementID := mux.Vars(r)["announcementId"]
+
+	if dealershipID == "" || userID == "" {
+		respondError(w, http.StatusBadRequest, "Missing required headers")
+		return
+	}
+
+	if !validateUUIDMessaging(w, announcementID, "announcementId") {
+		return
+	}
+
+	if err := h.db.MarkAnnouncementRead(announcementID, dealershipID, userID); err != nil {
+		if err.Error() == "announcement not found" {
+			respondError(w, http.StatusNotFound, "Announcement not found")
+			return
+		}
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to mark announcement read")
+		respondError(w, http.StatusInternalServerError, "Failed to mark announcement read")
+		return
+	}
 
 	respondJSON(w, http.StatusOK, map[string]string{"status": "read"})
 }
@@ -404,6 +664,7 @@ func (h *Handler) MarkAsRead(w http.ResponseWriter, r *http.Request) {
 
 // AddReaction adds a reaction to a message
 func (h *Handler) AddReaction(w http.ResponseWriter, r *http.Request) {
+	dealershipID := getDealershipID(r)
 	userID := getUserID(r)
 	userName := getUserName(r)
 	conversationID := mux.Vars(r)["conversationId"]
@@ -427,13 +688,14 @@ func (h *Handler) AddReaction(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Broadcast reaction
-	h.hub.BroadcastToConversation(conversationID, WSEventReactionAdded, reaction, "")
+	h.hub.BroadcastToConversation(dealershipID, conversationID, WSEventReactionAdded, reaction, "")
 
 	respondJSON(w, http.StatusCreated, reaction)
 }
 
 // RemoveReaction removes a reaction from a message
 func (h *Handler) RemoveReaction(w http.ResponseWriter, r *http.Request) {
+	dealershipID := getDealershipID(r)
 	userID := getUserID(r)
 	conversationID := mux.Vars(r)["conversationId"]
 	messageID := mux.Vars(r)["messageId"]
@@ -452,7 +714,7 @@ func (h *Handler) RemoveReaction(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Broadcast removal
-	h.hub.BroadcastToConversation(conversationID, WSEventReactionRemoved, map[string]string{
+	h.hub.BroadcastToConversation(dealershipID, conversationID, WSEventReactionRemoved, map[string]string{
 		"message_id":    messageID,
 		"user_id":       userID,
 		"reaction_type": reactionType,
@@ -465,6 +727,7 @@ func (h *Handler) RemoveReaction(w http.ResponseWriter, r *http.Request) {
 
 // SetTyping handles typing indicator
 func (h *Handler) SetTyping(w http.ResponseWriter, r *http.Request) {
+	dealershipID := getDealershipID(r)
 	userID := getUserID(r)
 	userName := getUserName(r)
 	conversationID := mux.Vars(r)["conversationId"]
@@ -482,7 +745,7 @@ func (h *Handler) SetTyping(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.hub.SetTyping(conversationID, userID, userName, req.IsTyping)
+	h.hub.SetTyping(dealershipID, conversationID, userID, userName, req.IsTyping)
 
 	respondJSON(w, http.StatusOK, map[string]bool{"is_typing": req.IsTyping})
 }
@@ -521,10 +784,10 @@ func (h *Handler) AddParticipant(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Broadcast to conversation
-	h.hub.BroadcastToConversation(conversationID, WSEventParticipantJoined, participant, "")
+	h.hub.BroadcastToConversation(dealershipID, conversationID, WSEventParticipantJoined, participant, "")
 
 	// Notify the added user
-	h.hub.BroadcastToUser(req.UserID, WSEventConversationCreated, map[string]string{
+	h.hub.BroadcastToUser(dealershipID, req.UserID, WSEventConversationCreated, map[string]string{
 		"conversation_id": conversationID,
 	})
 
@@ -533,6 +796,7 @@ func (h *Handler) AddParticipant(w http.ResponseWriter, r *http.Request) {
 
 // RemoveParticipant removes a participant from a group conversation
 func (h *Handler) RemoveParticipant(w http.ResponseWriter, r *http.Request) {
+	dealershipID := getDealershipID(r)
 	userID := getUserID(r)
 	conversationID := mux.Vars(r)["conversationId"]
 	participantUserID := mux.Vars(r)["userId"]
@@ -550,7 +814,7 @@ func (h *Handler) RemoveParticipant(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Broadcast to conversation
-	h.hub.BroadcastToConversation(conversationID, WSEventParticipantLeft, map[string]string{
+	h.hub.BroadcastToConversation(dealershipID, conversationID, WSEventParticipantLeft, map[string]string{
 		"user_id": participantUserID,
 	}, "")
 
@@ -559,6 +823,7 @@ func (h *Handler) RemoveParticipant(w http.ResponseWriter, r *http.Request) {
 
 // GetParticipants gets all participants in a conversation
 func (h *Handler) GetParticipants(w http.ResponseWriter, r *http.Request) {
+	dealershipID := getDealershipID(r)
 	conversationID := mux.Vars(r)["conversationId"]
 
 	participants, err := h.db.GetParticipants(conversationID)
@@ -570,8 +835,161 @@ func (h *Handler) GetParticipants(w http.ResponseWriter, r *http.Request) {
 
 	// Update presence
 	for i := range participants {
-		participants[i].Presence = h.hub.GetUserPresence(participants[i].UserID)
+		participants[i].Presence = h.hub.GetUserPresence(dealershipID, participants[i].UserID)
 	}
 
 	respondJSON(w, http.StatusOK, participants)
 }
+
+// Tag Handlers
+
+// ListTags lists the dealership's tag vocabulary
+func (h *Handler) ListTags(w http.ResponseWriter, r *http.Request) {
+	dealershipID := getDealershipID(r)
+
+	if dealershipID == "" {
+		respondError(w, http.StatusBadRequest, "Missing required headers")
+		return
+	}
+
+	tags, err := h.db.ListTags(dealershipID)
+	if err != nil {
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to list tags")
+		respondError(w, http.StatusInternalServerError, "Failed to list tags")
+		return
+	}
+
+	if tags == nil {
+		tags = []ConversationTag{}
+	}
+
+	respondJSON(w, http.StatusOK, TagsResponse{
+		Tags:  tags,
+		Total: len(tags),
+	})
+}
+
+// AddConversationTags applies tags to a conversation
+func (h *Handler) AddConversationTags(w http.ResponseWriter, r *http.Request) {
+	dealershipID := getDealershipID(r)
+	userID := getUserID(r)
+	conversationID := mux.Vars(r)["conversationId"]
+
+	if dealershipID == "" || userID == "" {
+		respondError(w, http.StatusBadRequest, "Missing required headers")
+		return
+	}
+
+	var req AddTagsRequest
+	if !decodeAndValidateMessaging(r, w, &req) {
+		return
+	}
+
+	if _, err := h.db.GetConversation(conversationID, dealershipID, userID); err != nil {
+		if err.Error() == "conversation not found" {
+			respondError(w, http.StatusNotFound, "Conversation not found")
+			return
+		}
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to get conversation")
+		respondError(w, http.StatusInternalServerError, "Failed to tag conversation")
+		return
+	}
+
+	tags, err := h.db.AddConversationTags(conversationID, dealershipID, userID, req.Tags)
+	if err != nil {
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to tag conversation")
+		respondError(w, http.StatusInternalServerError, "Failed to tag conversation")
+		return
+	}
+
+	h.broadcastTags(dealershipID, conversationID, tags)
+
+	respondJSON(w, http.StatusOK, ConversationTagsEvent{ConversationID: conversationID, Tags: tags})
+}
+
+// BulkTagConversations applies the same tags to several conversations
+func (h *Handler) BulkTagConversations(w http.ResponseWriter, r *http.Request) {
+	dealershipID := getDealershipID(r)
+	userID := getUserID(r)
+
+	if dealershipID == "" || userID == "" {
+		respondError(w, http.StatusBadRequest, "Missing required headers")
+		return
+	}
+
+	var req BulkTagRequest
+	if !decodeAndValidateMessaging(r, w, &req) {
+		return
+	}
+
+	resp := BulkTagResponse{Tagged: []string{}, NotFound: []string{}}
+	for _, conversationID := range req.ConversationIDs {
+		if _, err := h.db.GetConversation(conversationID, dealershipID, userID); err != nil {
+			if err.Error() != "conversation not found" {
+				h.logger.WithContext(r.Context()).WithError(err).Error("Failed to get conversation")
+				respondError(w, http.StatusInternalServerError, "Failed to tag conversations")
+				return
+			}
+			resp.NotFound = append(resp.NotFound, conversationID)
+			continue
+		}
+
+		tags, err := h.db.AddConversationTags(conversationID, dealershipID, userID, req.Tags)
+		if err != nil {
+			h.logger.WithContext(r.Context()).WithError(err).Error("Failed to tag conversation")
+			respondError(w, http.StatusInternalServerError, "Failed to tag conversations")
+			return
+		}
+
+		h.broadcastTags(dealershipID, conversationID, tags)
+		resp.Tagged = append(resp.Tagged, conversationID)
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// RemoveConversationTag removes a tag from a conversation
+func (h *Handler) RemoveConversationTag(w http.ResponseWriter, r *http.Request) {
+	dealershipID := getDealershipID(r)
+	userID := getUserID(r)
+	conversationID := mux.Vars(r)["conversationId"]
+	tagName := mux.Vars(r)["tag"]
+
+	if dealershipID == "" || userID == "" {
+		respondError(w, http.StatusBadRequest, "Missing required headers")
+		return
+	}
+
+	if _, err := h.db.GetConversation(conversationID, dealershipID, userID); err != nil {
+		if err.Error() == "conversation not found" {
+			respondError(w, http.StatusNotFound, "Conversation not found")
+			return
+		}
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to get conversation")
+		respondError(w, http.StatusInternalServerError, "Failed to remove tag")
+		return
+	}
+
+	tags, err := h.db.RemoveConversationTag(conversationID, dealershipID, tagName)
+	if err != nil {
+		if err.Error() == "tag not found" {
+			respondError(w, http.StatusNotFound, "Tag not found on conversation")
+			return
+		}
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to remove tag")
+		respondError(w, http.StatusInternalServerError, "Failed to remove tag")
+		return
+	}
+
+	h.broadcastTags(dealershipID, conversationID, tags)
+
+	respondJSON(w, http.StatusOK, ConversationTagsEvent{ConversationID: conversationID, Tags: tags})
+}
+
+// broadcastTags notifies the conversation that its tags changed
+func (h *Handler) broadcastTags(dealershipID, conversationID string, tags []ConversationTag) {
+	h.hub.BroadcastToConversation(dealershipID, conversationID, WSEventTagsUpdated, ConversationTagsEvent{
+		ConversationID: conversationID,
+		Tags:           tags,
+	}, "")
+}
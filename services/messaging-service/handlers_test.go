@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"autolytiq/shared/logging"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// MockDatabase is an in-memory implementation of MessagingDatabase for testing
+type MockDatabase struct {
+	conversations map[string]*Conversation
+	messages      map[string]*Message
+	tags          map[string]*ConversationTag // keyed by dealership and lowercase name
+	preferences   map[string]*UserPreferences // keyed by dealership and user
+	lastRead      map[string]time.Time        // keyed by conversation and user
+
+	staff         map[string][]mockStaffMember // keyed by dealership
+	announcements map[string]*Announcement
+	annRecipients map[string]map[string]*time.Time // keyed by announcement, then user; nil until read
+}
+
+func NewMockDatabase() *MockDatabase {
+	return &MockDatabase{
+		conversations: make(map[string]*Conversation),
+		messages:      make(map[string]*Message),
+		tags:          make(map[string]*ConversationTag),
+		preferences:   make(map[string]*UserPreferences),
+		lastRead:      make(map[string]time.Time),
+		staff:         make(map[string][]mockStaffMember),
+		announcements: make(map[string]*Announcement),
+		annRecipients: make(map[string]map[string]*time.Time),
+	}
+}
+
+func (db *MockDatabase) ListConversations(dealershipID, userID string, filter ConversationFilter) ([]Conversation, int, error) {
+	var result []Conversation
+	for _, c := range db.conversations {
+		if c.DealershipID != dealershipID {
+			continue
+		}
+		if filter.Tag != nil && !hasTag(c, *filter.Tag) {
+			continue
+		}
+		if !hasParticipants(c, filter.ParticipantIDs) {
+			continue
+		}
+		result = append(result, *c)
+	}
+	return result, len(result), nil
+}
+
+func (db *MockDatabase) GetConversation(id, dealershipID, userID string) (*Conversation, error) {
+	c, ok := db.conversations[id]
+	if !ok || c.DealershipID != dealershipID {
+		return nil, fmt.Errorf("conversation not found")
+	}
+	return c, nil
+}
+
+func (db *MockDatabase) CreateConversation(dealershipID, userID string, req CreateConversationRequest) (*Conversation, error) {
+	c := &Conversation{ID: uuid.New().String(), DealershipID: dealershipID, Type: req.Type, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	db.conversations[c.ID] = c
+	return c, nil
+}
+
+func (db *MockDatabase) UpdateConversation(id, dealershipID, userID string, req UpdateConversationRequest) (*Conversation, error) {
+	return db.GetConversation(id, dealershipID, userID)
+}
+
+func (db *MockDatabase) GetOrCreateDirectConversation(dealershipID, userID, otherUserID string) (*Conversation, error) {
+	return db.CreateConversation(dealershipID, userID, CreateConversationRequest{Type: "DIRECT"})
+}
+
+func (db *MockDatabase) ListMessages(conversationID, dealershipID, userID string, filter MessageFilter) ([]Message, int, bool, error) {
+	var result []Message
+	for _, m := range db.messages {
+		if m.ConversationID == conversationID && m.Status != MessageStatusScheduled {
+			result = append(result, *m)
+		}
+	}
+	return result, len(result), false, nil
+}
+
+func (db *MockDatabase) GetMessage(id, conversationID string) (*Message, error) {
+	m, ok := db.messages[id]
+	if !ok || m.ConversationID != conversationID {
+		return nil, fmt.Errorf("message not found")
+	}
+	return m, nil
+}
+
+func (db *MockDatabase) CreateMessage(conversationID, dealershipID, senderID string, req SendMessageRequest) (*Message, error) {
+	m := &Message{
+		ID:             uuid.New().String(),
+		ConversationID: conversationID,
+		DealershipID:   dealershipID,
+		SenderID:       senderID,
+		Type:           req.Type,
+		Content:        req.Content,
+		Status:         "SENT",
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+	if req.SendAt != nil {
+		m.Status = MessageStatusScheduled
+		m.ScheduledAt = req.SendAt
+	}
+	db.messages[m.ID] = m
+	return m, nil
+}
+
+func (db *MockDatabase) UpdateMessage(id, conversationID, userID string, req UpdateMessageRequest) (*Message, error) {
+	m, err := db.GetMessage(id, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	m.Content = req.Content
+	return m, nil
+}
+
+func (db *MockDatabase) DeleteMessage(id, conversationID, userID string) error {
+	delete(db.messages, id)
+	return nil
+}
+
+func (db *MockDatabase) MarkAsDelivered(messageID, userID string) error { return nil }
+
+func (db *MockDatabase) MarkAsRead(conversationID, userID string, sendReceipt bool) error {
+	now := time.Now()
+	db.lastRead[conversationID+"/"+userID] = now
+	if !sendReceipt {
+		return nil
+	}
+	for _, m := range db.messages {
+		if m.ConversationID == conversationID && m.SenderID != userID && (m.Status == "SENT" || m.Status == "DELIVERED") {
+			m.Status = "READ"
+			m.ReadAt = &now
+		}
+	}
+	return nil
+}
+
+func (db *MockDatabase) ListScheduledMessages(dealershipID, senderID string) ([]Message, error) {
+	var result []Message
+	for _, m := range db.messages {
+		c, ok := db.conversations[m.ConversationID]
+		if ok && c.DealershipID == dealershipID && m.SenderID == senderID && m.Status == MessageStatusScheduled {
+			result = append(result, *m)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ScheduledAt.Before(*result[j].ScheduledAt) })
+	return result, nil
+}
+
+func (db *MockDatabase) CancelScheduledMessage(id, dealershipID, senderID string) error {
+	m, ok := db.messages[id]
+	if !ok || m.SenderID != senderID || m.Status != MessageStatusScheduled {
+		return fmt.Errorf("scheduled message not found")
+	}
+	if c, ok := db.conversations[m.ConversationID]; !ok || c.DealershipID != dealershipID {
+		return fmt.Errorf("scheduled message not found")
+	}
+	delete(db.messages, id)
+	return nil
+}
+
+func (db *MockDatabase) DispatchDueMessages(now time.Time) ([]Message, error) {
+	var result []Message
+	for _, m := range db.messages {
+		if m.Status == MessageStatusScheduled && !m.ScheduledAt.After(now) {
+			m.Status = "SENT"
+			m.CreatedAt = now
+			result = append(result, *m)
+		}
+	}
+	return result, nil
+}
+
+func (db *MockDatabase) AddReaction(messageID, userID, userName string, req AddReactionRequest) (*MessageReaction, error) {
+	return &MessageReaction{ID: uuid.New().String(), MessageID: messageID, UserID: userID, ReactionType: req.ReactionType}, nil
+}
+
+func (db *MockDatabase) RemoveReaction(messageID, userID, reactionType string) error { return nil }
+
+func (db *MockDatabase) AddParticipant(conversationID, userID, addedByID, role string) (*Participant, error) {
+	return &Participant{ID: uuid.New().String(), UserID: userID, Role: role}, nil
+}
+
+func (db *MockDatabase) RemoveParticipant(conversationID, userID, removedByID string) error { return nil }
+
+func (db *MockDatabase) GetParticipants(conversationID string) ([]Participant, error) {
+	return nil, nil
+}
+
+func (db *MockDatabase) ListTags(dealershipID string) ([]ConversationTag, error) {
+	var result []ConversationTag
+	for _, t := range db.tags {
+		if t.DealershipID == dealershipID {
+			result = append(result, *t)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+func (db *MockDatabase) AddConversationTags(conversationID, dealershipID, userID string, tags []TagInput) ([]ConversationTag, error) {
+	c := db.conversations[conversationID]
+	for _, input := range tags {
+		key := dealershipID + "|" + strings.ToLower(input.Name)
+		tag, ok := db.tags[key]
+		if !ok {
+			tag = &ConversationTag{ID: uuid.New().String(), DealershipID: dealershipID, Name: input.Name, CreatedAt: time.Now()}
+			db.tags[key] = tag
+		}
+		if input.Color != nil {
+			tag.Color = input.Color
+		}
+		if !hasTag(c, tag.Name) {
+			c.Tags = append(c.Tags, *tag)
+		}
+	}
+	return c.Tags, nil
+}
+
+func (db *MockDatabase) RemoveConversationTag(conversationID, dealershipID, tagName string) ([]ConversationTag, error) {
+	c := db.conversations[conversationID]
+	for i, t := range c.Tags {
+		if strings.EqualFold(t.Name, tagName) {
+			c.Tags = append(c.Tags[:i:i], c.Tags[i+1:]...)
+			return c.Tags, nil
+		}
+	}
+	return nil, fmt.Errorf("tag not found")
+}
+
+func (db *MockDatabase) Close() error { return nil }
+
+func hasTag(c *Conversation, name string) bool {
+	for _, t := range c.Tags {
+		if strings.EqualFold(t.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasParticipants reports whether all of the users take part in c
+func hasParticipants(c *Conversation, userIDs []string) bool {
+	for _, id := range userIDs {
+		found := false
+		for _, p := range c.Participants {
+			if p.UserID == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// testLogger creates a logger that discards output during tests
+func testLogger() *logging.Logger {
+	return logging.New(logging.Config{
+		Service: "messaging-service-test",
+		Level:   logging.LevelError,
+		Output:  &bytes.Buffer{},
+	})
+}
+
+// setupTestHandler creates a handler with a mock database and a hub whose
+// event loop is not running, so broadcasts can be inspected on the channel
+func setupTestHandler() (*Handler, *MockDatabase, *mux.Router) {
+	db := NewMockDatabase()
+	hub := NewHub(DefaultTypingTimeout, testLogger())
+	handler := NewHandler(db, hub, testLogger())
+
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api/messaging").Subrouter()
+	api.HandleFunc("/conversations/{conversationId}/messages", handler.SendMessage).Methods("POST")
+	api.HandleFunc("/scheduled", handler.ListScheduledMessages).Methods("GET")
+	api.HandleFunc("/scheduled/{messageId}", handler.CancelScheduledMessage).Methods("DELETE")
+	api.HandleFunc("/conversations", handler.ListConversations).Methods("GET")
+	api.HandleFunc("/conversations/bulk-tags", handler.BulkTagConversations).Methods("POST")
+	api.HandleFunc("/tags", handler.ListTags).Methods("GET")
+	api.HandleFunc("/conversations/{conversationId}/tags", handler.AddConversationTags).Methods("POST")
+	api.HandleFunc("/conversations/{conversationId}/tags/{tag}", handler.RemoveConversationTag).Methods("DELETE")
+	api.HandleFunc("/conversations/{conversationId}/read", handler.MarkAsRead).Methods("POST")
+	api.HandleFunc("/preferences", handler.GetPreferences).Methods("GET")
+	api.HandleFunc("/preferences", handler.UpdatePreferences).Methods("PUT")
+	api.HandleFunc("/announcements", handler.ListAnnouncements).Methods("GET")
+	api.HandleFunc("/announcements", handler.CreateAnnouncement).Methods("POST")
+	api.HandleFunc("/announcements/{announcementId}/read", handler.MarkAnnouncementRead).Methods("POST")
+
+	return handler, db, router
+}
+
+// drainBroadcasts returns the event types queued on the hub's broadcast channel
+func drainBroadcasts(hub *Hub) []string {
+	var types []string
+	for {
+		select {
+		case msg := <-hub.broadcast:
+			var ws WSMessage
+			json.Unmarshal(msg.Message, &ws)
+			types = append(types, ws.Type)
+		default:
+			return types
+		}
+	}
+}
+
+func containsEvent(events []string, eventType string) bool {
+	for _, e := range events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func newTestConversation(db *MockDatabase, dealershipID string) *Conversation {
+	c := &Conversation{ID: uuid.New().String(), DealershipID: dealershipID, Type: "DIRECT"}
+	db.conversations[c.ID] = c
+	return c
+}
+
+func doRequest(router *mux.Router, method, path string, body interface{}, dealershipID, userID string) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Dealership-ID", dealershipID)
+	req.Header.Set("X-User-ID", userID)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestSendMessageImmediate(t *testing.T) {
+	handler, db, router := setupTestHandler()
+	dealershipID, userID := uuid.New().String(), uuid.New().String()
+	conv := newTestConversation(db, dealershipID)
+
+	rr := doRequest(router, "POST", "/api/messaging/conversations/"+conv.ID+"/messages",
+		SendMessageRequest{Type: "TEXT", Content: "Hello"}, dealershipID, userID)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if !containsEvent(drainBroadcasts(handler.hub), WSEventMessageSent) {
+		t.Error("Expected immediate message to be broadcast")
+	}
+}
+
+func TestSendMessageScheduled(t *testing.T) {
+	handler, db, router := setupTestHandler()
+	dealershipID, userID := uuid.New().String(), uuid.New().String()
+	conv := newTestConversation(db, dealershipID)
+
+	sendAt := time.Now().Add(2 * time.Hour)
+	rr := doRequest(router, "POST", "/api/messaging/conversations/"+conv.ID+"/messages",
+		SendMessageRequest{Type: "TEXT", Content: "Following up", SendAt: &sendAt}, dealershipID, userID)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var message Message
+	json.Unmarshal(rr.Body.Bytes(), &message)
+	if message.Status != MessageStatusScheduled {
+		t.Errorf("Expected status SCHEDULED, got %s", message.Status)
+	}
+	if message.ScheduledAt == nil || !message.ScheduledAt.Equal(sendAt) {
+		t.Errorf("Expected scheduled_at %v, got %v", sendAt, message.ScheduledAt)
+	}
+	if containsEvent(drainBroadcasts(handler.hub), WSEventMessageSent) {
+		t.Error("Scheduled message must not be broadcast before its send time")
+	}
+
+	visible, _, _, _ := db.ListMessages(conv.ID, dealershipID, userID, MessageFilter{Limit: 50})
+	if len(visible) != 0 {
+		t.Errorf("Expected scheduled message to be hidden from the conversation, got %d messages", len(visible))
+	}
+}
+
+func TestSendMessageRejectsPastSendAt(t *testing.T) {
+	_, db, router := setupTestHandler()
+	dealershipID, userID := uuid.New().String(), uuid.New().String()
+	conv := newTestConversation(db, dealershipID)
+
+	sendAt := time.Now().Add(-time.Minute)
+	rr := doRequest(router, "POST", "/api/messaging/conversations/"+conv.ID+"/messages",
+		SendMessageRequest{Type: "TEXT", Content: "Too late", SendAt: &sendAt}, dealershipID, userID)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rr.Code)
+	}
+	if len(db.messages) != 0 {
+		t.Error("Expected no message to be stored")
+	}
+}
+
+func TestListAndCancelScheduledMessages(t *testing.T) {
+	_, db, router := setupTestHandler()
+	dealershipID, userID := uuid.New().String(), uuid.New().String()
+	conv := newTestConversation(db, dealershipID)
+
+	later := time.Now().Add(3 * time.Hour)
+	sooner := time.Now().Add(time.Hour)
+	first, _ := db.CreateMessage(conv.ID, dealershipID, userID, SendMessageRequest{Type: "TEXT", Content: "later", SendAt: &later})
+	db.CreateMessage(conv.ID, dealershipID, userID, SendMessageRequest{Type: "TEXT", Content: "sooner", SendAt: &sooner})
+	db.CreateMessage(conv.ID, dealershipID, uuid.New().String(), SendMessageRequest{Type: "TEXT", Content: "someone else", SendAt: &sooner})
+
+	rr := doRequest(router, "GET", "/api/messaging/scheduled", nil, dealershipID, userID)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	var resp ScheduledMessagesResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Total != 2 {
+		t.Fatalf("Expected 2 scheduled messages for user, got %d", resp.Total)
+	}
+	if resp.Messages[0].Content != "sooner" {
+		t.Errorf("Expected soonest message first, got %q", resp.Messages[0].Content)
+	}
+
+	rr = doRequest(router, "DELETE", "/api/messaging/scheduled/"+first.ID, nil, dealershipID, userID)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 on cancel, got %d", rr.Code)
+	}
+	if _, ok := db.messages[first.ID]; ok {
+		t.Error("Expected cancelled message to be removed")
+	}
+
+	rr = doRequest(router, "DELETE", "/api/messaging/scheduled/"+first.ID, nil, dealershipID, userID)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 cancelling twice, got %d", rr.Code)
+	}
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"os"
+	"time"
 
+	"autolytiq/shared/graceful"
 	"autolytiq/shared/logging"
+	"autolytiq/shared/tracing"
 
 	"github.com/gorilla/mux"
 )
@@ -36,16 +40,28 @@ func main() {
 	defer db.Close()
 
 	// Initialize WebSocket hub
-	hub := NewHub(logger)
+	hub := NewHub(getTypingTimeout(), logger)
 	go hub.Run()
 	logger.Info("WebSocket hub started")
 
 	// Initialize handler
 	handler := NewHandler(db, hub, logger)
 
+	// Start scheduled message dispatcher
+	scheduler := NewMessageScheduler(db, handler, getScheduleInterval(), logger)
+	scheduler.Start()
+	defer scheduler.Stop()
+
 	// Setup router
 	router := mux.NewRouter()
 
+	// Trace requests when OTEL_EXPORTER_OTLP_ENDPOINT is set
+	tracer := tracing.New(tracing.Config{
+		Service: "messaging-service",
+		OnError: func(err error) { logger.WithError(err).Warn("Failed to export trace spans") },
+	})
+	defer tracer.Shutdown(context.Background())
+	router.Use(tracer.Middleware)
 	// Apply logging middleware
 	router.Use(logging.RequestIDMiddleware)
 	router.Use(logging.RequestLoggingMiddleware(logger))
@@ -64,6 +80,7 @@ func main() {
 	// Conversations
 	api.HandleFunc("/conversations", handler.ListConversations).Methods("GET")
 	api.HandleFunc("/conversations", handler.CreateConversation).Methods("POST")
+	api.HandleFunc("/conversations/bulk-tags", handler.BulkTagConversations).Methods("POST")
 	api.HandleFunc("/conversations/{id}", handler.GetConversation).Methods("GET")
 	api.HandleFunc("/conversations/{id}", handler.UpdateConversation).Methods("PATCH")
 
@@ -74,9 +91,22 @@ func main() {
 	api.HandleFunc("/conversations/{conversationId}/messages/{messageId}", handler.UpdateMessage).Methods("PATCH")
 	api.HandleFunc("/conversations/{conversationId}/messages/{messageId}", handler.DeleteMessage).Methods("DELETE")
 
+	// Scheduled messages
+	api.HandleFunc("/scheduled", handler.ListScheduledMessages).Methods("GET")
+	api.HandleFunc("/scheduled/{messageId}", handler.CancelScheduledMessage).Methods("DELETE")
+
 	// Read receipts
 	api.HandleFunc("/conversations/{conversationId}/read", handler.MarkAsRead).Methods("POST")
 
+	// Preferences
+	api.HandleFunc("/preferences", handler.GetPreferences).Methods("GET")
+	api.HandleFunc("/preferences", handler.UpdatePreferences).Methods("PUT")
+
+	// Announcements
+	api.HandleFunc("/announcements", handler.ListAnnouncements).Methods("GET")
+	api.HandleFunc("/announcements", handler.CreateAnnouncement).Methods("POST")
+	api.HandleFunc("/announcements/{announcementId}/read", handler.MarkAnnouncementRead).Methods("POST")
+
 	// Reactions
 	api.HandleFunc("/conversations/{conversationId}/messages/{messageId}/reactions", handler.AddReaction).Methods("POST")
 	api.HandleFunc("/conversations/{conversationId}/messages/{messageId}/reactions/{reactionType}", handler.RemoveReaction).Methods("DELETE")
@@ -89,13 +119,47 @@ func main() {
 	api.HandleFunc("/conversations/{conversationId}/participants", handler.AddParticipant).Methods("POST")
 	api.HandleFunc("/conversations/{conversationId}/participants/{userId}", handler.RemoveParticipant).Methods("DELETE")
 
+	// Tags
+	api.HandleFunc("/tags", handler.ListTags).Methods("GET")
+	api.HandleFunc("/conversations/{conversationId}/tags", handler.AddConversationTags).Methods("POST")
+	api.HandleFunc("/conversations/{conversationId}/tags/{tag}", handler.RemoveConversationTag).Methods("DELETE")
+
 	// CORS middleware
 	corsHandler := corsMiddleware(router)
 
+	// Drain in-flight requests and disconnect WebSocket clients on SIGTERM
+	ctx, stop := graceful.SignalContext(context.Background())
+	defer stop()
+
+	srv := &http.Server{Addr: ":" + port, Handler: corsHandler}
+	srv.RegisterOnShutdown(hub.Close)
+
 	logger.Infof("Messaging service starting on port %s", port)
-	if err := http.ListenAndServe(":"+port, corsHandler); err != nil {
+	if err := graceful.ListenAndServe(ctx, srv, graceful.DefaultTimeout); err != nil {
 		logger.Fatalf("Server failed: %v", err)
 	}
+	logger.Info("Messaging service stopped")
+}
+
+// getScheduleInterval returns how often the scheduled message dispatcher polls
+func getScheduleInterval() time.Duration {
+	if value := os.Getenv("SCHEDULED_MESSAGE_POLL_INTERVAL"); value != "" {
+		if interval, err := time.ParseDuration(value); err == nil && interval > 0 {
+			return interval
+		}
+	}
+	return 15 * time.Second
+}
+
+// getTypingTimeout returns how long a user shows as typing after their last
+// typing event
+func getTypingTimeout() time.Duration {
+	if value := os.Getenv("TYPING_INDICATOR_TIMEOUT"); value != "" {
+		if timeout, err := time.ParseDuration(value); err == nil && timeout > 0 {
+			return timeout
+		}
+	}
+	return DefaultTypingTimeout
 }
 
 func corsMiddleware(next http.Handler) http.Handler {
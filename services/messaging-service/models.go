@@ -9,6 +9,7 @@ import (
 type Message struct {
 	ID               string           `json:"id"`
 	ConversationID   string           `json:"conversation_id"`
+	DealershipID     string           `json:"-"` // The conversation's dealership, for delivery
 	SenderID         string           `json:"sender_id"`
 	Sender           *Participant     `json:"sender,omitempty"`
 	Type             string           `json:"type"`
@@ -28,6 +29,7 @@ type Message struct {
 	IsEphemeral      bool             `json:"is_ephemeral"`
 	EphemeralSeconds *int             `json:"ephemeral_seconds,omitempty"`
 	EphemeralExpiresAt *time.Time     `json:"ephemeral_expires_at,omitempty"`
+	ScheduledAt      *time.Time       `json:"scheduled_at,omitempty"`
 	CreatedAt        time.Time        `json:"created_at"`
 	UpdatedAt        time.Time        `json:"updated_at"`
 }
@@ -49,6 +51,18 @@ type Conversation struct {
 	CreatedByID  *string       `json:"created_by_id,omitempty"`
 	CreatedAt    time.Time     `json:"created_at"`
 	UpdatedAt    time.Time     `json:"updated_at"`
+
+	// Tags are the dealership labels applied to the conversation
+	Tags []ConversationTag `json:"tags"`
+}
+
+// ConversationTag is a dealership-defined label for categorizing conversations
+type ConversationTag struct {
+	ID           string    `json:"id"`
+	DealershipID string    `json:"dealership_id"`
+	Name         string    `json:"name"`
+	Color        *string   `json:"color,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
 }
 
 // Participant represents a user in a conversation
@@ -116,6 +130,46 @@ type ReadReceipt struct {
 	ReadAt    time.Time `json:"read_at"`
 }
 
+// UserPreferences holds a user's messaging privacy preferences
+type UserPreferences struct {
+	UserID       string `json:"user_id"`
+	DealershipID string `json:"dealership_id"`
+
+	// SendReadReceipts controls whether other participants can see when the
+	// user has read their messages
+	SendReadReceipts bool      `json:"send_read_receipts"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// DefaultUserPreferences returns the preferences of a user who hasn't set any
+func DefaultUserPreferences(dealershipID, userID string) *UserPreferences {
+	return &UserPreferences{
+		UserID:           userID,
+		DealershipID:     dealershipID,
+		SendReadReceipts: true,
+	}
+}
+
+// Announcement is a message broadcast to a dealership's staff, outside of
+// any conversation. It is delivered to each targeted user and kept in their
+// announcements feed.
+type Announcement struct {
+	ID           string `json:"id"`
+	DealershipID string `json:"dealership_id"`
+	SenderID     string `json:"sender_id"`
+	Content      string `json:"content"`
+
+	// TargetRoles limits the announcement to users with these roles; empty
+	// means all staff
+	TargetRoles    []string  `json:"target_roles"`
+	RecipientCount int       `json:"recipient_count"`
+	ReadCount      int       `json:"read_count"`
+	CreatedAt      time.Time `json:"created_at"`
+
+	// ReadAt is when the requesting user read the announcement
+	ReadAt *time.Time `json:"read_at,omitempty"`
+}
+
 // Request Types
 
 // CreateConversationRequest is the request for creating a conversation
@@ -128,11 +182,12 @@ type CreateConversationRequest struct {
 
 // SendMessageRequest is the request for sending a message
 type SendMessageRequest struct {
-	Type             string  `json:"type"`
-	Content          string  `json:"content"`
-	ReplyToID        *string `json:"reply_to_id,omitempty"`
-	IsEphemeral      bool    `json:"is_ephemeral"`
-	EphemeralSeconds *int    `json:"ephemeral_seconds,omitempty"`
+	Type             string     `json:"type"`
+	Content          string     `json:"content"`
+	ReplyToID        *string    `json:"reply_to_id,omitempty"`
+	IsEphemeral      bool       `json:"is_ephemeral"`
+	EphemeralSeconds *int       `json:"ephemeral_seconds,omitempty"`
+	SendAt           *time.Time `json:"send_at,omitempty"` // Future time to deliver a scheduled message
 }
 
 // UpdateMessageRequest is the request for updating a message
@@ -145,6 +200,35 @@ type AddReactionRequest struct {
 	ReactionType string `json:"reaction_type"`
 }
 
+// TagInput names a tag to apply. Tags not yet in the dealership's vocabulary
+// are created; a color, if given, replaces the tag's current color.
+type TagInput struct {
+	Name  string  `json:"name"`
+	Color *string `json:"color,omitempty"`
+}
+
+// AddTagsRequest is the request for tagging a conversation
+type AddTagsRequest struct {
+	Tags []TagInput `json:"tags"`
+}
+
+// BulkTagRequest is the request for applying tags to several conversations
+type BulkTagRequest struct {
+	ConversationIDs []string   `json:"conversation_ids"`
+	Tags            []TagInput `json:"tags"`
+}
+
+// UpdatePreferencesRequest is the request for updating a user's preferences
+type UpdatePreferencesRequest struct {
+	SendReadReceipts *bool `json:"send_read_receipts"`
+}
+
+// CreateAnnouncementRequest is the request for broadcasting an announcement
+type CreateAnnouncementRequest struct {
+	Content     string   `json:"content"`
+	TargetRoles []string `json:"target_roles,omitempty"`
+}
+
 // UpdateConversationRequest is the request for updating conversation settings
 type UpdateConversationRequest struct {
 	Name        *string `json:"name,omitempty"`
@@ -169,6 +253,38 @@ type MessagesResponse struct {
 	HasMore  bool      `json:"has_more"`
 }
 
+// ScheduledMessagesResponse is the response for listing scheduled messages
+type ScheduledMessagesResponse struct {
+	Messages []Message `json:"messages"`
+	Total    int       `json:"total"`
+}
+
+// TagsResponse is the response for listing a dealership's tags
+type TagsResponse struct {
+	Tags  []ConversationTag `json:"tags"`
+	Total int               `json:"total"`
+}
+
+// AnnouncementsResponse is the response for listing a user's announcements
+type AnnouncementsResponse struct {
+	Announcements []Announcement `json:"announcements"`
+	Total         int            `json:"total"`
+	Unread        int            `json:"unread"`
+}
+
+// BulkTagResponse reports which conversations were tagged. Conversations that
+// do not exist or that the user cannot access are listed as not found.
+type BulkTagResponse struct {
+	Tagged   []string `json:"tagged"`
+	NotFound []string `json:"not_found"`
+}
+
+// ConversationTagsEvent is broadcast when a conversation's tags change
+type ConversationTagsEvent struct {
+	ConversationID string            `json:"conversation_id"`
+	Tags           []ConversationTag `json:"tags"`
+}
+
 // Filter Types
 
 // ConversationFilter is the filter for listing conversations
@@ -177,8 +293,13 @@ type ConversationFilter struct {
 	IsArchived *bool   `json:"is_archived,omitempty"`
 	IsMuted    *bool   `json:"is_muted,omitempty"`
 	Search     *string `json:"search,omitempty"`
+	Tag        *string `json:"tag,omitempty"`
 	Limit      int     `json:"limit"`
 	Offset     int     `json:"offset"`
+
+	// ParticipantIDs limits results to conversations including all of
+	// these users
+	ParticipantIDs []string `json:"participant_ids,omitempty"`
 }
 
 // MessageFilter is the filter for listing messages
@@ -205,8 +326,12 @@ var ValidMessageStatuses = []string{
 	"DELIVERED",
 	"READ",
 	"FAILED",
+	"SCHEDULED",
 }
 
+// MessageStatusScheduled marks a message held back until its scheduled send time
+const MessageStatusScheduled = "SCHEDULED"
+
 // Valid conversation types
 var ValidConversationTypes = []string{
 	"DIRECT",
@@ -282,6 +407,8 @@ const (
 	WSEventConversationUpdated  = "CONVERSATION_UPDATED"
 	WSEventParticipantJoined    = "PARTICIPANT_JOINED"
 	WSEventParticipantLeft      = "PARTICIPANT_LEFT"
+	WSEventTagsUpdated          = "CONVERSATION_TAGS_UPDATED"
+	WSEventAnnouncementPosted   = "ANNOUNCEMENT_POSTED"
 )
 
 // WSMessage represents a WebSocket message
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// newConversationWith adds a conversation with the given participants
+func newConversationWith(db *MockDatabase, dealershipID string, userIDs ...string) *Conversation {
+	c := newTestConversation(db, dealershipID)
+	for _, id := range userIDs {
+		c.Participants = append(c.Participants, Participant{ID: uuid.New().String(), UserID: id, Role: "MEMBER"})
+	}
+	return c
+}
+
+// conversationIDs decodes a conversation list response into its IDs
+func conversationIDs(t *testing.T, rr *httptest.ResponseRecorder) map[string]bool {
+	t.Helper()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp ConversationsResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	ids := make(map[string]bool)
+	for _, c := range resp.Conversations {
+		ids[c.ID] = true
+	}
+	return ids
+}
+
+func TestListConversationsFilterByParticipant(t *testing.T) {
+	_, db, router := setupTestHandler()
+	dealershipID := uuid.New().String()
+	userID, customerID, colleagueID := uuid.New().String(), uuid.New().String(), uuid.New().String()
+
+	withCustomer := newConversationWith(db, dealershipID, userID, customerID)
+	withBoth := newConversationWith(db, dealershipID, userID, customerID, colleagueID)
+	withColleague := newConversationWith(db, dealershipID, userID, colleagueID)
+
+	ids := conversationIDs(t, doRequest(router, "GET", "/api/messaging/conversations?participant="+customerID, nil, dealershipID, userID))
+	if len(ids) != 2 || !ids[withCustomer.ID] || !ids[withBoth.ID] {
+		t.Errorf("Expected only the customer's conversations, got %v", ids)
+	}
+
+	ids = conversationIDs(t, doRequest(router, "GET", "/api/messaging/conversations?participant="+colleagueID, nil, dealershipID, userID))
+	if len(ids) != 2 || !ids[withColleague.ID] || !ids[withBoth.ID] {
+		t.Errorf("Expected only the colleague's conversations, got %v", ids)
+	}
+}
+
+func TestListConversationsFilterByAllParticipants(t *testing.T) {
+	_, db, router := setupTestHandler()
+	dealershipID := uuid.New().String()
+	userID, customerID, colleagueID := uuid.New().String(), uuid.New().String(), uuid.New().String()
+
+	newConversationWith(db, dealershipID, userID, customerID)
+	withBoth := newConversationWith(db, dealershipID, userID, customerID, colleagueID)
+	newConversationWith(db, dealershipID, userID, colleagueID)
+
+	for _, query := range []string{
+		"participant=" + customerID + "&participant=" + colleagueID,
+		"participant=" + customerID + "," + colleagueID,
+	} {
+		ids := conversationIDs(t, doRequest(router, "GET", "/api/messaging/conversations?"+query, nil, dealershipID, userID))
+		if len(ids) != 1 || !ids[withBoth.ID] {
+			t.Errorf("Expected only the conversation with both participants for %q, got %v", query, ids)
+		}
+	}
+}
+
+func TestListConversationsFilterByParticipantValidation(t *testing.T) {
+	_, _, router := setupTestHandler()
+	dealershipID, userID := uuid.New().String(), uuid.New().String()
+
+	rr := doRequest(router, "GET", "/api/messaging/conversations?participant=not-a-uuid", nil, dealershipID, userID)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid participant, got %d", rr.Code)
+	}
+
+	query := ""
+	for i := 0; i <= maxParticipantFilters; i++ {
+		query += "&participant=" + uuid.New().String()
+	}
+	rr = doRequest(router, "GET", "/api/messaging/conversations?"+query[1:], nil, dealershipID, userID)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for too many participants, got %d", rr.Code)
+	}
+}
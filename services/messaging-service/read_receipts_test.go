@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func (db *MockDatabase) GetUserPreferences(dealershipID, userID string) (*UserPreferences, error) {
+	if prefs, ok := db.preferences[dealershipID+"/"+userID]; ok {
+		return prefs, nil
+	}
+	return DefaultUserPreferences(dealershipID, userID), nil
+}
+
+func (db *MockDatabase) UpdateUserPreferences(dealershipID, userID string, req UpdatePreferencesRequest) (*UserPreferences, error) {
+	prefs := DefaultUserPreferences(dealershipID, userID)
+	prefs.SendReadReceipts = *req.SendReadReceipts
+	prefs.UpdatedAt = time.Now()
+	db.preferences[dealershipID+"/"+userID] = prefs
+	return prefs, nil
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestReadReceiptsDisabled(t *testing.T) {
+	for _, convType := range []string{"DIRECT", "GROUP"} {
+		t.Run(convType, func(t *testing.T) {
+			handler, db, router := setupTestHandler()
+			dealershipID, reader, sender := uuid.New().String(), uuid.New().String(), uuid.New().String()
+			conv := newTestConversation(db, dealershipID)
+			conv.Type = convType
+
+			msg := &Message{ID: uuid.New().String(), ConversationID: conv.ID, SenderID: sender, Status: "SENT"}
+			db.messages[msg.ID] = msg
+
+			rr := doRequest(router, "PUT", "/api/messaging/preferences", UpdatePreferencesRequest{SendReadReceipts: boolPtr(false)}, dealershipID, reader)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+			}
+
+			rr = doRequest(router, "POST", "/api/messaging/conversations/"+conv.ID+"/read", nil, dealershipID, reader)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+			}
+
+			if containsEvent(drainBroadcasts(handler.hub), WSEventMessageRead) {
+				t.Error("Expected no read event for a user with receipts disabled")
+			}
+			if msg.Status != "SENT" || msg.ReadAt != nil {
+				t.Errorf("Expected the sender not to see the message as read, got status %s", msg.Status)
+			}
+			if _, ok := db.lastRead[conv.ID+"/"+reader]; !ok {
+				t.Error("Expected the reader's own unread count to be cleared")
+			}
+		})
+	}
+}
+
+func TestReadReceiptsEnabledByDefault(t *testing.T) {
+	handler, db, router := setupTestHandler()
+	dealershipID, reader := uuid.New().String(), uuid.New().String()
+	conv := newTestConversation(db, dealershipID)
+
+	msg := &Message{ID: uuid.New().String(), ConversationID: conv.ID, SenderID: uuid.New().String(), Status: "DELIVERED"}
+	db.messages[msg.ID] = msg
+
+	doRequest(router, "POST", "/api/messaging/conversations/"+conv.ID+"/read", nil, dealershipID, reader)
+
+	if !containsEvent(drainBroadcasts(handler.hub), WSEventMessageRead) {
+		t.Error("Expected a read event by default")
+	}
+	if msg.Status != "READ" {
+		t.Errorf("Expected message status READ, got %s", msg.Status)
+	}
+}
+
+func TestPreferences(t *testing.T) {
+	_, _, router := setupTestHandler()
+	dealershipID, userID := uuid.New().String(), uuid.New().String()
+
+	rr := doRequest(router, "GET", "/api/messaging/preferences", nil, dealershipID, userID)
+	var prefs UserPreferences
+	json.Unmarshal(rr.Body.Bytes(), &prefs)
+	if rr.Code != http.StatusOK || !prefs.SendReadReceipts {
+		t.Fatalf("Expected receipts enabled by default, got %d %+v", rr.Code, prefs)
+	}
+
+	doRequest(router, "PUT", "/api/messaging/preferences", UpdatePreferencesRequest{SendReadReceipts: boolPtr(false)}, dealershipID, userID)
+	rr = doRequest(router, "GET", "/api/messaging/preferences", nil, dealershipID, userID)
+	json.Unmarshal(rr.Body.Bytes(), &prefs)
+	if prefs.SendReadReceipts {
+		t.Error("Expected receipts disabled after update")
+	}
+
+	rr = doRequest(router, "PUT", "/api/messaging/preferences", map[string]string{}, dealershipID, userID)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without send_read_receipts, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"autolytiq/shared/logging"
+)
+
+// MessageScheduler periodically dispatches scheduled messages whose send time
+// has passed. Scheduled messages live in the database, so any that come due
+// while the service is down are delivered on the first poll after restart.
+type MessageScheduler struct {
+	db       MessagingDatabase
+	handler  *Handler
+	interval time.Duration
+	logger   *logging.Logger
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+	running  bool
+	mu       sync.Mutex
+}
+
+// NewMessageScheduler creates a new scheduled message dispatcher
+func NewMessageScheduler(db MessagingDatabase, handler *Handler, interval time.Duration, logger *logging.Logger) *MessageScheduler {
+	return &MessageScheduler{
+		db:       db,
+		handler:  handler,
+		interval: interval,
+		logger:   logger,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start begins polling for due messages
+func (s *MessageScheduler) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.mu.Unlock()
+
+	s.logger.WithField("interval", s.interval.String()).Info("Starting scheduled message dispatcher")
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop stops the dispatcher and waits for an in-progress dispatch to finish
+func (s *MessageScheduler) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	s.mu.Unlock()
+
+	close(s.stopChan)
+	s.wg.Wait()
+	s.logger.Info("Scheduled message dispatcher stopped")
+}
+
+func (s *MessageScheduler) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	// Catch up on anything that came due while the service was down
+	s.DispatchDue(time.Now())
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case now := <-ticker.C:
+			s.DispatchDue(now)
+		}
+	}
+}
+
+// DispatchDue sends every scheduled message due at or before now through the
+// normal delivery path and returns the number dispatched
+func (s *MessageScheduler) DispatchDue(now time.Time) int {
+	messages, err := s.db.DispatchDueMessages(now)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to dispatch scheduled messages")
+		return 0
+	}
+
+	for i := range messages {
+		s.handler.deliverMessage(&messages[i])
+	}
+
+	if len(messages) > 0 {
+		s.logger.WithField("count", len(messages)).Info("Dispatched scheduled messages")
+	}
+
+	return len(messages)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestMessageSchedulerDispatchDue(t *testing.T) {
+	handler, db, _ := setupTestHandler()
+	dealershipID, userID := uuid.New().String(), uuid.New().String()
+	conv := newTestConversation(db, dealershipID)
+
+	now := time.Now()
+	due := now.Add(time.Minute)
+	notDue := now.Add(time.Hour)
+	dueMsg, _ := db.CreateMessage(conv.ID, dealershipID, userID, SendMessageRequest{Type: "TEXT", Content: "due", SendAt: &due})
+	pendingMsg, _ := db.CreateMessage(conv.ID, dealershipID, userID, SendMessageRequest{Type: "TEXT", Content: "not due", SendAt: &notDue})
+
+	scheduler := NewMessageScheduler(db, handler, time.Minute, testLogger())
+
+	if n := scheduler.DispatchDue(now); n != 0 {
+		t.Fatalf("Expected nothing dispatched before send time, got %d", n)
+	}
+
+	if n := scheduler.DispatchDue(now.Add(2 * time.Minute)); n != 1 {
+		t.Fatalf("Expected 1 message dispatched, got %d", n)
+	}
+
+	if db.messages[dueMsg.ID].Status != "SENT" {
+		t.Errorf("Expected due message to be SENT, got %s", db.messages[dueMsg.ID].Status)
+	}
+	if db.messages[pendingMsg.ID].Status != MessageStatusScheduled {
+		t.Errorf("Expected future message to remain SCHEDULED, got %s", db.messages[pendingMsg.ID].Status)
+	}
+
+	events := drainBroadcasts(handler.hub)
+	if !containsEvent(events, WSEventMessageSent) {
+		t.Error("Expected dispatched message to be broadcast through the normal send path")
+	}
+
+	if n := scheduler.DispatchDue(now.Add(3 * time.Minute)); n != 0 {
+		t.Errorf("Expected dispatched message not to be sent twice, got %d", n)
+	}
+}
+
+func TestMessageSchedulerStartCatchesUpOverdue(t *testing.T) {
+	handler, db, _ := setupTestHandler()
+	dealershipID, userID := uuid.New().String(), uuid.New().String()
+	conv := newTestConversation(db, dealershipID)
+
+	// Simulate a message that came due while the service was down
+	msg, _ := db.CreateMessage(conv.ID, dealershipID, userID, SendMessageRequest{Type: "TEXT", Content: "overdue"})
+	past := time.Now().Add(-time.Hour)
+	msg.Status = MessageStatusScheduled
+	msg.ScheduledAt = &past
+
+	scheduler := NewMessageScheduler(db, handler, time.Hour, testLogger())
+	scheduler.Start()
+	defer scheduler.Stop()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case <-handler.hub.broadcast:
+			return
+		default:
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	t.Fatal("Expected overdue message to be dispatched on startup")
+}
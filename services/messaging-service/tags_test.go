@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func stringPtr(s string) *string { return &s }
+
+func TestAddConversationTags(t *testing.T) {
+	handler, db, router := setupTestHandler()
+	dealershipID, userID := uuid.New().String(), uuid.New().String()
+	conv := newTestConversation(db, dealershipID)
+
+	rr := doRequest(router, "POST", "/api/messaging/conversations/"+conv.ID+"/tags", AddTagsRequest{
+		Tags: []TagInput{{Name: "  hot   lead ", Color: stringPtr("#ff0000")}, {Name: "service"}},
+	}, dealershipID, userID)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp ConversationTagsEvent
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if len(resp.Tags) != 2 || resp.Tags[0].Name != "hot lead" {
+		t.Fatalf("Expected tags [hot lead service], got %+v", resp.Tags)
+	}
+	if resp.Tags[0].Color == nil || *resp.Tags[0].Color != "#FF0000" {
+		t.Errorf("Expected normalized color #FF0000, got %v", resp.Tags[0].Color)
+	}
+
+	if !containsEvent(drainBroadcasts(handler.hub), WSEventTagsUpdated) {
+		t.Error("Expected tag change to be broadcast")
+	}
+
+	// Re-applying a tag with different casing does not duplicate it
+	doRequest(router, "POST", "/api/messaging/conversations/"+conv.ID+"/tags", AddTagsRequest{
+		Tags: []TagInput{{Name: "Service"}},
+	}, dealershipID, userID)
+	if len(conv.Tags) != 2 {
+		t.Errorf("Expected 2 tags after re-applying, got %+v", conv.Tags)
+	}
+
+	rr = doRequest(router, "GET", "/api/messaging/tags", nil, dealershipID, userID)
+	var vocab TagsResponse
+	json.Unmarshal(rr.Body.Bytes(), &vocab)
+	if vocab.Total != 2 {
+		t.Errorf("Expected 2 tags in the dealership vocabulary, got %+v", vocab)
+	}
+
+	rr = doRequest(router, "GET", "/api/messaging/tags", nil, uuid.New().String(), userID)
+	json.Unmarshal(rr.Body.Bytes(), &vocab)
+	if vocab.Total != 0 || vocab.Tags == nil {
+		t.Errorf("Expected an empty vocabulary for another dealership, got %+v", vocab)
+	}
+}
+
+func TestAddConversationTagsValidation(t *testing.T) {
+	_, db, router := setupTestHandler()
+	dealershipID, userID := uuid.New().String(), uuid.New().String()
+	conv := newTestConversation(db, dealershipID)
+
+	tests := []struct {
+		name string
+		tags []TagInput
+	}{
+		{"empty", nil},
+		{"blank name", []TagInput{{Name: "   "}}},
+		{"bad color", []TagInput{{Name: "vip", Color: stringPtr("red")}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := doRequest(router, "POST", "/api/messaging/conversations/"+conv.ID+"/tags", AddTagsRequest{Tags: tt.tags}, dealershipID, userID)
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d", rr.Code)
+			}
+		})
+	}
+
+	rr := doRequest(router, "POST", "/api/messaging/conversations/"+uuid.New().String()+"/tags", AddTagsRequest{
+		Tags: []TagInput{{Name: "vip"}},
+	}, dealershipID, userID)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown conversation, got %d", rr.Code)
+	}
+}
+
+func TestListConversationsFilterByTag(t *testing.T) {
+	_, db, router := setupTestHandler()
+	dealershipID, userID := uuid.New().String(), uuid.New().String()
+	tagged := newTestConversation(db, dealershipID)
+	newTestConversation(db, dealershipID)
+
+	doRequest(router, "POST", "/api/messaging/conversations/"+tagged.ID+"/tags", AddTagsRequest{
+		Tags: []TagInput{{Name: "complaint"}},
+	}, dealershipID, userID)
+
+	rr := doRequest(router, "GET", "/api/messaging/conversations?tag=Complaint", nil, dealershipID, userID)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp ConversationsResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if len(resp.Conversations) != 1 || resp.Conversations[0].ID != tagged.ID {
+		t.Errorf("Expected only the tagged conversation, got %+v", resp.Conversations)
+	}
+}
+
+func TestBulkTagConversations(t *testing.T) {
+	handler, db, router := setupTestHandler()
+	dealershipID, userID := uuid.New().String(), uuid.New().String()
+	first := newTestConversation(db, dealershipID)
+	second := newTestConversation(db, dealershipID)
+	other := newTestConversation(db, uuid.New().String())
+
+	rr := doRequest(router, "POST", "/api/messaging/conversations/bulk-tags", BulkTagRequest{
+		ConversationIDs: []string{first.ID, second.ID, other.ID},
+		Tags:            []TagInput{{Name: "hot lead"}},
+	}, dealershipID, userID)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp BulkTagResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if len(resp.Tagged) != 2 || len(resp.NotFound) != 1 || resp.NotFound[0] != other.ID {
+		t.Errorf("Expected 2 tagged and the other dealership's conversation not found, got %+v", resp)
+	}
+	if len(other.Tags) != 0 {
+		t.Error("Expected another dealership's conversation to be left untagged")
+	}
+
+	events := 0
+	for _, e := range drainBroadcasts(handler.hub) {
+		if e == WSEventTagsUpdated {
+			events++
+		}
+	}
+	if events != 2 {
+		t.Errorf("Expected a tag broadcast per tagged conversation, got %d", events)
+	}
+
+	rr = doRequest(router, "POST", "/api/messaging/conversations/bulk-tags", BulkTagRequest{
+		ConversationIDs: []string{"not-a-uuid"},
+		Tags:            []TagInput{{Name: "hot lead"}},
+	}, dealershipID, userID)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid conversation ID, got %d", rr.Code)
+	}
+}
+
+func TestRemoveConversationTag(t *testing.T) {
+	handler, db, router := setupTestHandler()
+	dealershipID, userID := uuid.New().String(), uuid.New().String()
+	conv := newTestConversation(db, dealershipID)
+
+	doRequest(router, "POST", "/api/messaging/conversations/"+conv.ID+"/tags", AddTagsRequest{
+		Tags: []TagInput{{Name: "hot lead"}, {Name: "service"}},
+	}, dealershipID, userID)
+	drainBroadcasts(handler.hub)
+
+	rr := doRequest(router, "DELETE", "/api/messaging/conversations/"+conv.ID+"/tags/Hot%20Lead", nil, dealershipID, userID)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp ConversationTagsEvent
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if len(resp.Tags) != 1 || resp.Tags[0].Name != "service" {
+		t.Errorf("Expected only service to remain, got %+v", resp.Tags)
+	}
+	if !containsEvent(drainBroadcasts(handler.hub), WSEventTagsUpdated) {
+		t.Error("Expected untagging to be broadcast")
+	}
+
+	rr = doRequest(router, "DELETE", "/api/messaging/conversations/"+conv.ID+"/tags/hot%20lead", nil, dealershipID, userID)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a tag not on the conversation, got %d", rr.Code)
+	}
+
+	// The tag stays in the dealership vocabulary
+	rr = doRequest(router, "GET", "/api/messaging/tags", nil, dealershipID, userID)
+	var vocab TagsResponse
+	json.Unmarshal(rr.Body.Bytes(), &vocab)
+	if vocab.Total != 2 {
+		t.Errorf("Expected removed tag to stay in the vocabulary, got %+v", vocab)
+	}
+}
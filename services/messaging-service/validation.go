@@ -2,9 +2,11 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"regexp"
 	"strings"
+	"time"
 )
 
 // ValidationError represents a single validation error
@@ -25,9 +27,28 @@ type ValidationErrorResponse struct {
 	Details []ValidationError `json:"details,omitempty"`
 }
 
+// maxScheduleAhead is how far in the future a message may be scheduled
+const maxScheduleAhead = 365 * 24 * time.Hour
+
+// maxAnnouncementLength is the longest announcement accepted
+const maxAnnouncementLength = 5000
+
+// Tagging limits
+const (
+	maxTagNameLength        = 50
+	maxTagsPerRequest       = 10
+	maxBulkTagConversations = 100
+)
+
+// maxParticipantFilters is the most participants a conversation list can
+// be filtered by
+const maxParticipantFilters = 10
+
 var (
 	uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
 
+	tagColorRegex = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
 	validMessageTypes = map[string]bool{
 		"TEXT":     true,
 		"IMAGE":    true,
@@ -224,6 +245,22 @@ func (r *SendMessageRequest) Validate() *ValidationErrors {
 		}
 	}
 
+	// Send-at validation (optional, must be in the future)
+	if r.SendAt != nil {
+		now := time.Now()
+		if !r.SendAt.After(now) {
+			errors = append(errors, ValidationError{
+				Field:   "send_at",
+				Message: "Scheduled send time must be in the future",
+			})
+		} else if r.SendAt.After(now.Add(maxScheduleAhead)) {
+			errors = append(errors, ValidationError{
+				Field:   "send_at",
+				Message: "Scheduled send time must be within 365 days",
+			})
+		}
+	}
+
 	if len(errors) > 0 {
 		return &ValidationErrors{Errors: errors}
 	}
@@ -343,6 +380,174 @@ func (r *AddParticipantRequest) Sanitize() {
 	}
 }
 
+// validateTagInputs validates the tags in a tagging request
+func validateTagInputs(tags []TagInput) []ValidationError {
+	var errors []ValidationError
+
+	if len(tags) == 0 {
+		errors = append(errors, ValidationError{
+			Field:   "tags",
+			Message: "At least one tag is required",
+		})
+	} else if len(tags) > maxTagsPerRequest {
+		errors = append(errors, ValidationError{
+			Field:   "tags",
+			Message: fmt.Sprintf("At most %d tags can be applied at once", maxTagsPerRequest),
+		})
+	}
+
+	for i, tag := range tags {
+		if tag.Name == "" {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("tags[%d].name", i),
+				Message: "Tag name is required",
+			})
+		} else if len(tag.Name) > maxTagNameLength {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("tags[%d].name", i),
+				Message: fmt.Sprintf("Tag name must be %d characters or less", maxTagNameLength),
+			})
+		}
+
+		if tag.Color != nil && !tagColorRegex.MatchString(*tag.Color) {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("tags[%d].color", i),
+				Message: "Color must be a hex value like #FF5733",
+			})
+		}
+	}
+
+	return errors
+}
+
+// sanitizeTagInputs trims tag names and normalizes colors
+func sanitizeTagInputs(tags []TagInput) {
+	for i := range tags {
+		tags[i].Name = strings.Join(strings.Fields(tags[i].Name), " ")
+		if tags[i].Color != nil {
+			color := strings.ToUpper(strings.TrimSpace(*tags[i].Color))
+			tags[i].Color = &color
+		}
+	}
+}
+
+// Validate validates AddTagsRequest
+func (r *AddTagsRequest) Validate() *ValidationErrors {
+	if errors := validateTagInputs(r.Tags); len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize sanitizes AddTagsRequest
+func (r *AddTagsRequest) Sanitize() {
+	sanitizeTagInputs(r.Tags)
+}
+
+// Validate validates BulkTagRequest
+func (r *BulkTagRequest) Validate() *ValidationErrors {
+	var errors []ValidationError
+
+	if len(r.ConversationIDs) == 0 {
+		errors = append(errors, ValidationError{
+			Field:   "conversation_ids",
+			Message: "At least one conversation ID is required",
+		})
+	} else if len(r.ConversationIDs) > maxBulkTagConversations {
+		errors = append(errors, ValidationError{
+			Field:   "conversation_ids",
+			Message: fmt.Sprintf("At most %d conversations can be tagged at once", maxBulkTagConversations),
+		})
+	}
+
+	for i, id := range r.ConversationIDs {
+		if !uuidRegex.MatchString(id) {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("conversation_ids[%d]", i),
+				Message: "Must be a valid UUID",
+			})
+		}
+	}
+
+	errors = append(errors, validateTagInputs(r.Tags)...)
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize sanitizes BulkTagRequest
+func (r *BulkTagRequest) Sanitize() {
+	for i := range r.ConversationIDs {
+		r.ConversationIDs[i] = strings.TrimSpace(r.ConversationIDs[i])
+	}
+	sanitizeTagInputs(r.Tags)
+}
+
+// Validate validates UpdatePreferencesRequest
+func (r *UpdatePreferencesRequest) Validate() *ValidationErrors {
+	if r.SendReadReceipts == nil {
+		return &ValidationErrors{Errors: []ValidationError{{
+			Field:   "send_read_receipts",
+			Message: "send_read_receipts is required",
+		}}}
+	}
+	return nil
+}
+
+// Sanitize sanitizes UpdatePreferencesRequest (nothing to sanitize)
+func (r *UpdatePreferencesRequest) Sanitize() {}
+
+// Validate validates CreateAnnouncementRequest
+func (r *CreateAnnouncementRequest) Validate() *ValidationErrors {
+	var errors []ValidationError
+
+	if r.Content == "" {
+		errors = append(errors, ValidationError{
+			Field:   "content",
+			Message: "Content is required",
+		})
+	} else if len(r.Content) > maxAnnouncementLength {
+		errors = append(errors, ValidationError{
+			Field:   "content",
+			Message: fmt.Sprintf("Content must be %d characters or less", maxAnnouncementLength),
+		})
+	}
+
+	for _, role := range r.TargetRoles {
+		if role == "" {
+			errors = append(errors, ValidationError{
+				Field:   "target_roles",
+				Message: "Roles cannot be empty",
+			})
+			break
+		}
+	}
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize sanitizes CreateAnnouncementRequest. Roles are matched
+// case-insensitively and duplicates dropped.
+func (r *CreateAnnouncementRequest) Sanitize() {
+	r.Content = strings.TrimSpace(r.Content)
+
+	seen := make(map[string]bool)
+	roles := make([]string, 0, len(r.TargetRoles))
+	for _, role := range r.TargetRoles {
+		role = strings.ToLower(strings.TrimSpace(role))
+		if !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+	r.TargetRoles = roles
+}
+
 // TypingRequest is the request body for typing indicators
 type TypingRequest struct {
 	IsTyping bool `json:"is_typing"`
@@ -356,6 +561,44 @@ func (r *TypingRequest) Validate() *ValidationErrors {
 // Sanitize sanitizes TypingRequest (nothing to sanitize)
 func (r *TypingRequest) Sanitize() {}
 
+// parseParticipantFilter reads the participant query parameters, each a user
+// ID or a comma-separated list of them, removing duplicates
+func parseParticipantFilter(values []string) ([]string, *ValidationErrors) {
+	var ids []string
+	var errors []ValidationError
+	seen := make(map[string]bool)
+
+	for _, value := range values {
+		for _, id := range strings.Split(value, ",") {
+			id = strings.ToLower(strings.TrimSpace(id))
+			if id == "" || seen[id] {
+				continue
+			}
+			seen[id] = true
+			if !uuidRegex.MatchString(id) {
+				errors = append(errors, ValidationError{
+					Field:   "participant",
+					Message: fmt.Sprintf("%q is not a valid UUID", id),
+				})
+				continue
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	if len(seen) > maxParticipantFilters {
+		errors = append(errors, ValidationError{
+			Field:   "participant",
+			Message: fmt.Sprintf("At most %d participants can be filtered by", maxParticipantFilters),
+		})
+	}
+
+	if len(errors) > 0 {
+		return nil, &ValidationErrors{Errors: errors}
+	}
+	return ids, nil
+}
+
 // respondValidationErrorMessaging writes a validation error response
 func respondValidationErrorMessaging(w http.ResponseWriter, errors *ValidationErrors) {
 	w.Header().Set("Content-Type", "application/json")
@@ -19,19 +19,18 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-// Hub manages WebSocket connections and message routing
+// Hub manages WebSocket connections and message routing. Every broadcast is
+// addressed to one dealership and only reaches that dealership's clients.
 type Hub struct {
-	// Registered clients by user ID
-	clients map[string]map[*Client]bool
+	// Registered clients by dealership ID, then user ID
+	clients map[string]map[string]map[*Client]bool
 
-	// Conversation rooms: conversation_id -> clients
-	rooms map[string]map[*Client]bool
+	// Conversation rooms: dealership and conversation ID -> clients
+	rooms map[roomKey]map[*Client]bool
 
-	// Presence tracking: user_id -> presence status
-	presence map[string]string
-
-	// Typing indicators: conversation_id -> user_ids
-	typing map[string]map[string]time.Time
+	// Typing indicators: dealership and conversation ID -> user_id -> state
+	typing        map[roomKey]map[string]*typingState
+	typingTimeout time.Duration
 
 	// Channels
 	register   chan *Client
@@ -40,8 +39,38 @@ type Hub struct {
 
 	mu     sync.RWMutex
 	logger *logging.Logger
+
+	// done is closed by Close to stop the hub and disconnect its clients
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
+// roomKey identifies a conversation within a dealership, so a client can't
+// join another dealership's conversation by its ID
+type roomKey struct {
+	dealershipID   string
+	conversationID string
+}
+
+// typingState is a user's typing indicator in one conversation
+type typingState struct {
+	userName string
+	lastSent time.Time   // When TYPING_START was last broadcast
+	expires  time.Time   // When TYPING_STOP is due without another event
+	timer    *time.Timer // Sends TYPING_STOP at expires
+}
+
+const (
+	// DefaultTypingTimeout is how long a user shows as typing after their
+	// last typing event
+	DefaultTypingTimeout = 5 * time.Second
+
+	// typingDebounce is how long typing events are coalesced after a
+	// TYPING_START is broadcast. Clients drop an indicator that isn't
+	// refreshed within 5s, so a user still typing is broadcast again.
+	typingDebounce = 3 * time.Second
+)
+
 // Client represents a single WebSocket connection
 type Client struct {
 	hub           *Hub
@@ -56,34 +85,53 @@ type Client struct {
 
 // BroadcastMessage represents a message to broadcast
 type BroadcastMessage struct {
-	ConversationID string
-	UserID         string // Target specific user (empty for all in conversation)
+	DealershipID   string   // Required; messages never leave their dealership
+	ConversationID string   // Target a conversation (empty for the whole dealership)
+	UserID         string   // Target specific user (empty for all in conversation)
+	UserIDs        []string // Target several users, outside of any conversation
 	Message        []byte
 	ExcludeUserID  string // Exclude this user from broadcast
 }
 
-// NewHub creates a new Hub
-func NewHub(logger *logging.Logger) *Hub {
+// NewHub creates a new Hub. Users stop showing as typing once they've sent
+// no typing event for typingTimeout.
+func NewHub(typingTimeout time.Duration, logger *logging.Logger) *Hub {
 	return &Hub{
-		clients:    make(map[string]map[*Client]bool),
-		rooms:      make(map[string]map[*Client]bool),
-		presence:   make(map[string]string),
-		typing:     make(map[string]map[string]time.Time),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		broadcast:  make(chan *BroadcastMessage, 256),
-		logger:     logger,
+		clients:       make(map[string]map[string]map[*Client]bool),
+		rooms:         make(map[roomKey]map[*Client]bool),
+		typing:        make(map[roomKey]map[string]*typingState),
+		typingTimeout: typingTimeout,
+		register:      make(chan *Client),
+		unregister:    make(chan *Client),
+		broadcast:     make(chan *BroadcastMessage, 256),
+		logger:        logger,
+		done:          make(chan struct{}),
 	}
 }
 
+// Close stops the hub and sends every client a close frame, for shutdown.
+// Broadcasts after Close are dropped.
+func (h *Hub) Close() {
+	h.closeOnce.Do(func() {
+		close(h.done)
+
+		h.mu.Lock()
+		for key, users := range h.typing {
+			for userID := range users {
+				h.clearTyping(key, userID)
+			}
+		}
+		h.mu.Unlock()
+	})
+}
+
 // Run starts the hub's event loop
 func (h *Hub) Run() {
-	// Typing cleanup ticker
-	typingCleanup := time.NewTicker(5 * time.Second)
-	defer typingCleanup.Stop()
-
 	for {
 		select {
+		case <-h.done:
+			return
+
 		case client := <-h.register:
 			h.registerClient(client)
 
@@ -92,25 +140,32 @@ func (h *Hub) Run() {
 
 		case msg := <-h.broadcast:
 			h.broadcastMessage(msg)
-
-		case <-typingCleanup.C:
-			h.cleanupTypingIndicators()
 		}
 	}
 }
 
+// queue hands a message to the event loop, or drops it once the hub is closed
+func (h *Hub) queue(msg *BroadcastMessage) {
+	select {
+	case h.broadcast <- msg:
+	case <-h.done:
+	}
+}
+
 func (h *Hub) registerClient(client *Client) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	// Add to user's client set
-	if h.clients[client.userID] == nil {
-		h.clients[client.userID] = make(map[*Client]bool)
+	// Add to the user's client set within their dealership
+	users := h.clients[client.dealershipID]
+	if users == nil {
+		users = make(map[string]map[*Client]bool)
+		h.clients[client.dealershipID] = users
 	}
-	h.clients[client.userID][client] = true
-
-	// Update presence
-	h.presence[client.userID] = "ONLINE"
+	if users[client.userID] == nil {
+		users[client.userID] = make(map[*Client]bool)
+	}
+	users[client.userID][client] = true
 
 	h.logger.WithFields(map[string]interface{}{
 		"user_id":       client.userID,
@@ -120,29 +175,57 @@ func (h *Hub) registerClient(client *Client) {
 
 func (h *Hub) unregisterClient(client *Client) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
 
 	// Remove from user's client set
-	if clients, ok := h.clients[client.userID]; ok {
-		delete(clients, client)
-		if len(clients) == 0 {
-			delete(h.clients, client.userID)
-			h.presence[client.userID] = "OFFLINE"
+	disconnected := false
+	if users, ok := h.clients[client.dealershipID]; ok {
+		if clients, ok := users[client.userID]; ok {
+			delete(clients, client)
+			if len(clients) == 0 {
+				delete(users, client.userID)
+				disconnected = true
+			}
+		}
+		if len(users) == 0 {
+			delete(h.clients, client.dealershipID)
+		}
+	}
+
+	// A user who closed their last connection has stopped typing
+	var typingStops []*BroadcastMessage
+	if disconnected {
+		for key, users := range h.typing {
+			if state, ok := users[client.userID]; ok && key.dealershipID == client.dealershipID {
+				typingStops = append(typingStops, typingMessage(key, client.userID, state.userName, false))
+				h.clearTyping(key, client.userID)
+			}
 		}
 	}
 
 	// Remove from all conversation rooms
 	client.mu.RLock()
 	for convID := range client.conversations {
-		if room, ok := h.rooms[convID]; ok {
+		key := roomKey{client.dealershipID, convID}
+		if room, ok := h.rooms[key]; ok {
 			delete(room, client)
+			if len(room) == 0 {
+				delete(h.rooms, key)
+			}
 		}
 	}
 	client.mu.RUnlock()
 
 	close(client.send)
+	h.mu.Unlock()
+
+	// Sent directly, since queueing from the event loop could block it
+	for _, msg := range typingStops {
+		h.broadcastMessage(msg)
+	}
+
 	h.logger.WithFields(map[string]interface{}{
-		"user_id": client.userID,
+		"user_id":       client.userID,
+		"dealership_id": client.dealershipID,
 	}).Debug("WebSocket client unregistered")
 }
 
@@ -150,82 +233,63 @@ func (h *Hub) broadcastMessage(msg *BroadcastMessage) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
+	if msg.DealershipID == "" {
+		h.logger.Warn("Dropped WebSocket broadcast without a dealership")
+		return
+	}
+	users := h.clients[msg.DealershipID]
+
+	// If targeting several users
+	if len(msg.UserIDs) > 0 {
+		for _, userID := range msg.UserIDs {
+			for client := range users[userID] {
+				client.deliver(msg.Message)
+			}
+		}
+		return
+	}
+
 	// If targeting specific user
 	if msg.UserID != "" {
-		if clients, ok := h.clients[msg.UserID]; ok {
-			for client := range clients {
-				select {
-				case client.send <- msg.Message:
-				default:
-					// Buffer full, client will be cleaned up
-				}
-			}
+		for client := range users[msg.UserID] {
+			client.deliver(msg.Message)
 		}
 		return
 	}
 
-	// Broadcast to conversation room
-	if room, ok := h.rooms[msg.ConversationID]; ok {
-		for client := range room {
-			if client.userID == msg.ExcludeUserID {
+	// If targeting the whole dealership
+	if msg.ConversationID == "" {
+		for userID, clients := range users {
+			if userID == msg.ExcludeUserID {
 				continue
 			}
-			select {
-			case client.send <- msg.Message:
-			default:
-				// Buffer full
+			for client := range clients {
+				client.deliver(msg.Message)
 			}
 		}
+		return
 	}
-}
 
-func (h *Hub) cleanupTypingIndicators() {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
-	now := time.Now()
-	for convID, users := range h.typing {
-		for userID, timestamp := range users {
-			if now.Sub(timestamp) > 5*time.Second {
-				delete(users, userID)
-				// Broadcast typing stop
-				h.broadcastTypingStop(convID, userID)
-			}
-		}
-		if len(users) == 0 {
-			delete(h.typing, convID)
+	// Broadcast to conversation room
+	for client := range h.rooms[roomKey{msg.DealershipID, msg.ConversationID}] {
+		if client.userID == msg.ExcludeUserID {
+			continue
 		}
+		client.deliver(msg.Message)
 	}
 }
 
-func (h *Hub) broadcastTypingStop(conversationID, userID string) {
-	msg := WSMessage{
-		Type:           WSEventTypingStop,
-		ConversationID: &conversationID,
-		Timestamp:      time.Now(),
-	}
-	data, _ := json.Marshal(map[string]interface{}{
-		"user_id": userID,
-	})
-	msg.Data = data
-
-	jsonData, _ := json.Marshal(msg)
-	h.broadcast <- &BroadcastMessage{
-		ConversationID: conversationID,
-		Message:        jsonData,
-		ExcludeUserID:  userID,
-	}
-}
-
-// SubscribeToConversation adds client to a conversation room
+// SubscribeToConversation adds client to a conversation room in its
+// dealership
 func (h *Hub) SubscribeToConversation(client *Client, conversationID string) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	if h.rooms[conversationID] == nil {
-		h.rooms[conversationID] = make(map[*Client]bool)
+	key := roomKey{client.dealershipID, conversationID}
+	if h.rooms[key] == nil {
+		h.rooms[key] = make(map[*Client]bool)
 	}
-	h.rooms[conversationID][client] = true
+	h.rooms[key][client] = true
 
 	client.mu.Lock()
 	client.conversations[conversationID] = true
@@ -233,6 +297,7 @@ func (h *Hub) SubscribeToConversation(client *Client, conversationID string) {
 
 	h.logger.WithFields(map[string]interface{}{
 		"user_id":         client.userID,
+		"dealership_id":   client.dealershipID,
 		"conversation_id": conversationID,
 	}).Debug("Client subscribed to conversation")
 }
@@ -242,8 +307,12 @@ func (h *Hub) UnsubscribeFromConversation(client *Client, conversationID string)
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	if room, ok := h.rooms[conversationID]; ok {
+	key := roomKey{client.dealershipID, conversationID}
+	if room, ok := h.rooms[key]; ok {
 		delete(room, client)
+		if len(room) == 0 {
+			delete(h.rooms, key)
+		}
 	}
 
 	client.mu.Lock()
@@ -251,24 +320,83 @@ func (h *Hub) UnsubscribeFromConversation(client *Client, conversationID string)
 	client.mu.Unlock()
 }
 
-// SetTyping sets typing indicator for a user in a conversation
-func (h *Hub) SetTyping(conversationID, userID, userName string, isTyping bool) {
+// SetTyping sets typing indicator for a user in a conversation. Typing
+// events within typingDebounce of the last broadcast are coalesced, and
+// TYPING_STOP is broadcast once the user has been quiet for the typing
+// timeout.
+func (h *Hub) SetTyping(dealershipID, conversationID, userID, userName string, isTyping bool) {
+	key := roomKey{dealershipID, conversationID}
+	now := time.Now()
+
 	h.mu.Lock()
+	state := h.typing[key][userID]
 
-	if isTyping {
-		if h.typing[conversationID] == nil {
-			h.typing[conversationID] = make(map[string]time.Time)
+	if !isTyping {
+		if state == nil {
+			// Not typing, so there is nothing to stop
+			h.mu.Unlock()
+			return
 		}
-		h.typing[conversationID][userID] = time.Now()
-	} else {
-		if users, ok := h.typing[conversationID]; ok {
-			delete(users, userID)
+		h.clearTyping(key, userID)
+		h.mu.Unlock()
+		h.queue(typingMessage(key, userID, userName, false))
+		return
+	}
+
+	if state == nil {
+		state = &typingState{}
+		state.timer = time.AfterFunc(h.typingTimeout, func() { h.expireTyping(key, userID, state) })
+		if h.typing[key] == nil {
+			h.typing[key] = make(map[string]*typingState)
 		}
+		h.typing[key][userID] = state
+	} else {
+		state.timer.Reset(h.typingTimeout)
 	}
+	state.expires = now.Add(h.typingTimeout)
 
+	if !state.lastSent.IsZero() && now.Sub(state.lastSent) < typingDebounce {
+		h.mu.Unlock()
+		return
+	}
+	state.userName = userName
+	state.lastSent = now
 	h.mu.Unlock()
 
-	// Broadcast typing event
+	h.queue(typingMessage(key, userID, userName, true))
+}
+
+// expireTyping broadcasts TYPING_STOP for a user who went quiet
+func (h *Hub) expireTyping(key roomKey, userID string, state *typingState) {
+	h.mu.Lock()
+	if h.typing[key][userID] != state || time.Now().Before(state.expires) {
+		// Already stopped, or typed again as the timer fired
+		h.mu.Unlock()
+		return
+	}
+	h.clearTyping(key, userID)
+	userName := state.userName
+	h.mu.Unlock()
+
+	h.queue(typingMessage(key, userID, userName, false))
+}
+
+// clearTyping removes a user's typing indicator and stops its timer. The
+// caller must hold h.mu.
+func (h *Hub) clearTyping(key roomKey, userID string) {
+	users := h.typing[key]
+	if state, ok := users[userID]; ok {
+		state.timer.Stop()
+		delete(users, userID)
+	}
+	if len(users) == 0 {
+		delete(h.typing, key)
+	}
+}
+
+// typingMessage builds the typing event for a user, which is sent to the
+// rest of the conversation
+func typingMessage(key roomKey, userID, userName string, isTyping bool) *BroadcastMessage {
 	eventType := WSEventTypingStart
 	if !isTyping {
 		eventType = WSEventTypingStop
@@ -276,11 +404,11 @@ func (h *Hub) SetTyping(conversationID, userID, userName string, isTyping bool)
 
 	msg := WSMessage{
 		Type:           eventType,
-		ConversationID: &conversationID,
+		ConversationID: &key.conversationID,
 		Timestamp:      time.Now(),
 	}
 	data, _ := json.Marshal(TypingIndicator{
-		ConversationID: conversationID,
+		ConversationID: key.conversationID,
 		UserID:         userID,
 		UserName:       userName,
 		IsTyping:       isTyping,
@@ -289,15 +417,34 @@ func (h *Hub) SetTyping(conversationID, userID, userName string, isTyping bool)
 	msg.Data = data
 
 	jsonData, _ := json.Marshal(msg)
-	h.broadcast <- &BroadcastMessage{
-		ConversationID: conversationID,
+	return &BroadcastMessage{
+		DealershipID:   key.dealershipID,
+		ConversationID: key.conversationID,
 		Message:        jsonData,
 		ExcludeUserID:  userID,
 	}
 }
 
+// BroadcastToDealership sends a message to every client in a dealership
+func (h *Hub) BroadcastToDealership(dealershipID string, eventType string, data interface{}) {
+	msg := WSMessage{
+		Type:      eventType,
+		Timestamp: time.Now(),
+	}
+	if data != nil {
+		jsonData, _ := json.Marshal(data)
+		msg.Data = jsonData
+	}
+
+	msgBytes, _ := json.Marshal(msg)
+	h.queue(&BroadcastMessage{
+		DealershipID: dealershipID,
+		Message:      msgBytes,
+	})
+}
+
 // BroadcastToConversation sends a message to all clients in a conversation
-func (h *Hub) BroadcastToConversation(conversationID string, eventType string, data interface{}, excludeUserID string) {
+func (h *Hub) BroadcastToConversation(dealershipID, conversationID string, eventType string, data interface{}, excludeUserID string) {
 	msg := WSMessage{
 		Type:           eventType,
 		ConversationID: &conversationID,
@@ -309,15 +456,16 @@ func (h *Hub) BroadcastToConversation(conversationID string, eventType string, d
 	}
 
 	msgBytes, _ := json.Marshal(msg)
-	h.broadcast <- &BroadcastMessage{
+	h.queue(&BroadcastMessage{
+		DealershipID:   dealershipID,
 		ConversationID: conversationID,
 		Message:        msgBytes,
 		ExcludeUserID:  excludeUserID,
-	}
+	})
 }
 
 // BroadcastToUser sends a message to a specific user
-func (h *Hub) BroadcastToUser(userID string, eventType string, data interface{}) {
+func (h *Hub) BroadcastToUser(dealershipID, userID string, eventType string, data interface{}) {
 	msg := WSMessage{
 		Type:      eventType,
 		Timestamp: time.Now(),
@@ -328,30 +476,54 @@ func (h *Hub) BroadcastToUser(userID string, eventType string, data interface{})
 	}
 
 	msgBytes, _ := json.Marshal(msg)
-	h.broadcast <- &BroadcastMessage{
-		UserID:  userID,
-		Message: msgBytes,
+	h.queue(&BroadcastMessage{
+		DealershipID: dealershipID,
+		UserID:       userID,
+		Message:      msgBytes,
+	})
+}
+
+// BroadcastToUsers sends one message to each of several users
+func (h *Hub) BroadcastToUsers(dealershipID string, userIDs []string, eventType string, data interface{}) {
+	if len(userIDs) == 0 {
+		return
 	}
+
+	msg := WSMessage{
+		Type:      eventType,
+		Timestamp: time.Now(),
+	}
+	if data != nil {
+		jsonData, _ := json.Marshal(data)
+		msg.Data = jsonData
+	}
+
+	msgBytes, _ := json.Marshal(msg)
+	h.queue(&BroadcastMessage{
+		DealershipID: dealershipID,
+		UserIDs:      userIDs,
+		Message:      msgBytes,
+	})
 }
 
-// GetUserPresence returns the presence status of a user
-func (h *Hub) GetUserPresence(userID string) string {
+// GetUserPresence returns the presence status of a user in a dealership
+func (h *Hub) GetUserPresence(dealershipID, userID string) string {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	if presence, ok := h.presence[userID]; ok {
-		return presence
+	if len(h.clients[dealershipID][userID]) > 0 {
+		return "ONLINE"
 	}
 	return "OFFLINE"
 }
 
 // GetTypingUsers returns users currently typing in a conversation
-func (h *Hub) GetTypingUsers(conversationID string) []string {
+func (h *Hub) GetTypingUsers(dealershipID, conversationID string) []string {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
 	var users []string
-	if typing, ok := h.typing[conversationID]; ok {
+	if typing, ok := h.typing[roomKey{dealershipID, conversationID}]; ok {
 		for userID := range typing {
 			users = append(users, userID)
 		}
@@ -361,9 +533,22 @@ func (h *Hub) GetTypingUsers(conversationID string) []string {
 
 // Client methods
 
+// deliver queues a message for the client, dropping it if the client's
+// buffer is full
+func (c *Client) deliver(message []byte) {
+	select {
+	case c.send <- message:
+	default:
+		// Buffer full, client will be cleaned up
+	}
+}
+
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.done:
+		}
 		c.conn.Close()
 	}()
 
@@ -425,6 +610,11 @@ func (c *Client) writePump() {
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
+
+		case <-c.hub.done:
+			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"))
+			return
 		}
 	}
 }
@@ -455,7 +645,7 @@ func (c *Client) handleMessage(message []byte) {
 	case "TYPING":
 		if msg.ConversationID != "" {
 			// Get user name (would normally come from DB or context)
-			c.hub.SetTyping(msg.ConversationID, c.userID, "User", msg.IsTyping)
+			c.hub.SetTyping(c.dealershipID, msg.ConversationID, c.userID, "User", msg.IsTyping)
 		}
 
 	case "PING":
@@ -466,13 +656,15 @@ func (c *Client) handleMessage(message []byte) {
 	}
 }
 
-// ServeWs handles WebSocket connection requests
+// ServeWs handles WebSocket connection requests. The gateway authenticates
+// the upgrade and passes the token's user and dealership, which are bound to
+// the connection for its lifetime.
 func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
 	userID := r.Header.Get("X-User-ID")
 	dealershipID := r.Header.Get("X-Dealership-ID")
 
-	if userID == "" {
-		http.Error(w, "Missing user ID", http.StatusBadRequest)
+	if userID == "" || dealershipID == "" {
+		respondError(w, http.StatusUnauthorized, "Missing user or dealership context")
 		return
 	}
 
@@ -492,7 +684,12 @@ func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
 		logger:        hub.logger,
 	}
 
-	hub.register <- client
+	select {
+	case hub.register <- client:
+	case <-hub.done:
+		conn.Close()
+		return
+	}
 
 	go client.writePump()
 	go client.readPump()
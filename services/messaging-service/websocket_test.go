@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestHubCloseDisconnectsClients(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	hub := NewHub(DefaultTypingTimeout, testLogger())
+	go hub.Run()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWs(hub, w, r)
+	}))
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), http.Header{"X-User-ID": {"user-1"}, "X-Dealership-ID": {"dealer-1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hub.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("Expected a going away close frame, got %v", err)
+	}
+	conn.Close()
+	server.Close()
+
+	// Broadcasts after Close must not block
+	hub.BroadcastToUser("dealer-1", "user-1", WSEventMessageSent, nil)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the hub and client goroutines to exit, %d left running", runtime.NumGoroutine()-before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServeWsRequiresIdentity(t *testing.T) {
+	hub := NewHub(DefaultTypingTimeout, testLogger())
+	go hub.Run()
+	defer hub.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWs(hub, w, r)
+	}))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	for _, header := range []http.Header{
+		nil,
+		{"X-User-ID": {"user-1"}},
+		{"X-Dealership-ID": {"dealer-1"}},
+	} {
+		_, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+		if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for headers %v, got %v", header, resp)
+		}
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"X-User-ID": {"user-1"}, "X-Dealership-ID": {"dealer-1"}})
+	if err != nil {
+		t.Fatalf("Expected the upgrade to be accepted: %v", err)
+	}
+	defer conn.Close()
+
+	// The connection is bound to the gateway's user and dealership
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		hub.mu.RLock()
+		var bound *Client
+		for client := range hub.clients["dealer-1"]["user-1"] {
+			bound = client
+		}
+		hub.mu.RUnlock()
+		if bound != nil {
+			if bound.dealershipID != "dealer-1" {
+				t.Errorf("Expected the connection bound to dealer-1, got %q", bound.dealershipID)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the client to be registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitForHub waits until cond, which is called with the hub locked, holds
+func waitForHub(t *testing.T, hub *Hub, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		hub.mu.RLock()
+		ok := cond()
+		hub.mu.RUnlock()
+		if ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// readEvents reads n events from a connection, which may batch several
+// into one frame, and returns the id in each event's data
+func readEvents(t *testing.T, conn *websocket.Conn, n int) []string {
+	t.Helper()
+	var ids []string
+	for len(ids) < n {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, frame, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Expected %d events, got %v: %v", n, ids, err)
+		}
+		for _, line := range bytes.Split(frame, []byte{'\n'}) {
+			var msg WSMessage
+			var data struct {
+				ID string `json:"id"`
+			}
+			json.Unmarshal(line, &msg)
+			json.Unmarshal(msg.Data, &data)
+			ids = append(ids, data.ID)
+		}
+	}
+	return ids
+}
+
+func TestHubIsolatesDealerships(t *testing.T) {
+	hub := NewHub(DefaultTypingTimeout, testLogger())
+	go hub.Run()
+	defer hub.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWs(hub, w, r)
+	}))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	// One user in each dealership, both subscribed to the same conversation ID
+	dial := func(userID, dealershipID string) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"X-User-ID": {userID}, "X-Dealership-ID": {dealershipID}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.WriteJSON(map[string]string{"type": "SUBSCRIBE_CONVERSATION", "conversation_id": "conv-1"})
+		return conn
+	}
+	first := dial("user-a", "dealer-1")
+	defer first.Close()
+	second := dial("user-b", "dealer-2")
+	defer second.Close()
+
+	waitForHub(t, hub, "both clients to subscribe", func() bool {
+		return len(hub.rooms[roomKey{"dealer-1", "conv-1"}]) == 1 && len(hub.rooms[roomKey{"dealer-2", "conv-1"}]) == 1
+	})
+
+	hub.BroadcastToConversation("dealer-1", "conv-1", WSEventMessageSent, map[string]string{"id": "dealer-1 conversation"}, "")
+	hub.BroadcastToUser("dealer-1", "user-b", WSEventConversationCreated, map[string]string{"id": "dealer-1 user-b"})
+	hub.BroadcastToUsers("dealer-2", []string{"user-a"}, WSEventAnnouncementPosted, map[string]string{"id": "dealer-2 user-a"})
+	hub.BroadcastToDealership("dealer-2", WSEventPresenceChanged, map[string]string{"id": "dealer-2 dealership"})
+	hub.BroadcastToConversation("dealer-2", "conv-1", WSEventMessageSent, map[string]string{"id": "dealer-2 conversation"}, "")
+	hub.BroadcastToDealership("dealer-1", WSEventPresenceChanged, map[string]string{"id": "dealer-1 dealership"})
+
+	// The hub delivers in order, so anything that crossed over would come
+	// before each connection's last event
+	if got := strings.Join(readEvents(t, first, 2), ", "); got != "dealer-1 conversation, dealer-1 dealership" {
+		t.Errorf("Expected only dealer-1's events, got %s", got)
+	}
+	if got := strings.Join(readEvents(t, second, 2), ", "); got != "dealer-2 dealership, dealer-2 conversation" {
+		t.Errorf("Expected only dealer-2's events, got %s", got)
+	}
+}
+
+func TestTypingCoalescesAndExpires(t *testing.T) {
+	hub := NewHub(200*time.Millisecond, testLogger())
+	defer hub.Close()
+
+	// Keystrokes within the debounce window are one TYPING_START
+	for i := 0; i < 3; i++ {
+		hub.SetTyping("dealer-1", "conv-1", "user-1", "Alex", true)
+	}
+	if events := drainBroadcasts(hub); len(events) != 1 || events[0] != WSEventTypingStart {
+		t.Fatalf("Expected a single TYPING_START, got %v", events)
+	}
+
+	// Typing again pushes back the expiry
+	time.Sleep(120 * time.Millisecond)
+	hub.SetTyping("dealer-1", "conv-1", "user-1", "Alex", true)
+	time.Sleep(120 * time.Millisecond)
+	if events := drainBroadcasts(hub); len(events) != 0 {
+		t.Fatalf("Expected the indicator to still be live, got %v", events)
+	}
+
+	// Silence sends TYPING_STOP for the conversation
+	select {
+	case msg := <-hub.broadcast:
+		var ws WSMessage
+		var indicator TypingIndicator
+		json.Unmarshal(msg.Message, &ws)
+		json.Unmarshal(ws.Data, &indicator)
+		if ws.Type != WSEventTypingStop || indicator.ConversationID != "conv-1" || indicator.UserID != "user-1" {
+			t.Errorf("Expected TYPING_STOP for user-1 in conv-1, got %s %+v", ws.Type, indicator)
+		}
+		if msg.DealershipID != "dealer-1" || msg.ExcludeUserID != "user-1" {
+			t.Errorf("Expected the stop addressed to the rest of dealer-1's conversation, got %+v", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected TYPING_STOP after the timeout")
+	}
+	if users := hub.GetTypingUsers("dealer-1", "conv-1"); len(users) != 0 {
+		t.Errorf("Expected no one typing, got %v", users)
+	}
+
+	// Stopping without typing sends nothing
+	hub.SetTyping("dealer-1", "conv-1", "user-1", "Alex", false)
+	if events := drainBroadcasts(hub); len(events) != 0 {
+		t.Errorf("Expected no event, got %v", events)
+	}
+}
+
+func TestTypingStopsOnDisconnect(t *testing.T) {
+	// A timeout that won't pass during the test
+	hub := NewHub(time.Minute, testLogger())
+	go hub.Run()
+	defer hub.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWs(hub, w, r)
+	}))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	dial := func(userID string) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"X-User-ID": {userID}, "X-Dealership-ID": {"dealer-1"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.WriteJSON(map[string]string{"type": "SUBSCRIBE_CONVERSATION", "conversation_id": "conv-1"})
+		return conn
+	}
+	typist := dial("user-1")
+	watcher := dial("user-2")
+	defer watcher.Close()
+	waitForHub(t, hub, "both clients to subscribe", func() bool {
+		return len(hub.rooms[roomKey{"dealer-1", "conv-1"}]) == 2
+	})
+
+	typist.WriteJSON(map[string]interface{}{"type": "TYPING", "conversation_id": "conv-1", "is_typing": true})
+	watcher.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg WSMessage
+	if err := watcher.ReadJSON(&msg); err != nil || msg.Type != WSEventTypingStart {
+		t.Fatalf("Expected TYPING_START, got %s: %v", msg.Type, err)
+	}
+
+	typist.Close()
+	watcher.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := watcher.ReadJSON(&msg); err != nil || msg.Type != WSEventTypingStop {
+		t.Fatalf("Expected TYPING_STOP once the typist disconnected, got %s: %v", msg.Type, err)
+	}
+	waitForHub(t, hub, "the typing timer to be cleared", func() bool {
+		return len(hub.typing) == 0
+	})
+}
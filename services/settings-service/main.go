@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
 
+	"autolytiq/shared/graceful"
 	"autolytiq/shared/logging"
+	"autolytiq/shared/tracing"
 )
 
 var logger *logging.Logger
@@ -46,15 +49,27 @@ func main() {
 	// Create and start server
 	server := NewServer(db)
 
+	// Trace requests when OTEL_EXPORTER_OTLP_ENDPOINT is set
+	tracer := tracing.New(tracing.Config{
+		Service: "settings-service",
+		OnError: func(err error) { logger.WithError(err).Warn("Failed to export trace spans") },
+	})
+	defer tracer.Shutdown(context.Background())
+	server.router.Use(tracer.Middleware)
 	// Apply logging middleware
 	server.router.Use(logging.RequestIDMiddleware)
 	server.router.Use(logging.RequestLoggingMiddleware(logger))
 
 	port := getEnv("PORT", "8090")
 	addr := fmt.Sprintf(":%s", port)
+	// Stop serving on SIGINT or SIGTERM, once in-flight requests finish
+	ctx, stop := graceful.SignalContext(context.Background())
+	defer stop()
+
+	srv := &http.Server{Addr: addr, Handler: server.router}
 	logger.Infof("Settings service listening on %s", addr)
 
-	if err := http.ListenAndServe(addr, server.router); err != nil {
+	if err := graceful.ListenAndServe(ctx, srv, graceful.DefaultTimeout); err != nil {
 		logger.Fatalf("Server failed: %v", err)
 	}
 }
@@ -0,0 +1,59 @@
+// Package apierror writes error responses in the one JSON shape clients
+// parse across the gateway and services:
+//
+//	{"error": {"code": "VEHICLE_NOT_FOUND", "message": "Vehicle not found", "request_id": "..."}}
+//
+// Codes are stable, machine-readable strings; messages are for people and
+// may change.
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// requestIDHeader is set on every response by logging.RequestIDMiddleware
+// to the request ID it puts in the request context
+const requestIDHeader = "X-Request-ID"
+
+// Codes shared by every service
+const (
+	CodeInternal           = "INTERNAL_ERROR"
+	CodeInvalidRequestBody = "INVALID_REQUEST_BODY"
+	CodeValidation         = "VALIDATION_ERROR"
+	CodeUnauthorized       = "UNAUTHORIZED"
+	CodeServiceUnavailable = "SERVICE_UNAVAILABLE"
+)
+
+// Response is the body of an error response
+type Response struct {
+	Error Error `json:"error"`
+}
+
+// Error describes what went wrong
+type Error struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	RequestID string      `json:"request_id,omitempty"`
+	Details   interface{} `json:"details,omitempty"`
+}
+
+// WriteError writes an error response with the request's ID, taken from the
+// X-Request-ID response header RequestIDMiddleware sets
+func WriteError(w http.ResponseWriter, status int, code, message string) {
+	WriteErrorDetails(w, status, code, message, nil)
+}
+
+// WriteErrorDetails writes an error response with details, such as the
+// fields that failed validation
+func WriteErrorDetails(w http.ResponseWriter, status int, code, message string, details interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Response{Error: Error{
+		Code:      code,
+		Message:   message,
+		RequestID: w.Header().Get(requestIDHeader),
+		Details:   details,
+	}})
+}
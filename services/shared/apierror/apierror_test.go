@@ -0,0 +1,52 @@
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteError(t *testing.T) {
+	rr := httptest.NewRecorder()
+	rr.Header().Set("X-Request-ID", "req-1")
+
+	WriteError(rr, http.StatusNotFound, "VEHICLE_NOT_FOUND", "Vehicle not found")
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected a JSON content type, got %q", ct)
+	}
+	var body map[string]map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Expected a JSON body, got %q", rr.Body.String())
+	}
+	want := map[string]interface{}{"code": "VEHICLE_NOT_FOUND", "message": "Vehicle not found", "request_id": "req-1"}
+	if len(body["error"]) != len(want) {
+		t.Errorf("Expected %v, got %v", want, body["error"])
+	}
+	for key, value := range want {
+		if body["error"][key] != value {
+			t.Errorf("Expected %s %v, got %v", key, value, body["error"][key])
+		}
+	}
+}
+
+func TestWriteErrorDetails(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	WriteErrorDetails(rr, http.StatusBadRequest, CodeValidation, "Validation failed", []string{"vin"})
+
+	var body Response
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Error.Code != CodeValidation || body.Error.RequestID != "" {
+		t.Errorf("Unexpected error %+v", body.Error)
+	}
+	if details, ok := body.Error.Details.([]interface{}); !ok || len(details) != 1 || details[0] != "vin" {
+		t.Errorf("Expected the details, got %v", body.Error.Details)
+	}
+}
@@ -0,0 +1,3 @@
+module autolytiq/shared/apierror
+
+go 1.18
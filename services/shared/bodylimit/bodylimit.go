@@ -0,0 +1,104 @@
+// Package bodylimit caps the size of request bodies so a client cannot
+// exhaust a service's memory by sending more than a handler expects.
+// Requests that declare a longer body are refused with 413 before the
+// handler runs; bodies sent without a length are cut off at the limit, and
+// handlers should answer the read error with RespondTooLarge.
+package bodylimit
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"autolytiq/shared/apierror"
+
+	"github.com/gorilla/mux"
+)
+
+// Default limits
+const (
+	DefaultMaxBytes     int64 = 1 << 20
+	DefaultBulkMaxBytes int64 = 10 << 20
+)
+
+// CodeRequestTooLarge is the error code of a refused oversized body
+const CodeRequestTooLarge = "REQUEST_TOO_LARGE"
+
+// Config sets the body limits. MaxBytes and BulkMaxBytes are read from
+// MAX_REQUEST_BODY_BYTES and MAX_BULK_REQUEST_BODY_BYTES when zero.
+type Config struct {
+	MaxBytes     int64
+	BulkMaxBytes int64
+
+	// BulkRoutes are the path templates of bulk endpoints, such as imports,
+	// which are allowed BulkMaxBytes
+	BulkRoutes []string
+
+	// Routes sets the limit of routes that need one of their own, such as
+	// file uploads, by path template
+	Routes map[string]int64
+}
+
+// Middleware caps request bodies at the limit of the matched route. It must
+// be installed with the router's Use so the route is known.
+func Middleware(cfg Config) func(http.Handler) http.Handler {
+	limits := make(map[string]int64, len(cfg.BulkRoutes)+len(cfg.Routes))
+	bulk := limitOrEnv(cfg.BulkMaxBytes, "MAX_BULK_REQUEST_BODY_BYTES", DefaultBulkMaxBytes)
+	for _, route := range cfg.BulkRoutes {
+		limits[route] = bulk
+	}
+	for route, limit := range cfg.Routes {
+		limits[route] = limit
+	}
+	defaultLimit := limitOrEnv(cfg.MaxBytes, "MAX_REQUEST_BODY_BYTES", DefaultMaxBytes)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			limit := defaultLimit
+			if route := mux.CurrentRoute(r); route != nil {
+				if tmpl, err := route.GetPathTemplate(); err == nil {
+					if routeLimit, ok := limits[tmpl]; ok {
+						limit = routeLimit
+					}
+				}
+			}
+
+			if r.ContentLength > limit {
+				apierror.WriteError(w, http.StatusRequestEntityTooLarge, CodeRequestTooLarge,
+					fmt.Sprintf("Request body exceeds %d bytes", limit))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// IsTooLarge reports whether err came from reading past a body's limit
+func IsTooLarge(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "http: request body too large")
+}
+
+// RespondTooLarge writes the 413 for a body read past its limit
+func RespondTooLarge(w http.ResponseWriter) {
+	apierror.WriteError(w, http.StatusRequestEntityTooLarge, CodeRequestTooLarge, "Request body too large")
+}
+
+// limitOrEnv returns limit if set, else the positive byte count in the
+// environment variable key, else def
+func limitOrEnv(limit int64, key string, def int64) int64 {
+	if limit > 0 {
+		return limit
+	}
+	if value, err := strconv.ParseInt(os.Getenv(key), 10, 64); err == nil && value > 0 {
+		return value
+	}
+	return def
+}
@@ -0,0 +1,102 @@
+package bodylimit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func newRouter(cfg Config) *mux.Router {
+	router := mux.NewRouter()
+	router.Use(Middleware(cfg))
+	read := func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			if IsTooLarge(err) {
+				RespondTooLarge(w)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+	router.HandleFunc("/items", read).Methods("POST")
+	router.HandleFunc("/items/bulk", read).Methods("POST")
+	router.HandleFunc("/items/{id}/file", read).Methods("PUT")
+	return router
+}
+
+// send posts size bytes, with a Content-Length unless chunked
+func send(router http.Handler, method, path string, size int, chunked bool) *httptest.ResponseRecorder {
+	var body io.Reader = strings.NewReader(strings.Repeat("x", size))
+	if chunked {
+		body = io.MultiReader(body)
+	}
+	req := httptest.NewRequest(method, path, body)
+	if chunked {
+		req.ContentLength = -1
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestMiddleware(t *testing.T) {
+	router := newRouter(Config{
+		MaxBytes:     100,
+		BulkMaxBytes: 1000,
+		BulkRoutes:   []string{"/items/bulk"},
+		Routes:       map[string]int64{"/items/{id}/file": 5000},
+	})
+
+	tests := []struct {
+		name, method, path string
+		size               int
+		chunked            bool
+		want               int
+	}{
+		{"within the default", "POST", "/items", 100, false, http.StatusNoContent},
+		{"over the default", "POST", "/items", 101, false, http.StatusRequestEntityTooLarge},
+		{"over the default without a length", "POST", "/items", 101, true, http.StatusRequestEntityTooLarge},
+		{"bulk route", "POST", "/items/bulk", 1000, false, http.StatusNoContent},
+		{"over the bulk limit", "POST", "/items/bulk", 1001, true, http.StatusRequestEntityTooLarge},
+		{"route limit", "PUT", "/items/7/file", 5000, false, http.StatusNoContent},
+		{"over the route limit", "PUT", "/items/7/file", 5001, false, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		rr := send(router, tt.method, tt.path, tt.size, tt.chunked)
+		if rr.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, rr.Code, rr.Body.String())
+			continue
+		}
+		if tt.want != http.StatusRequestEntityTooLarge {
+			continue
+		}
+		var resp struct {
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp.Error.Code != CodeRequestTooLarge {
+			t.Errorf("%s: expected a %s error, got %s", tt.name, CodeRequestTooLarge, rr.Body.String())
+		}
+	}
+}
+
+func TestMiddlewareLimitsFromEnv(t *testing.T) {
+	t.Setenv("MAX_REQUEST_BODY_BYTES", "10")
+	t.Setenv("MAX_BULK_REQUEST_BODY_BYTES", "not-a-number")
+	router := newRouter(Config{BulkRoutes: []string{"/items/bulk"}})
+
+	if rr := send(router, "POST", "/items", 11, false); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected the limit from the environment, got %d", rr.Code)
+	}
+	if rr := send(router, "POST", "/items/bulk", int(DefaultMaxBytes)+1, false); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected the default bulk limit for an invalid setting, got %d", rr.Code)
+	}
+}
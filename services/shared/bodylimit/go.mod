@@ -0,0 +1,10 @@
+module autolytiq/shared/bodylimit
+
+go 1.18
+
+require (
+	autolytiq/shared/apierror v0.0.0
+	github.com/gorilla/mux v1.8.1
+)
+
+replace autolytiq/shared/apierror => ../apierror
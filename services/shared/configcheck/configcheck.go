@@ -0,0 +1,145 @@
+// Package configcheck validates service configuration at startup. A Checker
+// collects every problem it finds so a misconfigured service reports them all
+// at once instead of failing on the first, or worse, running on a silent
+// default.
+package configcheck
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Problem is one invalid or missing configuration value
+type Problem struct {
+	Key     string
+	Message string
+}
+
+// Error is every configuration problem found by a Checker
+type Error struct {
+	Problems []Problem
+}
+
+func (e *Error) Error() string {
+	parts := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		parts[i] = p.Key + ": " + p.Message
+	}
+	noun := "problems"
+	if len(e.Problems) == 1 {
+		noun = "problem"
+	}
+	return fmt.Sprintf("invalid configuration (%d %s): %s", len(e.Problems), noun, strings.Join(parts, "; "))
+}
+
+// Checker collects configuration problems
+type Checker struct {
+	problems []Problem
+}
+
+// New creates an empty checker
+func New() *Checker {
+	return &Checker{}
+}
+
+// Addf records a problem with a configuration value
+func (c *Checker) Addf(key, format string, args ...interface{}) {
+	c.problems = append(c.problems, Problem{Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+// Required records a problem if value is empty
+func (c *Checker) Required(key, value string) {
+	if strings.TrimSpace(value) == "" {
+		c.Addf(key, "is required")
+	}
+}
+
+// URL records a problem if a set value is not an absolute URL. Empty values
+// pass; combine with Required for mandatory URLs.
+func (c *Checker) URL(key, value string) {
+	if value == "" {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		c.Addf(key, "must be an absolute URL (got %q)", value)
+	}
+}
+
+// Port records a problem if a set value is not a TCP port number
+func (c *Checker) Port(key, value string) {
+	if value == "" {
+		return
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		c.Addf(key, "must be a port between 1 and 65535 (got %q)", value)
+	}
+}
+
+// Int records a problem if a set value is not an integer of at least min
+func (c *Checker) Int(key, value string, min int) {
+	if value == "" {
+		return
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		c.Addf(key, "must be an integer (got %q)", value)
+		return
+	}
+	if n < min {
+		c.Addf(key, "must be at least %d (got %d)", min, n)
+	}
+}
+
+// Bool records a problem if a set value is not a boolean
+func (c *Checker) Bool(key, value string) {
+	if value == "" {
+		return
+	}
+	if _, err := strconv.ParseBool(value); err != nil {
+		c.Addf(key, "must be true or false (got %q)", value)
+	}
+}
+
+// Duration records a problem if a set value is not a positive duration. Both
+// Go durations ("15m") and whole days ("7d") are accepted.
+func (c *Checker) Duration(key, value string) {
+	if value == "" {
+		return
+	}
+	if d, err := ParseDuration(value); err != nil || d <= 0 {
+		c.Addf(key, "must be a positive duration such as 30s, 15m or 7d (got %q)", value)
+	}
+}
+
+// Secret records a problem if value is set but shorter than minLength.
+// Secrets are never included in the message.
+func (c *Checker) Secret(key, value string, minLength int) {
+	if value != "" && len(value) < minLength {
+		c.Addf(key, "must be at least %d characters (got %d)", minLength, len(value))
+	}
+}
+
+// Err returns an *Error listing every problem found, or nil
+func (c *Checker) Err() error {
+	if len(c.problems) == 0 {
+		return nil
+	}
+	return &Error{Problems: c.problems}
+}
+
+// ParseDuration parses a Go duration or a whole number of days ("7d")
+func ParseDuration(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
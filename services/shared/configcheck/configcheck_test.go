@@ -0,0 +1,79 @@
+package configcheck
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckerAggregatesProblems(t *testing.T) {
+	c := New()
+	c.Required("DATABASE_URL", "")
+	c.URL("AUTH_SERVICE_URL", "localhost:8087")
+	c.Port("PORT", "80800")
+	c.Duration("ACCESS_TOKEN_TTL", "fifteen minutes")
+	c.Secret("JWT_SECRET", "short", 32)
+	c.Int("RATE_LIMIT_IP", "-1", 1)
+	c.Bool("RATE_LIMIT_ENABLED", "yes please")
+
+	err := c.Err()
+	var configErr *Error
+	if !errors.As(err, &configErr) {
+		t.Fatalf("Expected *Error, got %v", err)
+	}
+	if len(configErr.Problems) != 7 {
+		t.Fatalf("Expected 7 problems, got %d: %v", len(configErr.Problems), err)
+	}
+
+	msg := err.Error()
+	for _, want := range []string{
+		"invalid configuration (7 problems)",
+		"DATABASE_URL: is required",
+		`AUTH_SERVICE_URL: must be an absolute URL (got "localhost:8087")`,
+		"PORT: must be a port",
+		"ACCESS_TOKEN_TTL: must be a positive duration",
+		"JWT_SECRET: must be at least 32 characters (got 5)",
+		"RATE_LIMIT_IP: must be at least 1 (got -1)",
+		`RATE_LIMIT_ENABLED: must be true or false (got "yes please")`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Expected error to contain %q, got %q", want, msg)
+		}
+	}
+	if strings.Contains(msg, "short") {
+		t.Error("Expected the secret value not to appear in the error")
+	}
+}
+
+func TestCheckerValidConfig(t *testing.T) {
+	c := New()
+	c.Required("DATABASE_URL", "postgres://localhost:5432/autolytiq")
+	c.URL("DATABASE_URL", "postgres://localhost:5432/autolytiq")
+	c.URL("OPTIONAL_URL", "")
+	c.Port("PORT", "8080")
+	c.Duration("REFRESH_TOKEN_TTL", "7d")
+	c.Secret("JWT_SECRET", strings.Repeat("x", 32), 32)
+	c.Int("RATE_LIMIT_IP", "100", 1)
+	c.Bool("RATE_LIMIT_ENABLED", "false")
+
+	if err := c.Err(); err != nil {
+		t.Errorf("Expected no problems, got %v", err)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"15m": 15 * time.Minute,
+		"7d":  7 * 24 * time.Hour,
+		"30d": 30 * 24 * time.Hour,
+	}
+	for input, want := range tests {
+		if got, err := ParseDuration(input); err != nil || got != want {
+			t.Errorf("ParseDuration(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	if _, err := ParseDuration("xd"); err == nil {
+		t.Error("Expected an error for an invalid day count")
+	}
+}
@@ -0,0 +1,3 @@
+module autolytiq/shared/configcheck
+
+go 1.18
@@ -0,0 +1,285 @@
+// Package configclient provides typed, cached access to dealership settings
+// stored in config-service. Values are converted according to their stored
+// type, cached per dealership with a TTL, and invalidated when the
+// dealership's config version changes.
+package configclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Common errors
+var (
+	ErrNotFound     = errors.New("config setting not found")
+	ErrTypeMismatch = errors.New("config setting has a different type")
+)
+
+// Setting types stored by config-service
+const (
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+	TypeJSON    = "json"
+)
+
+// Headers used when talking to config-service
+const (
+	DealershipHeader  = "X-Dealership-ID"
+	VersionHeader     = "X-Config-Version"
+	EnvironmentHeader = "X-Config-Environment"
+)
+
+// Config holds the configuration for the config client
+type Config struct {
+	// BaseURL is the config-service base URL (e.g., "http://config-service:8080")
+	BaseURL string
+
+	// CacheTTL is how long settings are cached before being fetched again
+	CacheTTL time.Duration
+
+	// Timeout bounds each request to config-service
+	Timeout time.Duration
+
+	// HTTPClient overrides the HTTP client used for requests
+	HTTPClient *http.Client
+
+	// Environment selects environment-scoped settings, falling back to the
+	// dealership's default values. Empty uses config-service's environment.
+	Environment string
+}
+
+// DefaultConfig returns a Config with sensible defaults
+func DefaultConfig() Config {
+	baseURL := os.Getenv("CONFIG_SERVICE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8086"
+	}
+
+	return Config{
+		BaseURL:     baseURL,
+		CacheTTL:    time.Minute,
+		Timeout:     2 * time.Second,
+		Environment: os.Getenv("CONFIG_ENVIRONMENT"),
+	}
+}
+
+// setting mirrors the config-service DealershipConfig response
+type setting struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Type  string `json:"type"`
+}
+
+// cachedSetting holds a cached setting with its expiry
+type cachedSetting struct {
+	setting   *setting
+	expiresAt time.Time
+}
+
+// Client fetches dealership settings from config-service with caching
+type Client struct {
+	config     Config
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	cache    map[string]map[string]*cachedSetting // dealershipID -> key -> setting
+	versions map[string]int64                     // dealershipID -> last seen version
+
+	now func() time.Time
+}
+
+// NewClient creates a new config client
+func NewClient(cfg Config) *Client {
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: cfg.Timeout}
+	}
+
+	return &Client{
+		config:     cfg,
+		httpClient: httpClient,
+		cache:      make(map[string]map[string]*cachedSetting),
+		versions:   make(map[string]int64),
+		now:        time.Now,
+	}
+}
+
+// GetString returns a string setting
+func (c *Client) GetString(ctx context.Context, dealershipID, key string) (string, error) {
+	s, err := c.get(ctx, dealershipID, key, TypeString)
+	if err != nil {
+		return "", err
+	}
+	return s.Value, nil
+}
+
+// GetInt returns an integer setting
+func (c *Client) GetInt(ctx context.Context, dealershipID, key string) (int, error) {
+	s, err := c.get(ctx, dealershipID, key, TypeInteger)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.Atoi(s.Value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer value for %s: %w", key, err)
+	}
+	return v, nil
+}
+
+// GetBool returns a boolean setting
+func (c *Client) GetBool(ctx context.Context, dealershipID, key string) (bool, error) {
+	s, err := c.get(ctx, dealershipID, key, TypeBoolean)
+	if err != nil {
+		return false, err
+	}
+	v, err := strconv.ParseBool(s.Value)
+	if err != nil {
+		return false, fmt.Errorf("invalid boolean value for %s: %w", key, err)
+	}
+	return v, nil
+}
+
+// GetJSON decodes a JSON setting into v
+func (c *Client) GetJSON(ctx context.Context, dealershipID, key string, v interface{}) error {
+	s, err := c.get(ctx, dealershipID, key, TypeJSON)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(s.Value), v); err != nil {
+		return fmt.Errorf("invalid JSON value for %s: %w", key, err)
+	}
+	return nil
+}
+
+// Invalidate drops all cached settings for a dealership
+func (c *Client) Invalidate(dealershipID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.cache, dealershipID)
+}
+
+// CheckVersion fetches the dealership's current config version and drops its
+// cached settings if the version has changed since it was last seen
+func (c *Client) CheckVersion(ctx context.Context, dealershipID string) error {
+	var body struct {
+		Version int64 `json:"version"`
+	}
+	status, _, err := c.fetch(ctx, dealershipID, "/config/version", &body)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("config-service returned status %d", status)
+	}
+
+	c.observeVersion(dealershipID, body.Version)
+	return nil
+}
+
+// get returns a setting from cache or config-service, checking its type
+func (c *Client) get(ctx context.Context, dealershipID, key, wantType string) (*setting, error) {
+	s, ok := c.cached(dealershipID, key)
+	if !ok {
+		var err error
+		s, err = c.load(ctx, dealershipID, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if s.Type != wantType {
+		return nil, fmt.Errorf("%w: %s is %s, not %s", ErrTypeMismatch, key, s.Type, wantType)
+	}
+	return s, nil
+}
+
+// cached returns an unexpired cached setting
+func (c *Client) cached(dealershipID, key string) (*setting, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.cache[dealershipID][key]
+	if !ok || !c.now().Before(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.setting, true
+}
+
+// load fetches a setting from config-service and caches it
+func (c *Client) load(ctx context.Context, dealershipID, key string) (*setting, error) {
+	var s setting
+	status, header, err := c.fetch(ctx, dealershipID, "/config/settings/"+url.PathEscape(key), &s)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("config-service returned status %d", status)
+	}
+
+	if v, err := strconv.ParseInt(header.Get(VersionHeader), 10, 64); err == nil {
+		c.observeVersion(dealershipID, v)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cache[dealershipID] == nil {
+		c.cache[dealershipID] = make(map[string]*cachedSetting)
+	}
+	c.cache[dealershipID][key] = &cachedSetting{
+		setting:   &s,
+		expiresAt: c.now().Add(c.config.CacheTTL),
+	}
+	return &s, nil
+}
+
+// observeVersion records a dealership's config version, invalidating its
+// cache when the version moves forward
+func (c *Client) observeVersion(dealershipID string, version int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	known, seen := c.versions[dealershipID]
+	if seen && version > known {
+		delete(c.cache, dealershipID)
+	}
+	if !seen || version > known {
+		c.versions[dealershipID] = version
+	}
+}
+
+// fetch performs a GET against config-service and decodes a 200 response into v
+func (c *Client) fetch(ctx context.Context, dealershipID, path string, v interface{}) (int, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.config.BaseURL+path, nil)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to build config request: %w", err)
+	}
+	req.Header.Set(DealershipHeader, dealershipID)
+	if c.config.Environment != "" {
+		req.Header.Set(EnvironmentHeader, c.config.Environment)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to fetch config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return 0, nil, fmt.Errorf("failed to decode config response: %w", err)
+		}
+	}
+	return resp.StatusCode, resp.Header, nil
+}
@@ -0,0 +1,243 @@
+package configclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConfigService serves settings and versions like config-service and
+// counts setting fetches
+type fakeConfigService struct {
+	mu       sync.Mutex
+	settings map[string]setting // key -> setting
+	version  int64
+	fetches  int
+
+	environment string // environment header of the last request
+}
+
+func newFakeConfigService() *fakeConfigService {
+	return &fakeConfigService{settings: make(map[string]setting)}
+}
+
+func (f *fakeConfigService) set(key, value, typ string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.settings[key] = setting{Key: key, Value: value, Type: typ}
+	f.version++
+}
+
+func (f *fakeConfigService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get(DealershipHeader) == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.environment = r.Header.Get(EnvironmentHeader)
+
+	if r.URL.Path == "/config/version" {
+		json.NewEncoder(w).Encode(map[string]interface{}{"version": f.version})
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/config/settings/")
+	f.fetches++
+	s, ok := f.settings[key]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set(VersionHeader, strconv.FormatInt(f.version, 10))
+	json.NewEncoder(w).Encode(s)
+}
+
+func (f *fakeConfigService) fetchCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.fetches
+}
+
+func setupTestClient(t *testing.T) (*Client, *fakeConfigService) {
+	t.Helper()
+
+	fake := newFakeConfigService()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	client := NewClient(Config{BaseURL: srv.URL, CacheTTL: time.Minute, Timeout: time.Second})
+	return client, fake
+}
+
+func TestTypedGetters(t *testing.T) {
+	client, fake := setupTestClient(t)
+	ctx := context.Background()
+
+	fake.set("from_name", "Acme Motors", TypeString)
+	fake.set("default_term_months", "72", TypeInteger)
+	fake.set("require_cobuyer_ssn", "true", TypeBoolean)
+	fake.set("tax_rates", `{"state":0.0625}`, TypeJSON)
+
+	if v, err := client.GetString(ctx, "dealer-1", "from_name"); err != nil || v != "Acme Motors" {
+		t.Errorf("GetString = %q, %v", v, err)
+	}
+	if v, err := client.GetInt(ctx, "dealer-1", "default_term_months"); err != nil || v != 72 {
+		t.Errorf("GetInt = %d, %v", v, err)
+	}
+	if v, err := client.GetBool(ctx, "dealer-1", "require_cobuyer_ssn"); err != nil || !v {
+		t.Errorf("GetBool = %v, %v", v, err)
+	}
+
+	var rates map[string]float64
+	if err := client.GetJSON(ctx, "dealer-1", "tax_rates", &rates); err != nil || rates["state"] != 0.0625 {
+		t.Errorf("GetJSON = %v, %v", rates, err)
+	}
+}
+
+func TestTypeMismatch(t *testing.T) {
+	client, fake := setupTestClient(t)
+	fake.set("from_name", "Acme Motors", TypeString)
+
+	_, err := client.GetInt(context.Background(), "dealer-1", "from_name")
+	if !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Expected ErrTypeMismatch, got %v", err)
+	}
+}
+
+func TestInvalidStoredValue(t *testing.T) {
+	client, fake := setupTestClient(t)
+	fake.set("default_term_months", "sixty", TypeInteger)
+
+	if _, err := client.GetInt(context.Background(), "dealer-1", "default_term_months"); err == nil {
+		t.Error("Expected error for non-numeric integer value")
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	client, _ := setupTestClient(t)
+
+	_, err := client.GetString(context.Background(), "dealer-1", "missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestCachesWithinTTL(t *testing.T) {
+	client, fake := setupTestClient(t)
+	ctx := context.Background()
+	fake.set("default_term_months", "60", TypeInteger)
+
+	now := time.Now()
+	client.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetInt(ctx, "dealer-1", "default_term_months"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := fake.fetchCount(); got != 1 {
+		t.Errorf("Expected 1 fetch within TTL, got %d", got)
+	}
+
+	// Cache is per dealership
+	if _, err := client.GetInt(ctx, "dealer-2", "default_term_months"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.fetchCount(); got != 2 {
+		t.Errorf("Expected a separate fetch for another dealership, got %d", got)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := client.GetInt(ctx, "dealer-1", "default_term_months"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.fetchCount(); got != 3 {
+		t.Errorf("Expected a refetch after TTL, got %d fetches", got)
+	}
+}
+
+func TestVersionBumpInvalidatesCache(t *testing.T) {
+	client, fake := setupTestClient(t)
+	ctx := context.Background()
+	fake.set("default_term_months", "60", TypeInteger)
+
+	if v, _ := client.GetInt(ctx, "dealer-1", "default_term_months"); v != 60 {
+		t.Fatalf("Expected 60, got %d", v)
+	}
+
+	// No change: version check keeps the cache
+	if err := client.CheckVersion(ctx, "dealer-1"); err != nil {
+		t.Fatal(err)
+	}
+	client.GetInt(ctx, "dealer-1", "default_term_months")
+	if got := fake.fetchCount(); got != 1 {
+		t.Errorf("Expected cache to survive an unchanged version, got %d fetches", got)
+	}
+
+	fake.set("default_term_months", "72", TypeInteger)
+	if err := client.CheckVersion(ctx, "dealer-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _ := client.GetInt(ctx, "dealer-1", "default_term_months"); v != 72 {
+		t.Errorf("Expected 72 after version bump, got %d", v)
+	}
+	if got := fake.fetchCount(); got != 2 {
+		t.Errorf("Expected refetch after version bump, got %d fetches", got)
+	}
+}
+
+func TestNewerVersionOnFetchDropsOtherEntries(t *testing.T) {
+	client, fake := setupTestClient(t)
+	ctx := context.Background()
+	fake.set("from_name", "Acme Motors", TypeString)
+	fake.set("default_term_months", "60", TypeInteger)
+
+	client.GetString(ctx, "dealer-1", "from_name")
+
+	// A change is observed via the version header on an unrelated fetch
+	fake.set("from_name", "Acme Auto Group", TypeString)
+	client.GetInt(ctx, "dealer-1", "default_term_months")
+
+	if v, _ := client.GetString(ctx, "dealer-1", "from_name"); v != "Acme Auto Group" {
+		t.Errorf("Expected stale entry to be dropped, got %q", v)
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	client, fake := setupTestClient(t)
+	ctx := context.Background()
+	fake.set("from_name", "Acme Motors", TypeString)
+
+	client.GetString(ctx, "dealer-1", "from_name")
+	client.Invalidate("dealer-1")
+	client.GetString(ctx, "dealer-1", "from_name")
+
+	if got := fake.fetchCount(); got != 2 {
+		t.Errorf("Expected refetch after Invalidate, got %d fetches", got)
+	}
+}
+
+func TestSendsEnvironment(t *testing.T) {
+	fake := newFakeConfigService()
+	fake.set("dealership_name", "Test Motors", TypeString)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	client := NewClient(Config{BaseURL: srv.URL, CacheTTL: time.Minute, Timeout: time.Second, Environment: "staging"})
+	if _, err := client.GetString(context.Background(), "dealer-1", "dealership_name"); err != nil {
+		t.Fatal(err)
+	}
+	if fake.environment != "staging" {
+		t.Errorf("Expected the staging environment header, got %q", fake.environment)
+	}
+}
@@ -0,0 +1,3 @@
+module autolytiq/shared/configclient
+
+go 1.18
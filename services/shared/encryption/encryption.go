@@ -433,6 +433,13 @@ func (e *Encryptor) ReEncrypt(encrypted string) (string, error) {
 	return e.Encrypt(plaintext)
 }
 
+// PrimaryKeyVersion returns the version of the key new values are encrypted
+// with
+func (e *Encryptor) PrimaryKeyVersion() (string, error) {
+	_, version, err := e.keyManager.GetPrimaryKey()
+	return version, err
+}
+
 // GetKeyVersion extracts the key version from an encrypted value
 func (e *Encryptor) GetKeyVersion(encrypted string) (string, error) {
 	if !e.IsEncrypted(encrypted) {
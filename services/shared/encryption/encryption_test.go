@@ -310,6 +310,10 @@ func TestKeyRotation(t *testing.T) {
 		t.Errorf("expected version v2 after rotation, got %s", version2)
 	}
 
+	if primary, _ := enc.PrimaryKeyVersion(); primary != "v2" {
+		t.Errorf("expected primary version v2 after rotation, got %s", primary)
+	}
+
 	// Old data encrypted with v1 should still decrypt
 	decrypted, err := enc.Decrypt(encrypted)
 	if err != nil {
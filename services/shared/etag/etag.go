@@ -0,0 +1,60 @@
+// Package etag implements optimistic concurrency for resources with an
+// integer version. The version is sent to clients as a strong ETag, and
+// writes must send it back in If-Match so that a client never overwrites a
+// change it has not seen.
+package etag
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// HeaderIfMatch is the request header carrying the expected version
+const HeaderIfMatch = "If-Match"
+
+// Precondition errors. Handlers should answer ErrMissing with 428
+// Precondition Required and ErrMismatch with 412 Precondition Failed.
+var (
+	ErrMissing  = errors.New("If-Match header is required")
+	ErrMismatch = errors.New("resource has been modified")
+)
+
+// Format returns the ETag for a version
+func Format(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// Set sets the ETag header for a version
+func Set(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", Format(version))
+}
+
+// Matches reports whether an If-Match value matches a version. The value
+// may be *, which matches any version, or a comma-separated list of ETags.
+// Weak ETags (W/"3") are compared by their value.
+func Matches(ifMatch string, version int) bool {
+	want := Format(version)
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == want {
+			return true
+		}
+	}
+	return false
+}
+
+// Check checks a request's If-Match header against the current version of
+// the resource it writes, returning ErrMissing or ErrMismatch if the write
+// must not go ahead
+func Check(r *http.Request, version int) error {
+	ifMatch := strings.TrimSpace(r.Header.Get(HeaderIfMatch))
+	if ifMatch == "" {
+		return ErrMissing
+	}
+	if !Matches(ifMatch, version) {
+		return ErrMismatch
+	}
+	return nil
+}
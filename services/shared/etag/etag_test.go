@@ -0,0 +1,43 @@
+package etag
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		ifMatch string
+		version int
+		want    bool
+	}{
+		{`"3"`, 3, true},
+		{`"3"`, 4, false},
+		{`*`, 7, true},
+		{`"1", "2" ,"3"`, 2, true},
+		{`W/"5"`, 5, true},
+		{`3`, 3, false},
+		{``, 1, false},
+	}
+
+	for _, tt := range tests {
+		if got := Matches(tt.ifMatch, tt.version); got != tt.want {
+			t.Errorf("Matches(%q, %d) = %v, want %v", tt.ifMatch, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	r := httptest.NewRequest("PUT", "/", nil)
+	if err := Check(r, 1); err != ErrMissing {
+		t.Errorf("Check without If-Match = %v, want ErrMissing", err)
+	}
+
+	r.Header.Set(HeaderIfMatch, Format(1))
+	if err := Check(r, 1); err != nil {
+		t.Errorf("Check with a matching ETag = %v, want nil", err)
+	}
+	if err := Check(r, 2); err != ErrMismatch {
+		t.Errorf("Check with a stale ETag = %v, want ErrMismatch", err)
+	}
+}
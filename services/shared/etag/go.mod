@@ -0,0 +1,3 @@
+module autolytiq/shared/etag
+
+go 1.18
@@ -0,0 +1,3 @@
+module autolytiq/shared/graceful
+
+go 1.18
@@ -0,0 +1,66 @@
+// Package graceful runs HTTP servers that drain in-flight requests on
+// SIGINT or SIGTERM instead of dropping them.
+package graceful
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// DefaultTimeout is how long in-flight requests get to finish on shutdown
+const DefaultTimeout = 30 * time.Second
+
+// SignalContext returns a context cancelled on SIGINT or SIGTERM. Call stop
+// once the server has shut down to restore default signal handling.
+func SignalContext(parent context.Context) (ctx context.Context, stop context.CancelFunc) {
+	return signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+}
+
+// ListenAndServe listens on srv.Addr and serves until ctx is cancelled, as
+// Serve does.
+func ListenAndServe(ctx context.Context, srv *http.Server, timeout time.Duration) error {
+	addr := srv.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return Serve(ctx, srv, ln, timeout)
+}
+
+// Serve serves on ln until ctx is cancelled, then stops accepting
+// connections and waits up to timeout for in-flight requests. Hijacked
+// connections such as WebSockets are not waited for; close them with
+// srv.RegisterOnShutdown. It returns nil after a clean shutdown.
+func Serve(ctx context.Context, srv *http.Server, ln net.Listener, timeout time.Duration) error {
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.Serve(ln) }()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		<-serveErr
+		return fmt.Errorf("shutdown did not finish within %s: %w", timeout, err)
+	}
+
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package graceful
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// waitForGoroutines waits for the goroutine count to fall back to want
+func waitForGoroutines(t *testing.T, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > want {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("Expected %d goroutines, got %d:\n%s", want, runtime.NumGoroutine(), buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ln
+}
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})}
+	ln := listen(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- Serve(ctx, srv, ln, time.Second) }()
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	type result struct {
+		body string
+		err  error
+	}
+	got := make(chan result, 1)
+	go func() {
+		resp, err := client.Get("http://" + ln.Addr().String())
+		if err != nil {
+			got <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		got <- result{string(body), err}
+	}()
+
+	<-started
+	cancel()
+	select {
+	case err := <-served:
+		t.Fatalf("Expected Serve to wait for the in-flight request, returned %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if r := <-got; r.err != nil || r.body != "done" {
+		t.Errorf("Expected the in-flight request to finish, got %q, %v", r.body, r.err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Expected a clean shutdown, got %v", err)
+	}
+	if _, err := net.Dial("tcp", ln.Addr().String()); err == nil {
+		t.Error("Expected the listener to be closed")
+	}
+
+	waitForGoroutines(t, before)
+}
+
+func TestServeGivesUpAfterTimeout(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	started := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	})}
+	ln := listen(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- Serve(ctx, srv, ln, 50*time.Millisecond) }()
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	go client.Get("http://" + ln.Addr().String())
+
+	<-started
+	cancel()
+	if err := <-served; err == nil {
+		t.Error("Expected an error when in-flight requests outlive the timeout")
+	}
+
+	client.CloseIdleConnections()
+	waitForGoroutines(t, before)
+}
+
+func TestServeReturnsListenerErrors(t *testing.T) {
+	ln := listen(t)
+	ln.Close()
+
+	if err := Serve(context.Background(), &http.Server{}, ln, time.Second); err == nil {
+		t.Error("Expected an error from a closed listener")
+	}
+}
@@ -0,0 +1,85 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// DefaultDBTimeout bounds the database ping of a HealthChecker
+const DefaultDBTimeout = 2 * time.Second
+
+// Database states reported by HealthChecker
+const (
+	DBUp   = "up"
+	DBDown = "down"
+)
+
+// PingFunc checks that a dependency is reachable, such as (*sql.DB).PingContext.
+type PingFunc func(ctx context.Context) error
+
+// DBHealth is the /health response of a service backed by a database.
+type DBHealth struct {
+	Status    Status `json:"status"`
+	Service   string `json:"service"`
+	DB        string `json:"db"`
+	Timestamp string `json:"timestamp"`
+}
+
+// HealthChecker serves /health for a service backed by a database, so every
+// service reports an unreachable database the same way and load balancers
+// pull the pod.
+type HealthChecker struct {
+	service string
+	ping    PingFunc
+	timeout time.Duration
+
+	// dependencies and ready only affect readiness
+	dependencies []dependency
+	ready        int32
+}
+
+// NewHealthChecker creates a health checker for service that pings its
+// database with ping.
+func NewHealthChecker(service string, ping PingFunc) *HealthChecker {
+	return &HealthChecker{
+		service: service,
+		ping:    ping,
+		timeout: DefaultDBTimeout,
+	}
+}
+
+// Check pings the database, giving up after the checker's timeout. The
+// error is returned for logging and is not part of the result.
+func (h *HealthChecker) Check(ctx context.Context) (DBHealth, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	result := DBHealth{
+		Status:    StatusHealthy,
+		Service:   h.service,
+		DB:        DBUp,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+	err := h.ping(ctx)
+	if err != nil {
+		result.Status = StatusUnhealthy
+		result.DB = DBDown
+	}
+	return result, err
+}
+
+// ServeHTTP writes the check's result, with 503 when the database is down.
+func (h *HealthChecker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	result, _ := h.Check(r.Context())
+
+	statusCode := http.StatusOK
+	if result.Status != StatusHealthy {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,114 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func serveHealth(h *HealthChecker) (int, DBHealth) {
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/health", nil))
+	var result DBHealth
+	json.Unmarshal(rr.Body.Bytes(), &result)
+	return rr.Code, result
+}
+
+func TestHealthCheckerHealthy(t *testing.T) {
+	h := NewHealthChecker("deal-service", func(ctx context.Context) error { return nil })
+
+	code, result := serveHealth(h)
+	if code != http.StatusOK || result.Status != StatusHealthy || result.DB != DBUp || result.Service != "deal-service" {
+		t.Errorf("Expected a healthy deal-service, got %d %+v", code, result)
+	}
+}
+
+func TestHealthCheckerDatabaseDown(t *testing.T) {
+	h := NewHealthChecker("deal-service", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+
+	code, result := serveHealth(h)
+	if code != http.StatusServiceUnavailable || result.Status != StatusUnhealthy || result.DB != DBDown {
+		t.Errorf("Expected 503 with the database down, got %d %+v", code, result)
+	}
+}
+
+func TestHealthCheckerTimesOut(t *testing.T) {
+	h := NewHealthChecker("deal-service", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	h.timeout = 10 * time.Millisecond
+
+	start := time.Now()
+	if _, err := h.Check(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the ping to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the check to give up quickly, took %v", elapsed)
+	}
+}
+
+func serveReady(h *HealthChecker) (int, DBReadiness) {
+	rr := httptest.NewRecorder()
+	h.ReadinessHandler(rr, httptest.NewRequest("GET", "/ready", nil))
+	var result DBReadiness
+	json.Unmarshal(rr.Body.Bytes(), &result)
+	return rr.Code, result
+}
+
+func TestReadinessWaitsForStartup(t *testing.T) {
+	pinged := false
+	h := NewHealthChecker("email-service", func(ctx context.Context) error {
+		pinged = true
+		return nil
+	})
+
+	code, result := serveReady(h)
+	if code != http.StatusServiceUnavailable || result.Status != StatusNotReady || result.Reason != ReasonInitializing {
+		t.Errorf("Expected 503 while initializing, got %d %+v", code, result)
+	}
+	if pinged {
+		t.Error("Expected no database ping before the service is ready")
+	}
+
+	h.MarkReady()
+	if code, result := serveReady(h); code != http.StatusOK || result.Status != StatusReady || result.DB != DBUp {
+		t.Errorf("Expected ready once started, got %d %+v", code, result)
+	}
+}
+
+func TestReadinessChecksDependencies(t *testing.T) {
+	smtpErr := errors.New("dial tcp: connection refused")
+	h := NewHealthChecker("email-service", func(ctx context.Context) error { return nil })
+	h.AddDependency("smtp", func(ctx context.Context) error { return smtpErr })
+	h.MarkReady()
+
+	code, result := serveReady(h)
+	if code != http.StatusServiceUnavailable || result.Reason != ReasonDependencyDown || result.Dependencies["smtp"] != DBDown {
+		t.Errorf("Expected 503 with SMTP down, got %d %+v", code, result)
+	}
+
+	smtpErr = nil
+	if code, result := serveReady(h); code != http.StatusOK || result.Dependencies["smtp"] != DBUp {
+		t.Errorf("Expected ready with SMTP up, got %d %+v", code, result)
+	}
+}
+
+func TestLivenessSkipsDatabase(t *testing.T) {
+	h := NewHealthChecker("email-service", func(ctx context.Context) error {
+		t.Error("Expected liveness not to ping the database")
+		return nil
+	})
+
+	rr := httptest.NewRecorder()
+	h.LivenessHandler(rr, httptest.NewRequest("GET", "/live", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,3 @@
+module autolytiq/shared/health
+
+go 1.18
@@ -0,0 +1,108 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Readiness reasons reported by HealthChecker
+const (
+	ReasonInitializing   = "initializing"
+	ReasonDependencyDown = "dependency_down"
+)
+
+// dependency is an extra dependency a readiness check pings
+type dependency struct {
+	name string
+	ping PingFunc
+}
+
+// DBReadiness is the /ready response of a service backed by a database.
+type DBReadiness struct {
+	Status       ReadinessStatus   `json:"status"`
+	Service      string            `json:"service"`
+	Reason       string            `json:"reason,omitempty"`
+	DB           string            `json:"db,omitempty"`
+	Dependencies map[string]string `json:"dependencies,omitempty"`
+	Timestamp    string            `json:"timestamp"`
+}
+
+// AddDependency adds a dependency, such as SMTP or Redis, that must be
+// reachable for the service to be ready. Dependencies are not part of
+// /health, which only checks the database.
+func (h *HealthChecker) AddDependency(name string, ping PingFunc) {
+	h.dependencies = append(h.dependencies, dependency{name: name, ping: ping})
+}
+
+// MarkReady records that startup work such as schema initialization has
+// finished. Until then the readiness check fails without pinging anything,
+// so no traffic is routed to a service that can't serve it yet.
+func (h *HealthChecker) MarkReady() {
+	atomic.StoreInt32(&h.ready, 1)
+}
+
+// Readiness reports whether the service has started and its database and
+// dependencies are reachable, giving up after the checker's timeout.
+func (h *HealthChecker) Readiness(ctx context.Context) DBReadiness {
+	result := DBReadiness{
+		Status:    StatusReady,
+		Service:   h.service,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+	if atomic.LoadInt32(&h.ready) == 0 {
+		result.Status = StatusNotReady
+		result.Reason = ReasonInitializing
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	result.DB = DBUp
+	if err := h.ping(ctx); err != nil {
+		result.DB = DBDown
+		result.Status = StatusNotReady
+		result.Reason = ReasonDependencyDown
+	}
+	if len(h.dependencies) > 0 {
+		result.Dependencies = make(map[string]string, len(h.dependencies))
+	}
+	for _, dep := range h.dependencies {
+		result.Dependencies[dep.name] = DBUp
+		if err := dep.ping(ctx); err != nil {
+			result.Dependencies[dep.name] = DBDown
+			result.Status = StatusNotReady
+			result.Reason = ReasonDependencyDown
+		}
+	}
+	return result
+}
+
+// ReadinessHandler serves /ready, with 503 until the service is ready.
+func (h *HealthChecker) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	result := h.Readiness(r.Context())
+
+	statusCode := http.StatusOK
+	if result.Status != StatusReady {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(result)
+}
+
+// LivenessHandler serves /live. It only shows the process is up and never
+// touches the database, so a database outage doesn't get pods restarted.
+func (h *HealthChecker) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(LivenessResult{
+		Status:    StatusAlive,
+		Service:   h.service,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	})
+}
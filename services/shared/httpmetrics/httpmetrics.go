@@ -0,0 +1,155 @@
+// Package httpmetrics records Prometheus metrics for a service's HTTP
+// requests: a request count, a duration histogram, and the requests in
+// flight. Requests are labeled by the route's mux path template
+// (/deals/{id}) rather than the raw path so IDs don't become label values.
+package httpmetrics
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes every metric name
+const namespace = "autolytiq"
+
+// unmatchedRoute labels requests that matched no route
+const unmatchedRoute = "unmatched"
+
+// DefaultBuckets are the request duration histogram buckets, in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics holds a service's HTTP metrics and the registry they are
+// registered with
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+}
+
+// New creates the HTTP metrics of a service in a registry of their own,
+// along with the Go runtime and process collectors
+func New(service string) *Metrics {
+	labels := prometheus.Labels{"service": service}
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "http_requests_total",
+			Help:        "Total number of HTTP requests",
+			ConstLabels: labels,
+		}, []string{"method", "route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "http_request_duration_seconds",
+			Help:        "HTTP request duration in seconds",
+			Buckets:     DefaultBuckets,
+			ConstLabels: labels,
+		}, []string{"method", "route", "status"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "http_requests_in_flight",
+			Help:        "Number of HTTP requests currently being processed",
+			ConstLabels: labels,
+		}, []string{"method", "route"}),
+	}
+	m.registry.MustRegister(
+		m.requests,
+		m.duration,
+		m.inFlight,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Registry returns the registry the metrics are registered with, for a
+// service to add metrics of its own
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler serves the registry's metrics for scraping
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware records every request but those to /metrics. It must be
+// installed with the router's Use so the route is known.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+		if route == "/metrics" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		inFlight := m.inFlight.WithLabelValues(r.Method, route)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		start := time.Now()
+		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(wrapped, r)
+
+		status := strconv.Itoa(wrapped.statusCode)
+		m.requests.WithLabelValues(r.Method, route, status).Inc()
+		m.duration.WithLabelValues(r.Method, route, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// routeTemplate returns the path template of the request's route
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return unmatchedRoute
+}
+
+// responseWriter wraps http.ResponseWriter to capture the status code
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	written    bool
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	if !rw.written {
+		rw.statusCode = code
+		rw.written = true
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.written = true
+	return rw.ResponseWriter.Write(b)
+}
+
+// Flush supports streaming responses through the wrapper
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack supports WebSocket upgrades through the wrapper
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
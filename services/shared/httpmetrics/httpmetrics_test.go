@@ -0,0 +1,83 @@
+package httpmetrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newRouter(m *Metrics) *mux.Router {
+	router := mux.NewRouter()
+	router.Use(m.Middleware)
+	router.Handle("/metrics", m.Handler()).Methods("GET")
+	router.HandleFunc("/deals/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}).Methods("GET")
+	return router
+}
+
+func get(router http.Handler, path string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+	return rr
+}
+
+func TestMiddlewareLabelsByRouteTemplate(t *testing.T) {
+	m := New("deal-service")
+	router := newRouter(m)
+
+	get(router, "/deals/1")
+	get(router, "/deals/2")
+	get(router, "/deals/missing")
+
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("GET", "/deals/{id}", "200")); got != 2 {
+		t.Errorf("Expected 2 requests counted under the route template, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("GET", "/deals/{id}", "404")); got != 1 {
+		t.Errorf("Expected the 404 counted by status, got %v", got)
+	}
+	if got := testutil.CollectAndCount(m.requests); got != 2 {
+		t.Errorf("Expected 2 series, not one per deal ID, got %d", got)
+	}
+	if got := testutil.CollectAndCount(m.duration); got != 2 {
+		t.Errorf("Expected a duration series per route and status, got %d", got)
+	}
+	if got := testutil.ToFloat64(m.inFlight.WithLabelValues("GET", "/deals/{id}")); got != 0 {
+		t.Errorf("Expected nothing in flight once requests finish, got %v", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m := New("deal-service")
+	router := newRouter(m)
+	get(router, "/deals/1")
+
+	rr := get(router, "/metrics")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+	body := rr.Body.String()
+	want := `autolytiq_http_requests_total{method="GET",route="/deals/{id}",service="deal-service",status="200"} 1`
+	if !strings.Contains(body, want) {
+		t.Errorf("Expected %q in the metrics, got:\n%s", want, body)
+	}
+	if strings.Contains(body, `route="/metrics"`) {
+		t.Error("Expected scrapes not to be recorded")
+	}
+	if !strings.Contains(body, "go_goroutines") {
+		t.Error("Expected the Go runtime metrics")
+	}
+
+	// Each service's metrics are registered separately
+	if other := New("customer-service"); other.Registry() == m.Registry() {
+		t.Error("Expected a registry per Metrics")
+	}
+}
@@ -106,6 +106,57 @@ func GetUserRole(ctx context.Context) string {
 	return ""
 }
 
+type identityKey struct{}
+
+// requestIdentity carries an identity learned while handling a request back
+// out to RequestLoggingMiddleware, which logs the request's completion
+// before an inner middleware, such as JWT verification, has run
+type requestIdentity struct {
+	dealershipID string
+	userID       string
+}
+
+// SetIdentity returns a new context with the dealership and user ID set, and
+// records them for the request's completion log. Use it where the caller's
+// identity is verified, such as from token claims. Empty values are left
+// unchanged.
+func SetIdentity(ctx context.Context, dealershipID, userID string) context.Context {
+	identity, _ := ctx.Value(identityKey{}).(*requestIdentity)
+	if dealershipID != "" {
+		ctx = WithDealershipID(ctx, dealershipID)
+		if identity != nil {
+			identity.dealershipID = dealershipID
+		}
+	}
+	if userID != "" {
+		ctx = WithUserID(ctx, userID)
+		if identity != nil {
+			identity.userID = userID
+		}
+	}
+	return ctx
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a new context carrying the logger for FromContext
+func WithLogger(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the request's logger, set by RequestLoggingMiddleware,
+// with the context's trace, dealership and user fields. fallback is used if
+// the context has no logger.
+func FromContext(ctx context.Context, fallback *Logger) *Logger {
+	logger := fallback
+	if ctx != nil {
+		if l, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+			logger = l
+		}
+	}
+	return logger.WithContext(ctx)
+}
+
 // NewTraceID generates a new unique trace ID.
 func NewTraceID() string {
 	return uuid.New().String()
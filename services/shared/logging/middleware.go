@@ -1,7 +1,10 @@
 package logging
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"net"
 	"net/http"
 	"time"
 )
@@ -48,10 +51,41 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return rw.ResponseWriter.Write(b)
 }
 
+// Flush supports streaming responses through the wrapper
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack supports WebSocket upgrades through the wrapper
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	rw.statusCode = http.StatusSwitchingProtocols
+	rw.written = true
+	return h.Hijack()
+}
+
 // RequestIDMiddleware generates or propagates request IDs.
 // It reads X-Request-ID from incoming request headers and generates a new one if not present.
-// The request ID is added to the response headers and request context.
+// The request ID is added to the response headers and request context, along
+// with the dealership and user from the identity headers the gateway forwards.
 func RequestIDMiddleware(next http.Handler) http.Handler {
+	return requestIDMiddleware(next, true)
+}
+
+// EdgeRequestIDMiddleware is RequestIDMiddleware for services that receive
+// requests directly from clients, such as the gateway. Identity headers are
+// not trusted there, so the dealership and user must come from SetIdentity
+// once verified.
+func EdgeRequestIDMiddleware(next http.Handler) http.Handler {
+	return requestIDMiddleware(next, false)
+}
+
+func requestIDMiddleware(next http.Handler, trustIdentityHeaders bool) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Get or generate trace ID
 		traceID := r.Header.Get(RequestIDHeader)
@@ -64,6 +98,10 @@ func RequestIDMiddleware(next http.Handler) http.Handler {
 
 		// Create new context with trace ID
 		ctx := WithTraceID(r.Context(), traceID)
+		if !trustIdentityHeaders {
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
 
 		// Extract other context values from headers
 		if dealershipID := r.Header.Get(DealershipIDHeader); dealershipID != "" {
@@ -86,6 +124,8 @@ func RequestIDMiddleware(next http.Handler) http.Handler {
 
 // RequestLoggingMiddleware logs all HTTP requests with structured fields.
 // It should be used after RequestIDMiddleware to ensure trace_id is available.
+// The logger is added to the context for FromContext. Log lines carry the
+// request's trace, dealership and user IDs, never the user's email.
 func RequestLoggingMiddleware(logger *Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -96,6 +136,9 @@ func RequestLoggingMiddleware(logger *Logger) func(http.Handler) http.Handler {
 
 			// Get logger with context
 			ctxLogger := logger.WithContext(r.Context())
+			identity := &requestIdentity{}
+			ctx := context.WithValue(r.Context(), identityKey{}, identity)
+			r = r.WithContext(WithLogger(ctx, logger))
 
 			// Log request start (debug level)
 			ctxLogger.WithFields(map[string]interface{}{
@@ -110,6 +153,11 @@ func RequestLoggingMiddleware(logger *Logger) func(http.Handler) http.Handler {
 			// Calculate duration
 			duration := time.Since(start)
 
+			// Include an identity verified while handling the request
+			if identity.dealershipID != "" || identity.userID != "" {
+				ctxLogger = logger.WithContext(SetIdentity(r.Context(), identity.dealershipID, identity.userID))
+			}
+
 			// Log request completion
 			ctxLogger.RequestLog(r.Method, r.URL.Path, wrapped.statusCode, duration, nil)
 		})
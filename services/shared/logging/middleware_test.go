@@ -0,0 +1,135 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// logLines decodes the JSON log lines written to buf, keyed by message
+func logLines(t *testing.T, buf *bytes.Buffer) map[string]map[string]interface{} {
+	t.Helper()
+	lines := make(map[string]map[string]interface{})
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("Invalid log line %q: %v", scanner.Text(), err)
+		}
+		lines[line["message"].(string)] = line
+	}
+	return lines
+}
+
+func TestRequestLogsIncludeIdentityFromHeaders(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Config{Service: "test", Output: &buf})
+
+	handler := CombinedMiddleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context(), logger).Info("handling")
+	}))
+
+	req := httptest.NewRequest("GET", "/deals", nil)
+	req.Header.Set(DealershipIDHeader, "dealer-1")
+	req.Header.Set(UserIDHeader, "user-1")
+	req.Header.Set(UserEmailHeader, "jordan@example.com")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := logLines(t, &buf)
+	for _, message := range []string{"handling", "request completed"} {
+		line, ok := lines[message]
+		if !ok {
+			t.Fatalf("Expected a %q log line, got %v", message, lines)
+		}
+		if line["dealership_id"] != "dealer-1" || line["user_id"] != "user-1" {
+			t.Errorf("Expected %q to carry the dealership and user, got %v", message, line)
+		}
+		if line["trace_id"] == nil {
+			t.Errorf("Expected %q to carry the trace ID, got %v", message, line)
+		}
+		for _, value := range line {
+			if value == "jordan@example.com" {
+				t.Errorf("Expected %q not to log the user's email, got %v", message, line)
+			}
+		}
+	}
+}
+
+func TestEdgeRequestLogsIncludeVerifiedIdentity(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Config{Service: "test", Output: &buf})
+
+	// Stands in for token verification, which runs inside the logging middleware
+	verify := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(SetIdentity(r.Context(), "dealer-verified", "user-verified")))
+		})
+	}
+	handler := EdgeRequestIDMiddleware(RequestLoggingMiddleware(logger)(verify(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context(), logger).Info("handling")
+	}))))
+
+	// Client-supplied identity headers are not trusted at the edge
+	req := httptest.NewRequest("GET", "/deals", nil)
+	req.Header.Set(DealershipIDHeader, "dealer-spoofed")
+	req.Header.Set(UserIDHeader, "user-spoofed")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := logLines(t, &buf)
+	for _, message := range []string{"handling", "request completed"} {
+		line := lines[message]
+		if line["dealership_id"] != "dealer-verified" || line["user_id"] != "user-verified" {
+			t.Errorf("Expected %q to carry the verified identity, got %v", message, line)
+		}
+	}
+}
+
+func TestRequestLogsWithoutIdentity(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Config{Service: "test", Output: &buf})
+
+	handler := CombinedMiddleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+
+	line := logLines(t, &buf)["request completed"]
+	if _, ok := line["dealership_id"]; ok {
+		t.Errorf("Expected no dealership field, got %v", line)
+	}
+	if _, ok := line["user_id"]; ok {
+		t.Errorf("Expected no user field, got %v", line)
+	}
+}
+
+func TestFromContextFallback(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Config{Service: "test", Output: &buf})
+
+	ctx := WithDealershipID(WithUserID(httptest.NewRequest("GET", "/", nil).Context(), "user-1"), "dealer-1")
+	FromContext(ctx, logger).Info("background")
+
+	line := logLines(t, &buf)["background"]
+	if line["dealership_id"] != "dealer-1" || line["user_id"] != "user-1" {
+		t.Errorf("Expected the fallback logger to carry the context's fields, got %v", line)
+	}
+}
+
+func TestRequestIDMiddlewareReusesHeader(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Config{Service: "test", Output: &buf})
+	handler := CombinedMiddleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest("GET", "/deals", nil)
+	req.Header.Set(RequestIDHeader, "gateway-request-1")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get(RequestIDHeader); got != "gateway-request-1" {
+		t.Errorf("Expected the caller's request ID in the response, got %q", got)
+	}
+	if line := logLines(t, &buf)["request completed"]; line["trace_id"] != "gateway-request-1" {
+		t.Errorf("Expected the caller's request ID to be logged, got %v", line)
+	}
+}
@@ -0,0 +1,260 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Headers sent with each published event
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	TopicHeader          = "X-Outbox-Topic"
+	EventIDHeader        = "X-Outbox-Event-ID"
+	AttemptHeader        = "X-Outbox-Attempt"
+)
+
+// Publisher delivers an event to its consumers. An error leaves the event in
+// the outbox to be retried.
+type Publisher interface {
+	Publish(ctx context.Context, event *Event) error
+}
+
+// PublisherFunc adapts a function to a Publisher
+type PublisherFunc func(ctx context.Context, event *Event) error
+
+// Publish calls f
+func (f PublisherFunc) Publish(ctx context.Context, event *Event) error {
+	return f(ctx, event)
+}
+
+// HTTPPublisher posts events as JSON to a URL per topic
+type HTTPPublisher struct {
+	routes     map[string]string
+	httpClient *http.Client
+}
+
+// NewHTTPPublisher creates a publisher that posts each event to the URL for
+// its topic in routes, or to routes["*"] when its topic has no route
+func NewHTTPPublisher(routes map[string]string, timeout time.Duration) *HTTPPublisher {
+	return &HTTPPublisher{
+		routes:     routes,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// Publish posts the event. Consumers should use the Idempotency-Key header
+// to discard events they have already processed; any non-2xx response is
+// treated as a failure and retried.
+func (p *HTTPPublisher) Publish(ctx context.Context, event *Event) error {
+	url, ok := p.routes[event.Topic]
+	if !ok {
+		url, ok = p.routes["*"]
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoRoute, event.Topic)
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode outbox event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IdempotencyKeyHeader, event.Key)
+	req.Header.Set(TopicHeader, event.Topic)
+	req.Header.Set(EventIDHeader, event.ID)
+	req.Header.Set(AttemptHeader, strconv.Itoa(event.Attempts+1))
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to publish outbox event: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("publishing outbox event %s returned status %d", event.ID, resp.StatusCode)
+	}
+	return nil
+}
+
+// DispatcherConfig controls how a Dispatcher publishes events
+type DispatcherConfig struct {
+	// Interval is how often the outbox is polled
+	Interval time.Duration
+
+	// BatchSize is the most events claimed per poll
+	BatchSize int
+
+	// Lease is how long a claimed event is reserved for this dispatcher.
+	// It must be longer than a publish attempt can take.
+	Lease time.Duration
+
+	// MaxAttempts is how many times an event is tried before it is
+	// abandoned. Zero retries forever.
+	MaxAttempts int
+
+	// BaseBackoff is the delay before the first retry; each further retry
+	// doubles it, up to MaxBackoff
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+
+	// OnError, if set, is called for every failed publish attempt; dead
+	// reports whether the event has been abandoned
+	OnError func(event *Event, err error, dead bool)
+}
+
+// DefaultDispatcherConfig returns a DispatcherConfig with sensible defaults
+func DefaultDispatcherConfig() DispatcherConfig {
+	return DispatcherConfig{
+		Interval:    time.Second,
+		BatchSize:   50,
+		Lease:       time.Minute,
+		MaxAttempts: 10,
+		BaseBackoff: time.Second,
+		MaxBackoff:  5 * time.Minute,
+	}
+}
+
+// backoff returns the delay after the given number of failed attempts
+func (c DispatcherConfig) backoff(attempts int) time.Duration {
+	delay := c.BaseBackoff
+	for i := 1; i < attempts && delay < c.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if c.MaxBackoff > 0 && delay > c.MaxBackoff {
+		delay = c.MaxBackoff
+	}
+	return delay
+}
+
+// Dispatcher publishes events from a Store in the background
+type Dispatcher struct {
+	store     Store
+	publisher Publisher
+	config    DispatcherConfig
+	now       func() time.Time
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+	running  bool
+	mu       sync.Mutex
+}
+
+// NewDispatcher creates a dispatcher publishing store's events with publisher
+func NewDispatcher(store Store, publisher Publisher, config DispatcherConfig) *Dispatcher {
+	return &Dispatcher{
+		store:     store,
+		publisher: publisher,
+		config:    config,
+		now:       time.Now,
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// Start begins polling the outbox
+func (d *Dispatcher) Start() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.running {
+		return
+	}
+	d.running = true
+
+	d.wg.Add(1)
+	go d.run()
+}
+
+// Stop stops polling and waits for an in-progress batch to finish
+func (d *Dispatcher) Stop() {
+	d.mu.Lock()
+	if !d.running {
+		d.mu.Unlock()
+		return
+	}
+	d.running = false
+	d.mu.Unlock()
+
+	close(d.stopChan)
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) run() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.stopChan:
+			return
+		case <-ticker.C:
+			// Keep draining while full batches are returned
+			for {
+				n, err := d.DispatchOnce(context.Background())
+				if err != nil || n < d.config.BatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// DispatchOnce claims one batch of due events and publishes each of them. It
+// returns the number of events claimed. A failed publish schedules a retry
+// rather than returning an error.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	events, err := d.store.Claim(ctx, d.now(), d.config.Lease, d.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		if err := d.publisher.Publish(ctx, event); err != nil {
+			d.fail(ctx, event, err)
+			continue
+		}
+		if err := d.store.MarkPublished(ctx, event.ID, d.now()); err != nil {
+			// The event is redelivered once its lease expires, which
+			// consumers absorb through its dedup key
+			d.report(event, err, false)
+		}
+	}
+	return len(events), nil
+}
+
+// fail records a failed publish, abandoning the event once it has used all
+// of its attempts
+func (d *Dispatcher) fail(ctx context.Context, event *Event, cause error) {
+	attempts := event.Attempts + 1
+	now := d.now()
+
+	dead := d.config.MaxAttempts > 0 && attempts >= d.config.MaxAttempts
+	var err error
+	if dead {
+		err = d.store.MarkDead(ctx, event.ID, attempts, now, cause.Error())
+	} else {
+		err = d.store.MarkRetry(ctx, event.ID, attempts, now.Add(d.config.backoff(attempts)), cause.Error())
+	}
+
+	d.report(event, cause, dead)
+	if err != nil {
+		d.report(event, err, false)
+	}
+}
+
+// report passes an error to the configured handler
+func (d *Dispatcher) report(event *Event, err error, dead bool) {
+	if d.config.OnError != nil {
+		d.config.OnError(event, err, dead)
+	}
+}
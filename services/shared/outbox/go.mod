@@ -0,0 +1,3 @@
+module autolytiq/shared/outbox
+
+go 1.18
@@ -0,0 +1,220 @@
+// Package outbox delivers cross-service events reliably. A service writes
+// events to its outbox table in the same transaction as the business change
+// they describe, so an event exists if and only if the change committed. A
+// Dispatcher then publishes pending events with at-least-once delivery,
+// retrying failures with backoff. Every event carries a dedup key that
+// consumers use to ignore redeliveries.
+package outbox
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Common errors
+var (
+	ErrInvalidEvent = errors.New("invalid outbox event")
+	ErrNoRoute      = errors.New("no publish route for outbox topic")
+)
+
+// Schema creates the outbox table. Services include it in their schema
+// initialization.
+const Schema = `
+	CREATE TABLE IF NOT EXISTS outbox (
+		id VARCHAR(36) PRIMARY KEY,
+		topic VARCHAR(100) NOT NULL,
+		dedup_key VARCHAR(255) NOT NULL UNIQUE,
+		payload JSONB NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		available_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		locked_until TIMESTAMP,
+		last_error TEXT,
+		published_at TIMESTAMP,
+		dead_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(available_at)
+		WHERE published_at IS NULL AND dead_at IS NULL;
+`
+
+// Event is a message waiting in, or published from, the outbox
+type Event struct {
+	ID    string `json:"id"`
+	Topic string `json:"topic"`
+
+	// Key identifies the change the event describes. Writing a second event
+	// with the same key is a no-op, and consumers use it to discard
+	// redeliveries. Defaults to the event ID.
+	Key string `json:"key"`
+
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+
+	// Attempts is the number of publish attempts made before this one
+	Attempts int `json:"attempts"`
+}
+
+// NewEvent creates an event with payload encoded as JSON
+func NewEvent(topic, key string, payload interface{}) (*Event, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+	}
+	return &Event{Topic: topic, Key: key, Payload: encoded}, nil
+}
+
+// Execer runs a statement; *sql.Tx satisfies it
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Write adds an event to the outbox using tx, which should be the
+// transaction making the change the event describes. The event is only
+// visible to the dispatcher once tx commits, and is discarded if it rolls
+// back.
+func Write(ctx context.Context, tx Execer, event *Event) error {
+	if event.Topic == "" {
+		return fmt.Errorf("%w: topic is required", ErrInvalidEvent)
+	}
+	if len(event.Payload) == 0 {
+		event.Payload = json.RawMessage("null")
+	}
+	if event.ID == "" {
+		id, err := newID()
+		if err != nil {
+			return err
+		}
+		event.ID = id
+	}
+	if event.Key == "" {
+		event.Key = event.ID
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now().UTC()
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO outbox (id, topic, dedup_key, payload, available_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+		ON CONFLICT (dedup_key) DO NOTHING
+	`, event.ID, event.Topic, event.Key, []byte(event.Payload), event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to write outbox event: %w", err)
+	}
+	return nil
+}
+
+// newID returns a random UUID
+func newID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate outbox event ID: %w", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// Store holds pending events for a Dispatcher
+type Store interface {
+	// Claim leases up to limit events that are due at now, oldest first. A
+	// leased event is not claimed again until the lease expires, so several
+	// dispatchers can share a table.
+	Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*Event, error)
+	MarkPublished(ctx context.Context, id string, at time.Time) error
+	// MarkRetry records a failed attempt and schedules the next one
+	MarkRetry(ctx context.Context, id string, attempts int, retryAt time.Time, cause string) error
+	// MarkDead stops retrying an event that has used all its attempts
+	MarkDead(ctx context.Context, id string, attempts int, at time.Time, cause string) error
+}
+
+// SQLStore is a Store backed by the outbox table in PostgreSQL
+type SQLStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore creates a store for the outbox table in db
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// Claim leases due events, skipping rows another dispatcher has locked
+func (s *SQLStore) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*Event, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE outbox SET locked_until = $2
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE published_at IS NULL AND dead_at IS NULL AND available_at <= $1
+				AND (locked_until IS NULL OR locked_until <= $1)
+			ORDER BY created_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, topic, dedup_key, payload, attempts, created_at
+	`, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*Event
+	for rows.Next() {
+		var e Event
+		var payload []byte
+		if err := rows.Scan(&e.ID, &e.Topic, &e.Key, &payload, &e.Attempts, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		e.Payload = payload
+		events = append(events, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING does not preserve the subquery's order
+	sort.Slice(events, func(i, j int) bool { return events[i].CreatedAt.Before(events[j].CreatedAt) })
+	return events, nil
+}
+
+// MarkPublished records that an event was delivered
+func (s *SQLStore) MarkPublished(ctx context.Context, id string, at time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE outbox SET published_at = $2, attempts = attempts + 1, locked_until = NULL, last_error = NULL
+		WHERE id = $1
+	`, id, at)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox event published: %w", err)
+	}
+	return nil
+}
+
+// MarkRetry records a failed attempt and when to try again
+func (s *SQLStore) MarkRetry(ctx context.Context, id string, attempts int, retryAt time.Time, cause string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE outbox SET attempts = $2, available_at = $3, last_error = $4, locked_until = NULL
+		WHERE id = $1
+	`, id, attempts, retryAt, cause)
+	if err != nil {
+		return fmt.Errorf("failed to schedule outbox event retry: %w", err)
+	}
+	return nil
+}
+
+// MarkDead records that an event will not be retried
+func (s *SQLStore) MarkDead(ctx context.Context, id string, attempts int, at time.Time, cause string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE outbox SET attempts = $2, dead_at = $3, last_error = $4, locked_until = NULL
+		WHERE id = $1
+	`, id, attempts, at, cause)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox event dead: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,395 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDriver is a database/sql driver that records the arguments of each
+// statement, making them visible only once their transaction commits
+type fakeDriver struct {
+	mu        sync.Mutex
+	committed map[string][][]driver.Value // DSN -> committed statement args
+}
+
+var testDriver = &fakeDriver{committed: make(map[string][][]driver.Value)}
+
+func init() {
+	sql.Register("outboxtest", testDriver)
+}
+
+func (d *fakeDriver) Open(dsn string) (driver.Conn, error) {
+	return &fakeConn{driver: d, dsn: dsn}, nil
+}
+
+func (d *fakeDriver) rows(dsn string) [][]driver.Value {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([][]driver.Value(nil), d.committed[dsn]...)
+}
+
+type fakeConn struct {
+	driver  *fakeDriver
+	dsn     string
+	pending [][]driver.Value
+	inTx    bool
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return &fakeStmt{conn: c}, nil }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.inTx = true
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.driver.mu.Lock()
+	c.driver.committed[c.dsn] = append(c.driver.committed[c.dsn], c.pending...)
+	c.driver.mu.Unlock()
+	c.pending, c.inTx = nil, false
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.pending, c.inTx = nil, false
+	return nil
+}
+
+type fakeStmt struct{ conn *fakeConn }
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if s.conn.inTx {
+		s.conn.pending = append(s.conn.pending, args)
+	} else {
+		s.conn.driver.mu.Lock()
+		s.conn.driver.committed[s.conn.dsn] = append(s.conn.driver.committed[s.conn.dsn], args)
+		s.conn.driver.mu.Unlock()
+	}
+	return driver.RowsAffected(1), nil
+}
+
+// memoryStore is a Store over the events committed through fakeDriver
+type memoryStore struct {
+	dsn    string
+	events map[string]*storedEvent
+}
+
+type storedEvent struct {
+	event       Event
+	availableAt time.Time
+	lockedUntil time.Time
+	published   bool
+	dead        bool
+	lastError   string
+}
+
+func newMemoryStore(dsn string) *memoryStore {
+	return &memoryStore{dsn: dsn, events: make(map[string]*storedEvent)}
+}
+
+// sync loads newly committed inserts, ignoring duplicate dedup keys as the
+// outbox table's unique constraint does
+func (s *memoryStore) sync() {
+	keys := make(map[string]bool)
+	for _, e := range s.events {
+		keys[e.event.Key] = true
+	}
+	for _, args := range testDriver.rows(s.dsn) {
+		id := args[0].(string)
+		key := args[2].(string)
+		if _, ok := s.events[id]; ok || keys[key] {
+			continue
+		}
+		keys[key] = true
+		createdAt := args[4].(time.Time)
+		s.events[id] = &storedEvent{
+			event:       Event{ID: id, Topic: args[1].(string), Key: key, Payload: args[3].([]byte), CreatedAt: createdAt},
+			availableAt: createdAt,
+		}
+	}
+}
+
+func (s *memoryStore) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*Event, error) {
+	s.sync()
+	var due []*storedEvent
+	for _, e := range s.events {
+		if !e.published && !e.dead && !e.availableAt.After(now) && !e.lockedUntil.After(now) {
+			due = append(due, e)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].event.CreatedAt.Before(due[j].event.CreatedAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	events := make([]*Event, len(due))
+	for i, e := range due {
+		e.lockedUntil = now.Add(lease)
+		event := e.event
+		events[i] = &event
+	}
+	return events, nil
+}
+
+func (s *memoryStore) MarkPublished(ctx context.Context, id string, at time.Time) error {
+	e := s.events[id]
+	e.published = true
+	e.event.Attempts++
+	e.lockedUntil = time.Time{}
+	return nil
+}
+
+func (s *memoryStore) MarkRetry(ctx context.Context, id string, attempts int, retryAt time.Time, cause string) error {
+	e := s.events[id]
+	e.event.Attempts = attempts
+	e.availableAt = retryAt
+	e.lastError = cause
+	e.lockedUntil = time.Time{}
+	return nil
+}
+
+func (s *memoryStore) MarkDead(ctx context.Context, id string, attempts int, at time.Time, cause string) error {
+	e := s.events[id]
+	e.event.Attempts = attempts
+	e.dead = true
+	e.lastError = cause
+	e.lockedUntil = time.Time{}
+	return nil
+}
+
+// recordingPublisher records published events, failing the first failures
+// attempts
+type recordingPublisher struct {
+	failures  int
+	published []*Event
+	attempts  int
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event *Event) error {
+	p.attempts++
+	if p.attempts <= p.failures {
+		return errors.New("consumer unavailable")
+	}
+	p.published = append(p.published, event)
+	return nil
+}
+
+func openTestDB(t *testing.T) (*sql.DB, *memoryStore) {
+	t.Helper()
+	db, err := sql.Open("outboxtest", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db, newMemoryStore(t.Name())
+}
+
+func writeInTx(t *testing.T, db *sql.DB, event *Event) *sql.Tx {
+	t.Helper()
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(context.Background(), tx, event); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func TestEventPublishedAfterCommit(t *testing.T) {
+	db, store := openTestDB(t)
+	publisher := &recordingPublisher{}
+	dispatcher := NewDispatcher(store, publisher, DefaultDispatcherConfig())
+	ctx := context.Background()
+
+	event, err := NewEvent("deal.funded", "deal-1:funded", map[string]string{"deal_id": "deal-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := writeInTx(t, db, event)
+
+	if n, _ := dispatcher.DispatchOnce(ctx); n != 0 {
+		t.Fatalf("Expected the uncommitted event not to be dispatched, got %d", n)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := dispatcher.DispatchOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("Expected the committed event to be dispatched, got %d, %v", n, err)
+	}
+	if len(publisher.published) != 1 {
+		t.Fatalf("Expected one published event, got %d", len(publisher.published))
+	}
+	published := publisher.published[0]
+	if published.ID != event.ID || published.Key != "deal-1:funded" || published.Topic != "deal.funded" {
+		t.Errorf("Unexpected published event %+v", published)
+	}
+	if string(published.Payload) != `{"deal_id":"deal-1"}` {
+		t.Errorf("Unexpected payload %s", published.Payload)
+	}
+
+	// Published events are not delivered again
+	if n, _ := dispatcher.DispatchOnce(ctx); n != 0 {
+		t.Errorf("Expected nothing left to dispatch, got %d", n)
+	}
+
+	// A rolled back write never reaches the consumer
+	rolledBack, _ := NewEvent("deal.funded", "deal-2:funded", nil)
+	if err := writeInTx(t, db, rolledBack).Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	// Nor does a second event with a key that was already written
+	duplicate, _ := NewEvent("deal.funded", "deal-1:funded", nil)
+	if err := writeInTx(t, db, duplicate).Commit(); err != nil {
+		t.Fatal(err)
+	}
+	dispatcher.DispatchOnce(ctx)
+	if len(publisher.published) != 1 {
+		t.Errorf("Expected rolled back and duplicate events not to be published, got %d", len(publisher.published))
+	}
+}
+
+func TestFailedPublishIsRetried(t *testing.T) {
+	db, store := openTestDB(t)
+	publisher := &recordingPublisher{failures: 2}
+
+	var reported []error
+	config := DefaultDispatcherConfig()
+	config.OnError = func(event *Event, err error, dead bool) {
+		if dead {
+			t.Error("Expected the event not to be abandoned")
+		}
+		reported = append(reported, err)
+	}
+	dispatcher := NewDispatcher(store, publisher, config)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	dispatcher.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	event, _ := NewEvent("customer.updated", "", map[string]string{"customer_id": "c1"})
+	event.CreatedAt = now
+	if err := writeInTx(t, db, event).Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher.DispatchOnce(ctx)
+	if len(publisher.published) != 0 || len(reported) != 1 {
+		t.Fatalf("Expected the first attempt to fail and be reported")
+	}
+	stored := store.events[event.ID]
+	if stored.event.Attempts != 1 || !stored.availableAt.Equal(now.Add(time.Second)) || stored.lastError == "" {
+		t.Errorf("Expected a retry in 1s after one attempt, got %+v", stored)
+	}
+
+	// Not retried before its backoff has elapsed
+	if n, _ := dispatcher.DispatchOnce(ctx); n != 0 {
+		t.Error("Expected no retry before the backoff elapsed")
+	}
+
+	now = now.Add(time.Second)
+	dispatcher.DispatchOnce(ctx)
+	if stored.event.Attempts != 2 || !stored.availableAt.Equal(now.Add(2*time.Second)) {
+		t.Errorf("Expected the backoff to double after the second failure, got %+v", stored)
+	}
+
+	now = now.Add(2 * time.Second)
+	dispatcher.DispatchOnce(ctx)
+	if len(publisher.published) != 1 || publisher.published[0].Key != event.ID {
+		t.Fatalf("Expected the third attempt to publish the event keyed by its ID, got %v", publisher.published)
+	}
+	if publisher.published[0].Attempts != 2 {
+		t.Errorf("Expected the event to report two earlier attempts, got %d", publisher.published[0].Attempts)
+	}
+	if !stored.published {
+		t.Error("Expected the event to be marked published")
+	}
+}
+
+func TestEventAbandonedAfterMaxAttempts(t *testing.T) {
+	db, store := openTestDB(t)
+	publisher := &recordingPublisher{failures: 100}
+
+	var dead int
+	config := DefaultDispatcherConfig()
+	config.MaxAttempts = 3
+	config.OnError = func(event *Event, err error, abandoned bool) {
+		if abandoned {
+			dead++
+		}
+	}
+	dispatcher := NewDispatcher(store, publisher, config)
+	now := time.Now()
+	dispatcher.now = func() time.Time { return now }
+
+	event, _ := NewEvent("gdpr.deleted", "req-1", nil)
+	event.CreatedAt = now
+	writeInTx(t, db, event).Commit()
+
+	for i := 0; i < 5; i++ {
+		dispatcher.DispatchOnce(context.Background())
+		now = now.Add(time.Hour)
+	}
+	if publisher.attempts != 3 || dead != 1 || !store.events[event.ID].dead {
+		t.Errorf("Expected 3 attempts and the event abandoned, got %d attempts and %d dead", publisher.attempts, dead)
+	}
+}
+
+func TestHTTPPublisher(t *testing.T) {
+	status := http.StatusAccepted
+	var got http.Header
+	var body Event
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(status)
+	}))
+	defer consumer.Close()
+
+	publisher := NewHTTPPublisher(map[string]string{"deal.funded": consumer.URL}, time.Second)
+	event := &Event{ID: "evt-1", Topic: "deal.funded", Key: "deal-1:funded", Payload: json.RawMessage(`{"a":1}`), Attempts: 1}
+	if err := publisher.Publish(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if got.Get(IdempotencyKeyHeader) != "deal-1:funded" || got.Get(TopicHeader) != "deal.funded" || got.Get(AttemptHeader) != "2" {
+		t.Errorf("Unexpected headers %v", got)
+	}
+	if body.ID != "evt-1" || string(body.Payload) != `{"a":1}` {
+		t.Errorf("Unexpected body %+v", body)
+	}
+
+	status = http.StatusServiceUnavailable
+	if err := publisher.Publish(context.Background(), event); err == nil {
+		t.Error("Expected a non-2xx response to fail the publish")
+	}
+
+	event.Topic = "unrouted"
+	if err := publisher.Publish(context.Background(), event); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("Expected ErrNoRoute, got %v", err)
+	}
+}
+
+func TestDispatcherBackoff(t *testing.T) {
+	config := DispatcherConfig{BaseBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 30: 10 * time.Second} {
+		if got := config.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module autolytiq/shared/residency
+
+go 1.18
@@ -0,0 +1,174 @@
+// Package residency keeps customer data in the region it must reside in.
+// Each dealership, and optionally each customer, has a data region; storage
+// backends are configured per region, and moving data to a backend in a
+// different region is rejected unless explicitly allowed.
+package residency
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Data regions
+const (
+	RegionUS = "us"
+	RegionEU = "eu"
+
+	// DefaultRegion is used for dealerships and customers with no region set
+	DefaultRegion = RegionUS
+)
+
+// Regions lists the supported data regions
+var Regions = []string{RegionUS, RegionEU}
+
+// Common errors
+var (
+	ErrUnknownRegion = errors.New("unknown data region")
+	ErrCrossRegion   = errors.New("cross-region data transfer is not allowed")
+	ErrNoBackend     = errors.New("no storage backend configured for region")
+)
+
+// SettingKey is the config-service setting holding a dealership's data region
+const SettingKey = "data_region"
+
+// AllowCrossRegionEnv enables transfers between regions when set to "true"
+const AllowCrossRegionEnv = "DATA_RESIDENCY_ALLOW_CROSS_REGION"
+
+// Normalize returns the canonical form of a region. An empty region is the
+// default region.
+func Normalize(region string) (string, error) {
+	region = strings.ToLower(strings.TrimSpace(region))
+	if region == "" {
+		return DefaultRegion, nil
+	}
+	for _, r := range Regions {
+		if region == r {
+			return region, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownRegion, region)
+}
+
+// Resolve returns the region of a customer's data: the customer's own region
+// when set, otherwise the dealership's
+func Resolve(customerRegion, dealershipRegion string) (string, error) {
+	if strings.TrimSpace(customerRegion) != "" {
+		return Normalize(customerRegion)
+	}
+	return Normalize(dealershipRegion)
+}
+
+// Backend is the storage used for one data region
+type Backend struct {
+	Region        string // data region, e.g. eu
+	Bucket        string
+	StorageRegion string // provider region, e.g. eu-west-1
+	Endpoint      string // optional S3-compatible endpoint
+}
+
+// Router selects the storage backend for a data region
+type Router struct {
+	backends         map[string]Backend
+	allowCrossRegion bool
+}
+
+// NewRouter creates a router over the given backends
+func NewRouter(allowCrossRegion bool, backends ...Backend) *Router {
+	r := &Router{
+		backends:         make(map[string]Backend),
+		allowCrossRegion: allowCrossRegion,
+	}
+	for _, b := range backends {
+		region, err := Normalize(b.Region)
+		if err != nil || b.Bucket == "" {
+			continue
+		}
+		b.Region = region
+		r.backends[region] = b
+	}
+	return r
+}
+
+// FromEnv creates a router from <prefix>_BUCKET_<REGION>,
+// <prefix>_REGION_<REGION> and <prefix>_ENDPOINT_<REGION>, e.g.
+// S3_BUCKET_EU. fallback is used for the default region when it has no
+// bucket of its own, so single-region deployments keep their existing
+// settings.
+func FromEnv(prefix string, fallback Backend) *Router {
+	var backends []Backend
+	for _, region := range Regions {
+		suffix := "_" + strings.ToUpper(region)
+		b := Backend{
+			Region:        region,
+			Bucket:        os.Getenv(prefix + "_BUCKET" + suffix),
+			StorageRegion: os.Getenv(prefix + "_REGION" + suffix),
+			Endpoint:      os.Getenv(prefix + "_ENDPOINT" + suffix),
+		}
+		if b.Bucket == "" && region == DefaultRegion {
+			b = fallback
+			b.Region = region
+		}
+		backends = append(backends, b)
+	}
+	return NewRouter(os.Getenv(AllowCrossRegionEnv) == "true", backends...)
+}
+
+// Enabled reports whether any backend is configured
+func (r *Router) Enabled() bool {
+	return r != nil && len(r.backends) > 0
+}
+
+// Backend returns the backend for a region
+func (r *Router) Backend(region string) (Backend, error) {
+	region, err := Normalize(region)
+	if err != nil {
+		return Backend{}, err
+	}
+	b, ok := r.backends[region]
+	if !ok {
+		return Backend{}, fmt.Errorf("%w: %s", ErrNoBackend, region)
+	}
+	return b, nil
+}
+
+// BackendForBucket returns the backend that owns a bucket
+func (r *Router) BackendForBucket(bucket string) (Backend, bool) {
+	for _, b := range r.backends {
+		if b.Bucket == bucket {
+			return b, true
+		}
+	}
+	return Backend{}, false
+}
+
+// CheckTransfer returns ErrCrossRegion if data in one region may not be moved
+// to another
+func (r *Router) CheckTransfer(from, to string) error {
+	from, err := Normalize(from)
+	if err != nil {
+		return err
+	}
+	to, err = Normalize(to)
+	if err != nil {
+		return err
+	}
+	if from != to && !r.allowCrossRegion {
+		return fmt.Errorf("%w: %s to %s", ErrCrossRegion, from, to)
+	}
+	return nil
+}
+
+// Target returns the backend to store data from dataRegion in. requested
+// overrides the destination region and must pass CheckTransfer; when empty,
+// the data's own region is used.
+func (r *Router) Target(dataRegion, requested string) (Backend, error) {
+	if strings.TrimSpace(requested) == "" {
+		return r.Backend(dataRegion)
+	}
+	if err := r.CheckTransfer(dataRegion, requested); err != nil {
+		return Backend{}, err
+	}
+	return r.Backend(requested)
+}
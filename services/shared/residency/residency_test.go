@@ -0,0 +1,81 @@
+package residency
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		customer, dealership, want string
+		wantErr                    error
+	}{
+		{"", "", RegionUS, nil},
+		{"", "EU", RegionEU, nil},
+		{" eu ", "us", RegionEU, nil},
+		{"us", "eu", RegionUS, nil},
+		{"", "apac", "", ErrUnknownRegion},
+	}
+
+	for _, tt := range tests {
+		got, err := Resolve(tt.customer, tt.dealership)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("Resolve(%q, %q) error = %v, want %v", tt.customer, tt.dealership, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("Resolve(%q, %q) = %q, want %q", tt.customer, tt.dealership, got, tt.want)
+		}
+	}
+}
+
+func TestRouterTarget(t *testing.T) {
+	router := NewRouter(false,
+		Backend{Region: "us", Bucket: "exports-us", StorageRegion: "us-east-1"},
+		Backend{Region: "EU", Bucket: "exports-eu", StorageRegion: "eu-west-1"},
+	)
+
+	b, err := router.Target(RegionEU, "")
+	if err != nil || b.Bucket != "exports-eu" {
+		t.Fatalf("Expected EU data to use the EU bucket, got %+v, %v", b, err)
+	}
+
+	if _, err := router.Target(RegionEU, RegionUS); !errors.Is(err, ErrCrossRegion) {
+		t.Errorf("Expected ErrCrossRegion moving EU data to the US, got %v", err)
+	}
+
+	allowed := NewRouter(true, Backend{Region: "us", Bucket: "exports-us"})
+	if b, err := allowed.Target(RegionEU, RegionUS); err != nil || b.Bucket != "exports-us" {
+		t.Errorf("Expected an allowed transfer to use the US bucket, got %+v, %v", b, err)
+	}
+	if _, err := allowed.Target(RegionEU, ""); !errors.Is(err, ErrNoBackend) {
+		t.Errorf("Expected ErrNoBackend with no EU bucket, got %v", err)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("EXPORT_BUCKET_EU", "exports-eu")
+	t.Setenv("EXPORT_REGION_EU", "eu-west-1")
+	t.Setenv("EXPORT_ENDPOINT_EU", "https://s3.eu-west-1.amazonaws.com")
+	t.Setenv(AllowCrossRegionEnv, "")
+
+	router := FromEnv("EXPORT", Backend{Bucket: "exports", StorageRegion: "us-east-1"})
+
+	eu, err := router.Backend(RegionEU)
+	if err != nil || eu.Bucket != "exports-eu" || eu.StorageRegion != "eu-west-1" || eu.Endpoint == "" {
+		t.Errorf("Expected EU backend from the environment, got %+v, %v", eu, err)
+	}
+	us, err := router.Backend(RegionUS)
+	if err != nil || us.Bucket != "exports" || us.Region != RegionUS {
+		t.Errorf("Expected the fallback for the default region, got %+v, %v", us, err)
+	}
+	if b, ok := router.BackendForBucket("exports-eu"); !ok || b.Region != RegionEU {
+		t.Errorf("Expected exports-eu to belong to the EU backend, got %+v", b)
+	}
+	if err := router.CheckTransfer(RegionEU, RegionUS); !errors.Is(err, ErrCrossRegion) {
+		t.Errorf("Expected cross-region transfer to be rejected by default, got %v", err)
+	}
+
+	if (&Router{}).Enabled() || FromEnv("UNSET", Backend{}).Enabled() {
+		t.Error("Expected a router with no buckets to be disabled")
+	}
+}
@@ -0,0 +1,246 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Export batching
+const (
+	exportQueueSize     = 2048
+	exportBatchSize     = 512
+	exportInterval      = 5 * time.Second
+	exportClientTimeout = 10 * time.Second
+)
+
+// instrumentationScope names this package as the spans' source
+const instrumentationScope = "autolytiq/shared/tracing"
+
+// exporter sends ended spans to an OTLP/HTTP collector in batches. Spans
+// ended while the queue is full are dropped rather than slow requests down.
+type exporter struct {
+	service string
+	url     string
+	client  *http.Client
+	onError func(error)
+
+	queue chan *Span
+	done  chan struct{}
+
+	stopOnce sync.Once
+	stopped  chan struct{}
+}
+
+func newExporter(service, endpoint string, onError func(error)) *exporter {
+	e := &exporter{
+		service: service,
+		url:     strings.TrimRight(endpoint, "/") + "/v1/traces",
+		client:  &http.Client{Timeout: exportClientTimeout},
+		onError: onError,
+		queue:   make(chan *Span, exportQueueSize),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// enqueue queues an ended span for export
+func (e *exporter) enqueue(span *Span) {
+	select {
+	case <-e.done:
+	case e.queue <- span:
+	default:
+	}
+}
+
+// run exports a batch when it is full, every exportInterval, and on
+// shutdown
+func (e *exporter) run() {
+	defer close(e.stopped)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	send := func() {
+		if len(batch) > 0 {
+			e.export(batch)
+			batch = nil
+		}
+	}
+	for {
+		select {
+		case span := <-e.queue:
+			if batch = append(batch, span); len(batch) >= exportBatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case <-e.done:
+			batch = e.drain(batch)
+			send()
+			return
+		}
+	}
+}
+
+// drain adds the queued spans to the batch
+func (e *exporter) drain(batch []*Span) []*Span {
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+		default:
+			return batch
+		}
+	}
+}
+
+// shutdown exports the queued spans and stops the exporter, giving up when
+// ctx is done
+func (e *exporter) shutdown(ctx context.Context) error {
+	e.stopOnce.Do(func() { close(e.done) })
+	select {
+	case <-e.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// export sends one batch, reporting a failure to onError
+func (e *exporter) export(batch []*Span) {
+	if err := e.post(batch); err != nil && e.onError != nil {
+		e.onError(fmt.Errorf("failed to export %d spans: %w", len(batch), err))
+	}
+}
+
+func (e *exporter) post(batch []*Span) error {
+	body, err := json.Marshal(e.request(batch))
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// OTLP/HTTP JSON encoding of an ExportTraceServiceRequest. IDs are hex and
+// 64-bit integers are decimal strings, as the OTLP spec requires.
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+func (e *exporter) request(batch []*Span) otlpRequest {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, span := range batch {
+		spans = append(spans, span.otlp())
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpAttribute{otlpAttr("service.name", e.service)}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: instrumentationScope}, Spans: spans}},
+	}}}
+}
+
+func (s *Span) otlp() otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	span := otlpSpan{
+		TraceID:           s.context.TraceID.String(),
+		SpanID:            s.context.SpanID.String(),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		Status:            otlpStatus{Code: s.status, Message: s.statusMessage},
+	}
+	if s.parentID != (SpanID{}) {
+		span.ParentSpanID = s.parentID.String()
+	}
+	for _, attr := range s.attributes {
+		span.Attributes = append(span.Attributes, otlpAttr(attr.key, attr.value))
+	}
+	return span
+}
+
+func otlpAttr(key string, value interface{}) otlpAttribute {
+	attr := otlpAttribute{Key: key}
+	switch v := value.(type) {
+	case string:
+		attr.Value.StringValue = &v
+	case int:
+		i := strconv.Itoa(v)
+		attr.Value.IntValue = &i
+	case int64:
+		i := strconv.FormatInt(v, 10)
+		attr.Value.IntValue = &i
+	case bool:
+		attr.Value.BoolValue = &v
+	default:
+		str := fmt.Sprint(v)
+		attr.Value.StringValue = &str
+	}
+	return attr
+}
@@ -0,0 +1,134 @@
+package tracing
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// requestIDHeader is the header RequestIDMiddleware takes the request ID
+// from
+const requestIDHeader = "X-Request-ID"
+
+// Middleware starts a server span for each request, continuing the caller's
+// trace when it sent a traceparent. Unless the caller sent a request ID the
+// trace ID is used as one, so logs and traces correlate. It must be
+// installed with the router's Use, before RequestIDMiddleware.
+func (t *Tracer) Middleware(next http.Handler) http.Handler {
+	if !t.Enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parent, _ := ParseTraceparent(r.Header.Get(TraceparentHeader))
+		route := routeTemplate(r)
+		span := t.start(r.Method+" "+route, kindServer, parent)
+		span.SetAttribute("http.request.method", r.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("url.path", r.URL.Path)
+
+		if r.Header.Get(requestIDHeader) == "" {
+			r.Header.Set(requestIDHeader, span.SpanContext().TraceID.String())
+		}
+
+		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(wrapped, r.WithContext(ContextWithSpan(r.Context(), span)))
+
+		span.SetAttribute("http.response.status_code", wrapped.statusCode)
+		if wrapped.statusCode >= http.StatusInternalServerError {
+			span.SetError(http.StatusText(wrapped.statusCode))
+		}
+		span.End()
+	})
+}
+
+// Transport wraps base, or http.DefaultTransport if nil, to record a client
+// span for each request made within a traced request and send the trace on
+// in the traceparent header
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	parent := SpanFromContext(req.Context())
+	if parent == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	span := parent.tracer.start(req.Method, kindClient, parent.SpanContext())
+	defer span.End()
+	span.SetAttribute("http.request.method", req.Method)
+	span.SetAttribute("server.address", req.URL.Host)
+	span.SetAttribute("url.full", req.URL.Redacted())
+
+	// A RoundTripper must not modify the request it was given
+	req = req.Clone(req.Context())
+	req.Header.Set(TraceparentHeader, span.SpanContext().Traceparent())
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.SetError(err.Error())
+		return nil, err
+	}
+	span.SetAttribute("http.response.status_code", resp.StatusCode)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetError(http.StatusText(resp.StatusCode))
+	}
+	return resp, nil
+}
+
+// routeTemplate returns the path template of the request's route, so span
+// names don't include IDs
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return r.URL.Path
+}
+
+// responseWriter wraps http.ResponseWriter to capture the status code
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	written    bool
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	if !rw.written {
+		rw.statusCode = code
+		rw.written = true
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.written = true
+	return rw.ResponseWriter.Write(b)
+}
+
+// Flush supports streaming responses through the wrapper
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack supports WebSocket upgrades through the wrapper
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
@@ -0,0 +1,240 @@
+// Package tracing records OpenTelemetry spans for HTTP requests and sends
+// them to an OTLP collector, so a request can be followed across services.
+// Trace context travels between services in the W3C traceparent header.
+//
+// Spans are exported to OTEL_EXPORTER_OTLP_ENDPOINT over OTLP/HTTP. When it
+// is unset the tracer is a no-op: no spans are started and requests pass
+// through unchanged.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceparentHeader carries the W3C trace context between services
+const TraceparentHeader = "traceparent"
+
+// flagSampled marks a trace whose spans are recorded
+const flagSampled = 0x01
+
+// Span kinds, as numbered by OTLP
+const (
+	kindServer = 2
+	kindClient = 3
+)
+
+// statusError is the OTLP status code of a failed span
+const statusError = 2
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// String returns the trace ID in hex
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+// String returns the span ID in hex
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext is the part of a span propagated to other services
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Flags   byte
+}
+
+// IsValid reports whether the trace and span IDs are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// IsSampled reports whether the trace's spans are recorded
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&flagSampled != 0
+}
+
+// Traceparent formats the span context as a traceparent header value
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// ParseTraceparent parses a traceparent header value. Versions other than
+// 00 are read by their 00 fields, as the W3C spec asks.
+func ParseTraceparent(value string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, false
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	var version, flags [1]byte
+	if _, err := hex.Decode(version[:], []byte(parts[0])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return sc, false
+	}
+	sc.Flags = flags[0]
+	return sc, sc.IsValid()
+}
+
+// Config configures a Tracer
+type Config struct {
+	// Service is reported as the spans' service.name
+	Service string
+
+	// Endpoint is the collector's base URL, such as
+	// http://otel-collector:4318. It is read from OTEL_EXPORTER_OTLP_ENDPOINT
+	// when empty, and tracing is off when both are empty.
+	Endpoint string
+
+	// OnError is told about spans that could not be exported
+	OnError func(error)
+}
+
+// Tracer starts spans and exports them. A nil Tracer is a no-op.
+type Tracer struct {
+	service  string
+	exporter *exporter
+}
+
+// New creates a Tracer, which is a no-op when no endpoint is configured
+func New(cfg Config) *Tracer {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	t := &Tracer{service: cfg.Service}
+	if endpoint != "" {
+		t.exporter = newExporter(cfg.Service, endpoint, cfg.OnError)
+	}
+	return t
+}
+
+// Enabled reports whether the tracer records spans
+func (t *Tracer) Enabled() bool {
+	return t != nil && t.exporter != nil
+}
+
+// Shutdown exports the spans not yet sent and stops the exporter
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if !t.Enabled() {
+		return nil
+	}
+	return t.exporter.shutdown(ctx)
+}
+
+// start starts a span, as a child of parent if it is valid or else as the
+// root of a new sampled trace
+func (t *Tracer) start(name string, kind int, parent SpanContext) *Span {
+	span := &Span{
+		tracer: t,
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+	}
+	if parent.IsValid() {
+		span.context.TraceID = parent.TraceID
+		span.context.Flags = parent.Flags
+		span.parentID = parent.SpanID
+	} else {
+		rand.Read(span.context.TraceID[:])
+		span.context.Flags = flagSampled
+	}
+	rand.Read(span.context.SpanID[:])
+	return span
+}
+
+// Span is a timed operation within a trace
+type Span struct {
+	tracer   *Tracer
+	name     string
+	kind     int
+	context  SpanContext
+	parentID SpanID
+	start    time.Time
+
+	mu            sync.Mutex
+	end           time.Time
+	attributes    []attribute
+	status        int
+	statusMessage string
+	ended         bool
+}
+
+// attribute is a key and a string, int, or bool value
+type attribute struct {
+	key   string
+	value interface{}
+}
+
+// SpanContext returns the span's propagated context
+func (s *Span) SpanContext() SpanContext {
+	return s.context
+}
+
+// SetAttribute records a string, int, or bool attribute on the span
+func (s *Span) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes = append(s.attributes, attribute{key: key, value: value})
+}
+
+// SetError marks the span as failed
+func (s *Span) SetError(message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = statusError
+	s.statusMessage = message
+}
+
+// End ends the span and queues it for export if its trace is sampled. Only
+// the first call has an effect.
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	if s.context.IsSampled() && s.tracer.Enabled() {
+		s.tracer.exporter.enqueue(s)
+	}
+}
+
+type spanKey struct{}
+
+// ContextWithSpan returns a context carrying the span
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span in the context, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
@@ -0,0 +1,194 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+const parentTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// collector is a stand-in OTLP/HTTP collector
+type collector struct {
+	mu    sync.Mutex
+	spans []otlpSpan
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req otlpRequest
+	if r.URL.Path != "/v1/traces" || json.NewDecoder(r.Body).Decode(&req) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+}
+
+func newRouter(tracer *Tracer, handler http.HandlerFunc) *mux.Router {
+	router := mux.NewRouter()
+	router.Use(tracer.Middleware)
+	router.HandleFunc("/deals/{id}", handler).Methods("GET")
+	return router
+}
+
+func TestParseTraceparent(t *testing.T) {
+	sc, ok := ParseTraceparent(parentTraceparent)
+	if !ok {
+		t.Fatal("Expected a valid traceparent")
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || !sc.IsSampled() {
+		t.Errorf("Unexpected span context %+v", sc)
+	}
+	if got := sc.Traceparent(); got != parentTraceparent {
+		t.Errorf("Expected %q, got %q", parentTraceparent, got)
+	}
+
+	for _, value := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, ok := ParseTraceparent(value); ok {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+	if _, ok := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"); !ok {
+		t.Error("Expected a later version to be read by its 00 fields")
+	}
+}
+
+func TestMiddlewareNoopWithoutEndpoint(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	tracer := New(Config{Service: "deal-service"})
+	if tracer.Enabled() {
+		t.Fatal("Expected tracing off without an endpoint")
+	}
+
+	router := newRouter(tracer, func(w http.ResponseWriter, r *http.Request) {
+		if SpanFromContext(r.Context()) != nil {
+			t.Error("Expected no span")
+		}
+		if r.Header.Get("X-Request-ID") != "" {
+			t.Error("Expected no request ID to be set")
+		}
+	})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/deals/1", nil))
+
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Errorf("Expected a no-op shutdown, got %v", err)
+	}
+}
+
+func TestMiddlewareExportsServerAndClientSpans(t *testing.T) {
+	col := &collector{}
+	collectorServer := httptest.NewServer(col)
+	defer collectorServer.Close()
+
+	// A downstream service records the traceparent it was sent
+	var downstreamTraceparent string
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstreamTraceparent = r.Header.Get(TraceparentHeader)
+	}))
+	defer downstream.Close()
+
+	tracer := New(Config{Service: "deal-service", Endpoint: collectorServer.URL})
+	client := &http.Client{Transport: Transport(nil)}
+
+	var requestID string
+	router := newRouter(tracer, func(w http.ResponseWriter, r *http.Request) {
+		requestID = r.Header.Get("X-Request-ID")
+		req, _ := http.NewRequestWithContext(r.Context(), "GET", downstream.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Errorf("Downstream request failed: %v", err)
+		} else {
+			resp.Body.Close()
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	req := httptest.NewRequest("GET", "/deals/42", nil)
+	req.Header.Set(TraceparentHeader, parentTraceparent)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	if requestID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected the trace ID as the request ID, got %q", requestID)
+	}
+	if len(col.spans) != 2 {
+		t.Fatalf("Expected a server and a client span, got %d", len(col.spans))
+	}
+
+	clientSpan, server := col.spans[0], col.spans[1]
+	if server.Name != "GET /deals/{id}" || server.Kind != kindServer {
+		t.Errorf("Unexpected server span %q of kind %d", server.Name, server.Kind)
+	}
+	if server.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || server.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("Expected the server span to continue the caller's trace, got %+v", server)
+	}
+	if server.Status.Code != statusError {
+		t.Error("Expected a 5xx response to mark the server span failed")
+	}
+	if clientSpan.Kind != kindClient || clientSpan.ParentSpanID != server.SpanID || clientSpan.TraceID != server.TraceID {
+		t.Errorf("Expected the client span to be a child of the server span, got %+v", clientSpan)
+	}
+	if want := "00-" + clientSpan.TraceID + "-" + clientSpan.SpanID + "-01"; downstreamTraceparent != want {
+		t.Errorf("Expected traceparent %q downstream, got %q", want, downstreamTraceparent)
+	}
+}
+
+func TestMiddlewareKeepsCallerRequestID(t *testing.T) {
+	tracer := New(Config{Service: "deal-service", Endpoint: "http://127.0.0.1:0"})
+	defer tracer.Shutdown(context.Background())
+
+	var requestID string
+	var span *Span
+	router := newRouter(tracer, func(w http.ResponseWriter, r *http.Request) {
+		requestID = r.Header.Get("X-Request-ID")
+		span = SpanFromContext(r.Context())
+	})
+	req := httptest.NewRequest("GET", "/deals/1", nil)
+	req.Header.Set("X-Request-ID", "req-123")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if requestID != "req-123" {
+		t.Errorf("Expected the caller's request ID to be kept, got %q", requestID)
+	}
+	if span == nil || !span.SpanContext().IsValid() {
+		t.Error("Expected a new trace to be started")
+	}
+}
+
+func TestTransportPassesThroughWithoutSpan(t *testing.T) {
+	var traceparent string
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get(TraceparentHeader)
+	}))
+	defer downstream.Close()
+
+	resp, err := (&http.Client{Transport: Transport(nil)}).Get(downstream.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if traceparent != "" {
+		t.Errorf("Expected no traceparent outside a trace, got %q", traceparent)
+	}
+}
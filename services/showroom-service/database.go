@@ -8,7 +8,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // VisitFilter contains filtering options for listing visits
@@ -33,11 +33,11 @@ type ShowroomDatabase interface {
 	UpdateVisit(id, dealershipID string, req UpdateVisitRequest) (*Visit, error)
 	ChangeStatus(id, dealershipID, status string) (*Visit, error)
 	AttachVehicle(id, dealershipID, vehicleID string, stockNumber *string) (*Visit, error)
-	CloseVisit(id, dealershipID, status string) (*Visit, error)
+	CloseVisit(id, dealershipID, status string, lostReason *string) (*Visit, error)
 
 	// Timers
 	GetTimers(visitID string) ([]Timer, error)
-	StartTimer(visitID, timerType string, userID *string) (*Timer, error)
+	StartTimer(visitID, timerType, category string, userID *string) (*Timer, error)
 	StopTimer(timerID, visitID string) (*Timer, error)
 
 	// Notes
@@ -46,6 +46,21 @@ type ShowroomDatabase interface {
 	UpdateNote(noteID, visitID string, req UpdateNoteRequest) (*Note, error)
 	DeleteNote(noteID, visitID string) error
 
+	// Mentions
+	ResolveMentionHandles(dealershipID string, handles []string) (map[string]UserInfo, error)
+	CreateMention(m Mention) (*Mention, error)
+	ListMentions(dealershipID, userID string, limit int) ([]Mention, error)
+
+	// Source attribution
+	ListSourceVisits(dealershipID string, from, to time.Time) ([]SourceVisit, error)
+	ListSourceDeals(dealershipID string, from, to time.Time) ([]SourceDeal, error)
+
+	// Timer analytics
+	ListCategoryTimers(dealershipID string, from, to time.Time) ([]CategoryTimer, error)
+
+	// Lost reason report
+	ListLostOutcomes(dealershipID string, from, to time.Time) ([]LostOutcome, error)
+
 	// Events
 	GetEvents(visitID string) ([]Event, error)
 	CreateEvent(visitID, eventType string, userID, previousValue, newValue *string, metadata []byte) error
@@ -128,7 +143,7 @@ func (p *PostgresDB) ListVisits(filter VisitFilter) ([]Visit, int, error) {
 	selectQuery := `
 		SELECT v.id, v.dealership_id, v.customer_id, v.salesperson_id, v.vehicle_id,
 		       v.stock_number, v.check_in_time, v.check_out_time, v.status, v.workflow_stage,
-		       v.source, v.appointment_id, v.created_at, v.updated_at,
+		       v.source, v.campaign, v.appointment_id, v.lost_reason, v.created_at, v.updated_at,
 		       c.id, c.first_name, c.last_name, c.email, c.phone,
 		       u.id, u.first_name, u.last_name, u.email,
 		       ve.id, ve.stock_number, ve.year, ve.make, ve.model, ve.trim, ve.exterior_color, ve.list_price
@@ -163,7 +178,7 @@ func (p *PostgresDB) scanVisitRow(rows *sql.Rows) (*Visit, error) {
 	var salesperson UserInfo
 	var vehicle VehicleInfo
 
-	var salespersonID, vehicleID, stockNumber, source, appointmentID sql.NullString
+	var salespersonID, vehicleID, stockNumber, source, campaign, appointmentID, lostReason sql.NullString
 	var checkOutTime sql.NullTime
 
 	var custEmail, custPhone sql.NullString
@@ -176,7 +191,7 @@ func (p *PostgresDB) scanVisitRow(rows *sql.Rows) (*Visit, error) {
 	err := rows.Scan(
 		&v.ID, &v.DealershipID, &v.CustomerID, &salespersonID, &vehicleID,
 		&stockNumber, &v.CheckInTime, &checkOutTime, &v.Status, &v.WorkflowStage,
-		&source, &appointmentID, &v.CreatedAt, &v.UpdatedAt,
+		&source, &campaign, &appointmentID, &lostReason, &v.CreatedAt, &v.UpdatedAt,
 		&customer.ID, &customer.FirstName, &customer.LastName, &custEmail, &custPhone,
 		&spID, &spFirstName, &spLastName, &spEmail,
 		&vehID, &vehStockNum, &vehYear, &vehMake, &vehModel, &vehTrim, &vehColor, &vehPrice,
@@ -197,9 +212,15 @@ func (p *PostgresDB) scanVisitRow(rows *sql.Rows) (*Visit, error) {
 	if source.Valid {
 		v.Source = &source.String
 	}
+	if campaign.Valid {
+		v.Campaign = &campaign.String
+	}
 	if appointmentID.Valid {
 		v.AppointmentID = &appointmentID.String
 	}
+	if lostReason.Valid {
+		v.LostReason = &lostReason.String
+	}
 	if checkOutTime.Valid {
 		v.CheckOutTime = &checkOutTime.Time
 	}
@@ -249,7 +270,7 @@ func (p *PostgresDB) GetVisit(id, dealershipID string) (*Visit, error) {
 	query := `
 		SELECT v.id, v.dealership_id, v.customer_id, v.salesperson_id, v.vehicle_id,
 		       v.stock_number, v.check_in_time, v.check_out_time, v.status, v.workflow_stage,
-		       v.source, v.appointment_id, v.created_at, v.updated_at,
+		       v.source, v.campaign, v.appointment_id, v.lost_reason, v.created_at, v.updated_at,
 		       c.id, c.first_name, c.last_name, c.email, c.phone,
 		       u.id, u.first_name, u.last_name, u.email,
 		       ve.id, ve.stock_number, ve.year, ve.make, ve.model, ve.trim, ve.exterior_color, ve.list_price
@@ -264,7 +285,7 @@ func (p *PostgresDB) GetVisit(id, dealershipID string) (*Visit, error) {
 	var salesperson UserInfo
 	var vehicle VehicleInfo
 
-	var salespersonID, vehicleID, stockNumber, source, appointmentID sql.NullString
+	var salespersonID, vehicleID, stockNumber, source, campaign, appointmentID, lostReason sql.NullString
 	var checkOutTime sql.NullTime
 	var custEmail, custPhone sql.NullString
 	var spID, spFirstName, spLastName, spEmail sql.NullString
@@ -276,7 +297,7 @@ func (p *PostgresDB) GetVisit(id, dealershipID string) (*Visit, error) {
 	err := p.db.QueryRow(query, id, dealershipID).Scan(
 		&v.ID, &v.DealershipID, &v.CustomerID, &salespersonID, &vehicleID,
 		&stockNumber, &v.CheckInTime, &checkOutTime, &v.Status, &v.WorkflowStage,
-		&source, &appointmentID, &v.CreatedAt, &v.UpdatedAt,
+		&source, &campaign, &appointmentID, &lostReason, &v.CreatedAt, &v.UpdatedAt,
 		&customer.ID, &customer.FirstName, &customer.LastName, &custEmail, &custPhone,
 		&spID, &spFirstName, &spLastName, &spEmail,
 		&vehID, &vehStockNum, &vehYear, &vehMake, &vehModel, &vehTrim, &vehColor, &vehPrice,
@@ -300,9 +321,15 @@ func (p *PostgresDB) GetVisit(id, dealershipID string) (*Visit, error) {
 	if source.Valid {
 		v.Source = &source.String
 	}
+	if campaign.Valid {
+		v.Campaign = &campaign.String
+	}
 	if appointmentID.Valid {
 		v.AppointmentID = &appointmentID.String
 	}
+	if lostReason.Valid {
+		v.LostReason = &lostReason.String
+	}
 	if checkOutTime.Valid {
 		v.CheckOutTime = &checkOutTime.Time
 	}
@@ -366,13 +393,13 @@ func (p *PostgresDB) CreateVisit(dealershipID string, req CreateVisitRequest) (*
 	query := `
 		INSERT INTO showroom_visits (id, dealership_id, customer_id, salesperson_id, vehicle_id,
 		                              stock_number, check_in_time, status, workflow_stage, source,
-		                              created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+		                              campaign, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
 
 	_, err := p.db.Exec(query,
 		id, dealershipID, req.CustomerID, req.SalespersonID, req.VehicleID,
 		req.StockNumber, now, "CHECKED_IN", 0, req.Source,
-		now, now,
+		req.Campaign, now, now,
 	)
 	if err != nil {
 		return nil, err
@@ -447,17 +474,18 @@ func (p *PostgresDB) AttachVehicle(id, dealershipID, vehicleID string, stockNumb
 	return p.GetVisit(id, dealershipID)
 }
 
-// CloseVisit closes a visit with a final status
-func (p *PostgresDB) CloseVisit(id, dealershipID, status string) (*Visit, error) {
+// CloseVisit closes a visit with a final status and, for a lost visit, the
+// reason it was lost
+func (p *PostgresDB) CloseVisit(id, dealershipID, status string, lostReason *string) (*Visit, error) {
 	workflowStage := getWorkflowStage(status)
 	now := time.Now()
 
 	query := `
 		UPDATE showroom_visits
-		SET status = $3, workflow_stage = $4, check_out_time = $5, updated_at = $5
+		SET status = $3, workflow_stage = $4, check_out_time = $5, updated_at = $5, lost_reason = $6
 		WHERE id = $1 AND dealership_id = $2`
 
-	result, err := p.db.Exec(query, id, dealershipID, status, workflowStage, now)
+	result, err := p.db.Exec(query, id, dealershipID, status, workflowStage, now, lostReason)
 	if err != nil {
 		return nil, err
 	}
@@ -479,7 +507,7 @@ func (p *PostgresDB) CloseVisit(id, dealershipID, status string) (*Visit, error)
 // GetTimers returns all timers for a visit
 func (p *PostgresDB) GetTimers(visitID string) ([]Timer, error) {
 	query := `
-		SELECT id, visit_id, timer_type, start_time, end_time, duration_seconds, started_by, created_at
+		SELECT id, visit_id, timer_type, category, start_time, end_time, duration_seconds, started_by, created_at
 		FROM visit_timers
 		WHERE visit_id = $1
 		ORDER BY start_time DESC`
@@ -495,13 +523,15 @@ func (p *PostgresDB) GetTimers(visitID string) ([]Timer, error) {
 		var t Timer
 		var endTime sql.NullTime
 		var durationSeconds sql.NullInt64
-		var startedBy sql.NullString
+		var category, startedBy sql.NullString
 
-		err := rows.Scan(&t.ID, &t.VisitID, &t.TimerType, &t.StartTime, &endTime, &durationSeconds, &startedBy, &t.CreatedAt)
+		err := rows.Scan(&t.ID, &t.VisitID, &t.TimerType, &category, &t.StartTime, &endTime, &durationSeconds, &startedBy, &t.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
 
+		t.Category = timerCategory(category.String, t.TimerType)
+
 		if endTime.Valid {
 			t.EndTime = &endTime.Time
 		}
@@ -518,16 +548,20 @@ func (p *PostgresDB) GetTimers(visitID string) ([]Timer, error) {
 	return timers, nil
 }
 
-// StartTimer starts a new timer
-func (p *PostgresDB) StartTimer(visitID, timerType string, userID *string) (*Timer, error) {
+// StartTimer starts a new timer. It returns ErrTimerCategoryActive if the
+// visit already has a running timer in the category.
+func (p *PostgresDB) StartTimer(visitID, timerType, category string, userID *string) (*Timer, error) {
 	id := uuid.New().String()
 	now := time.Now()
 
 	query := `
-		INSERT INTO visit_timers (id, visit_id, timer_type, start_time, started_by, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)`
+		INSERT INTO visit_timers (id, visit_id, timer_type, category, start_time, started_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
 
-	_, err := p.db.Exec(query, id, visitID, timerType, now, userID, now)
+	_, err := p.db.Exec(query, id, visitID, timerType, category, now, userID, now)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return nil, ErrTimerCategoryActive
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -536,6 +570,7 @@ func (p *PostgresDB) StartTimer(visitID, timerType string, userID *string) (*Tim
 		ID:        id,
 		VisitID:   visitID,
 		TimerType: timerType,
+		Category:  category,
 		StartTime: now,
 		StartedBy: userID,
 		CreatedAt: now,
@@ -566,14 +601,15 @@ func (p *PostgresDB) StopTimer(timerID, visitID string) (*Timer, error) {
 
 	// Return updated timer
 	var t Timer
-	var startedBy sql.NullString
+	var category, startedBy sql.NullString
 	err = p.db.QueryRow(`
-		SELECT id, visit_id, timer_type, start_time, end_time, duration_seconds, started_by, created_at
+		SELECT id, visit_id, timer_type, category, start_time, end_time, duration_seconds, started_by, created_at
 		FROM visit_timers WHERE id = $1`, timerID).Scan(
-		&t.ID, &t.VisitID, &t.TimerType, &t.StartTime, &t.EndTime, &t.DurationSeconds, &startedBy, &t.CreatedAt)
+		&t.ID, &t.VisitID, &t.TimerType, &category, &t.StartTime, &t.EndTime, &t.DurationSeconds, &startedBy, &t.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
+	t.Category = timerCategory(category.String, t.TimerType)
 	if startedBy.Valid {
 		t.StartedBy = &startedBy.String
 	}
@@ -715,6 +751,207 @@ func (p *PostgresDB) DeleteNote(noteID, visitID string) error {
 	return nil
 }
 
+// ResolveMentionHandles maps mention handles to active users in the
+// dealership by the local part of their email address
+func (p *PostgresDB) ResolveMentionHandles(dealershipID string, handles []string) (map[string]UserInfo, error) {
+	query := `
+		SELECT LOWER(SPLIT_PART(email, '@', 1)), id, COALESCE(first_name, ''), COALESCE(last_name, ''), email
+		FROM auth_users
+		WHERE dealership_id = $1 AND is_active = true
+		  AND LOWER(SPLIT_PART(email, '@', 1)) = ANY($2)`
+
+	rows, err := p.db.Query(query, dealershipID, pq.Array(handles))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make(map[string]UserInfo)
+	for rows.Next() {
+		var handle string
+		var u UserInfo
+		if err := rows.Scan(&handle, &u.ID, &u.FirstName, &u.LastName, &u.Email); err != nil {
+			return nil, err
+		}
+		users[handle] = u
+	}
+
+	return users, rows.Err()
+}
+
+// CreateMention records a mention of a user in a note
+func (p *PostgresDB) CreateMention(m Mention) (*Mention, error) {
+	m.ID = uuid.New().String()
+	m.CreatedAt = time.Now()
+
+	query := `
+		INSERT INTO note_mentions (id, note_id, visit_id, dealership_id, mentioned_user_id, mentioned_by_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := p.db.Exec(query, m.ID, m.NoteID, m.VisitID, m.DealershipID, m.MentionedUserID, m.MentionedByID, m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// ListMentions returns the most recent mentions of a user, newest first
+func (p *PostgresDB) ListMentions(dealershipID, userID string, limit int) ([]Mention, error) {
+	query := `
+		SELECT id, note_id, visit_id, dealership_id, mentioned_user_id, mentioned_by_id, created_at
+		FROM note_mentions
+		WHERE dealership_id = $1 AND mentioned_user_id = $2
+		ORDER BY created_at DESC
+		LIMIT $3`
+
+	rows, err := p.db.Query(query, dealershipID, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mentions := []Mention{}
+	for rows.Next() {
+		var m Mention
+		if err := rows.Scan(&m.ID, &m.NoteID, &m.VisitID, &m.DealershipID, &m.MentionedUserID, &m.MentionedByID, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		mentions = append(mentions, m)
+	}
+
+	return mentions, rows.Err()
+}
+
+// ListSourceVisits returns the visits checked in from from up to to, with
+// their source and campaign
+func (p *PostgresDB) ListSourceVisits(dealershipID string, from, to time.Time) ([]SourceVisit, error) {
+	query := `
+		SELECT id, customer_id, source, campaign, check_in_time
+		FROM showroom_visits
+		WHERE dealership_id = $1 AND check_in_time >= $2 AND check_in_time < $3
+		ORDER BY check_in_time`
+
+	rows, err := p.db.Query(query, dealershipID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	visits := []SourceVisit{}
+	for rows.Next() {
+		var v SourceVisit
+		var source, campaign sql.NullString
+		if err := rows.Scan(&v.ID, &v.CustomerID, &source, &campaign, &v.CheckInTime); err != nil {
+			return nil, err
+		}
+		if source.Valid {
+			v.Source = source.String
+		}
+		if campaign.Valid {
+			v.Campaign = campaign.String
+		}
+		visits = append(visits, v)
+	}
+
+	return visits, rows.Err()
+}
+
+// ListSourceDeals returns the deals created from from up to to that weren't
+// cancelled, with their front-end gross where the vehicle's cost is known
+func (p *PostgresDB) ListSourceDeals(dealershipID string, from, to time.Time) ([]SourceDeal, error) {
+	query := `
+		SELECT d.id, d.customer_id, d.created_at, d.sale_price - ve.invoice_price
+		FROM deals d
+		LEFT JOIN vehicles ve ON d.vehicle_id = ve.id
+		WHERE d.dealership_id = $1 AND d.created_at >= $2 AND d.created_at < $3
+		  AND LOWER(d.status) <> 'cancelled'
+		ORDER BY d.created_at`
+
+	rows, err := p.db.Query(query, dealershipID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deals := []SourceDeal{}
+	for rows.Next() {
+		var d SourceDeal
+		var gross sql.NullFloat64
+		if err := rows.Scan(&d.ID, &d.CustomerID, &d.CreatedAt, &gross); err != nil {
+			return nil, err
+		}
+		if gross.Valid {
+			d.Gross = &gross.Float64
+		}
+		deals = append(deals, d)
+	}
+
+	return deals, rows.Err()
+}
+
+// ListCategoryTimers returns the stopped timers of visits checked in from
+// from up to to
+func (p *PostgresDB) ListCategoryTimers(dealershipID string, from, to time.Time) ([]CategoryTimer, error) {
+	query := `
+		SELECT t.visit_id, t.timer_type, t.category, t.duration_seconds
+		FROM visit_timers t
+		JOIN showroom_visits v ON t.visit_id = v.id
+		WHERE v.dealership_id = $1 AND v.check_in_time >= $2 AND v.check_in_time < $3
+		  AND t.duration_seconds IS NOT NULL`
+
+	rows, err := p.db.Query(query, dealershipID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	timers := []CategoryTimer{}
+	for rows.Next() {
+		var t CategoryTimer
+		var category sql.NullString
+		if err := rows.Scan(&t.VisitID, &t.TimerType, &category, &t.DurationSeconds); err != nil {
+			return nil, err
+		}
+		t.Category = category.String
+		timers = append(timers, t)
+	}
+
+	return timers, rows.Err()
+}
+
+// ListLostOutcomes returns the visits closed as lost and the deals cancelled
+// from from up to to that have a lost reason
+func (p *PostgresDB) ListLostOutcomes(dealershipID string, from, to time.Time) ([]LostOutcome, error) {
+	query := `
+		SELECT 'visit', lost_reason
+		FROM showroom_visits
+		WHERE dealership_id = $1 AND status = 'CLOSED_LOST'
+		  AND check_out_time >= $2 AND check_out_time < $3 AND lost_reason IS NOT NULL
+		UNION ALL
+		SELECT 'deal', lost_reason
+		FROM deals
+		WHERE dealership_id = $1 AND LOWER(status) = 'cancelled'
+		  AND cancelled_at >= $2 AND cancelled_at < $3 AND lost_reason IS NOT NULL AND lost_reason <> ''`
+
+	rows, err := p.db.Query(query, dealershipID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	outcomes := []LostOutcome{}
+	for rows.Next() {
+		var o LostOutcome
+		if err := rows.Scan(&o.Kind, &o.Reason); err != nil {
+			return nil, err
+		}
+		outcomes = append(outcomes, o)
+	}
+
+	return outcomes, rows.Err()
+}
+
 // GetEvents returns all events for a visit
 func (p *PostgresDB) GetEvents(visitID string) ([]Event, error) {
 	query := `
@@ -3,9 +3,11 @@ module showroom-service
 go 1.18
 
 require (
+	autolytiq/shared/graceful v0.0.0
 	autolytiq/shared/logging v0.0.0
+	autolytiq/shared/tracing v0.0.0
 	github.com/google/uuid v1.6.0
-	github.com/gorilla/mux v1.8.0
+	github.com/gorilla/mux v1.8.1
 	github.com/gorilla/websocket v1.5.0
 	github.com/lib/pq v1.10.7
 	github.com/prometheus/client_golang v1.18.0
@@ -26,3 +28,7 @@ require (
 )
 
 replace autolytiq/shared/logging => ../shared/logging
+
+replace autolytiq/shared/graceful => ../shared/graceful
+
+replace autolytiq/shared/tracing => ../shared/tracing
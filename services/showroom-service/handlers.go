@@ -13,14 +13,15 @@ import (
 
 // Handler holds dependencies for HTTP handlers
 type Handler struct {
-	db     ShowroomDatabase
-	hub    *Hub
-	logger *logging.Logger
+	db       ShowroomDatabase
+	hub      *Hub
+	notifier MentionNotifier
+	logger   *logging.Logger
 }
 
 // NewHandler creates a new Handler instance
-func NewHandler(db ShowroomDatabase, hub *Hub, logger *logging.Logger) *Handler {
-	return &Handler{db: db, hub: hub, logger: logger}
+func NewHandler(db ShowroomDatabase, hub *Hub, notifier MentionNotifier, logger *logging.Logger) *Handler {
+	return &Handler{db: db, hub: hub, notifier: notifier, logger: logger}
 }
 
 // respondJSON writes a JSON response
@@ -166,7 +167,7 @@ func (h *Handler) CreateVisit(w http.ResponseWriter, r *http.Request) {
 	h.db.CreateEvent(visit.ID, "VISIT_CREATED", &userID, nil, nil, nil)
 
 	// Auto-start WAIT_TIME timer
-	timer, err := h.db.StartTimer(visit.ID, "WAIT_TIME", &userID)
+	timer, err := h.db.StartTimer(visit.ID, "WAIT_TIME", timerTypeCategories["WAIT_TIME"], &userID)
 	if err != nil {
 		h.logger.WithContext(r.Context()).WithError(err).Warn("Failed to auto-start timer")
 	} else {
@@ -190,6 +191,10 @@ func (h *Handler) CreateVisit(w http.ResponseWriter, r *http.Request) {
 	// Broadcast to all clients in dealership room
 	h.hub.Broadcast(dealershipID, WSEventVisitCreated, visit)
 
+	if len(visit.Notes) > 0 {
+		h.processMentions(r, dealershipID, &visit.Notes[0])
+	}
+
 	respondJSON(w, http.StatusCreated, visit)
 }
 
@@ -330,16 +335,11 @@ func (h *Handler) CloseVisit(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	visitID := vars["id"]
 
-	var req ChangeStatusRequest
+	var req CloseVisitRequest
 	if !decodeAndValidateShowroom(r, w, &req) {
 		return
 	}
 
-	if !IsClosedStatus(req.Status) {
-		respondError(w, http.StatusBadRequest, "Status must be CLOSED_WON or CLOSED_LOST")
-		return
-	}
-
 	// Get current visit
 	currentVisit, err := h.db.GetVisit(visitID, dealershipID)
 	if err != nil {
@@ -354,7 +354,7 @@ func (h *Handler) CloseVisit(w http.ResponseWriter, r *http.Request) {
 
 	previousStatus := currentVisit.Status
 
-	visit, err := h.db.CloseVisit(visitID, dealershipID, req.Status)
+	visit, err := h.db.CloseVisit(visitID, dealershipID, req.Status, req.LostReason)
 	if err != nil {
 		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to close visit")
 		respondError(w, http.StatusInternalServerError, "Failed to close visit")
@@ -392,7 +392,7 @@ func (h *Handler) StartTimer(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Verify visit exists and belongs to dealership
-	_, err := h.db.GetVisit(visitID, dealershipID)
+	visit, err := h.db.GetVisit(visitID, dealershipID)
 	if err != nil {
 		if err.Error() == "visit not found" {
 			respondError(w, http.StatusNotFound, "Visit not found")
@@ -403,7 +403,17 @@ func (h *Handler) StartTimer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	timer, err := h.db.StartTimer(visitID, req.TimerType, &userID)
+	// Only one timer per category runs at a time
+	if active := activeTimerInCategory(visit.Timers, req.Category); active != nil {
+		respondError(w, http.StatusConflict, "A "+req.Category+" timer is already running")
+		return
+	}
+
+	timer, err := h.db.StartTimer(visitID, req.TimerType, req.Category, &userID)
+	if err == ErrTimerCategoryActive {
+		respondError(w, http.StatusConflict, "A "+req.Category+" timer is already running")
+		return
+	}
 	if err != nil {
 		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to start timer")
 		respondError(w, http.StatusInternalServerError, "Failed to start timer")
@@ -470,7 +480,8 @@ func (h *Handler) StopTimer(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, timer)
 }
 
-// ListTimers handles GET /visits/{id}/timers
+// ListTimers handles GET /visits/{id}/timers. Timers can be filtered with
+// the category query parameter.
 func (h *Handler) ListTimers(w http.ResponseWriter, r *http.Request) {
 	dealershipID := getDealershipID(r)
 	if dealershipID == "" {
@@ -500,6 +511,16 @@ func (h *Handler) ListTimers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if category := NormalizeTimerCategory(r.URL.Query().Get("category")); category != "" {
+		filtered := []Timer{}
+		for _, t := range timers {
+			if t.Category == category {
+				filtered = append(filtered, t)
+			}
+		}
+		timers = filtered
+	}
+
 	respondJSON(w, http.StatusOK, timers)
 }
 
@@ -553,6 +574,8 @@ func (h *Handler) CreateNote(w http.ResponseWriter, r *http.Request) {
 		"note":     note,
 	})
 
+	h.processMentions(r, dealershipID, note)
+
 	respondJSON(w, http.StatusCreated, note)
 }
 
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// lostReasons is the list of accepted lost reasons. It is shared with the deal
+// service's LOST_REASONS and is replaced by configureLostReasons.
+var lostReasons = []string{"price", "financing", "inventory", "competitor", "timing"}
+
+// configureLostReasons replaces the accepted lost reasons with a comma
+// separated list. An empty list keeps the defaults.
+func configureLostReasons(list string) {
+	var reasons []string
+	seen := make(map[string]bool)
+	for _, r := range strings.Split(list, ",") {
+		reason := strings.ToLower(strings.TrimSpace(r))
+		if reason == "" || seen[reason] {
+			continue
+		}
+		seen[reason] = true
+		reasons = append(reasons, reason)
+	}
+	if len(reasons) > 0 {
+		lostReasons = reasons
+	}
+}
+
+// IsValidLostReason checks if a lowercased reason is one of the accepted lost
+// reasons
+func IsValidLostReason(reason string) bool {
+	for _, r := range lostReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildLostReasonReport counts lost visits and cancelled deals by reason. The
+// configured reasons are listed first, in order, followed by any no longer
+// configured.
+func BuildLostReasonReport(outcomes []LostOutcome) ([]LostReasonRow, LostReasonRow) {
+	rows := make([]LostReasonRow, 0, len(lostReasons))
+	index := make(map[string]int)
+	for _, reason := range lostReasons {
+		index[reason] = len(rows)
+		rows = append(rows, LostReasonRow{Reason: reason})
+	}
+
+	totals := LostReasonRow{Reason: "total"}
+	for _, o := range outcomes {
+		i, ok := index[o.Reason]
+		if !ok {
+			i = len(rows)
+			index[o.Reason] = i
+			rows = append(rows, LostReasonRow{Reason: o.Reason})
+		}
+		if o.Kind == LostOutcomeVisit {
+			rows[i].Visits++
+			totals.Visits++
+		} else {
+			rows[i].Deals++
+			totals.Deals++
+		}
+		rows[i].Total++
+		totals.Total++
+	}
+
+	return rows, totals
+}
+
+// GetLostReasonReport handles GET /reports/lost-reasons, why visits were
+// closed without a deal and deals were cancelled
+func (h *Handler) GetLostReasonReport(w http.ResponseWriter, r *http.Request) {
+	dealershipID, from, to, ok := parseReportRange(w, r)
+	if !ok {
+		return
+	}
+
+	outcomes, err := h.db.ListLostOutcomes(dealershipID, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		h.logger.WithContext(r.Context()).WithField("dealership_id", dealershipID).
+			WithError(err).Error("Failed to list lost outcomes for report")
+		respondError(w, http.StatusInternalServerError, "Failed to build lost reason report")
+		return
+	}
+
+	reasons, totals := BuildLostReasonReport(outcomes)
+
+	respondJSON(w, http.StatusOK, LostReasonReport{
+		DealershipID: dealershipID,
+		From:         from.Format("2006-01-02"),
+		To:           to.Format("2006-01-02"),
+		Reasons:      reasons,
+		Totals:       totals,
+	})
+}
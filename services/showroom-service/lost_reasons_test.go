@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"autolytiq/shared/logging"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func TestBuildLostReasonReport(t *testing.T) {
+	outcomes := []LostOutcome{
+		{Kind: LostOutcomeVisit, Reason: "price"},
+		{Kind: LostOutcomeVisit, Reason: "price"},
+		{Kind: LostOutcomeDeal, Reason: "price"},
+		{Kind: LostOutcomeDeal, Reason: "financing"},
+		{Kind: LostOutcomeVisit, Reason: "timing"},
+		// A reason that has since been removed from the configuration
+		{Kind: LostOutcomeDeal, Reason: "weather"},
+	}
+
+	reasons, totals := BuildLostReasonReport(outcomes)
+
+	expected := []LostReasonRow{
+		{Reason: "price", Visits: 2, Deals: 1, Total: 3},
+		{Reason: "financing", Deals: 1, Total: 1},
+		{Reason: "inventory"},
+		{Reason: "competitor"},
+		{Reason: "timing", Visits: 1, Total: 1},
+		{Reason: "weather", Deals: 1, Total: 1},
+	}
+	if len(reasons) != len(expected) {
+		t.Fatalf("Expected %d rows, got %+v", len(expected), reasons)
+	}
+	for i := range expected {
+		if reasons[i] != expected[i] {
+			t.Errorf("Row %d: expected %+v, got %+v", i, expected[i], reasons[i])
+		}
+	}
+	if totals != (LostReasonRow{Reason: "total", Visits: 3, Deals: 3, Total: 6}) {
+		t.Errorf("Unexpected totals: %+v", totals)
+	}
+}
+
+func TestConfigureLostReasons(t *testing.T) {
+	defer func(reasons []string) { lostReasons = reasons }(lostReasons)
+
+	configureLostReasons(" Price,credit-declined,,price")
+
+	for reason, valid := range map[string]bool{"price": true, "credit-declined": true, "timing": false} {
+		if IsValidLostReason(reason) != valid {
+			t.Errorf("Expected IsValidLostReason(%q) = %v", reason, valid)
+		}
+	}
+}
+
+// lostReasonTestDB implements the close and report parts of ShowroomDatabase
+type lostReasonTestDB struct {
+	timerTestDB
+
+	outcomes []LostOutcome
+}
+
+func (m *lostReasonTestDB) CloseVisit(id, dealershipID, status string, lostReason *string) (*Visit, error) {
+	if id != m.visit.ID || dealershipID != m.dealershipID {
+		return nil, errors.New("visit not found")
+	}
+	now := time.Now()
+	m.visit.Status = status
+	m.visit.CheckOutTime = &now
+	m.visit.LostReason = lostReason
+	if lostReason != nil {
+		m.outcomes = append(m.outcomes, LostOutcome{Kind: LostOutcomeVisit, Reason: *lostReason})
+	}
+	visit := m.visit
+	return &visit, nil
+}
+
+func (m *lostReasonTestDB) ListLostOutcomes(dealershipID string, from, to time.Time) ([]LostOutcome, error) {
+	return m.outcomes, nil
+}
+
+func TestCloseVisitLostReason(t *testing.T) {
+	db := &lostReasonTestDB{
+		timerTestDB: timerTestDB{dealershipID: uuid.New().String(), visit: Visit{ID: uuid.New().String(), Status: "NEGOTIATING"}},
+		outcomes:    []LostOutcome{{Kind: LostOutcomeDeal, Reason: "financing"}},
+	}
+	logger := logging.New(logging.Config{
+		Service: "showroom-service-test",
+		Level:   logging.LevelError,
+		Output:  &bytes.Buffer{},
+	})
+	handler := NewHandler(db, NewHub(logger), nil, logger)
+	router := mux.NewRouter()
+	router.HandleFunc("/visits/{id}/close", handler.CloseVisit).Methods("POST")
+	router.HandleFunc("/reports/lost-reasons", handler.GetLostReasonReport).Methods("GET")
+	path := "/visits/" + db.visit.ID + "/close"
+
+	reason := func(r string) *string { return &r }
+	for _, req := range []CloseVisitRequest{
+		{Status: "CLOSED_LOST"},
+		{Status: "CLOSED_LOST", LostReason: reason("weather")},
+		{Status: "CLOSED_WON", LostReason: reason("price")},
+		{Status: "NEGOTIATING"},
+	} {
+		if rr := sendTimerRequest(router, db.dealershipID, "POST", path, req); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 closing with %+v, got %d", req, rr.Code)
+		}
+	}
+	if db.visit.CheckOutTime != nil {
+		t.Fatal("Expected the visit not to be closed without a valid reason")
+	}
+
+	rr := sendTimerRequest(router, db.dealershipID, "POST", path, CloseVisitRequest{Status: "closed_lost", LostReason: reason(" Price ")})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var visit Visit
+	json.Unmarshal(rr.Body.Bytes(), &visit)
+	if visit.Status != "CLOSED_LOST" || visit.LostReason == nil || *visit.LostReason != "price" {
+		t.Errorf("Expected a visit lost on price, got %+v", visit)
+	}
+
+	rr = sendTimerRequest(router, db.dealershipID, "GET", "/reports/lost-reasons?from=2026-03-01&to=2026-03-31", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var report LostReasonReport
+	json.Unmarshal(rr.Body.Bytes(), &report)
+	if report.From != "2026-03-01" || report.To != "2026-03-31" || report.Totals.Visits != 1 || report.Totals.Deals != 1 {
+		t.Fatalf("Unexpected report: %+v", report)
+	}
+	if report.Reasons[0] != (LostReasonRow{Reason: "price", Visits: 1, Total: 1}) || report.Reasons[1] != (LostReasonRow{Reason: "financing", Deals: 1, Total: 1}) {
+		t.Errorf("Unexpected reasons: %+v", report.Reasons)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"autolytiq/shared/logging"
+	"autolytiq/shared/tracing"
+)
+
+// mentionRegex matches @handle mentions that are not part of an email
+// address. A handle is the local part of the user's email (e.g. @jsmith for
+// jsmith@dealer.com).
+var mentionRegex = regexp.MustCompile(`(?:^|[^A-Za-z0-9._%+-])@([A-Za-z0-9][A-Za-z0-9._-]*)`)
+
+// maxMentionsPerNote bounds how many users a single note can notify
+const maxMentionsPerNote = 20
+
+// ParseMentions returns the unique, lowercased handles mentioned in a note
+func ParseMentions(content string) []string {
+	seen := make(map[string]bool)
+	var handles []string
+
+	for _, match := range mentionRegex.FindAllStringSubmatch(content, -1) {
+		handle := strings.ToLower(strings.TrimRight(match[1], "._-"))
+		if handle == "" || seen[handle] {
+			continue
+		}
+		seen[handle] = true
+		handles = append(handles, handle)
+		if len(handles) == maxMentionsPerNote {
+			break
+		}
+	}
+
+	return handles
+}
+
+// MentionNotifier delivers a notification to a mentioned user
+type MentionNotifier interface {
+	NotifyMention(ctx context.Context, mention *Mention, user UserInfo, note *Note) error
+}
+
+// EmailMentionNotifier notifies mentioned users through email-service
+type EmailMentionNotifier struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewEmailMentionNotifier creates a notifier that sends mention emails
+func NewEmailMentionNotifier(baseURL string) *EmailMentionNotifier {
+	return &EmailMentionNotifier{
+		baseURL:    baseURL,
+		httpClient: &http.Client{Transport: tracing.Transport(nil), Timeout: 5 * time.Second},
+	}
+}
+
+// NotifyMention sends the mentioned user an email linking to the visit note
+func (n *EmailMentionNotifier) NotifyMention(ctx context.Context, mention *Mention, user UserInfo, note *Note) error {
+	author := "A teammate"
+	if note.CreatedBy != nil && note.CreatedBy.FirstName != "" {
+		author = strings.TrimSpace(note.CreatedBy.FirstName + " " + note.CreatedBy.LastName)
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"dealership_id": mention.DealershipID,
+		"to":            user.Email,
+		"subject":       author + " mentioned you in a showroom note",
+		"body_html": fmt.Sprintf("<p>%s mentioned you on visit %s:</p><blockquote>%s</blockquote>",
+			html.EscapeString(author), html.EscapeString(mention.VisitID), html.EscapeString(note.Content)),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.baseURL+"/email/send", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build notification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	logging.PropagateHeadersFromContext(ctx, req)
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send mention notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("email-service returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// processMentions resolves @mentions in a note to users in the dealership,
+// records them, notifies each mentioned user and broadcasts a MENTION event.
+// Unresolved handles and notification failures are logged and skipped.
+func (h *Handler) processMentions(r *http.Request, dealershipID string, note *Note) []Mention {
+	handles := ParseMentions(note.Content)
+	if len(handles) == 0 {
+		return nil
+	}
+
+	log := h.logger.WithContext(r.Context()).WithField("note_id", note.ID)
+
+	users, err := h.db.ResolveMentionHandles(dealershipID, handles)
+	if err != nil {
+		log.WithError(err).Warn("Failed to resolve mentions")
+		return nil
+	}
+
+	var mentions []Mention
+	for _, handle := range handles {
+		user, ok := users[handle]
+		if !ok {
+			log.WithField("handle", handle).Debug("Ignoring unresolved mention")
+			continue
+		}
+		if user.ID == note.CreatedByID {
+			continue
+		}
+
+		mention, err := h.db.CreateMention(Mention{
+			NoteID:          note.ID,
+			VisitID:         note.VisitID,
+			DealershipID:    dealershipID,
+			MentionedUserID: user.ID,
+			MentionedByID:   note.CreatedByID,
+		})
+		if err != nil {
+			log.WithError(err).WithField("user_id", user.ID).Warn("Failed to record mention")
+			continue
+		}
+		mentions = append(mentions, *mention)
+
+		if h.notifier != nil {
+			if err := h.notifier.NotifyMention(r.Context(), mention, user, note); err != nil {
+				log.WithError(err).WithField("user_id", user.ID).Warn("Failed to notify mentioned user")
+			}
+		}
+
+		h.hub.Broadcast(dealershipID, WSEventMention, mention)
+	}
+
+	return mentions
+}
+
+// ListMentions handles GET /mentions, the current user's mention feed
+func (h *Handler) ListMentions(w http.ResponseWriter, r *http.Request) {
+	dealershipID := getDealershipID(r)
+	userID := getUserID(r)
+	if dealershipID == "" {
+		respondError(w, http.StatusBadRequest, "Missing dealership ID")
+		return
+	}
+	if userID == "" {
+		respondError(w, http.StatusBadRequest, "Missing user ID")
+		return
+	}
+
+	limit := 50
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 200 {
+			limit = parsed
+		}
+	}
+
+	mentions, err := h.db.ListMentions(dealershipID, userID, limit)
+	if err != nil {
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to list mentions")
+		respondError(w, http.StatusInternalServerError, "Failed to list mentions")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mentions)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"autolytiq/shared/logging"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected []string
+	}{
+		{"@jsmith can you take this one?", []string{"jsmith"}},
+		{"Ping @JSmith and @mary.jones.", []string{"jsmith", "mary.jones"}},
+		{"@jsmith @jsmith again", []string{"jsmith"}},
+		{"Customer email is buyer@example.com", nil},
+		{"(@mgr) wants an update", []string{"mgr"}},
+		{"No mentions here", nil},
+	}
+
+	for _, tt := range tests {
+		got := ParseMentions(tt.content)
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("ParseMentions(%q) = %v, want %v", tt.content, got, tt.expected)
+		}
+	}
+}
+
+// mentionTestDB implements the note and mention parts of ShowroomDatabase
+type mentionTestDB struct {
+	ShowroomDatabase
+
+	visits   map[string]string              // visitID -> dealershipID
+	users    map[string]map[string]UserInfo // dealershipID -> handle -> user
+	mentions []Mention
+}
+
+func (m *mentionTestDB) GetVisit(id, dealershipID string) (*Visit, error) {
+	if m.visits[id] != dealershipID {
+		return nil, errors.New("visit not found")
+	}
+	return &Visit{ID: id, DealershipID: dealershipID}, nil
+}
+
+func (m *mentionTestDB) CreateNote(visitID, userID string, req CreateNoteRequest) (*Note, error) {
+	now := time.Now()
+	return &Note{
+		ID:          uuid.New().String(),
+		VisitID:     visitID,
+		CreatedByID: userID,
+		CreatedBy:   &UserInfo{ID: userID, FirstName: "Sam", LastName: "Sales"},
+		Content:     req.Content,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}, nil
+}
+
+func (m *mentionTestDB) ResolveMentionHandles(dealershipID string, handles []string) (map[string]UserInfo, error) {
+	resolved := make(map[string]UserInfo)
+	for _, handle := range handles {
+		if u, ok := m.users[dealershipID][handle]; ok {
+			resolved[handle] = u
+		}
+	}
+	return resolved, nil
+}
+
+func (m *mentionTestDB) CreateMention(mention Mention) (*Mention, error) {
+	mention.ID = uuid.New().String()
+	mention.CreatedAt = time.Now()
+	m.mentions = append(m.mentions, mention)
+	return &mention, nil
+}
+
+func (m *mentionTestDB) ListMentions(dealershipID, userID string, limit int) ([]Mention, error) {
+	result := []Mention{}
+	for _, mention := range m.mentions {
+		if mention.DealershipID == dealershipID && mention.MentionedUserID == userID {
+			result = append(result, mention)
+		}
+	}
+	return result, nil
+}
+
+// recordingNotifier captures mention notifications
+type recordingNotifier struct {
+	notified []UserInfo
+}
+
+func (n *recordingNotifier) NotifyMention(ctx context.Context, mention *Mention, user UserInfo, note *Note) error {
+	n.notified = append(n.notified, user)
+	return nil
+}
+
+func setupMentionTest() (*mux.Router, *Hub, *mentionTestDB, *recordingNotifier, string, string) {
+	dealershipID := uuid.New().String()
+	visitID := uuid.New().String()
+
+	db := &mentionTestDB{
+		visits: map[string]string{visitID: dealershipID},
+		users: map[string]map[string]UserInfo{
+			dealershipID: {
+				"jsmith": {ID: uuid.New().String(), FirstName: "John", LastName: "Smith", Email: "jsmith@dealer.com"},
+			},
+			// Same handle in another dealership must not resolve
+			uuid.New().String(): {
+				"mjones": {ID: uuid.New().String(), Email: "mjones@other.com"},
+			},
+		},
+	}
+
+	logger := logging.New(logging.Config{
+		Service: "showroom-service-test",
+		Level:   logging.LevelError,
+		Output:  &bytes.Buffer{},
+	})
+	hub := NewHub(logger)
+	notifier := &recordingNotifier{}
+	handler := NewHandler(db, hub, notifier, logger)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/visits/{id}/notes", handler.CreateNote).Methods("POST")
+	router.HandleFunc("/mentions", handler.ListMentions).Methods("GET")
+
+	return router, hub, db, notifier, dealershipID, visitID
+}
+
+func TestCreateNoteMentionNotifies(t *testing.T) {
+	router, hub, db, notifier, dealershipID, visitID := setupMentionTest()
+	authorID := uuid.New().String()
+
+	body, _ := json.Marshal(CreateNoteRequest{Content: "@jsmith please follow up, cc @mjones @nobody"})
+	req := httptest.NewRequest("POST", "/visits/"+visitID+"/notes", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Dealership-ID", dealershipID)
+	req.Header.Set("X-User-ID", authorID)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	jsmith := db.users[dealershipID]["jsmith"]
+
+	if len(db.mentions) != 1 {
+		t.Fatalf("Expected 1 recorded mention, got %d", len(db.mentions))
+	}
+	if db.mentions[0].MentionedUserID != jsmith.ID || db.mentions[0].MentionedByID != authorID {
+		t.Errorf("Unexpected mention: %+v", db.mentions[0])
+	}
+
+	if len(notifier.notified) != 1 || notifier.notified[0].Email != jsmith.Email {
+		t.Errorf("Expected jsmith to be notified, got %+v", notifier.notified)
+	}
+
+	// NOTE_ADDED is followed by the MENTION event
+	var types []string
+	for len(hub.broadcast) > 0 {
+		var msg WSMessage
+		json.Unmarshal((<-hub.broadcast).Message, &msg)
+		types = append(types, msg.Type)
+	}
+	if !reflect.DeepEqual(types, []string{WSEventNoteAdded, WSEventMention}) {
+		t.Errorf("Expected NOTE_ADDED then MENTION broadcasts, got %v", types)
+	}
+
+	// The mention appears in jsmith's feed
+	req = httptest.NewRequest("GET", "/mentions", nil)
+	req.Header.Set("X-Dealership-ID", dealershipID)
+	req.Header.Set("X-User-ID", jsmith.ID)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var feed []Mention
+	if err := json.Unmarshal(rr.Body.Bytes(), &feed); err != nil {
+		t.Fatal(err)
+	}
+	if len(feed) != 1 || feed[0].VisitID != visitID {
+		t.Errorf("Expected one mention for the visit in the feed, got %+v", feed)
+	}
+}
+
+func TestCreateNoteSelfMentionIgnored(t *testing.T) {
+	router, _, db, notifier, dealershipID, visitID := setupMentionTest()
+	jsmith := db.users[dealershipID]["jsmith"]
+
+	body, _ := json.Marshal(CreateNoteRequest{Content: "Reminder for @jsmith"})
+	req := httptest.NewRequest("POST", "/visits/"+visitID+"/notes", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Dealership-ID", dealershipID)
+	req.Header.Set("X-User-ID", jsmith.ID)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(db.mentions) != 0 || len(notifier.notified) != 0 {
+		t.Errorf("Expected no mention for the author, got %d mentions", len(db.mentions))
+	}
+}
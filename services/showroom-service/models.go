@@ -18,7 +18,9 @@ type Visit struct {
 	Status        string     `json:"status"`
 	WorkflowStage int        `json:"workflow_stage"`
 	Source        *string    `json:"source,omitempty"`
+	Campaign      *string    `json:"campaign,omitempty"`
 	AppointmentID *string    `json:"appointment_id,omitempty"`
+	LostReason    *string    `json:"lost_reason,omitempty"`
 	CreatedAt     time.Time  `json:"created_at"`
 	UpdatedAt     time.Time  `json:"updated_at"`
 
@@ -65,6 +67,7 @@ type Timer struct {
 	ID              string     `json:"id"`
 	VisitID         string     `json:"visit_id"`
 	TimerType       string     `json:"timer_type"`
+	Category        string     `json:"category"`
 	StartTime       time.Time  `json:"start_time"`
 	EndTime         *time.Time `json:"end_time,omitempty"`
 	DurationSeconds *int64     `json:"duration_seconds,omitempty"`
@@ -84,6 +87,17 @@ type Note struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// Mention records a user being @mentioned in a visit note
+type Mention struct {
+	ID              string    `json:"id"`
+	NoteID          string    `json:"note_id"`
+	VisitID         string    `json:"visit_id"`
+	DealershipID    string    `json:"dealership_id"`
+	MentionedUserID string    `json:"mentioned_user_id"`
+	MentionedByID   string    `json:"mentioned_by_id"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
 // Event represents an audit trail entry
 type Event struct {
 	ID            string          `json:"id"`
@@ -133,6 +147,7 @@ type CreateVisitRequest struct {
 	VehicleID     *string `json:"vehicle_id,omitempty"`
 	StockNumber   *string `json:"stock_number,omitempty"`
 	Source        *string `json:"source,omitempty"`
+	Campaign      *string `json:"campaign,omitempty"`
 	InitialNote   *string `json:"initial_note,omitempty"`
 }
 
@@ -148,6 +163,12 @@ type ChangeStatusRequest struct {
 	Status string `json:"status"`
 }
 
+// CloseVisitRequest is the request body for closing a visit
+type CloseVisitRequest struct {
+	Status     string  `json:"status"`
+	LostReason *string `json:"lost_reason,omitempty"` // Required for CLOSED_LOST
+}
+
 // AttachVehicleRequest is the request body for attaching a vehicle
 type AttachVehicleRequest struct {
 	VehicleID   string  `json:"vehicle_id"`
@@ -157,6 +178,7 @@ type AttachVehicleRequest struct {
 // StartTimerRequest is the request body for starting a timer
 type StartTimerRequest struct {
 	TimerType string `json:"timer_type"`
+	Category  string `json:"category,omitempty"` // Defaults to the category implied by the timer type
 }
 
 // CreateNoteRequest is the request body for creating a note
@@ -177,6 +199,103 @@ type VisitsResponse struct {
 	Total  int     `json:"total"`
 }
 
+// SourceVisit is a visit as seen by the source report
+type SourceVisit struct {
+	ID          string
+	CustomerID  string
+	Source      string
+	Campaign    string
+	CheckInTime time.Time
+}
+
+// SourceDeal is a deal as seen by the source report. Gross is nil when the
+// vehicle's cost isn't known.
+type SourceDeal struct {
+	ID         string
+	CustomerID string
+	CreatedAt  time.Time
+	Gross      *float64
+}
+
+// SourceReportRow is the visits, conversions and gross for one source and
+// campaign
+type SourceReportRow struct {
+	Source         string  `json:"source"`
+	Campaign       string  `json:"campaign,omitempty"`
+	Visits         int     `json:"visits"`
+	Conversions    int     `json:"conversions"`
+	ConversionRate float64 `json:"conversion_rate"`
+	Deals          int     `json:"deals"`
+	Gross          float64 `json:"gross"`
+	GrossPerVisit  float64 `json:"gross_per_visit"`
+}
+
+// CategoryTimer is a stopped timer as seen by the timer analytics
+type CategoryTimer struct {
+	VisitID         string
+	TimerType       string
+	Category        string
+	DurationSeconds int64
+}
+
+// TimerCategoryRow is the time spent in one timer category
+type TimerCategoryRow struct {
+	Category               string  `json:"category"`
+	Timers                 int     `json:"timers"`
+	Visits                 int     `json:"visits"`
+	TotalSeconds           int64   `json:"total_seconds"`
+	AverageSeconds         float64 `json:"average_seconds"`
+	AveragePerVisitSeconds float64 `json:"average_per_visit_seconds"`
+}
+
+// TimerAnalytics is the response for the time-per-category analytics
+type TimerAnalytics struct {
+	DealershipID string             `json:"dealership_id"`
+	From         string             `json:"from"`
+	To           string             `json:"to"`
+	Categories   []TimerCategoryRow `json:"categories"`
+}
+
+// Kinds of lost outcome
+const (
+	LostOutcomeVisit = "visit"
+	LostOutcomeDeal  = "deal"
+)
+
+// LostOutcome is a visit closed without a deal or a cancelled deal, as seen by
+// the lost reason report
+type LostOutcome struct {
+	Kind   string
+	Reason string
+}
+
+// LostReasonRow is the lost visits and cancelled deals for one reason
+type LostReasonRow struct {
+	Reason string `json:"reason"`
+	Visits int    `json:"visits"`
+	Deals  int    `json:"deals"`
+	Total  int    `json:"total"`
+}
+
+// LostReasonReport is the response for the lost reason report
+type LostReasonReport struct {
+	DealershipID string          `json:"dealership_id"`
+	From         string          `json:"from"`
+	To           string          `json:"to"`
+	Reasons      []LostReasonRow `json:"reasons"`
+	Totals       LostReasonRow   `json:"totals"`
+}
+
+// SourceReport is the response for the visit source report
+type SourceReport struct {
+	DealershipID          string            `json:"dealership_id"`
+	From                  string            `json:"from"`
+	To                    string            `json:"to"`
+	AttributionWindowDays int               `json:"attribution_window_days"`
+	Sources               []SourceReportRow `json:"sources"`
+	Totals                SourceReportRow   `json:"totals"`
+}
+
 // Valid visit statuses
 var ValidStatuses = []string{
 	"CHECKED_IN",
@@ -197,13 +316,22 @@ var ValidTimerTypes = []string{
 	"MANAGER_WAIT",
 }
 
-// Valid visit sources
+// Valid timer categories, the default when SHOWROOM_TIMER_CATEGORIES isn't set
+var ValidTimerCategories = []string{
+	"WAITING",
+	"TEST_DRIVE",
+	"NEGOTIATION",
+	"F_AND_I",
+}
+
+// Valid visit sources, the default when SHOWROOM_VISIT_SOURCES isn't set
 var ValidSources = []string{
 	"WALK_IN",
 	"APPOINTMENT",
 	"INTERNET",
 	"PHONE",
 	"REFERRAL",
+	"CAMPAIGN",
 }
 
 // IsValidStatus checks if a status is valid
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SourceUnknown groups visits recorded without a source in the source report
+const SourceUnknown = "UNKNOWN"
+
+// SourceCampaign is the source for visits driven by a specific campaign,
+// which must then be named
+const SourceCampaign = "CAMPAIGN"
+
+// sourceAttributionWindow is how long after a visit a deal by the same
+// customer is still credited to it
+const sourceAttributionWindow = 30 * 24 * time.Hour
+
+// maxSourceReportRange is the longest period a source report may cover
+const maxSourceReportRange = 366 * 24 * time.Hour
+
+// visitSources is the list of accepted visit sources. It defaults to
+// ValidSources and is replaced by configureVisitSources.
+var visitSources = ValidSources
+
+// sourceAliases maps the spellings staff and lead providers commonly use to
+// the canonical source
+var sourceAliases = map[string]string{
+	"WALKIN":      "WALK_IN",
+	"APPT":        "APPOINTMENT",
+	"WEB":         "INTERNET",
+	"WEBSITE":     "INTERNET",
+	"ONLINE":      "INTERNET",
+	"CALL":        "PHONE",
+	"PHONE_CALL":  "PHONE",
+	"PHONE_UP":    "PHONE",
+	"REFERRED":    "REFERRAL",
+	"MARKETING":   "CAMPAIGN",
+	"PROMO":       "CAMPAIGN",
+	"PROMOTION":   "CAMPAIGN",
+	"ADVERTISING": "CAMPAIGN",
+}
+
+var (
+	sourceSeparatorRegex   = regexp.MustCompile(`[\s\-]+`)
+	campaignSeparatorRegex = regexp.MustCompile(`[\s_]+`)
+	campaignRegex          = regexp.MustCompile(`^[a-z0-9][a-z0-9\-.]*$`)
+)
+
+// NormalizeSource returns the canonical form of a visit source, so that
+// "walk-in", "Walk In" and "WALKIN" are all recorded as WALK_IN
+func NormalizeSource(source string) string {
+	normalized := strings.ToUpper(strings.TrimSpace(source))
+	normalized = sourceSeparatorRegex.ReplaceAllString(normalized, "_")
+	if alias, ok := sourceAliases[normalized]; ok {
+		return alias
+	}
+	return normalized
+}
+
+// NormalizeCampaign returns the canonical form of a campaign name, lowercase
+// with words joined by hyphens, so "Spring Sale 2026" is spring-sale-2026
+func NormalizeCampaign(campaign string) string {
+	normalized := strings.ToLower(strings.TrimSpace(campaign))
+	return campaignSeparatorRegex.ReplaceAllString(normalized, "-")
+}
+
+// configureVisitSources replaces the accepted visit sources with a comma
+// separated list. An empty list keeps the defaults.
+func configureVisitSources(list string) {
+	var sources []string
+	seen := make(map[string]bool)
+	for _, s := range strings.Split(list, ",") {
+		source := NormalizeSource(s)
+		if source == "" || seen[source] {
+			continue
+		}
+		seen[source] = true
+		sources = append(sources, source)
+	}
+	if len(sources) > 0 {
+		visitSources = sources
+	}
+}
+
+// IsValidSource checks if a normalized source is one of the accepted sources
+func IsValidSource(source string) bool {
+	for _, s := range visitSources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildSourceReport credits each deal to the customer's most recent visit
+// checked in before it, within the attribution window, and totals visits,
+// conversions and gross by source and campaign. Only visits checked in from
+// from up to to are reported; later visits are passed in so that a deal
+// following a repeat visit is credited to that visit instead.
+func BuildSourceReport(visits []SourceVisit, deals []SourceDeal, from, to time.Time) ([]SourceReportRow, SourceReportRow) {
+	byCustomer := make(map[string][]SourceVisit)
+	for _, v := range visits {
+		byCustomer[v.CustomerID] = append(byCustomer[v.CustomerID], v)
+	}
+
+	dealCounts := make(map[string]int)
+	grossCents := make(map[string]int64)
+	for _, d := range deals {
+		var credited *SourceVisit
+		for i, v := range byCustomer[d.CustomerID] {
+			if v.CheckInTime.After(d.CreatedAt) || d.CreatedAt.Sub(v.CheckInTime) > sourceAttributionWindow {
+				continue
+			}
+			if credited == nil || v.CheckInTime.After(credited.CheckInTime) {
+				credited = &byCustomer[d.CustomerID][i]
+			}
+		}
+		if credited == nil {
+			continue
+		}
+		dealCounts[credited.ID]++
+		if d.Gross != nil {
+			grossCents[credited.ID] += int64(math.Round(*d.Gross * 100))
+		}
+	}
+
+	type sourceKey struct{ source, campaign string }
+	rows := make(map[sourceKey]*SourceReportRow)
+	rowCents := make(map[sourceKey]int64)
+	totals := SourceReportRow{Source: "ALL"}
+	var totalCents int64
+
+	for _, v := range visits {
+		if v.CheckInTime.Before(from) || !v.CheckInTime.Before(to) {
+			continue
+		}
+
+		source := v.Source
+		if source == "" {
+			source = SourceUnknown
+		}
+		key := sourceKey{source, v.Campaign}
+		row, ok := rows[key]
+		if !ok {
+			row = &SourceReportRow{Source: source, Campaign: v.Campaign}
+			rows[key] = row
+		}
+
+		row.Visits++
+		totals.Visits++
+		if n := dealCounts[v.ID]; n > 0 {
+			row.Conversions++
+			row.Deals += n
+			totals.Conversions++
+			totals.Deals += n
+		}
+		rowCents[key] += grossCents[v.ID]
+		totalCents += grossCents[v.ID]
+	}
+
+	report := make([]SourceReportRow, 0, len(rows))
+	for key, row := range rows {
+		row.finish(rowCents[key])
+		report = append(report, *row)
+	}
+	totals.finish(totalCents)
+
+	// Highest grossing first, then by name for a stable order
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Gross != report[j].Gross {
+			return report[i].Gross > report[j].Gross
+		}
+		if report[i].Source != report[j].Source {
+			return report[i].Source < report[j].Source
+		}
+		return report[i].Campaign < report[j].Campaign
+	})
+
+	return report, totals
+}
+
+// finish sets the row's gross and the rates derived from its counts
+func (row *SourceReportRow) finish(grossCents int64) {
+	row.Gross = float64(grossCents) / 100
+	if row.Visits > 0 {
+		row.ConversionRate = math.Round(float64(row.Conversions)/float64(row.Visits)*10000) / 10000
+		row.GrossPerVisit = math.Round(float64(grossCents)/float64(row.Visits)) / 100
+	}
+}
+
+// GetSourceReport handles GET /source-report, visits, conversions and gross
+// by visit source and campaign
+func (h *Handler) GetSourceReport(w http.ResponseWriter, r *http.Request) {
+	dealershipID, from, to, ok := parseReportRange(w, r)
+	if !ok {
+		return
+	}
+
+	// to is inclusive; deals and repeat visits are read past it for the
+	// attribution window
+	end := to.AddDate(0, 0, 1)
+	log := h.logger.WithContext(r.Context()).WithField("dealership_id", dealershipID)
+
+	visits, err := h.db.ListSourceVisits(dealershipID, from, end.Add(sourceAttributionWindow))
+	if err != nil {
+		log.WithError(err).Error("Failed to list visits for source report")
+		respondError(w, http.StatusInternalServerError, "Failed to build source report")
+		return
+	}
+	deals, err := h.db.ListSourceDeals(dealershipID, from, end.Add(sourceAttributionWindow))
+	if err != nil {
+		log.WithError(err).Error("Failed to list deals for source report")
+		respondError(w, http.StatusInternalServerError, "Failed to build source report")
+		return
+	}
+
+	sources, totals := BuildSourceReport(visits, deals, from, end)
+
+	respondJSON(w, http.StatusOK, SourceReport{
+		DealershipID:          dealershipID,
+		From:                  from.Format("2006-01-02"),
+		To:                    to.Format("2006-01-02"),
+		AttributionWindowDays: int(sourceAttributionWindow / (24 * time.Hour)),
+		Sources:               sources,
+		Totals:                totals,
+	})
+}
+
+// parseReportRange reads the dealership and the inclusive from and to dates
+// of a report, defaulting to the last 30 days. The dealership_id parameter
+// must match the caller's dealership when both are given. It writes an error
+// and reports false when the request is invalid.
+func parseReportRange(w http.ResponseWriter, r *http.Request) (string, time.Time, time.Time, bool) {
+	query := r.URL.Query()
+
+	dealershipID := query.Get("dealership_id")
+	if header := getDealershipID(r); header != "" {
+		if dealershipID == "" {
+			dealershipID = header
+		} else if dealershipID != header {
+			respondError(w, http.StatusForbidden, "Cannot report on another dealership")
+			return "", time.Time{}, time.Time{}, false
+		}
+	}
+	if dealershipID == "" {
+		respondError(w, http.StatusBadRequest, "Missing dealership ID")
+		return "", time.Time{}, time.Time{}, false
+	}
+	if !validateUUIDShowroom(w, dealershipID, "dealership_id") {
+		return "", time.Time{}, time.Time{}, false
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from := today.AddDate(0, 0, -30)
+	to := today
+	var errs []ValidationError
+	if v := query.Get("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			errs = append(errs, ValidationError{Field: "from", Message: "Must be a date in YYYY-MM-DD format"})
+		}
+		from = t
+	}
+	if v := query.Get("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			errs = append(errs, ValidationError{Field: "to", Message: "Must be a date in YYYY-MM-DD format"})
+		}
+		to = t
+	}
+	if len(errs) == 0 {
+		if to.Before(from) {
+			errs = append(errs, ValidationError{Field: "to", Message: "Must not be before from"})
+		} else if to.Sub(from) > maxSourceReportRange {
+			errs = append(errs, ValidationError{Field: "to", Message: "Report range must be one year or less"})
+		}
+	}
+	if len(errs) > 0 {
+		respondValidationErrorShowroom(w, &ValidationErrors{Errors: errs})
+		return "", time.Time{}, time.Time{}, false
+	}
+
+	return dealershipID, from, to, true
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"autolytiq/shared/logging"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func TestNormalizeSource(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{"walk-in", "WALK_IN"},
+		{" Walk In ", "WALK_IN"},
+		{"WALKIN", "WALK_IN"},
+		{"web", "INTERNET"},
+		{"Phone Call", "PHONE"},
+		{"referral", "REFERRAL"},
+		{"campaign", "CAMPAIGN"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeSource(tt.source); got != tt.expected {
+			t.Errorf("NormalizeSource(%q) = %q, want %q", tt.source, got, tt.expected)
+		}
+	}
+}
+
+func TestCreateVisitSourceValidation(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name     string
+		source   *string
+		campaign *string
+		valid    bool
+	}{
+		{"no source", nil, nil, true},
+		{"alias is normalized", str("walk-in"), nil, true},
+		{"unknown source", str("billboard"), nil, false},
+		{"campaign named", str("Campaign"), str("Spring Sale 2026"), true},
+		{"campaign without name", str("campaign"), nil, false},
+		{"campaign with bad characters", str("campaign"), str("50% off!"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := CreateVisitRequest{CustomerID: uuid.New().String(), Source: tt.source, Campaign: tt.campaign}
+			req.Sanitize()
+			if errs := req.Validate(); (errs == nil) != tt.valid {
+				t.Errorf("Expected valid=%v, got %+v", tt.valid, errs)
+			}
+		})
+	}
+
+	req := CreateVisitRequest{CustomerID: uuid.New().String(), Source: str("Campaign"), Campaign: str(" Spring  Sale_2026 ")}
+	req.Sanitize()
+	if *req.Source != "CAMPAIGN" || *req.Campaign != "spring-sale-2026" {
+		t.Errorf("Expected CAMPAIGN/spring-sale-2026, got %s/%s", *req.Source, *req.Campaign)
+	}
+}
+
+func TestConfigureVisitSources(t *testing.T) {
+	defer func(sources []string) { visitSources = sources }(visitSources)
+
+	configureVisitSources("walk-in, Trade Show,web,,WALK_IN")
+
+	for source, valid := range map[string]bool{"WALK_IN": true, "TRADE_SHOW": true, "INTERNET": true, "PHONE": false} {
+		if IsValidSource(source) != valid {
+			t.Errorf("Expected IsValidSource(%q) = %v", source, valid)
+		}
+	}
+	if len(visitSources) != 3 {
+		t.Errorf("Expected 3 configured sources, got %v", visitSources)
+	}
+
+	configureVisitSources(" , ")
+	if len(visitSources) != 3 {
+		t.Errorf("Expected an empty list to keep the sources, got %v", visitSources)
+	}
+}
+
+// sourceReportDB implements the source report parts of ShowroomDatabase
+type sourceReportDB struct {
+	ShowroomDatabase
+
+	visits []SourceVisit
+	deals  []SourceDeal
+}
+
+func (m *sourceReportDB) ListSourceVisits(dealershipID string, from, to time.Time) ([]SourceVisit, error) {
+	var visits []SourceVisit
+	for _, v := range m.visits {
+		if !v.CheckInTime.Before(from) && v.CheckInTime.Before(to) {
+			visits = append(visits, v)
+		}
+	}
+	return visits, nil
+}
+
+func (m *sourceReportDB) ListSourceDeals(dealershipID string, from, to time.Time) ([]SourceDeal, error) {
+	var deals []SourceDeal
+	for _, d := range m.deals {
+		if !d.CreatedAt.Before(from) && d.CreatedAt.Before(to) {
+			deals = append(deals, d)
+		}
+	}
+	return deals, nil
+}
+
+// seedSourceReport returns visits and deals across March 2026
+func seedSourceReport() *sourceReportDB {
+	day := func(d, hour int) time.Time { return time.Date(2026, 3, d, hour, 0, 0, 0, time.UTC) }
+	gross := func(g float64) *float64 { return &g }
+
+	return &sourceReportDB{
+		visits: []SourceVisit{
+			{ID: "v1", CustomerID: "alice", Source: "WALK_IN", CheckInTime: day(2, 10)},
+			{ID: "v2", CustomerID: "bob", Source: "WALK_IN", CheckInTime: day(3, 11)},
+			{ID: "v3", CustomerID: "carol", Source: "INTERNET", CheckInTime: day(5, 9)},
+			{ID: "v4", CustomerID: "dave", Source: "CAMPAIGN", Campaign: "spring-sale", CheckInTime: day(6, 14)},
+			{ID: "v5", CustomerID: "erin", Source: "CAMPAIGN", Campaign: "spring-sale", CheckInTime: day(7, 15)},
+			{ID: "v6", CustomerID: "frank", CheckInTime: day(8, 16)},
+			// Carol came back from a referral before buying
+			{ID: "v7", CustomerID: "carol", Source: "REFERRAL", CheckInTime: day(10, 12)},
+			// Outside the report period
+			{ID: "v8", CustomerID: "gina", Source: "PHONE", CheckInTime: day(20, 10)},
+		},
+		deals: []SourceDeal{
+			{ID: "d1", CustomerID: "alice", CreatedAt: day(2, 13), Gross: gross(1500.25)},
+			{ID: "d2", CustomerID: "carol", CreatedAt: day(11, 10), Gross: gross(2200)},
+			{ID: "d3", CustomerID: "dave", CreatedAt: day(6, 17), Gross: gross(999.99)},
+			// Dave bought a second car, with no known cost
+			{ID: "d4", CustomerID: "dave", CreatedAt: day(9, 10)},
+			// Erin bought long after the attribution window
+			{ID: "d5", CustomerID: "erin", CreatedAt: time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC), Gross: gross(800)},
+			// Frank's deal came before his visit
+			{ID: "d6", CustomerID: "frank", CreatedAt: day(1, 10), Gross: gross(500)},
+		},
+	}
+}
+
+func TestBuildSourceReport(t *testing.T) {
+	db := seedSourceReport()
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC)
+
+	rows, totals := BuildSourceReport(db.visits, db.deals, from, to)
+
+	expected := []SourceReportRow{
+		{Source: "REFERRAL", Visits: 1, Conversions: 1, ConversionRate: 1, Deals: 1, Gross: 2200, GrossPerVisit: 2200},
+		{Source: "WALK_IN", Visits: 2, Conversions: 1, ConversionRate: 0.5, Deals: 1, Gross: 1500.25, GrossPerVisit: 750.13},
+		{Source: "CAMPAIGN", Campaign: "spring-sale", Visits: 2, Conversions: 1, ConversionRate: 0.5, Deals: 2, Gross: 999.99, GrossPerVisit: 500},
+		{Source: "INTERNET", Visits: 1},
+		{Source: "UNKNOWN", Visits: 1},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("Expected %d rows, got %+v", len(expected), rows)
+	}
+	for i := range expected {
+		if rows[i] != expected[i] {
+			t.Errorf("Row %d: expected %+v, got %+v", i, expected[i], rows[i])
+		}
+	}
+
+	want := SourceReportRow{Source: "ALL", Visits: 7, Conversions: 3, ConversionRate: 0.4286, Deals: 4, Gross: 4700.24, GrossPerVisit: 671.46}
+	if totals != want {
+		t.Errorf("Expected totals %+v, got %+v", want, totals)
+	}
+}
+
+func setupSourceReportTest(db ShowroomDatabase) *mux.Router {
+	logger := logging.New(logging.Config{
+		Service: "showroom-service-test",
+		Level:   logging.LevelError,
+		Output:  &bytes.Buffer{},
+	})
+	handler := NewHandler(db, NewHub(logger), nil, logger)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/source-report", handler.GetSourceReport).Methods("GET")
+	return router
+}
+
+func TestGetSourceReport(t *testing.T) {
+	router := setupSourceReportTest(seedSourceReport())
+	dealershipID := uuid.New().String()
+
+	req := httptest.NewRequest("GET", "/source-report?dealership_id="+dealershipID+"&from=2026-03-01&to=2026-03-15", nil)
+	req.Header.Set("X-Dealership-ID", dealershipID)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var report SourceReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.From != "2026-03-01" || report.To != "2026-03-15" || report.AttributionWindowDays != 30 {
+		t.Errorf("Unexpected report period: %+v", report)
+	}
+	if len(report.Sources) != 5 || report.Totals.Visits != 7 || report.Totals.Gross != 4700.24 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
+
+func TestGetSourceReportRejectsBadRequests(t *testing.T) {
+	router := setupSourceReportTest(seedSourceReport())
+	dealershipID := uuid.New().String()
+
+	tests := []struct {
+		name     string
+		query    string
+		header   string
+		expected int
+	}{
+		{"missing dealership", "", "", http.StatusBadRequest},
+		{"another dealership", "?dealership_id=" + uuid.New().String(), dealershipID, http.StatusForbidden},
+		{"invalid dealership", "?dealership_id=abc", "", http.StatusBadRequest},
+		{"invalid date", "?from=03/01/2026", dealershipID, http.StatusBadRequest},
+		{"reversed range", "?from=2026-03-15&to=2026-03-01", dealershipID, http.StatusBadRequest},
+		{"range too long", "?from=2024-01-01&to=2026-03-01", dealershipID, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/source-report"+tt.query, nil)
+			if tt.header != "" {
+				req.Header.Set("X-Dealership-ID", tt.header)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d: %s", tt.expected, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// TimerCategoryUncategorized groups timers with no category, and none implied
+// by their type, in the analytics
+const TimerCategoryUncategorized = "UNCATEGORIZED"
+
+// ErrTimerCategoryActive is returned when starting a timer on a visit that
+// already has a running timer of the same category
+var ErrTimerCategoryActive = errors.New("timer category already active")
+
+// timerCategories is the list of accepted timer categories. It defaults to
+// ValidTimerCategories and is replaced by configureTimerCategories.
+var timerCategories = ValidTimerCategories
+
+// timerTypeCategories is the category a timer is recorded under when it is
+// started without one
+var timerTypeCategories = map[string]string{
+	"WAIT_TIME":    "WAITING",
+	"MANAGER_WAIT": "WAITING",
+	"TEST_DRIVE":   "TEST_DRIVE",
+	"NEGOTIATION":  "NEGOTIATION",
+	"PAPERWORK":    "F_AND_I",
+}
+
+// NormalizeTimerCategory returns the canonical form of a timer category, so
+// that "test drive" and "Test-Drive" are both TEST_DRIVE
+func NormalizeTimerCategory(category string) string {
+	normalized := strings.ToUpper(strings.TrimSpace(category))
+	return sourceSeparatorRegex.ReplaceAllString(normalized, "_")
+}
+
+// configureTimerCategories replaces the accepted timer categories with a
+// comma separated list. An empty list keeps the defaults.
+func configureTimerCategories(list string) {
+	var categories []string
+	seen := make(map[string]bool)
+	for _, c := range strings.Split(list, ",") {
+		category := NormalizeTimerCategory(c)
+		if category == "" || seen[category] {
+			continue
+		}
+		seen[category] = true
+		categories = append(categories, category)
+	}
+	if len(categories) > 0 {
+		timerCategories = categories
+	}
+}
+
+// IsValidTimerCategory checks if a normalized category is one of the accepted
+// timer categories
+func IsValidTimerCategory(category string) bool {
+	for _, c := range timerCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// timerCategory returns the category a timer is reported under, falling back
+// to the one implied by its type for timers recorded without one
+func timerCategory(category, timerType string) string {
+	if category != "" {
+		return category
+	}
+	if implied, ok := timerTypeCategories[timerType]; ok {
+		return implied
+	}
+	return TimerCategoryUncategorized
+}
+
+// BuildTimerAnalytics totals the time spent in each category across the
+// given stopped timers. Every accepted category gets a row, in configured
+// order, followed by any other categories found on older timers.
+func BuildTimerAnalytics(timers []CategoryTimer) []TimerCategoryRow {
+	type categoryTotals struct {
+		timers  int
+		visits  map[string]bool
+		seconds int64
+	}
+	totals := make(map[string]*categoryTotals)
+	for _, t := range timers {
+		category := timerCategory(t.Category, t.TimerType)
+		ct, ok := totals[category]
+		if !ok {
+			ct = &categoryTotals{visits: make(map[string]bool)}
+			totals[category] = ct
+		}
+		ct.timers++
+		ct.visits[t.VisitID] = true
+		ct.seconds += t.DurationSeconds
+	}
+
+	categories := append([]string(nil), timerCategories...)
+	var others []string
+	for category := range totals {
+		if !IsValidTimerCategory(category) {
+			others = append(others, category)
+		}
+	}
+	sort.Strings(others)
+	categories = append(categories, others...)
+
+	rows := make([]TimerCategoryRow, 0, len(categories))
+	for _, category := range categories {
+		row := TimerCategoryRow{Category: category}
+		if ct, ok := totals[category]; ok {
+			row.Timers = ct.timers
+			row.Visits = len(ct.visits)
+			row.TotalSeconds = ct.seconds
+			row.AverageSeconds = math.Round(float64(ct.seconds)/float64(ct.timers)*100) / 100
+			row.AveragePerVisitSeconds = math.Round(float64(ct.seconds)/float64(row.Visits)*100) / 100
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// GetTimerAnalytics handles GET /analytics/timers, the average time spent in
+// each timer category by visits checked in over the period
+func (h *Handler) GetTimerAnalytics(w http.ResponseWriter, r *http.Request) {
+	dealershipID, from, to, ok := parseReportRange(w, r)
+	if !ok {
+		return
+	}
+
+	timers, err := h.db.ListCategoryTimers(dealershipID, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		h.logger.WithContext(r.Context()).WithField("dealership_id", dealershipID).
+			WithError(err).Error("Failed to list timers for analytics")
+		respondError(w, http.StatusInternalServerError, "Failed to build timer analytics")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, TimerAnalytics{
+		DealershipID: dealershipID,
+		From:         from.Format("2006-01-02"),
+		To:           to.Format("2006-01-02"),
+		Categories:   BuildTimerAnalytics(timers),
+	})
+}
+
+// activeTimerInCategory returns the visit's running timer in the category,
+// or nil
+func activeTimerInCategory(timers []Timer, category string) *Timer {
+	for i, t := range timers {
+		if t.EndTime == nil && timerCategory(t.Category, t.TimerType) == category {
+			return &timers[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"autolytiq/shared/logging"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func TestStartTimerRequestCategory(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      StartTimerRequest
+		category string
+		valid    bool
+	}{
+		{"implied by type", StartTimerRequest{TimerType: "manager_wait"}, "WAITING", true},
+		{"paperwork is F&I", StartTimerRequest{TimerType: "PAPERWORK"}, "F_AND_I", true},
+		{"explicit category", StartTimerRequest{TimerType: "WAIT_TIME", Category: "test drive"}, "TEST_DRIVE", true},
+		{"unknown category", StartTimerRequest{TimerType: "WAIT_TIME", Category: "lunch"}, "LUNCH", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.Sanitize()
+			if tt.req.Category != tt.category {
+				t.Errorf("Expected category %q, got %q", tt.category, tt.req.Category)
+			}
+			if errs := tt.req.Validate(); (errs == nil) != tt.valid {
+				t.Errorf("Expected valid=%v, got %+v", tt.valid, errs)
+			}
+		})
+	}
+}
+
+func TestConfigureTimerCategories(t *testing.T) {
+	defer func(categories []string) { timerCategories = categories }(timerCategories)
+
+	configureTimerCategories("waiting, Test Drive,appraisal,,WAITING")
+
+	for category, valid := range map[string]bool{"WAITING": true, "TEST_DRIVE": true, "APPRAISAL": true, "F_AND_I": false} {
+		if IsValidTimerCategory(category) != valid {
+			t.Errorf("Expected IsValidTimerCategory(%q) = %v", category, valid)
+		}
+	}
+	if len(timerCategories) != 3 {
+		t.Errorf("Expected 3 configured categories, got %v", timerCategories)
+	}
+}
+
+func TestBuildTimerAnalytics(t *testing.T) {
+	timers := []CategoryTimer{
+		{VisitID: "v1", TimerType: "WAIT_TIME", Category: "WAITING", DurationSeconds: 300},
+		{VisitID: "v1", TimerType: "MANAGER_WAIT", Category: "WAITING", DurationSeconds: 120},
+		{VisitID: "v2", TimerType: "WAIT_TIME", Category: "WAITING", DurationSeconds: 600},
+		{VisitID: "v1", TimerType: "TEST_DRIVE", Category: "TEST_DRIVE", DurationSeconds: 1800},
+		{VisitID: "v2", TimerType: "TEST_DRIVE", Category: "TEST_DRIVE", DurationSeconds: 1201},
+		// Recorded before timers had categories
+		{VisitID: "v3", TimerType: "PAPERWORK", DurationSeconds: 2400},
+		// A category that has since been removed from the configuration
+		{VisitID: "v3", TimerType: "WAIT_TIME", Category: "APPRAISAL", DurationSeconds: 900},
+	}
+
+	rows := BuildTimerAnalytics(timers)
+
+	expected := []TimerCategoryRow{
+		{Category: "WAITING", Timers: 3, Visits: 2, TotalSeconds: 1020, AverageSeconds: 340, AveragePerVisitSeconds: 510},
+		{Category: "TEST_DRIVE", Timers: 2, Visits: 2, TotalSeconds: 3001, AverageSeconds: 1500.5, AveragePerVisitSeconds: 1500.5},
+		{Category: "NEGOTIATION"},
+		{Category: "F_AND_I", Timers: 1, Visits: 1, TotalSeconds: 2400, AverageSeconds: 2400, AveragePerVisitSeconds: 2400},
+		{Category: "APPRAISAL", Timers: 1, Visits: 1, TotalSeconds: 900, AverageSeconds: 900, AveragePerVisitSeconds: 900},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("Expected %d rows, got %+v", len(expected), rows)
+	}
+	for i := range expected {
+		if rows[i] != expected[i] {
+			t.Errorf("Row %d: expected %+v, got %+v", i, expected[i], rows[i])
+		}
+	}
+}
+
+// timerTestDB implements the timer parts of ShowroomDatabase
+type timerTestDB struct {
+	ShowroomDatabase
+
+	dealershipID string
+	visit        Visit
+	timers       []Timer
+	stopped      []CategoryTimer
+}
+
+func (m *timerTestDB) GetVisit(id, dealershipID string) (*Visit, error) {
+	if id != m.visit.ID || dealershipID != m.dealershipID {
+		return nil, errors.New("visit not found")
+	}
+	visit := m.visit
+	visit.Timers = append([]Timer(nil), m.timers...)
+	return &visit, nil
+}
+
+func (m *timerTestDB) GetTimers(visitID string) ([]Timer, error) {
+	return m.timers, nil
+}
+
+func (m *timerTestDB) StartTimer(visitID, timerType, category string, userID *string) (*Timer, error) {
+	timer := Timer{ID: uuid.New().String(), VisitID: visitID, TimerType: timerType, Category: category, StartTime: time.Now()}
+	m.timers = append(m.timers, timer)
+	return &timer, nil
+}
+
+func (m *timerTestDB) CreateEvent(visitID, eventType string, userID, previousValue, newValue *string, metadata []byte) error {
+	return nil
+}
+
+func (m *timerTestDB) ListCategoryTimers(dealershipID string, from, to time.Time) ([]CategoryTimer, error) {
+	return m.stopped, nil
+}
+
+func setupTimerTest(db ShowroomDatabase) *mux.Router {
+	logger := logging.New(logging.Config{
+		Service: "showroom-service-test",
+		Level:   logging.LevelError,
+		Output:  &bytes.Buffer{},
+	})
+	handler := NewHandler(db, NewHub(logger), nil, logger)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/visits/{id}/timers", handler.ListTimers).Methods("GET")
+	router.HandleFunc("/visits/{id}/timers", handler.StartTimer).Methods("POST")
+	router.HandleFunc("/analytics/timers", handler.GetTimerAnalytics).Methods("GET")
+	return router
+}
+
+func sendTimerRequest(router *mux.Router, dealershipID, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewBuffer(data))
+	req.Header.Set("X-Dealership-ID", dealershipID)
+	req.Header.Set("X-User-ID", uuid.New().String())
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestStartTimerRejectsConcurrentCategory(t *testing.T) {
+	db := &timerTestDB{dealershipID: uuid.New().String(), visit: Visit{ID: uuid.New().String()}}
+	router := setupTimerTest(db)
+	path := "/visits/" + db.visit.ID + "/timers"
+
+	rr := sendTimerRequest(router, db.dealershipID, "POST", path, StartTimerRequest{TimerType: "WAIT_TIME"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var timer Timer
+	json.Unmarshal(rr.Body.Bytes(), &timer)
+	if timer.Category != "WAITING" {
+		t.Errorf("Expected a WAITING timer, got %+v", timer)
+	}
+
+	// A manager wait is also waiting, so it can't run alongside
+	rr = sendTimerRequest(router, db.dealershipID, "POST", path, StartTimerRequest{TimerType: "MANAGER_WAIT"})
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a second WAITING timer, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Other categories can
+	rr = sendTimerRequest(router, db.dealershipID, "POST", path, StartTimerRequest{TimerType: "TEST_DRIVE"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 for a TEST_DRIVE timer, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Once the first is stopped another waiting timer can start
+	end := time.Now()
+	db.timers[0].EndTime = &end
+	rr = sendTimerRequest(router, db.dealershipID, "POST", path, StartTimerRequest{TimerType: "MANAGER_WAIT"})
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Expected 201 after the first WAITING timer stopped, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = sendTimerRequest(router, db.dealershipID, "GET", path+"?category=waiting", nil)
+	var waiting []Timer
+	json.Unmarshal(rr.Body.Bytes(), &waiting)
+	if len(waiting) != 2 {
+		t.Errorf("Expected 2 WAITING timers, got %+v", waiting)
+	}
+}
+
+func TestGetTimerAnalytics(t *testing.T) {
+	db := &timerTestDB{
+		dealershipID: uuid.New().String(),
+		stopped: []CategoryTimer{
+			{VisitID: "v1", TimerType: "NEGOTIATION", Category: "NEGOTIATION", DurationSeconds: 900},
+			{VisitID: "v2", TimerType: "NEGOTIATION", Category: "NEGOTIATION", DurationSeconds: 1500},
+		},
+	}
+	router := setupTimerTest(db)
+
+	rr := sendTimerRequest(router, db.dealershipID, "GET", "/analytics/timers?from=2026-03-01&to=2026-03-15", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var analytics TimerAnalytics
+	if err := json.Unmarshal(rr.Body.Bytes(), &analytics); err != nil {
+		t.Fatal(err)
+	}
+	if analytics.From != "2026-03-01" || analytics.To != "2026-03-15" || len(analytics.Categories) != len(ValidTimerCategories) {
+		t.Fatalf("Unexpected analytics: %+v", analytics)
+	}
+	for _, row := range analytics.Categories {
+		if row.Category == "NEGOTIATION" && (row.Timers != 2 || row.AverageSeconds != 1200) {
+			t.Errorf("Expected 2 negotiations averaging 1200s, got %+v", row)
+		}
+	}
+
+	rr = sendTimerRequest(router, db.dealershipID, "GET", "/analytics/timers?dealership_id="+uuid.New().String(), nil)
+	if rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "another dealership") {
+		t.Errorf("Expected 403 for another dealership, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -45,14 +45,6 @@ var (
 		"PAPERWORK":    true,
 		"MANAGER_WAIT": true,
 	}
-
-	validVisitSources = map[string]bool{
-		"WALK_IN":     true,
-		"APPOINTMENT": true,
-		"INTERNET":    true,
-		"PHONE":       true,
-		"REFERRAL":    true,
-	}
 )
 
 // Validate validates CreateVisitRequest
@@ -97,10 +89,30 @@ func (r *CreateVisitRequest) Validate() *ValidationErrors {
 	}
 
 	// Source validation (optional)
-	if r.Source != nil && *r.Source != "" && !validVisitSources[strings.ToUpper(*r.Source)] {
+	if r.Source != nil && !IsValidSource(*r.Source) {
 		errors = append(errors, ValidationError{
 			Field:   "source",
-			Message: "Invalid source. Must be one of: WALK_IN, APPOINTMENT, INTERNET, PHONE, REFERRAL",
+			Message: "Invalid source. Must be one of: " + strings.Join(visitSources, ", "),
+		})
+	}
+
+	// Campaign validation (required for campaign visits)
+	if r.Campaign == nil {
+		if r.Source != nil && *r.Source == SourceCampaign {
+			errors = append(errors, ValidationError{
+				Field:   "campaign",
+				Message: "Campaign is required when the source is CAMPAIGN",
+			})
+		}
+	} else if len(*r.Campaign) > 100 {
+		errors = append(errors, ValidationError{
+			Field:   "campaign",
+			Message: "Campaign must be 100 characters or less",
+		})
+	} else if !campaignRegex.MatchString(*r.Campaign) {
+		errors = append(errors, ValidationError{
+			Field:   "campaign",
+			Message: "Campaign may only contain letters, numbers, hyphens and periods",
 		})
 	}
 
@@ -134,8 +146,18 @@ func (r *CreateVisitRequest) Sanitize() {
 		r.StockNumber = &val
 	}
 	if r.Source != nil {
-		val := strings.TrimSpace(strings.ToUpper(*r.Source))
+		val := NormalizeSource(*r.Source)
 		r.Source = &val
+		if val == "" {
+			r.Source = nil
+		}
+	}
+	if r.Campaign != nil {
+		val := NormalizeCampaign(*r.Campaign)
+		r.Campaign = &val
+		if val == "" {
+			r.Campaign = nil
+		}
 	}
 	if r.InitialNote != nil {
 		val := strings.TrimSpace(*r.InitialNote)
@@ -221,6 +243,57 @@ func (r *ChangeStatusRequest) Sanitize() {
 	r.Status = strings.TrimSpace(strings.ToUpper(r.Status))
 }
 
+// Validate validates CloseVisitRequest
+func (r *CloseVisitRequest) Validate() *ValidationErrors {
+	var errors []ValidationError
+
+	if r.Status == "" {
+		errors = append(errors, ValidationError{
+			Field:   "status",
+			Message: "Status is required",
+		})
+	} else if !IsClosedStatus(r.Status) {
+		errors = append(errors, ValidationError{
+			Field:   "status",
+			Message: "Status must be CLOSED_WON or CLOSED_LOST",
+		})
+	}
+
+	// A visit closed without a deal needs a reason from the configured list
+	if r.Status == "CLOSED_LOST" {
+		if r.LostReason == nil || *r.LostReason == "" {
+			errors = append(errors, ValidationError{
+				Field:   "lost_reason",
+				Message: "Lost reason is required to close a visit as lost. Must be one of: " + strings.Join(lostReasons, ", "),
+			})
+		} else if !IsValidLostReason(*r.LostReason) {
+			errors = append(errors, ValidationError{
+				Field:   "lost_reason",
+				Message: "Invalid lost reason. Must be one of: " + strings.Join(lostReasons, ", "),
+			})
+		}
+	} else if r.LostReason != nil && *r.LostReason != "" {
+		errors = append(errors, ValidationError{
+			Field:   "lost_reason",
+			Message: "Lost reason is only allowed when closing a visit as lost",
+		})
+	}
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize sanitizes CloseVisitRequest
+func (r *CloseVisitRequest) Sanitize() {
+	r.Status = strings.TrimSpace(strings.ToUpper(r.Status))
+	if r.LostReason != nil {
+		reason := strings.ToLower(strings.TrimSpace(*r.LostReason))
+		r.LostReason = &reason
+	}
+}
+
 // Validate validates AttachVehicleRequest
 func (r *AttachVehicleRequest) Validate() *ValidationErrors {
 	var errors []ValidationError
@@ -278,6 +351,14 @@ func (r *StartTimerRequest) Validate() *ValidationErrors {
 		})
 	}
 
+	// Category validation (defaults from the timer type)
+	if r.Category != "" && !IsValidTimerCategory(r.Category) {
+		errors = append(errors, ValidationError{
+			Field:   "category",
+			Message: "Invalid category. Must be one of: " + strings.Join(timerCategories, ", "),
+		})
+	}
+
 	if len(errors) > 0 {
 		return &ValidationErrors{Errors: errors}
 	}
@@ -287,6 +368,10 @@ func (r *StartTimerRequest) Validate() *ValidationErrors {
 // Sanitize sanitizes StartTimerRequest
 func (r *StartTimerRequest) Sanitize() {
 	r.TimerType = strings.TrimSpace(strings.ToUpper(r.TimerType))
+	r.Category = NormalizeTimerCategory(r.Category)
+	if r.Category == "" {
+		r.Category = timerTypeCategories[r.TimerType]
+	}
 }
 
 // Validate validates CreateNoteRequest
@@ -24,7 +24,8 @@ type Client struct {
 	hub          *Hub
 	conn         *websocket.Conn
 	send         chan []byte
-	dealershipID string
+	userID       string
+	dealershipID string // Fixed at connect, from the authenticated upgrade
 	logger       *logging.Logger
 }
 
@@ -37,6 +38,10 @@ type Hub struct {
 	unregister chan *Client
 	mu         sync.RWMutex
 	logger     *logging.Logger
+
+	// done is closed by Close to stop the hub and disconnect its clients
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
 // BroadcastMessage is a message to be broadcast to clients
@@ -51,7 +56,8 @@ type WSMessage struct {
 	Data interface{} `json:"data,omitempty"`
 }
 
-// SubscribeMessage is sent by clients to subscribe to a dealership
+// SubscribeMessage is sent by clients to subscribe to a dealership. Clients
+// are already in their own dealership's room, so it only confirms it.
 type SubscribeMessage struct {
 	Type         string `json:"type"`
 	DealershipID string `json:"dealership_id"`
@@ -66,16 +72,30 @@ func NewHub(logger *logging.Logger) *Hub {
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		logger:     logger,
+		done:       make(chan struct{}),
 	}
 }
 
+// Close stops the hub and sends every client a close frame, for shutdown.
+// Broadcasts after Close are dropped.
+func (h *Hub) Close() {
+	h.closeOnce.Do(func() { close(h.done) })
+}
+
 // Run starts the hub's main loop
 func (h *Hub) Run() {
 	for {
 		select {
+		case <-h.done:
+			return
+
 		case client := <-h.register:
 			h.mu.Lock()
 			h.clients[client] = true
+			if _, ok := h.rooms[client.dealershipID]; !ok {
+				h.rooms[client.dealershipID] = make(map[*Client]bool)
+			}
+			h.rooms[client.dealershipID][client] = true
 			h.mu.Unlock()
 			h.logger.WithFields(map[string]interface{}{
 				"total_clients": len(h.clients),
@@ -88,12 +108,10 @@ func (h *Hub) Run() {
 				close(client.send)
 
 				// Remove from room
-				if client.dealershipID != "" {
-					if room, ok := h.rooms[client.dealershipID]; ok {
-						delete(room, client)
-						if len(room) == 0 {
-							delete(h.rooms, client.dealershipID)
-						}
+				if room, ok := h.rooms[client.dealershipID]; ok {
+					delete(room, client)
+					if len(room) == 0 {
+						delete(h.rooms, client.dealershipID)
 					}
 				}
 			}
@@ -133,16 +151,22 @@ func (h *Hub) Broadcast(dealershipID string, messageType string, data interface{
 		return
 	}
 
-	h.broadcast <- &BroadcastMessage{
+	select {
+	case h.broadcast <- &BroadcastMessage{
 		DealershipID: dealershipID,
 		Message:      jsonData,
+	}:
+	case <-h.done:
 	}
 }
 
 // readPump pumps messages from the WebSocket connection to the hub
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.done:
+		}
 		c.conn.Close()
 	}()
 
@@ -170,43 +194,34 @@ func (c *Client) readPump() {
 		}
 
 		// Handle subscription
-		if subMsg.Type == "SUBSCRIBE" && subMsg.DealershipID != "" {
-			c.hub.mu.Lock()
-
-			// Remove from old room if any
-			if c.dealershipID != "" {
-				if room, ok := c.hub.rooms[c.dealershipID]; ok {
-					delete(room, c)
-					if len(room) == 0 {
-						delete(c.hub.rooms, c.dealershipID)
-					}
-				}
+		if subMsg.Type == "SUBSCRIBE" {
+			if subMsg.DealershipID != "" && subMsg.DealershipID != c.dealershipID {
+				c.logger.WithFields(map[string]interface{}{
+					"user_id":       c.userID,
+					"dealership_id": subMsg.DealershipID,
+				}).Warn("Rejected showroom subscription to another dealership")
+				c.sendMessage(WSMessage{
+					Type: "ERROR",
+					Data: map[string]string{"message": "Cannot subscribe to another dealership"},
+				})
+				continue
 			}
 
-			// Add to new room
-			c.dealershipID = subMsg.DealershipID
-			if _, ok := c.hub.rooms[c.dealershipID]; !ok {
-				c.hub.rooms[c.dealershipID] = make(map[*Client]bool)
-			}
-			c.hub.rooms[c.dealershipID][c] = true
-
-			c.hub.mu.Unlock()
-
-			c.logger.WithFields(map[string]interface{}{
-				"dealership_id": c.dealershipID,
-			}).Debug("Client subscribed to dealership")
-
 			// Send confirmation
-			confirmMsg := WSMessage{
+			c.sendMessage(WSMessage{
 				Type: "SUBSCRIBED",
 				Data: map[string]string{"dealership_id": c.dealershipID},
-			}
-			jsonData, _ := json.Marshal(confirmMsg)
-			c.send <- jsonData
+			})
 		}
 	}
 }
 
+// sendMessage queues a message for the client
+func (c *Client) sendMessage(msg WSMessage) {
+	jsonData, _ := json.Marshal(msg)
+	c.send <- jsonData
+}
+
 // writePump pumps messages from the hub to the WebSocket connection
 func (c *Client) writePump() {
 	ticker := time.NewTicker(54 * time.Second)
@@ -246,12 +261,26 @@ func (c *Client) writePump() {
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
+
+		case <-c.hub.done:
+			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"))
+			return
 		}
 	}
 }
 
-// ServeWS handles WebSocket requests from clients
+// ServeWS handles WebSocket requests from clients. The gateway authenticates
+// the upgrade and passes the token's user and dealership; the client only
+// receives its dealership's events.
 func ServeWS(hub *Hub, w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-ID")
+	dealershipID := r.Header.Get("X-Dealership-ID")
+	if userID == "" || dealershipID == "" {
+		respondError(w, http.StatusUnauthorized, "Missing user or dealership context")
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		hub.logger.WithError(err).Error("WebSocket upgrade error")
@@ -259,13 +288,20 @@ func ServeWS(hub *Hub, w http.ResponseWriter, r *http.Request) {
 	}
 
 	client := &Client{
-		hub:    hub,
-		conn:   conn,
-		send:   make(chan []byte, 256),
-		logger: hub.logger,
+		hub:          hub,
+		conn:         conn,
+		send:         make(chan []byte, 256),
+		userID:       userID,
+		dealershipID: dealershipID,
+		logger:       hub.logger,
 	}
 
-	client.hub.register <- client
+	select {
+	case client.hub.register <- client:
+	case <-hub.done:
+		conn.Close()
+		return
+	}
 
 	// Start pumps in goroutines
 	go client.writePump()
@@ -283,4 +319,5 @@ const (
 	WSEventNoteUpdated   = "NOTE_UPDATED"
 	WSEventNoteDeleted   = "NOTE_DELETED"
 	WSEventVisitClosed   = "VISIT_CLOSED"
+	WSEventMention       = "MENTION"
 )
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"autolytiq/shared/logging"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestHubCloseDisconnectsClients(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	hub := NewHub(logging.New(logging.Config{
+		Service: "showroom-service-test",
+		Level:   logging.LevelError,
+		Output:  &bytes.Buffer{},
+	}))
+	go hub.Run()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWS(hub, w, r)
+	}))
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), wsIdentity("user-1", "dealer-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hub.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("Expected a going away close frame, got %v", err)
+	}
+	conn.Close()
+	server.Close()
+
+	// Broadcasts after Close must not block, even with the queue full
+	for i := 0; i < 300; i++ {
+		hub.Broadcast("dealer-1", WSEventVisitCreated, nil)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the hub and client goroutines to exit, %d left running", runtime.NumGoroutine()-before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func wsIdentity(userID, dealershipID string) http.Header {
+	return http.Header{"X-User-ID": {userID}, "X-Dealership-ID": {dealershipID}}
+}
+
+func readWSMessage(t *testing.T, conn *websocket.Conn) WSMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg WSMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("Expected a message: %v", err)
+	}
+	return msg
+}
+
+func TestServeWSScopesToDealership(t *testing.T) {
+	hub := NewHub(logging.New(logging.Config{
+		Service: "showroom-service-test",
+		Level:   logging.LevelError,
+		Output:  &bytes.Buffer{},
+	}))
+	go hub.Run()
+	defer hub.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWS(hub, w, r)
+	}))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	// Upgrades without the gateway's identity are rejected
+	for _, header := range []http.Header{nil, {"X-User-ID": {"user-1"}}, {"X-Dealership-ID": {"dealer-1"}}} {
+		_, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+		if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for headers %v, got %v", header, resp)
+		}
+	}
+
+	own, _, err := websocket.DefaultDialer.Dial(wsURL, wsIdentity("user-1", "dealer-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer own.Close()
+	other, _, err := websocket.DefaultDialer.Dial(wsURL, wsIdentity("user-2", "dealer-2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	own.WriteJSON(SubscribeMessage{Type: "SUBSCRIBE", DealershipID: "dealer-1"})
+	if msg := readWSMessage(t, own); msg.Type != "SUBSCRIBED" {
+		t.Errorf("Expected the subscription to be confirmed, got %s", msg.Type)
+	}
+
+	// Subscribing to another dealership doesn't move the connection
+	other.WriteJSON(SubscribeMessage{Type: "SUBSCRIBE", DealershipID: "dealer-1"})
+	if msg := readWSMessage(t, other); msg.Type != "ERROR" {
+		t.Errorf("Expected an error for another dealership, got %s", msg.Type)
+	}
+
+	hub.Broadcast("dealer-1", WSEventVisitCreated, map[string]string{"visit_id": "visit-1"})
+	hub.Broadcast("dealer-2", WSEventVisitCreated, map[string]string{"visit_id": "visit-2"})
+
+	if msg := readWSMessage(t, own); msg.Type != WSEventVisitCreated {
+		t.Errorf("Expected the dealership's event, got %s", msg.Type)
+	}
+	msg := readWSMessage(t, other)
+	data, _ := json.Marshal(msg.Data)
+	if msg.Type != WSEventVisitCreated || !strings.Contains(string(data), "visit-2") {
+		t.Errorf("Expected only dealer-2's event, got %s %s", msg.Type, data)
+	}
+}
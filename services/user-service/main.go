@@ -1,13 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
 	"strconv"
 
+	"autolytiq/shared/graceful"
 	"autolytiq/shared/logging"
+	"autolytiq/shared/tracing"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -53,6 +56,13 @@ func main() {
 	// Setup router
 	router := mux.NewRouter()
 
+	// Trace requests when OTEL_EXPORTER_OTLP_ENDPOINT is set
+	tracer := tracing.New(tracing.Config{
+		Service: "user-service",
+		OnError: func(err error) { logger.WithError(err).Warn("Failed to export trace spans") },
+	})
+	defer tracer.Shutdown(context.Background())
+	router.Use(tracer.Middleware)
 	// Apply middleware
 	router.Use(logging.RequestIDMiddleware)
 	router.Use(logging.RequestLoggingMiddleware(logger))
@@ -96,8 +106,13 @@ func main() {
 
 	// Start server
 	port := getEnv("PORT", "8080")
+	// Stop serving on SIGINT or SIGTERM, once in-flight requests finish
+	ctx, stop := graceful.SignalContext(context.Background())
+	defer stop()
+
+	srv := &http.Server{Addr: ":" + port, Handler: router}
 	logger.Infof("User service listening on port %s", port)
-	if err := http.ListenAndServe(":"+port, router); err != nil {
+	if err := graceful.ListenAndServe(ctx, srv, graceful.DefaultTimeout); err != nil {
 		logger.Fatal(err.Error())
 	}
 }
@@ -315,11 +330,8 @@ func updateRoleHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req struct {
-		Role string `json:"role"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid request body")
+	var req UpdateRoleRequest
+	if !decodeAndValidateStatus(r, w, &req, http.StatusUnprocessableEntity) {
 		return
 	}
 
@@ -360,12 +372,8 @@ func updatePasswordHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req struct {
-		OldPassword string `json:"old_password"`
-		NewPassword string `json:"new_password"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid request body")
+	var req UpdatePasswordRequest
+	if !decodeAndValidateStatus(r, w, &req, http.StatusUnprocessableEntity) {
 		return
 	}
 
@@ -455,12 +463,8 @@ func getPreferencesHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func validateEmailHandler(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Email        string    `json:"email"`
-		DealershipID uuid.UUID `json:"dealership_id"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid request body")
+	var req ValidateEmailRequest
+	if !decodeAndValidateStatus(r, w, &req, http.StatusUnprocessableEntity) {
 		return
 	}
 
@@ -9,6 +9,8 @@ import (
 	"testing"
 	"time"
 
+	"autolytiq/shared/logging"
+
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
@@ -245,6 +247,11 @@ func (m *MockDatabase) GetActivity(userID uuid.UUID, limit int) ([]UserActivity,
 func setupTest() (*mux.Router, *MockDatabase) {
 	mockDB := NewMockDatabase()
 	db = mockDB
+	logger = logging.New(logging.Config{
+		Service: "user-service-test",
+		Level:   logging.LevelError,
+		Output:  &bytes.Buffer{},
+	})
 
 	router := mux.NewRouter()
 	router.HandleFunc("/health", healthHandler).Methods("GET")
@@ -559,7 +566,7 @@ func TestUpdatePassword(t *testing.T) {
 
 	dealershipID := uuid.New()
 	oldPassword := "password123"
-	newPassword := "newpassword123"
+	newPassword := "NewPassword123"
 
 	user, _ := mockDB.CreateUser(CreateUserRequest{
 		DealershipID: dealershipID,
@@ -610,7 +617,7 @@ func TestUpdatePasswordInvalidOldPassword(t *testing.T) {
 
 	reqBody := map[string]string{
 		"old_password": "wrongpassword",
-		"new_password": "newpassword123",
+		"new_password": "NewPassword123",
 	}
 	body, _ := json.Marshal(reqBody)
 	url := fmt.Sprintf("/users/%s/password?dealership_id=%s", user.ID, dealershipID)
@@ -806,19 +813,19 @@ func TestMultiTenantIsolation(t *testing.T) {
 
 func TestRolePermissions(t *testing.T) {
 	// Test role permissions
-	if !HasPermission(RoleAdmin, PermissionManageUsers) {
+	if !RoleHasPermission(RoleAdmin, PermissionManageUsers) {
 		t.Error("Admin should have manage_users permission")
 	}
 
-	if HasPermission(RoleSalesperson, PermissionManageUsers) {
+	if RoleHasPermission(RoleSalesperson, PermissionManageUsers) {
 		t.Error("Salesperson should not have manage_users permission")
 	}
 
-	if !HasPermission(RoleManager, PermissionViewAll) {
+	if !RoleHasPermission(RoleManager, PermissionViewAll) {
 		t.Error("Manager should have view_all permission")
 	}
 
-	if !HasPermission(RoleSalesperson, PermissionViewOwn) {
+	if !RoleHasPermission(RoleSalesperson, PermissionViewOwn) {
 		t.Error("Salesperson should have view_own permission")
 	}
 }
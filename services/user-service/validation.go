@@ -6,6 +6,8 @@ import (
 	"regexp"
 	"strings"
 	"unicode"
+
+	"github.com/google/uuid"
 )
 
 // ValidationError represents a single validation error
@@ -192,6 +194,119 @@ func (r *UpdateUserRequest) Sanitize() {
 	}
 }
 
+// UpdateRoleRequest is the request body for changing a user's role
+type UpdateRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// Validate validates UpdateRoleRequest
+func (r *UpdateRoleRequest) Validate() *ValidationErrors {
+	var errors []ValidationError
+
+	if r.Role == "" {
+		errors = append(errors, ValidationError{
+			Field:   "role",
+			Message: "Role is required",
+		})
+	} else if !validRoles[r.Role] {
+		errors = append(errors, ValidationError{
+			Field:   "role",
+			Message: "Invalid role",
+		})
+	}
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize sanitizes UpdateRoleRequest
+func (r *UpdateRoleRequest) Sanitize() {
+	r.Role = strings.TrimSpace(strings.ToLower(r.Role))
+}
+
+// UpdatePasswordRequest is the request body for changing a user's password
+type UpdatePasswordRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+// Validate validates UpdatePasswordRequest. Passwords are not sanitized, so
+// surrounding whitespace is part of the password.
+func (r *UpdatePasswordRequest) Validate() *ValidationErrors {
+	var errors []ValidationError
+
+	if r.OldPassword == "" {
+		errors = append(errors, ValidationError{
+			Field:   "old_password",
+			Message: "Current password is required",
+		})
+	}
+
+	if strings.TrimSpace(r.NewPassword) == "" {
+		errors = append(errors, ValidationError{
+			Field:   "new_password",
+			Message: "New password is required",
+		})
+	} else if len(r.NewPassword) < 8 {
+		errors = append(errors, ValidationError{
+			Field:   "new_password",
+			Message: "Password must be at least 8 characters",
+		})
+	} else if !isStrongPassword(r.NewPassword) {
+		errors = append(errors, ValidationError{
+			Field:   "new_password",
+			Message: "Password must contain at least one uppercase letter, one lowercase letter, and one number",
+		})
+	}
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// ValidateEmailRequest is the request body for checking whether an email is taken
+type ValidateEmailRequest struct {
+	Email        string    `json:"email"`
+	DealershipID uuid.UUID `json:"dealership_id"`
+}
+
+// Validate validates ValidateEmailRequest
+func (r *ValidateEmailRequest) Validate() *ValidationErrors {
+	var errors []ValidationError
+
+	if r.DealershipID == uuid.Nil {
+		errors = append(errors, ValidationError{
+			Field:   "dealership_id",
+			Message: "Dealership ID is required",
+		})
+	}
+
+	if r.Email == "" {
+		errors = append(errors, ValidationError{
+			Field:   "email",
+			Message: "Email is required",
+		})
+	} else if !emailRegex.MatchString(r.Email) {
+		errors = append(errors, ValidationError{
+			Field:   "email",
+			Message: "Must be a valid email address",
+		})
+	}
+
+	if len(errors) > 0 {
+		return &ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// Sanitize sanitizes ValidateEmailRequest
+func (r *ValidateEmailRequest) Sanitize() {
+	r.Email = strings.TrimSpace(strings.ToLower(r.Email))
+}
+
 func isStrongPassword(password string) bool {
 	if len(password) < 8 {
 		return false
@@ -214,8 +329,13 @@ func isStrongPassword(password string) bool {
 
 // respondValidationErrorUser writes a validation error response
 func respondValidationErrorUser(w http.ResponseWriter, errors *ValidationErrors) {
+	respondValidationErrorStatus(w, http.StatusBadRequest, errors)
+}
+
+// respondValidationErrorStatus writes a validation error response with the given status
+func respondValidationErrorStatus(w http.ResponseWriter, status int, errors *ValidationErrors) {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusBadRequest)
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(ValidationErrorResponse{
 		Error:   "Validation failed",
 		Code:    "VALIDATION_ERROR",
@@ -235,6 +355,12 @@ type Sanitizable interface {
 
 // decodeAndValidateUser decodes JSON and validates the request
 func decodeAndValidateUser(r *http.Request, w http.ResponseWriter, dst interface{}) bool {
+	return decodeAndValidateStatus(r, w, dst, http.StatusBadRequest)
+}
+
+// decodeAndValidateStatus decodes JSON and validates the request, responding
+// with status when validation fails
+func decodeAndValidateStatus(r *http.Request, w http.ResponseWriter, dst interface{}, status int) bool {
 	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
 		respondError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
 		return false
@@ -248,7 +374,7 @@ func decodeAndValidateUser(r *http.Request, w http.ResponseWriter, dst interface
 	// Validate if applicable
 	if validatable, ok := dst.(Validatable); ok {
 		if errs := validatable.Validate(); errs != nil {
-			respondValidationErrorUser(w, errs)
+			respondValidationErrorStatus(w, status, errs)
 			return false
 		}
 	}
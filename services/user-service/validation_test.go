@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// expectFieldErrors sends body and checks for a 422 naming the given fields
+func expectFieldErrors(t *testing.T, router http.Handler, method, url string, body interface{}, fields ...string) {
+	t.Helper()
+
+	payload, _ := json.Marshal(body)
+	req, _ := http.NewRequest(method, url, bytes.NewBuffer(payload))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status 422 for %s, got %d: %s", payload, rr.Code, rr.Body.String())
+	}
+
+	var resp ValidationErrorResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp.Code != "VALIDATION_ERROR" {
+		t.Errorf("Expected code VALIDATION_ERROR, got %q", resp.Code)
+	}
+	got := make(map[string]bool)
+	for _, detail := range resp.Details {
+		got[detail.Field] = true
+	}
+	if len(got) != len(fields) {
+		t.Errorf("Expected errors for %v, got %+v", fields, resp.Details)
+	}
+	for _, field := range fields {
+		if !got[field] {
+			t.Errorf("Expected an error for %s, got %+v", field, resp.Details)
+		}
+	}
+}
+
+func TestUpdateRoleValidation(t *testing.T) {
+	router, mockDB := setupTest()
+
+	dealershipID := uuid.New()
+	user, _ := mockDB.CreateUser(CreateUserRequest{
+		DealershipID: dealershipID,
+		Email:        "test@example.com",
+		Name:         "Test User",
+		Password:     "password123",
+		Role:         RoleSalesperson,
+	})
+	url := fmt.Sprintf("/users/%s/role?dealership_id=%s", user.ID, dealershipID)
+
+	expectFieldErrors(t, router, "PUT", url, map[string]string{}, "role")
+	expectFieldErrors(t, router, "PUT", url, map[string]string{"role": "  "}, "role")
+	expectFieldErrors(t, router, "PUT", url, map[string]string{"role": "superuser"}, "role")
+
+	stored, _ := mockDB.GetUser(user.ID, dealershipID)
+	if stored.Role != RoleSalesperson {
+		t.Errorf("Expected role to be unchanged, got %s", stored.Role)
+	}
+}
+
+func TestUpdatePasswordValidation(t *testing.T) {
+	router, mockDB := setupTest()
+
+	dealershipID := uuid.New()
+	user, _ := mockDB.CreateUser(CreateUserRequest{
+		DealershipID: dealershipID,
+		Email:        "test@example.com",
+		Name:         "Test User",
+		Password:     "password123",
+		Role:         RoleAdmin,
+	})
+	url := fmt.Sprintf("/users/%s/password?dealership_id=%s", user.ID, dealershipID)
+
+	expectFieldErrors(t, router, "POST", url, map[string]string{}, "old_password", "new_password")
+	expectFieldErrors(t, router, "POST", url, map[string]string{"old_password": "password123", "new_password": "        "}, "new_password")
+	expectFieldErrors(t, router, "POST", url, map[string]string{"old_password": "password123", "new_password": "Short1"}, "new_password")
+	expectFieldErrors(t, router, "POST", url, map[string]string{"old_password": "password123", "new_password": "alllowercase1"}, "new_password")
+
+	if valid, _ := mockDB.ValidatePassword(user.ID, dealershipID, "password123"); !valid {
+		t.Error("Expected password to be unchanged")
+	}
+}
+
+func TestValidateEmailValidation(t *testing.T) {
+	router, _ := setupTest()
+
+	expectFieldErrors(t, router, "POST", "/users/validate-email", map[string]interface{}{}, "email", "dealership_id")
+	expectFieldErrors(t, router, "POST", "/users/validate-email", map[string]interface{}{
+		"email":         "not-an-email",
+		"dealership_id": uuid.New(),
+	}, "email")
+}